@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// recordAudit adds an action taken through the CLI to the audit log, as the invoking user.
+// The action has already taken effect, so a failure to record it is only reported.
+func recordAudit(ctx context.Context, db *database.DB, entry database.AuditEntry) {
+	entry.Actor = operatorUser()
+	if entry.Actor == "" {
+		entry.Actor = "unknown"
+	}
+	entry.Source = database.AuditSourceCLI
+	if err := db.RecordAudit(ctx, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// recordConfigLoaded records the configuration the daemon started with in the audit log,
+// by its path and a hash of its contents, so configuration changes can be told apart
+func recordConfigLoaded(ctx context.Context, db *database.DB, configPath string, cfg *config.Config, logger *logrus.Logger) {
+	details := database.JSONB{"path": configPath, "nodes": len(cfg.Nodes)}
+	if data, err := os.ReadFile(configPath); err == nil {
+		sum := sha256.Sum256(data)
+		details["sha256"] = hex.EncodeToString(sum[:])
+	}
+
+	if err := db.RecordAudit(ctx, database.AuditEntry{
+		Actor:   daemonHost(),
+		Source:  database.AuditSourceDaemon,
+		Action:  database.AuditConfigLoaded,
+		Message: "daemon started with " + configPath,
+		Details: details,
+	}); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "main",
+			"error":     err.Error(),
+		}).Warn("Failed to record audit entry")
+	}
+}
+
+// handleAuditCommand handles 'snapperd audit', printing the audit log of actions that
+// changed the daemon's state (uploads started or cancelled, nodes paused, registered or
+// purged, snoozes, configuration loads, migrations) with who took them, most recent first
+func handleAuditCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	since := fs.String("since", "7d", "Only show entries within this window (e.g. 7d, 12h)")
+	node := fs.String("node", "", "Only show entries for this node")
+	actor := fs.String("actor", "", "Only show entries by this actor")
+	action := fs.String("action", "", "Only show entries of this action (e.g. node_paused)")
+	source := fs.String("source", "", "Only show entries from this source: cli, node_api, snooze or daemon")
+	limit := fs.Int("limit", 50, "Maximum number of entries to show (0 = no limit)")
+	output := fs.String("output", "table", "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %v\n", fs.Args())
+		fmt.Fprintf(os.Stderr, "Usage: snapperd audit [--since <window>] [--node <node>] [--actor <actor>] [--action <action>] [--source <source>] [--limit <n>] [--output table|json]\n")
+		return 1
+	}
+
+	if *limit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --limit must not be negative\n")
+		return 1
+	}
+	switch *output {
+	case "table", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table or json)\n", *output)
+		return 1
+	}
+	window, err := parseSince(*since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	entries, err := db.ListAuditLog(ctx, database.AuditFilter{
+		NodeName: *node,
+		Actor:    *actor,
+		Action:   *action,
+		Source:   *source,
+		Since:    time.Now().Add(-window),
+		Limit:    *limit,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *output == "json" {
+		if entries == nil {
+			entries = []database.AuditEntry{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No audit entries in the last %s\n", *since)
+		return 0
+	}
+
+	fmt.Printf("Audit log (last %s, most recent first):\n", *since)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TIME\tACTOR\tSOURCE\tACTION\tNODE\tUPLOAD\tMESSAGE")
+	for _, e := range entries {
+		nodeName := "-"
+		if e.NodeName != nil {
+			nodeName = *e.NodeName
+		}
+		uploadID := "-"
+		if e.UploadID != nil {
+			uploadID = strconv.FormatInt(*e.UploadID, 10)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.Actor, e.Source, e.Action, nodeName, uploadID, e.Message)
+	}
+	w.Flush()
+	return 0
+}
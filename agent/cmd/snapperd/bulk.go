@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/logger"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// bulkOutcome records the result of a bulk operation for one node
+type bulkOutcome struct {
+	node   string
+	result string // e.g. "cancelled", "not running", "failed"
+	detail string
+	failed bool
+}
+
+// bulkEnv holds the dependencies shared by the bulk cancel and requeue commands
+type bulkEnv struct {
+	cfg              *config.Config
+	db               *database.DB
+	protocolRegistry *protocol.Registry
+	uploadMgr        *upload.Manager
+}
+
+// selectNodes resolves the target nodes from --all, --protocol or explicit node names
+func selectNodes(cfg *config.Config, registry *protocol.Registry, all bool, protocolName string, names []string) ([]string, error) {
+	modes := 0
+	if all {
+		modes++
+	}
+	if protocolName != "" {
+		modes++
+	}
+	if len(names) > 0 {
+		modes++
+	}
+	if modes != 1 {
+		return nil, fmt.Errorf("specify exactly one of --all, --protocol <name>, or node names")
+	}
+
+	var selected []string
+	switch {
+	case all:
+		for nodeName := range cfg.Nodes {
+			selected = append(selected, nodeName)
+		}
+	case protocolName != "":
+		// Resolve through the registry so aliases (e.g. arbitrum-one) match their module
+		target, err := registry.Get(protocolName)
+		if err != nil {
+			return nil, err
+		}
+		for nodeName, nodeConfig := range cfg.Nodes {
+			if module, err := registry.Get(nodeConfig.Protocol); err == nil && module.Name() == target.Name() {
+				selected = append(selected, nodeName)
+			}
+		}
+	default:
+		for _, nodeName := range names {
+			if _, exists := cfg.Nodes[nodeName]; !exists {
+				return nil, fmt.Errorf("node '%s' not found in configuration", nodeName)
+			}
+			selected = append(selected, nodeName)
+		}
+	}
+
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// newBulkEnv loads configuration and connects the database and upload manager
+func newBulkEnv(ctx context.Context, configPath string, log *logger.Logger) (*bulkEnv, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	protocolRegistry, err := newProtocolRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	exec, err := newExecutor(cfg, log.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	uploadMgr := newUploadManager(exec, db, cfg, log.Logger)
+
+	return &bulkEnv{
+		cfg:              cfg,
+		db:               db,
+		protocolRegistry: protocolRegistry,
+		uploadMgr:        uploadMgr,
+	}, nil
+}
+
+// printBulkSummary prints per-node outcomes followed by totals, returning the exit code
+func printBulkSummary(operation string, outcomes []bulkOutcome) int {
+	counts := make(map[string]int)
+	var order []string
+	failed := false
+
+	fmt.Printf("%s results:\n", operation)
+	for _, outcome := range outcomes {
+		if outcome.detail != "" {
+			fmt.Printf("  %-30s %-12s %s\n", outcome.node, outcome.result, outcome.detail)
+		} else {
+			fmt.Printf("  %-30s %s\n", outcome.node, outcome.result)
+		}
+		if counts[outcome.result] == 0 {
+			order = append(order, outcome.result)
+		}
+		counts[outcome.result]++
+		failed = failed || outcome.failed
+	}
+
+	fmt.Printf("\nSummary: %d nodes", len(outcomes))
+	for _, result := range order {
+		fmt.Printf(", %d %s", counts[result], result)
+	}
+	fmt.Println()
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// handleCancelCommand handles 'snapperd cancel', stopping running uploads on the selected nodes
+func handleCancelCommand(configPath string, consoleMode bool, args []string) int {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Cancel uploads on all configured nodes")
+	protocolName := fs.String("protocol", "", "Cancel uploads on all nodes of this protocol")
+	reason := fs.String("reason", "cancelled by operator", "Reason recorded on cancelled uploads")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	log := logger.New(logger.Config{
+		Level:       "warn",
+		ConsoleMode: consoleMode,
+	})
+
+	ctx := context.Background()
+	env, err := newBulkEnv(ctx, configPath, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer env.db.Close()
+
+	nodes, err := selectNodes(env.cfg, env.protocolRegistry, *all, *protocolName, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Usage: snapperd cancel [--reason <text>] (--all | --protocol <name> | <node>...)\n")
+		return 1
+	}
+
+	outcomes := make([]bulkOutcome, 0, len(nodes))
+	for _, nodeName := range nodes {
+		if nodeConfig := env.cfg.Nodes[nodeName]; runsInProcess(nodeConfig) {
+			outcomes = append(outcomes, bulkOutcome{node: nodeName, result: "skipped", detail: nodeConfig.GetEngine() + " uploads can only be stopped by the daemon running them", failed: true})
+			continue
+		}
+		uploadID, err := env.uploadMgr.CancelUpload(ctx, nodeName, *reason)
+		switch {
+		case errors.Is(err, upload.ErrNoRunningUpload):
+			outcomes = append(outcomes, bulkOutcome{node: nodeName, result: "not running"})
+		case err != nil:
+			log.WithFields(logrus.Fields{
+				"component": "cancel",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Error("Failed to cancel upload")
+			outcomes = append(outcomes, bulkOutcome{node: nodeName, result: "failed", detail: err.Error(), failed: true})
+		case uploadID == 0:
+			recordAudit(ctx, env.db, database.AuditEntry{Action: database.AuditUploadCancelled, NodeName: &nodeName, Message: "untracked bv job stopped: " + *reason})
+			outcomes = append(outcomes, bulkOutcome{node: nodeName, result: "cancelled", detail: "untracked bv job stopped"})
+		default:
+			recordAudit(ctx, env.db, database.AuditEntry{Action: database.AuditUploadCancelled, NodeName: &nodeName, UploadID: &uploadID, Message: "cancelled: " + *reason})
+			outcomes = append(outcomes, bulkOutcome{node: nodeName, result: "cancelled", detail: fmt.Sprintf("upload %d", uploadID)})
+		}
+	}
+
+	return printBulkSummary("Cancel", outcomes)
+}
+
+// handleRequeueCommand handles 'snapperd requeue', starting a new upload on the selected nodes
+func handleRequeueCommand(configPath string, consoleMode bool, args []string) int {
+	fs := flag.NewFlagSet("requeue", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Requeue uploads on all configured nodes")
+	protocolName := fs.String("protocol", "", "Requeue uploads on all nodes of this protocol")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	log := logger.New(logger.Config{
+		Level:       "warn",
+		ConsoleMode: consoleMode,
+	})
+
+	ctx := context.Background()
+	env, err := newBulkEnv(ctx, configPath, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer env.db.Close()
+
+	nodes, err := selectNodes(env.cfg, env.protocolRegistry, *all, *protocolName, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Usage: snapperd requeue (--all | --protocol <name> | <node>...)\n")
+		return 1
+	}
+
+	outcomes := make([]bulkOutcome, 0, len(nodes))
+	for _, nodeName := range nodes {
+		outcome := env.requeueNode(ctx, nodeName, log)
+		outcomes = append(outcomes, outcome)
+	}
+
+	return printBulkSummary("Requeue", outcomes)
+}
+
+// requeueNode starts a new upload for a node unless one is already running
+func (e *bulkEnv) requeueNode(ctx context.Context, nodeName string, log *logger.Logger) bulkOutcome {
+	nodeConfig := e.cfg.Nodes[nodeName]
+	if runsInProcess(nodeConfig) {
+		return bulkOutcome{node: nodeName, result: "skipped", detail: nodeConfig.GetEngine() + " uploads are started by the daemon", failed: true}
+	}
+
+	shouldSkip, err := e.uploadMgr.ShouldSkipUpload(ctx, nodeName)
+	if err != nil {
+		return bulkOutcome{node: nodeName, result: "failed", detail: err.Error(), failed: true}
+	}
+	if shouldSkip {
+		return bulkOutcome{node: nodeName, result: "running", detail: "upload already in progress"}
+	}
+
+	protocolModule, err := e.protocolRegistry.Get(nodeConfig.Protocol)
+	if err != nil {
+		return bulkOutcome{node: nodeName, result: "failed", detail: err.Error(), failed: true}
+	}
+
+	metrics, err := protocolModule.CollectMetrics(ctx, nodeConfig)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "requeue",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to collect metrics, continuing with partial data")
+		metrics = map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	metrics = protocol.WithMetadata(metrics, nodeConfig)
+
+	uploadID, err := e.uploadMgr.InitiateUploadWithProtocolData(ctx, nodeName, operatorTrigger("requeue", ""), nodeConfig.Protocol, nodeConfig.Type, metrics)
+	if err != nil {
+		return bulkOutcome{node: nodeName, result: "failed", detail: err.Error(), failed: true}
+	}
+	recordAudit(ctx, e.db, database.AuditEntry{Action: database.AuditUploadInitiated, NodeName: &nodeName, UploadID: &uploadID, Message: "requeued"})
+
+	return bulkOutcome{node: nodeName, result: "requeued", detail: fmt.Sprintf("upload %d", uploadID)}
+}
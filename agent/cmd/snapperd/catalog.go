@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/summary"
+)
+
+// handleCatalogCommand handles 'snapperd catalog', printing the freshest completed
+// snapshot of each protocol, network and node type
+func handleCatalogCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("catalog", flag.ContinueOnError)
+	protocolName := fs.String("protocol", "", "Only list snapshots of this protocol")
+	network := fs.String("network", "", "Only list snapshots of this network")
+	nodeType := fs.String("type", "", "Only list snapshots of this node type")
+	tenant := fs.String("tenant", "", "Only list snapshots of this tenant's nodes")
+	asJSON := fs.Bool("json", false, "Print the catalog as JSON, as served at "+summary.CatalogPath)
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: snapd catalog [--protocol <protocol>] [--network <network>] [--type <type>] [--tenant <tenant>] [--json]\n")
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if _, err := tenantConfig(cfg, *tenant); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	catalog, err := summary.NewBuilder(db, cfg, daemonHost()).Catalog(ctx, database.CatalogFilter{
+		Protocol: *protocolName,
+		Network:  *network,
+		NodeType: *nodeType,
+		Tenant:   *tenant,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(catalog); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(catalog.Snapshots) == 0 {
+		fmt.Println("No snapshots in the catalog")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTOCOL\tNETWORK\tTYPE\tNODE\tUPLOAD\tBLOCK\tSLOT\tSIZE\tAGE\tLOCATION")
+	for _, s := range catalog.Snapshots {
+		size := "-"
+		if s.SizeBytes != nil {
+			size = formatBytes(*s.SizeBytes)
+		}
+		location := "-"
+		if s.Location != nil {
+			location = *s.Location
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			s.Protocol, orDash(s.Network), orDash(s.NodeType), s.NodeName, s.UploadID,
+			formatOptionalInt(s.LatestBlock), formatOptionalInt(s.LatestSlot), size,
+			(time.Duration(s.AgeSeconds) * time.Second).Round(time.Minute), location)
+	}
+	w.Flush()
+	return 0
+}
+
+// formatOptionalInt formats n, or "-" when it is nil
+func formatOptionalInt(n *int64) string {
+	if n == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *n)
+}
+
+// orDash returns s, or "-" when it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
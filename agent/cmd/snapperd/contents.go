@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// contentEntry is one snapshot object as printed by the contents command
+type contentEntry struct {
+	Key       string  `json:"key"`
+	SizeBytes *int64  `json:"size_bytes,omitempty"`
+	Checksum  *string `json:"checksum,omitempty"`
+}
+
+// formatSize renders the entry's size for table and CSV output
+func (e contentEntry) formatSize(empty string) string {
+	if e.SizeBytes == nil {
+		return empty
+	}
+	return strconv.FormatInt(*e.SizeBytes, 10)
+}
+
+// formatChecksum renders the entry's checksum for table and CSV output
+func (e contentEntry) formatChecksum(empty string) string {
+	if e.Checksum == nil {
+		return empty
+	}
+	return *e.Checksum
+}
+
+// handleContentsCommand handles 'snapperd contents <upload-id>', listing the objects
+// recorded for a completed snapshot
+func handleContentsCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("contents", flag.ContinueOnError)
+	output := fs.String("output", "table", "Output format: table, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: contents command requires an upload ID\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapd contents [--output table|json|csv] <upload-id>\n")
+		return 1
+	}
+	uploadID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || uploadID <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid upload ID '%s'\n", fs.Arg(0))
+		return 1
+	}
+	switch *output {
+	case "table", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table, json or csv)\n", *output)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	record, err := db.GetUpload(ctx, uploadID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if record == nil {
+		fmt.Fprintf(os.Stderr, "Error: upload %d not found\n", uploadID)
+		return 1
+	}
+
+	objects, err := db.GetUploadObjects(ctx, uploadID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	entries := make([]contentEntry, 0, len(objects))
+	for _, object := range objects {
+		entries = append(entries, contentEntry{Key: object.ObjectKey, SizeBytes: object.SizeBytes, Checksum: object.Checksum})
+	}
+
+	switch *output {
+	case "json":
+		err = printContentsJSON(entries)
+	case "csv":
+		err = printContentsCSV(entries)
+	default:
+		err = printContentsTable(record, entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// printContentsTable prints a summary of the upload followed by its objects
+func printContentsTable(record *database.Upload, entries []contentEntry) error {
+	fmt.Printf("Upload %d (%s, %s)\n", record.ID, record.NodeName, record.Status)
+	if len(entries) == 0 {
+		fmt.Println("No content listing recorded.")
+		return nil
+	}
+
+	var totalBytes int64
+	for _, e := range entries {
+		if e.SizeBytes != nil {
+			totalBytes += *e.SizeBytes
+		}
+	}
+	fmt.Printf("Objects: %d, total size: %d bytes\n\n", len(entries), totalBytes)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSIZE\tCHECKSUM")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Key, e.formatSize("-"), e.formatChecksum("-"))
+	}
+	return w.Flush()
+}
+
+// printContentsJSON prints entries as a JSON array
+func printContentsJSON(entries []contentEntry) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// printContentsCSV prints entries as CSV with a header row
+func printContentsCSV(entries []contentEntry) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"key", "size_bytes", "checksum"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := w.Write([]string{e.Key, e.formatSize(""), e.formatChecksum("")}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
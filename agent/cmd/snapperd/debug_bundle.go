@@ -0,0 +1,430 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Debug bundle item statuses
+const (
+	bundleItemOK      = "ok"
+	bundleItemError   = "error"
+	bundleItemSkipped = "skipped"
+)
+
+// bundleManifest describes a debug bundle and what could be collected into it
+type bundleManifest struct {
+	CreatedAt  time.Time    `json:"created_at"`
+	Since      time.Time    `json:"since"` // Start of the collected time window
+	Host       string       `json:"host"`
+	Version    string       `json:"version"`
+	Commit     string       `json:"commit"`
+	ConfigPath string       `json:"config_path"`
+	Items      []bundleItem `json:"items"`
+}
+
+// bundleItem is one file of a debug bundle and the outcome of collecting it
+type bundleItem struct {
+	File   string `json:"file"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bundleEnvironment describes the host and process the bundle was collected on
+type bundleEnvironment struct {
+	Version               string            `json:"version"`
+	BuildDate             string            `json:"build_date"`
+	Commit                string            `json:"commit"`
+	GoVersion             string            `json:"go_version"`
+	OS                    string            `json:"os"`
+	Arch                  string            `json:"arch"`
+	Host                  string            `json:"host"`
+	UID                   int               `json:"uid"`
+	ConfigPath            string            `json:"config_path"`
+	PluginDir             string            `json:"plugin_dir"`
+	NotificationPluginDir string            `json:"notification_plugin_dir"`
+	Env                   map[string]string `json:"env"` // Secrets redacted
+}
+
+// debugBundle collects files into a bundle, recording the outcome of each
+type debugBundle struct {
+	files    map[string][]byte
+	manifest bundleManifest
+}
+
+// collect adds the output of fn to the bundle as file. Once ctx has expired, remaining
+// items are skipped so the bundle is still written within the time limit.
+func (b *debugBundle) collect(ctx context.Context, file string, fn func(ctx context.Context) ([]byte, error)) {
+	item := bundleItem{File: file, Status: bundleItemOK}
+	if ctx.Err() != nil {
+		item.Status = bundleItemSkipped
+		item.Error = "time limit reached"
+		b.manifest.Items = append(b.manifest.Items, item)
+		return
+	}
+
+	data, err := fn(ctx)
+	if err != nil {
+		item.Status = bundleItemError
+		item.Error = err.Error()
+	}
+	if len(data) > 0 {
+		b.files[file] = data
+	}
+	b.manifest.Items = append(b.manifest.Items, item)
+}
+
+// skip records an item that could not be collected because it depends on a failed step
+func (b *debugBundle) skip(file, reason string) {
+	b.manifest.Items = append(b.manifest.Items, bundleItem{File: file, Status: bundleItemSkipped, Error: reason})
+}
+
+// handleDebugBundleCommand handles 'snapperd debug-bundle', collecting the logs,
+// redacted configuration, recent database records, bv version and environment that
+// support needs into one tarball
+func handleDebugBundleCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("debug-bundle", flag.ContinueOnError)
+	output := fs.String("output", "", "Bundle path (default snapperd-debug-<host>-<time>.tar.gz in the current directory)")
+	sinceFlag := fs.String("since", "24h", "Collect logs and uploads from this far back (e.g. 7d, 12h, 90m)")
+	timeout := fs.Duration("timeout", 2*time.Minute, "Maximum time spent collecting; items not collected by then are skipped")
+	uploadLimit := fs.Int("uploads", 50, "Maximum number of finished uploads to include")
+	unit := fs.String("unit", "snapperd", "systemd unit whose journal is included")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: snapd debug-bundle [--output path] [--since 24h] [--timeout 2m] [--uploads 50] [--unit snapperd]\n")
+		return 1
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *timeout <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --timeout must be positive\n")
+		return 1
+	}
+	if *uploadLimit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --uploads cannot be negative\n")
+		return 1
+	}
+
+	now := time.Now()
+	host := daemonHost()
+	path := *output
+	if path == "" {
+		path = fmt.Sprintf("snapperd-debug-%s-%s.tar.gz", host, now.Format("20060102-150405"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	bundle := &debugBundle{
+		files: make(map[string][]byte),
+		manifest: bundleManifest{
+			CreatedAt:  now.UTC(),
+			Since:      now.Add(-since).UTC(),
+			Host:       host,
+			Version:    version,
+			Commit:     commitHash,
+			ConfigPath: configPath,
+		},
+	}
+	collectDebugBundle(ctx, bundle, configPath, *unit, *uploadLimit)
+
+	if err := writeDebugBundle(path, bundle); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	collected := 0
+	for _, item := range bundle.manifest.Items {
+		if item.Status == bundleItemOK {
+			collected++
+			continue
+		}
+		fmt.Printf("  %s: %s (%s)\n", item.File, item.Status, item.Error)
+	}
+	fmt.Printf("Debug bundle written to %s (%d/%d items collected)\n", path, collected, len(bundle.manifest.Items))
+	fmt.Printf("Secrets are redacted, but review the bundle before sharing it.\n")
+	return 0
+}
+
+// collectDebugBundle gathers every item of the bundle. Items that fail are recorded in
+// the manifest and the remaining items are still collected, so a bundle can be taken
+// from a host with a broken configuration or an unreachable database.
+func collectDebugBundle(ctx context.Context, bundle *debugBundle, configPath, unit string, uploadLimit int) {
+	since := bundle.manifest.Since
+
+	bundle.collect(ctx, "environment.json", func(ctx context.Context) ([]byte, error) {
+		return marshalBundleJSON(collectEnvironment(bundle.manifest.Host, configPath))
+	})
+
+	bundle.collect(ctx, "config.yaml", func(ctx context.Context) ([]byte, error) {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		return config.RedactYAML(data)
+	})
+
+	// Commands fail in the bundle's manifest rather than the executor's log
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg, cfgErr := config.LoadConfig(configPath)
+	bundle.collect(ctx, "config-effective.yaml", func(ctx context.Context) ([]byte, error) {
+		if cfgErr != nil {
+			return nil, cfgErr
+		}
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return config.RedactYAML(data)
+	})
+
+	// Without a valid configuration or executor settings, bv is run without the configured
+	// command prefix and sandbox
+	var exec *executor.DefaultExecutor
+	if cfg != nil {
+		exec, _ = newExecutor(cfg, log)
+	}
+	if exec == nil {
+		exec, _ = newExecutor(&config.Config{}, log)
+	}
+	exec.Allow("journalctl")
+
+	bundle.collect(ctx, "bv-version.txt", func(ctx context.Context) ([]byte, error) {
+		stdout, stderr, err := exec.Execute(ctx, "bv", "--version")
+		return []byte(stdout + stderr), err
+	})
+
+	bundle.collect(ctx, "logs.txt", func(ctx context.Context) ([]byte, error) {
+		stdout, stderr, err := exec.Execute(ctx, "journalctl", "-u", unit, "--since", since.Local().Format("2006-01-02 15:04:05"), "--no-pager", "-o", "short-iso")
+		if err != nil && stderr != "" {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+		}
+		return []byte(stdout), err
+	})
+
+	databaseFiles := []string{
+		"database/uploads.json",
+		"database/schedule_state.json",
+		"database/upload_queue.json",
+		"database/snoozes.json",
+		"database/daemon_heartbeat.json",
+		"database/registered_nodes.json",
+	}
+	if cfgErr != nil {
+		for _, file := range databaseFiles {
+			bundle.skip(file, "configuration could not be loaded")
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		for _, file := range databaseFiles {
+			bundle.skip(file, "time limit reached")
+		}
+		return
+	}
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		for _, file := range databaseFiles {
+			bundle.skip(file, err.Error())
+		}
+		return
+	}
+	defer db.Close()
+
+	bundle.collect(ctx, "database/uploads.json", func(ctx context.Context) ([]byte, error) {
+		return collectBundleUploads(ctx, db, since, uploadLimit)
+	})
+	bundle.collect(ctx, "database/schedule_state.json", func(ctx context.Context) ([]byte, error) {
+		states, err := db.GetScheduleStates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return marshalBundleJSON(states)
+	})
+	bundle.collect(ctx, "database/upload_queue.json", func(ctx context.Context) ([]byte, error) {
+		requests, err := db.ListUploadQueue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return marshalBundleJSON(requests)
+	})
+	bundle.collect(ctx, "database/snoozes.json", func(ctx context.Context) ([]byte, error) {
+		snoozes, err := db.GetActiveNotificationSnoozes(ctx, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return marshalBundleJSON(snoozes)
+	})
+	bundle.collect(ctx, "database/daemon_heartbeat.json", func(ctx context.Context) ([]byte, error) {
+		heartbeat, err := db.GetDaemonHeartbeat(ctx, bundle.manifest.Host)
+		if err != nil {
+			return nil, err
+		}
+		return marshalBundleJSON(heartbeat)
+	})
+	bundle.collect(ctx, "database/registered_nodes.json", func(ctx context.Context) ([]byte, error) {
+		nodes, err := db.ListRegisteredNodes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Registered configs can hold webhook URLs like the configuration file
+		for i := range nodes {
+			redacted, err := config.RedactYAML([]byte(nodes[i].Config))
+			if err != nil {
+				return nil, err
+			}
+			nodes[i].Config = string(redacted)
+		}
+		return marshalBundleJSON(nodes)
+	})
+}
+
+// collectBundleUploads returns the full records, as printed by the show command, of the
+// running uploads and of the most recent uploads started within the window
+func collectBundleUploads(ctx context.Context, db *database.DB, since time.Time, limit int) ([]byte, error) {
+	running, err := db.GetRunningUploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var finished []database.Upload
+	if limit > 0 {
+		finished, err = db.ListUploads(ctx, database.UploadFilter{Since: since, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]showEntry, 0, len(running)+len(finished))
+	for _, uploads := range [][]database.Upload{running, finished} {
+		for i := range uploads {
+			entry, err := buildShowEntry(ctx, db, &uploads[i])
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return marshalBundleJSON(entries)
+}
+
+// collectEnvironment describes the running binary, the host and the process environment,
+// with the values of variables that hold secrets redacted
+func collectEnvironment(host, configPath string) bundleEnvironment {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		switch {
+		case config.IsSensitiveKey(name):
+			value = config.Redacted
+		case strings.Contains(value, "://"):
+			value = config.RedactURL(value)
+		}
+		env[name] = value
+	}
+
+	return bundleEnvironment{
+		Version:               version,
+		BuildDate:             buildDate,
+		Commit:                commitHash,
+		GoVersion:             runtime.Version(),
+		OS:                    runtime.GOOS,
+		Arch:                  runtime.GOARCH,
+		Host:                  host,
+		UID:                   os.Getuid(),
+		ConfigPath:            configPath,
+		PluginDir:             protocolPluginDir,
+		NotificationPluginDir: notificationPluginDir,
+		Env:                   env,
+	}
+}
+
+// marshalBundleJSON renders a bundle item as indented JSON
+func marshalBundleJSON(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// writeDebugBundle writes the collected files and the manifest as a gzipped tarball
+// readable only by the current user
+func writeDebugBundle(path string, bundle *debugBundle) error {
+	manifest, err := marshalBundleJSON(bundle.manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	names := make([]string, 0, len(bundle.files))
+	for name := range bundle.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	prefix := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".tar") + "/"
+
+	write := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    prefix + name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: bundle.manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := write("manifest.json", manifest); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	for _, name := range names {
+		if err := write(name, bundle.files[name]); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return f.Close()
+}
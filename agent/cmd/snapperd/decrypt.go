@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/engine/s3"
+)
+
+// handleDecryptCommand handles 'snapperd decrypt', decrypting an archive uploaded by the
+// s3 engine with encryption, read from a file or standard input, to standard output
+func handleDecryptCommand(args []string) int {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "File with the key the archive was encrypted with")
+	manifestPath := fs.String("manifest", "", "The archive's manifest, for archives encrypted with a KMS data key")
+	region := fs.String("region", "", "KMS region (default the region of the KMS key ARN, or us-east-1)")
+	kmsEndpoint := fs.String("kms-endpoint", "", "KMS endpoint URL (default AWS for the region)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() > 1 || (*keyFile == "") == (*manifestPath == "") {
+		fmt.Fprintf(os.Stderr, "Error: decrypt requires either --key-file or --manifest\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapd decrypt --key-file <file> | --manifest <manifest.json> [archive]\n")
+		return 1
+	}
+
+	var key []byte
+	var err error
+	if *keyFile != "" {
+		if key, err = s3.LoadKey(*keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else {
+		if key, err = manifestDataKey(*manifestPath, *region, *kmsEndpoint); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	in := io.Reader(os.Stdin)
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r, err := s3.NewDecryptor(bufio.NewReaderSize(in, 1<<20), key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	out := bufio.NewWriterSize(os.Stdout, 1<<20)
+	if _, err := io.Copy(out, r); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// manifestDataKey decrypts the KMS data key recorded in an archive's manifest, with the
+// credentials in the AWS_* environment variables
+func manifestDataKey(path, region, endpoint string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m struct {
+		Encryption *s3.ManifestEncryption `json:"encryption"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if m.Encryption == nil {
+		return nil, fmt.Errorf("the archive of %s is not encrypted", path)
+	}
+	if len(m.Encryption.EncryptedDataKey) == 0 {
+		return nil, fmt.Errorf("the archive is encrypted with key %s, not a KMS data key; use --key-file", m.Encryption.KeyID)
+	}
+
+	// A KMS key ARN names its region: arn:aws:kms:<region>:<account>:key/<id>
+	if region == "" {
+		if fields := strings.Split(m.Encryption.KeyID, ":"); len(fields) > 3 && fields[0] == "arn" {
+			region = fields[3]
+		} else {
+			region = s3.DefaultRegion
+		}
+	}
+	return s3.DecryptDataKey(context.Background(), endpoint, region, s3.EnvCredentials(), m.Encryption.EncryptedDataKey)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/engine/rclone"
+	"github.com/nodexeus/agent/internal/engine/s3"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/nodexeus/agent/internal/kube"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// runsInProcess reports whether a node's uploads run inside the snapperd process that
+// starts them, so no other process can follow or stop them
+func runsInProcess(nodeConfig config.NodeConfig) bool {
+	return nodeConfig.GetEngine() != engine.BV
+}
+
+// stoppableEngine is an engine whose uploads run in this process
+type stoppableEngine interface {
+	engine.Engine
+	// Stop stops the engine's running uploads and waits for them to exit
+	Stop()
+}
+
+// nodeEngine is the engine created for a node and the settings it was created with
+type nodeEngine struct {
+	settings interface{}
+	engine   stoppableEngine
+}
+
+// rcloneSettings are the settings an rclone engine is created with
+type rcloneSettings struct {
+	rclone    config.RcloneConfig
+	container *config.ContainerConfig // Container rclone runs in (nil for the host)
+	args      map[string]string       // Engine arguments, added as rclone flags
+}
+
+// s3Settings are the settings an s3 engine is created with
+type s3Settings struct {
+	s3          config.S3Config
+	compression config.CompressionConfig
+	args        map[string]string // Engine arguments, set as headers of the archive
+}
+
+// nodeEngines sets the engine of each node that does not upload through bv. A node
+// keeps its engine, and with it a running transfer, while its settings are unchanged.
+type nodeEngines struct {
+	runner      engine.Runner
+	uploadMgr   *upload.Manager
+	checkpoints engine.CheckpointStore
+	kube        *kube.Client // Runs the commands of nodes in pods
+	logger      *logrus.Logger
+
+	mu      sync.Mutex
+	engines map[string]nodeEngine
+}
+
+// newNodeEngines creates the engine set of an upload manager, checkpointing uploads in
+// the database
+func newNodeEngines(runner engine.Runner, uploadMgr *upload.Manager, db *database.DB, logger *logrus.Logger) *nodeEngines {
+	return &nodeEngines{
+		runner:      runner,
+		uploadMgr:   uploadMgr,
+		checkpoints: &checkpointStore{db: db},
+		logger:      logger,
+		engines:     make(map[string]nodeEngine),
+	}
+}
+
+// setKubeClient sets the Kubernetes API client running the commands of nodes in pods
+func (n *nodeEngines) setKubeClient(client *kube.Client) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.kube = client
+}
+
+// configure sets the engine that runs a node's uploads, and the executor of its
+// commands, from its configuration
+func (n *nodeEngines) configure(nodeName string, nodeConfig config.NodeConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	runner := n.runner
+	switch c := nodeConfig.Container; {
+	case c != nil && c.GetRuntime() == kube.RuntimeKubernetes:
+		podExec := kube.NewPodExecutor(n.kube, c.Namespace, c.Pod, c.Name, n.logger)
+		n.uploadMgr.SetNodeExecutor(nodeName, podExec)
+		runner = podExec
+	case c != nil:
+		containerExec := executor.NewContainerExecutor(n.runner, c.Executor(), n.logger)
+		n.uploadMgr.SetNodeExecutor(nodeName, containerExec)
+		runner = containerExec
+	default:
+		n.uploadMgr.SetNodeExecutor(nodeName, nil)
+	}
+
+	var bvFlags []string
+	if nodeConfig.GetEngine() == engine.BV {
+		bvFlags = engine.Flags(nodeConfig.EngineArgs)
+	}
+	n.uploadMgr.SetNodeBVFlags(nodeName, bvFlags)
+	n.uploadMgr.SetNodeTenant(nodeName, nodeConfig.Tenant)
+
+	var settings interface{}
+	switch {
+	case nodeConfig.GetEngine() == engine.Rclone && nodeConfig.Rclone != nil:
+		settings = rcloneSettings{rclone: *nodeConfig.Rclone, container: nodeConfig.Container, args: nodeConfig.EngineArgs}
+	case nodeConfig.GetEngine() == engine.S3 && nodeConfig.S3 != nil:
+		settings = s3Settings{s3: *nodeConfig.S3, compression: nodeConfig.GetCompression(), args: nodeConfig.EngineArgs}
+	}
+
+	existing, exists := n.engines[nodeName]
+	if exists && settings != nil && reflect.DeepEqual(existing.settings, settings) {
+		return
+	}
+	// A transfer started with the previous settings can no longer be tracked
+	if exists {
+		existing.engine.Stop()
+		delete(n.engines, nodeName)
+	}
+
+	var e stoppableEngine
+	switch settings := settings.(type) {
+	case rcloneSettings:
+		e = rclone.New(runner, rclone.Config{
+			Binary:      settings.rclone.Binary,
+			Mode:        settings.rclone.Mode,
+			Source:      settings.rclone.Source,
+			Destination: settings.rclone.Destination,
+			Flags:       append(slices.Clone(settings.rclone.Flags), engine.Flags(settings.args)...),
+			LogDir:      settings.rclone.LogDir,
+			RemoteLog:   settings.container != nil,
+		}, n.logger)
+	case s3Settings:
+		s3Engine, err := newS3Engine(settings.s3, settings.compression, settings.args, n.checkpoints, n.logger)
+		if err != nil {
+			n.logger.WithFields(logrus.Fields{
+				"component": "main",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Error("Failed to create S3 upload engine")
+			break
+		}
+		e = s3Engine
+	}
+	if e == nil {
+		n.uploadMgr.SetNodeEngine(nodeName, nil)
+		return
+	}
+	n.engines[nodeName] = nodeEngine{settings: settings, engine: e}
+	n.uploadMgr.SetNodeEngine(nodeName, e)
+}
+
+// newS3Engine creates the s3 engine of a node's settings
+func newS3Engine(settings config.S3Config, compression config.CompressionConfig, args map[string]string, checkpoints engine.CheckpointStore, logger *logrus.Logger) (*s3.Engine, error) {
+	partSize, err := settings.GetPartSize()
+	if err != nil {
+		return nil, err
+	}
+	var encryption *s3.Encryption
+	if e := settings.Encryption; e != nil {
+		encryption = &s3.Encryption{KeyID: e.KeyID, KMSKeyID: e.KMSKeyID, KMSEndpoint: e.KMSEndpoint}
+		if e.KeyFile != "" {
+			if encryption.Key, err = s3.LoadKey(e.KeyFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s3.New(s3.Config{
+		Source:      settings.Source,
+		Endpoint:    settings.Endpoint,
+		Region:      settings.Region,
+		Bucket:      settings.Bucket,
+		Key:         settings.Key,
+		PathStyle:   settings.PathStyle,
+		PartSize:    partSize,
+		Concurrency: settings.Concurrency,
+		Compression: compression.Algorithm,
+		Level:       compression.Level,
+		Encryption:  encryption,
+		Args:        args,
+		Checkpoints: checkpoints,
+	}, logger)
+}
+
+// checkpointStore adapts the database's upload checkpoints to engine.CheckpointStore
+type checkpointStore struct {
+	db *database.DB
+}
+
+// GetCheckpoint reads a node's checkpoint with its chunks
+func (s *checkpointStore) GetCheckpoint(ctx context.Context, nodeName string) (*engine.Checkpoint, error) {
+	checkpoint, err := s.db.GetUploadCheckpoint(ctx, nodeName)
+	if err != nil || checkpoint == nil {
+		return nil, err
+	}
+	chunks, err := s.db.GetCheckpointChunks(ctx, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &engine.Checkpoint{Engine: checkpoint.Engine, Session: checkpoint.Session}
+	for _, c := range chunks {
+		result.Chunks = append(result.Chunks, engine.Chunk{Number: c.ChunkNumber, ETag: c.ETag, Size: c.SizeBytes, Checksum: c.Checksum})
+	}
+	return result, nil
+}
+
+// SaveCheckpoint replaces a node's checkpoint
+func (s *checkpointStore) SaveCheckpoint(ctx context.Context, nodeName string, checkpoint engine.Checkpoint) error {
+	now := time.Now()
+	chunks := make([]database.CheckpointChunk, 0, len(checkpoint.Chunks))
+	for _, c := range checkpoint.Chunks {
+		chunks = append(chunks, checkpointChunk(nodeName, c, now))
+	}
+	return s.db.SaveUploadCheckpoint(ctx, database.UploadCheckpoint{
+		NodeName:  nodeName,
+		Engine:    checkpoint.Engine,
+		Session:   checkpoint.Session,
+		CreatedAt: now,
+	}, chunks)
+}
+
+// AddCheckpointChunk records an uploaded chunk
+func (s *checkpointStore) AddCheckpointChunk(ctx context.Context, nodeName string, chunk engine.Chunk) error {
+	return s.db.AddCheckpointChunk(ctx, checkpointChunk(nodeName, chunk, time.Now()))
+}
+
+// DeleteCheckpoint removes a node's checkpoint
+func (s *checkpointStore) DeleteCheckpoint(ctx context.Context, nodeName string) error {
+	return s.db.DeleteUploadCheckpoint(ctx, nodeName)
+}
+
+// checkpointChunk converts an engine chunk into its database row
+func checkpointChunk(nodeName string, c engine.Chunk, uploadedAt time.Time) database.CheckpointChunk {
+	return database.CheckpointChunk{
+		NodeName:    nodeName,
+		ChunkNumber: c.Number,
+		ETag:        c.ETag,
+		SizeBytes:   c.Size,
+		Checksum:    c.Checksum,
+		UploadedAt:  uploadedAt,
+	}
+}
+
+// stop stops the running transfers of every engine
+func (n *nodeEngines) stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, e := range n.engines {
+		e.engine.Stop()
+	}
+}
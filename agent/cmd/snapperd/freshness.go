@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// snapshotAge is the age of a node's last successful upload as shown by the status command
+type snapshotAge struct {
+	node     string
+	uploadID int64         // 0 when the node never completed an upload
+	age      time.Duration // Time since the upload completed
+	maxAge   time.Duration // The node's max_snapshot_age, 0 if not checked
+	size     *int64        // Bytes uploaded, when the engine reported them
+}
+
+// stale reports whether the snapshot is older than the node's max_snapshot_age
+func (s snapshotAge) stale() bool {
+	return s.maxAge > 0 && s.uploadID != 0 && s.age > s.maxAge
+}
+
+// findSnapshotAges returns the age of every configured node's last successful upload
+func findSnapshotAges(ctx context.Context, db *database.DB, cfg *config.Config, now time.Time) ([]snapshotAge, error) {
+	ages := make([]snapshotAge, 0, len(cfg.Nodes))
+	for nodeName := range cfg.Nodes {
+		entry := snapshotAge{node: nodeName, maxAge: cfg.GetMaxSnapshotAge(nodeName)}
+
+		last, err := db.GetLatestCompletedUploadForNode(ctx, nodeName)
+		if err != nil {
+			return nil, err
+		}
+		if age, known := scheduler.SnapshotAge(last, now); known {
+			entry.uploadID = last.ID
+			entry.age = age
+			entry.size = last.SizeBytes
+		}
+		ages = append(ages, entry)
+	}
+
+	sort.Slice(ages, func(i, k int) bool { return ages[i].node < ages[k].node })
+	return ages, nil
+}
+
+// printSnapshotAges prints how old each node's last successful snapshot is, flagging
+// snapshots older than max_snapshot_age
+func printSnapshotAges(ages []snapshotAge) {
+	if len(ages) == 0 {
+		return
+	}
+
+	fmt.Printf("\nLast successful snapshot:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range ages {
+		if s.uploadID == 0 {
+			fmt.Fprintf(w, "  %s\t-\tnone completed\n", s.node)
+			continue
+		}
+
+		size := "-"
+		if s.size != nil {
+			size = formatBytes(*s.size)
+		}
+		line := fmt.Sprintf("  %s\tupload %d\t%s ago\t%s", s.node, s.uploadID, s.age.Round(time.Minute), size)
+		if s.stale() {
+			line += fmt.Sprintf("\tSTALE (max_snapshot_age %s)", s.maxAge)
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+}
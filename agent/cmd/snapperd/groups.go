@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// groupRunEntry is one consistency group run as printed by the groups command
+type groupRunEntry struct {
+	RunID     int64              `json:"run_id"`
+	Group     string             `json:"group"`
+	StartedAt time.Time          `json:"started_at"`
+	Status    string             `json:"status"`
+	Error     *string            `json:"error,omitempty"`
+	Members   []groupMemberEntry `json:"members"`
+}
+
+// groupMemberEntry is a member node of a group run and the upload started for it
+type groupMemberEntry struct {
+	Node         string                 `json:"node"`
+	UploadID     *int64                 `json:"upload_id,omitempty"`
+	UploadStatus string                 `json:"upload_status,omitempty"`
+	Anchor       map[string]interface{} `json:"anchor,omitempty"` // Chain position when the run started
+}
+
+// formatUploadID renders the member's upload ID for table and CSV output
+func (m groupMemberEntry) formatUploadID(empty string) string {
+	if m.UploadID == nil {
+		return empty
+	}
+	return strconv.FormatInt(*m.UploadID, 10)
+}
+
+// formatAnchor renders the member's chain position as "key=value" pairs
+func (m groupMemberEntry) formatAnchor() string {
+	keys := make([]string, 0, len(m.Anchor))
+	for key := range m.Anchor {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, m.Anchor[key]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// handleGroupsCommand handles 'snapperd groups', listing consistency group runs and the
+// uploads started together in each run
+func handleGroupsCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+	group := fs.String("group", "", "Only show runs of this consistency group")
+	limit := fs.Int("limit", 20, "Maximum number of runs to show (0 = no limit)")
+	output := fs.String("output", "table", "Output format: table, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if *limit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --limit must not be negative\n")
+		return 1
+	}
+	switch *output {
+	case "table", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table, json or csv)\n", *output)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if *group != "" {
+		if _, exists := cfg.ConsistencyGroups[*group]; !exists {
+			fmt.Fprintf(os.Stderr, "Error: consistency group '%s' not found in configuration\n", *group)
+			return 1
+		}
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	runs, err := db.ListConsistencyGroupRuns(ctx, *group, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	entries := make([]groupRunEntry, 0, len(runs))
+	for _, run := range runs {
+		entry, err := newGroupRunEntry(ctx, db, cfg, run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		entries = append(entries, entry)
+	}
+
+	switch *output {
+	case "json":
+		err = printGroupsJSON(entries)
+	case "csv":
+		err = printGroupsCSV(entries)
+	default:
+		err = printGroupsTable(entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// newGroupRunEntry combines a group run with its members' uploads. Members whose upload
+// was not started are listed without one.
+func newGroupRunEntry(ctx context.Context, db *database.DB, cfg *config.Config, run database.ConsistencyGroupRun) (groupRunEntry, error) {
+	entry := groupRunEntry{
+		RunID:     run.ID,
+		Group:     run.GroupName,
+		StartedAt: run.StartedAt,
+		Status:    run.Status,
+		Error:     run.ErrorMessage,
+	}
+
+	uploads, err := db.GetConsistencyGroupUploads(ctx, run.ID)
+	if err != nil {
+		return entry, err
+	}
+	uploadByNode := make(map[string]int64, len(uploads))
+	for _, u := range uploads {
+		uploadByNode[u.NodeName] = u.UploadID
+	}
+
+	// Members come from the recorded anchors and uploads, falling back to the configuration
+	nodes := make(map[string]bool)
+	for node := range run.Anchors {
+		nodes[node] = true
+	}
+	for node := range uploadByNode {
+		nodes[node] = true
+	}
+	if len(nodes) == 0 {
+		for _, node := range cfg.ConsistencyGroups[run.GroupName].Nodes {
+			nodes[node] = true
+		}
+	}
+	nodeNames := make([]string, 0, len(nodes))
+	for node := range nodes {
+		nodeNames = append(nodeNames, node)
+	}
+	sort.Strings(nodeNames)
+
+	for _, node := range nodeNames {
+		member := groupMemberEntry{Node: node}
+		if anchor, ok := run.Anchors[node].(map[string]interface{}); ok && len(anchor) > 0 {
+			member.Anchor = anchor
+		}
+		if uploadID, ok := uploadByNode[node]; ok {
+			member.UploadID = &uploadID
+			record, err := db.GetUpload(ctx, uploadID)
+			if err != nil {
+				return entry, err
+			}
+			if record != nil {
+				member.UploadStatus = record.Status
+			}
+		}
+		entry.Members = append(entry.Members, member)
+	}
+
+	return entry, nil
+}
+
+// printGroupsTable prints each run followed by its members
+func printGroupsTable(entries []groupRunEntry) error {
+	if len(entries) == 0 {
+		fmt.Println("No consistency group runs recorded.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Run %d\t%s\t%s\t%s\n", e.RunID, e.Group, e.StartedAt.Local().Format("2006-01-02 15:04:05"), e.Status)
+		if e.Error != nil {
+			fmt.Fprintf(w, "  error: %s\n", *e.Error)
+		}
+		for _, m := range e.Members {
+			status := m.UploadStatus
+			if m.UploadID == nil {
+				status = "not started"
+			}
+			fmt.Fprintf(w, "  %s\tupload %s\t%s\t%s\n", m.Node, m.formatUploadID("-"), status, m.formatAnchor())
+		}
+	}
+	return w.Flush()
+}
+
+// printGroupsJSON prints entries as a JSON array
+func printGroupsJSON(entries []groupRunEntry) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// printGroupsCSV prints one row per run member with a header row
+func printGroupsCSV(entries []groupRunEntry) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"run_id", "group", "started_at", "status", "node", "upload_id", "upload_status", "anchor"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		for _, m := range e.Members {
+			record := []string{
+				strconv.FormatInt(e.RunID, 10), e.Group, e.StartedAt.Format(time.RFC3339), e.Status,
+				m.Node, m.formatUploadID(""), m.UploadStatus, m.formatAnchor(),
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/upload"
+)
+
+// historyEntry is one upload as printed by the history command
+type historyEntry struct {
+	ID                  int64                  `json:"id"`
+	Node                string                 `json:"node"`
+	Protocol            string                 `json:"protocol"`
+	Status              string                 `json:"status"`
+	Trigger             string                 `json:"trigger"`
+	TriggerMetadata     map[string]interface{} `json:"trigger_metadata,omitempty"`
+	StartedAt           time.Time              `json:"started_at"`
+	CompletedAt         *time.Time             `json:"completed_at,omitempty"`
+	DurationSeconds     *int64                 `json:"duration_seconds,omitempty"`
+	ChunksCompleted     *int                   `json:"chunks_completed,omitempty"`
+	ChunksTotal         *int                   `json:"chunks_total,omitempty"`
+	Error               *string                `json:"error,omitempty"`
+	DetectionLagSeconds *float64               `json:"detection_lag_seconds,omitempty"` // Time from bv finishing the job to the monitor detecting it
+	BaseUploadID        *int64                 `json:"base_upload_id,omitempty"`        // Base snapshot of an incremental upload
+	RestoreVerification *string                `json:"restore_verification,omitempty"`  // Outcome of spot-restoring the snapshot: verified or failed
+	Compression         *string                `json:"compression,omitempty"`           // Algorithm, for engines that report compression
+	CompressionLevel    *int                   `json:"compression_level,omitempty"`     // 0 for the algorithm's default
+	RawSizeBytes        *int64                 `json:"raw_size_bytes,omitempty"`        // Size before compression
+	CompressedSizeBytes *int64                 `json:"compressed_size_bytes,omitempty"` // Size uploaded
+	CompressionRatio    *float64               `json:"compression_ratio,omitempty"`
+	SizeBytes           *int64                 `json:"size_bytes,omitempty"` // Bytes uploaded, for engines that report it
+	LatestBlock         interface{}            `json:"latest_block,omitempty"`
+	Agent               *string                `json:"agent,omitempty"` // Host of the snapperd that recorded the upload
+}
+
+// parseSince parses a lookback window such as "7d", "12h" or "90m"
+func parseSince(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid --since value '%s'", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid --since value '%s'", value)
+	}
+	return d, nil
+}
+
+// newHistoryEntry converts a database upload into a history entry
+func newHistoryEntry(u database.Upload) historyEntry {
+	entry := historyEntry{
+		ID:                  u.ID,
+		Node:                u.NodeName,
+		Protocol:            u.Protocol,
+		Status:              u.Status,
+		Trigger:             u.TriggerType,
+		TriggerMetadata:     u.TriggerMetadata,
+		StartedAt:           u.StartedAt,
+		CompletedAt:         u.CompletedAt,
+		ChunksCompleted:     u.ChunksCompleted,
+		ChunksTotal:         u.ChunksTotal,
+		Error:               u.ErrorMessage,
+		DetectionLagSeconds: u.DetectionLagSeconds,
+		BaseUploadID:        u.BaseUploadID,
+		RestoreVerification: u.RestoreVerification,
+		Compression:         u.Compression,
+		CompressionLevel:    u.CompressionLevel,
+		RawSizeBytes:        u.RawSizeBytes,
+		CompressedSizeBytes: u.CompressedSizeBytes,
+		SizeBytes:           u.SizeBytes,
+		LatestBlock:         u.ProtocolData["latest_block"],
+		Agent:               u.Agent,
+	}
+	if u.RawSizeBytes != nil && u.CompressedSizeBytes != nil && *u.CompressedSizeBytes > 0 {
+		ratio := math.Round(float64(*u.RawSizeBytes)/float64(*u.CompressedSizeBytes)*100) / 100
+		entry.CompressionRatio = &ratio
+	}
+	if u.CompletedAt != nil {
+		seconds := int64(u.CompletedAt.Sub(u.StartedAt).Round(time.Second).Seconds())
+		entry.DurationSeconds = &seconds
+	}
+	return entry
+}
+
+// formatDuration renders the entry's duration for table and CSV output
+func (e historyEntry) formatDuration() string {
+	if e.DurationSeconds == nil {
+		return "-"
+	}
+	return (time.Duration(*e.DurationSeconds) * time.Second).String()
+}
+
+// formatChunks renders the entry's chunk counts for table and CSV output
+func (e historyEntry) formatChunks() string {
+	switch {
+	case e.ChunksCompleted != nil && e.ChunksTotal != nil:
+		return fmt.Sprintf("%d/%d", *e.ChunksCompleted, *e.ChunksTotal)
+	case e.ChunksCompleted != nil:
+		return strconv.Itoa(*e.ChunksCompleted)
+	default:
+		return "-"
+	}
+}
+
+// formatTriggerMetadata renders the entry's trigger metadata as JSON for CSV output
+func (e historyEntry) formatTriggerMetadata() string {
+	if len(e.TriggerMetadata) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(e.TriggerMetadata)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// formatDetectionLag renders the entry's completion detection lag for CSV output
+func (e historyEntry) formatDetectionLag() string {
+	if e.DetectionLagSeconds == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*e.DetectionLagSeconds, 'f', 0, 64)
+}
+
+// formatBaseUpload renders the entry's base upload ID for CSV output
+func (e historyEntry) formatBaseUpload() string {
+	if e.BaseUploadID == nil {
+		return ""
+	}
+	return strconv.FormatInt(*e.BaseUploadID, 10)
+}
+
+// formatSize renders the entry's snapshot size for table output
+func (e historyEntry) formatSize() string {
+	if e.SizeBytes == nil {
+		return "-"
+	}
+	return formatBytes(*e.SizeBytes)
+}
+
+// formatBlock renders the latest block when the entry's upload started for table output
+func (e historyEntry) formatBlock() string {
+	if e.LatestBlock == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%v", e.LatestBlock)
+}
+
+// formatAgent renders the host that recorded the entry for table and CSV output
+func (e historyEntry) formatAgent(missing string) string {
+	if e.Agent == nil {
+		return missing
+	}
+	return *e.Agent
+}
+
+// formatBytes renders a byte count in binary units, e.g. "1.5 TiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatCompleted renders the entry's completion time for table and CSV output
+func (e historyEntry) formatCompleted() string {
+	if e.CompletedAt == nil {
+		return "-"
+	}
+	return e.CompletedAt.Format("2006-01-02 15:04:05")
+}
+
+// handleHistoryCommand handles 'snapperd history', listing past uploads from the database
+func handleHistoryCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	nodeName := fs.String("node", "", "Only show uploads for this node")
+	tenant := fs.String("tenant", "", "Only show uploads of this tenant's nodes")
+	status := fs.String("status", "", "Only show uploads with this status (default: all finished uploads)")
+	trigger := fs.String("trigger", "", "Only show uploads with this trigger type (scheduled, manual, external, api, queue, retry)")
+	since := fs.String("since", "", "Only show uploads started within this window (e.g. 7d, 12h)")
+	until := fs.String("until", "", "Only show uploads started before this long ago (e.g. 1d, 6h)")
+	minBlock := fs.Int64("min-block", 0, "Only show uploads whose latest_block is at or above this block")
+	maxBlock := fs.Int64("max-block", 0, "Only show uploads whose latest_block is at or below this block")
+	limit := fs.Int("limit", 50, "Maximum number of uploads to show (0 = no limit)")
+	offset := fs.Int("offset", 0, "Skip this many of the most recent matching uploads")
+	output := fs.String("output", "table", "Output format: table, json or csv")
+	columns := fs.String("columns", "", "Comma-separated table columns: "+strings.Join(config.HistoryColumns, ", ")+" (default output.history_columns)")
+	colorMode := fs.String("color", "", "Color statuses in the table: auto, always or never (default output.color)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	if *limit < 0 || *offset < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --limit and --offset must not be negative\n")
+		return 1
+	}
+	if *minBlock < 0 || *maxBlock < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --min-block and --max-block must not be negative\n")
+		return 1
+	}
+	if *maxBlock > 0 && *minBlock > *maxBlock {
+		fmt.Fprintf(os.Stderr, "Error: --min-block must not be above --max-block\n")
+		return 1
+	}
+	switch *output {
+	case "table", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table, json or csv)\n", *output)
+		return 1
+	}
+
+	filter := database.UploadFilter{
+		NodeName: *nodeName,
+		Tenant:   *tenant,
+		Status:   *status,
+		MinBlock: *minBlock,
+		MaxBlock: *maxBlock,
+		Limit:    *limit,
+		Offset:   *offset,
+	}
+	if *trigger != "" {
+		triggerType, err := upload.ParseTriggerType(*trigger)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		filter.Trigger = string(triggerType)
+	}
+	if *since != "" {
+		window, err := parseSince(*since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		filter.Since = time.Now().Add(-window)
+	}
+	if *until != "" {
+		window, err := parseSince(*until)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --until value '%s'\n", *until)
+			return 1
+		}
+		filter.Until = time.Now().Add(-window)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	tableColumns, err := resolveColumns(*columns, cfg.GetHistoryColumns(), config.HistoryColumns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	color, err := resolveColor(*colorMode, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	history, err := db.GetUploadHistory(ctx, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	entries := make([]historyEntry, 0, len(history.Uploads))
+	for _, u := range history.Uploads {
+		entries = append(entries, newHistoryEntry(u))
+	}
+
+	switch *output {
+	case "json":
+		err = printHistoryJSON(entries)
+	case "csv":
+		err = printHistoryCSV(entries)
+	default:
+		err = printHistoryTable(entries, tableColumns, color)
+		if err == nil {
+			printHistoryStats(history.Stats, *offset, len(entries))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// printHistoryTable prints entries as an aligned table of the chosen columns
+func printHistoryTable(entries []historyEntry, columns []string, color bool) error {
+	if len(entries) == 0 {
+		fmt.Println("No uploads found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = strings.ToUpper(column)
+		if column == "status" {
+			// Colored like the statuses below it, so the column stays aligned
+			header[i] = colorStatus("", header[i], color)
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, e := range entries {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = e.tableCell(column, color)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// printHistoryStats prints which of the matching uploads the table showed, with their
+// success rate and the average duration of the completed ones
+func printHistoryStats(stats database.UploadStats, offset, shown int) {
+	if stats.Total == 0 {
+		return
+	}
+	line := fmt.Sprintf("\n%d matching uploads", stats.Total)
+	if shown > 0 && shown < stats.Total {
+		line = fmt.Sprintf("\nShowing %d-%d of %d matching uploads", offset+1, offset+shown, stats.Total)
+	}
+	if stats.SuccessRate != nil {
+		line += fmt.Sprintf(", %.1f%% of finished uploads completed", *stats.SuccessRate*100)
+	}
+	if stats.AvgDurationSeconds != nil {
+		line += fmt.Sprintf(", average duration %s", (time.Duration(*stats.AvgDurationSeconds) * time.Second).Round(time.Second))
+	}
+	fmt.Println(line)
+}
+
+// tableCell renders one column of the entry for table output
+func (e historyEntry) tableCell(column string, color bool) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(e.ID, 10)
+	case "node":
+		return e.Node
+	case "protocol":
+		return e.Protocol
+	case "status":
+		return colorStatus(e.Status, e.Status, color)
+	case "trigger":
+		return e.Trigger
+	case "agent":
+		return e.formatAgent("-")
+	case "block":
+		return e.formatBlock()
+	case "started":
+		return e.StartedAt.Format("2006-01-02 15:04:05")
+	case "completed":
+		return e.formatCompleted()
+	case "duration":
+		return e.formatDuration()
+	case "chunks":
+		return e.formatChunks()
+	case "size":
+		return e.formatSize()
+	default:
+		return "-"
+	}
+}
+
+// printHistoryJSON prints entries as a JSON array
+func printHistoryJSON(entries []historyEntry) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// printHistoryCSV prints entries as CSV with a header row
+func printHistoryCSV(entries []historyEntry) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"id", "node", "protocol", "status", "trigger", "trigger_metadata", "started_at", "completed_at", "duration", "chunks", "detection_lag_seconds", "base_upload_id", "size_bytes", "agent"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		completedAt := ""
+		if e.CompletedAt != nil {
+			completedAt = e.CompletedAt.Format(time.RFC3339)
+		}
+		sizeBytes := ""
+		if e.SizeBytes != nil {
+			sizeBytes = strconv.FormatInt(*e.SizeBytes, 10)
+		}
+		record := []string{
+			strconv.FormatInt(e.ID, 10), e.Node, e.Protocol, e.Status, e.Trigger, e.formatTriggerMetadata(),
+			e.StartedAt.Format(time.RFC3339), completedAt, e.formatDuration(), e.formatChunks(), e.formatDetectionLag(),
+			e.formatBaseUpload(), sizeBytes, e.formatAgent(""),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
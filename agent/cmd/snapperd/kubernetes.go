@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/kube"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// leaseReleaseTimeout bounds giving up the leader Lease at shutdown
+const leaseReleaseTimeout = 10 * time.Second
+
+// errKubernetesNodes is returned when registering or deregistering a node while nodes
+// are discovered from Kubernetes
+var errKubernetesNodes = errors.New("nodes are discovered from Kubernetes pods")
+
+// newKubeClient creates the Kubernetes API client used to discover nodes, run their
+// commands and elect a leader through a Lease, or nil when the configuration needs none
+func newKubeClient(cfg *config.Config) (*kube.Client, error) {
+	switch {
+	case cfg.Kubernetes != nil:
+		return kube.NewClient(cfg.Kubernetes.Client())
+	case cfg.LeaderElection != nil && cfg.LeaderElection.GetBackend() == config.LeaderBackendKubernetes:
+		return kube.NewClient(kube.Config{})
+	}
+	return nil, nil
+}
+
+// kubeNodeStore is the node store of a daemon discovering its nodes from pods. Each
+// running pod matching the label selector and annotated with a node definition is a
+// node; its commands run in the pod, in the container named by its annotation, the
+// kubernetes section or, by default, its first container.
+type kubeNodeStore struct {
+	client *kube.Client
+	cfg    *config.KubernetesConfig
+}
+
+// ListAssignedNodes lists the nodes of the running pods. A node definition that fails
+// to parse is returned unchanged, so the registry skips it with a warning.
+func (s *kubeNodeStore) ListAssignedNodes(ctx context.Context, host string) ([]database.RegisteredNode, error) {
+	pods, err := s.client.ListPods(ctx, s.cfg.Namespace, s.cfg.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	// While a pod is replaced, its node runs in the newest of its running pods
+	sort.SliceStable(pods, func(i, j int) bool { return pods[i].CreatedAt.After(pods[j].CreatedAt) })
+	seen := make(map[string]bool)
+	var nodes []database.RegisteredNode
+	for _, pod := range pods {
+		definition, ok := pod.Annotations[config.KubernetesNodeConfigAnnotation]
+		if !ok || pod.Phase != "Running" || pod.Deleting {
+			continue
+		}
+		nodeName := pod.Annotations[config.KubernetesNodeNameAnnotation]
+		if nodeName == "" {
+			nodeName = pod.Name
+		}
+		if seen[nodeName] {
+			continue
+		}
+		seen[nodeName] = true
+
+		node := database.RegisteredNode{NodeName: nodeName, Config: definition, RegisteredAt: pod.CreatedAt, UpdatedAt: pod.CreatedAt}
+		if nodeConfig, err := config.ParseNodeConfig([]byte(definition)); err == nil {
+			nodeConfig.Container = &config.ContainerConfig{
+				Runtime:   kube.RuntimeKubernetes,
+				Name:      s.container(pod),
+				Namespace: pod.Namespace,
+				Pod:       pod.Name,
+			}
+			if encoded, err := config.EncodeNodeConfig(nodeConfig); err == nil {
+				node.Config = encoded
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// container returns the container of a pod that commands run in
+func (s *kubeNodeStore) container(pod kube.Pod) string {
+	if name := pod.Annotations[config.KubernetesContainerAnnotation]; name != "" {
+		return name
+	}
+	return s.cfg.Container
+}
+
+// GetRegisteredNode reports no node, as nodes are not registered in Kubernetes mode
+func (s *kubeNodeStore) GetRegisteredNode(ctx context.Context, nodeName string) (*database.RegisteredNode, error) {
+	return nil, nil
+}
+
+// SaveRegisteredNode refuses to register a node
+func (s *kubeNodeStore) SaveRegisteredNode(ctx context.Context, node database.RegisteredNode) error {
+	return errKubernetesNodes
+}
+
+// DeleteRegisteredNode refuses to deregister a node
+func (s *kubeNodeStore) DeleteRegisteredNode(ctx context.Context, nodeName string) error {
+	return errKubernetesNodes
+}
+
+// nodeStoreFor returns where the daemon's runtime nodes come from: pods in Kubernetes
+// mode, or the database
+func nodeStoreFor(cfg *config.Config, db *database.DB, client *kube.Client) scheduler.NodeStore {
+	if cfg.Kubernetes != nil {
+		return &kubeNodeStore{client: client, cfg: cfg.Kubernetes}
+	}
+	return db
+}
+
+// LeaseLockerAdapter elects the leader through a Kubernetes Lease, held by the pod the
+// daemon runs in
+type LeaseLockerAdapter struct {
+	client   *kube.Client
+	identity string
+	duration time.Duration
+}
+
+// TryAcquireLeaderLock takes the Lease named after the lock
+func (a *LeaseLockerAdapter) TryAcquireLeaderLock(ctx context.Context, name string) (scheduler.LeaderLock, error) {
+	lease, err := a.client.TryAcquireLease(ctx, "", name, a.identity, a.duration)
+	if err != nil || lease == nil {
+		return nil, err
+	}
+	return leaseLock{lease: lease}, nil
+}
+
+// leaseLock is a held leader Lease, renewed on every check
+type leaseLock struct {
+	lease *kube.Lease
+}
+
+// Check renews the Lease
+func (l leaseLock) Check(ctx context.Context) error {
+	return l.lease.Renew(ctx)
+}
+
+// Release gives up the Lease so a standby takes over at once
+func (l leaseLock) Release() error {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseReleaseTimeout)
+	defer cancel()
+	return l.lease.Release(ctx)
+}
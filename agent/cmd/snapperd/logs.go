@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// printUploadLogs prints the stored job log of a node's most recent upload that has one,
+// with stderr lines marked. Logs are captured when an upload finishes, so a running
+// upload shows the log of the node's previous one.
+func printUploadLogs(ctx context.Context, db *database.DB, nodeName string) error {
+	lines, err := db.GetLatestUploadLogs(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		fmt.Printf("No job log stored for %s (logs are captured when an upload finishes)\n", nodeName)
+		return nil
+	}
+
+	uploadID := lines[0].UploadID
+	header := fmt.Sprintf("Job log of upload %d", uploadID)
+	record, err := db.GetUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		header += fmt.Sprintf(" (%s, %s, started %s)", record.NodeName, record.Status, record.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("%s, last %d lines captured %s:\n", header, len(lines), lines[0].RecordedAt.Local().Format("2006-01-02 15:04:05"))
+	for _, line := range lines {
+		if line.Stream == "stderr" {
+			fmt.Printf("  [stderr] %s\n", line.Line)
+			continue
+		}
+		fmt.Printf("  %s\n", line.Line)
+	}
+	return nil
+}
@@ -4,19 +4,33 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+	// Embedded zone data, so timezone settings work on hosts without a zoneinfo database
+	_ "time/tzdata"
 
+	"github.com/nodexeus/agent/internal/analytics"
 	"github.com/nodexeus/agent/internal/config"
 	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/events"
 	"github.com/nodexeus/agent/internal/executor"
+	"github.com/nodexeus/agent/internal/health"
+	"github.com/nodexeus/agent/internal/hoststats"
 	"github.com/nodexeus/agent/internal/logger"
+	"github.com/nodexeus/agent/internal/metrics"
+	"github.com/nodexeus/agent/internal/nodeapi"
 	"github.com/nodexeus/agent/internal/notification"
 	"github.com/nodexeus/agent/internal/protocol"
 	"github.com/nodexeus/agent/internal/scheduler"
+	"github.com/nodexeus/agent/internal/snooze"
+	"github.com/nodexeus/agent/internal/summary"
+	"github.com/nodexeus/agent/internal/tracing"
 	"github.com/nodexeus/agent/internal/upload"
 	"github.com/sirupsen/logrus"
 )
@@ -33,20 +47,27 @@ type DatabaseAdapter struct {
 	db *database.DB
 }
 
-// CreateUpload adapts upload.Upload to database.Upload
-func (a *DatabaseAdapter) CreateUpload(ctx context.Context, u upload.Upload) (int64, error) {
+// CreateUploadIfNotRunning adapts upload.Upload to database.Upload
+func (a *DatabaseAdapter) CreateUploadIfNotRunning(ctx context.Context, u upload.Upload) (int64, bool, error) {
 	dbUpload := database.Upload{
 		NodeName:          u.NodeName,
 		Protocol:          u.Protocol,
 		NodeType:          u.NodeType,
 		StartedAt:         u.StartedAt,
 		Status:            u.Status,
-		TriggerType:       u.TriggerType,
+		TriggerType:       string(u.TriggerType),
+		TriggerMetadata:   database.JSONB(u.TriggerMetadata),
 		ErrorMessage:      u.ErrorMessage,
 		ProtocolData:      database.JSONB(u.ProtocolData),
 		CompletionMessage: u.CompletionMessage,
+		BaseUploadID:      u.BaseUploadID,
+		Agent:             u.Agent,
+		TraceParent:       u.TraceParent,
+		Tenant:            u.Tenant,
+		Attempt:           u.Attempt,
+		RetryOf:           u.RetryOf,
 	}
-	return a.db.CreateUpload(ctx, dbUpload)
+	return a.db.CreateUploadIfNotRunning(ctx, dbUpload)
 }
 
 // UpdateUpload adapts upload.Upload to database.Upload
@@ -59,7 +80,8 @@ func (a *DatabaseAdapter) UpdateUpload(ctx context.Context, u upload.Upload) err
 		StartedAt:         u.StartedAt,
 		CompletedAt:       u.CompletedAt,
 		Status:            u.Status,
-		TriggerType:       u.TriggerType,
+		TriggerType:       string(u.TriggerType),
+		TriggerMetadata:   database.JSONB(u.TriggerMetadata),
 		ErrorMessage:      u.ErrorMessage,
 		ProtocolData:      database.JSONB(u.ProtocolData),
 		CompletionMessage: u.CompletionMessage,
@@ -84,7 +106,8 @@ func (a *DatabaseAdapter) GetRunningUploadForNode(ctx context.Context, nodeName
 		StartedAt:         dbUpload.StartedAt,
 		CompletedAt:       dbUpload.CompletedAt,
 		Status:            dbUpload.Status,
-		TriggerType:       dbUpload.TriggerType,
+		TriggerType:       upload.TriggerType(dbUpload.TriggerType),
+		TriggerMetadata:   upload.JSONB(dbUpload.TriggerMetadata),
 		ErrorMessage:      dbUpload.ErrorMessage,
 		ProtocolData:      upload.JSONB(dbUpload.ProtocolData),
 		CompletionMessage: dbUpload.CompletionMessage,
@@ -108,7 +131,8 @@ func (a *DatabaseAdapter) GetLatestCompletedUploadForNode(ctx context.Context, n
 		StartedAt:         dbUpload.StartedAt,
 		CompletedAt:       dbUpload.CompletedAt,
 		Status:            dbUpload.Status,
-		TriggerType:       dbUpload.TriggerType,
+		TriggerType:       upload.TriggerType(dbUpload.TriggerType),
+		TriggerMetadata:   upload.JSONB(dbUpload.TriggerMetadata),
 		ErrorMessage:      dbUpload.ErrorMessage,
 		ProtocolData:      upload.JSONB(dbUpload.ProtocolData),
 		CompletionMessage: dbUpload.CompletionMessage,
@@ -125,11 +149,151 @@ func (a *DatabaseAdapter) UpdateUploadCompletion(ctx context.Context, uploadID i
 	return a.db.UpdateUploadCompletion(ctx, uploadID, completedAt, status, completionMessage, errorMessage)
 }
 
+// RecordProgressSample adapts to database.DB method
+func (a *DatabaseAdapter) RecordProgressSample(ctx context.Context, uploadID int64, sample analytics.Sample) error {
+	return a.db.RecordProgressSample(ctx, uploadID, sample)
+}
+
+// GetProgressSamples adapts to database.DB method
+func (a *DatabaseAdapter) GetProgressSamples(ctx context.Context, uploadID int64, since time.Time) ([]analytics.Sample, error) {
+	return a.db.GetProgressSamples(ctx, uploadID, since)
+}
+
+// SetUploadDetectionLag adapts to database.DB method
+func (a *DatabaseAdapter) SetUploadDetectionLag(ctx context.Context, uploadID int64, finishedAt time.Time, lag time.Duration) error {
+	return a.db.SetUploadDetectionLag(ctx, uploadID, finishedAt, lag)
+}
+
+// SetUploadCompression adapts to database.DB method
+func (a *DatabaseAdapter) SetUploadCompression(ctx context.Context, uploadID int64, algorithm string, level int, rawBytes, compressedBytes int64) error {
+	return a.db.SetUploadCompression(ctx, uploadID, algorithm, level, rawBytes, compressedBytes)
+}
+
+// SetUploadSize adapts to database.DB method
+func (a *DatabaseAdapter) SetUploadSize(ctx context.Context, uploadID int64, sizeBytes int64) error {
+	return a.db.SetUploadSize(ctx, uploadID, sizeBytes)
+}
+
+// RecordUploadResumed adapts to database.DB RecordNodeAction
+func (a *DatabaseAdapter) RecordUploadResumed(ctx context.Context, uploadID int64, nodeName string, resumedAt time.Time) error {
+	return a.db.RecordNodeAction(ctx, database.NodeAction{
+		NodeName:  nodeName,
+		UploadID:  &uploadID,
+		Action:    database.NodeActionResumed,
+		Message:   "resumed the upload interrupted by a restart from its checkpoint",
+		CreatedAt: resumedAt,
+	})
+}
+
+// RecordStatusOutput adapts to database.DB method
+func (a *DatabaseAdapter) RecordStatusOutput(ctx context.Context, uploadID int64, state, rawOutput string, recordedAt time.Time) (bool, error) {
+	return a.db.RecordStatusOutput(ctx, uploadID, state, rawOutput, recordedAt)
+}
+
+// UpdateUploadThroughput adapts to database.DB method
+func (a *DatabaseAdapter) UpdateUploadThroughput(ctx context.Context, uploadID int64, chunksPerMinute *float64, estimatedCompletion *time.Time) error {
+	return a.db.UpdateUploadThroughput(ctx, uploadID, chunksPerMinute, estimatedCompletion)
+}
+
+// LeaderLockAdapter adapts database.DB leader locks to the scheduler.LeaderLocker interface
+type LeaderLockAdapter struct {
+	db *database.DB
+}
+
+// TryAcquireLeaderLock adapts to database.DB method
+func (a *LeaderLockAdapter) TryAcquireLeaderLock(ctx context.Context, name string) (scheduler.LeaderLock, error) {
+	lock, err := a.db.TryAcquireLeaderLock(ctx, name)
+	if err != nil || lock == nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// newExecutor creates a command executor that runs bv through the configured command
+// prefix, and only the commands the configuration allows, in the configured sandbox
+func newExecutor(cfg *config.Config, logger *logrus.Logger) (*executor.DefaultExecutor, error) {
+	exec := executor.NewDefaultExecutor(logger)
+	exec.SetBVCommandPrefix(cfg.BVCommandPrefix)
+	exec.SetBVConcurrency(cfg.GetBVConcurrency())
+
+	if err := exec.SetSandbox(cfg.ExecutorSandbox()); err != nil {
+		return nil, fmt.Errorf("invalid executor config: %w", err)
+	}
+	return exec, nil
+}
+
+// executorAllowList allows the commands of nodes registered at runtime
+type executorAllowList struct {
+	exec *executor.DefaultExecutor
+}
+
+// SetNode allows the commands of a registered or updated node
+func (a executorAllowList) SetNode(cfg *config.Config, nodeName string) {
+	nodeConfig := cfg.Nodes[nodeName]
+	a.exec.Allow(nodeConfig.Commands()...)
+}
+
+// RemoveNode keeps a removed node's commands allowed, as an upload it started may still
+// run them
+func (a executorAllowList) RemoveNode(nodeName string) {}
+
+// newUploadManager creates an upload manager using the configured bv status rules and
+// output format, recording this host on the uploads it creates
+func newUploadManager(exec upload.CommandExecutor, db *database.DB, cfg *config.Config, logger *logrus.Logger) *upload.Manager {
+	uploadMgr := upload.NewManager(exec, &DatabaseAdapter{db: db}, logger)
+	uploadMgr.SetAgent(daemonHost())
+	rules := cfg.BVStatusRules
+	uploadMgr.SetStatusRules(upload.NewStatusRules(rules.NotRunning, rules.NotFound, rules.ReplaceDefaults))
+	uploadMgr.SetBVOutputFormat(cfg.BVOutputFormat)
+	uploadMgr.SetBVTimeouts(cfg.GetBVStatusTimeout(), cfg.GetBVStartTimeout())
+	return uploadMgr
+}
+
+// operatorUser returns the user invoking the CLI, looking through sudo
+func operatorUser() string {
+	if user := os.Getenv("SUDO_USER"); user != "" {
+		return user
+	}
+	return os.Getenv("USER")
+}
+
+// operatorTrigger describes an upload started from the CLI, recording the command,
+// the invoking user and an optional reason as trigger metadata
+func operatorTrigger(command, reason string) upload.Trigger {
+	metadata := map[string]interface{}{"command": command}
+	if user := operatorUser(); user != "" {
+		metadata["user"] = user
+	}
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+	return upload.Trigger{Type: upload.TriggerManual, Metadata: metadata}
+}
+
+// newDatabaseConfig builds the database connection settings from the daemon configuration
+func newDatabaseConfig(cfg *config.Config) database.Config {
+	return database.Config{
+		Driver:      cfg.Database.Driver,
+		Path:        cfg.Database.Path,
+		Host:        cfg.Database.Host,
+		Port:        cfg.Database.Port,
+		Database:    cfg.Database.Database,
+		User:        cfg.Database.User,
+		Password:    cfg.Database.Password,
+		SSLMode:     cfg.Database.SSLMode,
+		SSLRootCert: cfg.Database.SSLRootCert,
+		SSLCert:     cfg.Database.SSLCert,
+		SSLKey:      cfg.Database.SSLKey,
+	}
+}
+
 func main() {
 	// Parse command-line flags
 	configPath := flag.String("config", "/etc/snapperd/config.yaml", "Path to configuration file")
 	consoleMode := flag.Bool("console", false, "Run in console mode with human-readable logs")
 	showVersion := flag.Bool("version", false, "Show version information")
+	flag.StringVar(&protocolPluginDir, "plugin-dir", "/etc/snapperd/plugins", "Directory of protocol plugin definitions")
+	flag.StringVar(&notificationPluginDir, "notification-plugin-dir", "/etc/snapperd/plugins/notifications", "Directory of notification plugin definitions")
 	flag.Parse()
 
 	// Handle version command
@@ -145,14 +309,53 @@ func main() {
 	if len(args) > 0 {
 		switch args[0] {
 		case "status":
-			os.Exit(handleStatusCommand(*configPath, *consoleMode))
+			os.Exit(handleStatusCommand(*configPath, *consoleMode, args[1:]))
 		case "upload":
-			if len(args) < 2 {
-				fmt.Fprintf(os.Stderr, "Error: upload command requires a node name\n")
-				fmt.Fprintf(os.Stderr, "Usage: snapd upload <node>\n")
-				os.Exit(1)
-			}
-			os.Exit(handleUploadCommand(*configPath, *consoleMode, args[1]))
+			os.Exit(handleUploadCommand(*configPath, *consoleMode, args[1:]))
+		case "smoke":
+			os.Exit(handleSmokeCommand(*configPath, *consoleMode, args[1:]))
+		case "cancel":
+			os.Exit(handleCancelCommand(*configPath, *consoleMode, args[1:]))
+		case "requeue":
+			os.Exit(handleRequeueCommand(*configPath, *consoleMode, args[1:]))
+		case "schedule":
+			os.Exit(handleScheduleCommand(*configPath))
+		case "history":
+			os.Exit(handleHistoryCommand(*configPath, args[1:]))
+		case "contents":
+			os.Exit(handleContentsCommand(*configPath, args[1:]))
+		case "show":
+			os.Exit(handleShowCommand(*configPath, args[1:]))
+		case "show-node":
+			os.Exit(handleShowNodeCommand(*configPath, args[1:]))
+		case "audit":
+			os.Exit(handleAuditCommand(*configPath, args[1:]))
+		case "queue":
+			os.Exit(handleQueueCommand(*configPath, args[1:]))
+		case "groups":
+			os.Exit(handleGroupsCommand(*configPath, args[1:]))
+		case "nodes":
+			os.Exit(handleNodesCommand(*configPath, args[1:]))
+		case "purge-node":
+			os.Exit(handlePurgeNodeCommand(*configPath, args[1:]))
+		case "pause":
+			os.Exit(handlePauseCommand(*configPath, args[1:]))
+		case "resume":
+			os.Exit(handleResumeCommand(*configPath, args[1:]))
+		case "decrypt":
+			os.Exit(handleDecryptCommand(args[1:]))
+		case "debug-bundle":
+			os.Exit(handleDebugBundleCommand(*configPath, args[1:]))
+		case "summary":
+			os.Exit(handleSummaryCommand(*configPath, args[1:]))
+		case "stats":
+			os.Exit(handleStatsCommand(*configPath, args[1:]))
+		case "catalog":
+			os.Exit(handleCatalogCommand(*configPath, args[1:]))
+		case "validate":
+			os.Exit(handleValidateCommand(*configPath, args[1:]))
+		case "migrate":
+			os.Exit(handleMigrateCommand(*configPath, args[1:]))
 		case "version":
 			fmt.Printf("snapperd version %s\n", version)
 			fmt.Printf("Build date: %s\n", buildDate)
@@ -160,7 +363,7 @@ func main() {
 			os.Exit(0)
 		default:
 			fmt.Fprintf(os.Stderr, "Error: unknown command '%s'\n", args[0])
-			fmt.Fprintf(os.Stderr, "Available commands: status, upload, version\n")
+			fmt.Fprintf(os.Stderr, "Available commands: status, upload, smoke, cancel, requeue, history, contents, show, show-node, audit, queue, groups, nodes, purge-node, pause, resume, schedule, summary, stats, catalog, validate, migrate, decrypt, debug-bundle, version\n")
 			os.Exit(1)
 		}
 	}
@@ -186,136 +389,333 @@ func runDaemon(configPath string, consoleMode bool) int {
 		"console_mode": consoleMode,
 	}).Info("Starting snapshot daemon")
 
+	// Check each startup dependency in order. Failed checks stop startup unless the
+	// startup policy is degraded and the daemon can run without them.
+	selfCheck := health.NewSelfCheck(log.Logger)
+
 	// Load configuration
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": "main",
-			"error":     err.Error(),
-		}).Error("Failed to load configuration")
+		selfCheck.Fail(health.CheckConfig, err)
 		return 1
 	}
-
-	log.WithFields(logrus.Fields{
-		"component":  "main",
-		"node_count": len(cfg.Nodes),
-	}).Info("Configuration loaded successfully")
+	selfCheck.SetPolicy(cfg.GetStartupPolicy())
+	selfCheck.Pass(health.CheckConfig, fmt.Sprintf("%d nodes", len(cfg.Nodes)))
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Initialize database
-	dbCfg := database.Config{
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Database: cfg.Database.Database,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-		SSLMode:  cfg.Database.SSLMode,
+	// Export spans of the upload workflow to the configured collector
+	var tracer *tracing.Tracer
+	if cfg.Tracing != nil {
+		tracer = tracing.NewTracer(cfg.Tracing.GetTracesURL(), cfg.Tracing.Headers, cfg.Tracing.GetExportInterval(), []tracing.Attr{
+			tracing.String("service.name", cfg.Tracing.GetServiceName()),
+			tracing.String("service.version", version),
+			tracing.String("host.name", daemonHost()),
+		}, log.Logger)
+		tracing.SetTracer(tracer)
+		go tracer.Run(ctx)
+
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"url":       cfg.Tracing.GetTracesURL(),
+		}).Info("Tracing enabled")
 	}
 
-	db, err := database.New(ctx, dbCfg)
-	if err != nil {
+	// Serve the self-check so startup can be followed while checks run
+	if cfg.Health != nil {
+		listener, err := net.Listen("tcp", cfg.Health.Listen)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"listen":    cfg.Health.Listen,
+			}).Error("Failed to start health endpoint")
+			return 1
+		}
+
+		go func() {
+			if err := health.Serve(ctx, listener, health.NewHandler(selfCheck, log.Logger)); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Error("Health endpoint stopped")
+			}
+		}()
+
 		log.WithFields(logrus.Fields{
 			"component": "main",
-			"error":     err.Error(),
-		}).Error("Failed to connect to database")
+			"listen":    listener.Addr().String(),
+		}).Info("Health endpoint started")
+	}
+
+	// Initialize database
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		selfCheck.Fail(health.CheckDatabase, err)
 		return 1
 	}
 	defer db.Close()
-
-	log.WithFields(logrus.Fields{
-		"component": "main",
-	}).Info("Database connection established")
+	selfCheck.Pass(health.CheckDatabase, db.DriverName())
 
 	// Run database migrations
 	if err := db.Migrate(ctx); err != nil {
+		selfCheck.Fail(health.CheckMigrations, err)
+		return 1
+	}
+	selfCheck.Pass(health.CheckMigrations, "")
+	recordConfigLoaded(ctx, db, configPath, cfg, log.Logger)
+
+	// Initialize command executor and check bv can be run
+	exec, err := newExecutor(cfg, log.Logger)
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "main",
 			"error":     err.Error(),
-		}).Error("Failed to run database migrations")
+		}).Error("Failed to initialize command executor")
 		return 1
 	}
-
-	log.WithFields(logrus.Fields{
-		"component": "main",
-	}).Info("Database migrations completed")
+	if bvVersion, err := checkBV(ctx, exec); err != nil {
+		if !selfCheck.Fail(health.CheckBV, err) {
+			return 1
+		}
+	} else {
+		selfCheck.Pass(health.CheckBV, bvVersion)
+	}
 
 	// Initialize protocol registry
-	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry, err := newProtocolRegistry()
+	if err == nil {
+		err = checkNodeProtocols(cfg, protocolRegistry)
+	}
+	if err != nil {
+		if !selfCheck.Fail(health.CheckProtocols, err) {
+			return 1
+		}
+	} else {
+		selfCheck.Pass(health.CheckProtocols, strings.Join(protocolRegistry.List(), ","))
+	}
 	config.SetProtocolValidator(protocolRegistry)
 
-	// Register protocol modules
-	if err := protocolRegistry.Register(protocol.NewEthereumModule()); err != nil {
+	// Initialize notification registry
+	notificationRegistry, err := newNotificationRegistry(snoozeActions(cfg))
+	if err == nil {
+		err = checkNotificationTypes(cfg, notificationRegistry)
+	}
+	if err != nil {
+		if !selfCheck.Fail(health.CheckNotifications, err) {
+			return 1
+		}
+	} else {
+		selfCheck.Pass(health.CheckNotifications, strings.Join(notificationRegistry.List(), ","))
+	}
+	config.SetNotificationValidator(notificationRegistry)
+	selfCheck.Complete()
+
+	// Initialize upload manager with database adapter
+	uploadMgr := newUploadManager(exec, db, cfg, log.Logger)
+	engines := newNodeEngines(exec, uploadMgr, db, log.Logger)
+	kubeClient, err := newKubeClient(cfg)
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "main",
 			"error":     err.Error(),
-		}).Error("Failed to register Ethereum protocol module")
+		}).Error("Failed to create Kubernetes API client")
 		return 1
 	}
+	engines.setKubeClient(kubeClient)
+	// Uploads of in-process engines interrupted by the last shutdown resume from their
+	// checkpoints when the monitor finds them
+	uploadMgr.SetResumeInterrupted(true)
+	// Upload lifecycle events are streamed by the summary endpoint
+	eventHub := events.NewHub()
+	uploadMgr.SetEvents(eventHub)
+
+	// Initialize scheduler, recording the runs of its jobs for 'snapperd status --schedule'
+	host := daemonHost()
+	sched := scheduler.NewCronScheduler(log.Logger)
+	sched.SetJobStateStore(db, host)
+	sched.SetLocation(cfg.GetLocation())
+
+	// With several daemons sharing the database, only the elected leader runs uploads
+	var election *scheduler.LeaderElection
+	leaderOnly := func(job scheduler.Job) scheduler.Job { return job }
+	if cfg.LeaderElection != nil {
+		var locker scheduler.LeaderLocker = &LeaderLockAdapter{db: db}
+		if cfg.LeaderElection.GetBackend() == config.LeaderBackendKubernetes {
+			locker = &LeaseLockerAdapter{client: kubeClient, identity: host, duration: cfg.LeaderElection.GetLeaseDuration()}
+		}
+		election = scheduler.NewLeaderElection(locker, cfg.LeaderElection.GetName(), cfg.LeaderElection.GetRetryInterval(), log.Logger)
+		election.Poll(ctx)
+		leaderOnly = func(job scheduler.Job) scheduler.Job { return scheduler.LeaderOnly(job, election) }
 
-	if err := protocolRegistry.Register(protocol.NewArbitrumModule()); err != nil {
+		log.WithFields(logrus.Fields{
+			"component":      "main",
+			"backend":        cfg.LeaderElection.GetBackend(),
+			"lock":           cfg.LeaderElection.GetName(),
+			"retry_interval": cfg.LeaderElection.GetRetryInterval().String(),
+			"leader":         election.IsLeader(),
+		}).Info("Leader election enabled")
+	}
+
+	// Protocol metrics of all nodes are collected through one bounded pool
+	metricPool := scheduler.NewMetricPool(cfg.GetMetricConcurrency(), cfg.GetMetricTimeout())
+
+	// Add global status update job (upload monitor)
+	monitorJob := scheduler.NewUploadMonitorJob(uploadMgr, db, protocolRegistry, notificationRegistry, cfg.Notifications, cfg.ResolvedNodes(), cfg.StallIntervals, log.Logger)
+	monitorJob.SetMonitorLagThreshold(cfg.GetMonitorLagThreshold())
+	monitorJob.SetMetricPool(metricPool)
+	monitorJob.SetContentListing(cfg.ContentListing.Command)
+	if err := sched.AddJob(cfg.Schedule, scheduler.Named("upload_monitor", leaderOnly(monitorJob))); err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "main",
 			"error":     err.Error(),
-		}).Error("Failed to register Arbitrum protocol module")
+			"schedule":  cfg.Schedule,
+		}).Error("Failed to add upload monitor job")
 		return 1
 	}
 
 	log.WithFields(logrus.Fields{
 		"component": "main",
-		"protocols": protocolRegistry.List(),
-	}).Info("Protocol modules registered")
-
-	// Initialize notification registry
-	notificationRegistry := notification.NewRegistry()
-	config.SetNotificationValidator(notificationRegistry)
+		"schedule":  cfg.Schedule,
+	}).Info("Upload monitor job scheduled")
 
-	// Register notification modules
-	if err := notificationRegistry.Register(notification.NewDiscordModule()); err != nil {
+	// Add blob retention job (Ethereum nodes only)
+	// Retention is checked against each node's next run on its effective schedule
+	retentionNodes := make(map[string]config.NodeConfig, len(cfg.Nodes))
+	for nodeName, nodeConfig := range cfg.ResolvedNodes() {
+		nodeConfig.Schedule = cfg.GetNodeSchedule(nodeName)
+		retentionNodes[nodeName] = nodeConfig
+	}
+	blobRetentionJob := scheduler.NewBlobRetentionJob(db, protocolRegistry, notificationRegistry, cfg.Notifications, retentionNodes, log.Logger)
+	if err := sched.AddJob(cfg.BlobRetentionSchedule, scheduler.Named("blob_retention", leaderOnly(blobRetentionJob))); err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "main",
 			"error":     err.Error(),
-		}).Error("Failed to register Discord notification module")
+			"schedule":  cfg.BlobRetentionSchedule,
+		}).Error("Failed to add blob retention job")
 		return 1
 	}
 
 	log.WithFields(logrus.Fields{
 		"component": "main",
-		"types":     notificationRegistry.List(),
-	}).Info("Notification modules registered")
+		"schedule":  cfg.BlobRetentionSchedule,
+	}).Info("Blob retention job scheduled")
+
+	// Add snapshot freshness watchdog for nodes with a max_snapshot_age. It is added even
+	// without any, since registered nodes can have one.
+	maxSnapshotAges := make(map[string]time.Duration)
+	for nodeName := range cfg.Nodes {
+		if maxAge := cfg.GetMaxSnapshotAge(nodeName); maxAge > 0 {
+			maxSnapshotAges[nodeName] = maxAge
+		}
+	}
+	freshnessJob := scheduler.NewFreshnessJob(db, notificationRegistry, cfg.Notifications, cfg.ResolvedNodes(), maxSnapshotAges, log.Logger)
+	if err := sched.AddJob(cfg.FreshnessSchedule, scheduler.Named("freshness", leaderOnly(freshnessJob))); err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"error":     err.Error(),
+			"schedule":  cfg.FreshnessSchedule,
+		}).Error("Failed to add snapshot freshness job")
+		return 1
+	}
 
-	// Initialize command executor
-	exec := executor.NewDefaultExecutor(log.Logger)
+	log.WithFields(logrus.Fields{
+		"component": "main",
+		"schedule":  cfg.FreshnessSchedule,
+		"nodes":     len(maxSnapshotAges),
+	}).Info("Snapshot freshness job scheduled")
+
+	// Add upload SLO alerting for nodes with an SLO. Like the freshness watchdog, it is
+	// added even without any, since registered nodes can have one.
+	slos := make(map[string]*config.SLOConfig)
+	for nodeName := range cfg.Nodes {
+		if slo := cfg.GetNodeSLO(nodeName); slo != nil {
+			slos[nodeName] = slo
+		}
+	}
+	sloJob := scheduler.NewSLOJob(db, db, notificationRegistry, cfg.Notifications, cfg.ResolvedNodes(), slos, log.Logger)
+	if err := sched.AddJob(cfg.SLOSchedule, scheduler.Named("slo", leaderOnly(sloJob))); err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"error":     err.Error(),
+			"schedule":  cfg.SLOSchedule,
+		}).Error("Failed to add upload SLO job")
+		return 1
+	}
 
-	// Initialize upload manager with database adapter
-	dbAdapter := &DatabaseAdapter{db: db}
-	uploadMgr := upload.NewManager(exec, dbAdapter, log.Logger)
+	log.WithFields(logrus.Fields{
+		"component": "main",
+		"schedule":  cfg.SLOSchedule,
+		"nodes":     len(slos),
+	}).Info("Upload SLO job scheduled")
+
+	// Add the upload digest, summarizing every node's uploads to the global notification types
+	var digestJob *scheduler.DigestJob
+	if cfg.Digest != nil {
+		digestJob = scheduler.NewDigestJob(db, db, notificationRegistry, cfg.Notifications, cfg.Digest, cfg.Nodes, log.Logger)
+		if err := sched.AddJob(cfg.Digest.GetSchedule(), scheduler.Named("digest", leaderOnly(digestJob))); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"schedule":  cfg.Digest.GetSchedule(),
+			}).Error("Failed to add digest job")
+			return 1
+		}
 
-	// Initialize scheduler
-	sched := scheduler.NewCronScheduler(log.Logger)
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"schedule":  cfg.Digest.GetSchedule(),
+			"period":    cfg.Digest.GetPeriod().String(),
+		}).Info("Upload digest job scheduled")
+	}
 
-	// Add global status update job (upload monitor)
-	monitorJob := scheduler.NewUploadMonitorJob(uploadMgr, db, protocolRegistry, notificationRegistry, cfg.Notifications, cfg.Nodes, log.Logger)
-	if err := sched.AddJob(cfg.Schedule, monitorJob); err != nil {
+	// Add restore verification for nodes with verification configured. Like the freshness
+	// watchdog, it is added even without any, since registered nodes can have it.
+	verificationJob := scheduler.NewRestoreVerificationJob(db, protocolRegistry, uploadMgr, cfg.Nodes, log.Logger)
+	if err := sched.AddJob(cfg.VerificationSchedule, scheduler.Named("restore_verification", leaderOnly(verificationJob))); err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "main",
 			"error":     err.Error(),
-			"schedule":  cfg.Schedule,
-		}).Error("Failed to add upload monitor job")
+			"schedule":  cfg.VerificationSchedule,
+		}).Error("Failed to add restore verification job")
 		return 1
 	}
 
 	log.WithFields(logrus.Fields{
 		"component": "main",
-		"schedule":  cfg.Schedule,
-	}).Info("Upload monitor job scheduled")
+		"schedule":  cfg.VerificationSchedule,
+	}).Info("Restore verification job scheduled")
+
+	// Throttle or pause uploads while the host is short of resources
+	if cfg.Guardrails != nil {
+		guardrailJob := scheduler.NewGuardrailJob(cfg.Guardrails, hoststats.NewSampler("/"), db, uploadMgr, log.Logger)
+		guardrailSchedule := "@every " + cfg.Guardrails.GetInterval().String()
+		if err := sched.AddJob(guardrailSchedule, scheduler.Named("guardrails", leaderOnly(guardrailJob))); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"schedule":  guardrailSchedule,
+			}).Error("Failed to add guardrail job")
+			return 1
+		}
 
-	// Add per-node upload jobs
-	for nodeName, nodeConfig := range cfg.Nodes {
-		nodeSchedule := cfg.GetNodeSchedule(nodeName)
-		nodeNotifications := cfg.GetNodeNotifications(nodeName)
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"interval":  cfg.Guardrails.GetInterval().String(),
+			"action":    cfg.Guardrails.GetAction(),
+		}).Info("Host resource guardrails enabled")
+	}
+
+	// newNodeJob creates a node's upload job, for configured and registered nodes alike
+	newNodeJob := func(cfg *config.Config, nodeName string) *scheduler.NodeUploadJob {
+		nodeConfig := cfg.Nodes[nodeName]
+		groupName := cfg.GetNodeConsistencyGroup(nodeName)
+
+		// Record group members' runs against the group's schedule
+		nodeConfig.Schedule = cfg.GetNodeSchedule(nodeName)
+		engines.configure(nodeName, nodeConfig)
 
 		uploadJob := scheduler.NewNodeUploadJob(
 			nodeName,
@@ -324,30 +724,337 @@ func runDaemon(configPath string, consoleMode bool) int {
 			uploadMgr,
 			db,
 			notificationRegistry,
-			nodeNotifications,
+			cfg.GetNodeNotifications(nodeName),
 			log.Logger,
 		)
+		// With a concurrency limit, scheduled runs wait their turn in the upload queue.
+		// Consistency groups start their members together and bypass it.
+		uploadJob.SetQueued(cfg.MaxConcurrentUploads > 0 && groupName == "")
+		uploadJob.SetMetricPool(metricPool)
+		return uploadJob
+	}
+
+	// Add per-node upload jobs. Members of a consistency group are started by the group's
+	// job instead of a schedule of their own.
+	var catchUpJobs []scheduler.Job
+	var catchUpNodes []string
+	catchUpGroups := make(map[string]bool)
+	nodeJobs := make(map[string]*scheduler.NodeUploadJob, len(cfg.Nodes))
+	nodeEntries := make(map[string]scheduler.JobID, len(cfg.Nodes))
+	for nodeName := range cfg.Nodes {
+		nodeSchedule := cfg.GetNodeSchedule(nodeName)
+		groupName := cfg.GetNodeConsistencyGroup(nodeName)
+
+		uploadJob := newNodeJob(cfg, nodeName)
+		nodeJobs[nodeName] = uploadJob
+
+		if groupName == "" {
+			entry, err := sched.ScheduleJob(nodeSchedule, uploadJob.SplayOffset(), scheduler.Named(scheduler.NodeJobName(nodeName), leaderOnly(uploadJob)))
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"node":      nodeName,
+					"error":     err.Error(),
+					"schedule":  nodeSchedule,
+				}).Error("Failed to add node upload job")
+				return 1
+			}
+			nodeEntries[nodeName] = entry
 
-		if err := sched.AddJob(nodeSchedule, uploadJob); err != nil {
 			log.WithFields(logrus.Fields{
 				"component": "main",
 				"node":      nodeName,
-				"error":     err.Error(),
 				"schedule":  nodeSchedule,
-			}).Error("Failed to add node upload job")
+			}).Info("Node upload job scheduled")
+		}
+
+		// Decide on missed runs from the persisted schedule history
+		catchUp, err := uploadJob.Resume(ctx)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Warn("Failed to restore schedule state")
+		}
+		if uploadJob.RunOnStart() && !catchUp {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"node":      nodeName,
+			}).Info("Running node upload at startup")
+			catchUp = true
+		}
+		switch {
+		case catchUp && groupName != "":
+			catchUpGroups[groupName] = true
+		case catchUp:
+			catchUpNodes = append(catchUpNodes, nodeName)
+		}
+	}
+
+	// Add consistency group jobs
+	for groupName, group := range cfg.ConsistencyGroups {
+		members := make([]*scheduler.NodeUploadJob, 0, len(group.Nodes))
+		for _, nodeName := range group.Nodes {
+			members = append(members, nodeJobs[nodeName])
+		}
+
+		groupJob := scheduler.NewConsistencyGroupJob(groupName, group.Schedule, members, db, log.Logger)
+		if err := sched.AddJob(group.Schedule, scheduler.Named(scheduler.ConsistencyGroupJobName(groupName), leaderOnly(groupJob))); err != nil {
+			log.WithFields(logrus.Fields{
+				"component":         "main",
+				"consistency_group": groupName,
+				"error":             err.Error(),
+				"schedule":          group.Schedule,
+			}).Error("Failed to add consistency group job")
 			return 1
 		}
 
+		log.WithFields(logrus.Fields{
+			"component":         "main",
+			"consistency_group": groupName,
+			"nodes":             strings.Join(group.Nodes, ","),
+			"schedule":          group.Schedule,
+		}).Info("Consistency group job scheduled")
+
+		// A missed run of any member, or a member running at startup, runs the whole group
+		if catchUpGroups[groupName] {
+			catchUpJobs = append(catchUpJobs, scheduler.Named(scheduler.ConsistencyGroupJobName(groupName), leaderOnly(groupJob)))
+		}
+	}
+
+	// Drain the upload queue: requests from 'snapperd upload' and, with a concurrency
+	// limit, scheduled runs
+	uploadRequestJob := scheduler.NewUploadRequestJob(db, nodeJobs, host, os.Getpid(), log.Logger)
+	uploadRequestJob.SetMaxConcurrentUploads(cfg.MaxConcurrentUploads)
+	uploadRequestJob.SetTriggerDebounce(cfg.GetTriggerDebounce())
+	if election != nil {
+		uploadRequestJob.SetLeader(election)
+	}
+	if err := sched.AddJob(uploadRequestSchedule, scheduler.Named("upload_requests", uploadRequestJob)); err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "main",
-			"node":      nodeName,
-			"schedule":  nodeSchedule,
-		}).Info("Node upload job scheduled")
+			"error":     err.Error(),
+			"schedule":  uploadRequestSchedule,
+		}).Error("Failed to add upload request job")
+		return 1
+	}
+
+	// Schedule the registered nodes assigned to this host like the configured ones, and
+	// keep following changes made through other daemons or 'snapperd nodes'
+	nodeHost := assignedHost(cfg)
+	nodeRegistry := scheduler.NewNodeRegistry(cfg, nodeHost, nodeStoreFor(cfg, db, kubeClient), sched, newNodeJob, uploadRequestJob, log.Logger)
+	if cfg.RegisteredNodesOverride {
+		nodeRegistry.SetOverridable(nodeEntries)
+	}
+	summaryBuilder := summary.NewBuilder(db, cfg, host)
+	metricsCollector := metrics.NewCollector(db, cfg, host)
+	metricsCollector.SetMonitor(monitorJob, metricPool)
+	nodeActivity := scheduler.NewNodeActivityTracker(db, host, log.Logger)
+	nodeRegistry.Watch(executorAllowList{exec}, monitorJob, blobRetentionJob, freshnessJob, sloJob, verificationJob, summaryBuilder, metricsCollector, nodeActivity)
+	if digestJob != nil {
+		nodeRegistry.Watch(digestJob)
+	}
+	if election != nil {
+		nodeRegistry.SetLeader(election)
+	}
+	if err := nodeRegistry.Sync(ctx); err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"error":     err.Error(),
+		}).Warn("Failed to load registered nodes, retrying in the background")
+	} else {
+		// Once the registered nodes are known, mark nodes no longer run inactive
+		var nodeNames []string
+		for _, node := range nodeRegistry.Nodes() {
+			nodeNames = append(nodeNames, node.Name)
+		}
+		if err := nodeActivity.Sync(ctx, nodeNames); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+			}).Warn("Failed to record node activity")
+		}
+	}
+	syncSchedule := nodeSyncScheduleFor(cfg)
+	if err := sched.AddJob(syncSchedule, scheduler.Named("node_sync", nodeRegistry)); err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"error":     err.Error(),
+			"schedule":  syncSchedule,
+		}).Error("Failed to add node sync job")
+		return 1
+	}
+	if cfg.DatabaseNodes != nil {
+		log.WithFields(logrus.Fields{
+			"component":     "main",
+			"host":          nodeHost,
+			"poll_interval": cfg.DatabaseNodes.GetPollInterval().String(),
+			"nodes":         len(nodeRegistry.Nodes()),
+		}).Info("Database-backed nodes enabled")
+	}
+	if cfg.Kubernetes != nil {
+		log.WithFields(logrus.Fields{
+			"component":      "main",
+			"namespace":      cfg.Kubernetes.Namespace,
+			"label_selector": cfg.Kubernetes.LabelSelector,
+			"poll_interval":  cfg.Kubernetes.GetPollInterval().String(),
+			"nodes":          len(nodeRegistry.Nodes()),
+		}).Info("Kubernetes node discovery enabled")
+	}
+
+	// Serve the node API for registering nodes at runtime
+	if cfg.NodeAPI != nil {
+		listener, err := net.Listen("tcp", cfg.NodeAPI.Listen)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"listen":    cfg.NodeAPI.Listen,
+			}).Error("Failed to start node API")
+			return 1
+		}
+
+		nodeAPIHandler := nodeapi.NewHandler(nodeRegistry, cfg.NodeAPI.Token, log.Logger)
+		nodeAPIHandler.SetAudit(db)
+		nodeAPIHandler.SetTenants(cfg.TenantTokens())
+		go func() {
+			if err := nodeapi.Serve(ctx, listener, nodeAPIHandler); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Error("Node API stopped")
+			}
+		}()
+
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"listen":    listener.Addr().String(),
+		}).Info("Node API started")
+	}
+
+	// Serve the snooze links added to notifications
+	if cfg.Snooze != nil {
+		listener, err := net.Listen("tcp", cfg.Snooze.Listen)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"listen":    cfg.Snooze.Listen,
+			}).Error("Failed to start snooze endpoint")
+			return 1
+		}
+
+		snoozeHandler := snooze.NewHandler(snooze.NewLinks(cfg.Snooze), db, log.Logger)
+		snoozeHandler.SetAudit(db)
+		go func() {
+			if err := snooze.Serve(ctx, listener, snoozeHandler); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Error("Snooze endpoint stopped")
+			}
+		}()
+
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"listen":    listener.Addr().String(),
+		}).Info("Snooze endpoint started")
+	}
+
+	// Serve the summary for external pollers
+	if cfg.SummaryAPI != nil {
+		listener, err := net.Listen("tcp", cfg.SummaryAPI.Listen)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"listen":    cfg.SummaryAPI.Listen,
+			}).Error("Failed to start summary endpoint")
+			return 1
+		}
+
+		summaryHandler := summary.NewHandler(summaryBuilder, cfg.SummaryAPI.Token, log.Logger)
+		summaryHandler.SetEvents(eventHub)
+		summaryHandler.SetTenants(cfg.TenantTokens())
+		go func() {
+			if err := summary.Serve(ctx, listener, summaryHandler); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Error("Summary endpoint stopped")
+			}
+		}()
+
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"listen":    listener.Addr().String(),
+		}).Info("Summary endpoint started")
+	}
+
+	// Export snapshot sizes for Prometheus
+	if cfg.Metrics != nil {
+		listener, err := net.Listen("tcp", cfg.Metrics.Listen)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+				"listen":    cfg.Metrics.Listen,
+			}).Error("Failed to start metrics endpoint")
+			return 1
+		}
+
+		metricsHandler := metrics.NewHandler(metricsCollector, cfg.Metrics.Token, log.Logger)
+		go func() {
+			if err := metrics.Serve(ctx, listener, metricsHandler); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Error("Metrics endpoint stopped")
+			}
+		}()
+
+		log.WithFields(logrus.Fields{
+			"component": "main",
+			"listen":    listener.Addr().String(),
+		}).Info("Metrics endpoint started")
+	}
+
+	// Reconcile the running uploads with their jobs before anything is scheduled, so uploads
+	// that finished while the daemon was down do not block catch-up runs and uploads the
+	// last daemon stopped during are re-attached right away. Only the leader monitors
+	// uploads; a standby reconciles through its monitor once it takes over.
+	if election == nil || election.IsLeader() {
+		if _, err := monitorJob.Reconcile(ctx); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+			}).Warn("Failed to reconcile running uploads, the upload monitor retries on its schedule")
+		}
 	}
 
 	// Start the scheduler
 	sched.Start()
 
+	// Record the heartbeat right away so CLI uploads are routed to the daemon
+	sched.RunNow(scheduler.Named("upload_requests", uploadRequestJob))
+
+	// Run uploads missed while the daemon was stopped (nodes with catch_up enabled)
+	for _, nodeName := range catchUpNodes {
+		// A node replaced by a registered node catches up through the registry instead
+		if !nodeRegistry.Overridden(nodeName) {
+			sched.RunNow(scheduler.Named(scheduler.NodeJobName(nodeName), leaderOnly(nodeJobs[nodeName])))
+		}
+	}
+	for _, job := range catchUpJobs {
+		sched.RunNow(job)
+	}
+
+	// Keep checking leadership so a standby takes over when the leader dies
+	if election != nil {
+		go election.Run(ctx)
+	}
+
 	log.WithFields(logrus.Fields{
 		"component": "main",
 	}).Info("Scheduler started, daemon is now running")
@@ -383,6 +1090,53 @@ func runDaemon(configPath string, consoleMode bool) int {
 				"error":     err.Error(),
 			}).Warn("Scheduler shutdown timeout")
 		}
+
+		// Mark the uploads still running so the next daemon re-attaches monitoring on startup.
+		// Only the leader monitors uploads, so only it marks them.
+		if election == nil || election.IsLeader() {
+			if marked, err := monitorJob.MarkInterrupted(shutdownCtx); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Warn("Failed to mark running uploads interrupted")
+			} else if marked > 0 {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"uploads":   marked,
+				}).Info("Marked running uploads interrupted by shutdown")
+			}
+		}
+
+		// Transfers run inside the daemon and cannot outlive it
+		engines.stop()
+
+		// Let CLI uploads run locally again once the daemon is gone
+		if err := db.ClearDaemonHeartbeat(shutdownCtx, host); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "main",
+				"error":     err.Error(),
+			}).Warn("Failed to clear daemon heartbeat")
+		}
+
+		// Hand over to a standby once this daemon's jobs have finished
+		if election != nil {
+			if err := election.Resign(); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Warn("Failed to release leadership")
+			}
+		}
+
+		// Export the spans of jobs that finished during shutdown
+		if tracer != nil {
+			if err := tracer.Flush(shutdownCtx); err != nil {
+				log.WithFields(logrus.Fields{
+					"component": "main",
+					"error":     err.Error(),
+				}).Warn("Failed to export spans")
+			}
+		}
 	}()
 
 	// Wait for all shutdown tasks to complete
@@ -407,7 +1161,32 @@ func runDaemon(configPath string, consoleMode bool) int {
 }
 
 // handleStatusCommand handles the 'snapperd status' subcommand
-func handleStatusCommand(configPath string, consoleMode bool) int {
+func handleStatusCommand(configPath string, consoleMode bool, args []string) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	watch := fs.Bool("watch", false, "Continuously refresh a live progress view")
+	interval := fs.Duration("interval", 5*time.Second, "Refresh interval for --watch")
+	limit := fs.Int("limit", 50, "Maximum number of running uploads to show, oldest first")
+	schedule := fs.Bool("schedule", false, "Show the daemons' scheduled jobs with their last and next runs")
+	columns := fs.String("columns", "", "Comma-separated lines to show per upload: "+strings.Join(config.StatusColumns, ", ")+" (default output.status_columns)")
+	colorMode := fs.String("color", "", "Color statuses: auto, always or never (default output.color)")
+	logsNode := fs.String("logs", "", "Show the job log stored for this node's latest finished upload")
+	tenant := fs.String("tenant", "", "Only show this tenant's nodes and uploads")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if *logsNode != "" && (*watch || *schedule) {
+		fmt.Fprintf(os.Stderr, "Error: --logs cannot be combined with --watch or --schedule\n")
+		return 1
+	}
+	if *interval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --interval must be positive\n")
+		return 1
+	}
+	if *limit <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --limit must be positive\n")
+		return 1
+	}
+
 	// Initialize logger
 	log := logger.New(logger.Config{
 		Level:       "info",
@@ -423,19 +1202,24 @@ func handleStatusCommand(configPath string, consoleMode bool) int {
 		}).Error("Failed to load configuration")
 		return 1
 	}
+	if cfg, err = tenantConfig(cfg, *tenant); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	statusColumns, err := resolveColumns(*columns, cfg.GetStatusColumns(), config.StatusColumns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	color, err := resolveColor(*colorMode, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
 
 	// Connect to database
 	ctx := context.Background()
-	dbCfg := database.Config{
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Database: cfg.Database.Database,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-		SSLMode:  cfg.Database.SSLMode,
-	}
-
-	db, err := database.New(ctx, dbCfg)
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "status",
@@ -445,8 +1229,56 @@ func handleStatusCommand(configPath string, consoleMode bool) int {
 	}
 	defer db.Close()
 
-	// Get running uploads
-	runningUploads, err := db.GetRunningUploads(ctx)
+	if *logsNode != "" {
+		if err := printUploadLogs(ctx, db, *logsNode); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "status",
+				"error":     err.Error(),
+			}).Error("Failed to get upload logs")
+			return 1
+		}
+		return 0
+	}
+
+	if *watch {
+		if err := watchStatus(ctx, db, *interval, *limit, *tenant, newEventStream(cfg)); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "status",
+				"error":     err.Error(),
+			}).Error("Failed to watch uploads")
+			return 1
+		}
+		return 0
+	}
+
+	if *schedule {
+		states, err := db.GetJobStates(ctx, "")
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "status",
+				"error":     err.Error(),
+			}).Error("Failed to get job states")
+			return 1
+		}
+		printJobStates(states, cfg.Timezone, time.Now())
+		return 0
+	}
+
+	// Get the oldest running uploads, up to the limit, and which nodes have any
+	runningCount, err := db.CountRunningUploads(ctx)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to count running uploads")
+		return 1
+	}
+	page := database.UploadPage{Limit: *limit, Tenant: *tenant}
+	if *tenant != "" {
+		// Count the tenant's uploads by listing them all
+		page.Limit = 0
+	}
+	runningUploads, err := db.ListRunningUploads(ctx, page)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "status",
@@ -454,6 +1286,91 @@ func handleStatusCommand(configPath string, consoleMode bool) int {
 		}).Error("Failed to get running uploads")
 		return 1
 	}
+	if *tenant != "" {
+		runningCount = len(runningUploads)
+		runningUploads = runningUploads[:min(len(runningUploads), *limit)]
+	}
+	runningNodes, err := db.GetRunningUploadNodes(ctx)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to get nodes with running uploads")
+		return 1
+	}
+	active := make(map[string]bool, len(runningNodes))
+	for _, nodeName := range runningNodes {
+		active[nodeName] = true
+	}
+
+	// Classify configured nodes without any upload history
+	neverUploaded, err := findNeverUploadedNodes(ctx, db, cfg, active)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to get upload history")
+		return 1
+	}
+	defer printNeverUploaded(neverUploaded)
+
+	// Show how old each node's last successful snapshot is
+	snapshotAges, err := findSnapshotAges(ctx, db, cfg, time.Now())
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to get latest completed uploads")
+		return 1
+	}
+	defer printSnapshotAges(snapshotAges)
+
+	// Explain why idle nodes have not started an upload
+	waiting, err := findWaitingNodes(ctx, db, cfg, active, time.Now())
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to get schedule state")
+		return 1
+	}
+	defer printWaitingNodes(waiting, cfg.MaxConcurrentUploads)
+
+	// Show nodes whose notifications are snoozed
+	snoozes, err := db.GetActiveNotificationSnoozes(ctx, time.Now())
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to get notification snoozes")
+		return 1
+	}
+	defer printSnoozes(tenantEntries(snoozes, cfg, *tenant, func(s database.NotificationSnooze) string { return s.NodeName }))
+
+	// Show nodes whose scheduled uploads are stopped
+	pauses, err := db.GetNodePauses(ctx)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": "status",
+			"error":     err.Error(),
+		}).Error("Failed to get paused nodes")
+		return 1
+	}
+	defer printPausedNodes(tenantEntries(pauses, cfg, *tenant, func(p database.NodePause) string { return p.NodeName }), cfg)
+
+	// Show nodes removed from the configuration whose history is kept. Their tenant is
+	// not known, so they are left out of a tenant's status.
+	if *tenant == "" {
+		inactive, err := db.GetInactiveNodes(ctx)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "status",
+				"error":     err.Error(),
+			}).Error("Failed to get inactive nodes")
+			return 1
+		}
+		defer printInactiveNodes(inactive, cfg)
+	}
 
 	// Display results
 	if len(runningUploads) == 0 {
@@ -461,39 +1378,125 @@ func handleStatusCommand(configPath string, consoleMode bool) int {
 		return 0
 	}
 
-	fmt.Printf("Active uploads: %d\n\n", len(runningUploads))
+	fmt.Printf("Active uploads: %d\n\n", runningCount)
 	for _, upload := range runningUploads {
-		fmt.Printf("Node: %s (%s)\n", upload.NodeName, upload.Protocol)
-		fmt.Printf("  Upload ID: %d\n", upload.ID)
+		printRunningUpload(upload, statusColumns, color)
+	}
+	if hidden := runningCount - len(runningUploads); hidden > 0 {
+		fmt.Printf("%d more running uploads not shown (raise --limit to see them)\n", hidden)
+	}
+
+	return 0
+}
+
+// printRunningUpload prints a running upload's node, ID and status, with the chosen lines
+// in their fixed order
+func printRunningUpload(upload database.Upload, columns []string, color bool) {
+	fmt.Printf("Node: %s (%s)\n", upload.NodeName, upload.Protocol)
+	fmt.Printf("  Upload ID: %d\n", upload.ID)
+	if hasColumn(columns, "started") {
 		fmt.Printf("  Started: %s\n", upload.StartedAt.Format(time.RFC3339))
+	}
+	if hasColumn(columns, "duration") {
 		fmt.Printf("  Duration: %s\n", time.Since(upload.StartedAt).Round(time.Second))
+	}
+	if hasColumn(columns, "trigger") {
 		fmt.Printf("  Trigger: %s\n", upload.TriggerType)
+	}
+	if hasColumn(columns, "agent") {
+		agent := "-"
+		if upload.Agent != nil {
+			agent = *upload.Agent
+		}
+		fmt.Printf("  Agent: %s\n", agent)
+	}
 
-		// Display protocol data (blockchain state when upload started)
-		if upload.ProtocolData != nil {
-			fmt.Printf("  Blockchain State:\n")
-			if latestBlock, ok := upload.ProtocolData["latest_block"]; ok && latestBlock != nil {
-				fmt.Printf("    Latest Block: %v\n", latestBlock)
-			}
-			if latestSlot, ok := upload.ProtocolData["latest_slot"]; ok && latestSlot != nil {
-				fmt.Printf("    Latest Slot: %v\n", latestSlot)
-			}
-			if earliestBlob, ok := upload.ProtocolData["earliest_blob"]; ok && earliestBlob != nil {
-				fmt.Printf("    Earliest Blob: %v\n", earliestBlob)
-			}
+	// Display protocol data (blockchain state when upload started)
+	if hasColumn(columns, "block") && upload.ProtocolData != nil {
+		fmt.Printf("  Blockchain State:\n")
+		if latestBlock, ok := upload.ProtocolData["latest_block"]; ok && latestBlock != nil {
+			fmt.Printf("    Latest Block: %v\n", latestBlock)
+		}
+		if latestSlot, ok := upload.ProtocolData["latest_slot"]; ok && latestSlot != nil {
+			fmt.Printf("    Latest Slot: %v\n", latestSlot)
 		}
+		if earliestBlob, ok := upload.ProtocolData["earliest_blob"]; ok && earliestBlob != nil {
+			fmt.Printf("    Earliest Blob: %v\n", earliestBlob)
+		}
+	}
 
-		// Note: Progress data is now stored separately in upload_progress table
-		// For real-time progress, we'd need to query that table
-		fmt.Printf("  Status: %s\n", upload.Status)
+	// Progress and throughput as last recorded by the monitor
+	if hasColumn(columns, "progress") && upload.ProgressPercent != nil {
+		fmt.Printf("  Progress: %.1f%%", *upload.ProgressPercent)
+		if upload.ChunksCompleted != nil && upload.ChunksTotal != nil {
+			fmt.Printf(" (%d/%d chunks)", *upload.ChunksCompleted, *upload.ChunksTotal)
+		}
 		fmt.Println()
 	}
+	if hasColumn(columns, "throughput") && upload.ChunksPerMinute != nil {
+		fmt.Printf("  Throughput: %.1f chunks/min\n", *upload.ChunksPerMinute)
+	}
+	if hasColumn(columns, "eta") && upload.EstimatedCompletion != nil {
+		fmt.Printf("  ETA: %s (in %s)\n", upload.EstimatedCompletion.Format(time.RFC3339), time.Until(*upload.EstimatedCompletion).Round(time.Second))
+	}
+	fmt.Printf("  Status: %s\n", colorStatus(upload.Status, upload.Status, color))
+	fmt.Println()
+}
 
-	return 0
+// findNeverUploadedNodes returns the configured nodes with no running or finished uploads;
+// active holds the nodes with a running upload
+func findNeverUploadedNodes(ctx context.Context, db *database.DB, cfg *config.Config, active map[string]bool) ([]string, error) {
+	var nodes []string
+	for nodeName := range cfg.Nodes {
+		if active[nodeName] {
+			continue
+		}
+		uploads, err := db.ListUploads(ctx, database.UploadFilter{NodeName: nodeName, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(uploads) == 0 {
+			nodes = append(nodes, nodeName)
+		}
+	}
+
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// printNeverUploaded lists nodes that have never uploaded
+func printNeverUploaded(nodes []string) {
+	if len(nodes) == 0 {
+		return
+	}
+
+	fmt.Printf("\nNever uploaded: %d\n", len(nodes))
+	for _, nodeName := range nodes {
+		fmt.Printf("  %s\n", nodeName)
+	}
 }
 
 // handleUploadCommand handles the 'snapperd upload <node>' subcommand
-func handleUploadCommand(configPath string, consoleMode bool, nodeName string) int {
+func handleUploadCommand(configPath string, consoleMode bool, args []string) int {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	reason := fs.String("reason", "", "Reason recorded in the upload's trigger metadata")
+	wait := fs.Bool("wait", false, "Wait for the upload to finish and exit with its outcome")
+	interval := fs.Duration("interval", 30*time.Second, "Status check interval with --wait")
+	local := fs.Bool("local", false, "Run the upload in this process even if the daemon is running")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: upload command requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapd upload [--reason <text>] [--wait [--interval <duration>]] [--local] <node>\n")
+		return 1
+	}
+	if *interval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --interval must be positive\n")
+		return 1
+	}
+	nodeName := fs.Arg(0)
+
 	// Initialize logger
 	log := logger.New(logger.Config{
 		Level:       "info",
@@ -510,25 +1513,9 @@ func handleUploadCommand(configPath string, consoleMode bool, nodeName string) i
 		return 1
 	}
 
-	// Verify node exists in configuration
-	nodeConfig, exists := cfg.Nodes[nodeName]
-	if !exists {
-		fmt.Fprintf(os.Stderr, "Error: node '%s' not found in configuration\n", nodeName)
-		return 1
-	}
-
 	// Connect to database
 	ctx := context.Background()
-	dbCfg := database.Config{
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Database: cfg.Database.Database,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-		SSLMode:  cfg.Database.SSLMode,
-	}
-
-	db, err := database.New(ctx, dbCfg)
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "upload",
@@ -538,37 +1525,83 @@ func handleUploadCommand(configPath string, consoleMode bool, nodeName string) i
 	}
 	defer db.Close()
 
-	// Initialize protocol registry
-	protocolRegistry := protocol.NewRegistry()
-	if err := protocolRegistry.Register(protocol.NewEthereumModule()); err != nil {
+	kubeClient, err := newKubeClient(cfg)
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "upload",
 			"error":     err.Error(),
-		}).Error("Failed to register Ethereum protocol module")
+		}).Error("Failed to create Kubernetes API client")
+		return 1
+	}
+
+	// Verify node exists in configuration or was registered through the node API
+	if _, exists := cfg.Nodes[nodeName]; !exists {
+		if cfg, err = withRegisteredNodes(ctx, cfg, nodeStoreFor(cfg, db, kubeClient)); err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "upload",
+				"error":     err.Error(),
+			}).Error("Failed to load registered nodes")
+			return 1
+		}
+	}
+	nodeConfig, exists := cfg.Nodes[nodeName]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' not found in configuration\n", nodeName)
 		return 1
 	}
-	if err := protocolRegistry.Register(protocol.NewArbitrumModule()); err != nil {
+
+	// Hand the upload to the running daemon rather than racing it with our own bv calls
+	if !*local {
+		running, err := daemonRunning(ctx, db, time.Now())
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "upload",
+				"error":     err.Error(),
+			}).Warn("Failed to check for a running daemon, running upload locally")
+		}
+		if running {
+			return requestDaemonUpload(ctx, db, nodeName, cfg.Nodes[nodeName].Priority, operatorTrigger("upload", *reason), *wait, *interval)
+		}
+	}
+
+	// An rclone or s3 transfer runs inside this process, so it needs the process to wait for it
+	if runsInProcess(nodeConfig) && !*wait {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' uses the %s engine, which uploads from the snapperd process; use --wait or let the daemon run the upload\n", nodeName, nodeConfig.GetEngine())
+		return 1
+	}
+
+	// Initialize protocol registry
+	protocolRegistry, err := newProtocolRegistry()
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "upload",
 			"error":     err.Error(),
-		}).Error("Failed to register Arbitrum protocol module")
+		}).Error("Failed to register protocol modules")
 		return 1
 	}
 
 	// Initialize notification registry
-	notificationRegistry := notification.NewRegistry()
-	if err := notificationRegistry.Register(notification.NewDiscordModule()); err != nil {
+	notificationRegistry, err := newNotificationRegistry(snoozeActions(cfg))
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "upload",
 			"error":     err.Error(),
-		}).Error("Failed to register Discord notification module")
+		}).Error("Failed to register notification modules")
 		return 1
 	}
 
 	// Initialize command executor and upload manager
-	exec := executor.NewDefaultExecutor(log.Logger)
-	dbAdapter := &DatabaseAdapter{db: db}
-	uploadMgr := upload.NewManager(exec, dbAdapter, log.Logger)
+	exec, err := newExecutor(cfg, log.Logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	exec.Allow(nodeConfig.Commands()...)
+	uploadMgr := newUploadManager(exec, db, cfg, log.Logger)
+	engines := newNodeEngines(exec, uploadMgr, db, log.Logger)
+	engines.setKubeClient(kubeClient)
+	engines.configure(nodeName, nodeConfig)
+	defer engines.stop()
 
 	// Check if upload is already running (checks both database and actual command status)
 	shouldSkip, err := uploadMgr.ShouldSkipUpload(ctx, nodeName)
@@ -612,18 +1645,38 @@ func handleUploadCommand(configPath string, consoleMode bool, nodeName string) i
 		}
 	}
 
+	metrics = protocol.WithMetadata(metrics, nodeConfig)
+
 	fmt.Println("Metrics collected")
 
-	// Step 2: Initiate upload with protocol data
-	uploadID, err := uploadMgr.InitiateUploadWithProtocolData(ctx, nodeName, "manual", nodeConfig.Protocol, nodeConfig.Type, metrics)
+	// Step 2: Prepare the node with its pre_upload hooks; post_upload hooks undo that
+	// if the upload does not start, and otherwise run when the daemon sees it finish
+	if err := scheduler.RunUploadHooks(ctx, uploadMgr, nodeName, nodeConfig, scheduler.HookPreUpload, 0, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if err := scheduler.RunUploadHooks(ctx, uploadMgr, nodeName, nodeConfig, scheduler.HookPostUpload, 0, "failed"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return 1
+	}
+
+	// Step 3: Initiate upload with protocol data
+	uploadID, err := uploadMgr.InitiateUploadWithProtocolData(ctx, nodeName, operatorTrigger("upload", *reason), nodeConfig.Protocol, nodeConfig.Type, metrics)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"component": "upload",
 			"node":      nodeName,
 			"error":     err.Error(),
 		}).Error("Failed to initiate upload")
+		if err := scheduler.RunUploadHooks(ctx, uploadMgr, nodeName, nodeConfig, scheduler.HookPostUpload, 0, "failed"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 		return 1
 	}
+	auditMessage := "started manually"
+	if *reason != "" {
+		auditMessage += ": " + *reason
+	}
+	recordAudit(ctx, db, database.AuditEntry{Action: database.AuditUploadInitiated, NodeName: &nodeName, UploadID: &uploadID, Message: auditMessage})
 
 	fmt.Printf("Upload initiated successfully (ID: %d)\n", uploadID)
 
@@ -639,6 +1692,7 @@ func handleUploadCommand(configPath string, consoleMode bool, nodeName string) i
 				"upload_id":    uploadID,
 				"trigger_type": "manual",
 			},
+			Metadata: nodeConfig.Metadata,
 		}
 
 		// Send to all configured notification types
@@ -650,10 +1704,64 @@ func handleUploadCommand(configPath string, consoleMode bool, nodeName string) i
 
 			url := nodeNotifications.GetNotificationURL(notificationType)
 			if url != "" {
-				_ = notifyModule.Send(ctx, url, payload)
+				rendered := scheduler.RenderNotification(nodeNotifications, notificationType, payload, log.Logger)
+				_ = scheduler.DeliverNotification(ctx, db, log.Logger, notifyModule, url, rendered)
 			}
 		}
 	}
 
-	return 0
+	if !*wait {
+		return 0
+	}
+
+	fmt.Println("Waiting for upload to finish...")
+	result, err := waitForCompletion(ctx, uploadMgr, uploadID, nodeName, *interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if result.Message != nil {
+		fmt.Printf("Upload %s: %s\n", result.Outcome, *result.Message)
+	} else {
+		fmt.Printf("Upload %s\n", result.Outcome)
+	}
+
+	return completionExitCode(result)
+}
+
+// waitForCompletion polls an upload through the manager's monitor path until it finishes
+func waitForCompletion(ctx context.Context, uploadMgr *upload.Manager, uploadID int64, nodeName string, interval time.Duration) (upload.CompletionResult, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := uploadMgr.MonitorUpload(ctx, uploadID, nodeName)
+		if err != nil {
+			return result, err
+		}
+		if result.Done() {
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, fmt.Errorf("stopped waiting for upload %d: %w", uploadID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// completionExitCode maps an upload outcome to the upload --wait exit status
+func completionExitCode(result upload.CompletionResult) int {
+	switch result.Outcome {
+	case upload.OutcomeSuccess:
+		return 0
+	case upload.OutcomeCancelled:
+		return 2
+	default:
+		return 1
+	}
 }
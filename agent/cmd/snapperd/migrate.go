@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// handleMigrateCommand handles 'snapperd migrate', applying the pending database migrations,
+// or with --to applying or rolling back migrations until the given version is the latest
+// applied, then listing every migration and when it was applied
+func handleMigrateCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := fs.Int("to", -1, "Apply or roll back migrations up to this version (default: the latest)")
+	status := fs.Bool("status", false, "Only list the migrations and which are applied")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Error: migrate takes no arguments\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd migrate [--to <version>] [--status]\n")
+		return 1
+	}
+	toSet := false
+	fs.Visit(func(f *flag.Flag) { toSet = toSet || f.Name == "to" })
+	if toSet && *to < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --to must not be negative\n")
+		return 1
+	}
+	if toSet && *status {
+		fmt.Fprintf(os.Stderr, "Error: --to and --status cannot be combined\n")
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	before, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	switch {
+	case *status:
+	case toSet:
+		err = db.MigrateTo(ctx, *to)
+	default:
+		err = db.Migrate(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	applied, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fromVersion, toVersion := schemaVersion(before), schemaVersion(applied)
+	if fromVersion != toVersion && toVersion >= auditLogVersion(migrations) {
+		recordAudit(ctx, db, database.AuditEntry{
+			Action:  database.AuditDatabaseMigrated,
+			Message: fmt.Sprintf("schema version %d to %d", fromVersion, toVersion),
+			Details: database.JSONB{"from": fromVersion, "to": toVersion},
+		})
+	}
+	printMigrations(migrations, applied)
+	return 0
+}
+
+// schemaVersion returns the latest applied migration's version
+func schemaVersion(applied []database.AppliedMigration) int {
+	current := 0
+	for _, a := range applied {
+		current = max(current, a.Version)
+	}
+	return current
+}
+
+// auditLogVersion returns the version of the migration creating the audit log; a
+// migration rolled back past it leaves nowhere to record itself
+func auditLogVersion(migrations []database.Migration) int {
+	for _, m := range migrations {
+		if m.Name == "audit_log" {
+			return m.Version
+		}
+	}
+	return 0
+}
+
+// printMigrations lists the known migrations with when each was applied, followed by
+// any recorded by a newer snapperd
+func printMigrations(migrations []database.Migration, applied []database.AppliedMigration) {
+	appliedAt := make(map[int]database.AppliedMigration, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a
+	}
+	current := schemaVersion(applied)
+	fmt.Printf("Schema version: %d (latest %d)\n\n", current, len(migrations))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED\tROLLBACK")
+	for _, m := range migrations {
+		when := "pending"
+		if a, ok := appliedAt[m.Version]; ok {
+			when = a.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		rollback := "yes"
+		if len(m.Down) == 0 {
+			rollback = "no"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.Version, m.Name, when, rollback)
+	}
+	for _, a := range applied {
+		if a.Version > len(migrations) {
+			fmt.Fprintf(w, "%d\t%s (newer snapperd)\t%s\t-\n", a.Version, a.Name, a.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/snooze"
+)
+
+// protocolPluginDir is the directory of protocol plugin definitions, set by the -plugin-dir flag
+var protocolPluginDir string
+
+// newProtocolRegistry creates a protocol registry with all built-in protocol modules and
+// the plugins in protocolPluginDir registered. On error the registry holds the modules
+// registered before the failure, which a degraded daemon runs with.
+func newProtocolRegistry() (*protocol.Registry, error) {
+	registry := protocol.NewRegistry()
+
+	modules := []protocol.ProtocolModule{
+		protocol.NewEthereumModule(),
+		protocol.NewArbitrumModule(),
+		protocol.NewOptimismModule(),
+		protocol.NewPolygonModule(),
+		protocol.NewGenericModule(),
+	}
+	for _, module := range modules {
+		if err := registry.Register(module); err != nil {
+			return registry, fmt.Errorf("failed to register %s protocol module: %w", module.Name(), err)
+		}
+	}
+
+	if protocolPluginDir == "" {
+		return registry, nil
+	}
+
+	plugins, err := protocol.LoadPlugins(protocolPluginDir)
+	if err != nil {
+		return registry, fmt.Errorf("failed to load protocol plugins: %w", err)
+	}
+	for _, module := range plugins {
+		if err := registry.Register(module); err != nil {
+			return registry, fmt.Errorf("failed to register %s protocol module: %w", module.Name(), err)
+		}
+	}
+
+	return registry, nil
+}
+
+// snoozeActions returns the snooze links added to notifications, or nil when snooze
+// links are not configured
+func snoozeActions(cfg *config.Config) notification.ActionProvider {
+	if cfg.Snooze == nil {
+		return nil
+	}
+	return snooze.NewLinks(cfg.Snooze).Actions
+}
+
+// notificationPluginDir is the directory of notification plugin definitions, set by the
+// -notification-plugin-dir flag
+var notificationPluginDir string
+
+// newNotificationRegistry creates a notification registry with all built-in notification
+// modules and the plugins in notificationPluginDir registered. With actions, every
+// module's notifications carry the provider's actions. On error the registry holds the
+// modules registered before the failure, which a degraded daemon runs with.
+func newNotificationRegistry(actions notification.ActionProvider) (*notification.Registry, error) {
+	registry := notification.NewRegistry()
+
+	register := func(modules []notification.NotificationModule) error {
+		for _, module := range modules {
+			if actions != nil {
+				module = notification.WithActions(module, actions)
+			}
+			if err := registry.Register(module); err != nil {
+				return fmt.Errorf("failed to register %s notification module: %w", module.Name(), err)
+			}
+		}
+		return nil
+	}
+
+	if err := register([]notification.NotificationModule{notification.NewDiscordModule()}); err != nil {
+		return registry, err
+	}
+
+	if notificationPluginDir == "" {
+		return registry, nil
+	}
+
+	plugins, err := notification.LoadPlugins(notificationPluginDir)
+	if err != nil {
+		return registry, fmt.Errorf("failed to load notification plugins: %w", err)
+	}
+	if err := register(plugins); err != nil {
+		return registry, err
+	}
+
+	return registry, nil
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// nodeSyncSchedule is how often the daemon picks up nodes registered or deregistered
+// through another daemon sharing the database, unless database_nodes or kubernetes sets a
+// poll interval
+const nodeSyncSchedule = "*/30 * * * * *"
+
+// nodeSyncScheduleFor returns the schedule of the daemon's registered node sync
+func nodeSyncScheduleFor(cfg *config.Config) string {
+	switch {
+	case cfg.DatabaseNodes != nil:
+		return "@every " + cfg.DatabaseNodes.GetPollInterval().String()
+	case cfg.Kubernetes != nil:
+		return "@every " + cfg.Kubernetes.GetPollInterval().String()
+	}
+	return nodeSyncSchedule
+}
+
+// assignedHost returns the host whose registered nodes this daemon runs: database_nodes.host,
+// or the hostname
+func assignedHost(cfg *config.Config) string {
+	if cfg.DatabaseNodes != nil && cfg.DatabaseNodes.Host != "" {
+		return cfg.DatabaseNodes.Host
+	}
+	return daemonHost()
+}
+
+// withRegisteredNodes returns cfg with the registered nodes the local daemon runs added,
+// so CLI commands can address them. Nodes in the configuration file take precedence and
+// registered nodes that fail validation are left out.
+func withRegisteredNodes(ctx context.Context, cfg *config.Config, store scheduler.NodeStore) (*config.Config, error) {
+	registered, err := store.ListAssignedNodes(ctx, assignedHost(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range registered {
+		if _, exists := cfg.Nodes[node.NodeName]; exists {
+			continue
+		}
+		nodeConfig, err := config.ParseNodeConfig([]byte(node.Config))
+		if err != nil {
+			continue
+		}
+		if next, err := cfg.WithNode(node.NodeName, nodeConfig); err == nil {
+			cfg = next
+		}
+	}
+
+	return cfg, nil
+}
+
+// registeredNodeEntry is one registered node as printed by 'snapperd nodes list'
+type registeredNodeEntry struct {
+	Node      string    `json:"node"`
+	Host      string    `json:"host,omitempty"`
+	Protocol  string    `json:"protocol,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Schedule  string    `json:"schedule,omitempty"`
+	Config    string    `json:"config"` // Stored YAML node config
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// handleNodesCommand handles 'snapperd nodes', managing the node definitions stored in
+// the database. Daemons pick up changes on their next poll.
+func handleNodesCommand(configPath string, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return handleNodesListCommand(configPath, args[1:])
+		case "set":
+			return handleNodesSetCommand(configPath, args[1:])
+		case "remove":
+			return handleNodesRemoveCommand(configPath, args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: nodes command requires a subcommand\n")
+	fmt.Fprintf(os.Stderr, "Usage: snapperd nodes list [--host <host>] [--tenant <tenant>] [--output table|json]\n")
+	fmt.Fprintf(os.Stderr, "       snapperd nodes set [--host <host>] [--file <path>] <node>\n")
+	fmt.Fprintf(os.Stderr, "       snapperd nodes remove <node>\n")
+	return 1
+}
+
+// handleNodesListCommand lists the registered nodes, or those a host runs
+func handleNodesListCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("nodes list", flag.ContinueOnError)
+	host := fs.String("host", "", "Only show the nodes this host runs, including unassigned nodes")
+	tenant := fs.String("tenant", "", "Only show this tenant's nodes")
+	output := fs.String("output", "table", "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	switch *output {
+	case "table", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table or json)\n", *output)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	var nodes []database.RegisteredNode
+	if *host != "" {
+		nodes, err = db.ListAssignedNodes(ctx, *host)
+	} else {
+		nodes, err = db.ListRegisteredNodes(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	entries := make([]registeredNodeEntry, 0, len(nodes))
+	for _, node := range nodes {
+		entry := registeredNodeEntry{
+			Node:      node.NodeName,
+			Host:      node.Host,
+			Config:    node.Config,
+			UpdatedAt: node.UpdatedAt,
+		}
+		// A config that no longer parses is still listed so it can be replaced or removed,
+		// except when filtering by tenant
+		if nodeConfig, err := config.ParseNodeConfig([]byte(node.Config)); err == nil {
+			entry.Protocol = nodeConfig.Protocol
+			entry.Tenant = nodeConfig.Tenant
+			entry.Schedule = nodeConfig.Schedule
+		}
+		if *tenant != "" && entry.Tenant != *tenant {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entries)
+	} else {
+		err = printRegisteredNodesTable(entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// printRegisteredNodesTable prints one row per registered node
+func printRegisteredNodesTable(entries []registeredNodeEntry) error {
+	if len(entries) == 0 {
+		fmt.Println("No registered nodes.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tHOST\tPROTOCOL\tTENANT\tSCHEDULE\tUPDATED")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Node, orDefault(e.Host, "(any)"), orDefault(e.Protocol, "?"), orDefault(e.Tenant, "-"), orDefault(e.Schedule, "(global)"), e.UpdatedAt.Local().Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// handleNodesSetCommand registers a node, or replaces a registered node's config and host.
+// The node config is read from --file, or standard input, as a nodes entry in YAML or JSON.
+func handleNodesSetCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("nodes set", flag.ContinueOnError)
+	host := fs.String("host", "", "Host whose daemon runs the node (default: every daemon)")
+	file := fs.String("file", "-", "Node config file, or - for standard input")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: nodes set requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd nodes set [--host <host>] [--file <path>] <node>\n")
+		return 1
+	}
+	nodeName := fs.Arg(0)
+	if *host != "" {
+		if err := config.ValidateHostName(*host); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	var data []byte
+	var err error
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read node config: %v\n", err)
+		return 1
+	}
+	nodeConfig, err := config.ParseNodeConfig(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Validate the protocol against the modules available here, as the daemon does
+	protocolRegistry, err := newProtocolRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	config.SetProtocolValidator(protocolRegistry)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if _, exists := cfg.Nodes[nodeName]; exists {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' is defined in the configuration file\n", nodeName)
+		return 1
+	}
+	if _, err := cfg.WithNode(nodeName, nodeConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid node config: %v\n", err)
+		return 1
+	}
+	encoded, err := config.EncodeNodeConfig(nodeConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	// Nodes can be defined before any daemon has created the tables
+	if err := db.Migrate(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to run database migrations: %v\n", err)
+		return 1
+	}
+
+	existing, err := db.GetRegisteredNode(ctx, nodeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	now := time.Now()
+	if err := db.SaveRegisteredNode(ctx, database.RegisteredNode{
+		NodeName:     nodeName,
+		Host:         *host,
+		Config:       encoded,
+		RegisteredAt: now,
+		UpdatedAt:    now,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	action := "registered"
+	if existing != nil {
+		action = "updated"
+	}
+	recordAudit(ctx, db, database.AuditEntry{
+		Action:   database.AuditNodeRegistered,
+		NodeName: &nodeName,
+		Message:  fmt.Sprintf("%s for %s", action, orDefault(*host, "every host")),
+		Details:  database.JSONB{"host": *host, "protocol": nodeConfig.Protocol},
+	})
+	fmt.Printf("Node %s %s for %s\n", nodeName, action, orDefault(*host, "every host"))
+	return 0
+}
+
+// handleNodesRemoveCommand deregisters a node
+func handleNodesRemoveCommand(configPath string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: nodes remove requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd nodes remove <node>\n")
+		return 1
+	}
+	nodeName := args[0]
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	existing, err := db.GetRegisteredNode(ctx, nodeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if existing == nil {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' is not registered\n", nodeName)
+		return 1
+	}
+
+	if err := db.DeleteRegisteredNode(ctx, nodeName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	recordAudit(ctx, db, database.AuditEntry{Action: database.AuditNodeDeregistered, NodeName: &nodeName, Message: "removed"})
+
+	fmt.Printf("Node %s removed\n", nodeName)
+	return 0
+}
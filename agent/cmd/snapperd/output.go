@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/config"
+)
+
+// ANSI colors of upload statuses. Every code is the same length, so a tabwriter column of
+// colored statuses stays aligned.
+const (
+	ansiGreen   = "\033[32m"
+	ansiRed     = "\033[31m"
+	ansiYellow  = "\033[33m"
+	ansiDefault = "\033[39m"
+	ansiReset   = "\033[0m"
+)
+
+// statusColors maps upload statuses to their colors; other statuses use the default color
+var statusColors = map[string]string{
+	"completed": ansiGreen,
+	"failed":    ansiRed,
+	"running":   ansiYellow,
+}
+
+// resolveColor decides whether to color output from a --color value, else the configured
+// mode. auto colors only a terminal, and only when NO_COLOR is unset.
+func resolveColor(flagValue string, cfg *config.Config) (bool, error) {
+	mode := flagValue
+	if mode == "" {
+		mode = cfg.GetColor()
+	}
+	switch mode {
+	case config.ColorAlways:
+		return true, nil
+	case config.ColorNever:
+		return false, nil
+	case config.ColorAuto:
+		if _, set := os.LookupEnv("NO_COLOR"); set {
+			return false, nil
+		}
+		info, err := os.Stdout.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("invalid --color value '%s' (expected %s, %s or %s)", mode, config.ColorAuto, config.ColorAlways, config.ColorNever)
+	}
+}
+
+// colorStatus wraps text in the color of an upload status
+func colorStatus(status, text string, color bool) string {
+	if !color {
+		return text
+	}
+	code, ok := statusColors[status]
+	if !ok {
+		code = ansiDefault
+	}
+	return code + text + ansiReset
+}
+
+// resolveColumns returns the columns named by a comma-separated --columns value, else the
+// configured ones
+func resolveColumns(flagValue string, configured, available []string) ([]string, error) {
+	if flagValue == "" {
+		return configured, nil
+	}
+	var columns []string
+	for _, column := range strings.Split(flagValue, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, strings.ToLower(column))
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("--columns must name at least one column")
+	}
+	if err := config.ValidateColumns(columns, available); err != nil {
+		return nil, fmt.Errorf("invalid --columns: %w", err)
+	}
+	return columns, nil
+}
+
+// hasColumn reports whether columns includes column
+func hasColumn(columns []string, column string) bool {
+	return slices.Contains(columns, column)
+}
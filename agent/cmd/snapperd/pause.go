@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// handlePauseCommand handles 'snapperd pause <node>', stopping a node's scheduled uploads
+// until it is resumed. The pause is stored in the database, so the running daemon picks
+// it up at the node's next run without a configuration change or restart.
+func handlePauseCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("pause", flag.ContinueOnError)
+	reason := fs.String("reason", "", "Why the node is paused, shown by 'snapperd status'")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: pause requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd pause [--reason <text>] <node>\n")
+		return 1
+	}
+	nodeName := fs.Arg(0)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	// The node may be configured, or registered through another daemon or 'snapperd nodes'
+	if _, configured := cfg.Nodes[nodeName]; !configured {
+		registered, err := db.GetRegisteredNode(ctx, nodeName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if registered == nil {
+			fmt.Fprintf(os.Stderr, "Error: node '%s' not found in configuration\n", nodeName)
+			return 1
+		}
+	}
+
+	actor := operatorUser()
+	if actor == "" {
+		actor = "unknown"
+	}
+	pause := database.NodePause{NodeName: nodeName, Actor: actor, PausedAt: time.Now()}
+	if *reason != "" {
+		pause.Reason = reason
+	}
+	if err := db.PauseNode(ctx, pause); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	message := "paused"
+	if *reason != "" {
+		message += ": " + *reason
+	}
+	recordAudit(ctx, db, database.AuditEntry{Action: database.AuditNodePaused, NodeName: &nodeName, Message: message})
+
+	fmt.Printf("Node %s paused; scheduled uploads are skipped until 'snapperd resume %s'\n", nodeName, nodeName)
+	return 0
+}
+
+// handleResumeCommand handles 'snapperd resume <node>', restarting the scheduled uploads
+// of a node paused with 'snapperd pause' or whose circuit breaker is open. A node disabled
+// in the configuration stays disabled.
+func handleResumeCommand(configPath string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: resume requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd resume <node>\n")
+		return 1
+	}
+	nodeName := args[0]
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	pause, err := db.GetNodePause(ctx, nodeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if pause == nil {
+		fmt.Printf("Node %s is not paused\n", nodeName)
+		return 0
+	}
+	if err := db.ResumeNode(ctx, nodeName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	recordAudit(ctx, db, database.AuditEntry{
+		Action:   database.AuditNodeResumed,
+		NodeName: &nodeName,
+		Message:  fmt.Sprintf("resumed (paused by %s)", pause.Actor),
+	})
+
+	if pause.IsCircuit() {
+		fmt.Printf("Node %s resumed (circuit open after %d consecutive failed uploads since %s)\n", nodeName, *pause.CircuitFailures, pause.PausedAt.Local().Format(time.RFC3339))
+	} else {
+		fmt.Printf("Node %s resumed (paused by %s since %s)\n", nodeName, pause.Actor, pause.PausedAt.Local().Format(time.RFC3339))
+	}
+	if nodeConfig, configured := cfg.Nodes[nodeName]; configured && !nodeConfig.IsEnabled() {
+		fmt.Printf("Note: node %s is still disabled in the configuration (enabled: false)\n", nodeName)
+	}
+	return 0
+}
+
+// printPausedNodes prints the nodes whose scheduled uploads are stopped: those paused with
+// 'snapperd pause', with who paused them, those whose circuit breaker is open and those
+// disabled in the configuration
+func printPausedNodes(pauses []database.NodePause, cfg *config.Config) {
+	var disabled []string
+	for nodeName, nodeConfig := range cfg.Nodes {
+		if !nodeConfig.IsEnabled() {
+			disabled = append(disabled, nodeName)
+		}
+	}
+	sort.Strings(disabled)
+	if len(pauses) == 0 && len(disabled) == 0 {
+		return
+	}
+
+	fmt.Printf("\nPaused nodes (scheduled uploads skipped):\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, p := range pauses {
+		reason := ""
+		if p.Reason != nil {
+			reason = *p.Reason
+		}
+		if p.IsCircuit() {
+			reason = "circuit open: " + reason
+		}
+		fmt.Fprintf(w, "  %s\tsince %s\tby %s\t%s\n", p.NodeName, p.PausedAt.Local().Format(time.RFC3339), p.Actor, reason)
+	}
+	for _, nodeName := range disabled {
+		fmt.Fprintf(w, "  %s\tdisabled in configuration\t\t\n", nodeName)
+	}
+	w.Flush()
+}
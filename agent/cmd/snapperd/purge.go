@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// handlePurgeNodeCommand handles 'snapperd purge-node <node>', deleting every record of a
+// node that is no longer run. Nodes removed from the configuration keep their history
+// until purged.
+func handlePurgeNodeCommand(configPath string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: purge-node requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd purge-node <node>\n")
+		return 1
+	}
+	nodeName := args[0]
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if _, configured := cfg.Nodes[nodeName]; configured {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' is configured; remove it from the configuration and restart the daemon first\n", nodeName)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	registered, err := db.GetRegisteredNode(ctx, nodeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if registered != nil {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' is registered; remove it with 'snapperd nodes remove %s' first\n", nodeName, nodeName)
+		return 1
+	}
+
+	// Another daemon may still run a node this configuration does not have
+	activity, err := db.GetNodeActivity(ctx, nodeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if activity != nil && activity.InactiveSince == nil {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' is still run by the daemon on %s\n", nodeName, activity.Host)
+		return 1
+	}
+
+	deleted, err := db.PurgeNode(ctx, nodeName)
+	if errors.Is(err, database.ErrNodeUploadRunning) {
+		fmt.Fprintf(os.Stderr, "Error: node '%s' has a running upload; cancel it or wait for it to finish\n", nodeName)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(deleted) == 0 {
+		fmt.Printf("No records found for node %s\n", nodeName)
+		return 0
+	}
+	details := make(database.JSONB, len(deleted))
+	for table, rows := range deleted {
+		details[table] = rows
+	}
+	recordAudit(ctx, db, database.AuditEntry{Action: database.AuditNodePurged, NodeName: &nodeName, Message: "purged", Details: details})
+
+	tables := make([]string, 0, len(deleted))
+	for table := range deleted {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	fmt.Printf("Node %s purged:\n", nodeName)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, table := range tables {
+		fmt.Fprintf(w, "  %s\t%d rows\n", table, deleted[table])
+	}
+	w.Flush()
+	return 0
+}
+
+// printInactiveNodes prints the nodes no longer run whose history is kept, leaving out
+// nodes configured again that the daemon has not picked up yet
+func printInactiveNodes(nodes []database.NodeActivity, cfg *config.Config) {
+	var shown []database.NodeActivity
+	for _, node := range nodes {
+		if _, configured := cfg.Nodes[node.NodeName]; !configured {
+			shown = append(shown, node)
+		}
+	}
+	if len(shown) == 0 {
+		return
+	}
+
+	fmt.Printf("\nInactive nodes (history kept, delete with 'snapperd purge-node <node>'):\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, node := range shown {
+		fmt.Fprintf(w, "  %s\tsince %s\n", node.NodeName, node.InactiveSince.Local().Format(time.RFC3339))
+	}
+	w.Flush()
+}
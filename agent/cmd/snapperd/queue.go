@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// queueEntry is one upload queue entry as printed by the queue command
+type queueEntry struct {
+	Position        int                    `json:"position,omitempty"` // Dequeue order of pending entries
+	RequestID       int64                  `json:"request_id"`
+	Node            string                 `json:"node"`
+	Trigger         string                 `json:"trigger"`
+	Priority        int                    `json:"priority"`
+	Status          string                 `json:"status"`
+	TriggerMetadata map[string]interface{} `json:"trigger_metadata,omitempty"`
+	RequestedAt     time.Time              `json:"requested_at"`
+}
+
+// formatPosition renders the entry's queue position for table and CSV output
+func (e queueEntry) formatPosition(empty string) string {
+	if e.Position == 0 {
+		return empty
+	}
+	return strconv.Itoa(e.Position)
+}
+
+// handleQueueCommand handles 'snapperd queue list', showing the uploads waiting for the
+// daemon in the order it will start them
+func handleQueueCommand(configPath string, args []string) int {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintf(os.Stderr, "Error: queue command requires a subcommand\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapd queue list [--tenant <tenant>] [--output table|json|csv]\n")
+		return 1
+	}
+
+	fs := flag.NewFlagSet("queue list", flag.ContinueOnError)
+	output := fs.String("output", "table", "Output format: table, json or csv")
+	tenant := fs.String("tenant", "", "Only show requests for this tenant's nodes")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+	switch *output {
+	case "table", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table, json or csv)\n", *output)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if _, err := tenantConfig(cfg, *tenant); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	requests, err := db.ListUploadQueue(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	var tenantNodes map[string]bool
+	if *tenant != "" {
+		if tenantNodes, err = tenantNodeNames(ctx, db, cfg, *tenant); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	entries := make([]queueEntry, 0, len(requests))
+	position := 0
+	for _, request := range requests {
+		entry := queueEntry{
+			RequestID:       request.ID,
+			Node:            request.NodeName,
+			Trigger:         request.TriggerType,
+			Priority:        request.Priority,
+			Status:          request.Status,
+			TriggerMetadata: request.TriggerMetadata,
+			RequestedAt:     request.RequestedAt,
+		}
+		if request.Status == database.UploadRequestPending {
+			position++
+			entry.Position = position
+		}
+		// Positions count every tenant's requests, which share the queue
+		if tenantNodes != nil && !tenantNodes[request.NodeName] {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	switch *output {
+	case "json":
+		err = printQueueJSON(entries)
+	case "csv":
+		err = printQueueCSV(entries)
+	default:
+		err = printQueueTable(entries, cfg.MaxConcurrentUploads, time.Now())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// printQueueTable prints the concurrency limit followed by the queue entries
+func printQueueTable(entries []queueEntry, maxConcurrent int, now time.Time) error {
+	if maxConcurrent > 0 {
+		fmt.Printf("Concurrency limit: %d\n", maxConcurrent)
+	} else {
+		fmt.Printf("Concurrency limit: none (only manual requests are queued)\n")
+	}
+	if len(entries) == 0 {
+		fmt.Println("Upload queue is empty.")
+		return nil
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POS\tREQUEST\tNODE\tTRIGGER\tPRIORITY\tSTATUS\tWAITING")
+	for _, e := range entries {
+		waiting := now.Sub(e.RequestedAt).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\t%s\n", e.formatPosition("-"), e.RequestID, e.Node, e.Trigger, e.Priority, e.Status, waiting)
+	}
+	return w.Flush()
+}
+
+// printQueueJSON prints entries as a JSON array
+func printQueueJSON(entries []queueEntry) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// printQueueCSV prints entries as CSV with a header row
+func printQueueCSV(entries []queueEntry) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"position", "request_id", "node", "trigger", "priority", "status", "requested_at"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := []string{
+			e.formatPosition(""), strconv.FormatInt(e.RequestID, 10), e.Node, e.Trigger,
+			strconv.Itoa(e.Priority), e.Status, e.RequestedAt.Format(time.RFC3339),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
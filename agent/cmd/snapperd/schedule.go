@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// handleScheduleCommand handles 'snapperd schedule', showing each node's last and next
+// scheduled run, marking the runs of disabled and paused nodes that will be skipped
+func handleScheduleCommand(configPath string) int {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	states, err := db.GetScheduleStates(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	stateByNode := make(map[string]database.ScheduleState, len(states))
+	for _, state := range states {
+		stateByNode[state.NodeName] = state
+	}
+
+	pauses, err := db.GetNodePauses(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	// Nodes paused by an operator or by their circuit breaker, with how their runs are marked
+	paused := make(map[string]string, len(pauses))
+	for _, pause := range pauses {
+		paused[pause.NodeName] = "paused"
+		if pause.IsCircuit() {
+			paused[pause.NodeName] = "circuit open"
+		}
+	}
+
+	nodeNames := make([]string, 0, len(cfg.Nodes))
+	for nodeName := range cfg.Nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	now := time.Now()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tSCHEDULE\tLAST RUN\tLAST RESULT\tNEXT RUN")
+	for _, nodeName := range nodeNames {
+		nodeSchedule := cfg.GetNodeSchedule(nodeName)
+		timezone := cfg.GetNodeTimezone(nodeName)
+		lastRun, lastResult, nextRun := "-", "-", "-"
+
+		state, recorded := stateByNode[nodeName]
+		if recorded && state.LastRunAt != nil {
+			lastRun = formatRunTime(*state.LastRunAt, timezone)
+		}
+		if recorded && state.LastResult != nil {
+			lastResult = *state.LastResult
+		}
+
+		// Use the recorded next run while it is still ahead; otherwise the daemon has not
+		// run since it was due (or never ran), so compute it from the schedule
+		if recorded && state.NextRunAt != nil && state.NextRunAt.After(now) {
+			nextRun = formatRunTime(*state.NextRunAt, timezone)
+		} else if parsed, err := scheduler.ParseSchedule(nodeSchedule, scheduler.SplayOffset(nodeName, cfg.GetNodeSplay(nodeName))); err == nil {
+			nextRun = formatRunTime(parsed.Next(now), timezone)
+		}
+		// The run still fires, but is skipped
+		if nodeConfig := cfg.Nodes[nodeName]; !nodeConfig.IsEnabled() {
+			nextRun += " (disabled)"
+		} else if label, ok := paused[nodeName]; ok {
+			nextRun += " (" + label + ")"
+		}
+
+		scheduleLabel := nodeSchedule
+		if groupName := cfg.GetNodeConsistencyGroup(nodeName); groupName != "" {
+			scheduleLabel = fmt.Sprintf("%s (group %s)", nodeSchedule, groupName)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", nodeName, scheduleLabel, lastRun, lastResult, nextRun)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// jobOverdueGrace is how long past its next run a job may be before it is marked overdue
+const jobOverdueGrace = time.Minute
+
+// formatRunTime formats a run time in a schedule's timezone, naming the zone, or in local
+// time for schedules without a timezone
+func formatRunTime(t time.Time, timezone string) string {
+	if timezone == "" {
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	return t.In(loc).Format("2006-01-02 15:04:05 MST")
+}
+
+// printJobStates lists each daemon's scheduled jobs with their last run and next run, as
+// recorded by the scheduler, in the global schedule timezone
+func printJobStates(states []database.JobState, timezone string, now time.Time) {
+	if len(states) == 0 {
+		fmt.Println("No scheduled jobs recorded; is the daemon running?")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tJOB\tSCHEDULE\tLAST RUN\tRESULT\tDURATION\tNEXT RUN\tERROR")
+	for _, state := range states {
+		lastRun, result, duration, nextRun, lastError := "-", "-", "-", "-", ""
+		if state.LastRunAt != nil {
+			lastRun = formatRunTime(*state.LastRunAt, timezone)
+		}
+		if state.LastResult != nil {
+			result = *state.LastResult
+		}
+		if state.LastDurationMs != nil {
+			duration = (time.Duration(*state.LastDurationMs) * time.Millisecond).Round(time.Millisecond).String()
+		}
+		if state.NextRunAt != nil {
+			nextRun = formatRunTime(*state.NextRunAt, timezone)
+			if now.Sub(*state.NextRunAt) > jobOverdueGrace {
+				nextRun += " (overdue)"
+			}
+		}
+		if state.LastError != nil {
+			lastError = *state.LastError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", state.Host, state.JobName, state.Schedule, lastRun, result, duration, nextRun, lastError)
+	}
+	w.Flush()
+}
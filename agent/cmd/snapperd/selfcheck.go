@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/executor"
+)
+
+// bvCheckTimeout bounds the bv presence check at startup
+const bvCheckTimeout = 10 * time.Second
+
+// checkBV verifies bv can be run through the configured command prefix and returns
+// its version
+func checkBV(ctx context.Context, exec *executor.DefaultExecutor) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, bvCheckTimeout)
+	defer cancel()
+
+	stdout, stderr, err := exec.Execute(ctx, "bv", "--version")
+	if err != nil {
+		if stderr = strings.TrimSpace(stderr); stderr != "" {
+			return "", fmt.Errorf("bv --version failed: %w: %s", err, stderr)
+		}
+		return "", fmt.Errorf("bv --version failed: %w", err)
+	}
+
+	version, _, _ := strings.Cut(strings.TrimSpace(stdout), "\n")
+	return version, nil
+}
+
+// registrationValidator reports whether a module name is registered
+type registrationValidator interface {
+	IsRegistered(name string) bool
+}
+
+// checkNodeProtocols returns an error naming the configured nodes whose protocol has no
+// registered module
+func checkNodeProtocols(cfg *config.Config, registry registrationValidator) error {
+	var missing []string
+	for nodeName, nodeConfig := range cfg.Nodes {
+		if !registry.IsRegistered(nodeConfig.Protocol) {
+			missing = append(missing, fmt.Sprintf("%s (%s)", nodeName, nodeConfig.Protocol))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no protocol module registered for nodes: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkNotificationTypes returns an error naming the configured notification types that
+// have no registered module
+func checkNotificationTypes(cfg *config.Config, registry registrationValidator) error {
+	configs := []*config.NotificationConfig{cfg.Notifications}
+	for _, nodeConfig := range cfg.Nodes {
+		configs = append(configs, nodeConfig.Notifications)
+	}
+	for _, tenant := range cfg.Tenants {
+		configs = append(configs, tenant.Notifications)
+	}
+
+	missing := make(map[string]bool)
+	for _, notifyConfig := range configs {
+		if notifyConfig == nil {
+			continue
+		}
+		for typeName := range notifyConfig.Types {
+			if !registry.IsRegistered(typeName) {
+				missing[typeName] = true
+			}
+		}
+	}
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for typeName := range missing {
+			names = append(names, typeName)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no notification module registered for types: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// maxTimelineRows bounds the progress samples printed in text output; JSON output
+// includes every sample
+const maxTimelineRows = 20
+
+// maxStatusOutputLines bounds the lines of the latest engine output printed in text
+// output; JSON output includes the output stored at every status change
+const maxStatusOutputLines = 10
+
+// showEntry is the full record of one upload as printed by the show command
+type showEntry struct {
+	historyEntry
+	NodeType            string                 `json:"node_type,omitempty"`
+	ProtocolData        map[string]interface{} `json:"protocol_data,omitempty"`
+	ProgressPercent     *float64               `json:"progress_percent,omitempty"`
+	LastProgressCheck   *time.Time             `json:"last_progress_check,omitempty"`
+	CompletionMessage   *string                `json:"completion_message,omitempty"`
+	StalledSince        *time.Time             `json:"stalled_since,omitempty"`
+	ChunksPerMinute     *float64               `json:"chunks_per_minute,omitempty"`
+	EstimatedCompletion *time.Time             `json:"estimated_completion,omitempty"`
+	FinishedAt          *time.Time             `json:"finished_at,omitempty"` // When bv reported the job finished
+	RestoreVerifiedAt   *time.Time             `json:"restore_verified_at,omitempty"`
+	RestoreMessage      *string                `json:"restore_verification_message,omitempty"`
+	CoalescedTriggers   int                    `json:"coalesced_triggers,omitempty"` // Later upload requests merged into this upload
+	Attempt             int                    `json:"attempt"`                      // Attempt of the upload, from 1
+	RetryOf             *int64                 `json:"retry_of,omitempty"`           // The failed upload this one retries
+	Events              []showEvent            `json:"events"`
+	Timeline            []showSample           `json:"progress_timeline"`
+	StatusOutputs       []showStatusOutput     `json:"status_outputs"`
+	Contents            *showContents          `json:"contents,omitempty"`
+	Notifications       []showNotification     `json:"notifications"`
+	LogExcerpt          []string               `json:"log_excerpt,omitempty"`
+	LogError            string                 `json:"log_error,omitempty"` // Why the job log could not be read
+}
+
+// showEvent is a step in an upload's life, derived from its records
+type showEvent struct {
+	At      time.Time `json:"at"`
+	Event   string    `json:"event"`
+	Message string    `json:"message,omitempty"`
+}
+
+// showStatusOutput is the engine output stored when the upload's status changed
+type showStatusOutput struct {
+	RecordedAt time.Time `json:"recorded_at"`
+	State      string    `json:"state"`
+	RawOutput  string    `json:"raw_output"`
+}
+
+// showNotification is one notification delivery attempt about the upload
+type showNotification struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	Event       string    `json:"event"`
+	Type        string    `json:"type"`
+	TargetHash  string    `json:"target_hash"`
+	Status      string    `json:"status"`
+	StatusCode  *int      `json:"status_code,omitempty"`
+	Error       *string   `json:"error,omitempty"`
+}
+
+// showSample is one progress sample of the upload
+type showSample struct {
+	RecordedAt      time.Time `json:"recorded_at"`
+	ChunksCompleted int       `json:"chunks_completed"`
+	ChunksTotal     *int      `json:"chunks_total,omitempty"`
+}
+
+// showContents summarizes the recorded content listing of a snapshot
+type showContents struct {
+	Objects      int    `json:"objects"`
+	TotalBytes   int64  `json:"total_bytes"`
+	Command      string `json:"command,omitempty"`        // CLI command printing the full listing
+	BaseCommand  string `json:"base_command,omitempty"`   // Listing of the incremental's base snapshot
+	BaseUploadID *int64 `json:"base_upload_id,omitempty"` // Base snapshot of an incremental upload
+}
+
+// handleShowCommand handles 'snapperd show <upload-id>', printing everything recorded
+// about one upload
+func handleShowCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	output := fs.String("output", "text", "Output format: text or json")
+	logLines := fs.Int("logs", 20, "Upload job log lines to include for the node's latest upload (0 disables)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: show command requires an upload ID\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapd show [--output text|json] [--logs N] <upload-id>\n")
+		return 1
+	}
+	uploadID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || uploadID <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid upload ID '%s'\n", fs.Arg(0))
+		return 1
+	}
+	switch *output {
+	case "text", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected text or json)\n", *output)
+		return 1
+	}
+	if *logLines < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --logs cannot be negative\n")
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	record, err := db.GetUpload(ctx, uploadID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if record == nil {
+		fmt.Fprintf(os.Stderr, "Error: upload %d not found\n", uploadID)
+		return 1
+	}
+
+	entry, err := buildShowEntry(ctx, db, record)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *logLines > 0 {
+		entry.LogExcerpt, entry.LogError = fetchLogExcerpt(ctx, db, cfg, record, *logLines)
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entry)
+	} else {
+		err = printShowText(entry)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// buildShowEntry gathers the upload's record, progress samples, derived events and
+// content listing summary
+func buildShowEntry(ctx context.Context, db *database.DB, record *database.Upload) (showEntry, error) {
+	entry := showEntry{
+		historyEntry:        newHistoryEntry(*record),
+		NodeType:            record.NodeType,
+		ProtocolData:        record.ProtocolData,
+		ProgressPercent:     record.ProgressPercent,
+		LastProgressCheck:   record.LastProgressCheck,
+		CompletionMessage:   record.CompletionMessage,
+		StalledSince:        record.StalledSince,
+		ChunksPerMinute:     record.ChunksPerMinute,
+		EstimatedCompletion: record.EstimatedCompletion,
+		FinishedAt:          record.FinishedAt,
+		RestoreVerifiedAt:   record.RestoreVerifiedAt,
+		RestoreMessage:      record.RestoreVerificationMessage,
+		CoalescedTriggers:   record.CoalescedTriggers,
+		Attempt:             max(record.Attempt, 1),
+		RetryOf:             record.RetryOf,
+		Timeline:            []showSample{},
+		StatusOutputs:       []showStatusOutput{},
+		Notifications:       []showNotification{},
+	}
+
+	samples, err := db.GetProgressSamples(ctx, record.ID, time.Time{})
+	if err != nil {
+		return entry, err
+	}
+	for _, s := range samples {
+		entry.Timeline = append(entry.Timeline, showSample{RecordedAt: s.RecordedAt, ChunksCompleted: s.ChunksCompleted, ChunksTotal: s.ChunksTotal})
+	}
+
+	outputs, err := db.GetStatusOutputs(ctx, record.ID)
+	if err != nil {
+		return entry, err
+	}
+	for _, o := range outputs {
+		entry.StatusOutputs = append(entry.StatusOutputs, showStatusOutput{RecordedAt: o.RecordedAt, State: o.State, RawOutput: o.RawOutput})
+	}
+
+	request, err := db.GetUploadRequestForUpload(ctx, record.ID)
+	if err != nil {
+		return entry, err
+	}
+	run, err := db.GetConsistencyGroupRunForUpload(ctx, record.ID)
+	if err != nil {
+		return entry, err
+	}
+	throttles, err := db.GetThrottleEvents(ctx, record.ID)
+	if err != nil {
+		return entry, err
+	}
+	entry.Events = uploadEvents(record, request, run, throttles, outputs)
+
+	attempts, err := db.GetNotificationAttempts(ctx, record.ID)
+	if err != nil {
+		return entry, err
+	}
+	for _, a := range attempts {
+		entry.Notifications = append(entry.Notifications, showNotification{
+			AttemptedAt: a.AttemptedAt,
+			Event:       a.Event,
+			Type:        a.NotificationType,
+			TargetHash:  a.TargetHash,
+			Status:      a.Status,
+			StatusCode:  a.StatusCode,
+			Error:       a.ErrorMessage,
+		})
+	}
+
+	objects, err := db.GetUploadObjects(ctx, record.ID)
+	if err != nil {
+		return entry, err
+	}
+	if len(objects) > 0 || record.BaseUploadID != nil {
+		contents := &showContents{Objects: len(objects), BaseUploadID: record.BaseUploadID}
+		for _, object := range objects {
+			if object.SizeBytes != nil {
+				contents.TotalBytes += *object.SizeBytes
+			}
+		}
+		if len(objects) > 0 {
+			contents.Command = fmt.Sprintf("snapperd contents %d", record.ID)
+		}
+		if record.BaseUploadID != nil {
+			contents.BaseCommand = fmt.Sprintf("snapperd contents %d", *record.BaseUploadID)
+		}
+		entry.Contents = contents
+	}
+
+	return entry, nil
+}
+
+// uploadEvents derives the upload's timeline from its record, the queued request it was
+// started for, the consistency group run that started it, the guardrail actions taken
+// on it and the status changes the monitor saw
+func uploadEvents(record *database.Upload, request *database.UploadRequest, run *database.ConsistencyGroupRun, throttles []database.ThrottleEvent, outputs []database.StatusOutput) []showEvent {
+	var events []showEvent
+
+	if request != nil {
+		events = append(events, showEvent{
+			At:      request.RequestedAt,
+			Event:   "requested",
+			Message: fmt.Sprintf("%s request %d queued (priority %d)", request.TriggerType, request.ID, request.Priority),
+		})
+		if request.ProcessedAt != nil {
+			events = append(events, showEvent{At: *request.ProcessedAt, Event: "dequeued", Message: fmt.Sprintf("request %d claimed by the daemon", request.ID)})
+		}
+	}
+	if run != nil {
+		events = append(events, showEvent{At: run.StartedAt, Event: "group_run", Message: fmt.Sprintf("consistency group %s run %d (%s)", run.GroupName, run.ID, run.Status)})
+	}
+
+	started := fmt.Sprintf("%s upload started", record.TriggerType)
+	if record.BaseUploadID != nil {
+		started = fmt.Sprintf("%s incremental upload started against upload %d", record.TriggerType, *record.BaseUploadID)
+	}
+	events = append(events, showEvent{At: record.StartedAt, Event: "started", Message: started})
+
+	for _, throttle := range throttles {
+		event := "throttled"
+		switch throttle.Action {
+		case config.GuardrailActionPause:
+			event = "paused"
+		case config.GuardrailActionRecord:
+			event = "resource_pressure"
+		}
+		events = append(events, showEvent{At: throttle.StartedAt, Event: event, Message: throttle.Reason})
+		if throttle.EndedAt != nil {
+			events = append(events, showEvent{At: *throttle.EndedAt, Event: "resumed", Message: fmt.Sprintf("after %s", throttle.EndedAt.Sub(throttle.StartedAt).Round(time.Second))})
+		}
+	}
+	for _, output := range outputs {
+		events = append(events, showEvent{At: output.RecordedAt, Event: "status_changed", Message: output.State})
+	}
+	if record.StalledSince != nil {
+		events = append(events, showEvent{At: *record.StalledSince, Event: "stalled", Message: "chunk progress stopped advancing"})
+	}
+	if record.FinishedAt != nil {
+		events = append(events, showEvent{At: *record.FinishedAt, Event: "finished", Message: "bv reported the job finished"})
+	}
+	if record.CompletedAt != nil {
+		message := ""
+		if record.ErrorMessage != nil {
+			message = *record.ErrorMessage
+		} else if record.CompletionMessage != nil {
+			message = *record.CompletionMessage
+		}
+		if record.DetectionLagSeconds != nil {
+			lag := time.Duration(*record.DetectionLagSeconds * float64(time.Second)).Round(time.Second)
+			message = strings.TrimSpace(fmt.Sprintf("%s (detected %s after bv finished)", message, lag))
+		}
+		events = append(events, showEvent{At: *record.CompletedAt, Event: record.Status, Message: message})
+	}
+	if record.RestoreVerification != nil && record.RestoreVerifiedAt != nil {
+		message := ""
+		if record.RestoreVerificationMessage != nil {
+			message = *record.RestoreVerificationMessage
+		}
+		events = append(events, showEvent{At: *record.RestoreVerifiedAt, Event: "restore_" + *record.RestoreVerification, Message: message})
+	}
+
+	sort.SliceStable(events, func(i, k int) bool { return events[i].At.Before(events[k].At) })
+	return events
+}
+
+// fetchLogExcerpt returns the last lines of the upload's log: the tail stored when it
+// finished, else the log of the node's upload from its engine. The engines keep the log of
+// the node's most recent upload only, so older uploads without a stored tail have no
+// excerpt.
+func fetchLogExcerpt(ctx context.Context, db *database.DB, cfg *config.Config, record *database.Upload, n int) ([]string, string) {
+	stored, err := db.GetUploadLogs(ctx, record.ID)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if len(stored) > 0 {
+		if len(stored) > n {
+			stored = stored[len(stored)-n:]
+		}
+		lines := make([]string, 0, len(stored))
+		for _, line := range stored {
+			lines = append(lines, line.Line)
+		}
+		return lines, ""
+	}
+
+	latestID := int64(0)
+	running, err := db.GetRunningUploadForNode(ctx, record.NodeName)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if running != nil {
+		latestID = running.ID
+	} else {
+		finished, err := db.ListUploads(ctx, database.UploadFilter{NodeName: record.NodeName, Limit: 1})
+		if err != nil {
+			return nil, err.Error()
+		}
+		if len(finished) > 0 {
+			latestID = finished[0].ID
+		}
+	}
+	if latestID != record.ID {
+		return nil, "not the node's latest upload; only the latest upload's log is kept"
+	}
+
+	// Failures are reported in the output instead of the executor's log
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	exec, err := newExecutor(cfg, log)
+	if err != nil {
+		return nil, err.Error()
+	}
+	kubeClient, err := newKubeClient(cfg)
+	if err != nil {
+		return nil, err.Error()
+	}
+	uploadMgr := newUploadManager(exec, db, cfg, log)
+	engines := newNodeEngines(exec, uploadMgr, db, log)
+	engines.setKubeClient(kubeClient)
+	engines.configure(record.NodeName, cfg.Nodes[record.NodeName])
+	lines, err := uploadMgr.FetchJobLogs(ctx, record.NodeName, n)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return lines, ""
+}
+
+// printShowText prints the upload's record as sections of human-readable text
+func printShowText(e showEntry) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Upload %d\n", e.ID)
+	nodeInfo := e.Protocol
+	if e.NodeType != "" {
+		nodeInfo += ", " + e.NodeType
+	}
+	fmt.Fprintf(w, "  Node:\t%s (%s)\n", e.Node, nodeInfo)
+	fmt.Fprintf(w, "  Status:\t%s\n", e.Status)
+	trigger := e.Trigger
+	if len(e.TriggerMetadata) > 0 {
+		trigger += " " + e.formatTriggerMetadata()
+	}
+	fmt.Fprintf(w, "  Trigger:\t%s\n", trigger)
+	if e.CoalescedTriggers > 0 {
+		fmt.Fprintf(w, "  Coalesced:\t%d later requests joined this upload\n", e.CoalescedTriggers)
+	}
+	if e.RetryOf != nil {
+		fmt.Fprintf(w, "  Attempt:\t%d, retrying upload %d\n", e.Attempt, *e.RetryOf)
+	}
+	fmt.Fprintf(w, "  Started:\t%s\n", e.StartedAt.Local().Format(time.RFC3339))
+	if e.CompletedAt != nil {
+		fmt.Fprintf(w, "  Completed:\t%s (took %s)\n", e.CompletedAt.Local().Format(time.RFC3339), e.formatDuration())
+	}
+	if e.ProgressPercent != nil || e.ChunksCompleted != nil {
+		progress := e.formatChunks() + " chunks"
+		if e.ProgressPercent != nil {
+			progress = fmt.Sprintf("%.1f%% (%s)", *e.ProgressPercent, progress)
+		}
+		fmt.Fprintf(w, "  Progress:\t%s\n", progress)
+	}
+	if e.ChunksPerMinute != nil {
+		throughput := fmt.Sprintf("%.1f chunks/min", *e.ChunksPerMinute)
+		if e.EstimatedCompletion != nil && e.CompletedAt == nil {
+			throughput += fmt.Sprintf(", ETA %s", e.EstimatedCompletion.Local().Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "  Throughput:\t%s\n", throughput)
+	}
+	if e.Error != nil {
+		fmt.Fprintf(w, "  Error:\t%s\n", *e.Error)
+	} else if e.CompletionMessage != nil {
+		fmt.Fprintf(w, "  Message:\t%s\n", *e.CompletionMessage)
+	}
+	if e.SizeBytes != nil {
+		fmt.Fprintf(w, "  Size:\t%s (%d bytes)\n", formatBytes(*e.SizeBytes), *e.SizeBytes)
+	}
+	if e.Compression != nil && e.RawSizeBytes != nil && e.CompressedSizeBytes != nil {
+		compression := *e.Compression
+		if e.CompressionLevel != nil && *e.CompressionLevel != 0 {
+			compression += fmt.Sprintf(" level %d", *e.CompressionLevel)
+		}
+		compression += fmt.Sprintf(", %d bytes to %d", *e.RawSizeBytes, *e.CompressedSizeBytes)
+		if e.CompressionRatio != nil {
+			compression += fmt.Sprintf(" (ratio %.2f)", *e.CompressionRatio)
+		}
+		fmt.Fprintf(w, "  Compression:\t%s\n", compression)
+	}
+	if e.RestoreVerification != nil {
+		restore := *e.RestoreVerification
+		if e.RestoreVerifiedAt != nil {
+			restore += fmt.Sprintf(" at %s", e.RestoreVerifiedAt.Local().Format(time.RFC3339))
+		}
+		if e.RestoreMessage != nil && *e.RestoreMessage != "" {
+			restore += fmt.Sprintf(" (%s)", *e.RestoreMessage)
+		}
+		fmt.Fprintf(w, "  Restore:\t%s\n", restore)
+	}
+	if e.Contents != nil {
+		if e.Contents.Command != "" {
+			fmt.Fprintf(w, "  Contents:\t%d objects, %d bytes (%s)\n", e.Contents.Objects, e.Contents.TotalBytes, e.Contents.Command)
+		}
+		if e.Contents.BaseUploadID != nil {
+			fmt.Fprintf(w, "  Base:\tupload %d (%s)\n", *e.Contents.BaseUploadID, e.Contents.BaseCommand)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(e.ProtocolData) > 0 {
+		fmt.Printf("\nProtocol data:\n")
+		keys := make([]string, 0, len(e.ProtocolData))
+		for key := range e.ProtocolData {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %s: %v\n", key, e.ProtocolData[key])
+		}
+	}
+
+	fmt.Printf("\nEvents:\n")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, event := range e.Events {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", event.At.Local().Format(time.RFC3339), event.Event, event.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(e.Timeline) > 0 {
+		rows := thinTimeline(e.Timeline, maxTimelineRows)
+		fmt.Printf("\nProgress timeline (%d of %d samples):\n", len(rows), len(e.Timeline))
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, s := range rows {
+			chunks := strconv.Itoa(s.ChunksCompleted)
+			if s.ChunksTotal != nil {
+				chunks += "/" + strconv.Itoa(*s.ChunksTotal)
+			}
+			fmt.Fprintf(w, "  %s\t%s\n", s.RecordedAt.Local().Format(time.RFC3339), chunks)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(e.Notifications) == 0 {
+		fmt.Printf("\nNotifications: none recorded\n")
+	} else {
+		fmt.Printf("\nNotifications:\n")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, n := range e.Notifications {
+			line := fmt.Sprintf("  %s\t%s\t%s\t%s\t%s", n.AttemptedAt.Local().Format(time.RFC3339), n.Event, n.Type, n.TargetHash, n.Status)
+			if n.StatusCode != nil {
+				line += fmt.Sprintf(" (%d)", *n.StatusCode)
+			}
+			if n.Error != nil {
+				line += "\t" + *n.Error
+			}
+			fmt.Fprintln(w, line)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(e.StatusOutputs) > 0 {
+		last := e.StatusOutputs[len(e.StatusOutputs)-1]
+		lines := strings.Split(strings.TrimRight(last.RawOutput, "\n"), "\n")
+		if len(lines) > maxStatusOutputLines {
+			lines = lines[len(lines)-maxStatusOutputLines:]
+		}
+		fmt.Printf("\nLast engine output (%s, %s, last %d lines):\n", last.State, last.RecordedAt.Local().Format(time.RFC3339), len(lines))
+		for _, line := range lines {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if len(e.LogExcerpt) > 0 {
+		fmt.Printf("\nUpload job log (last %d lines):\n", len(e.LogExcerpt))
+		for _, line := range e.LogExcerpt {
+			fmt.Printf("  %s\n", line)
+		}
+	} else if e.LogError != "" {
+		fmt.Printf("\nUpload job log: unavailable (%s)\n", e.LogError)
+	}
+
+	return nil
+}
+
+// thinTimeline picks at most max evenly spaced samples, always keeping the first and last
+func thinTimeline(samples []showSample, max int) []showSample {
+	if len(samples) <= max || max < 2 {
+		return samples
+	}
+	rows := make([]showSample, 0, max)
+	step := float64(len(samples)-1) / float64(max-1)
+	for i := 0; i < max; i++ {
+		rows = append(rows, samples[int(float64(i)*step+0.5)])
+	}
+	return rows
+}
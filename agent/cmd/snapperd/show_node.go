@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// nodeActionEntry is one automatic action as printed by the show-node command
+type nodeActionEntry struct {
+	Time     time.Time              `json:"time"`
+	Action   string                 `json:"action"`
+	UploadID *int64                 `json:"upload_id,omitempty"`
+	Message  string                 `json:"message"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// handleShowNodeCommand handles 'snapperd show-node <node>', printing the node's log of
+// what the daemon did on its own (timeouts, cancellations, guardrail actions, resumed
+// uploads, resent notifications and coalesced requests), most recent first
+func handleShowNodeCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("show-node", flag.ContinueOnError)
+	since := fs.String("since", "7d", "Only show actions within this window (e.g. 7d, 12h)")
+	limit := fs.Int("limit", 50, "Maximum number of actions to show (0 = no limit)")
+	output := fs.String("output", "table", "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: show-node requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd show-node [--since <window>] [--limit <n>] [--output table|json] <node>\n")
+		return 1
+	}
+	nodeName := fs.Arg(0)
+
+	if *limit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --limit must not be negative\n")
+		return 1
+	}
+	switch *output {
+	case "table", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (expected table or json)\n", *output)
+		return 1
+	}
+	window, err := parseSince(*since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	actions, err := db.GetNodeActions(ctx, nodeName, time.Now().Add(-window), *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	entries := make([]nodeActionEntry, 0, len(actions))
+	for _, a := range actions {
+		entries = append(entries, nodeActionEntry{
+			Time:     a.CreatedAt,
+			Action:   a.Action,
+			UploadID: a.UploadID,
+			Message:  a.Message,
+			Details:  a.Details,
+		})
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Printf("Node: %s\n", nodeName)
+	if _, configured := cfg.Nodes[nodeName]; !configured {
+		fmt.Printf("Note: node %s is not in the configuration\n", nodeName)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No automatic actions in the last %s\n", *since)
+		return 0
+	}
+
+	fmt.Printf("\nAutomatic actions (last %s, most recent first):\n", *since)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TIME\tACTION\tUPLOAD\tMESSAGE")
+	for _, e := range entries {
+		uploadID := "-"
+		if e.UploadID != nil {
+			uploadID = strconv.FormatInt(*e.UploadID, 10)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Action, uploadID, e.Message)
+	}
+	w.Flush()
+	return 0
+}
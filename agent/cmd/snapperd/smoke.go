@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/nodexeus/agent/internal/logger"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+)
+
+// smokeCheck records the outcome of one smoke test stage
+type smokeCheck struct {
+	name   string
+	passed bool
+	detail string
+}
+
+// smokeRun collects the smoke test checks in execution order
+type smokeRun struct {
+	checks []smokeCheck
+}
+
+// pass records a successful check
+func (r *smokeRun) pass(name, format string, args ...interface{}) {
+	r.checks = append(r.checks, smokeCheck{name: name, passed: true, detail: fmt.Sprintf(format, args...)})
+}
+
+// fail records a failed check
+func (r *smokeRun) fail(name, format string, args ...interface{}) {
+	r.checks = append(r.checks, smokeCheck{name: name, passed: false, detail: fmt.Sprintf(format, args...)})
+}
+
+// handleSmokeCommand handles the 'snapperd smoke <node>' subcommand. It runs the full
+// upload pipeline for a node against the simulated bv backend and a scratch database,
+// then prints a pass/fail summary.
+func handleSmokeCommand(configPath string, consoleMode bool, args []string) int {
+	fs := flag.NewFlagSet("smoke", flag.ContinueOnError)
+	sendNotifications := fs.Bool("notify", false, "Send a test notification to every configured notification type")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: smoke command requires a node name\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd smoke [--notify] <node>\n")
+		return 1
+	}
+	nodeName := fs.Arg(0)
+
+	// Keep the summary readable; only warnings and errors are logged
+	log := logger.New(logger.Config{
+		Level:       "warn",
+		ConsoleMode: consoleMode,
+	})
+
+	run := &smokeRun{}
+	runSmokeTest(context.Background(), run, configPath, nodeName, *sendNotifications, log)
+
+	fmt.Printf("Smoke test for node '%s'\n", nodeName)
+	passed := 0
+	for _, check := range run.checks {
+		result := "FAIL"
+		if check.passed {
+			result = "PASS"
+			passed++
+		}
+		fmt.Printf("  [%s] %-14s %s\n", result, check.name, check.detail)
+	}
+	fmt.Println()
+
+	if passed != len(run.checks) {
+		fmt.Printf("Result: FAIL (%d/%d checks passed)\n", passed, len(run.checks))
+		return 1
+	}
+
+	fmt.Printf("Result: PASS (%d/%d checks passed)\n", passed, len(run.checks))
+	return 0
+}
+
+// runSmokeTest executes the smoke test stages, stopping at the first stage later stages depend on
+func runSmokeTest(ctx context.Context, run *smokeRun, configPath, nodeName string, sendNotifications bool, log *logger.Logger) {
+	// Stage 1: module registration (registries must exist before config validation)
+	protocolRegistry, err := newProtocolRegistry()
+	if err != nil {
+		run.fail("modules", "%v", err)
+		return
+	}
+	notificationRegistry, err := newNotificationRegistry(nil)
+	if err != nil {
+		run.fail("modules", "%v", err)
+		return
+	}
+	config.SetProtocolValidator(protocolRegistry)
+	config.SetNotificationValidator(notificationRegistry)
+	run.pass("modules", "%d protocol names, %d notification types registered", len(protocolRegistry.List()), len(notificationRegistry.List()))
+
+	// Stage 2: configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		run.fail("config", "%v", err)
+		return
+	}
+	nodeConfig, exists := cfg.Nodes[nodeName]
+	if !exists {
+		run.fail("config", "node '%s' not found in %s", nodeName, configPath)
+		return
+	}
+	run.pass("config", "loaded %s (%d nodes, protocol %s)", configPath, len(cfg.Nodes), nodeConfig.Protocol)
+
+	// Stage 3: scratch database with the full schema
+	db, cleanup, err := database.NewScratch(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		run.fail("database", "%v", err)
+		return
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to clean up scratch database: %v\n", err)
+		}
+	}()
+	run.pass("database", "%s scratch database migrated", db.DriverName())
+
+	// Stage 4: protocol metrics from the real node
+	protocolModule, err := protocolRegistry.Get(nodeConfig.Protocol)
+	if err != nil {
+		run.fail("metrics", "%v", err)
+		return
+	}
+	metricsCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	metrics, err := protocolModule.CollectMetrics(metricsCtx, nodeConfig)
+	cancel()
+	if err != nil {
+		run.fail("metrics", "%v", err)
+		return
+	}
+	var collected, missing []string
+	for key, value := range metrics {
+		if key == protocol.MetricErrorsKey {
+			continue
+		}
+		if value == nil {
+			missing = append(missing, missingMetric(metrics, key))
+		} else {
+			collected = append(collected, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	sort.Strings(collected)
+	sort.Strings(missing)
+	if len(collected) == 0 {
+		run.fail("metrics", "no metrics collected from %s (missing: %s)", nodeConfig.URL, strings.Join(missing, ", "))
+		return
+	}
+	if len(missing) > 0 {
+		run.pass("metrics", "%s (missing: %s)", strings.Join(collected, ", "), strings.Join(missing, ", "))
+	} else {
+		run.pass("metrics", "%s", strings.Join(collected, ", "))
+	}
+
+	metrics = protocol.WithMetadata(metrics, nodeConfig)
+
+	// Stage 5: upload initiation against the simulated bv backend
+	simulated := executor.NewSimulatedExecutor(log.Logger, 100, 3)
+	uploadMgr := newUploadManager(simulated, db, cfg, log.Logger)
+
+	uploadID, err := uploadMgr.InitiateUploadWithProtocolData(ctx, nodeName, operatorTrigger("smoke", ""), nodeConfig.Protocol, nodeConfig.Type, metrics)
+	if err != nil {
+		run.fail("upload", "%v", err)
+		return
+	}
+	run.pass("upload", "simulated upload %d initiated and recorded", uploadID)
+
+	// Stage 6: status parsing and progress/completion tracking
+	if _, err := uploadMgr.MonitorUpload(ctx, uploadID, nodeName); err != nil {
+		run.fail("parsing", "%v", err)
+		return
+	}
+	running, err := db.GetRunningUploadForNode(ctx, nodeName)
+	if err != nil || running == nil || running.ChunksCompleted == nil || running.ChunksTotal == nil {
+		run.fail("parsing", "progress was not parsed into the upload record (err: %v)", err)
+		return
+	}
+	progressDetail := fmt.Sprintf("progress %d/%d chunks", *running.ChunksCompleted, *running.ChunksTotal)
+
+	var result upload.CompletionResult
+	for i := 0; i < 10 && !result.Done(); i++ {
+		result, err = uploadMgr.MonitorUpload(ctx, uploadID, nodeName)
+		if err != nil {
+			run.fail("parsing", "%v", err)
+			return
+		}
+	}
+	if result.Outcome != upload.OutcomeSuccess {
+		run.fail("parsing", "%s, but the upload finished with outcome %q", progressDetail, result.Outcome)
+		return
+	}
+	latest, err := db.GetLatestCompletedUploadForNode(ctx, nodeName)
+	if err != nil || latest == nil || latest.ID != uploadID {
+		run.fail("parsing", "%s, but completion was not recorded (err: %v)", progressDetail, err)
+		return
+	}
+	run.pass("parsing", "%s, completion recorded", progressDetail)
+
+	// Stage 7: notification routing (and delivery when requested)
+	notifyConfig := cfg.GetNodeNotifications(nodeName)
+	if notifyConfig == nil || len(notifyConfig.Types) == 0 {
+		run.pass("notifications", "none configured")
+		return
+	}
+	var delivered []string
+	for _, notificationType := range notifyConfig.GetNotificationTypes() {
+		notifyModule, err := notificationRegistry.Get(notificationType)
+		if err != nil {
+			run.fail("notifications", "%v", err)
+			return
+		}
+		if !sendNotifications {
+			delivered = append(delivered, notificationType)
+			continue
+		}
+		payload := notification.NotificationPayload{
+			Event:     notification.EventComplete,
+			NodeName:  nodeName,
+			Timestamp: time.Now(),
+			Message:   "Smoke test notification",
+			Details: map[string]interface{}{
+				"upload_id":    uploadID,
+				"trigger_type": "manual",
+			},
+			Metadata: nodeConfig.Metadata,
+		}
+		// The configured templates are rendered, so the test shows how notifications look
+		tmpl := notifyConfig.GetTemplate(notificationType, string(payload.Event))
+		payload, err = notification.Render(payload, notification.Template{Title: tmpl.Title, Body: tmpl.Body})
+		if err != nil {
+			run.fail("notifications", "%s: %v", notificationType, err)
+			return
+		}
+		if err := notifyModule.Send(ctx, notifyConfig.GetNotificationURL(notificationType), payload); err != nil {
+			run.fail("notifications", "%s: %v", notificationType, err)
+			return
+		}
+		delivered = append(delivered, notificationType)
+	}
+	sort.Strings(delivered)
+	if sendNotifications {
+		run.pass("notifications", "test notification delivered to %s", strings.Join(delivered, ", "))
+	} else {
+		run.pass("notifications", "%s configured (use --notify to send a test message)", strings.Join(delivered, ", "))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// printSnoozes prints the nodes whose notifications are snoozed, with who snoozed them.
+// Only the longest snooze of each node is shown.
+func printSnoozes(snoozes []database.NotificationSnooze) {
+	if len(snoozes) == 0 {
+		return
+	}
+
+	fmt.Printf("\nSnoozed notifications:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	shown := make(map[string]bool)
+	for _, s := range snoozes {
+		if shown[s.NodeName] {
+			continue
+		}
+		shown[s.NodeName] = true
+		fmt.Fprintf(w, "  %s\tuntil %s\tby %s\n", s.NodeName, s.SnoozedUntil.Local().Format(time.RFC3339), s.Actor)
+	}
+	w.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/summary"
+)
+
+// handleStatsCommand handles 'snapperd stats <node>', printing a node's upload durations,
+// chunk counts and failure rate over its last uploads, and whether its latest upload
+// regressed against its recent average
+func handleStatsCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	last := fs.Int("last", summary.DefaultStatsLast, "Number of most recent finished uploads to cover (0 = all)")
+	asJSON := fs.Bool("json", false, "Print the stats as JSON, as served at "+summary.StatsPath+"<node>")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: snapperd stats [--last <n>] [--json] <node>\n")
+		return 1
+	}
+	if *last < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --last must not be negative\n")
+		return 1
+	}
+	nodeName := fs.Arg(0)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	stats, err := summary.NewBuilder(db, cfg, daemonHost()).NodeStats(ctx, nodeName, *last)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	printNodeStats(stats)
+	return 0
+}
+
+// printNodeStats prints a node's stats as text
+func printNodeStats(s *summary.NodeStats) {
+	scope := fmt.Sprintf("last %d finished uploads", s.Last)
+	if s.Last == 0 {
+		scope = "all finished uploads"
+	}
+	fmt.Printf("Node: %s (%s)\n", s.Node, scope)
+	if s.Uploads == 0 {
+		fmt.Printf("No finished uploads\n")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  Uploads:\t%d (%d completed, %d failed, %d other)\n", s.Uploads, s.Completed, s.Failed, s.Uploads-s.Completed-s.Failed)
+	if s.FailureRate != nil {
+		fmt.Fprintf(w, "  Failure rate:\t%.1f%%\n", *s.FailureRate*100)
+	}
+	fmt.Fprintf(w, "  Duration:\tavg %s, min %s, max %s\n", formatSeconds(s.AvgDurationSeconds), formatSeconds(s.MinDurationSeconds), formatSeconds(s.MaxDurationSeconds))
+	if s.AvgChunks != nil {
+		fmt.Fprintf(w, "  Chunks:\tavg %.0f\n", *s.AvgChunks)
+	}
+	w.Flush()
+
+	t := s.Trend
+	if t == nil {
+		return
+	}
+	fmt.Printf("\nLatest completed upload %d took %s\n", t.UploadID, formatSeconds(&t.DurationSeconds))
+	window := time.Duration(t.BaselineWindowSeconds) * time.Second
+	if t.BaselineAvgDurationSeconds == nil {
+		fmt.Printf("No completed uploads in the %.0f days before it to compare with\n", window.Hours()/24)
+		return
+	}
+	fmt.Printf("%.0f-day average before it: %s over %d uploads (%.2fx)\n", window.Hours()/24, formatSeconds(t.BaselineAvgDurationSeconds), t.BaselineUploads, *t.Factor)
+	switch {
+	case t.Regressed:
+		fmt.Printf("REGRESSION: at least %gx slower than the average\n", analytics.DefaultRegressionFactor)
+	case t.BaselineUploads < analytics.MinBaselineUploads:
+		fmt.Printf("Too few uploads to detect regressions (need %d)\n", analytics.MinBaselineUploads)
+	}
+}
+
+// formatSeconds renders a duration in seconds rounded to the second, or "-" when unknown
+func formatSeconds(seconds *float64) string {
+	if seconds == nil {
+		return "-"
+	}
+	return time.Duration(*seconds * float64(time.Second)).Round(time.Second).String()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/summary"
+)
+
+// handleSummaryCommand handles 'snapperd summary', printing the document served by the
+// summary endpoint: node freshness, running uploads, recent failures and scheduler health
+func handleSummaryCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the summary as JSON, as served at "+summary.Path)
+	tenant := fs.String("tenant", "", "Only cover this tenant's nodes and uploads")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: snapd summary [--json] [--tenant <tenant>]\n")
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if _, err := tenantConfig(cfg, *tenant); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, newDatabaseConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	s, err := summary.NewBuilder(db, cfg, daemonHost()).BuildTenant(ctx, *tenant)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(s); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	printSummary(s)
+	return 0
+}
+
+// printSummary prints the summary as text
+func printSummary(s *summary.Summary) {
+	daemon := "stopped"
+	if s.Scheduler.DaemonRunning {
+		daemon = "running"
+	}
+	health := "healthy"
+	if !s.Scheduler.Healthy {
+		health = "unhealthy"
+	}
+	fmt.Printf("Scheduler: %s (daemon on %s %s", health, s.Scheduler.Host, daemon)
+	if s.Scheduler.HeartbeatAt != nil {
+		fmt.Printf(", last heartbeat %s", s.Scheduler.HeartbeatAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf(")\n")
+	fmt.Printf("Overdue nodes: %d, queued requests: %d\n", s.Scheduler.OverdueNodes, s.Scheduler.QueuedRequests)
+
+	fmt.Printf("\nNodes: %d\n", len(s.Nodes))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, n := range s.Nodes {
+		age := "none completed"
+		if n.AgeSeconds != nil {
+			age = fmt.Sprintf("%s ago", (time.Duration(*n.AgeSeconds) * time.Second).Round(time.Minute))
+		}
+		flags := ""
+		if n.Stale {
+			flags += " STALE"
+		}
+		if n.Overdue {
+			flags += " OVERDUE"
+		}
+		if n.RunningUploadID != nil {
+			flags += fmt.Sprintf(" uploading (%d)", *n.RunningUploadID)
+		}
+		slo := ""
+		if n.SLO != nil {
+			slo = fmt.Sprintf("SLO %.1f%% of %g%% (%d uploads)", n.SLO.CompliancePercent, n.SLO.TargetPercent, n.SLO.Uploads)
+			if n.SLO.AtRisk {
+				flags += " SLO AT RISK"
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", n.Name, n.Protocol, age, slo, flags)
+	}
+	w.Flush()
+
+	if len(s.RunningUploads) > 0 {
+		fmt.Printf("\nRunning uploads: %d\n", len(s.RunningUploads))
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, u := range s.RunningUploads {
+			progress := "-"
+			if u.ProgressPercent != nil {
+				progress = fmt.Sprintf("%.1f%%", *u.ProgressPercent)
+			}
+			detail := ""
+			if u.Stalled {
+				detail = "STALLED"
+			} else if u.EstimatedCompletion != nil {
+				detail = fmt.Sprintf("ETA %s", u.EstimatedCompletion.Local().Format("2006-01-02 15:04:05"))
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%s\n", u.ID, u.Node, progress, detail)
+		}
+		w.Flush()
+	}
+
+	fmt.Printf("\nFailures in the last %.0fh: %d\n", summary.FailureWindow.Hours(), len(s.RecentFailures))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range s.RecentFailures {
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\n", f.ID, f.Node, f.StartedAt.Local().Format("2006-01-02 15:04:05"), f.Error)
+	}
+	w.Flush()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// tenantConfig returns a copy of cfg holding only the tenant's nodes, for commands
+// filtered with --tenant. An empty tenant returns cfg itself.
+func tenantConfig(cfg *config.Config, tenant string) (*config.Config, error) {
+	if tenant == "" {
+		return cfg, nil
+	}
+	if _, exists := cfg.Tenants[tenant]; !exists {
+		return nil, fmt.Errorf("tenant %s is not configured", tenant)
+	}
+
+	scoped := *cfg
+	scoped.Nodes = make(map[string]config.NodeConfig)
+	for nodeName, nodeConfig := range cfg.Nodes {
+		if nodeConfig.Tenant == tenant {
+			scoped.Nodes[nodeName] = nodeConfig
+		}
+	}
+	return &scoped, nil
+}
+
+// tenantEntries returns the entries of the nodes in cfg when a tenant is set, and every
+// entry otherwise
+func tenantEntries[T any](entries []T, cfg *config.Config, tenant string, nodeName func(T) string) []T {
+	if tenant == "" {
+		return entries
+	}
+	var kept []T
+	for _, entry := range entries {
+		if _, exists := cfg.Nodes[nodeName(entry)]; exists {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// tenantNodeNames returns the names of the tenant's nodes, from the configuration file
+// and registered in the database
+func tenantNodeNames(ctx context.Context, db *database.DB, cfg *config.Config, tenant string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for nodeName, nodeConfig := range cfg.Nodes {
+		if nodeConfig.Tenant == tenant {
+			names[nodeName] = true
+		}
+	}
+
+	registered, err := db.ListRegisteredNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range registered {
+		if nodeConfig, err := config.ParseNodeConfig([]byte(node.Config)); err == nil && nodeConfig.Tenant == tenant {
+			names[node.NodeName] = true
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/upload"
+)
+
+const (
+	// uploadRequestSchedule is how often the daemon records its heartbeat and picks up
+	// upload requests queued by the CLI
+	uploadRequestSchedule = "*/10 * * * * *"
+
+	// daemonHeartbeatTimeout is how old the daemon's heartbeat may be before the CLI
+	// considers the daemon stopped and runs uploads itself
+	daemonHeartbeatTimeout = time.Minute
+
+	// uploadRequestPollInterval is how often the CLI checks whether the daemon has
+	// processed its upload request
+	uploadRequestPollInterval = 2 * time.Second
+)
+
+// daemonHost identifies this host's daemon in the daemon_heartbeats table
+func daemonHost() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "localhost"
+	}
+	return host
+}
+
+// daemonRunning reports whether a daemon on this host has recorded a recent heartbeat
+func daemonRunning(ctx context.Context, db *database.DB, now time.Time) (bool, error) {
+	heartbeat, err := db.GetDaemonHeartbeat(ctx, daemonHost())
+	if err != nil {
+		return false, err
+	}
+	return heartbeat != nil && now.Sub(heartbeat.HeartbeatAt) < daemonHeartbeatTimeout, nil
+}
+
+// requestDaemonUpload queues an upload request for the running daemon and reports its
+// outcome, so the CLI does not run bv or create upload records alongside the daemon.
+// With wait, it then follows the upload record until the daemon's monitor records the
+// upload as finished.
+func requestDaemonUpload(ctx context.Context, db *database.DB, nodeName string, priority int, trigger upload.Trigger, wait bool, interval time.Duration) int {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	requestID, err := db.CreateUploadRequest(ctx, nodeName, string(trigger.Type), priority, database.JSONB(trigger.Metadata))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	recordAudit(ctx, db, database.AuditEntry{
+		Action:   database.AuditUploadRequested,
+		NodeName: &nodeName,
+		Message:  "queued for the daemon",
+		Details:  database.JSONB{"request_id": requestID},
+	})
+	fmt.Printf("Daemon is running, upload for node '%s' queued (request ID: %d)\n", nodeName, requestID)
+
+	request, err := waitForUploadRequest(ctx, db, requestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	coalesced := false
+	switch request.Status {
+	case database.UploadRequestInitiated:
+	case database.UploadRequestCoalesced:
+		coalesced = true
+	case database.UploadRequestSkipped:
+		fmt.Fprintf(os.Stderr, "Error: upload already running for node '%s'\n", nodeName)
+		return 1
+	default:
+		message := "unknown error"
+		if request.ErrorMessage != nil {
+			message = *request.ErrorMessage
+		}
+		fmt.Fprintf(os.Stderr, "Error: daemon failed to start upload: %s\n", message)
+		return 1
+	}
+
+	uploadID := *request.UploadID
+	if coalesced {
+		fmt.Printf("Upload for node '%s' was just started; request joined upload %d\n", nodeName, uploadID)
+	} else {
+		fmt.Printf("Upload initiated successfully (ID: %d)\n", uploadID)
+	}
+	if !wait {
+		return 0
+	}
+
+	fmt.Println("Waiting for upload to finish...")
+	result, err := waitForUploadRecord(ctx, db, uploadID, interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if result.Message != nil {
+		fmt.Printf("Upload %s: %s\n", result.Outcome, *result.Message)
+	} else {
+		fmt.Printf("Upload %s\n", result.Outcome)
+	}
+
+	return completionExitCode(result)
+}
+
+// waitForUploadRequest polls an upload request until the daemon has processed it. A
+// pending request may wait in the queue for the concurrency limit, so it is only given
+// up on once the daemon's heartbeat has gone stale.
+func waitForUploadRequest(ctx context.Context, db *database.DB, requestID int64) (*database.UploadRequest, error) {
+	ticker := time.NewTicker(uploadRequestPollInterval)
+	defer ticker.Stop()
+
+	reportedQueued := false
+	for {
+		request, err := db.GetUploadRequest(ctx, requestID)
+		if err != nil {
+			return nil, err
+		}
+		if request == nil {
+			return nil, fmt.Errorf("upload request %d not found", requestID)
+		}
+
+		switch request.Status {
+		case database.UploadRequestPending:
+			running, err := daemonRunning(ctx, db, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			if !running {
+				return nil, fmt.Errorf("daemon stopped before picking up upload request %d (use --local to run the upload here)", requestID)
+			}
+			if !reportedQueued && time.Since(request.RequestedAt) > daemonHeartbeatTimeout {
+				fmt.Println("Request is waiting in the upload queue (see 'snapperd queue list')")
+				reportedQueued = true
+			}
+		case database.UploadRequestProcessing:
+			// The daemon may be waiting for finality before starting the upload
+		default:
+			return request, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stopped waiting for upload request %d: %w", requestID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForUploadRecord polls an upload record until the daemon's monitor records it as finished
+func waitForUploadRecord(ctx context.Context, db *database.DB, uploadID int64, interval time.Duration) (upload.CompletionResult, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		record, err := db.GetUpload(ctx, uploadID)
+		if err != nil {
+			return upload.CompletionResult{}, err
+		}
+		if record == nil {
+			return upload.CompletionResult{}, fmt.Errorf("upload %d not found", uploadID)
+		}
+		if result := recordCompletion(*record); result.Done() {
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return upload.CompletionResult{}, fmt.Errorf("stopped waiting for upload %d: %w", uploadID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordCompletion maps an upload record's status to a monitor check result
+func recordCompletion(record database.Upload) upload.CompletionResult {
+	result := upload.CompletionResult{Message: record.CompletionMessage}
+	if result.Message == nil {
+		result.Message = record.ErrorMessage
+	}
+
+	switch record.Status {
+	case "completed":
+		result.Outcome = upload.OutcomeSuccess
+	case "failed":
+		result.Outcome = upload.OutcomeFailure
+	case "cancelled":
+		result.Outcome = upload.OutcomeCancelled
+	default:
+		result.Outcome = upload.OutcomeRunning
+	}
+	return result
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// validateReport collects the checks of 'snapperd validate': those of the whole
+// configuration, then each configured node's
+type validateReport struct {
+	global    smokeRun
+	nodeNames []string
+	nodes     map[string]*smokeRun
+}
+
+// counts returns how many checks passed, and how many ran
+func (r *validateReport) counts() (passed, total int) {
+	runs := []*smokeRun{&r.global}
+	for _, nodeName := range r.nodeNames {
+		runs = append(runs, r.nodes[nodeName])
+	}
+	for _, run := range runs {
+		for _, check := range run.checks {
+			total++
+			if check.passed {
+				passed++
+			}
+		}
+	}
+	return passed, total
+}
+
+// handleValidateCommand handles 'snapperd validate', checking the configuration the daemon
+// would start with and printing a pass/fail report with each node's details. It exits 1
+// when any check fails, for use in CI.
+func handleValidateCommand(configPath string, args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", configPath, "Path to configuration file")
+	connect := fs.Bool("connect", false, "Also connect to the database and query each node's RPC endpoint")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of each connection check")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Error: validate takes no arguments\n")
+		fmt.Fprintf(os.Stderr, "Usage: snapperd validate [--config <path>] [--connect] [--timeout <duration>]\n")
+		return 1
+	}
+	if *timeout <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --timeout must be positive\n")
+		return 1
+	}
+
+	report := runValidation(context.Background(), configPath, *connect, *timeout)
+
+	fmt.Printf("Configuration %s\n", configPath)
+	printValidateChecks(report.global.checks, "  ")
+	if len(report.nodeNames) > 0 {
+		fmt.Printf("\nNodes:\n")
+		for _, nodeName := range report.nodeNames {
+			fmt.Printf("  %s\n", nodeName)
+			printValidateChecks(report.nodes[nodeName].checks, "    ")
+		}
+	}
+	fmt.Println()
+
+	passed, total := report.counts()
+	if passed != total {
+		fmt.Printf("Result: FAIL (%d/%d checks passed)\n", passed, total)
+		return 1
+	}
+
+	fmt.Printf("Result: PASS (%d/%d checks passed)\n", passed, total)
+	return 0
+}
+
+// printValidateChecks prints checks, one per line
+func printValidateChecks(checks []smokeCheck, indent string) {
+	for _, check := range checks {
+		result := "FAIL"
+		if check.passed {
+			result = "PASS"
+		}
+		fmt.Printf("%s[%s] %-14s %s\n", indent, result, check.name, check.detail)
+	}
+}
+
+// runValidation runs the checks of the whole configuration and each of its nodes. Unlike
+// startup, it does not stop at the first problem: every check runs, except those that
+// need a configuration that could not be read.
+func runValidation(ctx context.Context, configPath string, connect bool, timeout time.Duration) *validateReport {
+	report := &validateReport{nodes: make(map[string]*smokeRun)}
+	run := &report.global
+
+	// Modules are registered as the daemon registers them. A failed registration leaves
+	// the modules registered before it, which the rest of the checks use.
+	protocolRegistry, protocolErr := newProtocolRegistry()
+	notificationRegistry, notificationErr := newNotificationRegistry(nil)
+	switch {
+	case protocolErr != nil:
+		run.fail("modules", "%v", protocolErr)
+	case notificationErr != nil:
+		run.fail("modules", "%v", notificationErr)
+	default:
+		run.pass("modules", "%d protocol names, %d notification types registered", len(protocolRegistry.List()), len(notificationRegistry.List()))
+	}
+
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		run.fail("config", "%v", err)
+		return report
+	}
+	if err := cfg.Validate(); err != nil {
+		run.fail("config", "%v", err)
+	} else {
+		run.pass("config", "%d nodes", len(cfg.Nodes))
+	}
+
+	if err := checkNodeProtocols(cfg, protocolRegistry); err != nil {
+		run.fail("protocols", "%v", err)
+	} else {
+		run.pass("protocols", "every node's protocol is registered")
+	}
+
+	if err := checkNotificationTypes(cfg, notificationRegistry); err != nil {
+		run.fail("notifications", "%v", err)
+	} else if err := checkNotificationURLs(cfg.Notifications); err != nil {
+		run.fail("notifications", "%v", err)
+	} else {
+		run.pass("notifications", "%d global notification types", notificationTypeCount(cfg.Notifications))
+	}
+
+	if err := checkJobSchedules(cfg); err != nil {
+		run.fail("schedules", "%v", err)
+	} else {
+		run.pass("schedules", "job and consistency group schedules parse")
+	}
+
+	if connect {
+		dbCtx, cancel := context.WithTimeout(ctx, timeout)
+		db, err := database.New(dbCtx, newDatabaseConfig(cfg))
+		cancel()
+		if err != nil {
+			run.fail("database", "%v", err)
+		} else {
+			run.pass("database", "connected (%s)", db.DriverName())
+			db.Close()
+		}
+	}
+
+	for nodeName := range cfg.Nodes {
+		report.nodeNames = append(report.nodeNames, nodeName)
+	}
+	sort.Strings(report.nodeNames)
+	for _, nodeName := range report.nodeNames {
+		report.nodes[nodeName] = validateNode(ctx, cfg, nodeName, protocolRegistry, notificationRegistry, connect, timeout)
+	}
+
+	return report
+}
+
+// validateNode runs the checks of one configured node. With connect, the node's RPC
+// endpoint is queried through its protocol module.
+func validateNode(ctx context.Context, cfg *config.Config, nodeName string, protocolRegistry *protocol.Registry, notificationRegistry *notification.Registry, connect bool, timeout time.Duration) *smokeRun {
+	run := &smokeRun{}
+	nodeConfig := cfg.Nodes[nodeName]
+
+	if err := nodeConfig.Validate(); err != nil {
+		run.fail("config", "%v", err)
+	} else if !nodeConfig.IsEnabled() {
+		run.pass("config", "valid (disabled: scheduled uploads are skipped)")
+	} else {
+		run.pass("config", "valid")
+	}
+
+	module, err := protocolRegistry.Get(nodeConfig.Protocol)
+	if err != nil {
+		run.fail("protocol", "no protocol module registered for '%s'", nodeConfig.Protocol)
+	} else {
+		run.pass("protocol", "%s", module.Name())
+	}
+
+	schedule := cfg.GetNodeSchedule(nodeName)
+	if parsed, err := scheduler.ParseSchedule(schedule, scheduler.SplayOffset(nodeName, cfg.GetNodeSplay(nodeName))); err != nil {
+		run.fail("schedule", "invalid schedule '%s': %v", schedule, err)
+	} else {
+		run.pass("schedule", "%s, next run %s", schedule, formatRunTime(parsed.Next(time.Now()), cfg.GetNodeTimezone(nodeName)))
+	}
+
+	urlErr := checkNodeURL(nodeConfig.URL)
+	if urlErr != nil {
+		run.fail("url", "%v", urlErr)
+	} else {
+		run.pass("url", "%s", nodeConfig.URL)
+	}
+
+	notifyConfig := cfg.GetNodeNotifications(nodeName)
+	if err := checkNotificationRegistrations(notifyConfig, notificationRegistry); err != nil {
+		run.fail("notifications", "%v", err)
+	} else if err := checkNotificationURLs(notifyConfig); err != nil {
+		run.fail("notifications", "%v", err)
+	} else if nodeConfig.Notifications != nil {
+		run.pass("notifications", "%d node notification types", notificationTypeCount(notifyConfig))
+	} else {
+		run.pass("notifications", "%d global notification types", notificationTypeCount(notifyConfig))
+	}
+
+	if connect {
+		switch {
+		case module == nil:
+			run.fail("rpc", "skipped: no protocol module")
+		case urlErr != nil:
+			run.fail("rpc", "skipped: invalid url")
+		default:
+			rpcCtx, cancel := context.WithTimeout(ctx, timeout)
+			metrics, err := module.CollectMetrics(rpcCtx, nodeConfig)
+			cancel()
+			// Modules record a metric whose query failed as nil rather than failing, with
+			// its error under metric_errors
+			var missing []string
+			total := 0
+			for name, value := range metrics {
+				if name == protocol.MetricErrorsKey {
+					continue
+				}
+				total++
+				if value == nil {
+					missing = append(missing, missingMetric(metrics, name))
+				}
+			}
+			sort.Strings(missing)
+			switch {
+			case err != nil:
+				run.fail("rpc", "%v", err)
+			case total > 0 && len(missing) == total:
+				run.fail("rpc", "no metric could be queried from %s (%s)", nodeConfig.URL, strings.Join(missing, ", "))
+			case len(missing) > 0:
+				run.pass("rpc", "collected %d of %d metrics (no %s)", total-len(missing), total, strings.Join(missing, ", "))
+			default:
+				run.pass("rpc", "collected %d metrics", total)
+			}
+		}
+	}
+
+	return run
+}
+
+// missingMetric names a metric whose query failed, with its recorded error
+func missingMetric(metrics map[string]interface{}, key string) string {
+	if reason := protocol.MetricError(metrics, key); reason != "" {
+		return fmt.Sprintf("%s: %s", key, reason)
+	}
+	return key
+}
+
+// checkNodeURL returns an error unless a node's RPC endpoint is an absolute http(s) or
+// ws(s) URL
+func checkNodeURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url '%s': %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("invalid url '%s': must be an http, https, ws or wss URL", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid url '%s': missing host", rawURL)
+	}
+	return nil
+}
+
+// checkNotificationRegistrations returns an error naming the notification types of
+// notifyConfig that have no registered module
+func checkNotificationRegistrations(notifyConfig *config.NotificationConfig, registry registrationValidator) error {
+	if notifyConfig == nil {
+		return nil
+	}
+	var missing []string
+	for typeName := range notifyConfig.Types {
+		if !registry.IsRegistered(typeName) {
+			missing = append(missing, typeName)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no notification module registered for types: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkNotificationURLs returns an error naming the notification types of notifyConfig
+// whose URL is not an absolute URL
+func checkNotificationURLs(notifyConfig *config.NotificationConfig) error {
+	if notifyConfig == nil {
+		return nil
+	}
+	var invalid []string
+	for typeName, typeConfig := range notifyConfig.Types {
+		if u, err := url.Parse(typeConfig.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid = append(invalid, typeName)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("invalid notification url for types: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// notificationTypeCount returns how many notification types notifyConfig sends to
+func notificationTypeCount(notifyConfig *config.NotificationConfig) int {
+	if notifyConfig == nil {
+		return 0
+	}
+	return len(notifyConfig.Types)
+}
+
+// checkJobSchedules returns an error naming every daemon job and consistency group
+// schedule that does not parse
+func checkJobSchedules(cfg *config.Config) error {
+	schedules := map[string]string{
+		"schedule":                cfg.Schedule,
+		"blob_retention_schedule": cfg.BlobRetentionSchedule,
+		"freshness_schedule":      cfg.FreshnessSchedule,
+		"verification_schedule":   cfg.VerificationSchedule,
+		"slo_schedule":            cfg.SLOSchedule,
+	}
+	for groupName, group := range cfg.ConsistencyGroups {
+		schedules["consistency group "+groupName] = group.Schedule
+	}
+
+	var invalid []string
+	for name, schedule := range schedules {
+		if _, err := config.ParseSchedule(config.WithTimezone(schedule, cfg.Timezone)); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s '%s' (%v)", name, schedule, err))
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("invalid schedules: %s", strings.Join(invalid, "; "))
+	}
+	return nil
+}
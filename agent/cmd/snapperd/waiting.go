@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// waitingNode explains why a configured node has no running upload
+type waitingNode struct {
+	node   string
+	reason string
+	detail string
+}
+
+// findWaitingNodes explains, for each configured node without a running upload, what the
+// daemon is waiting for, based on the persisted schedule state; active holds the nodes
+// with a running upload
+func findWaitingNodes(ctx context.Context, db *database.DB, cfg *config.Config, active map[string]bool, now time.Time) ([]waitingNode, error) {
+	states, err := db.GetScheduleStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stateByNode := make(map[string]database.ScheduleState, len(states))
+	for _, state := range states {
+		stateByNode[state.NodeName] = state
+	}
+
+	var waiting []waitingNode
+	for nodeName := range cfg.Nodes {
+		if active[nodeName] {
+			continue
+		}
+		state, recorded := stateByNode[nodeName]
+
+		if recorded && state.LastResult != nil && (*state.LastResult == "waiting_finality" || *state.LastResult == "queued") {
+			reason := "waiting for finality"
+			if *state.LastResult == "queued" {
+				reason = "queued (concurrency limit)"
+			}
+			detail := ""
+			if state.LastRunAt != nil {
+				detail = fmt.Sprintf("since %s", formatRunTime(*state.LastRunAt, cfg.GetNodeTimezone(nodeName)))
+			}
+			waiting = append(waiting, waitingNode{node: nodeName, reason: reason, detail: detail})
+			continue
+		}
+
+		entry := waitingNode{node: nodeName, reason: "scheduled"}
+		timezone := cfg.GetNodeTimezone(nodeName)
+		switch {
+		case recorded && state.NextRunAt != nil && state.NextRunAt.After(now):
+			entry.detail = fmt.Sprintf("next run %s", formatRunTime(*state.NextRunAt, timezone))
+		case recorded && state.NextRunAt != nil:
+			// The recorded run is overdue, so the daemon is not running or has not caught up
+			entry.reason = "overdue"
+			entry.detail = fmt.Sprintf("was due %s, is the daemon running?", formatRunTime(*state.NextRunAt, timezone))
+		default:
+			offset := scheduler.SplayOffset(nodeName, cfg.GetNodeSplay(nodeName))
+			if parsed, err := scheduler.ParseSchedule(cfg.GetNodeSchedule(nodeName), offset); err == nil {
+				entry.detail = fmt.Sprintf("next run %s", formatRunTime(parsed.Next(now), timezone))
+			}
+		}
+		if recorded && state.LastResult != nil {
+			switch *state.LastResult {
+			case "failed":
+				entry.detail += " (last run failed)"
+			case "invalid_metrics":
+				entry.detail += " (last run blocked by metric validation)"
+			case "preflight_failed":
+				entry.detail += " (last run failed preflight)"
+			case "paused":
+				entry.detail += " (last run skipped, node paused)"
+			}
+		}
+		waiting = append(waiting, entry)
+	}
+
+	sort.Slice(waiting, func(i, k int) bool { return waiting[i].node < waiting[k].node })
+	return waiting, nil
+}
+
+// printWaitingNodes prints why idle nodes have not started an upload, along with the
+// daemon's concurrency limit (0 means each node's uploads are scheduled independently)
+func printWaitingNodes(nodes []waitingNode, maxConcurrent int) {
+	if len(nodes) == 0 {
+		return
+	}
+
+	if maxConcurrent > 0 {
+		fmt.Printf("\nConcurrency limit: %d (see 'snapperd queue list')\n", maxConcurrent)
+	} else {
+		fmt.Printf("\nConcurrency limit: none (nodes upload independently)\n")
+	}
+	fmt.Printf("Waiting nodes: %d\n", len(nodes))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, n := range nodes {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", n.node, n.reason, n.detail)
+	}
+	w.Flush()
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/events"
+	"github.com/nodexeus/agent/internal/summary"
+)
+
+// watchHistoryWindow bounds how far back progress samples are kept for ETA estimates
+const watchHistoryWindow = 15 * time.Minute
+
+// progressBarWidth is the number of cells in a rendered progress bar
+const progressBarWidth = 30
+
+// progressSample is a chunk count observed at a point in time
+type progressSample struct {
+	at     time.Time
+	chunks int
+}
+
+// progressHistory keeps recent progress samples per upload to estimate completion times
+type progressHistory struct {
+	samples map[int64][]progressSample
+}
+
+// newProgressHistory creates an empty progress history
+func newProgressHistory() *progressHistory {
+	return &progressHistory{samples: make(map[int64][]progressSample)}
+}
+
+// record adds a sample for an upload, dropping samples older than the history window
+func (h *progressHistory) record(uploadID int64, sample progressSample) {
+	samples := h.samples[uploadID]
+	if n := len(samples); n > 0 && samples[n-1].chunks == sample.chunks && samples[n-1].at.Equal(sample.at) {
+		return
+	}
+	samples = append(samples, sample)
+
+	cutoff := sample.at.Add(-watchHistoryWindow)
+	for len(samples) > 2 && samples[0].at.Before(cutoff) {
+		samples = samples[1:]
+	}
+	h.samples[uploadID] = samples
+}
+
+// prune forgets uploads that are no longer running
+func (h *progressHistory) prune(active map[int64]bool) {
+	for uploadID := range h.samples {
+		if !active[uploadID] {
+			delete(h.samples, uploadID)
+		}
+	}
+}
+
+// eta estimates the remaining time for an upload. It prefers the estimate stored by the
+// daemon's monitor, then the chunk rate across the samples recorded by this session,
+// and falls back to the average rate since the upload started.
+func (h *progressHistory) eta(u database.Upload, now time.Time) (time.Duration, bool) {
+	if u.EstimatedCompletion != nil {
+		if remaining := u.EstimatedCompletion.Sub(now); remaining > 0 {
+			return remaining.Round(time.Second), true
+		}
+	}
+	if u.ChunksCompleted == nil || u.ChunksTotal == nil || *u.ChunksTotal <= 0 {
+		return 0, false
+	}
+	remaining := *u.ChunksTotal - *u.ChunksCompleted
+	if remaining <= 0 {
+		return 0, true
+	}
+
+	var rate float64 // chunks per second
+	if samples := h.samples[u.ID]; len(samples) >= 2 {
+		first, last := samples[0], samples[len(samples)-1]
+		if elapsed := last.at.Sub(first.at).Seconds(); elapsed > 0 && last.chunks > first.chunks {
+			rate = float64(last.chunks-first.chunks) / elapsed
+		}
+	}
+	if rate == 0 {
+		if elapsed := now.Sub(u.StartedAt).Seconds(); elapsed > 0 && *u.ChunksCompleted > 0 {
+			rate = float64(*u.ChunksCompleted) / elapsed
+		}
+	}
+	if rate == 0 {
+		return 0, false
+	}
+
+	return time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second), true
+}
+
+// renderProgressBar draws a fixed-width bar for a percentage between 0 and 100
+func renderProgressBar(percent float64) string {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	filled := int(percent / 100 * progressBarWidth)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled) + "]"
+}
+
+// uploadPercent returns the upload's progress, deriving it from chunk counts when needed
+func uploadPercent(u database.Upload) (float64, bool) {
+	if u.ProgressPercent != nil {
+		return *u.ProgressPercent, true
+	}
+	if u.ChunksCompleted != nil && u.ChunksTotal != nil && *u.ChunksTotal > 0 {
+		return float64(*u.ChunksCompleted) / float64(*u.ChunksTotal) * 100, true
+	}
+	return 0, false
+}
+
+// renderWatchFrame formats one refresh of the watch view; live marks a view kept current
+// from the daemon's event stream
+func renderWatchFrame(uploads []database.Upload, history *progressHistory, now time.Time, live bool) string {
+	var b strings.Builder
+	source := ""
+	if live {
+		source = ", live"
+	}
+	fmt.Fprintf(&b, "Active uploads: %d    (updated %s%s, Ctrl+C to exit)\n\n", len(uploads), now.Format("15:04:05"), source)
+
+	if len(uploads) == 0 {
+		b.WriteString("No active uploads\n")
+		return b.String()
+	}
+
+	for _, u := range uploads {
+		fmt.Fprintf(&b, "%s (%s)  upload %d, running %s\n", u.NodeName, u.Protocol, u.ID, now.Sub(u.StartedAt).Round(time.Second))
+
+		percent, ok := uploadPercent(u)
+		if !ok {
+			b.WriteString("  waiting for progress\n\n")
+			continue
+		}
+
+		line := fmt.Sprintf("  %s %5.1f%%", renderProgressBar(percent), percent)
+		if u.ChunksCompleted != nil && u.ChunksTotal != nil {
+			line += fmt.Sprintf("  %d/%d chunks", *u.ChunksCompleted, *u.ChunksTotal)
+		}
+		if u.ChunksPerMinute != nil {
+			line += fmt.Sprintf("  %.1f chunks/min", *u.ChunksPerMinute)
+		}
+		if eta, ok := history.eta(u, now); ok {
+			line += fmt.Sprintf("  ETA %s", eta)
+		} else {
+			line += "  ETA unknown"
+		}
+		b.WriteString(line + "\n")
+		if u.StalledSince != nil {
+			fmt.Fprintf(&b, "  no progress since %s\n", u.StalledSince.Format(time.RFC3339))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// eventStream is the daemon's upload event stream, at the summary endpoint
+type eventStream struct {
+	url   string
+	token string
+}
+
+// newEventStream returns the event stream of the configured summary endpoint, or nil
+// without one. A listen address without a host, or with an unspecified one, is reached
+// on the loopback address.
+func newEventStream(cfg *config.Config) *eventStream {
+	if cfg.SummaryAPI == nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(cfg.SummaryAPI.Listen)
+	if err != nil {
+		return nil
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return &eventStream{
+		url:   "http://" + net.JoinHostPort(host, port) + summary.EventsPath,
+		token: cfg.SummaryAPI.Token,
+	}
+}
+
+// watchStatus redraws the oldest running uploads, up to limit and of the tenant if set,
+// every interval until interrupted. With an event stream the uploads are read once and then kept current from
+// the daemon's events; without one, or once the stream fails, they are read again for
+// every redraw.
+func watchStatus(ctx context.Context, db *database.DB, interval time.Duration, limit int, tenant string, stream *eventStream) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	history := newProgressHistory()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var received <-chan events.Event
+	var streamErr <-chan error
+	if stream != nil {
+		eventCh := make(chan events.Event)
+		errCh := make(chan error, 1)
+		filter := events.Filter{Tenant: tenant}
+		go func() {
+			errCh <- events.Listen(ctx, &http.Client{}, stream.url, stream.token, func(e events.Event) {
+				if !filter.Match(e) {
+					return
+				}
+				select {
+				case eventCh <- e:
+				case <-ctx.Done():
+				}
+			})
+		}()
+		received, streamErr = eventCh, errCh
+	}
+
+	var uploads []database.Upload
+	stale := true // The uploads must be read from the database before the next redraw
+	for {
+		if stale {
+			var err error
+			uploads, err = db.ListRunningUploads(ctx, database.UploadPage{Limit: limit, Tenant: tenant})
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("failed to get running uploads: %w", err)
+			}
+			stale = received == nil
+		}
+		sort.Slice(uploads, func(i, k int) bool { return uploads[i].NodeName < uploads[k].NodeName })
+
+		now := time.Now()
+		active := make(map[int64]bool, len(uploads))
+		for _, u := range uploads {
+			active[u.ID] = true
+			if u.ChunksCompleted == nil {
+				continue
+			}
+			sampledAt := now
+			if u.LastProgressCheck != nil {
+				sampledAt = *u.LastProgressCheck
+			}
+			history.record(u.ID, progressSample{at: sampledAt, chunks: *u.ChunksCompleted})
+		}
+		history.prune(active)
+
+		// Clear the screen and move the cursor home before redrawing
+		fmt.Print("\033[H\033[2J")
+		fmt.Print(renderWatchFrame(uploads, history, now, received != nil))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case e := <-received:
+			var err error
+			uploads, stale, err = applyUploadEvent(ctx, db, uploads, e, limit)
+			if err != nil {
+				return err
+			}
+		case <-streamErr:
+			// Fall back to reading the database every interval
+			received, streamErr, stale = nil, nil, true
+		}
+	}
+}
+
+// applyUploadEvent updates the watched uploads with an event, reading an upload that
+// started from the database. It reports whether the uploads must be read again: when
+// one finishes while the view is full, another may take its place.
+func applyUploadEvent(ctx context.Context, db *database.DB, uploads []database.Upload, e events.Event, limit int) ([]database.Upload, bool, error) {
+	index := slices.IndexFunc(uploads, func(u database.Upload) bool { return u.ID == e.UploadID })
+
+	switch {
+	case e.Finished():
+		if index < 0 {
+			return uploads, false, nil
+		}
+		return slices.Delete(uploads, index, index+1), len(uploads) == limit, nil
+	case index >= 0:
+		u := &uploads[index]
+		if e.ChunksCompleted != nil && (u.ChunksCompleted == nil || *u.ChunksCompleted != *e.ChunksCompleted) {
+			u.StalledSince = nil
+		}
+		if e.ProgressPercent != nil {
+			u.ProgressPercent = e.ProgressPercent
+		}
+		if e.ChunksCompleted != nil {
+			u.ChunksCompleted = e.ChunksCompleted
+			u.LastProgressCheck = &e.Time
+		}
+		if e.ChunksTotal != nil {
+			u.ChunksTotal = e.ChunksTotal
+		}
+		if e.ChunksPerMinute != nil {
+			u.ChunksPerMinute = e.ChunksPerMinute
+			u.EstimatedCompletion = e.EstimatedCompletion
+		}
+		return uploads, false, nil
+	case len(uploads) < limit:
+		u, err := db.GetUpload(ctx, e.UploadID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return uploads, false, nil
+			}
+			return uploads, false, fmt.Errorf("failed to get upload %d: %w", e.UploadID, err)
+		}
+		if u != nil && u.Status == "running" {
+			uploads = append(uploads, *u)
+		}
+		return uploads, false, nil
+	default:
+		return uploads, false, nil
+	}
+}
@@ -0,0 +1,92 @@
+package analytics
+
+import (
+	"math"
+	"time"
+)
+
+// SLO is an upload service level objective: Target percent of uploads complete within
+// MaxDuration and average at least MinBandwidth. A zero MaxDuration or MinBandwidth is
+// not checked.
+type SLO struct {
+	Target       float64 // Percent of uploads that must meet the objective
+	MaxDuration  time.Duration
+	MinBandwidth float64 // Bytes per second
+}
+
+// SLOUpload is a finished upload measured against an SLO
+type SLOUpload struct {
+	Succeeded bool
+	Duration  time.Duration
+	SizeBytes *int64 // Bytes uploaded, for engines that report it
+}
+
+// Bandwidth returns the bytes per second an upload averaged, and false when its size or
+// duration is unknown
+func (u SLOUpload) Bandwidth() (float64, bool) {
+	if u.SizeBytes == nil || u.Duration <= 0 {
+		return 0, false
+	}
+	return float64(*u.SizeBytes) / u.Duration.Seconds(), true
+}
+
+// Met reports whether an upload met the objective. Failed uploads miss it. An upload
+// whose bandwidth is unknown is judged on its duration alone, and is not counted at all
+// when the objective only sets a bandwidth.
+func (s SLO) Met(u SLOUpload) (met, counted bool) {
+	if !u.Succeeded {
+		return false, true
+	}
+	bandwidth, measured := u.Bandwidth()
+	if s.MaxDuration <= 0 && !measured {
+		return false, false
+	}
+	if s.MaxDuration > 0 && u.Duration > s.MaxDuration {
+		return false, true
+	}
+	if s.MinBandwidth > 0 && measured && bandwidth < s.MinBandwidth {
+		return false, true
+	}
+	return true, true
+}
+
+// SLOCompliance is how uploads measure against an SLO
+type SLOCompliance struct {
+	Uploads int     // Uploads counted
+	Met     int     // Uploads that met the objective
+	Percent float64 // Met share of the uploads counted, 100 without any
+	// Budget is how many more uploads may miss the objective before compliance falls
+	// below the target; it is negative once compliance is below the target
+	Budget int
+}
+
+// Evaluate measures uploads against the objective
+func (s SLO) Evaluate(uploads []SLOUpload) SLOCompliance {
+	var c SLOCompliance
+	for _, u := range uploads {
+		met, counted := s.Met(u)
+		if !counted {
+			continue
+		}
+		c.Uploads++
+		if met {
+			c.Met++
+		}
+	}
+
+	c.Percent = 100
+	if c.Uploads > 0 {
+		c.Percent = float64(c.Met) / float64(c.Uploads) * 100
+	}
+	// Met/(Uploads+k) stays at or above the target for k up to Met*100/Target - Uploads.
+	// The tolerance keeps exact ratios such as 19 of 20 at 95% from rounding down.
+	if s.Target > 0 {
+		c.Budget = int(math.Floor(float64(c.Met)*100/s.Target+1e-9)) - c.Uploads
+	}
+	return c
+}
+
+// Breached reports whether compliance is below the target
+func (c SLOCompliance) Breached() bool {
+	return c.Budget < 0
+}
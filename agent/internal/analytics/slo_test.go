@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func TestSLOMet(t *testing.T) {
+	slo := SLO{Target: 95, MaxDuration: 8 * time.Hour, MinBandwidth: 50e6}
+
+	tests := []struct {
+		name        string
+		slo         SLO
+		upload      SLOUpload
+		wantMet     bool
+		wantCounted bool
+	}{
+		{name: "within objective", slo: slo, upload: SLOUpload{Succeeded: true, Duration: 6 * time.Hour, SizeBytes: int64Ptr(2e12)}, wantMet: true, wantCounted: true},
+		{name: "too long", slo: slo, upload: SLOUpload{Succeeded: true, Duration: 9 * time.Hour}, wantMet: false, wantCounted: true},
+		{name: "too slow", slo: slo, upload: SLOUpload{Succeeded: true, Duration: 6 * time.Hour, SizeBytes: int64Ptr(1e12)}, wantMet: false, wantCounted: true},
+		{name: "failed", slo: slo, upload: SLOUpload{Duration: time.Hour}, wantMet: false, wantCounted: true},
+		{name: "size unknown", slo: slo, upload: SLOUpload{Succeeded: true, Duration: 6 * time.Hour}, wantMet: true, wantCounted: true},
+		{name: "bandwidth only, size unknown", slo: SLO{Target: 95, MinBandwidth: 50e6}, upload: SLOUpload{Succeeded: true, Duration: time.Hour}, wantMet: false, wantCounted: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			met, counted := tt.slo.Met(tt.upload)
+			if met != tt.wantMet || counted != tt.wantCounted {
+				t.Errorf("Met() = %v, %v, want %v, %v", met, counted, tt.wantMet, tt.wantCounted)
+			}
+		})
+	}
+}
+
+func TestSLOEvaluate(t *testing.T) {
+	slo := SLO{Target: 95, MaxDuration: 8 * time.Hour}
+	met := SLOUpload{Succeeded: true, Duration: 6 * time.Hour}
+	missed := SLOUpload{Succeeded: true, Duration: 9 * time.Hour}
+
+	repeat := func(u SLOUpload, n int) []SLOUpload {
+		uploads := make([]SLOUpload, n)
+		for i := range uploads {
+			uploads[i] = u
+		}
+		return uploads
+	}
+
+	tests := []struct {
+		name        string
+		uploads     []SLOUpload
+		wantPercent float64
+		wantBudget  int
+	}{
+		{name: "no uploads", wantPercent: 100, wantBudget: 0},
+		// 19 of 20 is exactly 95%, with no miss to spare
+		{name: "at target", uploads: append(repeat(met, 19), missed), wantPercent: 95, wantBudget: 0},
+		{name: "budget left", uploads: repeat(met, 40), wantPercent: 100, wantBudget: 2},
+		{name: "breached", uploads: append(repeat(met, 18), missed, missed), wantPercent: 90, wantBudget: -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := slo.Evaluate(tt.uploads)
+			if c.Percent != tt.wantPercent || c.Budget != tt.wantBudget {
+				t.Errorf("Evaluate() = %.1f%% with budget %d, want %.1f%% with budget %d", c.Percent, c.Budget, tt.wantPercent, tt.wantBudget)
+			}
+			if c.Breached() != (tt.wantBudget < 0) {
+				t.Errorf("Breached() = %v with budget %d", c.Breached(), c.Budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package analytics
+
+import (
+	"time"
+)
+
+// DefaultWindow is how far back samples are considered when estimating throughput
+const DefaultWindow = 30 * time.Minute
+
+// Sample is an upload's chunk progress observed at a point in time
+type Sample struct {
+	RecordedAt      time.Time
+	ChunksCompleted int
+	ChunksTotal     *int
+}
+
+// Estimate is an upload's recent throughput and projected completion time
+type Estimate struct {
+	ChunksPerMinute     float64
+	EstimatedCompletion *time.Time // nil when the upload is not advancing or the total is unknown
+}
+
+// EstimateThroughput computes chunks per minute across the samples within window of
+// the most recent one and projects when the remaining chunks will complete. Samples
+// must be ordered by RecordedAt. It reports false when fewer than two samples span
+// a non-zero interval.
+func EstimateThroughput(samples []Sample, window time.Duration) (Estimate, bool) {
+	if len(samples) < 2 {
+		return Estimate{}, false
+	}
+
+	last := samples[len(samples)-1]
+	first := samples[0]
+	cutoff := last.RecordedAt.Add(-window)
+	for _, sample := range samples[:len(samples)-1] {
+		if !sample.RecordedAt.Before(cutoff) {
+			first = sample
+			break
+		}
+	}
+
+	elapsed := last.RecordedAt.Sub(first.RecordedAt)
+	if elapsed <= 0 {
+		return Estimate{}, false
+	}
+
+	// Chunk counts can reset when bv restarts a job; never report negative throughput
+	advanced := last.ChunksCompleted - first.ChunksCompleted
+	if advanced < 0 {
+		advanced = 0
+	}
+
+	estimate := Estimate{ChunksPerMinute: float64(advanced) / elapsed.Minutes()}
+	if estimate.ChunksPerMinute > 0 && last.ChunksTotal != nil {
+		remaining := *last.ChunksTotal - last.ChunksCompleted
+		if remaining < 0 {
+			remaining = 0
+		}
+		eta := last.RecordedAt.Add(time.Duration(float64(remaining) / estimate.ChunksPerMinute * float64(time.Minute)))
+		estimate.EstimatedCompletion = &eta
+	}
+
+	return estimate, true
+}
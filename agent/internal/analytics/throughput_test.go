@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestEstimateThroughput(t *testing.T) {
+	base := time.Date(2024, 12, 9, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		samples     []Sample
+		window      time.Duration
+		wantOK      bool
+		wantRate    float64
+		wantETA     *time.Time
+		wantETANone bool
+	}{
+		{
+			name:    "single sample",
+			samples: []Sample{{RecordedAt: base, ChunksCompleted: 10, ChunksTotal: intPtr(100)}},
+			window:  DefaultWindow,
+			wantOK:  false,
+		},
+		{
+			name: "steady progress",
+			samples: []Sample{
+				{RecordedAt: base, ChunksCompleted: 10, ChunksTotal: intPtr(100)},
+				{RecordedAt: base.Add(5 * time.Minute), ChunksCompleted: 20, ChunksTotal: intPtr(100)},
+				{RecordedAt: base.Add(10 * time.Minute), ChunksCompleted: 30, ChunksTotal: intPtr(100)},
+			},
+			window:   DefaultWindow,
+			wantOK:   true,
+			wantRate: 2,
+			wantETA:  timePtr(base.Add(45 * time.Minute)),
+		},
+		{
+			name: "window excludes old samples",
+			samples: []Sample{
+				{RecordedAt: base, ChunksCompleted: 0, ChunksTotal: intPtr(100)},
+				{RecordedAt: base.Add(50 * time.Minute), ChunksCompleted: 10, ChunksTotal: intPtr(100)},
+				{RecordedAt: base.Add(60 * time.Minute), ChunksCompleted: 50, ChunksTotal: intPtr(100)},
+			},
+			window:   15 * time.Minute,
+			wantOK:   true,
+			wantRate: 4,
+			wantETA:  timePtr(base.Add(60*time.Minute + 12*time.Minute + 30*time.Second)),
+		},
+		{
+			name: "no progress",
+			samples: []Sample{
+				{RecordedAt: base, ChunksCompleted: 30, ChunksTotal: intPtr(100)},
+				{RecordedAt: base.Add(5 * time.Minute), ChunksCompleted: 30, ChunksTotal: intPtr(100)},
+			},
+			window:      DefaultWindow,
+			wantOK:      true,
+			wantRate:    0,
+			wantETANone: true,
+		},
+		{
+			name: "unknown total",
+			samples: []Sample{
+				{RecordedAt: base, ChunksCompleted: 10},
+				{RecordedAt: base.Add(10 * time.Minute), ChunksCompleted: 20},
+			},
+			window:      DefaultWindow,
+			wantOK:      true,
+			wantRate:    1,
+			wantETANone: true,
+		},
+		{
+			name: "chunk count reset",
+			samples: []Sample{
+				{RecordedAt: base, ChunksCompleted: 50, ChunksTotal: intPtr(100)},
+				{RecordedAt: base.Add(5 * time.Minute), ChunksCompleted: 5, ChunksTotal: intPtr(100)},
+			},
+			window:      DefaultWindow,
+			wantOK:      true,
+			wantRate:    0,
+			wantETANone: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, ok := EstimateThroughput(tt.samples, tt.window)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if estimate.ChunksPerMinute != tt.wantRate {
+				t.Errorf("expected %.2f chunks/min, got %.2f", tt.wantRate, estimate.ChunksPerMinute)
+			}
+			if tt.wantETANone && estimate.EstimatedCompletion != nil {
+				t.Errorf("expected no ETA, got %v", estimate.EstimatedCompletion)
+			}
+			if tt.wantETA != nil && (estimate.EstimatedCompletion == nil || !estimate.EstimatedCompletion.Equal(*tt.wantETA)) {
+				t.Errorf("expected ETA %v, got %v", *tt.wantETA, estimate.EstimatedCompletion)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,50 @@
+package analytics
+
+import (
+	"time"
+)
+
+const (
+	// DefaultRegressionFactor is how many times slower than its baseline an upload must be
+	// to count as a regression
+	DefaultRegressionFactor = 2.0
+
+	// DefaultBaselineWindow is how far back the uploads an upload is compared with reach
+	DefaultBaselineWindow = 30 * 24 * time.Hour
+
+	// MinBaselineUploads is how many uploads a baseline needs before regressions are
+	// reported against it
+	MinBaselineUploads = 3
+)
+
+// Baseline is the average duration of a node's completed uploads over a window
+type Baseline struct {
+	Uploads     int
+	AvgDuration time.Duration
+}
+
+// Regression is an upload that took Factor times its baseline's average duration
+type Regression struct {
+	Duration    time.Duration
+	AvgDuration time.Duration
+	Factor      float64
+}
+
+// DetectRegression compares an upload's duration with its baseline and reports a
+// regression when it is at least threshold times the average. It reports false when the
+// baseline has fewer than MinBaselineUploads uploads. A threshold of 0 uses
+// DefaultRegressionFactor.
+func DetectRegression(duration time.Duration, baseline Baseline, threshold float64) (Regression, bool) {
+	if threshold <= 0 {
+		threshold = DefaultRegressionFactor
+	}
+	if baseline.Uploads < MinBaselineUploads || baseline.AvgDuration <= 0 {
+		return Regression{}, false
+	}
+
+	factor := duration.Seconds() / baseline.AvgDuration.Seconds()
+	if factor < threshold {
+		return Regression{}, false
+	}
+	return Regression{Duration: duration, AvgDuration: baseline.AvgDuration, Factor: factor}, true
+}
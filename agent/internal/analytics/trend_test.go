@@ -0,0 +1,37 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectRegression(t *testing.T) {
+	baseline := Baseline{Uploads: 10, AvgDuration: 2 * time.Hour}
+
+	tests := []struct {
+		name       string
+		duration   time.Duration
+		baseline   Baseline
+		threshold  float64
+		wantFound  bool
+		wantFactor float64
+	}{
+		{name: "twice the average", duration: 4 * time.Hour, baseline: baseline, wantFound: true, wantFactor: 2},
+		{name: "slower within threshold", duration: 3 * time.Hour, baseline: baseline, wantFound: false},
+		{name: "faster", duration: time.Hour, baseline: baseline, wantFound: false},
+		{name: "custom threshold", duration: 3 * time.Hour, baseline: baseline, threshold: 1.5, wantFound: true, wantFactor: 1.5},
+		{name: "too few baseline uploads", duration: 10 * time.Hour, baseline: Baseline{Uploads: 2, AvgDuration: time.Hour}, wantFound: false},
+		{name: "no baseline duration", duration: time.Hour, baseline: Baseline{Uploads: 5}, wantFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regression, found := DetectRegression(tt.duration, tt.baseline, tt.threshold)
+			if found != tt.wantFound {
+				t.Fatalf("DetectRegression() found = %v, want %v", found, tt.wantFound)
+			}
+			if found && (regression.Factor != tt.wantFactor || regression.Duration != tt.duration || regression.AvgDuration != tt.baseline.AvgDuration) {
+				t.Errorf("DetectRegression() = %+v, want factor %v", regression, tt.wantFactor)
+			}
+		})
+	}
+}
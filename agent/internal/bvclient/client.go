@@ -0,0 +1,202 @@
+package bvclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
+)
+
+// versionTTL is how long a detected bv version is trusted before it is checked again, so
+// a bv upgrade is noticed without restarting the daemon
+const versionTTL = time.Hour
+
+// flagRejectedPatterns match the errors bv prints for a flag it does not know, in the
+// wordings of the argument parser across bv versions
+var flagRejectedPatterns = []string{
+	"unexpected argument",
+	"wasn't expected",
+	"unrecognized",
+	"unknown flag",
+	"unknown option",
+}
+
+// Runner runs a command and returns its output, implemented by the executor
+type Runner interface {
+	Execute(ctx context.Context, command string, args ...string) (stdout, stderr string, err error)
+}
+
+// CommandError is a bv command that failed. Its output often explains why, such as a job
+// that does not exist.
+type CommandError struct {
+	Stdout string
+	Stderr string
+	Err    error
+}
+
+// Error returns the command's error
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the command's error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Output returns the command's error output, or its standard output when it wrote no errors
+func (e *CommandError) Output() string {
+	if e.Stderr != "" {
+		return e.Stderr
+	}
+	return e.Stdout
+}
+
+// Client runs bv commands and parses their output independently of the bv version. It
+// detects the installed version and, in auto format, asks for JSON output; when bv
+// rejects --output json, the client remembers it for that version and parses the text
+// output instead.
+type Client struct {
+	runner Runner
+	format string
+	now    func() time.Time
+
+	mu         sync.Mutex
+	version    *Version // nil when detection failed
+	detectedAt time.Time
+	noJSON     bool // The detected version rejects --output json
+}
+
+// New creates a client running bv through runner, in auto format
+func New(runner Runner) *Client {
+	return &Client{
+		runner: runner,
+		format: FormatAuto,
+		now:    time.Now,
+	}
+}
+
+// SetFormat sets the output format: auto, json or text. An empty format is auto.
+func (c *Client) SetFormat(format string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if format == "" {
+		format = FormatAuto
+	}
+	c.format = format
+}
+
+// Version returns the installed bv version. The version is detected with `bv --version`
+// and cached; an error means bv did not report a version.
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.detect(ctx)
+	if c.version == nil {
+		return Version{}, fmt.Errorf("bv version unknown")
+	}
+	return *c.version, nil
+}
+
+// detect runs `bv --version` unless a recent result is cached. Failures are cached too,
+// so a bv without a parsable version is not asked on every command; such a bv is only
+// parsed as text. The caller holds c.mu.
+func (c *Client) detect(ctx context.Context) {
+	now := c.now()
+	if !c.detectedAt.IsZero() && now.Sub(c.detectedAt) < versionTTL {
+		return
+	}
+
+	previous := c.version
+	c.version = nil
+	c.detectedAt = now
+
+	stdout, _, err := c.runner.Execute(ctx, "bv", "--version")
+	if err != nil {
+		return
+	}
+	version, err := ParseVersion(stdout)
+	if err != nil {
+		return
+	}
+	c.version = &version
+
+	// A different version may support JSON output
+	if previous == nil || previous.Raw != version.Raw {
+		c.noJSON = false
+	}
+}
+
+// useJSON reports whether the next command should ask for JSON output, and whether it
+// may fall back to text when bv rejects the flag
+func (c *Client) useJSON(ctx context.Context) (useJSON, fallback bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.format {
+	case FormatJSON:
+		return true, false
+	case FormatText:
+		return false, false
+	}
+	c.detect(ctx)
+	return c.version != nil && !c.noJSON, true
+}
+
+// rejectJSON records that the installed bv does not support --output json
+func (c *Client) rejectJSON() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noJSON = true
+}
+
+// JobInfo returns the state of a node's job, from `bv node job <node> info <job>`. A
+// failed command returns a *CommandError with bv's output.
+func (c *Client) JobInfo(ctx context.Context, nodeName, job string) (*JobInfo, error) {
+	args := []string{"node", "job", nodeName, "info", job}
+
+	if useJSON, fallback := c.useJSON(ctx); useJSON {
+		stdout, stderr, err := c.runner.Execute(ctx, "bv", append(args, "--output", "json")...)
+		switch {
+		case err == nil:
+			info, parseErr := parseJSONJobInfo(stdout)
+			if parseErr == nil {
+				return info, nil
+			}
+			if executor.IsTruncated(stdout) {
+				// The logs overflowed the output cap and the JSON lost its middle; the
+				// text output leads with the status, which survives the cap
+				break
+			}
+			// bv accepted the flag but printed text
+			return parseTextJobInfo(stdout), nil
+		case fallback && flagRejected(stdout, stderr):
+			c.rejectJSON()
+		default:
+			return nil, &CommandError{Stdout: stdout, Stderr: stderr, Err: err}
+		}
+	}
+
+	stdout, stderr, err := c.runner.Execute(ctx, "bv", args...)
+	if err != nil {
+		return nil, &CommandError{Stdout: stdout, Stderr: stderr, Err: err}
+	}
+	return parseTextJobInfo(stdout), nil
+}
+
+// flagRejected reports whether bv's output says it does not know a flag
+func flagRejected(outputs ...string) bool {
+	for _, output := range outputs {
+		lower := strings.ToLower(output)
+		for _, pattern := range flagRejectedPatterns {
+			if strings.Contains(lower, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
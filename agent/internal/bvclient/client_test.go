@@ -0,0 +1,348 @@
+package bvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
+)
+
+// fixture is the output of one bv command
+type fixture struct {
+	stdout string
+	stderr string
+	err    error
+}
+
+// fixtureRunner replays bv outputs by command line and records the commands run
+type fixtureRunner struct {
+	outputs map[string]fixture
+	calls   []string
+}
+
+func (r *fixtureRunner) Execute(ctx context.Context, command string, args ...string) (string, string, error) {
+	line := strings.Join(append([]string{command}, args...), " ")
+	r.calls = append(r.calls, line)
+	out, ok := r.outputs[line]
+	if !ok {
+		return "", "", fmt.Errorf("command failed: no fixture for %s", line)
+	}
+	return out.stdout, out.stderr, out.err
+}
+
+const (
+	infoCommand = "bv node job eth-1 info upload"
+	jsonCommand = infoCommand + " --output json"
+)
+
+// textRunning is the text output of a running upload, printed by every bv version
+const textRunning = `status:           2025-12-10 15:18:44 UTC| Running
+progress:         75.50% (3100/4112 uploading)
+restart_count:    1
+upgrade_blocking: true
+logs:             <empty>
+`
+
+// bvFixtures are the outputs of each bv version for a running upload
+var bvFixtures = []struct {
+	version      string
+	outputs      map[string]fixture
+	jsonAttempts int // --output json runs over two checks
+}{
+	{
+		// No parsable version: text only, --output json is never tried
+		version: "unknown",
+		outputs: map[string]fixture{
+			"bv --version": {stdout: "blockvisor development build\n"},
+			infoCommand:    {stdout: textRunning},
+		},
+	},
+	{
+		// An older argument parser rejecting --output
+		version: "1.2.0",
+		outputs: map[string]fixture{
+			"bv --version": {stdout: "bv 1.2.0\n"},
+			jsonCommand:    {stderr: "error: Found argument '--output' which wasn't expected, or isn't valid in this context\n", err: errors.New("exit status 2")},
+			infoCommand:    {stdout: textRunning},
+		},
+		jsonAttempts: 1,
+	},
+	{
+		version: "1.4.1",
+		outputs: map[string]fixture{
+			"bv --version": {stdout: "bv 1.4.1\n"},
+			jsonCommand:    {stderr: "error: unexpected argument '--output' found\n\nUsage: bv node job <ID_OR_NAME> info <NAME>\n", err: errors.New("exit status 2")},
+			infoCommand:    {stdout: textRunning},
+		},
+		jsonAttempts: 1,
+	},
+	{
+		// JSON with a nested status and structured progress
+		version: "1.6.0",
+		outputs: map[string]fixture{
+			"bv --version": {stdout: "bv 1.6.0\n"},
+			jsonCommand:    {stdout: `{"status": {"state": "Running", "timestamp": "2025-12-10T15:18:44Z"}, "progress": {"completed": 3100, "total": 4112, "message": "uploading"}, "restart_count": 1, "upgrade_blocking": true, "logs": []}`},
+		},
+		jsonAttempts: 2,
+	},
+	{
+		// JSON with flat fields, wrapped in a job object
+		version: "2.0.0-rc.1",
+		outputs: map[string]fixture{
+			"bv --version": {stdout: "bv v2.0.0-rc.1\n"},
+			jsonCommand:    {stdout: `{"job": {"name": "upload", "State": "running", "updated_at": "2025-12-10 15:18:44", "progress": "75.50% (3100/4112 uploading)", "restart_count": "1"}}`},
+		},
+		jsonAttempts: 2,
+	},
+}
+
+func TestJobInfo_Versions(t *testing.T) {
+	for _, tt := range bvFixtures {
+		t.Run(tt.version, func(t *testing.T) {
+			runner := &fixtureRunner{outputs: tt.outputs}
+			client := New(runner)
+
+			for i := 0; i < 2; i++ {
+				info, err := client.JobInfo(context.Background(), "eth-1", "upload")
+				if err != nil {
+					t.Fatalf("JobInfo failed: %v", err)
+				}
+				if !info.Running || !strings.EqualFold(info.State, "running") {
+					t.Errorf("expected a running job, got %+v", info)
+				}
+				if info.StatusTime == nil || !info.StatusTime.Equal(time.Date(2025, 12, 10, 15, 18, 44, 0, time.UTC)) {
+					t.Errorf("expected the status time, got %v", info.StatusTime)
+				}
+				if info.ProgressPercent == nil || *info.ProgressPercent < 75.3 || *info.ProgressPercent > 75.6 {
+					t.Errorf("expected about 75.5%% progress, got %v", info.ProgressPercent)
+				}
+				if info.ChunksCompleted == nil || *info.ChunksCompleted != 3100 || info.ChunksTotal == nil || *info.ChunksTotal != 4112 {
+					t.Errorf("expected 3100/4112 chunks, got %v/%v", info.ChunksCompleted, info.ChunksTotal)
+				}
+				if info.Fields["restart_count"] != "1" {
+					t.Errorf("expected restart_count 1, got %q", info.Fields["restart_count"])
+				}
+			}
+
+			// The version is detected once and a rejected flag is not tried again
+			var versionChecks, jsonAttempts int
+			for _, call := range runner.calls {
+				switch call {
+				case "bv --version":
+					versionChecks++
+				case jsonCommand:
+					jsonAttempts++
+				}
+			}
+			if versionChecks != 1 || jsonAttempts != tt.jsonAttempts {
+				t.Errorf("expected 1 version check and %d JSON attempts, got %d and %d: %v", tt.jsonAttempts, versionChecks, jsonAttempts, runner.calls)
+			}
+		})
+	}
+}
+
+func TestJobInfo_Finished(t *testing.T) {
+	info, err := ParseJobInfo(`{"state": "Finished", "exit_code": 1, "message": "no space left on device", "timestamp": 1765120903, "progress": 40}`, FormatJSON)
+	if err != nil {
+		t.Fatalf("ParseJobInfo failed: %v", err)
+	}
+	wantState := "Finished with exit code 1 and message `no space left on device`"
+	if info.Running || info.State != wantState || info.Status != "2025-12-07 15:21:43 UTC| "+wantState {
+		t.Errorf("expected the text status format, got running %v, state %q, status %q", info.Running, info.State, info.Status)
+	}
+	if info.ProgressPercent == nil || *info.ProgressPercent != 40 || info.Progress != "40.00%" {
+		t.Errorf("expected 40%% progress, got %v %q", info.ProgressPercent, info.Progress)
+	}
+
+	if _, err := ParseJobInfo("status: Running", FormatJSON); err == nil {
+		t.Error("expected an error for text output parsed as JSON")
+	}
+}
+
+func TestJobInfo_SizeBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		format string
+		want   int64
+	}{
+		{name: "text", output: textRunning + "size_bytes:       1073741824\n", format: FormatText, want: 1073741824},
+		{name: "json progress", output: `{"state": "Running", "progress": {"completed": 3100, "total": 4112, "bytes": 1073741824}}`, format: FormatJSON, want: 1073741824},
+		{name: "json field", output: `{"state": "Finished", "total_bytes": "2147483648"}`, format: FormatJSON, want: 2147483648},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseJobInfo(tt.output, tt.format)
+			if err != nil {
+				t.Fatalf("ParseJobInfo failed: %v", err)
+			}
+			if info.SizeBytes == nil || *info.SizeBytes != tt.want {
+				t.Errorf("expected %d bytes, got %v", tt.want, info.SizeBytes)
+			}
+		})
+	}
+
+	// Sizes in human units are not parsed
+	info, _ := ParseJobInfo(textRunning+"size_bytes:       1.2 TiB\n", FormatText)
+	if info.SizeBytes != nil {
+		t.Errorf("expected no size, got %d", *info.SizeBytes)
+	}
+}
+
+func TestJobInfo_LocaleProgress(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		output    string
+		format    string
+		percent   float64
+		completed *int
+		total     *int
+	}{
+		{name: "comma decimal", output: "progress: 75,50% (3100/4112 uploading)\n", format: FormatText, percent: 75.5, completed: intPtr(3100), total: intPtr(4112)},
+		{name: "dot groups", output: "progress: 75,50% (13.100/14.112 uploading)\n", format: FormatText, percent: 75.5, completed: intPtr(13100), total: intPtr(14112)},
+		{name: "comma groups", output: "progress: 75.50% (13,100/14,112 uploading)\n", format: FormatText, percent: 75.5, completed: intPtr(13100), total: intPtr(14112)},
+		{name: "space groups", output: "progress: 75,5 % (13\u00a0100/14 112 uploading)\n", format: FormatText, percent: 75.5, completed: intPtr(13100), total: intPtr(14112)},
+		{name: "above 100", output: "progress: 1.012,5% (4200/4112 uploading)\n", format: FormatText, percent: 1012.5, completed: intPtr(4200), total: intPtr(4112)},
+		{name: "no total", output: "progress: 75.50% (3100/? uploading)\n", format: FormatText, percent: 75.5, completed: intPtr(3100)},
+		{name: "counts only", output: "progress: (3100/4000 uploading)\n", format: FormatText, percent: 77.5, completed: intPtr(3100), total: intPtr(4000)},
+		{name: "json strings", output: `{"state": "Running", "progress": {"percent": "75,5", "completed": "3.100"}}`, format: FormatJSON, percent: 75.5, completed: intPtr(3100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseJobInfo(tt.output, tt.format)
+			if err != nil {
+				t.Fatalf("ParseJobInfo failed: %v", err)
+			}
+			if info.ProgressPercent == nil || *info.ProgressPercent != tt.percent {
+				t.Errorf("expected %v%%, got %v", tt.percent, info.ProgressPercent)
+			}
+			for name, pair := range map[string][2]*int{"completed": {tt.completed, info.ChunksCompleted}, "total": {tt.total, info.ChunksTotal}} {
+				want, got := pair[0], pair[1]
+				if (want == nil) != (got == nil) || (want != nil && *want != *got) {
+					t.Errorf("expected %s chunks %v, got %v", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	for input, want := range map[string]float64{
+		"75.50": 75.5, "75,50": 75.5, "1,234.5": 1234.5, "1.234,5": 1234.5, "1,234,567": 1234567,
+		"1.234.567": 1234567, "1 234,5": 1234.5, "1'234.5": 1234.5, "42%": 42, " 7 ": 7,
+	} {
+		if got, ok := parseDecimal(input); !ok || got != want {
+			t.Errorf("parseDecimal(%q) = %v, %v, want %v", input, got, ok, want)
+		}
+	}
+	for _, input := range []string{"", "%", "abc", "NaN", "Inf", "1.2.3,4,5"} {
+		if got, ok := parseDecimal(input); ok {
+			t.Errorf("parseDecimal(%q) = %v, want no number", input, got)
+		}
+	}
+}
+
+func TestJobInfo_CommandError(t *testing.T) {
+	runner := &fixtureRunner{outputs: map[string]fixture{
+		"bv --version": {stdout: "bv 1.6.0\n"},
+		jsonCommand:    {stderr: "Error: job 'upload' not found\n", err: errors.New("exit status 1")},
+	}}
+
+	_, err := New(runner).JobInfo(context.Background(), "eth-1", "upload")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !strings.Contains(cmdErr.Output(), "not found") {
+		t.Fatalf("expected a CommandError with bv's output, got %v", err)
+	}
+	if len(runner.calls) != 2 {
+		t.Errorf("expected no text retry for a job error, got %v", runner.calls)
+	}
+}
+
+func TestJobInfo_TruncatedJSON(t *testing.T) {
+	// Megabytes of logs overflowed the executor's output cap and cut the JSON in two
+	logs := `"` + strings.Repeat("x", 2*executor.MaxOutputBytes) + `"`
+	truncated := executor.TruncateOutput(`{"status": "Running", "logs": [`+logs+`]}`, executor.MaxOutputBytes)
+	runner := &fixtureRunner{outputs: map[string]fixture{
+		"bv --version": {stdout: "bv 1.6.0\n"},
+		jsonCommand:    {stdout: truncated},
+		infoCommand:    {stdout: executor.TruncateOutput(textRunning+strings.Repeat("log line\n", 1000), 512)},
+	}}
+
+	info, err := New(runner).JobInfo(context.Background(), "eth-1", "upload")
+	if err != nil {
+		t.Fatalf("JobInfo failed: %v", err)
+	}
+	if info.Format != FormatText || !info.Running || info.ChunksCompleted == nil || *info.ChunksCompleted != 3100 {
+		t.Errorf("expected the text output's running status, got %+v", info)
+	}
+}
+
+func TestJobInfo_Formats(t *testing.T) {
+	outputs := map[string]fixture{
+		"bv --version": {stdout: "bv 1.4.1\n"},
+		jsonCommand:    {stderr: "error: unexpected argument '--output' found\n", err: errors.New("exit status 2")},
+		infoCommand:    {stdout: textRunning},
+	}
+
+	// A forced format skips version detection; forced JSON does not fall back
+	runner := &fixtureRunner{outputs: outputs}
+	client := New(runner)
+	client.SetFormat(FormatText)
+	if info, err := client.JobInfo(context.Background(), "eth-1", "upload"); err != nil || info.Format != FormatText {
+		t.Errorf("expected text output, got %+v, %v", info, err)
+	}
+	client.SetFormat(FormatJSON)
+	if _, err := client.JobInfo(context.Background(), "eth-1", "upload"); err == nil {
+		t.Error("expected the rejected flag to fail with forced JSON")
+	}
+	if strings.Join(runner.calls, ";") != infoCommand+";"+jsonCommand {
+		t.Errorf("unexpected commands: %v", runner.calls)
+	}
+
+	// After an upgrade the new version is tried with JSON again
+	runner = &fixtureRunner{outputs: outputs}
+	client = New(runner)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+	client.JobInfo(context.Background(), "eth-1", "upload")
+
+	outputs["bv --version"] = fixture{stdout: "bv 1.6.0\n"}
+	outputs[jsonCommand] = fixture{stdout: `{"status": "Running"}`}
+	now = now.Add(versionTTL)
+	info, err := client.JobInfo(context.Background(), "eth-1", "upload")
+	if err != nil || info.Format != FormatJSON {
+		t.Errorf("expected JSON output after the upgrade, got %+v, %v", info, err)
+	}
+	if version, err := client.Version(context.Background()); err != nil || version.String() != "1.6.0" {
+		t.Errorf("expected version 1.6.0, got %v, %v", version, err)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    Version
+		wantErr bool
+	}{
+		{output: "bv 1.4.2\n", want: Version{Major: 1, Minor: 4, Patch: 2, Raw: "1.4.2"}},
+		{output: "\nblockvisor v2.1\n", want: Version{Major: 2, Minor: 1, Raw: "2.1"}},
+		{output: "bv 1.5.0-rc.1 (abc123 2025-11-02)", want: Version{Major: 1, Minor: 5, Raw: "1.5.0-rc.1"}},
+		{output: "blockvisor development build", wantErr: true},
+		{output: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.output)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, %v; want %+v", tt.output, got, err, tt.want)
+		}
+	}
+}
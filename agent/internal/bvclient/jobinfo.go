@@ -0,0 +1,348 @@
+package bvclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats of bv commands
+const (
+	FormatAuto = "auto" // JSON when the installed bv supports it, text otherwise
+	FormatJSON = "json" // Always request --output json
+	FormatText = "text" // Always parse the human-readable output
+)
+
+// sizeFields are the field names bv versions report the bytes uploaded under
+var sizeFields = []string{"size_bytes", "total_bytes", "uploaded_bytes", "bytes_uploaded", "bytes"}
+
+// statusTimeLayout is the timestamp bv prints before the status, e.g. "2025-12-10 15:18:44 UTC"
+const statusTimeLayout = "2006-01-02 15:04:05 MST"
+
+// JobInfo is the state of a bv job as reported by `bv node job <node> info <job>`
+type JobInfo struct {
+	Format     string     // Output format the info was parsed from (json or text)
+	Running    bool       // The job is running
+	Status     string     // Status as reported, including its timestamp when bv prints one
+	State      string     // Status without the timestamp, e.g. "Finished with exit code 0"
+	StatusTime *time.Time // When bv recorded the status, if reported
+	// Progress is the progress as reported, e.g. "75.50% (3100/4112 uploading)"
+	Progress        string
+	ProgressPercent *float64
+	ChunksCompleted *int
+	ChunksTotal     *int
+	SizeBytes       *int64            // Bytes uploaded, when bv reports them
+	Fields          map[string]string // Every reported field by lowercase name, e.g. restart_count
+	Raw             string            // Output the info was parsed from
+}
+
+// ParseJobInfo parses job info output in the given format, json or text. Text parsing is
+// tolerant: unknown lines are ignored and missing fields are left empty.
+func ParseJobInfo(output, format string) (*JobInfo, error) {
+	if format == FormatJSON {
+		return parseJSONJobInfo(output)
+	}
+	return parseTextJobInfo(output), nil
+}
+
+// parseTextJobInfo parses the key-value text format:
+//
+//	status:           2025-12-07 13:41:43 UTC| Finished with exit code 0 and message `...`
+//	progress:         100.00% (3248/3248 multi-client upload completed)
+//	restart_count:    0
+//	upgrade_blocking: true
+//	logs:             <empty>
+func parseTextJobInfo(output string) *JobInfo {
+	output = strings.TrimSpace(output)
+	info := &JobInfo{
+		Format: FormatText,
+		Fields: make(map[string]string),
+		Raw:    output,
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		info.Fields[key] = value
+
+		switch key {
+		case "status":
+			info.setStatus(value)
+		case "progress":
+			info.setProgress(value)
+		}
+	}
+	info.setSize(info.Fields)
+
+	return info
+}
+
+// setStatus sets the status from a status line, splitting off the timestamp in lines such
+// as "2025-12-10 15:18:44 UTC| Running"
+func (i *JobInfo) setStatus(value string) {
+	i.Status = value
+	i.State = value
+
+	if timestamp, state, ok := strings.Cut(value, "UTC|"); ok {
+		i.State = strings.TrimSpace(state)
+		if parsed, err := time.Parse(statusTimeLayout, strings.TrimSpace(timestamp)+" UTC"); err == nil {
+			i.StatusTime = &parsed
+		}
+	}
+
+	i.Running = strings.Contains(strings.ToLower(i.State), "running")
+}
+
+// setSize sets the bytes uploaded from the first size field reported as a whole number
+func (i *JobInfo) setSize(fields map[string]string) {
+	for _, key := range sizeFields {
+		if n, ok := parseCount(fields[key]); ok {
+			i.SizeBytes = &n
+			return
+		}
+	}
+}
+
+// setProgress sets the progress from text such as "75.50% (3100/4112 uploading)". The
+// numbers may use any locale's separators, as in "75,50% (3.100/4.112 uploading)", and
+// whichever of the percentage and chunk counts are present are kept.
+func (i *JobInfo) setProgress(value string) {
+	i.Progress = value
+
+	if percentIdx := strings.Index(value, "%"); percentIdx > 0 {
+		if percent, ok := parseDecimal(value[:percentIdx]); ok {
+			i.ProgressPercent = &percent
+		}
+	}
+
+	// Chunk counts follow in parentheses, e.g. "(3100/4112 uploading)"
+	start := strings.Index(value, "(")
+	end := strings.Index(value, ")")
+	if start < 0 || end <= start {
+		return
+	}
+	completed, total, hasTotal := strings.Cut(value[start+1:end], "/")
+	if n, ok := parseCount(leadingNumber(completed)); ok {
+		count := int(n)
+		i.ChunksCompleted = &count
+	}
+	if n, ok := parseCount(leadingNumber(total)); ok && hasTotal {
+		count := int(n)
+		i.ChunksTotal = &count
+	}
+	i.setPercentFromChunks()
+}
+
+// setPercentFromChunks derives a missing percentage from the chunk counts
+func (i *JobInfo) setPercentFromChunks() {
+	if i.ProgressPercent == nil && i.ChunksCompleted != nil && i.ChunksTotal != nil && *i.ChunksTotal > 0 {
+		percent := float64(*i.ChunksCompleted) * 100 / float64(*i.ChunksTotal)
+		i.ProgressPercent = &percent
+	}
+}
+
+// leadingNumber returns the number s starts with, digits and group separators included,
+// e.g. "3 100" from " 3 100 uploading"
+func leadingNumber(s string) string {
+	s = strings.TrimSpace(s)
+	runes := []rune(s)
+	end := 0
+	for k, r := range runes {
+		digit := r >= '0' && r <= '9'
+		separator := strings.ContainsRune(",.'\u2019\u00a0\u202f\u2009", r) ||
+			(r == ' ' && k+1 < len(runes) && runes[k+1] >= '0' && runes[k+1] <= '9')
+		if !digit && (!separator || k == 0) {
+			break
+		}
+		end = k + 1
+	}
+	return string(runes[:end])
+}
+
+// parseJSONJobInfo parses `--output json` job info. Field names vary between bv versions,
+// so the common spellings are accepted: the status may be a string or an object with its
+// state, exit code, message and timestamp, and the progress a string, a percentage or an
+// object with percent, completed and total counts. A job wrapped in a "job" or "info"
+// object, or listed in an array, is unwrapped.
+func parseJSONJobInfo(output string) (*JobInfo, error) {
+	output = strings.TrimSpace(output)
+	info := &JobInfo{
+		Format: FormatJSON,
+		Fields: make(map[string]string),
+		Raw:    output,
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		return nil, fmt.Errorf("failed to parse bv JSON output: %w", err)
+	}
+	object := unwrapJob(decoded)
+	if object == nil {
+		// null or an empty array: no job
+		return info, nil
+	}
+
+	for key, value := range object {
+		if text, ok := scalarString(value); ok {
+			info.Fields[key] = text
+		}
+	}
+	if logs, ok := object["logs"].([]interface{}); ok {
+		lines := make([]string, 0, len(logs))
+		for _, line := range logs {
+			if text, ok := scalarString(line); ok {
+				lines = append(lines, text)
+			}
+		}
+		info.Fields["logs"] = strings.Join(lines, "\n")
+	}
+
+	status := object
+	if nested, ok := lowerKeys(lookup(object, "status")); ok {
+		status = nested
+	}
+	state, _ := scalarString(lookup(status, "state", "status", "phase"))
+	exitCode, hasExitCode := scalarString(lookup(status, "exit_code", "exitcode", "code"))
+	message, _ := scalarString(lookup(status, "message", "msg"))
+	if state != "" {
+		if hasExitCode && !strings.Contains(strings.ToLower(state), "exit code") {
+			state = fmt.Sprintf("%s with exit code %s", state, exitCode)
+		}
+		if message != "" && !strings.Contains(state, message) {
+			state = fmt.Sprintf("%s and message `%s`", state, message)
+		}
+	}
+	info.State = state
+	info.Status = state
+	info.Running = strings.Contains(strings.ToLower(state), "running")
+	if statusTime, ok := parseTime(lookup(status, "timestamp", "time", "updated_at", "finished_at", "started_at")); ok {
+		info.StatusTime = &statusTime
+		info.Status = fmt.Sprintf("%s UTC| %s", statusTime.UTC().Format("2006-01-02 15:04:05"), state)
+	}
+
+	switch progress := lookup(object, "progress").(type) {
+	case string:
+		info.setProgress(progress)
+	case float64:
+		info.ProgressPercent = &progress
+		info.Progress = fmt.Sprintf("%.2f%%", progress)
+	case map[string]interface{}:
+		fields, _ := lowerKeys(progress)
+		if percent, ok := numberValue(lookup(fields, "percent", "percentage", "progress", "value")); ok {
+			info.ProgressPercent = &percent
+		}
+		if completed, ok := countValue(lookup(fields, "completed", "current", "done", "chunks_completed")); ok {
+			info.ChunksCompleted = &completed
+		}
+		if total, ok := countValue(lookup(fields, "total", "chunks_total")); ok {
+			info.ChunksTotal = &total
+		}
+		info.setPercentFromChunks()
+		info.Progress = formatProgress(info, fields)
+		progressFields := make(map[string]string)
+		for _, key := range sizeFields {
+			if text, ok := scalarString(fields[key]); ok {
+				progressFields[key] = text
+			}
+		}
+		info.setSize(progressFields)
+	}
+	if info.SizeBytes == nil {
+		info.setSize(info.Fields)
+	}
+
+	return info, nil
+}
+
+// unwrapJob returns the object describing the job, with lowercase keys
+func unwrapJob(decoded interface{}) map[string]interface{} {
+	switch value := decoded.(type) {
+	case []interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		return unwrapJob(value[0])
+	case map[string]interface{}:
+		object, _ := lowerKeys(value)
+		if _, ok := object["status"]; !ok {
+			if nested, ok := lowerKeys(lookup(object, "job", "info", "upload")); ok {
+				return nested
+			}
+		}
+		return object
+	}
+	return nil
+}
+
+// lowerKeys returns an object with its keys lowercased, reporting whether value is an object
+func lowerKeys(value interface{}) (map[string]interface{}, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	lowered := make(map[string]interface{}, len(object))
+	for key, field := range object {
+		lowered[strings.ToLower(key)] = field
+	}
+	return lowered, true
+}
+
+// lookup returns the first of the keys present in object
+func lookup(object map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if value, ok := object[key]; ok && value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// scalarString renders a JSON string, number or boolean as text
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// parseTime parses a timestamp as RFC 3339, bv's status layout or Unix seconds
+func parseTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, statusTimeLayout, "2006-01-02 15:04:05"} {
+			if parsed, err := time.Parse(layout, v); err == nil {
+				return parsed.UTC(), true
+			}
+		}
+	case float64:
+		return time.Unix(int64(v), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// formatProgress renders structured progress in the text format, e.g. "75.50% (3100/4112 uploading)"
+func formatProgress(info *JobInfo, fields map[string]interface{}) string {
+	var progress string
+	if info.ProgressPercent != nil {
+		progress = fmt.Sprintf("%.2f%%", *info.ProgressPercent)
+	}
+	if info.ChunksCompleted != nil && info.ChunksTotal != nil {
+		counts := fmt.Sprintf("%d/%d", *info.ChunksCompleted, *info.ChunksTotal)
+		if message, ok := scalarString(lookup(fields, "message", "msg")); ok && message != "" {
+			counts += " " + message
+		}
+		progress = strings.TrimSpace(fmt.Sprintf("%s (%s)", progress, counts))
+	}
+	return progress
+}
@@ -0,0 +1,93 @@
+package bvclient
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// groupSeparators are the digit group separators bv prints in some locales, besides the
+// commas and dots handled by parseDecimal and parseCount
+var groupSeparators = strings.NewReplacer(
+	" ", "",
+	"\u00a0", "", // No-break space (fr, ru)
+	"\u202f", "", // Narrow no-break space (fr)
+	"\u2009", "", // Thin space
+	"'", "", // Apostrophe (de-CH)
+	"\u2019", "", // Right single quotation mark (de-CH)
+)
+
+// parseDecimal parses a number printed in any locale, such as "75.50", "75,50",
+// "1,234.5" or "1.234,5", with an optional trailing percent sign. When both a comma and
+// a dot appear, the last one is the decimal separator; a single kind of separator is the
+// decimal separator when it appears once and a group separator otherwise.
+func parseDecimal(s string) (float64, bool) {
+	s = groupSeparators.Replace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if s == "" {
+		return 0, false
+	}
+
+	lastComma, lastDot := strings.LastIndex(s, ","), strings.LastIndex(s, ".")
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastComma >= 0:
+		if strings.Count(s, ",") > 1 {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.Replace(s, ",", ".", 1)
+		}
+	case lastDot >= 0:
+		if strings.Count(s, ".") > 1 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	return value, err == nil && !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// parseCount parses a whole number printed in any locale, such as "3100", "3,100",
+// "3.100" or "3 100". Commas and dots are group separators, since counts have no
+// fraction.
+func parseCount(s string) (int64, bool) {
+	s = groupSeparators.Replace(strings.TrimSpace(s))
+	s = strings.NewReplacer(",", "", ".", "").Replace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	return value, err == nil && value >= 0
+}
+
+// numberValue returns a JSON number, or a string holding one in any locale
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		return parseDecimal(v)
+	}
+	return 0, false
+}
+
+// countValue returns a JSON number, or a string holding a whole number in any locale
+func countValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v < 0 {
+			return 0, false
+		}
+		return int(v), true
+	case string:
+		n, ok := parseCount(v)
+		return int(n), ok
+	}
+	return 0, false
+}
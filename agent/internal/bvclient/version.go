@@ -0,0 +1,54 @@
+package bvclient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionPattern matches a version token such as "1.4.2", "v1.4" or "1.5.0-rc.1"
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?(?:[-+][0-9A-Za-z.-]+)?$`)
+
+// Version is a bv CLI version
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Raw   string // Version token as printed, e.g. "1.5.0-rc.1"
+}
+
+// ParseVersion extracts the version from `bv --version` output, which prints the program
+// name followed by the version, e.g. "bv 1.4.2". The first version-like token of the first
+// non-empty line is used.
+func ParseVersion(output string) (Version, error) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, field := range fields {
+			match := versionPattern.FindStringSubmatch(field)
+			if match == nil {
+				continue
+			}
+			version := Version{Raw: strings.TrimPrefix(field, "v")}
+			version.Major, _ = strconv.Atoi(match[1])
+			version.Minor, _ = strconv.Atoi(match[2])
+			if match[3] != "" {
+				version.Patch, _ = strconv.Atoi(match[3])
+			}
+			return version, nil
+		}
+		break
+	}
+	return Version{}, fmt.Errorf("no version in bv output %q", strings.TrimSpace(output))
+}
+
+// String returns the version as printed by bv
+func (v Version) String() string {
+	if v.Raw != "" {
+		return v.Raw
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
@@ -0,0 +1,290 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
+)
+
+// Blockvisor node sources
+const (
+	BlockvisorSourceFile    = "file"    // Read node definitions from a JSON file
+	BlockvisorSourceCommand = "command" // Run `bv node list --json`
+)
+
+// DefaultBlockvisorPath is the blockvisor config read by the file source
+const DefaultBlockvisorPath = "/etc/blockvisor.json"
+
+// BlockvisorConfig enables deriving node entries from blockvisor. Entries under
+// nodes in config.yaml with the same name override the derived values.
+type BlockvisorConfig struct {
+	Source   string `yaml:"source"`   // file (default) or command
+	Path     string `yaml:"path"`     // JSON file for the file source (default /etc/blockvisor.json)
+	Schedule string `yaml:"schedule"` // Upload schedule for derived nodes without an override
+	RPCHost  string `yaml:"rpc_host"` // Host used in derived RPC URLs when the node has no IP (default localhost)
+}
+
+// blockvisorNode is a node entry in blockvisor's JSON output
+type blockvisorNode struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	NodeType string `json:"node_type"`
+	IP       string `json:"ip"`
+	RPCPort  int    `json:"rpc_port"`
+	Image    *struct {
+		Protocol string `json:"protocol"`
+		NodeType string `json:"node_type"`
+	} `json:"image"`
+}
+
+// blockvisorExecutor returns the executor running `bv node list --json`. Like the
+// daemon's own, it runs bv through the bv command prefix, only the allowed commands and
+// in the executor sandbox, and it waits for any bv command changing a node; replaced in
+// tests
+var blockvisorExecutor = func(c *Config) (executor.CommandExecutor, error) {
+	exec := executor.NewDefaultExecutor(nil)
+	exec.SetBVCommandPrefix(c.BVCommandPrefix)
+	if err := exec.SetSandbox(c.ExecutorSandbox()); err != nil {
+		return nil, fmt.Errorf("invalid executor config: %w", err)
+	}
+	return exec, nil
+}
+
+// Validate validates the blockvisor configuration
+func (b *BlockvisorConfig) Validate() error {
+	switch b.Source {
+	case "", BlockvisorSourceFile, BlockvisorSourceCommand:
+	default:
+		return fmt.Errorf("unsupported blockvisor source %s", b.Source)
+	}
+
+	if b.Schedule != "" {
+		if err := validateCronSchedule(b.Schedule); err != nil {
+			return fmt.Errorf("invalid blockvisor schedule: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loadNodes reads node definitions from the configured blockvisor source, running the
+// command source through the executor of c
+func (b *BlockvisorConfig) loadNodes(c *Config) (map[string]NodeConfig, error) {
+	var data []byte
+	var err error
+
+	switch b.Source {
+	case BlockvisorSourceCommand:
+		exec, err := blockvisorExecutor(c)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		stdout, _, err := exec.Execute(ctx, "bv", "node", "list", "--json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to run bv node list: %w", err)
+		}
+		data = []byte(stdout)
+	default:
+		path := b.Path
+		if path == "" {
+			path = DefaultBlockvisorPath
+		}
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blockvisor config: %w", err)
+		}
+	}
+
+	return parseBlockvisorNodes(data, b.Schedule, b.RPCHost)
+}
+
+// parseBlockvisorNodes converts blockvisor JSON (a node array, or an object with a
+// "nodes" array) into node configurations
+func parseBlockvisorNodes(data []byte, schedule, rpcHost string) (map[string]NodeConfig, error) {
+	var entries []blockvisorNode
+	if err := json.Unmarshal(data, &entries); err != nil {
+		var wrapped struct {
+			Nodes []blockvisorNode `json:"nodes"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to parse blockvisor nodes: %w", err)
+		}
+		entries = wrapped.Nodes
+	}
+
+	if rpcHost == "" {
+		rpcHost = "localhost"
+	}
+
+	nodes := make(map[string]NodeConfig, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "" {
+			continue
+		}
+
+		node := NodeConfig{
+			Protocol: entry.Protocol,
+			Type:     entry.NodeType,
+			Schedule: schedule,
+		}
+		if entry.Image != nil {
+			if node.Protocol == "" {
+				node.Protocol = entry.Image.Protocol
+			}
+			if node.Type == "" {
+				node.Type = entry.Image.NodeType
+			}
+		}
+		if entry.RPCPort > 0 {
+			host := entry.IP
+			if host == "" {
+				host = rpcHost
+			}
+			node.URL = fmt.Sprintf("http://%s:%d", host, entry.RPCPort)
+		}
+
+		nodes[entry.Name] = node
+	}
+
+	return nodes, nil
+}
+
+// mergeNodeConfig applies the non-empty fields of an override onto a derived node. A new
+// NodeConfig field needs a case here, or the field is ignored for blockvisor-derived nodes.
+func mergeNodeConfig(base, override NodeConfig) NodeConfig {
+	merged := base
+	if override.Protocol != "" {
+		merged.Protocol = override.Protocol
+	}
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.Schedule != "" {
+		merged.Schedule = override.Schedule
+	}
+	if override.Timezone != "" {
+		merged.Timezone = override.Timezone
+	}
+	if override.URL != "" {
+		merged.URL = override.URL
+	}
+	if override.Notifications != nil {
+		merged.Notifications = override.Notifications
+	}
+	if override.Metadata != nil {
+		merged.Metadata = override.Metadata
+	}
+	if override.MaxDuration != "" {
+		merged.MaxDuration = override.MaxDuration
+	}
+	if override.FinalityTimeout != "" {
+		merged.FinalityTimeout = override.FinalityTimeout
+	}
+	if override.Metrics != nil {
+		merged.Metrics = override.Metrics
+	}
+	if override.Incremental != nil {
+		merged.Incremental = override.Incremental
+	}
+	if override.Priority != 0 {
+		merged.Priority = override.Priority
+	}
+	if override.MaxSnapshotAge != "" {
+		merged.MaxSnapshotAge = override.MaxSnapshotAge
+	}
+	if override.SLO != nil {
+		merged.SLO = override.SLO
+	}
+	if override.Enabled != nil {
+		merged.Enabled = override.Enabled
+	}
+	if override.Preflight != nil {
+		merged.Preflight = override.Preflight
+	}
+	if override.Hooks != nil {
+		merged.Hooks = override.Hooks
+	}
+	if override.Validation != nil {
+		merged.Validation = override.Validation
+	}
+	if override.Tenant != "" {
+		merged.Tenant = override.Tenant
+	}
+	if override.Engine != "" {
+		merged.Engine = override.Engine
+	}
+	if override.Rclone != nil {
+		merged.Rclone = override.Rclone
+	}
+	if override.S3 != nil {
+		merged.S3 = override.S3
+	}
+	if override.Compression != nil {
+		merged.Compression = override.Compression
+	}
+	if override.Verification != nil {
+		merged.Verification = override.Verification
+	}
+	if override.Splay != "" {
+		merged.Splay = override.Splay
+	}
+	if override.Container != nil {
+		merged.Container = override.Container
+	}
+	if override.EngineArgs != nil {
+		merged.EngineArgs = override.EngineArgs
+	}
+	if override.Network != "" {
+		merged.Network = override.Network
+	}
+	if override.SnapshotLocation != "" {
+		merged.SnapshotLocation = override.SnapshotLocation
+	}
+	if override.Retry != nil {
+		merged.Retry = override.Retry
+	}
+	if override.CircuitBreaker != nil {
+		merged.CircuitBreaker = override.CircuitBreaker
+	}
+	if override.MetricRetry != nil {
+		merged.MetricRetry = override.MetricRetry
+	}
+	merged.CancelStalled = base.CancelStalled || override.CancelStalled
+	merged.WaitForFinality = base.WaitForFinality || override.WaitForFinality
+	merged.CatchUp = base.CatchUp || override.CatchUp
+	merged.RunOnStart = base.RunOnStart || override.RunOnStart
+	return merged
+}
+
+// applyBlockvisorNodes merges blockvisor-derived nodes with the nodes in config.yaml
+func (c *Config) applyBlockvisorNodes() error {
+	if err := c.Blockvisor.Validate(); err != nil {
+		return err
+	}
+	if err := validateBVCommandPrefix(c.BVCommandPrefix); err != nil {
+		return fmt.Errorf("invalid bv_command_prefix: %w", err)
+	}
+
+	derived, err := c.Blockvisor.loadNodes(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Nodes == nil {
+		c.Nodes = make(map[string]NodeConfig)
+	}
+	for name, node := range derived {
+		if override, exists := c.Nodes[name]; exists {
+			node = mergeNodeConfig(node, override)
+		}
+		c.Nodes[name] = node
+	}
+
+	return nil
+}
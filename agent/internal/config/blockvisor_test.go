@@ -0,0 +1,327 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
+)
+
+func TestParseBlockvisorNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]NodeConfig
+	}{
+		{
+			name: "array",
+			data: `[{"name": "eth-1", "protocol": "ethereum", "node_type": "archive", "ip": "10.0.0.5", "rpc_port": 8545}]`,
+			want: map[string]NodeConfig{
+				"eth-1": {Protocol: "ethereum", Type: "archive", URL: "http://10.0.0.5:8545", Schedule: "0 0 */6 * * *"},
+			},
+		},
+		{
+			name: "nodes object with image",
+			data: `{"nodes": [{"name": "arb-1", "image": {"protocol": "arbitrum", "node_type": "full"}, "rpc_port": 8547}]}`,
+			want: map[string]NodeConfig{
+				"arb-1": {Protocol: "arbitrum", Type: "full", URL: "http://localhost:8547", Schedule: "0 0 */6 * * *"},
+			},
+		},
+		{
+			name: "no rpc port and unnamed entries",
+			data: `[{"name": "eth-2", "protocol": "ethereum"}, {"protocol": "ethereum"}]`,
+			want: map[string]NodeConfig{
+				"eth-2": {Protocol: "ethereum", Schedule: "0 0 */6 * * *"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parseBlockvisorNodes([]byte(tt.data), "0 0 */6 * * *", "")
+			if err != nil {
+				t.Fatalf("parseBlockvisorNodes failed: %v", err)
+			}
+			if len(nodes) != len(tt.want) {
+				t.Fatalf("expected %d nodes, got %d: %+v", len(tt.want), len(nodes), nodes)
+			}
+			for name, want := range tt.want {
+				got := nodes[name]
+				if got.Protocol != want.Protocol || got.Type != want.Type || got.URL != want.URL || got.Schedule != want.Schedule {
+					t.Errorf("node %s: expected %+v, got %+v", name, want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := parseBlockvisorNodes([]byte(`not json`), "", ""); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestLoadConfigWithBlockvisorFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	blockvisorPath := filepath.Join(tmpDir, "blockvisor.json")
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	blockvisorContent := `[
+  {"name": "ethereum-mainnet", "protocol": "ethereum", "node_type": "archive", "rpc_port": 8545},
+  {"name": "arbitrum-one", "protocol": "arbitrum", "node_type": "archive", "rpc_port": 8547}
+]`
+	if err := os.WriteFile(blockvisorPath, []byte(blockvisorContent), 0644); err != nil {
+		t.Fatalf("Failed to write blockvisor config: %v", err)
+	}
+
+	configContent := `
+database:
+  driver: sqlite
+  path: /tmp/snapd.db
+blockvisor:
+  path: ` + blockvisorPath + `
+  schedule: "0 0 */6 * * *"
+nodes:
+  arbitrum-one:
+    schedule: "0 0 */12 * * *"
+    max_duration: 12h
+    splay: 10m
+    enabled: false
+  base-mainnet:
+    protocol: ethereum
+    schedule: "0 0 0 * * *"
+    url: http://localhost:9545
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if len(config.Nodes) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d", len(config.Nodes))
+	}
+
+	eth := config.Nodes["ethereum-mainnet"]
+	if eth.URL != "http://localhost:8545" || eth.Schedule != "0 0 */6 * * *" || eth.Type != "archive" {
+		t.Errorf("Unexpected derived node: %+v", eth)
+	}
+
+	arb := config.Nodes["arbitrum-one"]
+	if arb.Schedule != "0 0 */12 * * *" {
+		t.Errorf("Expected override schedule, got '%s'", arb.Schedule)
+	}
+	if arb.MaxDuration != "12h" {
+		t.Errorf("Expected override max_duration '12h', got '%s'", arb.MaxDuration)
+	}
+	if arb.Protocol != "arbitrum" || arb.URL != "http://localhost:8547" {
+		t.Errorf("Expected derived protocol and URL to be kept, got %+v", arb)
+	}
+	if arb.IsEnabled() {
+		t.Error("Expected override enabled: false to disable the node")
+	}
+	if got := config.GetNodeSplay("arbitrum-one"); got != 10*time.Minute {
+		t.Errorf("Expected override splay 10m, got %v", got)
+	}
+	if !eth.IsEnabled() {
+		t.Error("Expected nodes to be enabled by default")
+	}
+
+	if config.Nodes["base-mainnet"].URL != "http://localhost:9545" {
+		t.Error("Expected config-only node to be kept")
+	}
+}
+
+// recordingExecutor records the commands it runs and answers them with stdout
+type recordingExecutor struct {
+	stdout  string
+	invoked []string
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, command string, args ...string) (string, string, error) {
+	e.invoked = append([]string{command}, args...)
+	return e.stdout, "", nil
+}
+
+func TestLoadConfigWithBlockvisorCommand(t *testing.T) {
+	original := blockvisorExecutor
+	defer func() { blockvisorExecutor = original }()
+	exec := &recordingExecutor{stdout: `[{"name": "ethereum-mainnet", "protocol": "ethereum", "ip": "10.0.0.5", "rpc_port": 8545}]`}
+	var prefix []string
+	blockvisorExecutor = func(c *Config) (executor.CommandExecutor, error) {
+		if _, err := original(c); err != nil {
+			return nil, err
+		}
+		prefix = c.BVCommandPrefix
+		return exec, nil
+	}
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+database:
+  driver: sqlite
+  path: /tmp/snapd.db
+bv_command_prefix: [sudo, -n, -u, blockvisor]
+blockvisor:
+  source: command
+  schedule: "0 0 */6 * * *"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.Nodes["ethereum-mainnet"].URL != "http://10.0.0.5:8545" {
+		t.Errorf("Unexpected derived node: %+v", config.Nodes["ethereum-mainnet"])
+	}
+	if strings.Join(exec.invoked, " ") != "bv node list --json" {
+		t.Errorf("Expected bv node list, got %q", exec.invoked)
+	}
+	if strings.Join(prefix, " ") != "sudo -n -u blockvisor" {
+		t.Errorf("Expected bv node list through the command prefix, got %q", prefix)
+	}
+}
+
+func TestLoadConfigWithBlockvisorCommandNotAllowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+database:
+  driver: sqlite
+  path: /tmp/snapd.db
+executor:
+  allowed_commands: [rclone]
+blockvisor:
+  source: command
+  schedule: "0 0 */6 * * *"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	// bv node list runs through the executor, so it is refused without ever running bv
+	_, err := LoadConfig(configPath)
+	if !errors.Is(err, executor.ErrCommandNotAllowed) {
+		t.Errorf("Expected bv node list refused by the allow-list, got %v", err)
+	}
+}
+
+func TestMergeNodeConfig(t *testing.T) {
+	base := NodeConfig{Protocol: "ethereum", Type: "archive", Schedule: "0 0 */6 * * *", URL: "http://localhost:8545"}
+	tests := []struct {
+		field    string
+		override NodeConfig
+	}{
+		{field: "Tenant", override: NodeConfig{Tenant: "team-a"}},
+		{field: "Engine", override: NodeConfig{Engine: "rclone"}},
+		{field: "Rclone", override: NodeConfig{Rclone: &RcloneConfig{Source: "/var/lib/node", Destination: "r2:snapshots/{node}"}}},
+		{field: "S3", override: NodeConfig{S3: &S3Config{Source: "/var/lib/node", Bucket: "snapshots"}}},
+		{field: "Compression", override: NodeConfig{Compression: &CompressionConfig{Algorithm: "zstd", Level: 3}}},
+		{field: "Verification", override: NodeConfig{Verification: &VerificationConfig{Restore: []string{"restore.sh"}, RPCURL: "http://localhost:18545"}}},
+		{field: "Splay", override: NodeConfig{Splay: "10m"}},
+		{field: "Container", override: NodeConfig{Container: &ContainerConfig{Name: "geth"}}},
+		{field: "EngineArgs", override: NodeConfig{EngineArgs: map[string]string{"--transfers": "16"}}},
+		{field: "Network", override: NodeConfig{Network: "sepolia"}},
+		{field: "SnapshotLocation", override: NodeConfig{SnapshotLocation: "s3://snapshots/eth-sepolia"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			merged := mergeNodeConfig(base, tt.override)
+			got := reflect.ValueOf(merged).FieldByName(tt.field).Interface()
+			want := reflect.ValueOf(tt.override).FieldByName(tt.field).Interface()
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected override %s %v, got %v", tt.field, want, got)
+			}
+			if merged.Protocol != base.Protocol || merged.URL != base.URL || merged.Schedule != base.Schedule {
+				t.Errorf("Expected derived fields to be kept, got %+v", merged)
+			}
+		})
+	}
+}
+
+// TestMergeNodeConfigCoversEveryField fails when a NodeConfig field is added without
+// mergeNodeConfig applying its override, which would drop the field for
+// blockvisor-derived nodes
+func TestMergeNodeConfigCoversEveryField(t *testing.T) {
+	nodeType := reflect.TypeOf(NodeConfig{})
+	for i := 0; i < nodeType.NumField(); i++ {
+		field := nodeType.Field(i)
+		var override NodeConfig
+		value := reflect.ValueOf(&override).Elem().Field(i)
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString("override")
+		case reflect.Bool:
+			value.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			value.SetInt(1)
+		case reflect.Ptr:
+			value.Set(reflect.New(field.Type.Elem()))
+		case reflect.Slice:
+			value.Set(reflect.MakeSlice(field.Type, 1, 1))
+		case reflect.Map:
+			value.Set(reflect.MakeMap(field.Type))
+			value.SetMapIndex(reflect.Zero(field.Type.Key()), reflect.Zero(field.Type.Elem()))
+		default:
+			t.Fatalf("NodeConfig.%s has kind %s, which this test cannot set", field.Name, value.Kind())
+		}
+
+		merged := mergeNodeConfig(NodeConfig{}, override)
+		if got := reflect.ValueOf(merged).Field(i).Interface(); !reflect.DeepEqual(got, value.Interface()) {
+			t.Errorf("mergeNodeConfig drops the override of NodeConfig.%s", field.Name)
+		}
+	}
+}
+
+func TestLoadConfigWithBlockvisorErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		blockvisor string
+		wantErr    string
+	}{
+		{
+			name:       "invalid source",
+			blockvisor: "  source: api\n",
+			wantErr:    "unsupported blockvisor source",
+		},
+		{
+			name:       "invalid schedule",
+			blockvisor: "  path: /nonexistent\n  schedule: \"invalid\"\n",
+			wantErr:    "invalid blockvisor schedule",
+		},
+		{
+			name:       "missing file",
+			blockvisor: "  path: /nonexistent/blockvisor.json\n",
+			wantErr:    "failed to read blockvisor config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "config.yaml")
+			configContent := "database:\n  driver: sqlite\n  path: /tmp/snapd.db\nblockvisor:\n" + tt.blockvisor
+			if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			_, err := LoadConfig(configPath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -2,9 +2,23 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
-	"github.com/robfig/cron/v3"
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/engine/rclone"
+	"github.com/nodexeus/agent/internal/engine/s3"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/nodexeus/agent/internal/kube"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,81 +32,2039 @@ type NotificationValidator interface {
 	IsRegistered(name string) bool
 }
 
+// NotificationTemplateValidator is implemented by notification validators that can also
+// validate templates, checking the event is known and the title and body parse
+type NotificationTemplateValidator interface {
+	ValidateTemplate(event, title, body string) error
+}
+
 var (
 	protocolValidator     ProtocolValidator
 	notificationValidator NotificationValidator
 )
 
-// SetProtocolValidator sets the protocol validator for configuration validation
-func SetProtocolValidator(validator ProtocolValidator) {
-	protocolValidator = validator
+// SetProtocolValidator sets the protocol validator for configuration validation
+func SetProtocolValidator(validator ProtocolValidator) {
+	protocolValidator = validator
+}
+
+// SetNotificationValidator sets the notification validator for configuration validation
+func SetNotificationValidator(validator NotificationValidator) {
+	notificationValidator = validator
+}
+
+// Config represents the complete daemon configuration
+type Config struct {
+	Schedule              string                `yaml:"schedule"`
+	SecretsFile           string                `yaml:"secrets_file,omitempty"` // YAML file of values referenced as ${NAME}, readable by its owner only
+	Timezone              string                `yaml:"timezone,omitempty"`     // IANA zone schedules are evaluated in, e.g. Europe/Berlin (default the host clock's)
+	BlobRetentionSchedule string                `yaml:"blob_retention_schedule"`
+	StallIntervals        int                   `yaml:"stall_intervals"`        // Monitor runs without chunk progress before an upload is stalled (0 disables)
+	MonitorLagThreshold   string                `yaml:"monitor_lag_threshold"`  // Completion detection lag that triggers a monitor_lag notification (Go duration, empty disables)
+	MaxConcurrentUploads  int                   `yaml:"max_concurrent_uploads"` // Uploads running at once across all nodes; more are queued (0 = unlimited)
+	TriggerDebounce       string                `yaml:"trigger_debounce"`       // Window in which repeated upload requests for a node join its last upload (Go duration, 0s disables)
+	MetricConcurrency     int                   `yaml:"metric_concurrency"`     // Protocol metric collections running at once across nodes (0 = 8)
+	MetricTimeout         string                `yaml:"metric_timeout"`         // Limit on one node's protocol metric collection (Go duration, default 30s)
+	MaxSnapshotAge        string                `yaml:"max_snapshot_age"`       // Age of a node's last successful upload that triggers a stale notification (Go duration, empty disables)
+	FreshnessSchedule     string                `yaml:"freshness_schedule"`     // How often snapshot ages are checked against max_snapshot_age
+	VerificationSchedule  string                `yaml:"verification_schedule"`  // How often the latest snapshot of nodes with verification is spot-restored
+	SLO                   *SLOConfig            `yaml:"slo,omitempty"`          // Upload duration and bandwidth objective of every node
+	SLOSchedule           string                `yaml:"slo_schedule"`           // How often SLO compliance is checked for nodes at risk
+	Digest                *DigestConfig         `yaml:"digest,omitempty"`       // Periodic notification summarizing every node's uploads
+	Notifications         *NotificationConfig   `yaml:"notifications"`
+	Database              DatabaseConfig        `yaml:"database"`
+	Blockvisor            *BlockvisorConfig     `yaml:"blockvisor,omitempty"` // Derive nodes from blockvisor (entries in nodes override them)
+	BVStatusRules         BVStatusRulesConfig   `yaml:"bv_status_rules"`
+	BVCommandPrefix       []string              `yaml:"bv_command_prefix,omitempty"` // Wrapper that bv is run through, e.g. [sudo, -n, -u, blockvisor]
+	BVOutputFormat        string                `yaml:"bv_output_format,omitempty"`  // How bv output is read: auto (default) requests JSON where bv supports it, json or text
+	Executor              *ExecutorConfig       `yaml:"executor,omitempty"`          // Commands the daemon may run and the environment they run in
+	ContentListing        ContentListingConfig  `yaml:"content_listing"`             // Record the objects of completed snapshots
+	Snooze                *SnoozeConfig         `yaml:"snooze,omitempty"`            // Snooze links in notifications and the endpoint that serves them
+	LeaderElection        *LeaderElectionConfig `yaml:"leader_election,omitempty"`   // Elect one of several daemons sharing the database to run uploads
+	StartupPolicy         string                `yaml:"startup_policy,omitempty"`    // What failed startup checks do: strict (default) refuses to start, degraded starts with warnings
+	Health                *HealthConfig         `yaml:"health,omitempty"`            // HTTP endpoint reporting the startup self-check
+	NodeAPI               *NodeAPIConfig        `yaml:"node_api,omitempty"`          // HTTP API registering and deregistering nodes at runtime
+	SummaryAPI            *SummaryAPIConfig     `yaml:"summary_api,omitempty"`       // HTTP endpoint serving a JSON summary for external pollers
+	Metrics               *MetricsConfig        `yaml:"metrics,omitempty"`           // HTTP endpoint exporting snapshot sizes as Prometheus metrics
+	Tracing               *TracingConfig        `yaml:"tracing,omitempty"`           // Export OpenTelemetry traces of the upload workflow over OTLP
+	DatabaseNodes         *DatabaseNodesConfig  `yaml:"database_nodes,omitempty"`    // Take node definitions from the database instead of nodes
+	Kubernetes            *KubernetesConfig     `yaml:"kubernetes,omitempty"`        // Discover nodes from the annotations of Kubernetes pods
+	Guardrails            *GuardrailsConfig     `yaml:"guardrails,omitempty"`        // Throttle or pause uploads when the host runs short of CPU, memory or disk I/O
+	Output                *OutputConfig         `yaml:"output,omitempty"`            // Default columns and color of the status and history commands
+	Nodes                 map[string]NodeConfig `yaml:"nodes"`
+	// ConsistencyGroups are sets of nodes whose uploads are started together
+	ConsistencyGroups map[string]ConsistencyGroupConfig `yaml:"consistency_groups,omitempty"`
+	// RegisteredNodesOverride lets a node registered at runtime replace the nodes entry of
+	// the same name, which runs again once the registered node is removed
+	RegisteredNodesOverride bool `yaml:"registered_nodes_override,omitempty"`
+	// Tenants are the teams sharing the daemon and its database, by name. Nodes name their
+	// tenant, whose uploads are recorded under it.
+	Tenants map[string]TenantConfig `yaml:"tenants,omitempty"`
+}
+
+// TenantConfig is a team whose nodes share the daemon with other teams. A tenant's token
+// scopes the node API and the summary API to the tenant's nodes and uploads, and its
+// notifications are sent for the tenant's nodes that have none of their own.
+type TenantConfig struct {
+	Token         string              `yaml:"token,omitempty"`         // Bearer token of the tenant on the node and summary APIs (at least 16 characters)
+	Notifications *NotificationConfig `yaml:"notifications,omitempty"` // Notifications of the tenant's nodes, replacing the global ones
+}
+
+// Validate validates the tenant settings
+func (t *TenantConfig) Validate() error {
+	if t.Token != "" && len(t.Token) < MinNodeAPITokenLength {
+		return fmt.Errorf("token must be at least %d characters", MinNodeAPITokenLength)
+	}
+	if t.Notifications != nil {
+		if err := t.Notifications.Validate(); err != nil {
+			return fmt.Errorf("invalid notifications config: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConsistencyGroupConfig defines nodes whose snapshots must be mutually consistent, such
+// as the execution and consensus clients of one Ethereum node. The group's schedule
+// replaces the members' own schedules: on each run the daemon collects every member's
+// metrics at the same moment, waits until all members reach finality when configured,
+// and starts all uploads together, recording them as one group run.
+type ConsistencyGroupConfig struct {
+	Nodes    []string `yaml:"nodes"`    // Member nodes (at least two)
+	Schedule string   `yaml:"schedule"` // Upload schedule for the whole group
+}
+
+// Validate validates the consistency group configuration
+func (g *ConsistencyGroupConfig) Validate() error {
+	if len(g.Nodes) < 2 {
+		return fmt.Errorf("at least two nodes are required")
+	}
+	seen := make(map[string]bool, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if seen[node] {
+			return fmt.Errorf("node %s is listed twice", node)
+		}
+		seen[node] = true
+	}
+	if g.Schedule == "" {
+		return fmt.Errorf("schedule is required")
+	}
+	if err := validateCronSchedule(g.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	return nil
+}
+
+// NodeConfig represents a single node's configuration
+type NodeConfig struct {
+	Protocol      string              `yaml:"protocol"`
+	Type          string              `yaml:"type"`
+	Schedule      string              `yaml:"schedule"`
+	URL           string              `yaml:"url"`
+	Notifications *NotificationConfig `yaml:"notifications,omitempty"`
+	Metadata      map[string]string   `yaml:"metadata,omitempty"`       // Static labels (operator, datacenter, ...) attached to uploads and notifications
+	MaxDuration   string              `yaml:"max_duration,omitempty"`   // Maximum upload run time (Go duration, e.g. "12h")
+	CancelStalled bool                `yaml:"cancel_stalled,omitempty"` // Stop the bv upload job when max_duration is exceeded
+	// WaitForFinality delays upload initiation until the finalized head reaches the scheduled snapshot block
+	WaitForFinality bool   `yaml:"wait_for_finality,omitempty"`
+	FinalityTimeout string `yaml:"finality_timeout,omitempty"` // Maximum finality wait (Go duration, default 30m)
+	CatchUp         bool   `yaml:"catch_up,omitempty"`         // Run a scheduled upload missed while the daemon was stopped at startup
+	RunOnStart      bool   `yaml:"run_on_start,omitempty"`     // Run an upload once whenever the daemon starts, besides the schedule
+	// Enabled set to false stops the node's scheduled uploads, like 'snapperd pause', while
+	// keeping it monitored (default true)
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Splay delays the node's scheduled runs by up to this long (Go duration, e.g. "15m"),
+	// by an offset derived from the node name, so nodes sharing a schedule start staggered
+	Splay string `yaml:"splay,omitempty"`
+	// Timezone overrides the global timezone the node's schedule is evaluated in
+	Timezone string `yaml:"timezone,omitempty"`
+	// Metrics declares the JSON-RPC queries collected by the generic protocol module
+	Metrics []MetricQueryConfig `yaml:"metrics,omitempty"`
+	// Incremental uploads only the objects changed since the last recorded snapshot
+	Incremental *IncrementalConfig `yaml:"incremental,omitempty"`
+	Priority    int                `yaml:"priority,omitempty"` // Upload queue priority, higher is dequeued first
+	// MaxSnapshotAge overrides the global max_snapshot_age for this node
+	MaxSnapshotAge string `yaml:"max_snapshot_age,omitempty"`
+	// SLO overrides the global upload objective for this node
+	SLO *SLOConfig `yaml:"slo,omitempty"`
+	// Validation rejects uploads whose collected metrics look implausible
+	Validation *MetricValidationConfig `yaml:"validation,omitempty"`
+	// Preflight gates the node must pass before an upload is started
+	Preflight *PreflightConfig `yaml:"preflight,omitempty"`
+	// Hooks are shell commands run before and after the node's uploads
+	Hooks *HooksConfig `yaml:"hooks,omitempty"`
+	// Verification spot-restores the node's latest snapshot to check that it loads
+	Verification *VerificationConfig `yaml:"verification,omitempty"`
+	// Engine runs the node's uploads: bv (default), rclone or s3
+	Engine string        `yaml:"engine,omitempty"`
+	Rclone *RcloneConfig `yaml:"rclone,omitempty"` // Transfer settings of the rclone engine
+	S3     *S3Config     `yaml:"s3,omitempty"`     // Upload settings of the s3 engine
+	// EngineArgs are passed through to the engine: extra flags of bv node run upload or
+	// of the rclone transfer, as "--name=value", or headers of the s3 engine's archive
+	EngineArgs map[string]string `yaml:"engine_args,omitempty"`
+	// Compression sets how the engine compresses the uploaded data (s3 engine only)
+	Compression *CompressionConfig `yaml:"compression,omitempty"`
+	// Container runs the node's hooks, preflight command and rclone transfer inside the
+	// container of its chain client
+	Container *ContainerConfig `yaml:"container,omitempty"`
+	// Tenant is the team the node belongs to, one of tenants
+	Tenant string `yaml:"tenant,omitempty"`
+	// Network is the chain network the node follows, e.g. "mainnet", which with the
+	// protocol and type keys the node's snapshots in the catalog
+	Network string `yaml:"network,omitempty"`
+	// SnapshotLocation is where the node's snapshots are published, recorded in the catalog
+	// when the engine does not report one, as with bv
+	SnapshotLocation string `yaml:"snapshot_location,omitempty"`
+	// Retry starts the node's failed uploads again after an exponential backoff instead of
+	// waiting for the next scheduled run
+	Retry *RetryConfig `yaml:"retry,omitempty"`
+	// CircuitBreaker stops scheduling the node after consecutive failed uploads, until a
+	// probe upload succeeds or an operator resumes it
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker,omitempty"`
+	// MetricRetry retries the node's failed protocol metric queries after a backoff and
+	// limits each attempt to a query timeout
+	MetricRetry *MetricRetryConfig `yaml:"metric_retry,omitempty"`
+}
+
+// compressionLevels are the levels accepted for each compression algorithm
+var compressionLevels = map[string]struct{ min, max int }{
+	engine.CompressionGzip: {1, 9},
+	engine.CompressionZstd: {1, 19},
+	engine.CompressionLz4:  {1, 12},
+	engine.CompressionNone: {0, 0},
+}
+
+// CompressionConfig sets how a node's engine compresses the data it uploads. zstd and
+// lz4 are run through the tools of the same name, which must be installed.
+type CompressionConfig struct {
+	Algorithm string `yaml:"algorithm"`       // zstd, lz4, gzip or none
+	Level     int    `yaml:"level,omitempty"` // gzip 1-9, zstd 1-19, lz4 1-12 (default the algorithm's own)
+}
+
+// Validate validates the compression settings
+func (c *CompressionConfig) Validate() error {
+	levels, ok := compressionLevels[c.Algorithm]
+	if !ok {
+		return fmt.Errorf("invalid algorithm '%s': must be zstd, lz4, gzip or none", c.Algorithm)
+	}
+	if c.Level != 0 && (c.Level < levels.min || c.Level > levels.max) {
+		if c.Algorithm == engine.CompressionNone {
+			return fmt.Errorf("level cannot be set without compression")
+		}
+		return fmt.Errorf("invalid level %d: %s accepts %d to %d", c.Level, c.Algorithm, levels.min, levels.max)
+	}
+	return nil
+}
+
+// RcloneConfig describes the transfer run by the rclone engine, for hosts not managed
+// by blockvisor. "{node}" in the source and destination is replaced with the node name.
+type RcloneConfig struct {
+	Source      string   `yaml:"source"`           // Local data directory to upload
+	Destination string   `yaml:"destination"`      // rclone remote path, e.g. s3:snapshots/{node}
+	Mode        string   `yaml:"mode,omitempty"`   // sync (default) or copy
+	Flags       []string `yaml:"flags,omitempty"`  // Additional rclone flags, e.g. ["--transfers", "16"]
+	Binary      string   `yaml:"binary,omitempty"` // rclone binary (default "rclone")
+	// LogDir is the directory rclone writes its log to (default a snapperd-rclone
+	// directory in the temporary directory), inside the container when rclone runs in one
+	LogDir string `yaml:"log_dir,omitempty"`
+}
+
+// Validate validates the rclone transfer configuration
+func (r *RcloneConfig) Validate() error {
+	if r.Source == "" {
+		return fmt.Errorf("source is required")
+	}
+	if r.Destination == "" {
+		return fmt.Errorf("destination is required")
+	}
+	switch r.Mode {
+	case "", rclone.ModeSync, rclone.ModeCopy:
+	default:
+		return fmt.Errorf("invalid mode '%s': must be sync or copy", r.Mode)
+	}
+	if r.LogDir != "" && !filepath.IsAbs(r.LogDir) {
+		return fmt.Errorf("log_dir must be an absolute path")
+	}
+	return nil
+}
+
+// ContainerConfig names the running container a node's commands are run in, through
+// the CLI of its container runtime, for chain clients run in containers on hosts
+// without bv. With the kubernetes runtime, set on nodes discovered from pods, commands
+// are run through the Kubernetes exec API instead. The container needs sh and env(1).
+type ContainerConfig struct {
+	Name       string `yaml:"name"`                  // Container name or ID (with kubernetes, empty for the pod's first container)
+	Runtime    string `yaml:"runtime,omitempty"`     // docker (default), podman, nerdctl, ctr or kubernetes
+	Namespace  string `yaml:"namespace,omitempty"`   // containerd namespace for nerdctl and ctr, or the pod's namespace
+	Pod        string `yaml:"pod,omitempty"`         // Pod the container belongs to, for kubernetes
+	User       string `yaml:"user,omitempty"`        // User the commands run as (default the container's)
+	WorkingDir string `yaml:"working_dir,omitempty"` // Directory the commands run in (default the container's)
+}
+
+// Validate validates the container configuration
+func (c *ContainerConfig) Validate() error {
+	if c.GetRuntime() == kube.RuntimeKubernetes {
+		if c.Pod == "" {
+			return fmt.Errorf("pod is required with runtime kubernetes")
+		}
+		// The exec API runs commands as the container's user in its working directory
+		if c.User != "" || c.WorkingDir != "" {
+			return fmt.Errorf("user and working_dir are not supported with runtime kubernetes")
+		}
+		return nil
+	}
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.Pod != "" {
+		return fmt.Errorf("pod requires runtime kubernetes")
+	}
+	switch c.GetRuntime() {
+	case executor.RuntimeDocker, executor.RuntimePodman:
+		if c.Namespace != "" {
+			return fmt.Errorf("namespace requires runtime nerdctl, ctr or kubernetes")
+		}
+	case executor.RuntimeNerdctl, executor.RuntimeCtr:
+	default:
+		return fmt.Errorf("invalid runtime '%s': must be docker, podman, nerdctl, ctr or kubernetes", c.Runtime)
+	}
+	if c.WorkingDir != "" && !path.IsAbs(c.WorkingDir) {
+		return fmt.Errorf("working_dir must be an absolute path")
+	}
+	return nil
+}
+
+// GetRuntime returns the container runtime whose CLI runs the commands (default docker)
+func (c *ContainerConfig) GetRuntime() string {
+	if c.Runtime == "" {
+		return executor.RuntimeDocker
+	}
+	return c.Runtime
+}
+
+// Executor returns the container as the executor package describes it
+func (c *ContainerConfig) Executor() executor.Container {
+	return executor.Container{
+		Runtime:    c.GetRuntime(),
+		Name:       c.Name,
+		Namespace:  c.Namespace,
+		User:       c.User,
+		WorkingDir: c.WorkingDir,
+	}
+}
+
+// S3Config describes the archive uploaded by the s3 engine, for hosts not managed by
+// blockvisor. "{node}" in the source and key is replaced with the node name and
+// "{timestamp}" in the key with the upload's start time. Credentials are read from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type S3Config struct {
+	Source      string `yaml:"source"`                // Local data directory to archive
+	Bucket      string `yaml:"bucket"`                // Destination bucket
+	Key         string `yaml:"key,omitempty"`         // Object key (default {node}/{timestamp}.tar.gz)
+	Endpoint    string `yaml:"endpoint,omitempty"`    // S3-compatible endpoint URL (default AWS for the region)
+	Region      string `yaml:"region,omitempty"`      // Signing region (default us-east-1)
+	PathStyle   bool   `yaml:"path_style,omitempty"`  // Address the bucket in the URL path, as MinIO requires
+	PartSize    string `yaml:"part_size,omitempty"`   // Multipart part size, e.g. "128MiB" (default 64MiB)
+	Concurrency int    `yaml:"concurrency,omitempty"` // Parts uploaded at once (default 4)
+	Compression string `yaml:"compression,omitempty"` // gzip (default), zstd, lz4 or none; the node's compression section also sets a level
+	// Encryption encrypts the archive with AES-256-GCM before it leaves the host
+	Encryption *S3EncryptionConfig `yaml:"encryption,omitempty"`
+}
+
+// S3EncryptionConfig sets the key the s3 engine encrypts archives with: a key file or an
+// AWS KMS key generating a data key per upload
+type S3EncryptionConfig struct {
+	KeyFile     string `yaml:"key_file,omitempty"`     // File with a 256-bit key: 32 raw bytes, hex or base64
+	KeyID       string `yaml:"key_id,omitempty"`       // Identifies the key file's key in the manifest (default its fingerprint)
+	KMSKeyID    string `yaml:"kms_key_id,omitempty"`   // AWS KMS key ID, ARN or alias
+	KMSEndpoint string `yaml:"kms_endpoint,omitempty"` // KMS endpoint URL (default AWS for the region)
+}
+
+// Validate validates the encryption settings
+func (e *S3EncryptionConfig) Validate() error {
+	switch {
+	case e.KeyFile == "" && e.KMSKeyID == "":
+		return fmt.Errorf("key_file or kms_key_id is required")
+	case e.KeyFile != "" && e.KMSKeyID != "":
+		return fmt.Errorf("key_file and kms_key_id cannot both be set")
+	case e.KeyID != "" && e.KMSKeyID != "":
+		return fmt.Errorf("key_id cannot be set with kms_key_id, which identifies the key")
+	case e.KMSEndpoint != "" && e.KMSKeyID == "":
+		return fmt.Errorf("kms_endpoint requires kms_key_id")
+	}
+	if e.KMSEndpoint != "" {
+		u, err := url.Parse(e.KMSEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid kms_endpoint '%s': must be an http or https URL", e.KMSEndpoint)
+		}
+	}
+	return nil
+}
+
+// Validate validates the s3 upload configuration
+func (s *S3Config) Validate() error {
+	if s.Source == "" {
+		return fmt.Errorf("source is required")
+	}
+	if s.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if s.Endpoint != "" {
+		u, err := url.Parse(s.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint '%s': must be an http or https URL", s.Endpoint)
+		}
+	}
+	if _, err := s.GetPartSize(); err != nil {
+		return err
+	}
+	if s.Concurrency < 0 {
+		return fmt.Errorf("concurrency cannot be negative")
+	}
+	if _, ok := compressionLevels[s.Compression]; s.Compression != "" && !ok {
+		return fmt.Errorf("invalid compression '%s': must be gzip, zstd, lz4 or none", s.Compression)
+	}
+	if s.Encryption != nil {
+		if err := s.Encryption.Validate(); err != nil {
+			return fmt.Errorf("invalid encryption: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPartSize returns the multipart part size in bytes (default 64MiB)
+func (s *S3Config) GetPartSize() (int64, error) {
+	if s.PartSize == "" {
+		return s3.DefaultPartSize, nil
+	}
+	size, ok := parseSize(s.PartSize)
+	if !ok || size < s3.MinPartSize || size > s3.MaxPartSize {
+		return 0, fmt.Errorf("invalid part_size '%s': must be between 5MiB and 5GiB", s.PartSize)
+	}
+	return int64(size), nil
+}
+
+// DefaultPreflightDiskPath is the filesystem checked by min_free_disk when disk_path is not set
+const DefaultPreflightDiskPath = "/var/lib/blockvisor"
+
+// PreflightConfig declares health gates checked before a node's upload is started.
+// Uploading a node that is unreachable, still syncing or short on disk produces a
+// useless snapshot, so a run with a failed gate starts no upload.
+type PreflightConfig struct {
+	RPC            bool     `yaml:"rpc,omitempty"`             // Metric collection must succeed and report latest_block
+	Synced         bool     `yaml:"synced,omitempty"`          // The node must report it is not syncing
+	MinFreeDisk    string   `yaml:"min_free_disk,omitempty"`   // Free space required on disk_path, in bytes ("500GB", "1.5TiB") or percent ("10%")
+	DiskPath       string   `yaml:"disk_path,omitempty"`       // Filesystem checked by min_free_disk (default /var/lib/blockvisor)
+	Command        []string `yaml:"command,omitempty"`         // Custom check that must exit 0; "{node}" in the arguments is replaced
+	CommandTimeout string   `yaml:"command_timeout,omitempty"` // Maximum command run time (Go duration, default 1m)
+}
+
+// Validate validates the preflight gates
+func (p *PreflightConfig) Validate() error {
+	if p.MinFreeDisk != "" {
+		if _, _, err := p.GetMinFreeDisk(); err != nil {
+			return err
+		}
+	}
+	if len(p.Command) > 0 && strings.TrimSpace(p.Command[0]) == "" {
+		return fmt.Errorf("command cannot be empty")
+	}
+	if p.CommandTimeout != "" {
+		timeout, err := time.ParseDuration(p.CommandTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid command_timeout '%s': %w", p.CommandTimeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("command_timeout must be positive")
+		}
+	}
+	return nil
+}
+
+// DefaultHookTimeout is the maximum run time of each hook command when timeout is not set
+const DefaultHookTimeout = 5 * time.Minute
+
+// HooksConfig defines shell commands run around a node's uploads, for example to pause
+// compaction before a snapshot and resume it afterwards. Commands run with sh -c and get
+// NODE_NAME, PROTOCOL and HOOK_STAGE in their environment; post_upload commands also get
+// UPLOAD_ID and UPLOAD_STATUS.
+type HooksConfig struct {
+	PreUpload  []string `yaml:"pre_upload,omitempty"`  // Run in order before the upload starts; a failure cancels the upload
+	PostUpload []string `yaml:"post_upload,omitempty"` // Run in order once the upload ends, whatever its outcome
+	Timeout    string   `yaml:"timeout,omitempty"`     // Maximum run time of each command (Go duration, default 5m)
+}
+
+// Validate validates the hook commands
+func (h *HooksConfig) Validate() error {
+	for _, command := range append(append([]string{}, h.PreUpload...), h.PostUpload...) {
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("hook commands cannot be empty")
+		}
+	}
+	if h.Timeout != "" {
+		timeout, err := time.ParseDuration(h.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %w", h.Timeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+	}
+	return nil
+}
+
+// GetTimeout returns the maximum run time of each hook command (default 5 minutes)
+func (h *HooksConfig) GetTimeout() time.Duration {
+	if h.Timeout == "" {
+		return DefaultHookTimeout
+	}
+
+	timeout, err := time.ParseDuration(h.Timeout)
+	if err != nil {
+		return DefaultHookTimeout
+	}
+
+	return timeout
+}
+
+// Restore verification defaults
+const (
+	DefaultVerificationTimeout        = 6 * time.Hour
+	DefaultVerificationStartupTimeout = 10 * time.Minute
+)
+
+// VerificationConfig declares how a node's latest completed snapshot is spot-restored
+// into a scratch location or scratch bv node, whose client must start and report the
+// snapshot's block. Commands run with sh -c and get NODE_NAME, PROTOCOL, UPLOAD_ID,
+// SNAPSHOT_BLOCK (when recorded) and SCRATCH_DIR in their environment.
+type VerificationConfig struct {
+	Restore        []string `yaml:"restore"`                   // Download the snapshot and start the client, run in order; a failure fails the verification
+	Cleanup        []string `yaml:"cleanup,omitempty"`         // Stop the client and remove its data, always run
+	RPCURL         string   `yaml:"rpc_url"`                   // RPC endpoint of the restored client, queried with the node's protocol module
+	ScratchDir     string   `yaml:"scratch_dir,omitempty"`     // Directory for the restored data; "{node}" is replaced (default <tmp>/snapperd-verify/{node})
+	Timeout        string   `yaml:"timeout,omitempty"`         // Maximum run time of the restore commands (Go duration, default 6h)
+	StartupTimeout string   `yaml:"startup_timeout,omitempty"` // How long the client has to report the block (Go duration, default 10m)
+	BlockTolerance int64    `yaml:"block_tolerance,omitempty"` // Blocks the client may report below the snapshot's latest_block
+}
+
+// Validate validates the restore verification settings
+func (v *VerificationConfig) Validate() error {
+	if len(v.Restore) == 0 {
+		return fmt.Errorf("at least one restore command is required")
+	}
+	for _, command := range append(append([]string{}, v.Restore...), v.Cleanup...) {
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("commands cannot be empty")
+		}
+	}
+	if v.RPCURL == "" {
+		return fmt.Errorf("rpc_url is required")
+	}
+	if _, err := url.ParseRequestURI(v.RPCURL); err != nil {
+		return fmt.Errorf("invalid rpc_url: %w", err)
+	}
+	for name, value := range map[string]string{"timeout": v.Timeout, "startup_timeout": v.StartupTimeout} {
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", name, value, err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+	if v.BlockTolerance < 0 {
+		return fmt.Errorf("block_tolerance cannot be negative")
+	}
+	return nil
+}
+
+// GetTimeout returns the maximum run time of the restore commands (default 6 hours)
+func (v *VerificationConfig) GetTimeout() time.Duration {
+	timeout, err := time.ParseDuration(v.Timeout)
+	if v.Timeout == "" || err != nil {
+		return DefaultVerificationTimeout
+	}
+	return timeout
+}
+
+// GetStartupTimeout returns how long the restored client has to report the snapshot's
+// block (default 10 minutes)
+func (v *VerificationConfig) GetStartupTimeout() time.Duration {
+	timeout, err := time.ParseDuration(v.StartupTimeout)
+	if v.StartupTimeout == "" || err != nil {
+		return DefaultVerificationStartupTimeout
+	}
+	return timeout
+}
+
+// GetScratchDir returns the node's scratch directory
+func (v *VerificationConfig) GetScratchDir(nodeName string) string {
+	dir := v.ScratchDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "snapperd-verify", "{node}")
+	}
+	return strings.ReplaceAll(dir, "{node}", nodeName)
+}
+
+// sizeUnits maps size suffixes to their size in bytes
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3}, {"B", 1},
+}
+
+// GetMinFreeDisk returns the min_free_disk threshold, either as a number of bytes or,
+// for values ending in "%", as a percentage of the filesystem size. Both are 0 when
+// min_free_disk is not set.
+func (p *PreflightConfig) GetMinFreeDisk() (bytes uint64, percent float64, err error) {
+	value := strings.ToUpper(strings.TrimSpace(p.MinFreeDisk))
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "%")), 64)
+		if err != nil || pct <= 0 || pct >= 100 {
+			return 0, 0, fmt.Errorf("invalid min_free_disk '%s': percentage must be between 0 and 100", p.MinFreeDisk)
+		}
+		return 0, pct, nil
+	}
+
+	size, ok := parseSize(value)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid min_free_disk '%s': expected a positive size such as 500GB or a percentage such as 10%%", p.MinFreeDisk)
+	}
+	return size, 0, nil
+}
+
+// parseSize parses a positive size with a unit, such as "500GB" or "1.5TiB", into bytes
+func parseSize(value string) (uint64, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	for _, unit := range sizeUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		size, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil || size <= 0 {
+			return 0, false
+		}
+		return uint64(size * unit.bytes), true
+	}
+	return 0, false
+}
+
+// GetDiskPath returns the filesystem checked by min_free_disk
+func (p *PreflightConfig) GetDiskPath() string {
+	if p.DiskPath == "" {
+		return DefaultPreflightDiskPath
+	}
+	return p.DiskPath
+}
+
+// GetCommandTimeout returns the maximum run time of the preflight command (default 1 minute)
+func (p *PreflightConfig) GetCommandTimeout() time.Duration {
+	if p.CommandTimeout == "" {
+		return time.Minute
+	}
+
+	timeout, err := time.ParseDuration(p.CommandTimeout)
+	if err != nil {
+		return time.Minute
+	}
+
+	return timeout
+}
+
+// MetricValidationConfig sets sanity thresholds on the metrics collected before an
+// upload. When set, latest_block must be present and positive, and a run whose
+// metrics violate a threshold is not started, so a misbehaving RPC endpoint cannot
+// label a snapshot with bogus chain state.
+type MetricValidationConfig struct {
+	// MaxBlockChange is the largest allowed difference between latest_block and the
+	// previous completed snapshot's latest_block, in either direction (0 = unchecked)
+	MaxBlockChange int64 `yaml:"max_block_change,omitempty"`
+}
+
+// Validate validates the metric validation thresholds
+func (v *MetricValidationConfig) Validate() error {
+	if v.MaxBlockChange < 0 {
+		return fmt.Errorf("max_block_change cannot be negative")
+	}
+	return nil
+}
+
+// IncrementalConfig enables incremental snapshots for a node. Instead of
+// 'bv node run upload', the command is run with the content listing of the base
+// snapshot, the latest completed upload with recorded contents, so a backend that
+// supports it uploads only new or changed objects. Placeholders in the arguments:
+// "{node}", "{base_upload_id}" and "{base_manifest}" (a file in the content listing
+// format). Without a base snapshot a full upload is started.
+type IncrementalConfig struct {
+	Command   []string `yaml:"command"`              // Incremental upload command and arguments
+	FullEvery int      `yaml:"full_every,omitempty"` // Start a full upload after this many consecutive incrementals (0 = never)
+}
+
+// Validate validates the incremental snapshot configuration
+func (i *IncrementalConfig) Validate() error {
+	if len(i.Command) == 0 || strings.TrimSpace(i.Command[0]) == "" {
+		return fmt.Errorf("command is required")
+	}
+	if i.FullEvery < 0 {
+		return fmt.Errorf("full_every cannot be negative")
+	}
+	return nil
+}
+
+// DefaultSLOWindow is the rolling window SLO compliance is computed over when window is
+// not set
+const DefaultSLOWindow = 30 * 24 * time.Hour
+
+// SLOConfig is an upload service level objective, such as 95% of uploads completing
+// within 8h. Compliance is the share of the uploads started within the rolling window
+// that completed within max_duration and averaged at least min_bandwidth; failed and
+// cancelled uploads miss the objective.
+type SLOConfig struct {
+	Target       float64 `yaml:"target"`                  // Percent of uploads that must meet the objective, e.g. 95
+	MaxDuration  string  `yaml:"max_duration,omitempty"`  // Uploads complete within this long (Go duration, e.g. "8h")
+	MinBandwidth string  `yaml:"min_bandwidth,omitempty"` // Uploads average at least this rate, e.g. "50MB/s"
+	Window       string  `yaml:"window,omitempty"`        // Rolling window (Go duration, default 720h)
+}
+
+// Validate validates the SLO configuration
+func (s *SLOConfig) Validate() error {
+	if s.Target <= 0 || s.Target > 100 {
+		return fmt.Errorf("target must be a percentage above 0 and at most 100")
+	}
+	if s.MaxDuration == "" && s.MinBandwidth == "" {
+		return fmt.Errorf("max_duration or min_bandwidth is required")
+	}
+	if s.MaxDuration != "" {
+		maxDuration, err := time.ParseDuration(s.MaxDuration)
+		if err != nil {
+			return fmt.Errorf("invalid max_duration '%s': %w", s.MaxDuration, err)
+		}
+		if maxDuration <= 0 {
+			return fmt.Errorf("max_duration must be positive")
+		}
+	}
+	if s.MinBandwidth != "" {
+		if _, ok := parseBandwidth(s.MinBandwidth); !ok {
+			return fmt.Errorf("invalid min_bandwidth '%s': expected a positive rate such as 50MB/s", s.MinBandwidth)
+		}
+	}
+	if s.Window != "" {
+		window, err := time.ParseDuration(s.Window)
+		if err != nil {
+			return fmt.Errorf("invalid window '%s': %w", s.Window, err)
+		}
+		if window <= 0 {
+			return fmt.Errorf("window must be positive")
+		}
+	}
+	return nil
+}
+
+// GetMaxDuration returns the duration uploads must complete within, or 0 if not set
+func (s *SLOConfig) GetMaxDuration() time.Duration {
+	maxDuration, err := time.ParseDuration(s.MaxDuration)
+	if err != nil {
+		return 0
+	}
+	return maxDuration
+}
+
+// GetMinBandwidth returns the bytes per second uploads must average, or 0 if not set
+func (s *SLOConfig) GetMinBandwidth() float64 {
+	bytes, ok := parseBandwidth(s.MinBandwidth)
+	if !ok {
+		return 0
+	}
+	return float64(bytes)
+}
+
+// parseBandwidth parses a rate such as "50MB/s" into bytes per second
+func parseBandwidth(value string) (uint64, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	return parseSize(strings.TrimSuffix(value, "/S"))
+}
+
+// GetWindow returns the rolling window compliance is computed over (default 30 days)
+func (s *SLOConfig) GetWindow() time.Duration {
+	window, err := time.ParseDuration(s.Window)
+	if err != nil || window <= 0 {
+		return DefaultSLOWindow
+	}
+	return window
+}
+
+// MaxRetryAttempts bounds max_attempts, so a node that keeps failing cannot retry for days
+const MaxRetryAttempts = 10
+
+// RetryConfig retries a node's failed uploads. The first retry starts backoff after the
+// failure, and each later one waits twice as long as the one before, until an upload has
+// been attempted max_attempts times.
+type RetryConfig struct {
+	MaxAttempts int    `yaml:"max_attempts"` // Attempts of an upload, the first included (2-10)
+	Backoff     string `yaml:"backoff"`      // Delay before the first retry (Go duration, e.g. "5m")
+}
+
+// Validate validates the retry policy
+func (r *RetryConfig) Validate() error {
+	if r.MaxAttempts < 2 || r.MaxAttempts > MaxRetryAttempts {
+		return fmt.Errorf("max_attempts must be between 2 and %d", MaxRetryAttempts)
+	}
+	backoff, err := time.ParseDuration(r.Backoff)
+	if err != nil {
+		return fmt.Errorf("invalid backoff '%s': %w", r.Backoff, err)
+	}
+	if backoff <= 0 {
+		return fmt.Errorf("backoff must be positive")
+	}
+	return nil
+}
+
+// Delay returns how long after attempt failed the next attempt starts, doubling the
+// backoff for each retry already made
+func (r *RetryConfig) Delay(attempt int) time.Duration {
+	backoff, err := time.ParseDuration(r.Backoff)
+	if err != nil || backoff <= 0 {
+		return 0
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// CircuitBreakerConfig opens a node's circuit once its last failures uploads have failed
+// in a row: its scheduled runs are skipped like those of a paused node. After
+// half_open_after the next scheduled run probes the node with one upload; without it the
+// circuit stays open until 'snapperd resume'.
+type CircuitBreakerConfig struct {
+	Failures      int    `yaml:"failures"`                  // Consecutive failed uploads that open the circuit
+	HalfOpenAfter string `yaml:"half_open_after,omitempty"` // How long the circuit stays open before a probe (Go duration, e.g. "6h")
+}
+
+// Validate validates the circuit breaker
+func (c *CircuitBreakerConfig) Validate() error {
+	if c.Failures < 1 {
+		return fmt.Errorf("failures must be at least 1")
+	}
+	if c.HalfOpenAfter != "" {
+		halfOpenAfter, err := time.ParseDuration(c.HalfOpenAfter)
+		if err != nil {
+			return fmt.Errorf("invalid half_open_after '%s': %w", c.HalfOpenAfter, err)
+		}
+		if halfOpenAfter <= 0 {
+			return fmt.Errorf("half_open_after must be positive")
+		}
+	}
+	return nil
+}
+
+// GetHalfOpenAfter returns how long the circuit stays open before a probe upload, or 0
+// when only 'snapperd resume' closes it
+func (c *CircuitBreakerConfig) GetHalfOpenAfter() time.Duration {
+	if c.HalfOpenAfter == "" {
+		return 0
+	}
+	halfOpenAfter, err := time.ParseDuration(c.HalfOpenAfter)
+	if err != nil {
+		return 0
+	}
+	return halfOpenAfter
+}
+
+// MaxMetricRetryAttempts bounds a metric query's attempts, so retries stay well within
+// the metric_timeout of a node's collection
+const MaxMetricRetryAttempts = 10
+
+// DefaultMetricRetryBackoff is the delay before a metric query's first retry
+const DefaultMetricRetryBackoff = 500 * time.Millisecond
+
+// MetricRetryConfig retries a node's failed protocol metric queries. The first retry starts
+// backoff after the failure, and each later one waits twice as long as the one before,
+// until the query has been attempted attempts times. All attempts count against the
+// daemon's metric_timeout.
+type MetricRetryConfig struct {
+	Attempts     int    `yaml:"attempts"`                // Attempts of a query, the first included (1-10)
+	Backoff      string `yaml:"backoff,omitempty"`       // Delay before the first retry (Go duration, default 500ms)
+	QueryTimeout string `yaml:"query_timeout,omitempty"` // Limit on one attempt of a query (Go duration, default none)
+}
+
+// Validate validates the metric retry policy
+func (m *MetricRetryConfig) Validate() error {
+	if m.Attempts < 1 || m.Attempts > MaxMetricRetryAttempts {
+		return fmt.Errorf("attempts must be between 1 and %d", MaxMetricRetryAttempts)
+	}
+	if m.Backoff != "" {
+		backoff, err := time.ParseDuration(m.Backoff)
+		if err != nil {
+			return fmt.Errorf("invalid backoff '%s': %w", m.Backoff, err)
+		}
+		if backoff <= 0 {
+			return fmt.Errorf("backoff must be positive")
+		}
+	}
+	if m.QueryTimeout != "" {
+		queryTimeout, err := time.ParseDuration(m.QueryTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid query_timeout '%s': %w", m.QueryTimeout, err)
+		}
+		if queryTimeout <= 0 {
+			return fmt.Errorf("query_timeout must be positive")
+		}
+	}
+	return nil
+}
+
+// Delay returns how long after attempt failed the next attempt starts, doubling the
+// backoff for each retry already made
+func (m *MetricRetryConfig) Delay(attempt int) time.Duration {
+	backoff := DefaultMetricRetryBackoff
+	if m.Backoff != "" {
+		parsed, err := time.ParseDuration(m.Backoff)
+		if err != nil || parsed <= 0 {
+			return 0
+		}
+		backoff = parsed
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// GetQueryTimeout returns the limit on one attempt of a query, or 0 when attempts are
+// only limited by the collection's metric_timeout
+func (m *MetricRetryConfig) GetQueryTimeout() time.Duration {
+	if m.QueryTimeout == "" {
+		return 0
+	}
+	queryTimeout, err := time.ParseDuration(m.QueryTimeout)
+	if err != nil {
+		return 0
+	}
+	return queryTimeout
+}
+
+// Digest periods
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestConfig sends one notification summarizing the uploads of every node over the last
+// day or week, for teams that turn off per-event notifications
+type DigestConfig struct {
+	Period   string `yaml:"period,omitempty"`   // daily (default) or weekly
+	Schedule string `yaml:"schedule,omitempty"` // When the digest is sent (default 09:00 daily, or Mondays at 09:00 weekly)
+}
+
+// Validate validates the digest configuration
+func (d *DigestConfig) Validate() error {
+	switch d.Period {
+	case "", DigestDaily, DigestWeekly:
+	default:
+		return fmt.Errorf("invalid period '%s': must be %s or %s", d.Period, DigestDaily, DigestWeekly)
+	}
+	if d.Schedule != "" {
+		if err := validateCronSchedule(d.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPeriod returns how far back the digest summarizes uploads
+func (d *DigestConfig) GetPeriod() time.Duration {
+	if d.Period == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// GetSchedule returns when the digest is sent
+func (d *DigestConfig) GetSchedule() string {
+	switch {
+	case d.Schedule != "":
+		return d.Schedule
+	case d.Period == DigestWeekly:
+		return "0 0 9 * * 1" // Mondays at 09:00
+	default:
+		return "0 0 9 * * *" // Daily at 09:00
+	}
+}
+
+// GenericProtocol is the protocol whose metrics are declared in the node configuration
+const GenericProtocol = "generic"
+
+// MetricQueryConfig declares a JSON-RPC query whose result is stored as a protocol metric
+type MetricQueryConfig struct {
+	Key    string        `yaml:"key"`              // protocol_data key the value is stored under
+	Method string        `yaml:"method"`           // JSON-RPC method, e.g. eth_blockNumber
+	Params []interface{} `yaml:"params,omitempty"` // JSON-RPC params (default: none)
+	Path   string        `yaml:"path"`             // JSONPath into the response, e.g. $.result.number
+	Format string        `yaml:"format,omitempty"` // "hex" or "int" converts the value to an integer
+}
+
+// Validate validates a metric query
+func (m *MetricQueryConfig) Validate() error {
+	if m.Key == "" {
+		return fmt.Errorf("metric key is required")
+	}
+	if m.Method == "" {
+		return fmt.Errorf("method is required for metric %s", m.Key)
+	}
+	if !strings.HasPrefix(m.Path, "$") {
+		return fmt.Errorf("path for metric %s must be a JSONPath starting with '$'", m.Key)
+	}
+	switch m.Format {
+	case "", "hex", "int":
+	default:
+		return fmt.Errorf("unsupported format '%s' for metric %s (expected hex or int)", m.Format, m.Key)
+	}
+	return nil
+}
+
+// NotificationConfig represents notification settings
+type NotificationConfig struct {
+	Failure         bool `yaml:"failure"`
+	Skip            bool `yaml:"skip"`
+	Complete        bool `yaml:"complete"`
+	BlobRetention   bool `yaml:"blob_retention"`
+	Stalled         bool `yaml:"stalled"`
+	MonitorLag      bool `yaml:"monitor_lag"`
+	Stale           bool `yaml:"stale"`
+	Preflight       bool `yaml:"preflight"`
+	SLO             bool `yaml:"slo"`
+	Interrupted     bool `yaml:"interrupted"`                 // The daemon stopped while an upload was running
+	CircuitOpen     bool `yaml:"circuit_open"`                // A node's circuit breaker stopped its scheduled uploads
+	FailureLogLines int  `yaml:"failure_log_lines,omitempty"` // Upload job log lines attached to failure notifications (0 disables)
+	// Templates customize the notifications of events, by event name, for every type
+	Templates map[string]NotificationTemplateConfig `yaml:"templates,omitempty"`
+	Types     map[string]NotificationTypeConfig     `yaml:",inline"`
+}
+
+// MaxFailureLogLines bounds failure_log_lines so log excerpts fit in a notification
+const MaxFailureLogLines = 50
+
+// NotificationTypeConfig represents a single notification type configuration
+type NotificationTypeConfig struct {
+	URL string `yaml:"url"`
+	// Templates customize this type's notifications of events, by event name, over the
+	// notifications-wide templates
+	Templates map[string]NotificationTemplateConfig `yaml:"templates,omitempty"`
+}
+
+// NotificationTemplateConfig sets the title and body of an event's notifications as Go
+// templates executed with the notification payload (see notification.Template). An empty
+// field keeps the less specific template, or the event's default.
+type NotificationTemplateConfig struct {
+	Title string `yaml:"title,omitempty"`
+	Body  string `yaml:"body,omitempty"`
+}
+
+// DefaultSnoozeDurations are the snooze durations offered when durations is not set
+var DefaultSnoozeDurations = []string{"1h", "4h", "24h"}
+
+// DefaultSnoozeLinkTTL is how long snooze links stay valid when link_ttl is not set
+const DefaultSnoozeLinkTTL = 7 * 24 * time.Hour
+
+// MinSnoozeSecretLength is the shortest accepted snooze link signing secret
+const MinSnoozeSecretLength = 16
+
+// SnoozeConfig enables snooze links in notifications. Each link mutes a node's
+// notifications for one of the durations; it points at an HTTP endpoint served by the
+// daemon on listen, under base_url, and is signed with secret so only links sent by
+// the daemon are accepted.
+type SnoozeConfig struct {
+	Listen    string   `yaml:"listen"`              // Address the snooze endpoint listens on, e.g. ":8095"
+	BaseURL   string   `yaml:"base_url"`            // External URL of the endpoint used in links, e.g. https://snapper.example.com
+	Secret    string   `yaml:"secret"`              // Link signing key (at least 16 characters)
+	Durations []string `yaml:"durations,omitempty"` // Offered snooze durations (Go durations, default 1h, 4h and 24h)
+	LinkTTL   string   `yaml:"link_ttl,omitempty"`  // How long a link stays valid after the notification (Go duration, default 168h)
+}
+
+// Validate validates the snooze endpoint settings
+func (s *SnoozeConfig) Validate() error {
+	if s.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+
+	baseURL, err := url.Parse(s.BaseURL)
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		return fmt.Errorf("base_url must be an http or https URL")
+	}
+
+	if len(s.Secret) < MinSnoozeSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", MinSnoozeSecretLength)
+	}
+
+	for _, value := range s.Durations {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration '%s': %w", value, err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("durations must be positive")
+		}
+	}
+
+	if s.LinkTTL != "" {
+		ttl, err := time.ParseDuration(s.LinkTTL)
+		if err != nil {
+			return fmt.Errorf("invalid link_ttl '%s': %w", s.LinkTTL, err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("link_ttl must be positive")
+		}
+	}
+
+	return nil
+}
+
+// GetDurations returns the offered snooze durations (default 1h, 4h and 24h)
+func (s *SnoozeConfig) GetDurations() []time.Duration {
+	values := s.Durations
+	if len(values) == 0 {
+		values = DefaultSnoozeDurations
+	}
+
+	durations := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+			durations = append(durations, duration)
+		}
+	}
+	return durations
+}
+
+// GetLinkTTL returns how long snooze links stay valid (default 7 days)
+func (s *SnoozeConfig) GetLinkTTL() time.Duration {
+	if s.LinkTTL == "" {
+		return DefaultSnoozeLinkTTL
+	}
+
+	ttl, err := time.ParseDuration(s.LinkTTL)
+	if err != nil {
+		return DefaultSnoozeLinkTTL
+	}
+
+	return ttl
+}
+
+// DefaultLeaderElectionName is the lock name used when leader_election.name is not set
+const DefaultLeaderElectionName = "snapperd"
+
+// DefaultLeaderRetryInterval is how often leadership is checked when retry_interval is not set
+const DefaultLeaderRetryInterval = 10 * time.Second
+
+// DefaultLeaseDuration is how long a leader Lease lasts unrenewed when lease_duration is not set
+const DefaultLeaseDuration = 30 * time.Second
+
+// Leader election backends
+const (
+	LeaderBackendPostgres   = "postgres"   // PostgreSQL advisory lock
+	LeaderBackendKubernetes = "kubernetes" // Kubernetes Lease
+)
+
+// LeaderElectionConfig runs several daemons against the same database and nodes for high
+// availability. The daemons elect a leader through a PostgreSQL advisory lock, or a
+// Kubernetes Lease when they run as the replicas of a Deployment, and only the leader
+// starts and monitors uploads. The others stand by and take over within retry_interval
+// once the leader's database session ends, or once its Lease goes unrenewed for
+// lease_duration.
+type LeaderElectionConfig struct {
+	Name          string `yaml:"name,omitempty"`           // Lock or Lease name; daemons sharing it elect one leader (default snapperd)
+	Backend       string `yaml:"backend,omitempty"`        // postgres (default) or kubernetes
+	RetryInterval string `yaml:"retry_interval,omitempty"` // How often standbys try to take over and the leader checks its lock (Go duration, default 10s)
+	LeaseDuration string `yaml:"lease_duration,omitempty"` // How long the Lease lasts unrenewed, for kubernetes (Go duration, default 30s)
+}
+
+// Validate validates the leader election settings
+func (l *LeaderElectionConfig) Validate() error {
+	switch l.GetBackend() {
+	case LeaderBackendPostgres:
+		if l.LeaseDuration != "" {
+			return fmt.Errorf("lease_duration requires backend kubernetes")
+		}
+	case LeaderBackendKubernetes:
+	default:
+		return fmt.Errorf("invalid backend '%s': must be postgres or kubernetes", l.Backend)
+	}
+
+	if l.RetryInterval != "" {
+		interval, err := time.ParseDuration(l.RetryInterval)
+		if err != nil {
+			return fmt.Errorf("invalid retry_interval '%s': %w", l.RetryInterval, err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("retry_interval must be positive")
+		}
+	}
+
+	if l.LeaseDuration != "" {
+		duration, err := time.ParseDuration(l.LeaseDuration)
+		if err != nil {
+			return fmt.Errorf("invalid lease_duration '%s': %w", l.LeaseDuration, err)
+		}
+		if duration < time.Second {
+			return fmt.Errorf("lease_duration must be at least 1s")
+		}
+	}
+	// The leader renews its Lease every retry_interval
+	if l.GetBackend() == LeaderBackendKubernetes && l.GetLeaseDuration() <= l.GetRetryInterval() {
+		return fmt.Errorf("lease_duration must be longer than retry_interval")
+	}
+
+	return nil
+}
+
+// GetBackend returns what the leader is elected through (default postgres)
+func (l *LeaderElectionConfig) GetBackend() string {
+	if l.Backend == "" {
+		return LeaderBackendPostgres
+	}
+	return l.Backend
+}
+
+// GetLeaseDuration returns how long a leader Lease lasts unrenewed (default 30s)
+func (l *LeaderElectionConfig) GetLeaseDuration() time.Duration {
+	if l.LeaseDuration == "" {
+		return DefaultLeaseDuration
+	}
+
+	duration, err := time.ParseDuration(l.LeaseDuration)
+	if err != nil {
+		return DefaultLeaseDuration
+	}
+
+	return duration
+}
+
+// GetName returns the leader lock name (default snapperd)
+func (l *LeaderElectionConfig) GetName() string {
+	if l.Name == "" {
+		return DefaultLeaderElectionName
+	}
+	return l.Name
+}
+
+// GetRetryInterval returns how often leadership is checked (default 10s)
+func (l *LeaderElectionConfig) GetRetryInterval() time.Duration {
+	if l.RetryInterval == "" {
+		return DefaultLeaderRetryInterval
+	}
+
+	interval, err := time.ParseDuration(l.RetryInterval)
+	if err != nil {
+		return DefaultLeaderRetryInterval
+	}
+
+	return interval
+}
+
+// Startup policies
+const (
+	// StartupPolicyStrict refuses to start when any startup check fails
+	StartupPolicyStrict = "strict"
+	// StartupPolicyDegraded starts with warnings when checks the daemon can run without fail
+	StartupPolicyDegraded = "degraded"
+)
+
+// GetStartupPolicy returns the startup policy (default strict)
+func (c *Config) GetStartupPolicy() string {
+	if c.StartupPolicy == "" {
+		return StartupPolicyStrict
+	}
+	return c.StartupPolicy
+}
+
+// HealthConfig enables the HTTP endpoint reporting the daemon's startup self-check
+type HealthConfig struct {
+	Listen string `yaml:"listen"` // Address the health endpoint listens on, e.g. "127.0.0.1:8096"
+}
+
+// Validate validates the health endpoint settings
+func (h *HealthConfig) Validate() error {
+	if h.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+	return nil
+}
+
+// MinNodeAPITokenLength is the shortest accepted node API token
+const MinNodeAPITokenLength = 16
+
+// NodeAPIConfig enables the HTTP API that registers and deregisters nodes at runtime,
+// for environments where nodes are provisioned programmatically. Registered nodes are
+// stored in the database and scheduled like nodes in the configuration file. Requests
+// must send token as a bearer token.
+type NodeAPIConfig struct {
+	Listen string `yaml:"listen"` // Address the node API listens on, e.g. "127.0.0.1:8097"
+	Token  string `yaml:"token"`  // Bearer token required on every request (at least 16 characters)
+}
+
+// Validate validates the node API settings
+func (n *NodeAPIConfig) Validate() error {
+	if n.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+	if len(n.Token) < MinNodeAPITokenLength {
+		return fmt.Errorf("token must be at least %d characters", MinNodeAPITokenLength)
+	}
+	return nil
+}
+
+// SummaryAPIConfig enables the HTTP endpoint serving a compact JSON summary of node
+// freshness, running uploads, recent failures and scheduler health, for external pollers
+// that cannot scrape Prometheus metrics. With a token, requests must send it as a bearer
+// token.
+type SummaryAPIConfig struct {
+	Listen string `yaml:"listen"`          // Address the summary endpoint listens on, e.g. "127.0.0.1:8099"
+	Token  string `yaml:"token,omitempty"` // Optional bearer token (at least 16 characters)
+}
+
+// Validate validates the summary endpoint settings
+func (s *SummaryAPIConfig) Validate() error {
+	if s.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+	if s.Token != "" && len(s.Token) < MinNodeAPITokenLength {
+		return fmt.Errorf("token must be at least %d characters", MinNodeAPITokenLength)
+	}
+	return nil
+}
+
+// MetricsConfig enables the HTTP endpoint exporting each node's latest snapshot size and
+// completion time in the Prometheus text format. With a token, scrapes must send it as a
+// bearer token.
+type MetricsConfig struct {
+	Listen string `yaml:"listen"`          // Address the metrics endpoint listens on, e.g. "127.0.0.1:9105"
+	Token  string `yaml:"token,omitempty"` // Optional bearer token (at least 16 characters)
+}
+
+// Validate validates the metrics endpoint settings
+func (m *MetricsConfig) Validate() error {
+	if m.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+	if m.Token != "" && len(m.Token) < MinNodeAPITokenLength {
+		return fmt.Errorf("token must be at least %d characters", MinNodeAPITokenLength)
+	}
+	return nil
+}
+
+// Tracing defaults
+const (
+	// DefaultTracingServiceName is the service.name of exported spans when service_name is not set
+	DefaultTracingServiceName = "snapperd"
+	// DefaultTracingExportInterval is how often finished spans are exported when export_interval is not set
+	DefaultTracingExportInterval = 5 * time.Second
+)
+
+// TracingConfig exports OpenTelemetry traces of the upload workflow, from the scheduler
+// jobs through metric collection, engine commands, monitor checks and database calls, to
+// a collector such as Jaeger or Tempo over OTLP/HTTP with JSON encoding.
+type TracingConfig struct {
+	Endpoint       string            `yaml:"endpoint"`                  // Collector's OTLP/HTTP URL, e.g. "http://localhost:4318"; /v1/traces is added unless present
+	Headers        map[string]string `yaml:"headers,omitempty"`         // Sent with every export, e.g. Authorization
+	ServiceName    string            `yaml:"service_name,omitempty"`    // service.name of the spans (default snapperd)
+	ExportInterval string            `yaml:"export_interval,omitempty"` // How often finished spans are exported (Go duration, default 5s)
+}
+
+// Validate validates the tracing settings
+func (t *TracingConfig) Validate() error {
+	if t.Endpoint == "" {
+		return fmt.Errorf("endpoint is required")
+	}
+	u, err := url.Parse(t.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint '%s': must be an http or https URL", t.Endpoint)
+	}
+	if t.ExportInterval != "" {
+		interval, err := time.ParseDuration(t.ExportInterval)
+		if err != nil {
+			return fmt.Errorf("invalid export_interval '%s': %w", t.ExportInterval, err)
+		}
+		if interval < time.Second {
+			return fmt.Errorf("export_interval must be at least 1s")
+		}
+	}
+	return nil
+}
+
+// GetTracesURL returns the URL spans are exported to: the endpoint with the OTLP traces
+// path added unless it already ends with it
+func (t *TracingConfig) GetTracesURL() string {
+	endpoint := strings.TrimSuffix(t.Endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+// GetServiceName returns the service.name of exported spans (default snapperd)
+func (t *TracingConfig) GetServiceName() string {
+	if t.ServiceName == "" {
+		return DefaultTracingServiceName
+	}
+	return t.ServiceName
+}
+
+// GetExportInterval returns how often finished spans are exported (default 5s)
+func (t *TracingConfig) GetExportInterval() time.Duration {
+	if t.ExportInterval == "" {
+		return DefaultTracingExportInterval
+	}
+
+	interval, err := time.ParseDuration(t.ExportInterval)
+	if err != nil {
+		return DefaultTracingExportInterval
+	}
+
+	return interval
+}
+
+// DefaultNodePollInterval is how often assigned nodes are polled when poll_interval is not set
+const DefaultNodePollInterval = 30 * time.Second
+
+// DatabaseNodesConfig switches the daemon to database-backed node configuration: node
+// definitions live in the database instead of the nodes section, managed centrally with
+// 'snapperd nodes' or the node API, and each daemon polls for the nodes assigned to its
+// host. Nodes assigned to no host run on every daemon.
+type DatabaseNodesConfig struct {
+	Host         string `yaml:"host,omitempty"`          // Host whose assignments this daemon runs (default: the hostname)
+	PollInterval string `yaml:"poll_interval,omitempty"` // How often assignments are polled (Go duration, default 30s)
+}
+
+// Validate validates the database-backed node settings
+func (d *DatabaseNodesConfig) Validate() error {
+	if d.Host != "" {
+		if err := ValidateHostName(d.Host); err != nil {
+			return err
+		}
+	}
+	if d.PollInterval != "" {
+		interval, err := time.ParseDuration(d.PollInterval)
+		if err != nil {
+			return fmt.Errorf("invalid poll_interval '%s': %w", d.PollInterval, err)
+		}
+		if interval < time.Second {
+			return fmt.Errorf("poll_interval must be at least 1s")
+		}
+	}
+	return nil
+}
+
+// GetPollInterval returns how often assigned nodes are polled (default 30s)
+func (d *DatabaseNodesConfig) GetPollInterval() time.Duration {
+	if d.PollInterval == "" {
+		return DefaultNodePollInterval
+	}
+
+	interval, err := time.ParseDuration(d.PollInterval)
+	if err != nil {
+		return DefaultNodePollInterval
+	}
+
+	return interval
+}
+
+// Pod annotations read by Kubernetes node discovery
+const (
+	// KubernetesNodeConfigAnnotation holds a pod's node definition, as YAML in the format
+	// of an entry of nodes
+	KubernetesNodeConfigAnnotation = "snapperd.io/node-config"
+	// KubernetesNodeNameAnnotation names the node (default the pod name)
+	KubernetesNodeNameAnnotation = "snapperd.io/node"
+	// KubernetesContainerAnnotation names the container commands run in
+	KubernetesContainerAnnotation = "snapperd.io/container"
+)
+
+// KubernetesConfig discovers nodes from Kubernetes: each running pod matching the label
+// selector and annotated with a node definition is a node, whose hooks, preflight
+// command and rclone transfer run in the pod through the exec API. Pods are listed every
+// poll_interval, so nodes follow pods being created, rescheduled and deleted. The API
+// server and credentials default to the service account of the pod snapperd runs in.
+type KubernetesConfig struct {
+	Namespace     string `yaml:"namespace,omitempty"`     // Namespace of the node pods (default snapperd's own)
+	LabelSelector string `yaml:"label_selector"`          // Selects the node pods, e.g. app.kubernetes.io/part-of=chain-nodes
+	Container     string `yaml:"container,omitempty"`     // Container commands run in unless a pod names one (default the pod's first)
+	PollInterval  string `yaml:"poll_interval,omitempty"` // How often pods are listed (Go duration, default 30s)
+	APIServer     string `yaml:"api_server,omitempty"`    // API server URL, when snapperd runs outside the cluster
+	TokenFile     string `yaml:"token_file,omitempty"`    // Bearer token file (default the service account's)
+	CAFile        string `yaml:"ca_file,omitempty"`       // CA bundle verifying the API server (default the service account's)
+}
+
+// Validate validates the Kubernetes settings
+func (k *KubernetesConfig) Validate() error {
+	if k.LabelSelector == "" {
+		return fmt.Errorf("label_selector is required")
+	}
+	if k.PollInterval != "" {
+		interval, err := time.ParseDuration(k.PollInterval)
+		if err != nil {
+			return fmt.Errorf("invalid poll_interval '%s': %w", k.PollInterval, err)
+		}
+		if interval < time.Second {
+			return fmt.Errorf("poll_interval must be at least 1s")
+		}
+	}
+	if k.APIServer != "" {
+		u, err := url.Parse(k.APIServer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid api_server '%s': must be an http or https URL", k.APIServer)
+		}
+	}
+	return nil
+}
+
+// GetPollInterval returns how often pods are listed (default 30s)
+func (k *KubernetesConfig) GetPollInterval() time.Duration {
+	if k.PollInterval == "" {
+		return DefaultNodePollInterval
+	}
+
+	interval, err := time.ParseDuration(k.PollInterval)
+	if err != nil {
+		return DefaultNodePollInterval
+	}
+
+	return interval
+}
+
+// Client returns the API client settings
+func (k *KubernetesConfig) Client() kube.Config {
+	return kube.Config{Server: k.APIServer, TokenFile: k.TokenFile, CAFile: k.CAFile}
+}
+
+// Guardrail actions
+const (
+	// GuardrailActionRecord only records when usage crosses a threshold
+	GuardrailActionRecord = "record"
+	// GuardrailActionThrottle runs the command to slow uploads down
+	GuardrailActionThrottle = "throttle"
+	// GuardrailActionPause runs the command to pause uploads
+	GuardrailActionPause = "pause"
+)
+
+// Guardrail defaults
+const (
+	// DefaultGuardrailInterval is how often host usage is sampled when interval is not set
+	DefaultGuardrailInterval = 15 * time.Second
+	// DefaultGuardrailRecoveryChecks is how many samples below every threshold end a throttle
+	DefaultGuardrailRecoveryChecks = 2
+	// DefaultGuardrailCommandTimeout is the maximum run time of the guardrail commands
+	DefaultGuardrailCommandTimeout = time.Minute
+)
+
+// GuardrailsConfig watches the host's resources while uploads run, so snapshotting does
+// not starve the blockchain clients sharing the host. When a threshold is crossed, each
+// running upload is throttled or paused with command and the event is recorded on the
+// upload; once usage stays below every threshold for recovery_checks samples,
+// resume_command undoes it. Commands run with sh -c and get NODE_NAME, UPLOAD_ID,
+// GUARDRAIL_ACTION and GUARDRAIL_REASON in their environment.
+type GuardrailsConfig struct {
+	Interval         string  `yaml:"interval,omitempty"`            // How often host usage is sampled (Go duration, default 15s)
+	MaxCPUPercent    float64 `yaml:"max_cpu_percent,omitempty"`     // Busy time across all cores (0 disables)
+	MaxMemoryPercent float64 `yaml:"max_memory_percent,omitempty"`  // Memory in use, excluding reclaimable caches (0 disables)
+	MaxDiskIOPercent float64 `yaml:"max_disk_io_percent,omitempty"` // Utilization of the busiest disk (0 disables)
+	Action           string  `yaml:"action,omitempty"`              // record (default), throttle or pause
+	Command          string  `yaml:"command,omitempty"`             // Throttles or pauses an upload; required for throttle and pause
+	ResumeCommand    string  `yaml:"resume_command,omitempty"`      // Undoes command once usage recovers
+	RecoveryChecks   int     `yaml:"recovery_checks,omitempty"`     // Samples below every threshold before resuming (default 2)
+	CommandTimeout   string  `yaml:"command_timeout,omitempty"`     // Maximum command run time (Go duration, default 1m)
 }
 
-// SetNotificationValidator sets the notification validator for configuration validation
-func SetNotificationValidator(validator NotificationValidator) {
-	notificationValidator = validator
+// Validate validates the guardrail settings
+func (g *GuardrailsConfig) Validate() error {
+	if g.Interval != "" {
+		interval, err := time.ParseDuration(g.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid interval '%s': %w", g.Interval, err)
+		}
+		if interval < time.Second {
+			return fmt.Errorf("interval must be at least 1s")
+		}
+	}
+
+	thresholds := []struct {
+		name  string
+		value float64
+	}{
+		{"max_cpu_percent", g.MaxCPUPercent},
+		{"max_memory_percent", g.MaxMemoryPercent},
+		{"max_disk_io_percent", g.MaxDiskIOPercent},
+	}
+	enabled := false
+	for _, threshold := range thresholds {
+		if threshold.value < 0 || threshold.value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", threshold.name)
+		}
+		enabled = enabled || threshold.value > 0
+	}
+	if !enabled {
+		return fmt.Errorf("at least one of max_cpu_percent, max_memory_percent or max_disk_io_percent is required")
+	}
+
+	switch g.GetAction() {
+	case GuardrailActionRecord:
+		if g.Command != "" || g.ResumeCommand != "" {
+			return fmt.Errorf("command and resume_command require action %s or %s", GuardrailActionThrottle, GuardrailActionPause)
+		}
+	case GuardrailActionThrottle, GuardrailActionPause:
+		if strings.TrimSpace(g.Command) == "" {
+			return fmt.Errorf("action %s requires a command", g.Action)
+		}
+	default:
+		return fmt.Errorf("invalid action '%s': must be %s, %s or %s", g.Action, GuardrailActionRecord, GuardrailActionThrottle, GuardrailActionPause)
+	}
+
+	if g.RecoveryChecks < 0 {
+		return fmt.Errorf("recovery_checks cannot be negative")
+	}
+	if g.CommandTimeout != "" {
+		timeout, err := time.ParseDuration(g.CommandTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid command_timeout '%s': %w", g.CommandTimeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("command_timeout must be positive")
+		}
+	}
+	return nil
 }
 
-// Config represents the complete daemon configuration
-type Config struct {
-	Schedule      string                `yaml:"schedule"`
-	Notifications *NotificationConfig   `yaml:"notifications"`
-	Database      DatabaseConfig        `yaml:"database"`
-	Nodes         map[string]NodeConfig `yaml:"nodes"`
+// GetInterval returns how often host usage is sampled (default 15s)
+func (g *GuardrailsConfig) GetInterval() time.Duration {
+	interval, err := time.ParseDuration(g.Interval)
+	if g.Interval == "" || err != nil {
+		return DefaultGuardrailInterval
+	}
+	return interval
 }
 
-// NodeConfig represents a single node's configuration
-type NodeConfig struct {
-	Protocol      string              `yaml:"protocol"`
-	Type          string              `yaml:"type"`
-	Schedule      string              `yaml:"schedule"`
-	URL           string              `yaml:"url"`
-	Notifications *NotificationConfig `yaml:"notifications,omitempty"`
+// GetAction returns what crossing a threshold does (default record)
+func (g *GuardrailsConfig) GetAction() string {
+	if g.Action == "" {
+		return GuardrailActionRecord
+	}
+	return g.Action
 }
 
-// NotificationConfig represents notification settings
-type NotificationConfig struct {
-	Failure  bool                              `yaml:"failure"`
-	Skip     bool                              `yaml:"skip"`
-	Complete bool                              `yaml:"complete"`
-	Types    map[string]NotificationTypeConfig `yaml:",inline"`
+// GetRecoveryChecks returns how many samples below every threshold end a throttle (default 2)
+func (g *GuardrailsConfig) GetRecoveryChecks() int {
+	if g.RecoveryChecks == 0 {
+		return DefaultGuardrailRecoveryChecks
+	}
+	return g.RecoveryChecks
 }
 
-// NotificationTypeConfig represents a single notification type configuration
-type NotificationTypeConfig struct {
-	URL string `yaml:"url"`
+// GetCommandTimeout returns the maximum run time of the guardrail commands (default 1m)
+func (g *GuardrailsConfig) GetCommandTimeout() time.Duration {
+	timeout, err := time.ParseDuration(g.CommandTimeout)
+	if g.CommandTimeout == "" || err != nil {
+		return DefaultGuardrailCommandTimeout
+	}
+	return timeout
+}
+
+// Output color modes
+const (
+	ColorAuto   = "auto"   // Color when writing to a terminal and NO_COLOR is unset
+	ColorAlways = "always" // Color even when piped
+	ColorNever  = "never"
+)
+
+// StatusColumns are the optional lines of each upload shown by the status command; the
+// node, upload ID and status are always shown
+var StatusColumns = []string{"started", "duration", "trigger", "agent", "block", "progress", "throughput", "eta"}
+
+// DefaultStatusColumns are the status lines shown when none are configured
+var DefaultStatusColumns = []string{"started", "duration", "trigger", "block", "progress", "throughput", "eta"}
+
+// HistoryColumns are the columns the history command's table can show
+var HistoryColumns = []string{"id", "node", "protocol", "status", "trigger", "agent", "block", "started", "completed", "duration", "chunks", "size"}
+
+// DefaultHistoryColumns are the history table's columns when none are configured
+var DefaultHistoryColumns = []string{"id", "node", "protocol", "status", "trigger", "started", "completed", "duration", "chunks", "size"}
+
+// OutputConfig sets the default views of the status and history commands, which their
+// --columns and --color flags override
+type OutputConfig struct {
+	StatusColumns  []string `yaml:"status_columns,omitempty"`  // Lines shown for each running upload (default all but agent)
+	HistoryColumns []string `yaml:"history_columns,omitempty"` // Columns of the history table (default all but agent and block)
+	Color          string   `yaml:"color,omitempty"`           // Color statuses: auto (default), always or never
+}
+
+// Validate validates the output settings
+func (o *OutputConfig) Validate() error {
+	if err := ValidateColumns(o.StatusColumns, StatusColumns); err != nil {
+		return fmt.Errorf("invalid status_columns: %w", err)
+	}
+	if err := ValidateColumns(o.HistoryColumns, HistoryColumns); err != nil {
+		return fmt.Errorf("invalid history_columns: %w", err)
+	}
+	switch o.Color {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		return fmt.Errorf("invalid color '%s': must be %s, %s or %s", o.Color, ColorAuto, ColorAlways, ColorNever)
+	}
+	return nil
+}
+
+// ValidateColumns checks that columns names each of the available columns at most once
+func ValidateColumns(columns, available []string) error {
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if !slices.Contains(available, column) {
+			return fmt.Errorf("unknown column '%s' (available: %s)", column, strings.Join(available, ", "))
+		}
+		if seen[column] {
+			return fmt.Errorf("column '%s' is listed more than once", column)
+		}
+		seen[column] = true
+	}
+	return nil
+}
+
+// GetStatusColumns returns the status command's default lines
+func (c *Config) GetStatusColumns() []string {
+	if c.Output == nil || len(c.Output.StatusColumns) == 0 {
+		return DefaultStatusColumns
+	}
+	return c.Output.StatusColumns
+}
+
+// GetHistoryColumns returns the history table's default columns
+func (c *Config) GetHistoryColumns() []string {
+	if c.Output == nil || len(c.Output.HistoryColumns) == 0 {
+		return DefaultHistoryColumns
+	}
+	return c.Output.HistoryColumns
+}
+
+// GetColor returns the default output color mode (default auto)
+func (c *Config) GetColor() string {
+	if c.Output == nil || c.Output.Color == "" {
+		return ColorAuto
+	}
+	return c.Output.Color
+}
+
+// BVStatusRulesConfig adds bv output patterns recognized by status checks, so new bv
+// error wordings can be handled without a release. Patterns are case-insensitive substrings.
+type BVStatusRulesConfig struct {
+	NotRunning      []string `yaml:"not_running"`      // Output meaning no upload is running
+	NotFound        []string `yaml:"not_found"`        // Output meaning the node has never uploaded
+	ReplaceDefaults bool     `yaml:"replace_defaults"` // Use only the configured patterns
+}
+
+// ContentListingConfig configures how the objects of a completed snapshot are listed.
+// The command runs once per successful upload; "{node}" in its arguments is replaced
+// with the node name and each output line is "<key> [size_bytes] [checksum]".
+type ContentListingConfig struct {
+	Command []string `yaml:"command"` // Listing command and arguments (empty disables recording)
+}
+
+// Enabled reports whether snapshot contents are recorded
+func (c *ContentListingConfig) Enabled() bool {
+	return len(c.Command) > 0
+}
+
+// Validate validates the content listing configuration
+func (c *ContentListingConfig) Validate() error {
+	if c.Enabled() && strings.TrimSpace(c.Command[0]) == "" {
+		return fmt.Errorf("command executable cannot be empty")
+	}
+	return nil
+}
+
+// DefaultAllowedCommands are the commands the daemon may run when executor.allowed_commands
+// is not set, besides those the configuration names
+var DefaultAllowedCommands = []string{"bv"}
+
+// minExecutorOutputBytes is the smallest output limit, so bv status output is not cut
+const minExecutorOutputBytes = 64 << 10
+
+// maxBVConcurrency bounds bv_concurrency, as every bv command is a process reading
+// /etc/blockvisor.json
+const maxBVConcurrency = 64
+
+// Timeouts of bv commands when executor.status_timeout and executor.start_timeout are not set
+const (
+	DefaultBVStatusTimeout = time.Minute
+	DefaultBVStartTimeout  = 5 * time.Minute
+)
+
+// ExecutorConfig restricts the commands the daemon runs, such as bv, hooks and preflight
+// checks, and the processes they run in
+type ExecutorConfig struct {
+	AllowedCommands []string `yaml:"allowed_commands,omitempty"` // Commands that may be run, by name or absolute path (default: bv)
+	RunAsUser       string   `yaml:"run_as_user,omitempty"`      // Run commands as this user; the daemon must run as root
+	WorkingDir      string   `yaml:"working_dir,omitempty"`      // Directory commands run in (default: the daemon's)
+	ScrubEnv        bool     `yaml:"scrub_env,omitempty"`        // Run commands with a minimal environment instead of the daemon's
+	PassEnv         []string `yaml:"pass_env,omitempty"`         // Variables kept when scrub_env is set
+	MaxOutputBytes  int      `yaml:"max_output_bytes,omitempty"` // Bound on each of a command's stdout and stderr kept in memory (default 1MiB)
+	StatusTimeout   string   `yaml:"status_timeout,omitempty"`   // Timeout of bv commands reading an upload job: its info and logs (default 1m)
+	StartTimeout    string   `yaml:"start_timeout,omitempty"`    // Timeout of bv commands starting or stopping an upload job (default 5m)
+	BVConcurrency   int      `yaml:"bv_concurrency,omitempty"`   // Read-only bv commands of different nodes run at once (default 4, 1 runs every bv command alone)
+}
+
+// Validate validates the executor settings
+func (e *ExecutorConfig) Validate() error {
+	for _, command := range e.AllowedCommands {
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("allowed_commands cannot contain empty commands")
+		}
+		if strings.ContainsRune(command, '/') && !filepath.IsAbs(command) {
+			return fmt.Errorf("allowed command '%s' must be a name or an absolute path", command)
+		}
+	}
+	if e.WorkingDir != "" && !filepath.IsAbs(e.WorkingDir) {
+		return fmt.Errorf("working_dir must be an absolute path")
+	}
+	for _, name := range e.PassEnv {
+		if name == "" || strings.ContainsAny(name, "= ") {
+			return fmt.Errorf("invalid pass_env variable name '%s'", name)
+		}
+	}
+	if len(e.PassEnv) > 0 && !e.ScrubEnv {
+		return fmt.Errorf("pass_env requires scrub_env")
+	}
+	if e.MaxOutputBytes != 0 && e.MaxOutputBytes < minExecutorOutputBytes {
+		return fmt.Errorf("max_output_bytes must be at least %d", minExecutorOutputBytes)
+	}
+	if e.BVConcurrency < 0 || e.BVConcurrency > maxBVConcurrency {
+		return fmt.Errorf("bv_concurrency must be between 0 and %d (0 uses the default of %d)", maxBVConcurrency, executor.DefaultBVConcurrency)
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"status_timeout", e.StatusTimeout},
+		{"start_timeout", e.StartTimeout},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(setting.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", setting.name, setting.value, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("%s must be positive", setting.name)
+		}
+	}
+	return nil
+}
+
+// GetBVConcurrency returns how many read-only bv commands may run at once (default
+// executor.DefaultBVConcurrency)
+func (c *Config) GetBVConcurrency() int {
+	if c.Executor == nil || c.Executor.BVConcurrency == 0 {
+		return executor.DefaultBVConcurrency
+	}
+	return c.Executor.BVConcurrency
+}
+
+// GetBVStatusTimeout returns the timeout of bv commands reading an upload job (default 1 minute)
+func (c *Config) GetBVStatusTimeout() time.Duration {
+	if c.Executor == nil {
+		return DefaultBVStatusTimeout
+	}
+	timeout, err := time.ParseDuration(c.Executor.StatusTimeout)
+	if c.Executor.StatusTimeout == "" || err != nil {
+		return DefaultBVStatusTimeout
+	}
+	return timeout
+}
+
+// GetBVStartTimeout returns the timeout of bv commands starting or stopping an upload job
+// (default 5 minutes)
+func (c *Config) GetBVStartTimeout() time.Duration {
+	if c.Executor == nil {
+		return DefaultBVStartTimeout
+	}
+	timeout, err := time.ParseDuration(c.Executor.StartTimeout)
+	if c.Executor.StartTimeout == "" || err != nil {
+		return DefaultBVStartTimeout
+	}
+	return timeout
+}
+
+// ExecutorCommands returns the commands the daemon may run: executor.allowed_commands
+// (default bv) and every command the configuration names, for content listing,
+// guardrails and each node. The commands of nodes registered at runtime are added as
+// they are registered.
+func (c *Config) ExecutorCommands() []string {
+	commands := DefaultAllowedCommands
+	if c.Executor != nil && len(c.Executor.AllowedCommands) > 0 {
+		commands = c.Executor.AllowedCommands
+	}
+	commands = append([]string{}, commands...)
+
+	if c.ContentListing.Enabled() {
+		commands = append(commands, c.ContentListing.Command[0])
+	}
+	if c.Guardrails != nil && (c.Guardrails.Command != "" || c.Guardrails.ResumeCommand != "") {
+		commands = append(commands, "env")
+	}
+	nodeNames := make([]string, 0, len(c.Nodes))
+	for nodeName := range c.Nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	slices.Sort(nodeNames)
+	for _, nodeName := range nodeNames {
+		nodeConfig := c.Nodes[nodeName]
+		commands = append(commands, nodeConfig.Commands()...)
+	}
+
+	seen := make(map[string]bool, len(commands))
+	unique := commands[:0]
+	for _, command := range commands {
+		if !seen[command] {
+			seen[command] = true
+			unique = append(unique, command)
+		}
+	}
+	return unique
+}
+
+// ExecutorSandbox returns the sandbox the daemon runs commands in: the commands of
+// ExecutorCommands, with the user, working directory, environment and output limit of
+// the executor section
+func (c *Config) ExecutorSandbox() executor.Sandbox {
+	sandbox := executor.Sandbox{AllowedCommands: c.ExecutorCommands()}
+	if e := c.Executor; e != nil {
+		sandbox.RunAsUser = e.RunAsUser
+		sandbox.WorkingDir = e.WorkingDir
+		sandbox.ScrubEnv = e.ScrubEnv
+		sandbox.PassEnv = e.PassEnv
+		sandbox.MaxOutputBytes = e.MaxOutputBytes
+	}
+	return sandbox
+}
+
+// validateBVCommandPrefix checks a bv command prefix. The {command} placeholder may appear
+// in one argument at most, and never as the wrapper executable itself.
+func validateBVCommandPrefix(prefix []string) error {
+	if len(prefix) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(prefix[0]) == "" {
+		return fmt.Errorf("wrapper executable cannot be empty")
+	}
+	if strings.Contains(prefix[0], executor.CommandPlaceholder) {
+		return fmt.Errorf("%s cannot be the wrapper executable", executor.CommandPlaceholder)
+	}
+	placeholders := 0
+	for _, arg := range prefix[1:] {
+		placeholders += strings.Count(arg, executor.CommandPlaceholder)
+	}
+	if placeholders > 1 {
+		return fmt.Errorf("%s can appear only once", executor.CommandPlaceholder)
+	}
+	return nil
+}
+
+// Validate validates the bv status rules
+func (r *BVStatusRulesConfig) Validate() error {
+	for _, pattern := range append(append([]string{}, r.NotRunning...), r.NotFound...) {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("bv status rule patterns cannot be empty")
+		}
+	}
+	if r.ReplaceDefaults && len(r.NotRunning) == 0 && len(r.NotFound) == 0 {
+		return fmt.Errorf("replace_defaults requires at least one not_running or not_found pattern")
+	}
+	return nil
 }
 
 // DatabaseConfig represents database connection settings
 type DatabaseConfig struct {
+	Driver   string `yaml:"driver"` // postgres (default) or sqlite
+	Path     string `yaml:"path"`   // Database file path (sqlite only)
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	Database string `yaml:"database"`
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
-	SSLMode  string `yaml:"ssl_mode"`
+	SSLMode  string `yaml:"ssl_mode"` // disable, require (default), verify-ca or verify-full
+	// TLS files (postgres only)
+	SSLRootCert string `yaml:"ssl_root_cert"` // CA certificate the server's certificate is verified against
+	SSLCert     string `yaml:"ssl_cert"`      // Client certificate, for servers requiring mutual TLS
+	SSLKey      string `yaml:"ssl_key"`       // Client certificate's private key, readable only by its owner
 }
 
 // LoadConfig loads configuration from the specified file path
 func LoadConfig(path string) (*Config, error) {
+	config, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate configuration
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// ReadConfig reads the configuration from the specified file path and applies defaults
+// without validating it, so every problem can be reported rather than only the first.
+// Use LoadConfig to run with the configuration.
+func ReadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if err := interpolate(&root); err != nil {
+		return nil, fmt.Errorf("failed to expand config variables: %w", err)
+	}
+
+	var config Config
+	if len(root.Content) > 0 {
+		if err := root.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	// Derive node entries from blockvisor before validating the merged result
+	if config.Blockvisor != nil {
+		if err := config.applyBlockvisorNodes(); err != nil {
+			return nil, fmt.Errorf("failed to load blockvisor nodes: %w", err)
+		}
+	}
 
 	// Apply defaults
 	if config.Schedule == "" {
 		config.Schedule = "0 * * * * *" // Default to every minute (6-field format: second minute hour day month weekday)
 	}
+	if config.BlobRetentionSchedule == "" {
+		config.BlobRetentionSchedule = "0 */15 * * * *" // Default to every 15 minutes
+	}
+	if config.FreshnessSchedule == "" {
+		config.FreshnessSchedule = "0 */15 * * * *" // Default to every 15 minutes
+	}
+	if config.VerificationSchedule == "" {
+		config.VerificationSchedule = "0 0 */6 * * *" // Default to every 6 hours
+	}
+	if config.SLOSchedule == "" {
+		config.SLOSchedule = "0 */15 * * * *" // Default to every 15 minutes
+	}
 
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+	if config.TriggerDebounce == "" {
+		config.TriggerDebounce = "1m"
 	}
 
 	return &config, nil
@@ -105,11 +2077,220 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid global schedule: %w", err)
 	}
 
+	// Validate schedule timezone if set
+	if err := validateTimezone(c.Timezone); err != nil {
+		return err
+	}
+
+	// Validate blob retention check schedule if set
+	if c.BlobRetentionSchedule != "" {
+		if err := validateCronSchedule(c.BlobRetentionSchedule); err != nil {
+			return fmt.Errorf("invalid blob retention schedule: %w", err)
+		}
+	}
+
+	// Validate snapshot freshness check schedule if set
+	if c.FreshnessSchedule != "" {
+		if err := validateCronSchedule(c.FreshnessSchedule); err != nil {
+			return fmt.Errorf("invalid freshness schedule: %w", err)
+		}
+	}
+
+	// Validate restore verification schedule if set
+	if c.VerificationSchedule != "" {
+		if err := validateCronSchedule(c.VerificationSchedule); err != nil {
+			return fmt.Errorf("invalid verification schedule: %w", err)
+		}
+	}
+
+	// Validate SLO compliance check schedule if set
+	if c.SLOSchedule != "" {
+		if err := validateCronSchedule(c.SLOSchedule); err != nil {
+			return fmt.Errorf("invalid slo schedule: %w", err)
+		}
+	}
+
+	// Validate snapshot freshness alerting
+	if err := validateMaxSnapshotAge(c.MaxSnapshotAge); err != nil {
+		return err
+	}
+
+	// Validate the upload objective if set
+	if c.SLO != nil {
+		if err := c.SLO.Validate(); err != nil {
+			return fmt.Errorf("invalid slo config: %w", err)
+		}
+	}
+
+	// Validate the digest if set; it is sent to the global notification types
+	if c.Digest != nil {
+		if err := c.Digest.Validate(); err != nil {
+			return fmt.Errorf("invalid digest config: %w", err)
+		}
+		if c.Notifications == nil {
+			return fmt.Errorf("digest requires global notifications")
+		}
+	}
+
+	// Validate stalled progress detection
+	if c.StallIntervals < 0 {
+		return fmt.Errorf("stall_intervals cannot be negative")
+	}
+
+	// Validate monitor lag alerting
+	if c.MonitorLagThreshold != "" {
+		threshold, err := time.ParseDuration(c.MonitorLagThreshold)
+		if err != nil {
+			return fmt.Errorf("invalid monitor_lag_threshold '%s': %w", c.MonitorLagThreshold, err)
+		}
+		if threshold <= 0 {
+			return fmt.Errorf("monitor_lag_threshold must be positive")
+		}
+	}
+
+	// Validate the upload concurrency limit
+	if c.MaxConcurrentUploads < 0 {
+		return fmt.Errorf("max_concurrent_uploads cannot be negative")
+	}
+
+	// Validate the upload request debounce window
+	if c.TriggerDebounce != "" {
+		debounce, err := time.ParseDuration(c.TriggerDebounce)
+		if err != nil {
+			return fmt.Errorf("invalid trigger_debounce '%s': %w", c.TriggerDebounce, err)
+		}
+		if debounce < 0 {
+			return fmt.Errorf("trigger_debounce cannot be negative")
+		}
+	}
+
+	// Validate the metric collection limits
+	if c.MetricConcurrency < 0 {
+		return fmt.Errorf("metric_concurrency cannot be negative")
+	}
+	if c.MetricTimeout != "" {
+		timeout, err := time.ParseDuration(c.MetricTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid metric_timeout '%s': %w", c.MetricTimeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("metric_timeout must be positive")
+		}
+	}
+
+	// Validate bv status rules
+	if err := c.BVStatusRules.Validate(); err != nil {
+		return fmt.Errorf("invalid bv_status_rules: %w", err)
+	}
+
+	// Validate the bv command prefix
+	if err := validateBVCommandPrefix(c.BVCommandPrefix); err != nil {
+		return fmt.Errorf("invalid bv_command_prefix: %w", err)
+	}
+
+	// Validate the executor sandbox
+	if c.Executor != nil {
+		if err := c.Executor.Validate(); err != nil {
+			return fmt.Errorf("invalid executor config: %w", err)
+		}
+	}
+
+	// Validate the bv output format
+	switch c.BVOutputFormat {
+	case "", "auto", "json", "text":
+	default:
+		return fmt.Errorf("invalid bv_output_format '%s': must be auto, json or text", c.BVOutputFormat)
+	}
+
+	// Validate snapshot content listing
+	if err := c.ContentListing.Validate(); err != nil {
+		return fmt.Errorf("invalid content_listing: %w", err)
+	}
+
+	// Validate the snooze endpoint
+	if c.Snooze != nil {
+		if err := c.Snooze.Validate(); err != nil {
+			return fmt.Errorf("invalid snooze config: %w", err)
+		}
+	}
+
+	// Validate the startup policy
+	switch c.StartupPolicy {
+	case "", StartupPolicyStrict, StartupPolicyDegraded:
+	default:
+		return fmt.Errorf("invalid startup_policy '%s': must be %s or %s", c.StartupPolicy, StartupPolicyStrict, StartupPolicyDegraded)
+	}
+
+	// Validate the health endpoint
+	if c.Health != nil {
+		if err := c.Health.Validate(); err != nil {
+			return fmt.Errorf("invalid health config: %w", err)
+		}
+	}
+
 	// Validate database configuration
 	if err := c.Database.Validate(); err != nil {
 		return fmt.Errorf("invalid database config: %w", err)
 	}
 
+	// Validate the node API
+	if c.NodeAPI != nil {
+		if err := c.NodeAPI.Validate(); err != nil {
+			return fmt.Errorf("invalid node_api config: %w", err)
+		}
+	}
+
+	// Validate the summary endpoint
+	if c.SummaryAPI != nil {
+		if err := c.SummaryAPI.Validate(); err != nil {
+			return fmt.Errorf("invalid summary_api config: %w", err)
+		}
+	}
+
+	if c.Metrics != nil {
+		if err := c.Metrics.Validate(); err != nil {
+			return fmt.Errorf("invalid metrics config: %w", err)
+		}
+	}
+
+	if c.Tracing != nil {
+		if err := c.Tracing.Validate(); err != nil {
+			return fmt.Errorf("invalid tracing config: %w", err)
+		}
+	}
+
+	// Validate database-backed nodes
+	if c.DatabaseNodes != nil {
+		if err := c.DatabaseNodes.Validate(); err != nil {
+			return fmt.Errorf("invalid database_nodes config: %w", err)
+		}
+	}
+
+	// Validate host resource guardrails
+	if c.Guardrails != nil {
+		if err := c.Guardrails.Validate(); err != nil {
+			return fmt.Errorf("invalid guardrails config: %w", err)
+		}
+	}
+
+	// Validate the status and history output settings
+	if c.Output != nil {
+		if err := c.Output.Validate(); err != nil {
+			return fmt.Errorf("invalid output config: %w", err)
+		}
+	}
+
+	// Validate leader election, which relies on PostgreSQL advisory locks unless it
+	// uses a Kubernetes Lease
+	if c.LeaderElection != nil {
+		if err := c.LeaderElection.Validate(); err != nil {
+			return fmt.Errorf("invalid leader_election config: %w", err)
+		}
+		if c.LeaderElection.GetBackend() == LeaderBackendPostgres && c.Database.Driver == "sqlite" {
+			return fmt.Errorf("invalid leader_election config: requires the postgres database driver")
+		}
+	}
+
 	// Validate global notifications if present
 	if c.Notifications != nil {
 		if err := c.Notifications.Validate(); err != nil {
@@ -117,15 +2298,87 @@ func (c *Config) Validate() error {
 		}
 	}
 
-	// Validate each node configuration
-	if len(c.Nodes) == 0 {
+	// Validate Kubernetes node discovery, which replaces the other runtime node sources
+	if c.Kubernetes != nil {
+		if err := c.Kubernetes.Validate(); err != nil {
+			return fmt.Errorf("invalid kubernetes config: %w", err)
+		}
+		if c.DatabaseNodes != nil || c.NodeAPI != nil {
+			return fmt.Errorf("invalid kubernetes config: cannot be combined with database_nodes or node_api")
+		}
+	}
+
+	// Validate each node configuration. With the node API, database-backed nodes or
+	// Kubernetes, nodes can all be registered at runtime instead.
+	if len(c.Nodes) == 0 && c.NodeAPI == nil && c.DatabaseNodes == nil && c.Kubernetes == nil {
 		return fmt.Errorf("at least one node must be configured")
 	}
 
+	// Validate tenants, whose tokens must tell them apart from each other and from the
+	// APIs' own tokens
+	tokens := make(map[string]string)
+	if c.NodeAPI != nil {
+		tokens[c.NodeAPI.Token] = "node_api"
+	}
+	if c.SummaryAPI != nil && c.SummaryAPI.Token != "" {
+		tokens[c.SummaryAPI.Token] = "summary_api"
+	}
+	for name, tenant := range c.Tenants {
+		if err := validateName("tenant", name); err != nil {
+			return fmt.Errorf("invalid tenant %s: %w", name, err)
+		}
+		if err := tenant.Validate(); err != nil {
+			return fmt.Errorf("invalid tenant %s: %w", name, err)
+		}
+		if tenant.Token == "" {
+			continue
+		}
+		if other, used := tokens[tenant.Token]; used {
+			return fmt.Errorf("invalid tenant %s: token is already used by %s", name, other)
+		}
+		tokens[tenant.Token] = "tenant " + name
+	}
+
 	for name, node := range c.Nodes {
 		if err := node.Validate(); err != nil {
 			return fmt.Errorf("invalid config for node %s: %w", name, err)
 		}
+		if _, exists := c.Tenants[node.Tenant]; node.Tenant != "" && !exists {
+			return fmt.Errorf("invalid config for node %s: tenant %s is not configured", name, node.Tenant)
+		}
+		// Incremental uploads diff against the recorded contents of the base snapshot
+		if node.Incremental != nil && !c.ContentListing.Enabled() {
+			return fmt.Errorf("invalid config for node %s: incremental snapshots require content_listing", name)
+		}
+		// Commands run in pods through the API client of the kubernetes section
+		if node.Container != nil && node.Container.GetRuntime() == kube.RuntimeKubernetes && c.Kubernetes == nil {
+			return fmt.Errorf("invalid config for node %s: container runtime kubernetes requires the kubernetes section", name)
+		}
+	}
+
+	// Validate consistency groups: members must be configured and belong to one group
+	groupOf := make(map[string]string)
+	for name, group := range c.ConsistencyGroups {
+		if err := group.Validate(); err != nil {
+			return fmt.Errorf("invalid consistency group %s: %w", name, err)
+		}
+		for _, node := range group.Nodes {
+			if _, exists := c.Nodes[node]; !exists {
+				return fmt.Errorf("invalid consistency group %s: node %s is not configured", name, node)
+			}
+			if other, grouped := groupOf[node]; grouped {
+				return fmt.Errorf("invalid consistency group %s: node %s is already in group %s", name, node, other)
+			}
+			// Members start together with the group, so their runs cannot be staggered
+			if c.Nodes[node].Splay != "" {
+				return fmt.Errorf("invalid consistency group %s: node %s cannot have a splay", name, node)
+			}
+			// The group's schedule is evaluated in the global timezone
+			if c.Nodes[node].Timezone != "" {
+				return fmt.Errorf("invalid consistency group %s: node %s cannot have a timezone", name, node)
+			}
+			groupOf[node] = name
+		}
 	}
 
 	return nil
@@ -133,6 +2386,17 @@ func (c *Config) Validate() error {
 
 // Validate validates the database configuration
 func (d *DatabaseConfig) Validate() error {
+	switch d.Driver {
+	case "", "postgres":
+	case "sqlite":
+		if d.Path == "" {
+			return fmt.Errorf("database path is required for sqlite driver")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported database driver %s", d.Driver)
+	}
+
 	if d.Host == "" {
 		return fmt.Errorf("database host is required")
 	}
@@ -146,6 +2410,18 @@ func (d *DatabaseConfig) Validate() error {
 		return fmt.Errorf("database user is required")
 	}
 	// Password can be empty if using other auth methods
+
+	switch d.SSLMode {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("unsupported database ssl_mode %s (expected disable, require, verify-ca or verify-full)", d.SSLMode)
+	}
+	if (d.SSLCert == "") != (d.SSLKey == "") {
+		return fmt.Errorf("database ssl_cert and ssl_key must be set together")
+	}
+	if d.SSLMode == "disable" && (d.SSLRootCert != "" || d.SSLCert != "") {
+		return fmt.Errorf("database ssl_root_cert, ssl_cert and ssl_key require ssl_mode other than disable")
+	}
 	return nil
 }
 
@@ -171,6 +2447,213 @@ func (n *NodeConfig) Validate() error {
 		return fmt.Errorf("invalid node schedule: %w", err)
 	}
 
+	// Validate max duration if set
+	if n.MaxDuration != "" {
+		maxDuration, err := time.ParseDuration(n.MaxDuration)
+		if err != nil {
+			return fmt.Errorf("invalid max_duration '%s': %w", n.MaxDuration, err)
+		}
+		if maxDuration <= 0 {
+			return fmt.Errorf("max_duration must be positive")
+		}
+	}
+
+	// Validate splay if set
+	if n.Splay != "" {
+		splay, err := time.ParseDuration(n.Splay)
+		if err != nil {
+			return fmt.Errorf("invalid splay '%s': %w", n.Splay, err)
+		}
+		if splay <= 0 {
+			return fmt.Errorf("splay must be positive")
+		}
+	}
+
+	// Validate schedule timezone if set
+	if err := validateTimezone(n.Timezone); err != nil {
+		return err
+	}
+
+	// Validate snapshot freshness override if set
+	if err := validateMaxSnapshotAge(n.MaxSnapshotAge); err != nil {
+		return err
+	}
+
+	// Validate upload objective override if set
+	if n.SLO != nil {
+		if err := n.SLO.Validate(); err != nil {
+			return fmt.Errorf("invalid slo config: %w", err)
+		}
+	}
+
+	// Validate retry policy if set
+	if n.Retry != nil {
+		if err := n.Retry.Validate(); err != nil {
+			return fmt.Errorf("invalid retry config: %w", err)
+		}
+	}
+
+	// Validate circuit breaker if set
+	if n.CircuitBreaker != nil {
+		if err := n.CircuitBreaker.Validate(); err != nil {
+			return fmt.Errorf("invalid circuit_breaker config: %w", err)
+		}
+	}
+
+	// Validate metric retry policy if set
+	if n.MetricRetry != nil {
+		if err := n.MetricRetry.Validate(); err != nil {
+			return fmt.Errorf("invalid metric_retry config: %w", err)
+		}
+	}
+
+	// Validate finality timeout if set
+	if n.FinalityTimeout != "" {
+		finalityTimeout, err := time.ParseDuration(n.FinalityTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid finality_timeout '%s': %w", n.FinalityTimeout, err)
+		}
+		if finalityTimeout <= 0 {
+			return fmt.Errorf("finality_timeout must be positive")
+		}
+	}
+
+	// Validate generic protocol metric queries
+	if n.Protocol == GenericProtocol && len(n.Metrics) == 0 {
+		return fmt.Errorf("the generic protocol requires at least one metric")
+	}
+	if n.Protocol != GenericProtocol && len(n.Metrics) > 0 {
+		return fmt.Errorf("metrics are only supported by the generic protocol")
+	}
+	keys := make(map[string]bool, len(n.Metrics))
+	for i := range n.Metrics {
+		if err := n.Metrics[i].Validate(); err != nil {
+			return fmt.Errorf("invalid metric: %w", err)
+		}
+		if keys[n.Metrics[i].Key] {
+			return fmt.Errorf("duplicate metric key %s", n.Metrics[i].Key)
+		}
+		keys[n.Metrics[i].Key] = true
+	}
+
+	// Validate incremental snapshots
+	if n.Incremental != nil {
+		if err := n.Incremental.Validate(); err != nil {
+			return fmt.Errorf("invalid incremental config: %w", err)
+		}
+	}
+
+	// Validate metric validation thresholds
+	if n.Validation != nil {
+		if err := n.Validation.Validate(); err != nil {
+			return fmt.Errorf("invalid validation config: %w", err)
+		}
+	}
+
+	// Validate preflight gates
+	if n.Preflight != nil {
+		if err := n.Preflight.Validate(); err != nil {
+			return fmt.Errorf("invalid preflight config: %w", err)
+		}
+	}
+
+	// Validate upload hooks
+	if n.Hooks != nil {
+		if err := n.Hooks.Validate(); err != nil {
+			return fmt.Errorf("invalid hooks config: %w", err)
+		}
+	}
+
+	// Validate restore verification
+	if n.Verification != nil {
+		if err := n.Verification.Validate(); err != nil {
+			return fmt.Errorf("invalid verification config: %w", err)
+		}
+	}
+
+	// Validate compression, which the engine applies
+	if n.Compression != nil {
+		if err := n.Compression.Validate(); err != nil {
+			return fmt.Errorf("invalid compression config: %w", err)
+		}
+		if n.GetEngine() != engine.S3 {
+			return fmt.Errorf("compression is not supported by the %s engine", n.GetEngine())
+		}
+		if n.S3 != nil && n.S3.Compression != "" {
+			return fmt.Errorf("compression is set both on the node and in its s3 settings")
+		}
+	}
+
+	// Validate the upload engine
+	switch n.GetEngine() {
+	case engine.BV:
+		if n.Rclone != nil {
+			return fmt.Errorf("rclone settings require engine: rclone")
+		}
+		if n.S3 != nil {
+			return fmt.Errorf("s3 settings require engine: s3")
+		}
+	case engine.Rclone:
+		if n.S3 != nil {
+			return fmt.Errorf("s3 settings require engine: s3")
+		}
+		if n.Rclone == nil {
+			return fmt.Errorf("the rclone engine requires rclone settings")
+		}
+		if err := n.Rclone.Validate(); err != nil {
+			return fmt.Errorf("invalid rclone config: %w", err)
+		}
+		// Incremental uploads run their own command, whose status is read from bv
+		if n.Incremental != nil {
+			return fmt.Errorf("incremental uploads are not supported by the rclone engine")
+		}
+	case engine.S3:
+		if n.Rclone != nil {
+			return fmt.Errorf("rclone settings require engine: rclone")
+		}
+		if n.S3 == nil {
+			return fmt.Errorf("the s3 engine requires s3 settings")
+		}
+		if err := n.S3.Validate(); err != nil {
+			return fmt.Errorf("invalid s3 config: %w", err)
+		}
+		if n.Incremental != nil {
+			return fmt.Errorf("incremental uploads are not supported by the s3 engine")
+		}
+	default:
+		return fmt.Errorf("invalid engine '%s': must be bv, rclone or s3", n.Engine)
+	}
+	if len(n.EngineArgs) > 0 {
+		if n.Incremental != nil {
+			return fmt.Errorf("engine_args are not supported with incremental uploads, which run their own command")
+		}
+		if err := validateEngineArgs(n.GetEngine(), n.EngineArgs); err != nil {
+			return fmt.Errorf("invalid engine_args: %w", err)
+		}
+	}
+
+	// Validate the container the node's commands run in
+	if n.Container != nil {
+		if err := n.Container.Validate(); err != nil {
+			return fmt.Errorf("invalid container config: %w", err)
+		}
+		// The base manifest of an incremental upload is written on the host
+		if n.Incremental != nil {
+			return fmt.Errorf("incremental uploads are not supported in a container")
+		}
+		// A pod has no bv to upload through
+		if n.Container.GetRuntime() == kube.RuntimeKubernetes && n.GetEngine() == engine.BV {
+			return fmt.Errorf("engine bv is not supported for nodes in pods")
+		}
+	}
+
+	// Validate metadata keys
+	for key := range n.Metadata {
+		if key == "" {
+			return fmt.Errorf("metadata keys cannot be empty")
+		}
+	}
+
 	// Validate per-node notifications if present
 	if n.Notifications != nil {
 		if err := n.Notifications.Validate(); err != nil {
@@ -187,6 +2670,10 @@ func (n *NotificationConfig) Validate() error {
 		return fmt.Errorf("at least one notification type is required")
 	}
 
+	if n.FailureLogLines < 0 || n.FailureLogLines > MaxFailureLogLines {
+		return fmt.Errorf("failure_log_lines must be between 0 and %d", MaxFailureLogLines)
+	}
+
 	// Validate each notification type
 	for typeName, typeConfig := range n.Types {
 		if typeConfig.URL == "" {
@@ -197,17 +2684,39 @@ func (n *NotificationConfig) Validate() error {
 		if notificationValidator != nil && !notificationValidator.IsRegistered(typeName) {
 			return fmt.Errorf("notification type %s is not registered", typeName)
 		}
+
+		if err := validateNotificationTemplates(typeConfig.Templates); err != nil {
+			return fmt.Errorf("invalid templates for notification type %s: %w", typeName, err)
+		}
+	}
+
+	if err := validateNotificationTemplates(n.Templates); err != nil {
+		return fmt.Errorf("invalid notification templates: %w", err)
 	}
 
 	return nil
 }
 
-// validateCronSchedule validates a cron schedule expression
-// Uses 6-field format: second minute hour day month weekday
+// validateNotificationTemplates checks templates with the notification validator when it
+// can validate them
+func validateNotificationTemplates(templates map[string]NotificationTemplateConfig) error {
+	validator, ok := notificationValidator.(NotificationTemplateValidator)
+	if !ok {
+		return nil
+	}
+	for event, tmpl := range templates {
+		if err := validator.ValidateTemplate(event, tmpl.Title, tmpl.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCronSchedule validates a schedule: a 6-field cron expression (second minute
+// hour day month weekday), a 5-field one, a descriptor or one of the forms
+// NormalizeSchedule accepts
 func validateCronSchedule(schedule string) error {
-	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-	_, err := parser.Parse(schedule)
-	if err != nil {
+	if _, err := ParseSchedule(schedule); err != nil {
 		return fmt.Errorf("invalid cron schedule '%s': %w", schedule, err)
 	}
 	return nil
@@ -221,11 +2730,320 @@ func (c *Config) GetNodeSchedule(nodeName string) string {
 		return ""
 	}
 
-	return node.Schedule
+	// Members of a consistency group upload on the group's schedule
+	schedule := node.Schedule
+	if groupName := c.GetNodeConsistencyGroup(nodeName); groupName != "" {
+		schedule = c.ConsistencyGroups[groupName].Schedule
+	}
+
+	return WithTimezone(schedule, c.GetNodeTimezone(nodeName))
+}
+
+// GetNodeTimezone returns the timezone a node's schedule is evaluated in: its own, the
+// global one, or an empty string for the host clock's. Members of a consistency group use
+// the global timezone.
+func (c *Config) GetNodeTimezone(nodeName string) string {
+	if node, exists := c.Nodes[nodeName]; exists && node.Timezone != "" && c.GetNodeConsistencyGroup(nodeName) == "" {
+		return node.Timezone
+	}
+	return c.Timezone
+}
+
+// GetLocation returns the global timezone schedules are evaluated in, time.Local if none
+// is set
+func (c *Config) GetLocation() *time.Location {
+	return loadLocation(c.Timezone)
+}
+
+// GetNodeLocation returns the timezone a node's schedule is evaluated in, see GetNodeTimezone
+func (c *Config) GetNodeLocation(nodeName string) *time.Location {
+	return loadLocation(c.GetNodeTimezone(nodeName))
+}
+
+// GetNodeSplay returns how long a node's scheduled runs may be delayed, or 0 if they are
+// not splayed. Members of a consistency group start with the group and are never splayed.
+func (c *Config) GetNodeSplay(nodeName string) time.Duration {
+	node, exists := c.Nodes[nodeName]
+	if !exists || c.GetNodeConsistencyGroup(nodeName) != "" {
+		return 0
+	}
+	return node.GetSplay()
+}
+
+// GetNodeConsistencyGroup returns the name of the consistency group a node belongs to,
+// or an empty string if it is not in a group
+func (c *Config) GetNodeConsistencyGroup(nodeName string) string {
+	for name, group := range c.ConsistencyGroups {
+		for _, node := range group.Nodes {
+			if node == nodeName {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// GetMonitorLagThreshold returns the completion detection lag that triggers an alert, or 0 if disabled
+func (c *Config) GetMonitorLagThreshold() time.Duration {
+	if c.MonitorLagThreshold == "" {
+		return 0
+	}
+
+	threshold, err := time.ParseDuration(c.MonitorLagThreshold)
+	if err != nil {
+		return 0
+	}
+
+	return threshold
+}
+
+// DefaultMetricConcurrency is how many protocol metric collections run at once by default
+const DefaultMetricConcurrency = 8
+
+// DefaultMetricTimeout is the default limit on one node's protocol metric collection
+const DefaultMetricTimeout = 30 * time.Second
+
+// GetMetricConcurrency returns how many protocol metric collections may run at once
+func (c *Config) GetMetricConcurrency() int {
+	if c.MetricConcurrency <= 0 {
+		return DefaultMetricConcurrency
+	}
+	return c.MetricConcurrency
+}
+
+// GetMetricTimeout returns the limit on one node's protocol metric collection
+func (c *Config) GetMetricTimeout() time.Duration {
+	if c.MetricTimeout == "" {
+		return DefaultMetricTimeout
+	}
+
+	timeout, err := time.ParseDuration(c.MetricTimeout)
+	if err != nil || timeout <= 0 {
+		return DefaultMetricTimeout
+	}
+
+	return timeout
+}
+
+// GetTriggerDebounce returns the window in which repeated upload requests for a node are
+// coalesced into its last upload, or 0 if disabled
+func (c *Config) GetTriggerDebounce() time.Duration {
+	if c.TriggerDebounce == "" {
+		return 0
+	}
+
+	debounce, err := time.ParseDuration(c.TriggerDebounce)
+	if err != nil {
+		return 0
+	}
+
+	return debounce
+}
+
+// validateTimezone validates a timezone name; empty means not set
+func validateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("invalid timezone '%s': %w", name, err)
+	}
+	return nil
+}
+
+// loadLocation returns the named timezone, time.Local if the name is empty. Names are
+// validated with the config, so one failing to load here also falls back to time.Local.
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// validateMaxSnapshotAge validates a max_snapshot_age value; empty means not set
+func validateMaxSnapshotAge(value string) error {
+	if value == "" {
+		return nil
+	}
+	maxAge, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid max_snapshot_age '%s': %w", value, err)
+	}
+	if maxAge <= 0 {
+		return fmt.Errorf("max_snapshot_age must be positive")
+	}
+	return nil
+}
+
+// GetNodeSLO returns a node's upload objective: its own, else the global one, or nil if
+// neither is set
+func (c *Config) GetNodeSLO(nodeName string) *SLOConfig {
+	if nodeConfig, exists := c.Nodes[nodeName]; exists && nodeConfig.SLO != nil {
+		return nodeConfig.SLO
+	}
+	return c.SLO
+}
+
+// GetMaxSnapshotAge returns the age of a node's last successful upload that makes its
+// snapshot stale: the node's max_snapshot_age, else the global one, or 0 if disabled
+func (c *Config) GetMaxSnapshotAge(nodeName string) time.Duration {
+	value := c.MaxSnapshotAge
+	if nodeConfig, exists := c.Nodes[nodeName]; exists && nodeConfig.MaxSnapshotAge != "" {
+		value = nodeConfig.MaxSnapshotAge
+	}
+	if value == "" {
+		return 0
+	}
+
+	maxAge, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+
+	return maxAge
+}
+
+// IsEnabled reports whether the node's scheduled uploads run, which they do unless
+// enabled is set to false
+func (n *NodeConfig) IsEnabled() bool {
+	return n.Enabled == nil || *n.Enabled
+}
+
+// GetMaxDuration returns the node's maximum upload duration, or 0 if not limited
+func (n *NodeConfig) GetMaxDuration() time.Duration {
+	if n.MaxDuration == "" {
+		return 0
+	}
+
+	maxDuration, err := time.ParseDuration(n.MaxDuration)
+	if err != nil {
+		return 0
+	}
+
+	return maxDuration
+}
+
+// GetSplay returns how long the node's scheduled runs may be delayed, or 0 if not splayed
+func (n *NodeConfig) GetSplay() time.Duration {
+	if n.Splay == "" {
+		return 0
+	}
+
+	splay, err := time.ParseDuration(n.Splay)
+	if err != nil {
+		return 0
+	}
+
+	return splay
+}
+
+// GetCompression returns how the node's uploads are compressed: its compression section,
+// or the s3 settings' compression with the default level. An empty algorithm leaves the
+// engine's default.
+func (n *NodeConfig) GetCompression() CompressionConfig {
+	if n.Compression != nil {
+		return *n.Compression
+	}
+	if n.S3 != nil {
+		return CompressionConfig{Algorithm: n.S3.Compression}
+	}
+	return CompressionConfig{}
+}
+
+// engineFlagPattern matches the flag names accepted as engine arguments of bv and rclone,
+// without their leading dashes
+var engineFlagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// validateEngineArgs checks a node's engine arguments against what its engine accepts:
+// flag names for bv and rclone, except the flags the rclone engine sets itself, and the
+// archive headers of s3.ObjectArgs for s3
+func validateEngineArgs(engineName string, args map[string]string) error {
+	for name, value := range args {
+		if strings.ContainsFunc(value, unicode.IsControl) {
+			return fmt.Errorf("value of '%s' contains control characters", name)
+		}
+		switch engineName {
+		case engine.S3:
+			if _, ok := s3.ObjectArgs[name]; !ok {
+				names := make([]string, 0, len(s3.ObjectArgs))
+				for known := range s3.ObjectArgs {
+					names = append(names, known)
+				}
+				sort.Strings(names)
+				return fmt.Errorf("unknown s3 argument '%s': must be one of %s", name, strings.Join(names, ", "))
+			}
+		default:
+			if !engineFlagPattern.MatchString(name) {
+				return fmt.Errorf("invalid flag name '%s': use lowercase letters, digits and '-', without leading dashes", name)
+			}
+			if engineName == engine.Rclone && slices.Contains(rclone.ReservedFlags, name) {
+				return fmt.Errorf("flag '%s' is set by the rclone engine", name)
+			}
+		}
+	}
+	return nil
+}
+
+// GetEngine returns the engine that runs the node's uploads (default bv)
+func (n *NodeConfig) GetEngine() string {
+	if n.Engine == "" {
+		return engine.BV
+	}
+	return n.Engine
+}
+
+// Commands returns the commands the node's configuration runs besides bv: env for its
+// hooks, which run through env(1), its preflight and incremental upload commands and
+// its rclone binary, or its container runtime when they run in a container. Commands of
+// nodes in pods run through the Kubernetes API, not on the host.
+func (n *NodeConfig) Commands() []string {
+	if n.Container != nil {
+		if n.Container.GetRuntime() == kube.RuntimeKubernetes {
+			return nil
+		}
+		return []string{n.Container.GetRuntime()}
+	}
+	var commands []string
+	if n.Hooks != nil && len(n.Hooks.PreUpload)+len(n.Hooks.PostUpload) > 0 {
+		commands = append(commands, "env")
+	}
+	if n.Preflight != nil && len(n.Preflight.Command) > 0 {
+		commands = append(commands, n.Preflight.Command[0])
+	}
+	if n.Incremental != nil && len(n.Incremental.Command) > 0 {
+		commands = append(commands, n.Incremental.Command[0])
+	}
+	if n.GetEngine() == engine.Rclone {
+		binary := rclone.DefaultBinary
+		if n.Rclone != nil && n.Rclone.Binary != "" {
+			binary = n.Rclone.Binary
+		}
+		commands = append(commands, binary)
+	}
+	return commands
+}
+
+// GetFinalityTimeout returns how long to wait for finality before giving up (default 30 minutes)
+func (n *NodeConfig) GetFinalityTimeout() time.Duration {
+	if n.FinalityTimeout == "" {
+		return 30 * time.Minute
+	}
+
+	finalityTimeout, err := time.ParseDuration(n.FinalityTimeout)
+	if err != nil {
+		return 30 * time.Minute
+	}
+
+	return finalityTimeout
 }
 
 // GetNodeNotifications returns the effective notification config for a node
-// (per-node notifications override global notifications)
+// (per-node notifications override the node's tenant's, which override global notifications)
 func (c *Config) GetNodeNotifications(nodeName string) *NotificationConfig {
 	node, exists := c.Nodes[nodeName]
 	if !exists {
@@ -236,9 +3054,69 @@ func (c *Config) GetNodeNotifications(nodeName string) *NotificationConfig {
 		return node.Notifications
 	}
 
+	if tenant, exists := c.Tenants[node.Tenant]; exists && tenant.Notifications != nil {
+		return tenant.Notifications
+	}
+
 	return c.Notifications
 }
 
+// ResolvedNode returns a node's configuration with its tenant's notifications in place
+// of none of its own, for jobs that fall back from a node's notifications to the global
+// ones
+func (c *Config) ResolvedNode(nodeName string) NodeConfig {
+	node := c.Nodes[nodeName]
+	if tenant, exists := c.Tenants[node.Tenant]; exists && node.Notifications == nil {
+		node.Notifications = tenant.Notifications
+	}
+	return node
+}
+
+// ResolvedNodes returns every node as ResolvedNode does
+func (c *Config) ResolvedNodes() map[string]NodeConfig {
+	nodes := make(map[string]NodeConfig, len(c.Nodes))
+	for nodeName := range c.Nodes {
+		nodes[nodeName] = c.ResolvedNode(nodeName)
+	}
+	return nodes
+}
+
+// GetNodeTenant returns the tenant a node belongs to, empty for nodes of no tenant
+func (c *Config) GetNodeTenant(nodeName string) string {
+	return c.Nodes[nodeName].Tenant
+}
+
+// TenantTokens maps the tokens of the tenants that have one to their tenant
+func (c *Config) TenantTokens() map[string]string {
+	tokens := make(map[string]string, len(c.Tenants))
+	for name, tenant := range c.Tenants {
+		if tenant.Token != "" {
+			tokens[tenant.Token] = name
+		}
+	}
+	return tokens
+}
+
+// GetTemplate returns the template of an event's notifications of a type: each field from
+// the type's templates, else the notifications-wide ones. Fields left empty use the
+// event's default template.
+func (n *NotificationConfig) GetTemplate(notificationType, event string) NotificationTemplateConfig {
+	var tmpl NotificationTemplateConfig
+	if n == nil {
+		return tmpl
+	}
+	for _, templates := range []map[string]NotificationTemplateConfig{n.Types[notificationType].Templates, n.Templates} {
+		configured := templates[event]
+		if tmpl.Title == "" {
+			tmpl.Title = configured.Title
+		}
+		if tmpl.Body == "" {
+			tmpl.Body = configured.Body
+		}
+	}
+	return tmpl
+}
+
 // GetNotificationURL returns the URL for a specific notification type from the config
 // Returns empty string if the type is not configured
 func (n *NotificationConfig) GetNotificationURL(notificationType string) string {
@@ -3,7 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -111,6 +117,11 @@ nodes:
 	if config.Schedule != "0 * * * * *" {
 		t.Errorf("Expected default schedule '0 * * * * *', got '%s'", config.Schedule)
 	}
+
+	// Verify default blob retention schedule
+	if config.BlobRetentionSchedule != "0 */15 * * * *" {
+		t.Errorf("Expected default blob retention schedule '0 */15 * * * *', got '%s'", config.BlobRetentionSchedule)
+	}
 }
 
 func TestLoadConfigInvalidFile(t *testing.T) {
@@ -139,6 +150,46 @@ this is not: valid: yaml: content
 	}
 }
 
+func TestReadConfigSkipsValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+database:
+  host: localhost
+  port: 5432
+  database: snapd
+  user: snapd
+nodes:
+  broken:
+    protocol: ethereum
+    url: http://localhost:8545
+    schedule: "not a schedule"
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Fatal("Expected LoadConfig to reject the invalid schedule")
+	}
+
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfig failed: %v", err)
+	}
+	if _, exists := config.Nodes["broken"]; !exists {
+		t.Error("Expected the invalid node to be read")
+	}
+	if config.Schedule != "0 * * * * *" {
+		t.Errorf("Expected defaults applied, got schedule '%s'", config.Schedule)
+	}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected Validate to reject the invalid schedule")
+	}
+}
+
 func TestValidateCronSchedule(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -149,6 +200,12 @@ func TestValidateCronSchedule(t *testing.T) {
 		{"valid every 5 minutes", "0 */5 * * * *", false},
 		{"valid specific time", "0 0 */6 * * *", false},
 		{"valid complex", "0 0 0 * * 1-5", false},
+		{"valid 5-field", "*/15 * * * *", false},
+		{"valid descriptor", "@every 90m", false},
+		{"valid interval", "every 6h", false},
+		{"valid daily", "daily@03:00", false},
+		{"invalid interval", "every 7h", true},
+		{"invalid daily time", "daily@25:00", true},
 		{"invalid format", "invalid", true},
 		{"invalid too many fields", "0 * * * * * *", true},
 		{"invalid too few fields", "* * *", true},
@@ -218,6 +275,81 @@ func TestDatabaseConfigValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid sqlite config",
+			config: DatabaseConfig{
+				Driver: "sqlite",
+				Path:   "/var/lib/snapperd/snapperd.db",
+			},
+			wantErr: false,
+		},
+		{
+			name: "sqlite missing path",
+			config: DatabaseConfig{
+				Driver: "sqlite",
+			},
+			wantErr: true,
+		},
+		{
+			name: "mutual TLS",
+			config: DatabaseConfig{
+				Host:        "db.example.com",
+				Port:        5432,
+				Database:    "snapd",
+				User:        "snapd",
+				SSLMode:     "verify-full",
+				SSLRootCert: "/etc/snapperd/tls/ca.crt",
+				SSLCert:     "/etc/snapperd/tls/client.crt",
+				SSLKey:      "/etc/snapperd/tls/client.key",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported ssl mode",
+			config: DatabaseConfig{
+				Host:     "localhost",
+				Port:     5432,
+				Database: "snapd",
+				User:     "snapd",
+				SSLMode:  "prefer",
+			},
+			wantErr: true,
+		},
+		{
+			name: "client certificate without key",
+			config: DatabaseConfig{
+				Host:     "db.example.com",
+				Port:     5432,
+				Database: "snapd",
+				User:     "snapd",
+				SSLMode:  "verify-full",
+				SSLCert:  "/etc/snapperd/tls/client.crt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "certificates with TLS disabled",
+			config: DatabaseConfig{
+				Host:        "localhost",
+				Port:        5432,
+				Database:    "snapd",
+				User:        "snapd",
+				SSLMode:     "disable",
+				SSLRootCert: "/etc/snapperd/tls/ca.crt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported driver",
+			config: DatabaseConfig{
+				Driver:   "mysql",
+				Host:     "localhost",
+				Port:     3306,
+				Database: "snapd",
+				User:     "snapd",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -291,6 +423,272 @@ func TestNodeConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid splay",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Splay:    "15m",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid splay",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Splay:    "15",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative splay",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Splay:    "-5m",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid timezone",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "daily@03:00",
+				Timezone: "Europe/Berlin",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid slo",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				SLO:      &SLOConfig{Target: 95, MaxDuration: "8h", MinBandwidth: "50MB/s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "slo without objective",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				SLO:      &SLOConfig{Target: 95},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown timezone",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "daily@03:00",
+				Timezone: "Europe/Atlantis",
+			},
+			wantErr: true,
+		},
+		{
+			name: "generic with metrics",
+			config: NodeConfig{
+				Protocol: "generic",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metrics: []MetricQueryConfig{
+					{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result", Format: "hex"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "generic without metrics",
+			config: NodeConfig{
+				Protocol: "generic",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+			},
+			wantErr: true,
+		},
+		{
+			name: "generic metric without method",
+			config: NodeConfig{
+				Protocol: "generic",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metrics:  []MetricQueryConfig{{Key: "latest_block", Path: "$.result"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generic metric with invalid path",
+			config: NodeConfig{
+				Protocol: "generic",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metrics:  []MetricQueryConfig{{Key: "latest_block", Method: "eth_blockNumber", Path: "result"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generic metric with unknown format",
+			config: NodeConfig{
+				Protocol: "generic",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metrics:  []MetricQueryConfig{{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result", Format: "float"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generic duplicate metric keys",
+			config: NodeConfig{
+				Protocol: "generic",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metrics: []MetricQueryConfig{
+					{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result"},
+					{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics on a built-in protocol",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metrics:  []MetricQueryConfig{{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid incremental",
+			config: NodeConfig{
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				Incremental: &IncrementalConfig{Command: []string{"/usr/local/bin/upload-incremental", "{node}", "{base_manifest}"}, FullEvery: 7},
+			},
+			wantErr: false,
+		},
+		{
+			name: "incremental without command",
+			config: NodeConfig{
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				Incremental: &IncrementalConfig{FullEvery: 7},
+			},
+			wantErr: true,
+		},
+		{
+			name: "incremental with negative full_every",
+			config: NodeConfig{
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				Incremental: &IncrementalConfig{Command: []string{"upload-incremental"}, FullEvery: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid metric validation",
+			config: NodeConfig{
+				Protocol:   "ethereum",
+				URL:        "http://localhost:8545",
+				Schedule:   "0 0 */6 * * *",
+				Validation: &MetricValidationConfig{MaxBlockChange: 100000},
+			},
+			wantErr: false,
+		},
+		{
+			name: "metric validation with negative max_block_change",
+			config: NodeConfig{
+				Protocol:   "ethereum",
+				URL:        "http://localhost:8545",
+				Schedule:   "0 0 */6 * * *",
+				Validation: &MetricValidationConfig{MaxBlockChange: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid with metadata",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metadata: map[string]string{"operator": "ops-team", "datacenter": "fra1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid max duration",
+			config: NodeConfig{
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				MaxDuration: "12h",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid max duration",
+			config: NodeConfig{
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				MaxDuration: "twelve hours",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max duration",
+			config: NodeConfig{
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				MaxDuration: "-1h",
+			},
+			wantErr: true,
+		},
+		{
+			name: "wait for finality with timeout",
+			config: NodeConfig{
+				Protocol:        "ethereum",
+				URL:             "http://localhost:8545",
+				Schedule:        "0 0 */6 * * *",
+				WaitForFinality: true,
+				FinalityTimeout: "45m",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid finality timeout",
+			config: NodeConfig{
+				Protocol:        "ethereum",
+				URL:             "http://localhost:8545",
+				Schedule:        "0 0 */6 * * *",
+				WaitForFinality: true,
+				FinalityTimeout: "soon",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty metadata key",
+			config: NodeConfig{
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+				Metadata: map[string]string{"": "value"},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -344,14 +742,44 @@ func TestNotificationConfigValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := tt.config.Validate()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("NotificationConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
-			}
+		{
+			name: "failure log lines",
+			config: NotificationConfig{
+				FailureLogLines: 20,
+				Types: map[string]NotificationTypeConfig{
+					"discord": {URL: "https://discord.com/api/webhooks/test"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative failure log lines",
+			config: NotificationConfig{
+				FailureLogLines: -1,
+				Types: map[string]NotificationTypeConfig{
+					"discord": {URL: "https://discord.com/api/webhooks/test"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "too many failure log lines",
+			config: NotificationConfig{
+				FailureLogLines: MaxFailureLogLines + 1,
+				Types: map[string]NotificationTypeConfig{
+					"discord": {URL: "https://discord.com/api/webhooks/test"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NotificationConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
 		})
 	}
 }
@@ -392,6 +820,231 @@ func TestGetNodeSchedule(t *testing.T) {
 	}
 }
 
+func TestSLOConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		slo     SLOConfig
+		wantErr bool
+	}{
+		{name: "duration", slo: SLOConfig{Target: 95, MaxDuration: "8h"}},
+		{name: "bandwidth", slo: SLOConfig{Target: 99.9, MinBandwidth: "1.5GiB/s", Window: "168h"}},
+		{name: "target above 100", slo: SLOConfig{Target: 101, MaxDuration: "8h"}, wantErr: true},
+		{name: "missing target", slo: SLOConfig{MaxDuration: "8h"}, wantErr: true},
+		{name: "invalid duration", slo: SLOConfig{Target: 95, MaxDuration: "8"}, wantErr: true},
+		{name: "invalid bandwidth", slo: SLOConfig{Target: 95, MinBandwidth: "fast"}, wantErr: true},
+		{name: "negative window", slo: SLOConfig{Target: 95, MaxDuration: "8h", Window: "-1h"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.slo.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	slo := SLOConfig{Target: 95, MaxDuration: "8h", MinBandwidth: "50mb/s"}
+	if got := slo.GetMaxDuration(); got != 8*time.Hour {
+		t.Errorf("GetMaxDuration() = %v", got)
+	}
+	if got := slo.GetMinBandwidth(); got != 50e6 {
+		t.Errorf("GetMinBandwidth() = %v", got)
+	}
+	if got := slo.GetWindow(); got != DefaultSLOWindow {
+		t.Errorf("GetWindow() = %v, want the default", got)
+	}
+
+	// A node's SLO overrides the global one
+	global := &SLOConfig{Target: 90, MaxDuration: "12h"}
+	cfg := &Config{SLO: global, Nodes: map[string]NodeConfig{
+		"eth": {SLO: &slo},
+		"arb": {},
+	}}
+	if got := cfg.GetNodeSLO("eth"); got != &slo {
+		t.Errorf("Expected eth's own SLO, got %v", got)
+	}
+	if got := cfg.GetNodeSLO("arb"); got != global {
+		t.Errorf("Expected the global SLO for arb, got %v", got)
+	}
+}
+
+func TestRetryConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetryConfig
+		wantErr bool
+	}{
+		{name: "valid", retry: RetryConfig{MaxAttempts: 3, Backoff: "5m"}},
+		{name: "single attempt", retry: RetryConfig{MaxAttempts: 1, Backoff: "5m"}, wantErr: true},
+		{name: "too many attempts", retry: RetryConfig{MaxAttempts: MaxRetryAttempts + 1, Backoff: "5m"}, wantErr: true},
+		{name: "missing backoff", retry: RetryConfig{MaxAttempts: 3}, wantErr: true},
+		{name: "negative backoff", retry: RetryConfig{MaxAttempts: 3, Backoff: "-5m"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.retry.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	retry := RetryConfig{MaxAttempts: 4, Backoff: "5m"}
+	for attempt, want := range map[int]time.Duration{1: 5 * time.Minute, 2: 10 * time.Minute, 3: 20 * time.Minute} {
+		if got := retry.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	node := NodeConfig{Protocol: "ethereum", Type: "archive", Schedule: "0 0 * * * *", URL: "http://localhost:8545", Retry: &RetryConfig{MaxAttempts: 3, Backoff: "soon"}}
+	if err := node.Validate(); err == nil || !strings.Contains(err.Error(), "invalid retry config") {
+		t.Errorf("Expected the node's invalid retry policy to be rejected, got %v", err)
+	}
+}
+
+func TestCircuitBreakerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker CircuitBreakerConfig
+		wantErr bool
+	}{
+		{name: "manual resume", breaker: CircuitBreakerConfig{Failures: 3}},
+		{name: "half-open", breaker: CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "6h"}},
+		{name: "no failures", breaker: CircuitBreakerConfig{}, wantErr: true},
+		{name: "invalid half_open_after", breaker: CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "later"}, wantErr: true},
+		{name: "negative half_open_after", breaker: CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "-1h"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.breaker.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := (&CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "6h"}).GetHalfOpenAfter(); got != 6*time.Hour {
+		t.Errorf("GetHalfOpenAfter() = %v, want 6h", got)
+	}
+	if got := (&CircuitBreakerConfig{Failures: 3}).GetHalfOpenAfter(); got != 0 {
+		t.Errorf("GetHalfOpenAfter() = %v, want 0 without half_open_after", got)
+	}
+
+	node := NodeConfig{Protocol: "ethereum", Type: "archive", Schedule: "0 0 * * * *", URL: "http://localhost:8545", CircuitBreaker: &CircuitBreakerConfig{}}
+	if err := node.Validate(); err == nil || !strings.Contains(err.Error(), "invalid circuit_breaker config") {
+		t.Errorf("Expected the node's invalid circuit breaker to be rejected, got %v", err)
+	}
+}
+
+func TestMetricRetryConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   MetricRetryConfig
+		wantErr bool
+	}{
+		{name: "defaults", retry: MetricRetryConfig{Attempts: 3}},
+		{name: "single attempt with timeout", retry: MetricRetryConfig{Attempts: 1, QueryTimeout: "5s"}},
+		{name: "backoff and timeout", retry: MetricRetryConfig{Attempts: 3, Backoff: "1s", QueryTimeout: "5s"}},
+		{name: "no attempts", retry: MetricRetryConfig{}, wantErr: true},
+		{name: "too many attempts", retry: MetricRetryConfig{Attempts: 11}, wantErr: true},
+		{name: "invalid backoff", retry: MetricRetryConfig{Attempts: 3, Backoff: "soon"}, wantErr: true},
+		{name: "negative query_timeout", retry: MetricRetryConfig{Attempts: 3, QueryTimeout: "-5s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.retry.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	retry := MetricRetryConfig{Attempts: 4, Backoff: "1s", QueryTimeout: "5s"}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second} {
+		if got := retry.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := (&MetricRetryConfig{Attempts: 3}).Delay(1); got != DefaultMetricRetryBackoff {
+		t.Errorf("Delay(1) = %v, want the default backoff", got)
+	}
+	if got := retry.GetQueryTimeout(); got != 5*time.Second {
+		t.Errorf("GetQueryTimeout() = %v, want 5s", got)
+	}
+
+	node := NodeConfig{Protocol: "ethereum", Type: "archive", Schedule: "0 0 * * * *", URL: "http://localhost:8545", MetricRetry: &MetricRetryConfig{}}
+	if err := node.Validate(); err == nil || !strings.Contains(err.Error(), "invalid metric_retry config") {
+		t.Errorf("Expected the node's invalid metric retry to be rejected, got %v", err)
+	}
+}
+
+func TestDigestConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		digest  DigestConfig
+		wantErr bool
+	}{
+		{name: "default", digest: DigestConfig{}},
+		{name: "weekly", digest: DigestConfig{Period: DigestWeekly, Schedule: "0 30 8 * * 5"}},
+		{name: "invalid period", digest: DigestConfig{Period: "monthly"}, wantErr: true},
+		{name: "invalid schedule", digest: DigestConfig{Schedule: "every now and then"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.digest.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	daily := DigestConfig{}
+	if daily.GetPeriod() != 24*time.Hour || daily.GetSchedule() != "0 0 9 * * *" {
+		t.Errorf("Unexpected daily defaults: %v, %s", daily.GetPeriod(), daily.GetSchedule())
+	}
+	weekly := DigestConfig{Period: DigestWeekly}
+	if weekly.GetPeriod() != 7*24*time.Hour || weekly.GetSchedule() != "0 0 9 * * 1" {
+		t.Errorf("Unexpected weekly defaults: %v, %s", weekly.GetPeriod(), weekly.GetSchedule())
+	}
+	custom := DigestConfig{Schedule: "0 0 18 * * *"}
+	if custom.GetSchedule() != "0 0 18 * * *" {
+		t.Errorf("Expected the configured schedule, got %s", custom.GetSchedule())
+	}
+}
+
+func TestGetNodeSchedule_Timezone(t *testing.T) {
+	config := &Config{
+		Schedule: "0 * * * * *",
+		Timezone: "America/New_York",
+		Nodes: map[string]NodeConfig{
+			"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "daily@03:00"},
+			"arb": {Protocol: "arbitrum", URL: "http://localhost:8547", Schedule: "daily@03:00", Timezone: "Asia/Tokyo"},
+		},
+	}
+
+	// Nodes without a timezone use the global one
+	if got := config.GetNodeSchedule("eth"); got != "CRON_TZ=America/New_York daily@03:00" {
+		t.Errorf("Expected the global timezone for eth, got '%s'", got)
+	}
+	if got := config.GetNodeSchedule("arb"); got != "CRON_TZ=Asia/Tokyo daily@03:00" {
+		t.Errorf("Expected the node's timezone for arb, got '%s'", got)
+	}
+	if got := config.GetNodeLocation("arb").String(); got != "Asia/Tokyo" {
+		t.Errorf("Expected arb's location Asia/Tokyo, got %s", got)
+	}
+	if got := config.GetLocation().String(); got != "America/New_York" {
+		t.Errorf("Expected the global location America/New_York, got %s", got)
+	}
+
+	// Without any timezone, schedules follow the host clock
+	config.Timezone = ""
+	if got := config.GetNodeSchedule("eth"); got != "daily@03:00" {
+		t.Errorf("Expected eth's schedule unchanged, got '%s'", got)
+	}
+	if config.GetLocation() != time.Local {
+		t.Errorf("Expected the local timezone without a timezone set")
+	}
+
+	if err := validateTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("Expected an unknown timezone to be rejected")
+	}
+}
+
 func TestGetNodeNotifications(t *testing.T) {
 	globalNotif := &NotificationConfig{
 		Failure:  true,
@@ -453,6 +1106,82 @@ func TestGetNodeNotifications(t *testing.T) {
 	}
 }
 
+func TestTenants(t *testing.T) {
+	tenantNotif := &NotificationConfig{Failure: true, Types: map[string]NotificationTypeConfig{"slack": {URL: "https://hooks.slack.com/services/team-a"}}}
+	nodeNotif := &NotificationConfig{Complete: true, Types: map[string]NotificationTypeConfig{"discord": {URL: "https://discord.com/api/webhooks/arb-a"}}}
+	newConfig := func() *Config {
+		return &Config{
+			Schedule:      "0 * * * * *",
+			Database:      DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapperd/snapperd.db"},
+			Notifications: &NotificationConfig{Types: map[string]NotificationTypeConfig{"slack": {URL: "https://hooks.slack.com/services/global"}}},
+			NodeAPI:       &NodeAPIConfig{Listen: "127.0.0.1:8097", Token: "node-api-token-0001"},
+			Tenants: map[string]TenantConfig{
+				"team-a": {Token: "team-a-token-00001", Notifications: tenantNotif},
+				"team-b": {},
+			},
+			Nodes: map[string]NodeConfig{
+				"eth-a":    {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *", Tenant: "team-a"},
+				"arb-a":    {Protocol: "arbitrum", URL: "http://localhost:8547", Schedule: "0 0 */6 * * *", Tenant: "team-a", Notifications: nodeNotif},
+				"sol-none": {Protocol: "solana", URL: "http://localhost:8899", Schedule: "0 0 */6 * * *"},
+			},
+		}
+	}
+
+	if err := newConfig().Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{name: "unknown node tenant", modify: func(c *Config) {
+			c.Nodes["eth-a"] = NodeConfig{Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *", Tenant: "team-c"}
+		}},
+		{name: "invalid tenant name", modify: func(c *Config) { c.Tenants["team a"] = TenantConfig{} }},
+		{name: "short token", modify: func(c *Config) { c.Tenants["team-b"] = TenantConfig{Token: "short"} }},
+		{name: "shared token", modify: func(c *Config) { c.Tenants["team-b"] = TenantConfig{Token: "team-a-token-00001"} }},
+		{name: "node API token", modify: func(c *Config) { c.Tenants["team-b"] = TenantConfig{Token: "node-api-token-0001"} }},
+		{name: "invalid notifications", modify: func(c *Config) {
+			c.Tenants["team-b"] = TenantConfig{Notifications: &NotificationConfig{Types: map[string]NotificationTypeConfig{"slack": {}}}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			tt.modify(c)
+			if err := c.Validate(); err == nil {
+				t.Fatal("expected a validation error")
+			}
+		})
+	}
+
+	// Node notifications override the tenant's, which override the global ones
+	c := newConfig()
+	if got := c.GetNodeNotifications("eth-a"); got != tenantNotif {
+		t.Errorf("expected the tenant's notifications for eth-a, got %+v", got)
+	}
+	if got := c.GetNodeNotifications("arb-a"); got != nodeNotif {
+		t.Errorf("expected the node's notifications for arb-a, got %+v", got)
+	}
+	if got := c.GetNodeNotifications("sol-none"); got != c.Notifications {
+		t.Errorf("expected the global notifications for sol-none, got %+v", got)
+	}
+	if nodes := c.ResolvedNodes(); nodes["eth-a"].Notifications != tenantNotif || nodes["arb-a"].Notifications != nodeNotif || nodes["sol-none"].Notifications != nil {
+		t.Errorf("unexpected resolved notifications: %+v", nodes)
+	}
+	if c.Nodes["eth-a"].Notifications != nil {
+		t.Error("expected ResolvedNodes to leave the configuration unchanged")
+	}
+
+	if c.GetNodeTenant("eth-a") != "team-a" || c.GetNodeTenant("sol-none") != "" {
+		t.Errorf("unexpected node tenants")
+	}
+	if tokens := c.TenantTokens(); len(tokens) != 1 || tokens["team-a-token-00001"] != "team-a" {
+		t.Errorf("expected team-a's token only, got %v", tokens)
+	}
+}
+
 func TestConfigValidateNoNodes(t *testing.T) {
 	config := &Config{
 		Schedule: "0 * * * * *",
@@ -495,6 +1224,1144 @@ func TestConfigValidateInvalidGlobalSchedule(t *testing.T) {
 	}
 }
 
+func TestConfigValidateNegativeStallIntervals(t *testing.T) {
+	config := &Config{
+		Schedule:       "0 * * * * *",
+		StallIntervals: -1,
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "snapd",
+			User:     "snapd",
+		},
+		Nodes: map[string]NodeConfig{
+			"test": {
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for negative stall_intervals")
+	}
+}
+
+func TestConfigValidateNegativeMaxConcurrentUploads(t *testing.T) {
+	config := &Config{
+		Schedule:             "0 * * * * *",
+		MaxConcurrentUploads: -1,
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "snapd",
+			User:     "snapd",
+		},
+		Nodes: map[string]NodeConfig{
+			"test": {
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for negative max_concurrent_uploads")
+	}
+
+	config.MaxConcurrentUploads = 2
+	if err := config.Validate(); err != nil {
+		t.Errorf("Unexpected error with max_concurrent_uploads 2: %v", err)
+	}
+}
+
+func TestConfigValidateIncrementalRequiresContentListing(t *testing.T) {
+	config := &Config{
+		Schedule: "0 * * * * *",
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "snapd",
+			User:     "snapd",
+		},
+		Nodes: map[string]NodeConfig{
+			"test": {
+				Protocol:    "ethereum",
+				URL:         "http://localhost:8545",
+				Schedule:    "0 0 */6 * * *",
+				Incremental: &IncrementalConfig{Command: []string{"upload-incremental", "{base_manifest}"}},
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for incremental snapshots without content_listing")
+	}
+
+	config.ContentListing.Command = []string{"list-snapshot", "{node}"}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Unexpected error with content_listing configured: %v", err)
+	}
+}
+
+func TestConfigMonitorLagThreshold(t *testing.T) {
+	tests := []struct {
+		threshold string
+		want      time.Duration
+		wantErr   bool
+	}{
+		{threshold: "", want: 0},
+		{threshold: "10m", want: 10 * time.Minute},
+		{threshold: "soon", wantErr: true},
+		{threshold: "-5m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.threshold, func(t *testing.T) {
+			config := &Config{
+				Schedule:            "0 * * * * *",
+				MonitorLagThreshold: tt.threshold,
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Port:     5432,
+					Database: "snapd",
+					User:     "snapd",
+				},
+				Nodes: map[string]NodeConfig{
+					"test": {
+						Protocol: "ethereum",
+						URL:      "http://localhost:8545",
+						Schedule: "0 0 */6 * * *",
+					},
+				},
+			}
+
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && config.GetMonitorLagThreshold() != tt.want {
+				t.Errorf("GetMonitorLagThreshold() = %v, want %v", config.GetMonitorLagThreshold(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigTriggerDebounce(t *testing.T) {
+	tests := []struct {
+		debounce string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{debounce: "", want: 0},
+		{debounce: "0s", want: 0},
+		{debounce: "2m", want: 2 * time.Minute},
+		{debounce: "soon", wantErr: true},
+		{debounce: "-1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.debounce, func(t *testing.T) {
+			config := &Config{
+				Schedule:        "0 * * * * *",
+				TriggerDebounce: tt.debounce,
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Port:     5432,
+					Database: "snapd",
+					User:     "snapd",
+				},
+				Nodes: map[string]NodeConfig{
+					"test": {
+						Protocol: "ethereum",
+						URL:      "http://localhost:8545",
+						Schedule: "0 0 */6 * * *",
+					},
+				},
+			}
+
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && config.GetTriggerDebounce() != tt.want {
+				t.Errorf("GetTriggerDebounce() = %v, want %v", config.GetTriggerDebounce(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigMetricCollection(t *testing.T) {
+	tests := []struct {
+		name            string
+		concurrency     int
+		timeout         string
+		wantConcurrency int
+		wantTimeout     time.Duration
+		wantErr         bool
+	}{
+		{name: "defaults", wantConcurrency: DefaultMetricConcurrency, wantTimeout: DefaultMetricTimeout},
+		{name: "set", concurrency: 16, timeout: "5s", wantConcurrency: 16, wantTimeout: 5 * time.Second},
+		{name: "negative concurrency", concurrency: -1, wantErr: true},
+		{name: "invalid timeout", timeout: "soon", wantErr: true},
+		{name: "zero timeout", timeout: "0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Schedule:          "0 * * * * *",
+				MetricConcurrency: tt.concurrency,
+				MetricTimeout:     tt.timeout,
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Port:     5432,
+					Database: "snapd",
+					User:     "snapd",
+				},
+				Nodes: map[string]NodeConfig{
+					"test": {
+						Protocol: "ethereum",
+						URL:      "http://localhost:8545",
+						Schedule: "0 0 */6 * * *",
+					},
+				},
+			}
+
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if config.GetMetricConcurrency() != tt.wantConcurrency {
+				t.Errorf("GetMetricConcurrency() = %d, want %d", config.GetMetricConcurrency(), tt.wantConcurrency)
+			}
+			if config.GetMetricTimeout() != tt.wantTimeout {
+				t.Errorf("GetMetricTimeout() = %v, want %v", config.GetMetricTimeout(), tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestConfigOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      *OutputConfig
+		wantStatus  []string
+		wantHistory []string
+		wantColor   string
+		wantErr     string
+	}{
+		{name: "defaults", wantStatus: DefaultStatusColumns, wantHistory: DefaultHistoryColumns, wantColor: ColorAuto},
+		{
+			name:        "set",
+			output:      &OutputConfig{StatusColumns: []string{"progress", "agent"}, HistoryColumns: []string{"node", "status", "block"}, Color: ColorNever},
+			wantStatus:  []string{"progress", "agent"},
+			wantHistory: []string{"node", "status", "block"},
+			wantColor:   ColorNever,
+		},
+		{name: "unknown status column", output: &OutputConfig{StatusColumns: []string{"eta", "speed"}}, wantErr: "invalid status_columns: unknown column 'speed'"},
+		{name: "duplicate history column", output: &OutputConfig{HistoryColumns: []string{"id", "id"}}, wantErr: "column 'id' is listed more than once"},
+		{name: "invalid color", output: &OutputConfig{Color: "sometimes"}, wantErr: "invalid color 'sometimes'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Schedule: "0 * * * * *",
+				Output:   tt.output,
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Port:     5432,
+					Database: "snapd",
+					User:     "snapd",
+				},
+				Nodes: map[string]NodeConfig{
+					"test": {
+						Protocol: "ethereum",
+						URL:      "http://localhost:8545",
+						Schedule: "0 0 */6 * * *",
+					},
+				},
+			}
+
+			err := config.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !slices.Equal(config.GetStatusColumns(), tt.wantStatus) {
+				t.Errorf("GetStatusColumns() = %v, want %v", config.GetStatusColumns(), tt.wantStatus)
+			}
+			if !slices.Equal(config.GetHistoryColumns(), tt.wantHistory) {
+				t.Errorf("GetHistoryColumns() = %v, want %v", config.GetHistoryColumns(), tt.wantHistory)
+			}
+			if config.GetColor() != tt.wantColor {
+				t.Errorf("GetColor() = %s, want %s", config.GetColor(), tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestConfigMaxSnapshotAge(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  string
+		node    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "disabled", want: 0},
+		{name: "global", global: "48h", want: 48 * time.Hour},
+		{name: "node override", global: "48h", node: "12h", want: 12 * time.Hour},
+		{name: "node only", node: "72h", want: 72 * time.Hour},
+		{name: "invalid global", global: "two days", wantErr: true},
+		{name: "negative node", node: "-1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Schedule:       "0 * * * * *",
+				MaxSnapshotAge: tt.global,
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Port:     5432,
+					Database: "snapd",
+					User:     "snapd",
+				},
+				Nodes: map[string]NodeConfig{
+					"test": {
+						Protocol:       "ethereum",
+						URL:            "http://localhost:8545",
+						Schedule:       "0 0 */6 * * *",
+						MaxSnapshotAge: tt.node,
+					},
+				},
+			}
+
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && config.GetMaxSnapshotAge("test") != tt.want {
+				t.Errorf("GetMaxSnapshotAge() = %v, want %v", config.GetMaxSnapshotAge("test"), tt.want)
+			}
+		})
+	}
+}
+
+func TestBVStatusRulesConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   BVStatusRulesConfig
+		wantErr bool
+	}{
+		{name: "empty", rules: BVStatusRulesConfig{}},
+		{name: "patterns", rules: BVStatusRulesConfig{NotRunning: []string{"job is paused"}, NotFound: []string{"no such job"}}},
+		{name: "blank pattern", rules: BVStatusRulesConfig{NotFound: []string{"  "}}, wantErr: true},
+		{name: "replace without patterns", rules: BVStatusRulesConfig{ReplaceDefaults: true}, wantErr: true},
+		{name: "replace with patterns", rules: BVStatusRulesConfig{ReplaceDefaults: true, NotRunning: []string{"idle"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContentListingConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		listing     ContentListingConfig
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{name: "disabled", listing: ContentListingConfig{}},
+		{name: "command", listing: ContentListingConfig{Command: []string{"bv", "node", "job", "{node}", "manifest", "upload"}}, wantEnabled: true},
+		{name: "blank executable", listing: ContentListingConfig{Command: []string{" ", "list"}}, wantEnabled: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.listing.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.listing.Enabled() != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", tt.listing.Enabled(), tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestPreflightConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		preflight   PreflightConfig
+		wantBytes   uint64
+		wantPercent float64
+		wantErr     bool
+	}{
+		{name: "gates without disk threshold", preflight: PreflightConfig{RPC: true, Synced: true}},
+		{name: "decimal size", preflight: PreflightConfig{MinFreeDisk: "500GB"}, wantBytes: 500e9},
+		{name: "binary size", preflight: PreflightConfig{MinFreeDisk: "1.5TiB"}, wantBytes: 3 << 39},
+		{name: "lower case size", preflight: PreflightConfig{MinFreeDisk: "200gb"}, wantBytes: 200e9},
+		{name: "percentage", preflight: PreflightConfig{MinFreeDisk: "10%"}, wantPercent: 10},
+		{name: "percentage out of range", preflight: PreflightConfig{MinFreeDisk: "100%"}, wantErr: true},
+		{name: "size without unit", preflight: PreflightConfig{MinFreeDisk: "500"}, wantErr: true},
+		{name: "negative size", preflight: PreflightConfig{MinFreeDisk: "-5GB"}, wantErr: true},
+		{name: "command", preflight: PreflightConfig{Command: []string{"/usr/local/bin/node-healthy", "{node}"}, CommandTimeout: "30s"}},
+		{name: "blank command", preflight: PreflightConfig{Command: []string{" "}}, wantErr: true},
+		{name: "invalid command timeout", preflight: PreflightConfig{Command: []string{"true"}, CommandTimeout: "soon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.preflight.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			bytes, percent, _ := tt.preflight.GetMinFreeDisk()
+			if bytes != tt.wantBytes || percent != tt.wantPercent {
+				t.Errorf("GetMinFreeDisk() = %d, %v, want %d, %v", bytes, percent, tt.wantBytes, tt.wantPercent)
+			}
+		})
+	}
+}
+
+func TestHooksConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		hooks       HooksConfig
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "default timeout", hooks: HooksConfig{PreUpload: []string{"systemctl stop compaction@$NODE_NAME"}}, wantTimeout: DefaultHookTimeout},
+		{name: "custom timeout", hooks: HooksConfig{PostUpload: []string{"curl -fsS https://example.com/done"}, Timeout: "30s"}, wantTimeout: 30 * time.Second},
+		{name: "blank command", hooks: HooksConfig{PreUpload: []string{"true", " "}}, wantErr: true},
+		{name: "invalid timeout", hooks: HooksConfig{PreUpload: []string{"true"}, Timeout: "soon"}, wantErr: true},
+		{name: "zero timeout", hooks: HooksConfig{PreUpload: []string{"true"}, Timeout: "0s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hooks.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.hooks.GetTimeout() != tt.wantTimeout {
+				t.Errorf("GetTimeout() = %v, want %v", tt.hooks.GetTimeout(), tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestVerificationConfigValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		verification VerificationConfig
+		wantErr      bool
+	}{
+		{name: "valid", verification: VerificationConfig{Restore: []string{"restore-snapshot"}, Cleanup: []string{"stop-client"}, RPCURL: "http://localhost:18545", StartupTimeout: "30m", BlockTolerance: 10}},
+		{name: "no restore command", verification: VerificationConfig{RPCURL: "http://localhost:18545"}, wantErr: true},
+		{name: "blank cleanup command", verification: VerificationConfig{Restore: []string{"true"}, Cleanup: []string{" "}, RPCURL: "http://localhost:18545"}, wantErr: true},
+		{name: "no rpc_url", verification: VerificationConfig{Restore: []string{"true"}}, wantErr: true},
+		{name: "invalid rpc_url", verification: VerificationConfig{Restore: []string{"true"}, RPCURL: "localhost"}, wantErr: true},
+		{name: "zero timeout", verification: VerificationConfig{Restore: []string{"true"}, RPCURL: "http://localhost:18545", Timeout: "0s"}, wantErr: true},
+		{name: "invalid startup timeout", verification: VerificationConfig{Restore: []string{"true"}, RPCURL: "http://localhost:18545", StartupTimeout: "soon"}, wantErr: true},
+		{name: "negative block tolerance", verification: VerificationConfig{Restore: []string{"true"}, RPCURL: "http://localhost:18545", BlockTolerance: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.verification.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	v := VerificationConfig{StartupTimeout: "30m", ScratchDir: "/scratch/{node}"}
+	if v.GetTimeout() != DefaultVerificationTimeout || v.GetStartupTimeout() != 30*time.Minute {
+		t.Errorf("GetTimeout() = %v, GetStartupTimeout() = %v", v.GetTimeout(), v.GetStartupTimeout())
+	}
+	if dir := v.GetScratchDir("eth-node"); dir != "/scratch/eth-node" {
+		t.Errorf("GetScratchDir() = %q, want /scratch/eth-node", dir)
+	}
+}
+
+func TestSnoozeConfigValidate(t *testing.T) {
+	valid := func() SnoozeConfig {
+		return SnoozeConfig{Listen: ":8095", BaseURL: "https://snapper.example.com", Secret: "0123456789abcdef"}
+	}
+
+	tests := []struct {
+		name          string
+		modify        func(s *SnoozeConfig)
+		wantDurations []time.Duration
+		wantTTL       time.Duration
+		wantErr       bool
+	}{
+		{name: "defaults", modify: func(s *SnoozeConfig) {}, wantDurations: []time.Duration{time.Hour, 4 * time.Hour, 24 * time.Hour}, wantTTL: DefaultSnoozeLinkTTL},
+		{name: "custom durations", modify: func(s *SnoozeConfig) { s.Durations = []string{"30m", "2h"}; s.LinkTTL = "24h" }, wantDurations: []time.Duration{30 * time.Minute, 2 * time.Hour}, wantTTL: 24 * time.Hour},
+		{name: "missing listen", modify: func(s *SnoozeConfig) { s.Listen = "" }, wantErr: true},
+		{name: "base_url without scheme", modify: func(s *SnoozeConfig) { s.BaseURL = "snapper.example.com" }, wantErr: true},
+		{name: "short secret", modify: func(s *SnoozeConfig) { s.Secret = "secret" }, wantErr: true},
+		{name: "invalid duration", modify: func(s *SnoozeConfig) { s.Durations = []string{"1h", "a while"} }, wantErr: true},
+		{name: "zero duration", modify: func(s *SnoozeConfig) { s.Durations = []string{"0s"} }, wantErr: true},
+		{name: "negative link_ttl", modify: func(s *SnoozeConfig) { s.LinkTTL = "-1h" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snooze := valid()
+			tt.modify(&snooze)
+			err := snooze.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := snooze.GetDurations(); !reflect.DeepEqual(got, tt.wantDurations) {
+				t.Errorf("GetDurations() = %v, want %v", got, tt.wantDurations)
+			}
+			if snooze.GetLinkTTL() != tt.wantTTL {
+				t.Errorf("GetLinkTTL() = %v, want %v", snooze.GetLinkTTL(), tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestLeaderElectionConfig(t *testing.T) {
+	newConfig := func(driver string, election *LeaderElectionConfig) *Config {
+		return &Config{
+			Schedule: "0 * * * * *",
+			Database: DatabaseConfig{
+				Driver:   driver,
+				Path:     "/var/lib/snapd/snapd.db",
+				Host:     "localhost",
+				Port:     5432,
+				Database: "snapd",
+				User:     "snapd",
+			},
+			Nodes: map[string]NodeConfig{
+				"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+			},
+			LeaderElection: election,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		driver       string
+		election     *LeaderElectionConfig
+		wantName     string
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{name: "defaults", driver: "postgres", election: &LeaderElectionConfig{}, wantName: DefaultLeaderElectionName, wantInterval: DefaultLeaderRetryInterval},
+		{name: "custom", driver: "postgres", election: &LeaderElectionConfig{Name: "snapper-eu", RetryInterval: "5s"}, wantName: "snapper-eu", wantInterval: 5 * time.Second},
+		{name: "invalid retry_interval", driver: "postgres", election: &LeaderElectionConfig{RetryInterval: "soon"}, wantErr: true},
+		{name: "zero retry_interval", driver: "postgres", election: &LeaderElectionConfig{RetryInterval: "0s"}, wantErr: true},
+		{name: "sqlite", driver: "sqlite", election: &LeaderElectionConfig{}, wantErr: true},
+		{name: "kubernetes lease", driver: "postgres", election: &LeaderElectionConfig{Backend: "kubernetes", LeaseDuration: "45s"}, wantName: DefaultLeaderElectionName, wantInterval: DefaultLeaderRetryInterval},
+		{name: "kubernetes lease with sqlite", driver: "sqlite", election: &LeaderElectionConfig{Backend: "kubernetes"}, wantName: DefaultLeaderElectionName, wantInterval: DefaultLeaderRetryInterval},
+		{name: "lease shorter than retry_interval", driver: "postgres", election: &LeaderElectionConfig{Backend: "kubernetes", RetryInterval: "30s", LeaseDuration: "20s"}, wantErr: true},
+		{name: "lease_duration with postgres", driver: "postgres", election: &LeaderElectionConfig{LeaseDuration: "30s"}, wantErr: true},
+		{name: "unknown backend", driver: "postgres", election: &LeaderElectionConfig{Backend: "etcd"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.driver, tt.election).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.election.GetName() != tt.wantName {
+				t.Errorf("GetName() = %q, want %q", tt.election.GetName(), tt.wantName)
+			}
+			if tt.election.GetRetryInterval() != tt.wantInterval {
+				t.Errorf("GetRetryInterval() = %v, want %v", tt.election.GetRetryInterval(), tt.wantInterval)
+			}
+		})
+	}
+
+	// Without leader election a sqlite database is fine
+	if err := newConfig("sqlite", nil).Validate(); err != nil {
+		t.Errorf("expected sqlite config without leader election to be valid, got %v", err)
+	}
+
+	if election := (&LeaderElectionConfig{}); election.GetBackend() != LeaderBackendPostgres || election.GetLeaseDuration() != DefaultLeaseDuration {
+		t.Errorf("GetBackend(), GetLeaseDuration() = %q, %v, want the defaults", election.GetBackend(), election.GetLeaseDuration())
+	}
+}
+
+func TestKubernetesConfig(t *testing.T) {
+	pod := &ContainerConfig{Runtime: "kubernetes", Pod: "geth-0", Namespace: "chains"}
+	tests := []struct {
+		name         string
+		kubernetes   *KubernetesConfig
+		nodes        map[string]NodeConfig
+		nodeAPI      *NodeAPIConfig
+		wantErr      bool
+		wantInterval time.Duration
+	}{
+		{name: "defaults", kubernetes: &KubernetesConfig{LabelSelector: "snapperd.io/enabled=true"}, wantInterval: DefaultNodePollInterval},
+		{name: "outside the cluster", kubernetes: &KubernetesConfig{LabelSelector: "app=geth", Namespace: "chains", PollInterval: "1m", APIServer: "https://10.0.0.1:6443", TokenFile: "/etc/snapperd/token", CAFile: "/etc/snapperd/ca.crt"}, wantInterval: time.Minute},
+		{name: "without label_selector", kubernetes: &KubernetesConfig{}, wantErr: true},
+		{name: "invalid poll_interval", kubernetes: &KubernetesConfig{LabelSelector: "app=geth", PollInterval: "100ms"}, wantErr: true},
+		{name: "invalid api_server", kubernetes: &KubernetesConfig{LabelSelector: "app=geth", APIServer: "10.0.0.1:6443"}, wantErr: true},
+		{name: "with node_api", kubernetes: &KubernetesConfig{LabelSelector: "app=geth"}, nodeAPI: &NodeAPIConfig{Listen: "127.0.0.1:8097", Token: "0123456789abcdef"}, wantErr: true},
+		{
+			name:         "node in a pod",
+			kubernetes:   &KubernetesConfig{LabelSelector: "app=geth"},
+			nodes:        map[string]NodeConfig{"geth": {Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots"}, Container: pod}},
+			wantInterval: DefaultNodePollInterval,
+		},
+		{
+			name:    "node in a pod without the kubernetes section",
+			nodes:   map[string]NodeConfig{"geth": {Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots"}, Container: pod}},
+			wantErr: true,
+		},
+		{
+			name:       "bv node in a pod",
+			kubernetes: &KubernetesConfig{LabelSelector: "app=geth"},
+			nodes:      map[string]NodeConfig{"geth": {Container: pod}},
+			wantErr:    true,
+		},
+		{
+			name:       "pod without a name",
+			kubernetes: &KubernetesConfig{LabelSelector: "app=geth"},
+			nodes:      map[string]NodeConfig{"geth": {Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots"}, Container: &ContainerConfig{Runtime: "kubernetes"}}},
+			wantErr:    true,
+		},
+		{
+			name:       "pod with a user",
+			kubernetes: &KubernetesConfig{LabelSelector: "app=geth"},
+			nodes:      map[string]NodeConfig{"geth": {Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots"}, Container: &ContainerConfig{Runtime: "kubernetes", Pod: "geth-0", User: "geth"}}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Discovered nodes need no nodes in the configuration file
+			nodes := make(map[string]NodeConfig)
+			for name, node := range tt.nodes {
+				node.Protocol, node.URL, node.Schedule = "ethereum", "http://localhost:8545", "0 0 */6 * * *"
+				nodes[name] = node
+			}
+			cfg := &Config{
+				Schedule:   "0 * * * * *",
+				Database:   DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+				Kubernetes: tt.kubernetes,
+				NodeAPI:    tt.nodeAPI,
+				Nodes:      nodes,
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.kubernetes.GetPollInterval() != tt.wantInterval {
+				t.Errorf("GetPollInterval() = %v, want %v", tt.kubernetes.GetPollInterval(), tt.wantInterval)
+			}
+		})
+	}
+
+	// Commands of nodes in pods do not run on the host
+	if commands := (&NodeConfig{Container: pod}).Commands(); commands != nil {
+		t.Errorf("Commands() = %v, want none", commands)
+	}
+}
+
+func TestStartupPolicy(t *testing.T) {
+	newConfig := func(policy string, health *HealthConfig) *Config {
+		return &Config{
+			Schedule: "0 * * * * *",
+			Database: DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+			Nodes: map[string]NodeConfig{
+				"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+			},
+			StartupPolicy: policy,
+			Health:        health,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		health     *HealthConfig
+		wantPolicy string
+		wantErr    bool
+	}{
+		{name: "default", wantPolicy: StartupPolicyStrict},
+		{name: "strict", policy: "strict", wantPolicy: StartupPolicyStrict},
+		{name: "degraded with health endpoint", policy: "degraded", health: &HealthConfig{Listen: "127.0.0.1:8096"}, wantPolicy: StartupPolicyDegraded},
+		{name: "unknown policy", policy: "lenient", wantErr: true},
+		{name: "health without listen", health: &HealthConfig{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(tt.policy, tt.health)
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.GetStartupPolicy() != tt.wantPolicy {
+				t.Errorf("GetStartupPolicy() = %q, want %q", cfg.GetStartupPolicy(), tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestSummaryAPIConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary SummaryAPIConfig
+		wantErr bool
+	}{
+		{name: "open endpoint", summary: SummaryAPIConfig{Listen: "127.0.0.1:8099"}},
+		{name: "with token", summary: SummaryAPIConfig{Listen: "127.0.0.1:8099", Token: "0123456789abcdef"}},
+		{name: "missing listen", summary: SummaryAPIConfig{}, wantErr: true},
+		{name: "short token", summary: SummaryAPIConfig{Listen: "127.0.0.1:8099", Token: "secret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.summary.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetricsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics MetricsConfig
+		wantErr bool
+	}{
+		{name: "open endpoint", metrics: MetricsConfig{Listen: "127.0.0.1:9105"}},
+		{name: "with token", metrics: MetricsConfig{Listen: "127.0.0.1:9105", Token: "0123456789abcdef"}},
+		{name: "missing listen", metrics: MetricsConfig{}, wantErr: true},
+		{name: "short token", metrics: MetricsConfig{Listen: "127.0.0.1:9105", Token: "secret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.metrics.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTracingConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracing  TracingConfig
+		wantErr  bool
+		wantURL  string
+		interval time.Duration
+	}{
+		{name: "collector base URL", tracing: TracingConfig{Endpoint: "http://localhost:4318"}, wantURL: "http://localhost:4318/v1/traces", interval: 5 * time.Second},
+		{name: "traces URL", tracing: TracingConfig{Endpoint: "https://tempo.example.com/v1/traces/", ExportInterval: "30s"}, wantURL: "https://tempo.example.com/v1/traces", interval: 30 * time.Second},
+		{name: "missing endpoint", tracing: TracingConfig{}, wantErr: true},
+		{name: "not a URL", tracing: TracingConfig{Endpoint: "localhost:4318"}, wantErr: true},
+		{name: "interval too short", tracing: TracingConfig{Endpoint: "http://localhost:4318", ExportInterval: "100ms"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tracing.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if url := tt.tracing.GetTracesURL(); url != tt.wantURL {
+				t.Errorf("GetTracesURL() = %s, want %s", url, tt.wantURL)
+			}
+			if interval := tt.tracing.GetExportInterval(); interval != tt.interval {
+				t.Errorf("GetExportInterval() = %s, want %s", interval, tt.interval)
+			}
+			if name := tt.tracing.GetServiceName(); name != "snapperd" {
+				t.Errorf("GetServiceName() = %s, want snapperd", name)
+			}
+		})
+	}
+}
+
+func TestBVOutputFormat(t *testing.T) {
+	for format, wantErr := range map[string]bool{"": false, "auto": false, "json": false, "text": false, "xml": true} {
+		cfg := &Config{
+			Schedule: "0 * * * * *",
+			Database: DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+			Nodes: map[string]NodeConfig{
+				"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+			},
+			BVOutputFormat: format,
+		}
+		if err := cfg.Validate(); (err != nil) != wantErr {
+			t.Errorf("Validate() with bv_output_format %q: error = %v, wantErr %v", format, err, wantErr)
+		}
+	}
+}
+
+func TestExecutorConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor ExecutorConfig
+		wantErr  bool
+	}{
+		{name: "sandbox", executor: ExecutorConfig{AllowedCommands: []string{"bv", "/usr/local/bin/snapshot-check"}, RunAsUser: "snapperd", WorkingDir: "/var/lib/snapperd", ScrubEnv: true, PassEnv: []string{"AWS_PROFILE"}, MaxOutputBytes: 4 << 20}},
+		{name: "relative command path", executor: ExecutorConfig{AllowedCommands: []string{"bin/bv"}}, wantErr: true},
+		{name: "empty command", executor: ExecutorConfig{AllowedCommands: []string{" "}}, wantErr: true},
+		{name: "relative working directory", executor: ExecutorConfig{WorkingDir: "snapperd"}, wantErr: true},
+		{name: "pass_env without scrub_env", executor: ExecutorConfig{PassEnv: []string{"AWS_PROFILE"}}, wantErr: true},
+		{name: "invalid variable name", executor: ExecutorConfig{ScrubEnv: true, PassEnv: []string{"A=B"}}, wantErr: true},
+		{name: "output limit too small", executor: ExecutorConfig{MaxOutputBytes: 1024}, wantErr: true},
+		{name: "bv timeouts", executor: ExecutorConfig{StatusTimeout: "30s", StartTimeout: "10m"}},
+		{name: "invalid status timeout", executor: ExecutorConfig{StatusTimeout: "soon"}, wantErr: true},
+		{name: "negative start timeout", executor: ExecutorConfig{StartTimeout: "-1m"}, wantErr: true},
+		{name: "bv concurrency", executor: ExecutorConfig{BVConcurrency: 8}},
+		{name: "default bv concurrency", executor: ExecutorConfig{BVConcurrency: 0}},
+		{name: "negative bv concurrency", executor: ExecutorConfig{BVConcurrency: -1}, wantErr: true},
+		{name: "bv concurrency too high", executor: ExecutorConfig{BVConcurrency: 1000}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.executor.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Commands the configuration names are allowed besides bv
+	cfg := &Config{
+		ContentListing: ContentListingConfig{Command: []string{"/usr/local/bin/list-snapshot", "{node}"}},
+		Nodes: map[string]NodeConfig{
+			"eth":     {Protocol: "ethereum", Hooks: &HooksConfig{PreUpload: []string{"systemctl stop compaction"}}, Preflight: &PreflightConfig{Command: []string{"check-peers", "{node}"}}},
+			"archive": {Protocol: "ethereum", Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "s3:snapshots"}},
+			"sol":     {Protocol: "solana", Hooks: &HooksConfig{PreUpload: []string{"solana-validator exit"}}, Container: &ContainerConfig{Name: "solana", Runtime: "podman"}},
+		},
+	}
+	want := []string{"bv", "/usr/local/bin/list-snapshot", "rclone", "env", "check-peers", "podman"}
+	if got := cfg.ExecutorCommands(); !slices.Equal(got, want) {
+		t.Errorf("ExecutorCommands() = %v, want %v", got, want)
+	}
+	cfg.Executor = &ExecutorConfig{AllowedCommands: []string{"/usr/bin/bv", "env"}}
+	want = []string{"/usr/bin/bv", "env", "/usr/local/bin/list-snapshot", "rclone", "check-peers", "podman"}
+	if got := cfg.ExecutorCommands(); !slices.Equal(got, want) {
+		t.Errorf("ExecutorCommands() with allowed_commands = %v, want %v", got, want)
+	}
+
+	// bv timeouts default when not set
+	if got := cfg.GetBVStatusTimeout(); got != DefaultBVStatusTimeout {
+		t.Errorf("GetBVStatusTimeout() = %v, want %v", got, DefaultBVStatusTimeout)
+	}
+	cfg.Executor.StatusTimeout, cfg.Executor.StartTimeout = "30s", "10m"
+	if got := cfg.GetBVStatusTimeout(); got != 30*time.Second {
+		t.Errorf("GetBVStatusTimeout() = %v, want 30s", got)
+	}
+	if got := cfg.GetBVStartTimeout(); got != 10*time.Minute {
+		t.Errorf("GetBVStartTimeout() = %v, want 10m", got)
+	}
+	if got := cfg.GetBVConcurrency(); got != executor.DefaultBVConcurrency {
+		t.Errorf("GetBVConcurrency() = %d, want %d", got, executor.DefaultBVConcurrency)
+	}
+	cfg.Executor.BVConcurrency = 1
+	if got := cfg.GetBVConcurrency(); got != 1 {
+		t.Errorf("GetBVConcurrency() = %d, want 1", got)
+	}
+	if got := (&Config{}).GetBVStartTimeout(); got != DefaultBVStartTimeout {
+		t.Errorf("GetBVStartTimeout() without executor = %v, want %v", got, DefaultBVStartTimeout)
+	}
+}
+
+func TestGuardrailsConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		guardrails GuardrailsConfig
+		wantErr    bool
+	}{
+		{name: "record by default", guardrails: GuardrailsConfig{MaxCPUPercent: 90}},
+		{name: "throttle", guardrails: GuardrailsConfig{MaxDiskIOPercent: 80, Action: "throttle", Command: "systemctl set-property --runtime blockvisor.service IOWeight=10", ResumeCommand: "systemctl set-property --runtime blockvisor.service IOWeight=100", Interval: "30s", RecoveryChecks: 4}},
+		{name: "no thresholds", guardrails: GuardrailsConfig{Action: "record"}, wantErr: true},
+		{name: "threshold above 100", guardrails: GuardrailsConfig{MaxMemoryPercent: 120}, wantErr: true},
+		{name: "pause without command", guardrails: GuardrailsConfig{MaxCPUPercent: 90, Action: "pause"}, wantErr: true},
+		{name: "command with record", guardrails: GuardrailsConfig{MaxCPUPercent: 90, Command: "true"}, wantErr: true},
+		{name: "unknown action", guardrails: GuardrailsConfig{MaxCPUPercent: 90, Action: "kill"}, wantErr: true},
+		{name: "interval too short", guardrails: GuardrailsConfig{MaxCPUPercent: 90, Interval: "100ms"}, wantErr: true},
+		{name: "negative recovery checks", guardrails: GuardrailsConfig{MaxCPUPercent: 90, RecoveryChecks: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Schedule: "0 * * * * *",
+				Database: DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+				Nodes: map[string]NodeConfig{
+					"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+				},
+				Guardrails: &tt.guardrails,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	defaults := &GuardrailsConfig{MaxCPUPercent: 90}
+	if defaults.GetInterval() != DefaultGuardrailInterval || defaults.GetAction() != GuardrailActionRecord ||
+		defaults.GetRecoveryChecks() != DefaultGuardrailRecoveryChecks || defaults.GetCommandTimeout() != DefaultGuardrailCommandTimeout {
+		t.Errorf("unexpected defaults: %v %s %d %v", defaults.GetInterval(), defaults.GetAction(), defaults.GetRecoveryChecks(), defaults.GetCommandTimeout())
+	}
+}
+
+func TestNodeEngine(t *testing.T) {
+	rcloneSettings := &RcloneConfig{Source: "/var/lib/{node}/data", Destination: "s3:snapshots/{node}"}
+
+	tests := []struct {
+		name    string
+		node    NodeConfig
+		wantErr bool
+	}{
+		{name: "bv by default", node: NodeConfig{}},
+		{name: "rclone", node: NodeConfig{Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots", Mode: "copy", Flags: []string{"--transfers", "16"}}}},
+		{name: "rclone without settings", node: NodeConfig{Engine: "rclone"}, wantErr: true},
+		{name: "rclone without destination", node: NodeConfig{Engine: "rclone", Rclone: &RcloneConfig{Source: "/data"}}, wantErr: true},
+		{name: "unknown rclone mode", node: NodeConfig{Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots", Mode: "move"}}, wantErr: true},
+		{name: "rclone settings with bv", node: NodeConfig{Rclone: rcloneSettings}, wantErr: true},
+		{name: "rclone with incremental", node: NodeConfig{Engine: "rclone", Rclone: rcloneSettings, Incremental: &IncrementalConfig{Command: []string{"upload-delta"}}}, wantErr: true},
+		{name: "unknown engine", node: NodeConfig{Engine: "restic"}, wantErr: true},
+		{name: "s3", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Endpoint: "http://minio:9000", PathStyle: true, PartSize: "128MiB", Compression: "none"}}},
+		{name: "s3 without settings", node: NodeConfig{Engine: "s3"}, wantErr: true},
+		{name: "s3 without bucket", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data"}}, wantErr: true},
+		{name: "s3 part size too small", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", PartSize: "1MB"}}, wantErr: true},
+		{name: "s3 endpoint without scheme", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Endpoint: "minio:9000"}}, wantErr: true},
+		{name: "s3 zstd compression", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Compression: "zstd"}}},
+		{name: "unknown s3 compression", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Compression: "brotli"}}, wantErr: true},
+		{name: "s3 key file encryption", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Encryption: &S3EncryptionConfig{KeyFile: "/etc/snapperd/snapshots.key", KeyID: "snapshots-2026"}}}},
+		{name: "s3 kms encryption", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Encryption: &S3EncryptionConfig{KMSKeyID: "alias/snapshots", KMSEndpoint: "https://kms.internal"}}}},
+		{name: "s3 encryption without key", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Encryption: &S3EncryptionConfig{KeyID: "snapshots-2026"}}}, wantErr: true},
+		{name: "s3 encryption with both keys", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Encryption: &S3EncryptionConfig{KeyFile: "/etc/snapperd/snapshots.key", KMSKeyID: "alias/snapshots"}}}, wantErr: true},
+		{name: "s3 kms encryption with key id", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Encryption: &S3EncryptionConfig{KMSKeyID: "alias/snapshots", KeyID: "other"}}}, wantErr: true},
+		{name: "node compression", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, Compression: &CompressionConfig{Algorithm: "lz4", Level: 9}}},
+		{name: "node compression level out of range", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, Compression: &CompressionConfig{Algorithm: "gzip", Level: 12}}, wantErr: true},
+		{name: "node compression level without compression", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, Compression: &CompressionConfig{Algorithm: "none", Level: 3}}, wantErr: true},
+		{name: "node compression without algorithm", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, Compression: &CompressionConfig{Level: 3}}, wantErr: true},
+		{name: "node compression and s3 compression", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots", Compression: "gzip"}, Compression: &CompressionConfig{Algorithm: "zstd"}}, wantErr: true},
+		{name: "node compression with bv", node: NodeConfig{Compression: &CompressionConfig{Algorithm: "zstd"}}, wantErr: true},
+		{name: "s3 settings with rclone", node: NodeConfig{Engine: "rclone", Rclone: rcloneSettings, S3: &S3Config{Source: "/data", Bucket: "snapshots"}}, wantErr: true},
+		{name: "s3 with incremental", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, Incremental: &IncrementalConfig{Command: []string{"upload-delta"}}}, wantErr: true},
+		{name: "rclone in a container", node: NodeConfig{Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots", LogDir: "/var/lib/snapperd/rclone"}, Container: &ContainerConfig{Name: "geth", User: "geth", WorkingDir: "/data"}}},
+		{name: "rclone in a container with the default log_dir", node: NodeConfig{Engine: "rclone", Rclone: rcloneSettings, Container: &ContainerConfig{Name: "geth"}}},
+		{name: "relative rclone log_dir", node: NodeConfig{Engine: "rclone", Rclone: &RcloneConfig{Source: "/data", Destination: "r2:snapshots", LogDir: "rclone"}}, wantErr: true},
+		{name: "containerd namespace", node: NodeConfig{Container: &ContainerConfig{Name: "geth", Runtime: "ctr", Namespace: "chains"}}},
+		{name: "namespace with docker", node: NodeConfig{Container: &ContainerConfig{Name: "geth", Namespace: "chains"}}, wantErr: true},
+		{name: "container without name", node: NodeConfig{Container: &ContainerConfig{Runtime: "podman"}}, wantErr: true},
+		{name: "unknown container runtime", node: NodeConfig{Container: &ContainerConfig{Name: "geth", Runtime: "lxc"}}, wantErr: true},
+		{name: "relative container working_dir", node: NodeConfig{Container: &ContainerConfig{Name: "geth", WorkingDir: "data"}}, wantErr: true},
+		{name: "container with incremental", node: NodeConfig{Container: &ContainerConfig{Name: "geth"}, Incremental: &IncrementalConfig{Command: []string{"upload-delta"}}}, wantErr: true},
+		{name: "bv engine args", node: NodeConfig{EngineArgs: map[string]string{"max-chunks": "100", "verbose": ""}}},
+		{name: "bv engine arg with dashes", node: NodeConfig{EngineArgs: map[string]string{"--max-chunks": "100"}}, wantErr: true},
+		{name: "engine arg with a newline", node: NodeConfig{EngineArgs: map[string]string{"label": "a\nb"}}, wantErr: true},
+		{name: "engine args with incremental", node: NodeConfig{EngineArgs: map[string]string{"verbose": ""}, Incremental: &IncrementalConfig{Command: []string{"upload-delta"}}}, wantErr: true},
+		{name: "rclone engine args", node: NodeConfig{Engine: "rclone", Rclone: rcloneSettings, EngineArgs: map[string]string{"s3-storage-class": "STANDARD_IA"}}},
+		{name: "rclone engine arg set by the engine", node: NodeConfig{Engine: "rclone", Rclone: rcloneSettings, EngineArgs: map[string]string{"log-file": "/tmp/x"}}, wantErr: true},
+		{name: "s3 engine args", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, EngineArgs: map[string]string{"storage_class": "GLACIER_IR", "tagging": "chain=eth"}}},
+		{name: "unknown s3 engine arg", node: NodeConfig{Engine: "s3", S3: &S3Config{Source: "/data", Bucket: "snapshots"}, EngineArgs: map[string]string{"transfers": "16"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := tt.node
+			node.Protocol, node.URL, node.Schedule = "ethereum", "http://localhost:8545", "0 0 */6 * * *"
+			if err := node.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if node := (&NodeConfig{}); node.GetEngine() != "bv" {
+		t.Errorf("GetEngine() = %q, want bv", node.GetEngine())
+	}
+	if node := (&NodeConfig{S3: &S3Config{Compression: "none"}}); node.GetCompression() != (CompressionConfig{Algorithm: "none"}) {
+		t.Errorf("GetCompression() = %+v, want the s3 compression", node.GetCompression())
+	}
+	if node := (&NodeConfig{Compression: &CompressionConfig{Algorithm: "zstd", Level: 19}}); node.GetCompression() != (CompressionConfig{Algorithm: "zstd", Level: 19}) {
+		t.Errorf("GetCompression() = %+v, want the node compression", node.GetCompression())
+	}
+	if size, err := (&S3Config{PartSize: "128MiB"}).GetPartSize(); err != nil || size != 128<<20 {
+		t.Errorf("GetPartSize() = %d, %v, want 128MiB", size, err)
+	}
+}
+
+func TestConsistencyGroups(t *testing.T) {
+	newConfig := func(groups map[string]ConsistencyGroupConfig) *Config {
+		return &Config{
+			Schedule: "0 * * * * *",
+			Database: DatabaseConfig{
+				Host:     "localhost",
+				Port:     5432,
+				Database: "snapd",
+				User:     "snapd",
+			},
+			Nodes: map[string]NodeConfig{
+				"eth-el": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+				"eth-cl": {Protocol: "ethereum", URL: "http://localhost:5052", Schedule: "0 0 */12 * * *"},
+				"arb":    {Protocol: "arbitrum", URL: "http://localhost:8547", Schedule: "0 0 */12 * * *"},
+			},
+			ConsistencyGroups: groups,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		groups  map[string]ConsistencyGroupConfig
+		wantErr bool
+	}{
+		{name: "valid", groups: map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-cl"}, Schedule: "0 0 0 * * *"}}},
+		{name: "single member", groups: map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el"}, Schedule: "0 0 0 * * *"}}, wantErr: true},
+		{name: "duplicate member", groups: map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-el"}, Schedule: "0 0 0 * * *"}}, wantErr: true},
+		{name: "missing schedule", groups: map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-cl"}}}, wantErr: true},
+		{name: "invalid schedule", groups: map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-cl"}, Schedule: "0 0 * *"}}, wantErr: true},
+		{name: "unknown member", groups: map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-vc"}, Schedule: "0 0 0 * * *"}}, wantErr: true},
+		{
+			name: "node in two groups",
+			groups: map[string]ConsistencyGroupConfig{
+				"eth":   {Nodes: []string{"eth-el", "eth-cl"}, Schedule: "0 0 0 * * *"},
+				"mixed": {Nodes: []string{"eth-el", "arb"}, Schedule: "0 0 0 * * *"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.groups).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Members start with the group, so they cannot be splayed
+	splayed := newConfig(map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-cl"}, Schedule: "0 0 0 * * *"}})
+	member := splayed.Nodes["eth-cl"]
+	member.Splay = "10m"
+	splayed.Nodes["eth-cl"] = member
+	if err := splayed.Validate(); err == nil {
+		t.Error("Expected a splayed group member to be rejected")
+	}
+	arb := splayed.Nodes["arb"]
+	arb.Splay = "10m"
+	splayed.Nodes["arb"] = arb
+	if got := splayed.GetNodeSplay("arb"); got != 10*time.Minute {
+		t.Errorf("Expected 10m splay for arb, got %v", got)
+	}
+	if got := splayed.GetNodeSplay("eth-cl"); got != 0 {
+		t.Errorf("Expected no splay for a group member, got %v", got)
+	}
+
+	// The group's schedule is evaluated in the global timezone, not a member's
+	zoned := newConfig(map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-cl"}, Schedule: "0 0 0 * * *"}})
+	member = zoned.Nodes["eth-el"]
+	member.Timezone = "Asia/Tokyo"
+	zoned.Nodes["eth-el"] = member
+	if err := zoned.Validate(); err == nil {
+		t.Error("Expected a group member with a timezone to be rejected")
+	}
+
+	// Members follow the group's schedule, other nodes keep their own
+	config := newConfig(map[string]ConsistencyGroupConfig{"eth": {Nodes: []string{"eth-el", "eth-cl"}, Schedule: "0 0 0 * * *"}})
+	if got := config.GetNodeConsistencyGroup("eth-cl"); got != "eth" {
+		t.Errorf("Expected eth-cl in group 'eth', got '%s'", got)
+	}
+	if got := config.GetNodeSchedule("eth-el"); got != "0 0 0 * * *" {
+		t.Errorf("Expected group schedule for eth-el, got '%s'", got)
+	}
+	if got := config.GetNodeSchedule("arb"); got != "0 0 */12 * * *" {
+		t.Errorf("Expected own schedule for arb, got '%s'", got)
+	}
+	if got := config.GetNodeConsistencyGroup("arb"); got != "" {
+		t.Errorf("Expected arb in no group, got '%s'", got)
+	}
+}
+
+func TestValidateBVCommandPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  []string
+		wantErr bool
+	}{
+		{name: "unset", prefix: nil},
+		{name: "sudo", prefix: []string{"sudo", "-n", "-u", "blockvisor"}},
+		{name: "command placeholder", prefix: []string{"su", "blockvisor", "-s", "/bin/sh", "-c", "{command}"}},
+		{name: "empty executable", prefix: []string{" ", "-u", "blockvisor"}, wantErr: true},
+		{name: "placeholder executable", prefix: []string{"{command}"}, wantErr: true},
+		{name: "repeated placeholder", prefix: []string{"sh", "-c", "{command} && {command}"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBVCommandPrefix(tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBVCommandPrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateInvalidBlobRetentionSchedule(t *testing.T) {
+	config := &Config{
+		Schedule:              "0 * * * * *",
+		BlobRetentionSchedule: "*/15 * * *",
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "snapd",
+			User:     "snapd",
+		},
+		Nodes: map[string]NodeConfig{
+			"test": {
+				Protocol: "ethereum",
+				URL:      "http://localhost:8545",
+				Schedule: "0 0 */6 * * *",
+			},
+		},
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Error("Expected error for invalid blob retention schedule")
+	}
+}
+
 func TestNotificationConfig_GetNotificationURL(t *testing.T) {
 	config := &NotificationConfig{
 		Types: map[string]NotificationTypeConfig{
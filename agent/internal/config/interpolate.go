@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variableNamePattern matches the names that may be referenced as ${NAME}
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LoadSecretsFile reads a secrets file: a YAML mapping of names to values, referenced in
+// the configuration as ${NAME}. The file must be a regular file that neither its group
+// nor other users can access, since it holds credentials in plain text.
+func LoadSecretsFile(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("secrets file %s is not a regular file", path)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return nil, fmt.Errorf("secrets file %s must not be accessible by group or others (mode %04o, expected 0600 or 0400)", path, perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	secrets := make(map[string]string)
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s: %w", path, err)
+	}
+	for name := range secrets {
+		if !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid name '%s' in secrets file %s: use letters, digits and '_'", name, path)
+		}
+	}
+	return secrets, nil
+}
+
+// interpolate expands ${NAME} references in every value of a parsed configuration, from
+// its secrets_file, else the environment. The secrets_file value itself may only
+// reference the environment. $${ is written for a literal ${. Keys are not expanded.
+func interpolate(root *yaml.Node) error {
+	env := func(name string) (string, bool) { return os.LookupEnv(name) }
+
+	var secrets map[string]string
+	if secretsFile := topLevelValue(root, "secrets_file"); secretsFile != nil {
+		if err := expandNode(secretsFile, env, nil); err != nil {
+			return err
+		}
+		if secretsFile.Value != "" {
+			var err error
+			if secrets, err = LoadSecretsFile(secretsFile.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	lookup := func(name string) (string, bool) {
+		if value, ok := secrets[name]; ok {
+			return value, true
+		}
+		return env(name)
+	}
+	return expandNode(root, lookup, topLevelValue(root, "secrets_file"))
+}
+
+// topLevelValue returns the value of a key of the document's top-level mapping, or nil
+func topLevelValue(root *yaml.Node, key string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// expandNode expands the references in the scalar values below a YAML node, except skip,
+// which is already expanded. Every undefined name is reported, with the line it is
+// referenced on.
+func expandNode(node *yaml.Node, lookup func(string) (string, bool), skip *yaml.Node) error {
+	var undefined []string
+	var walk func(n *yaml.Node, isKey bool) error
+	walk = func(n *yaml.Node, isKey bool) error {
+		switch n.Kind {
+		case yaml.ScalarNode:
+			if isKey || n == skip || !strings.Contains(n.Value, "${") {
+				return nil
+			}
+			expanded, missing, err := expandValue(n.Value, lookup)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			for _, name := range missing {
+				undefined = append(undefined, fmt.Sprintf("%s (line %d)", name, n.Line))
+			}
+			n.Value = expanded
+			// An unquoted value is typed by what it expands to, so port: ${DB_PORT} is a number
+			if n.Style&(yaml.TaggedStyle|yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				n.Tag = ""
+			}
+		case yaml.MappingNode:
+			for i, child := range n.Content {
+				if err := walk(child, i%2 == 0); err != nil {
+					return err
+				}
+			}
+		default:
+			for _, child := range n.Content {
+				if err := walk(child, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(node, false); err != nil {
+		return err
+	}
+	if len(undefined) > 0 {
+		sort.Strings(undefined)
+		return fmt.Errorf("undefined variables: %s", strings.Join(undefined, ", "))
+	}
+	return nil
+}
+
+// expandValue replaces the ${NAME} references in a value, returning the names that
+// lookup does not define
+func expandValue(value string, lookup func(string) (string, bool)) (string, []string, error) {
+	var b strings.Builder
+	var missing []string
+	for {
+		i := strings.Index(value, "${")
+		if i < 0 {
+			b.WriteString(value)
+			return b.String(), missing, nil
+		}
+		if i > 0 && value[i-1] == '$' {
+			b.WriteString(value[:i-1])
+			b.WriteString("${")
+			value = value[i+2:]
+			continue
+		}
+		b.WriteString(value[:i])
+
+		end := strings.IndexByte(value[i:], '}')
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated variable reference")
+		}
+		name := value[i+2 : i+end]
+		if !variableNamePattern.MatchString(name) {
+			return "", nil, fmt.Errorf("invalid variable name '%s'", name)
+		}
+		if resolved, ok := lookup(name); ok {
+			b.WriteString(resolved)
+		} else {
+			missing = append(missing, name)
+		}
+		value = value[i+end+1:]
+	}
+}
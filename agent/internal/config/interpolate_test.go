@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigInterpolation(t *testing.T) {
+	tmpDir := t.TempDir()
+	secretsPath := filepath.Join(tmpDir, "secrets.yaml")
+	if err := os.WriteFile(secretsPath, []byte("DB_PASSWORD: from-secrets\nWEBHOOK_TOKEN: abc123\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secrets file: %v", err)
+	}
+	t.Setenv("SECRETS_DIR", tmpDir)
+	t.Setenv("DB_PASSWORD", "from-env")
+	t.Setenv("DB_PORT", "6432")
+	t.Setenv("RPC_HOST", "10.0.0.5")
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+secrets_file: ${SECRETS_DIR}/secrets.yaml
+database:
+  host: localhost
+  port: ${DB_PORT}
+  database: snapd
+  user: snapd
+  password: ${DB_PASSWORD}
+notifications:
+  discord:
+    url: "https://discord.com/api/webhooks/1/${WEBHOOK_TOKEN}"
+nodes:
+  ${RPC_HOST}:
+    protocol: ethereum
+    url: http://${RPC_HOST}:8545
+    schedule: "0 0 * * * *"
+    metadata:
+      note: "costs $${PRICE}"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.SecretsFile != secretsPath {
+		t.Errorf("Expected secrets_file %s, got %s", secretsPath, config.SecretsFile)
+	}
+	if config.Database.Password != "from-secrets" {
+		t.Errorf("Expected the secrets file to take precedence, got password %q", config.Database.Password)
+	}
+	if config.Database.Port != 6432 {
+		t.Errorf("Expected port 6432, got %d", config.Database.Port)
+	}
+	if url := config.Notifications.Types["discord"].URL; url != "https://discord.com/api/webhooks/1/abc123" {
+		t.Errorf("Unexpected webhook url %q", url)
+	}
+	node, exists := config.Nodes["${RPC_HOST}"]
+	if !exists {
+		t.Fatalf("Expected keys not to be expanded, got nodes %v", config.Nodes)
+	}
+	if node.URL != "http://10.0.0.5:8545" {
+		t.Errorf("Unexpected node url %q", node.URL)
+	}
+	if note := node.Metadata["note"]; note != "costs ${PRICE}" {
+		t.Errorf("Expected $${ to escape a reference, got %q", note)
+	}
+}
+
+func TestReadConfigInterpolationErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	looseSecrets := filepath.Join(tmpDir, "loose.yaml")
+	if err := os.WriteFile(looseSecrets, []byte("DB_PASSWORD: secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secrets file: %v", err)
+	}
+	// WriteFile's mode is subject to the umask
+	if err := os.Chmod(looseSecrets, 0644); err != nil {
+		t.Fatalf("Failed to chmod secrets file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "undefined variables",
+			content: "database:\n  password: ${SNAPPERD_TEST_UNSET}\n  user: ${SNAPPERD_TEST_ALSO_UNSET}\n",
+			wantErr: "undefined variables: SNAPPERD_TEST_ALSO_UNSET (line 3), SNAPPERD_TEST_UNSET (line 2)",
+		},
+		{
+			name:    "unterminated reference",
+			content: "database:\n  password: ${DB_PASSWORD\n",
+			wantErr: "line 2: unterminated variable reference",
+		},
+		{
+			name:    "invalid name",
+			content: "database:\n  password: ${DB-PASSWORD}\n",
+			wantErr: "line 2: invalid variable name 'DB-PASSWORD'",
+		},
+		{
+			name:    "secrets file readable by others",
+			content: "secrets_file: " + looseSecrets + "\n",
+			wantErr: "must not be accessible by group or others (mode 0644",
+		},
+		{
+			name:    "missing secrets file",
+			content: "secrets_file: " + filepath.Join(tmpDir, "missing.yaml") + "\n",
+			wantErr: "failed to read secrets file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(tmpDir, "config.yaml")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			_, err := ReadConfig(configPath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
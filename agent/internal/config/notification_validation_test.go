@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -344,3 +346,84 @@ nodes:
 		t.Errorf("Expected no slack URL for node, got '%s'", nodeNotif.GetNotificationURL("slack"))
 	}
 }
+
+// MockTemplateValidator is a mock notification validator that also validates templates
+type MockTemplateValidator struct {
+	MockNotificationValidator
+	events map[string]bool
+}
+
+func (m *MockTemplateValidator) ValidateTemplate(event, title, body string) error {
+	if !m.events[event] {
+		return fmt.Errorf("unknown event '%s'", event)
+	}
+	return nil
+}
+
+func TestNotificationValidation_Templates(t *testing.T) {
+	config := NotificationConfig{
+		Templates: map[string]NotificationTemplateConfig{
+			"failure": {Title: "{{.NodeName}} failed"},
+		},
+		Types: map[string]NotificationTypeConfig{
+			"discord": {
+				URL: "https://discord.com/api/webhooks/test",
+				Templates: map[string]NotificationTemplateConfig{
+					"unknown": {Body: "{{.Message}}"},
+				},
+			},
+		},
+	}
+
+	// Templates are not checked by a validator that cannot validate them
+	SetNotificationValidator(&MockNotificationValidator{registered: map[string]bool{"discord": true}})
+	defer SetNotificationValidator(nil)
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected templates to be unchecked, got %v", err)
+	}
+
+	SetNotificationValidator(&MockTemplateValidator{
+		MockNotificationValidator: MockNotificationValidator{registered: map[string]bool{"discord": true}},
+		events:                    map[string]bool{"failure": true},
+	})
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "unknown event 'unknown'") {
+		t.Errorf("expected an unknown event error, got %v", err)
+	}
+
+	delete(config.Types["discord"].Templates, "unknown")
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected valid templates, got %v", err)
+	}
+}
+
+func TestNotificationConfig_GetTemplate(t *testing.T) {
+	config := &NotificationConfig{
+		Templates: map[string]NotificationTemplateConfig{
+			"failure": {Title: "global title", Body: "global body"},
+		},
+		Types: map[string]NotificationTypeConfig{
+			"discord": {
+				URL: "https://discord.com/api/webhooks/test",
+				Templates: map[string]NotificationTemplateConfig{
+					"failure": {Title: "discord title"},
+				},
+			},
+		},
+	}
+
+	// Each field comes from the type's template, else the notifications-wide one
+	if got := config.GetTemplate("discord", "failure"); got.Title != "discord title" || got.Body != "global body" {
+		t.Errorf("unexpected discord template: %+v", got)
+	}
+	if got := config.GetTemplate("slack", "failure"); got.Title != "global title" || got.Body != "global body" {
+		t.Errorf("unexpected slack template: %+v", got)
+	}
+	if got := config.GetTemplate("discord", "complete"); got != (NotificationTemplateConfig{}) {
+		t.Errorf("expected an empty template, got %+v", got)
+	}
+
+	var unset *NotificationConfig
+	if got := unset.GetTemplate("discord", "failure"); got != (NotificationTemplateConfig{}) {
+		t.Errorf("expected an empty template for nil config, got %+v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret values in redacted configuration
+const Redacted = "REDACTED"
+
+// sensitiveKeys are configuration keys whose values are replaced entirely
+var sensitiveKeys = []string{"password", "secret", "token", "api_key"}
+
+// headersKey is the configuration key of HTTP header maps, such as an exporter's
+// Authorization header, whose values are all replaced
+const headersKey = "headers"
+
+// IsSensitiveKey reports whether a configuration key or environment variable name holds
+// a secret
+func IsSensitiveKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isURLKey reports whether a configuration key holds a URL (url, a key ending in _url, or
+// an endpoint). URLs often embed credentials such as webhook tokens or RPC API keys, so
+// only their scheme and host are kept.
+func isURLKey(name string) bool {
+	name = strings.ToLower(name)
+	return name == "url" || strings.HasSuffix(name, "_url") || strings.HasSuffix(name, "endpoint")
+}
+
+// RedactYAML returns a YAML configuration with secrets removed, so it can be shared for
+// support: passwords, secrets, tokens and HTTP header values are replaced with REDACTED,
+// and URLs, including endpoints, keep only their scheme and host. Environment variable
+// references such as ${DB_PASSWORD} are kept.
+func RedactYAML(data []byte) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	redactNode(&root)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, fmt.Errorf("failed to write configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write configuration: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// redactNode redacts the values of sensitive keys below a YAML node
+func redactNode(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			if value.Kind == yaml.ScalarNode && value.Value != "" && !isEnvReference(value.Value) {
+				switch {
+				case IsSensitiveKey(key):
+					value.Value = Redacted
+					value.Tag = "!!str"
+					value.Style = 0
+					continue
+				case isURLKey(key):
+					value.Value = RedactURL(value.Value)
+					continue
+				}
+			}
+			if strings.EqualFold(key, headersKey) && value.Kind == yaml.MappingNode {
+				redactValues(value)
+				continue
+			}
+			redactNode(value)
+		}
+		return
+	}
+
+	for _, child := range node.Content {
+		redactNode(child)
+	}
+}
+
+// redactValues replaces every scalar value of a mapping, such as a map of HTTP headers
+func redactValues(node *yaml.Node) {
+	for i := 1; i < len(node.Content); i += 2 {
+		value := node.Content[i]
+		if value.Kind == yaml.ScalarNode && value.Value != "" && !isEnvReference(value.Value) {
+			value.Value = Redacted
+			value.Tag = "!!str"
+			value.Style = 0
+		}
+	}
+}
+
+// isEnvReference reports whether a value only references an environment variable
+func isEnvReference(value string) bool {
+	return strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") && !strings.Contains(value[2:], "${")
+}
+
+// RedactURL keeps a URL's scheme and host and replaces its credentials, path and query,
+// for example https://discord.com/api/webhooks/123/abc becomes https://discord.com/REDACTED
+func RedactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		return Redacted
+	}
+
+	redacted := parsed.Scheme + "://" + parsed.Host
+	if parsed.User != nil || strings.Trim(parsed.Path, "/") != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+		redacted += "/" + Redacted
+	}
+	return redacted
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxNodeNameLength bounds node names to the database column size
+const MaxNodeNameLength = 255
+
+// nodeNamePattern matches the names accepted for nodes registered at runtime
+var nodeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateNodeName checks a name for a node registered at runtime. Names start with a
+// letter or digit and contain only letters, digits, '.', '_' and '-', so they can be
+// used in URL paths unescaped.
+func ValidateNodeName(name string) error {
+	return validateName("node", name)
+}
+
+// ValidateHostName checks the host a registered node is assigned to, which follows the
+// rules of node names
+func ValidateHostName(host string) error {
+	return validateName("host", host)
+}
+
+// validateName checks a node or host name
+func validateName(kind, name string) error {
+	if len(name) > MaxNodeNameLength {
+		return fmt.Errorf("%s name must be at most %d characters", kind, MaxNodeNameLength)
+	}
+	if !nodeNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid %s name '%s': use letters, digits, '.', '_' and '-'", kind, name)
+	}
+	return nil
+}
+
+// ParseNodeConfig decodes a single node's configuration in the format of a nodes entry.
+// JSON is accepted too, since it is valid YAML. Unknown fields are rejected so a
+// misspelt option is not silently ignored.
+func ParseNodeConfig(data []byte) (NodeConfig, error) {
+	var node NodeConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&node); err != nil {
+		return NodeConfig{}, fmt.Errorf("failed to parse node config: %w", err)
+	}
+	return node, nil
+}
+
+// EncodeNodeConfig encodes a node's configuration as YAML, the inverse of ParseNodeConfig
+func EncodeNodeConfig(node NodeConfig) (string, error) {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode node config: %w", err)
+	}
+	return string(data), nil
+}
+
+// WithNode returns a copy of the configuration with the node added, or replaced if it
+// exists, after validating the result. The receiver is not modified.
+func (c *Config) WithNode(name string, node NodeConfig) (*Config, error) {
+	if err := ValidateNodeName(name); err != nil {
+		return nil, err
+	}
+
+	next := *c
+	next.Nodes = make(map[string]NodeConfig, len(c.Nodes)+1)
+	for nodeName, nodeConfig := range c.Nodes {
+		next.Nodes[nodeName] = nodeConfig
+	}
+	next.Nodes[name] = node
+
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// WithoutNode returns a copy of the configuration without the node. The receiver is not
+// modified.
+func (c *Config) WithoutNode(name string) *Config {
+	next := *c
+	next.Nodes = make(map[string]NodeConfig, len(c.Nodes))
+	for nodeName, nodeConfig := range c.Nodes {
+		if nodeName != name {
+			next.Nodes[nodeName] = nodeConfig
+		}
+	}
+	return &next
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNodeConfig(t *testing.T) {
+	jsonNode := `{"protocol": "ethereum", "type": "archive", "url": "http://10.0.0.5:8545", "schedule": "0 0 */6 * * *", "metadata": {"operator": "ops"}}`
+	node, err := ParseNodeConfig([]byte(jsonNode))
+	if err != nil {
+		t.Fatalf("ParseNodeConfig failed: %v", err)
+	}
+	if node.Protocol != "ethereum" || node.Type != "archive" || node.Metadata["operator"] != "ops" {
+		t.Errorf("unexpected node config: %+v", node)
+	}
+
+	// The YAML encoding round-trips
+	encoded, err := EncodeNodeConfig(node)
+	if err != nil {
+		t.Fatalf("EncodeNodeConfig failed: %v", err)
+	}
+	decoded, err := ParseNodeConfig([]byte(encoded))
+	if err != nil {
+		t.Fatalf("ParseNodeConfig of encoded config failed: %v", err)
+	}
+	if decoded.URL != node.URL || decoded.Schedule != node.Schedule || decoded.Metadata["operator"] != "ops" {
+		t.Errorf("expected %+v after round trip, got %+v", node, decoded)
+	}
+
+	if _, err := ParseNodeConfig([]byte(`{"protocol": "ethereum", "shedule": "0 0 * * * *"}`)); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestWithNode(t *testing.T) {
+	cfg := &Config{
+		Schedule: "0 * * * * *",
+		Database: DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+		Nodes: map[string]NodeConfig{
+			"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+		},
+	}
+	node := NodeConfig{Protocol: "arbitrum", URL: "http://localhost:8547", Schedule: "0 0 */12 * * *"}
+
+	next, err := cfg.WithNode("arb-1", node)
+	if err != nil {
+		t.Fatalf("WithNode failed: %v", err)
+	}
+	if len(next.Nodes) != 2 || next.GetNodeSchedule("arb-1") != node.Schedule {
+		t.Errorf("expected arb-1 added, got %+v", next.Nodes)
+	}
+	if len(cfg.Nodes) != 1 {
+		t.Errorf("expected original config unchanged, got %+v", cfg.Nodes)
+	}
+
+	if removed := next.WithoutNode("arb-1"); len(removed.Nodes) != 1 || len(next.Nodes) != 2 {
+		t.Errorf("expected arb-1 removed from a copy, got %+v and %+v", removed.Nodes, next.Nodes)
+	}
+
+	tests := []struct {
+		name     string
+		nodeName string
+		node     NodeConfig
+		wantErr  string
+	}{
+		{name: "invalid name", nodeName: "arb/1", node: node, wantErr: "invalid node name"},
+		{name: "name too long", nodeName: strings.Repeat("a", MaxNodeNameLength+1), node: node, wantErr: "at most"},
+		{name: "invalid node", nodeName: "arb-1", node: NodeConfig{Protocol: "arbitrum", Schedule: "0 0 * * * *"}, wantErr: "url is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cfg.WithNode(tt.nodeName, tt.node)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNodeAPIConfig(t *testing.T) {
+	newConfig := func(nodes map[string]NodeConfig, nodeAPI *NodeAPIConfig) *Config {
+		return &Config{
+			Schedule: "0 * * * * *",
+			Database: DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+			Nodes:    nodes,
+			NodeAPI:  nodeAPI,
+		}
+	}
+	nodes := map[string]NodeConfig{
+		"eth": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+	}
+	validAPI := &NodeAPIConfig{Listen: "127.0.0.1:8097", Token: "0123456789abcdef"}
+
+	tests := []struct {
+		name    string
+		nodes   map[string]NodeConfig
+		nodeAPI *NodeAPIConfig
+		wantErr bool
+	}{
+		{name: "with nodes", nodes: nodes, nodeAPI: validAPI},
+		{name: "no nodes with node api", nodeAPI: validAPI},
+		{name: "no nodes without node api", wantErr: true},
+		{name: "missing listen", nodes: nodes, nodeAPI: &NodeAPIConfig{Token: "0123456789abcdef"}, wantErr: true},
+		{name: "short token", nodes: nodes, nodeAPI: &NodeAPIConfig{Listen: "127.0.0.1:8097", Token: "secret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.nodes, tt.nodeAPI).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatabaseNodesConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		databaseNodes *DatabaseNodesConfig
+		wantErr       bool
+		wantInterval  time.Duration
+	}{
+		{name: "defaults", databaseNodes: &DatabaseNodesConfig{}, wantInterval: DefaultNodePollInterval},
+		{name: "host and interval", databaseNodes: &DatabaseNodesConfig{Host: "host-a.example", PollInterval: "2m"}, wantInterval: 2 * time.Minute},
+		{name: "invalid host", databaseNodes: &DatabaseNodesConfig{Host: "host a"}, wantErr: true},
+		{name: "invalid interval", databaseNodes: &DatabaseNodesConfig{PollInterval: "often"}, wantErr: true},
+		{name: "interval too short", databaseNodes: &DatabaseNodesConfig{PollInterval: "100ms"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Database-backed nodes need no nodes in the configuration file
+			cfg := &Config{
+				Schedule:      "0 * * * * *",
+				Database:      DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+				DatabaseNodes: tt.databaseNodes,
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.databaseNodes.GetPollInterval() != tt.wantInterval {
+				t.Errorf("expected poll interval %v, got %v", tt.wantInterval, tt.databaseNodes.GetPollInterval())
+			}
+		})
+	}
+}
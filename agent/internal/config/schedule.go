@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser parses normalized schedules: 6-field cron expressions with seconds and the
+// @ descriptors, such as @daily and @every 1h
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// NormalizeSchedule converts a schedule into the 6-field cron expression, with seconds,
+// that jobs are scheduled with. Besides 6-field expressions and @ descriptors, it accepts:
+//
+//   - standard 5-field cron expressions, run at second 0: "0 */6 * * *"
+//   - "every <duration>", for durations dividing a minute, an hour or a day evenly:
+//     "every 6h" is "0 0 */6 * * *"
+//   - "hourly" and "hourly@<minute>": "hourly@15" is "0 15 * * * *"
+//   - "daily" and "daily@<hh:mm>", in the schedule's timezone: "daily@03:00" is "0 0 3 * * *"
+//
+// A leading "CRON_TZ=<zone>" or "TZ=<zone>", as added by WithTimezone, is kept in front of
+// the normalized schedule.
+func NormalizeSchedule(schedule string) (string, error) {
+	schedule = strings.TrimSpace(schedule)
+	if zone, rest, ok := splitTimezone(schedule); ok {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return "", fmt.Errorf("invalid timezone '%s': %w", zone, err)
+		}
+		normalized, err := NormalizeSchedule(rest)
+		if err != nil {
+			return "", err
+		}
+		return "CRON_TZ=" + zone + " " + normalized, nil
+	}
+	lower := strings.ToLower(schedule)
+
+	switch {
+	case strings.HasPrefix(lower, "@"):
+		return schedule, nil
+	case strings.HasPrefix(lower, "every"):
+		return normalizeInterval(strings.TrimLeft(schedule[len("every"):], ": "))
+	case lower == "hourly":
+		return "0 0 * * * *", nil
+	case strings.HasPrefix(lower, "hourly@"):
+		minute, err := strconv.Atoi(strings.TrimPrefix(schedule[len("hourly@"):], ":"))
+		if err != nil || minute < 0 || minute > 59 {
+			return "", fmt.Errorf("hourly@ takes a minute from 0 to 59, as in hourly@15")
+		}
+		return fmt.Sprintf("0 %d * * * *", minute), nil
+	case lower == "daily":
+		return "0 0 0 * * *", nil
+	case strings.HasPrefix(lower, "daily@"):
+		at, err := time.Parse("15:04", schedule[len("daily@"):])
+		if err != nil {
+			return "", fmt.Errorf("daily@ takes a time of day as hh:mm, as in daily@03:00")
+		}
+		return fmt.Sprintf("0 %d %d * * *", at.Minute(), at.Hour()), nil
+	}
+
+	if fields := strings.Fields(schedule); len(fields) == 5 {
+		return "0 " + strings.Join(fields, " "), nil
+	}
+	return schedule, nil
+}
+
+// WithTimezone returns a schedule evaluated in timezone, by prefixing it with
+// "CRON_TZ=<timezone>". Schedules without a timezone, or naming their own, are returned
+// unchanged.
+func WithTimezone(schedule, timezone string) string {
+	if timezone == "" || schedule == "" {
+		return schedule
+	}
+	if _, _, ok := splitTimezone(schedule); ok {
+		return schedule
+	}
+	return "CRON_TZ=" + timezone + " " + schedule
+}
+
+// splitTimezone splits a "CRON_TZ=<zone> <schedule>" or "TZ=<zone> <schedule>" schedule
+// into the zone and the schedule
+func splitTimezone(schedule string) (zone, rest string, ok bool) {
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(schedule, prefix) {
+			zone, rest, _ = strings.Cut(schedule[len(prefix):], " ")
+			return zone, strings.TrimSpace(rest), true
+		}
+	}
+	return "", "", false
+}
+
+// normalizeInterval converts an interval into a cron expression running at every
+// multiple of it within the minute, hour or day
+func normalizeInterval(interval string) (string, error) {
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return "", fmt.Errorf("every takes a positive duration, as in every 6h")
+	}
+
+	switch {
+	case d%time.Second != 0:
+	case d < time.Minute && time.Minute%d == 0:
+		return fmt.Sprintf("*/%d * * * * *", d/time.Second), nil
+	case d < time.Hour && d%time.Minute == 0 && time.Hour%d == 0:
+		return fmt.Sprintf("0 */%d * * * *", d/time.Minute), nil
+	case d < 24*time.Hour && d%time.Hour == 0 && (24*time.Hour)%d == 0:
+		return fmt.Sprintf("0 0 */%d * * *", d/time.Hour), nil
+	case d == 24*time.Hour:
+		return "0 0 0 * * *", nil
+	}
+	return "", fmt.Errorf("every %s does not divide a minute, an hour or a day evenly; use a cron expression", interval)
+}
+
+// ParseSchedule normalizes a schedule, see NormalizeSchedule, and parses it
+func ParseSchedule(schedule string) (cron.Schedule, error) {
+	normalized, err := NormalizeSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+	return cronParser.Parse(normalized)
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     string
+		wantErr  bool
+	}{
+		{schedule: "0 0 */6 * * *", want: "0 0 */6 * * *"},
+		{schedule: "0 */6 * * *", want: "0 0 */6 * * *"},
+		{schedule: "  30 3 * * 1-5 ", want: "0 30 3 * * 1-5"},
+		{schedule: "@daily", want: "@daily"},
+		{schedule: "@every 90m", want: "@every 90m"},
+		{schedule: "every 30s", want: "*/30 * * * * *"},
+		{schedule: "every 15m", want: "0 */15 * * * *"},
+		{schedule: "every 6h", want: "0 0 */6 * * *"},
+		{schedule: "every: 6h", want: "0 0 */6 * * *"},
+		{schedule: "Every 24h", want: "0 0 0 * * *"},
+		{schedule: "hourly", want: "0 0 * * * *"},
+		{schedule: "hourly@15", want: "0 15 * * * *"},
+		{schedule: "hourly@:45", want: "0 45 * * * *"},
+		{schedule: "daily", want: "0 0 0 * * *"},
+		{schedule: "daily@03:00", want: "0 0 3 * * *"},
+		{schedule: "daily@23:30", want: "0 30 23 * * *"},
+		{schedule: "every 7h", wantErr: true},
+		{schedule: "every 90m", wantErr: true},
+		{schedule: "every 1500ms", wantErr: true},
+		{schedule: "every 48h", wantErr: true},
+		{schedule: "every soon", wantErr: true},
+		{schedule: "hourly@60", wantErr: true},
+		{schedule: "daily@3am", wantErr: true},
+		{schedule: "CRON_TZ=Europe/Berlin daily@03:00", want: "CRON_TZ=Europe/Berlin 0 0 3 * * *"},
+		{schedule: "TZ=UTC 0 */6 * * *", want: "CRON_TZ=UTC 0 0 */6 * * *"},
+		{schedule: "CRON_TZ=Asia/Tokyo @daily", want: "CRON_TZ=Asia/Tokyo @daily"},
+		{schedule: "CRON_TZ=Nowhere/Land daily", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			got, err := NormalizeSchedule(tt.schedule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeSchedule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 20, 0, 0, time.Local)
+
+	// The friendly, 5-field and 6-field forms of a schedule run at the same times
+	for _, schedule := range []string{"every 6h", "0 */6 * * *", "0 0 */6 * * *"} {
+		parsed, err := ParseSchedule(schedule)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q) failed: %v", schedule, err)
+		}
+		if next := parsed.Next(now); !next.Equal(time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)) {
+			t.Errorf("%q: expected the next run at 12:00, got %v", schedule, next)
+		}
+	}
+
+	if _, err := ParseSchedule("0 * * * * * *"); err == nil {
+		t.Error("expected an error for a 7-field expression")
+	}
+}
+
+func TestParseSchedule_Timezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// 03:00 in Berlin is 01:00 UTC in summer, whatever the clock the caller passes
+	parsed, err := ParseSchedule(WithTimezone("daily@03:00", "Europe/Berlin"))
+	if err != nil {
+		t.Fatalf("ParseSchedule() error = %v", err)
+	}
+	next := parsed.Next(time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 7, 2, 3, 0, 0, 0, berlin); !next.Equal(want) {
+		t.Errorf("expected the next run at %v, got %v", want, next)
+	}
+}
+
+func TestWithTimezone(t *testing.T) {
+	if got := WithTimezone("daily", "UTC"); got != "CRON_TZ=UTC daily" {
+		t.Errorf("WithTimezone() = %q", got)
+	}
+	if got := WithTimezone("daily", ""); got != "daily" {
+		t.Errorf("expected no prefix without a timezone, got %q", got)
+	}
+	if got := WithTimezone("TZ=Asia/Tokyo daily", "UTC"); got != "TZ=Asia/Tokyo daily" {
+		t.Errorf("expected a schedule's own timezone to be kept, got %q", got)
+	}
+}
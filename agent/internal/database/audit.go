@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditUploadInitiated  = "upload_initiated"  // An upload was started, e.g. by 'snapperd upload' or 'snapperd requeue'
+	AuditUploadRequested  = "upload_requested"  // An upload request was queued for the running daemon
+	AuditUploadCancelled  = "upload_cancelled"  // A running upload was cancelled
+	AuditNodePaused       = "node_paused"       // A node's scheduled uploads were paused
+	AuditNodeResumed      = "node_resumed"      // A paused node was resumed
+	AuditNodeRegistered   = "node_registered"   // A node was registered, or a registered node's config replaced
+	AuditNodeDeregistered = "node_deregistered" // A registered node was removed
+	AuditNodePurged       = "node_purged"       // Every record of a node was deleted
+	AuditNodeSnoozed      = "node_snoozed"      // A node's notifications were snoozed
+	AuditConfigLoaded     = "config_loaded"     // The daemon started with a configuration
+	AuditDatabaseMigrated = "database_migrated" // Database migrations were applied or rolled back
+)
+
+// Sources of audited actions
+const (
+	AuditSourceCLI     = "cli"      // A snapperd command; the actor is the invoking user
+	AuditSourceNodeAPI = "node_api" // The node API; the actor is the caller's X-Snapperd-Actor header
+	AuditSourceSnooze  = "snooze"   // A snooze link; the actor is the name entered
+	AuditSourceDaemon  = "daemon"   // The daemon itself; the actor is its host
+)
+
+// AuditEntry is an action that changed the daemon's state, recorded with who took it and
+// from where. Unlike a node's action log, which records what the daemon did on its own,
+// the audit log records what it was told to do, and is kept when a node is purged.
+type AuditEntry struct {
+	ID        int64     `db:"id" json:"id"`
+	Actor     string    `db:"actor" json:"actor"`
+	Source    string    `db:"source" json:"source"`
+	Action    string    `db:"action" json:"action"`
+	NodeName  *string   `db:"node_name" json:"node_name,omitempty"`
+	UploadID  *int64    `db:"upload_id" json:"upload_id,omitempty"`
+	Message   string    `db:"message" json:"message"` // What was done, e.g. "paused: disk replacement"
+	Details   JSONB     `db:"details" json:"details,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// AuditFilter selects audit log entries; zero fields match every entry
+type AuditFilter struct {
+	NodeName string
+	Actor    string
+	Action   string
+	Source   string
+	Since    time.Time // Only entries recorded at or after this time
+	Until    time.Time // Only entries recorded before this time
+	Limit    int       // Maximum number of entries (0 = no limit)
+}
+
+// RecordAudit adds an entry to the audit log
+func (db *DB) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO audit_log (actor, source, action, node_name, upload_id, message, details, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	if err := db.execWithRetry(ctx, query, entry.Actor, entry.Source, entry.Action, entry.NodeName, entry.UploadID, entry.Message, entry.Details, entry.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditLog retrieves the audit log entries matching the filter, most recent first
+func (db *DB) ListAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	query := `SELECT id, actor, source, action, node_name, upload_id, message, details, created_at
+	          FROM audit_log`
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.NodeName != "" {
+		addCondition("node_name = $%d", filter.NodeName)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.Source != "" {
+		addCondition("source = $%d", filter.Source)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= $%d", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at < $%d", filter.Until.UTC())
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\t          WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\n\t          ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\t          LIMIT $%d", len(args))
+	}
+
+	var entries []AuditEntry
+	if err := db.queryWithRetry(ctx, &entries, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+
+	return entries, nil
+}
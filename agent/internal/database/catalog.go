@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CatalogEntry is the freshest completed snapshot of a protocol, network and node type,
+// a row of the snapshots table
+type CatalogEntry struct {
+	Protocol    string    `db:"protocol" json:"protocol"`
+	Network     string    `db:"network" json:"network"`
+	NodeType    string    `db:"node_type" json:"node_type"`
+	NodeName    string    `db:"node_name" json:"node_name"` // Node that uploaded the snapshot
+	Tenant      string    `db:"tenant" json:"tenant,omitempty"`
+	UploadID    int64     `db:"upload_id" json:"upload_id"`
+	LatestBlock *int64    `db:"latest_block" json:"latest_block,omitempty"`
+	LatestSlot  *int64    `db:"latest_slot" json:"latest_slot,omitempty"`
+	SizeBytes   *int64    `db:"size_bytes" json:"size_bytes,omitempty"`
+	Location    *string   `db:"location" json:"location,omitempty"` // Where the snapshot is stored, when known
+	CompletedAt time.Time `db:"completed_at" json:"completed_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// CatalogFilter selects catalog entries; empty fields match every entry
+type CatalogFilter struct {
+	Protocol string
+	Network  string
+	NodeType string
+	Tenant   string // Only entries uploaded by the tenant's nodes
+}
+
+// UpsertCatalogEntry records a completed snapshot as the catalog entry of its protocol,
+// network and node type. An entry is only replaced by a snapshot at or above its
+// latest_block, so a lagging node cannot make the catalog go back; snapshots without a
+// block always replace it. It reports whether the entry was written.
+func (db *DB) UpsertCatalogEntry(ctx context.Context, entry CatalogEntry) (bool, error) {
+	query := `INSERT INTO snapshots (protocol, network, node_type, node_name, tenant, upload_id, latest_block, latest_slot, size_bytes, location, completed_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	          ON CONFLICT (protocol, network, node_type) DO UPDATE SET
+	              node_name = EXCLUDED.node_name,
+	              tenant = EXCLUDED.tenant,
+	              upload_id = EXCLUDED.upload_id,
+	              latest_block = EXCLUDED.latest_block,
+	              latest_slot = EXCLUDED.latest_slot,
+	              size_bytes = EXCLUDED.size_bytes,
+	              location = EXCLUDED.location,
+	              completed_at = EXCLUDED.completed_at,
+	              updated_at = EXCLUDED.updated_at
+	          WHERE snapshots.latest_block IS NULL
+	             OR EXCLUDED.latest_block IS NULL
+	             OR EXCLUDED.latest_block >= snapshots.latest_block
+	          RETURNING upload_id`
+
+	var written []int64
+	if err := db.queryWithRetry(ctx, &written, query,
+		entry.Protocol, entry.Network, entry.NodeType, entry.NodeName, entry.Tenant, entry.UploadID,
+		entry.LatestBlock, entry.LatestSlot, entry.SizeBytes, entry.Location, entry.CompletedAt.UTC(), time.Now().UTC()); err != nil {
+		return false, fmt.Errorf("failed to upsert catalog entry: %w", err)
+	}
+
+	return len(written) > 0, nil
+}
+
+// ListCatalog retrieves the catalog entries matching filter, ordered by protocol, network
+// and node type
+func (db *DB) ListCatalog(ctx context.Context, filter CatalogFilter) ([]CatalogEntry, error) {
+	var conditions []string
+	var args []interface{}
+	for _, c := range []struct{ column, value string }{
+		{"protocol", filter.Protocol},
+		{"network", filter.Network},
+		{"node_type", filter.NodeType},
+		{"tenant", filter.Tenant},
+	} {
+		if c.value == "" {
+			continue
+		}
+		args = append(args, c.value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", c.column, len(args)))
+	}
+
+	query := `SELECT protocol, network, node_type, node_name, tenant, upload_id, latest_block, latest_slot, size_bytes, location, completed_at, updated_at
+	          FROM snapshots`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY protocol, network, node_type"
+
+	entries := []CatalogEntry{}
+	if err := db.queryWithRetry(ctx, &entries, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list catalog: %w", err)
+	}
+
+	return entries, nil
+}
@@ -4,27 +4,40 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/tracing"
 )
 
 // DB wraps the database connection with retry logic
 type DB struct {
 	conn           *sqlx.DB
+	driver         Driver
 	maxRetries     int
 	retryBaseDelay time.Duration
 }
 
 // Config holds database connection configuration
 type Config struct {
+	Driver   string // "postgres" (default) or "sqlite"
+	Path     string // Database file path (sqlite only)
 	Host     string
 	Port     int
 	Database string
 	User     string
 	Password string
-	SSLMode  string
+	SSLMode  string // disable, require (default), verify-ca or verify-full
+	// TLS files (postgres only): the CA verifying the server, and a client certificate
+	// and key for servers requiring mutual TLS
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	Schema      string // Optional PostgreSQL schema (search_path) for isolated runs
 }
 
 // Upload represents an upload operation and the blockchain state it contains
@@ -37,6 +50,7 @@ type Upload struct {
 	CompletedAt       *time.Time `db:"completed_at"`
 	Status            string     `db:"status"`
 	TriggerType       string     `db:"trigger_type"`
+	TriggerMetadata   JSONB      `db:"trigger_metadata"` // Who or what triggered the upload and why
 	ErrorMessage      *string    `db:"error_message"`
 	ProtocolData      JSONB      `db:"protocol_data"`       // Blockchain state when upload started
 	ProgressPercent   *float64   `db:"progress_percent"`    // Current progress percentage
@@ -44,208 +58,1850 @@ type Upload struct {
 	ChunksTotal       *int       `db:"chunks_total"`        // Total chunks in upload
 	LastProgressCheck *time.Time `db:"last_progress_check"` // When progress was last updated
 	CompletionMessage *string    `db:"completion_message"`  // Success/completion message
+	StalledSince      *time.Time `db:"stalled_since"`       // When progress stopped advancing (nil while progressing)
+	// Throughput over recent progress samples and the completion time it projects
+	ChunksPerMinute     *float64   `db:"chunks_per_minute"`
+	EstimatedCompletion *time.Time `db:"estimated_completion"`
+	// When bv reports the job finished, and how long after that the monitor detected it
+	FinishedAt          *time.Time `db:"finished_at"`
+	DetectionLagSeconds *float64   `db:"detection_lag_seconds"`
+	// The snapshot an incremental upload was taken against (nil for full uploads)
+	BaseUploadID *int64 `db:"base_upload_id"`
+	// Outcome of spot-restoring the snapshot into a scratch node (nil until verified)
+	RestoreVerification        *string    `db:"restore_verification"`
+	RestoreVerifiedAt          *time.Time `db:"restore_verified_at"`
+	RestoreVerificationMessage *string    `db:"restore_verification_message"`
+	// How the uploaded data was compressed and its size before and after, for engines
+	// that report it (nil otherwise)
+	Compression         *string `db:"compression"`
+	CompressionLevel    *int    `db:"compression_level"`
+	RawSizeBytes        *int64  `db:"raw_size_bytes"`
+	CompressedSizeBytes *int64  `db:"compressed_size_bytes"`
+	// Bytes uploaded by a completed upload, for engines that report it (nil otherwise)
+	SizeBytes *int64 `db:"size_bytes"`
+	// Later upload requests coalesced into this upload instead of starting another
+	CoalescedTriggers int `db:"coalesced_triggers"`
+	// Host name of the snapperd that recorded the upload (nil for older uploads)
+	Agent *string `db:"agent"`
+	// W3C traceparent of the span that recorded the upload, which monitor checks continue
+	// (nil when tracing was disabled)
+	TraceParent *string `db:"trace_parent"`
+	// Tenant whose node recorded the upload (empty for nodes of no tenant)
+	Tenant string `db:"tenant"`
+	// When a daemon stopped while the upload was running (nil once a daemon re-attached
+	// monitoring)
+	InterruptedAt *time.Time `db:"interrupted_at"`
+	// Which attempt at the snapshot the upload is, 1 unless it retries a failed upload,
+	// and the failed upload it retries
+	Attempt int    `db:"attempt"`
+	RetryOf *int64 `db:"retry_of"`
+}
+
+// Restore verification outcomes
+const (
+	RestoreVerified           = "verified" // The restored client loaded and reported the snapshot's block
+	RestoreVerificationFailed = "failed"   // The restore or the client check failed (see restore_verification_message)
+)
+
+// progressSample is a row of the upload_progress_samples table
+type progressSample struct {
+	RecordedAt      time.Time `db:"recorded_at"`
+	ChunksCompleted int       `db:"chunks_completed"`
+	ChunksTotal     *int      `db:"chunks_total"`
+}
+
+// ScheduleState is the persisted scheduling history of a node's upload job
+type ScheduleState struct {
+	NodeName   string     `db:"node_name"`
+	LastRunAt  *time.Time `db:"last_run_at"` // When the scheduled job last ran
+	NextRunAt  *time.Time `db:"next_run_at"` // When the scheduled job is next due
+	LastResult *string    `db:"last_result"` // Outcome of the last run (initiated, skipped, failed)
+	UpdatedAt  time.Time  `db:"updated_at"`
+}
+
+// Upload request statuses
+const (
+	UploadRequestPending    = "pending"    // Waiting for the daemon to pick it up
+	UploadRequestProcessing = "processing" // Claimed by the daemon, upload workflow running
+	UploadRequestInitiated  = "initiated"  // An upload was started (see upload_id)
+	UploadRequestSkipped    = "skipped"    // An upload was already running for the node, or the node is paused
+	UploadRequestCoalesced  = "coalesced"  // Merged into an upload started moments earlier (see upload_id)
+	UploadRequestFailed     = "failed"     // The upload could not be started (see error_message)
+)
+
+// UploadRequest is an entry in the daemon's upload queue: an operator's request queued
+// by the CLI, or a scheduled run queued because the concurrency limit was reached
+type UploadRequest struct {
+	ID              int64      `db:"id"`
+	NodeName        string     `db:"node_name"`
+	TriggerType     string     `db:"trigger_type"`     // manual or scheduled
+	Priority        int        `db:"priority"`         // The node's queue priority
+	TriggerMetadata JSONB      `db:"trigger_metadata"` // Who requested the upload and why
+	RequestedAt     time.Time  `db:"requested_at"`
+	Status          string     `db:"status"`
+	UploadID        *int64     `db:"upload_id"`     // The upload started for the request
+	ErrorMessage    *string    `db:"error_message"` // Why no upload was started
+	ProcessedAt     *time.Time `db:"processed_at"`  // When the daemon claimed the request
+	NotBefore       *time.Time `db:"not_before"`    // When a delayed request, such as a retry, may be taken
+}
+
+// UploadObject is one object in a completed snapshot's content listing
+type UploadObject struct {
+	UploadID  int64   `db:"upload_id"`
+	ObjectKey string  `db:"object_key"`
+	SizeBytes *int64  `db:"size_bytes"`
+	Checksum  *string `db:"checksum"`
+}
+
+// Consistency group run statuses
+const (
+	ConsistencyGroupRunStarting  = "starting"  // Members are being prepared
+	ConsistencyGroupRunInitiated = "initiated" // Every member's upload was started
+	ConsistencyGroupRunPartial   = "partial"   // Some members' uploads could not be started
+	ConsistencyGroupRunFailed    = "failed"    // No upload was started
+)
+
+// ConsistencyGroupRun is one coordinated start of a consistency group's uploads
+type ConsistencyGroupRun struct {
+	ID           int64     `db:"id"`
+	GroupName    string    `db:"group_name"`
+	StartedAt    time.Time `db:"started_at"`
+	Status       string    `db:"status"`
+	Anchors      JSONB     `db:"anchors"`       // Chain position of each member at the start, by node
+	ErrorMessage *string   `db:"error_message"` // Why members could not be started
+}
+
+// ConsistencyGroupUpload pairs a member's upload with the group run that started it
+type ConsistencyGroupUpload struct {
+	RunID    int64  `db:"run_id"`
+	NodeName string `db:"node_name"`
+	UploadID int64  `db:"upload_id"`
+}
+
+// Notification attempt statuses
+const (
+	NotificationSent    = "sent"    // The target accepted the notification
+	NotificationFailed  = "failed"  // Delivery failed (see error_message)
+	NotificationSnoozed = "snoozed" // Not sent because the node's notifications were snoozed
+)
+
+// NotificationAttempt is one delivery of a notification to one target
+type NotificationAttempt struct {
+	ID               int64     `db:"id"`
+	UploadID         *int64    `db:"upload_id"` // The upload the notification is about (nil when none)
+	NodeName         string    `db:"node_name"`
+	Event            string    `db:"event"`
+	NotificationType string    `db:"notification_type"`
+	TargetHash       string    `db:"target_hash"` // Hash of the target URL, which is not stored
+	Status           string    `db:"status"`
+	StatusCode       *int      `db:"status_code"` // Response code, when the module reports one
+	ErrorMessage     *string   `db:"error_message"`
+	AttemptedAt      time.Time `db:"attempted_at"`
+}
+
+// NotificationSnooze mutes a node's notifications until a point in time
+type NotificationSnooze struct {
+	ID           int64     `db:"id"`
+	NodeName     string    `db:"node_name"`
+	SnoozedUntil time.Time `db:"snoozed_until"`
+	Actor        string    `db:"actor"` // Who snoozed the node
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// DaemonHeartbeat records that a daemon is running on a host
+type DaemonHeartbeat struct {
+	Host        string    `db:"host"`
+	PID         int       `db:"pid"`
+	HeartbeatAt time.Time `db:"heartbeat_at"`
+}
+
+// StatusOutput is the raw engine output of an upload when its status changed, kept once
+// per transition rather than with every progress sample
+type StatusOutput struct {
+	ID         int64     `db:"id"`
+	UploadID   int64     `db:"upload_id"`
+	RecordedAt time.Time `db:"recorded_at"`
+	State      string    `db:"state"`      // The status without its timestamp, e.g. "Running"
+	RawOutput  string    `db:"raw_output"` // Capped by the upload manager
+}
+
+// UploadNotification is a notification about an upload, remembered so it is sent once
+// across daemon restarts. It is recorded before it is sent and marked sent after.
+type UploadNotification struct {
+	UploadID  int64      `db:"upload_id"`
+	Key       string     `db:"notification_key"` // Which notification, e.g. "completion" or "stalled:3100"
+	NodeName  string     `db:"node_name"`
+	Event     string     `db:"event"`
+	Message   string     `db:"message"`
+	Details   JSONB      `db:"details"`
+	CreatedAt time.Time  `db:"created_at"`
+	SentAt    *time.Time `db:"sent_at"` // nil while the notification is pending
+}
+
+// JobState is what a daemon's scheduler last did with one of its named jobs
+type JobState struct {
+	Host           string     `db:"host"`
+	JobName        string     `db:"job_name"` // e.g. "upload_monitor" or "node_upload/ethereum-mainnet"
+	Schedule       string     `db:"schedule"`
+	LastRunAt      *time.Time `db:"last_run_at"`
+	LastResult     *string    `db:"last_result"` // running, succeeded, failed or panicked
+	LastError      *string    `db:"last_error"`
+	LastDurationMs *int64     `db:"last_duration_ms"` // nil while the last run is in progress
+	NextRunAt      *time.Time `db:"next_run_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// ThrottleEvent is a host resource guardrail action taken on a running upload, from
+// crossing a threshold until usage recovered
+type ThrottleEvent struct {
+	ID            int64      `db:"id"`
+	UploadID      int64      `db:"upload_id"`
+	NodeName      string     `db:"node_name"`
+	Action        string     `db:"action"` // record, throttle or pause
+	Reason        string     `db:"reason"` // The thresholds crossed, e.g. "cpu 93.2% > 90%"
+	CPUPercent    *float64   `db:"cpu_percent"`
+	MemoryPercent *float64   `db:"memory_percent"`
+	DiskIOPercent *float64   `db:"disk_io_percent"`
+	StartedAt     time.Time  `db:"started_at"`
+	EndedAt       *time.Time `db:"ended_at"` // nil while the action is in effect
+}
+
+// RegisteredNode is a node added at runtime instead of in the configuration file
+type RegisteredNode struct {
+	NodeName     string    `db:"node_name"`
+	Host         string    `db:"host"`   // Host whose daemon runs the node; empty runs it on every daemon
+	Config       string    `db:"config"` // Node configuration as YAML, in the format of a nodes entry
+	RegisteredAt time.Time `db:"registered_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+// UploadCheckpoint is the saved progress of a node's upload run by an in-process engine,
+// from which the upload resumes after a failure or a restart of the daemon
+type UploadCheckpoint struct {
+	NodeName  string    `db:"node_name"`
+	Engine    string    `db:"engine"`
+	Session   string    `db:"session"` // Engine-specific description of the upload at the destination
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// CheckpointChunk is a chunk of a checkpointed upload that has reached the destination
+type CheckpointChunk struct {
+	NodeName    string    `db:"node_name"`
+	ChunkNumber int       `db:"chunk_number"`
+	ETag        string    `db:"etag"`
+	SizeBytes   int64     `db:"size_bytes"`
+	Checksum    string    `db:"checksum"`
+	UploadedAt  time.Time `db:"uploaded_at"`
 }
 
 // New creates a new database connection with connection pooling
 func New(ctx context.Context, cfg Config) (*DB, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode,
-	)
+	driver, err := getDriver(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := driver.Open(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	db := &DB{
+		conn:           conn,
+		driver:         driver,
+		maxRetries:     3,
+		retryBaseDelay: 100 * time.Millisecond,
+	}
+
+	return db, nil
+}
+
+// Close closes the database connection gracefully
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+// DriverName returns the name of the active database driver
+func (db *DB) DriverName() string {
+	return db.driver.Name()
+}
+
+// insertUploadQuery inserts an upload record with the arguments of insertUploadArgs
+const insertUploadQuery = `INSERT INTO uploads (node_name, protocol, node_type, started_at, status, trigger_type, trigger_metadata, protocol_data, 
+	                              progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                              completion_message, error_message, base_upload_id, agent, trace_parent, tenant, attempt, retry_of)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	          RETURNING id`
+
+// insertUploadArgs returns the arguments of insertUploadQuery for an upload. An upload
+// without an attempt is the first.
+func insertUploadArgs(upload Upload) []interface{} {
+	return []interface{}{upload.NodeName, upload.Protocol, upload.NodeType, upload.StartedAt, upload.Status, upload.TriggerType, upload.TriggerMetadata, upload.ProtocolData, upload.ProgressPercent, upload.ChunksCompleted, upload.ChunksTotal, upload.LastProgressCheck, upload.CompletionMessage, upload.ErrorMessage, upload.BaseUploadID, upload.Agent, upload.TraceParent, upload.Tenant, max(upload.Attempt, 1), upload.RetryOf}
+}
+
+// CreateUpload creates a new upload record with protocol data
+func (db *DB) CreateUpload(ctx context.Context, upload Upload) (int64, error) {
+	var id int64
+	err := db.queryRowWithRetry(ctx, insertUploadQuery, &id, insertUploadArgs(upload)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return id, nil
+}
+
+// CreateUploadIfNotRunning creates the upload unless its node already has a running
+// upload. The check and the insert run in one transaction holding the node's lock, so
+// concurrent triggers, in this daemon, another daemon or the CLI, cannot both create an
+// upload for the node. It returns the running upload's ID and false when there is one.
+func (db *DB) CreateUploadIfNotRunning(ctx context.Context, upload Upload) (int64, bool, error) {
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create upload: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := db.driver.LockNode(ctx, tx, upload.NodeName); err != nil {
+		return 0, false, fmt.Errorf("failed to lock node %s: %w", upload.NodeName, err)
+	}
+
+	var runningID int64
+	err = tx.GetContext(ctx, &runningID, db.driver.Rebind(`SELECT id FROM uploads
+	          WHERE node_name = $1 AND status = 'running'
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT 1`), upload.NodeName)
+	if err == nil {
+		return runningID, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("failed to check for running upload: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRowxContext(ctx, db.driver.Rebind(insertUploadQuery), insertUploadArgs(upload)...).Scan(&id)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// UpdateUpload updates an existing upload record
+func (db *DB) UpdateUpload(ctx context.Context, upload Upload) error {
+	query := `UPDATE uploads 
+	          SET completed_at = $1, status = $2, error_message = $3, 
+	              progress_percent = $4, chunks_completed = $5, chunks_total = $6, last_progress_check = $7,
+	              completion_message = $8
+	          WHERE id = $9`
+
+	return db.execWithRetry(ctx, query, upload.CompletedAt, upload.Status, upload.ErrorMessage, upload.ProgressPercent, upload.ChunksCompleted, upload.ChunksTotal, upload.LastProgressCheck, upload.CompletionMessage, upload.ID)
+}
+
+// UpdateUploadProgress updates only the progress-related fields of an upload record
+func (db *DB) UpdateUploadProgress(ctx context.Context, uploadID int64, status string, progressPercent *float64, chunksCompleted *int, chunksTotal *int, lastProgressCheck *time.Time) error {
+	query := `UPDATE uploads 
+	          SET status = $1, progress_percent = $2, chunks_completed = $3, chunks_total = $4, last_progress_check = $5
+	          WHERE id = $6`
+
+	return db.execWithRetry(ctx, query, status, progressPercent, chunksCompleted, chunksTotal, lastProgressCheck, uploadID)
+}
+
+// UpdateUploadCompletion updates an upload record when it completes
+func (db *DB) UpdateUploadCompletion(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error {
+	query := `UPDATE uploads 
+	          SET completed_at = $1, status = $2, completion_message = $3, error_message = $4
+	          WHERE id = $5`
+
+	return db.execWithRetry(ctx, query, completedAt, status, completionMessage, errorMessage, uploadID)
+}
+
+// SetUploadStalled records when an upload's progress stopped advancing; nil clears the mark
+func (db *DB) SetUploadStalled(ctx context.Context, uploadID int64, stalledSince *time.Time) error {
+	query := `UPDATE uploads 
+	          SET stalled_since = $1
+	          WHERE id = $2`
+
+	return db.execWithRetry(ctx, query, stalledSince, uploadID)
+}
+
+// SetUploadInterrupted records when a daemon stopped while an upload was running; nil clears
+// the mark once monitoring is re-attached
+func (db *DB) SetUploadInterrupted(ctx context.Context, uploadID int64, interruptedAt *time.Time) error {
+	query := `UPDATE uploads 
+	          SET interrupted_at = $1
+	          WHERE id = $2`
+
+	return db.execWithRetry(ctx, query, interruptedAt, uploadID)
+}
+
+// SetUploadDetectionLag records when bv reports an upload finished and how long the
+// monitor took to detect it
+func (db *DB) SetUploadDetectionLag(ctx context.Context, uploadID int64, finishedAt time.Time, lag time.Duration) error {
+	query := `UPDATE uploads
+	          SET finished_at = $1, detection_lag_seconds = $2
+	          WHERE id = $3`
+
+	if err := db.execWithRetry(ctx, query, finishedAt, lag.Seconds(), uploadID); err != nil {
+		return fmt.Errorf("failed to update upload detection lag: %w", err)
+	}
+
+	return nil
+}
+
+// SetUploadCompression records how a completed upload was compressed and its size before
+// and after compression
+func (db *DB) SetUploadCompression(ctx context.Context, uploadID int64, algorithm string, level int, rawBytes, compressedBytes int64) error {
+	query := `UPDATE uploads
+	          SET compression = $1, compression_level = $2, raw_size_bytes = $3, compressed_size_bytes = $4
+	          WHERE id = $5`
+
+	if err := db.execWithRetry(ctx, query, algorithm, level, rawBytes, compressedBytes, uploadID); err != nil {
+		return fmt.Errorf("failed to update upload compression: %w", err)
+	}
+
+	return nil
+}
+
+// SetUploadSize records the bytes a completed upload uploaded
+func (db *DB) SetUploadSize(ctx context.Context, uploadID int64, sizeBytes int64) error {
+	query := `UPDATE uploads SET size_bytes = $1 WHERE id = $2`
+
+	if err := db.execWithRetry(ctx, query, sizeBytes, uploadID); err != nil {
+		return fmt.Errorf("failed to update upload size: %w", err)
+	}
+
+	return nil
+}
+
+// SetUploadRestoreVerification records the outcome of spot-restoring a completed snapshot
+func (db *DB) SetUploadRestoreVerification(ctx context.Context, uploadID int64, outcome string, verifiedAt time.Time, message string) error {
+	query := `UPDATE uploads
+	          SET restore_verification = $1, restore_verified_at = $2, restore_verification_message = $3
+	          WHERE id = $4`
+
+	if err := db.execWithRetry(ctx, query, outcome, verifiedAt, message, uploadID); err != nil {
+		return fmt.Errorf("failed to update upload restore verification: %w", err)
+	}
+
+	return nil
+}
+
+// RecordProgressSample appends a chunk progress observation to an upload's history
+func (db *DB) RecordProgressSample(ctx context.Context, uploadID int64, sample analytics.Sample) error {
+	query := `INSERT INTO upload_progress_samples (upload_id, recorded_at, chunks_completed, chunks_total)
+	          VALUES ($1, $2, $3, $4)`
+
+	if err := db.execWithRetry(ctx, query, uploadID, sample.RecordedAt, sample.ChunksCompleted, sample.ChunksTotal); err != nil {
+		return fmt.Errorf("failed to record progress sample: %w", err)
+	}
+
+	return nil
+}
+
+// GetProgressSamples retrieves an upload's progress samples recorded at or after since, oldest first
+func (db *DB) GetProgressSamples(ctx context.Context, uploadID int64, since time.Time) ([]analytics.Sample, error) {
+	query := `SELECT recorded_at, chunks_completed, chunks_total
+	          FROM upload_progress_samples
+	          WHERE upload_id = $1 AND recorded_at >= $2
+	          ORDER BY recorded_at`
+
+	var rows []progressSample
+	if err := db.queryWithRetry(ctx, &rows, query, uploadID, since); err != nil {
+		return nil, fmt.Errorf("failed to get progress samples: %w", err)
+	}
+
+	samples := make([]analytics.Sample, 0, len(rows))
+	for _, row := range rows {
+		samples = append(samples, analytics.Sample{
+			RecordedAt:      row.RecordedAt,
+			ChunksCompleted: row.ChunksCompleted,
+			ChunksTotal:     row.ChunksTotal,
+		})
+	}
+
+	return samples, nil
+}
+
+// UpdateUploadThroughput stores an upload's current throughput and estimated completion
+func (db *DB) UpdateUploadThroughput(ctx context.Context, uploadID int64, chunksPerMinute *float64, estimatedCompletion *time.Time) error {
+	query := `UPDATE uploads
+	          SET chunks_per_minute = $1, estimated_completion = $2
+	          WHERE id = $3`
+
+	if err := db.execWithRetry(ctx, query, chunksPerMinute, estimatedCompletion, uploadID); err != nil {
+		return fmt.Errorf("failed to update upload throughput: %w", err)
+	}
+
+	return nil
+}
+
+// UploadFilter narrows the uploads returned by ListUploads
+type UploadFilter struct {
+	NodeName string        // Only uploads for this node (empty = all nodes)
+	Tenant   string        // Only uploads of this tenant's nodes (empty = all tenants)
+	Status   string        // Only uploads with this status (empty = all finished uploads)
+	Trigger  string        // Only uploads with this trigger type (empty = all triggers)
+	Since    time.Time     // Only uploads started at or after this time (zero = no limit)
+	Until    time.Time     // Only uploads started before this time (zero = no limit)
+	MinBlock int64         // Only uploads whose latest_block is at or above this block (0 = no limit)
+	MaxBlock int64         // Only uploads whose latest_block is at or below this block (0 = no limit)
+	Limit    int           // Maximum number of uploads (0 = no limit)
+	Offset   int           // Matching uploads to skip, most recent first
+	After    *UploadCursor // Only uploads listed after this position (keyset pagination; nil = from the start)
+}
+
+// UploadCursor is the position of an upload in the most-recent-first order of ListUploads
+// and GetUploadHistory. Unlike an offset, it stays on the same upload as new ones start.
+type UploadCursor struct {
+	StartedAt time.Time `json:"started_at"`
+	ID        int64     `json:"id"`
+}
+
+// uploadConditions returns the WHERE conditions of a filter, without its paging, with
+// their arguments numbered from $1
+func (db *DB) uploadConditions(filter UploadFilter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.NodeName != "" {
+		addCondition("node_name = $%d", filter.NodeName)
+	}
+	if filter.Tenant != "" {
+		addCondition("tenant = $%d", filter.Tenant)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	} else {
+		conditions = append(conditions, "completed_at IS NOT NULL")
+	}
+	if filter.Trigger != "" {
+		addCondition("trigger_type = $%d", filter.Trigger)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("started_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		addCondition("started_at < $%d", filter.Until)
+	}
+	if filter.MinBlock > 0 || filter.MaxBlock > 0 {
+		block, isNumber := db.driver.ProtocolDataNumber("latest_block")
+		conditions = append(conditions, isNumber)
+		if filter.MinBlock > 0 {
+			addCondition(block+" >= $%d", filter.MinBlock)
+		}
+		if filter.MaxBlock > 0 {
+			addCondition(block+" <= $%d", filter.MaxBlock)
+		}
+	}
+	return conditions, args
+}
+
+// ListUploads retrieves uploads matching the filter, most recent first
+func (db *DB) ListUploads(ctx context.Context, filter UploadFilter) ([]Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data,
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads`
+
+	conditions, args := db.uploadConditions(filter)
+	if filter.After != nil {
+		args = append(args, filter.After.StartedAt, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(started_at < $%d OR (started_at = $%d AND id < $%d))", len(args)-1, len(args)-1, len(args)))
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\t          WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\n\t          ORDER BY started_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\t          LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 {
+			// SQLite only takes an OFFSET after a LIMIT
+			args = append(args, int64(math.MaxInt64))
+			query += fmt.Sprintf("\n\t          LIMIT $%d", len(args))
+		}
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	var uploads []Upload
+	if err := db.queryWithRetry(ctx, &uploads, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// UploadStats summarizes every upload matching a filter
+type UploadStats struct {
+	Total              int            `json:"total"`
+	ByStatus           map[string]int `json:"by_status"`
+	Finished           int            `json:"finished"`                       // Uploads with a completion time
+	SuccessRate        *float64       `json:"success_rate,omitempty"`         // Completed share of finished uploads (nil when none finished)
+	AvgDurationSeconds *float64       `json:"avg_duration_seconds,omitempty"` // Mean run time of completed uploads (nil when none completed)
+}
+
+// UploadHistory is one page of the uploads matching a filter, with statistics over all
+// of them
+type UploadHistory struct {
+	Uploads []Upload
+	Stats   UploadStats
+	Next    *UploadCursor // Position to pass as the filter's After for the next page (nil on the last page)
+}
+
+// GetUploadHistory retrieves one page of the uploads matching the filter, most recent
+// first, with statistics over every matching upload whatever the page. Pages are taken
+// with Limit and either Offset or the previous page's Next as After.
+func (db *DB) GetUploadHistory(ctx context.Context, filter UploadFilter) (*UploadHistory, error) {
+	if filter.Offset > 0 && filter.After != nil {
+		return nil, fmt.Errorf("offset and cursor pagination cannot be combined")
+	}
+
+	// One more upload than the page tells whether there is a next page
+	page := filter
+	if filter.Limit > 0 {
+		page.Limit = filter.Limit + 1
+	}
+	uploads, err := db.ListUploads(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &UploadHistory{Uploads: uploads}
+	if filter.Limit > 0 && len(uploads) > filter.Limit {
+		history.Uploads = uploads[:filter.Limit]
+		last := history.Uploads[filter.Limit-1]
+		history.Next = &UploadCursor{StartedAt: last.StartedAt, ID: last.ID}
+	}
+
+	stats, err := db.GetUploadStats(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	history.Stats = *stats
+	return history, nil
+}
+
+// NodeUploadStats aggregates a node's most recent finished uploads
+type NodeUploadStats struct {
+	Uploads            int      `db:"uploads" json:"uploads"` // Finished uploads counted
+	Completed          int      `db:"completed" json:"completed"`
+	Failed             int      `db:"failed" json:"failed"`
+	AvgDurationSeconds *float64 `db:"avg_duration" json:"avg_duration_seconds,omitempty"` // Of completed uploads (nil when none completed)
+	MinDurationSeconds *float64 `db:"min_duration" json:"min_duration_seconds,omitempty"`
+	MaxDurationSeconds *float64 `db:"max_duration" json:"max_duration_seconds,omitempty"`
+	AvgChunks          *float64 `db:"avg_chunks" json:"avg_chunks,omitempty"` // Average chunks_total of completed uploads that report it
+}
+
+// GetNodeUploadStats aggregates the durations, chunk counts and outcomes of a node's last
+// finished uploads, up to limit (0 = all of them)
+func (db *DB) GetNodeUploadStats(ctx context.Context, nodeName string, limit int) (*NodeUploadStats, error) {
+	recent := fmt.Sprintf(`SELECT status, chunks_total, %s AS duration_seconds
+	                   FROM uploads
+	                   WHERE node_name = $1 AND completed_at IS NOT NULL
+	                   ORDER BY started_at DESC, id DESC`, db.driver.SecondsBetween("started_at", "completed_at"))
+	args := []interface{}{nodeName}
+	if limit > 0 {
+		args = append(args, limit)
+		recent += "\n\t                   LIMIT $2"
+	}
+
+	query := `SELECT COUNT(*) AS uploads,
+	                 COUNT(CASE WHEN status = 'completed' THEN 1 END) AS completed,
+	                 COUNT(CASE WHEN status = 'failed' THEN 1 END) AS failed,
+	                 AVG(CASE WHEN status = 'completed' THEN duration_seconds END) AS avg_duration,
+	                 MIN(CASE WHEN status = 'completed' THEN duration_seconds END) AS min_duration,
+	                 MAX(CASE WHEN status = 'completed' THEN duration_seconds END) AS max_duration,
+	                 AVG(CASE WHEN status = 'completed' THEN chunks_total END) AS avg_chunks
+	          FROM (` + recent + `) recent`
+
+	var stats NodeUploadStats
+	if err := db.getWithRetry(ctx, &stats, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get upload stats for node %s: %w", nodeName, err)
+	}
+	return &stats, nil
+}
+
+// GetUploadStats summarizes the uploads matching the filter, ignoring its paging
+func (db *DB) GetUploadStats(ctx context.Context, filter UploadFilter) (*UploadStats, error) {
+	conditions, args := db.uploadConditions(filter)
+	query := fmt.Sprintf(`SELECT status, COUNT(*) AS uploads, COUNT(completed_at) AS finished,
+	                 AVG(CASE WHEN status = 'completed' THEN %s END) AS avg_duration
+	          FROM uploads`, db.driver.SecondsBetween("started_at", "completed_at"))
+	if len(conditions) > 0 {
+		query += "\n\t          WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\n\t          GROUP BY status"
+
+	var rows []struct {
+		Status      string   `db:"status"`
+		Uploads     int      `db:"uploads"`
+		Finished    int      `db:"finished"`
+		AvgDuration *float64 `db:"avg_duration"`
+	}
+	if err := db.queryWithRetry(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get upload stats: %w", err)
+	}
+
+	stats := &UploadStats{ByStatus: make(map[string]int, len(rows))}
+	for _, row := range rows {
+		stats.Total += row.Uploads
+		stats.Finished += row.Finished
+		stats.ByStatus[row.Status] = row.Uploads
+		if row.Status == "completed" {
+			stats.AvgDurationSeconds = row.AvgDuration
+		}
+	}
+	if stats.Finished > 0 {
+		rate := float64(stats.ByStatus["completed"]) / float64(stats.Finished)
+		stats.SuccessRate = &rate
+	}
+	return stats, nil
+}
+
+// GetRunningUploads retrieves all currently running uploads
+func (db *DB) GetRunningUploads(ctx context.Context) ([]Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data, 
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE status = 'running'
+	          ORDER BY started_at DESC, id DESC`
+
+	var uploads []Upload
+	err := db.queryWithRetry(ctx, &uploads, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// UploadPage selects one page of uploads ordered by ID. The next page starts after the
+// last ID of the previous one, so rows inserted or finished meanwhile neither repeat nor
+// shift later pages.
+type UploadPage struct {
+	AfterID int64  // Only uploads with a greater ID (0 = from the first upload)
+	Limit   int    // Maximum number of uploads (0 = no limit)
+	Tenant  string // Only uploads of this tenant's nodes (empty = all tenants)
+}
+
+// ListRunningUploads retrieves one page of running uploads, oldest first
+func (db *DB) ListRunningUploads(ctx context.Context, page UploadPage) ([]Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data, 
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE status = 'running' AND id > $1`
+
+	args := []interface{}{page.AfterID}
+	if page.Tenant != "" {
+		args = append(args, page.Tenant)
+		query += fmt.Sprintf(" AND tenant = $%d", len(args))
+	}
+	query += "\n\t          ORDER BY id"
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		query += fmt.Sprintf("\n\t          LIMIT $%d", len(args))
+	}
+
+	var uploads []Upload
+	if err := db.queryWithRetry(ctx, &uploads, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list running uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// CountRunningUploads returns the number of running uploads
+func (db *DB) CountRunningUploads(ctx context.Context) (int, error) {
+	var count int
+	err := db.getWithRetry(ctx, &count, `SELECT COUNT(*) FROM uploads WHERE status = 'running'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count running uploads: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListInterruptedUploads retrieves the running uploads a daemon stopped during, oldest first
+func (db *DB) ListInterruptedUploads(ctx context.Context) ([]Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data,
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE status = 'running' AND interrupted_at IS NOT NULL
+	          ORDER BY id`
+
+	uploads := []Upload{}
+	if err := db.queryWithRetry(ctx, &uploads, query); err != nil {
+		return nil, fmt.Errorf("failed to list interrupted uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// GetRunningUploadNodes returns the names of the nodes with a running upload, sorted
+func (db *DB) GetRunningUploadNodes(ctx context.Context) ([]string, error) {
+	query := `SELECT DISTINCT node_name
+	          FROM uploads
+	          WHERE status = 'running'
+	          ORDER BY node_name`
+
+	var nodes []string
+	if err := db.queryWithRetry(ctx, &nodes, query); err != nil {
+		return nil, fmt.Errorf("failed to get running upload nodes: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// GetRunningUploadForNode retrieves a running upload for a specific node
+func (db *DB) GetRunningUploadForNode(ctx context.Context, nodeName string) (*Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data,
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE node_name = $1 AND status = 'running'
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT 1`
+
+	var upload Upload
+	err := db.getWithRetry(ctx, &upload, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running upload for node: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// GetLatestCompletedUploadForNode retrieves the most recent completed upload for a node
+func (db *DB) GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data,
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE node_name = $1 AND status = 'completed' AND completed_at IS NOT NULL
+	          ORDER BY completed_at DESC, id DESC
+	          LIMIT 1`
+
+	var upload Upload
+	err := db.getWithRetry(ctx, &upload, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest completed upload for node: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// CountConsecutiveFailedUploads counts a node's failed uploads since its last completed
+// upload. Uploads that were cancelled or stalled in between neither count nor break the run.
+func (db *DB) CountConsecutiveFailedUploads(ctx context.Context, nodeName string) (int, error) {
+	query := `SELECT COUNT(*)
+	          FROM uploads
+	          WHERE node_name = $1 AND status = 'failed'
+	            AND id > COALESCE((SELECT MAX(id) FROM uploads WHERE node_name = $1 AND status = 'completed'), 0)`
+
+	var count int
+	if err := db.getWithRetry(ctx, &count, query, nodeName); err != nil {
+		return 0, fmt.Errorf("failed to count consecutive failed uploads: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetSnapshotAtOrAboveBlock retrieves the node's completed upload with the highest
+// latest_block, if that block is at or above block, or nil if there is none
+func (db *DB) GetSnapshotAtOrAboveBlock(ctx context.Context, nodeName string, block int64) (*Upload, error) {
+	return db.getSnapshotByBlock(ctx, nodeName, ">=", block)
+}
+
+// GetSnapshotAtOrBelowBlock retrieves the node's completed upload with the highest
+// latest_block at or below block, such as the snapshot to restore a node to a height
+// from, or nil if there is none
+func (db *DB) GetSnapshotAtOrBelowBlock(ctx context.Context, nodeName string, block int64) (*Upload, error) {
+	return db.getSnapshotByBlock(ctx, nodeName, "<=", block)
+}
+
+// getSnapshotByBlock retrieves the node's completed upload with the highest latest_block
+// comparing to block with op, through the idx_uploads_latest_block index
+func (db *DB) getSnapshotByBlock(ctx context.Context, nodeName, op string, block int64) (*Upload, error) {
+	value, isNumber := db.driver.ProtocolDataNumber("latest_block")
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data,
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE node_name = $1 AND status = 'completed' AND ` + isNumber + ` AND ` + value + ` ` + op + ` $2
+	          ORDER BY ` + value + ` DESC, id DESC
+	          LIMIT 1`
+
+	var upload Upload
+	err := db.getWithRetry(ctx, &upload, query, nodeName, block)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot by block for node: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// SaveScheduleState inserts or replaces the schedule state for a node
+func (db *DB) SaveScheduleState(ctx context.Context, state ScheduleState) error {
+	query := `INSERT INTO schedule_state (node_name, last_run_at, next_run_at, last_result, updated_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (node_name) DO UPDATE SET
+	              last_run_at = EXCLUDED.last_run_at,
+	              next_run_at = EXCLUDED.next_run_at,
+	              last_result = EXCLUDED.last_result,
+	              updated_at = EXCLUDED.updated_at`
+
+	if err := db.execWithRetry(ctx, query, state.NodeName, state.LastRunAt, state.NextRunAt, state.LastResult, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save schedule state: %w", err)
+	}
+
+	return nil
+}
+
+// GetScheduleState retrieves the schedule state for a node, or nil if none is recorded
+func (db *DB) GetScheduleState(ctx context.Context, nodeName string) (*ScheduleState, error) {
+	query := `SELECT node_name, last_run_at, next_run_at, last_result, updated_at
+	          FROM schedule_state
+	          WHERE node_name = $1`
+
+	var state ScheduleState
+	err := db.getWithRetry(ctx, &state, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// GetScheduleStates retrieves the schedule state for all nodes
+func (db *DB) GetScheduleStates(ctx context.Context) ([]ScheduleState, error) {
+	query := `SELECT node_name, last_run_at, next_run_at, last_result, updated_at
+	          FROM schedule_state
+	          ORDER BY node_name`
+
+	var states []ScheduleState
+	if err := db.queryWithRetry(ctx, &states, query); err != nil {
+		return nil, fmt.Errorf("failed to get schedule states: %w", err)
+	}
+
+	return states, nil
+}
+
+// SaveJobSchedule records a job's schedule and next run, keeping the last run recorded for it
+func (db *DB) SaveJobSchedule(ctx context.Context, host, jobName, schedule string, nextRunAt *time.Time) error {
+	query := `INSERT INTO job_states (host, job_name, schedule, next_run_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (host, job_name) DO UPDATE SET
+	              schedule = EXCLUDED.schedule,
+	              next_run_at = EXCLUDED.next_run_at,
+	              updated_at = EXCLUDED.updated_at`
+
+	if err := db.execWithRetry(ctx, query, host, jobName, schedule, nextRunAt, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save job schedule: %w", err)
+	}
+
+	return nil
+}
+
+// RecordJobRun inserts or replaces a job's state after it started or finished a run
+func (db *DB) RecordJobRun(ctx context.Context, state JobState) error {
+	query := `INSERT INTO job_states (host, job_name, schedule, last_run_at, last_result, last_error, last_duration_ms, next_run_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	          ON CONFLICT (host, job_name) DO UPDATE SET
+	              schedule = EXCLUDED.schedule,
+	              last_run_at = EXCLUDED.last_run_at,
+	              last_result = EXCLUDED.last_result,
+	              last_error = EXCLUDED.last_error,
+	              last_duration_ms = EXCLUDED.last_duration_ms,
+	              next_run_at = EXCLUDED.next_run_at,
+	              updated_at = EXCLUDED.updated_at`
+
+	err := db.execWithRetry(ctx, query, state.Host, state.JobName, state.Schedule, state.LastRunAt, state.LastResult,
+		state.LastError, state.LastDurationMs, state.NextRunAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteJobState forgets a job the daemon on host no longer schedules
+func (db *DB) DeleteJobState(ctx context.Context, host, jobName string) error {
+	query := `DELETE FROM job_states WHERE host = $1 AND job_name = $2`
+
+	if err := db.execWithRetry(ctx, query, host, jobName); err != nil {
+		return fmt.Errorf("failed to delete job state: %w", err)
+	}
+
+	return nil
+}
+
+// GetJobStates retrieves the job states of the daemon on host, or of every daemon when
+// host is empty, ordered by host and job name
+func (db *DB) GetJobStates(ctx context.Context, host string) ([]JobState, error) {
+	query := `SELECT host, job_name, schedule, last_run_at, last_result, last_error, last_duration_ms, next_run_at, updated_at
+	          FROM job_states
+	          WHERE $1 = '' OR host = $1
+	          ORDER BY host, job_name`
+
+	var states []JobState
+	if err := db.queryWithRetry(ctx, &states, query, host); err != nil {
+		return nil, fmt.Errorf("failed to get job states: %w", err)
+	}
+
+	return states, nil
+}
+
+// GetUpload retrieves an upload by ID, or nil if it does not exist
+func (db *DB) GetUpload(ctx context.Context, uploadID int64) (*Upload, error) {
+	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
+	                 trigger_type, trigger_metadata, error_message, protocol_data,
+	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
+	                 completion_message, stalled_since, chunks_per_minute, estimated_completion,
+	                 finished_at, detection_lag_seconds, base_upload_id,
+	                 restore_verification, restore_verified_at, restore_verification_message,
+                 compression, compression_level, raw_size_bytes, compressed_size_bytes, size_bytes,
+                 coalesced_triggers, agent, trace_parent, tenant, interrupted_at, attempt, retry_of
+	          FROM uploads
+	          WHERE id = $1`
+
+	var upload Upload
+	err := db.getWithRetry(ctx, &upload, query, uploadID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// RecordUploadObjects replaces the content listing of an upload. The listing is written
+// in a single transaction so readers never see a partial snapshot.
+func (db *DB) RecordUploadObjects(ctx context.Context, uploadID int64, objects []UploadObject) error {
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record upload objects: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, db.driver.Rebind(`DELETE FROM upload_objects WHERE upload_id = $1`), uploadID); err != nil {
+		return fmt.Errorf("failed to record upload objects: %w", err)
+	}
+
+	insert, err := tx.PreparexContext(ctx, db.driver.Rebind(`INSERT INTO upload_objects (upload_id, object_key, size_bytes, checksum)
+	          VALUES ($1, $2, $3, $4)`))
+	if err != nil {
+		return fmt.Errorf("failed to record upload objects: %w", err)
+	}
+	defer insert.Close()
+
+	for _, object := range objects {
+		if _, err := insert.ExecContext(ctx, uploadID, object.ObjectKey, object.SizeBytes, object.Checksum); err != nil {
+			return fmt.Errorf("failed to record upload object %s: %w", object.ObjectKey, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record upload objects: %w", err)
+	}
+
+	return nil
+}
+
+// GetUploadObjects retrieves the content listing of an upload, ordered by object key
+func (db *DB) GetUploadObjects(ctx context.Context, uploadID int64) ([]UploadObject, error) {
+	query := `SELECT upload_id, object_key, size_bytes, checksum
+	          FROM upload_objects
+	          WHERE upload_id = $1
+	          ORDER BY object_key`
+
+	var objects []UploadObject
+	if err := db.queryWithRetry(ctx, &objects, query, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to get upload objects: %w", err)
+	}
+
+	return objects, nil
+}
+
+// RecordDaemonHeartbeat records that the daemon on a host is alive
+func (db *DB) RecordDaemonHeartbeat(ctx context.Context, host string, pid int, at time.Time) error {
+	query := `INSERT INTO daemon_heartbeats (host, pid, heartbeat_at)
+	          VALUES ($1, $2, $3)
+	          ON CONFLICT (host) DO UPDATE SET
+	              pid = EXCLUDED.pid,
+	              heartbeat_at = EXCLUDED.heartbeat_at`
+
+	if err := db.execWithRetry(ctx, query, host, pid, at.UTC()); err != nil {
+		return fmt.Errorf("failed to record daemon heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// ClearDaemonHeartbeat removes a host's heartbeat when its daemon shuts down
+func (db *DB) ClearDaemonHeartbeat(ctx context.Context, host string) error {
+	if err := db.execWithRetry(ctx, `DELETE FROM daemon_heartbeats WHERE host = $1`, host); err != nil {
+		return fmt.Errorf("failed to clear daemon heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// GetDaemonHeartbeat retrieves the heartbeat of the daemon on a host, or nil if none is recorded
+func (db *DB) GetDaemonHeartbeat(ctx context.Context, host string) (*DaemonHeartbeat, error) {
+	query := `SELECT host, pid, heartbeat_at
+	          FROM daemon_heartbeats
+	          WHERE host = $1`
+
+	var heartbeat DaemonHeartbeat
+	err := db.getWithRetry(ctx, &heartbeat, query, host)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daemon heartbeat: %w", err)
+	}
+
+	return &heartbeat, nil
+}
+
+// CreateUploadRequest queues a pending upload request for the daemon
+func (db *DB) CreateUploadRequest(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata JSONB) (int64, error) {
+	query := `INSERT INTO upload_requests (node_name, trigger_type, priority, trigger_metadata, requested_at, status)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING id`
+
+	var id int64
+	if err := db.queryRowWithRetry(ctx, query, &id, nodeName, triggerType, priority, triggerMetadata, time.Now().UTC(), UploadRequestPending); err != nil {
+		return 0, fmt.Errorf("failed to create upload request: %w", err)
+	}
+
+	return id, nil
+}
+
+// CreateDelayedUploadRequest queues a pending upload request the daemon takes no earlier
+// than notBefore
+func (db *DB) CreateDelayedUploadRequest(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata JSONB, notBefore time.Time) (int64, error) {
+	query := `INSERT INTO upload_requests (node_name, trigger_type, priority, trigger_metadata, requested_at, status, not_before)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          RETURNING id`
+
+	var id int64
+	if err := db.queryRowWithRetry(ctx, query, &id, nodeName, triggerType, priority, triggerMetadata, time.Now().UTC(), UploadRequestPending, notBefore.UTC()); err != nil {
+		return 0, fmt.Errorf("failed to create upload request: %w", err)
+	}
+
+	return id, nil
+}
+
+// ClaimUploadRequests marks a node's pending upload requests as processing and returns
+// them in queue order. A request is claimed by a single caller. Delayed requests are left
+// pending until their not_before has passed.
+func (db *DB) ClaimUploadRequests(ctx context.Context, nodeName string) ([]UploadRequest, error) {
+	query := `UPDATE upload_requests
+	          SET status = $1, processed_at = $2
+	          WHERE node_name = $3 AND status = $4 AND (not_before IS NULL OR not_before <= $2)
+	          RETURNING id, node_name, trigger_type, priority, trigger_metadata, requested_at, status, upload_id, error_message, processed_at, not_before`
+
+	var requests []UploadRequest
+	if err := db.queryWithRetry(ctx, &requests, query, UploadRequestProcessing, time.Now().UTC(), nodeName, UploadRequestPending); err != nil {
+		return nil, fmt.Errorf("failed to claim upload requests: %w", err)
+	}
+
+	sortUploadQueue(requests)
+	return requests, nil
+}
+
+// ListUploadQueue retrieves the pending and processing upload requests. Processing
+// requests come first, followed by pending requests in the order the daemon takes them.
+func (db *DB) ListUploadQueue(ctx context.Context) ([]UploadRequest, error) {
+	query := `SELECT id, node_name, trigger_type, priority, trigger_metadata, requested_at, status, upload_id, error_message, processed_at, not_before
+	          FROM upload_requests
+	          WHERE status IN ($1, $2)`
+
+	var requests []UploadRequest
+	if err := db.queryWithRetry(ctx, &requests, query, UploadRequestProcessing, UploadRequestPending); err != nil {
+		return nil, fmt.Errorf("failed to list upload queue: %w", err)
+	}
+
+	sortUploadQueue(requests)
+	sort.SliceStable(requests, func(i, k int) bool {
+		return requests[i].Status == UploadRequestProcessing && requests[k].Status != UploadRequestProcessing
+	})
+	return requests, nil
+}
+
+// sortUploadQueue orders upload requests the way the daemon takes them: manual requests
+// before scheduled runs, then by priority (highest first), then oldest first
+func sortUploadQueue(requests []UploadRequest) {
+	sort.SliceStable(requests, func(i, k int) bool {
+		a, b := requests[i], requests[k]
+		if manualA, manualB := a.TriggerType == "manual", b.TriggerType == "manual"; manualA != manualB {
+			return manualA
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.ID < b.ID
+	})
+}
 
-	conn, err := sqlx.ConnectContext(ctx, "postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+// CompleteUploadRequest records the outcome of a claimed upload request
+func (db *DB) CompleteUploadRequest(ctx context.Context, requestID int64, status string, uploadID *int64, errorMessage *string) error {
+	query := `UPDATE upload_requests
+	          SET status = $1, upload_id = $2, error_message = $3
+	          WHERE id = $4`
+
+	if err := db.execWithRetry(ctx, query, status, uploadID, errorMessage, requestID); err != nil {
+		return fmt.Errorf("failed to complete upload request: %w", err)
 	}
 
-	// Configure connection pool
-	conn.SetMaxOpenConns(25)
-	conn.SetMaxIdleConns(5)
-	conn.SetConnMaxLifetime(5 * time.Minute)
+	return nil
+}
 
-	db := &DB{
-		conn:           conn,
-		maxRetries:     3,
-		retryBaseDelay: 100 * time.Millisecond,
+// IncrementCoalescedTriggers counts another upload request coalesced into an upload,
+// returning the upload's new count
+func (db *DB) IncrementCoalescedTriggers(ctx context.Context, uploadID int64) (int, error) {
+	query := `UPDATE uploads
+	          SET coalesced_triggers = coalesced_triggers + 1
+	          WHERE id = $1
+	          RETURNING coalesced_triggers`
+
+	var count int
+	if err := db.queryRowWithRetry(ctx, query, &count, uploadID); err != nil {
+		return 0, fmt.Errorf("failed to count coalesced trigger: %w", err)
 	}
 
-	return db, nil
+	return count, nil
 }
 
-// Close closes the database connection gracefully
-func (db *DB) Close() error {
-	return db.conn.Close()
+// GetUploadRequest retrieves an upload request by ID, or nil if it does not exist
+func (db *DB) GetUploadRequest(ctx context.Context, requestID int64) (*UploadRequest, error) {
+	query := `SELECT id, node_name, trigger_type, priority, trigger_metadata, requested_at, status, upload_id, error_message, processed_at, not_before
+	          FROM upload_requests
+	          WHERE id = $1`
+
+	var request UploadRequest
+	err := db.getWithRetry(ctx, &request, query, requestID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload request: %w", err)
+	}
+
+	return &request, nil
 }
 
-// Migrate runs database migrations to create required tables
-func (db *DB) Migrate(ctx context.Context) error {
-	migrations := []string{
-		// Create new uploads table structure
-		`CREATE TABLE IF NOT EXISTS uploads (
-			id BIGSERIAL PRIMARY KEY,
-			node_name VARCHAR(255) NOT NULL,
-			protocol VARCHAR(50) NOT NULL,
-			node_type VARCHAR(50),
-			started_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			completed_at TIMESTAMP,
-			status VARCHAR(50) NOT NULL,
-			trigger_type VARCHAR(20) NOT NULL,
-			error_message TEXT,
-			protocol_data JSONB NOT NULL,
-			total_chunks INTEGER,
-			completion_message TEXT
-		)`,
-		// Add new columns to existing uploads table
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS protocol VARCHAR(50)`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS node_type VARCHAR(50)`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS protocol_data JSONB`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS total_chunks INTEGER`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS completion_message TEXT`,
-		// Add progress columns to uploads table
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS progress_percent DECIMAL(5,2)`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS chunks_completed INTEGER`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS chunks_total INTEGER`,
-		`ALTER TABLE uploads ADD COLUMN IF NOT EXISTS last_progress_check TIMESTAMP`,
-		// Drop old columns (will be ignored if they don't exist)
-		`ALTER TABLE uploads DROP COLUMN IF EXISTS progress`,
-		`ALTER TABLE uploads DROP COLUMN IF EXISTS latest_block`,
-		`ALTER TABLE uploads DROP COLUMN IF EXISTS latest_slot`,
-		`ALTER TABLE uploads DROP COLUMN IF EXISTS data_size_bytes`,
-		`ALTER TABLE uploads DROP COLUMN IF EXISTS total_chunks`,
-		// Create indexes
-		`CREATE INDEX IF NOT EXISTS idx_uploads_node_status 
-		 ON uploads (node_name, status)`,
-		`CREATE INDEX IF NOT EXISTS idx_uploads_started 
-		 ON uploads (started_at DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_uploads_completed 
-		 ON uploads (node_name, completed_at DESC) WHERE completed_at IS NOT NULL`,
-		// Drop old tables
-		`DROP TABLE IF EXISTS upload_progress`,
-		`DROP TABLE IF EXISTS node_metrics`,
-	}
-
-	for _, migration := range migrations {
-		if err := db.execWithRetry(ctx, migration); err != nil {
-			return fmt.Errorf("migration failed: %w", err)
-		}
+// GetUploadRequestForUpload retrieves the queued request an upload was started for, or
+// nil when the upload was started directly
+func (db *DB) GetUploadRequestForUpload(ctx context.Context, uploadID int64) (*UploadRequest, error) {
+	query := `SELECT id, node_name, trigger_type, priority, trigger_metadata, requested_at, status, upload_id, error_message, processed_at, not_before
+	          FROM upload_requests
+	          WHERE upload_id = $1
+	          ORDER BY id
+	          LIMIT 1`
+
+	var request UploadRequest
+	err := db.getWithRetry(ctx, &request, query, uploadID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload request for upload: %w", err)
 	}
 
-	return nil
+	return &request, nil
 }
 
-// CreateUpload creates a new upload record with protocol data
-func (db *DB) CreateUpload(ctx context.Context, upload Upload) (int64, error) {
-	query := `INSERT INTO uploads (node_name, protocol, node_type, started_at, status, trigger_type, protocol_data, 
-	                              progress_percent, chunks_completed, chunks_total, last_progress_check,
-	                              completion_message, error_message)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+// CreateConsistencyGroupRun records the start of a consistency group run
+func (db *DB) CreateConsistencyGroupRun(ctx context.Context, groupName string, startedAt time.Time) (int64, error) {
+	query := `INSERT INTO consistency_group_runs (group_name, started_at, status)
+	          VALUES ($1, $2, $3)
 	          RETURNING id`
 
 	var id int64
-	err := db.queryRowWithRetry(ctx, query, &id, upload.NodeName, upload.Protocol, upload.NodeType, upload.StartedAt, upload.Status, upload.TriggerType, upload.ProtocolData, upload.ProgressPercent, upload.ChunksCompleted, upload.ChunksTotal, upload.LastProgressCheck, upload.CompletionMessage, upload.ErrorMessage)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create upload: %w", err)
+	if err := db.queryRowWithRetry(ctx, query, &id, groupName, startedAt.UTC(), ConsistencyGroupRunStarting); err != nil {
+		return 0, fmt.Errorf("failed to create consistency group run: %w", err)
 	}
 
 	return id, nil
 }
 
-// UpdateUpload updates an existing upload record
-func (db *DB) UpdateUpload(ctx context.Context, upload Upload) error {
-	query := `UPDATE uploads 
-	          SET completed_at = $1, status = $2, error_message = $3, 
-	              progress_percent = $4, chunks_completed = $5, chunks_total = $6, last_progress_check = $7,
-	              completion_message = $8
-	          WHERE id = $9`
+// AddConsistencyGroupUpload records a member's upload as part of a group run
+func (db *DB) AddConsistencyGroupUpload(ctx context.Context, runID int64, nodeName string, uploadID int64) error {
+	query := `INSERT INTO consistency_group_uploads (run_id, node_name, upload_id)
+	          VALUES ($1, $2, $3)`
 
-	return db.execWithRetry(ctx, query, upload.CompletedAt, upload.Status, upload.ErrorMessage, upload.ProgressPercent, upload.ChunksCompleted, upload.ChunksTotal, upload.LastProgressCheck, upload.CompletionMessage, upload.ID)
+	if err := db.execWithRetry(ctx, query, runID, nodeName, uploadID); err != nil {
+		return fmt.Errorf("failed to add consistency group upload: %w", err)
+	}
+
+	return nil
 }
 
-// UpdateUploadProgress updates only the progress-related fields of an upload record
-func (db *DB) UpdateUploadProgress(ctx context.Context, uploadID int64, status string, progressPercent *float64, chunksCompleted *int, chunksTotal *int, lastProgressCheck *time.Time) error {
-	query := `UPDATE uploads 
-	          SET status = $1, progress_percent = $2, chunks_completed = $3, chunks_total = $4, last_progress_check = $5
-	          WHERE id = $6`
+// CompleteConsistencyGroupRun records the outcome of a group run and its members' anchors
+func (db *DB) CompleteConsistencyGroupRun(ctx context.Context, runID int64, status string, anchors JSONB, errorMessage *string) error {
+	query := `UPDATE consistency_group_runs
+	          SET status = $1, anchors = $2, error_message = $3
+	          WHERE id = $4`
 
-	return db.execWithRetry(ctx, query, status, progressPercent, chunksCompleted, chunksTotal, lastProgressCheck, uploadID)
+	if err := db.execWithRetry(ctx, query, status, anchors, errorMessage, runID); err != nil {
+		return fmt.Errorf("failed to complete consistency group run: %w", err)
+	}
+
+	return nil
 }
 
-// UpdateUploadCompletion updates an upload record when it completes
-func (db *DB) UpdateUploadCompletion(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error {
-	query := `UPDATE uploads 
-	          SET completed_at = $1, status = $2, completion_message = $3, error_message = $4
-	          WHERE id = $5`
+// ListConsistencyGroupRuns retrieves group runs, newest first. An empty group name lists
+// every group; a limit of 0 returns all runs.
+func (db *DB) ListConsistencyGroupRuns(ctx context.Context, groupName string, limit int) ([]ConsistencyGroupRun, error) {
+	query := `SELECT id, group_name, started_at, status, anchors, error_message
+	          FROM consistency_group_runs`
 
-	return db.execWithRetry(ctx, query, completedAt, status, completionMessage, errorMessage, uploadID)
+	var args []interface{}
+	if groupName != "" {
+		args = append(args, groupName)
+		query += "\n\t          WHERE group_name = $1"
+	}
+	query += "\n\t          ORDER BY started_at DESC, id DESC"
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf("\n\t          LIMIT $%d", len(args))
+	}
+
+	var runs []ConsistencyGroupRun
+	if err := db.queryWithRetry(ctx, &runs, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list consistency group runs: %w", err)
+	}
+
+	return runs, nil
 }
 
-// GetRunningUploads retrieves all currently running uploads
-func (db *DB) GetRunningUploads(ctx context.Context) ([]Upload, error) {
-	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
-	                 trigger_type, error_message, protocol_data, 
-	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
-	                 completion_message
-	          FROM uploads
-	          WHERE status = 'running'
-	          ORDER BY started_at DESC`
+// GetConsistencyGroupUploads retrieves the member uploads of a group run, ordered by node
+func (db *DB) GetConsistencyGroupUploads(ctx context.Context, runID int64) ([]ConsistencyGroupUpload, error) {
+	query := `SELECT run_id, node_name, upload_id
+	          FROM consistency_group_uploads
+	          WHERE run_id = $1
+	          ORDER BY node_name`
 
-	var uploads []Upload
-	err := db.queryWithRetry(ctx, &uploads, query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get running uploads: %w", err)
+	var uploads []ConsistencyGroupUpload
+	if err := db.queryWithRetry(ctx, &uploads, query, runID); err != nil {
+		return nil, fmt.Errorf("failed to get consistency group uploads: %w", err)
 	}
 
 	return uploads, nil
 }
 
-// GetRunningUploadForNode retrieves a running upload for a specific node
-func (db *DB) GetRunningUploadForNode(ctx context.Context, nodeName string) (*Upload, error) {
-	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
-	                 trigger_type, error_message, protocol_data,
-	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
-	                 completion_message
-	          FROM uploads
-	          WHERE node_name = $1 AND status = 'running'
-	          ORDER BY started_at DESC
+// GetConsistencyGroupRunForUpload retrieves the group run that started an upload, or nil
+// when the upload was not part of a consistency group
+func (db *DB) GetConsistencyGroupRunForUpload(ctx context.Context, uploadID int64) (*ConsistencyGroupRun, error) {
+	query := `SELECT r.id, r.group_name, r.started_at, r.status, r.anchors, r.error_message
+	          FROM consistency_group_runs r
+	          JOIN consistency_group_uploads u ON u.run_id = r.id
+	          WHERE u.upload_id = $1
 	          LIMIT 1`
 
-	var upload Upload
-	err := db.getWithRetry(ctx, &upload, query, nodeName)
+	var run ConsistencyGroupRun
+	err := db.getWithRetry(ctx, &run, query, uploadID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get running upload for node: %w", err)
+		return nil, fmt.Errorf("failed to get consistency group run for upload: %w", err)
 	}
 
-	return &upload, nil
+	return &run, nil
 }
 
-// GetLatestCompletedUploadForNode retrieves the most recent completed upload for a node
-func (db *DB) GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*Upload, error) {
-	query := `SELECT id, node_name, protocol, node_type, started_at, completed_at, status, 
-	                 trigger_type, error_message, protocol_data,
-	                 progress_percent, chunks_completed, chunks_total, last_progress_check,
-	                 completion_message
-	          FROM uploads
-	          WHERE node_name = $1 AND status = 'completed' AND completed_at IS NOT NULL
-	          ORDER BY completed_at DESC
+// RecordNotificationAttempt stores the outcome of a notification delivery
+func (db *DB) RecordNotificationAttempt(ctx context.Context, attempt NotificationAttempt) error {
+	query := `INSERT INTO notification_attempts (upload_id, node_name, event, notification_type, target_hash, status, status_code, error_message, attempted_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	if err := db.execWithRetry(ctx, query, attempt.UploadID, attempt.NodeName, attempt.Event, attempt.NotificationType,
+		attempt.TargetHash, attempt.Status, attempt.StatusCode, attempt.ErrorMessage, attempt.AttemptedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record notification attempt: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotificationAttempts retrieves the notification attempts about an upload, oldest first
+func (db *DB) GetNotificationAttempts(ctx context.Context, uploadID int64) ([]NotificationAttempt, error) {
+	query := `SELECT id, upload_id, node_name, event, notification_type, target_hash, status, status_code, error_message, attempted_at
+	          FROM notification_attempts
+	          WHERE upload_id = $1
+	          ORDER BY attempted_at, id`
+
+	var attempts []NotificationAttempt
+	if err := db.queryWithRetry(ctx, &attempts, query, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to get notification attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// CreateNotificationSnooze records that a node's notifications are muted until
+// snooze.SnoozedUntil
+func (db *DB) CreateNotificationSnooze(ctx context.Context, snooze NotificationSnooze) (int64, error) {
+	query := `INSERT INTO notification_snoozes (node_name, snoozed_until, actor, created_at)
+	          VALUES ($1, $2, $3, $4)
+	          RETURNING id`
+
+	var id int64
+	if err := db.queryRowWithRetry(ctx, query, &id, snooze.NodeName, snooze.SnoozedUntil.UTC(), snooze.Actor, snooze.CreatedAt.UTC()); err != nil {
+		return 0, fmt.Errorf("failed to create notification snooze: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetActiveNotificationSnooze retrieves the node's snooze that lasts longest past now.
+// Returns nil if the node's notifications are not snoozed.
+func (db *DB) GetActiveNotificationSnooze(ctx context.Context, nodeName string, now time.Time) (*NotificationSnooze, error) {
+	query := `SELECT id, node_name, snoozed_until, actor, created_at
+	          FROM notification_snoozes
+	          WHERE node_name = $1 AND snoozed_until > $2
+	          ORDER BY snoozed_until DESC, id DESC
 	          LIMIT 1`
 
-	var upload Upload
-	err := db.getWithRetry(ctx, &upload, query, nodeName)
+	var snooze NotificationSnooze
+	err := db.getWithRetry(ctx, &snooze, query, nodeName, now.UTC())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get latest completed upload for node: %w", err)
+		return nil, fmt.Errorf("failed to get notification snooze: %w", err)
 	}
 
-	return &upload, nil
+	return &snooze, nil
+}
+
+// GetActiveNotificationSnoozes retrieves every snooze that lasts past now, by node name
+func (db *DB) GetActiveNotificationSnoozes(ctx context.Context, now time.Time) ([]NotificationSnooze, error) {
+	query := `SELECT id, node_name, snoozed_until, actor, created_at
+	          FROM notification_snoozes
+	          WHERE snoozed_until > $1
+	          ORDER BY node_name, snoozed_until DESC`
+
+	var snoozes []NotificationSnooze
+	if err := db.queryWithRetry(ctx, &snoozes, query, now.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get notification snoozes: %w", err)
+	}
+
+	return snoozes, nil
+}
+
+// SaveRegisteredNode registers a node, or replaces the configuration and host of a
+// registered node while keeping its original registration time
+func (db *DB) SaveRegisteredNode(ctx context.Context, node RegisteredNode) error {
+	query := `INSERT INTO registered_nodes (node_name, host, config, registered_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (node_name) DO UPDATE SET
+	              host = EXCLUDED.host,
+	              config = EXCLUDED.config,
+	              updated_at = EXCLUDED.updated_at`
+
+	if err := db.execWithRetry(ctx, query, node.NodeName, node.Host, node.Config, node.RegisteredAt.UTC(), node.UpdatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save registered node: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRegisteredNode removes a registered node. Removing a node that is not registered
+// is not an error.
+func (db *DB) DeleteRegisteredNode(ctx context.Context, nodeName string) error {
+	if err := db.execWithRetry(ctx, `DELETE FROM registered_nodes WHERE node_name = $1`, nodeName); err != nil {
+		return fmt.Errorf("failed to delete registered node: %w", err)
+	}
+
+	return nil
+}
+
+// GetRegisteredNode retrieves a registered node, or nil if the node is not registered
+func (db *DB) GetRegisteredNode(ctx context.Context, nodeName string) (*RegisteredNode, error) {
+	query := `SELECT node_name, host, config, registered_at, updated_at
+	          FROM registered_nodes
+	          WHERE node_name = $1`
+
+	var node RegisteredNode
+	err := db.getWithRetry(ctx, &node, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registered node: %w", err)
+	}
+
+	return &node, nil
+}
+
+// ListRegisteredNodes retrieves every registered node by name
+func (db *DB) ListRegisteredNodes(ctx context.Context) ([]RegisteredNode, error) {
+	query := `SELECT node_name, host, config, registered_at, updated_at
+	          FROM registered_nodes
+	          ORDER BY node_name`
+
+	var nodes []RegisteredNode
+	if err := db.queryWithRetry(ctx, &nodes, query); err != nil {
+		return nil, fmt.Errorf("failed to list registered nodes: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// ListAssignedNodes retrieves the registered nodes the daemon on host runs, those assigned
+// to the host and those assigned to no host, by name
+func (db *DB) ListAssignedNodes(ctx context.Context, host string) ([]RegisteredNode, error) {
+	query := `SELECT node_name, host, config, registered_at, updated_at
+	          FROM registered_nodes
+	          WHERE host = '' OR host = $1
+	          ORDER BY node_name`
+
+	var nodes []RegisteredNode
+	if err := db.queryWithRetry(ctx, &nodes, query, host); err != nil {
+		return nil, fmt.Errorf("failed to list assigned nodes: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// CreateThrottleEvent records a guardrail action taken on an upload
+func (db *DB) CreateThrottleEvent(ctx context.Context, event ThrottleEvent) (int64, error) {
+	query := `INSERT INTO upload_throttle_events (upload_id, node_name, action, reason, cpu_percent, memory_percent, disk_io_percent, started_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	          RETURNING id`
+
+	var id int64
+	if err := db.queryRowWithRetry(ctx, query, &id, event.UploadID, event.NodeName, event.Action, event.Reason,
+		event.CPUPercent, event.MemoryPercent, event.DiskIOPercent, event.StartedAt.UTC()); err != nil {
+		return 0, fmt.Errorf("failed to create throttle event: %w", err)
+	}
+
+	return id, nil
+}
+
+// EndThrottleEvent records when a guardrail action was undone
+func (db *DB) EndThrottleEvent(ctx context.Context, id int64, endedAt time.Time) error {
+	query := `UPDATE upload_throttle_events SET ended_at = $1 WHERE id = $2`
+
+	if err := db.execWithRetry(ctx, query, endedAt.UTC(), id); err != nil {
+		return fmt.Errorf("failed to end throttle event: %w", err)
+	}
+
+	return nil
+}
+
+// GetOpenThrottleEvents retrieves the guardrail actions still in effect, oldest first
+func (db *DB) GetOpenThrottleEvents(ctx context.Context) ([]ThrottleEvent, error) {
+	query := `SELECT id, upload_id, node_name, action, reason, cpu_percent, memory_percent, disk_io_percent, started_at, ended_at
+	          FROM upload_throttle_events
+	          WHERE ended_at IS NULL
+	          ORDER BY started_at, id`
+
+	var events []ThrottleEvent
+	if err := db.queryWithRetry(ctx, &events, query); err != nil {
+		return nil, fmt.Errorf("failed to get open throttle events: %w", err)
+	}
+
+	return events, nil
+}
+
+// GetThrottleEvents retrieves the guardrail actions taken on an upload, oldest first
+func (db *DB) GetThrottleEvents(ctx context.Context, uploadID int64) ([]ThrottleEvent, error) {
+	query := `SELECT id, upload_id, node_name, action, reason, cpu_percent, memory_percent, disk_io_percent, started_at, ended_at
+	          FROM upload_throttle_events
+	          WHERE upload_id = $1
+	          ORDER BY started_at, id`
+
+	var events []ThrottleEvent
+	if err := db.queryWithRetry(ctx, &events, query, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to get throttle events: %w", err)
+	}
+
+	return events, nil
+}
+
+// RecordStatusOutput stores an upload's raw engine output when its state differs from
+// the last one recorded, reporting whether it was stored
+func (db *DB) RecordStatusOutput(ctx context.Context, uploadID int64, state, rawOutput string, recordedAt time.Time) (bool, error) {
+	var last []string
+	if err := db.queryWithRetry(ctx, &last, `SELECT state FROM upload_status_outputs
+	          WHERE upload_id = $1
+	          ORDER BY recorded_at DESC, id DESC
+	          LIMIT 1`, uploadID); err != nil {
+		return false, fmt.Errorf("failed to get last status output: %w", err)
+	}
+	if len(last) > 0 && last[0] == state {
+		return false, nil
+	}
+
+	query := `INSERT INTO upload_status_outputs (upload_id, recorded_at, state, raw_output)
+	          VALUES ($1, $2, $3, $4)`
+
+	if err := db.execWithRetry(ctx, query, uploadID, recordedAt, state, rawOutput); err != nil {
+		return false, fmt.Errorf("failed to record status output: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetStatusOutputs retrieves the raw engine output recorded at each of an upload's status
+// changes, oldest first
+func (db *DB) GetStatusOutputs(ctx context.Context, uploadID int64) ([]StatusOutput, error) {
+	query := `SELECT id, upload_id, recorded_at, state, raw_output
+	          FROM upload_status_outputs
+	          WHERE upload_id = $1
+	          ORDER BY recorded_at, id`
+
+	var outputs []StatusOutput
+	if err := db.queryWithRetry(ctx, &outputs, query, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to get status outputs: %w", err)
+	}
+
+	return outputs, nil
+}
+
+// RecordUploadNotification records a pending notification about an upload, reporting
+// false when the upload already has a notification with the same key
+func (db *DB) RecordUploadNotification(ctx context.Context, n UploadNotification) (bool, error) {
+	query := `INSERT INTO upload_notifications (upload_id, notification_key, node_name, event, message, details, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (upload_id, notification_key) DO NOTHING
+	          RETURNING upload_id`
+
+	var inserted []int64
+	if err := db.queryWithRetry(ctx, &inserted, query, n.UploadID, n.Key, n.NodeName, n.Event, n.Message, n.Details, n.CreatedAt.UTC()); err != nil {
+		return false, fmt.Errorf("failed to record upload notification: %w", err)
+	}
+
+	return len(inserted) > 0, nil
+}
+
+// MarkUploadNotificationSent records that a pending notification was sent
+func (db *DB) MarkUploadNotificationSent(ctx context.Context, uploadID int64, key string, sentAt time.Time) error {
+	query := `UPDATE upload_notifications SET sent_at = $1
+	          WHERE upload_id = $2 AND notification_key = $3`
+
+	if err := db.execWithRetry(ctx, query, sentAt.UTC(), uploadID, key); err != nil {
+		return fmt.Errorf("failed to mark upload notification sent: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingUploadNotifications retrieves the notifications recorded before the given
+// time and never marked sent, oldest first
+func (db *DB) GetPendingUploadNotifications(ctx context.Context, before time.Time) ([]UploadNotification, error) {
+	query := `SELECT upload_id, notification_key, node_name, event, message, details, created_at, sent_at
+	          FROM upload_notifications
+	          WHERE sent_at IS NULL AND created_at < $1
+	          ORDER BY created_at, upload_id, notification_key`
+
+	var notifications []UploadNotification
+	if err := db.queryWithRetry(ctx, &notifications, query, before.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get pending upload notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// SaveUploadCheckpoint replaces a node's upload checkpoint and its chunks in a single
+// transaction
+func (db *DB) SaveUploadCheckpoint(ctx context.Context, checkpoint UploadCheckpoint, chunks []CheckpointChunk) error {
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save upload checkpoint: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, db.driver.Rebind(`DELETE FROM upload_checkpoint_chunks WHERE node_name = $1`), checkpoint.NodeName); err != nil {
+		return fmt.Errorf("failed to save upload checkpoint: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, db.driver.Rebind(`INSERT INTO upload_checkpoints (node_name, engine, session, created_at)
+	          VALUES ($1, $2, $3, $4)
+	          ON CONFLICT (node_name) DO UPDATE SET
+	              engine = EXCLUDED.engine,
+	              session = EXCLUDED.session,
+	              created_at = EXCLUDED.created_at`),
+		checkpoint.NodeName, checkpoint.Engine, checkpoint.Session, checkpoint.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save upload checkpoint: %w", err)
+	}
+
+	insert, err := tx.PreparexContext(ctx, db.driver.Rebind(`INSERT INTO upload_checkpoint_chunks (node_name, chunk_number, etag, size_bytes, checksum, uploaded_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`))
+	if err != nil {
+		return fmt.Errorf("failed to save upload checkpoint: %w", err)
+	}
+	defer insert.Close()
+
+	for _, chunk := range chunks {
+		if _, err := insert.ExecContext(ctx, checkpoint.NodeName, chunk.ChunkNumber, chunk.ETag, chunk.SizeBytes, chunk.Checksum, chunk.UploadedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to save checkpoint chunk %d: %w", chunk.ChunkNumber, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save upload checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// AddCheckpointChunk records a chunk uploaded under a node's checkpoint, replacing a
+// chunk with the same number
+func (db *DB) AddCheckpointChunk(ctx context.Context, chunk CheckpointChunk) error {
+	query := `INSERT INTO upload_checkpoint_chunks (node_name, chunk_number, etag, size_bytes, checksum, uploaded_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (node_name, chunk_number) DO UPDATE SET
+	              etag = EXCLUDED.etag,
+	              size_bytes = EXCLUDED.size_bytes,
+	              checksum = EXCLUDED.checksum,
+	              uploaded_at = EXCLUDED.uploaded_at`
+
+	if err := db.execWithRetry(ctx, query, chunk.NodeName, chunk.ChunkNumber, chunk.ETag, chunk.SizeBytes, chunk.Checksum, chunk.UploadedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to add checkpoint chunk: %w", err)
+	}
+
+	return nil
+}
+
+// GetUploadCheckpoint retrieves a node's upload checkpoint, or nil if it has none
+func (db *DB) GetUploadCheckpoint(ctx context.Context, nodeName string) (*UploadCheckpoint, error) {
+	query := `SELECT node_name, engine, session, created_at
+	          FROM upload_checkpoints
+	          WHERE node_name = $1`
+
+	var checkpoint UploadCheckpoint
+	err := db.getWithRetry(ctx, &checkpoint, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload checkpoint: %w", err)
+	}
+
+	return &checkpoint, nil
+}
+
+// GetCheckpointChunks retrieves the chunks of a node's upload checkpoint, by number
+func (db *DB) GetCheckpointChunks(ctx context.Context, nodeName string) ([]CheckpointChunk, error) {
+	query := `SELECT node_name, chunk_number, etag, size_bytes, checksum, uploaded_at
+	          FROM upload_checkpoint_chunks
+	          WHERE node_name = $1
+	          ORDER BY chunk_number`
+
+	var chunks []CheckpointChunk
+	if err := db.queryWithRetry(ctx, &chunks, query, nodeName); err != nil {
+		return nil, fmt.Errorf("failed to get checkpoint chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// DeleteUploadCheckpoint removes a node's upload checkpoint and its chunks. Removing a
+// checkpoint that does not exist is not an error.
+func (db *DB) DeleteUploadCheckpoint(ctx context.Context, nodeName string) error {
+	if err := db.execWithRetry(ctx, `DELETE FROM upload_checkpoint_chunks WHERE node_name = $1`, nodeName); err != nil {
+		return fmt.Errorf("failed to delete upload checkpoint: %w", err)
+	}
+	if err := db.execWithRetry(ctx, `DELETE FROM upload_checkpoints WHERE node_name = $1`, nodeName); err != nil {
+		return fmt.Errorf("failed to delete upload checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// startQuerySpan starts the span of a query made by a traced operation. Only the statement
+// is recorded, not its arguments.
+func (db *DB) startQuerySpan(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	statement := strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+	return tracing.StartClient(ctx, "db "+strings.ToUpper(operation),
+		tracing.String("db.system", db.driver.Name()),
+		tracing.String("db.statement", statement),
+	)
+}
+
+// endQuerySpan ends a query's span, failed unless the query succeeded or found no rows
+func endQuerySpan(span *tracing.Span, err error) {
+	if err != sql.ErrNoRows {
+		span.RecordError(err)
+	}
+	span.End()
 }
 
 // execWithRetry executes a query with exponential backoff retry logic
-func (db *DB) execWithRetry(ctx context.Context, query string, args ...interface{}) error {
+func (db *DB) execWithRetry(ctx context.Context, query string, args ...interface{}) (err error) {
+	query = db.driver.Rebind(query)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer func() { endQuerySpan(span, err) }()
+
 	var lastErr error
 	delay := db.retryBaseDelay
 
@@ -259,7 +1915,7 @@ func (db *DB) execWithRetry(ctx context.Context, query string, args ...interface
 			}
 		}
 
-		_, err := db.conn.ExecContext(ctx, query, args...)
+		_, err = db.conn.ExecContext(ctx, query, args...)
 		if err == nil {
 			return nil
 		}
@@ -271,7 +1927,11 @@ func (db *DB) execWithRetry(ctx context.Context, query string, args ...interface
 }
 
 // queryRowWithRetry executes a query that returns a single row with retry logic
-func (db *DB) queryRowWithRetry(ctx context.Context, query string, dest interface{}, args ...interface{}) error {
+func (db *DB) queryRowWithRetry(ctx context.Context, query string, dest interface{}, args ...interface{}) (err error) {
+	query = db.driver.Rebind(query)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer func() { endQuerySpan(span, err) }()
+
 	var lastErr error
 	delay := db.retryBaseDelay
 
@@ -285,7 +1945,7 @@ func (db *DB) queryRowWithRetry(ctx context.Context, query string, dest interfac
 			}
 		}
 
-		err := db.conn.QueryRowContext(ctx, query, args...).Scan(dest)
+		err = db.conn.QueryRowContext(ctx, query, args...).Scan(dest)
 		if err == nil {
 			return nil
 		}
@@ -297,7 +1957,11 @@ func (db *DB) queryRowWithRetry(ctx context.Context, query string, dest interfac
 }
 
 // queryWithRetry executes a query that returns multiple rows with retry logic
-func (db *DB) queryWithRetry(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+func (db *DB) queryWithRetry(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	query = db.driver.Rebind(query)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer func() { endQuerySpan(span, err) }()
+
 	var lastErr error
 	delay := db.retryBaseDelay
 
@@ -311,7 +1975,7 @@ func (db *DB) queryWithRetry(ctx context.Context, dest interface{}, query string
 			}
 		}
 
-		err := db.conn.SelectContext(ctx, dest, query, args...)
+		err = db.conn.SelectContext(ctx, dest, query, args...)
 		if err == nil {
 			return nil
 		}
@@ -323,7 +1987,11 @@ func (db *DB) queryWithRetry(ctx context.Context, dest interface{}, query string
 }
 
 // getWithRetry executes a query that returns a single struct with retry logic
-func (db *DB) getWithRetry(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+func (db *DB) getWithRetry(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	query = db.driver.Rebind(query)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer func() { endQuerySpan(span, err) }()
+
 	var lastErr error
 	delay := db.retryBaseDelay
 
@@ -337,7 +2005,7 @@ func (db *DB) getWithRetry(ctx context.Context, dest interface{}, query string,
 			}
 		}
 
-		err := db.conn.GetContext(ctx, dest, query, args...)
+		err = db.conn.GetContext(ctx, dest, query, args...)
 		if err == nil {
 			return nil
 		}
@@ -62,6 +62,42 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+// TestPostgresConnString verifies TLS parameters are passed to lib/pq and values are quoted
+func TestPostgresConnString(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{
+			name: "plain",
+			cfg:  Config{Host: "localhost", Port: 5432, User: "snapd", Password: "pass", Database: "snapd", SSLMode: "disable"},
+			want: "host=localhost port=5432 user=snapd password=pass dbname=snapd sslmode=disable",
+		},
+		{
+			name: "mutual TLS",
+			cfg: Config{
+				Host: "db.example.com", Port: 5432, User: "snapd", Database: "snapd", SSLMode: "verify-full",
+				SSLRootCert: "/etc/snapperd/tls/ca.crt", SSLCert: "/etc/snapperd/tls/client.crt", SSLKey: "/etc/snapperd/tls/client key.pem",
+			},
+			want: "host=db.example.com port=5432 user=snapd password='' dbname=snapd sslmode=verify-full sslrootcert=/etc/snapperd/tls/ca.crt sslcert=/etc/snapperd/tls/client.crt sslkey='/etc/snapperd/tls/client key.pem'",
+		},
+		{
+			name: "quoted password and schema",
+			cfg:  Config{Host: "localhost", Port: 5432, User: "snapd", Password: `it's a \ secret`, Database: "snapd", SSLMode: "require", Schema: "test_1"},
+			want: `host=localhost port=5432 user=snapd password='it\'s a \\ secret' dbname=snapd sslmode=require search_path=test_1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postgresConnString(tt.cfg); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 // TestJSONBType verifies JSONB marshaling and unmarshaling
 func TestJSONBType(t *testing.T) {
 	original := JSONB{
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// Supported database driver names
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Driver abstracts the backend-specific parts of the database layer
+type Driver interface {
+	// Name returns the driver identifier used in configuration (e.g., "postgres", "sqlite")
+	Name() string
+
+	// Open establishes a connection pool for the given configuration
+	Open(ctx context.Context, cfg Config) (*sqlx.DB, error)
+
+	// Migrations returns the backend's versioned migrations, ordered by version
+	Migrations() ([]Migration, error)
+
+	// LockMigrations serializes migrations: it blocks until no other transaction, in this
+	// or another process, is migrating the database and holds the lock until tx ends
+	LockMigrations(ctx context.Context, tx *sqlx.Tx) error
+
+	// Rebind rewrites a query written with PostgreSQL-style $N placeholders for this backend
+	Rebind(query string) string
+
+	// LockNode serializes transactions on a node: it blocks until no other transaction,
+	// in this or another process, holds the node's lock and holds it until tx ends
+	LockNode(ctx context.Context, tx *sqlx.Tx, nodeName string) error
+
+	// ProtocolDataNumber returns the SQL expression for a numeric protocol_data key, and
+	// the condition that the key holds a number. Queries use both exactly as written so
+	// the expression indexes on protocol_data apply.
+	ProtocolDataNumber(key string) (value string, isNumber string)
+
+	// SecondsBetween returns the SQL expression for the seconds from one timestamp column
+	// to another
+	SecondsBetween(from, to string) string
+}
+
+// migrationPreparer is implemented by drivers that cannot express every migration
+// statement idempotently in SQL. PrepareMigration returns the statement to execute, or
+// false when it has already been applied and should be skipped.
+type migrationPreparer interface {
+	PrepareMigration(ctx context.Context, conn sqlx.QueryerContext, statement string) (string, bool, error)
+}
+
+// drivers holds all supported database drivers keyed by name
+var drivers = map[string]Driver{
+	DriverPostgres: &postgresDriver{},
+	DriverSQLite:   &sqliteDriver{},
+}
+
+// getDriver returns the driver for the given name, defaulting to PostgreSQL
+func getDriver(name string) (Driver, error) {
+	if name == "" {
+		name = DriverPostgres
+	}
+
+	driver, exists := drivers[name]
+	if !exists {
+		return nil, fmt.Errorf("unsupported database driver %s", name)
+	}
+
+	return driver, nil
+}
+
+// postgresDriver implements Driver for PostgreSQL
+type postgresDriver struct{}
+
+// Name returns the driver identifier
+func (d *postgresDriver) Name() string {
+	return DriverPostgres
+}
+
+// Open connects to PostgreSQL and configures the connection pool
+func (d *postgresDriver) Open(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	// lib/pq skips a client certificate that does not exist, leaving the server to
+	// reject the connection without saying why
+	for _, path := range []string{cfg.SSLRootCert, cfg.SSLCert, cfg.SSLKey} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+	}
+
+	conn, err := sqlx.ConnectContext(ctx, "postgres", postgresConnString(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pool
+	conn.SetMaxOpenConns(25)
+	conn.SetMaxIdleConns(5)
+	conn.SetConnMaxLifetime(5 * time.Minute)
+
+	return conn, nil
+}
+
+// postgresConnString returns the lib/pq connection string of cfg
+func postgresConnString(cfg Config) string {
+	connStr := fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		connValue(cfg.Host), cfg.Port, connValue(cfg.User), connValue(cfg.Password), connValue(cfg.Database), connValue(cfg.SSLMode),
+	)
+	for _, param := range []struct{ key, value string }{
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+		{"search_path", cfg.Schema},
+	} {
+		if param.value != "" {
+			connStr += " " + param.key + "=" + connValue(param.value)
+		}
+	}
+	return connStr
+}
+
+// connValue quotes a connection string value that is empty or holds spaces, quotes or
+// backslashes, such as a password or a file path
+func connValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n'\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Rebind returns the query unchanged since it is already in PostgreSQL form
+func (d *postgresDriver) Rebind(query string) string {
+	return query
+}
+
+// ProtocolDataNumber casts the key's text to numeric, which accepts any JSON number. The
+// cast fails on other values, so it is only evaluated where the key holds a number.
+func (d *postgresDriver) ProtocolDataNumber(key string) (string, string) {
+	return fmt.Sprintf("((protocol_data->>'%s')::numeric)", key),
+		fmt.Sprintf("jsonb_typeof(protocol_data->'%s') = 'number'", key)
+}
+
+// SecondsBetween extracts the epoch seconds of the interval between the timestamps
+func (d *postgresDriver) SecondsBetween(from, to string) string {
+	return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", to, from)
+}
+
+// LockNode takes a transaction-level advisory lock keyed by the node name
+func (d *postgresDriver) LockNode(ctx context.Context, tx *sqlx.Tx, nodeName string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, advisoryLockKey("node", nodeName))
+	return err
+}
+
+// LockMigrations takes a transaction-level advisory lock, so daemons starting together
+// do not apply the same migration twice
+func (d *postgresDriver) LockMigrations(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, advisoryLockKey("migrations", "schema"))
+	return err
+}
+
+// advisoryLockKey maps a lock name within a scope to the 64-bit key of a PostgreSQL
+// advisory lock
+func advisoryLockKey(scope, name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("snapperd-" + scope + ":" + name))
+	return int64(h.Sum64())
+}
+
+// Migrations returns the PostgreSQL migrations, from migrations/postgres
+func (d *postgresDriver) Migrations() ([]Migration, error) {
+	return loadMigrations(DriverPostgres)
+}
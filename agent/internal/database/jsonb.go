@@ -6,7 +6,7 @@ import (
 	"errors"
 )
 
-// JSONB is a custom type for handling PostgreSQL JSONB columns
+// JSONB is a custom type for handling JSONB columns (TEXT on SQLite)
 type JSONB map[string]interface{}
 
 // Value implements the driver.Valuer interface for JSONB
@@ -14,7 +14,12 @@ func (j JSONB) Value() (driver.Value, error) {
 	if j == nil {
 		return nil, nil
 	}
-	return json.Marshal(j)
+	// Bind as text so SQLite stores readable JSON usable by its json functions
+	data, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
 }
 
 // Scan implements the sql.Scanner interface for JSONB
@@ -24,9 +29,15 @@ func (j *JSONB) Scan(value interface{}) error {
 		return nil
 	}
 
-	bytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("failed to scan JSONB: value is not []byte")
+	// PostgreSQL returns JSONB as []byte; SQLite stores it as TEXT
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("failed to scan JSONB: value is not []byte or string")
 	}
 
 	result := make(map[string]interface{})
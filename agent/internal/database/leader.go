@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// ErrLeaderLockUnsupported is returned when the active driver has no advisory locks
+var ErrLeaderLockUnsupported = errors.New("leader election requires the postgres driver")
+
+// LeaderLock is a PostgreSQL session-level advisory lock held on a dedicated connection.
+// The lock lives as long as that session: if the holder crashes or loses its database
+// connection, PostgreSQL releases the lock and another instance can take it.
+type LeaderLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAcquireLeaderLock tries to take the named leader lock without waiting. It returns
+// nil when another session holds the lock.
+func (db *DB) TryAcquireLeaderLock(ctx context.Context, name string) (*LeaderLock, error) {
+	if db.driver.Name() != DriverPostgres {
+		return nil, ErrLeaderLockUnsupported
+	}
+
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open leader lock connection: %w", err)
+	}
+
+	key := advisoryLockKey("leader", name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		discardConn(conn)
+		return nil, fmt.Errorf("failed to acquire leader lock: %w", err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+
+	return &LeaderLock{conn: conn, key: key}, nil
+}
+
+// Check verifies the session holding the lock is still alive. An error means the lock
+// may have been released and leadership must be given up.
+func (l *LeaderLock) Check(ctx context.Context) error {
+	var one int
+	if err := l.conn.QueryRowContext(ctx, `SELECT 1`).Scan(&one); err != nil {
+		return fmt.Errorf("leader lock connection lost: %w", err)
+	}
+	return nil
+}
+
+// Release gives up the lock by closing its session, so a standby can take over at once
+func (l *LeaderLock) Release() error {
+	discardConn(l.conn)
+	return nil
+}
+
+// discardConn closes the connection's session instead of returning it to the pool,
+// releasing any session-level locks it holds
+func discardConn(conn *sql.Conn) {
+	_ = conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds each driver's migrations, in migrations/<driver>
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFilePattern matches migration file names: NNNN_name.up.sql applies a
+// migration and the optional NNNN_name.down.sql reverts it
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// createMigrationTable records the migrations applied to the database
+const createMigrationTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string // Statements applying the migration, in order
+	Down    []string // Statements reverting it; empty when it cannot be rolled back
+}
+
+// AppliedMigration is a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// loadMigrations reads a driver's embedded migrations, ordered by version. Versions must
+// run from 1 without gaps and every migration needs an up file.
+func loadMigrations(driverName string) ([]Migration, error) {
+	dir := path.Join("migrations", driverName)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s migrations: %w", driverName, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files named %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = splitStatements(string(data))
+		} else {
+			m.Down = splitStatements(string(data))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("%s migrations skip from version %d to %d", driverName, i, m.Version)
+		}
+		if len(m.Up) == 0 {
+			return nil, fmt.Errorf("migration %d (%s) has no up statements", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// splitStatements splits a migration file into statements, each ending with ';' at the
+// end of a line. Lines starting with -- are comments and are dropped.
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(strings.TrimRight(line, " \t"))
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Migrations returns the driver's migrations, ordered by version
+func (db *DB) Migrations() ([]Migration, error) {
+	return db.driver.Migrations()
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations, ordered by version
+func (db *DB) AppliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	if err := db.execWithRetry(ctx, createMigrationTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []AppliedMigration
+	if err := db.queryWithRetry(ctx, &applied, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// Migrate applies every migration not yet applied, in order. Databases created before
+// migrations were versioned adopt the baseline migration, whose statements are idempotent.
+// Migrations recorded by a newer snapperd are left alone.
+func (db *DB) Migrate(ctx context.Context) error {
+	migrations, err := db.driver.Migrations()
+	if err != nil {
+		return err
+	}
+	if err := db.execWithRetry(ctx, createMigrationTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err := db.runMigration(ctx, m, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateTo applies or reverts migrations until version is the latest applied one. Reverting
+// runs the down statements of each later migration, newest first; version 0 reverts all.
+func (db *DB) MigrateTo(ctx context.Context, version int) error {
+	migrations, err := db.driver.Migrations()
+	if err != nil {
+		return err
+	}
+	if version < 0 || version > len(migrations) {
+		return fmt.Errorf("unknown migration version %d (latest is %d)", version, len(migrations))
+	}
+
+	applied, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, a := range applied {
+		if a.Version > len(migrations) {
+			return fmt.Errorf("database has migration %d (%s), which is newer than this snapperd's latest (%d)", a.Version, a.Name, len(migrations))
+		}
+	}
+
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		if err := db.runMigration(ctx, m, true); err != nil {
+			return err
+		}
+	}
+	for i := len(migrations) - 1; i >= 0 && migrations[i].Version > version; i-- {
+		if err := db.runMigration(ctx, migrations[i], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMigration applies a migration (up) or reverts it in one transaction that also records
+// it in schema_migrations. It does nothing when the migration is already in that state,
+// which it checks holding the migration lock, so concurrent processes run it once.
+func (db *DB) runMigration(ctx context.Context, m Migration, up bool) error {
+	action := "apply"
+	if !up {
+		action = "revert"
+	}
+
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to %s migration %d (%s): %w", action, m.Version, m.Name, err)
+	}
+	defer tx.Rollback()
+
+	if err := db.driver.LockMigrations(ctx, tx); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	var count int
+	if err := tx.GetContext(ctx, &count, db.driver.Rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = $1`), m.Version); err != nil {
+		return fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if (count > 0) == up {
+		return nil
+	}
+
+	statements := m.Up
+	if !up {
+		if len(m.Down) == 0 {
+			return fmt.Errorf("migration %d (%s) cannot be rolled back", m.Version, m.Name)
+		}
+		statements = m.Down
+	}
+
+	preparer, needsPrepare := db.driver.(migrationPreparer)
+	for _, statement := range statements {
+		if needsPrepare {
+			prepared, apply, err := preparer.PrepareMigration(ctx, tx, statement)
+			if err != nil {
+				return fmt.Errorf("failed to %s migration %d (%s): %w", action, m.Version, m.Name, err)
+			}
+			if !apply {
+				continue
+			}
+			statement = prepared
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to %s migration %d (%s): %w", action, m.Version, m.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, db.driver.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`), m.Version, m.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, db.driver.Rebind(`DELETE FROM schema_migrations WHERE version = $1`), m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d (%s): %w", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to %s migration %d (%s): %w", action, m.Version, m.Name, err)
+	}
+	return nil
+}
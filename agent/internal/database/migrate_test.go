@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	postgres, err := loadMigrations(DriverPostgres)
+	if err != nil {
+		t.Fatalf("failed to load postgres migrations: %v", err)
+	}
+	sqlite, err := loadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("failed to load sqlite migrations: %v", err)
+	}
+
+	// Both backends have the same schema, so they have the same migrations
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("expected as many postgres as sqlite migrations, got %d and %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != i+1 || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d: postgres %d_%s, sqlite %d_%s", i+1, postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+		if (len(postgres[i].Down) == 0) != (len(sqlite[i].Down) == 0) {
+			t.Errorf("migration %d (%s) can be rolled back on only one backend", postgres[i].Version, postgres[i].Name)
+		}
+	}
+
+	if postgres[0].Name != "baseline" || len(postgres[0].Down) != 0 {
+		t.Errorf("expected a baseline migration without a down migration first, got %s", postgres[0].Name)
+	}
+	for _, statement := range postgres[0].Up {
+		if strings.HasPrefix(statement, "--") || strings.HasSuffix(statement, ";") {
+			t.Errorf("expected statements without comments or terminators, got %q", statement)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- Comment; with a semicolon
+CREATE TABLE t (
+    id INTEGER PRIMARY KEY
+);
+
+-- Another comment
+ALTER TABLE t ADD COLUMN IF NOT EXISTS name TEXT;
+UPDATE t SET name = 'a' WHERE id = 1
+`
+	want := []string{
+		"CREATE TABLE t (\n    id INTEGER PRIMARY KEY\n)",
+		"ALTER TABLE t ADD COLUMN IF NOT EXISTS name TEXT",
+		"UPDATE t SET name = 'a' WHERE id = 1",
+	}
+	if got := splitStatements(sql); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestSQLiteMigrateTo(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+	appliedVersions := func() []int {
+		t.Helper()
+		applied, err := db.AppliedMigrations(ctx)
+		if err != nil {
+			t.Fatalf("AppliedMigrations failed: %v", err)
+		}
+		versions := []int{}
+		for _, a := range applied {
+			versions = append(versions, a.Version)
+		}
+		return versions
+	}
+	hasAgentColumn := func() bool {
+		t.Helper()
+		var count int
+		if err := db.conn.GetContext(ctx, &count, `SELECT COUNT(*) FROM pragma_table_info('uploads') WHERE name = 'agent'`); err != nil {
+			t.Fatalf("failed to inspect uploads: %v", err)
+		}
+		return count > 0
+	}
+
+	if versions := appliedVersions(); len(versions) != len(migrations) {
+		t.Fatalf("expected every migration applied, got %v", versions)
+	}
+
+	// Roll back the upload agent column
+	if err := db.MigrateTo(ctx, 1); err != nil {
+		t.Fatalf("MigrateTo(1) failed: %v", err)
+	}
+	if versions := appliedVersions(); !reflect.DeepEqual(versions, []int{1}) {
+		t.Errorf("expected only the baseline applied, got %v", versions)
+	}
+	if hasAgentColumn() {
+		t.Error("expected the agent column to be dropped")
+	}
+
+	if err := db.MigrateTo(ctx, 0); err == nil || !strings.Contains(err.Error(), "migration 1 (baseline) cannot be rolled back") {
+		t.Errorf("expected the baseline to refuse a rollback, got %v", err)
+	}
+	if err := db.MigrateTo(ctx, len(migrations)+1); err == nil || !strings.Contains(err.Error(), "unknown migration version") {
+		t.Errorf("expected an unknown version error, got %v", err)
+	}
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if versions := appliedVersions(); len(versions) != len(migrations) {
+		t.Errorf("expected every migration applied again, got %v", versions)
+	}
+	if !hasAgentColumn() {
+		t.Error("expected the agent column to be added back")
+	}
+
+	// A migration recorded by a newer snapperd stops MigrateTo but not Migrate
+	if _, err := db.conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?1, 'future', CURRENT_TIMESTAMP)`, len(migrations)+1); err != nil {
+		t.Fatalf("failed to record a future migration: %v", err)
+	}
+	if err := db.MigrateTo(ctx, 1); err == nil || !strings.Contains(err.Error(), "newer than this snapperd") {
+		t.Errorf("expected MigrateTo to refuse a newer database, got %v", err)
+	}
+	if err := db.Migrate(ctx); err != nil {
+		t.Errorf("Migrate failed on a newer database: %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+-- Host of the daemon or CLI that recorded each upload
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS agent VARCHAR(255);
@@ -0,0 +1,2 @@
+-- W3C traceparent of the span that recorded each upload, so monitor checks join its trace
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS trace_parent VARCHAR(55);
@@ -0,0 +1,9 @@
+-- The tail of each finished upload's job log, so failed jobs can be debugged without the node
+CREATE TABLE IF NOT EXISTS upload_logs (
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    line_no INTEGER NOT NULL,
+    stream VARCHAR(10) NOT NULL,
+    line TEXT NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (upload_id, line_no)
+);
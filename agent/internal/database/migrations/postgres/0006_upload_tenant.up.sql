@@ -0,0 +1,4 @@
+-- Tenant whose node recorded each upload, empty for nodes of no tenant
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS tenant VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_uploads_tenant_started
+    ON uploads (tenant, started_at DESC);
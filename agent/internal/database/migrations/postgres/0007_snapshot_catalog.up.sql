@@ -0,0 +1,17 @@
+-- The freshest completed snapshot of each protocol, network and node type, served by
+-- 'snapperd catalog' and /api/v1/catalog
+CREATE TABLE IF NOT EXISTS snapshots (
+    protocol VARCHAR(50) NOT NULL,
+    network VARCHAR(255) NOT NULL DEFAULT '',
+    node_type VARCHAR(50) NOT NULL DEFAULT '',
+    node_name VARCHAR(255) NOT NULL,
+    tenant VARCHAR(255) NOT NULL DEFAULT '',
+    upload_id BIGINT NOT NULL,
+    latest_block BIGINT,
+    latest_slot BIGINT,
+    size_bytes BIGINT,
+    location TEXT,
+    completed_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (protocol, network, node_type)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE upload_requests DROP COLUMN IF EXISTS not_before;
+ALTER TABLE uploads DROP COLUMN IF EXISTS retry_of;
+ALTER TABLE uploads DROP COLUMN IF EXISTS attempt;
@@ -0,0 +1 @@
+ALTER TABLE node_pauses DROP COLUMN IF EXISTS circuit_failures;
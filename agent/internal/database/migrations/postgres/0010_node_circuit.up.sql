@@ -0,0 +1,3 @@
+-- A pause opened by a node's circuit breaker records the consecutive failed uploads that
+-- opened it; operator pauses have none
+ALTER TABLE node_pauses ADD COLUMN IF NOT EXISTS circuit_failures INTEGER;
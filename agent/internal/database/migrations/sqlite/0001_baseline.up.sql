@@ -0,0 +1,282 @@
+-- Schema created by the unversioned migrations that preceded schema_migrations. Every
+-- statement is idempotent, so databases they created adopt this as version 1. It has
+-- no down migration: rolling it back would drop every table.
+
+CREATE TABLE IF NOT EXISTS uploads (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_name VARCHAR(255) NOT NULL,
+    protocol VARCHAR(50) NOT NULL,
+    node_type VARCHAR(50),
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    status VARCHAR(50) NOT NULL,
+    trigger_type VARCHAR(20) NOT NULL,
+    error_message TEXT,
+    protocol_data TEXT NOT NULL,
+    progress_percent DECIMAL(5,2),
+    chunks_completed INTEGER,
+    chunks_total INTEGER,
+    last_progress_check TIMESTAMP,
+    completion_message TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_uploads_node_status
+    ON uploads (node_name, status);
+CREATE INDEX IF NOT EXISTS idx_uploads_started
+    ON uploads (started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_uploads_completed
+    ON uploads (node_name, completed_at DESC) WHERE completed_at IS NOT NULL;
+
+-- Add stalled progress tracking
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS stalled_since TIMESTAMP;
+
+-- Persist scheduling history across restarts
+CREATE TABLE IF NOT EXISTS schedule_state (
+    node_name VARCHAR(255) PRIMARY KEY,
+    last_run_at TIMESTAMP,
+    next_run_at TIMESTAMP,
+    last_result VARCHAR(20),
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Track progress history for throughput and completion estimates
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS chunks_per_minute DOUBLE PRECISION;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS estimated_completion TIMESTAMP;
+CREATE TABLE IF NOT EXISTS upload_progress_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    recorded_at TIMESTAMP NOT NULL,
+    chunks_completed INTEGER NOT NULL,
+    chunks_total INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_upload_progress_samples_upload
+    ON upload_progress_samples (upload_id, recorded_at);
+
+-- Fixed trigger taxonomy with who/why metadata; legacy trigger names are folded in
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS trigger_metadata TEXT;
+UPDATE uploads SET trigger_type = 'external' WHERE trigger_type = 'discovered';
+UPDATE uploads SET trigger_type = 'manual', trigger_metadata = '{"command":"requeue"}' WHERE trigger_type = 'requeue';
+UPDATE uploads SET trigger_type = 'manual', trigger_metadata = '{"command":"smoke"}' WHERE trigger_type = 'smoke';
+
+-- Monitor lag: bv's finish time and how long the monitor took to detect it
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS detection_lag_seconds DOUBLE PRECISION;
+
+-- Upload requests queued by the CLI for the running daemon, and the daemon's heartbeat
+CREATE TABLE IF NOT EXISTS upload_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_name VARCHAR(255) NOT NULL,
+    trigger_metadata TEXT,
+    requested_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    upload_id BIGINT,
+    error_message TEXT,
+    processed_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_upload_requests_node_status
+    ON upload_requests (node_name, status);
+CREATE TABLE IF NOT EXISTS daemon_heartbeats (
+    host VARCHAR(255) PRIMARY KEY,
+    pid INTEGER NOT NULL,
+    heartbeat_at TIMESTAMP NOT NULL
+);
+
+-- Content listing of completed snapshots, for restores and integrity audits
+CREATE TABLE IF NOT EXISTS upload_objects (
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    object_key TEXT NOT NULL,
+    size_bytes BIGINT,
+    checksum VARCHAR(255),
+    PRIMARY KEY (upload_id, object_key)
+);
+
+-- Incremental snapshots link to the snapshot they were taken against
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS base_upload_id BIGINT;
+
+-- Upload queue: scheduled runs are queued too when a concurrency limit is set
+ALTER TABLE upload_requests ADD COLUMN IF NOT EXISTS trigger_type VARCHAR(50) NOT NULL DEFAULT 'manual';
+ALTER TABLE upload_requests ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_upload_requests_status
+    ON upload_requests (status);
+
+-- Consistency group runs and the uploads started together in each run
+CREATE TABLE IF NOT EXISTS consistency_group_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_name VARCHAR(255) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    anchors TEXT,
+    error_message TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_consistency_group_runs_group_started
+    ON consistency_group_runs (group_name, started_at DESC);
+CREATE TABLE IF NOT EXISTS consistency_group_uploads (
+    run_id BIGINT NOT NULL REFERENCES consistency_group_runs(id) ON DELETE CASCADE,
+    node_name VARCHAR(255) NOT NULL,
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    PRIMARY KEY (run_id, node_name)
+);
+
+-- Every notification delivery attempt, linked to the upload it is about
+CREATE TABLE IF NOT EXISTS notification_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    upload_id BIGINT,
+    node_name VARCHAR(255) NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    notification_type VARCHAR(50) NOT NULL,
+    target_hash VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    status_code INTEGER,
+    error_message TEXT,
+    attempted_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notification_attempts_upload
+    ON notification_attempts (upload_id);
+
+-- Muted notifications per node, with who muted them
+CREATE TABLE IF NOT EXISTS notification_snoozes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_name VARCHAR(255) NOT NULL,
+    snoozed_until TIMESTAMP NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notification_snoozes_node_until
+    ON notification_snoozes (node_name, snoozed_until);
+
+-- Rows written to take the database write lock for a node (see LockNode)
+CREATE TABLE IF NOT EXISTS node_locks (
+    node_name VARCHAR(255) PRIMARY KEY,
+    locked_at TIMESTAMP NOT NULL
+);
+
+-- Nodes registered at runtime through the node API, with their YAML node config
+CREATE TABLE IF NOT EXISTS registered_nodes (
+    node_name VARCHAR(255) PRIMARY KEY,
+    config TEXT NOT NULL,
+    registered_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Host a registered node is assigned to; empty runs it on every daemon
+ALTER TABLE registered_nodes ADD COLUMN IF NOT EXISTS host VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_registered_nodes_host ON registered_nodes (host);
+
+-- Host resource guardrail actions taken while an upload ran
+CREATE TABLE IF NOT EXISTS upload_throttle_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    node_name VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    cpu_percent DOUBLE PRECISION,
+    memory_percent DOUBLE PRECISION,
+    disk_io_percent DOUBLE PRECISION,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_upload_throttle_events_upload
+    ON upload_throttle_events (upload_id, started_at);
+
+-- Outcome of spot-restoring a completed snapshot into a scratch node
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS restore_verification VARCHAR(20);
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS restore_verified_at TIMESTAMP;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS restore_verification_message TEXT;
+
+-- Progress of uploads run by in-process engines, so interrupted ones resume
+CREATE TABLE IF NOT EXISTS upload_checkpoints (
+    node_name VARCHAR(255) PRIMARY KEY,
+    engine VARCHAR(50) NOT NULL,
+    session TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS upload_checkpoint_chunks (
+    node_name VARCHAR(255) NOT NULL REFERENCES upload_checkpoints(node_name) ON DELETE CASCADE,
+    chunk_number INTEGER NOT NULL,
+    etag TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    checksum TEXT NOT NULL,
+    uploaded_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (node_name, chunk_number)
+);
+
+-- Compression of uploads by engines that report it, and the sizes before and after
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS compression VARCHAR(20);
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS compression_level INTEGER;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS raw_size_bytes BIGINT;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS compressed_size_bytes BIGINT;
+
+-- Raw engine output, kept once per status change instead of with every progress check
+CREATE TABLE IF NOT EXISTS upload_status_outputs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    recorded_at TIMESTAMP NOT NULL,
+    state TEXT NOT NULL,
+    raw_output TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_upload_status_outputs_upload
+    ON upload_status_outputs (upload_id, recorded_at);
+
+-- Bytes uploaded by completed uploads, for snapshot growth
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS size_bytes BIGINT;
+
+-- Notifications sent about each upload, so a restarted daemon neither repeats nor loses them
+CREATE TABLE IF NOT EXISTS upload_notifications (
+    upload_id BIGINT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    notification_key VARCHAR(100) NOT NULL,
+    node_name VARCHAR(255) NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP,
+    PRIMARY KEY (upload_id, notification_key)
+);
+
+-- What the scheduler last did with each named job of each daemon, and when it runs next
+CREATE TABLE IF NOT EXISTS job_states (
+    host VARCHAR(255) NOT NULL,
+    job_name VARCHAR(255) NOT NULL,
+    schedule VARCHAR(255) NOT NULL,
+    last_run_at TIMESTAMP,
+    last_result VARCHAR(20),
+    last_error TEXT,
+    last_duration_ms BIGINT,
+    next_run_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, job_name)
+);
+
+-- Look up completed snapshots by block height (see ProtocolDataNumber)
+CREATE INDEX IF NOT EXISTS idx_uploads_latest_block
+    ON uploads (node_name, json_extract(protocol_data, '$.latest_block'))
+    WHERE status = 'completed' AND json_type(protocol_data, '$.latest_block') IN ('integer', 'real');
+CREATE TABLE IF NOT EXISTS node_activity (
+    node_name VARCHAR(255) PRIMARY KEY,
+    host VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    inactive_since TIMESTAMP
+);
+
+-- Nodes whose scheduled uploads an operator paused with 'snapperd pause'
+CREATE TABLE IF NOT EXISTS node_pauses (
+    node_name VARCHAR(255) PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT,
+    paused_at TIMESTAMP NOT NULL
+);
+
+-- Upload requests coalesced into an upload already started for the node
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS coalesced_triggers INTEGER NOT NULL DEFAULT 0;
+
+-- What the daemon did on its own for each node, shown by 'snapperd show-node'
+CREATE TABLE IF NOT EXISTS node_actions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_name VARCHAR(255) NOT NULL,
+    upload_id BIGINT,
+    action VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_node_actions_node
+    ON node_actions (node_name, created_at);
@@ -0,0 +1,16 @@
+-- Operator and API actions that changed the daemon's state, with who took them
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor VARCHAR(255) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    node_name VARCHAR(255),
+    upload_id BIGINT,
+    message TEXT NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created
+    ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_node
+    ON audit_log (node_name, created_at);
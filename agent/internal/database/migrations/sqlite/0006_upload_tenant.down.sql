@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_uploads_tenant_started;
+ALTER TABLE uploads DROP COLUMN tenant;
@@ -0,0 +1,2 @@
+-- When a daemon stopped while the upload was running, until a daemon re-attaches monitoring
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS interrupted_at TIMESTAMP;
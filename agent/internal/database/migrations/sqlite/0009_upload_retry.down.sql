@@ -0,0 +1,3 @@
+ALTER TABLE upload_requests DROP COLUMN not_before;
+ALTER TABLE uploads DROP COLUMN retry_of;
+ALTER TABLE uploads DROP COLUMN attempt;
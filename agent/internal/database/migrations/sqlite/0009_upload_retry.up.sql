@@ -0,0 +1,5 @@
+-- Which attempt of an upload each upload is, and the failed upload a retry started again
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS attempt INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS retry_of BIGINT;
+-- Retries are queued to start only once their backoff has passed
+ALTER TABLE upload_requests ADD COLUMN IF NOT EXISTS not_before TIMESTAMP;
@@ -0,0 +1 @@
+ALTER TABLE node_pauses DROP COLUMN circuit_failures;
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Automatic actions recorded in a node's action log
+const (
+	NodeActionTimedOut           = "timed_out"           // An upload exceeded max_duration and was marked stalled
+	NodeActionCancelled          = "cancelled"           // An upload exceeded max_duration and its job was stopped (cancel_stalled)
+	NodeActionResumed            = "resumed"             // An upload interrupted by a restart was resumed from its checkpoint
+	NodeActionGuardrailApplied   = "guardrail_applied"   // A host resource guardrail throttled, paused or recorded an upload
+	NodeActionGuardrailLifted    = "guardrail_lifted"    // A guardrail action was undone
+	NodeActionNotificationResent = "notification_resent" // A notification interrupted by a restart was sent again
+	NodeActionRequestCoalesced   = "request_coalesced"   // An upload request joined an upload started moments earlier
+	NodeActionInterrupted        = "interrupted"         // The daemon stopped while an upload was running
+	NodeActionReattached         = "reattached"          // Monitoring of an upload interrupted by a stop was re-attached on startup
+	NodeActionRetryScheduled     = "retry_scheduled"     // A failed upload's next attempt was queued after the retry backoff
+	NodeActionRetriesExhausted   = "retries_exhausted"   // An upload failed on its last retry attempt
+	NodeActionCircuitOpened      = "circuit_opened"      // Consecutive failed uploads opened the node's circuit breaker
+	NodeActionCircuitClosed      = "circuit_closed"      // An upload completed and closed the node's open circuit
+)
+
+// NodeAction is an entry in a node's log of what the daemon did on its own, so an
+// operator can explain a surprise after the fact
+type NodeAction struct {
+	ID        int64     `db:"id"`
+	NodeName  string    `db:"node_name"`
+	UploadID  *int64    `db:"upload_id"` // The upload acted on, if any
+	Action    string    `db:"action"`
+	Message   string    `db:"message"` // Why, e.g. "exceeded max duration of 6h0m0s"
+	Details   JSONB     `db:"details"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// RecordNodeAction adds an automatic action to a node's action log
+func (db *DB) RecordNodeAction(ctx context.Context, action NodeAction) error {
+	query := `INSERT INTO node_actions (node_name, upload_id, action, message, details, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if err := db.execWithRetry(ctx, query, action.NodeName, action.UploadID, action.Action, action.Message, action.Details, action.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record node action: %w", err)
+	}
+
+	return nil
+}
+
+// GetNodeActions retrieves a node's automatic actions recorded since the given time, most
+// recent first, up to limit (0 = no limit)
+func (db *DB) GetNodeActions(ctx context.Context, nodeName string, since time.Time, limit int) ([]NodeAction, error) {
+	query := `SELECT id, node_name, upload_id, action, message, details, created_at
+	          FROM node_actions
+	          WHERE node_name = $1 AND created_at >= $2
+	          ORDER BY created_at DESC, id DESC`
+	args := []interface{}{nodeName, since.UTC()}
+	if limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, limit)
+	}
+
+	var actions []NodeAction
+	if err := db.queryWithRetry(ctx, &actions, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get node actions: %w", err)
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrNodeUploadRunning is returned when purging a node that has a running upload
+var ErrNodeUploadRunning = errors.New("node has a running upload")
+
+// NodeActivity records whether a node is still run by a daemon. A node removed from the
+// configuration keeps its history and is marked inactive until it is added back or
+// purged with PurgeNode.
+type NodeActivity struct {
+	NodeName      string     `db:"node_name"`
+	Host          string     `db:"host"`           // Host of the daemon that last ran the node
+	UpdatedAt     time.Time  `db:"updated_at"`     // When the node was last marked active or inactive
+	InactiveSince *time.Time `db:"inactive_since"` // When the node stopped being run (nil while active)
+}
+
+// MarkNodesActive records that the daemon on host runs the nodes, reactivating nodes that
+// were inactive
+func (db *DB) MarkNodesActive(ctx context.Context, host string, nodeNames []string, now time.Time) error {
+	query := `INSERT INTO node_activity (node_name, host, updated_at, inactive_since)
+	          VALUES ($1, $2, $3, NULL)
+	          ON CONFLICT (node_name) DO UPDATE SET
+	              host = EXCLUDED.host,
+	              updated_at = EXCLUDED.updated_at,
+	              inactive_since = NULL`
+
+	for _, nodeName := range nodeNames {
+		if err := db.execWithRetry(ctx, query, nodeName, host, now.UTC()); err != nil {
+			return fmt.Errorf("failed to mark node %s active: %w", nodeName, err)
+		}
+	}
+
+	return nil
+}
+
+// MarkNodeInactive records that a node is no longer run, keeping the time it first
+// became inactive. A node without recorded activity is left alone.
+func (db *DB) MarkNodeInactive(ctx context.Context, nodeName string, now time.Time) error {
+	query := `UPDATE node_activity SET inactive_since = $2, updated_at = $2
+	          WHERE node_name = $1 AND inactive_since IS NULL`
+
+	if err := db.execWithRetry(ctx, query, nodeName, now.UTC()); err != nil {
+		return fmt.Errorf("failed to mark node inactive: %w", err)
+	}
+
+	return nil
+}
+
+// DeactivateMissingNodes marks inactive the nodes last run by the daemon on host that are
+// not among its active nodes, and the nodes with uploads or schedule state from before
+// activity was tracked that no daemon runs. It returns the nodes newly marked inactive,
+// sorted by name.
+func (db *DB) DeactivateMissingNodes(ctx context.Context, host string, active []string, now time.Time) ([]string, error) {
+	isActive := make(map[string]bool, len(active))
+	for _, nodeName := range active {
+		isActive[nodeName] = true
+	}
+
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate nodes: %w", err)
+	}
+	defer tx.Rollback()
+
+	var untracked []string
+	if err := tx.SelectContext(ctx, &untracked, `SELECT node_name FROM uploads
+	          WHERE node_name NOT IN (SELECT node_name FROM node_activity)
+	          UNION
+	          SELECT node_name FROM schedule_state
+	          WHERE node_name NOT IN (SELECT node_name FROM node_activity)`); err != nil {
+		return nil, fmt.Errorf("failed to find untracked nodes: %w", err)
+	}
+	var tracked []string
+	if err := tx.SelectContext(ctx, &tracked, db.driver.Rebind(`SELECT node_name FROM node_activity
+	          WHERE host = $1 AND inactive_since IS NULL`), host); err != nil {
+		return nil, fmt.Errorf("failed to find active nodes: %w", err)
+	}
+
+	var deactivated []string
+	for _, nodeName := range untracked {
+		if isActive[nodeName] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, db.driver.Rebind(`INSERT INTO node_activity (node_name, host, updated_at, inactive_since)
+		          VALUES ($1, $2, $3, $3)`), nodeName, host, now.UTC()); err != nil {
+			return nil, fmt.Errorf("failed to mark node %s inactive: %w", nodeName, err)
+		}
+		deactivated = append(deactivated, nodeName)
+	}
+	for _, nodeName := range tracked {
+		if isActive[nodeName] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, db.driver.Rebind(`UPDATE node_activity SET inactive_since = $2, updated_at = $2
+		          WHERE node_name = $1`), nodeName, now.UTC()); err != nil {
+			return nil, fmt.Errorf("failed to mark node %s inactive: %w", nodeName, err)
+		}
+		deactivated = append(deactivated, nodeName)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to deactivate nodes: %w", err)
+	}
+
+	sort.Strings(deactivated)
+	return deactivated, nil
+}
+
+// GetInactiveNodes retrieves the nodes marked inactive, longest inactive first
+func (db *DB) GetInactiveNodes(ctx context.Context) ([]NodeActivity, error) {
+	query := `SELECT node_name, host, updated_at, inactive_since
+	          FROM node_activity
+	          WHERE inactive_since IS NOT NULL
+	          ORDER BY inactive_since, node_name`
+
+	var nodes []NodeActivity
+	if err := db.queryWithRetry(ctx, &nodes, query); err != nil {
+		return nil, fmt.Errorf("failed to get inactive nodes: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// GetNodeActivity retrieves a node's recorded activity, or nil if it has none
+func (db *DB) GetNodeActivity(ctx context.Context, nodeName string) (*NodeActivity, error) {
+	query := `SELECT node_name, host, updated_at, inactive_since
+	          FROM node_activity
+	          WHERE node_name = $1`
+
+	var node NodeActivity
+	err := db.getWithRetry(ctx, &node, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node activity: %w", err)
+	}
+
+	return &node, nil
+}
+
+// purgeStatements delete a node's rows ($1 is the node name), children of its uploads
+// first. Each is labeled with its table for PurgeNode's counts.
+var purgeStatements = []struct {
+	table string
+	query string
+}{
+	{"upload_progress_samples", `DELETE FROM upload_progress_samples WHERE upload_id IN (SELECT id FROM uploads WHERE node_name = $1)`},
+	{"upload_objects", `DELETE FROM upload_objects WHERE upload_id IN (SELECT id FROM uploads WHERE node_name = $1)`},
+	{"upload_status_outputs", `DELETE FROM upload_status_outputs WHERE upload_id IN (SELECT id FROM uploads WHERE node_name = $1)`},
+	{"upload_logs", `DELETE FROM upload_logs WHERE upload_id IN (SELECT id FROM uploads WHERE node_name = $1)`},
+	{"upload_throttle_events", `DELETE FROM upload_throttle_events WHERE node_name = $1`},
+	{"upload_notifications", `DELETE FROM upload_notifications WHERE node_name = $1`},
+	{"consistency_group_uploads", `DELETE FROM consistency_group_uploads WHERE node_name = $1`},
+	{"notification_attempts", `DELETE FROM notification_attempts WHERE node_name = $1`},
+	{"notification_snoozes", `DELETE FROM notification_snoozes WHERE node_name = $1`},
+	{"upload_requests", `DELETE FROM upload_requests WHERE node_name = $1`},
+	{"snapshots", `DELETE FROM snapshots WHERE node_name = $1`},
+	{"uploads", `DELETE FROM uploads WHERE node_name = $1`},
+	{"schedule_state", `DELETE FROM schedule_state WHERE node_name = $1`},
+	{"upload_checkpoint_chunks", `DELETE FROM upload_checkpoint_chunks WHERE node_name = $1`},
+	{"upload_checkpoints", `DELETE FROM upload_checkpoints WHERE node_name = $1`},
+	{"node_activity", `DELETE FROM node_activity WHERE node_name = $1`},
+	{"node_pauses", `DELETE FROM node_pauses WHERE node_name = $1`},
+	{"node_actions", `DELETE FROM node_actions WHERE node_name = $1`},
+}
+
+// PurgeNode deletes every row recorded for a node in a single transaction: its uploads
+// and their progress, contents and notifications, its requests, catalog entries, schedule
+// state, checkpoints, activity, pause and action log. It returns the number of rows deleted per table,
+// leaving out tables without rows for the node, or ErrNodeUploadRunning while the node
+// has a running upload. Registered node definitions are not deleted.
+func (db *DB) PurgeNode(ctx context.Context, nodeName string) (map[string]int64, error) {
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge node: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := db.driver.LockNode(ctx, tx, nodeName); err != nil {
+		return nil, fmt.Errorf("failed to lock node %s: %w", nodeName, err)
+	}
+
+	var running int
+	if err := tx.GetContext(ctx, &running, db.driver.Rebind(`SELECT COUNT(*) FROM uploads
+	          WHERE node_name = $1 AND status = 'running'`), nodeName); err != nil {
+		return nil, fmt.Errorf("failed to check for running upload: %w", err)
+	}
+	if running > 0 {
+		return nil, ErrNodeUploadRunning
+	}
+
+	deleted := make(map[string]int64)
+	for _, statement := range purgeStatements {
+		result, err := tx.ExecContext(ctx, db.driver.Rebind(statement.query), nodeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge %s: %w", statement.table, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge %s: %w", statement.table, err)
+		}
+		if rows > 0 {
+			deleted[statement.table] = rows
+		}
+	}
+
+	// SQLite's node lock is a row of its own, taken above, so it is not counted
+	if _, err := tx.ExecContext(ctx, db.driver.Rebind(`DELETE FROM node_locks WHERE node_name = $1`), nodeName); err != nil {
+		return nil, fmt.Errorf("failed to purge node_locks: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to purge node: %w", err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CircuitBreakerActor is the actor of the pauses opened by a node's circuit breaker
+const CircuitBreakerActor = "circuit_breaker"
+
+// NodePause records that an operator paused a node's scheduled uploads with
+// 'snapperd pause', or that its circuit breaker opened, until it is resumed
+type NodePause struct {
+	NodeName string    `db:"node_name"`
+	Actor    string    `db:"actor"`  // Who paused the node
+	Reason   *string   `db:"reason"` // Why the node was paused, if given
+	PausedAt time.Time `db:"paused_at"`
+	// CircuitFailures is the number of consecutive failed uploads that opened the node's
+	// circuit, nil for a pause by an operator
+	CircuitFailures *int `db:"circuit_failures"`
+}
+
+// IsCircuit reports whether the pause is an open circuit rather than an operator's pause
+func (p *NodePause) IsCircuit() bool {
+	return p.CircuitFailures != nil
+}
+
+// PauseNode records that a node is paused, replacing an earlier pause of the node
+func (db *DB) PauseNode(ctx context.Context, pause NodePause) error {
+	query := `INSERT INTO node_pauses (node_name, actor, reason, paused_at, circuit_failures)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (node_name) DO UPDATE SET
+	              actor = EXCLUDED.actor,
+	              reason = EXCLUDED.reason,
+	              paused_at = EXCLUDED.paused_at,
+	              circuit_failures = EXCLUDED.circuit_failures`
+
+	if err := db.execWithRetry(ctx, query, pause.NodeName, pause.Actor, pause.Reason, pause.PausedAt.UTC(), pause.CircuitFailures); err != nil {
+		return fmt.Errorf("failed to pause node: %w", err)
+	}
+
+	return nil
+}
+
+// OpenNodeCircuit records that a node's circuit opened after failures consecutive failed
+// uploads, replacing an earlier open circuit of the node. It reports whether the circuit
+// was recorded: a node paused by an operator keeps that pause.
+func (db *DB) OpenNodeCircuit(ctx context.Context, nodeName string, failures int, openedAt time.Time) (bool, error) {
+	query := `INSERT INTO node_pauses (node_name, actor, reason, paused_at, circuit_failures)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (node_name) DO UPDATE SET
+	              reason = EXCLUDED.reason,
+	              paused_at = EXCLUDED.paused_at,
+	              circuit_failures = EXCLUDED.circuit_failures
+	          WHERE node_pauses.circuit_failures IS NOT NULL
+	          RETURNING node_name`
+
+	reason := fmt.Sprintf("%d consecutive uploads failed", failures)
+	var opened []string
+	if err := db.queryWithRetry(ctx, &opened, query, nodeName, CircuitBreakerActor, reason, openedAt.UTC(), failures); err != nil {
+		return false, fmt.Errorf("failed to open node circuit: %w", err)
+	}
+
+	return len(opened) > 0, nil
+}
+
+// CloseNodeCircuit deletes a node's open circuit, leaving a pause by an operator in place.
+// It reports whether a circuit was open.
+func (db *DB) CloseNodeCircuit(ctx context.Context, nodeName string) (bool, error) {
+	query := `DELETE FROM node_pauses
+	          WHERE node_name = $1 AND circuit_failures IS NOT NULL
+	          RETURNING node_name`
+
+	var closed []string
+	if err := db.queryWithRetry(ctx, &closed, query, nodeName); err != nil {
+		return false, fmt.Errorf("failed to close node circuit: %w", err)
+	}
+
+	return len(closed) > 0, nil
+}
+
+// ResumeNode deletes a node's pause. Resuming a node that is not paused does nothing.
+func (db *DB) ResumeNode(ctx context.Context, nodeName string) error {
+	if err := db.execWithRetry(ctx, `DELETE FROM node_pauses WHERE node_name = $1`, nodeName); err != nil {
+		return fmt.Errorf("failed to resume node: %w", err)
+	}
+
+	return nil
+}
+
+// GetNodePause retrieves a node's pause, or nil if the node is not paused
+func (db *DB) GetNodePause(ctx context.Context, nodeName string) (*NodePause, error) {
+	query := `SELECT node_name, actor, reason, paused_at, circuit_failures
+	          FROM node_pauses
+	          WHERE node_name = $1`
+
+	var pause NodePause
+	err := db.getWithRetry(ctx, &pause, query, nodeName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node pause: %w", err)
+	}
+
+	return &pause, nil
+}
+
+// GetNodePauses retrieves every paused node, by node name
+func (db *DB) GetNodePauses(ctx context.Context) ([]NodePause, error) {
+	query := `SELECT node_name, actor, reason, paused_at, circuit_failures
+	          FROM node_pauses
+	          ORDER BY node_name`
+
+	var pauses []NodePause
+	if err := db.queryWithRetry(ctx, &pauses, query); err != nil {
+		return nil, fmt.Errorf("failed to get node pauses: %w", err)
+	}
+
+	return pauses, nil
+}
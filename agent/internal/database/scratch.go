@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// NewScratch opens an isolated, migrated database for dry runs such as the smoke test.
+// PostgreSQL uses a temporary schema in the configured database; SQLite uses a
+// temporary file. The returned cleanup function drops the scratch data and closes
+// the connection.
+func NewScratch(ctx context.Context, cfg Config) (*DB, func() error, error) {
+	driver, err := getDriver(cfg.Driver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch driver.Name() {
+	case DriverSQLite:
+		return newSQLiteScratch(ctx, cfg)
+	default:
+		return newPostgresScratch(ctx, cfg)
+	}
+}
+
+// newPostgresScratch creates a uniquely named schema and connects with it as search_path
+func newPostgresScratch(ctx context.Context, cfg Config) (*DB, func() error, error) {
+	admin, err := New(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schema := fmt.Sprintf("snapperd_scratch_%d", time.Now().UnixNano())
+	if err := admin.execWithRetry(ctx, fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		admin.Close()
+		return nil, nil, fmt.Errorf("failed to create scratch schema: %w", err)
+	}
+
+	dropSchema := func() error {
+		defer admin.Close()
+		if err := admin.execWithRetry(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema)); err != nil {
+			return fmt.Errorf("failed to drop scratch schema %s: %w", schema, err)
+		}
+		return nil
+	}
+
+	cfg.Schema = schema
+	db, err := New(ctx, cfg)
+	if err != nil {
+		_ = dropSchema()
+		return nil, nil, err
+	}
+
+	if err := db.Migrate(ctx); err != nil {
+		db.Close()
+		_ = dropSchema()
+		return nil, nil, err
+	}
+
+	cleanup := func() error {
+		db.Close()
+		return dropSchema()
+	}
+
+	return db, cleanup, nil
+}
+
+// newSQLiteScratch creates a database file in a temporary directory
+func newSQLiteScratch(ctx context.Context, cfg Config) (*DB, func() error, error) {
+	dir, err := os.MkdirTemp("", "snapperd-scratch-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+
+	cfg.Path = filepath.Join(dir, "scratch.db")
+	db, err := New(ctx, cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	if err := db.Migrate(ctx); err != nil {
+		db.Close()
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	cleanup := func() error {
+		db.Close()
+		return os.RemoveAll(dir)
+	}
+
+	return db, cleanup, nil
+}
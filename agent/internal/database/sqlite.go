@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// placeholderPattern matches PostgreSQL-style $N query placeholders
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+// addColumnPattern matches ALTER TABLE ... ADD COLUMN IF NOT EXISTS statements,
+// which SQLite does not support natively
+var addColumnPattern = regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+IF\s+NOT\s+EXISTS\s+(\w+)\s+(.+)$`)
+
+// sqliteDriver implements Driver for SQLite (single-host deployments)
+type sqliteDriver struct{}
+
+// Name returns the driver identifier
+func (d *sqliteDriver) Name() string {
+	return DriverSQLite
+}
+
+// Open opens the SQLite database file in WAL mode
+func (d *sqliteDriver) Open(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+
+	if dir := filepath.Dir(cfg.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	// WAL allows readers (status CLI) to run alongside the daemon's writes;
+	// busy_timeout makes concurrent writers wait instead of failing immediately
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_time_format=sqlite", cfg.Path)
+
+	conn, err := sqlx.ConnectContext(ctx, "sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite serializes writers, so a small pool is sufficient
+	conn.SetMaxOpenConns(4)
+	conn.SetMaxIdleConns(4)
+	conn.SetConnMaxLifetime(30 * time.Minute)
+
+	return conn, nil
+}
+
+// Rebind rewrites $N placeholders to SQLite's numbered ?N form, which keeps
+// the same positional semantics (including reused parameters)
+func (d *sqliteDriver) Rebind(query string) string {
+	return placeholderPattern.ReplaceAllString(query, "?$1")
+}
+
+// ProtocolDataNumber extracts the key with json_extract, which returns JSON numbers as
+// SQLite integers or reals
+func (d *sqliteDriver) ProtocolDataNumber(key string) (string, string) {
+	return fmt.Sprintf("json_extract(protocol_data, '$.%s')", key),
+		fmt.Sprintf("json_type(protocol_data, '$.%s') IN ('integer', 'real')", key)
+}
+
+// SecondsBetween converts the difference of the timestamps' Julian days to seconds
+func (d *sqliteDriver) SecondsBetween(from, to string) string {
+	return fmt.Sprintf("((julianday(%s) - julianday(%s)) * 86400.0)", to, from)
+}
+
+// LockNode takes SQLite's database write lock by touching the node's row in node_locks.
+// SQLite has no advisory locks, but only one transaction can write at a time, so other
+// writers wait (up to busy_timeout) until tx ends.
+func (d *sqliteDriver) LockNode(ctx context.Context, tx *sqlx.Tx, nodeName string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO node_locks (node_name, locked_at) VALUES (?1, ?2)
+	          ON CONFLICT (node_name) DO UPDATE SET locked_at = excluded.locked_at`, nodeName, time.Now().UTC())
+	return err
+}
+
+// LockMigrations takes SQLite's database write lock with a write to schema_migrations
+// that changes nothing, so other migrating processes wait (up to busy_timeout) until tx ends
+func (d *sqliteDriver) LockMigrations(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, `UPDATE schema_migrations SET version = version WHERE version < 0`)
+	return err
+}
+
+// PrepareMigration emulates ADD COLUMN IF NOT EXISTS by checking the table's columns first
+func (d *sqliteDriver) PrepareMigration(ctx context.Context, conn sqlx.QueryerContext, statement string) (string, bool, error) {
+	match := addColumnPattern.FindStringSubmatch(statement)
+	if match == nil {
+		return statement, true, nil
+	}
+	table, column, definition := match[1], match[2], match[3]
+
+	var count int
+	if err := sqlx.GetContext(ctx, conn, &count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column); err != nil {
+		return "", false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	if count > 0 {
+		return "", false, nil
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition), true, nil
+}
+
+// Migrations returns the SQLite migrations, from migrations/sqlite
+func (d *sqliteDriver) Migrations() ([]Migration, error) {
+	return loadMigrations(DriverSQLite)
+}
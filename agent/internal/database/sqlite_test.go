@@ -0,0 +1,2310 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/analytics"
+)
+
+// newTestSQLiteDB opens a migrated SQLite database in a temporary directory
+func newTestSQLiteDB(t *testing.T) *DB {
+	t.Helper()
+
+	ctx := context.Background()
+	db, err := New(ctx, Config{
+		Driver: DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "snapperd.db"),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("failed to migrate sqlite database: %v", err)
+	}
+
+	return db
+}
+
+func TestGetDriver(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "default", driver: "", expected: DriverPostgres},
+		{name: "postgres", driver: "postgres", expected: DriverPostgres},
+		{name: "sqlite", driver: "sqlite", expected: DriverSQLite},
+		{name: "unknown", driver: "mysql", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, err := getDriver(tt.driver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && driver.Name() != tt.expected {
+				t.Errorf("expected driver %s, got %s", tt.expected, driver.Name())
+			}
+		})
+	}
+}
+
+func TestSQLiteRebind(t *testing.T) {
+	driver := &sqliteDriver{}
+
+	got := driver.Rebind("UPDATE uploads SET status = $1 WHERE id = $2 OR id = $10")
+	expected := "UPDATE uploads SET status = ?1 WHERE id = ?2 OR id = ?10"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestSQLiteUploadLifecycle(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	// Migrations must be idempotent
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+
+	id, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		NodeType:     "archive",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{"latest_block": 12345},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	running, err := db.GetRunningUploadForNode(ctx, "ethereum-mainnet")
+	if err != nil {
+		t.Fatalf("GetRunningUploadForNode failed: %v", err)
+	}
+	if running == nil || running.ID != id {
+		t.Fatalf("expected running upload %d, got %+v", id, running)
+	}
+	if running.ProtocolData["latest_block"] != float64(12345) {
+		t.Errorf("expected latest_block 12345, got %v", running.ProtocolData["latest_block"])
+	}
+
+	percent := 42.5
+	completed, total := 10, 20
+	now := time.Now()
+	if err := db.UpdateUploadProgress(ctx, id, "running", &percent, &completed, &total, &now); err != nil {
+		t.Fatalf("UpdateUploadProgress failed: %v", err)
+	}
+
+	uploads, err := db.GetRunningUploads(ctx)
+	if err != nil {
+		t.Fatalf("GetRunningUploads failed: %v", err)
+	}
+	if len(uploads) != 1 || uploads[0].ChunksCompleted == nil || *uploads[0].ChunksCompleted != 10 {
+		t.Fatalf("expected one running upload with 10 chunks completed, got %+v", uploads)
+	}
+
+	message := "Finished with exit code 0"
+	if err := db.UpdateUploadCompletion(ctx, id, time.Now(), "completed", &message, nil); err != nil {
+		t.Fatalf("UpdateUploadCompletion failed: %v", err)
+	}
+
+	running, err = db.GetRunningUploadForNode(ctx, "ethereum-mainnet")
+	if err != nil {
+		t.Fatalf("GetRunningUploadForNode failed: %v", err)
+	}
+	if running != nil {
+		t.Errorf("expected no running upload after completion, got %+v", running)
+	}
+
+	latest, err := db.GetLatestCompletedUploadForNode(ctx, "ethereum-mainnet")
+	if err != nil {
+		t.Fatalf("GetLatestCompletedUploadForNode failed: %v", err)
+	}
+	if latest == nil || latest.ID != id || latest.CompletedAt == nil {
+		t.Fatalf("expected completed upload %d, got %+v", id, latest)
+	}
+	if latest.CompletionMessage == nil || *latest.CompletionMessage != message {
+		t.Errorf("expected completion message %q, got %v", message, latest.CompletionMessage)
+	}
+}
+
+func TestSQLiteCreateUploadIfNotRunning(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	// Concurrent triggers for one node create a single upload
+	const triggers = 8
+	type result struct {
+		id      int64
+		created bool
+		err     error
+	}
+	results := make(chan result, triggers)
+	for i := 0; i < triggers; i++ {
+		go func() {
+			id, created, err := db.CreateUploadIfNotRunning(ctx, Upload{
+				NodeName:     "ethereum-mainnet",
+				Protocol:     "ethereum",
+				StartedAt:    time.Now(),
+				Status:       "running",
+				TriggerType:  "scheduled",
+				ProtocolData: JSONB{"latest_block": 12345},
+			})
+			results <- result{id, created, err}
+		}()
+	}
+
+	var createdID int64
+	ids := make(map[int64]bool)
+	for i := 0; i < triggers; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("CreateUploadIfNotRunning failed: %v", r.err)
+		}
+		if r.created {
+			if createdID != 0 {
+				t.Fatalf("expected one upload created, got %d and %d", createdID, r.id)
+			}
+			createdID = r.id
+		}
+		ids[r.id] = true
+	}
+	if createdID == 0 || len(ids) != 1 {
+		t.Fatalf("expected every trigger to get upload %d, got %v", createdID, ids)
+	}
+
+	// Other nodes are not affected, and a finished upload no longer blocks the node
+	if _, created, err := db.CreateUploadIfNotRunning(ctx, Upload{NodeName: "arbitrum-one", StartedAt: time.Now(), Status: "running", TriggerType: "manual", ProtocolData: JSONB{}}); err != nil || !created {
+		t.Errorf("expected upload for another node created, got created=%v, error %v", created, err)
+	}
+	if err := db.UpdateUploadCompletion(ctx, createdID, time.Now(), "completed", nil, nil); err != nil {
+		t.Fatalf("UpdateUploadCompletion failed: %v", err)
+	}
+	id, created, err := db.CreateUploadIfNotRunning(ctx, Upload{NodeName: "ethereum-mainnet", StartedAt: time.Now(), Status: "running", TriggerType: "manual", ProtocolData: JSONB{}})
+	if err != nil || !created || id == createdID {
+		t.Errorf("expected a new upload after completion, got id %d, created=%v, error %v", id, created, err)
+	}
+}
+
+func TestSQLiteMigrateIsIdempotent(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	// Re-running migrations must skip columns that already exist
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+
+	id, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "test-node",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now().UTC(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	stalledSince := time.Now().UTC().Truncate(time.Second)
+	if err := db.SetUploadStalled(ctx, id, &stalledSince); err != nil {
+		t.Fatalf("SetUploadStalled failed: %v", err)
+	}
+
+	running, err := db.GetRunningUploadForNode(ctx, "test-node")
+	if err != nil || running == nil {
+		t.Fatalf("GetRunningUploadForNode failed: %v", err)
+	}
+	if running.StalledSince == nil || !running.StalledSince.Equal(stalledSince) {
+		t.Errorf("StalledSince = %v, want %v", running.StalledSince, stalledSince)
+	}
+
+	if err := db.SetUploadStalled(ctx, id, nil); err != nil {
+		t.Fatalf("SetUploadStalled(nil) failed: %v", err)
+	}
+	running, _ = db.GetRunningUploadForNode(ctx, "test-node")
+	if running.StalledSince != nil {
+		t.Errorf("expected stalled mark to be cleared, got %v", running.StalledSince)
+	}
+}
+
+func TestSQLiteListUploads(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	create := func(node, status string, startedAt time.Time, finished bool) {
+		t.Helper()
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     node,
+			Protocol:     "ethereum",
+			StartedAt:    startedAt,
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+			Tenant:       "team-" + strings.TrimPrefix(node, "node-"),
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		if finished {
+			if err := db.UpdateUploadCompletion(ctx, id, startedAt.Add(time.Hour), status, nil, nil); err != nil {
+				t.Fatalf("UpdateUploadCompletion failed: %v", err)
+			}
+		}
+	}
+
+	create("node-a", "completed", now.Add(-10*24*time.Hour), true)
+	create("node-a", "completed", now.Add(-2*time.Hour), true)
+	create("node-a", "failed", now.Add(-time.Hour), true)
+	create("node-b", "completed", now.Add(-3*time.Hour), true)
+	create("node-b", "running", now, false)
+
+	tests := []struct {
+		name   string
+		filter UploadFilter
+		want   int
+	}{
+		{name: "all finished", filter: UploadFilter{}, want: 4},
+		{name: "by node", filter: UploadFilter{NodeName: "node-a"}, want: 3},
+		{name: "by status", filter: UploadFilter{Status: "failed"}, want: 1},
+		{name: "by tenant", filter: UploadFilter{Tenant: "team-b"}, want: 1},
+		{name: "unknown tenant", filter: UploadFilter{Tenant: "team-c"}, want: 0},
+		{name: "running status", filter: UploadFilter{Status: "running"}, want: 1},
+		{name: "since", filter: UploadFilter{Since: now.Add(-7 * 24 * time.Hour)}, want: 3},
+		{name: "until", filter: UploadFilter{Until: now.Add(-2 * time.Hour)}, want: 2},
+		{name: "limit", filter: UploadFilter{Limit: 2}, want: 2},
+		{name: "offset", filter: UploadFilter{Offset: 3}, want: 1},
+		{name: "combined", filter: UploadFilter{NodeName: "node-a", Status: "completed", Since: now.Add(-24 * time.Hour)}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads, err := db.ListUploads(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListUploads failed: %v", err)
+			}
+			if len(uploads) != tt.want {
+				t.Errorf("ListUploads returned %d uploads, want %d", len(uploads), tt.want)
+			}
+		})
+	}
+
+	// Most recent first
+	uploads, _ := db.ListUploads(ctx, UploadFilter{})
+	if len(uploads) > 1 && uploads[0].StartedAt.Before(uploads[1].StartedAt) {
+		t.Error("expected uploads ordered by started_at descending")
+	}
+}
+
+func TestSQLiteUploadHistory(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	create := func(node, status string, startedAt time.Time, duration time.Duration) {
+		t.Helper()
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     node,
+			Protocol:     "ethereum",
+			StartedAt:    startedAt,
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		if status != "running" {
+			if err := db.UpdateUploadCompletion(ctx, id, startedAt.Add(duration), status, nil, nil); err != nil {
+				t.Fatalf("UpdateUploadCompletion failed: %v", err)
+			}
+		}
+	}
+
+	// Two uploads start at the same time, so the cursor has to break the tie by ID
+	create("node-a", "completed", now.Add(-5*time.Hour), time.Hour)
+	create("node-a", "completed", now.Add(-4*time.Hour), 3*time.Hour)
+	create("node-b", "failed", now.Add(-4*time.Hour), 10*time.Minute)
+	create("node-b", "cancelled", now.Add(-2*time.Hour), time.Minute)
+	create("node-a", "completed", now.Add(-time.Hour), 2*time.Hour)
+	create("node-b", "running", now, 0)
+
+	history, err := db.GetUploadHistory(ctx, UploadFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetUploadHistory failed: %v", err)
+	}
+	stats := history.Stats
+	if stats.Total != 5 || stats.Finished != 5 || stats.ByStatus["completed"] != 3 || stats.ByStatus["failed"] != 1 {
+		t.Errorf("unexpected stats for finished uploads: %+v", stats)
+	}
+	if stats.SuccessRate == nil || *stats.SuccessRate != 0.6 {
+		t.Errorf("expected a 60%% success rate, got %v", stats.SuccessRate)
+	}
+	if stats.AvgDurationSeconds == nil || math.Abs(*stats.AvgDurationSeconds-7200) > 1 {
+		t.Errorf("expected completed uploads to average 2h, got %v", stats.AvgDurationSeconds)
+	}
+
+	// Follow the cursor through every page
+	var ids []int64
+	filter := UploadFilter{Limit: 2}
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatal("expected the cursor to reach the last page")
+		}
+		history, err := db.GetUploadHistory(ctx, filter)
+		if err != nil {
+			t.Fatalf("GetUploadHistory failed: %v", err)
+		}
+		for _, u := range history.Uploads {
+			ids = append(ids, u.ID)
+		}
+		if history.Next == nil {
+			break
+		}
+		filter.After = history.Next
+	}
+	if want := []int64{5, 4, 3, 2, 1}; fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("expected pages to list uploads %v, got %v", want, ids)
+	}
+
+	// Stats follow the filter but not the paging
+	history, err = db.GetUploadHistory(ctx, UploadFilter{NodeName: "node-b", Status: "failed", Offset: 1})
+	if err != nil {
+		t.Fatalf("GetUploadHistory failed: %v", err)
+	}
+	if len(history.Uploads) != 0 || history.Stats.Total != 1 || history.Next != nil {
+		t.Errorf("expected an empty page of one failed upload, got %d uploads, stats %+v", len(history.Uploads), history.Stats)
+	}
+	if history.Stats.SuccessRate == nil || *history.Stats.SuccessRate != 0 || history.Stats.AvgDurationSeconds != nil {
+		t.Errorf("expected no successes and no average duration, got %+v", history.Stats)
+	}
+
+	if _, err := db.GetUploadHistory(ctx, UploadFilter{Offset: 1, After: &UploadCursor{StartedAt: now, ID: 1}}); err == nil {
+		t.Error("expected offset and cursor pagination to be rejected together")
+	}
+}
+
+func TestSQLiteNodeUploadStats(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	create := func(node, status string, startedAt time.Time, duration time.Duration, chunks int) {
+		t.Helper()
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     node,
+			Protocol:     "ethereum",
+			StartedAt:    startedAt,
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+			ChunksTotal:  &chunks,
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		if status != "running" {
+			if err := db.UpdateUploadCompletion(ctx, id, startedAt.Add(duration), status, nil, nil); err != nil {
+				t.Fatalf("UpdateUploadCompletion failed: %v", err)
+			}
+		}
+	}
+
+	create("node-a", "completed", now.Add(-5*time.Hour), 4*time.Hour, 400)
+	create("node-a", "completed", now.Add(-4*time.Hour), time.Hour, 100)
+	create("node-a", "failed", now.Add(-3*time.Hour), 10*time.Minute, 50)
+	create("node-a", "completed", now.Add(-2*time.Hour), 3*time.Hour, 200)
+	create("node-a", "running", now, 0, 300)
+	create("node-b", "completed", now.Add(-time.Hour), 10*time.Hour, 1000)
+
+	stats, err := db.GetNodeUploadStats(ctx, "node-a", 0)
+	if err != nil {
+		t.Fatalf("GetNodeUploadStats failed: %v", err)
+	}
+	if stats.Uploads != 4 || stats.Completed != 3 || stats.Failed != 1 {
+		t.Errorf("expected 4 finished uploads, 3 completed and 1 failed, got %+v", stats)
+	}
+	if stats.AvgDurationSeconds == nil || math.Abs(*stats.AvgDurationSeconds-8*3600/3.0) > 1 {
+		t.Errorf("expected completed uploads to average 2h40m, got %v", stats.AvgDurationSeconds)
+	}
+	if stats.MinDurationSeconds == nil || math.Abs(*stats.MinDurationSeconds-3600) > 1 ||
+		stats.MaxDurationSeconds == nil || math.Abs(*stats.MaxDurationSeconds-4*3600) > 1 {
+		t.Errorf("expected durations from 1h to 4h, got %v to %v", stats.MinDurationSeconds, stats.MaxDurationSeconds)
+	}
+	if stats.AvgChunks == nil || math.Abs(*stats.AvgChunks-700/3.0) > 0.01 {
+		t.Errorf("expected completed uploads to average 233.33 chunks, got %v", stats.AvgChunks)
+	}
+
+	// Only the most recent uploads count
+	stats, err = db.GetNodeUploadStats(ctx, "node-a", 2)
+	if err != nil {
+		t.Fatalf("GetNodeUploadStats failed: %v", err)
+	}
+	if stats.Uploads != 2 || stats.Completed != 1 || stats.Failed != 1 {
+		t.Errorf("expected the last 2 uploads, got %+v", stats)
+	}
+	if stats.AvgDurationSeconds == nil || math.Abs(*stats.AvgDurationSeconds-3*3600) > 1 {
+		t.Errorf("expected the last completed upload's 3h duration, got %v", stats.AvgDurationSeconds)
+	}
+
+	stats, err = db.GetNodeUploadStats(ctx, "node-c", 10)
+	if err != nil {
+		t.Fatalf("GetNodeUploadStats failed: %v", err)
+	}
+	if stats.Uploads != 0 || stats.AvgDurationSeconds != nil || stats.AvgChunks != nil {
+		t.Errorf("expected no stats for a node without uploads, got %+v", stats)
+	}
+}
+
+func TestSQLiteTriggerMetadata(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for _, u := range []Upload{
+		{NodeName: "node-a", TriggerType: "manual", TriggerMetadata: JSONB{"user": "alice", "reason": "pre-upgrade"}},
+		{NodeName: "node-a", TriggerType: "scheduled"},
+		{NodeName: "node-b", TriggerType: "discovered"},
+	} {
+		u.Protocol = "ethereum"
+		u.StartedAt = now
+		u.Status = "running"
+		u.ProtocolData = JSONB{}
+		id, err := db.CreateUpload(ctx, u)
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		if err := db.UpdateUploadCompletion(ctx, id, now.Add(time.Hour), "completed", nil, nil); err != nil {
+			t.Fatalf("UpdateUploadCompletion failed: %v", err)
+		}
+	}
+
+	manual, err := db.ListUploads(ctx, UploadFilter{Trigger: "manual"})
+	if err != nil {
+		t.Fatalf("ListUploads failed: %v", err)
+	}
+	if len(manual) != 1 {
+		t.Fatalf("expected 1 manual upload, got %d", len(manual))
+	}
+	if manual[0].TriggerMetadata["user"] != "alice" || manual[0].TriggerMetadata["reason"] != "pre-upgrade" {
+		t.Errorf("unexpected trigger metadata: %v", manual[0].TriggerMetadata)
+	}
+
+	// Adopting the baseline migration folds legacy trigger names into the taxonomy, as
+	// for a database created before migrations were versioned
+	if _, err := db.conn.ExecContext(ctx, `DROP TABLE schema_migrations`); err != nil {
+		t.Fatalf("failed to drop schema_migrations: %v", err)
+	}
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	external, err := db.ListUploads(ctx, UploadFilter{Trigger: "external"})
+	if err != nil {
+		t.Fatalf("ListUploads failed: %v", err)
+	}
+	if len(external) != 1 || external[0].NodeName != "node-b" {
+		t.Errorf("expected the discovered upload to become external, got %+v", external)
+	}
+}
+
+func TestSQLiteProgressSamplesAndThroughput(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Truncate(time.Second)
+
+	id, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "node-a",
+		Protocol:     "ethereum",
+		StartedAt:    start,
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	total := 100
+	for i := 0; i < 3; i++ {
+		sample := analytics.Sample{RecordedAt: start.Add(time.Duration(i) * time.Minute), ChunksCompleted: i * 10, ChunksTotal: &total}
+		if err := db.RecordProgressSample(ctx, id, sample); err != nil {
+			t.Fatalf("RecordProgressSample failed: %v", err)
+		}
+	}
+
+	samples, err := db.GetProgressSamples(ctx, id, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetProgressSamples failed: %v", err)
+	}
+	if len(samples) != 2 || samples[0].ChunksCompleted != 10 || samples[1].ChunksCompleted != 20 {
+		t.Fatalf("unexpected samples: %+v", samples)
+	}
+	if samples[1].ChunksTotal == nil || *samples[1].ChunksTotal != total {
+		t.Errorf("expected chunks_total %d, got %v", total, samples[1].ChunksTotal)
+	}
+
+	rate := 10.0
+	eta := start.Add(10 * time.Minute)
+	if err := db.UpdateUploadThroughput(ctx, id, &rate, &eta); err != nil {
+		t.Fatalf("UpdateUploadThroughput failed: %v", err)
+	}
+
+	running, err := db.GetRunningUploadForNode(ctx, "node-a")
+	if err != nil || running == nil {
+		t.Fatalf("GetRunningUploadForNode failed: %v", err)
+	}
+	if running.ChunksPerMinute == nil || *running.ChunksPerMinute != rate {
+		t.Errorf("expected chunks_per_minute %.1f, got %v", rate, running.ChunksPerMinute)
+	}
+	if running.EstimatedCompletion == nil || !running.EstimatedCompletion.Equal(eta) {
+		t.Errorf("expected estimated_completion %v, got %v", eta, running.EstimatedCompletion)
+	}
+}
+
+func TestSQLiteScheduleState(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	state, err := db.GetScheduleState(ctx, "node-a")
+	if err != nil {
+		t.Fatalf("GetScheduleState failed: %v", err)
+	}
+	if state != nil {
+		t.Fatalf("expected no state for unknown node, got %+v", state)
+	}
+
+	lastRun := time.Now().UTC().Truncate(time.Second)
+	nextRun := lastRun.Add(time.Hour)
+	result := "initiated"
+	if err := db.SaveScheduleState(ctx, ScheduleState{NodeName: "node-a", LastRunAt: &lastRun, NextRunAt: &nextRun, LastResult: &result}); err != nil {
+		t.Fatalf("SaveScheduleState failed: %v", err)
+	}
+
+	// Saving again replaces the existing row
+	nextRun = nextRun.Add(time.Hour)
+	result = "skipped"
+	if err := db.SaveScheduleState(ctx, ScheduleState{NodeName: "node-a", LastRunAt: &lastRun, NextRunAt: &nextRun, LastResult: &result}); err != nil {
+		t.Fatalf("SaveScheduleState (update) failed: %v", err)
+	}
+
+	state, err = db.GetScheduleState(ctx, "node-a")
+	if err != nil {
+		t.Fatalf("GetScheduleState failed: %v", err)
+	}
+	if state == nil || state.NextRunAt == nil || !state.NextRunAt.Equal(nextRun) {
+		t.Errorf("expected next_run_at %v, got %+v", nextRun, state)
+	}
+	if state.LastResult == nil || *state.LastResult != "skipped" {
+		t.Errorf("expected last_result skipped, got %v", state.LastResult)
+	}
+
+	states, err := db.GetScheduleStates(ctx)
+	if err != nil {
+		t.Fatalf("GetScheduleStates failed: %v", err)
+	}
+	if len(states) != 1 {
+		t.Errorf("expected 1 schedule state, got %d", len(states))
+	}
+}
+
+func TestSQLiteUploadRequests(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	firstID, err := db.CreateUploadRequest(ctx, "node-a", "manual", 0, JSONB{"command": "upload", "user": "alice"})
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	secondID, err := db.CreateUploadRequest(ctx, "node-a", "manual", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	if _, err := db.CreateUploadRequest(ctx, "node-b", "manual", 0, nil); err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+
+	claimed, err := db.ClaimUploadRequests(ctx, "node-a")
+	if err != nil {
+		t.Fatalf("ClaimUploadRequests failed: %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].ID != firstID || claimed[1].ID != secondID {
+		t.Fatalf("expected requests %d and %d claimed in order, got %+v", firstID, secondID, claimed)
+	}
+	if claimed[0].Status != UploadRequestProcessing || claimed[0].ProcessedAt == nil {
+		t.Errorf("expected claimed request to be processing, got %+v", claimed[0])
+	}
+	if claimed[0].TriggerMetadata["user"] != "alice" {
+		t.Errorf("expected trigger metadata to round-trip, got %v", claimed[0].TriggerMetadata)
+	}
+
+	// A request is only claimed once
+	claimed, err = db.ClaimUploadRequests(ctx, "node-a")
+	if err != nil {
+		t.Fatalf("ClaimUploadRequests failed: %v", err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("expected no requests left to claim, got %d", len(claimed))
+	}
+
+	uploadID := int64(42)
+	if err := db.CompleteUploadRequest(ctx, firstID, UploadRequestInitiated, &uploadID, nil); err != nil {
+		t.Fatalf("CompleteUploadRequest failed: %v", err)
+	}
+	request, err := db.GetUploadRequest(ctx, firstID)
+	if err != nil {
+		t.Fatalf("GetUploadRequest failed: %v", err)
+	}
+	if request == nil || request.Status != UploadRequestInitiated || request.UploadID == nil || *request.UploadID != uploadID {
+		t.Errorf("expected request initiated with upload 42, got %+v", request)
+	}
+
+	request, err = db.GetUploadRequestForUpload(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("GetUploadRequestForUpload failed: %v", err)
+	}
+	if request == nil || request.ID != firstID {
+		t.Errorf("expected request %d for upload 42, got %+v", firstID, request)
+	}
+	request, err = db.GetUploadRequestForUpload(ctx, 43)
+	if err != nil {
+		t.Fatalf("GetUploadRequestForUpload failed: %v", err)
+	}
+	if request != nil {
+		t.Errorf("expected no request for a directly started upload, got %+v", request)
+	}
+
+	request, err = db.GetUploadRequest(ctx, 999)
+	if err != nil {
+		t.Fatalf("GetUploadRequest failed: %v", err)
+	}
+	if request != nil {
+		t.Errorf("expected no request for unknown ID, got %+v", request)
+	}
+}
+
+func TestSQLiteCoalescedUploadRequests(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	uploadID, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "node-a",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now().UTC(),
+		Status:       "running",
+		TriggerType:  "manual",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	firstID, err := db.CreateUploadRequest(ctx, "node-a", "manual", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	secondID, err := db.CreateUploadRequest(ctx, "node-a", "manual", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	if err := db.CompleteUploadRequest(ctx, firstID, UploadRequestInitiated, &uploadID, nil); err != nil {
+		t.Fatalf("CompleteUploadRequest failed: %v", err)
+	}
+	if err := db.CompleteUploadRequest(ctx, secondID, UploadRequestCoalesced, &uploadID, nil); err != nil {
+		t.Fatalf("CompleteUploadRequest failed: %v", err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		count, err := db.IncrementCoalescedTriggers(ctx, uploadID)
+		if err != nil {
+			t.Fatalf("IncrementCoalescedTriggers failed: %v", err)
+		}
+		if count != want {
+			t.Errorf("expected %d coalesced triggers, got %d", want, count)
+		}
+	}
+
+	u, err := db.GetUpload(ctx, uploadID)
+	if err != nil || u == nil {
+		t.Fatalf("GetUpload failed: %v", err)
+	}
+	if u.CoalescedTriggers != 2 {
+		t.Errorf("expected upload to record 2 coalesced triggers, got %d", u.CoalescedTriggers)
+	}
+
+	// The upload still belongs to the request that started it
+	request, err := db.GetUploadRequestForUpload(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("GetUploadRequestForUpload failed: %v", err)
+	}
+	if request == nil || request.ID != firstID {
+		t.Errorf("expected request %d for the upload, got %+v", firstID, request)
+	}
+}
+
+func TestSQLiteUploadQueue(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	low, err := db.CreateUploadRequest(ctx, "node-a", "scheduled", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	high, err := db.CreateUploadRequest(ctx, "node-b", "scheduled", 5, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	manual, err := db.CreateUploadRequest(ctx, "node-c", "manual", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	claimedID, err := db.CreateUploadRequest(ctx, "node-d", "scheduled", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	if _, err := db.ClaimUploadRequests(ctx, "node-d"); err != nil {
+		t.Fatalf("ClaimUploadRequests failed: %v", err)
+	}
+
+	// Processing first, then manual requests, then by priority, then oldest first
+	queue, err := db.ListUploadQueue(ctx)
+	if err != nil {
+		t.Fatalf("ListUploadQueue failed: %v", err)
+	}
+	want := []int64{claimedID, manual, high, low}
+	if len(queue) != len(want) {
+		t.Fatalf("expected %d queued requests, got %+v", len(want), queue)
+	}
+	for i, id := range want {
+		if queue[i].ID != id {
+			t.Errorf("position %d: expected request %d, got %d", i, id, queue[i].ID)
+		}
+	}
+	if queue[2].TriggerType != "scheduled" || queue[2].Priority != 5 {
+		t.Errorf("expected trigger type and priority to round-trip, got %+v", queue[2])
+	}
+
+	// Finished requests leave the queue
+	if err := db.CompleteUploadRequest(ctx, claimedID, UploadRequestSkipped, nil, nil); err != nil {
+		t.Fatalf("CompleteUploadRequest failed: %v", err)
+	}
+	queue, err = db.ListUploadQueue(ctx)
+	if err != nil {
+		t.Fatalf("ListUploadQueue failed: %v", err)
+	}
+	if len(queue) != 3 {
+		t.Errorf("expected 3 queued requests, got %d", len(queue))
+	}
+}
+
+func TestSQLiteConsistencyGroupRuns(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	startedAt := time.Now().Add(-time.Hour)
+	uploadIDs := make(map[string]int64)
+	for _, node := range []string{"eth-el", "eth-cl"} {
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     node,
+			Protocol:     "ethereum",
+			StartedAt:    startedAt,
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		uploadIDs[node] = id
+	}
+
+	older, err := db.CreateConsistencyGroupRun(ctx, "eth", startedAt.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("CreateConsistencyGroupRun failed: %v", err)
+	}
+	runID, err := db.CreateConsistencyGroupRun(ctx, "eth", startedAt)
+	if err != nil {
+		t.Fatalf("CreateConsistencyGroupRun failed: %v", err)
+	}
+	if _, err := db.CreateConsistencyGroupRun(ctx, "other", startedAt); err != nil {
+		t.Fatalf("CreateConsistencyGroupRun failed: %v", err)
+	}
+	for node, uploadID := range uploadIDs {
+		if err := db.AddConsistencyGroupUpload(ctx, runID, node, uploadID); err != nil {
+			t.Fatalf("AddConsistencyGroupUpload failed: %v", err)
+		}
+	}
+	anchors := JSONB{"eth-el": map[string]interface{}{"latest_block": float64(100)}}
+	if err := db.CompleteConsistencyGroupRun(ctx, runID, ConsistencyGroupRunInitiated, anchors, nil); err != nil {
+		t.Fatalf("CompleteConsistencyGroupRun failed: %v", err)
+	}
+
+	runs, err := db.ListConsistencyGroupRuns(ctx, "eth", 0)
+	if err != nil {
+		t.Fatalf("ListConsistencyGroupRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != runID || runs[1].ID != older {
+		t.Fatalf("expected runs %d and %d newest first, got %+v", runID, older, runs)
+	}
+	if runs[0].Status != ConsistencyGroupRunInitiated || runs[1].Status != ConsistencyGroupRunStarting {
+		t.Errorf("unexpected run statuses: %s, %s", runs[0].Status, runs[1].Status)
+	}
+	anchor, ok := runs[0].Anchors["eth-el"].(map[string]interface{})
+	if !ok || anchor["latest_block"] != float64(100) {
+		t.Errorf("expected anchors to round-trip, got %v", runs[0].Anchors)
+	}
+
+	runs, err = db.ListConsistencyGroupRuns(ctx, "", 1)
+	if err != nil {
+		t.Fatalf("ListConsistencyGroupRuns failed: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("expected limit of 1 run, got %d", len(runs))
+	}
+
+	members, err := db.GetConsistencyGroupUploads(ctx, runID)
+	if err != nil {
+		t.Fatalf("GetConsistencyGroupUploads failed: %v", err)
+	}
+	if len(members) != 2 || members[0].NodeName != "eth-cl" || members[0].UploadID != uploadIDs["eth-cl"] || members[1].NodeName != "eth-el" {
+		t.Errorf("unexpected group members: %+v", members)
+	}
+
+	run, err := db.GetConsistencyGroupRunForUpload(ctx, uploadIDs["eth-el"])
+	if err != nil {
+		t.Fatalf("GetConsistencyGroupRunForUpload failed: %v", err)
+	}
+	if run == nil || run.ID != runID || run.GroupName != "eth" {
+		t.Errorf("expected run %d for eth-el's upload, got %+v", runID, run)
+	}
+	run, err = db.GetConsistencyGroupRunForUpload(ctx, 999)
+	if err != nil {
+		t.Fatalf("GetConsistencyGroupRunForUpload failed: %v", err)
+	}
+	if run != nil {
+		t.Errorf("expected no run for an ungrouped upload, got %+v", run)
+	}
+}
+
+func TestSQLiteUploadObjects(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	id, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "completed",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	size := int64(1024)
+	checksum := "sha256:abc"
+	if err := db.RecordUploadObjects(ctx, id, []UploadObject{
+		{ObjectKey: "chunks/000002"},
+		{ObjectKey: "chunks/000001", SizeBytes: &size, Checksum: &checksum},
+	}); err != nil {
+		t.Fatalf("RecordUploadObjects failed: %v", err)
+	}
+
+	objects, err := db.GetUploadObjects(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUploadObjects failed: %v", err)
+	}
+	if len(objects) != 2 || objects[0].ObjectKey != "chunks/000001" || objects[1].ObjectKey != "chunks/000002" {
+		t.Fatalf("expected 2 objects ordered by key, got %+v", objects)
+	}
+	if objects[0].SizeBytes == nil || *objects[0].SizeBytes != size || objects[0].Checksum == nil || *objects[0].Checksum != checksum {
+		t.Errorf("expected size and checksum to round-trip, got %+v", objects[0])
+	}
+	if objects[1].SizeBytes != nil || objects[1].Checksum != nil {
+		t.Errorf("expected no size or checksum, got %+v", objects[1])
+	}
+
+	// Recording again replaces the listing
+	if err := db.RecordUploadObjects(ctx, id, []UploadObject{{ObjectKey: "chunks/000003"}}); err != nil {
+		t.Fatalf("RecordUploadObjects (replace) failed: %v", err)
+	}
+	objects, err = db.GetUploadObjects(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUploadObjects failed: %v", err)
+	}
+	if len(objects) != 1 || objects[0].ObjectKey != "chunks/000003" {
+		t.Errorf("expected the listing to be replaced, got %+v", objects)
+	}
+}
+
+func TestSQLiteRestoreVerification(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	id, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "completed",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	upload, err := db.GetUpload(ctx, id)
+	if err != nil || upload == nil {
+		t.Fatalf("GetUpload failed: %v", err)
+	}
+	if upload.RestoreVerification != nil || upload.RestoreVerifiedAt != nil {
+		t.Errorf("expected a new upload to be unverified, got %+v", upload)
+	}
+
+	verifiedAt := time.Now().UTC().Truncate(time.Second)
+	if err := db.SetUploadRestoreVerification(ctx, id, RestoreVerified, verifiedAt, "restored client reported block 21000000"); err != nil {
+		t.Fatalf("SetUploadRestoreVerification failed: %v", err)
+	}
+	upload, err = db.GetUpload(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUpload failed: %v", err)
+	}
+	if upload.RestoreVerification == nil || *upload.RestoreVerification != RestoreVerified ||
+		upload.RestoreVerifiedAt == nil || !upload.RestoreVerifiedAt.Equal(verifiedAt) ||
+		upload.RestoreVerificationMessage == nil || *upload.RestoreVerificationMessage != "restored client reported block 21000000" {
+		t.Errorf("expected the verification to round-trip, got %+v", upload)
+	}
+}
+
+func TestSQLiteUploadCheckpoints(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	checkpoint, err := db.GetUploadCheckpoint(ctx, "eth-1")
+	if err != nil || checkpoint != nil {
+		t.Fatalf("expected no checkpoint, got %+v, %v", checkpoint, err)
+	}
+
+	saved := UploadCheckpoint{NodeName: "eth-1", Engine: "s3", Session: `{"upload_id":"abc"}`, CreatedAt: now}
+	chunks := []CheckpointChunk{{ChunkNumber: 1, ETag: `"e1"`, SizeBytes: 1024, Checksum: "m1", UploadedAt: now}}
+	if err := db.SaveUploadCheckpoint(ctx, saved, chunks); err != nil {
+		t.Fatalf("SaveUploadCheckpoint failed: %v", err)
+	}
+	for _, number := range []int{3, 2, 2} {
+		chunk := CheckpointChunk{NodeName: "eth-1", ChunkNumber: number, ETag: fmt.Sprintf(`"e%d"`, number), SizeBytes: 1024, Checksum: "m", UploadedAt: now}
+		if err := db.AddCheckpointChunk(ctx, chunk); err != nil {
+			t.Fatalf("AddCheckpointChunk failed: %v", err)
+		}
+	}
+
+	checkpoint, err = db.GetUploadCheckpoint(ctx, "eth-1")
+	if err != nil || checkpoint == nil || checkpoint.Engine != "s3" || checkpoint.Session != saved.Session || !checkpoint.CreatedAt.Equal(now) {
+		t.Fatalf("expected the checkpoint to round-trip, got %+v, %v", checkpoint, err)
+	}
+	recorded, err := db.GetCheckpointChunks(ctx, "eth-1")
+	if err != nil || len(recorded) != 3 || recorded[0].ChunkNumber != 1 || recorded[1].ETag != `"e2"` || recorded[2].ChunkNumber != 3 {
+		t.Fatalf("expected chunks 1 to 3, got %+v, %v", recorded, err)
+	}
+
+	// Saving again replaces the chunks
+	saved.Session = `{"upload_id":"def"}`
+	if err := db.SaveUploadCheckpoint(ctx, saved, nil); err != nil {
+		t.Fatalf("SaveUploadCheckpoint failed: %v", err)
+	}
+	if recorded, _ := db.GetCheckpointChunks(ctx, "eth-1"); len(recorded) != 0 {
+		t.Errorf("expected the chunks to be replaced, got %+v", recorded)
+	}
+	if checkpoint, _ := db.GetUploadCheckpoint(ctx, "eth-1"); checkpoint.Session != saved.Session {
+		t.Errorf("expected the session to be replaced, got %q", checkpoint.Session)
+	}
+
+	// Chunks need their checkpoint
+	if err := db.AddCheckpointChunk(ctx, CheckpointChunk{NodeName: "eth-2", ChunkNumber: 1, UploadedAt: now}); err == nil {
+		t.Error("expected an error adding a chunk without a checkpoint")
+	}
+
+	if err := db.DeleteUploadCheckpoint(ctx, "eth-1"); err != nil {
+		t.Fatalf("DeleteUploadCheckpoint failed: %v", err)
+	}
+	if checkpoint, _ := db.GetUploadCheckpoint(ctx, "eth-1"); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be deleted, got %+v", checkpoint)
+	}
+	if err := db.DeleteUploadCheckpoint(ctx, "eth-1"); err != nil {
+		t.Errorf("expected deleting a missing checkpoint to succeed, got %v", err)
+	}
+}
+
+func TestSQLiteNotificationAttempts(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	uploadID := int64(7)
+	at := time.Now().UTC().Truncate(time.Second)
+	code := 429
+	message := "Discord webhook returned non-success status: 429"
+	attempts := []NotificationAttempt{
+		{UploadID: &uploadID, NodeName: "node-a", Event: "failure", NotificationType: "discord", TargetHash: "ab12", Status: NotificationFailed, StatusCode: &code, ErrorMessage: &message, AttemptedAt: at},
+		{UploadID: &uploadID, NodeName: "node-a", Event: "failure", NotificationType: "slack", TargetHash: "cd34", Status: NotificationSent, AttemptedAt: at.Add(time.Second)},
+		{NodeName: "node-a", Event: "stale", NotificationType: "discord", TargetHash: "ab12", Status: NotificationSent, AttemptedAt: at},
+	}
+	for _, attempt := range attempts {
+		if err := db.RecordNotificationAttempt(ctx, attempt); err != nil {
+			t.Fatalf("RecordNotificationAttempt failed: %v", err)
+		}
+	}
+
+	recorded, err := db.GetNotificationAttempts(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("GetNotificationAttempts failed: %v", err)
+	}
+	if len(recorded) != 2 || recorded[0].NotificationType != "discord" || recorded[1].NotificationType != "slack" {
+		t.Fatalf("expected the upload's 2 attempts oldest first, got %+v", recorded)
+	}
+	if recorded[0].Status != NotificationFailed || recorded[0].StatusCode == nil || *recorded[0].StatusCode != 429 ||
+		recorded[0].ErrorMessage == nil || *recorded[0].ErrorMessage != message || !recorded[0].AttemptedAt.Equal(at) {
+		t.Errorf("expected failed attempt to round-trip, got %+v", recorded[0])
+	}
+	if recorded[1].StatusCode != nil || recorded[1].ErrorMessage != nil {
+		t.Errorf("expected no status code or error, got %+v", recorded[1])
+	}
+}
+
+func TestSQLiteNotificationSnoozes(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	snoozes := []NotificationSnooze{
+		{NodeName: "node-a", SnoozedUntil: now.Add(time.Hour), Actor: "alice", CreatedAt: now},
+		{NodeName: "node-a", SnoozedUntil: now.Add(4 * time.Hour), Actor: "bob", CreatedAt: now},
+		{NodeName: "node-b", SnoozedUntil: now.Add(-time.Minute), Actor: "alice", CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, snooze := range snoozes {
+		if _, err := db.CreateNotificationSnooze(ctx, snooze); err != nil {
+			t.Fatalf("CreateNotificationSnooze failed: %v", err)
+		}
+	}
+
+	active, err := db.GetActiveNotificationSnooze(ctx, "node-a", now)
+	if err != nil {
+		t.Fatalf("GetActiveNotificationSnooze failed: %v", err)
+	}
+	if active == nil || active.Actor != "bob" || !active.SnoozedUntil.Equal(now.Add(4*time.Hour)) {
+		t.Fatalf("expected bob's 4h snooze, got %+v", active)
+	}
+
+	expired, err := db.GetActiveNotificationSnooze(ctx, "node-b", now)
+	if err != nil {
+		t.Fatalf("GetActiveNotificationSnooze failed: %v", err)
+	}
+	if expired != nil {
+		t.Errorf("expected no active snooze for node-b, got %+v", expired)
+	}
+
+	all, err := db.GetActiveNotificationSnoozes(ctx, now)
+	if err != nil {
+		t.Fatalf("GetActiveNotificationSnoozes failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Actor != "bob" {
+		t.Errorf("expected node-a's 2 snoozes, longest first, got %+v", all)
+	}
+}
+
+func TestSQLiteDaemonHeartbeat(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	heartbeat, err := db.GetDaemonHeartbeat(ctx, "host-a")
+	if err != nil {
+		t.Fatalf("GetDaemonHeartbeat failed: %v", err)
+	}
+	if heartbeat != nil {
+		t.Fatalf("expected no heartbeat, got %+v", heartbeat)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+	if err := db.RecordDaemonHeartbeat(ctx, "host-a", 100, at.Add(-time.Minute)); err != nil {
+		t.Fatalf("RecordDaemonHeartbeat failed: %v", err)
+	}
+	if err := db.RecordDaemonHeartbeat(ctx, "host-a", 200, at); err != nil {
+		t.Fatalf("RecordDaemonHeartbeat (update) failed: %v", err)
+	}
+
+	heartbeat, err = db.GetDaemonHeartbeat(ctx, "host-a")
+	if err != nil {
+		t.Fatalf("GetDaemonHeartbeat failed: %v", err)
+	}
+	if heartbeat == nil || heartbeat.PID != 200 || !heartbeat.HeartbeatAt.Equal(at) {
+		t.Errorf("expected latest heartbeat from pid 200 at %v, got %+v", at, heartbeat)
+	}
+
+	if err := db.ClearDaemonHeartbeat(ctx, "host-a"); err != nil {
+		t.Fatalf("ClearDaemonHeartbeat failed: %v", err)
+	}
+	heartbeat, err = db.GetDaemonHeartbeat(ctx, "host-a")
+	if err != nil {
+		t.Fatalf("GetDaemonHeartbeat failed: %v", err)
+	}
+	if heartbeat != nil {
+		t.Errorf("expected heartbeat cleared, got %+v", heartbeat)
+	}
+}
+
+func TestSQLiteRegisteredNodes(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	registeredAt := time.Now().UTC().Truncate(time.Second)
+	for _, name := range []string{"polygon-mainnet", "arbitrum-one"} {
+		if err := db.SaveRegisteredNode(ctx, RegisteredNode{
+			NodeName:     name,
+			Config:       "protocol: ethereum\n",
+			RegisteredAt: registeredAt,
+			UpdatedAt:    registeredAt,
+		}); err != nil {
+			t.Fatalf("SaveRegisteredNode failed: %v", err)
+		}
+	}
+
+	// Replacing the config keeps the registration time
+	updatedAt := registeredAt.Add(time.Hour)
+	if err := db.SaveRegisteredNode(ctx, RegisteredNode{
+		NodeName:     "polygon-mainnet",
+		Config:       "protocol: polygon\n",
+		RegisteredAt: updatedAt,
+		UpdatedAt:    updatedAt,
+	}); err != nil {
+		t.Fatalf("SaveRegisteredNode (update) failed: %v", err)
+	}
+
+	nodes, err := db.ListRegisteredNodes(ctx)
+	if err != nil {
+		t.Fatalf("ListRegisteredNodes failed: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].NodeName != "arbitrum-one" || nodes[1].NodeName != "polygon-mainnet" {
+		t.Fatalf("expected both nodes by name, got %+v", nodes)
+	}
+	if nodes[1].Config != "protocol: polygon\n" || !nodes[1].RegisteredAt.Equal(registeredAt) || !nodes[1].UpdatedAt.Equal(updatedAt) {
+		t.Errorf("expected updated config with original registration time, got %+v", nodes[1])
+	}
+
+	if err := db.DeleteRegisteredNode(ctx, "arbitrum-one"); err != nil {
+		t.Fatalf("DeleteRegisteredNode failed: %v", err)
+	}
+	if err := db.DeleteRegisteredNode(ctx, "arbitrum-one"); err != nil {
+		t.Fatalf("DeleteRegisteredNode of a missing node failed: %v", err)
+	}
+	nodes, err = db.ListRegisteredNodes(ctx)
+	if err != nil {
+		t.Fatalf("ListRegisteredNodes failed: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].NodeName != "polygon-mainnet" {
+		t.Errorf("expected only polygon-mainnet left, got %+v", nodes)
+	}
+}
+
+func TestSQLiteAssignedNodes(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for name, host := range map[string]string{"eth-a": "host-a", "eth-b": "host-b", "eth-any": ""} {
+		if err := db.SaveRegisteredNode(ctx, RegisteredNode{NodeName: name, Host: host, Config: "protocol: ethereum\n", RegisteredAt: now, UpdatedAt: now}); err != nil {
+			t.Fatalf("SaveRegisteredNode failed: %v", err)
+		}
+	}
+
+	nodes, err := db.ListAssignedNodes(ctx, "host-a")
+	if err != nil {
+		t.Fatalf("ListAssignedNodes failed: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].NodeName != "eth-a" || nodes[0].Host != "host-a" || nodes[1].NodeName != "eth-any" {
+		t.Errorf("expected eth-a and the unassigned eth-any, got %+v", nodes)
+	}
+
+	// Reassigning moves the node to the other host
+	if err := db.SaveRegisteredNode(ctx, RegisteredNode{NodeName: "eth-a", Host: "host-b", Config: "protocol: ethereum\n", RegisteredAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("SaveRegisteredNode (reassign) failed: %v", err)
+	}
+	node, err := db.GetRegisteredNode(ctx, "eth-a")
+	if err != nil || node == nil || node.Host != "host-b" {
+		t.Fatalf("expected eth-a on host-b, got %+v, %v", node, err)
+	}
+	if nodes, err = db.ListAssignedNodes(ctx, "host-a"); err != nil || len(nodes) != 1 {
+		t.Errorf("expected only eth-any for host-a, got %+v, %v", nodes, err)
+	}
+
+	if node, err := db.GetRegisteredNode(ctx, "missing"); err != nil || node != nil {
+		t.Errorf("expected nil for a missing node, got %+v, %v", node, err)
+	}
+}
+
+func TestSQLiteThrottleEvents(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	uploadID, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+	cpu := 93.5
+	id, err := db.CreateThrottleEvent(ctx, ThrottleEvent{UploadID: uploadID, NodeName: "ethereum-mainnet", Action: "throttle", Reason: "cpu 93.5% > 90%", CPUPercent: &cpu, StartedAt: at})
+	if err != nil {
+		t.Fatalf("CreateThrottleEvent failed: %v", err)
+	}
+	if _, err := db.CreateThrottleEvent(ctx, ThrottleEvent{UploadID: uploadID, NodeName: "ethereum-mainnet", Action: "throttle", Reason: "cpu 91.0% > 90%", StartedAt: at.Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateThrottleEvent failed: %v", err)
+	}
+	if err := db.EndThrottleEvent(ctx, id, at.Add(time.Minute)); err != nil {
+		t.Fatalf("EndThrottleEvent failed: %v", err)
+	}
+
+	events, err := db.GetThrottleEvents(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("GetThrottleEvents failed: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != id || events[1].EndedAt != nil {
+		t.Fatalf("expected 2 events oldest first, the second still in effect, got %+v", events)
+	}
+	first := events[0]
+	if first.Reason != "cpu 93.5% > 90%" || first.CPUPercent == nil || *first.CPUPercent != cpu || first.MemoryPercent != nil ||
+		!first.StartedAt.Equal(at) || first.EndedAt == nil || !first.EndedAt.Equal(at.Add(time.Minute)) {
+		t.Errorf("expected the ended event to round-trip, got %+v", first)
+	}
+
+	open, err := db.GetOpenThrottleEvents(ctx)
+	if err != nil || len(open) != 1 || open[0].ID != events[1].ID {
+		t.Errorf("expected the second event to be open, got %+v, %v", open, err)
+	}
+}
+
+func TestSQLiteLeaderLockUnsupported(t *testing.T) {
+	db := newTestSQLiteDB(t)
+
+	lock, err := db.TryAcquireLeaderLock(context.Background(), "snapperd")
+	if !errors.Is(err, ErrLeaderLockUnsupported) {
+		t.Errorf("expected ErrLeaderLockUnsupported, got lock %v, error %v", lock, err)
+	}
+}
+
+func TestNewScratch_SQLite(t *testing.T) {
+	ctx := context.Background()
+
+	db, cleanup, err := NewScratch(ctx, Config{Driver: DriverSQLite})
+	if err != nil {
+		t.Fatalf("NewScratch failed: %v", err)
+	}
+
+	if _, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "test-node",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "smoke",
+		ProtocolData: JSONB{},
+	}); err != nil {
+		t.Fatalf("CreateUpload on scratch database failed: %v", err)
+	}
+
+	if err := cleanup(); err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+}
+
+func TestSQLiteStatusOutputs(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	uploadID, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+	checks := []struct {
+		state string
+		want  bool
+	}{
+		{state: "Running", want: true},
+		{state: "Running", want: false},
+		{state: "Finished with exit code 1", want: true},
+		{state: "Finished with exit code 1", want: false},
+	}
+	for i, check := range checks {
+		recorded, err := db.RecordStatusOutput(ctx, uploadID, check.state, fmt.Sprintf("output %d", i), at.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("RecordStatusOutput failed: %v", err)
+		}
+		if recorded != check.want {
+			t.Errorf("check %d: expected recorded %v, got %v", i, check.want, recorded)
+		}
+	}
+
+	outputs, err := db.GetStatusOutputs(ctx, uploadID)
+	if err != nil {
+		t.Fatalf("GetStatusOutputs failed: %v", err)
+	}
+	if len(outputs) != 2 || outputs[0].State != "Running" || outputs[0].RawOutput != "output 0" || !outputs[0].RecordedAt.Equal(at) ||
+		outputs[1].State != "Finished with exit code 1" || outputs[1].RawOutput != "output 2" {
+		t.Errorf("expected one output per state change, got %+v", outputs)
+	}
+}
+
+func TestSQLiteUploadSize(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	id, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+	if err := db.SetUploadSize(ctx, id, 3<<40); err != nil {
+		t.Fatalf("SetUploadSize failed: %v", err)
+	}
+	if err := db.UpdateUploadCompletion(ctx, id, time.Now(), "completed", nil, nil); err != nil {
+		t.Fatalf("UpdateUploadCompletion failed: %v", err)
+	}
+
+	upload, err := db.GetLatestCompletedUploadForNode(ctx, "ethereum-mainnet")
+	if err != nil || upload == nil {
+		t.Fatalf("GetLatestCompletedUploadForNode failed: %v", err)
+	}
+	if upload.SizeBytes == nil || *upload.SizeBytes != 3<<40 {
+		t.Errorf("expected a 3TiB snapshot, got %v", upload.SizeBytes)
+	}
+}
+
+func TestSQLiteUploadAgent(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	agent := "snap-host-1"
+	if _, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+		Agent:        &agent,
+	}); err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	upload, err := db.GetRunningUploadForNode(ctx, "ethereum-mainnet")
+	if err != nil || upload == nil {
+		t.Fatalf("GetRunningUploadForNode failed: %v", err)
+	}
+	if upload.Agent == nil || *upload.Agent != agent {
+		t.Errorf("expected agent %s, got %v", agent, upload.Agent)
+	}
+}
+
+func TestSQLiteUploadTraceParent(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	uploadID, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+		TraceParent:  &traceParent,
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	upload, err := db.GetUpload(ctx, uploadID)
+	if err != nil || upload == nil {
+		t.Fatalf("GetUpload failed: %v", err)
+	}
+	if upload.TraceParent == nil || *upload.TraceParent != traceParent {
+		t.Errorf("expected trace parent %s, got %v", traceParent, upload.TraceParent)
+	}
+}
+
+func TestSQLiteUploadNotifications(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	uploadID, err := db.CreateUpload(ctx, Upload{
+		NodeName:     "ethereum-mainnet",
+		Protocol:     "ethereum",
+		StartedAt:    time.Now(),
+		Status:       "running",
+		TriggerType:  "scheduled",
+		ProtocolData: JSONB{},
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+	notification := UploadNotification{
+		UploadID:  uploadID,
+		Key:       "completion",
+		NodeName:  "ethereum-mainnet",
+		Event:     "complete",
+		Message:   "Upload completed successfully",
+		Details:   JSONB{"duration": "3h0m0s"},
+		CreatedAt: at,
+	}
+	if recorded, err := db.RecordUploadNotification(ctx, notification); err != nil || !recorded {
+		t.Fatalf("RecordUploadNotification() = %v, %v, want true", recorded, err)
+	}
+	if recorded, err := db.RecordUploadNotification(ctx, notification); err != nil || recorded {
+		t.Fatalf("expected the second record to be refused, got %v, %v", recorded, err)
+	}
+
+	pending, err := db.GetPendingUploadNotifications(ctx, at.Add(time.Second))
+	if err != nil {
+		t.Fatalf("GetPendingUploadNotifications failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Key != "completion" || pending[0].Details["duration"] != "3h0m0s" || pending[0].SentAt != nil {
+		t.Fatalf("expected the pending notification, got %+v", pending)
+	}
+	if pending, _ := db.GetPendingUploadNotifications(ctx, at); len(pending) != 0 {
+		t.Errorf("expected notifications recorded since to be excluded, got %+v", pending)
+	}
+
+	if err := db.MarkUploadNotificationSent(ctx, uploadID, "completion", at); err != nil {
+		t.Fatalf("MarkUploadNotificationSent failed: %v", err)
+	}
+	if pending, _ := db.GetPendingUploadNotifications(ctx, at.Add(time.Second)); len(pending) != 0 {
+		t.Errorf("expected no pending notifications once sent, got %+v", pending)
+	}
+}
+
+func TestSQLiteRunningUploadPages(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	// Stuck rows sharing a start time with a live upload, and a finished upload
+	startedAt := time.Now().Add(-time.Hour)
+	var ids []int64
+	for _, nodeName := range []string{"eth-node", "eth-node", "arb-node", "sol-node", "done-node"} {
+		var tenant string
+		if nodeName == "arb-node" {
+			tenant = "team-arb"
+		}
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     nodeName,
+			Protocol:     "ethereum",
+			StartedAt:    startedAt,
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+			Tenant:       tenant,
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := db.UpdateUploadCompletion(ctx, ids[4], time.Now(), "completed", nil, nil); err != nil {
+		t.Fatalf("UpdateUploadCompletion failed: %v", err)
+	}
+
+	var got []int64
+	page := UploadPage{Limit: 2}
+	for {
+		uploads, err := db.ListRunningUploads(ctx, page)
+		if err != nil {
+			t.Fatalf("ListRunningUploads failed: %v", err)
+		}
+		if len(uploads) > page.Limit {
+			t.Fatalf("expected at most %d uploads, got %d", page.Limit, len(uploads))
+		}
+		for _, u := range uploads {
+			got = append(got, u.ID)
+		}
+		if len(uploads) < page.Limit {
+			break
+		}
+		page.AfterID = uploads[len(uploads)-1].ID
+	}
+	if fmt.Sprint(got) != fmt.Sprint(ids[:4]) {
+		t.Errorf("expected running uploads %v in ID order, got %v", ids[:4], got)
+	}
+
+	tenantUploads, err := db.ListRunningUploads(ctx, UploadPage{Tenant: "team-arb", Limit: 2})
+	if err != nil {
+		t.Fatalf("ListRunningUploads failed: %v", err)
+	}
+	if len(tenantUploads) != 1 || tenantUploads[0].ID != ids[2] || tenantUploads[0].Tenant != "team-arb" {
+		t.Errorf("expected the tenant's running upload %d, got %+v", ids[2], tenantUploads)
+	}
+
+	count, err := db.CountRunningUploads(ctx)
+	if err != nil || count != 4 {
+		t.Errorf("expected 4 running uploads, got %d (%v)", count, err)
+	}
+
+	nodes, err := db.GetRunningUploadNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetRunningUploadNodes failed: %v", err)
+	}
+	if fmt.Sprint(nodes) != "[arb-node eth-node sol-node]" {
+		t.Errorf("expected each node with a running upload once, got %v", nodes)
+	}
+
+	// Ties on started_at are broken by ID, newest first
+	running, err := db.GetRunningUploads(ctx)
+	if err != nil || len(running) != 4 || running[0].ID != ids[3] || running[3].ID != ids[0] {
+		t.Errorf("expected running uploads newest ID first, got %+v (%v)", running, err)
+	}
+}
+
+func TestSQLiteJobStates(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	nextRunAt := time.Now().Add(5 * time.Minute).UTC().Truncate(time.Second)
+	if err := db.SaveJobSchedule(ctx, "snap-1", "upload_monitor", "0 */5 * * * *", &nextRunAt); err != nil {
+		t.Fatalf("SaveJobSchedule failed: %v", err)
+	}
+
+	startedAt := time.Now().UTC().Truncate(time.Second)
+	result, message, durationMs := "failed", "database is locked", int64(1500)
+	err := db.RecordJobRun(ctx, JobState{
+		Host:           "snap-1",
+		JobName:        "upload_monitor",
+		Schedule:       "0 */5 * * * *",
+		LastRunAt:      &startedAt,
+		LastResult:     &result,
+		LastError:      &message,
+		LastDurationMs: &durationMs,
+		NextRunAt:      &nextRunAt,
+	})
+	if err != nil {
+		t.Fatalf("RecordJobRun failed: %v", err)
+	}
+	if err := db.SaveJobSchedule(ctx, "snap-2", "upload_monitor", "0 */5 * * * *", nil); err != nil {
+		t.Fatalf("SaveJobSchedule failed: %v", err)
+	}
+
+	// Saving the schedule again on restart keeps the last run
+	later := nextRunAt.Add(5 * time.Minute)
+	if err := db.SaveJobSchedule(ctx, "snap-1", "upload_monitor", "0 */5 * * * *", &later); err != nil {
+		t.Fatalf("SaveJobSchedule failed: %v", err)
+	}
+	states, err := db.GetJobStates(ctx, "snap-1")
+	if err != nil {
+		t.Fatalf("GetJobStates failed: %v", err)
+	}
+	if len(states) != 1 {
+		t.Fatalf("expected one job on snap-1, got %+v", states)
+	}
+	state := states[0]
+	if state.LastRunAt == nil || !state.LastRunAt.Equal(startedAt) || *state.LastResult != "failed" || *state.LastError != message ||
+		*state.LastDurationMs != 1500 || !state.NextRunAt.Equal(later) {
+		t.Errorf("unexpected job state: %+v", state)
+	}
+
+	all, err := db.GetJobStates(ctx, "")
+	if err != nil || len(all) != 2 || all[0].Host != "snap-1" || all[1].Host != "snap-2" || all[1].LastRunAt != nil {
+		t.Errorf("expected both hosts' jobs, got %+v (%v)", all, err)
+	}
+
+	if err := db.DeleteJobState(ctx, "snap-1", "upload_monitor"); err != nil {
+		t.Fatalf("DeleteJobState failed: %v", err)
+	}
+	if states, _ := db.GetJobStates(ctx, "snap-1"); len(states) != 0 {
+		t.Errorf("expected the job state deleted, got %+v", states)
+	}
+}
+
+func TestSQLiteSnapshotsByBlock(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	// Completed snapshots at increasing blocks, a running upload above them, a snapshot of
+	// another node and snapshots without a numeric block
+	createUpload := func(nodeName, status string, protocolData JSONB) int64 {
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     nodeName,
+			Protocol:     "ethereum",
+			StartedAt:    time.Now().Add(-time.Hour),
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: protocolData,
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		if status != "running" {
+			if err := db.UpdateUploadCompletion(ctx, id, time.Now(), status, nil, nil); err != nil {
+				t.Fatalf("UpdateUploadCompletion failed: %v", err)
+			}
+		}
+		return id
+	}
+	first := createUpload("eth-node", "completed", JSONB{"latest_block": 1000})
+	second := createUpload("eth-node", "completed", JSONB{"latest_block": 2000})
+	createUpload("eth-node", "failed", JSONB{"latest_block": 2500})
+	createUpload("eth-node", "running", JSONB{"latest_block": 3000})
+	createUpload("eth-node", "completed", JSONB{"latest_block": "0xbb8"})
+	createUpload("eth-node", "completed", JSONB{})
+	createUpload("arb-node", "completed", JSONB{"latest_block": 9000})
+
+	tests := []struct {
+		name   string
+		lookup func(ctx context.Context, nodeName string, block int64) (*Upload, error)
+		block  int64
+		want   int64 // 0 = none
+	}{
+		{name: "at or above, below all", lookup: db.GetSnapshotAtOrAboveBlock, block: 500, want: second},
+		{name: "at or above, exact", lookup: db.GetSnapshotAtOrAboveBlock, block: 2000, want: second},
+		{name: "at or above, past the latest", lookup: db.GetSnapshotAtOrAboveBlock, block: 2001},
+		{name: "at or below, between", lookup: db.GetSnapshotAtOrBelowBlock, block: 1999, want: first},
+		{name: "at or below, above all", lookup: db.GetSnapshotAtOrBelowBlock, block: 5000, want: second},
+		{name: "at or below, before the first", lookup: db.GetSnapshotAtOrBelowBlock, block: 999},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload, err := tt.lookup(ctx, "eth-node", tt.block)
+			if err != nil {
+				t.Fatalf("lookup failed: %v", err)
+			}
+			var got int64
+			if upload != nil {
+				got = upload.ID
+			}
+			if got != tt.want {
+				t.Errorf("expected upload %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	// The lookup uses the expression index rather than scanning the node's uploads
+	value, isNumber := db.driver.ProtocolDataNumber("latest_block")
+	rows, err := db.conn.QueryxContext(ctx, `EXPLAIN QUERY PLAN SELECT id FROM uploads
+		WHERE node_name = ? AND status = 'completed' AND `+isNumber+` AND `+value+` >= ?
+		ORDER BY `+value+` DESC LIMIT 1`, "eth-node", 1000)
+	if err != nil {
+		t.Fatalf("EXPLAIN failed: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		columns, err := rows.SliceScan()
+		if err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		plan = append(plan, fmt.Sprint(columns[len(columns)-1]))
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_uploads_latest_block") {
+		t.Errorf("expected the query to use idx_uploads_latest_block, got plan %v", plan)
+	}
+
+	// History can be narrowed to a block range
+	uploads, err := db.ListUploads(ctx, UploadFilter{NodeName: "eth-node", MinBlock: 1500, MaxBlock: 2600})
+	if err != nil {
+		t.Fatalf("ListUploads failed: %v", err)
+	}
+	if len(uploads) != 2 {
+		t.Errorf("expected the completed and failed uploads between blocks 1500 and 2600, got %d", len(uploads))
+	}
+}
+
+func TestSQLiteNodeActivityAndPurge(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// A node with history from before activity was tracked, a node removed from this
+	// host's configuration, and a node another host runs
+	createUpload := func(nodeName string) int64 {
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     nodeName,
+			Protocol:     "ethereum",
+			StartedAt:    now.Add(-time.Hour),
+			Status:       "running",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		return id
+	}
+	oldID := createUpload("old-node")
+	if err := db.UpdateUploadCompletion(ctx, oldID, now, "completed", nil, nil); err != nil {
+		t.Fatalf("UpdateUploadCompletion failed: %v", err)
+	}
+	if err := db.MarkNodesActive(ctx, "snap-1", []string{"eth-node", "arb-node"}, now.Add(-24*time.Hour)); err != nil {
+		t.Fatalf("MarkNodesActive failed: %v", err)
+	}
+	if err := db.MarkNodesActive(ctx, "snap-2", []string{"sol-node"}, now.Add(-24*time.Hour)); err != nil {
+		t.Fatalf("MarkNodesActive failed: %v", err)
+	}
+
+	deactivated, err := db.DeactivateMissingNodes(ctx, "snap-1", []string{"eth-node"}, now)
+	if err != nil {
+		t.Fatalf("DeactivateMissingNodes failed: %v", err)
+	}
+	if fmt.Sprint(deactivated) != "[arb-node old-node]" {
+		t.Errorf("expected arb-node and old-node deactivated, got %v", deactivated)
+	}
+	// Deactivating again reports nothing new
+	if deactivated, err := db.DeactivateMissingNodes(ctx, "snap-1", []string{"eth-node"}, now.Add(time.Hour)); err != nil || len(deactivated) != 0 {
+		t.Errorf("expected no newly deactivated nodes, got %v (%v)", deactivated, err)
+	}
+
+	inactive, err := db.GetInactiveNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetInactiveNodes failed: %v", err)
+	}
+	if len(inactive) != 2 || inactive[0].InactiveSince == nil || !inactive[0].InactiveSince.Equal(now) {
+		t.Fatalf("expected two nodes inactive since %v, got %+v", now, inactive)
+	}
+
+	// Configuring a node again reactivates it; deregistering marks it inactive
+	if err := db.MarkNodesActive(ctx, "snap-1", []string{"arb-node"}, now); err != nil {
+		t.Fatalf("MarkNodesActive failed: %v", err)
+	}
+	if activity, err := db.GetNodeActivity(ctx, "arb-node"); err != nil || activity == nil || activity.InactiveSince != nil {
+		t.Errorf("expected arb-node active, got %+v (%v)", activity, err)
+	}
+	if err := db.MarkNodeInactive(ctx, "sol-node", now); err != nil {
+		t.Fatalf("MarkNodeInactive failed: %v", err)
+	}
+	if activity, err := db.GetNodeActivity(ctx, "sol-node"); err != nil || activity == nil || activity.InactiveSince == nil {
+		t.Errorf("expected sol-node inactive, got %+v (%v)", activity, err)
+	}
+
+	// Purging deletes the node's rows and leaves other nodes alone
+	if err := db.RecordUploadObjects(ctx, oldID, []UploadObject{{ObjectKey: "data/0001"}}); err != nil {
+		t.Fatalf("RecordUploadObjects failed: %v", err)
+	}
+	if err := db.SaveScheduleState(ctx, ScheduleState{NodeName: "old-node", LastRunAt: &now}); err != nil {
+		t.Fatalf("SaveScheduleState failed: %v", err)
+	}
+	if _, err := db.CreateUploadRequest(ctx, "old-node", "manual", 0, nil); err != nil {
+		t.Fatalf("CreateUploadRequest failed: %v", err)
+	}
+	ethID := createUpload("eth-node")
+
+	deleted, err := db.PurgeNode(ctx, "old-node")
+	if err != nil {
+		t.Fatalf("PurgeNode failed: %v", err)
+	}
+	want := map[string]int64{"uploads": 1, "upload_objects": 1, "schedule_state": 1, "upload_requests": 1, "node_activity": 1}
+	if fmt.Sprint(deleted) != fmt.Sprint(want) {
+		t.Errorf("expected deleted rows %v, got %v", want, deleted)
+	}
+	if u, err := db.GetUpload(ctx, oldID); err != nil || u != nil {
+		t.Errorf("expected old-node's upload deleted, got %+v (%v)", u, err)
+	}
+	if activity, err := db.GetNodeActivity(ctx, "old-node"); err != nil || activity != nil {
+		t.Errorf("expected old-node's activity deleted, got %+v (%v)", activity, err)
+	}
+	if u, err := db.GetUpload(ctx, ethID); err != nil || u == nil {
+		t.Errorf("expected eth-node's upload kept, got %+v (%v)", u, err)
+	}
+
+	// A node with a running upload is not purged
+	if _, err := db.PurgeNode(ctx, "eth-node"); !errors.Is(err, ErrNodeUploadRunning) {
+		t.Errorf("expected ErrNodeUploadRunning, got %v", err)
+	}
+}
+
+func TestSQLiteNodePauses(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	if pause, err := db.GetNodePause(ctx, "eth-node"); err != nil || pause != nil {
+		t.Fatalf("expected eth-node not paused, got %+v (%v)", pause, err)
+	}
+
+	reason := "disk replacement"
+	if err := db.PauseNode(ctx, NodePause{NodeName: "eth-node", Actor: "alice", PausedAt: now}); err != nil {
+		t.Fatalf("PauseNode failed: %v", err)
+	}
+	// Pausing again replaces the pause
+	if err := db.PauseNode(ctx, NodePause{NodeName: "eth-node", Actor: "bob", Reason: &reason, PausedAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("PauseNode failed: %v", err)
+	}
+	if err := db.PauseNode(ctx, NodePause{NodeName: "arb-node", Actor: "alice", PausedAt: now}); err != nil {
+		t.Fatalf("PauseNode failed: %v", err)
+	}
+
+	pause, err := db.GetNodePause(ctx, "eth-node")
+	if err != nil {
+		t.Fatalf("GetNodePause failed: %v", err)
+	}
+	if pause == nil || pause.Actor != "bob" || pause.Reason == nil || *pause.Reason != reason || !pause.PausedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected eth-node paused by bob for %q, got %+v", reason, pause)
+	}
+
+	pauses, err := db.GetNodePauses(ctx)
+	if err != nil {
+		t.Fatalf("GetNodePauses failed: %v", err)
+	}
+	if len(pauses) != 2 || pauses[0].NodeName != "arb-node" || pauses[1].NodeName != "eth-node" {
+		t.Errorf("expected arb-node and eth-node paused, got %+v", pauses)
+	}
+
+	if err := db.ResumeNode(ctx, "eth-node"); err != nil {
+		t.Fatalf("ResumeNode failed: %v", err)
+	}
+	if pause, err := db.GetNodePause(ctx, "eth-node"); err != nil || pause != nil {
+		t.Errorf("expected eth-node resumed, got %+v (%v)", pause, err)
+	}
+	// Resuming a node that is not paused does nothing
+	if err := db.ResumeNode(ctx, "eth-node"); err != nil {
+		t.Errorf("ResumeNode of a running node failed: %v", err)
+	}
+}
+
+func TestSQLiteNodeActions(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	start := time.Now().UTC().Truncate(time.Second)
+	uploadID := int64(7)
+	actions := []NodeAction{
+		{NodeName: "eth-node", UploadID: &uploadID, Action: NodeActionTimedOut, Message: "exceeded max duration of 6h0m0s", CreatedAt: start},
+		{NodeName: "eth-node", UploadID: &uploadID, Action: NodeActionGuardrailApplied, Message: "throttle: cpu 95.0% > 90%", Details: JSONB{"reason": "cpu 95.0% > 90%"}, CreatedAt: start.Add(time.Minute)},
+		{NodeName: "eth-node", Action: NodeActionNotificationResent, Message: "completion notification", CreatedAt: start.Add(2 * time.Minute)},
+		{NodeName: "arb-node", Action: NodeActionRequestCoalesced, Message: "request 3 joined upload 9", CreatedAt: start},
+	}
+	for _, action := range actions {
+		if err := db.RecordNodeAction(ctx, action); err != nil {
+			t.Fatalf("RecordNodeAction failed: %v", err)
+		}
+	}
+
+	got, err := db.GetNodeActions(ctx, "eth-node", time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("GetNodeActions failed: %v", err)
+	}
+	if len(got) != 3 || got[0].Action != NodeActionNotificationResent || got[2].Action != NodeActionTimedOut {
+		t.Fatalf("expected eth-node's 3 actions, most recent first, got %+v", got)
+	}
+	if got[2].UploadID == nil || *got[2].UploadID != uploadID || got[0].UploadID != nil {
+		t.Errorf("expected upload IDs to round-trip, got %v and %v", got[2].UploadID, got[0].UploadID)
+	}
+	if got[1].Details["reason"] != "cpu 95.0% > 90%" {
+		t.Errorf("expected details to round-trip, got %v", got[1].Details)
+	}
+
+	got, err = db.GetNodeActions(ctx, "eth-node", start.Add(time.Minute), 1)
+	if err != nil {
+		t.Fatalf("GetNodeActions failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Action != NodeActionNotificationResent {
+		t.Errorf("expected only the latest action since the cutoff, got %+v", got)
+	}
+
+	counts, err := db.PurgeNode(ctx, "eth-node")
+	if err != nil {
+		t.Fatalf("PurgeNode failed: %v", err)
+	}
+	if counts["node_actions"] != 3 {
+		t.Errorf("expected 3 node actions purged, got %v", counts)
+	}
+}
+
+func TestSQLiteAuditLog(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	start := time.Now().UTC().Truncate(time.Second)
+	node := "eth-node"
+	uploadID := int64(7)
+	entries := []AuditEntry{
+		{Actor: "alice", Source: AuditSourceCLI, Action: AuditNodePaused, NodeName: &node, Message: "paused: disk replacement", CreatedAt: start},
+		{Actor: "alice", Source: AuditSourceCLI, Action: AuditUploadCancelled, NodeName: &node, UploadID: &uploadID, Message: "cancelled by operator", CreatedAt: start.Add(time.Minute)},
+		{Actor: "provisioner", Source: AuditSourceNodeAPI, Action: AuditNodeRegistered, NodeName: &node, Message: "registered", Details: JSONB{"remote": "10.0.0.5:4312"}, CreatedAt: start.Add(2 * time.Minute)},
+		{Actor: "host-1", Source: AuditSourceDaemon, Action: AuditConfigLoaded, Message: "/etc/snapperd/config.yaml", CreatedAt: start.Add(3 * time.Minute)},
+	}
+	for _, entry := range entries {
+		if err := db.RecordAudit(ctx, entry); err != nil {
+			t.Fatalf("RecordAudit failed: %v", err)
+		}
+	}
+
+	got, err := db.ListAuditLog(ctx, AuditFilter{})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(got) != 4 || got[0].Action != AuditConfigLoaded || got[3].Action != AuditNodePaused {
+		t.Fatalf("expected 4 entries, most recent first, got %+v", got)
+	}
+	if got[0].NodeName != nil || got[2].UploadID == nil || *got[2].UploadID != uploadID {
+		t.Errorf("expected node names and upload IDs to round-trip, got %v and %v", got[0].NodeName, got[2].UploadID)
+	}
+	if got[1].Details["remote"] != "10.0.0.5:4312" {
+		t.Errorf("expected details to round-trip, got %v", got[1].Details)
+	}
+
+	got, err = db.ListAuditLog(ctx, AuditFilter{NodeName: node, Actor: "alice", Since: start.Add(time.Minute), Limit: 5})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Action != AuditUploadCancelled {
+		t.Errorf("expected alice's cancel on eth-node, got %+v", got)
+	}
+
+	got, err = db.ListAuditLog(ctx, AuditFilter{Source: AuditSourceCLI, Until: start.Add(time.Minute), Action: AuditNodePaused})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Message != "paused: disk replacement" {
+		t.Errorf("expected the pause, got %+v", got)
+	}
+
+	// The audit log outlives a purged node
+	if _, err := db.PurgeNode(ctx, node); err != nil {
+		t.Fatalf("PurgeNode failed: %v", err)
+	}
+	if got, err := db.ListAuditLog(ctx, AuditFilter{NodeName: node}); err != nil || len(got) != 3 {
+		t.Errorf("expected the node's audit entries to be kept, got %d (%v)", len(got), err)
+	}
+}
+
+func TestSQLiteUploadLogs(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	createUpload := func(nodeName string) int64 {
+		id, err := db.CreateUpload(ctx, Upload{
+			NodeName:     nodeName,
+			Protocol:     "ethereum",
+			StartedAt:    now,
+			Status:       "failed",
+			TriggerType:  "scheduled",
+			ProtocolData: JSONB{},
+		})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		return id
+	}
+	firstID, secondID, otherID := createUpload("eth-node"), createUpload("eth-node"), createUpload("sol-node")
+
+	if lines, err := db.GetLatestUploadLogs(ctx, "eth-node"); err != nil || len(lines) != 0 {
+		t.Errorf("expected no logs before any were recorded, got %v (%v)", lines, err)
+	}
+
+	// Only the last MaxUploadLogLines lines are kept, numbered from 1
+	var lines []UploadLogLine
+	for i := 0; i < MaxUploadLogLines+5; i++ {
+		lines = append(lines, UploadLogLine{Stream: "stdout", Line: fmt.Sprintf("line %d", i), RecordedAt: now})
+	}
+	lines = append(lines, UploadLogLine{Stream: "stderr", Line: "error: no space left on device", RecordedAt: now})
+	if err := db.RecordUploadLogs(ctx, firstID, lines); err != nil {
+		t.Fatalf("RecordUploadLogs failed: %v", err)
+	}
+	got, err := db.GetUploadLogs(ctx, firstID)
+	if err != nil {
+		t.Fatalf("GetUploadLogs failed: %v", err)
+	}
+	if len(got) != MaxUploadLogLines || got[0].LineNo != 1 || got[0].Line != "line 6" {
+		t.Fatalf("expected the last %d lines from line 6, got %d from %+v", MaxUploadLogLines, len(got), got[0])
+	}
+	if last := got[len(got)-1]; last.Stream != "stderr" || last.LineNo != MaxUploadLogLines || !last.RecordedAt.Equal(now) {
+		t.Errorf("expected the stderr line last, got %+v", last)
+	}
+
+	// Recording again replaces an upload's log
+	if err := db.RecordUploadLogs(ctx, firstID, []UploadLogLine{{Stream: "stdout", Line: "retried"}}); err != nil {
+		t.Fatalf("RecordUploadLogs failed: %v", err)
+	}
+	if got, err := db.GetUploadLogs(ctx, firstID); err != nil || len(got) != 1 || got[0].Line != "retried" {
+		t.Errorf("expected the log replaced, got %+v (%v)", got, err)
+	}
+
+	// The latest upload with a log is the node's, not another node's
+	if err := db.RecordUploadLogs(ctx, otherID, []UploadLogLine{{Stream: "stdout", Line: "sol"}}); err != nil {
+		t.Fatalf("RecordUploadLogs failed: %v", err)
+	}
+	if got, err := db.GetLatestUploadLogs(ctx, "eth-node"); err != nil || len(got) != 1 || got[0].UploadID != firstID {
+		t.Errorf("expected the first upload's log, got %+v (%v)", got, err)
+	}
+	if err := db.RecordUploadLogs(ctx, secondID, []UploadLogLine{{Stream: "stdout", Line: "second"}}); err != nil {
+		t.Fatalf("RecordUploadLogs failed: %v", err)
+	}
+	if got, err := db.GetLatestUploadLogs(ctx, "eth-node"); err != nil || len(got) != 1 || got[0].UploadID != secondID {
+		t.Errorf("expected the second upload's log, got %+v (%v)", got, err)
+	}
+
+	// Purging a node deletes its uploads' logs
+	deleted, err := db.PurgeNode(ctx, "eth-node")
+	if err != nil {
+		t.Fatalf("PurgeNode failed: %v", err)
+	}
+	if deleted["upload_logs"] != 2 {
+		t.Errorf("expected 2 log lines deleted, got %v", deleted)
+	}
+	if got, err := db.GetUploadLogs(ctx, otherID); err != nil || len(got) != 1 {
+		t.Errorf("expected sol-node's log kept, got %+v (%v)", got, err)
+	}
+}
+
+func TestSQLiteCatalog(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	block := func(n int64) *int64 { return &n }
+	location := "s3://snapshots/eth-archive-1/20261001.tar.gz"
+
+	if entries, err := db.ListCatalog(ctx, CatalogFilter{}); err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty catalog, got %v (%v)", entries, err)
+	}
+
+	upsert := func(entry CatalogEntry) bool {
+		t.Helper()
+		written, err := db.UpsertCatalogEntry(ctx, entry)
+		if err != nil {
+			t.Fatalf("UpsertCatalogEntry failed: %v", err)
+		}
+		return written
+	}
+	archive := CatalogEntry{Protocol: "ethereum", Network: "mainnet", NodeType: "archive", NodeName: "eth-archive-1", Tenant: "team-a", UploadID: 1, LatestBlock: block(20000000), LatestSlot: block(9000000), SizeBytes: block(1 << 40), Location: &location, CompletedAt: now}
+	if !upsert(archive) {
+		t.Error("expected the first snapshot written")
+	}
+	if !upsert(CatalogEntry{Protocol: "ethereum", Network: "mainnet", NodeType: "full", NodeName: "eth-full-1", UploadID: 2, LatestBlock: block(20000100), CompletedAt: now}) {
+		t.Error("expected another node type written")
+	}
+
+	// A lagging node does not replace a fresher snapshot, a newer block does
+	if upsert(CatalogEntry{Protocol: "ethereum", Network: "mainnet", NodeType: "archive", NodeName: "eth-archive-2", UploadID: 3, LatestBlock: block(19999000), CompletedAt: now.Add(time.Hour)}) {
+		t.Error("expected an older block not to replace the entry")
+	}
+	entries, err := db.ListCatalog(ctx, CatalogFilter{Protocol: "ethereum", Network: "mainnet", NodeType: "archive"})
+	if err != nil {
+		t.Fatalf("ListCatalog failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].UploadID != 1 || entries[0].Location == nil || *entries[0].Location != location || *entries[0].LatestSlot != 9000000 || entries[0].Tenant != "team-a" {
+		t.Fatalf("expected the first archive snapshot, got %+v", entries)
+	}
+	if !upsert(CatalogEntry{Protocol: "ethereum", Network: "mainnet", NodeType: "archive", NodeName: "eth-archive-2", UploadID: 4, LatestBlock: block(20000500), CompletedAt: now.Add(2 * time.Hour)}) {
+		t.Error("expected a newer block to replace the entry")
+	}
+	entries, err = db.ListCatalog(ctx, CatalogFilter{NodeType: "archive"})
+	if err != nil {
+		t.Fatalf("ListCatalog failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].UploadID != 4 || entries[0].NodeName != "eth-archive-2" || entries[0].Location != nil || entries[0].Tenant != "" {
+		t.Fatalf("expected the newer archive snapshot, got %+v", entries)
+	}
+
+	// Entries are ordered by protocol, network and node type
+	entries, err = db.ListCatalog(ctx, CatalogFilter{})
+	if err != nil {
+		t.Fatalf("ListCatalog failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].NodeType != "archive" || entries[1].NodeType != "full" {
+		t.Errorf("expected the archive and full entries, got %+v", entries)
+	}
+	if entries, err := db.ListCatalog(ctx, CatalogFilter{Network: "sepolia"}); err != nil || len(entries) != 0 {
+		t.Errorf("expected no sepolia entries, got %+v (%v)", entries, err)
+	}
+
+	// Purging a node deletes the entries of its snapshots
+	deleted, err := db.PurgeNode(ctx, "eth-full-1")
+	if err != nil {
+		t.Fatalf("PurgeNode failed: %v", err)
+	}
+	if deleted["snapshots"] != 1 {
+		t.Errorf("expected 1 catalog entry deleted, got %v", deleted)
+	}
+}
+
+func TestSQLiteInterruptedUploads(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var ids []int64
+	for _, node := range []string{"node-a", "node-b", "node-c"} {
+		id, err := db.CreateUpload(ctx, Upload{NodeName: node, StartedAt: now, Status: "running", TriggerType: "scheduled", ProtocolData: JSONB{}})
+		if err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids[:2] {
+		if err := db.SetUploadInterrupted(ctx, id, &now); err != nil {
+			t.Fatalf("SetUploadInterrupted failed: %v", err)
+		}
+	}
+	// A finished upload is no longer monitored, so it is not re-attached
+	if err := db.UpdateUploadCompletion(ctx, ids[1], now, "completed", nil, nil); err != nil {
+		t.Fatalf("UpdateUploadCompletion failed: %v", err)
+	}
+
+	interrupted, err := db.ListInterruptedUploads(ctx)
+	if err != nil {
+		t.Fatalf("ListInterruptedUploads failed: %v", err)
+	}
+	if len(interrupted) != 1 || interrupted[0].ID != ids[0] {
+		t.Fatalf("expected upload %d to be interrupted, got %+v", ids[0], interrupted)
+	}
+	if interrupted[0].InterruptedAt == nil || !interrupted[0].InterruptedAt.Equal(now) {
+		t.Errorf("InterruptedAt = %v, want %v", interrupted[0].InterruptedAt, now)
+	}
+
+	if err := db.SetUploadInterrupted(ctx, ids[0], nil); err != nil {
+		t.Fatalf("SetUploadInterrupted(nil) failed: %v", err)
+	}
+	if interrupted, err = db.ListInterruptedUploads(ctx); err != nil || len(interrupted) != 0 {
+		t.Errorf("expected no interrupted uploads after clearing, got %+v, error %v", interrupted, err)
+	}
+}
+
+func TestSQLiteUploadRetries(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	firstID, err := db.CreateUpload(ctx, Upload{NodeName: "node-a", StartedAt: now, Status: "running", TriggerType: "scheduled", ProtocolData: JSONB{}})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+	retryID, err := db.CreateUpload(ctx, Upload{NodeName: "node-b", StartedAt: now, Status: "running", TriggerType: "retry", ProtocolData: JSONB{}, Attempt: 2, RetryOf: &firstID})
+	if err != nil {
+		t.Fatalf("CreateUpload failed: %v", err)
+	}
+
+	first, err := db.GetUpload(ctx, firstID)
+	if err != nil || first == nil || first.Attempt != 1 || first.RetryOf != nil {
+		t.Errorf("expected an upload without an attempt to be the first, got %+v, error %v", first, err)
+	}
+	retry, err := db.GetUpload(ctx, retryID)
+	if err != nil || retry == nil || retry.Attempt != 2 || retry.RetryOf == nil || *retry.RetryOf != firstID {
+		t.Errorf("expected attempt 2 retrying upload %d, got %+v, error %v", firstID, retry, err)
+	}
+
+	// A delayed request is queued but not claimed before its not_before
+	delayedID, err := db.CreateDelayedUploadRequest(ctx, "node-a", "retry", 0, JSONB{"retry_of": firstID}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreateDelayedUploadRequest failed: %v", err)
+	}
+	dueID, err := db.CreateDelayedUploadRequest(ctx, "node-a", "retry", 0, nil, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("CreateDelayedUploadRequest failed: %v", err)
+	}
+
+	claimed, err := db.ClaimUploadRequests(ctx, "node-a")
+	if err != nil {
+		t.Fatalf("ClaimUploadRequests failed: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != dueID {
+		t.Fatalf("expected only the due request %d claimed, got %+v", dueID, claimed)
+	}
+
+	request, err := db.GetUploadRequest(ctx, delayedID)
+	if err != nil || request == nil {
+		t.Fatalf("GetUploadRequest failed: %v", err)
+	}
+	if request.Status != UploadRequestPending || request.NotBefore == nil || !request.NotBefore.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the delayed request pending until %v, got %+v", now.Add(time.Hour), request)
+	}
+}
+
+func TestSQLiteNodeCircuits(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Failed uploads count from the node's last completed upload; cancelled ones are ignored
+	for _, status := range []string{"failed", "completed", "failed", "cancelled", "failed"} {
+		if _, err := db.CreateUpload(ctx, Upload{NodeName: "eth-node", StartedAt: now, Status: status, TriggerType: "scheduled", ProtocolData: JSONB{}}); err != nil {
+			t.Fatalf("CreateUpload failed: %v", err)
+		}
+	}
+	if failures, err := db.CountConsecutiveFailedUploads(ctx, "eth-node"); err != nil || failures != 2 {
+		t.Errorf("expected 2 failures since the last completed upload, got %d (%v)", failures, err)
+	}
+	if failures, err := db.CountConsecutiveFailedUploads(ctx, "arb-node"); err != nil || failures != 0 {
+		t.Errorf("expected no failures for a node without uploads, got %d (%v)", failures, err)
+	}
+
+	opened, err := db.OpenNodeCircuit(ctx, "eth-node", 2, now)
+	if err != nil || !opened {
+		t.Fatalf("OpenNodeCircuit failed: %v", err)
+	}
+	// Opening again replaces the open circuit
+	if opened, err := db.OpenNodeCircuit(ctx, "eth-node", 3, now.Add(time.Hour)); err != nil || !opened {
+		t.Fatalf("OpenNodeCircuit failed: %v", err)
+	}
+	pause, err := db.GetNodePause(ctx, "eth-node")
+	if err != nil {
+		t.Fatalf("GetNodePause failed: %v", err)
+	}
+	if pause == nil || !pause.IsCircuit() || *pause.CircuitFailures != 3 || pause.Actor != CircuitBreakerActor || !pause.PausedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected eth-node's circuit open after 3 failures, got %+v", pause)
+	}
+
+	// An operator's pause is neither replaced by a circuit nor closed with one
+	if err := db.PauseNode(ctx, NodePause{NodeName: "arb-node", Actor: "alice", PausedAt: now}); err != nil {
+		t.Fatalf("PauseNode failed: %v", err)
+	}
+	if opened, err := db.OpenNodeCircuit(ctx, "arb-node", 3, now); err != nil || opened {
+		t.Errorf("expected arb-node's pause kept, got opened %v (%v)", opened, err)
+	}
+	if closed, err := db.CloseNodeCircuit(ctx, "arb-node"); err != nil || closed {
+		t.Errorf("expected arb-node's pause kept, got closed %v (%v)", closed, err)
+	}
+	if pause, err := db.GetNodePause(ctx, "arb-node"); err != nil || pause == nil || pause.IsCircuit() || pause.Actor != "alice" {
+		t.Errorf("expected arb-node paused by alice, got %+v (%v)", pause, err)
+	}
+
+	if closed, err := db.CloseNodeCircuit(ctx, "eth-node"); err != nil || !closed {
+		t.Errorf("expected eth-node's circuit closed, got %v (%v)", closed, err)
+	}
+	if pause, err := db.GetNodePause(ctx, "eth-node"); err != nil || pause != nil {
+		t.Errorf("expected eth-node resumed, got %+v (%v)", pause, err)
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaxUploadLogLines bounds the job log lines kept per upload; RecordUploadLogs keeps the
+// last ones
+const MaxUploadLogLines = 200
+
+// UploadLogLine is a line of the tail of an upload's job log, captured when it finished
+type UploadLogLine struct {
+	UploadID   int64     `db:"upload_id" json:"upload_id"`
+	LineNo     int       `db:"line_no" json:"line_no"` // Position in the captured tail, from 1
+	Stream     string    `db:"stream" json:"stream"`   // "stdout" or "stderr"
+	Line       string    `db:"line" json:"line"`
+	RecordedAt time.Time `db:"recorded_at" json:"recorded_at"`
+}
+
+// RecordUploadLogs replaces the stored job log of an upload with lines, keeping the last
+// MaxUploadLogLines. The lines are numbered in order and written in a single transaction.
+func (db *DB) RecordUploadLogs(ctx context.Context, uploadID int64, lines []UploadLogLine) error {
+	if len(lines) > MaxUploadLogLines {
+		lines = lines[len(lines)-MaxUploadLogLines:]
+	}
+
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record upload logs: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, db.driver.Rebind(`DELETE FROM upload_logs WHERE upload_id = $1`), uploadID); err != nil {
+		return fmt.Errorf("failed to record upload logs: %w", err)
+	}
+
+	insert, err := tx.PreparexContext(ctx, db.driver.Rebind(`INSERT INTO upload_logs (upload_id, line_no, stream, line, recorded_at)
+	          VALUES ($1, $2, $3, $4, $5)`))
+	if err != nil {
+		return fmt.Errorf("failed to record upload logs: %w", err)
+	}
+	defer insert.Close()
+
+	now := time.Now().UTC()
+	for i, line := range lines {
+		recordedAt := line.RecordedAt
+		if recordedAt.IsZero() {
+			recordedAt = now
+		}
+		if _, err := insert.ExecContext(ctx, uploadID, i+1, line.Stream, line.Line, recordedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to record upload log line %d: %w", i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record upload logs: %w", err)
+	}
+
+	return nil
+}
+
+// GetUploadLogs retrieves the stored job log of an upload, in order
+func (db *DB) GetUploadLogs(ctx context.Context, uploadID int64) ([]UploadLogLine, error) {
+	query := `SELECT upload_id, line_no, stream, line, recorded_at
+	          FROM upload_logs
+	          WHERE upload_id = $1
+	          ORDER BY line_no`
+
+	var lines []UploadLogLine
+	if err := db.queryWithRetry(ctx, &lines, query, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to get upload logs: %w", err)
+	}
+
+	return lines, nil
+}
+
+// GetLatestUploadLogs retrieves the stored job log of a node's most recent upload that has
+// one, in order, or none when no upload of the node has a stored log
+func (db *DB) GetLatestUploadLogs(ctx context.Context, nodeName string) ([]UploadLogLine, error) {
+	query := `SELECT upload_id, line_no, stream, line, recorded_at
+	          FROM upload_logs
+	          WHERE upload_id = (
+	              SELECT MAX(l.upload_id)
+	              FROM upload_logs l
+	              JOIN uploads u ON u.id = l.upload_id
+	              WHERE u.node_name = $1
+	          )
+	          ORDER BY line_no`
+
+	var lines []UploadLogLine
+	if err := db.queryWithRetry(ctx, &lines, query, nodeName); err != nil {
+		return nil, fmt.Errorf("failed to get latest upload logs: %w", err)
+	}
+
+	return lines, nil
+}
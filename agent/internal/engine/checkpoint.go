@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Chunk is a piece of an upload that has reached the destination
+type Chunk struct {
+	Number   int
+	ETag     string // The destination's identifier of the stored chunk
+	Size     int64
+	Checksum string
+}
+
+// Checkpoint is the saved progress of a node's upload run by an in-process engine
+type Checkpoint struct {
+	Engine  string
+	Session string // Engine-specific description of the upload at the destination
+	Chunks  []Chunk
+}
+
+// CheckpointStore keeps the chunks in-process engines have uploaded, so an upload
+// interrupted by a failure or a restart of the daemon resumes instead of starting over.
+// A node has at most one checkpoint.
+type CheckpointStore interface {
+	// GetCheckpoint returns the node's checkpoint, or nil if it has none
+	GetCheckpoint(ctx context.Context, nodeName string) (*Checkpoint, error)
+	// SaveCheckpoint replaces the node's checkpoint and its chunks
+	SaveCheckpoint(ctx context.Context, nodeName string, checkpoint Checkpoint) error
+	// AddCheckpointChunk records a chunk uploaded under the node's checkpoint
+	AddCheckpointChunk(ctx context.Context, nodeName string, chunk Chunk) error
+	// DeleteCheckpoint removes the node's checkpoint, once its upload has completed or
+	// been discarded
+	DeleteCheckpoint(ctx context.Context, nodeName string) error
+}
+
+// Resumer is implemented by engines that resume an upload interrupted by a restart of
+// the daemon from its checkpoint
+type Resumer interface {
+	// ResumeUpload starts the node's interrupted upload again, keeping the chunks already
+	// uploaded. It returns false when there is nothing to resume.
+	ResumeUpload(ctx context.Context, nodeName string) (bool, error)
+}
+
+// MemoryCheckpoints is a CheckpointStore that keeps checkpoints in memory, for engines
+// used without a database. Its uploads resume after failures but not after a restart.
+type MemoryCheckpoints struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewMemoryCheckpoints creates an empty in-memory checkpoint store
+func NewMemoryCheckpoints() *MemoryCheckpoints {
+	return &MemoryCheckpoints{checkpoints: make(map[string]Checkpoint)}
+}
+
+// GetCheckpoint returns a copy of the node's checkpoint, or nil if it has none
+func (m *MemoryCheckpoints) GetCheckpoint(ctx context.Context, nodeName string) (*Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	checkpoint, ok := m.checkpoints[nodeName]
+	if !ok {
+		return nil, nil
+	}
+	checkpoint.Chunks = append([]Chunk(nil), checkpoint.Chunks...)
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint replaces the node's checkpoint
+func (m *MemoryCheckpoints) SaveCheckpoint(ctx context.Context, nodeName string, checkpoint Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	checkpoint.Chunks = append([]Chunk(nil), checkpoint.Chunks...)
+	m.checkpoints[nodeName] = checkpoint
+	return nil
+}
+
+// AddCheckpointChunk records a chunk, replacing a chunk with the same number
+func (m *MemoryCheckpoints) AddCheckpointChunk(ctx context.Context, nodeName string, chunk Chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	checkpoint, ok := m.checkpoints[nodeName]
+	if !ok {
+		return nil
+	}
+	chunks := []Chunk{chunk}
+	for _, c := range checkpoint.Chunks {
+		if c.Number != chunk.Number {
+			chunks = append(chunks, c)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Number < chunks[j].Number })
+	checkpoint.Chunks = chunks
+	m.checkpoints[nodeName] = checkpoint
+	return nil
+}
+
+// DeleteCheckpoint removes the node's checkpoint
+func (m *MemoryCheckpoints) DeleteCheckpoint(ctx context.Context, nodeName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.checkpoints, nodeName)
+	return nil
+}
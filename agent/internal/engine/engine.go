@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
+)
+
+// Names of the upload engines a node can be configured with
+const (
+	BV     = "bv"     // blockvisor's upload job, driven through the bv CLI (default)
+	Rclone = "rclone" // A direct transfer of a data directory with rclone
+	S3     = "s3"     // A tar archive of a data directory streamed to S3-compatible storage
+)
+
+// Compression algorithms an engine can apply to the data it uploads
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionLz4  = "lz4"
+	CompressionNone = "none"
+)
+
+// CompressionReport is how a completed upload was compressed and how much it shrank
+type CompressionReport struct {
+	Algorithm       string
+	Level           int   // 0 when the algorithm's default level was used
+	RawBytes        int64 // Bytes of data before compression
+	CompressedBytes int64 // Bytes uploaded
+}
+
+// Ratio returns the raw size divided by the compressed size, or 0 when nothing was
+// uploaded
+func (c CompressionReport) Ratio() float64 {
+	if c.CompressedBytes <= 0 {
+		return 0
+	}
+	return float64(c.RawBytes) / float64(c.CompressedBytes)
+}
+
+// Flags converts a node's engine arguments into command-line flags, "--name=value" or
+// "--name" for an empty value, sorted by name so the command line is stable
+func Flags(args map[string]string) []string {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		if args[name] == "" {
+			flags = append(flags, "--"+name)
+			continue
+		}
+		flags = append(flags, "--"+name+"="+args[name])
+	}
+	return flags
+}
+
+// StatusTimeFormat is the timestamp format of a status line, as printed by bv
+const StatusTimeFormat = "2006-01-02 15:04:05 UTC"
+
+// Runner executes system commands
+type Runner interface {
+	Execute(ctx context.Context, command string, args ...string) (stdout, stderr string, err error)
+}
+
+// Engine starts, inspects and stops a node's snapshot upload
+type Engine interface {
+	// Name returns the engine name, e.g. "bv"
+	Name() string
+	// StartUpload starts the node's upload and returns once it is under way
+	StartUpload(ctx context.Context, nodeName string) error
+	// Status reports the node's current or last upload
+	Status(ctx context.Context, nodeName string) (*Status, error)
+	// Cancel stops the node's running upload
+	Cancel(ctx context.Context, nodeName string) error
+}
+
+// LogReader is implemented by engines that keep a log of the node's last upload
+type LogReader interface {
+	Logs(ctx context.Context, nodeName string) (string, error)
+}
+
+// LogStreamer is implemented by engines that can hand out the log of the node's last
+// upload line by line, without holding it in memory
+type LogStreamer interface {
+	StreamLogs(ctx context.Context, nodeName string, onLine executor.LineHandler) error
+}
+
+// Status is an engine's report of a node's upload. Once the upload has stopped, the
+// status line says how it ended, in bv's wording: "Finished with exit code 0" for a
+// success and an exit code, "failed" or "cancelled" otherwise.
+type Status struct {
+	Running  bool
+	NotFound bool   // The node has never uploaded with this engine
+	Status   string // Status line, including its timestamp when known
+	State    string // Status line without the timestamp
+	// StatusTime is when the status last changed: the start of a running upload, the end
+	// of a finished one
+	StatusTime *time.Time
+	// Progress is the progress as reported, e.g. "75.50% (3100/4112 uploading)"
+	Progress        string
+	ProgressPercent *float64
+	ChunksCompleted *int
+	ChunksTotal     *int
+	Fields          map[string]string // Additional engine-specific fields, kept with the upload's progress
+	// SizeBytes is the bytes uploaded, the snapshot's size once the upload has completed,
+	// for engines that report it
+	SizeBytes *int64
+	// Compression is reported once an upload has completed, by engines that compress
+	Compression *CompressionReport
+	// Location is where the upload stores the snapshot, such as an rclone remote path or an
+	// s3:// URL, for engines that know it
+	Location string
+	Raw      string // Output the status was read from
+}
+
+// SetState sets the status line from a state and the time it was reached, formatted
+// like bv's: "2025-12-10 15:18:44 UTC| Running"
+func (s *Status) SetState(state string, at time.Time) {
+	at = at.UTC()
+	s.State = state
+	s.StatusTime = &at
+	s.Status = at.Format(StatusTimeFormat) + "| " + state
+}
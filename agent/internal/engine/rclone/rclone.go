@@ -0,0 +1,396 @@
+package rclone
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults and transfer modes
+const (
+	DefaultBinary = "rclone"
+	ModeSync      = "sync" // Make the destination match the source, deleting extra objects
+	ModeCopy      = "copy" // Copy new and changed files, never deleting
+	// statsInterval is how often rclone logs transfer stats
+	statsInterval = "10s"
+	// remoteLogLines is how many of the last lines of a remote log are read
+	remoteLogLines = 1000
+)
+
+// ReservedFlags are the flags the engine sets itself to read rclone's log, which a
+// node's engine arguments cannot replace
+var ReservedFlags = []string{"use-json-log", "log-file", "stats", "stats-log-level"}
+
+// exitStatusPattern extracts the exit code from an error that does not carry the process
+// state, such as one wrapped by an executor
+var exitStatusPattern = regexp.MustCompile(`exit status (\d+)`)
+
+// Config describes a node's transfer. "{node}" in the source and destination is
+// replaced with the node name.
+type Config struct {
+	Binary      string   // rclone binary (default "rclone")
+	Mode        string   // sync or copy (default sync)
+	Source      string   // Local data directory, e.g. /var/lib/{node}/data
+	Destination string   // rclone remote path, e.g. s3:snapshots/{node}
+	Flags       []string // Additional rclone flags, e.g. --transfers 16
+	LogDir      string   // Directory of rclone's logs (default snapperd-rclone in the temporary directory)
+	// RemoteLog is set when the runner runs rclone away from the daemon's filesystem, such
+	// as in a container, so its log is prepared and read through the runner with sh
+	RemoteLog bool
+}
+
+// transfer is a node's current or last rclone run
+type transfer struct {
+	cancel     context.CancelFunc
+	logPath    string
+	startedAt  time.Time
+	finishedAt *time.Time
+	exitCode   int
+	cancelled  bool
+	// destination is the node's destination, with {node} replaced
+	destination string
+}
+
+// Engine uploads a node's data directory with rclone, for hosts not managed by
+// blockvisor. Each transfer runs in the background for as long as the daemon; rclone's
+// JSON log is read for progress. Transfers are kept in memory, so after a restart a
+// node has no status until its next upload.
+type Engine struct {
+	runner engine.Runner
+	cfg    Config
+	logDir string
+	logger *logrus.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	transfers map[string]*transfer
+	wg        sync.WaitGroup
+}
+
+// New creates an rclone engine running transfers through runner
+func New(runner engine.Runner, cfg Config, logger *logrus.Logger) *Engine {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if cfg.Binary == "" {
+		cfg.Binary = DefaultBinary
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = ModeSync
+	}
+	logDir := cfg.LogDir
+	if logDir == "" {
+		logDir = filepath.Join(os.TempDir(), "snapperd-rclone")
+	}
+	return &Engine{
+		runner:    runner,
+		cfg:       cfg,
+		logDir:    logDir,
+		logger:    logger,
+		now:       time.Now,
+		transfers: make(map[string]*transfer),
+	}
+}
+
+// Name returns the engine name
+func (e *Engine) Name() string {
+	return engine.Rclone
+}
+
+// StartUpload starts the node's transfer in the background
+func (e *Engine) StartUpload(ctx context.Context, nodeName string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if t, ok := e.transfers[nodeName]; ok && t.finishedAt == nil {
+		return fmt.Errorf("rclone transfer already running for node %s", nodeName)
+	}
+
+	logPath := e.logPath(nodeName)
+	if err := e.resetLog(ctx, logPath); err != nil {
+		return err
+	}
+
+	replacer := strings.NewReplacer("{node}", nodeName)
+	args := []string{
+		e.cfg.Mode,
+		replacer.Replace(e.cfg.Source),
+		replacer.Replace(e.cfg.Destination),
+		"--use-json-log",
+		"--log-file", logPath,
+		"--stats", statsInterval,
+		"--stats-log-level", "NOTICE",
+	}
+	args = append(args, e.cfg.Flags...)
+
+	// The transfer outlives the request that started it
+	runCtx, cancel := context.WithCancel(context.Background())
+	t := &transfer{cancel: cancel, logPath: logPath, destination: args[2], startedAt: e.now()}
+	e.transfers[nodeName] = t
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer cancel()
+
+		_, stderr, err := e.runner.Execute(runCtx, e.cfg.Binary, args...)
+
+		e.mu.Lock()
+		finishedAt := e.now()
+		t.finishedAt = &finishedAt
+		t.cancelled = err != nil && runCtx.Err() != nil
+		t.exitCode = exitCode(err)
+		e.mu.Unlock()
+
+		fields := logrus.Fields{
+			"component": "engine",
+			"engine":    engine.Rclone,
+			"node":      nodeName,
+			"exit_code": t.exitCode,
+			"cancelled": t.cancelled,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			fields["stderr"] = stderr
+		}
+		e.logger.WithFields(fields).Info("rclone transfer finished")
+	}()
+
+	e.logger.WithFields(logrus.Fields{
+		"component":   "engine",
+		"engine":      engine.Rclone,
+		"node":        nodeName,
+		"mode":        e.cfg.Mode,
+		"source":      args[1],
+		"destination": args[2],
+	}).Info("rclone transfer started")
+
+	return nil
+}
+
+// Status reports the node's transfer from its state and the last stats in its log
+func (e *Engine) Status(ctx context.Context, nodeName string) (*engine.Status, error) {
+	e.mu.Lock()
+	t, ok := e.transfers[nodeName]
+	var current transfer
+	if ok {
+		current = *t
+	}
+	e.mu.Unlock()
+
+	if !ok {
+		// A record of a running upload without a transfer was interrupted by a restart
+		return &engine.Status{
+			NotFound: true,
+			Status:   "Failed: no rclone transfer has run for the node since snapperd started",
+		}, nil
+	}
+
+	status := &engine.Status{
+		Fields:   map[string]string{"log_file": current.logPath},
+		Location: current.destination,
+	}
+	stats, lastError, err := e.readLog(ctx, current.logPath)
+	if err != nil {
+		return nil, err
+	}
+	if stats != nil {
+		status.Raw = stats.raw
+		status.Fields["errors"] = strconv.FormatInt(stats.Errors, 10)
+		bytes := stats.Bytes
+		status.SizeBytes = &bytes
+		if stats.TotalBytes > 0 {
+			percent := float64(stats.Bytes) / float64(stats.TotalBytes) * 100
+			status.ProgressPercent = &percent
+		}
+		if stats.TotalTransfers > 0 {
+			completed, total := int(stats.Transfers), int(stats.TotalTransfers)
+			status.ChunksCompleted = &completed
+			status.ChunksTotal = &total
+		}
+		if status.ProgressPercent != nil {
+			status.Progress = fmt.Sprintf("%.2f%% (%d/%d files transferred)", *status.ProgressPercent, stats.Transfers, stats.TotalTransfers)
+		}
+	}
+
+	switch {
+	case current.finishedAt == nil:
+		status.Running = true
+		status.SetState("Running", current.startedAt)
+	case current.cancelled:
+		status.SetState("Cancelled", *current.finishedAt)
+	case current.exitCode != 0 && lastError != "":
+		status.SetState(fmt.Sprintf("Finished with exit code %d and message `%s`", current.exitCode, lastError), *current.finishedAt)
+	default:
+		status.SetState(fmt.Sprintf("Finished with exit code %d", current.exitCode), *current.finishedAt)
+	}
+
+	return status, nil
+}
+
+// Cancel stops the node's running transfer
+func (e *Engine) Cancel(ctx context.Context, nodeName string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, ok := e.transfers[nodeName]
+	if !ok || t.finishedAt != nil {
+		return fmt.Errorf("no rclone transfer running for node %s", nodeName)
+	}
+	t.cancel()
+	return nil
+}
+
+// Logs returns the rclone log of the node's last transfer, which outlives the process
+// that ran it
+func (e *Engine) Logs(ctx context.Context, nodeName string) (string, error) {
+	if e.cfg.RemoteLog {
+		return e.readRemoteLog(ctx, e.logPath(nodeName))
+	}
+	data, err := os.ReadFile(e.logPath(nodeName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read rclone log: %w", err)
+	}
+	return string(data), nil
+}
+
+// resetLog creates the log directory and removes the previous log at path, since rclone
+// appends to its log file
+func (e *Engine) resetLog(ctx context.Context, path string) error {
+	if e.cfg.RemoteLog {
+		if _, stderr, err := e.runner.Execute(ctx, "sh", "-c", `mkdir -p "$(dirname "$1")" && rm -f "$1"`, "sh", path); err != nil {
+			return fmt.Errorf("failed to prepare rclone log: %w: %s", err, strings.TrimSpace(stderr))
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(e.logDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create rclone log directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous rclone log: %w", err)
+	}
+	return nil
+}
+
+// readRemoteLog returns the last remoteLogLines lines of the log at path through the
+// runner, or nothing when rclone has not written it yet
+func (e *Engine) readRemoteLog(ctx context.Context, path string) (string, error) {
+	stdout, stderr, err := e.runner.Execute(ctx, "sh", "-c", `[ ! -e "$1" ] || tail -n "$2" "$1"`, "sh", path, strconv.Itoa(remoteLogLines))
+	if err != nil {
+		return "", fmt.Errorf("failed to read rclone log: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return stdout, nil
+}
+
+// logPath returns the path of a node's rclone log
+func (e *Engine) logPath(nodeName string) string {
+	return filepath.Join(e.logDir, filepath.Base(nodeName)+".log")
+}
+
+// Stop cancels every running transfer and waits for them to exit
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	for _, t := range e.transfers {
+		if t.finishedAt == nil {
+			t.cancel()
+		}
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+// stats are the transfer stats rclone logs periodically and on exit
+type stats struct {
+	Bytes          int64 `json:"bytes"`
+	TotalBytes     int64 `json:"totalBytes"`
+	Transfers      int64 `json:"transfers"`
+	TotalTransfers int64 `json:"totalTransfers"`
+	Errors         int64 `json:"errors"`
+	raw            string
+}
+
+// logLine is one line of rclone's JSON log
+type logLine struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+	Stats *stats `json:"stats"`
+}
+
+// readLog returns the last stats and error message in an rclone JSON log. A missing log
+// has neither, since rclone has not written it yet.
+func (e *Engine) readLog(ctx context.Context, path string) (*stats, string, error) {
+	if e.cfg.RemoteLog {
+		data, err := e.readRemoteLog(ctx, path)
+		if err != nil {
+			return nil, "", err
+		}
+		return parseLog(strings.NewReader(data))
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read rclone log: %w", err)
+	}
+	defer f.Close()
+	return parseLog(f)
+}
+
+// parseLog returns the last stats and error message of the rclone JSON log read from r
+func parseLog(r io.Reader) (*stats, string, error) {
+	var last *stats
+	var lastError string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line logLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.Stats != nil {
+			line.Stats.raw = scanner.Text()
+			last = line.Stats
+		}
+		if line.Level == "error" || line.Level == "critical" {
+			lastError = strings.TrimSpace(line.Msg)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read rclone log: %w", err)
+	}
+	return last, lastError, nil
+}
+
+// exitCode returns a finished command's exit code, 1 when it failed without one
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	if match := exitStatusPattern.FindStringSubmatch(err.Error()); match != nil {
+		if code, err := strconv.Atoi(match[1]); err == nil {
+			return code
+		}
+	}
+	return 1
+}
@@ -0,0 +1,223 @@
+package rclone
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// blockingRunner runs a fake rclone that writes the given log lines and then waits to be
+// released or cancelled
+type blockingRunner struct {
+	log     string
+	release chan error
+	args    chan []string
+}
+
+func newBlockingRunner(log string) *blockingRunner {
+	return &blockingRunner{log: log, release: make(chan error, 1), args: make(chan []string, 1)}
+}
+
+func (r *blockingRunner) Execute(ctx context.Context, command string, args ...string) (string, string, error) {
+	for i, arg := range args {
+		if arg == "--log-file" {
+			if err := os.WriteFile(args[i+1], []byte(r.log), 0o644); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	r.args <- append([]string{command}, args...)
+
+	select {
+	case err := <-r.release:
+		return "", "", err
+	case <-ctx.Done():
+		return "", "", errors.New("command canceled: signal: killed")
+	}
+}
+
+const runningLog = `{"level":"notice","msg":"Starting transfer","time":"2025-12-10T15:18:44Z"}
+{"level":"notice","msg":"Transferred: 3 GiB / 4 GiB","stats":{"bytes":3000,"totalBytes":4000,"transfers":30,"totalTransfers":40,"errors":0},"time":"2025-12-10T15:18:54Z"}
+{"level":"error","msg":"chain/000123.ldb: Failed to copy: AccessDenied: Access Denied","time":"2025-12-10T15:18:55Z"}
+`
+
+// waitFinished waits for a node's transfer to end
+func waitFinished(t *testing.T, e *Engine, nodeName string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := e.Status(context.Background(), nodeName)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if !status.Running {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("transfer did not finish")
+}
+
+func TestEngine_Lifecycle(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	runner := newBlockingRunner(runningLog)
+	e := New(runner, Config{Source: "/var/lib/{node}/data", Destination: "s3:snapshots/{node}", Flags: []string{"--transfers", "16"}}, logger)
+	e.logDir = t.TempDir()
+	ctx := context.Background()
+
+	// A node without a transfer reports a failure, so an interrupted upload is not recorded as a success
+	status, err := e.Status(ctx, "eth-1")
+	if err != nil || !status.NotFound || status.Running || !strings.HasPrefix(status.Status, "Failed") {
+		t.Fatalf("expected no transfer for the node, got %+v, %v", status, err)
+	}
+
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	args := strings.Join(<-runner.args, " ")
+	if !strings.HasPrefix(args, "rclone sync /var/lib/eth-1/data s3:snapshots/eth-1 --use-json-log --log-file ") || !strings.HasSuffix(args, "--transfers 16") {
+		t.Errorf("unexpected command: %s", args)
+	}
+	if err := e.StartUpload(ctx, "eth-1"); err == nil {
+		t.Error("expected an error starting a second transfer for the node")
+	}
+
+	status, err = e.Status(ctx, "eth-1")
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.Running || status.State != "Running" || status.StatusTime == nil {
+		t.Errorf("expected a running transfer, got %+v", status)
+	}
+	if status.ProgressPercent == nil || *status.ProgressPercent != 75 || status.Progress != "75.00% (30/40 files transferred)" {
+		t.Errorf("expected 75%% progress, got %v %q", status.ProgressPercent, status.Progress)
+	}
+	if status.SizeBytes == nil || *status.SizeBytes != 3000 {
+		t.Errorf("expected 3000 bytes transferred, got %v", status.SizeBytes)
+	}
+	if status.Location != "s3:snapshots/eth-1" {
+		t.Errorf("expected the node's destination, got %q", status.Location)
+	}
+	if status.ChunksCompleted == nil || *status.ChunksCompleted != 30 || status.ChunksTotal == nil || *status.ChunksTotal != 40 {
+		t.Errorf("expected 30/40 files, got %v/%v", status.ChunksCompleted, status.ChunksTotal)
+	}
+
+	// rclone exiting with an error reports its exit code and last error
+	runner.release <- errors.New("command failed: exit status 3")
+	waitFinished(t, e, "eth-1")
+	status, _ = e.Status(ctx, "eth-1")
+	if !strings.Contains(status.Status, "Finished with exit code 3 and message `chain/000123.ldb: Failed to copy: AccessDenied: Access Denied`") {
+		t.Errorf("expected the exit code and error in the status, got %q", status.Status)
+	}
+	if logs, err := e.Logs(ctx, "eth-1"); err != nil || !strings.Contains(logs, "AccessDenied") {
+		t.Errorf("expected the rclone log, got %q, %v", logs, err)
+	}
+
+	// A cancelled transfer is reported as cancelled
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	<-runner.args
+	if err := e.Cancel(ctx, "eth-1"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+	status, _ = e.Status(ctx, "eth-1")
+	if status.State != "Cancelled" {
+		t.Errorf("expected a cancelled transfer, got %q", status.State)
+	}
+	if err := e.Cancel(ctx, "eth-1"); err == nil {
+		t.Error("expected an error cancelling a finished transfer")
+	}
+}
+
+func TestEngine_Success(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	runner := newBlockingRunner("")
+	e := New(runner, Config{Binary: "/usr/local/bin/rclone", Mode: ModeCopy, Source: "/data", Destination: "r2:bucket"}, logger)
+	e.logDir = t.TempDir()
+
+	if err := e.StartUpload(context.Background(), "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	if args := <-runner.args; args[0] != "/usr/local/bin/rclone" || args[1] != ModeCopy {
+		t.Errorf("unexpected command: %v", args)
+	}
+	runner.release <- nil
+	waitFinished(t, e, "eth-1")
+	e.Stop()
+
+	status, err := e.Status(context.Background(), "eth-1")
+	if err != nil || status.Running || status.State != "Finished with exit code 0" || status.ProgressPercent != nil {
+		t.Errorf("expected a successful transfer without stats, got %+v, %v", status, err)
+	}
+}
+
+// shellRunner runs sh commands, standing in for a container's shell, and hands the rest
+// to a blockingRunner
+type shellRunner struct {
+	*blockingRunner
+	shell []string // Scripts run with sh
+}
+
+func (r *shellRunner) Execute(ctx context.Context, command string, args ...string) (string, string, error) {
+	if command != "sh" {
+		return r.blockingRunner.Execute(ctx, command, args...)
+	}
+	r.shell = append(r.shell, args[1])
+	var stdout, stderr strings.Builder
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestEngine_RemoteLog(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	runner := &shellRunner{blockingRunner: newBlockingRunner(runningLog)}
+	logDir := filepath.Join(t.TempDir(), "rclone")
+	e := New(runner, Config{Source: "/data", Destination: "r2:bucket", LogDir: logDir, RemoteLog: true}, logger)
+	ctx := context.Background()
+
+	// A log not written yet reads as empty
+	if logs, err := e.Logs(ctx, "eth-1"); err != nil || logs != "" {
+		t.Fatalf("Logs() = %q, %v, want an empty log", logs, err)
+	}
+
+	// The log directory is created through the runner
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	<-runner.args
+	if _, err := os.Stat(logDir); err != nil {
+		t.Errorf("expected the log directory to be created: %v", err)
+	}
+
+	status, err := e.Status(ctx, "eth-1")
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.ProgressPercent == nil || *status.ProgressPercent != 75 {
+		t.Errorf("expected 75%% progress read through the runner, got %v", status.ProgressPercent)
+	}
+	if logs, err := e.Logs(ctx, "eth-1"); err != nil || logs != runningLog {
+		t.Errorf("Logs() = %q, %v", logs, err)
+	}
+	if len(runner.shell) != 4 {
+		t.Errorf("expected the log to be prepared and read with sh, ran %v", runner.shell)
+	}
+
+	runner.release <- nil
+	waitFinished(t, e, "eth-1")
+	e.Stop()
+}
@@ -0,0 +1,216 @@
+package s3
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nodexeus/agent/internal/engine"
+)
+
+// compressorBinaries are the tools that compress archives with algorithms the standard
+// library does not implement
+var compressorBinaries = map[string]string{
+	engine.CompressionZstd: "zstd",
+	engine.CompressionLz4:  "lz4",
+}
+
+// archiveExtensions are the key suffixes of archives by compression
+var archiveExtensions = map[string]string{
+	engine.CompressionGzip: ".tar.gz",
+	engine.CompressionZstd: ".tar.zst",
+	engine.CompressionLz4:  ".tar.lz4",
+	engine.CompressionNone: ".tar",
+}
+
+// sourceSize returns the total size of the regular files under dir, the data the
+// archive reads
+func sourceSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read source directory: %w", err)
+	}
+	return total, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+// nopWriteCloser passes writes through to an archive written without compression
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// commandWriter compresses through a tool reading stdin and writing stdout
+type commandWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *bytes.Buffer
+}
+
+func (c *commandWriter) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// Close ends the input and waits for the tool to write the rest of its output
+func (c *commandWriter) Close() error {
+	c.stdin.Close()
+	if err := c.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+			return fmt.Errorf("%s failed: %w: %s", c.cmd.Path, err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", c.cmd.Path, err)
+	}
+	return nil
+}
+
+// newCompressor returns a writer compressing into w with the algorithm at level (0 for
+// the algorithm's default). Closing it flushes the compressed output.
+func newCompressor(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch algorithm {
+	case engine.CompressionNone:
+		return nopWriteCloser{w}, nil
+	case engine.CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+
+	binary, ok := compressorBinaries[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression '%s'", algorithm)
+	}
+	args := []string{"-q", "-c"}
+	if level > 0 {
+		args = append(args, "-"+strconv.Itoa(level))
+	}
+	cmd := exec.Command(binary, args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = w
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s compression: %w", algorithm, err)
+	}
+	return &commandWriter{cmd: cmd, stdin: stdin, stderr: stderr}, nil
+}
+
+// writeArchive writes dir as a tar archive, compressed with the algorithm at level, to
+// w. Entries are written in lexical order, so an unchanged directory produces the same
+// archive and an interrupted upload can be resumed. The file bytes read are added to
+// read and the archive bytes before compression to raw as they go.
+func writeArchive(w io.Writer, dir, algorithm string, level int, read, raw *atomic.Int64) error {
+	compressor, err := newCompressor(w, algorithm, level)
+	if err != nil {
+		return err
+	}
+	// The compressor exits, and releases w, however the archive ends
+	defer compressor.Close()
+	tw := tar.NewWriter(countingWriter{w: compressor, count: raw})
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch {
+		case info.Mode().IsRegular(), info.IsDir():
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			// Sockets, pipes and devices are not part of a snapshot
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// A file that changes size while it is read fails the archive rather than
+		// producing a corrupt one
+		n, err := io.Copy(tw, io.LimitReader(f, header.Size))
+		read.Add(n)
+		if err != nil {
+			return err
+		}
+		if n != header.Size {
+			return fmt.Errorf("%s changed while it was archived", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive source directory: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to archive source directory: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return nil
+}
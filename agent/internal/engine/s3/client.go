@@ -0,0 +1,365 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Credentials sign requests to the storage backend
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Set for temporary credentials
+}
+
+// APIError is an error response from the storage backend
+type APIError struct {
+	StatusCode int
+	Code       string // e.g. NoSuchUpload, AccessDenied
+	Message    string
+}
+
+// Error returns the backend's error code and message
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3 request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3 request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// client makes the S3 multipart upload requests the engine needs, signed with AWS
+// Signature Version 4. It works with any S3-compatible backend.
+type client struct {
+	http        *http.Client
+	endpoint    *url.URL
+	region      string
+	bucket      string
+	pathStyle   bool // Address the bucket in the path rather than the host name
+	credentials Credentials
+	now         func() time.Time
+}
+
+// part is an uploaded part of a multipart upload
+type part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+	Size   int64  `xml:"Size"`
+	MD5    string `xml:"-"` // Base64 MD5 of the part, checked by the backend on upload
+}
+
+// createMultipartUpload starts a multipart upload of an object with headers, such as its
+// storage class, and returns its ID
+func (c *client) createMultipartUpload(ctx context.Context, key string, headers http.Header) (string, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, headers, nil, &result); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("failed to create multipart upload: no upload ID in response")
+	}
+	return result.UploadID, nil
+}
+
+// uploadPart uploads one part with its MD5, which the backend verifies, and returns the
+// part's ETag
+func (c *client) uploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	sum := md5.Sum(data)
+	headers := http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}}
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+
+	resp, err := c.request(ctx, http.MethodPut, key, query, headers, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("failed to upload part %d: no ETag in response", number)
+	}
+	return etag, nil
+}
+
+// listParts returns the parts uploaded so far
+func (c *client) listParts(ctx context.Context, key, uploadID string) ([]part, error) {
+	var parts []part
+	marker := ""
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+		var result struct {
+			Parts                []part `xml:"Part"`
+			IsTruncated          bool   `xml:"IsTruncated"`
+			NextPartNumberMarker string `xml:"NextPartNumberMarker"`
+		}
+		if err := c.do(ctx, http.MethodGet, key, query, nil, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		parts = append(parts, result.Parts...)
+		if !result.IsTruncated || result.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// completeMultipartUpload assembles the uploaded parts into the object and returns its ETag
+func (c *client) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []part) (string, error) {
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+	body := struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, completedPart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode parts: %w", err)
+	}
+
+	// The backend can report an error in a 200 response once it has started assembling
+	var result struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	headers := http.Header{"Content-Type": {"application/xml"}}
+	if err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, headers, data, &result); err != nil {
+		return "", fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return "", fmt.Errorf("failed to complete multipart upload: %w", &APIError{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message})
+	}
+	return result.ETag, nil
+}
+
+// abortMultipartUpload discards a multipart upload and its parts
+func (c *client) abortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// headObject returns the size of an object
+func (c *client) headObject(ctx context.Context, key string) (int64, error) {
+	resp, err := c.request(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read object: %w", err)
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// putObject uploads a small object in one request
+func (c *client) putObject(ctx context.Context, key string, data []byte, contentType string) error {
+	sum := md5.Sum(data)
+	headers := http.Header{
+		"Content-Md5":  {base64.StdEncoding.EncodeToString(sum[:])},
+		"Content-Type": {contentType},
+	}
+	if err := c.do(ctx, http.MethodPut, key, nil, headers, data, nil); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// do sends a request and decodes an XML response into result, when given
+func (c *client) do(ctx context.Context, method, key string, query url.Values, headers http.Header, body []byte, result interface{}) error {
+	resp, err := c.request(ctx, method, key, query, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// request sends a signed request and returns the response, or an *APIError for an
+// error status
+func (c *client) request(ctx context.Context, method, key string, query url.Values, headers http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	path, rawPath := strings.TrimSuffix(u.Path, "/"), strings.TrimSuffix(u.EscapedPath(), "/")
+	if c.pathStyle {
+		path, rawPath = path+"/"+c.bucket, rawPath+"/"+escape(c.bucket)
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	u.Path, u.RawPath = path+"/"+key, rawPath+"/"+escapePath(key)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.credentials.SessionToken)
+	}
+	signRequest(req, c.credentials, c.region, "s3", payloadHash, c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var body struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body) == nil {
+			apiErr.Code, apiErr.Message = body.Code, body.Message
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// signRequest adds an AWS Signature Version 4 Authorization header. The host, the
+// x-amz-* headers and Content-MD5 and Content-Type, when set, are signed.
+func signRequest(req *http.Request, creds Credentials, region, service, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as signed
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, escape(name)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escapePath URI-encodes each segment of an object key
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape URI-encodes a string as Signature Version 4 requires: every byte except
+// letters, digits and "-._~"
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package s3
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EncryptionAES256GCM is the algorithm archives are encrypted with
+const EncryptionAES256GCM = "aes-256-gcm"
+
+// Encrypted archive layout: a header of encryptionMagic and a random nonce prefix, then
+// the archive in segments of up to segmentSize bytes, each sealed with AES-256-GCM. A
+// segment's nonce is the prefix, its big-endian number and a byte marking the last
+// segment, so segments cannot be reordered, dropped or the archive truncated unnoticed.
+const (
+	encryptionMagic = "SNAPAES1"
+	noncePrefixSize = 7
+	segmentSize     = 64 << 10
+	// encryptionExtension is appended to the default key of encrypted archives
+	encryptionExtension = ".enc"
+)
+
+// Encryption encrypts archives on the client before they are uploaded, with Key or with
+// a data key generated per upload by AWS KMS
+type Encryption struct {
+	Key   []byte // 256-bit key, see LoadKey
+	KeyID string // Identifies the key in the manifest (default the key's fingerprint)
+	// KMSKeyID is the AWS KMS key ID, ARN or alias generating a data key per upload,
+	// instead of Key. The data key is stored encrypted in the manifest.
+	KMSKeyID    string
+	KMSEndpoint string // KMS endpoint URL (default https://kms.<region>.amazonaws.com)
+}
+
+// encryptionState is the encryption of a checkpointed upload, kept so a resumed upload
+// encrypts its archive exactly as before
+type encryptionState struct {
+	KeyID            string `json:"key_id"`
+	NoncePrefix      []byte `json:"nonce_prefix"`
+	EncryptedDataKey []byte `json:"encrypted_data_key,omitempty"` // Set for KMS data keys
+}
+
+// LoadKey reads a 256-bit key from a file holding it as 32 raw bytes, 64 hex digits or
+// base64
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key in %s is not 32 bytes, raw, hex or base64", path)
+}
+
+// KeyFingerprint identifies a key without revealing it
+func KeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// segmentNonce returns the nonce of a segment
+func segmentNonce(prefix []byte, number uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, number)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// newNoncePrefix returns a random nonce prefix for an archive
+func newNoncePrefix() ([]byte, error) {
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return prefix, nil
+}
+
+// newGCM returns AES-256-GCM with key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key is %d bytes, expected 32", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptor seals what is written through it into segments. The same key, nonce prefix
+// and input always produce the same output, so an interrupted upload can be resumed.
+type encryptor struct {
+	w      io.Writer
+	gcm    cipher.AEAD
+	prefix []byte
+	buf    []byte
+	number uint32
+}
+
+// newEncryptor writes the header to w and returns a writer encrypting into it. Closing
+// it seals the last segment.
+func newEncryptor(w io.Writer, key, prefix []byte) (io.WriteCloser, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(encryptionMagic), prefix...)); err != nil {
+		return nil, err
+	}
+	return &encryptor{w: w, gcm: gcm, prefix: prefix, buf: make([]byte, 0, segmentSize)}, nil
+}
+
+func (e *encryptor) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full segment is sealed once more data follows, since only the last is marked
+		if len(e.buf) == segmentSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):segmentSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last segment, which is empty when the input is
+func (e *encryptor) Close() error {
+	return e.seal(true)
+}
+
+// seal encrypts and writes the buffered segment
+func (e *encryptor) seal(last bool) error {
+	sealed := e.gcm.Seal(nil, segmentNonce(e.prefix, e.number, last), e.buf, nil)
+	e.number++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptor opens the segments of an encrypted archive
+type decryptor struct {
+	r      io.Reader
+	gcm    cipher.AEAD
+	prefix []byte
+	number uint32
+	sealed []byte // The next sealed segment, read ahead to find the last one
+	plain  []byte
+	done   bool
+}
+
+// NewDecryptor returns a reader decrypting an archive encrypted by the engine with key.
+// Reads fail if the archive was modified or truncated.
+func NewDecryptor(r io.Reader, key []byte) (io.Reader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptionMagic)+noncePrefixSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, errors.New("not an encrypted archive")
+	}
+	return &decryptor{r: r, gcm: gcm, prefix: header[len(encryptionMagic):]}, nil
+}
+
+func (d *decryptor) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open decrypts the next segment. A segment is the last when nothing follows it.
+func (d *decryptor) open() error {
+	size := segmentSize + d.gcm.Overhead()
+	if d.sealed == nil {
+		d.sealed = make([]byte, size)
+		n, err := io.ReadFull(d.r, d.sealed)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			if err == io.EOF {
+				return errors.New("encrypted archive is truncated")
+			}
+			return err
+		}
+		d.sealed = d.sealed[:n]
+	}
+
+	next := make([]byte, size)
+	n, err := io.ReadFull(d.r, next)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	last := n == 0
+
+	plain, err := d.gcm.Open(d.sealed[:0], segmentNonce(d.prefix, d.number, last), d.sealed, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt segment %d: archive is corrupt, truncated or encrypted with another key", d.number)
+	}
+	d.number++
+	d.plain = plain
+	d.done = last
+	d.sealed = next[:n]
+	return nil
+}
@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// kmsClient makes the AWS KMS requests that generate and decrypt archive data keys,
+// signed with AWS Signature Version 4
+type kmsClient struct {
+	http        *http.Client
+	endpoint    string
+	region      string
+	credentials Credentials
+	now         func() time.Time
+}
+
+// newKMSClient returns a client of the KMS endpoint (default https://kms.<region>.amazonaws.com)
+func newKMSClient(endpoint, region string, credentials Credentials, now func() time.Time) (*kmsClient, error) {
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid KMS endpoint '%s'", endpoint)
+	}
+	return &kmsClient{http: &http.Client{}, endpoint: endpoint, region: region, credentials: credentials, now: now}, nil
+}
+
+// generateDataKey returns a new 256-bit data key, in plaintext and encrypted with the
+// KMS key
+func (c *kmsClient) generateDataKey(ctx context.Context, keyID string) (plaintext, encrypted []byte, err error) {
+	var result struct {
+		Plaintext      []byte
+		CiphertextBlob []byte
+	}
+	request := map[string]string{"KeyId": keyID, "KeySpec": "AES_256"}
+	if err := c.do(ctx, "GenerateDataKey", request, &result); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	if len(result.Plaintext) != 32 || len(result.CiphertextBlob) == 0 {
+		return nil, nil, fmt.Errorf("failed to generate data key: KMS returned no 256-bit key")
+	}
+	return result.Plaintext, result.CiphertextBlob, nil
+}
+
+// decrypt returns the plaintext of a data key encrypted by generateDataKey
+func (c *kmsClient) decrypt(ctx context.Context, encrypted []byte) ([]byte, error) {
+	var result struct {
+		Plaintext []byte
+	}
+	if err := c.do(ctx, "Decrypt", map[string][]byte{"CiphertextBlob": encrypted}, &result); err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	return result.Plaintext, nil
+}
+
+// DecryptDataKey decrypts the data key recorded in the manifest of an archive encrypted
+// with a KMS key, for restoring it
+func DecryptDataKey(ctx context.Context, endpoint, region string, credentials Credentials, encrypted []byte) ([]byte, error) {
+	c, err := newKMSClient(endpoint, region, credentials, time.Now)
+	if err != nil {
+		return nil, err
+	}
+	return c.decrypt(ctx, encrypted)
+}
+
+// do sends a KMS action with a JSON request and decodes the JSON response into result.
+// Byte slices are sent and decoded as base64, as KMS expects.
+func (c *kmsClient) do(ctx context.Context, action string, request, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	if c.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.credentials.SessionToken)
+	}
+	sum := sha256.Sum256(body)
+	signRequest(req, c.credentials, c.region, "kms", hex.EncodeToString(sum[:]), c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&failure)
+		return fmt.Errorf("kms request failed with status %d: %s: %s", resp.StatusCode, failure.Type, failure.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
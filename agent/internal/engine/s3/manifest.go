@@ -0,0 +1,40 @@
+package s3
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// manifest describes an uploaded archive. It is uploaded beside the archive as
+// "<key>.manifest.json" once the archive is complete.
+type manifest struct {
+	Node             string    `json:"node"`
+	Bucket           string    `json:"bucket"`
+	Key              string    `json:"key"`
+	CreatedAt        time.Time `json:"created_at"`
+	Compression      string    `json:"compression"`
+	CompressionLevel int       `json:"compression_level,omitempty"` // Omitted for the algorithm's default level
+	RawBytes         int64     `json:"raw_bytes"`                   // Archive size before compression
+	CompressedBytes  int64     `json:"compressed_bytes"`            // Archive size after compression, before any encryption
+	CompressionRatio float64   `json:"compression_ratio"`
+	SHA256           string    `json:"sha256"`
+	Parts            int       `json:"parts"`
+	// Encryption is set for archives encrypted on the client
+	Encryption *ManifestEncryption `json:"encryption,omitempty"`
+}
+
+// ManifestEncryption records how an archive was encrypted, to decrypt it on restore
+type ManifestEncryption struct {
+	Algorithm        string `json:"algorithm"`                    // aes-256-gcm
+	KeyID            string `json:"key_id"`                       // The configured key ID, the key's fingerprint or the KMS key
+	EncryptedDataKey []byte `json:"encrypted_data_key,omitempty"` // The archive's data key encrypted by KMS, in base64
+}
+
+// encode renders the manifest as indented JSON
+func (m manifest) encode() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,846 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults and limits
+const (
+	DefaultRegion      = "us-east-1"
+	DefaultPartSize    = 64 << 20 // 64MiB
+	MinPartSize        = 5 << 20  // The smallest part S3 accepts, except the last
+	MaxPartSize        = 5 << 30
+	DefaultConcurrency = 4
+	// maxParts is the most parts a multipart upload can have
+	maxParts = 10000
+	// partAttempts is how many times a part is sent before the upload fails
+	partAttempts = 3
+	// keyTimestampFormat formats "{timestamp}" in the object key
+	keyTimestampFormat = "20060102T150405Z"
+)
+
+// ObjectArgs are the engine arguments the s3 engine accepts, with the header each sets
+// on the archive when its upload is created
+var ObjectArgs = map[string]string{
+	"storage_class":          "X-Amz-Storage-Class",
+	"acl":                    "X-Amz-Acl",
+	"tagging":                "X-Amz-Tagging",
+	"server_side_encryption": "X-Amz-Server-Side-Encryption",
+	"sse_kms_key_id":         "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+}
+
+// errSourceChanged fails a resumed upload whose archive no longer matches the parts
+// already uploaded
+var errSourceChanged = errors.New("source directory changed since the interrupted upload started; the next upload starts over")
+
+// Config describes a node's upload. "{node}" in the source and key is replaced with the
+// node name, and "{timestamp}" in the key with the upload's start time.
+type Config struct {
+	Source      string      // Local data directory, e.g. /var/lib/{node}/data
+	Endpoint    string      // Storage endpoint URL (default https://s3.<region>.amazonaws.com)
+	Region      string      // Signing region (default us-east-1)
+	Bucket      string      // Destination bucket
+	Key         string      // Object key (default {node}/{timestamp}.tar with the compression's extension, e.g. .tar.gz)
+	PathStyle   bool        // Address the bucket in the path, as MinIO and some other backends require
+	PartSize    int64       // Bytes per part (default 64MiB)
+	Concurrency int         // Parts uploaded at once (default 4)
+	Compression string      // gzip, zstd, lz4 or none (default gzip); zstd and lz4 run the tools of the same name
+	Level       int         // Compression level (default the algorithm's own)
+	Credentials Credentials // Signing credentials (default from the AWS_* environment variables)
+	Encryption  *Encryption // Encrypts the archive before it is uploaded (default not encrypted)
+	// Args sets headers of the archive, such as its storage class, by the names in ObjectArgs
+	Args map[string]string
+	// Checkpoints keeps the uploaded parts (default in memory, so uploads resume after a
+	// failure but not after a restart)
+	Checkpoints engine.CheckpointStore
+}
+
+// EnvCredentials returns the credentials in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN
+func EnvCredentials() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// transfer is a node's current or last upload
+type transfer struct {
+	cancel     context.CancelFunc
+	cancelled  bool // Cancelled by request; the multipart upload is aborted
+	stopped    bool // Stopped with the daemon; the multipart upload is kept to resume
+	startedAt  time.Time
+	finishedAt *time.Time
+	err        error
+
+	state         *state
+	total         int64        // Bytes in the source directory
+	read          atomic.Int64 // Source bytes archived so far
+	raw           atomic.Int64 // Archive bytes before compression
+	compressed    atomic.Int64 // Archive bytes after compression, before encryption
+	archived      bool         // The whole archive has been read
+	partsRead     int
+	partsUploaded int
+	size          int64                     // Archive size, once uploaded
+	checksum      string                    // Archive SHA-256, once uploaded
+	compression   *engine.CompressionReport // Archive sizes before and after compression, once uploaded
+}
+
+// Engine uploads a node's data directory to S3-compatible storage as a tar archive,
+// without bv or any other tool. The archive is streamed in parts, each verified by the
+// backend with its MD5; the uploaded parts are checkpointed so an upload interrupted by
+// a failure or a restart resumes with the parts not yet sent. A "<key>.sha256" object
+// with the archive's checksum and a "<key>.manifest.json" object describing it, including
+// its compression ratio, are uploaded beside it. The archive can be encrypted before
+// it leaves the host. A log of the node's last upload is kept in the state directory.
+type Engine struct {
+	cfg         Config
+	client      *client
+	kms         *kmsClient // Set for encryption with KMS data keys
+	checkpoints engine.CheckpointStore
+	stateDir    string
+	logger      *logrus.Logger
+	now         func() time.Time
+	retryDelay  time.Duration
+
+	mu        sync.Mutex
+	transfers map[string]*transfer
+	wg        sync.WaitGroup
+}
+
+// New creates an S3 engine
+func New(cfg Config, logger *logrus.Logger) (*Engine, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	if cfg.Compression == "" {
+		cfg.Compression = engine.CompressionGzip
+	}
+	extension, ok := archiveExtensions[cfg.Compression]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression '%s'", cfg.Compression)
+	}
+	for name := range cfg.Args {
+		if _, ok := ObjectArgs[name]; !ok {
+			return nil, fmt.Errorf("unsupported engine argument '%s'", name)
+		}
+	}
+	if cfg.Key == "" {
+		cfg.Key = "{node}/{timestamp}" + extension
+		if cfg.Encryption != nil {
+			cfg.Key += encryptionExtension
+		}
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.Credentials.AccessKeyID == "" {
+		cfg.Credentials = EnvCredentials()
+	}
+	if cfg.Checkpoints == nil {
+		cfg.Checkpoints = engine.NewMemoryCheckpoints()
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint '%s'", cfg.Endpoint)
+	}
+
+	e := &Engine{
+		cfg:         cfg,
+		checkpoints: cfg.Checkpoints,
+		stateDir:    filepath.Join(os.TempDir(), "snapperd-s3"),
+		logger:      logger,
+		now:         time.Now,
+		retryDelay:  2 * time.Second,
+		transfers:   make(map[string]*transfer),
+	}
+	if cfg.Encryption != nil {
+		encryption := *cfg.Encryption
+		switch {
+		case encryption.KMSKeyID != "" && encryption.Key != nil:
+			return nil, errors.New("encryption takes a key or a KMS key, not both")
+		case encryption.KMSKeyID != "":
+			if encryption.KeyID != "" && encryption.KeyID != encryption.KMSKeyID {
+				return nil, errors.New("the key ID of KMS encryption is the KMS key")
+			}
+			encryption.KeyID = encryption.KMSKeyID
+			e.kms, err = newKMSClient(encryption.KMSEndpoint, cfg.Region, cfg.Credentials, func() time.Time { return e.now() })
+			if err != nil {
+				return nil, err
+			}
+		case len(encryption.Key) != 32:
+			return nil, fmt.Errorf("encryption key is %d bytes, expected 32", len(encryption.Key))
+		case encryption.KeyID == "":
+			encryption.KeyID = KeyFingerprint(encryption.Key)
+		}
+		e.cfg.Encryption = &encryption
+	}
+
+	e.client = &client{
+		http:        &http.Client{Transport: &tracing.Transport{}},
+		endpoint:    endpoint,
+		region:      cfg.Region,
+		bucket:      cfg.Bucket,
+		pathStyle:   cfg.PathStyle,
+		credentials: cfg.Credentials,
+		now:         func() time.Time { return e.now() },
+	}
+	return e, nil
+}
+
+// Name returns the engine name
+func (e *Engine) Name() string {
+	return engine.S3
+}
+
+// StartUpload starts the node's upload in the background, resuming its interrupted
+// upload if there is one
+func (e *Engine) StartUpload(ctx context.Context, nodeName string) error {
+	if e.cfg.Credentials.AccessKeyID == "" || e.cfg.Credentials.SecretAccessKey == "" {
+		return errors.New("no S3 credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if t, ok := e.transfers[nodeName]; ok && t.finishedAt == nil {
+		return fmt.Errorf("S3 upload already running for node %s", nodeName)
+	}
+
+	if err := os.MkdirAll(e.stateDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create S3 state directory: %w", err)
+	}
+	if err := os.Remove(e.logPath(nodeName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous S3 upload log: %w", err)
+	}
+
+	// The upload outlives the request that started it
+	runCtx, cancel := context.WithCancel(context.Background())
+	t := &transfer{cancel: cancel, startedAt: e.now()}
+	e.transfers[nodeName] = t
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer cancel()
+
+		err := e.upload(runCtx, nodeName, t)
+
+		e.mu.Lock()
+		finishedAt := e.now()
+		t.finishedAt = &finishedAt
+		// An upload that completed as it was cancelled or stopped is kept
+		t.cancelled = t.cancelled && err != nil
+		t.stopped = t.stopped && err != nil
+		cancelled, stopped := t.cancelled, t.stopped
+		if err != nil && !cancelled && !stopped {
+			t.err = err
+		}
+		st := t.state
+		e.mu.Unlock()
+
+		// A cancelled upload is discarded; one that failed resumes on the next upload
+		// unless its source has changed
+		if st != nil && (cancelled || errors.Is(err, errSourceChanged)) {
+			abortCtx, abortCancel := context.WithTimeout(context.Background(), time.Minute)
+			if abortErr := e.client.abortMultipartUpload(abortCtx, st.Key, st.UploadID); abortErr != nil {
+				e.record(nodeName, "Could not abort multipart upload %s: %v", st.UploadID, abortErr)
+			}
+			if removeErr := removeState(abortCtx, e.checkpoints, nodeName); removeErr != nil {
+				e.record(nodeName, "%v", removeErr)
+			}
+			abortCancel()
+		}
+
+		fields := logrus.Fields{
+			"component": "engine",
+			"engine":    engine.S3,
+			"node":      nodeName,
+			"cancelled": cancelled,
+		}
+		if st != nil {
+			fields["key"] = st.Key
+		}
+		if err != nil && !cancelled && !stopped {
+			fields["error"] = err.Error()
+		}
+		e.logger.WithFields(fields).Info("S3 upload finished")
+	}()
+
+	e.logger.WithFields(logrus.Fields{
+		"component": "engine",
+		"engine":    engine.S3,
+		"node":      nodeName,
+		"bucket":    e.cfg.Bucket,
+	}).Info("S3 upload started")
+
+	return nil
+}
+
+// ResumeUpload resumes the node's checkpointed upload after a restart of the daemon. It
+// returns false when the node has no checkpoint or already has an upload in this process.
+func (e *Engine) ResumeUpload(ctx context.Context, nodeName string) (bool, error) {
+	e.mu.Lock()
+	_, exists := e.transfers[nodeName]
+	e.mu.Unlock()
+	if exists {
+		return false, nil
+	}
+
+	st, err := loadState(ctx, e.checkpoints, nodeName)
+	if err != nil || st == nil {
+		return false, err
+	}
+	if err := e.StartUpload(ctx, nodeName); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// chunk is a part of the archive waiting to be uploaded
+type chunk struct {
+	number int
+	data   []byte
+	md5    string
+}
+
+// upload archives the node's source directory and uploads it, resuming a saved upload
+func (e *Engine) upload(ctx context.Context, nodeName string, t *transfer) error {
+	source := strings.ReplaceAll(e.cfg.Source, "{node}", nodeName)
+	total, err := sourceSize(source)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	t.total = total
+	e.mu.Unlock()
+
+	st, key, err := e.prepare(ctx, nodeName, t)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	archiveDone := make(chan struct{})
+	go func() {
+		defer close(archiveDone)
+		pw.CloseWithError(writeEncryptedArchive(pw, source, st, key, t))
+	}()
+	defer func() {
+		pr.Close()
+		<-archiveDone
+	}()
+
+	uploadCtx, cancelUploads := context.WithCancel(ctx)
+	defer cancelUploads()
+	var uploadErr error
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			uploadErr = err
+			cancelUploads()
+		})
+	}
+
+	chunks := make(chan chunk)
+	var workers sync.WaitGroup
+	for i := 0; i < e.cfg.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for c := range chunks {
+				if err := e.uploadPart(uploadCtx, nodeName, t, st, c); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	hash := sha256.New()
+	var size int64
+	var number int
+	readErr := func() error {
+		for {
+			buf := make([]byte, e.cfg.PartSize)
+			n, err := io.ReadFull(pr, buf)
+			if n > 0 {
+				number++
+				if number > maxParts {
+					return fmt.Errorf("archive needs more than %d parts; increase the part size", maxParts)
+				}
+				data := buf[:n]
+				hash.Write(data)
+				size += int64(n)
+				sum := md5.Sum(data)
+				c := chunk{number: number, data: data, md5: base64.StdEncoding.EncodeToString(sum[:])}
+
+				e.mu.Lock()
+				t.partsRead = number
+				saved, resumed := st.part(number)
+				if resumed && saved.MD5 == c.md5 && saved.Size == int64(n) {
+					t.partsUploaded++
+				}
+				e.mu.Unlock()
+				if resumed {
+					if saved.MD5 != c.md5 || saved.Size != int64(n) {
+						return errSourceChanged
+					}
+					continue
+				}
+
+				select {
+				case chunks <- c:
+				case <-uploadCtx.Done():
+					return nil
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}()
+	if readErr != nil {
+		cancelUploads()
+	}
+	close(chunks)
+	workers.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case uploadErr != nil:
+		return uploadErr
+	case readErr != nil:
+		return readErr
+	}
+
+	e.mu.Lock()
+	t.archived = true
+	var parts []part
+	for _, p := range st.Parts {
+		if p.Number <= number {
+			parts = append(parts, p)
+		}
+	}
+	e.mu.Unlock()
+	if len(parts) != number {
+		return fmt.Errorf("only %d of %d parts were uploaded", len(parts), number)
+	}
+
+	if _, err := e.client.completeMultipartUpload(ctx, st.Key, st.UploadID, parts); err != nil {
+		return err
+	}
+	objectSize, err := e.client.headObject(ctx, st.Key)
+	if err != nil {
+		return err
+	}
+	if objectSize != size {
+		return fmt.Errorf("uploaded object is %d bytes, expected %d", objectSize, size)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	sidecar := fmt.Sprintf("%s  %s\n", checksum, path.Base(st.Key))
+	if err := e.client.putObject(ctx, st.Key+".sha256", []byte(sidecar), "text/plain"); err != nil {
+		return err
+	}
+
+	report := engine.CompressionReport{Algorithm: st.Compression, Level: st.Level, RawBytes: t.raw.Load(), CompressedBytes: t.compressed.Load()}
+	var encryption *ManifestEncryption
+	if st.Encryption != nil {
+		encryption = &ManifestEncryption{Algorithm: EncryptionAES256GCM, KeyID: st.Encryption.KeyID, EncryptedDataKey: st.Encryption.EncryptedDataKey}
+	}
+	m, err := manifest{
+		Node:             nodeName,
+		Bucket:           st.Bucket,
+		Key:              st.Key,
+		CreatedAt:        t.startedAt.UTC(),
+		Compression:      report.Algorithm,
+		CompressionLevel: report.Level,
+		RawBytes:         report.RawBytes,
+		CompressedBytes:  report.CompressedBytes,
+		CompressionRatio: math.Round(report.Ratio()*100) / 100,
+		SHA256:           checksum,
+		Parts:            number,
+		Encryption:       encryption,
+	}.encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := e.client.putObject(ctx, st.Key+".manifest.json", m, "application/json"); err != nil {
+		return err
+	}
+	if err := removeState(ctx, e.checkpoints, nodeName); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	t.size = size
+	t.checksum = checksum
+	t.compression = &report
+	e.mu.Unlock()
+	e.record(nodeName, "Uploaded s3://%s/%s: %d parts, %d bytes (%d before %s compression, ratio %.2f), sha256 %s",
+		e.cfg.Bucket, st.Key, number, size, report.RawBytes, report.Algorithm, report.Ratio(), checksum)
+	if encryption != nil {
+		e.record(nodeName, "Archive encrypted with %s, key %s", encryption.Algorithm, encryption.KeyID)
+	}
+	return nil
+}
+
+// writeEncryptedArchive writes the node's archive to w, encrypted with key when the
+// upload is encrypted
+func writeEncryptedArchive(w io.Writer, source string, st *state, key []byte, t *transfer) error {
+	out := io.WriteCloser(nopWriteCloser{w})
+	if st.Encryption != nil {
+		encryptor, err := newEncryptor(w, key, st.Encryption.NoncePrefix)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt archive: %w", err)
+		}
+		out = encryptor
+	}
+	if err := writeArchive(countingWriter{w: out, count: &t.compressed}, source, st.Compression, st.Level, &t.read, &t.raw); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt archive: %w", err)
+	}
+	return nil
+}
+
+// prepare resumes the node's checkpointed multipart upload, keeping the parts the
+// backend still has, or creates a new one. It returns the key the archive is encrypted
+// with, or nil without encryption.
+func (e *Engine) prepare(ctx context.Context, nodeName string, t *transfer) (*state, []byte, error) {
+	st, err := loadState(ctx, e.checkpoints, nodeName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if st != nil && st.matches(e.cfg) {
+		uploaded, err := e.client.listParts(ctx, st.Key, st.UploadID)
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			etags := make(map[int]string, len(uploaded))
+			for _, p := range uploaded {
+				etags[p.Number] = p.ETag
+			}
+			var kept []part
+			for _, p := range st.Parts {
+				if etags[p.Number] == p.ETag {
+					kept = append(kept, p)
+				}
+			}
+			st.Parts = kept
+			key, err := e.encryptionKey(ctx, st.Encryption)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := st.save(ctx, e.checkpoints, nodeName); err != nil {
+				return nil, nil, err
+			}
+			e.mu.Lock()
+			t.state = st
+			e.mu.Unlock()
+			e.record(nodeName, "Resuming upload of s3://%s/%s with %d parts already uploaded", st.Bucket, st.Key, len(kept))
+			return st, key, nil
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+			e.record(nodeName, "Interrupted upload of s3://%s/%s no longer exists; starting over", st.Bucket, st.Key)
+		default:
+			return nil, nil, err
+		}
+	} else if st != nil {
+		// The saved upload was made with other settings and cannot be resumed
+		if err := e.client.abortMultipartUpload(ctx, st.Key, st.UploadID); err != nil {
+			e.record(nodeName, "Could not abort multipart upload %s: %v", st.UploadID, err)
+		}
+	}
+
+	key := strings.NewReplacer(
+		"{node}", nodeName,
+		"{timestamp}", t.startedAt.UTC().Format(keyTimestampFormat),
+	).Replace(e.cfg.Key)
+	encryption, dataKey, err := e.newEncryption(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	uploadID, err := e.client.createMultipartUpload(ctx, key, e.objectHeaders())
+	if err != nil {
+		return nil, nil, err
+	}
+	st = &state{
+		Bucket:      e.cfg.Bucket,
+		Key:         key,
+		UploadID:    uploadID,
+		PartSize:    e.cfg.PartSize,
+		Compression: e.cfg.Compression,
+		Level:       e.cfg.Level,
+		Encryption:  encryption,
+	}
+	if err := st.save(ctx, e.checkpoints, nodeName); err != nil {
+		return nil, nil, err
+	}
+	e.mu.Lock()
+	t.state = st
+	e.mu.Unlock()
+	e.record(nodeName, "Started upload of s3://%s/%s", st.Bucket, key)
+	return st, dataKey, nil
+}
+
+// objectHeaders returns the headers the engine arguments set on the archive
+func (e *Engine) objectHeaders() http.Header {
+	headers := make(http.Header, len(e.cfg.Args))
+	for name, value := range e.cfg.Args {
+		headers.Set(ObjectArgs[name], value)
+	}
+	return headers
+}
+
+// newEncryption sets up the encryption of a new upload: a random nonce prefix and, with
+// KMS, a new data key. It returns nil without encryption.
+func (e *Engine) newEncryption(ctx context.Context) (*encryptionState, []byte, error) {
+	if e.cfg.Encryption == nil {
+		return nil, nil, nil
+	}
+	prefix, err := newNoncePrefix()
+	if err != nil {
+		return nil, nil, err
+	}
+	encryption := &encryptionState{KeyID: e.cfg.Encryption.KeyID, NoncePrefix: prefix}
+	if e.kms == nil {
+		return encryption, e.cfg.Encryption.Key, nil
+	}
+	dataKey, encrypted, err := e.kms.generateDataKey(ctx, e.cfg.Encryption.KMSKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryption.EncryptedDataKey = encrypted
+	return encryption, dataKey, nil
+}
+
+// encryptionKey returns the key a checkpointed upload is encrypted with, decrypting its
+// KMS data key
+func (e *Engine) encryptionKey(ctx context.Context, encryption *encryptionState) ([]byte, error) {
+	switch {
+	case encryption == nil:
+		return nil, nil
+	case encryption.EncryptedDataKey == nil:
+		return e.cfg.Encryption.Key, nil
+	case e.kms == nil:
+		return nil, errors.New("the interrupted upload was encrypted with a KMS data key, but KMS is not configured")
+	}
+	return e.kms.decrypt(ctx, encryption.EncryptedDataKey)
+}
+
+// uploadPart sends a part, retrying transient failures, and checkpoints it
+func (e *Engine) uploadPart(ctx context.Context, nodeName string, t *transfer, st *state, c chunk) error {
+	var etag string
+	var err error
+	for attempt := 1; attempt <= partAttempts; attempt++ {
+		etag, err = e.client.uploadPart(ctx, st.Key, st.UploadID, c.number, c.data)
+		if err == nil || ctx.Err() != nil || !retryable(err) || attempt == partAttempts {
+			break
+		}
+		e.record(nodeName, "Part %d attempt %d: %v", c.number, attempt, err)
+		select {
+		case <-time.After(e.retryDelay * time.Duration(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// The part is checkpointed even when the upload is being stopped, so it is not sent
+	// again on resume
+	uploaded := part{Number: c.number, ETag: etag, Size: int64(len(c.data)), MD5: c.md5}
+	if err := e.checkpoints.AddCheckpointChunk(context.WithoutCancel(ctx), nodeName, uploaded.chunk()); err != nil {
+		return fmt.Errorf("failed to checkpoint part %d: %w", c.number, err)
+	}
+
+	e.mu.Lock()
+	st.setPart(uploaded)
+	t.partsUploaded++
+	e.mu.Unlock()
+	return nil
+}
+
+// retryable reports whether a failed request may succeed if sent again
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// Status reports the node's upload
+func (e *Engine) Status(ctx context.Context, nodeName string) (*engine.Status, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, ok := e.transfers[nodeName]
+	if !ok {
+		// A record of a running upload without a transfer was interrupted by a restart
+		msg := "Failed: no S3 upload has run for the node since snapperd started"
+		if st, _ := loadState(ctx, e.checkpoints, nodeName); st != nil {
+			msg += "; the next upload resumes the interrupted one"
+		}
+		return &engine.Status{NotFound: true, Status: msg}, nil
+	}
+
+	status := &engine.Status{Fields: map[string]string{"bucket": e.cfg.Bucket}}
+	if t.state != nil {
+		status.Fields["key"] = t.state.Key
+		status.Fields["upload_id"] = t.state.UploadID
+		status.Location = "s3://" + e.cfg.Bucket + "/" + t.state.Key
+	}
+
+	read := t.read.Load()
+	if t.total > 0 {
+		percent := math.Min(float64(read)/float64(t.total)*100, 100)
+		status.ProgressPercent = &percent
+	}
+	// The number of parts is known once the archive has been read; until then it is
+	// estimated from the share of the source archived so far
+	if t.partsRead > 0 {
+		total := t.partsRead
+		if !t.archived && read > 0 && read < t.total {
+			total = int(math.Ceil(float64(t.partsRead) * float64(t.total) / float64(read)))
+		}
+		completed := t.partsUploaded
+		status.ChunksCompleted = &completed
+		status.ChunksTotal = &total
+	}
+	if status.ProgressPercent != nil && status.ChunksTotal != nil {
+		status.Progress = fmt.Sprintf("%.2f%% (%d/%d parts uploaded)", *status.ProgressPercent, *status.ChunksCompleted, *status.ChunksTotal)
+	}
+	if t.checksum != "" {
+		status.Fields["size"] = strconv.FormatInt(t.size, 10)
+		status.Fields["sha256"] = t.checksum
+		size := t.size
+		status.SizeBytes = &size
+		status.Compression = t.compression
+	}
+
+	switch {
+	case t.finishedAt == nil:
+		status.Running = true
+		status.SetState("Running", t.startedAt)
+	case t.cancelled:
+		status.SetState("Cancelled", *t.finishedAt)
+	case t.stopped:
+		status.SetState("Stopped with snapperd; resumed from its checkpoint", *t.finishedAt)
+	case t.err != nil:
+		status.SetState("Failed: "+t.err.Error(), *t.finishedAt)
+	default:
+		status.SetState("Finished with exit code 0", *t.finishedAt)
+	}
+
+	return status, nil
+}
+
+// Cancel stops the node's running upload and discards its uploaded parts
+func (e *Engine) Cancel(ctx context.Context, nodeName string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, ok := e.transfers[nodeName]
+	if !ok || t.finishedAt != nil {
+		return fmt.Errorf("no S3 upload running for node %s", nodeName)
+	}
+	t.cancelled = true
+	t.cancel()
+	return nil
+}
+
+// Logs returns the log of the node's last upload, which outlives the process that ran it
+func (e *Engine) Logs(ctx context.Context, nodeName string) (string, error) {
+	data, err := os.ReadFile(e.logPath(nodeName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read S3 upload log: %w", err)
+	}
+	return string(data), nil
+}
+
+// Stop stops every running upload, keeping their state to resume, and waits for them
+// to exit
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	for _, t := range e.transfers {
+		if t.finishedAt == nil {
+			t.stopped = true
+			t.cancel()
+		}
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+// record adds a line to the log of the node's upload
+func (e *Engine) record(nodeName string, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fields := logrus.Fields{
+		"component": "engine",
+		"engine":    engine.S3,
+		"node":      nodeName,
+	}
+	e.logger.WithFields(fields).Debug(msg)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f, err := os.OpenFile(e.logPath(nodeName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err == nil {
+		_, err = fmt.Fprintf(f, "%s| %s\n", e.now().UTC().Format(engine.StatusTimeFormat), msg)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		e.logger.WithFields(fields).Warn("Failed to write S3 upload log")
+	}
+}
+
+// logPath returns the path of the log of a node's last upload
+func (e *Engine) logPath(nodeName string) string {
+	return filepath.Join(e.stateDir, filepath.Base(nodeName)+".log")
+}
@@ -0,0 +1,634 @@
+package s3
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSignRequest(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signRequest(req, creds, "us-east-1", "service", emptyPayloadHash, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("unexpected signature:\n got %s\nwant %s", got, expected)
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got := escapePath("eth-1/snap shot+1.tar.gz"); got != "eth-1/snap%20shot%2B1.tar.gz" {
+		t.Errorf("unexpected escaped key: %s", got)
+	}
+}
+
+// fakeS3 is an in-memory S3-compatible backend serving path-style multipart uploads
+type fakeS3 struct {
+	mu      sync.Mutex
+	nextID  int
+	uploads map[string]map[int][]byte // Parts by upload ID
+	objects map[string][]byte
+	aborted []string
+	created []http.Header // Headers of each multipart upload created
+	sent    map[int]int   // Times each part number was uploaded
+	// failPart, when set, decides the status returned for an upload of a part
+	failPart func(number int) int
+	// block, when set, holds part uploads until it is closed
+	block chan struct{}
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{uploads: make(map[string]map[int][]byte), objects: make(map[string][]byte), sent: make(map[int]int)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/snapshots/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	uploadID := query.Get("uploadId")
+
+	writeError := func(status int, code string) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+	}
+
+	if r.Method == http.MethodPut && query.Has("partNumber") && f.block != nil {
+		select {
+		case <-f.block:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts, uploadExists := f.uploads[uploadID]
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		f.created = append(f.created, r.Header.Clone())
+		id := "upload-" + strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		f.sent[number]++
+		if !uploadExists {
+			writeError(http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		if f.failPart != nil {
+			if status := f.failPart(number); status != 0 {
+				writeError(status, "InjectedFailure")
+				return
+			}
+		}
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			writeError(http.StatusBadRequest, "BadDigest")
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum))
+
+	case r.Method == http.MethodGet && uploadID != "":
+		if !uploadExists {
+			writeError(http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		fmt.Fprint(w, "<ListPartsResult>")
+		for number, data := range parts {
+			fmt.Fprintf(w, `<Part><PartNumber>%d</PartNumber><ETag>"%x"</ETag><Size>%d</Size></Part>`, number, md5.Sum(data), len(data))
+		}
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListPartsResult>")
+
+	case r.Method == http.MethodPost && uploadID != "":
+		if !uploadExists {
+			writeError(http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			writeError(http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var object []byte
+		for i, p := range complete.Parts {
+			data, ok := parts[p.PartNumber]
+			if !ok || p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"%x"`, md5.Sum(data)) {
+				writeError(http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			object = append(object, data...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, uploadID)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult><ETag>\"done\"</ETag></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete && uploadID != "":
+		f.aborted = append(f.aborted, uploadID)
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+
+	default:
+		writeError(http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// object returns an uploaded object
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// writeSource creates a data directory with files large enough to span several parts
+func writeSource(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "eth-1")
+	if err := os.MkdirAll(filepath.Join(dir, "chain"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"chain/000001.ldb": 3000, "chain/000002.ldb": 2500, "config.toml": 100} {
+		data := bytes.Repeat([]byte(name[len(name)-5:]), size/5)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// newTestEngine creates an engine uploading dir through the fake backend in 1KiB parts
+func newTestEngine(t *testing.T, server *httptest.Server, source string, checkpoints engine.CheckpointStore) *Engine {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	e, err := New(Config{
+		Source:      filepath.Join(filepath.Dir(source), "{node}"),
+		Endpoint:    server.URL,
+		Bucket:      "snapshots",
+		Key:         "{node}/{timestamp}.tar",
+		PathStyle:   true,
+		PartSize:    1024,
+		Concurrency: 2,
+		Compression: engine.CompressionNone,
+		Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Checkpoints: checkpoints,
+	}, logger)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	e.stateDir = t.TempDir()
+	e.retryDelay = time.Millisecond
+	e.now = func() time.Time { return time.Date(2025, 12, 10, 15, 18, 44, 0, time.UTC) }
+	return e
+}
+
+// waitFinished waits for a node's upload to end
+func waitFinished(t *testing.T, e *Engine, nodeName string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := e.Status(context.Background(), nodeName)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if !status.Running {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("upload did not finish")
+}
+
+// archivedFiles returns the regular files in a tar archive
+func archivedFiles(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("invalid archive: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			content, _ := io.ReadAll(tr)
+			files[header.Name] = string(content)
+		}
+	}
+}
+
+func TestEngine_Upload(t *testing.T) {
+	fake := newFakeS3()
+	failures := 0
+	// The first attempt at part 2 fails with a transient error and is retried
+	fake.failPart = func(number int) int {
+		if number == 2 && failures == 0 {
+			failures++
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	source := writeSource(t)
+	checkpoints := engine.NewMemoryCheckpoints()
+	e := newTestEngine(t, server, source, checkpoints)
+	ctx := context.Background()
+
+	status, err := e.Status(ctx, "eth-1")
+	if err != nil || !status.NotFound || !strings.HasPrefix(status.Status, "Failed") {
+		t.Fatalf("expected no upload for the node, got %+v, %v", status, err)
+	}
+
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+
+	status, _ = e.Status(ctx, "eth-1")
+	if status.State != "Finished with exit code 0" {
+		logs, _ := e.Logs(ctx, "eth-1")
+		t.Fatalf("expected a successful upload, got %q\n%s", status.State, logs)
+	}
+	key := "eth-1/20251210T151844Z.tar"
+	if status.Fields["key"] != key {
+		t.Errorf("expected key %s, got %q", key, status.Fields["key"])
+	}
+	if status.Location != "s3://"+e.cfg.Bucket+"/"+key {
+		t.Errorf("expected the archive's location, got %q", status.Location)
+	}
+	if status.ProgressPercent == nil || *status.ProgressPercent != 100 || status.ChunksCompleted == nil || *status.ChunksCompleted != *status.ChunksTotal {
+		t.Errorf("expected complete progress, got %q", status.Progress)
+	}
+
+	object, ok := fake.object(key)
+	if !ok {
+		t.Fatal("expected the archive to be uploaded")
+	}
+	files := archivedFiles(t, object)
+	if len(files) != 3 || len(files["chain/000001.ldb"]) != 3000 || files["config.toml"] != strings.Repeat(".toml", 20) {
+		t.Errorf("unexpected archive contents: %d files", len(files))
+	}
+	sum := sha256.Sum256(object)
+	if sidecar, _ := fake.object(key + ".sha256"); string(sidecar) != hex.EncodeToString(sum[:])+"  20251210T151844Z.tar\n" {
+		t.Errorf("unexpected checksum object: %q", sidecar)
+	}
+	if status.Fields["sha256"] != hex.EncodeToString(sum[:]) || status.Fields["size"] != strconv.Itoa(len(object)) ||
+		status.SizeBytes == nil || *status.SizeBytes != int64(len(object)) {
+		t.Errorf("expected the checksum and size in the status fields, got %v", status.Fields)
+	}
+	want := engine.CompressionReport{Algorithm: engine.CompressionNone, RawBytes: int64(len(object)), CompressedBytes: int64(len(object))}
+	if status.Compression == nil || *status.Compression != want {
+		t.Errorf("expected compression report %+v, got %+v", want, status.Compression)
+	}
+	var m manifest
+	data, _ := fake.object(key + ".manifest.json")
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("invalid manifest %q: %v", data, err)
+	}
+	if m.Node != "eth-1" || m.Key != key || m.SHA256 != hex.EncodeToString(sum[:]) || m.CompressionRatio != 1 || m.Parts != 9 {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+	if checkpoint, _ := checkpoints.GetCheckpoint(ctx, "eth-1"); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be removed, got %+v", checkpoint)
+	}
+}
+
+func TestEngine_Compression(t *testing.T) {
+	tests := []struct {
+		algorithm  string
+		level      int
+		decompress func(data []byte) ([]byte, error)
+	}{
+		{algorithm: engine.CompressionGzip, level: 9, decompress: func(data []byte) ([]byte, error) {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(gz)
+		}},
+		{algorithm: engine.CompressionZstd, level: 19},
+		{algorithm: engine.CompressionLz4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			if binary, ok := compressorBinaries[tt.algorithm]; ok {
+				if _, err := exec.LookPath(binary); err != nil {
+					t.Skipf("%s is not installed", binary)
+				}
+				// The tool decompresses its own output
+				tt.decompress = func(data []byte) ([]byte, error) {
+					cmd := exec.Command(binary, "-d", "-c")
+					cmd.Stdin = bytes.NewReader(data)
+					return cmd.Output()
+				}
+			}
+
+			fake := newFakeS3()
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			source := writeSource(t)
+			e := newTestEngine(t, server, source, nil)
+			e.cfg.Compression, e.cfg.Level = tt.algorithm, tt.level
+			e.cfg.Key = "{node}/{timestamp}" + archiveExtensions[tt.algorithm]
+			ctx := context.Background()
+
+			if err := e.StartUpload(ctx, "eth-1"); err != nil {
+				t.Fatalf("StartUpload failed: %v", err)
+			}
+			waitFinished(t, e, "eth-1")
+			status, _ := e.Status(ctx, "eth-1")
+			if status.State != "Finished with exit code 0" {
+				logs, _ := e.Logs(ctx, "eth-1")
+				t.Fatalf("expected a successful upload, got %q\n%s", status.State, logs)
+			}
+
+			object, ok := fake.object(status.Fields["key"])
+			if !ok {
+				t.Fatalf("expected the archive to be uploaded as %s", status.Fields["key"])
+			}
+			archive, err := tt.decompress(object)
+			if err != nil {
+				t.Fatalf("failed to decompress the archive: %v", err)
+			}
+			if files := archivedFiles(t, archive); len(files) != 3 {
+				t.Errorf("unexpected archive contents: %d files", len(files))
+			}
+
+			c := status.Compression
+			if c == nil || c.Algorithm != tt.algorithm || c.Level != tt.level || c.RawBytes != int64(len(archive)) || c.CompressedBytes != int64(len(object)) || c.Ratio() <= 1 {
+				t.Errorf("unexpected compression report %+v for %d bytes compressed to %d", c, len(archive), len(object))
+			}
+		})
+	}
+}
+
+func TestEngine_Resume(t *testing.T) {
+	fake := newFakeS3()
+	// Part 4 is rejected until the backend is fixed
+	var broken = true
+	fake.failPart = func(number int) int {
+		if number == 4 && broken {
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	source := writeSource(t)
+	checkpoints := engine.NewMemoryCheckpoints()
+	ctx := context.Background()
+
+	e := newTestEngine(t, server, source, checkpoints)
+	e.cfg.Concurrency = 1
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+	status, _ := e.Status(ctx, "eth-1")
+	if !strings.Contains(status.State, "Failed: failed to upload part 4") || !strings.Contains(status.State, "InjectedFailure") {
+		t.Fatalf("expected part 4 to fail the upload, got %q", status.State)
+	}
+	if fake.sent[4] != 1 {
+		t.Errorf("expected a rejected part not to be retried, sent %d times", fake.sent[4])
+	}
+
+	checkpoint, _ := checkpoints.GetCheckpoint(ctx, "eth-1")
+	if checkpoint == nil || checkpoint.Engine != engine.S3 || len(checkpoint.Chunks) != 3 {
+		t.Fatalf("expected the 3 uploaded parts to be checkpointed, got %+v", checkpoint)
+	}
+
+	// After a restart the interrupted upload is reported and then resumed
+	e = newTestEngine(t, server, source, checkpoints)
+	status, _ = e.Status(ctx, "eth-1")
+	if !status.NotFound || !strings.Contains(status.Status, "resumes the interrupted one") {
+		t.Errorf("expected the interrupted upload to be reported, got %q", status.Status)
+	}
+
+	fake.mu.Lock()
+	broken = false
+	fake.mu.Unlock()
+	if resumed, err := e.ResumeUpload(ctx, "eth-1"); !resumed || err != nil {
+		t.Fatalf("ResumeUpload() = %v, %v", resumed, err)
+	}
+	waitFinished(t, e, "eth-1")
+	if resumed, err := e.ResumeUpload(ctx, "eth-1"); resumed || err != nil {
+		t.Errorf("expected nothing left to resume, got %v, %v", resumed, err)
+	}
+	status, _ = e.Status(ctx, "eth-1")
+	if status.State != "Finished with exit code 0" {
+		t.Fatalf("expected the resumed upload to finish, got %q", status.State)
+	}
+	var resent []int
+	for number, count := range fake.sent {
+		if number < 4 && count > 1 {
+			resent = append(resent, number)
+		}
+	}
+	sort.Ints(resent)
+	if len(resent) > 0 {
+		t.Errorf("expected uploaded parts to be kept, parts %v were sent again", resent)
+	}
+	if logs, _ := e.Logs(ctx, "eth-1"); !strings.Contains(logs, "Resuming upload of s3://snapshots/eth-1/20251210T151844Z.tar with 3 parts already uploaded") {
+		t.Errorf("expected the resume to be logged, got %q", logs)
+	}
+	object, _ := fake.object("eth-1/20251210T151844Z.tar")
+	if files := archivedFiles(t, object); len(files["chain/000002.ldb"]) != 2500 {
+		t.Errorf("unexpected archive contents: %d files", len(files))
+	}
+}
+
+func TestEngine_SourceChanged(t *testing.T) {
+	fake := newFakeS3()
+	fake.failPart = func(number int) int {
+		if number == 3 {
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	source := writeSource(t)
+	checkpoints := engine.NewMemoryCheckpoints()
+	ctx := context.Background()
+
+	e := newTestEngine(t, server, source, checkpoints)
+	e.cfg.Concurrency = 1
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+
+	// A changed source no longer matches the uploaded parts, so the upload is discarded
+	if err := os.WriteFile(filepath.Join(source, "chain/000001.ldb"), bytes.Repeat([]byte("x"), 3000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+	e.Stop()
+	status, _ := e.Status(ctx, "eth-1")
+	if !strings.Contains(status.State, "source directory changed") {
+		t.Errorf("expected a changed source to fail the upload, got %q", status.State)
+	}
+	if len(fake.aborted) != 1 {
+		t.Errorf("expected the stale upload to be aborted, got %v", fake.aborted)
+	}
+	if checkpoint, _ := checkpoints.GetCheckpoint(ctx, "eth-1"); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be removed, got %+v", checkpoint)
+	}
+}
+
+func TestEngine_Cancel(t *testing.T) {
+	fake := newFakeS3()
+	fake.block = make(chan struct{})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer close(fake.block)
+
+	source := writeSource(t)
+	checkpoints := engine.NewMemoryCheckpoints()
+	e := newTestEngine(t, server, source, checkpoints)
+	ctx := context.Background()
+
+	if err := e.StartUpload(ctx, "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	if err := e.StartUpload(ctx, "eth-1"); err == nil {
+		t.Error("expected an error starting a second upload for the node")
+	}
+	// Wait for the multipart upload to be created, with its parts held by the backend
+	var status *engine.Status
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if status, _ = e.Status(ctx, "eth-1"); status.Fields["upload_id"] != "" {
+			break
+		}
+	}
+	if !status.Running || status.State != "Running" || status.Fields["upload_id"] == "" {
+		t.Fatalf("expected a running upload, got %+v", status)
+	}
+
+	if err := e.Cancel(ctx, "eth-1"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+	e.Stop()
+
+	status, _ = e.Status(ctx, "eth-1")
+	if status.State != "Cancelled" {
+		t.Errorf("expected a cancelled upload, got %q", status.State)
+	}
+	if len(fake.aborted) != 1 {
+		t.Errorf("expected the multipart upload to be aborted, got %v", fake.aborted)
+	}
+	if checkpoint, _ := checkpoints.GetCheckpoint(ctx, "eth-1"); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be removed, got %+v", checkpoint)
+	}
+	if err := e.Cancel(ctx, "eth-1"); err == nil {
+		t.Error("expected an error cancelling a finished upload")
+	}
+}
+
+func TestEngine_NoCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	e, err := New(Config{Source: "/data", Bucket: "snapshots"}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if e.client.endpoint.Host != "s3.us-east-1.amazonaws.com" || e.cfg.Key != "{node}/{timestamp}.tar.gz" {
+		t.Errorf("unexpected defaults: %s %s", e.client.endpoint, e.cfg.Key)
+	}
+	if err := e.StartUpload(context.Background(), "eth-1"); err == nil || !strings.Contains(err.Error(), "AWS_ACCESS_KEY_ID") {
+		t.Errorf("expected a missing credentials error, got %v", err)
+	}
+	if _, err := New(Config{Endpoint: "minio:9000"}, nil); err == nil {
+		t.Error("expected an error for an endpoint without a scheme")
+	}
+}
+
+func TestEngine_Args(t *testing.T) {
+	if _, err := New(Config{Source: "/data", Bucket: "snapshots", Args: map[string]string{"website": "x"}}, nil); err == nil {
+		t.Error("expected an error for an unsupported engine argument")
+	}
+
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	e := newTestEngine(t, server, writeSource(t), nil)
+	e.cfg.Args = map[string]string{"storage_class": "GLACIER_IR", "tagging": "chain=ethereum"}
+	if err := e.StartUpload(context.Background(), "eth-1"); err != nil {
+		t.Fatalf("StartUpload failed: %v", err)
+	}
+	waitFinished(t, e, "eth-1")
+
+	if len(fake.created) != 1 {
+		t.Fatalf("expected one multipart upload, got %d", len(fake.created))
+	}
+	created := fake.created[0]
+	if created.Get("X-Amz-Storage-Class") != "GLACIER_IR" || created.Get("X-Amz-Tagging") != "chain=ethereum" {
+		t.Errorf("expected the archive's headers set, got %v", created)
+	}
+	if !strings.Contains(created.Get("Authorization"), "x-amz-storage-class") {
+		t.Errorf("expected the headers signed, got %s", created.Get("Authorization"))
+	}
+}
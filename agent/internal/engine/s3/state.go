@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/nodexeus/agent/internal/engine"
+)
+
+// state is the progress of a node's multipart upload. It is checkpointed after every
+// part so an interrupted upload resumes where it stopped.
+type state struct {
+	Bucket      string `json:"bucket"`
+	Key         string `json:"key"`
+	UploadID    string `json:"upload_id"`
+	PartSize    int64  `json:"part_size"`
+	Compression string `json:"compression"`
+	Level       int    `json:"level,omitempty"`
+	Parts       []part `json:"-"` // Saved as the checkpoint's chunks
+	// Encryption is set for uploads encrypted on the client
+	Encryption *encryptionState `json:"encryption,omitempty"`
+}
+
+// matches reports whether an upload saved in the state can be resumed with cfg
+func (s *state) matches(cfg Config) bool {
+	if (s.Encryption == nil) != (cfg.Encryption == nil) || (s.Encryption != nil && s.Encryption.KeyID != cfg.Encryption.KeyID) {
+		return false
+	}
+	return s.Bucket == cfg.Bucket && s.PartSize == cfg.PartSize && s.Compression == cfg.Compression && s.Level == cfg.Level
+}
+
+// part returns the saved part with the given number
+func (s *state) part(number int) (part, bool) {
+	for _, p := range s.Parts {
+		if p.Number == number {
+			return p, true
+		}
+	}
+	return part{}, false
+}
+
+// setPart records an uploaded part, keeping the parts ordered by number
+func (s *state) setPart(p part) {
+	for i := range s.Parts {
+		if s.Parts[i].Number == p.Number {
+			s.Parts[i] = p
+			return
+		}
+	}
+	s.Parts = append(s.Parts, p)
+	sort.Slice(s.Parts, func(i, j int) bool { return s.Parts[i].Number < s.Parts[j].Number })
+}
+
+// chunk converts an uploaded part into a checkpoint chunk
+func (p part) chunk() engine.Chunk {
+	return engine.Chunk{Number: p.Number, ETag: p.ETag, Size: p.Size, Checksum: p.MD5}
+}
+
+// loadState reads the node's checkpoint; a node without one, or with one saved by
+// another engine, returns nil
+func loadState(ctx context.Context, checkpoints engine.CheckpointStore, nodeName string) (*state, error) {
+	checkpoint, err := checkpoints.GetCheckpoint(ctx, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload checkpoint: %w", err)
+	}
+	if checkpoint == nil || checkpoint.Engine != engine.S3 {
+		return nil, nil
+	}
+	var s state
+	if err := json.Unmarshal([]byte(checkpoint.Session), &s); err != nil {
+		return nil, fmt.Errorf("failed to parse upload checkpoint: %w", err)
+	}
+	for _, c := range checkpoint.Chunks {
+		s.Parts = append(s.Parts, part{Number: c.Number, ETag: c.ETag, Size: c.Size, MD5: c.Checksum})
+	}
+	return &s, nil
+}
+
+// save replaces the node's checkpoint with the state
+func (s *state) save(ctx context.Context, checkpoints engine.CheckpointStore, nodeName string) error {
+	session, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload checkpoint: %w", err)
+	}
+	checkpoint := engine.Checkpoint{Engine: engine.S3, Session: string(session)}
+	for _, p := range s.Parts {
+		checkpoint.Chunks = append(checkpoint.Chunks, p.chunk())
+	}
+	if err := checkpoints.SaveCheckpoint(ctx, nodeName, checkpoint); err != nil {
+		return fmt.Errorf("failed to save upload checkpoint: %w", err)
+	}
+	return nil
+}
+
+// removeState deletes the node's checkpoint
+func removeState(ctx context.Context, checkpoints engine.CheckpointStore, nodeName string) error {
+	if err := checkpoints.DeleteCheckpoint(ctx, nodeName); err != nil {
+		return fmt.Errorf("failed to remove upload checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type is the kind of an upload lifecycle event
+type Type string
+
+// Upload lifecycle events
+const (
+	TypeStarted   Type = "started"   // An upload was recorded as running
+	TypeProgress  Type = "progress"  // A running upload's progress was refreshed
+	TypeCompleted Type = "completed" // An upload finished successfully
+	TypeFailed    Type = "failed"    // An upload failed, could not start or exceeded its max duration
+	TypeCancelled Type = "cancelled" // An upload was cancelled
+)
+
+const (
+	// recentEvents is how many published events a hub keeps for subscribers resuming
+	// after a disconnect
+	recentEvents = 256
+
+	// subscriberBuffer is how many events a subscriber may fall behind before it is
+	// disconnected
+	subscriberBuffer = 64
+)
+
+// Event is one change in an upload's lifecycle. Progress fields are set on started and
+// progress events when the engine reports them.
+type Event struct {
+	ID                  uint64     `json:"id"`
+	Type                Type       `json:"type"`
+	Time                time.Time  `json:"time"`
+	UploadID            int64      `json:"upload_id"`
+	Node                string     `json:"node"`
+	Tenant              string     `json:"tenant,omitempty"`
+	Protocol            string     `json:"protocol,omitempty"`
+	Trigger             string     `json:"trigger,omitempty"`
+	Status              string     `json:"status,omitempty"` // Recorded status of a finished upload
+	Message             *string    `json:"message,omitempty"`
+	StartedAt           *time.Time `json:"started_at,omitempty"`
+	ProgressPercent     *float64   `json:"progress_percent,omitempty"`
+	ChunksCompleted     *int       `json:"chunks_completed,omitempty"`
+	ChunksTotal         *int       `json:"chunks_total,omitempty"`
+	ChunksPerMinute     *float64   `json:"chunks_per_minute,omitempty"`
+	EstimatedCompletion *time.Time `json:"estimated_completion,omitempty"`
+}
+
+// Finished reports whether the event ends its upload
+func (e Event) Finished() bool {
+	return e.Type == TypeCompleted || e.Type == TypeFailed || e.Type == TypeCancelled
+}
+
+// Hub fans upload events out to subscribers. A nil hub discards events, so publishers
+// need no checks.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	recent      []Event // The last recentEvents events, oldest first
+	subscribers map[*Subscription]struct{}
+}
+
+// NewHub creates a hub without subscribers
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish numbers the event, timestamps it unless it has a time, and sends it to every
+// subscriber. A subscriber too far behind to take it is disconnected rather than
+// blocking the publisher.
+func (h *Hub) Publish(e Event) {
+	if h == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	e.ID = h.lastID
+	if len(h.recent) == recentEvents {
+		h.recent = append(h.recent[:0], h.recent[1:]...)
+	}
+	h.recent = append(h.recent, e)
+
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+}
+
+// Subscribe returns a subscription to the hub's events. With afterID set, as from a
+// resuming client's Last-Event-ID, the retained events after it are delivered first.
+func (h *Hub) Subscribe(afterID uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []Event
+	if afterID > 0 {
+		for _, e := range h.recent {
+			if e.ID > afterID {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	sub := &Subscription{hub: h, events: make(chan Event, subscriberBuffer+len(missed))}
+	for _, e := range missed {
+		sub.events <- e
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Events returns the subscription's events. The channel is closed when the subscription
+// is closed or falls too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove closes a subscription; the caller holds the hub's lock
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
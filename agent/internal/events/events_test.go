@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	hub.Publish(Event{Type: TypeStarted, UploadID: 1, Node: "eth-node"})
+
+	// A new subscription only receives later events
+	sub := hub.Subscribe(0)
+	hub.Publish(Event{Type: TypeProgress, UploadID: 1, Node: "eth-node"})
+	e := <-sub.Events()
+	if e.ID != 2 || e.Type != TypeProgress || e.Time.IsZero() {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	// A resuming subscription first receives the retained events it missed
+	resumed := hub.Subscribe(1)
+	hub.Publish(Event{Type: TypeCompleted, UploadID: 1, Node: "eth-node"})
+	for _, wantID := range []uint64{2, 3} {
+		if e := <-resumed.Events(); e.ID != wantID {
+			t.Errorf("expected event %d, got %+v", wantID, e)
+		}
+	}
+	resumed.Close()
+	resumed.Close()
+	if _, ok := <-resumed.Events(); ok {
+		t.Error("expected a closed subscription's channel to be closed")
+	}
+
+	// A subscriber that falls too far behind is disconnected instead of blocking
+	for i := 0; i < subscriberBuffer+1; i++ {
+		hub.Publish(Event{Type: TypeProgress, UploadID: 1, Node: "eth-node"})
+	}
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("expected %d events before the disconnect, got %d", subscriberBuffer, received)
+	}
+
+	// Only the last recentEvents events are retained
+	for i := 0; i < recentEvents; i++ {
+		hub.Publish(Event{Type: TypeProgress, UploadID: 1, Node: "eth-node"})
+	}
+	if len(hub.recent) != recentEvents || hub.recent[0].ID != hub.lastID-recentEvents+1 {
+		t.Errorf("expected the last %d events retained, got %d from %d", recentEvents, len(hub.recent), hub.recent[0].ID)
+	}
+
+	// A nil hub discards events
+	var discard *Hub
+	discard.Publish(Event{Type: TypeStarted})
+}
+
+func TestFilter(t *testing.T) {
+	e := Event{Node: "eth-node", Tenant: "team-a"}
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{Node: "eth-node"}, true},
+		{Filter{Node: "other-node"}, false},
+		{Filter{Tenant: "team-a"}, true},
+		{Filter{Tenant: "team-b"}, false},
+		{Filter{Node: "eth-node", Tenant: "team-b"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(e); got != tt.want {
+			t.Errorf("%+v.Match = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	hub := NewHub()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ServeStream(w, r, hub, Filter{Node: r.URL.Query().Get("node")})
+	}))
+	defer server.Close()
+
+	if err := Listen(context.Background(), server.Client(), server.URL, "", func(Event) {}); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("expected an unauthorized error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan Event)
+	done := make(chan error, 1)
+	go func() {
+		done <- Listen(ctx, server.Client(), server.URL+"?node=eth-node", "secret", func(e Event) {
+			received <- e
+		})
+	}()
+
+	// Publish until the stream has subscribed, as events before it are not delivered
+	percent := 42.5
+	var e Event
+	for e.Type == "" {
+		hub.Publish(Event{Type: TypeProgress, UploadID: 1, Node: "other-node"})
+		hub.Publish(Event{Type: TypeProgress, UploadID: 2, Node: "eth-node", ProgressPercent: &percent})
+		select {
+		case e = <-received:
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+	if e.Node != "eth-node" || e.UploadID != 2 || e.ProgressPercent == nil || *e.ProgressPercent != percent {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	cancel()
+	for {
+		select {
+		case <-received:
+			continue
+		case err := <-done:
+			if err != nil {
+				t.Errorf("expected a cancelled stream to end without an error, got %v", err)
+			}
+		}
+		break
+	}
+}
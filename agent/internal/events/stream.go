@@ -0,0 +1,145 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepaliveInterval is how often an idle stream sends a comment, so proxies keep it open
+// and a gone client is noticed
+const keepaliveInterval = 15 * time.Second
+
+// Filter selects the events of a stream; empty fields match every event
+type Filter struct {
+	Node   string
+	Tenant string
+}
+
+// Match reports whether the filter selects e
+func (f Filter) Match(e Event) bool {
+	return (f.Node == "" || e.Node == f.Node) && (f.Tenant == "" || e.Tenant == f.Tenant)
+}
+
+// ServeStream streams the hub's events as server-sent events until the request ends,
+// only those the filter matches. A client resuming with a Last-Event-ID header first
+// receives the retained events it missed. A client that falls too far behind is
+// disconnected and can resume the same way.
+func ServeStream(w http.ResponseWriter, r *http.Request, hub *Hub, filter Filter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var afterID uint64
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		afterID, _ = strconv.ParseUint(value, 10, 64)
+	}
+	sub := hub.Subscribe(afterID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if !filter.Match(e) {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one server-sent event, named for its type with its JSON as data
+func writeEvent(w io.Writer, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// Listen reads the event stream at url, calling handle with each event until ctx is
+// cancelled or the stream ends. An empty token sends no Authorization header. It returns
+// nil once ctx is cancelled, and an error when the stream cannot be opened or ends.
+func Listen(ctx context.Context, client *http.Client, url, token string, handle func(Event)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream returned status %d", resp.StatusCode)
+	}
+
+	// Events are separated by blank lines; only the data lines are needed, since the
+	// JSON carries the type and ID too
+	scanner := bufio.NewScanner(resp.Body)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+		if data.Len() == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+			return fmt.Errorf("failed to parse event: %w", err)
+		}
+		data.Reset()
+		handle(e)
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return fmt.Errorf("event stream ended")
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBVConcurrency is how many read-only bv commands may run at once by default
+const DefaultBVConcurrency = 4
+
+// bvCommand describes how a bv command may overlap with others
+type bvCommand struct {
+	node      string // The node the command reads, serialized per node ("" for none)
+	exclusive bool   // The command may change bv's state, so it runs alone
+}
+
+// classifyBV reports how a bv command may overlap with others. Only commands known to
+// read state are shared: the version and a node's job info and logs. Every other command,
+// such as starting or stopping a job, runs alone, since bv rewrites /etc/blockvisor.json
+// when it changes a node.
+func classifyBV(args []string) bvCommand {
+	if len(args) == 1 && args[0] == "--version" {
+		return bvCommand{}
+	}
+	// bv node job <node> info|logs <job>, with the n and j aliases
+	if len(args) >= 4 && (args[0] == "node" || args[0] == "n") && (args[1] == "job" || args[1] == "j") {
+		switch args[3] {
+		case "info", "logs":
+			return bvCommand{node: args[2]}
+		}
+	}
+	return bvCommand{exclusive: true}
+}
+
+// bvLimiter lets read-only bv commands run concurrently, up to a limit and one at a time
+// per node, while each command that may change bv's state runs alone. Waiting exclusive
+// commands hold back new shared ones, so a stream of status checks cannot starve an
+// upload start.
+type bvLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	shared    int             // Shared commands running
+	exclusive bool            // An exclusive command is running
+	waiting   int             // Exclusive commands waiting
+	nodes     map[string]bool // Nodes with a shared command running
+	changed   chan struct{}   // Closed and replaced whenever a command finishes or gives up
+}
+
+// processBVLimiter is shared by every executor of the process, since they all run the
+// same bv: an executor created while the configuration loads, such as the one listing
+// blockvisor nodes, waits for the daemon's own bv commands like any other
+var processBVLimiter = newBVLimiter(DefaultBVConcurrency)
+
+func newBVLimiter(limit int) *bvLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return &bvLimiter{
+		limit:   limit,
+		nodes:   make(map[string]bool),
+		changed: make(chan struct{}),
+	}
+}
+
+// setLimit changes how many shared commands may run at once
+func (l *bvLimiter) setLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.broadcast()
+}
+
+// acquire waits until cmd may run, or ctx ends, and returns the function that releases it
+func (l *bvLimiter) acquire(ctx context.Context, cmd bvCommand) (func(), error) {
+	l.mu.Lock()
+	if cmd.exclusive {
+		l.waiting++
+	}
+	for !l.admit(cmd) {
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			l.mu.Lock()
+			if cmd.exclusive {
+				l.waiting--
+				l.broadcast()
+			}
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() { once.Do(func() { l.release(cmd) }) }, nil
+}
+
+// admit starts cmd when it may run now, reporting whether it did. l.mu must be held.
+func (l *bvLimiter) admit(cmd bvCommand) bool {
+	if l.exclusive {
+		return false
+	}
+	if cmd.exclusive {
+		if l.shared > 0 {
+			return false
+		}
+		l.waiting--
+		l.exclusive = true
+		return true
+	}
+	if l.waiting > 0 || l.shared >= l.limit || (cmd.node != "" && l.nodes[cmd.node]) {
+		return false
+	}
+	l.shared++
+	if cmd.node != "" {
+		l.nodes[cmd.node] = true
+	}
+	return true
+}
+
+// release ends cmd and wakes the waiting commands
+func (l *bvLimiter) release(cmd bvCommand) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cmd.exclusive {
+		l.exclusive = false
+	} else {
+		l.shared--
+		delete(l.nodes, cmd.node)
+	}
+	l.broadcast()
+}
+
+// broadcast wakes every waiting command to check whether it may run. l.mu must be held.
+func (l *bvLimiter) broadcast() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestClassifyBV(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bvCommand
+	}{
+		{[]string{"--version"}, bvCommand{}},
+		{[]string{"node", "job", "eth-node", "info", "upload", "--output", "json"}, bvCommand{node: "eth-node"}},
+		{[]string{"n", "j", "eth-node", "logs", "upload"}, bvCommand{node: "eth-node"}},
+		{[]string{"node", "run", "upload", "eth-node"}, bvCommand{exclusive: true}},
+		{[]string{"node", "job", "eth-node", "stop", "upload"}, bvCommand{exclusive: true}},
+		{[]string{"node", "list"}, bvCommand{exclusive: true}},
+		{nil, bvCommand{exclusive: true}},
+	}
+	for _, tt := range tests {
+		if got := classifyBV(tt.args); got != tt.want {
+			t.Errorf("classifyBV(%v) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBVLimiter(t *testing.T) {
+	ctx := context.Background()
+	short := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	l := newBVLimiter(2)
+
+	// Reads of different nodes share, up to the limit
+	releaseEth, err := l.acquire(ctx, bvCommand{node: "eth"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	releaseSol, err := l.acquire(ctx, bvCommand{node: "sol"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := l.acquire(short(), bvCommand{node: "base"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the limit to hold back a third read, got %v", err)
+	}
+
+	// Reads of the same node are serialized
+	releaseSol()
+	if _, err := l.acquire(short(), bvCommand{node: "eth"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a second read of eth to wait, got %v", err)
+	}
+
+	// An exclusive command waits for running reads and holds back new ones
+	started := make(chan func())
+	go func() {
+		release, err := l.acquire(ctx, bvCommand{exclusive: true})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		started <- release
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := l.acquire(short(), bvCommand{node: "sol"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a read to wait behind the exclusive command, got %v", err)
+	}
+	select {
+	case <-started:
+		t.Fatal("Expected the exclusive command to wait for the running read")
+	case <-time.After(10 * time.Millisecond):
+	}
+	releaseEth()
+	releaseExclusive := <-started
+	if _, err := l.acquire(short(), bvCommand{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the version check to wait for the exclusive command, got %v", err)
+	}
+	releaseExclusive()
+	releaseExclusive()
+
+	// Everything may run again, and a limit of 1 runs reads one at a time
+	l.setLimit(1)
+	release, err := l.acquire(ctx, bvCommand{node: "eth"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := l.acquire(short(), bvCommand{node: "sol"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a limit of 1 to serialize reads, got %v", err)
+	}
+	release()
+}
+
+func TestBVLimiter_SharedByExecutors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	// Another executor's bv command changing a node is running
+	release, err := NewDefaultExecutor(logger).bvLimiter.acquire(context.Background(), bvCommand{exclusive: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer release()
+
+	executor := NewDefaultExecutor(logger)
+	executor.SetBVCommandPrefix([]string{"true"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := executor.Execute(ctx, "bv", "node", "list", "--json"); err == nil || !strings.Contains(err.Error(), "waiting for another bv command") {
+		t.Errorf("Expected bv node list to wait for the other executor's command, got %v", err)
+	}
+}
+
+// BenchmarkStatusChecks measures a monitor run checking the status of 20 nodes at once
+// against a bv that takes 10ms per command, with every command serialized and with the
+// default concurrency
+func BenchmarkStatusChecks(b *testing.B) {
+	for _, concurrency := range []int{1, DefaultBVConcurrency} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			logger := logrus.New()
+			logger.SetLevel(logrus.FatalLevel)
+			executor := NewDefaultExecutor(logger)
+			executor.SetBVCommandPrefix([]string{"sh", "-c", "sleep 0.01", "--"})
+			executor.SetBVConcurrency(concurrency)
+
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for node := 0; node < 20; node++ {
+					wg.Add(1)
+					go func(node string) {
+						defer wg.Done()
+						if _, _, err := executor.Execute(context.Background(), "bv", "node", "job", node, "info", "upload"); err != nil {
+							b.Error(err)
+						}
+					}(fmt.Sprintf("node-%d", node))
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Container runtimes a ContainerExecutor can run commands through
+const (
+	RuntimeDocker  = "docker"
+	RuntimePodman  = "podman"
+	RuntimeNerdctl = "nerdctl" // containerd, through its Docker-compatible CLI
+	RuntimeCtr     = "ctr"     // containerd, through its own CLI
+)
+
+// execIDEnv tags each process a ContainerExecutor starts in a container, so a canceled
+// command and the processes it started can be found and stopped
+const execIDEnv = "SNAPPERD_EXEC_ID"
+
+// containerKillTimeout bounds stopping a canceled command inside its container
+const containerKillTimeout = 30 * time.Second
+
+// containerKillScript sends SIGTERM to every process of the container whose environment
+// holds $1 (SNAPPERD_EXEC_ID=<id>)
+const containerKillScript = `for p in /proc/[0-9]*; do
+  if tr '\0' '\n' 2>/dev/null < "$p/environ" | grep -qx "$1"; then kill -TERM "${p#/proc/}" 2>/dev/null; fi
+done`
+
+// execCounter numbers the commands started in containers, for their exec IDs
+var execCounter atomic.Uint64
+
+// Container names the container a ContainerExecutor runs commands in
+type Container struct {
+	Runtime    string // docker (default), podman, nerdctl or ctr
+	Name       string // Container name or ID
+	Namespace  string // containerd namespace, for nerdctl and ctr (default the runtime's)
+	User       string // User the commands run as (default the container's)
+	WorkingDir string // Directory the commands run in (default the container's)
+}
+
+// ContainerExecutor runs commands inside a running container, for nodes whose chain
+// client runs in a container on a host without bv. Each command is run through the
+// container runtime's CLI on the wrapped executor, so the runtime binary, not the
+// command, is subject to its sandbox. A runtime CLI killed by a canceled context leaves
+// its command running in the container, so the executor stops the command there itself.
+type ContainerExecutor struct {
+	exec      CommandExecutor
+	container Container
+	logger    *logrus.Logger
+}
+
+// NewContainerExecutor creates an executor running commands in a container through exec
+func NewContainerExecutor(exec CommandExecutor, container Container, logger *logrus.Logger) *ContainerExecutor {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if container.Runtime == "" {
+		container.Runtime = RuntimeDocker
+	}
+	return &ContainerExecutor{exec: exec, container: container, logger: logger}
+}
+
+// NewExecID returns a new ID tagging a command started in a container
+func NewExecID() string {
+	return fmt.Sprintf("snapperd-%d-%d", os.Getpid(), execCounter.Add(1))
+}
+
+// TagCommand returns the command line running command tagged with execID. The tag is set
+// through env(1), which every container runtime can run.
+func TagCommand(execID string, command string, args ...string) []string {
+	return append([]string{"env", execIDEnv + "=" + execID, command}, args...)
+}
+
+// KillTaggedCommand returns the command line that, run in the same container, sends
+// SIGTERM to every process of the command tagged with execID. It needs sh and /proc.
+func KillTaggedCommand(execID string) []string {
+	return []string{"sh", "-c", containerKillScript, "sh", execIDEnv + "=" + execID}
+}
+
+// Execute runs a command in the container and returns its stdout and stderr. When ctx
+// ends first, the command is stopped inside the container.
+func (e *ContainerExecutor) Execute(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+	execID := NewExecID()
+	stdout, stderr, err = e.exec.Execute(ctx, e.container.Runtime, e.execArgs(execID, TagCommand(execID, command, args...))...)
+	if err != nil && ctx.Err() != nil {
+		e.kill(ctx, execID)
+	}
+	return stdout, stderr, err
+}
+
+// kill stops the processes of a canceled command inside the container
+func (e *ContainerExecutor) kill(ctx context.Context, execID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), containerKillTimeout)
+	defer cancel()
+
+	_, stderr, err := e.exec.Execute(ctx, e.container.Runtime, e.execArgs(execID+"-kill", KillTaggedCommand(execID))...)
+	if err != nil {
+		e.logger.WithFields(logrus.Fields{
+			"component": "executor",
+			"container": e.container.Name,
+			"exec_id":   execID,
+			"error":     err.Error(),
+			"stderr":    stderr,
+		}).Warn("Failed to stop a canceled command in its container")
+	}
+}
+
+// execArgs returns the runtime arguments running a command line in the container. ctr
+// uses execID as the exec process ID it requires.
+func (e *ContainerExecutor) execArgs(execID string, commandLine []string) []string {
+	c := e.container
+	var out []string
+	if c.Runtime == RuntimeCtr {
+		if c.Namespace != "" {
+			out = append(out, "--namespace", c.Namespace)
+		}
+		out = append(out, "task", "exec", "--exec-id", execID)
+		if c.User != "" {
+			out = append(out, "--user", c.User)
+		}
+		if c.WorkingDir != "" {
+			out = append(out, "--cwd", c.WorkingDir)
+		}
+	} else {
+		if c.Runtime == RuntimeNerdctl && c.Namespace != "" {
+			out = append(out, "--namespace", c.Namespace)
+		}
+		out = append(out, "exec")
+		if c.User != "" {
+			out = append(out, "--user", c.User)
+		}
+		if c.WorkingDir != "" {
+			out = append(out, "--workdir", c.WorkingDir)
+		}
+	}
+	out = append(out, c.Name)
+	return append(out, commandLine...)
+}
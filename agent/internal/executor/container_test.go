@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingExecutor records the commands it is given
+type recordingExecutor struct {
+	mu       sync.Mutex
+	commands [][]string
+	err      error
+}
+
+func (r *recordingExecutor) Execute(ctx context.Context, command string, args ...string) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, append([]string{command}, args...))
+	return "out", "", r.err
+}
+
+func TestContainerExecutor(t *testing.T) {
+	tests := []struct {
+		name      string
+		container Container
+		want      []string // Command before the exec ID tag
+	}{
+		{
+			name:      "docker by default",
+			container: Container{Name: "geth"},
+			want:      []string{"docker", "exec", "geth"},
+		},
+		{
+			name:      "podman as a user in a directory",
+			container: Container{Runtime: RuntimePodman, Name: "geth", User: "geth", WorkingDir: "/data"},
+			want:      []string{"podman", "exec", "--user", "geth", "--workdir", "/data", "geth"},
+		},
+		{
+			name:      "nerdctl in a namespace",
+			container: Container{Runtime: RuntimeNerdctl, Name: "geth", Namespace: "k8s.io"},
+			want:      []string{"nerdctl", "--namespace", "k8s.io", "exec", "geth"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &recordingExecutor{}
+			e := NewContainerExecutor(runner, tt.container, nil)
+			stdout, _, err := e.Execute(context.Background(), "geth", "attach", "--exec", "eth.blockNumber")
+			if err != nil || stdout != "out" {
+				t.Fatalf("Execute() = %q, %v", stdout, err)
+			}
+
+			got := runner.commands[0]
+			if !slices.Equal(got[:len(tt.want)], tt.want) {
+				t.Errorf("Command = %v, want prefix %v", got, tt.want)
+			}
+			rest := got[len(tt.want):]
+			if len(rest) != 6 || rest[0] != "env" || !strings.HasPrefix(rest[1], execIDEnv+"=snapperd-") ||
+				!slices.Equal(rest[2:], []string{"geth", "attach", "--exec", "eth.blockNumber"}) {
+				t.Errorf("Command = %v, want the command tagged through env", got)
+			}
+		})
+	}
+
+	// ctr takes the exec ID as its exec process ID
+	runner := &recordingExecutor{}
+	e := NewContainerExecutor(runner, Container{Runtime: RuntimeCtr, Name: "geth", Namespace: "chains", WorkingDir: "/data"}, nil)
+	if _, _, err := e.Execute(context.Background(), "true"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got := runner.commands[0]
+	execID := strings.TrimPrefix(got[len(got)-2], execIDEnv+"=")
+	want := []string{"ctr", "--namespace", "chains", "task", "exec", "--exec-id", execID, "--cwd", "/data", "geth", "env", execIDEnv + "=" + execID, "true"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Command = %v, want %v", got, want)
+	}
+}
+
+func TestContainerExecutor_StopsCanceledCommands(t *testing.T) {
+	runner := &recordingExecutor{}
+	e := NewContainerExecutor(runner, Container{Name: "geth"}, nil)
+
+	// A command that completes is not stopped
+	runner.err = errors.New("exit status 1")
+	if _, _, err := e.Execute(context.Background(), "false"); err == nil {
+		t.Fatal("Expected the command's error")
+	}
+	if len(runner.commands) != 1 {
+		t.Fatalf("Expected no kill command after a failure, got %v", runner.commands)
+	}
+
+	// A canceled command is stopped inside the container
+	runner.commands = nil
+	runner.err = context.Canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := e.Execute(ctx, "rclone", "sync"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the cancellation, got %v", err)
+	}
+	if len(runner.commands) != 2 {
+		t.Fatalf("Expected a kill command, got %v", runner.commands)
+	}
+	tag := runner.commands[0][4]
+	kill := runner.commands[1]
+	if kill[4] == tag || kill[len(kill)-1] != tag || !slices.Contains(kill, containerKillScript) {
+		t.Errorf("Kill command = %v, want one killing the processes tagged %s", kill, tag)
+	}
+}
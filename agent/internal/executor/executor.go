@@ -1,14 +1,17 @@
 package executor
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/nodexeus/agent/internal/tracing"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,8 +23,11 @@ type CommandExecutor interface {
 
 // DefaultExecutor is the standard implementation of CommandExecutor
 type DefaultExecutor struct {
-	logger *logrus.Logger
-	bvMu   sync.Mutex // Mutex to serialize bv CLI commands
+	logger    *logrus.Logger
+	bvLimiter *bvLimiter // Decides which bv CLI commands may run at once
+	bvPrefix  []string   // Command prefix applied to bv invocations (e.g. sudo -n -u blockvisor)
+	sandboxMu sync.Mutex
+	sandbox   *processSandbox // Restrictions on the commands run (nil allows every command)
 }
 
 // NewDefaultExecutor creates a new DefaultExecutor with the provided logger
@@ -30,18 +36,81 @@ func NewDefaultExecutor(logger *logrus.Logger) *DefaultExecutor {
 		logger = logrus.New()
 	}
 	return &DefaultExecutor{
-		logger: logger,
+		logger:    logger,
+		bvLimiter: processBVLimiter,
 	}
 }
 
+// SetBVConcurrency sets how many read-only bv commands, such as status checks of
+// different nodes, may run at once (default DefaultBVConcurrency). Commands that may
+// change bv's state always run alone; 1 runs every bv command alone. The limit applies
+// to the bv commands of every executor in the process.
+func (e *DefaultExecutor) SetBVConcurrency(n int) {
+	e.bvLimiter.setLimit(n)
+}
+
+// SetBVCommandPrefix sets a wrapper command (such as sudo or a setuid helper) that bv
+// invocations are run through, so the daemon can run as a non-root service account.
+// See WrapCommand for how the prefix is applied.
+func (e *DefaultExecutor) SetBVCommandPrefix(prefix []string) {
+	e.bvPrefix = prefix
+}
+
 // Execute runs a command with context support and captures stdout and stderr separately
 func (e *DefaultExecutor) Execute(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
-	// Serialize bv CLI commands to prevent race conditions
-	// The bv CLI rewrites /etc/blockvisor.json on every run, causing race conditions in parallel execution
+	// Create buffers to capture stdout and stderr, keeping at most the output limit of each
+	limit := e.currentSandbox().outputLimit()
+	stdoutBuf, stderrBuf := NewOutputBuffer(limit), NewOutputBuffer(limit)
+	err = e.run(ctx, stdoutBuf, stderrBuf, command, args...)
+	return stdoutBuf.String(), stderrBuf.String(), err
+}
+
+// run runs a command in the sandbox, writing its stdout and stderr to the given writers
+func (e *DefaultExecutor) run(ctx context.Context, stdout, stderr io.Writer, command string, args ...string) (err error) {
+	// bv CLI commands that may change a node rewrite /etc/blockvisor.json, so they must not
+	// overlap other bv commands; read-only commands of different nodes may
 	isBvCommand := command == "bv" || strings.HasSuffix(command, "/bv")
+
+	// Arguments are only recorded for bv, whose arguments hold no secrets
+	ctx, span := tracing.StartClient(ctx, "exec "+filepath.Base(command), tracing.String("process.executable.name", filepath.Base(command)))
+	defer span.End()
 	if isBvCommand {
-		e.bvMu.Lock()
-		defer e.bvMu.Unlock()
+		span.SetAttributes(tracing.String("process.command_args", strings.Join(args, " ")))
+	}
+	defer func() {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			span.SetAttributes(tracing.Int("process.exit.code", exitErr.ExitCode()))
+		}
+		span.RecordError(err)
+	}()
+
+	sandbox := e.currentSandbox()
+	if !sandbox.allows(command) {
+		e.logger.WithFields(logrus.Fields{
+			"component": "executor",
+			"command":   command,
+		}).Error("Refused to run a command missing from the allow-list")
+		return fmt.Errorf("%w: %s", ErrCommandNotAllowed, command)
+	}
+
+	if isBvCommand {
+		// Wait for conflicting bv commands within the context, so a hung command does not
+		// hold up callers past their own timeouts
+		release, err := e.bvLimiter.acquire(ctx, classifyBV(args))
+		if err != nil {
+			e.logger.WithFields(logrus.Fields{
+				"component": "executor",
+				"command":   command,
+				"args":      args,
+			}).Error("Gave up waiting for another bv command to finish")
+			if err == context.DeadlineExceeded {
+				return fmt.Errorf("command timed out waiting for another bv command: %w", err)
+			}
+			return fmt.Errorf("command canceled waiting for another bv command: %w", err)
+		}
+		defer release()
+		command, args = WrapCommand(e.bvPrefix, command, args...)
 	}
 
 	// Log the command being executed
@@ -53,21 +122,18 @@ func (e *DefaultExecutor) Execute(ctx context.Context, command string, args ...s
 
 	// Create the command with context
 	cmd := exec.CommandContext(ctx, command, args...)
+	sandbox.apply(cmd)
 
-	// Create buffers to capture stdout and stderr
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
+	// Keep the ends of stderr for the failure log
+	loggedStderr := NewOutputBuffer(maxLoggedOutputBytes)
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(stderr, loggedStderr)
 
 	// Execute the command
 	startTime := time.Now()
 	execErr := cmd.Run()
 	duration := time.Since(startTime)
 
-	// Get the output strings
-	stdout = stdoutBuf.String()
-	stderr = stderrBuf.String()
-
 	// Log the result
 	logFields := logrus.Fields{
 		"component": "executor",
@@ -80,19 +146,19 @@ func (e *DefaultExecutor) Execute(ctx context.Context, command string, args ...s
 		// Check if the error is due to context cancellation or timeout
 		if ctx.Err() == context.DeadlineExceeded {
 			e.logger.WithFields(logFields).Error("Command execution timed out")
-			return stdout, stderr, fmt.Errorf("command timed out: %w", execErr)
+			return fmt.Errorf("command timed out: %w", execErr)
 		} else if ctx.Err() == context.Canceled {
 			e.logger.WithFields(logFields).Error("Command execution canceled")
-			return stdout, stderr, fmt.Errorf("command canceled: %w", execErr)
+			return fmt.Errorf("command canceled: %w", execErr)
 		}
 
 		// Log the error with full details
 		logFields["error"] = execErr.Error()
-		logFields["stderr"] = stderr
+		logFields["stderr"] = loggedStderr.String()
 		e.logger.WithFields(logFields).Error("Command execution failed")
-		return stdout, stderr, fmt.Errorf("command failed: %w", execErr)
+		return fmt.Errorf("command failed: %w", execErr)
 	}
 
 	e.logger.WithFields(logFields).Info("Command executed successfully")
-	return stdout, stderr, nil
+	return nil
 }
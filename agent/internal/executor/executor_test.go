@@ -2,6 +2,9 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -243,3 +246,274 @@ func TestDefaultExecutor_Execute_ReadOnlyFileSystemRetry(t *testing.T) {
 		t.Errorf("Expected empty stderr, got: %q", stderr)
 	}
 }
+
+func TestWrapCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      []string
+		args        []string
+		wantCommand string
+		wantArgs    []string
+	}{
+		{
+			name:        "no prefix",
+			args:        []string{"node", "list", "--json"},
+			wantCommand: "bv",
+			wantArgs:    []string{"node", "list", "--json"},
+		},
+		{
+			name:        "sudo",
+			prefix:      []string{"sudo", "-n", "-u", "blockvisor"},
+			args:        []string{"n", "run", "upload", "my node"},
+			wantCommand: "sudo",
+			wantArgs:    []string{"-n", "-u", "blockvisor", "bv", "n", "run", "upload", "my node"},
+		},
+		{
+			name:        "command placeholder",
+			prefix:      []string{"su", "blockvisor", "-s", "/bin/sh", "-c", "{command}"},
+			args:        []string{"n", "j", "it's-a-node", "info", "upload"},
+			wantCommand: "su",
+			wantArgs:    []string{"blockvisor", "-s", "/bin/sh", "-c", `bv n j 'it'"'"'s-a-node' info upload`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, args := WrapCommand(tt.prefix, "bv", tt.args...)
+			if command != tt.wantCommand || strings.Join(args, "|") != strings.Join(tt.wantArgs, "|") {
+				t.Errorf("WrapCommand() = %s %q, want %s %q", command, args, tt.wantCommand, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	// The quoted line must reproduce the original arguments when parsed by a shell
+	args := []string{"plain", "with space", "", "it's", "$HOME", "a;b", "--flag=1"}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	script := "set -- " + ShellQuote(args...) + `; for a in "$@"; do printf '[%s]' "$a"; done`
+	stdout, stderr, err := NewDefaultExecutor(logger).Execute(context.Background(), "sh", "-c", script)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v (%s)", err, stderr)
+	}
+	want := "[plain][with space][][it's][$HOME][a;b][--flag=1]"
+	if stdout != want {
+		t.Errorf("round trip = %s, want %s", stdout, want)
+	}
+}
+
+func TestDefaultExecutor_BVCommandPrefix(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	executor := NewDefaultExecutor(logger)
+	executor.SetBVCommandPrefix([]string{"echo", "wrapped"})
+
+	// bv commands run through the prefix; other commands are unaffected
+	stdout, _, err := executor.Execute(context.Background(), "bv", "node", "list")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if strings.TrimSpace(stdout) != "wrapped bv node list" {
+		t.Errorf("Expected prefixed bv command, got: %s", stdout)
+	}
+
+	stdout, _, err = executor.Execute(context.Background(), "echo", "direct")
+	if err != nil || strings.TrimSpace(stdout) != "direct" {
+		t.Errorf("Expected unprefixed command output, got: %s (%v)", stdout, err)
+	}
+}
+
+func TestDefaultExecutor_BVLockHonorsContext(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	executor := NewDefaultExecutor(logger)
+	// A hung bv: every bv command sleeps instead of running
+	executor.SetBVCommandPrefix([]string{"sh", "-c", "exec sleep 5", "--"})
+
+	hungCtx, cancelHung := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancelHung()
+	hung := make(chan error, 1)
+	go func() {
+		_, _, err := executor.Execute(hungCtx, "bv", "node", "run", "upload", "eth-node")
+		hung <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// A bv command queued behind the hung one gives up at its own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := executor.Execute(ctx, "bv", "node", "job", "sol-node", "info", "upload")
+	if err == nil || !strings.Contains(err.Error(), "timed out waiting for another bv command") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout waiting for the lock, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the queued command to give up at its deadline, took %s", elapsed)
+	}
+
+	// The hung command is killed at its deadline, releasing the lock
+	if err := <-hung; err == nil || !strings.Contains(err.Error(), "command timed out") {
+		t.Errorf("Expected the hung command to time out, got %v", err)
+	}
+	executor.SetBVCommandPrefix([]string{"echo"})
+	if stdout, _, err := executor.Execute(context.Background(), "bv", "--version"); err != nil || strings.TrimSpace(stdout) != "bv --version" {
+		t.Errorf("Expected bv to run once the lock was released, got %q (%v)", stdout, err)
+	}
+}
+
+func TestDefaultExecutor_Execute_OutputCap(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	executor := NewDefaultExecutor(logger)
+
+	// 3MiB of logs between a first and a last line, on stdout and stderr
+	script := `echo first; head -c 3145728 /dev/zero | tr '\0' x; echo; echo last`
+	stdout, stderr, err := executor.Execute(context.Background(), "sh", "-c", script+"; ("+script+") >&2; exit 1")
+	if err == nil {
+		t.Fatal("Expected an error for the failed command")
+	}
+
+	for name, output := range map[string]string{"stdout": stdout, "stderr": stderr} {
+		if len(output) > MaxOutputBytes+64 {
+			t.Errorf("Expected %s to be capped, got %d bytes", name, len(output))
+		}
+		if !strings.HasPrefix(output, "first\n") || !strings.HasSuffix(output, "\nlast\n") {
+			t.Errorf("Expected %s to keep its first and last lines", name)
+		}
+		if !IsTruncated(output) || !strings.Contains(output, fmt.Sprintf("[... %d bytes truncated ...]", 3145728+12-MaxOutputBytes)) {
+			t.Errorf("Expected a truncation marker in %s", name)
+		}
+	}
+}
+
+func TestDefaultExecutor_ExecuteStream(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	executor := NewDefaultExecutor(logger)
+
+	var lines []string
+	onLine := func(stream Stream, line string) {
+		lines = append(lines, string(stream)+": "+line)
+	}
+
+	// Lines of both streams, a CRLF line ending and a last line without one. Each stream
+	// keeps its order, but the streams are read concurrently.
+	err := executor.ExecuteStream(context.Background(), onLine, "sh", "-c", `echo one; echo two >&2; printf 'three\r\nfour'`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var stdout, stderr []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "stderr: ") {
+			stderr = append(stderr, line)
+		} else {
+			stdout = append(stdout, line)
+		}
+	}
+	if strings.Join(stdout, "|") != "stdout: one|stdout: three|stdout: four" || strings.Join(stderr, "|") != "stderr: two" {
+		t.Errorf("Unexpected lines: %v", lines)
+	}
+
+	// Overlong lines are cut at MaxLineBytes, and a failure is still returned
+	lines = nil
+	err = executor.ExecuteStream(context.Background(), onLine, "sh", "-c", fmt.Sprintf("head -c %d /dev/zero | tr '\\0' x; echo; echo last; exit 1", 2*MaxLineBytes))
+	if err == nil || !strings.Contains(err.Error(), "command failed") {
+		t.Errorf("Expected a command failure, got %v", err)
+	}
+	if len(lines) != 2 || len(lines[0]) != len("stdout: ")+MaxLineBytes || lines[1] != "stdout: last" {
+		t.Errorf("Expected a truncated line and the last line, got %d lines", len(lines))
+	}
+
+	// The sandbox's allow-list applies
+	if err := executor.SetSandbox(Sandbox{AllowedCommands: []string{"bv"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := executor.ExecuteStream(context.Background(), onLine, "sh", "-c", "echo hi"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("Expected ErrCommandNotAllowed, got %v", err)
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		limit  int
+		want   string
+	}{
+		{name: "fits", output: "status: Running\n", limit: 64, want: "status: Running\n"},
+		{name: "middle dropped", output: "0123456789", limit: 4, want: "01\n[... 6 bytes truncated ...]\n89"},
+		{name: "characters kept whole", output: "ééééé", limit: 5, want: "é\n[... 6 bytes truncated ...]\né"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateOutput(tt.output, tt.limit)
+			if got != tt.want {
+				t.Errorf("TruncateOutput() = %q, want %q", got, tt.want)
+			}
+			if IsTruncated(got) != (got != tt.output) {
+				t.Errorf("IsTruncated(%q) = %v", got, IsTruncated(got))
+			}
+		})
+	}
+
+	// Output written in small pieces is cut the same way
+	b := NewOutputBuffer(4)
+	for _, c := range "0123456789" {
+		b.WriteString(string(c))
+	}
+	if b.String() != "01\n[... 6 bytes truncated ...]\n89" {
+		t.Errorf("Unexpected streamed output %q", b.String())
+	}
+}
+
+func TestDefaultExecutor_Sandbox(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	executor := NewDefaultExecutor(logger)
+	dir := t.TempDir()
+	t.Setenv("SNAPPERD_TEST_SECRET", "secret")
+	t.Setenv("SNAPPERD_TEST_PASSED", "passed")
+
+	if err := executor.SetSandbox(Sandbox{AllowedCommands: []string{"sh"}, RunAsUser: "no-such-user-snapperd"}); err == nil {
+		t.Error("Expected an error for an unknown run-as user")
+	}
+	if err := executor.SetSandbox(Sandbox{WorkingDir: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("Expected an error for a missing working directory")
+	}
+	err := executor.SetSandbox(Sandbox{
+		AllowedCommands: []string{"sh"},
+		WorkingDir:      dir,
+		ScrubEnv:        true,
+		PassEnv:         []string{"SNAPPERD_TEST_PASSED"},
+		MaxOutputBytes:  64,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Commands missing from the allow-list are refused without being run
+	if _, _, err := executor.Execute(context.Background(), "echo", "hello"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("Expected ErrCommandNotAllowed, got: %v", err)
+	}
+	executor.Allow("echo")
+	if stdout, _, err := executor.Execute(context.Background(), "echo", "hello"); err != nil || strings.TrimSpace(stdout) != "hello" {
+		t.Errorf("Expected an allowed command to run, got: %s (%v)", stdout, err)
+	}
+
+	// Commands run in the working directory with a scrubbed environment
+	stdout, _, err := executor.Execute(context.Background(), "sh", "-c", `echo "$PWD ${SNAPPERD_TEST_SECRET:-unset} $SNAPPERD_TEST_PASSED"`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if want := dir + " unset passed"; strings.TrimSpace(stdout) != want {
+		t.Errorf("Expected %q, got: %q", want, stdout)
+	}
+
+	// Output is bounded by the sandbox's limit
+	stdout, _, err = executor.Execute(context.Background(), "sh", "-c", "head -c 1000 /dev/zero | tr '\\0' x")
+	if err != nil || len(stdout) > 64+64 || !IsTruncated(stdout) {
+		t.Errorf("Expected output capped at 64 bytes, got %d bytes (%v)", len(stdout), err)
+	}
+}
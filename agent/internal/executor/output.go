@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// MaxOutputBytes bounds how much of each of a command's stdout and stderr is kept. Some
+// bv failure modes dump megabytes of logs; beyond the limit, the start and the end of the
+// output are kept and the middle is replaced by a truncation marker.
+const MaxOutputBytes = 1 << 20
+
+// maxLoggedOutputBytes bounds the stderr logged with a failed command
+const maxLoggedOutputBytes = 4 << 10
+
+// truncationMarker replaces the dropped middle of an output, with the bytes dropped
+const truncationMarker = "\n[... %d bytes truncated ...]\n"
+
+// truncationPattern matches a truncation marker
+var truncationPattern = regexp.MustCompile(`\n\[\.\.\. \d+ bytes truncated \.\.\.\]\n`)
+
+// IsTruncated reports whether output had its middle dropped by the executor or
+// TruncateOutput
+func IsTruncated(output string) bool {
+	return truncationPattern.MatchString(output)
+}
+
+// TruncateOutput returns output unchanged when it fits in limit bytes, or its first and
+// last limit/2 bytes around a truncation marker
+func TruncateOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	b := NewOutputBuffer(limit)
+	b.WriteString(output)
+	return b.String()
+}
+
+// OutputBuffer is an io.Writer keeping the first and last limit/2 bytes written, so a
+// command's output is streamed through a bounded amount of memory
+type OutputBuffer struct {
+	half    int
+	head    []byte
+	tail    []byte // The last bytes written after head filled, up to 2*half before compaction
+	written int64
+}
+
+// NewOutputBuffer creates a buffer keeping at most limit bytes of output, for executors
+// capturing a command's output as Execute does
+func NewOutputBuffer(limit int) *OutputBuffer {
+	half := limit / 2
+	if half < 1 {
+		half = 1
+	}
+	return &OutputBuffer{half: half}
+}
+
+// Write keeps p's bytes that fall in the head or the tail of the output
+func (b *OutputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.written += int64(n)
+
+	if room := b.half - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+	if len(p) >= b.half {
+		b.tail = append(b.tail[:0], p[len(p)-b.half:]...)
+		return n, nil
+	}
+	b.tail = append(b.tail, p...)
+	if len(b.tail) > 2*b.half {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.half:]...)
+	}
+	return n, nil
+}
+
+// WriteString writes s
+func (b *OutputBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// String returns the output, with a truncation marker where bytes were dropped. The cut
+// falls on UTF-8 character boundaries, so it never splits a character.
+func (b *OutputBuffer) String() string {
+	tail := b.tail
+	if len(tail) > b.half {
+		tail = tail[len(tail)-b.half:]
+	}
+	dropped := b.written - int64(len(b.head)) - int64(len(tail))
+	if dropped == 0 {
+		return string(b.head) + string(tail)
+	}
+
+	head := trimPartialRune(b.head)
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	dropped = b.written - int64(len(head)) - int64(len(tail))
+	return string(head) + fmt.Sprintf(truncationMarker, dropped) + string(tail)
+}
+
+// trimPartialRune drops an incomplete UTF-8 character from the end of p
+func trimPartialRune(p []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(p); i++ {
+		if utf8.RuneStart(p[len(p)-i]) {
+			if !utf8.FullRune(p[len(p)-i:]) {
+				return p[:len(p)-i]
+			}
+			return p
+		}
+	}
+	return p
+}
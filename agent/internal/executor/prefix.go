@@ -0,0 +1,62 @@
+package executor
+
+import "strings"
+
+// CommandPlaceholder in a command prefix is replaced by the whole wrapped command line,
+// shell-quoted as one argument, for wrappers that take a command string
+// (e.g. su blockvisor -s /bin/sh -c {command})
+const CommandPlaceholder = "{command}"
+
+// WrapCommand applies a command prefix such as ["sudo", "-n", "-u", "blockvisor"] to a
+// command. Without a {command} placeholder the command and its arguments are appended to
+// the prefix unchanged; with one, the placeholder is replaced by the quoted command line.
+// An empty prefix returns the command as is.
+func WrapCommand(prefix []string, command string, args ...string) (string, []string) {
+	if len(prefix) == 0 {
+		return command, args
+	}
+
+	wrapped := make([]string, 0, len(prefix)+len(args)+1)
+	placeholder := false
+	for _, arg := range prefix[1:] {
+		if strings.Contains(arg, CommandPlaceholder) {
+			arg = strings.ReplaceAll(arg, CommandPlaceholder, ShellQuote(append([]string{command}, args...)...))
+			placeholder = true
+		}
+		wrapped = append(wrapped, arg)
+	}
+	if !placeholder {
+		wrapped = append(wrapped, command)
+		wrapped = append(wrapped, args...)
+	}
+
+	return prefix[0], wrapped
+}
+
+// ShellQuote joins arguments into a POSIX shell command line, single-quoting any argument
+// that contains characters the shell would interpret
+func ShellQuote(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quoteArg quotes a single argument for a POSIX shell
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,202 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrCommandNotAllowed is returned for a command missing from the sandbox's allow-list
+var ErrCommandNotAllowed = errors.New("command not allowed")
+
+// scrubbedEnvKeep are the variables a scrubbed environment keeps besides PassEnv
+var scrubbedEnvKeep = []string{"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+// Sandbox restricts the commands an executor runs and the processes they run in. The
+// zero Sandbox allows every command, as the executor does without one.
+type Sandbox struct {
+	// AllowedCommands are the commands that may be run, compared exactly with the command
+	// given to Execute before any bv prefix is applied: a name looked up in PATH such as
+	// "bv", or an absolute path. Empty allows every command.
+	AllowedCommands []string
+	RunAsUser       string   // Run commands as this user, which requires root (default: the daemon's user)
+	WorkingDir      string   // Directory commands run in (default: the daemon's)
+	ScrubEnv        bool     // Run commands with only PATH, HOME, USER, LOGNAME, LANG, LC_ALL, TZ, TMPDIR and PassEnv
+	PassEnv         []string // Variables a scrubbed environment keeps
+	MaxOutputBytes  int      // Bound on each of stdout and stderr (0 = MaxOutputBytes)
+}
+
+// processSandbox is a sandbox resolved for starting processes
+type processSandbox struct {
+	allowed    map[string]bool // nil allows every command
+	credential *syscall.Credential
+	home       string // The run-as user's home and name, set in the environment
+	username   string
+	workingDir string
+	scrubEnv   bool
+	passEnv    []string
+	maxOutput  int
+}
+
+// SetSandbox applies a sandbox to the commands run from now on. It fails when the
+// run-as user does not exist or cannot be switched to, or the working directory is not
+// a directory.
+func (e *DefaultExecutor) SetSandbox(sandbox Sandbox) error {
+	s := &processSandbox{
+		workingDir: sandbox.WorkingDir,
+		scrubEnv:   sandbox.ScrubEnv,
+		passEnv:    sandbox.PassEnv,
+		maxOutput:  sandbox.MaxOutputBytes,
+	}
+	if s.maxOutput <= 0 {
+		s.maxOutput = MaxOutputBytes
+	}
+	if len(sandbox.AllowedCommands) > 0 {
+		s.allowed = make(map[string]bool, len(sandbox.AllowedCommands))
+		for _, command := range sandbox.AllowedCommands {
+			s.allowed[command] = true
+		}
+	}
+
+	if sandbox.RunAsUser != "" {
+		u, err := user.Lookup(sandbox.RunAsUser)
+		if err != nil {
+			return fmt.Errorf("failed to look up run-as user: %w", err)
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("run-as user %s has a non-numeric uid %s", u.Username, u.Uid)
+		}
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("run-as user %s has a non-numeric gid %s", u.Username, u.Gid)
+		}
+		if int(uid) != os.Geteuid() {
+			if os.Geteuid() != 0 {
+				return fmt.Errorf("running commands as %s requires the daemon to run as root", u.Username)
+			}
+			s.credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+			// Keep the user's supplementary groups, such as one granting access to bv
+			groupIDs, _ := u.GroupIds()
+			for _, groupID := range groupIDs {
+				if g, err := strconv.ParseUint(groupID, 10, 32); err == nil {
+					s.credential.Groups = append(s.credential.Groups, uint32(g))
+				}
+			}
+		}
+		s.home, s.username = u.HomeDir, u.Username
+	}
+
+	if s.workingDir != "" {
+		info, err := os.Stat(s.workingDir)
+		if err != nil {
+			return fmt.Errorf("failed to use working directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("working directory %s is not a directory", s.workingDir)
+		}
+	}
+
+	e.sandboxMu.Lock()
+	defer e.sandboxMu.Unlock()
+	e.sandbox = s
+	return nil
+}
+
+// Allow adds commands to the sandbox's allow-list, such as the commands of a node
+// registered at runtime. It does nothing when every command is allowed.
+func (e *DefaultExecutor) Allow(commands ...string) {
+	e.sandboxMu.Lock()
+	defer e.sandboxMu.Unlock()
+	if e.sandbox == nil || e.sandbox.allowed == nil {
+		return
+	}
+
+	allowed := make(map[string]bool, len(e.sandbox.allowed)+len(commands))
+	for command := range e.sandbox.allowed {
+		allowed[command] = true
+	}
+	for _, command := range commands {
+		allowed[command] = true
+	}
+	s := *e.sandbox
+	s.allowed = allowed
+	e.sandbox = &s
+}
+
+// currentSandbox returns the sandbox commands are started in (nil without one)
+func (e *DefaultExecutor) currentSandbox() *processSandbox {
+	e.sandboxMu.Lock()
+	defer e.sandboxMu.Unlock()
+	return e.sandbox
+}
+
+// allows reports whether command may be run
+func (s *processSandbox) allows(command string) bool {
+	return s == nil || s.allowed == nil || s.allowed[command]
+}
+
+// outputLimit returns the bound on each of a command's stdout and stderr
+func (s *processSandbox) outputLimit() int {
+	if s == nil {
+		return MaxOutputBytes
+	}
+	return s.maxOutput
+}
+
+// apply sets up cmd to run in the sandbox
+func (s *processSandbox) apply(cmd *exec.Cmd) {
+	if s == nil {
+		return
+	}
+
+	cmd.Dir = s.workingDir
+	if s.credential != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: s.credential}
+	}
+	if !s.scrubEnv && s.username == "" {
+		return
+	}
+
+	env := os.Environ()
+	if s.scrubEnv {
+		env = scrubEnv(env, append(append([]string{}, scrubbedEnvKeep...), s.passEnv...))
+	}
+	if s.username != "" {
+		env = setEnv(env, "HOME", s.home)
+		env = setEnv(env, "USER", s.username)
+		env = setEnv(env, "LOGNAME", s.username)
+	}
+	cmd.Env = env
+}
+
+// scrubEnv returns the variables of env named in keep
+func scrubEnv(env []string, keep []string) []string {
+	kept := make([]string, 0, len(keep))
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		for _, k := range keep {
+			if name == k {
+				kept = append(kept, variable)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// setEnv returns env with the variable name set to value
+func setEnv(env []string, name, value string) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, variable := range env {
+		if n, _, _ := strings.Cut(variable, "="); n != name {
+			out = append(out, variable)
+		}
+	}
+	return append(out, name+"="+value)
+}
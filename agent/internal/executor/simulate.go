@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SimulatedExecutor emulates the bv CLI upload commands without touching real nodes.
+// Each status check advances a started upload until it finishes after a fixed number of checks.
+type SimulatedExecutor struct {
+	logger      *logrus.Logger
+	chunksTotal int
+	steps       int
+	mu          sync.Mutex
+	jobs        map[string]*simulatedJob
+}
+
+// simulatedJob tracks the state of one simulated upload
+type simulatedJob struct {
+	startedAt time.Time
+	checks    int
+}
+
+// NewSimulatedExecutor creates a simulated bv backend whose uploads consist of
+// chunksTotal chunks and finish after the given number of status checks
+func NewSimulatedExecutor(logger *logrus.Logger, chunksTotal, steps int) *SimulatedExecutor {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if chunksTotal <= 0 {
+		chunksTotal = 100
+	}
+	if steps <= 0 {
+		steps = 3
+	}
+	return &SimulatedExecutor{
+		logger:      logger,
+		chunksTotal: chunksTotal,
+		steps:       steps,
+		jobs:        make(map[string]*simulatedJob),
+	}
+}
+
+// Execute emulates `bv --version`, `bv node run upload <node>`, `bv node job <node> info
+// upload` and `bv node job <node> stop upload`. Like bv releases without JSON output, it
+// rejects --output, so status checks read the text format.
+func (e *SimulatedExecutor) Execute(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", fmt.Errorf("command canceled: %w", err)
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"component": "executor",
+		"command":   command,
+		"args":      args,
+		"simulated": true,
+	}).Debug("Executing simulated command")
+
+	if command != "bv" && !strings.HasSuffix(command, "/bv") {
+		return "", "", fmt.Errorf("command failed: simulated executor only supports bv, got %s", command)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case len(args) == 1 && args[0] == "--version":
+		return "bv 0.0.0-simulated\n", "", nil
+
+	case len(args) >= 2 && args[len(args)-2] == "--output":
+		return "", "error: unexpected argument '--output' found\n", fmt.Errorf("command failed: exit status 2")
+
+	case len(args) >= 4 && args[0] == "node" && args[1] == "run" && args[2] == "upload":
+		e.jobs[args[3]] = &simulatedJob{startedAt: time.Now().UTC()}
+		return "Started job 'upload'\n", "", nil
+
+	case len(args) == 5 && args[0] == "node" && args[1] == "job" && args[3] == "info" && args[4] == "upload":
+		job, exists := e.jobs[args[2]]
+		if !exists {
+			return "", "Error: job 'upload' not found\n", fmt.Errorf("command failed: exit status 1")
+		}
+		job.checks++
+		return e.statusOutput(job), "", nil
+
+	case len(args) == 5 && args[0] == "node" && args[1] == "job" && args[3] == "stop" && args[4] == "upload":
+		if _, exists := e.jobs[args[2]]; !exists {
+			return "", "Error: job 'upload' not found\n", fmt.Errorf("command failed: exit status 1")
+		}
+		delete(e.jobs, args[2])
+		return "Stopped job 'upload'\n", "", nil
+	}
+
+	return "", "", fmt.Errorf("command failed: unsupported simulated bv command: %s", strings.Join(args, " "))
+}
+
+// statusOutput renders a status block in the same format as `bv node job <node> info upload`
+func (e *SimulatedExecutor) statusOutput(job *simulatedJob) string {
+	timestamp := job.startedAt.Format("2006-01-02 15:04:05")
+
+	if job.checks >= e.steps {
+		return fmt.Sprintf(`status:           %s UTC| Finished with exit code 0 and message `+"`Simulated upload completed`"+`
+progress:         100.00%% (%d/%d multi-client upload completed)
+restart_count:    0
+upgrade_blocking: true
+logs:             <empty>
+`, timestamp, e.chunksTotal, e.chunksTotal)
+	}
+
+	completed := e.chunksTotal * job.checks / e.steps
+	percent := float64(completed) * 100 / float64(e.chunksTotal)
+	return fmt.Sprintf(`status:           %s UTC| Running
+progress:         %.2f%% (%d/%d multi-client upload (in progress clients))
+restart_count:    0
+upgrade_blocking: true
+logs:             <empty>
+`, timestamp, percent, completed, e.chunksTotal)
+}
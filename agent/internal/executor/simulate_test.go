@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSimulatedExecutor_UploadLifecycle(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	executor := NewSimulatedExecutor(logger, 10, 2)
+	ctx := context.Background()
+
+	if stdout, _, err := executor.Execute(ctx, "bv", "--version"); err != nil || !strings.HasPrefix(stdout, "bv ") {
+		t.Errorf("Expected a bv version, got %q, %v", stdout, err)
+	}
+	if _, stderr, err := executor.Execute(ctx, "bv", "node", "job", "test-node", "info", "upload", "--output", "json"); err == nil || !strings.Contains(stderr, "unexpected argument") {
+		t.Errorf("Expected --output to be rejected, got %q, %v", stderr, err)
+	}
+
+	// No upload started yet - bv reports the job as missing
+	_, stderr, err := executor.Execute(ctx, "bv", "node", "job", "test-node", "info", "upload")
+	if err == nil {
+		t.Fatal("Expected error before upload is started")
+	}
+	if !strings.Contains(stderr, "job 'upload' not found") {
+		t.Errorf("Expected job not found stderr, got: %s", stderr)
+	}
+
+	if _, _, err := executor.Execute(ctx, "bv", "node", "run", "upload", "test-node"); err != nil {
+		t.Fatalf("Expected upload to start, got: %v", err)
+	}
+
+	stdout, _, err := executor.Execute(ctx, "bv", "node", "job", "test-node", "info", "upload")
+	if err != nil {
+		t.Fatalf("Expected status check to succeed, got: %v", err)
+	}
+	if !strings.Contains(stdout, "UTC| Running") || !strings.Contains(stdout, "(5/10 ") {
+		t.Errorf("Expected running status at 5/10 chunks, got: %s", stdout)
+	}
+
+	stdout, _, err = executor.Execute(ctx, "bv", "node", "job", "test-node", "info", "upload")
+	if err != nil {
+		t.Fatalf("Expected status check to succeed, got: %v", err)
+	}
+	if !strings.Contains(stdout, "Finished with exit code 0") || !strings.Contains(stdout, "(10/10 ") {
+		t.Errorf("Expected finished status at 10/10 chunks, got: %s", stdout)
+	}
+
+	if _, _, err := executor.Execute(ctx, "bv", "node", "job", "test-node", "stop", "upload"); err != nil {
+		t.Fatalf("Expected upload to stop, got: %v", err)
+	}
+	if _, _, err := executor.Execute(ctx, "bv", "node", "job", "test-node", "info", "upload"); err == nil {
+		t.Error("Expected job to be gone after stop")
+	}
+}
+
+func TestSimulatedExecutor_RejectsOtherCommands(t *testing.T) {
+	executor := NewSimulatedExecutor(nil, 0, 0)
+
+	if _, _, err := executor.Execute(context.Background(), "rm", "-rf", "/"); err == nil {
+		t.Error("Expected error for non-bv command")
+	}
+	if _, _, err := executor.Execute(context.Background(), "bv", "node", "restart", "test-node"); err == nil {
+		t.Error("Expected error for unsupported bv command")
+	}
+}
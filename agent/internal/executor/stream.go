@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+)
+
+// Stream names the output stream a line of a command was written to
+type Stream string
+
+// Output streams of a command
+const (
+	StreamStdout Stream = "stdout"
+	StreamStderr Stream = "stderr"
+)
+
+// MaxLineBytes bounds each line handed to a LineHandler. The rest of a longer line is
+// dropped, so a command that never writes a newline is still streamed in bounded memory.
+const MaxLineBytes = 64 << 10
+
+// LineHandler receives each line of a command's output as it is written, without its
+// line ending. Calls are never concurrent, even across stdout and stderr.
+type LineHandler func(stream Stream, line string)
+
+// StreamingExecutor is implemented by executors that can hand a command's output to a
+// callback line by line instead of buffering it, for long outputs such as job logs
+type StreamingExecutor interface {
+	// ExecuteStream runs a command, passing each line of its stdout and stderr to onLine
+	ExecuteStream(ctx context.Context, onLine LineHandler, command string, args ...string) error
+}
+
+// ExecuteStream runs a command like Execute, but passes each line of its stdout and
+// stderr to onLine as it is written instead of capturing the output. The sandbox's output
+// limit does not apply; each line is bounded by MaxLineBytes.
+func (e *DefaultExecutor) ExecuteStream(ctx context.Context, onLine LineHandler, command string, args ...string) error {
+	var mu sync.Mutex
+	stdout := &lineWriter{stream: StreamStdout, onLine: onLine, mu: &mu}
+	stderr := &lineWriter{stream: StreamStderr, onLine: onLine, mu: &mu}
+	err := e.run(ctx, stdout, stderr, command, args...)
+	stdout.flush()
+	stderr.flush()
+	return err
+}
+
+// lineWriter is an io.Writer splitting what is written into lines for a LineHandler
+type lineWriter struct {
+	stream    Stream
+	onLine    LineHandler
+	mu        *sync.Mutex // Shared by a command's stdout and stderr writers
+	line      []byte
+	truncated bool // The current line exceeded MaxLineBytes
+}
+
+// Write hands each completed line of p to the handler and keeps the rest for later writes
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.append(p)
+			break
+		}
+		w.append(p[:i])
+		w.emit()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush hands a last line without a line ending to the handler
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.line) > 0 || w.truncated {
+		w.emit()
+	}
+}
+
+// append adds p to the current line, up to MaxLineBytes
+func (w *lineWriter) append(p []byte) {
+	if room := MaxLineBytes - len(w.line); len(p) > room {
+		p = p[:room]
+		w.truncated = true
+	}
+	w.line = append(w.line, p...)
+}
+
+// emit hands the current line to the handler and starts the next
+func (w *lineWriter) emit() {
+	line := w.line
+	if w.truncated {
+		line = trimPartialRune(line)
+	}
+	text := strings.TrimRight(string(line), "\r")
+	w.line, w.truncated = w.line[:0], false
+	w.onLine(w.stream, text)
+}
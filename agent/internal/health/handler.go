@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Path is the URL path of the health endpoint
+const Path = "/health"
+
+// Handler serves the startup self-check as JSON. It answers 200 once the daemon is
+// running, in degraded mode too, and 503 while startup checks are still running.
+type Handler struct {
+	selfCheck *SelfCheck
+	logger    *logrus.Logger
+}
+
+// NewHandler creates a handler reporting the self-check
+func NewHandler(selfCheck *SelfCheck, logger *logrus.Logger) *Handler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &Handler{
+		selfCheck: selfCheck,
+		logger:    logger,
+	}
+}
+
+// ServeHTTP writes the self-check report
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := h.selfCheck.Report()
+	status := http.StatusOK
+	if report.Status != StatusOK && report.Status != StatusDegraded {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "health",
+			"error":     err.Error(),
+		}).Warn("Failed to write health report")
+	}
+}
+
+// Serve serves the health endpoint on the listener until ctx is cancelled
+func Serve(ctx context.Context, listener net.Listener, handler *Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
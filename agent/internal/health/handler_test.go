@@ -0,0 +1,53 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	selfCheck := newTestSelfCheck(PolicyDegraded)
+	handler := NewHandler(selfCheck, logger)
+
+	get := func() (int, Report) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+		var report Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("failed to decode report: %v: %s", err, rec.Body.String())
+		}
+		return rec.Code, report
+	}
+
+	// Checks in progress
+	selfCheck.Pass(CheckConfig, "1 nodes")
+	code, report := get()
+	if code != http.StatusServiceUnavailable || report.Status != StatusStarting || len(report.Checks) != 1 {
+		t.Fatalf("expected 503 while starting, got %d: %+v", code, report)
+	}
+
+	// Running in degraded mode
+	selfCheck.Fail(CheckBV, fmt.Errorf("bv not found"))
+	selfCheck.Complete()
+	code, report = get()
+	if code != http.StatusOK || report.Status != StatusDegraded || report.Policy != PolicyDegraded {
+		t.Fatalf("expected 200 degraded report, got %d: %+v", code, report)
+	}
+	if report.Checks[1].Name != CheckBV || report.Checks[1].Error != "bv not found" {
+		t.Errorf("expected failed bv check, got %+v", report.Checks)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", rec.Code)
+	}
+}
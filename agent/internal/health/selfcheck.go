@@ -0,0 +1,192 @@
+package health
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Startup checks, in the order the daemon runs them
+const (
+	CheckConfig        = "config"
+	CheckDatabase      = "database"
+	CheckMigrations    = "migrations"
+	CheckBV            = "bv"
+	CheckProtocols     = "protocols"
+	CheckNotifications = "notifications"
+)
+
+// criticalChecks are the checks the daemon cannot run without. They stop startup under
+// every policy; other failed checks only stop it under the strict policy.
+var criticalChecks = map[string]bool{
+	CheckConfig:     true,
+	CheckDatabase:   true,
+	CheckMigrations: true,
+}
+
+// Startup policies
+const (
+	PolicyStrict   = "strict"
+	PolicyDegraded = "degraded"
+)
+
+// Check outcomes
+const (
+	CheckPassed = "passed"
+	CheckFailed = "failed"
+)
+
+// Daemon statuses
+const (
+	StatusStarting = "starting" // Startup checks are still running
+	StatusOK       = "ok"       // Every check passed
+	StatusDegraded = "degraded" // Running with failed checks under the degraded policy
+	StatusFailed   = "failed"   // A failed check stopped startup
+)
+
+// CheckResult is the outcome of one startup check
+type CheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the startup self-check as served by the health endpoint
+type Report struct {
+	Status      string        `json:"status"`
+	Policy      string        `json:"policy"`
+	StartedAt   time.Time     `json:"started_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+	Checks      []CheckResult `json:"checks"`
+}
+
+// SelfCheck records the daemon's startup checks and decides, under the startup policy,
+// whether a failed check stops startup. It is safe for concurrent use, so the health
+// endpoint can report checks while they run.
+type SelfCheck struct {
+	logger *logrus.Logger
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	report Report
+}
+
+// NewSelfCheck starts a self-check under the strict policy. The policy is usually
+// changed with SetPolicy once the configuration has been loaded.
+func NewSelfCheck(logger *logrus.Logger) *SelfCheck {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &SelfCheck{
+		logger: logger,
+		now:    time.Now,
+		report: Report{
+			Status:    StatusStarting,
+			Policy:    PolicyStrict,
+			StartedAt: time.Now().UTC(),
+			Checks:    []CheckResult{},
+		},
+	}
+}
+
+// SetPolicy sets the startup policy applied to the following checks
+func (s *SelfCheck) SetPolicy(policy string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.Policy = policy
+}
+
+// Pass records a passed check
+func (s *SelfCheck) Pass(name, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.report.Checks = append(s.report.Checks, CheckResult{
+		Name:      name,
+		Status:    CheckPassed,
+		Critical:  criticalChecks[name],
+		Detail:    detail,
+		CheckedAt: s.now().UTC(),
+	})
+
+	s.logger.WithFields(logrus.Fields{
+		"component": "health",
+		"check":     name,
+		"detail":    detail,
+	}).Info("Startup check passed")
+}
+
+// Fail records a failed check and reports whether startup may continue: critical checks
+// always stop it, other checks only under the strict policy
+func (s *SelfCheck) Fail(name string, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	critical := criticalChecks[name]
+	s.report.Checks = append(s.report.Checks, CheckResult{
+		Name:      name,
+		Status:    CheckFailed,
+		Critical:  critical,
+		Error:     err.Error(),
+		CheckedAt: s.now().UTC(),
+	})
+
+	fields := logrus.Fields{
+		"component": "health",
+		"check":     name,
+		"critical":  critical,
+		"policy":    s.report.Policy,
+		"error":     err.Error(),
+	}
+
+	if critical || s.report.Policy != PolicyDegraded {
+		s.report.Status = StatusFailed
+		s.logger.WithFields(fields).Error("Startup check failed, refusing to start")
+		return false
+	}
+
+	s.logger.WithFields(fields).Warn("Startup check failed, starting in degraded mode")
+	return true
+}
+
+// Complete marks the self-check finished and logs the overall result
+func (s *SelfCheck) Complete() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	completedAt := s.now().UTC()
+	s.report.CompletedAt = &completedAt
+
+	var failed []string
+	for _, check := range s.report.Checks {
+		if check.Status == CheckFailed {
+			failed = append(failed, check.Name)
+		}
+	}
+	s.report.Status = StatusOK
+	if len(failed) > 0 {
+		s.report.Status = StatusDegraded
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"component": "health",
+		"status":    s.report.Status,
+		"policy":    s.report.Policy,
+		"failed":    failed,
+	}).Info("Startup self-check completed")
+}
+
+// Report returns a copy of the self-check's current state
+func (s *SelfCheck) Report() Report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report := s.report
+	report.Checks = append([]CheckResult{}, s.report.Checks...)
+	return report
+}
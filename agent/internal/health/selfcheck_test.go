@@ -0,0 +1,79 @@
+package health
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestSelfCheck(policy string) *SelfCheck {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	selfCheck := NewSelfCheck(logger)
+	selfCheck.SetPolicy(policy)
+	return selfCheck
+}
+
+func TestSelfCheck_Fail(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		check     string
+		wantStart bool
+	}{
+		{name: "strict non-critical", policy: PolicyStrict, check: CheckBV, wantStart: false},
+		{name: "degraded non-critical", policy: PolicyDegraded, check: CheckBV, wantStart: true},
+		{name: "strict critical", policy: PolicyStrict, check: CheckDatabase, wantStart: false},
+		{name: "degraded critical", policy: PolicyDegraded, check: CheckMigrations, wantStart: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selfCheck := newTestSelfCheck(tt.policy)
+			selfCheck.Pass(CheckConfig, "2 nodes")
+
+			if got := selfCheck.Fail(tt.check, fmt.Errorf("not available")); got != tt.wantStart {
+				t.Fatalf("Fail() = %v, want %v", got, tt.wantStart)
+			}
+
+			report := selfCheck.Report()
+			if !tt.wantStart {
+				if report.Status != StatusFailed {
+					t.Errorf("expected status %q, got %q", StatusFailed, report.Status)
+				}
+				return
+			}
+
+			selfCheck.Complete()
+			report = selfCheck.Report()
+			if report.Status != StatusDegraded || report.CompletedAt == nil {
+				t.Errorf("expected completed degraded report, got %+v", report)
+			}
+			if len(report.Checks) != 2 || report.Checks[1].Status != CheckFailed || report.Checks[1].Error != "not available" {
+				t.Errorf("expected the failed check recorded after config, got %+v", report.Checks)
+			}
+		})
+	}
+}
+
+func TestSelfCheck_AllPassed(t *testing.T) {
+	selfCheck := newTestSelfCheck(PolicyStrict)
+	if report := selfCheck.Report(); report.Status != StatusStarting {
+		t.Fatalf("expected status %q before completion, got %q", StatusStarting, report.Status)
+	}
+
+	for _, check := range []string{CheckConfig, CheckDatabase, CheckMigrations, CheckBV, CheckProtocols, CheckNotifications} {
+		selfCheck.Pass(check, "")
+	}
+	selfCheck.Complete()
+
+	report := selfCheck.Report()
+	if report.Status != StatusOK || report.Policy != PolicyStrict || len(report.Checks) != 6 {
+		t.Errorf("expected ok report with 6 checks, got %+v", report)
+	}
+	if !report.Checks[0].Critical || report.Checks[3].Critical {
+		t.Errorf("expected config critical and bv not, got %+v", report.Checks)
+	}
+}
@@ -0,0 +1,201 @@
+package hoststats
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// virtualDiskPrefixes are block devices that are not disks, skipped when finding the
+// busiest disk
+var virtualDiskPrefixes = []string{"loop", "ram", "zram"}
+
+// Usage is the host's resource use over the interval between two samples
+type Usage struct {
+	CPUPercent    float64 // Busy time across all cores
+	MemoryPercent float64 // Memory in use, excluding caches the kernel can reclaim
+	DiskIOPercent float64 // Utilization of the busiest disk: share of the interval it had I/O in flight
+	DiskDevice    string  // The busiest disk, empty when the host reports none
+}
+
+// snapshot holds the cumulative kernel counters read by one sample
+type snapshot struct {
+	at       time.Time
+	cpuBusy  uint64            // Jiffies spent outside idle and iowait
+	cpuTotal uint64            // Jiffies in all states
+	diskIO   map[string]uint64 // Milliseconds spent doing I/O, by disk
+}
+
+// Sampler reads host usage from /proc. CPU and disk utilization are rates, so each
+// sample is compared with the previous one.
+type Sampler struct {
+	root string
+	now  func() time.Time
+
+	mu   sync.Mutex
+	prev *snapshot
+}
+
+// NewSampler creates a sampler reading the kernel files under root ("/" for the host)
+func NewSampler(root string) *Sampler {
+	if root == "" {
+		root = "/"
+	}
+	return &Sampler{
+		root: root,
+		now:  time.Now,
+	}
+}
+
+// Sample reads the host's counters and returns the usage since the previous sample. The
+// first sample has no interval to measure, so it returns false.
+func (s *Sampler) Sample() (Usage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.read()
+	if err != nil {
+		return Usage{}, false, err
+	}
+	memory, err := s.readMemory()
+	if err != nil {
+		return Usage{}, false, err
+	}
+
+	prev := s.prev
+	s.prev = current
+	if prev == nil {
+		return Usage{}, false, nil
+	}
+
+	usage := Usage{MemoryPercent: memory}
+	if current.cpuTotal > prev.cpuTotal && current.cpuBusy >= prev.cpuBusy {
+		usage.CPUPercent = percent(float64(current.cpuBusy-prev.cpuBusy), float64(current.cpuTotal-prev.cpuTotal))
+	}
+
+	elapsed := current.at.Sub(prev.at).Milliseconds()
+	if elapsed > 0 {
+		for device, busy := range current.diskIO {
+			before, ok := prev.diskIO[device]
+			if !ok || busy < before {
+				continue
+			}
+			if util := percent(float64(busy-before), float64(elapsed)); util > usage.DiskIOPercent || usage.DiskDevice == "" {
+				usage.DiskIOPercent = util
+				usage.DiskDevice = device
+			}
+		}
+	}
+
+	return usage, true, nil
+}
+
+// read reads the cumulative CPU and disk counters
+func (s *Sampler) read() (*snapshot, error) {
+	snap := &snapshot{at: s.now(), diskIO: make(map[string]uint64)}
+
+	// The first line of /proc/stat sums all cores:
+	// cpu  user nice system idle iowait irq softirq steal guest guest_nice
+	stat, err := os.ReadFile(filepath.Join(s.root, "proc", "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CPU usage: %w", err)
+	}
+	line, _, _ := strings.Cut(string(stat), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return nil, fmt.Errorf("unexpected /proc/stat format: %q", line)
+	}
+	// Guest time is already counted in user time
+	for i, field := range fields[1:min(len(fields), 9)] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected /proc/stat value %q: %w", field, err)
+		}
+		snap.cpuTotal += value
+		if i != 3 && i != 4 { // idle, iowait
+			snap.cpuBusy += value
+		}
+	}
+
+	// /proc/diskstats: major minor name, then counters; the tenth counter is the time
+	// spent doing I/O in milliseconds. Partitions are skipped: only whole disks are
+	// listed in /sys/block.
+	diskstats, err := os.Open(filepath.Join(s.root, "proc", "diskstats"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk usage: %w", err)
+	}
+	defer diskstats.Close()
+
+	scanner := bufio.NewScanner(diskstats)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 || isVirtualDisk(fields[2]) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(s.root, "sys", "block", fields[2])); err != nil {
+			continue
+		}
+		busy, err := strconv.ParseUint(fields[12], 10, 64)
+		if err != nil {
+			continue
+		}
+		snap.diskIO[fields[2]] = busy
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read disk usage: %w", err)
+	}
+
+	return snap, nil
+}
+
+// readMemory returns the share of memory in use, from MemTotal and MemAvailable
+func (s *Sampler) readMemory() (float64, error) {
+	meminfo, err := os.ReadFile(filepath.Join(s.root, "proc", "meminfo"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+
+	values := make(map[string]float64)
+	for _, line := range strings.Split(string(meminfo), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			values[key] = n
+		}
+	}
+
+	total, available := values["MemTotal"], values["MemAvailable"]
+	if total <= 0 {
+		return 0, fmt.Errorf("unexpected /proc/meminfo format: no MemTotal")
+	}
+	return percent(total-available, total), nil
+}
+
+// isVirtualDisk reports whether a block device is a loop device or RAM disk
+func isVirtualDisk(name string) bool {
+	for _, prefix := range virtualDiskPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// percent returns part as a percentage of whole, capped at 100
+func percent(part, whole float64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return min(part/whole*100, 100)
+}
@@ -0,0 +1,80 @@
+package hoststats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeProc writes the kernel files a sample reads under root
+func writeProc(t *testing.T, root, stat, diskstats string) {
+	t.Helper()
+	files := map[string]string{
+		"proc/stat":      stat,
+		"proc/diskstats": diskstats,
+		"proc/meminfo":   "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    4000000 kB\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSampler(t *testing.T) {
+	root := t.TempDir()
+	for _, disk := range []string{"sda", "nvme0n1", "loop0"} {
+		if err := os.MkdirAll(filepath.Join(root, "sys", "block", disk), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2025, 12, 10, 15, 0, 0, 0, time.UTC)
+	sampler := NewSampler(root)
+	sampler.now = func() time.Time { return now }
+
+	writeProc(t, root,
+		"cpu  1000 0 1000 7000 1000 0 0 0 0 0\ncpu0 500 0 500 3500 500 0 0 0 0 0\n",
+		"   8       0 sda 10 0 0 0 10 0 0 0 0 1000 0\n"+
+			"   8       1 sda1 10 0 0 0 10 0 0 0 0 1000 0\n"+
+			" 259       0 nvme0n1 10 0 0 0 10 0 0 0 0 5000 0\n"+
+			"   7       0 loop0 10 0 0 0 10 0 0 0 0 0 0\n")
+	if _, ok, err := sampler.Sample(); err != nil || ok {
+		t.Fatalf("expected the first sample to have no usage, got %v, %v", ok, err)
+	}
+
+	// 10s later: 1000 of 2000 jiffies busy, nvme0n1 busy 9s, sda 1s, the partition and
+	// loop device busy throughout
+	now = now.Add(10 * time.Second)
+	writeProc(t, root,
+		"cpu  1500 0 1500 7500 1500 0 0 0 0 0\n",
+		"   8       0 sda 10 0 0 0 10 0 0 0 0 2000 0\n"+
+			"   8       1 sda1 10 0 0 0 10 0 0 0 0 11000 0\n"+
+			" 259       0 nvme0n1 10 0 0 0 10 0 0 0 0 14000 0\n"+
+			"   7       0 loop0 10 0 0 0 10 0 0 0 0 10000 0\n")
+	usage, ok, err := sampler.Sample()
+	if err != nil || !ok {
+		t.Fatalf("expected usage, got %v, %v", ok, err)
+	}
+
+	if usage.CPUPercent != 50 {
+		t.Errorf("CPUPercent = %v, want 50", usage.CPUPercent)
+	}
+	if usage.MemoryPercent != 75 {
+		t.Errorf("MemoryPercent = %v, want 75", usage.MemoryPercent)
+	}
+	if usage.DiskIOPercent != 90 || usage.DiskDevice != "nvme0n1" {
+		t.Errorf("disk = %v%% on %q, want 90%% on nvme0n1", usage.DiskIOPercent, usage.DiskDevice)
+	}
+}
+
+func TestSampler_MissingFiles(t *testing.T) {
+	if _, _, err := NewSampler(t.TempDir()).Sample(); err == nil {
+		t.Error("expected an error without /proc")
+	}
+}
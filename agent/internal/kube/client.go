@@ -0,0 +1,238 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// requestTimeout bounds each API request besides exec
+const requestTimeout = 30 * time.Second
+
+// Config locates the Kubernetes API server and the credentials used with it. The zero
+// Config uses the pod's service account, for snapperd running in the cluster.
+type Config struct {
+	Server    string // API server URL (default from KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT)
+	TokenFile string // Bearer token file, read on every request as it is rotated (default the service account's)
+	CAFile    string // CA bundle verifying the API server (default the service account's)
+	Namespace string // Namespace used when none is given (default the pod's own)
+}
+
+// APIError is an error response from the API server
+type APIError struct {
+	StatusCode int
+	Reason     string // e.g. NotFound, Conflict, Forbidden
+	Message    string
+}
+
+// Error returns the API server's reason and message
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("kubernetes API request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("kubernetes API request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is an API error for a missing object
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an API error for an object changed or created
+// concurrently
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// status is the Status object the API server returns with errors and exec results
+type status struct {
+	Status  string `json:"status"` // Success or Failure
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Details *struct {
+		Causes []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"causes"`
+	} `json:"details"`
+}
+
+// Client makes the Kubernetes API requests snapperd needs: listing pods, holding a
+// leader Lease and running commands in pods. It speaks HTTP/1.1, which exec requires.
+type Client struct {
+	server    *url.URL
+	tokenFile string
+	namespace string
+	http      *http.Client
+	now       func() time.Time
+
+	leasesMu sync.Mutex
+	leases   map[string]observedLease // Namespace/name -> Lease renewal last seen
+}
+
+// NewClient creates a client from cfg, falling back to the pod's service account
+func NewClient(cfg Config) (*Client, error) {
+	server := cfg.Server
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set and no API server is configured")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+	serverURL, err := url.Parse(server)
+	if err != nil || (serverURL.Scheme != "https" && serverURL.Scheme != "http") || serverURL.Host == "" {
+		return nil, fmt.Errorf("invalid API server URL '%s'", server)
+	}
+
+	tokenFile := cfg.TokenFile
+	if tokenFile == "" {
+		tokenFile = serviceAccountDir + "/token"
+	}
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	caFile := cfg.CAFile
+	if caFile == "" && cfg.Server == "" {
+		caFile = serviceAccountDir + "/ca.crt"
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API server CA: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		if data, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	return &Client{
+		server:    serverURL,
+		tokenFile: tokenFile,
+		namespace: namespace,
+		now:       time.Now,
+		leases:    make(map[string]observedLease),
+		// A custom TLS config without ForceAttemptHTTP2 keeps requests on HTTP/1.1
+		http: &http.Client{Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		}},
+	}, nil
+}
+
+// Namespace returns the namespace used when none is given
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// namespaceOr returns namespace, or the client's when it is empty
+func (c *Client) namespaceOr(namespace string) string {
+	if namespace == "" {
+		return c.namespace
+	}
+	return namespace
+}
+
+// newRequest creates an authenticated request for an API path
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.server
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// do sends a JSON request and decodes the response into out, when not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode kubernetes API response: %w", err)
+	}
+	return nil
+}
+
+// responseError reads the Status of an error response
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	var s status
+	if json.Unmarshal(data, &s) == nil && s.Message != "" {
+		apiErr.Reason, apiErr.Message = s.Reason, s.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
@@ -0,0 +1,61 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestClient creates a client of an API server serving handler, authenticating with
+// the token "secret"
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(Config{Server: server.URL, TokenFile: tokenFile, Namespace: "snapperd"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestNewClient_RequiresCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	if _, err := NewClient(Config{}); err == nil {
+		t.Error("Expected an error outside a cluster without an API server")
+	}
+	if _, err := NewClient(Config{Server: "ftp://example.com"}); err == nil {
+		t.Error("Expected an error for an invalid API server URL")
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the token", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"kind":"Status","status":"Failure","reason":"Forbidden","message":"pods is forbidden"}`))
+	}))
+
+	_, err := client.ListPods(context.Background(), "", "app=geth")
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if IsNotFound(err) || IsConflict(err) {
+		t.Errorf("Error %v reported as not found or conflict", err)
+	}
+	want := "failed to list pods: kubernetes API request failed with status 403: pods is forbidden"
+	if err.Error() != want {
+		t.Errorf("Error = %q, want %q", err, want)
+	}
+}
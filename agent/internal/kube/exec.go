@@ -0,0 +1,299 @@
+package kube
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// execProtocol is the exec subprotocol: binary WebSocket messages whose first byte is the
+// channel, with the command's exit status sent as a Status on the error channel
+const execProtocol = "v4.channel.k8s.io"
+
+// Exec channels
+const (
+	channelStdout = 1
+	channelStderr = 2
+	channelError  = 3
+)
+
+// websocketGUID is appended to the handshake key to prove the server speaks WebSocket
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxFrameBytes bounds a WebSocket frame read from the API server
+const maxFrameBytes = 16 << 20
+
+// WebSocket opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// ExitError reports a command run in a pod that exited with a non-zero code. Its message
+// matches that of a local command, "exit status <code>".
+type ExitError struct {
+	Code int
+}
+
+// Error returns the exit status
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Exec runs a command in a container of a pod (the first container when container is
+// empty) through the exec API, writing its stdout and stderr to the given writers. It
+// returns an *ExitError when the command exits with a non-zero code. Ending ctx closes
+// the connection, which does not stop the command in the pod.
+func (c *Client) Exec(ctx context.Context, namespace, pod, container string, command []string, stdout, stderr io.Writer) error {
+	query := url.Values{"command": command, "stdout": {"true"}, "stderr": {"true"}}
+	if container != "" {
+		query.Set("container", container)
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(c.namespaceOr(namespace)) + "/pods/" + url.PathEscape(pod) + "/exec"
+
+	conn, err := c.dialWebSocket(ctx, path, query)
+	if err != nil {
+		return fmt.Errorf("failed to exec in pod %s: %w", pod, err)
+	}
+	defer conn.Close()
+
+	// Closing the connection ends the read loop when ctx ends
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	result, err := readExecStream(conn, stdout, stderr)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("exec stream from pod %s failed: %w", pod, err)
+	}
+	if result == nil {
+		return fmt.Errorf("exec stream from pod %s closed without the command's status", pod)
+	}
+	return result.err()
+}
+
+// err returns the command's outcome from its exec Status
+func (s *status) err() error {
+	if s.Status == "Success" {
+		return nil
+	}
+	if s.Reason == "NonZeroExitCode" && s.Details != nil {
+		for _, cause := range s.Details.Causes {
+			if cause.Reason == "ExitCode" {
+				if code, err := strconv.Atoi(cause.Message); err == nil {
+					return &ExitError{Code: code}
+				}
+			}
+		}
+	}
+	return errors.New(s.Message)
+}
+
+// wsConn is an upgraded connection, read through a buffer
+type wsConn struct {
+	io.ReadWriteCloser
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+}
+
+// dialWebSocket opens a WebSocket connection speaking the exec subprotocol
+func (c *Client) dialWebSocket(ctx context.Context, path string, query url.Values) (*wsConn, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Protocol", execProtocol)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+
+	// The body of a 101 response is the upgraded connection
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, errors.New("upgraded connection is not writable")
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		rwc.Close()
+		return nil, errors.New("invalid WebSocket handshake response")
+	}
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != execProtocol {
+		rwc.Close()
+		return nil, fmt.Errorf("API server does not support the %s exec protocol", execProtocol)
+	}
+	return &wsConn{ReadWriteCloser: rwc, reader: bufio.NewReader(rwc)}, nil
+}
+
+// readExecStream copies the command's output to stdout and stderr until the server
+// closes the stream, and returns the Status sent on the error channel
+func readExecStream(conn *wsConn, stdout, stderr io.Writer) (*status, error) {
+	var result *status
+	var message []byte
+	for {
+		fin, opcode, payload, err := conn.readFrame()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := conn.writeFrame(opPong, payload); err != nil {
+				return result, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = conn.writeFrame(opClose, payload)
+			return result, nil
+		case opText, opBinary:
+			message = append(message[:0], payload...)
+		case opContinuation:
+			message = append(message, payload...)
+		default:
+			return result, fmt.Errorf("unexpected WebSocket opcode %d", opcode)
+		}
+		if !fin || len(message) == 0 {
+			continue
+		}
+
+		data := message[1:]
+		switch message[0] {
+		case channelStdout:
+			if _, err := stdout.Write(data); err != nil {
+				return result, err
+			}
+		case channelStderr:
+			if _, err := stderr.Write(data); err != nil {
+				return result, err
+			}
+		case channelError:
+			if len(data) == 0 {
+				continue
+			}
+			result = &status{}
+			if err := json.Unmarshal(data, result); err != nil {
+				return nil, fmt.Errorf("invalid exec status: %w", err)
+			}
+		}
+	}
+}
+
+// readFrame reads a WebSocket frame
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = header[0]&0x80 != 0, header[0]&0x0f
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxFrameBytes {
+		return false, 0, nil, fmt.Errorf("WebSocket frame of %d bytes exceeds the limit", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a final WebSocket frame, masked as clients must
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.Write(frame)
+	return err
+}
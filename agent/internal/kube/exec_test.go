@@ -0,0 +1,147 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// execServer answers exec requests over WebSocket with frames from script, after
+// checking the client answers a ping
+type execServer struct {
+	t       *testing.T
+	script  func(conn *wsConn)
+	queries [][]string // command of each request
+}
+
+func (s *execServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.queries = append(s.queries, r.URL.Query()["command"])
+	if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Protocol") != execProtocol {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n" +
+		"Sec-WebSocket-Protocol: " + execProtocol + "\r\n\r\n")
+	rw.Flush()
+	s.script(&wsConn{ReadWriteCloser: conn, reader: bufio.NewReader(conn)})
+}
+
+// serverFrame encodes an unmasked frame, as servers send them
+func serverFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, byte(len(payload))}
+	return append(frame, payload...)
+}
+
+func TestClient_Exec(t *testing.T) {
+	server := &execServer{t: t}
+	client := newTestClient(t, server)
+
+	server.script = func(conn *wsConn) {
+		conn.Write(serverFrame(true, opBinary, []byte("\x01block 42\n")))
+		// A message split across frames, with a ping in between
+		conn.Write(serverFrame(false, opBinary, []byte("\x02warn")))
+		conn.Write(serverFrame(true, opPing, []byte("hi")))
+		fin, opcode, payload, err := conn.readFrame()
+		if err != nil || !fin || opcode != opPong || string(payload) != "hi" {
+			t.Errorf("Reply to ping = %v %d %q %v, want a pong", fin, opcode, payload, err)
+		}
+		conn.Write(serverFrame(true, opContinuation, []byte("ing\n")))
+		conn.Write(serverFrame(true, opBinary, []byte(``+"\x03"+`{"status":"Success"}`)))
+		conn.Write(serverFrame(true, opClose, nil))
+	}
+	var stdout, stderr bytes.Buffer
+	err := client.Exec(context.Background(), "chains", "geth-0", "geth", []string{"geth", "attach"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if stdout.String() != "block 42\n" || stderr.String() != "warning\n" {
+		t.Errorf("Exec() output = %q, %q", stdout.String(), stderr.String())
+	}
+	if !slices.Equal(server.queries[0], []string{"geth", "attach"}) {
+		t.Errorf("Command = %v", server.queries[0])
+	}
+
+	// A non-zero exit code is reported like a local command's
+	server.script = func(conn *wsConn) {
+		conn.Write(serverFrame(true, opBinary, []byte("\x03"+`{"status":"Failure","reason":"NonZeroExitCode",`+
+			`"details":{"causes":[{"reason":"ExitCode","message":"3"}]}}`)))
+	}
+	err = client.Exec(context.Background(), "chains", "geth-0", "", []string{"false"}, &stdout, &stderr)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 || err.Error() != "exit status 3" {
+		t.Errorf("Exec() error = %v, want exit status 3", err)
+	}
+
+	// A stream ending without a status is an error
+	server.script = func(conn *wsConn) {}
+	if err := client.Exec(context.Background(), "chains", "geth-0", "", []string{"true"}, &stdout, &stderr); err == nil {
+		t.Error("Expected an error without the command's status")
+	}
+}
+
+func TestPodExecutor_StopsCanceledCommands(t *testing.T) {
+	server := &execServer{t: t}
+	client := newTestClient(t, server)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	e := NewPodExecutor(client, "chains", "geth-0", "geth", logger)
+
+	// The command is tagged with its exec ID
+	server.script = func(conn *wsConn) {
+		conn.Write(serverFrame(true, opBinary, []byte("\x01out")))
+		conn.Write(serverFrame(true, opBinary, []byte("\x03"+`{"status":"Success"}`)))
+	}
+	stdout, _, err := e.Execute(context.Background(), "rclone", "version")
+	if err != nil || stdout != "out" {
+		t.Fatalf("Execute() = %q, %v", stdout, err)
+	}
+	command := server.queries[0]
+	if len(command) != 4 || command[0] != "env" || !strings.HasPrefix(command[1], "SNAPPERD_EXEC_ID=") ||
+		!slices.Equal(command[2:], []string{"rclone", "version"}) {
+		t.Errorf("Command = %v, want it tagged through env", command)
+	}
+
+	// A canceled command is stopped in the pod
+	server.queries = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	server.script = func(conn *wsConn) {
+		if len(server.queries) == 1 {
+			cancel()
+			conn.readFrame() // Until the client closes the connection
+			return
+		}
+		conn.Write(serverFrame(true, opBinary, []byte("\x03"+`{"status":"Success"}`)))
+	}
+	if _, _, err := e.Execute(ctx, "rclone", "sync"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the cancellation, got %v", err)
+	}
+	if len(server.queries) != 2 {
+		t.Fatalf("Expected a kill command, got %v", server.queries)
+	}
+	tag := server.queries[0][1]
+	kill := server.queries[1]
+	if kill[0] != "sh" || kill[len(kill)-1] != tag {
+		t.Errorf("Kill command = %v, want one killing the processes tagged %s", kill, tag)
+	}
+}
@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/sirupsen/logrus"
+)
+
+// RuntimeKubernetes is the container runtime of a node running in a pod, whose commands
+// are run through the exec API
+const RuntimeKubernetes = "kubernetes"
+
+// killTimeout bounds stopping a canceled command inside its pod
+const killTimeout = 30 * time.Second
+
+// PodExecutor runs commands in a container of a pod through the exec API, for nodes
+// discovered from Kubernetes. Closing an exec connection leaves its command running in
+// the pod, so a command whose context ends is stopped there by its exec ID, as
+// executor.ContainerExecutor does.
+type PodExecutor struct {
+	client    *Client
+	namespace string
+	pod       string
+	container string
+	logger    *logrus.Logger
+}
+
+// NewPodExecutor creates an executor running commands in a container of a pod (the
+// first container when empty)
+func NewPodExecutor(client *Client, namespace, pod, container string, logger *logrus.Logger) *PodExecutor {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &PodExecutor{client: client, namespace: namespace, pod: pod, container: container, logger: logger}
+}
+
+// Execute runs a command in the pod and returns its stdout and stderr
+func (e *PodExecutor) Execute(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+	logFields := logrus.Fields{
+		"component": "executor",
+		"pod":       e.pod,
+		"container": e.container,
+		"command":   command,
+		"args":      args,
+	}
+	e.logger.WithFields(logFields).Debug("Executing command")
+
+	stdoutBuf := executor.NewOutputBuffer(executor.MaxOutputBytes)
+	stderrBuf := executor.NewOutputBuffer(executor.MaxOutputBytes)
+	execID := executor.NewExecID()
+	startTime := time.Now()
+	execErr := e.client.Exec(ctx, e.namespace, e.pod, e.container, executor.TagCommand(execID, command, args...), stdoutBuf, stderrBuf)
+	logFields["duration"] = time.Since(startTime)
+
+	if execErr != nil {
+		if ctx.Err() != nil {
+			e.kill(ctx, execID)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			e.logger.WithFields(logFields).Error("Command execution timed out")
+			return stdoutBuf.String(), stderrBuf.String(), fmt.Errorf("command timed out: %w", execErr)
+		} else if ctx.Err() == context.Canceled {
+			e.logger.WithFields(logFields).Error("Command execution canceled")
+			return stdoutBuf.String(), stderrBuf.String(), fmt.Errorf("command canceled: %w", execErr)
+		}
+
+		logFields["error"] = execErr.Error()
+		logFields["stderr"] = executor.TruncateOutput(stderrBuf.String(), 4<<10)
+		e.logger.WithFields(logFields).Error("Command execution failed")
+		return stdoutBuf.String(), stderrBuf.String(), fmt.Errorf("command failed: %w", execErr)
+	}
+
+	e.logger.WithFields(logFields).Info("Command executed successfully")
+	return stdoutBuf.String(), stderrBuf.String(), nil
+}
+
+// kill stops the processes of a canceled command inside the pod
+func (e *PodExecutor) kill(ctx context.Context, execID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), killTimeout)
+	defer cancel()
+
+	stderr := executor.NewOutputBuffer(4 << 10)
+	if err := e.client.Exec(ctx, e.namespace, e.pod, e.container, executor.KillTaggedCommand(execID), io.Discard, stderr); err != nil {
+		e.logger.WithFields(logrus.Fields{
+			"component": "executor",
+			"pod":       e.pod,
+			"exec_id":   execID,
+			"error":     err.Error(),
+			"stderr":    stderr.String(),
+		}).Warn("Failed to stop a canceled command in its pod")
+	}
+}
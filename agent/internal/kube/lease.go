@@ -0,0 +1,226 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// microTimeFormat is the format of a Lease's times
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// microTime is a Lease time, serialized with microseconds
+type microTime struct {
+	time.Time
+}
+
+// MarshalJSON formats the time with microseconds, or null when zero
+func (t microTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.UTC().Format(microTimeFormat) + `"`), nil
+}
+
+// UnmarshalJSON parses an RFC 3339 time of any precision
+func (t *microTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(`"`+time.RFC3339Nano+`"`, string(data))
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// lease is a coordination.k8s.io/v1 Lease
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string    `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int       `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          microTime `json:"acquireTime"`
+		RenewTime            microTime `json:"renewTime"`
+		LeaseTransitions     int       `json:"leaseTransitions"`
+	} `json:"spec"`
+}
+
+// observedLease is when a client first saw a Lease renewed by its holder. Expiry is
+// judged from the client's own clock, as the holder's clock may be skewed.
+type observedLease struct {
+	holder    string
+	renewTime time.Time
+	at        time.Time
+}
+
+// expired reports whether the holder of current, as observed by c, has not renewed it
+// within its duration
+func (c *Client) expired(key string, current *lease, now time.Time) bool {
+	if current.Spec.HolderIdentity == "" {
+		return true
+	}
+
+	c.leasesMu.Lock()
+	defer c.leasesMu.Unlock()
+	observed, ok := c.leases[key]
+	if !ok || observed.holder != current.Spec.HolderIdentity || !observed.renewTime.Equal(current.Spec.RenewTime.Time) {
+		c.leases[key] = observedLease{holder: current.Spec.HolderIdentity, renewTime: current.Spec.RenewTime.Time, at: now}
+		return false
+	}
+	duration := time.Duration(current.Spec.LeaseDurationSeconds) * time.Second
+	return now.Sub(observed.at) > duration
+}
+
+// Lease is a held Lease, giving its holder leadership until it stops renewing it
+type Lease struct {
+	client    *Client
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+
+	mu      sync.Mutex
+	current *lease // As last written
+}
+
+// TryAcquireLease takes the named Lease of a namespace (the client's when empty) for
+// identity without waiting, creating it when missing. A Lease held by another identity
+// is taken over once the client has seen it go unrenewed for its duration, so a standby
+// must try more often than that. It returns nil when another identity holds the Lease.
+func (c *Client) TryAcquireLease(ctx context.Context, namespace, name, identity string, duration time.Duration) (*Lease, error) {
+	l := &Lease{
+		client:    c,
+		namespace: c.namespaceOr(namespace),
+		name:      name,
+		identity:  identity,
+		duration:  duration,
+	}
+
+	current, err := l.get(ctx)
+	if err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to read lease: %w", err)
+	}
+
+	now := c.now()
+	if current == nil {
+		next := &lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		next.Metadata.Name, next.Metadata.Namespace = l.name, l.namespace
+		l.hold(next, now)
+		created := &lease{}
+		err := c.do(ctx, http.MethodPost, l.collectionPath(), nil, next, created)
+		if IsConflict(err) {
+			return nil, nil // Created concurrently by another identity
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lease: %w", err)
+		}
+		l.current = created
+		return l, nil
+	}
+
+	if current.Spec.HolderIdentity != l.identity && !c.expired(l.namespace+"/"+l.name, current, now) {
+		return nil, nil
+	}
+	if current.Spec.HolderIdentity != l.identity {
+		current.Spec.LeaseTransitions++
+		current.Spec.AcquireTime = microTime{now}
+	}
+	l.hold(current, now)
+	updated, err := l.update(ctx, current)
+	if IsConflict(err) {
+		return nil, nil // Taken or renewed concurrently
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	l.current = updated
+	return l, nil
+}
+
+// Renew extends the Lease by its duration. An error means the Lease may have been taken
+// over and leadership must be given up.
+func (l *Lease) Renew(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.client.now()
+	if l.current.Spec.RenewTime.Add(l.duration).Before(now) {
+		return fmt.Errorf("lease %s expired before it was renewed", l.name)
+	}
+
+	next := *l.current
+	next.Spec.RenewTime = microTime{now}
+	updated, err := l.update(ctx, &next)
+	if IsConflict(err) {
+		return fmt.Errorf("lease %s was changed by another holder", l.name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+	l.current = updated
+	return nil
+}
+
+// Release gives up the Lease, so another identity can take it over at once
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := *l.current
+	next.Spec.HolderIdentity = ""
+	next.Spec.LeaseDurationSeconds = 1
+	if _, err := l.update(ctx, &next); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
+// hold makes identity the holder of next, renewed at now
+func (l *Lease) hold(next *lease, now time.Time) {
+	next.Spec.HolderIdentity = l.identity
+	next.Spec.LeaseDurationSeconds = int(l.duration.Round(time.Second) / time.Second)
+	if next.Spec.AcquireTime.IsZero() {
+		next.Spec.AcquireTime = microTime{now}
+	}
+	next.Spec.RenewTime = microTime{now}
+}
+
+// get reads the Lease
+func (l *Lease) get(ctx context.Context) (*lease, error) {
+	current := &lease{}
+	if err := l.client.do(ctx, http.MethodGet, l.path(), nil, nil, current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// update replaces the Lease, failing with a conflict when it changed since next was read
+func (l *Lease) update(ctx context.Context, next *lease) (*lease, error) {
+	updated := &lease{}
+	if err := l.client.do(ctx, http.MethodPut, l.path(), nil, next, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// collectionPath returns the API path of the namespace's Leases
+func (l *Lease) collectionPath() string {
+	return "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(l.namespace) + "/leases"
+}
+
+// path returns the API path of the Lease
+func (l *Lease) path() string {
+	return l.collectionPath() + "/" + url.PathEscape(l.name)
+}
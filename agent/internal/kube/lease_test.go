@@ -0,0 +1,116 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaseServer serves one Lease, rejecting updates of a stale resource version
+type leaseServer struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+}
+
+func (s *leaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	const path = "/apis/coordination.k8s.io/v1/namespaces/snapperd/leases"
+	conflict := func() {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"status":"Failure","reason":"Conflict","message":"the object has been modified"}`))
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == path+"/snapperd":
+		if s.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":"Failure","reason":"NotFound","message":"leases \"snapperd\" not found"}`))
+			return
+		}
+	case r.Method == http.MethodPost && r.URL.Path == path:
+		if s.lease != nil {
+			conflict()
+			return
+		}
+		s.lease = &lease{}
+		json.NewDecoder(r.Body).Decode(s.lease)
+	case r.Method == http.MethodPut && r.URL.Path == path+"/snapperd":
+		next := &lease{}
+		json.NewDecoder(r.Body).Decode(next)
+		if next.Metadata.ResourceVersion != s.lease.Metadata.ResourceVersion {
+			conflict()
+			return
+		}
+		s.lease = next
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.version++
+		s.lease.Metadata.ResourceVersion = strconv.Itoa(s.version)
+	}
+	json.NewEncoder(w).Encode(s.lease)
+}
+
+func TestClient_TryAcquireLease(t *testing.T) {
+	ctx := context.Background()
+	server := &leaseServer{}
+	a := newTestClient(t, server)
+	b := newTestClient(t, server)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now }
+
+	// The first daemon creates the Lease
+	leaseA, err := a.TryAcquireLease(ctx, "", "snapperd", "snapperd-a", 15*time.Second)
+	if err != nil || leaseA == nil {
+		t.Fatalf("TryAcquireLease() = %v, %v, want the Lease", leaseA, err)
+	}
+	if server.lease.Spec.HolderIdentity != "snapperd-a" || server.lease.Spec.LeaseDurationSeconds != 15 {
+		t.Errorf("Lease spec = %+v", server.lease.Spec)
+	}
+
+	// While the leader renews it, the standby cannot take it
+	for i := 0; i < 3; i++ {
+		if leaseB, err := b.TryAcquireLease(ctx, "", "snapperd", "snapperd-b", 15*time.Second); err != nil || leaseB != nil {
+			t.Fatalf("Standby TryAcquireLease() = %v, %v, want no Lease", leaseB, err)
+		}
+		now = now.Add(10 * time.Second)
+		if err := leaseA.Renew(ctx); err != nil {
+			t.Fatalf("Renew() error = %v", err)
+		}
+	}
+
+	// Once the standby has seen it go unrenewed for its duration, it takes over
+	if leaseB, err := b.TryAcquireLease(ctx, "", "snapperd", "snapperd-b", 15*time.Second); err != nil || leaseB != nil {
+		t.Fatalf("TryAcquireLease() = %v, %v, want no Lease before it expires", leaseB, err)
+	}
+	now = now.Add(16 * time.Second)
+	leaseB, err := b.TryAcquireLease(ctx, "", "snapperd", "snapperd-b", 15*time.Second)
+	if err != nil || leaseB == nil {
+		t.Fatalf("TryAcquireLease() = %v, %v, want the expired Lease", leaseB, err)
+	}
+	if server.lease.Spec.HolderIdentity != "snapperd-b" || server.lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("Lease spec = %+v, want it taken over", server.lease.Spec)
+	}
+
+	// The old leader finds out it lost the Lease
+	if err := leaseA.Renew(ctx); err == nil {
+		t.Error("Expected the old leader's renewal to fail")
+	}
+
+	// A released Lease is taken at once
+	if err := leaseB.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if leaseA, err = a.TryAcquireLease(ctx, "", "snapperd", "snapperd-a", 15*time.Second); err != nil || leaseA == nil {
+		t.Fatalf("TryAcquireLease() = %v, %v, want the released Lease", leaseA, err)
+	}
+}
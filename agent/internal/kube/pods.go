@@ -0,0 +1,84 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Pod is the part of a pod snapperd reads
+type Pod struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	Containers  []string // Names of the pod's containers, in order
+	Phase       string   // Pending, Running, Succeeded, Failed or Unknown
+	CreatedAt   time.Time
+	Deleting    bool // The pod is being deleted
+}
+
+// podList is the JSON of a pod list, with the fields Pod reads
+type podList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []struct {
+		Metadata struct {
+			Name              string            `json:"name"`
+			Namespace         string            `json:"namespace"`
+			Labels            map[string]string `json:"labels"`
+			Annotations       map[string]string `json:"annotations"`
+			CreationTimestamp time.Time         `json:"creationTimestamp"`
+			DeletionTimestamp *time.Time        `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Spec struct {
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// listPageSize is how many pods are requested at once
+const listPageSize = 500
+
+// ListPods returns the pods of a namespace (the client's when empty) matching a label
+// selector, such as "app.kubernetes.io/part-of=chain-nodes"
+func (c *Client) ListPods(ctx context.Context, namespace, labelSelector string) ([]Pod, error) {
+	namespace = c.namespaceOr(namespace)
+	path := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+
+	var pods []Pod
+	query := url.Values{"labelSelector": {labelSelector}, "limit": {fmt.Sprint(listPageSize)}}
+	for {
+		var list podList
+		if err := c.do(ctx, http.MethodGet, path, query, nil, &list); err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+		for _, item := range list.Items {
+			pod := Pod{
+				Name:        item.Metadata.Name,
+				Namespace:   item.Metadata.Namespace,
+				Labels:      item.Metadata.Labels,
+				Annotations: item.Metadata.Annotations,
+				Phase:       item.Status.Phase,
+				CreatedAt:   item.Metadata.CreationTimestamp,
+				Deleting:    item.Metadata.DeletionTimestamp != nil,
+			}
+			for _, container := range item.Spec.Containers {
+				pod.Containers = append(pod.Containers, container.Name)
+			}
+			pods = append(pods, pod)
+		}
+		if list.Metadata.Continue == "" {
+			return pods, nil
+		}
+		query.Set("continue", list.Metadata.Continue)
+	}
+}
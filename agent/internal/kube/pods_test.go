@@ -0,0 +1,50 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestClient_ListPods(t *testing.T) {
+	pages := []string{
+		`{"metadata":{"continue":"page2"},"items":[{"metadata":{"name":"geth-0","namespace":"chains","labels":{"app":"geth"},
+			"annotations":{"snapperd.io/node":"geth"},"creationTimestamp":"2026-01-02T03:04:05Z"},
+			"spec":{"containers":[{"name":"geth"},{"name":"exporter"}]},"status":{"phase":"Running"}}]}`,
+		`{"metadata":{},"items":[{"metadata":{"name":"geth-1","namespace":"chains","creationTimestamp":"2026-01-02T03:04:05Z",
+			"deletionTimestamp":"2026-01-03T00:00:00Z"},"spec":{"containers":[{"name":"geth"}]},"status":{"phase":"Running"}}]}`,
+	}
+	var requests int
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/chains/pods" {
+			t.Errorf("Path = %s, want the chains pods", r.URL.Path)
+		}
+		if r.URL.Query().Get("labelSelector") != "app=geth" {
+			t.Errorf("labelSelector = %q", r.URL.Query().Get("labelSelector"))
+		}
+		wantContinue := map[int]string{0: "", 1: "page2"}[requests]
+		if got := r.URL.Query().Get("continue"); got != wantContinue {
+			t.Errorf("continue = %q, want %q", got, wantContinue)
+		}
+		fmt.Fprint(w, pages[requests])
+		requests++
+	}))
+
+	pods, err := client.ListPods(context.Background(), "chains", "app=geth")
+	if err != nil {
+		t.Fatalf("ListPods() error = %v", err)
+	}
+	if len(pods) != 2 {
+		t.Fatalf("ListPods() returned %d pods, want 2", len(pods))
+	}
+	first := pods[0]
+	if first.Name != "geth-0" || first.Namespace != "chains" || first.Phase != "Running" || first.Deleting ||
+		first.Annotations["snapperd.io/node"] != "geth" || !slices.Equal(first.Containers, []string{"geth", "exporter"}) {
+		t.Errorf("First pod = %+v", first)
+	}
+	if !pods[1].Deleting {
+		t.Errorf("Second pod = %+v, want it deleting", pods[1])
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Path is the URL path of the metrics endpoint
+const Path = "/metrics"
+
+// contentType is the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler serves the collector's metrics for Prometheus to scrape. When a token is
+// configured, requests must carry it as a bearer token.
+type Handler struct {
+	collector *Collector
+	token     string
+	logger    *logrus.Logger
+}
+
+// NewHandler creates a handler serving the collector's metrics; an empty token leaves
+// the endpoint open
+func NewHandler(collector *Collector, token string, logger *logrus.Logger) *Handler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &Handler{
+		collector: collector,
+		token:     token,
+		logger:    logger,
+	}
+}
+
+// ServeHTTP writes the current metrics
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="snapperd"`)
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := h.collector.WriteTo(r.Context(), &body); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "metrics",
+			"error":     err.Error(),
+		}).Error("Failed to collect metrics")
+		http.Error(w, "failed to collect metrics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "metrics",
+			"error":     err.Error(),
+		}).Warn("Failed to write metrics")
+	}
+}
+
+// authorized reports whether the request carries the configured bearer token, if any
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// Serve serves the metrics endpoint on the listener until ctx is cancelled
+func Serve(ctx context.Context, listener net.Listener, handler *Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+// Store is the database the metrics are read from
+type Store interface {
+	GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
+	GetJobStates(ctx context.Context, host string) ([]database.JobState, error)
+	ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error)
+	GetRunningUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
+}
+
+// gauge is a metric family with one sample per node
+type gauge struct {
+	name  string
+	help  string
+	value func(u *database.Upload) (float64, bool) // The node's sample, if its latest snapshot has one
+}
+
+// gauges are the exported metric families, in output order
+var gauges = []gauge{
+	{
+		name: "snapperd_snapshot_size_bytes",
+		help: "Bytes uploaded by the node's latest completed snapshot.",
+		value: func(u *database.Upload) (float64, bool) {
+			if u.SizeBytes == nil {
+				return 0, false
+			}
+			return float64(*u.SizeBytes), true
+		},
+	},
+	{
+		name: "snapperd_snapshot_raw_size_bytes",
+		help: "Size before compression of the node's latest completed snapshot.",
+		value: func(u *database.Upload) (float64, bool) {
+			if u.RawSizeBytes == nil {
+				return 0, false
+			}
+			return float64(*u.RawSizeBytes), true
+		},
+	},
+	{
+		name: "snapperd_snapshot_completed_timestamp_seconds",
+		help: "Unix time the node's latest completed snapshot finished.",
+		value: func(u *database.Upload) (float64, bool) {
+			if u.CompletedAt == nil {
+				return 0, false
+			}
+			return float64(u.CompletedAt.Unix()), true
+		},
+	},
+}
+
+// sloGauge is a metric family with one sample per node with an SLO
+type sloGauge struct {
+	name  string
+	help  string
+	value func(s *scheduler.SLOStatus) float64
+}
+
+// sloGauges are the exported SLO metric families, in output order
+var sloGauges = []sloGauge{
+	{
+		name:  "snapperd_slo_target_percent",
+		help:  "Percent of the node's uploads that must meet its SLO.",
+		value: func(s *scheduler.SLOStatus) float64 { return s.Objective.Target },
+	},
+	{
+		name:  "snapperd_slo_compliance_percent",
+		help:  "Percent of the node's uploads within the SLO window that met its SLO.",
+		value: func(s *scheduler.SLOStatus) float64 { return s.Percent },
+	},
+	{
+		name:  "snapperd_slo_uploads",
+		help:  "Uploads within the SLO window measured against the node's SLO.",
+		value: func(s *scheduler.SLOStatus) float64 { return float64(s.Uploads) },
+	},
+	{
+		name:  "snapperd_slo_error_budget",
+		help:  "Further uploads that may miss the node's SLO before compliance falls below target, negative once it has.",
+		value: func(s *scheduler.SLOStatus) float64 { return float64(s.Budget) },
+	},
+	{
+		name: "snapperd_slo_at_risk",
+		help: "Whether the node's SLO is breached or the running upload is projected to breach it (1) or not (0).",
+		value: func(s *scheduler.SLOStatus) float64 {
+			if s.AtRisk {
+				return 1
+			}
+			return 0
+		},
+	},
+}
+
+// jobGauge is a metric family with one sample per scheduled job
+type jobGauge struct {
+	name  string
+	help  string
+	value func(s *database.JobState) (float64, bool) // The job's sample, if its state has one
+}
+
+// jobGauges are the exported scheduler metric families, in output order
+var jobGauges = []jobGauge{
+	{
+		name: "snapperd_job_last_run_timestamp_seconds",
+		help: "Unix time the scheduled job last started.",
+		value: func(s *database.JobState) (float64, bool) {
+			if s.LastRunAt == nil {
+				return 0, false
+			}
+			return float64(s.LastRunAt.Unix()), true
+		},
+	},
+	{
+		name: "snapperd_job_last_run_duration_seconds",
+		help: "Duration of the scheduled job's last finished run.",
+		value: func(s *database.JobState) (float64, bool) {
+			if s.LastDurationMs == nil {
+				return 0, false
+			}
+			return float64(*s.LastDurationMs) / 1000, true
+		},
+	},
+	{
+		name: "snapperd_job_last_run_success",
+		help: "Whether the scheduled job's last finished run succeeded (1) or failed (0).",
+		value: func(s *database.JobState) (float64, bool) {
+			if s.LastResult == nil || s.LastDurationMs == nil {
+				return 0, false
+			}
+			if *s.LastResult == "succeeded" {
+				return 1, true
+			}
+			return 0, true
+		},
+	},
+	{
+		name: "snapperd_job_next_run_timestamp_seconds",
+		help: "Unix time the scheduled job is next due.",
+		value: func(s *database.JobState) (float64, bool) {
+			if s.NextRunAt == nil {
+				return 0, false
+			}
+			return float64(s.NextRunAt.Unix()), true
+		},
+	},
+}
+
+// passGauge is a metric family with a single sample, of the upload monitor's last pass
+type passGauge struct {
+	name  string
+	help  string
+	value func(p *scheduler.MonitorPass) float64
+}
+
+// passGauges are the exported monitor pass metric families, in output order
+var passGauges = []passGauge{
+	{
+		name:  "snapperd_monitor_pass_duration_seconds",
+		help:  "Duration of the upload monitor's last pass over running uploads and nodes.",
+		value: func(p *scheduler.MonitorPass) float64 { return p.Duration.Seconds() },
+	},
+	{
+		name:  "snapperd_monitor_pass_timestamp_seconds",
+		help:  "Unix time the upload monitor's last pass started.",
+		value: func(p *scheduler.MonitorPass) float64 { return float64(p.StartedAt.Unix()) },
+	},
+	{
+		name:  "snapperd_monitor_pass_uploads",
+		help:  "Running uploads monitored by the upload monitor's last pass.",
+		value: func(p *scheduler.MonitorPass) float64 { return float64(p.Uploads) },
+	},
+	{
+		name:  "snapperd_monitor_pass_probed_nodes",
+		help:  "Nodes probed for uploads started outside the daemon by the upload monitor's last pass.",
+		value: func(p *scheduler.MonitorPass) float64 { return float64(p.Probed) },
+	},
+}
+
+// MonitorSource reports the upload monitor's last pass, implemented by
+// scheduler.UploadMonitorJob
+type MonitorSource interface {
+	LastPass() (scheduler.MonitorPass, bool)
+}
+
+// Collector reads the latest snapshot of each node of a configuration, and the scheduled
+// jobs of the daemon on its host, and writes them in the Prometheus text format. The
+// daemon keeps it in step with nodes registered at runtime through SetNode and RemoveNode.
+type Collector struct {
+	store Store
+	host  string
+	now   func() time.Time
+
+	monitor    MonitorSource         // nil exports no monitor pass metrics
+	metricPool *scheduler.MetricPool // nil exports no metric collection timeouts
+
+	mu    sync.RWMutex
+	nodes map[string]node
+}
+
+// node is what the collector knows about a node from its configuration
+type node struct {
+	protocol string
+	slo      *config.SLOConfig
+}
+
+// NewCollector creates a collector for the nodes of cfg and the jobs of the daemon on host
+func NewCollector(store Store, cfg *config.Config, host string) *Collector {
+	c := &Collector{
+		store: store,
+		host:  host,
+		now:   time.Now,
+		nodes: make(map[string]node, len(cfg.Nodes)),
+	}
+	for nodeName := range cfg.Nodes {
+		c.nodes[nodeName] = newNode(cfg, nodeName)
+	}
+	return c
+}
+
+// newNode reads a node's protocol and SLO from cfg
+func newNode(cfg *config.Config, nodeName string) node {
+	return node{protocol: cfg.Nodes[nodeName].Protocol, slo: cfg.GetNodeSLO(nodeName)}
+}
+
+// SetMonitor exports the last pass of the daemon's upload monitor, and the timeouts of
+// the metric pool its jobs collect protocol metrics through
+func (c *Collector) SetMonitor(monitor MonitorSource, metricPool *scheduler.MetricPool) {
+	c.monitor = monitor
+	c.metricPool = metricPool
+}
+
+// SetNode adds a node registered, or updated, at runtime
+func (c *Collector) SetNode(cfg *config.Config, nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodes[nodeName] = newNode(cfg, nodeName)
+}
+
+// RemoveNode drops a node deregistered at runtime
+func (c *Collector) RemoveNode(nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.nodes, nodeName)
+}
+
+// snapshot is a node's latest completed upload
+type snapshot struct {
+	node     string
+	protocol string
+	upload   *database.Upload
+}
+
+// sloSample is a node's SLO compliance
+type sloSample struct {
+	node     string
+	protocol string
+	status   scheduler.SLOStatus
+}
+
+// WriteTo writes every gauge in the Prometheus text exposition format. Nodes that never
+// completed an upload, or whose engine does not report a value, have no sample, and
+// neither do jobs that have not run yet. SLO metrics are written for nodes with an SLO.
+func (c *Collector) WriteTo(ctx context.Context, w io.Writer) error {
+	c.mu.RLock()
+	nodes := make(map[string]node, len(c.nodes))
+	for nodeName, n := range c.nodes {
+		nodes[nodeName] = n
+	}
+	c.mu.RUnlock()
+
+	now := c.now()
+	snapshots := make([]snapshot, 0, len(nodes))
+	var slos []sloSample
+	for nodeName, n := range nodes {
+		u, err := c.store.GetLatestCompletedUploadForNode(ctx, nodeName)
+		if err != nil {
+			return fmt.Errorf("failed to get latest completed upload for %s: %w", nodeName, err)
+		}
+		if u != nil {
+			snapshots = append(snapshots, snapshot{node: nodeName, protocol: n.protocol, upload: u})
+		}
+
+		if n.slo != nil {
+			status, err := scheduler.NodeSLOStatus(ctx, c.store, nodeName, n.slo, now)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate SLO for %s: %w", nodeName, err)
+			}
+			slos = append(slos, sloSample{node: nodeName, protocol: n.protocol, status: status})
+		}
+	}
+	sort.Slice(snapshots, func(i, k int) bool { return snapshots[i].node < snapshots[k].node })
+	sort.Slice(slos, func(i, k int) bool { return slos[i].node < slos[k].node })
+
+	jobs, err := c.store.GetJobStates(ctx, c.host)
+	if err != nil {
+		return fmt.Errorf("failed to get job states: %w", err)
+	}
+
+	var b strings.Builder
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, s := range snapshots {
+			if value, ok := g.value(s.upload); ok {
+				fmt.Fprintf(&b, "%s{node=\"%s\",protocol=\"%s\"} %g\n", g.name, escapeLabel(s.node), escapeLabel(s.protocol), value)
+			}
+		}
+	}
+	for _, g := range sloGauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for i := range slos {
+			fmt.Fprintf(&b, "%s{node=\"%s\",protocol=\"%s\"} %g\n", g.name, escapeLabel(slos[i].node), escapeLabel(slos[i].protocol), g.value(&slos[i].status))
+		}
+	}
+	for _, g := range jobGauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for i := range jobs {
+			if value, ok := g.value(&jobs[i]); ok {
+				fmt.Fprintf(&b, "%s{job=\"%s\"} %g\n", g.name, escapeLabel(jobs[i].JobName), value)
+			}
+		}
+	}
+	if c.monitor != nil {
+		if pass, ok := c.monitor.LastPass(); ok {
+			for _, g := range passGauges {
+				fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.value(&pass))
+			}
+		}
+	}
+	if c.metricPool != nil {
+		const name = "snapperd_metric_collection_timeouts_total"
+		fmt.Fprintf(&b, "# HELP %s Protocol metric collections stopped by metric_timeout.\n# TYPE %s counter\n%s %d\n", name, name, name, c.metricPool.Timeouts())
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// labelEscaper escapes a label value as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
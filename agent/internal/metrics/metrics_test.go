@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+	"github.com/sirupsen/logrus"
+)
+
+const testToken = "0123456789abcdef"
+
+// mockStore serves fixed latest completed uploads, finished uploads and job states
+type mockStore struct {
+	completed map[string]*database.Upload
+	finished  []database.Upload
+	jobs      []database.JobState
+	err       error
+}
+
+func (m *mockStore) ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error) {
+	var uploads []database.Upload
+	for _, u := range m.finished {
+		if u.NodeName == filter.NodeName && !u.StartedAt.Before(filter.Since) {
+			uploads = append(uploads, u)
+		}
+	}
+	return uploads, m.err
+}
+
+func (m *mockStore) GetRunningUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	return nil, m.err
+}
+
+func (m *mockStore) GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	return m.completed[nodeName], m.err
+}
+
+func (m *mockStore) GetJobStates(ctx context.Context, host string) ([]database.JobState, error) {
+	var jobs []database.JobState
+	for _, job := range m.jobs {
+		if job.Host == host {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func newTestCollector() (*Collector, *mockStore) {
+	completedAt := time.Unix(1790000000, 0)
+	size := int64(3 << 40)
+	raw := int64(5 << 40)
+	nextRunAt := time.Unix(1790000300, 0)
+	failed, running := "failed", "running"
+	durationMs := int64(2500)
+
+	store := &mockStore{completed: map[string]*database.Upload{
+		"eth-node": {ID: 7, NodeName: "eth-node", CompletedAt: &completedAt, SizeBytes: &size, RawSizeBytes: &raw},
+		"arb-node": {ID: 8, NodeName: "arb-node", CompletedAt: &completedAt},
+	}, jobs: []database.JobState{
+		{Host: "snap-1", JobName: "node_upload/eth-node", LastRunAt: &completedAt, LastResult: &running, NextRunAt: &nextRunAt},
+		{Host: "snap-1", JobName: "upload_monitor", LastRunAt: &completedAt, LastResult: &failed, LastDurationMs: &durationMs, NextRunAt: &nextRunAt},
+		{Host: "snap-2", JobName: "upload_monitor", NextRunAt: &nextRunAt},
+	}}
+	// Three of eth-node's four uploads completed within its 8h SLO
+	for i, hours := range []int{6, 7, 9, 5} {
+		startedAt := completedAt.Add(-time.Duration(24*i+hours) * time.Hour)
+		finishedAt := startedAt.Add(time.Duration(hours) * time.Hour)
+		store.finished = append(store.finished, database.Upload{NodeName: "eth-node", Status: "completed", StartedAt: startedAt, CompletedAt: &finishedAt})
+	}
+	cfg := &config.Config{
+		SLO: &config.SLOConfig{Target: 90, MaxDuration: "8h"},
+		Nodes: map[string]config.NodeConfig{
+			"eth-node": {Protocol: "ethereum"},
+			"arb-node": {Protocol: "arbitrum", SLO: &config.SLOConfig{Target: 50, MaxDuration: "12h"}},
+			"new-node": {Protocol: "ethereum"},
+		},
+	}
+	collector := NewCollector(store, cfg, "snap-1")
+	collector.now = func() time.Time { return completedAt.Add(time.Hour) }
+	return collector, store
+}
+
+func TestCollectorWriteTo(t *testing.T) {
+	collector, store := newTestCollector()
+
+	var b strings.Builder
+	if err := collector.WriteTo(context.Background(), &b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := `# HELP snapperd_snapshot_size_bytes Bytes uploaded by the node's latest completed snapshot.
+# TYPE snapperd_snapshot_size_bytes gauge
+snapperd_snapshot_size_bytes{node="eth-node",protocol="ethereum"} 3.298534883328e+12
+# HELP snapperd_snapshot_raw_size_bytes Size before compression of the node's latest completed snapshot.
+# TYPE snapperd_snapshot_raw_size_bytes gauge
+snapperd_snapshot_raw_size_bytes{node="eth-node",protocol="ethereum"} 5.49755813888e+12
+# HELP snapperd_snapshot_completed_timestamp_seconds Unix time the node's latest completed snapshot finished.
+# TYPE snapperd_snapshot_completed_timestamp_seconds gauge
+snapperd_snapshot_completed_timestamp_seconds{node="arb-node",protocol="arbitrum"} 1.79e+09
+snapperd_snapshot_completed_timestamp_seconds{node="eth-node",protocol="ethereum"} 1.79e+09
+# HELP snapperd_slo_target_percent Percent of the node's uploads that must meet its SLO.
+# TYPE snapperd_slo_target_percent gauge
+snapperd_slo_target_percent{node="arb-node",protocol="arbitrum"} 50
+snapperd_slo_target_percent{node="eth-node",protocol="ethereum"} 90
+snapperd_slo_target_percent{node="new-node",protocol="ethereum"} 90
+# HELP snapperd_slo_compliance_percent Percent of the node's uploads within the SLO window that met its SLO.
+# TYPE snapperd_slo_compliance_percent gauge
+snapperd_slo_compliance_percent{node="arb-node",protocol="arbitrum"} 100
+snapperd_slo_compliance_percent{node="eth-node",protocol="ethereum"} 75
+snapperd_slo_compliance_percent{node="new-node",protocol="ethereum"} 100
+# HELP snapperd_slo_uploads Uploads within the SLO window measured against the node's SLO.
+# TYPE snapperd_slo_uploads gauge
+snapperd_slo_uploads{node="arb-node",protocol="arbitrum"} 0
+snapperd_slo_uploads{node="eth-node",protocol="ethereum"} 4
+snapperd_slo_uploads{node="new-node",protocol="ethereum"} 0
+# HELP snapperd_slo_error_budget Further uploads that may miss the node's SLO before compliance falls below target, negative once it has.
+# TYPE snapperd_slo_error_budget gauge
+snapperd_slo_error_budget{node="arb-node",protocol="arbitrum"} 0
+snapperd_slo_error_budget{node="eth-node",protocol="ethereum"} -1
+snapperd_slo_error_budget{node="new-node",protocol="ethereum"} 0
+# HELP snapperd_slo_at_risk Whether the node's SLO is breached or the running upload is projected to breach it (1) or not (0).
+# TYPE snapperd_slo_at_risk gauge
+snapperd_slo_at_risk{node="arb-node",protocol="arbitrum"} 0
+snapperd_slo_at_risk{node="eth-node",protocol="ethereum"} 1
+snapperd_slo_at_risk{node="new-node",protocol="ethereum"} 0
+# HELP snapperd_job_last_run_timestamp_seconds Unix time the scheduled job last started.
+# TYPE snapperd_job_last_run_timestamp_seconds gauge
+snapperd_job_last_run_timestamp_seconds{job="node_upload/eth-node"} 1.79e+09
+snapperd_job_last_run_timestamp_seconds{job="upload_monitor"} 1.79e+09
+# HELP snapperd_job_last_run_duration_seconds Duration of the scheduled job's last finished run.
+# TYPE snapperd_job_last_run_duration_seconds gauge
+snapperd_job_last_run_duration_seconds{job="upload_monitor"} 2.5
+# HELP snapperd_job_last_run_success Whether the scheduled job's last finished run succeeded (1) or failed (0).
+# TYPE snapperd_job_last_run_success gauge
+snapperd_job_last_run_success{job="upload_monitor"} 0
+# HELP snapperd_job_next_run_timestamp_seconds Unix time the scheduled job is next due.
+# TYPE snapperd_job_next_run_timestamp_seconds gauge
+snapperd_job_next_run_timestamp_seconds{job="node_upload/eth-node"} 1.7900003e+09
+snapperd_job_next_run_timestamp_seconds{job="upload_monitor"} 1.7900003e+09
+`
+	if b.String() != want {
+		t.Errorf("unexpected metrics:\n%s", b.String())
+	}
+
+	// A node registered at runtime is exported with its label values escaped, and a
+	// deregistered one is dropped
+	collector.SetNode(&config.Config{Nodes: map[string]config.NodeConfig{`odd"node`: {Protocol: "sol\\ana"}}}, `odd"node`)
+	completedAt := time.Unix(1790000000, 0)
+	store.completed[`odd"node`] = &database.Upload{CompletedAt: &completedAt}
+	collector.RemoveNode("eth-node")
+	b.Reset()
+	if err := collector.WriteTo(context.Background(), &b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if !strings.Contains(b.String(), `{node="odd\"node",protocol="sol\\ana"}`) || strings.Contains(b.String(), `node="eth-node"`) {
+		t.Errorf("unexpected metrics after node changes:\n%s", b.String())
+	}
+}
+
+// mockMonitor reports a fixed last monitor pass
+type mockMonitor struct {
+	pass *scheduler.MonitorPass
+}
+
+func (m *mockMonitor) LastPass() (scheduler.MonitorPass, bool) {
+	if m.pass == nil {
+		return scheduler.MonitorPass{}, false
+	}
+	return *m.pass, true
+}
+
+func TestCollectorMonitorPass(t *testing.T) {
+	collector, _ := newTestCollector()
+	monitor := &mockMonitor{}
+	collector.SetMonitor(monitor, scheduler.NewMetricPool(4, time.Second))
+
+	var b strings.Builder
+	if err := collector.WriteTo(context.Background(), &b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if strings.Contains(b.String(), "snapperd_monitor_pass") {
+		t.Errorf("Expected no monitor pass metrics before the first pass:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), "# TYPE snapperd_metric_collection_timeouts_total counter\nsnapperd_metric_collection_timeouts_total 0\n") {
+		t.Errorf("Expected the metric collection timeouts:\n%s", b.String())
+	}
+
+	monitor.pass = &scheduler.MonitorPass{StartedAt: time.Unix(1790000000, 0), Duration: 1500 * time.Millisecond, Uploads: 3, Probed: 47}
+	b.Reset()
+	if err := collector.WriteTo(context.Background(), &b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE snapperd_monitor_pass_duration_seconds gauge\nsnapperd_monitor_pass_duration_seconds 1.5\n",
+		"snapperd_monitor_pass_timestamp_seconds 1.79e+09\n",
+		"snapperd_monitor_pass_uploads 3\n",
+		"snapperd_monitor_pass_probed_nodes 47\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	collector, store := newTestCollector()
+
+	tests := []struct {
+		name        string
+		method      string
+		token       string
+		header      string
+		storeErr    error
+		wantStatus  int
+		wantMetrics bool
+	}{
+		{name: "open endpoint", method: http.MethodGet, wantStatus: http.StatusOK, wantMetrics: true},
+		{name: "valid token", method: http.MethodGet, token: testToken, header: "Bearer " + testToken, wantStatus: http.StatusOK, wantMetrics: true},
+		{name: "missing token", method: http.MethodGet, token: testToken, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, token: testToken, header: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "head", method: http.MethodHead, wantStatus: http.StatusOK},
+		{name: "post", method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+		{name: "store error", method: http.MethodGet, storeErr: errors.New("database is locked"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.err = tt.storeErr
+			handler := NewHandler(collector, tt.token, logger)
+			req := httptest.NewRequest(tt.method, Path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if !tt.wantMetrics {
+				return
+			}
+			if rec.Header().Get("Content-Type") != contentType {
+				t.Errorf("expected the text exposition format, got %q", rec.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(rec.Body.String(), `snapperd_snapshot_size_bytes{node="eth-node",protocol="ethereum"}`) {
+				t.Errorf("expected the snapshot size, got:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package nodeapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Path is the URL path of the node collection; single nodes are under Path + "/<name>"
+const Path = "/nodes"
+
+// maxBodySize bounds the node configuration accepted in a request
+const maxBodySize = 1 << 20
+
+// ActorHeader names who is calling the API, recorded in the audit log
+const ActorHeader = "X-Snapperd-Actor"
+
+// maxActorLength bounds the recorded actor to the column size
+const maxActorLength = 255
+
+// Registry registers and deregisters nodes at runtime
+type Registry interface {
+	Register(ctx context.Context, nodeName, host string, nodeConfig config.NodeConfig) (bool, error)
+	Deregister(ctx context.Context, nodeName string) error
+	Nodes() []scheduler.NodeInfo
+	Node(nodeName string) (scheduler.NodeInfo, string, bool)
+	Tenant(ctx context.Context, nodeName string) (string, bool, error)
+}
+
+// AuditRecorder records the nodes registered and deregistered in the audit log
+type AuditRecorder interface {
+	RecordAudit(ctx context.Context, entry database.AuditEntry) error
+}
+
+// Handler serves the node API:
+//
+//	GET    /nodes         list every node
+//	GET    /nodes/<name>  describe a node, with a registered node's definition
+//	PUT    /nodes/<name>  register a node, or replace a registered node's config; the
+//	                      optional host query parameter assigns it to one host
+//	DELETE /nodes/<name>  deregister a node
+//
+// Every request must carry the configured token as a bearer token, and may name who is
+// calling in the X-Snapperd-Actor header. A tenant's token instead scopes the request to
+// the tenant's nodes: other nodes are not found, and registered nodes belong to the
+// tenant.
+type Handler struct {
+	registry Registry
+	token    string
+	tenants  map[string]string // Token -> tenant
+	audit    AuditRecorder
+	logger   *logrus.Logger
+	mux      *http.ServeMux
+}
+
+// NewHandler creates a handler serving registry, accepting requests with token
+func NewHandler(registry Registry, token string, logger *logrus.Logger) *Handler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	h := &Handler{
+		registry: registry,
+		token:    token,
+		logger:   logger,
+		mux:      http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET "+Path, h.list)
+	h.mux.HandleFunc("GET "+Path+"/{name}", h.get)
+	h.mux.HandleFunc("PUT "+Path+"/{name}", h.register)
+	h.mux.HandleFunc("DELETE "+Path+"/{name}", h.deregister)
+	return h
+}
+
+// SetAudit records the nodes registered and deregistered through the API in audit
+func (h *Handler) SetAudit(audit AuditRecorder) {
+	h.audit = audit
+}
+
+// SetTenants accepts the tenants' tokens, mapped to their tenant, scoping each request
+// made with one to its tenant
+func (h *Handler) SetTenants(tokens map[string]string) {
+	h.tenants = tokens
+}
+
+// tenantKey is the request context key of the tenant a request is scoped to
+type tenantKey struct{}
+
+// requestTenant returns the tenant a request is scoped to, empty for the API's own token
+func requestTenant(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantKey{}).(string)
+	return tenant
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// listResponse is the body of GET /nodes
+type listResponse struct {
+	Nodes []scheduler.NodeInfo `json:"nodes"`
+}
+
+// nodeResponse is the body of GET /nodes/<name>. Config holds a registered node's
+// definition with the field names of a nodes entry, so it can be sent back in a PUT.
+type nodeResponse struct {
+	scheduler.NodeInfo
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// ServeHTTP authenticates the request and routes it
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := h.authorize(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="snapperd"`)
+		h.write(w, http.StatusUnauthorized, errorResponse{Error: "invalid or missing token"})
+		return
+	}
+	if tenant != "" {
+		r = r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant))
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// authorize reports whether the request carries the configured bearer token or a
+// tenant's token, returning the tenant the request is scoped to
+func (h *Handler) authorize(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	for tenantToken, tenant := range h.tenants {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(tenantToken)) == 1 {
+			return tenant, true
+		}
+	}
+	return "", subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// list writes every node, from the configuration file and registered, or the tenant's
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	nodes := h.registry.Nodes()
+	if tenant := requestTenant(r); tenant != "" {
+		owned := make([]scheduler.NodeInfo, 0, len(nodes))
+		for _, node := range nodes {
+			if node.Tenant == tenant {
+				owned = append(owned, node)
+			}
+		}
+		nodes = owned
+	}
+	h.write(w, http.StatusOK, listResponse{Nodes: nodes})
+}
+
+// get writes the node in the URL. Nodes assigned to another host are not run by this
+// daemon and are not found.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+
+	info, stored, exists := h.registry.Node(nodeName)
+	if tenant := requestTenant(r); !exists || (tenant != "" && info.Tenant != tenant) {
+		h.write(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+	response := nodeResponse{NodeInfo: info}
+	if stored != "" {
+		if err := yaml.Unmarshal([]byte(stored), &response.Config); err != nil {
+			h.fail(w, nodeName, "Failed to decode node config", err)
+			return
+		}
+	}
+	h.write(w, http.StatusOK, response)
+}
+
+// register registers the node in the URL with the node config in the body, written as
+// a nodes entry in JSON or YAML
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		h.write(w, http.StatusRequestEntityTooLarge, errorResponse{Error: "request body too large"})
+		return
+	}
+	nodeConfig, err := config.ParseNodeConfig(body)
+	if err != nil {
+		h.write(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if tenant := requestTenant(r); tenant != "" {
+		if nodeConfig.Tenant != "" && nodeConfig.Tenant != tenant {
+			h.write(w, http.StatusForbidden, errorResponse{Error: "node config names another tenant"})
+			return
+		}
+		if !h.owned(w, r, nodeName, http.StatusForbidden, "node belongs to another tenant") {
+			return
+		}
+		nodeConfig.Tenant = tenant
+	}
+
+	host := r.URL.Query().Get("host")
+	created, err := h.registry.Register(r.Context(), nodeName, host, nodeConfig)
+	if err != nil {
+		h.fail(w, nodeName, "Failed to register node", err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"component": "nodeapi",
+		"node":      nodeName,
+		"host":      host,
+		"created":   created,
+		"remote":    r.RemoteAddr,
+	}).Info("Node registered through the node API")
+	message := "registered"
+	if !created {
+		message = "updated"
+	}
+	if host != "" {
+		message += " for " + host
+	} else {
+		message += " for every host"
+	}
+	h.recordAudit(r, database.AuditNodeRegistered, nodeName, message, database.JSONB{"host": host, "protocol": nodeConfig.Protocol})
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	// A node assigned to another host is not run by this daemon
+	if node, _, exists := h.registry.Node(nodeName); exists {
+		h.write(w, status, node)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// deregister removes the registered node in the URL
+func (h *Handler) deregister(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+
+	if requestTenant(r) != "" && !h.owned(w, r, nodeName, http.StatusNotFound, "node not found") {
+		return
+	}
+	if err := h.registry.Deregister(r.Context(), nodeName); err != nil {
+		h.fail(w, nodeName, "Failed to deregister node", err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"component": "nodeapi",
+		"node":      nodeName,
+		"remote":    r.RemoteAddr,
+	}).Info("Node deregistered through the node API")
+	h.recordAudit(r, database.AuditNodeDeregistered, nodeName, "removed", nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// owned reports whether the node in a tenant's request is new or the tenant's, writing
+// status with message otherwise
+func (h *Handler) owned(w http.ResponseWriter, r *http.Request, nodeName string, status int, message string) bool {
+	tenant, exists, err := h.registry.Tenant(r.Context(), nodeName)
+	if err != nil {
+		h.fail(w, nodeName, "Failed to look up node tenant", err)
+		return false
+	}
+	if exists && tenant != requestTenant(r) {
+		h.write(w, status, errorResponse{Error: message})
+		return false
+	}
+	return true
+}
+
+// recordAudit records an action taken through the API, by the actor the request names.
+// The action has already taken effect, so a failure to record it is only logged.
+func (h *Handler) recordAudit(r *http.Request, action, nodeName, message string, details database.JSONB) {
+	if h.audit == nil {
+		return
+	}
+
+	actor := strings.TrimSpace(r.Header.Get(ActorHeader))
+	if actor == "" {
+		actor = "unknown"
+	}
+	if len(actor) > maxActorLength {
+		actor = actor[:maxActorLength]
+	}
+	if details == nil {
+		details = database.JSONB{}
+	}
+	details["remote"] = r.RemoteAddr
+	if tenant := requestTenant(r); tenant != "" {
+		details["tenant"] = tenant
+	}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		details["user_agent"] = userAgent
+	}
+
+	if err := h.audit.RecordAudit(r.Context(), database.AuditEntry{
+		Actor:    actor,
+		Source:   database.AuditSourceNodeAPI,
+		Action:   action,
+		NodeName: &nodeName,
+		Message:  message,
+		Details:  details,
+	}); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "nodeapi",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to record audit entry")
+	}
+}
+
+// fail writes the status matching a registry error. Unexpected errors are logged and
+// reported without detail.
+func (h *Handler) fail(w http.ResponseWriter, nodeName, message string, err error) {
+	switch {
+	case errors.Is(err, scheduler.ErrInvalidNode):
+		h.write(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, scheduler.ErrNodeConfigured):
+		h.write(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.Is(err, scheduler.ErrNodeNotRegistered):
+		h.write(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	default:
+		h.logger.WithFields(logrus.Fields{
+			"component": "nodeapi",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Error(message)
+		h.write(w, http.StatusInternalServerError, errorResponse{Error: strings.ToLower(message)})
+	}
+}
+
+// write writes a JSON response
+func (h *Handler) write(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "nodeapi",
+			"error":     err.Error(),
+		}).Warn("Failed to write node API response")
+	}
+}
+
+// Serve serves the node API on the listener until ctx is cancelled
+func Serve(ctx context.Context, listener net.Listener, handler *Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
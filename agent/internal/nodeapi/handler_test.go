@@ -0,0 +1,262 @@
+package nodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+	"github.com/sirupsen/logrus"
+)
+
+const testToken = "0123456789abcdef"
+
+// mockRegistry records registrations in memory
+type mockRegistry struct {
+	nodes map[string]config.NodeConfig
+	hosts map[string]string
+}
+
+func (m *mockRegistry) Register(ctx context.Context, nodeName, host string, nodeConfig config.NodeConfig) (bool, error) {
+	switch {
+	case nodeName == "eth-config":
+		return false, scheduler.ErrNodeConfigured
+	case nodeConfig.URL == "":
+		return false, fmt.Errorf("%w: url is required", scheduler.ErrInvalidNode)
+	}
+	_, exists := m.nodes[nodeName]
+	m.nodes[nodeName] = nodeConfig
+	m.hosts[nodeName] = host
+	return !exists, nil
+}
+
+func (m *mockRegistry) Deregister(ctx context.Context, nodeName string) error {
+	if _, exists := m.nodes[nodeName]; !exists {
+		return scheduler.ErrNodeNotRegistered
+	}
+	delete(m.nodes, nodeName)
+	return nil
+}
+
+func (m *mockRegistry) Nodes() []scheduler.NodeInfo {
+	var nodes []scheduler.NodeInfo
+	for nodeName, nodeConfig := range m.nodes {
+		nodes = append(nodes, scheduler.NodeInfo{Name: nodeName, Source: scheduler.NodeSourceRegistered, Host: m.hosts[nodeName], Protocol: nodeConfig.Protocol, Tenant: nodeConfig.Tenant, Schedule: nodeConfig.Schedule})
+	}
+	return nodes
+}
+
+func (m *mockRegistry) Node(nodeName string) (scheduler.NodeInfo, string, bool) {
+	nodeConfig, exists := m.nodes[nodeName]
+	if !exists || m.hosts[nodeName] != "" {
+		return scheduler.NodeInfo{}, "", false
+	}
+	stored, _ := config.EncodeNodeConfig(nodeConfig)
+	return scheduler.NodeInfo{Name: nodeName, Source: scheduler.NodeSourceRegistered, Protocol: nodeConfig.Protocol, Tenant: nodeConfig.Tenant, Schedule: nodeConfig.Schedule}, stored, true
+}
+
+func (m *mockRegistry) Tenant(ctx context.Context, nodeName string) (string, bool, error) {
+	nodeConfig, exists := m.nodes[nodeName]
+	return nodeConfig.Tenant, exists, nil
+}
+
+// mockAudit records audit entries in memory
+type mockAudit struct {
+	entries []database.AuditEntry
+}
+
+func (m *mockAudit) RecordAudit(ctx context.Context, entry database.AuditEntry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	registry := &mockRegistry{nodes: make(map[string]config.NodeConfig), hosts: make(map[string]string)}
+	handler := NewHandler(registry, testToken, logger)
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	node := `{"protocol": "ethereum", "url": "http://10.0.0.5:8545", "schedule": "0 0 */6 * * *"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{name: "missing token", method: http.MethodGet, path: "/nodes", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPut, path: "/nodes/eth-1", token: "fedcba9876543210", body: node, wantStatus: http.StatusUnauthorized},
+		{name: "register", method: http.MethodPut, path: "/nodes/eth-1", token: testToken, body: node, wantStatus: http.StatusCreated},
+		{name: "update", method: http.MethodPut, path: "/nodes/eth-1", token: testToken, body: node, wantStatus: http.StatusOK},
+		{name: "yaml body", method: http.MethodPut, path: "/nodes/eth-2", token: testToken, body: "protocol: ethereum\nurl: http://10.0.0.6:8545\nschedule: 0 0 */6 * * *\n", wantStatus: http.StatusCreated},
+		{name: "unknown field", method: http.MethodPut, path: "/nodes/eth-3", token: testToken, body: `{"protocol": "ethereum", "rpc": "x"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid node", method: http.MethodPut, path: "/nodes/eth-3", token: testToken, body: `{"protocol": "ethereum"}`, wantStatus: http.StatusBadRequest},
+		{name: "configuration file node", method: http.MethodPut, path: "/nodes/eth-config", token: testToken, body: node, wantStatus: http.StatusConflict},
+		{name: "assign to host", method: http.MethodPut, path: "/nodes/eth-2?host=host-b", token: testToken, body: node, wantStatus: http.StatusOK},
+		{name: "get", method: http.MethodGet, path: "/nodes/eth-1", token: testToken, wantStatus: http.StatusOK},
+		{name: "get other host's node", method: http.MethodGet, path: "/nodes/eth-2", token: testToken, wantStatus: http.StatusNotFound},
+		{name: "deregister", method: http.MethodDelete, path: "/nodes/eth-2", token: testToken, wantStatus: http.StatusNoContent},
+		{name: "deregister unknown", method: http.MethodDelete, path: "/nodes/eth-2", token: testToken, wantStatus: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPost, path: "/nodes", token: testToken, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(tt.method, tt.path, tt.token, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if registry.hosts["eth-2"] != "host-b" {
+		t.Errorf("expected eth-2 assigned to host-b, got %q", registry.hosts["eth-2"])
+	}
+
+	rec := request(http.MethodGet, "/nodes", testToken, "")
+	var list listResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode node list: %v: %s", err, rec.Body.String())
+	}
+	if rec.Code != http.StatusOK || len(list.Nodes) != 1 || list.Nodes[0].Name != "eth-1" {
+		t.Errorf("expected eth-1 listed, got %d: %+v", rec.Code, list)
+	}
+
+	// A node's definition can be sent back unchanged
+	rec = request(http.MethodGet, "/nodes/eth-1", testToken, "")
+	var described struct {
+		Name   string          `json:"name"`
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &described); err != nil {
+		t.Fatalf("failed to decode node: %v: %s", err, rec.Body.String())
+	}
+	if described.Name != "eth-1" {
+		t.Errorf("expected eth-1, got %s", rec.Body.String())
+	}
+	if rec := request(http.MethodPut, "/nodes/eth-1", testToken, string(described.Config)); rec.Code != http.StatusOK {
+		t.Errorf("expected the returned config accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if registry.nodes["eth-1"].URL != "http://10.0.0.5:8545" {
+		t.Errorf("expected eth-1 unchanged, got %+v", registry.nodes["eth-1"])
+	}
+}
+
+func TestHandler_Audit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	registry := &mockRegistry{nodes: make(map[string]config.NodeConfig), hosts: make(map[string]string)}
+	audit := &mockAudit{}
+	handler := NewHandler(registry, testToken, logger)
+	handler.SetAudit(audit)
+
+	request := func(method, path, actor, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		if actor != "" {
+			req.Header.Set(ActorHeader, actor)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	node := `{"protocol": "ethereum", "url": "http://10.0.0.5:8545", "schedule": "0 0 */6 * * *"}`
+	request(http.MethodPut, "/nodes/eth-1?host=host-a", "provisioner", node)
+	request(http.MethodPut, "/nodes/eth-2", "provisioner", `{"protocol": "ethereum"}`)
+	request(http.MethodDelete, "/nodes/eth-1", "", "")
+
+	// Rejected requests are not recorded, and a caller without a name is unknown
+	if len(audit.entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", audit.entries)
+	}
+	registered, deregistered := audit.entries[0], audit.entries[1]
+	if registered.Actor != "provisioner" || registered.Source != database.AuditSourceNodeAPI || registered.Action != database.AuditNodeRegistered ||
+		*registered.NodeName != "eth-1" || registered.Message != "registered for host-a" || registered.Details["remote"] == nil {
+		t.Errorf("unexpected registration entry %+v", registered)
+	}
+	if deregistered.Actor != "unknown" || deregistered.Action != database.AuditNodeDeregistered || *deregistered.NodeName != "eth-1" {
+		t.Errorf("unexpected deregistration entry %+v", deregistered)
+	}
+}
+
+func TestHandler_Tenant(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	const tenantToken = "team-a-token-00001"
+	registry := &mockRegistry{nodes: make(map[string]config.NodeConfig), hosts: make(map[string]string)}
+	registry.nodes["eth-b"] = config.NodeConfig{Protocol: "ethereum", URL: "http://10.0.0.9:8545", Tenant: "team-b"}
+	audit := &mockAudit{}
+	handler := NewHandler(registry, testToken, logger)
+	handler.SetTenants(map[string]string{tenantToken: "team-a"})
+	handler.SetAudit(audit)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tenantToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	node := `{"protocol": "ethereum", "url": "http://10.0.0.5:8545", "schedule": "0 0 */6 * * *"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "register", method: http.MethodPut, path: "/nodes/eth-a", body: node, wantStatus: http.StatusCreated},
+		{name: "other tenant in config", method: http.MethodPut, path: "/nodes/eth-c", body: `{"protocol": "ethereum", "url": "http://10.0.0.7:8545", "tenant": "team-b"}`, wantStatus: http.StatusForbidden},
+		{name: "replace other tenant's node", method: http.MethodPut, path: "/nodes/eth-b", body: node, wantStatus: http.StatusForbidden},
+		{name: "get own node", method: http.MethodGet, path: "/nodes/eth-a", wantStatus: http.StatusOK},
+		{name: "get other tenant's node", method: http.MethodGet, path: "/nodes/eth-b", wantStatus: http.StatusNotFound},
+		{name: "deregister other tenant's node", method: http.MethodDelete, path: "/nodes/eth-b", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(tt.method, tt.path, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if registry.nodes["eth-a"].Tenant != "team-a" || registry.nodes["eth-b"].Tenant != "team-b" {
+		t.Errorf("expected eth-a registered for team-a and eth-b untouched, got %+v", registry.nodes)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Details["tenant"] != "team-a" {
+		t.Errorf("expected the registration audited with its tenant, got %+v", audit.entries)
+	}
+
+	rec := request(http.MethodGet, "/nodes", "")
+	var list listResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode node list: %v: %s", err, rec.Body.String())
+	}
+	if len(list.Nodes) != 1 || list.Nodes[0].Name != "eth-a" || list.Nodes[0].Tenant != "team-a" {
+		t.Errorf("expected only the tenant's node listed, got %+v", list.Nodes)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -24,19 +26,25 @@ func (d *DiscordModule) Name() string {
 
 // Send delivers a notification to Discord using a webhook URL
 func (d *DiscordModule) Send(ctx context.Context, url string, payload NotificationPayload) error {
+	_, err := d.Deliver(ctx, url, payload)
+	return err
+}
+
+// Deliver sends a notification to a Discord webhook and returns the webhook's response code
+func (d *DiscordModule) Deliver(ctx context.Context, url string, payload NotificationPayload) (int, error) {
 	// Format the Discord webhook payload
 	webhookPayload := d.formatWebhookPayload(payload)
 
 	// Marshal to JSON
 	jsonData, err := json.Marshal(webhookPayload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal Discord webhook payload: %w", err)
+		return 0, fmt.Errorf("failed to marshal Discord webhook payload: %w", err)
 	}
 
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create Discord webhook request: %w", err)
+		return 0, fmt.Errorf("failed to create Discord webhook request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -48,16 +56,16 @@ func (d *DiscordModule) Send(ctx context.Context, url string, payload Notificati
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send Discord webhook: %w", err)
+		return 0, fmt.Errorf("failed to send Discord webhook: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check response status
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("Discord webhook returned non-success status: %d", resp.StatusCode)
+		return resp.StatusCode, fmt.Errorf("Discord webhook returned non-success status: %d", resp.StatusCode)
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
 
 // formatWebhookPayload formats the notification payload as a Discord webhook message
@@ -65,37 +73,80 @@ func (d *DiscordModule) formatWebhookPayload(payload NotificationPayload) map[st
 	// Determine color based on event type
 	color := d.getColorForEvent(payload.Event)
 
-	// Build embed fields
-	fields := []map[string]interface{}{
-		{
+	// Build embed fields; Discord rejects empty values, so digests have no Node field
+	var fields []map[string]interface{}
+	if payload.NodeName != "" {
+		fields = append(fields, map[string]interface{}{
 			"name":   "Node",
 			"value":  payload.NodeName,
 			"inline": true,
-		},
-		{
+		})
+	}
+	fields = append(fields,
+		map[string]interface{}{
 			"name":   "Event",
 			"value":  string(payload.Event),
 			"inline": true,
 		},
-		{
+		map[string]interface{}{
 			"name":   "Timestamp",
 			"value":  payload.Timestamp.Format(time.RFC3339),
 			"inline": false,
 		},
-	}
+	)
 
-	// Add detail fields
+	// Add detail fields; multi-line values such as log excerpts are shown as code blocks
 	for key, value := range payload.Details {
+		text := fmt.Sprintf("%v", value)
+		if strings.Contains(text, "\n") {
+			fields = append(fields, map[string]interface{}{
+				"name":   key,
+				"value":  d.formatCodeBlock(text),
+				"inline": false,
+			})
+			continue
+		}
 		fields = append(fields, map[string]interface{}{
 			"name":   key,
-			"value":  fmt.Sprintf("%v", value),
+			"value":  text,
 			"inline": true,
 		})
 	}
 
-	// Build the embed
+	// Add node metadata fields in a stable order
+	metadataKeys := make([]string, 0, len(payload.Metadata))
+	for key := range payload.Metadata {
+		metadataKeys = append(metadataKeys, key)
+	}
+	sort.Strings(metadataKeys)
+	for _, key := range metadataKeys {
+		fields = append(fields, map[string]interface{}{
+			"name":   key,
+			"value":  payload.Metadata[key],
+			"inline": true,
+		})
+	}
+
+	// Add actions as links in one field
+	if len(payload.Actions) > 0 {
+		links := make([]string, 0, len(payload.Actions))
+		for _, action := range payload.Actions {
+			links = append(links, fmt.Sprintf("[%s](%s)", action.Label, action.URL))
+		}
+		fields = append(fields, map[string]interface{}{
+			"name":   "Actions",
+			"value":  strings.Join(links, " · "),
+			"inline": false,
+		})
+	}
+
+	// Build the embed, titled for the event unless the payload was rendered with a title
+	title := payload.Title
+	if title == "" {
+		title = d.getTitleForEvent(payload.Event)
+	}
 	embed := map[string]interface{}{
-		"title":       d.getTitleForEvent(payload.Event),
+		"title":       title,
 		"description": payload.Message,
 		"color":       color,
 		"fields":      fields,
@@ -107,6 +158,20 @@ func (d *DiscordModule) formatWebhookPayload(payload NotificationPayload) map[st
 	}
 }
 
+// discordFieldLimit is the maximum length of a Discord embed field value
+const discordFieldLimit = 1024
+
+// formatCodeBlock wraps multi-line text in a code block, keeping the end of the text
+// (the most recent log lines) when it exceeds the Discord field limit
+func (d *DiscordModule) formatCodeBlock(text string) string {
+	const fence = "```"
+	text = strings.ReplaceAll(text, fence, "'''")
+	if limit := discordFieldLimit - 2*len(fence) - 2; len(text) > limit {
+		text = "..." + text[len(text)-limit+3:]
+	}
+	return fence + "\n" + text + "\n" + fence
+}
+
 // getColorForEvent returns the Discord embed color for an event type
 func (d *DiscordModule) getColorForEvent(event NotificationEvent) int {
 	switch event {
@@ -116,21 +181,30 @@ func (d *DiscordModule) getColorForEvent(event NotificationEvent) int {
 		return 0xFFA500 // Orange
 	case EventComplete:
 		return 0x00FF00 // Green
+	case EventBlobRetention:
+		return 0xFFD700 // Yellow
+	case EventStalled:
+		return 0x9B59B6 // Purple
+	case EventMonitorLag:
+		return 0x3498DB // Blue
+	case EventStale:
+		return 0xE67E22 // Dark orange
+	case EventPreflight:
+		return 0xC0392B // Dark red
+	case EventSLO:
+		return 0xF1C40F // Amber
+	case EventInterrupted:
+		return 0x95A5A6 // Slate
+	case EventCircuitOpen:
+		return 0x8B0000 // Maroon
+	case EventDigest:
+		return 0x1ABC9C // Teal
 	default:
 		return 0x808080 // Gray
 	}
 }
 
-// getTitleForEvent returns the Discord embed title for an event type
+// getTitleForEvent returns the Discord embed title for an event type, from its default template
 func (d *DiscordModule) getTitleForEvent(event NotificationEvent) string {
-	switch event {
-	case EventFailure:
-		return "❌ Upload Failed"
-	case EventSkip:
-		return "⏭️ Upload Skipped"
-	case EventComplete:
-		return "✅ Upload Complete"
-	default:
-		return "📢 Notification"
-	}
+	return DefaultTemplate(event).Title
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -181,6 +182,39 @@ func TestDiscordModule_Send(t *testing.T) {
 	}
 }
 
+func TestDeliver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/limited") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	payload := NotificationPayload{Event: EventFailure, NodeName: "test-node", Timestamp: time.Now()}
+	ctx := context.Background()
+
+	attempt := Deliver(ctx, NewDiscordModule(), server.URL+"/ok", payload)
+	if attempt.Err != nil || attempt.StatusCode != http.StatusNoContent || attempt.Type != "discord" {
+		t.Errorf("expected delivered attempt with 204, got %+v", attempt)
+	}
+	if attempt.TargetHash != TargetHash(server.URL+"/ok") || strings.Contains(attempt.TargetHash, "ok") {
+		t.Errorf("expected hashed target, got %q", attempt.TargetHash)
+	}
+
+	attempt = Deliver(ctx, NewDiscordModule(), server.URL+"/limited", payload)
+	if attempt.Err == nil || attempt.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected failed attempt with 429, got %+v", attempt)
+	}
+
+	// Modules that do not report a response code only report the error
+	attempt = Deliver(ctx, &MockNotificationModule{name: "mock"}, "https://example.com/hook", payload)
+	if attempt.Err != nil || attempt.StatusCode != 0 || attempt.Type != "mock" {
+		t.Errorf("expected delivered attempt without status code, got %+v", attempt)
+	}
+}
+
 func TestDiscordModule_Send_ContextCancellation(t *testing.T) {
 	// Create a server that delays response
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -272,6 +306,127 @@ func TestDiscordModule_formatWebhookPayload(t *testing.T) {
 	}
 }
 
+func TestDiscordModule_formatWebhookPayload_Metadata(t *testing.T) {
+	module := NewDiscordModule()
+
+	payload := NotificationPayload{
+		Event:     EventFailure,
+		NodeName:  "test-node",
+		Timestamp: time.Now(),
+		Message:   "Test message",
+		Metadata:  map[string]string{"operator": "ops-team", "datacenter": "fra1"},
+	}
+
+	result := module.formatWebhookPayload(payload)
+	embeds := result["embeds"].([]map[string]interface{})
+	fields := embeds[0]["fields"].([]map[string]interface{})
+
+	// Node, Event, Timestamp followed by metadata sorted by key
+	if len(fields) != 5 {
+		t.Fatalf("fields length = %d, want 5", len(fields))
+	}
+	if fields[3]["name"] != "datacenter" || fields[3]["value"] != "fra1" {
+		t.Errorf("datacenter field incorrect: %v", fields[3])
+	}
+	if fields[4]["name"] != "operator" || fields[4]["value"] != "ops-team" {
+		t.Errorf("operator field incorrect: %v", fields[4])
+	}
+}
+
+func TestDiscordModule_formatWebhookPayload_Actions(t *testing.T) {
+	module := NewDiscordModule()
+
+	payload := NotificationPayload{
+		Event:     EventFailure,
+		NodeName:  "test-node",
+		Timestamp: time.Now(),
+		Actions: []Action{
+			{Label: "Snooze 1h", URL: "https://snapper.example.com/snooze?for=1h"},
+			{Label: "Snooze 4h", URL: "https://snapper.example.com/snooze?for=4h"},
+		},
+	}
+
+	result := module.formatWebhookPayload(payload)
+	embeds := result["embeds"].([]map[string]interface{})
+	fields := embeds[0]["fields"].([]map[string]interface{})
+
+	last := fields[len(fields)-1]
+	want := "[Snooze 1h](https://snapper.example.com/snooze?for=1h) · [Snooze 4h](https://snapper.example.com/snooze?for=4h)"
+	if last["name"] != "Actions" || last["value"] != want {
+		t.Errorf("actions field incorrect: %v", last)
+	}
+}
+
+func TestWithActions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	actions := func(payload NotificationPayload) []Action {
+		return []Action{{Label: "Snooze 1h", URL: "https://snapper.example.com/snooze?node=" + payload.NodeName}}
+	}
+	payload := NotificationPayload{Event: EventFailure, NodeName: "test-node", Timestamp: time.Now()}
+
+	mock := &MockNotificationModule{name: "mock"}
+	module := WithActions(mock, actions)
+	if module.Name() != "mock" {
+		t.Errorf("Name() = %q, want mock", module.Name())
+	}
+	if err := module.Send(context.Background(), "https://example.com/hook", payload); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(mock.lastPayload.Actions) != 1 || mock.lastPayload.Actions[0].URL != "https://snapper.example.com/snooze?node=test-node" {
+		t.Errorf("expected snooze action for test-node, got %v", mock.lastPayload.Actions)
+	}
+	if len(payload.Actions) != 0 {
+		t.Errorf("expected caller's payload to be unchanged, got %v", payload.Actions)
+	}
+
+	// Wrapped modules still report their response code
+	attempt := Deliver(context.Background(), WithActions(NewDiscordModule(), actions), server.URL, payload)
+	if attempt.Err != nil || attempt.StatusCode != http.StatusNoContent || attempt.Type != "discord" {
+		t.Errorf("expected delivered attempt with 204, got %+v", attempt)
+	}
+}
+
+func TestDiscordModule_formatWebhookPayload_LogExcerpt(t *testing.T) {
+	module := NewDiscordModule()
+
+	payload := NotificationPayload{
+		Event:     EventFailure,
+		NodeName:  "test-node",
+		Timestamp: time.Now(),
+		Message:   "Upload failed",
+		Details: map[string]interface{}{
+			"log_excerpt": "uploading chunk 41\nerror: no space left on device",
+		},
+	}
+
+	result := module.formatWebhookPayload(payload)
+	embeds := result["embeds"].([]map[string]interface{})
+	fields := embeds[0]["fields"].([]map[string]interface{})
+
+	field := fields[3]
+	if field["inline"] != false {
+		t.Errorf("log excerpt should not be inline")
+	}
+	want := "```\nuploading chunk 41\nerror: no space left on device\n```"
+	if field["value"] != want {
+		t.Errorf("log excerpt value = %q, want %q", field["value"], want)
+	}
+
+	// Long excerpts keep their most recent lines within the field limit
+	long := strings.Repeat("older log line\n", 200) + "final error"
+	value := module.formatCodeBlock(long)
+	if len(value) > discordFieldLimit {
+		t.Errorf("code block length = %d, want at most %d", len(value), discordFieldLimit)
+	}
+	if !strings.HasSuffix(value, "final error\n```") {
+		t.Errorf("code block should keep the end of the text, got %q", value)
+	}
+}
+
 func TestDiscordModule_getColorForEvent(t *testing.T) {
 	module := NewDiscordModule()
 
@@ -282,6 +437,14 @@ func TestDiscordModule_getColorForEvent(t *testing.T) {
 		{EventFailure, 0xFF0000},
 		{EventSkip, 0xFFA500},
 		{EventComplete, 0x00FF00},
+		{EventBlobRetention, 0xFFD700},
+		{EventStalled, 0x9B59B6},
+		{EventMonitorLag, 0x3498DB},
+		{EventStale, 0xE67E22},
+		{EventPreflight, 0xC0392B},
+		{EventSLO, 0xF1C40F},
+		{EventCircuitOpen, 0x8B0000},
+		{EventDigest, 0x1ABC9C},
 		{NotificationEvent("unknown"), 0x808080},
 	}
 
@@ -305,6 +468,14 @@ func TestDiscordModule_getTitleForEvent(t *testing.T) {
 		{EventFailure, "❌ Upload Failed"},
 		{EventSkip, "⏭️ Upload Skipped"},
 		{EventComplete, "✅ Upload Complete"},
+		{EventBlobRetention, "⚠️ Blob Retention Risk"},
+		{EventStalled, "⏸️ Upload Stalled"},
+		{EventMonitorLag, "🐢 Completion Detected Late"},
+		{EventStale, "🕰️ Snapshot Stale"},
+		{EventPreflight, "🩺 Failed Preflight"},
+		{EventSLO, "🎯 Upload SLO At Risk"},
+		{EventCircuitOpen, "⛔ Node Circuit Open"},
+		{EventDigest, "📊 Upload Digest"},
 		{NotificationEvent("unknown"), "📢 Notification"},
 	}
 
@@ -2,6 +2,8 @@ package notification
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"time"
@@ -11,9 +13,18 @@ import (
 type NotificationEvent string
 
 const (
-	EventFailure  NotificationEvent = "failure"
-	EventSkip     NotificationEvent = "skip"
-	EventComplete NotificationEvent = "complete"
+	EventFailure       NotificationEvent = "failure"
+	EventSkip          NotificationEvent = "skip"
+	EventComplete      NotificationEvent = "complete"
+	EventBlobRetention NotificationEvent = "blob_retention"
+	EventStalled       NotificationEvent = "stalled"
+	EventMonitorLag    NotificationEvent = "monitor_lag"
+	EventStale         NotificationEvent = "stale"
+	EventPreflight     NotificationEvent = "preflight"
+	EventSLO           NotificationEvent = "slo"
+	EventInterrupted   NotificationEvent = "interrupted"
+	EventCircuitOpen   NotificationEvent = "circuit_open"
+	EventDigest        NotificationEvent = "digest" // Periodic summary of every node's uploads, without a node name
 )
 
 // NotificationPayload contains event details for notification delivery
@@ -21,10 +32,22 @@ type NotificationPayload struct {
 	Event     NotificationEvent      `json:"event"`
 	NodeName  string                 `json:"node_name"`
 	Timestamp time.Time              `json:"timestamp"`
+	Title     string                 `json:"title,omitempty"` // Rendered from the event's title template, see Render
 	Message   string                 `json:"message"`
 	Details   map[string]interface{} `json:"details"`
+	Metadata  map[string]string      `json:"metadata,omitempty"` // Static node metadata from configuration
+	Actions   []Action               `json:"actions,omitempty"`  // Links the recipient can follow, such as snoozing the node
 }
 
+// Action is a link offered with a notification
+type Action struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// ActionProvider returns the actions offered with a notification
+type ActionProvider func(payload NotificationPayload) []Action
+
 // NotificationModule defines the interface for notification delivery
 type NotificationModule interface {
 	// Name returns the notification type identifier (e.g., "discord", "slack")
@@ -34,6 +57,81 @@ type NotificationModule interface {
 	Send(ctx context.Context, url string, payload NotificationPayload) error
 }
 
+// DeliveryModule is implemented by notification modules that can report the response code
+// of their delivery endpoint, which is recorded in the notification history
+type DeliveryModule interface {
+	NotificationModule
+
+	// Deliver sends the notification like Send and returns the endpoint's response code
+	// (0 when no response was received)
+	Deliver(ctx context.Context, url string, payload NotificationPayload) (int, error)
+}
+
+// Attempt is the outcome of delivering a notification to one target
+type Attempt struct {
+	Type       string // Notification type (module name)
+	TargetHash string // Hash of the target URL, see TargetHash
+	StatusCode int    // Response code reported by a DeliveryModule (0 when unknown)
+	Err        error  // nil when the notification was delivered
+}
+
+// Deliver sends a payload through a module and reports the outcome of the attempt
+func Deliver(ctx context.Context, module NotificationModule, url string, payload NotificationPayload) Attempt {
+	attempt := Attempt{Type: module.Name(), TargetHash: TargetHash(url)}
+	if deliveryModule, ok := module.(DeliveryModule); ok {
+		attempt.StatusCode, attempt.Err = deliveryModule.Deliver(ctx, url, payload)
+	} else {
+		attempt.Err = module.Send(ctx, url, payload)
+	}
+	return attempt
+}
+
+// actionModule adds actions to every payload before passing it to a module
+type actionModule struct {
+	module  NotificationModule
+	actions ActionProvider
+}
+
+// WithActions wraps a module so every notification it sends carries the actions
+// returned by the provider
+func WithActions(module NotificationModule, actions ActionProvider) NotificationModule {
+	return &actionModule{module: module, actions: actions}
+}
+
+// Name returns the wrapped module's name
+func (a *actionModule) Name() string {
+	return a.module.Name()
+}
+
+// Send delivers the notification with its actions through the wrapped module
+func (a *actionModule) Send(ctx context.Context, url string, payload NotificationPayload) error {
+	return a.module.Send(ctx, url, a.withActions(payload))
+}
+
+// Deliver delivers the notification with its actions and reports the wrapped module's
+// response code
+func (a *actionModule) Deliver(ctx context.Context, url string, payload NotificationPayload) (int, error) {
+	attempt := Deliver(ctx, a.module, url, a.withActions(payload))
+	return attempt.StatusCode, attempt.Err
+}
+
+// withActions returns a copy of the payload with the provider's actions appended
+func (a *actionModule) withActions(payload NotificationPayload) NotificationPayload {
+	actions := a.actions(payload)
+	if len(actions) == 0 {
+		return payload
+	}
+	payload.Actions = append(append([]Action{}, payload.Actions...), actions...)
+	return payload
+}
+
+// TargetHash identifies a notification target without revealing its URL, since webhook
+// URLs embed their credentials
+func TargetHash(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
 // Registry manages notification module registration and retrieval
 type Registry struct {
 	mu      sync.RWMutex
@@ -2,6 +2,10 @@ package notification
 
 import (
 	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -254,3 +258,99 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	received := filepath.Join(dir, "received.json")
+
+	script := `#!/bin/sh
+input=$(cat)
+case "$input" in
+  *'"node_name":"broken-node"'*) echo 'matrix homeserver unavailable' >&2; exit 1 ;;
+esac
+echo "$input" > "$1"
+`
+	if err := os.WriteFile(filepath.Join(dir, "matrix.sh"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write plugin script: %v", err)
+	}
+	definition := "name: matrix\ncommand: ./matrix.sh\nargs: [\"" + received + "\"]\ntimeout: 5s\n"
+	if err := os.WriteFile(filepath.Join(dir, "matrix.yaml"), []byte(definition), 0644); err != nil {
+		t.Fatalf("failed to write plugin definition: %v", err)
+	}
+
+	modules, err := LoadPlugins(dir)
+	if err != nil {
+		t.Fatalf("LoadPlugins() error = %v", err)
+	}
+	if len(modules) != 1 || modules[0].Name() != "matrix" {
+		t.Fatalf("expected the matrix plugin, got %v", modules)
+	}
+
+	registry := NewRegistry()
+	if err := registry.Register(modules[0]); err != nil {
+		t.Fatalf("failed to register plugin: %v", err)
+	}
+	if !registry.IsRegistered("matrix") {
+		t.Fatal("expected matrix to be registered")
+	}
+
+	payload := NotificationPayload{
+		Event:     EventFailure,
+		NodeName:  "test-node",
+		Timestamp: time.Now(),
+		Message:   "Upload failed",
+		Details:   map[string]interface{}{"upload_id": 7},
+	}
+	if err := modules[0].Send(context.Background(), "matrix://!room:example.org", payload); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	data, err := os.ReadFile(received)
+	if err != nil {
+		t.Fatalf("plugin did not receive the request: %v", err)
+	}
+	var request struct {
+		APIVersion int                 `json:"api_version"`
+		URL        string              `json:"url"`
+		Payload    NotificationPayload `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("plugin request is not JSON: %v", err)
+	}
+	if request.APIVersion != PluginAPIVersion {
+		t.Errorf("expected request in API version %d, got %d", PluginAPIVersion, request.APIVersion)
+	}
+	if request.URL != "matrix://!room:example.org" || request.Payload.NodeName != "test-node" || request.Payload.Event != EventFailure {
+		t.Errorf("unexpected plugin request: %+v", request)
+	}
+
+	payload.NodeName = "broken-node"
+	if err := modules[0].Send(context.Background(), "matrix://!room:example.org", payload); err == nil || !strings.Contains(err.Error(), "homeserver unavailable") {
+		t.Errorf("expected delivery failure with stderr, got %v", err)
+	}
+}
+
+func TestLoadPlugins_InvalidDefinitions(t *testing.T) {
+	modules, err := LoadPlugins(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(modules) != 0 {
+		t.Errorf("missing directory should load no plugins, got %v, %v", modules, err)
+	}
+
+	tests := map[string]string{
+		"missing command": "name: teams\n",
+		"missing name":    "command: /bin/true\n",
+		"invalid timeout": "name: teams\ncommand: /bin/true\ntimeout: later\n",
+		"newer API":       "name: teams\ncommand: /bin/true\nmin_api_version: 2\n",
+	}
+	for name, definition := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "teams.yaml"), []byte(definition), 0644); err != nil {
+				t.Fatalf("failed to write plugin definition: %v", err)
+			}
+			if _, err := LoadPlugins(dir); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
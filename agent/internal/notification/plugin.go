@@ -0,0 +1,188 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPluginTimeout bounds a plugin delivery when its definition sets no timeout
+const defaultPluginTimeout = 30 * time.Second
+
+// Notification plugin API versions this agent speaks, versioned independently of protocol
+// plugins. Incompatible changes to the delivery request bump PluginAPIVersion.
+const (
+	MinPluginAPIVersion = 1
+	PluginAPIVersion    = 1
+)
+
+// PluginDefinition describes an external notification module. Definitions are YAML files
+// in the notification plugin directory; the command is run once per notification with
+// the delivery request as JSON on stdin.
+type PluginDefinition struct {
+	Name    string   `yaml:"name"`              // Notification type used in the notifications config
+	Command string   `yaml:"command"`           // Executable, relative paths resolve against the plugin directory
+	Args    []string `yaml:"args,omitempty"`    // Arguments passed to the command
+	Timeout string   `yaml:"timeout,omitempty"` // Maximum run time per delivery (Go duration, default 30s)
+	// MinAPIVersion and MaxAPIVersion are the plugin API versions the plugin speaks
+	// (default 1 to MinAPIVersion)
+	MinAPIVersion int `yaml:"min_api_version,omitempty"`
+	MaxAPIVersion int `yaml:"max_api_version,omitempty"`
+}
+
+// pluginRequest is written to a plugin's stdin
+type pluginRequest struct {
+	APIVersion int                 `json:"api_version"` // Plugin API version the request is in
+	URL        string              `json:"url"`
+	Payload    NotificationPayload `json:"payload"`
+}
+
+// PluginModule implements the NotificationModule interface by running an external executable
+type PluginModule struct {
+	definition PluginDefinition
+	timeout    time.Duration
+	apiVersion int
+}
+
+// NewPluginModule creates a notification module from a plugin definition. Deliveries use
+// the highest API version both the agent and the plugin speak; a plugin sharing none is
+// refused.
+func NewPluginModule(definition PluginDefinition) (*PluginModule, error) {
+	if definition.Name == "" {
+		return nil, fmt.Errorf("plugin name is required")
+	}
+	if definition.Command == "" {
+		return nil, fmt.Errorf("plugin %s: command is required", definition.Name)
+	}
+
+	timeout := defaultPluginTimeout
+	if definition.Timeout != "" {
+		parsed, err := time.ParseDuration(definition.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: invalid timeout '%s': %w", definition.Name, definition.Timeout, err)
+		}
+		if parsed <= 0 {
+			return nil, fmt.Errorf("plugin %s: timeout must be positive", definition.Name)
+		}
+		timeout = parsed
+	}
+
+	apiVersion, err := negotiateAPIVersion(definition.MinAPIVersion, definition.MaxAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", definition.Name, err)
+	}
+
+	return &PluginModule{definition: definition, timeout: timeout, apiVersion: apiVersion}, nil
+}
+
+// negotiateAPIVersion picks the API version for a plugin speaking minVersion to
+// maxVersion, failing with the side to upgrade when the ranges do not overlap
+func negotiateAPIVersion(minVersion, maxVersion int) (int, error) {
+	if minVersion == 0 {
+		minVersion = 1
+	}
+	if maxVersion == 0 {
+		maxVersion = minVersion
+	}
+	if minVersion < 1 || maxVersion < minVersion {
+		return 0, fmt.Errorf("invalid API versions %d to %d", minVersion, maxVersion)
+	}
+
+	switch {
+	case minVersion > PluginAPIVersion:
+		return 0, fmt.Errorf("plugin requires API version %d or later, but this agent speaks versions %d to %d; upgrade the agent", minVersion, MinPluginAPIVersion, PluginAPIVersion)
+	case maxVersion < MinPluginAPIVersion:
+		return 0, fmt.Errorf("plugin speaks API versions up to %d, but this agent requires version %d or later; upgrade the plugin", maxVersion, MinPluginAPIVersion)
+	}
+	return min(maxVersion, PluginAPIVersion), nil
+}
+
+// LoadPlugins reads the plugin definitions (*.yaml, *.yml) in a directory. A missing
+// directory means no plugins are installed.
+func LoadPlugins(dir string) ([]NotificationModule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	modules := make([]NotificationModule, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin definition %s: %w", file, err)
+		}
+
+		var definition PluginDefinition
+		if err := yaml.Unmarshal(data, &definition); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin definition %s: %w", file, err)
+		}
+		if definition.Command != "" && !filepath.IsAbs(definition.Command) && strings.ContainsRune(definition.Command, filepath.Separator) {
+			definition.Command = filepath.Join(dir, definition.Command)
+		}
+
+		module, err := NewPluginModule(definition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin definition %s: %w", file, err)
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+// Name returns the notification type identifier
+func (p *PluginModule) Name() string {
+	return p.definition.Name
+}
+
+// APIVersion returns the plugin API version deliveries are made in
+func (p *PluginModule) APIVersion() int {
+	return p.apiVersion
+}
+
+// Send runs the plugin with the configured URL and payload as JSON on stdin. The plugin
+// reports a failed delivery by exiting with a non-zero status.
+func (p *PluginModule) Send(ctx context.Context, url string, payload NotificationPayload) error {
+	request, err := json.Marshal(pluginRequest{APIVersion: p.apiVersion, URL: url, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.definition.Command, p.definition.Args...)
+	cmd.Stdin = bytes.NewReader(request)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("notification plugin %s timed out after %s", p.definition.Name, p.timeout)
+		}
+		return fmt.Errorf("notification plugin %s failed: %w: %s", p.definition.Name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
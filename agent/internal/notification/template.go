@@ -0,0 +1,194 @@
+package notification
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template sets the title and body of an event's notifications. Both are Go text
+// templates executed with the NotificationPayload, so they can use .NodeName, .Message,
+// .Timestamp, .Metadata and .Details, e.g. {{.Details.size_bytes | bytes}}. An empty field
+// uses the event's default template.
+type Template struct {
+	Title string
+	Body  string
+}
+
+// defaultBody is the body of every default template: the message the event was raised with
+const defaultBody = "{{.Message}}"
+
+// DefaultTemplates are the templates of each event unless configured otherwise
+var DefaultTemplates = map[NotificationEvent]Template{
+	EventFailure:       {Title: "❌ Upload Failed", Body: defaultBody},
+	EventSkip:          {Title: "⏭️ Upload Skipped", Body: defaultBody},
+	EventComplete:      {Title: "✅ Upload Complete", Body: defaultBody},
+	EventBlobRetention: {Title: "⚠️ Blob Retention Risk", Body: defaultBody},
+	EventStalled:       {Title: "⏸️ Upload Stalled", Body: defaultBody},
+	EventMonitorLag:    {Title: "🐢 Completion Detected Late", Body: defaultBody},
+	EventStale:         {Title: "🕰️ Snapshot Stale", Body: defaultBody},
+	EventPreflight:     {Title: "🩺 Failed Preflight", Body: defaultBody},
+	EventSLO:           {Title: "🎯 Upload SLO At Risk", Body: defaultBody},
+	EventInterrupted:   {Title: "🛑 Daemon Stopped During Upload", Body: defaultBody},
+	EventCircuitOpen:   {Title: "⛔ Node Circuit Open", Body: defaultBody},
+	EventDigest:        {Title: "📊 Upload Digest", Body: defaultBody},
+}
+
+// fallbackTemplate is the default template of an event without one of its own
+var fallbackTemplate = Template{Title: "📢 Notification", Body: defaultBody}
+
+// DefaultTemplate returns the default template of an event
+func DefaultTemplate(event NotificationEvent) Template {
+	if t, ok := DefaultTemplates[event]; ok {
+		return t
+	}
+	return fallbackTemplate
+}
+
+// TemplateFuncs are the functions available to templates besides Go's built-ins
+var TemplateFuncs = template.FuncMap{
+	"bytes":    FormatBytes,
+	"duration": FormatDuration,
+	"default":  defaultValue,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// ParseTemplate parses a title or body template
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(TemplateFuncs).Parse(text)
+}
+
+// Render returns the payload with its Title and Message rendered from t, using the event's
+// default template for empty fields. The payload's Message is what the templates see as
+// .Message. A field whose template fails is rendered from the default and the first error
+// is returned with the payload.
+func Render(payload NotificationPayload, t Template) (NotificationPayload, error) {
+	defaults := DefaultTemplate(payload.Event)
+	if t.Title == "" {
+		t.Title = defaults.Title
+	}
+	if t.Body == "" {
+		t.Body = defaults.Body
+	}
+
+	title, titleErr := execute("title", t.Title, payload)
+	if titleErr != nil {
+		title, _ = execute("title", defaults.Title, payload)
+	}
+	body, bodyErr := execute("body", t.Body, payload)
+	if bodyErr != nil {
+		body, _ = execute("body", defaults.Body, payload)
+	}
+
+	payload.Title = strings.TrimSpace(title)
+	payload.Message = strings.TrimSpace(body)
+	if titleErr != nil {
+		return payload, fmt.Errorf("failed to render %s title: %w", payload.Event, titleErr)
+	}
+	if bodyErr != nil {
+		return payload, fmt.Errorf("failed to render %s body: %w", payload.Event, bodyErr)
+	}
+	return payload, nil
+}
+
+// ValidateTemplate checks that event is known and its title and body templates parse,
+// so configured templates are rejected when the configuration is loaded
+func (r *Registry) ValidateTemplate(event, title, body string) error {
+	if _, ok := DefaultTemplates[NotificationEvent(event)]; !ok {
+		return fmt.Errorf("unknown event '%s'", event)
+	}
+	if _, err := ParseTemplate("title", title); err != nil {
+		return fmt.Errorf("%s title: %w", event, err)
+	}
+	if _, err := ParseTemplate("body", body); err != nil {
+		return fmt.Errorf("%s body: %w", event, err)
+	}
+	return nil
+}
+
+// execute renders one template with the payload
+func execute(name, text string, payload NotificationPayload) (string, error) {
+	tmpl, err := ParseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, payload); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// FormatBytes formats a byte count with binary units, e.g. 1.5 GiB. Values that are not
+// numbers are returned as they are.
+func FormatBytes(value interface{}) string {
+	n, ok := toFloat(value)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	const unit = 1024
+	if math.Abs(n) < unit {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	exp := 0
+	for math.Abs(n) >= unit && exp < 6 {
+		n /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", n, "KMGTPE"[exp-1])
+}
+
+// FormatDuration formats a duration, a Go duration string or a number of seconds rounded
+// to the second, e.g. 1h2m3s. Other values are returned as they are.
+func FormatDuration(value interface{}) string {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.Round(time.Second).String()
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d.Round(time.Second).String()
+		}
+		return v
+	}
+	if seconds, ok := toFloat(value); ok {
+		return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprint(value)
+}
+
+// defaultValue returns value, or fallback when value is missing or empty
+func defaultValue(fallback, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	if s, ok := value.(string); ok && s == "" {
+		return fallback
+	}
+	return value
+}
+
+// toFloat converts a numeric value, or a string holding one, to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	payload := NotificationPayload{
+		Event:     EventComplete,
+		NodeName:  "eth-node",
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Message:   "Upload completed successfully",
+		Details: map[string]interface{}{
+			"size_bytes":       int64(1610612736),
+			"duration_seconds": 3723.4,
+		},
+	}
+
+	// Empty templates render the event's defaults
+	got, err := Render(payload, Template{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got.Title != "✅ Upload Complete" || got.Message != payload.Message {
+		t.Errorf("unexpected default rendering: title %q, message %q", got.Title, got.Message)
+	}
+
+	// Custom templates see the payload and the template functions
+	got, err = Render(payload, Template{
+		Title: "{{.NodeName | upper}} done",
+		Body:  "{{.Message}}: {{.Details.size_bytes | bytes}} in {{.Details.duration_seconds | duration}} ({{.Details.region | default \"n/a\"}})",
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got.Title != "ETH-NODE done" {
+		t.Errorf("unexpected title %q", got.Title)
+	}
+	if want := "Upload completed successfully: 1.5 GiB in 1h2m3s (n/a)"; got.Message != want {
+		t.Errorf("expected message %q, got %q", want, got.Message)
+	}
+
+	// A field that fails to render falls back to its default and reports the error
+	got, err = Render(payload, Template{Title: "{{.Missing}}", Body: "custom {{.NodeName}}"})
+	if err == nil || !strings.Contains(err.Error(), "title") {
+		t.Errorf("expected a title error, got %v", err)
+	}
+	if got.Title != "✅ Upload Complete" || got.Message != "custom eth-node" {
+		t.Errorf("unexpected fallback rendering: title %q, message %q", got.Title, got.Message)
+	}
+}
+
+func TestRegistry_ValidateTemplate(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.ValidateTemplate(string(EventFailure), "{{.NodeName}} failed", "{{.Message | lower}}"); err != nil {
+		t.Errorf("expected a valid template, got %v", err)
+	}
+	if err := registry.ValidateTemplate("unknown", "", ""); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+	if err := registry.ValidateTemplate(string(EventFailure), "{{.NodeName", ""); err == nil {
+		t.Error("expected an error for a title that does not parse")
+	}
+	if err := registry.ValidateTemplate(string(EventFailure), "", "{{nosuchfunc .Message}}"); err == nil {
+		t.Error("expected an error for a body using an unknown function")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{512, "512 B"},
+		{int64(1536), "1.5 KiB"},
+		{float64(5 << 30), "5.0 GiB"},
+		{"1048576", "1.0 MiB"},
+		{"unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.value); got != tt.want {
+			t.Errorf("FormatBytes(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{90 * time.Second, "1m30s"},
+		{"2h30m0.4s", "2h30m0s"},
+		{3600, "1h0m0s"},
+		{61.6, "1m2s"},
+		{"soon", "soon"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.value); got != tt.want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/tracing"
 )
 
 // ArbitrumModule implements the ProtocolModule interface for Arbitrum nodes
@@ -21,7 +22,7 @@ type ArbitrumModule struct {
 // NewArbitrumModule creates a new Arbitrum protocol module
 func NewArbitrumModule() *ArbitrumModule {
 	return &ArbitrumModule{
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Transport: &tracing.Transport{}},
 	}
 }
 
@@ -37,17 +38,72 @@ func (a *ArbitrumModule) Aliases() []string {
 
 // CollectMetrics executes Arbitrum-specific RPC queries
 func (a *ArbitrumModule) CollectMetrics(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
-	metrics := make(map[string]interface{})
+	metrics := newMetricSet(cfg)
 
 	// Query eth_blockNumber from Arbitrum node
-	blockNumber, err := a.queryBlockNumber(ctx, cfg.URL)
+	metrics.collect(ctx, "latest_block", func(ctx context.Context) (interface{}, error) {
+		return a.queryBlockNumber(ctx, cfg.URL)
+	})
+
+	return metrics.result(), nil
+}
+
+// FinalizedBlock returns the latest finalized block number
+func (a *ArbitrumModule) FinalizedBlock(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+	return a.queryFinalizedBlock(ctx, cfg.URL)
+}
+
+// Syncing reports whether the Nitro node is still syncing
+func (a *ArbitrumModule) Syncing(ctx context.Context, cfg config.NodeConfig) (bool, error) {
+	respData, err := a.doJSONRPCRequest(ctx, cfg.URL, ethSyncingRequest)
 	if err != nil {
-		metrics["latest_block"] = nil
-	} else {
-		metrics["latest_block"] = blockNumber
+		return false, err
 	}
+	return parseEthSyncing(respData)
+}
 
-	return metrics, nil
+// queryFinalizedBlock queries the finalized block number via JSON-RPC
+func (e *ArbitrumModule) queryFinalizedBlock(ctx context.Context, rpcURL string) (int64, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{"finalized", false},
+		"id":      1,
+	}
+
+	respData, err := e.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result *struct {
+			Number string `json:"number"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	if response.Result == nil {
+		return 0, fmt.Errorf("no finalized block available")
+	}
+
+	blockNumber, err := e.hexToInt64(response.Result.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hex block number to decimal: %w", err)
+	}
+
+	return blockNumber, nil
 }
 
 // queryBlockNumber queries the latest block number via JSON-RPC
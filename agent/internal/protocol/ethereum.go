@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/tracing"
 )
 
 // EthereumModule implements the ProtocolModule interface for Ethereum nodes
@@ -21,7 +22,7 @@ type EthereumModule struct {
 // NewEthereumModule creates a new Ethereum protocol module
 func NewEthereumModule() *EthereumModule {
 	return &EthereumModule{
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Transport: &tracing.Transport{}},
 	}
 }
 
@@ -32,36 +33,127 @@ func (e *EthereumModule) Name() string {
 
 // CollectMetrics executes Ethereum-specific RPC queries
 func (e *EthereumModule) CollectMetrics(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
-	metrics := make(map[string]interface{})
+	metrics := newMetricSet(cfg)
 
 	// Query eth_blockNumber from execution client
-	blockNumber, err := e.queryBlockNumber(ctx, cfg.URL)
-	if err != nil {
-		metrics["latest_block"] = nil
-	} else {
-		metrics["latest_block"] = blockNumber
-	}
+	metrics.collect(ctx, "latest_block", func(ctx context.Context) (interface{}, error) {
+		return e.queryBlockNumber(ctx, cfg.URL)
+	})
 
 	// Build beacon URL from base URL
 	beaconURL := fmt.Sprintf("%s/beacon", cfg.URL)
 
 	// Query beacon chain slot
-	slot, err := e.queryBeaconSlot(ctx, beaconURL)
+	metrics.collect(ctx, "latest_slot", func(ctx context.Context) (interface{}, error) {
+		return e.queryBeaconSlot(ctx, beaconURL)
+	})
+
+	// Query earliest blob
+	metrics.collect(ctx, "earliest_blob", func(ctx context.Context) (interface{}, error) {
+		return e.queryEarliestBlob(ctx, beaconURL)
+	})
+
+	return metrics.result(), nil
+}
+
+// FinalizedBlock returns the latest finalized block number
+func (e *EthereumModule) FinalizedBlock(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+	return e.queryFinalizedBlock(ctx, cfg.URL)
+}
+
+// Syncing reports whether the execution or the consensus client is still syncing
+func (e *EthereumModule) Syncing(ctx context.Context, cfg config.NodeConfig) (bool, error) {
+	respData, err := e.doJSONRPCRequest(ctx, cfg.URL, ethSyncingRequest)
 	if err != nil {
-		metrics["latest_slot"] = nil
-	} else {
-		metrics["latest_slot"] = slot
+		return false, err
+	}
+	syncing, err := parseEthSyncing(respData)
+	if err != nil || syncing {
+		return syncing, err
 	}
 
-	// Query earliest blob
-	earliestBlob, err := e.queryEarliestBlob(ctx, beaconURL)
+	return e.queryBeaconSyncing(ctx, fmt.Sprintf("%s/beacon", cfg.URL))
+}
+
+// queryBeaconSyncing queries whether the beacon node is syncing
+func (e *EthereumModule) queryBeaconSyncing(ctx context.Context, beaconURL string) (bool, error) {
+	url := fmt.Sprintf("%s/eth/v1/node/syncing", beaconURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var response struct {
+		Data struct {
+			IsSyncing bool `json:"is_syncing"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return response.Data.IsSyncing, nil
+}
+
+// queryFinalizedBlock queries the finalized block number via JSON-RPC
+func (e *EthereumModule) queryFinalizedBlock(ctx context.Context, rpcURL string) (int64, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{"finalized", false},
+		"id":      1,
+	}
+
+	respData, err := e.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result *struct {
+			Number string `json:"number"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	if response.Result == nil {
+		return 0, fmt.Errorf("no finalized block available")
+	}
+
+	blockNumber, err := e.hexToInt64(response.Result.Number)
 	if err != nil {
-		metrics["earliest_blob"] = nil
-	} else {
-		metrics["earliest_blob"] = earliestBlob
+		return 0, fmt.Errorf("failed to convert hex block number to decimal: %w", err)
 	}
 
-	return metrics, nil
+	return blockNumber, nil
 }
 
 // queryBlockNumber queries the latest block number via JSON-RPC
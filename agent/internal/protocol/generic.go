@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/tracing"
+)
+
+// GenericModule implements the ProtocolModule interface for chains without a dedicated
+// module. Each node declares its own JSON-RPC metric queries in the configuration.
+type GenericModule struct {
+	httpClient *http.Client
+}
+
+// NewGenericModule creates a new generic JSON-RPC protocol module
+func NewGenericModule() *GenericModule {
+	return &GenericModule{
+		httpClient: &http.Client{Transport: &tracing.Transport{}},
+	}
+}
+
+// Name returns the protocol identifier
+func (g *GenericModule) Name() string {
+	return config.GenericProtocol
+}
+
+// CollectMetrics executes the node's configured metric queries
+func (g *GenericModule) CollectMetrics(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+	metrics := newMetricSet(cfg)
+
+	for _, query := range cfg.Metrics {
+		metrics.collect(ctx, query.Key, func(ctx context.Context) (interface{}, error) {
+			return g.queryMetric(ctx, cfg.URL, query)
+		})
+	}
+
+	return metrics.result(), nil
+}
+
+// queryMetric executes a single metric query and extracts its value from the response
+func (g *GenericModule) queryMetric(ctx context.Context, rpcURL string, query config.MetricQueryConfig) (interface{}, error) {
+	params := query.Params
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  query.Method,
+		"params":  params,
+		"id":      1,
+	}
+
+	respData, err := g.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(respData))
+	decoder.UseNumber()
+	var response interface{}
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if object, ok := response.(map[string]interface{}); ok && object["error"] != nil {
+		return nil, fmt.Errorf("RPC error: %v", object["error"])
+	}
+
+	value, err := extractJSONPath(response, query.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.formatValue(value, query.Format)
+}
+
+// formatValue converts an extracted value according to the metric's format. Without a
+// format, integral numbers become int64 and other values are stored as returned.
+func (g *GenericModule) formatValue(value interface{}, format string) (interface{}, error) {
+	switch format {
+	case "hex":
+		hexStr, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a hex string, got %T", value)
+		}
+		return g.hexToInt64(hexStr)
+	case "int":
+		var text string
+		switch v := value.(type) {
+		case json.Number:
+			text = v.String()
+		case string:
+			text = v
+		default:
+			return nil, fmt.Errorf("expected an integer, got %T", value)
+		}
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer '%s': %w", text, err)
+		}
+		return n, nil
+	}
+
+	if number, ok := value.(json.Number); ok {
+		if n, err := number.Int64(); err == nil {
+			return n, nil
+		}
+		return number.Float64()
+	}
+	return value, nil
+}
+
+// extractJSONPath returns the value at a JSONPath such as $.result.sync_info[0].height.
+// Only child (.name) and index ([n]) steps are supported.
+func extractJSONPath(document interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with '$'")
+	}
+
+	current := document
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("empty field name in path %s", path)
+			}
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot select field %s from a non-object", name)
+			}
+			value, exists := object[name]
+			if !exists {
+				return nil, fmt.Errorf("field %s not found", name)
+			}
+			current = value
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in path %s", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index '%s' in path %s", rest[1:end], path)
+			}
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot index a non-array")
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("index %d out of range", index)
+			}
+			current = array[index]
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' in path %s", rest[0], path)
+		}
+	}
+
+	return current, nil
+}
+
+// doJSONRPCRequest performs a JSON-RPC request
+func (g *GenericModule) doJSONRPCRequest(ctx context.Context, url string, reqBody map[string]interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
+
+// hexToInt64 converts a hexadecimal string (with or without 0x prefix) to int64
+func (g *GenericModule) hexToInt64(hexStr string) (int64, error) {
+	// Remove 0x prefix if present
+	hexStr = strings.TrimPrefix(hexStr, "0x")
+
+	// Parse as hexadecimal
+	value, err := strconv.ParseInt(hexStr, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex string '%s': %w", hexStr, err)
+	}
+
+	return value, nil
+}
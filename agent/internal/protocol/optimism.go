@@ -0,0 +1,263 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/tracing"
+)
+
+// OptimismModule implements the ProtocolModule interface for OP Stack nodes (OP Mainnet, Base)
+type OptimismModule struct {
+	httpClient *http.Client
+}
+
+// opSyncStatus is the subset of op-node's optimism_syncStatus result used for metrics
+type opSyncStatus struct {
+	CurrentL1   opBlockRef `json:"current_l1"`
+	HeadL1      opBlockRef `json:"head_l1"`
+	UnsafeL2    opBlockRef `json:"unsafe_l2"`
+	SafeL2      opBlockRef `json:"safe_l2"`
+	FinalizedL2 opBlockRef `json:"finalized_l2"`
+}
+
+// opBlockRef is a block reference as reported by op-node
+type opBlockRef struct {
+	Hash   string `json:"hash"`
+	Number int64  `json:"number"`
+}
+
+// NewOptimismModule creates a new Optimism protocol module
+func NewOptimismModule() *OptimismModule {
+	return &OptimismModule{
+		httpClient: &http.Client{Transport: &tracing.Transport{}},
+	}
+}
+
+// Name returns the protocol identifier
+func (o *OptimismModule) Name() string {
+	return "optimism"
+}
+
+// Aliases returns alternative protocol identifiers that map to this module.
+func (o *OptimismModule) Aliases() []string {
+	return []string{"base", "op-mainnet"}
+}
+
+// CollectMetrics executes OP Stack RPC queries against op-geth and op-node
+func (o *OptimismModule) CollectMetrics(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+	metrics := newMetricSet(cfg)
+
+	// Query eth_blockNumber from the execution client (op-geth)
+	metrics.collect(ctx, "latest_block", func(ctx context.Context) (interface{}, error) {
+		return o.queryBlockNumber(ctx, cfg.URL)
+	})
+
+	// Build op-node rollup URL from base URL
+	rollupURL := fmt.Sprintf("%s/rollup", cfg.URL)
+
+	// Query op-node sync status for L2 heads and L1 derivation progress
+	statusKeys := []string{"unsafe_l2_block", "safe_l2_block", "finalized_l2_block", "current_l1_block", "head_l1_block", "l1_sync_lag"}
+	metrics.collectAll(ctx, statusKeys, func(ctx context.Context) (map[string]interface{}, error) {
+		status, err := o.querySyncStatus(ctx, rollupURL)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"unsafe_l2_block":    status.UnsafeL2.Number,
+			"safe_l2_block":      status.SafeL2.Number,
+			"finalized_l2_block": status.FinalizedL2.Number,
+			"current_l1_block":   status.CurrentL1.Number,
+			"head_l1_block":      status.HeadL1.Number,
+			"l1_sync_lag":        status.HeadL1.Number - status.CurrentL1.Number,
+		}, nil
+	})
+
+	return metrics.result(), nil
+}
+
+// FinalizedBlock returns the latest finalized L2 block number
+func (o *OptimismModule) FinalizedBlock(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+	return o.queryFinalizedBlock(ctx, cfg.URL)
+}
+
+// querySyncStatus queries op-node's optimism_syncStatus via JSON-RPC
+func (o *OptimismModule) querySyncStatus(ctx context.Context, rollupURL string) (*opSyncStatus, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "optimism_syncStatus",
+		"params":  []interface{}{},
+		"id":      1,
+	}
+
+	respData, err := o.doJSONRPCRequest(ctx, rollupURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result *opSyncStatus `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	if response.Result == nil {
+		return nil, fmt.Errorf("no sync status available")
+	}
+
+	return response.Result, nil
+}
+
+// Syncing reports whether op-geth is still syncing
+func (o *OptimismModule) Syncing(ctx context.Context, cfg config.NodeConfig) (bool, error) {
+	respData, err := o.doJSONRPCRequest(ctx, cfg.URL, ethSyncingRequest)
+	if err != nil {
+		return false, err
+	}
+	return parseEthSyncing(respData)
+}
+
+// queryFinalizedBlock queries the finalized block number via JSON-RPC
+func (o *OptimismModule) queryFinalizedBlock(ctx context.Context, rpcURL string) (int64, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{"finalized", false},
+		"id":      1,
+	}
+
+	respData, err := o.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result *struct {
+			Number string `json:"number"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	if response.Result == nil {
+		return 0, fmt.Errorf("no finalized block available")
+	}
+
+	blockNumber, err := o.hexToInt64(response.Result.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hex block number to decimal: %w", err)
+	}
+
+	return blockNumber, nil
+}
+
+// queryBlockNumber queries the latest block number via JSON-RPC
+func (o *OptimismModule) queryBlockNumber(ctx context.Context, rpcURL string) (int64, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_blockNumber",
+		"params":  []interface{}{},
+		"id":      1,
+	}
+
+	respData, err := o.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	// Convert hexadecimal string to decimal
+	blockNumber, err := o.hexToInt64(response.Result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hex block number to decimal: %w", err)
+	}
+
+	return blockNumber, nil
+}
+
+// doJSONRPCRequest performs a JSON-RPC request
+func (o *OptimismModule) doJSONRPCRequest(ctx context.Context, url string, reqBody map[string]interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
+
+// hexToInt64 converts a hexadecimal string (with or without 0x prefix) to int64
+func (o *OptimismModule) hexToInt64(hexStr string) (int64, error) {
+	// Remove 0x prefix if present
+	hexStr = strings.TrimPrefix(hexStr, "0x")
+
+	// Parse as hexadecimal
+	value, err := strconv.ParseInt(hexStr, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex string '%s': %w", hexStr, err)
+	}
+
+	return value, nil
+}
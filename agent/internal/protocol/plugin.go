@@ -0,0 +1,308 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPluginTimeout bounds a plugin invocation when its definition sets no timeout
+const defaultPluginTimeout = 30 * time.Second
+
+// Protocol plugin API versions this agent speaks. A change to the request or response
+// format that older plugins or agents cannot handle bumps PluginAPIVersion; support for a
+// version is dropped by raising MinPluginAPIVersion.
+const (
+	MinPluginAPIVersion = 1
+	PluginAPIVersion    = 1
+)
+
+// PluginDefinition describes an external protocol module. Definitions are YAML files in
+// the plugin directory; the command is run once per request with a JSON request on
+// stdin and must write a JSON response to stdout.
+type PluginDefinition struct {
+	Name     string   `yaml:"name"`               // Protocol identifier
+	Aliases  []string `yaml:"aliases,omitempty"`  // Alternative protocol identifiers
+	Command  string   `yaml:"command"`            // Executable, relative paths resolve against the plugin directory
+	Args     []string `yaml:"args,omitempty"`     // Arguments passed to the command
+	Timeout  string   `yaml:"timeout,omitempty"`  // Maximum run time per request (Go duration, default 30s)
+	Finality bool     `yaml:"finality,omitempty"` // The plugin answers finalized_block requests
+	// MinAPIVersion and MaxAPIVersion are the plugin API versions the plugin speaks
+	// (default 1 to MinAPIVersion). Requests use the highest version both sides speak.
+	MinAPIVersion int `yaml:"min_api_version,omitempty"`
+	MaxAPIVersion int `yaml:"max_api_version,omitempty"`
+}
+
+// pluginRequest is written to a plugin's stdin
+type pluginRequest struct {
+	APIVersion int              `json:"api_version"` // Plugin API version the request is in
+	Action     string           `json:"action"`      // "collect_metrics" or "finalized_block"
+	Node       pluginNodeConfig `json:"node"`
+}
+
+// pluginNodeConfig is the node configuration passed to a plugin
+type pluginNodeConfig struct {
+	Protocol string            `json:"protocol"`
+	Type     string            `json:"type"`
+	URL      string            `json:"url"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// pluginResponse is read from a plugin's stdout
+type pluginResponse struct {
+	Metrics        map[string]interface{} `json:"metrics"`
+	FinalizedBlock *int64                 `json:"finalized_block"`
+	Error          string                 `json:"error"`
+}
+
+// PluginModule implements the ProtocolModule interface by running an external executable
+type PluginModule struct {
+	definition PluginDefinition
+	timeout    time.Duration
+	apiVersion int
+}
+
+// pluginFinalityModule is a PluginModule whose plugin also reports the finalized head
+type pluginFinalityModule struct {
+	*PluginModule
+}
+
+// NewPluginModule creates a protocol module from a plugin definition. The returned module
+// implements FinalityModule when the definition declares finality support. A plugin
+// sharing no API version with the agent is refused.
+func NewPluginModule(definition PluginDefinition) (ProtocolModule, error) {
+	if definition.Name == "" {
+		return nil, fmt.Errorf("plugin name is required")
+	}
+	if definition.Command == "" {
+		return nil, fmt.Errorf("plugin %s: command is required", definition.Name)
+	}
+
+	timeout := defaultPluginTimeout
+	if definition.Timeout != "" {
+		parsed, err := time.ParseDuration(definition.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: invalid timeout '%s': %w", definition.Name, definition.Timeout, err)
+		}
+		if parsed <= 0 {
+			return nil, fmt.Errorf("plugin %s: timeout must be positive", definition.Name)
+		}
+		timeout = parsed
+	}
+
+	apiVersion, err := negotiateAPIVersion(definition.MinAPIVersion, definition.MaxAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", definition.Name, err)
+	}
+
+	module := &PluginModule{definition: definition, timeout: timeout, apiVersion: apiVersion}
+	if definition.Finality {
+		return &pluginFinalityModule{module}, nil
+	}
+	return module, nil
+}
+
+// negotiateAPIVersion returns the highest plugin API version spoken by both the agent and
+// a plugin declaring the range minVersion to maxVersion, or an error naming the side to
+// upgrade
+func negotiateAPIVersion(minVersion, maxVersion int) (int, error) {
+	if minVersion == 0 {
+		minVersion = 1
+	}
+	if maxVersion == 0 {
+		maxVersion = minVersion
+	}
+	if minVersion < 1 || maxVersion < minVersion {
+		return 0, fmt.Errorf("invalid API versions %d to %d", minVersion, maxVersion)
+	}
+
+	switch {
+	case minVersion > PluginAPIVersion:
+		return 0, fmt.Errorf("plugin requires API version %d or later, but this agent speaks versions %d to %d; upgrade the agent", minVersion, MinPluginAPIVersion, PluginAPIVersion)
+	case maxVersion < MinPluginAPIVersion:
+		return 0, fmt.Errorf("plugin speaks API versions up to %d, but this agent requires version %d or later; upgrade the plugin", maxVersion, MinPluginAPIVersion)
+	}
+	return min(maxVersion, PluginAPIVersion), nil
+}
+
+// LoadPlugins reads the plugin definitions (*.yaml, *.yml) in a directory. A missing
+// directory means no plugins are installed.
+func LoadPlugins(dir string) ([]ProtocolModule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	modules := make([]ProtocolModule, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin definition %s: %w", file, err)
+		}
+
+		var definition PluginDefinition
+		if err := yaml.Unmarshal(data, &definition); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin definition %s: %w", file, err)
+		}
+		if definition.Command != "" && !filepath.IsAbs(definition.Command) && strings.ContainsRune(definition.Command, filepath.Separator) {
+			definition.Command = filepath.Join(dir, definition.Command)
+		}
+
+		module, err := NewPluginModule(definition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin definition %s: %w", file, err)
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+// Name returns the protocol identifier
+func (p *PluginModule) Name() string {
+	return p.definition.Name
+}
+
+// Aliases returns alternative protocol identifiers that map to this module.
+func (p *PluginModule) Aliases() []string {
+	return p.definition.Aliases
+}
+
+// APIVersion returns the plugin API version the plugin's requests are in
+func (p *PluginModule) APIVersion() int {
+	return p.apiVersion
+}
+
+// CollectMetrics runs the plugin with a collect_metrics request, retried under the node's
+// metric_retry policy
+func (p *PluginModule) CollectMetrics(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+	var response *pluginResponse
+	if _, err := runWithRetry(ctx, cfg.MetricRetry, func(ctx context.Context) error {
+		var err error
+		response, err = p.call(ctx, "collect_metrics", cfg)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]interface{}, len(response.Metrics))
+	for key, value := range response.Metrics {
+		metrics[key] = normalizePluginValue(value)
+	}
+	return metrics, nil
+}
+
+// FinalizedBlock runs the plugin with a finalized_block request
+func (p *pluginFinalityModule) FinalizedBlock(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+	response, err := p.call(ctx, "finalized_block", cfg)
+	if err != nil {
+		return 0, err
+	}
+	if response.FinalizedBlock == nil {
+		return 0, fmt.Errorf("plugin %s returned no finalized_block", p.definition.Name)
+	}
+	return *response.FinalizedBlock, nil
+}
+
+// call runs the plugin command with a JSON request on stdin and decodes its stdout
+func (p *PluginModule) call(ctx context.Context, action string, cfg config.NodeConfig) (_ *pluginResponse, err error) {
+	ctx, span := tracing.StartClient(ctx, "plugin "+p.definition.Name,
+		tracing.String("plugin.name", p.definition.Name),
+		tracing.String("plugin.action", action),
+	)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	request, err := json.Marshal(pluginRequest{
+		APIVersion: p.apiVersion,
+		Action:     action,
+		Node: pluginNodeConfig{
+			Protocol: cfg.Protocol,
+			Type:     cfg.Type,
+			URL:      cfg.URL,
+			Metadata: cfg.Metadata,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.definition.Command, p.definition.Args...)
+	cmd.Stdin = bytes.NewReader(request)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s timed out after %s", p.definition.Name, p.timeout)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w: %s", p.definition.Name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	decoder := json.NewDecoder(&stdout)
+	decoder.UseNumber()
+	var response pluginResponse
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin %s response: %w", p.definition.Name, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin %s error: %s", p.definition.Name, response.Error)
+	}
+
+	return &response, nil
+}
+
+// normalizePluginValue converts integral JSON numbers to int64, matching the built-in modules
+func normalizePluginValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizePluginValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizePluginValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
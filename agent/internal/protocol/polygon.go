@@ -0,0 +1,297 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/tracing"
+)
+
+// PolygonModule implements the ProtocolModule interface for Polygon PoS nodes (Bor + Heimdall)
+type PolygonModule struct {
+	httpClient *http.Client
+}
+
+// NewPolygonModule creates a new Polygon protocol module
+func NewPolygonModule() *PolygonModule {
+	return &PolygonModule{
+		httpClient: &http.Client{Transport: &tracing.Transport{}},
+	}
+}
+
+// Name returns the protocol identifier
+func (p *PolygonModule) Name() string {
+	return "polygon"
+}
+
+// Aliases returns alternative protocol identifiers that map to this module.
+func (p *PolygonModule) Aliases() []string {
+	return []string{"polygon-pos"}
+}
+
+// CollectMetrics executes Bor RPC and Heimdall REST queries
+func (p *PolygonModule) CollectMetrics(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+	metrics := newMetricSet(cfg)
+
+	// Query eth_blockNumber from Bor
+	metrics.collect(ctx, "latest_block", func(ctx context.Context) (interface{}, error) {
+		return p.queryBlockNumber(ctx, cfg.URL)
+	})
+
+	// Build Heimdall REST URL from base URL
+	heimdallURL := fmt.Sprintf("%s/heimdall", cfg.URL)
+
+	// Query latest Heimdall block height
+	metrics.collect(ctx, "heimdall_height", func(ctx context.Context) (interface{}, error) {
+		return p.queryHeimdallHeight(ctx, heimdallURL)
+	})
+
+	// Query latest checkpoint number
+	metrics.collect(ctx, "checkpoint_number", func(ctx context.Context) (interface{}, error) {
+		return p.queryCheckpointNumber(ctx, heimdallURL)
+	})
+
+	return metrics.result(), nil
+}
+
+// FinalizedBlock returns the latest finalized Bor block number
+func (p *PolygonModule) FinalizedBlock(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+	return p.queryFinalizedBlock(ctx, cfg.URL)
+}
+
+// queryHeimdallHeight queries the latest Heimdall block height via the REST API
+func (p *PolygonModule) queryHeimdallHeight(ctx context.Context, heimdallURL string) (int64, error) {
+	respData, err := p.doGetRequest(ctx, fmt.Sprintf("%s/blocks/latest", heimdallURL))
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Block struct {
+			Header struct {
+				Height json.RawMessage `json:"height"`
+			} `json:"header"`
+		} `json:"block"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return p.parseNumber(response.Block.Header.Height)
+}
+
+// queryCheckpointNumber queries the latest checkpoint ID via the REST API.
+// Heimdall v1 wraps the checkpoint in "result" and v2 in "checkpoint".
+func (p *PolygonModule) queryCheckpointNumber(ctx context.Context, heimdallURL string) (int64, error) {
+	respData, err := p.doGetRequest(ctx, fmt.Sprintf("%s/checkpoints/latest", heimdallURL))
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result *struct {
+			ID json.RawMessage `json:"id"`
+		} `json:"result"`
+		Checkpoint *struct {
+			ID json.RawMessage `json:"id"`
+		} `json:"checkpoint"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	switch {
+	case response.Checkpoint != nil:
+		return p.parseNumber(response.Checkpoint.ID)
+	case response.Result != nil:
+		return p.parseNumber(response.Result.ID)
+	default:
+		return 0, fmt.Errorf("no checkpoint available")
+	}
+}
+
+// Syncing reports whether Bor is still syncing
+func (p *PolygonModule) Syncing(ctx context.Context, cfg config.NodeConfig) (bool, error) {
+	respData, err := p.doJSONRPCRequest(ctx, cfg.URL, ethSyncingRequest)
+	if err != nil {
+		return false, err
+	}
+	return parseEthSyncing(respData)
+}
+
+// queryFinalizedBlock queries the finalized block number via JSON-RPC
+func (p *PolygonModule) queryFinalizedBlock(ctx context.Context, rpcURL string) (int64, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{"finalized", false},
+		"id":      1,
+	}
+
+	respData, err := p.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result *struct {
+			Number string `json:"number"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	if response.Result == nil {
+		return 0, fmt.Errorf("no finalized block available")
+	}
+
+	blockNumber, err := p.hexToInt64(response.Result.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hex block number to decimal: %w", err)
+	}
+
+	return blockNumber, nil
+}
+
+// queryBlockNumber queries the latest block number via JSON-RPC
+func (p *PolygonModule) queryBlockNumber(ctx context.Context, rpcURL string) (int64, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_blockNumber",
+		"params":  []interface{}{},
+		"id":      1,
+	}
+
+	respData, err := p.doJSONRPCRequest(ctx, rpcURL, reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	// Convert hexadecimal string to decimal
+	blockNumber, err := p.hexToInt64(response.Result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hex block number to decimal: %w", err)
+	}
+
+	return blockNumber, nil
+}
+
+// doGetRequest performs an HTTP GET request against the Heimdall REST API
+func (p *PolygonModule) doGetRequest(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
+
+// doJSONRPCRequest performs a JSON-RPC request
+func (p *PolygonModule) doJSONRPCRequest(ctx context.Context, url string, reqBody map[string]interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
+
+// parseNumber parses a Heimdall numeric field, which may be a JSON number or a decimal string
+func (p *PolygonModule) parseNumber(raw json.RawMessage) (int64, error) {
+	value := strings.Trim(string(raw), `"`)
+	if value == "" || value == "null" {
+		return 0, fmt.Errorf("missing numeric value")
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s': %w", value, err)
+	}
+
+	return n, nil
+}
+
+// hexToInt64 converts a hexadecimal string (with or without 0x prefix) to int64
+func (p *PolygonModule) hexToInt64(hexStr string) (int64, error) {
+	// Remove 0x prefix if present
+	hexStr = strings.TrimPrefix(hexStr, "0x")
+
+	// Parse as hexadecimal
+	value, err := strconv.ParseInt(hexStr, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex string '%s': %w", hexStr, err)
+	}
+
+	return value, nil
+}
@@ -18,6 +18,40 @@ type ProtocolModule interface {
 	CollectMetrics(ctx context.Context, config config.NodeConfig) (map[string]interface{}, error)
 }
 
+// FinalityModule is implemented by protocol modules that can report the chain's finalized head
+type FinalityModule interface {
+	// FinalizedBlock returns the number of the latest finalized block
+	FinalizedBlock(ctx context.Context, config config.NodeConfig) (int64, error)
+}
+
+// SyncModule is implemented by protocol modules that can report whether the node is still syncing
+type SyncModule interface {
+	// Syncing reports whether the node is catching up with the chain head
+	Syncing(ctx context.Context, config config.NodeConfig) (bool, error)
+}
+
+// MetadataKey is the protocol_data key holding the node's static metadata
+const MetadataKey = "metadata"
+
+// WithMetadata attaches the node's static metadata to protocol data under MetadataKey.
+// Protocol data is returned unchanged when the node has no metadata configured.
+func WithMetadata(protocolData map[string]interface{}, cfg config.NodeConfig) map[string]interface{} {
+	if len(cfg.Metadata) == 0 {
+		return protocolData
+	}
+	if protocolData == nil {
+		protocolData = make(map[string]interface{})
+	}
+
+	metadata := make(map[string]interface{}, len(cfg.Metadata))
+	for key, value := range cfg.Metadata {
+		metadata[key] = value
+	}
+	protocolData[MetadataKey] = metadata
+
+	return protocolData
+}
+
 // Registry manages protocol module registration and retrieval
 type Registry struct {
 	mu      sync.RWMutex
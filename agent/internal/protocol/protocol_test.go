@@ -2,7 +2,16 @@ package protocol
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/nodexeus/agent/internal/config"
 )
@@ -182,3 +191,582 @@ func TestEthereumModule_HexToInt64(t *testing.T) {
 		})
 	}
 }
+
+func TestWithMetadata(t *testing.T) {
+	cfg := config.NodeConfig{
+		Protocol: "ethereum",
+		Metadata: map[string]string{"operator": "ops-team", "datacenter": "fra1"},
+	}
+
+	data := WithMetadata(map[string]interface{}{"latest_block": int64(100)}, cfg)
+	metadata, ok := data[MetadataKey].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected metadata map under %q, got %T", MetadataKey, data[MetadataKey])
+	}
+	if metadata["operator"] != "ops-team" || metadata["datacenter"] != "fra1" {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+	if data["latest_block"] != int64(100) {
+		t.Errorf("expected metrics to be preserved, got %v", data["latest_block"])
+	}
+
+	// Nil protocol data gets a fresh map
+	if data := WithMetadata(nil, cfg); data[MetadataKey] == nil {
+		t.Error("expected metadata to be attached to nil protocol data")
+	}
+
+	// Nodes without metadata leave protocol data untouched
+	data = WithMetadata(map[string]interface{}{}, config.NodeConfig{})
+	if _, exists := data[MetadataKey]; exists {
+		t.Error("expected no metadata key for node without metadata")
+	}
+}
+
+func TestEthereumModule_FinalizedBlock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Method != "eth_getBlockByNumber" || len(req.Params) == 0 || req.Params[0] != "finalized" {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x3e8"}}`))
+	}))
+	defer server.Close()
+
+	module := NewEthereumModule()
+	finalized, err := module.FinalizedBlock(context.Background(), config.NodeConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if finalized != 1000 {
+		t.Errorf("expected finalized block 1000, got %d", finalized)
+	}
+
+	var _ FinalityModule = NewArbitrumModule()
+}
+
+func TestEthereumModule_Syncing(t *testing.T) {
+	tests := []struct {
+		name          string
+		elResult      string
+		beaconSyncing bool
+		want          bool
+	}{
+		{name: "both synced", elResult: `false`, want: false},
+		{name: "execution client syncing", elResult: `{"currentBlock":"0x10","highestBlock":"0x3e8"}`, want: true},
+		{name: "beacon node syncing", elResult: `false`, beaconSyncing: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/beacon/eth/v1/node/syncing" {
+					fmt.Fprintf(w, `{"data":{"head_slot":"100","sync_distance":"0","is_syncing":%t}}`, tt.beaconSyncing)
+					return
+				}
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%s}`, tt.elResult)
+			}))
+			defer server.Close()
+
+			syncing, err := NewEthereumModule().Syncing(context.Background(), config.NodeConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if syncing != tt.want {
+				t.Errorf("Syncing() = %v, want %v", syncing, tt.want)
+			}
+		})
+	}
+
+	var _ SyncModule = NewArbitrumModule()
+	var _ SyncModule = NewOptimismModule()
+	var _ SyncModule = NewPolygonModule()
+}
+
+func TestOptimismModule_Aliases(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Register(NewOptimismModule()); err != nil {
+		t.Fatalf("failed to register module: %v", err)
+	}
+
+	for _, name := range []string{"optimism", "base", "op-mainnet"} {
+		module, err := registry.Get(name)
+		if err != nil {
+			t.Errorf("expected %s to resolve, got error: %v", name, err)
+			continue
+		}
+		if module.Name() != "optimism" {
+			t.Errorf("expected %s to resolve to 'optimism', got '%s'", name, module.Name())
+		}
+	}
+
+	var _ FinalityModule = NewOptimismModule()
+}
+
+func TestOptimismModule_CollectMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		switch {
+		case r.URL.Path == "/" && req.Method == "eth_blockNumber":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x7d0"}`))
+		case r.URL.Path == "/rollup" && req.Method == "optimism_syncStatus":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{
+				"current_l1":{"hash":"0xa","number":19000},
+				"head_l1":{"hash":"0xb","number":19004},
+				"unsafe_l2":{"hash":"0xc","number":2000},
+				"safe_l2":{"hash":"0xd","number":1990},
+				"finalized_l2":{"hash":"0xe","number":1900}}}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.URL.Path, req.Method)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	module := NewOptimismModule()
+	metrics, err := module.CollectMetrics(context.Background(), config.NodeConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]int64{
+		"latest_block":       2000,
+		"unsafe_l2_block":    2000,
+		"safe_l2_block":      1990,
+		"finalized_l2_block": 1900,
+		"current_l1_block":   19000,
+		"head_l1_block":      19004,
+		"l1_sync_lag":        4,
+	}
+	for key, want := range expected {
+		if got, ok := metrics[key].(int64); !ok || got != want {
+			t.Errorf("expected %s = %d, got %v", key, want, metrics[key])
+		}
+	}
+}
+
+func TestOptimismModule_CollectMetricsRollupUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rollup" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer server.Close()
+
+	module := NewOptimismModule()
+	metrics, err := module.CollectMetrics(context.Background(), config.NodeConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics["latest_block"] != int64(16) {
+		t.Errorf("expected latest_block 16, got %v", metrics["latest_block"])
+	}
+	if metrics["safe_l2_block"] != nil {
+		t.Errorf("expected nil safe_l2_block when op-node is unavailable, got %v", metrics["safe_l2_block"])
+	}
+
+	// Every metric of the failed sync status query records its error
+	metricErrors, ok := metrics[MetricErrorsKey].(map[string]interface{})
+	if !ok || len(metricErrors) != 6 || metricErrors["latest_block"] != nil {
+		t.Fatalf("expected errors for the six sync status metrics, got %v", metrics[MetricErrorsKey])
+	}
+	failure := metricErrors["l1_sync_lag"].(map[string]interface{})
+	if failure["error"] != "unexpected status code: 502" || failure["attempts"] != 1 {
+		t.Errorf("expected one attempt failed with a 502, got %v", failure)
+	}
+}
+
+func TestPolygonModule_CollectMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint string
+	}{
+		{name: "heimdall v1", checkpoint: `{"height":"100","result":{"id":51234}}`},
+		{name: "heimdall v2", checkpoint: `{"checkpoint":{"id":"51234"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3e8"}`))
+				case "/heimdall/blocks/latest":
+					w.Write([]byte(`{"block":{"header":{"height":"22500000"}}}`))
+				case "/heimdall/checkpoints/latest":
+					w.Write([]byte(tt.checkpoint))
+				default:
+					t.Errorf("unexpected request path: %s", r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			module := NewPolygonModule()
+			metrics, err := module.CollectMetrics(context.Background(), config.NodeConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := map[string]int64{
+				"latest_block":      1000,
+				"heimdall_height":   22500000,
+				"checkpoint_number": 51234,
+			}
+			for key, want := range expected {
+				if got, ok := metrics[key].(int64); !ok || got != want {
+					t.Errorf("expected %s = %d, got %v", key, want, metrics[key])
+				}
+			}
+		})
+	}
+}
+
+func TestPolygonModule_CollectMetricsHeimdallUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer server.Close()
+
+	module := NewPolygonModule()
+	metrics, err := module.CollectMetrics(context.Background(), config.NodeConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics["latest_block"] != int64(16) {
+		t.Errorf("expected latest_block 16, got %v", metrics["latest_block"])
+	}
+	if metrics["heimdall_height"] != nil || metrics["checkpoint_number"] != nil {
+		t.Errorf("expected nil Heimdall metrics, got %v and %v", metrics["heimdall_height"], metrics["checkpoint_number"])
+	}
+}
+
+func TestGenericModule_CollectMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "eth_blockNumber":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1b4"}`))
+		case "status":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"sync_info":{"latest_block_height":"1234","catching_up":false},"peers":[{"id":"a","score":7}]}}`))
+		case "eth_getBlockByNumber":
+			if len(req.Params) != 2 || req.Params[0] != "finalized" {
+				t.Errorf("unexpected params: %v", req.Params)
+			}
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x10"}}`))
+		default:
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.NodeConfig{
+		Protocol: "generic",
+		URL:      server.URL,
+		Metrics: []config.MetricQueryConfig{
+			{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result", Format: "hex"},
+			{Key: "height", Method: "status", Path: "$.result.sync_info.latest_block_height", Format: "int"},
+			{Key: "catching_up", Method: "status", Path: "$.result.sync_info.catching_up"},
+			{Key: "peer_score", Method: "status", Path: "$.result.peers[0].score"},
+			{Key: "finalized_block", Method: "eth_getBlockByNumber", Params: []interface{}{"finalized", false}, Path: "$.result.number", Format: "hex"},
+			{Key: "missing", Method: "unknown_method", Path: "$.result"},
+		},
+	}
+
+	module := NewGenericModule()
+	metrics, err := module.CollectMetrics(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"latest_block":    int64(436),
+		"height":          int64(1234),
+		"catching_up":     false,
+		"peer_score":      int64(7),
+		"finalized_block": int64(16),
+		"missing":         nil,
+	}
+	for key, want := range expected {
+		got, exists := metrics[key]
+		if !exists {
+			t.Errorf("metric %s missing", key)
+			continue
+		}
+		if got != want {
+			t.Errorf("metric %s = %v (%T), want %v (%T)", key, got, got, want, want)
+		}
+	}
+}
+
+func TestEthereumModule_CollectMetricsRetry(t *testing.T) {
+	var blockRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		// The execution client blips on the first request
+		if blockRequests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer server.Close()
+
+	cfg := config.NodeConfig{URL: server.URL, MetricRetry: &config.MetricRetryConfig{Attempts: 3, Backoff: "1ms"}}
+	metrics, err := NewEthereumModule().CollectMetrics(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if metrics["latest_block"] != int64(16) || blockRequests.Load() != 2 {
+		t.Errorf("expected latest_block 16 on the second attempt, got %v after %d requests", metrics["latest_block"], blockRequests.Load())
+	}
+	metricErrors, ok := metrics[MetricErrorsKey].(map[string]interface{})
+	if !ok || len(metricErrors) != 2 {
+		t.Fatalf("expected errors for the two beacon metrics, got %v", metrics[MetricErrorsKey])
+	}
+	for _, key := range []string{"latest_slot", "earliest_blob"} {
+		failure := metricErrors[key].(map[string]interface{})
+		if metrics[key] != nil || failure["attempts"] != 3 || failure["error"] != "unexpected status code: 502" {
+			t.Errorf("expected %s nil after three failed attempts, got %v with %v", key, metrics[key], failure)
+		}
+	}
+}
+
+func TestGenericModule_CollectMetricsQueryTimeout(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		// The first request hangs past the query timeout
+		if requests.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1b4"}`))
+	}))
+	defer server.Close()
+
+	cfg := config.NodeConfig{
+		Protocol: "generic",
+		URL:      server.URL,
+		Metrics:  []config.MetricQueryConfig{{Key: "latest_block", Method: "eth_blockNumber", Path: "$.result", Format: "hex"}},
+	}
+
+	// A single attempt records the timeout
+	cfg.MetricRetry = &config.MetricRetryConfig{Attempts: 1, QueryTimeout: "50ms"}
+	metrics, err := NewGenericModule().CollectMetrics(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failure, ok := metrics[MetricErrorsKey].(map[string]interface{})["latest_block"].(map[string]interface{})
+	if metrics["latest_block"] != nil || !ok || failure["error"] != "query timed out after 50ms" {
+		t.Errorf("expected latest_block to time out, got %v with %v", metrics["latest_block"], metrics[MetricErrorsKey])
+	}
+
+	// A retry after the timed out attempt collects the metric
+	requests.Store(0)
+	cfg.MetricRetry = &config.MetricRetryConfig{Attempts: 2, Backoff: "1ms", QueryTimeout: "50ms"}
+	metrics, err = NewGenericModule().CollectMetrics(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics["latest_block"] != int64(436) {
+		t.Errorf("expected latest_block 436 after the retry, got %v", metrics["latest_block"])
+	}
+	if _, exists := metrics[MetricErrorsKey]; exists {
+		t.Errorf("expected no metric errors once the retry succeeded, got %v", metrics[MetricErrorsKey])
+	}
+}
+
+func TestExtractJSONPath(t *testing.T) {
+	document := map[string]interface{}{
+		"result": map[string]interface{}{
+			"items": []interface{}{"a", map[string]interface{}{"name": "b"}},
+		},
+	}
+
+	tests := []struct {
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{path: "$.result.items[0]", want: "a"},
+		{path: "$.result.items[1].name", want: "b"},
+		{path: "$.result.missing", wantErr: true},
+		{path: "$.result.items[2]", wantErr: true},
+		{path: "$.result.items[x]", wantErr: true},
+		{path: "result.items", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := extractJSONPath(document, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractJSONPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("extractJSONPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+
+	script := `#!/bin/sh
+input=$(cat)
+case "$input" in
+  *finalized_block*) echo '{"finalized_block": 90}' ;;
+  *http://bad*) echo 'node unreachable' >&2; exit 1 ;;
+  *'"api_version":1,'*'"url":"http://localhost:8899"'*) echo '{"metrics": {"latest_block": 100, "syncing": false}}' ;;
+  *) echo '{"error": "unexpected request"}' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "solana.sh"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write plugin script: %v", err)
+	}
+	definition := "name: solana\naliases: [sol]\ncommand: ./solana.sh\nfinality: true\ntimeout: 5s\nmax_api_version: 2\n"
+	if err := os.WriteFile(filepath.Join(dir, "solana.yaml"), []byte(definition), 0644); err != nil {
+		t.Fatalf("failed to write plugin definition: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	modules, err := LoadPlugins(dir)
+	if err != nil {
+		t.Fatalf("LoadPlugins() error = %v", err)
+	}
+	if len(modules) != 1 {
+		t.Fatalf("expected 1 plugin, got %d", len(modules))
+	}
+
+	registry := NewRegistry()
+	if err := registry.Register(modules[0]); err != nil {
+		t.Fatalf("failed to register plugin: %v", err)
+	}
+	module, err := registry.Get("sol")
+	if err != nil {
+		t.Fatalf("plugin alias not registered: %v", err)
+	}
+
+	metrics, err := module.CollectMetrics(context.Background(), config.NodeConfig{Protocol: "solana", URL: "http://localhost:8899"})
+	if err != nil {
+		t.Fatalf("CollectMetrics() error = %v", err)
+	}
+	if metrics["latest_block"] != int64(100) || metrics["syncing"] != false {
+		t.Errorf("unexpected metrics: %v", metrics)
+	}
+
+	finality, ok := module.(FinalityModule)
+	if !ok {
+		t.Fatal("expected plugin with finality: true to implement FinalityModule")
+	}
+	finalized, err := finality.FinalizedBlock(context.Background(), config.NodeConfig{URL: "http://localhost:8899"})
+	if err != nil || finalized != 90 {
+		t.Errorf("FinalizedBlock() = %d, %v, want 90", finalized, err)
+	}
+
+	if _, err := module.CollectMetrics(context.Background(), config.NodeConfig{URL: "http://bad"}); err == nil || !strings.Contains(err.Error(), "node unreachable") {
+		t.Errorf("expected plugin failure with stderr, got %v", err)
+	}
+	if _, err := module.CollectMetrics(context.Background(), config.NodeConfig{URL: "http://other"}); err == nil || !strings.Contains(err.Error(), "unexpected request") {
+		t.Errorf("expected plugin error response, got %v", err)
+	}
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		minVersion int
+		maxVersion int
+		want       int
+		wantErr    string
+	}{
+		{name: "undeclared", want: 1},
+		{name: "same version", minVersion: 1, maxVersion: 1, want: 1},
+		{name: "plugin speaks newer versions too", minVersion: 1, maxVersion: 3, want: PluginAPIVersion},
+		{name: "plugin requires a newer agent", minVersion: PluginAPIVersion + 1, wantErr: "upgrade the agent"},
+		{name: "max below min", minVersion: 2, maxVersion: 1, wantErr: "invalid API versions"},
+		{name: "negative", minVersion: -1, maxVersion: 1, wantErr: "invalid API versions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := negotiateAPIVersion(tt.minVersion, tt.maxVersion)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("negotiateAPIVersion() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("negotiateAPIVersion() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPlugins_InvalidDefinitions(t *testing.T) {
+	modules, err := LoadPlugins(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(modules) != 0 {
+		t.Errorf("missing directory should load no plugins, got %v, %v", modules, err)
+	}
+
+	tests := map[string]string{
+		"missing command": "name: chain\n",
+		"missing name":    "command: /bin/true\n",
+		"invalid timeout": "name: chain\ncommand: /bin/true\ntimeout: soon\n",
+		"newer API":       "name: chain\ncommand: /bin/true\nmin_api_version: 2\nmax_api_version: 3\n",
+		"invalid API":     "name: chain\ncommand: /bin/true\nmin_api_version: 2\nmax_api_version: 1\n",
+	}
+	for name, definition := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "plugin.yaml"), []byte(definition), 0644); err != nil {
+				t.Fatalf("failed to write plugin definition: %v", err)
+			}
+			if _, err := LoadPlugins(dir); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	module, err := NewPluginModule(PluginDefinition{Name: "chain", Command: "/bin/true"})
+	if err != nil {
+		t.Fatalf("NewPluginModule() error = %v", err)
+	}
+	if _, ok := module.(FinalityModule); ok {
+		t.Error("plugin without finality should not implement FinalityModule")
+	}
+}
@@ -0,0 +1,143 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+)
+
+// MetricErrorsKey is the protocol_data key recording why the metrics stored as nil failed.
+// Each failed metric maps to the error of its last attempt and the attempts made.
+const MetricErrorsKey = "metric_errors"
+
+// metricSet collects a node's metrics, retrying each failed query under the node's
+// metric_retry policy. Without a policy every query is attempted once, without a timeout
+// of its own.
+type metricSet struct {
+	retry   *config.MetricRetryConfig
+	metrics map[string]interface{}
+	errors  map[string]interface{}
+}
+
+// newMetricSet creates an empty metric set for a node
+func newMetricSet(cfg config.NodeConfig) *metricSet {
+	return &metricSet{
+		retry:   cfg.MetricRetry,
+		metrics: make(map[string]interface{}),
+		errors:  make(map[string]interface{}),
+	}
+}
+
+// collect stores the value of a query under key, or nil once all its attempts failed
+func (s *metricSet) collect(ctx context.Context, key string, query func(ctx context.Context) (interface{}, error)) {
+	var value interface{}
+	attempts, err := runWithRetry(ctx, s.retry, func(ctx context.Context) error {
+		var err error
+		value, err = query(ctx)
+		return err
+	})
+	if err != nil {
+		s.fail(attempts, err, key)
+		return
+	}
+	s.metrics[key] = value
+}
+
+// collectAll stores the values of a query answering several metrics, or nil for each of
+// keys once all its attempts failed
+func (s *metricSet) collectAll(ctx context.Context, keys []string, query func(ctx context.Context) (map[string]interface{}, error)) {
+	var values map[string]interface{}
+	attempts, err := runWithRetry(ctx, s.retry, func(ctx context.Context) error {
+		var err error
+		values, err = query(ctx)
+		return err
+	})
+	if err != nil {
+		s.fail(attempts, err, keys...)
+		return
+	}
+	for _, key := range keys {
+		s.metrics[key] = values[key]
+	}
+}
+
+// fail stores keys as nil metrics and records why their query failed
+func (s *metricSet) fail(attempts int, err error, keys ...string) {
+	for _, key := range keys {
+		s.metrics[key] = nil
+		s.errors[key] = map[string]interface{}{
+			"error":    err.Error(),
+			"attempts": attempts,
+		}
+	}
+}
+
+// result returns the collected metrics, with the failed ones recorded under MetricErrorsKey
+func (s *metricSet) result() map[string]interface{} {
+	if len(s.errors) > 0 {
+		s.metrics[MetricErrorsKey] = s.errors
+	}
+	return s.metrics
+}
+
+// MetricError returns the recorded error of a metric whose query failed, or "" when
+// metrics record none for it
+func MetricError(metrics map[string]interface{}, key string) string {
+	metricErrors, ok := metrics[MetricErrorsKey].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	failure, ok := metricErrors[key].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	message, _ := failure["error"].(string)
+	return message
+}
+
+// runWithRetry runs query until it succeeds or has been attempted as often as the retry
+// policy allows, waiting the policy's backoff between attempts. Each attempt is limited
+// to the policy's query timeout. It returns the attempts made and the last attempt's error.
+func runWithRetry(ctx context.Context, retry *config.MetricRetryConfig, query func(ctx context.Context) error) (int, error) {
+	attempts := 1
+	var queryTimeout time.Duration
+	if retry != nil {
+		attempts = retry.Attempts
+		queryTimeout = retry.GetQueryTimeout()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = runAttempt(ctx, queryTimeout, query)
+		if err == nil || attempt >= attempts {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(retry.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// The collection's metric_timeout leaves no time for another attempt
+			timer.Stop()
+			return attempt, err
+		}
+	}
+}
+
+// runAttempt runs one attempt of a query, limited to queryTimeout when it is set
+func runAttempt(ctx context.Context, queryTimeout time.Duration, query func(ctx context.Context) error) error {
+	if queryTimeout <= 0 {
+		return query(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	err := query(attemptCtx)
+	if err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("query timed out after %s", queryTimeout)
+	}
+	return err
+}
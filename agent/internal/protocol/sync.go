@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ethSyncingRequest is the JSON-RPC request for an EVM client's sync status
+var ethSyncingRequest = map[string]interface{}{
+	"jsonrpc": "2.0",
+	"method":  "eth_syncing",
+	"params":  []interface{}{},
+	"id":      1,
+}
+
+// parseEthSyncing parses an eth_syncing response. The result is false when the client
+// is synced and a sync progress object while it is catching up.
+func parseEthSyncing(respData []byte) (bool, error) {
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return false, fmt.Errorf("RPC error: %s", response.Error.Message)
+	}
+
+	if len(response.Result) == 0 {
+		return false, fmt.Errorf("no sync status available")
+	}
+
+	var syncing bool
+	if err := json.Unmarshal(response.Result, &syncing); err == nil {
+		return syncing, nil
+	}
+
+	// Anything other than a boolean is a sync progress object
+	return true, nil
+}
@@ -0,0 +1,287 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// blobRetentionProtocol is the protocol module whose nodes expose earliest_blob
+const blobRetentionProtocol = "ethereum"
+
+// blobRetentionRisk describes a projected gap in blob coverage between the last
+// completed snapshot and the next scheduled one
+type blobRetentionRisk struct {
+	LastSnapshotSlot      int64     // latest_slot captured by the last completed snapshot
+	CurrentEarliestBlob   int64     // earliest_blob reported by the node now
+	ProjectedEarliestBlob int64     // earliest_blob projected at the next scheduled run
+	SlotsPerHour          float64   // observed earliest_blob advance rate
+	NextRun               time.Time // next scheduled snapshot
+}
+
+// assessBlobRetention projects earliest_blob forward to the next scheduled run using the
+// advance rate observed since the last completed snapshot. It reports a risk when blobs
+// newer than the last snapshot would be pruned before the next snapshot captures them.
+func assessBlobRetention(last database.Upload, currentEarliestBlob int64, now, nextRun time.Time) (*blobRetentionRisk, bool) {
+	lastSlot, ok := toInt64(last.ProtocolData["latest_slot"])
+	if !ok {
+		return nil, false
+	}
+	lastEarliestBlob, ok := toInt64(last.ProtocolData["earliest_blob"])
+	if !ok {
+		return nil, false
+	}
+
+	elapsed := now.Sub(last.StartedAt)
+	if elapsed <= 0 {
+		return nil, false
+	}
+
+	slotsPerHour := float64(currentEarliestBlob-lastEarliestBlob) / elapsed.Hours()
+	if slotsPerHour < 0 {
+		slotsPerHour = 0
+	}
+
+	projected := currentEarliestBlob
+	if untilNext := nextRun.Sub(now); untilNext > 0 {
+		projected += int64(math.Ceil(slotsPerHour * untilNext.Hours()))
+	}
+
+	// Blobs up to the last snapshot's latest_slot are already captured; anything
+	// pruned beyond that point before the next run is lost from every snapshot
+	if projected <= lastSlot {
+		return nil, false
+	}
+
+	return &blobRetentionRisk{
+		LastSnapshotSlot:      lastSlot,
+		CurrentEarliestBlob:   currentEarliestBlob,
+		ProjectedEarliestBlob: projected,
+		SlotsPerHour:          slotsPerHour,
+		NextRun:               nextRun,
+	}, true
+}
+
+// toInt64 converts a protocol data value (int64 from live metrics, float64 from JSON) to int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// BlobRetentionJob tracks earliest_blob on Ethereum nodes and alerts when blob pruning
+// is projected to overtake the last snapshot before the next scheduled snapshot runs
+type BlobRetentionJob struct {
+	db               Database
+	protocolRegistry *protocol.Registry
+	notifyRegistry   *notification.Registry
+	globalNotifyCfg  *config.NotificationConfig
+	nodeConfigs      *nodeConfigSet
+	logger           *logrus.Logger
+	now              func() time.Time
+
+	mu      sync.Mutex
+	alerted map[string]int64 // node name -> completed upload ID already alerted on
+}
+
+// NewBlobRetentionJob creates a new blob retention job
+func NewBlobRetentionJob(
+	db Database,
+	protocolRegistry *protocol.Registry,
+	notifyRegistry *notification.Registry,
+	globalNotifyCfg *config.NotificationConfig,
+	nodeConfigs map[string]config.NodeConfig,
+	logger *logrus.Logger,
+) *BlobRetentionJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &BlobRetentionJob{
+		db:               db,
+		protocolRegistry: protocolRegistry,
+		notifyRegistry:   notifyRegistry,
+		globalNotifyCfg:  globalNotifyCfg,
+		nodeConfigs:      newNodeConfigSet(nodeConfigs),
+		logger:           logger,
+		now:              time.Now,
+		alerted:          make(map[string]int64),
+	}
+}
+
+// SetNode starts tracking blob retention of a node registered, or updated, at runtime
+func (j *BlobRetentionJob) SetNode(cfg *config.Config, nodeName string) {
+	nodeConfig := cfg.ResolvedNode(nodeName)
+	nodeConfig.Schedule = cfg.GetNodeSchedule(nodeName)
+	j.nodeConfigs.set(nodeName, nodeConfig)
+}
+
+// RemoveNode stops tracking a deregistered node
+func (j *BlobRetentionJob) RemoveNode(nodeName string) {
+	j.nodeConfigs.remove(nodeName)
+}
+
+// Run checks blob retention for every Ethereum node
+func (j *BlobRetentionJob) Run(ctx context.Context) error {
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"job":       "blob_retention",
+	}).Debug("Starting blob retention job")
+
+	var wg sync.WaitGroup
+	for nodeName, nodeConfig := range j.nodeConfigs.all() {
+		protocolModule, err := j.protocolRegistry.Get(nodeConfig.Protocol)
+		if err != nil || protocolModule.Name() != blobRetentionProtocol {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node string, cfg config.NodeConfig, module protocol.ProtocolModule) {
+			defer wg.Done()
+
+			// Each node is checked independently to ensure node isolation
+			if err := j.checkNode(ctx, node, cfg, module); err != nil {
+				j.logger.WithFields(logrus.Fields{
+					"component": "scheduler",
+					"node":      node,
+					"error":     err.Error(),
+				}).Warn("Failed to check blob retention")
+			}
+		}(nodeName, nodeConfig, protocolModule)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// checkNode compares the node's current earliest_blob with its last completed snapshot
+func (j *BlobRetentionJob) checkNode(ctx context.Context, nodeName string, nodeConfig config.NodeConfig, module protocol.ProtocolModule) error {
+	// A running upload is the next snapshot; nothing can be done until it completes
+	running, err := j.db.GetRunningUploadForNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get running upload: %w", err)
+	}
+	if running != nil {
+		return nil
+	}
+
+	last, err := j.db.GetLatestCompletedUploadForNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get latest completed upload: %w", err)
+	}
+	if last == nil {
+		return nil
+	}
+
+	metrics, err := module.CollectMetrics(ctx, nodeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	currentEarliestBlob, ok := toInt64(metrics["earliest_blob"])
+	if !ok {
+		return nil
+	}
+
+	now := j.now()
+	nextRun, err := nextScheduledRun(nodeConfig.Schedule, SplayOffset(nodeName, nodeConfig.GetSplay()), now)
+	if err != nil {
+		return fmt.Errorf("failed to get next node run: %w", err)
+	}
+
+	risk, atRisk := assessBlobRetention(*last, currentEarliestBlob, now, nextRun)
+	if !atRisk {
+		return nil
+	}
+
+	// Alert once per completed snapshot; a new snapshot resets the baseline
+	j.mu.Lock()
+	alreadyAlerted := j.alerted[nodeName] == last.ID
+	j.alerted[nodeName] = last.ID
+	j.mu.Unlock()
+	if alreadyAlerted {
+		return nil
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component":               "scheduler",
+		"node":                    nodeName,
+		"last_snapshot_slot":      risk.LastSnapshotSlot,
+		"earliest_blob":           risk.CurrentEarliestBlob,
+		"projected_earliest_blob": risk.ProjectedEarliestBlob,
+		"next_run":                risk.NextRun,
+	}).Warn("Blob retention will prune data before the next scheduled snapshot")
+
+	j.sendNotification(ctx, nodeName, notification.EventBlobRetention,
+		"Blob retention will prune data before the next scheduled snapshot", map[string]interface{}{
+			"upload_id":               last.ID,
+			"last_snapshot_slot":      risk.LastSnapshotSlot,
+			"earliest_blob":           risk.CurrentEarliestBlob,
+			"projected_earliest_blob": risk.ProjectedEarliestBlob,
+			"slots_per_hour":          fmt.Sprintf("%.1f", risk.SlotsPerHour),
+			"next_run":                risk.NextRun.UTC().Format(time.RFC3339),
+		})
+
+	return nil
+}
+
+// sendNotification sends a blob retention notification using the node's effective config
+func (j *BlobRetentionJob) sendNotification(ctx context.Context, nodeName string, event notification.NotificationEvent, message string, details map[string]interface{}) {
+	if j.notifyRegistry == nil {
+		return
+	}
+
+	nodeConfig, _ := j.nodeConfigs.get(nodeName)
+	notifyConfig := nodeConfig.Notifications
+	if notifyConfig == nil {
+		notifyConfig = j.globalNotifyCfg
+	}
+	if notifyConfig == nil || !notifyConfig.BlobRetention {
+		return
+	}
+
+	payload := notification.NotificationPayload{
+		Event:     event,
+		NodeName:  nodeName,
+		Timestamp: time.Now(),
+		Message:   message,
+		Details:   details,
+		Metadata:  nodeConfig.Metadata,
+	}
+
+	for notificationType, typeConfig := range notifyConfig.Types {
+		notificationModule, err := j.notifyRegistry.Get(notificationType)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+			}).Warn("Notification module not found")
+			continue
+		}
+
+		rendered := RenderNotification(notifyConfig, notificationType, payload, j.logger)
+		if err := DeliverNotification(ctx, j.db, j.logger, notificationModule, typeConfig.URL, rendered); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Error("Failed to send notification")
+		}
+	}
+}
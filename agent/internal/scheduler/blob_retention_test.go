@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAssessBlobRetention(t *testing.T) {
+	now := time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		protocolData    database.JSONB
+		currentEarliest int64
+		nextRun         time.Time
+		wantRisk        bool
+		wantProjected   int64
+	}{
+		{
+			name:            "pruning overtakes last snapshot before next run",
+			protocolData:    database.JSONB{"latest_slot": float64(2000), "earliest_blob": float64(1000)},
+			currentEarliest: 1600, // 600 slots/hour over the last hour
+			nextRun:         now.Add(time.Hour),
+			wantRisk:        true,
+			wantProjected:   2200,
+		},
+		{
+			name:            "next run captures data in time",
+			protocolData:    database.JSONB{"latest_slot": float64(2000), "earliest_blob": float64(1000)},
+			currentEarliest: 1100,
+			nextRun:         now.Add(time.Hour),
+			wantRisk:        false,
+		},
+		{
+			name:            "already pruned past last snapshot",
+			protocolData:    database.JSONB{"latest_slot": float64(2000), "earliest_blob": float64(1000)},
+			currentEarliest: 2100,
+			nextRun:         now,
+			wantRisk:        true,
+			wantProjected:   2100,
+		},
+		{
+			name:            "missing slot data",
+			protocolData:    database.JSONB{"latest_slot": nil, "earliest_blob": float64(1000)},
+			currentEarliest: 5000,
+			nextRun:         now.Add(time.Hour),
+			wantRisk:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := database.Upload{
+				ID:           1,
+				StartedAt:    now.Add(-time.Hour),
+				ProtocolData: tt.protocolData,
+			}
+
+			risk, atRisk := assessBlobRetention(last, tt.currentEarliest, now, tt.nextRun)
+			if atRisk != tt.wantRisk {
+				t.Fatalf("assessBlobRetention() atRisk = %v, want %v", atRisk, tt.wantRisk)
+			}
+			if atRisk && risk.ProjectedEarliestBlob != tt.wantProjected {
+				t.Errorf("ProjectedEarliestBlob = %d, want %d", risk.ProjectedEarliestBlob, tt.wantProjected)
+			}
+		})
+	}
+}
+
+func TestBlobRetentionJob_AlertsOncePerSnapshot(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC)
+
+	db := &mockDatabase{
+		getLatestCompletedUploadForNodeFunc: func(ctx context.Context, nodeName string) (*database.Upload, error) {
+			return &database.Upload{
+				ID:           7,
+				NodeName:     nodeName,
+				StartedAt:    now.Add(-time.Hour),
+				ProtocolData: database.JSONB{"latest_slot": float64(2000), "earliest_blob": float64(1000)},
+			}, nil
+		},
+	}
+
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{
+		name: "ethereum",
+		collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+			return map[string]interface{}{"earliest_blob": int64(1600)}, nil
+		},
+	})
+	protocolRegistry.Register(&mockProtocolModule{
+		name: "arbitrum",
+		collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+			t.Error("Expected non-Ethereum nodes to be skipped")
+			return nil, nil
+		},
+	})
+
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		BlobRetention: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"eth-node": {Protocol: "ethereum", Schedule: "0 0 13 * * *"},
+		"arb-node": {Protocol: "arbitrum", Schedule: "0 0 13 * * *"},
+	}
+
+	job := NewBlobRetentionJob(db, protocolRegistry, notifyRegistry, notifyConfig, nodes, logger)
+	job.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := job.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(sent))
+	}
+	if sent[0].Event != notification.EventBlobRetention {
+		t.Errorf("Expected EventBlobRetention, got %v", sent[0].Event)
+	}
+	if sent[0].NodeName != "eth-node" {
+		t.Errorf("Expected notification for eth-node, got %s", sent[0].NodeName)
+	}
+}
+
+func TestBlobRetentionJob_SkipsRunningUpload(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	db := &mockDatabase{
+		getRunningUploadForNodeFunc: func(ctx context.Context, nodeName string) (*database.Upload, error) {
+			return &database.Upload{ID: 8, NodeName: nodeName, Status: "running"}, nil
+		},
+	}
+
+	metricsCollected := false
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{
+		name: "ethereum",
+		collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+			metricsCollected = true
+			return map[string]interface{}{}, nil
+		},
+	})
+
+	nodes := map[string]config.NodeConfig{
+		"eth-node": {Protocol: "ethereum", Schedule: "0 0 13 * * *"},
+	}
+
+	job := NewBlobRetentionJob(db, protocolRegistry, notification.NewRegistry(), nil, nodes, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if metricsCollected {
+		t.Error("Expected metrics not to be collected while an upload is running")
+	}
+}
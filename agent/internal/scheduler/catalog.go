@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// recordCatalog records a successfully completed upload as the catalog entry of its
+// protocol, network and node type, with the location the engine reported or the node's
+// snapshot_location. A failure is only logged: the catalog catches up with the node's
+// next snapshot.
+func (j *UploadMonitorJob) recordCatalog(ctx context.Context, u database.Upload, result upload.CompletionResult) {
+	nodeConfig, _ := j.nodeConfigs.get(u.NodeName)
+
+	entry := database.CatalogEntry{
+		Protocol:    u.Protocol,
+		Network:     nodeConfig.Network,
+		NodeType:    u.NodeType,
+		NodeName:    u.NodeName,
+		Tenant:      u.Tenant,
+		UploadID:    u.ID,
+		SizeBytes:   result.SizeBytes,
+		CompletedAt: j.now(),
+	}
+	if result.FinishedAt != nil {
+		entry.CompletedAt = *result.FinishedAt
+	}
+	if block, ok := toInt64(u.ProtocolData["latest_block"]); ok {
+		entry.LatestBlock = &block
+	}
+	if slot, ok := toInt64(u.ProtocolData["latest_slot"]); ok {
+		entry.LatestSlot = &slot
+	}
+	location := result.Location
+	if location == "" {
+		location = nodeConfig.SnapshotLocation
+	}
+	if location != "" {
+		entry.Location = &location
+	}
+
+	written, err := j.db.UpsertCatalogEntry(ctx, entry)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+			"error":     err.Error(),
+		}).Warn("Failed to record snapshot in catalog")
+		return
+	}
+	if !written {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+			"protocol":  entry.Protocol,
+			"network":   entry.Network,
+			"node_type": entry.NodeType,
+		}).Debug("Catalog already has a fresher snapshot")
+	}
+}
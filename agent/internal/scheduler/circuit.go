@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// circuitTrip is a node's circuit opened by its consecutive failed uploads
+type circuitTrip struct {
+	failures   int
+	halfOpenAt *time.Time // When the next scheduled run probes the node, nil when only 'snapperd resume' closes it
+	reopened   bool       // The circuit was already open, and its probe or a requested upload failed
+}
+
+// message describes the open circuit for the circuit_open notification
+func (t *circuitTrip) message(nodeName string) string {
+	if t.halfOpenAt != nil {
+		return fmt.Sprintf("%d consecutive uploads failed; scheduled uploads are stopped until a probe upload after %s or 'snapperd resume %s'",
+			t.failures, t.halfOpenAt.UTC().Format(time.RFC3339), nodeName)
+	}
+	return fmt.Sprintf("%d consecutive uploads failed; scheduled uploads are stopped until 'snapperd resume %s'", t.failures, nodeName)
+}
+
+// details returns the circuit's notification and node action details
+func (t *circuitTrip) details() map[string]interface{} {
+	details := map[string]interface{}{"failures": t.failures}
+	if t.halfOpenAt != nil {
+		details["half_open_at"] = t.halfOpenAt.UTC().Format(time.RFC3339)
+	}
+	return details
+}
+
+// openCircuit opens a node's circuit once its failed uploads since the last completed one
+// reach the circuit breaker's failures, so its scheduled runs are skipped like those of a
+// paused node. A node paused by an operator keeps that pause. It returns nil when the
+// circuit stays closed.
+func openCircuit(ctx context.Context, db Database, logger *logrus.Logger, nodeName string, breaker *config.CircuitBreakerConfig, now time.Time, uploadID *int64) *circuitTrip {
+	if breaker == nil {
+		return nil
+	}
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+	}
+	failures, err := db.CountConsecutiveFailedUploads(ctx, nodeName)
+	if err != nil {
+		logger.WithFields(fields).WithField("error", err.Error()).Warn("Failed to count consecutive failed uploads")
+		return nil
+	}
+	if failures < breaker.Failures {
+		return nil
+	}
+
+	existing, err := db.GetNodePause(ctx, nodeName)
+	if err != nil {
+		logger.WithFields(fields).WithField("error", err.Error()).Warn("Failed to check whether node is paused")
+		return nil
+	}
+	opened, err := db.OpenNodeCircuit(ctx, nodeName, failures, now)
+	if err != nil {
+		logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to open node circuit")
+		return nil
+	}
+	if !opened {
+		logger.WithFields(fields).Info("Node is paused by an operator, leaving its circuit closed")
+		return nil
+	}
+
+	trip := &circuitTrip{failures: failures, reopened: existing != nil && existing.IsCircuit()}
+	if halfOpenAfter := breaker.GetHalfOpenAfter(); halfOpenAfter > 0 {
+		halfOpenAt := now.Add(halfOpenAfter)
+		trip.halfOpenAt = &halfOpenAt
+	}
+
+	fields["failures"] = failures
+	message := fmt.Sprintf("%d consecutive uploads failed, scheduled uploads stopped", failures)
+	if trip.reopened {
+		message = fmt.Sprintf("Upload failed while the circuit was open, %d consecutive uploads failed", failures)
+		logger.WithFields(fields).Warn("Upload failed while node circuit was open, circuit open again")
+	} else {
+		logger.WithFields(fields).Warn("Consecutive failed uploads opened node circuit, scheduled uploads stopped")
+	}
+	recordNodeAction(ctx, db, logger, database.NodeAction{
+		NodeName:  nodeName,
+		UploadID:  uploadID,
+		Action:    database.NodeActionCircuitOpened,
+		Message:   message,
+		Details:   trip.details(),
+		CreatedAt: now,
+	})
+	return trip
+}
+
+// closeCircuit closes a node's open circuit once one of its uploads completed
+func closeCircuit(ctx context.Context, db Database, logger *logrus.Logger, nodeName string, uploadID int64) {
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+		"upload_id": uploadID,
+	}
+	closed, err := db.CloseNodeCircuit(ctx, nodeName)
+	if err != nil {
+		logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to close node circuit")
+		return
+	}
+	if !closed {
+		return
+	}
+
+	logger.WithFields(fields).Info("Upload completed, node circuit closed")
+	recordNodeAction(ctx, db, logger, database.NodeAction{
+		NodeName: nodeName,
+		UploadID: &uploadID,
+		Action:   database.NodeActionCircuitClosed,
+		Message:  "Upload completed, scheduled uploads restarted",
+	})
+}
+
+// checkCircuit opens the node's circuit after an upload it monitored failed, sending a
+// circuit_open notification when the circuit was closed. It reports whether the circuit
+// is open.
+func (j *UploadMonitorJob) checkCircuit(ctx context.Context, u database.Upload) bool {
+	nodeConfig, exists := j.nodeConfigs.get(u.NodeName)
+	if !exists {
+		return false
+	}
+
+	trip := openCircuit(ctx, j.db, j.logger, u.NodeName, nodeConfig.CircuitBreaker, j.now(), &u.ID)
+	if trip == nil {
+		return false
+	}
+	if !trip.reopened {
+		details := trip.details()
+		details["upload_id"] = u.ID
+		j.sendNotification(ctx, u.NodeName, notification.EventCircuitOpen, trip.message(u.NodeName), details)
+	}
+	return true
+}
+
+// checkCircuit opens the node's circuit after an upload failed to start, sending a
+// circuit_open notification when the circuit was closed
+func (j *NodeUploadJob) checkCircuit(ctx context.Context) {
+	trip := openCircuit(ctx, j.db, j.logger, j.nodeName, j.nodeConfig.CircuitBreaker, j.now(), nil)
+	if trip != nil && !trip.reopened {
+		j.sendNotification(ctx, notification.EventCircuitOpen, trip.message(j.nodeName), trip.details())
+	}
+}
+
+// circuitHalfOpen reports whether a node's open circuit has been open for the circuit
+// breaker's half_open_after, so the next run probes the node with one upload. Its outcome
+// closes the circuit or opens it again.
+func (j *NodeUploadJob) circuitHalfOpen(pause *database.NodePause) bool {
+	breaker := j.nodeConfig.CircuitBreaker
+	if breaker == nil || !pause.IsCircuit() {
+		return false
+	}
+	halfOpenAfter := breaker.GetHalfOpenAfter()
+	return halfOpenAfter > 0 && !j.now().Before(pause.PausedAt.Add(halfOpenAfter))
+}
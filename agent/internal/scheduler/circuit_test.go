@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUploadMonitorJob_OpensCircuit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeFailure}, nil
+		},
+	}
+
+	failures := map[string]int{"breaker-node": 3, "reopened-node": 4, "operator-node": 3, "flaky-node": 1}
+	var mu sync.Mutex
+	opened := make(map[string]int)
+	var retried []string
+	var actions []database.NodeAction
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "breaker-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "reopened-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 3, NodeName: "operator-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 4, NodeName: "flaky-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		countConsecutiveFailedUploadsFunc: func(ctx context.Context, nodeName string) (int, error) {
+			return failures[nodeName], nil
+		},
+		getNodePauseFunc: func(ctx context.Context, nodeName string) (*database.NodePause, error) {
+			if nodeName == "reopened-node" {
+				count := 3
+				return &database.NodePause{NodeName: nodeName, Actor: database.CircuitBreakerActor, CircuitFailures: &count}, nil
+			}
+			return nil, nil
+		},
+		openNodeCircuitFunc: func(ctx context.Context, nodeName string, failures int, openedAt time.Time) (bool, error) {
+			if nodeName == "operator-node" {
+				return false, nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			opened[nodeName] = failures
+			return true, nil
+		},
+		createDelayedUploadRequestFunc: func(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB, notBefore time.Time) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			retried = append(retried, nodeName)
+			return 1, nil
+		},
+		recordNodeActionFunc: func(ctx context.Context, action database.NodeAction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actions = append(actions, action)
+			return nil
+		},
+	}
+
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if payload.Event == notification.EventCircuitOpen {
+				sent = append(sent, payload)
+			}
+			return nil
+		},
+	})
+	notifyConfig := &config.NotificationConfig{
+		CircuitOpen: true,
+		Types:       map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+
+	breaker := &config.CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "6h"}
+	retry := &config.RetryConfig{MaxAttempts: 3, Backoff: "5m"}
+	nodes := map[string]config.NodeConfig{
+		"breaker-node":  {Protocol: "ethereum", CircuitBreaker: breaker, Retry: retry},
+		"reopened-node": {Protocol: "ethereum", CircuitBreaker: breaker},
+		"operator-node": {Protocol: "ethereum", CircuitBreaker: breaker, Retry: retry},
+		"flaky-node":    {Protocol: "ethereum", CircuitBreaker: breaker},
+	}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if len(opened) != 2 || opened["breaker-node"] != 3 || opened["reopened-node"] != 4 {
+		t.Errorf("Expected the circuits of breaker-node and reopened-node opened, got %v", opened)
+	}
+	// A node whose circuit opened is not retried, one paused by an operator still is
+	if len(retried) != 1 || retried[0] != "operator-node" {
+		t.Errorf("Expected only operator-node's upload retried, got %v", retried)
+	}
+	circuitActions := 0
+	for _, action := range actions {
+		if action.Action == database.NodeActionCircuitOpened {
+			circuitActions++
+		}
+	}
+	if circuitActions != 2 {
+		t.Errorf("Expected both circuits in the nodes' action logs, got %+v", actions)
+	}
+
+	// Only a circuit that was closed is announced
+	if len(sent) != 1 || sent[0].NodeName != "breaker-node" {
+		t.Fatalf("Expected one circuit_open notification for breaker-node, got %+v", sent)
+	}
+	if sent[0].Details["failures"] != 3 || sent[0].Details["half_open_at"] != now.Add(6*time.Hour).Format(time.RFC3339) {
+		t.Errorf("Expected the failures and probe time in the notification, got %v", sent[0].Details)
+	}
+}
+
+func TestUploadMonitorJob_ClosesCircuit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeSuccess}, nil
+		},
+	}
+
+	var mu sync.Mutex
+	closed := make(map[string]bool)
+	var actions []database.NodeAction
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "probed-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "healthy-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		closeNodeCircuitFunc: func(ctx context.Context, nodeName string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			closed[nodeName] = true
+			return nodeName == "probed-node", nil
+		},
+		recordNodeActionFunc: func(ctx context.Context, action database.NodeAction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actions = append(actions, action)
+			return nil
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"probed-node":  {Protocol: "ethereum"},
+		"healthy-node": {Protocol: "ethereum"},
+	}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notification.NewRegistry(), nil, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if !closed["probed-node"] || !closed["healthy-node"] {
+		t.Errorf("Expected every completed upload to close its node's circuit, got %v", closed)
+	}
+	if len(actions) != 1 || actions[0].Action != database.NodeActionCircuitClosed || actions[0].NodeName != "probed-node" {
+		t.Errorf("Expected only probed-node's closed circuit in its action log, got %+v", actions)
+	}
+}
+
+func TestNodeUploadJob_CircuitOpen(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	failures := 3
+	tests := []struct {
+		name     string
+		breaker  *config.CircuitBreakerConfig
+		openedAt time.Time
+		want     string
+	}{
+		{name: "open", breaker: &config.CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "6h"}, openedAt: now.Add(-time.Hour), want: scheduleResultPaused},
+		{name: "half-open", breaker: &config.CircuitBreakerConfig{Failures: 3, HalfOpenAfter: "6h"}, openedAt: now.Add(-6 * time.Hour), want: scheduleResultInitiated},
+		{name: "manual resume only", breaker: &config.CircuitBreakerConfig{Failures: 3}, openedAt: now.Add(-30 * 24 * time.Hour), want: scheduleResultPaused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDatabase{
+				getNodePauseFunc: func(ctx context.Context, nodeName string) (*database.NodePause, error) {
+					return &database.NodePause{NodeName: nodeName, Actor: database.CircuitBreakerActor, PausedAt: tt.openedAt, CircuitFailures: &failures}, nil
+				},
+			}
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+			job := NewNodeUploadJob("test-node", config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", CircuitBreaker: tt.breaker},
+				protocolRegistry, &mockUploadManager{}, db, notification.NewRegistry(), nil, logger)
+			job.now = func() time.Time { return now }
+
+			result, _, err := job.RunQueued(context.Background(), upload.Trigger{Type: upload.TriggerQueue})
+			if err != nil {
+				t.Fatalf("RunQueued returned error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, result)
+			}
+		})
+	}
+}
+
+func TestNodeUploadJob_StartFailureOpensCircuit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			return 0, errors.New("bv: node not found")
+		},
+	}
+	opened := false
+	db := &mockDatabase{
+		countConsecutiveFailedUploadsFunc: func(ctx context.Context, nodeName string) (int, error) {
+			return 2, nil
+		},
+		openNodeCircuitFunc: func(ctx context.Context, nodeName string, failures int, openedAt time.Time) (bool, error) {
+			opened = true
+			return true, nil
+		},
+	}
+
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+	notifyConfig := &config.NotificationConfig{
+		CircuitOpen: true,
+		Types:       map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+	job := NewNodeUploadJob("test-node", config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", CircuitBreaker: &config.CircuitBreakerConfig{Failures: 2}},
+		protocolRegistry, uploadManager, db, notifyRegistry, notifyConfig, logger)
+
+	if result, _, _ := job.RunQueued(context.Background(), upload.Trigger{Type: upload.TriggerQueue}); result != scheduleResultFailed {
+		t.Errorf("Expected the run failed, got %s", result)
+	}
+	if !opened {
+		t.Error("Expected the node's circuit opened")
+	}
+	if len(sent) != 1 || sent[0].Event != notification.EventCircuitOpen || sent[0].Details["half_open_at"] != nil {
+		t.Errorf("Expected one circuit_open notification without a probe time, got %+v", sent)
+	}
+}
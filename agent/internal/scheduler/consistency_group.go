@@ -0,0 +1,413 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// anchorKeys are the protocol_data keys recorded as a member's chain position when a
+// consistency group run starts
+var anchorKeys = []string{"latest_block", "latest_slot", "finalized_block"}
+
+// ConsistencyGroupStore is the database access needed to record consistency group runs
+type ConsistencyGroupStore interface {
+	CreateConsistencyGroupRun(ctx context.Context, groupName string, startedAt time.Time) (int64, error)
+	AddConsistencyGroupUpload(ctx context.Context, runID int64, nodeName string, uploadID int64) error
+	CompleteConsistencyGroupRun(ctx context.Context, runID int64, status string, anchors database.JSONB, errorMessage *string) error
+}
+
+// ConsistencyGroupJob starts the uploads of a consistency group together, so the
+// snapshots of nodes that are restored as a pair (such as an Ethereum execution and
+// consensus client) capture the same point of the chain. Every member's metrics are
+// collected at the same moment, finality is awaited for all members that require it,
+// and all uploads are started only when none of the members is already uploading.
+type ConsistencyGroupJob struct {
+	name     string
+	schedule string
+	members  []*NodeUploadJob
+	store    ConsistencyGroupStore
+	logger   *logrus.Logger
+	now      func() time.Time
+}
+
+// NewConsistencyGroupJob creates a job starting the given member node jobs together
+func NewConsistencyGroupJob(name string, schedule string, members []*NodeUploadJob, store ConsistencyGroupStore, logger *logrus.Logger) *ConsistencyGroupJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	// Lock members in a fixed order so concurrent runs cannot deadlock
+	sorted := append([]*NodeUploadJob(nil), members...)
+	sort.Slice(sorted, func(i, k int) bool { return sorted[i].nodeName < sorted[k].nodeName })
+
+	return &ConsistencyGroupJob{
+		name:     name,
+		schedule: schedule,
+		members:  sorted,
+		store:    store,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// groupMember holds a member's state during a group run
+type groupMember struct {
+	job            *NodeUploadJob
+	protocolModule protocol.ProtocolModule
+	metrics        map[string]interface{}
+	result         string
+	uploadID       int64
+	err            error
+}
+
+// Run executes a coordinated upload of all group members and records each member's
+// result in its schedule state
+func (j *ConsistencyGroupJob) Run(ctx context.Context) error {
+	for _, member := range j.members {
+		member.mu.Lock()
+		defer member.mu.Unlock()
+	}
+
+	startedAt := j.now()
+	members := make([]*groupMember, len(j.members))
+	for i, job := range j.members {
+		members[i] = &groupMember{job: job, result: scheduleResultFailed}
+	}
+
+	err := j.run(ctx, startedAt, members)
+	for _, member := range members {
+		member.job.saveScheduleState(ctx, startedAt, member.result)
+	}
+	return err
+}
+
+// run performs the group workflow, setting each member's result
+func (j *ConsistencyGroupJob) run(ctx context.Context, startedAt time.Time, members []*groupMember) error {
+	fields := logrus.Fields{
+		"component":         "scheduler",
+		"job":               "consistency_group",
+		"consistency_group": j.name,
+	}
+	j.logger.WithFields(fields).Info("Starting consistency group upload")
+
+	// A disabled or paused member holds the whole group, whose snapshots must be taken
+	// together
+	if j.paused(ctx, members) {
+		return nil
+	}
+
+	// Step 1: The group only starts when no member is uploading
+	if skip, err := j.checkRunning(ctx, members); err != nil || skip {
+		return err
+	}
+
+	// Step 2: Collect every member's metrics at the same moment
+	if err := j.collectMetrics(ctx, members); err != nil {
+		return err
+	}
+
+	// Step 3: No member starts when any member is unhealthy or its metrics look implausible
+	if err := j.preflight(ctx, members); err != nil {
+		return err
+	}
+	if err := j.validateMetrics(ctx, members); err != nil {
+		return err
+	}
+
+	// Step 4: Hold the group until every member that requires it has reached finality
+	if err := j.waitForFinality(ctx, startedAt, members); err != nil {
+		return err
+	}
+
+	// Step 5: Record the run, then start all uploads together
+	runID, err := j.store.CreateConsistencyGroupRun(ctx, j.name, startedAt)
+	if err != nil {
+		j.fail(ctx, members, "Failed to record consistency group run", err)
+		return fmt.Errorf("failed to record consistency group run: %w", err)
+	}
+
+	trigger := upload.Trigger{
+		Type:     upload.TriggerScheduled,
+		Metadata: map[string]interface{}{"schedule": j.schedule, "consistency_group": j.name},
+	}
+	var wg sync.WaitGroup
+	for _, member := range members {
+		member.metrics = protocol.WithMetadata(member.metrics, member.job.nodeConfig)
+		member.metrics["consistency_group"] = map[string]interface{}{"name": j.name, "run_id": runID}
+
+		wg.Add(1)
+		go func(member *groupMember) {
+			defer wg.Done()
+			member.uploadID, member.err = member.job.initiateUpload(ctx, trigger, member.metrics)
+		}(member)
+	}
+	wg.Wait()
+
+	// Step 6: Record the pairing and the outcome
+	anchors := make(database.JSONB, len(members))
+	var started, failed []string
+	for _, member := range members {
+		nodeName := member.job.nodeName
+		anchor := make(map[string]interface{})
+		for _, key := range anchorKeys {
+			if value, ok := member.metrics[key]; ok {
+				anchor[key] = value
+			}
+		}
+		anchors[nodeName] = anchor
+
+		if member.err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", nodeName, member.err))
+			continue
+		}
+		member.result = scheduleResultInitiated
+		started = append(started, nodeName)
+		if err := j.store.AddConsistencyGroupUpload(ctx, runID, nodeName, member.uploadID); err != nil {
+			j.logger.WithFields(fields).WithFields(logrus.Fields{
+				"node":      nodeName,
+				"upload_id": member.uploadID,
+				"error":     err.Error(),
+			}).Error("Failed to record consistency group upload")
+		}
+	}
+
+	status := database.ConsistencyGroupRunInitiated
+	var errorMessage *string
+	if len(failed) > 0 {
+		status = database.ConsistencyGroupRunPartial
+		if len(started) == 0 {
+			status = database.ConsistencyGroupRunFailed
+		}
+		message := strings.Join(failed, "; ")
+		errorMessage = &message
+	}
+	if err := j.store.CompleteConsistencyGroupRun(ctx, runID, status, anchors, errorMessage); err != nil {
+		j.logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to record consistency group run outcome")
+	}
+
+	fields["run_id"] = runID
+	fields["status"] = status
+	if errorMessage != nil {
+		fields["error"] = *errorMessage
+		j.logger.WithFields(fields).Error("Consistency group upload not started for all members")
+		for _, member := range members {
+			member.job.sendNotification(ctx, notification.EventFailure, "Consistency group upload not started for all members, snapshots are not consistent", map[string]interface{}{
+				"consistency_group": j.name,
+				"run_id":            runID,
+				"started":           strings.Join(started, ", "),
+				"error":             *errorMessage,
+			})
+		}
+		return fmt.Errorf("consistency group %s: %s", j.name, *errorMessage)
+	}
+
+	j.logger.WithFields(fields).Info("Consistency group upload initiated")
+	return nil
+}
+
+// paused reports whether the group must be skipped because a member is disabled or
+// paused, recording every member's run as paused
+func (j *ConsistencyGroupJob) paused(ctx context.Context, members []*groupMember) bool {
+	for _, member := range members {
+		if !member.job.paused(ctx) {
+			continue
+		}
+		j.logger.WithFields(logrus.Fields{
+			"component":         "scheduler",
+			"consistency_group": j.name,
+			"node":              member.job.nodeName,
+		}).Info("Consistency group member is paused, skipping group upload")
+		for _, m := range members {
+			m.result = scheduleResultPaused
+		}
+		return true
+	}
+	return false
+}
+
+// checkRunning reports whether the group must be skipped because a member is uploading
+func (j *ConsistencyGroupJob) checkRunning(ctx context.Context, members []*groupMember) (bool, error) {
+	for _, member := range members {
+		shouldSkip, err := member.job.uploadManager.ShouldSkipUpload(ctx, member.job.nodeName)
+		if err != nil {
+			j.fail(ctx, members, "Failed to check upload status", err)
+			return true, fmt.Errorf("failed to check upload status of %s: %w", member.job.nodeName, err)
+		}
+		if shouldSkip {
+			j.logger.WithFields(logrus.Fields{
+				"component":         "scheduler",
+				"consistency_group": j.name,
+				"node":              member.job.nodeName,
+			}).Info("Consistency group member already uploading, skipping group")
+			for _, m := range members {
+				m.result = scheduleResultSkipped
+				m.job.sendNotification(ctx, notification.EventSkip, "Consistency group member upload already running", map[string]interface{}{
+					"consistency_group": j.name,
+					"running_node":      member.job.nodeName,
+				})
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// collectMetrics collects all members' metrics concurrently, so the recorded chain
+// positions are taken as close together as possible
+func (j *ConsistencyGroupJob) collectMetrics(ctx context.Context, members []*groupMember) error {
+	for _, member := range members {
+		protocolModule, err := member.job.protocolRegistry.Get(member.job.nodeConfig.Protocol)
+		if err != nil {
+			j.fail(ctx, members, "Failed to get protocol module", err)
+			return fmt.Errorf("failed to get protocol module for %s: %w", member.job.nodeName, err)
+		}
+		member.protocolModule = protocolModule
+	}
+
+	var wg sync.WaitGroup
+	for _, member := range members {
+		wg.Add(1)
+		go func(member *groupMember) {
+			defer wg.Done()
+			metrics, err := member.protocolModule.CollectMetrics(ctx, member.job.nodeConfig)
+			if err != nil {
+				j.logger.WithFields(logrus.Fields{
+					"component":         "scheduler",
+					"consistency_group": j.name,
+					"node":              member.job.nodeName,
+					"error":             err.Error(),
+				}).Error("Failed to collect metrics")
+				metrics = map[string]interface{}{
+					"error": err.Error(),
+				}
+			}
+			member.metrics = metrics
+		}(member)
+	}
+	wg.Wait()
+	return nil
+}
+
+// preflight checks every member's preflight gates. A failed gate skips the whole group,
+// since the other members' snapshots would have no consistent counterpart.
+func (j *ConsistencyGroupJob) preflight(ctx context.Context, members []*groupMember) error {
+	var failed []string
+	for _, member := range members {
+		for _, gate := range member.job.preflight(ctx, member.protocolModule, member.metrics) {
+			failed = append(failed, fmt.Sprintf("%s: %s", member.job.nodeName, gate))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	reason := strings.Join(failed, "; ")
+	j.logger.WithFields(logrus.Fields{
+		"component":         "scheduler",
+		"consistency_group": j.name,
+		"failed_gates":      reason,
+	}).Warn("Consistency group member failed preflight, skipping group")
+	for _, member := range members {
+		member.result = scheduleResultPreflightFailed
+		member.job.sendNotification(ctx, notification.EventPreflight, "Consistency group upload skipped: failed preflight", map[string]interface{}{
+			"consistency_group": j.name,
+			"failed_gates":      reason,
+		})
+	}
+	return fmt.Errorf("failed preflight: %s", reason)
+}
+
+// validateMetrics checks every member's metrics against its validation thresholds. A
+// violation blocks the whole group, since the other members' snapshots would have no
+// consistent counterpart.
+func (j *ConsistencyGroupJob) validateMetrics(ctx context.Context, members []*groupMember) error {
+	var invalid []string
+	for _, member := range members {
+		if err := member.job.validateMetrics(ctx, member.metrics); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", member.job.nodeName, err))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("%s", strings.Join(invalid, "; "))
+	j.fail(ctx, members, "Collected metrics failed validation, upload not started", err)
+	for _, member := range members {
+		member.result = scheduleResultInvalidMetrics
+	}
+	return fmt.Errorf("collected metrics failed validation: %w", err)
+}
+
+// waitForFinality waits, concurrently, until every member configured with
+// wait_for_finality has finalized its collected snapshot point
+func (j *ConsistencyGroupJob) waitForFinality(ctx context.Context, startedAt time.Time, members []*groupMember) error {
+	var waiting []*groupMember
+	for _, member := range members {
+		if member.job.nodeConfig.WaitForFinality {
+			waiting = append(waiting, member)
+		}
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+
+	// Let 'snapperd status' explain why the group has not started yet
+	for _, member := range members {
+		member.job.saveScheduleState(ctx, startedAt, scheduleResultWaitingFinality)
+	}
+
+	var wg sync.WaitGroup
+	for _, member := range waiting {
+		wg.Add(1)
+		go func(member *groupMember) {
+			defer wg.Done()
+			var finalizedBlock int64
+			finalizedBlock, member.err = member.job.waitForFinality(ctx, member.protocolModule, member.metrics)
+			if member.err == nil {
+				member.metrics["finalized_block"] = finalizedBlock
+			}
+		}(member)
+	}
+	wg.Wait()
+
+	for _, member := range waiting {
+		if member.err != nil {
+			err := fmt.Errorf("%s: %w", member.job.nodeName, member.err)
+			j.fail(ctx, members, "Failed waiting for finality", err)
+			return fmt.Errorf("failed waiting for finality: %w", err)
+		}
+	}
+
+	// A member's upload may have started while waiting
+	if skip, err := j.checkRunning(ctx, members); err != nil || skip {
+		return err
+	}
+	return nil
+}
+
+// fail logs a group failure before any upload was started and notifies every member
+func (j *ConsistencyGroupJob) fail(ctx context.Context, members []*groupMember, message string, err error) {
+	j.logger.WithFields(logrus.Fields{
+		"component":         "scheduler",
+		"consistency_group": j.name,
+		"error":             err.Error(),
+	}).Error(message)
+	for _, member := range members {
+		member.result = scheduleResultFailed
+		member.job.sendNotification(ctx, notification.EventFailure, message, map[string]interface{}{
+			"consistency_group": j.name,
+			"error":             err.Error(),
+		})
+	}
+}
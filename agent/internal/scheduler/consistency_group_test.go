@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+type mockConsistencyGroupStore struct {
+	mu           sync.Mutex
+	runs         int
+	uploads      map[string]int64
+	status       string
+	anchors      database.JSONB
+	errorMessage *string
+}
+
+func (m *mockConsistencyGroupStore) CreateConsistencyGroupRun(ctx context.Context, groupName string, startedAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs++
+	return int64(m.runs), nil
+}
+
+func (m *mockConsistencyGroupStore) AddConsistencyGroupUpload(ctx context.Context, runID int64, nodeName string, uploadID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[nodeName] = uploadID
+	return nil
+}
+
+func (m *mockConsistencyGroupStore) CompleteConsistencyGroupRun(ctx context.Context, runID int64, status string, anchors database.JSONB, errorMessage *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status, m.anchors, m.errorMessage = status, anchors, errorMessage
+	return nil
+}
+
+func TestConsistencyGroupJob(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	tests := []struct {
+		name        string
+		running     string // Member already uploading
+		failNode    string // Member whose upload fails to start
+		invalidNode string // Member reporting an implausible latest_block
+		pausedNode  string // Member paused with 'snapperd pause'
+		wantStarted []string
+		wantRuns    int
+		wantStatus  string
+		wantResult  string
+		wantErr     bool
+	}{
+		{name: "all members started", wantStarted: []string{"eth-cl", "eth-el"}, wantRuns: 1, wantStatus: database.ConsistencyGroupRunInitiated, wantResult: scheduleResultInitiated},
+		{name: "member already uploading", running: "eth-cl", wantResult: scheduleResultSkipped},
+		{name: "member paused", pausedNode: "eth-cl", wantResult: scheduleResultPaused},
+		{name: "member metrics invalid", invalidNode: "eth-el", wantResult: scheduleResultInvalidMetrics, wantErr: true},
+		{name: "member fails to start", failNode: "eth-el", wantStarted: []string{"eth-cl"}, wantRuns: 1, wantStatus: database.ConsistencyGroupRunPartial, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			protocolData := make(map[string]map[string]interface{})
+			uploadManager := &mockUploadManager{
+				shouldSkipFunc: func(ctx context.Context, nodeName string) (bool, error) {
+					return nodeName == tt.running, nil
+				},
+				initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, data map[string]interface{}) (int64, error) {
+					if nodeName == tt.failNode {
+						return 0, fmt.Errorf("bv failed")
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					protocolData[nodeName] = data
+					return int64(len(protocolData)), nil
+				},
+			}
+
+			results := make(map[string]string)
+			db := &mockDatabase{
+				saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+					mu.Lock()
+					defer mu.Unlock()
+					results[state.NodeName] = *state.LastResult
+					return nil
+				},
+				getNodePauseFunc: func(ctx context.Context, nodeName string) (*database.NodePause, error) {
+					if nodeName == tt.pausedNode {
+						return &database.NodePause{NodeName: nodeName, Actor: "alice"}, nil
+					}
+					return nil, nil
+				},
+			}
+
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{
+				name: "ethereum",
+				collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+					if cfg.URL == tt.invalidNode {
+						return map[string]interface{}{"latest_block": int64(0), "latest_slot": int64(3200)}, nil
+					}
+					return map[string]interface{}{"latest_block": int64(100), "latest_slot": int64(3200)}, nil
+				},
+			})
+
+			var members []*NodeUploadJob
+			for _, nodeName := range []string{"eth-el", "eth-cl"} {
+				members = append(members, NewNodeUploadJob(
+					nodeName,
+					config.NodeConfig{Protocol: "ethereum", URL: nodeName, Schedule: "0 0 0 * * *", Validation: &config.MetricValidationConfig{}},
+					protocolRegistry,
+					uploadManager,
+					db,
+					notification.NewRegistry(),
+					nil,
+					logger,
+				))
+			}
+
+			store := &mockConsistencyGroupStore{uploads: make(map[string]int64)}
+			job := NewConsistencyGroupJob("eth", "0 0 0 * * *", members, store, logger)
+
+			err := job.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(protocolData) != len(tt.wantStarted) {
+				t.Fatalf("expected %d uploads started, got %d", len(tt.wantStarted), len(protocolData))
+			}
+			for _, nodeName := range tt.wantStarted {
+				group, ok := protocolData[nodeName]["consistency_group"].(map[string]interface{})
+				if !ok || group["name"] != "eth" || group["run_id"] != int64(1) {
+					t.Errorf("expected %s protocol data to reference run 1 of group eth, got %v", nodeName, protocolData[nodeName])
+				}
+				if _, recorded := store.uploads[nodeName]; !recorded {
+					t.Errorf("expected %s upload recorded in the group run", nodeName)
+				}
+			}
+
+			if store.runs != tt.wantRuns {
+				t.Errorf("expected %d group runs, got %d", tt.wantRuns, store.runs)
+			}
+			if store.status != tt.wantStatus {
+				t.Errorf("expected run status %q, got %q", tt.wantStatus, store.status)
+			}
+			if tt.wantRuns > 0 {
+				anchor, ok := store.anchors["eth-el"].(map[string]interface{})
+				if !ok || anchor["latest_block"] != int64(100) || anchor["latest_slot"] != int64(3200) {
+					t.Errorf("expected eth-el anchor at block 100, slot 3200, got %v", store.anchors)
+				}
+			}
+
+			if tt.wantResult != "" {
+				for _, nodeName := range []string{"eth-el", "eth-cl"} {
+					if results[nodeName] != tt.wantResult {
+						t.Errorf("expected %s schedule result %q, got %q", nodeName, tt.wantResult, results[nodeName])
+					}
+				}
+			}
+			if tt.failNode != "" && (results[tt.failNode] != scheduleResultFailed || results["eth-cl"] != scheduleResultInitiated) {
+				t.Errorf("expected per-member results, got %v", results)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// DigestStore is the database the uploads summarized by digests are read from
+type DigestStore interface {
+	ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error)
+}
+
+// NodeDigest summarizes a node's finished uploads that started within a digest's period
+type NodeDigest struct {
+	Node        string
+	Completed   int
+	Failed      int // Failed, stalled past max_duration or otherwise not completed
+	Cancelled   int
+	AvgDuration time.Duration // Of completed uploads (0 when none completed)
+	MaxDuration time.Duration // Of completed uploads
+	SizeBytes   int64         // Total of completed uploads that report their size
+}
+
+// Uploads returns how many of the node's uploads finished
+func (n NodeDigest) Uploads() int {
+	return n.Completed + n.Failed + n.Cancelled
+}
+
+// Digest summarizes the finished uploads of every node that started within a period
+type Digest struct {
+	Since     time.Time
+	Until     time.Time
+	Nodes     []NodeDigest // By node name, including nodes without uploads
+	Completed int
+	Failed    int
+	Cancelled int
+	SizeBytes int64
+}
+
+// Uploads returns how many uploads finished
+func (d Digest) Uploads() int {
+	return d.Completed + d.Failed + d.Cancelled
+}
+
+// BuildDigest summarizes finished uploads by node. Every node in nodes is listed, with or
+// without uploads, as are the nodes of the uploads. A finished upload lasts until bv
+// reported the job finished, else until it was detected.
+func BuildDigest(uploads []database.Upload, nodes []string, since, until time.Time) Digest {
+	byNode := make(map[string]*NodeDigest, len(nodes))
+	nodeDigest := func(name string) *NodeDigest {
+		if n, ok := byNode[name]; ok {
+			return n
+		}
+		n := &NodeDigest{Node: name}
+		byNode[name] = n
+		return n
+	}
+	for _, name := range nodes {
+		nodeDigest(name)
+	}
+
+	totalDurations := make(map[string]time.Duration)
+	for _, u := range uploads {
+		n := nodeDigest(u.NodeName)
+		switch u.Status {
+		case "completed":
+			n.Completed++
+			if end := uploadEnd(u); end != nil {
+				duration := end.Sub(u.StartedAt)
+				totalDurations[u.NodeName] += duration
+				if duration > n.MaxDuration {
+					n.MaxDuration = duration
+				}
+			}
+			if u.SizeBytes != nil {
+				n.SizeBytes += *u.SizeBytes
+			}
+		case "cancelled":
+			n.Cancelled++
+		default:
+			n.Failed++
+		}
+	}
+
+	digest := Digest{Since: since, Until: until, Nodes: make([]NodeDigest, 0, len(byNode))}
+	for name, n := range byNode {
+		if n.Completed > 0 {
+			n.AvgDuration = totalDurations[name] / time.Duration(n.Completed)
+		}
+		digest.Completed += n.Completed
+		digest.Failed += n.Failed
+		digest.Cancelled += n.Cancelled
+		digest.SizeBytes += n.SizeBytes
+		digest.Nodes = append(digest.Nodes, *n)
+	}
+	sort.Slice(digest.Nodes, func(i, k int) bool {
+		return digest.Nodes[i].Node < digest.Nodes[k].Node
+	})
+	return digest
+}
+
+// uploadEnd returns when a finished upload ended: when bv reported the job finished,
+// else when it was detected
+func uploadEnd(u database.Upload) *time.Time {
+	if u.FinishedAt != nil {
+		return u.FinishedAt
+	}
+	return u.CompletedAt
+}
+
+// summaryLine describes a node's uploads in one line of a digest notification
+func (n NodeDigest) summaryLine() string {
+	if n.Uploads() == 0 {
+		return n.Node + ": no uploads"
+	}
+
+	counts := []string{fmt.Sprintf("%d completed", n.Completed)}
+	if n.Failed > 0 {
+		counts = append(counts, fmt.Sprintf("%d failed", n.Failed))
+	}
+	if n.Cancelled > 0 {
+		counts = append(counts, fmt.Sprintf("%d cancelled", n.Cancelled))
+	}
+	line := n.Node + ": " + strings.Join(counts, ", ")
+	if n.Completed > 0 {
+		line += fmt.Sprintf(" · avg %s, max %s", notification.FormatDuration(n.AvgDuration), notification.FormatDuration(n.MaxDuration))
+	}
+	if n.SizeBytes > 0 {
+		line += " · " + notification.FormatBytes(n.SizeBytes)
+	}
+	return line
+}
+
+// DigestJob sends one notification summarizing the finished uploads of every node that
+// started within the last period, to the global notification types. Teams that turn off
+// per-event notifications still see how uploads are going.
+type DigestJob struct {
+	db             Database
+	store          DigestStore
+	notifyRegistry *notification.Registry
+	notifyCfg      *config.NotificationConfig
+	digestCfg      *config.DigestConfig
+	nodeConfigs    *nodeConfigSet
+	logger         *logrus.Logger
+	now            func() time.Time
+}
+
+// NewDigestJob creates a new digest job
+func NewDigestJob(
+	db Database,
+	store DigestStore,
+	notifyRegistry *notification.Registry,
+	notifyCfg *config.NotificationConfig,
+	digestCfg *config.DigestConfig,
+	nodeConfigs map[string]config.NodeConfig,
+	logger *logrus.Logger,
+) *DigestJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &DigestJob{
+		db:             db,
+		store:          store,
+		notifyRegistry: notifyRegistry,
+		notifyCfg:      notifyCfg,
+		digestCfg:      digestCfg,
+		nodeConfigs:    newNodeConfigSet(nodeConfigs),
+		logger:         logger,
+		now:            time.Now,
+	}
+}
+
+// SetNode lists a node registered, or updated, at runtime in later digests
+func (j *DigestJob) SetNode(cfg *config.Config, nodeName string) {
+	j.nodeConfigs.set(nodeName, cfg.Nodes[nodeName])
+}
+
+// RemoveNode stops listing a deregistered node in digests without its uploads
+func (j *DigestJob) RemoveNode(nodeName string) {
+	j.nodeConfigs.remove(nodeName)
+}
+
+// Run summarizes the finished uploads started within the last period and sends the digest
+func (j *DigestJob) Run(ctx context.Context) error {
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"job":       "digest",
+	}).Debug("Starting digest job")
+
+	until := j.now()
+	since := until.Add(-j.digestCfg.GetPeriod())
+	uploads, err := j.store.ListUploads(ctx, database.UploadFilter{Since: since, Until: until})
+	if err != nil {
+		return fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	nodes := make([]string, 0, len(j.nodeConfigs.all()))
+	for nodeName := range j.nodeConfigs.all() {
+		nodes = append(nodes, nodeName)
+	}
+	digest := BuildDigest(uploads, nodes, since, until)
+
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"since":     since.UTC().Format(time.RFC3339),
+		"uploads":   digest.Uploads(),
+		"completed": digest.Completed,
+		"failed":    digest.Failed,
+	}).Info("Sending upload digest")
+
+	j.sendNotification(ctx, digest)
+
+	return nil
+}
+
+// sendNotification sends a digest notification to every global notification type
+func (j *DigestJob) sendNotification(ctx context.Context, digest Digest) {
+	if j.notifyRegistry == nil || j.notifyCfg == nil {
+		return
+	}
+
+	lines := make([]string, 0, len(digest.Nodes))
+	for _, n := range digest.Nodes {
+		lines = append(lines, n.summaryLine())
+	}
+	period := j.digestCfg.Period
+	if period == "" {
+		period = config.DigestDaily
+	}
+	details := map[string]interface{}{
+		"period":     period,
+		"since":      digest.Since.UTC().Format(time.RFC3339),
+		"uploads":    digest.Uploads(),
+		"completed":  digest.Completed,
+		"failed":     digest.Failed,
+		"cancelled":  digest.Cancelled,
+		"size_bytes": digest.SizeBytes,
+		"nodes":      strings.Join(lines, "\n"),
+	}
+	message := fmt.Sprintf("%d uploads finished across %d nodes: %d completed, %d failed, %d cancelled",
+		digest.Uploads(), len(digest.Nodes), digest.Completed, digest.Failed, digest.Cancelled)
+
+	payload := notification.NotificationPayload{
+		Event:     notification.EventDigest,
+		Timestamp: time.Now(),
+		Message:   message,
+		Details:   details,
+	}
+
+	for notificationType, typeConfig := range j.notifyCfg.Types {
+		notificationModule, err := j.notifyRegistry.Get(notificationType)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+			}).Warn("Notification module not found")
+			continue
+		}
+
+		rendered := RenderNotification(j.notifyCfg, notificationType, payload, j.logger)
+		if err := DeliverNotification(ctx, j.db, j.logger, notificationModule, typeConfig.URL, rendered); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+				"error":     err.Error(),
+			}).Error("Failed to send notification")
+		}
+	}
+}
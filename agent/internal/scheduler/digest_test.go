@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// mockDigestStore serves fixed finished uploads and records the filter they were listed with
+type mockDigestStore struct {
+	uploads []database.Upload
+	filter  database.UploadFilter
+}
+
+func (m *mockDigestStore) ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error) {
+	m.filter = filter
+	return m.uploads, nil
+}
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	size := int64(3 << 30)
+	uploads := append(sloUploads(now, "completed", 2*time.Hour, 4*time.Hour), sloUploads(now, "failed", time.Hour)...)
+	uploads[0].SizeBytes = &size
+	cancelled := sloUploads(now, "cancelled", time.Hour)
+	cancelled[0].NodeName = "removed"
+	uploads = append(uploads, cancelled...)
+
+	digest := BuildDigest(uploads, []string{"eth", "idle"}, now.Add(-24*time.Hour), now)
+	if digest.Uploads() != 4 || digest.Completed != 2 || digest.Failed != 1 || digest.Cancelled != 1 || digest.SizeBytes != size {
+		t.Fatalf("Unexpected totals: %+v", digest)
+	}
+	if len(digest.Nodes) != 3 || digest.Nodes[0].Node != "eth" || digest.Nodes[1].Node != "idle" || digest.Nodes[2].Node != "removed" {
+		t.Fatalf("Expected eth, idle and removed in order, got %+v", digest.Nodes)
+	}
+
+	eth := digest.Nodes[0]
+	if eth.AvgDuration != 3*time.Hour || eth.MaxDuration != 4*time.Hour {
+		t.Errorf("Expected avg 3h and max 4h, got %v and %v", eth.AvgDuration, eth.MaxDuration)
+	}
+	if want := "eth: 2 completed, 1 failed · avg 3h0m0s, max 4h0m0s · 3.0 GiB"; eth.summaryLine() != want {
+		t.Errorf("Expected %q, got %q", want, eth.summaryLine())
+	}
+	if want := "idle: no uploads"; digest.Nodes[1].summaryLine() != want {
+		t.Errorf("Expected %q, got %q", want, digest.Nodes[1].summaryLine())
+	}
+	if want := "removed: 0 completed, 1 cancelled"; digest.Nodes[2].summaryLine() != want {
+		t.Errorf("Expected %q, got %q", want, digest.Nodes[2].summaryLine())
+	}
+}
+
+func TestDigestJob(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	store := &mockDigestStore{
+		uploads: append(sloUploads(now, "completed", 2*time.Hour), sloUploads(now, "failed", time.Hour)...),
+	}
+
+	var mu sync.Mutex
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+	// Per-event notifications are off; the digest is still sent
+	notifyConfig := &config.NotificationConfig{
+		Types: map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+
+	nodes := map[string]config.NodeConfig{"eth": {Protocol: "ethereum"}}
+	job := NewDigestJob(nil, store, notifyRegistry, notifyConfig, &config.DigestConfig{Period: config.DigestWeekly}, nodes, logger)
+	job.now = func() time.Time { return now }
+	job.SetNode(&config.Config{Nodes: map[string]config.NodeConfig{"arb": {Protocol: "arbitrum"}}}, "arb")
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if !store.filter.Since.Equal(now.Add(-7*24*time.Hour)) || !store.filter.Until.Equal(now) {
+		t.Errorf("Expected the last week's uploads, got %v to %v", store.filter.Since, store.filter.Until)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(sent))
+	}
+	payload := sent[0]
+	if payload.Event != notification.EventDigest || payload.NodeName != "" || payload.Title != "📊 Upload Digest" {
+		t.Errorf("Unexpected digest notification: %+v", payload)
+	}
+	if payload.Details["period"] != config.DigestWeekly || payload.Details["completed"] != 1 || payload.Details["failed"] != 1 {
+		t.Errorf("Unexpected details: %v", payload.Details)
+	}
+	if want := "arb: no uploads\neth: 1 completed, 1 failed · avg 2h0m0s, max 2h0m0s"; payload.Details["nodes"] != want {
+		t.Errorf("Expected nodes %q, got %q", want, payload.Details["nodes"])
+	}
+	if !strings.HasPrefix(payload.Message, "2 uploads finished across 2 nodes") {
+		t.Errorf("Unexpected message %q", payload.Message)
+	}
+}
@@ -0,0 +1,243 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// FreshnessJob is a watchdog that alerts when a node's last successful upload is older
+// than its max_snapshot_age, which catches schedules that have silently stopped
+// producing snapshots (failing runs, blocked uploads, a misconfigured cron expression)
+type FreshnessJob struct {
+	db              Database
+	notifyRegistry  *notification.Registry
+	globalNotifyCfg *config.NotificationConfig
+	nodeConfigs     *nodeConfigSet
+	logger          *logrus.Logger
+	now             func() time.Time
+	startedAt       time.Time // Age reference for nodes that never completed an upload
+
+	// maxAges maps node names to max_snapshot_age; nodes without one are not checked.
+	// Changes replace the map, so Run can range over it without holding maxAgesMu.
+	maxAgesMu sync.RWMutex
+	maxAges   map[string]time.Duration
+
+	mu      sync.Mutex
+	alerted map[string]int64 // node name -> latest completed upload ID already alerted on (0 = none)
+}
+
+// NewFreshnessJob creates a new snapshot freshness watchdog job
+func NewFreshnessJob(
+	db Database,
+	notifyRegistry *notification.Registry,
+	globalNotifyCfg *config.NotificationConfig,
+	nodeConfigs map[string]config.NodeConfig,
+	maxAges map[string]time.Duration,
+	logger *logrus.Logger,
+) *FreshnessJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &FreshnessJob{
+		db:              db,
+		notifyRegistry:  notifyRegistry,
+		globalNotifyCfg: globalNotifyCfg,
+		nodeConfigs:     newNodeConfigSet(nodeConfigs),
+		maxAges:         maxAges,
+		logger:          logger,
+		now:             time.Now,
+		startedAt:       time.Now(),
+		alerted:         make(map[string]int64),
+	}
+}
+
+// SnapshotAge returns how long ago a node's last successful upload completed, and false
+// when the upload has no recorded completion time
+func SnapshotAge(last *database.Upload, now time.Time) (time.Duration, bool) {
+	if last == nil || last.CompletedAt == nil {
+		return 0, false
+	}
+	return now.Sub(*last.CompletedAt), true
+}
+
+// SetNode starts checking a node registered, or updated, at runtime, if it has a
+// max_snapshot_age
+func (j *FreshnessJob) SetNode(cfg *config.Config, nodeName string) {
+	j.nodeConfigs.set(nodeName, cfg.ResolvedNode(nodeName))
+	j.setMaxAge(nodeName, cfg.GetMaxSnapshotAge(nodeName))
+}
+
+// RemoveNode stops checking a deregistered node
+func (j *FreshnessJob) RemoveNode(nodeName string) {
+	j.nodeConfigs.remove(nodeName)
+	j.setMaxAge(nodeName, 0)
+}
+
+// setMaxAge replaces a node's max_snapshot_age; 0 stops checking the node
+func (j *FreshnessJob) setMaxAge(nodeName string, maxAge time.Duration) {
+	j.maxAgesMu.Lock()
+	defer j.maxAgesMu.Unlock()
+
+	maxAges := make(map[string]time.Duration, len(j.maxAges)+1)
+	for name, existing := range j.maxAges {
+		if name != nodeName {
+			maxAges[name] = existing
+		}
+	}
+	if maxAge > 0 {
+		maxAges[nodeName] = maxAge
+	}
+	j.maxAges = maxAges
+}
+
+// Run checks the age of every node's last successful upload
+func (j *FreshnessJob) Run(ctx context.Context) error {
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"job":       "freshness",
+	}).Debug("Starting snapshot freshness job")
+
+	var wg sync.WaitGroup
+	j.maxAgesMu.RLock()
+	maxAges := j.maxAges
+	j.maxAgesMu.RUnlock()
+
+	for nodeName, maxAge := range maxAges {
+		if maxAge <= 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node string, maxAge time.Duration) {
+			defer wg.Done()
+
+			// Each node is checked independently to ensure node isolation
+			if err := j.checkNode(ctx, node, maxAge); err != nil {
+				j.logger.WithFields(logrus.Fields{
+					"component": "scheduler",
+					"node":      node,
+					"error":     err.Error(),
+				}).Warn("Failed to check snapshot freshness")
+			}
+		}(nodeName, maxAge)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// checkNode alerts once when the node's last successful upload becomes older than maxAge.
+// A node that never completed an upload is measured from the daemon's start.
+func (j *FreshnessJob) checkNode(ctx context.Context, nodeName string, maxAge time.Duration) error {
+	last, err := j.db.GetLatestCompletedUploadForNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get latest completed upload: %w", err)
+	}
+
+	now := j.now()
+	var lastID int64
+	age, known := SnapshotAge(last, now)
+	if known {
+		lastID = last.ID
+	} else {
+		age = now.Sub(j.startedAt)
+	}
+
+	// Alert once per stale snapshot; a new snapshot resets the baseline
+	j.mu.Lock()
+	alertedID, alreadyAlerted := j.alerted[nodeName]
+	if age <= maxAge {
+		delete(j.alerted, nodeName)
+	} else {
+		j.alerted[nodeName] = lastID
+	}
+	j.mu.Unlock()
+	if age <= maxAge || (alreadyAlerted && alertedID == lastID) {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"snapshot_age":     age.Round(time.Minute).String(),
+		"max_snapshot_age": maxAge.String(),
+	}
+	message := "No successful upload within max_snapshot_age"
+	if known {
+		details["upload_id"] = last.ID
+		details["completed_at"] = last.CompletedAt.UTC().Format(time.RFC3339)
+	} else {
+		message = "No successful upload recorded since the daemon started"
+	}
+
+	// A running upload may be about to refresh the snapshot, or be what is stuck
+	running, err := j.db.GetRunningUploadForNode(ctx, nodeName)
+	if err == nil && running != nil {
+		details["running_upload_id"] = running.ID
+		details["running_for"] = now.Sub(running.StartedAt).Round(time.Minute).String()
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component":        "scheduler",
+		"node":             nodeName,
+		"snapshot_age":     age.Round(time.Minute).String(),
+		"max_snapshot_age": maxAge.String(),
+	}).Warn(message)
+
+	j.sendNotification(ctx, nodeName, notification.EventStale, message, details)
+
+	return nil
+}
+
+// sendNotification sends a stale notification using the node's effective config
+func (j *FreshnessJob) sendNotification(ctx context.Context, nodeName string, event notification.NotificationEvent, message string, details map[string]interface{}) {
+	if j.notifyRegistry == nil {
+		return
+	}
+
+	nodeConfig, _ := j.nodeConfigs.get(nodeName)
+	notifyConfig := nodeConfig.Notifications
+	if notifyConfig == nil {
+		notifyConfig = j.globalNotifyCfg
+	}
+	if notifyConfig == nil || !notifyConfig.Stale {
+		return
+	}
+
+	payload := notification.NotificationPayload{
+		Event:     event,
+		NodeName:  nodeName,
+		Timestamp: time.Now(),
+		Message:   message,
+		Details:   details,
+		Metadata:  nodeConfig.Metadata,
+	}
+
+	for notificationType, typeConfig := range notifyConfig.Types {
+		notificationModule, err := j.notifyRegistry.Get(notificationType)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+			}).Warn("Notification module not found")
+			continue
+		}
+
+		rendered := RenderNotification(notifyConfig, notificationType, payload, j.logger)
+		if err := DeliverNotification(ctx, j.db, j.logger, notificationModule, typeConfig.URL, rendered); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Error("Failed to send notification")
+		}
+	}
+}
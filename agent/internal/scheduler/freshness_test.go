@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+func TestFreshnessJob(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC)
+	completedAt := now.Add(-50 * time.Hour)
+	latest := map[string]*database.Upload{
+		"stale-node": {ID: 7, NodeName: "stale-node", CompletedAt: &completedAt},
+		"fresh-node": {ID: 8, NodeName: "fresh-node", CompletedAt: &completedAt},
+	}
+
+	db := &mockDatabase{
+		getLatestCompletedUploadForNodeFunc: func(ctx context.Context, nodeName string) (*database.Upload, error) {
+			return latest[nodeName], nil
+		},
+	}
+
+	var mu sync.Mutex
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Stale: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"stale-node": {Protocol: "ethereum", Schedule: "0 0 0 * * *"},
+		"fresh-node": {Protocol: "ethereum", Schedule: "0 0 0 * * *"},
+		"new-node":   {Protocol: "ethereum", Schedule: "0 0 0 * * *"},
+		"unchecked":  {Protocol: "ethereum", Schedule: "0 0 0 * * *"},
+	}
+	maxAges := map[string]time.Duration{
+		"stale-node": 48 * time.Hour,
+		"fresh-node": 72 * time.Hour,
+		"new-node":   48 * time.Hour,
+	}
+
+	job := NewFreshnessJob(db, notifyRegistry, notifyConfig, nodes, maxAges, logger)
+	job.now = func() time.Time { return now }
+	job.startedAt = now.Add(-time.Hour)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := job.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(sent))
+	}
+	if sent[0].Event != notification.EventStale || sent[0].NodeName != "stale-node" {
+		t.Errorf("Expected stale notification for stale-node, got %v for %s", sent[0].Event, sent[0].NodeName)
+	}
+	if sent[0].Details["snapshot_age"] != "50h0m0s" || sent[0].Details["upload_id"] != int64(7) {
+		t.Errorf("Unexpected notification details: %v", sent[0].Details)
+	}
+
+	// A new snapshot clears the alert, and the node alerts again once it goes stale
+	refreshed := now.Add(-time.Hour)
+	latest["stale-node"] = &database.Upload{ID: 9, NodeName: "stale-node", CompletedAt: &refreshed}
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	job.now = func() time.Time { return now.Add(48 * time.Hour) }
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The never-uploaded node is now measured from the daemon's start and alerts as well
+	if len(sent) != 4 {
+		t.Fatalf("Expected 4 notifications, got %d", len(sent))
+	}
+	alerted := make(map[string]bool)
+	for _, payload := range sent[1:] {
+		alerted[payload.NodeName] = true
+	}
+	if !alerted["stale-node"] || !alerted["fresh-node"] || !alerted["new-node"] {
+		t.Errorf("Expected stale-node, fresh-node and new-node alerts, got %v", alerted)
+	}
+}
@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/hoststats"
+	"github.com/sirupsen/logrus"
+)
+
+// HostSampler measures the host's resource use since its previous sample, reporting false
+// when there is no previous sample yet
+type HostSampler interface {
+	Sample() (hoststats.Usage, bool, error)
+}
+
+// ThrottleStore is the database the guardrail job reads running uploads from and records
+// its actions in
+type ThrottleStore interface {
+	GetRunningUploads(ctx context.Context) ([]database.Upload, error)
+	CreateThrottleEvent(ctx context.Context, event database.ThrottleEvent) (int64, error)
+	EndThrottleEvent(ctx context.Context, id int64, endedAt time.Time) error
+	GetOpenThrottleEvents(ctx context.Context) ([]database.ThrottleEvent, error)
+	NodeActionRecorder
+}
+
+// GuardrailJob samples the host's CPU, memory and disk I/O while uploads run. When usage
+// crosses a threshold it throttles or pauses each running upload with the configured
+// command and records the event on the upload; once usage has stayed below every
+// threshold for the configured number of samples, it resumes them. An upload that ends
+// while throttled is resumed as well, so a host-wide throttle is never left behind.
+type GuardrailJob struct {
+	cfg           *config.GuardrailsConfig
+	sampler       HostSampler
+	store         ThrottleStore
+	uploadManager UploadManager
+	logger        *logrus.Logger
+	now           func() time.Time
+
+	mu        sync.Mutex
+	loaded    bool                             // Events left open by a previous run of the daemon were loaded
+	active    map[int64]database.ThrottleEvent // Upload ID -> the guardrail action in effect
+	recovered int                              // Consecutive samples below every threshold
+}
+
+// NewGuardrailJob creates a host resource guardrail job
+func NewGuardrailJob(cfg *config.GuardrailsConfig, sampler HostSampler, store ThrottleStore, uploadManager UploadManager, logger *logrus.Logger) *GuardrailJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &GuardrailJob{
+		cfg:           cfg,
+		sampler:       sampler,
+		store:         store,
+		uploadManager: uploadManager,
+		logger:        logger,
+		now:           time.Now,
+		active:        make(map[int64]database.ThrottleEvent),
+	}
+}
+
+// Run samples host usage and applies or lifts the guardrail action
+func (j *GuardrailJob) Run(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Uploads throttled before a restart still need resuming
+	if !j.loaded {
+		open, err := j.store.GetOpenThrottleEvents(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load throttle events: %w", err)
+		}
+		for _, event := range open {
+			j.active[event.UploadID] = event
+		}
+		j.loaded = true
+	}
+
+	usage, ok, err := j.sampler.Sample()
+	if err != nil {
+		return fmt.Errorf("failed to sample host usage: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	uploads, err := j.store.GetRunningUploads(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get running uploads: %w", err)
+	}
+	running := make(map[int64]bool, len(uploads))
+	for _, u := range uploads {
+		running[u.ID] = true
+	}
+
+	now := j.now()
+	for uploadID, event := range j.active {
+		if !running[uploadID] && j.resume(ctx, event, now, "upload ended") {
+			delete(j.active, uploadID)
+		}
+	}
+
+	exceeded := j.exceeded(usage)
+	if len(exceeded) > 0 {
+		j.recovered = 0
+		reason := strings.Join(exceeded, ", ")
+		for _, u := range uploads {
+			if _, throttled := j.active[u.ID]; !throttled {
+				j.apply(ctx, u, usage, reason, now)
+			}
+		}
+		return nil
+	}
+
+	if len(j.active) == 0 {
+		return nil
+	}
+	j.recovered++
+	if j.recovered < j.cfg.GetRecoveryChecks() {
+		return nil
+	}
+	for uploadID, event := range j.active {
+		if j.resume(ctx, event, now, "host usage recovered") {
+			delete(j.active, uploadID)
+		}
+	}
+
+	return nil
+}
+
+// exceeded describes each threshold the usage crosses, e.g. "cpu 93.2% > 90%"
+func (j *GuardrailJob) exceeded(usage hoststats.Usage) []string {
+	var exceeded []string
+	if j.cfg.MaxCPUPercent > 0 && usage.CPUPercent > j.cfg.MaxCPUPercent {
+		exceeded = append(exceeded, fmt.Sprintf("cpu %.1f%% > %g%%", usage.CPUPercent, j.cfg.MaxCPUPercent))
+	}
+	if j.cfg.MaxMemoryPercent > 0 && usage.MemoryPercent > j.cfg.MaxMemoryPercent {
+		exceeded = append(exceeded, fmt.Sprintf("memory %.1f%% > %g%%", usage.MemoryPercent, j.cfg.MaxMemoryPercent))
+	}
+	if j.cfg.MaxDiskIOPercent > 0 && usage.DiskIOPercent > j.cfg.MaxDiskIOPercent {
+		exceeded = append(exceeded, fmt.Sprintf("disk_io %.1f%% > %g%% (%s)", usage.DiskIOPercent, j.cfg.MaxDiskIOPercent, usage.DiskDevice))
+	}
+	return exceeded
+}
+
+// apply throttles or pauses an upload and records the event. When the command fails the
+// upload is left alone and tried again on the next sample.
+func (j *GuardrailJob) apply(ctx context.Context, u database.Upload, usage hoststats.Usage, reason string, now time.Time) {
+	action := j.cfg.GetAction()
+	fields := logrus.Fields{
+		"component":       "scheduler",
+		"node":            u.NodeName,
+		"upload_id":       u.ID,
+		"action":          action,
+		"reason":          reason,
+		"cpu_percent":     usage.CPUPercent,
+		"memory_percent":  usage.MemoryPercent,
+		"disk_io_percent": usage.DiskIOPercent,
+	}
+
+	if action != config.GuardrailActionRecord {
+		if err := j.runCommand(ctx, j.cfg.Command, u.NodeName, u.ID, action, reason); err != nil {
+			fields["error"] = err.Error()
+			j.logger.WithFields(fields).Error("Failed to apply guardrail action")
+			return
+		}
+	}
+
+	event := database.ThrottleEvent{
+		UploadID:      u.ID,
+		NodeName:      u.NodeName,
+		Action:        action,
+		Reason:        reason,
+		CPUPercent:    &usage.CPUPercent,
+		MemoryPercent: &usage.MemoryPercent,
+		DiskIOPercent: &usage.DiskIOPercent,
+		StartedAt:     now,
+	}
+	id, err := j.store.CreateThrottleEvent(ctx, event)
+	if err != nil {
+		// The action is in effect either way, so it is still resumed
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"upload_id": u.ID,
+			"error":     err.Error(),
+		}).Warn("Failed to record throttle event")
+	}
+	event.ID = id
+	j.active[u.ID] = event
+
+	recordNodeAction(ctx, j.store, j.logger, database.NodeAction{
+		NodeName: u.NodeName,
+		UploadID: &u.ID,
+		Action:   database.NodeActionGuardrailApplied,
+		Message:  fmt.Sprintf("Guardrail %s: %s", action, reason),
+		Details: database.JSONB{
+			"action":          action,
+			"cpu_percent":     usage.CPUPercent,
+			"memory_percent":  usage.MemoryPercent,
+			"disk_io_percent": usage.DiskIOPercent,
+		},
+		CreatedAt: now,
+	})
+
+	j.logger.WithFields(fields).Warn("Host resource guardrail exceeded")
+}
+
+// resume undoes an upload's guardrail action and ends its event, reporting whether it
+// succeeded. A failed resume command is retried on the next sample.
+func (j *GuardrailJob) resume(ctx context.Context, event database.ThrottleEvent, now time.Time, why string) bool {
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      event.NodeName,
+		"upload_id": event.UploadID,
+		"action":    event.Action,
+	}
+
+	if event.Action != config.GuardrailActionRecord && j.cfg.ResumeCommand != "" {
+		if err := j.runCommand(ctx, j.cfg.ResumeCommand, event.NodeName, event.UploadID, event.Action, why); err != nil {
+			fields["error"] = err.Error()
+			j.logger.WithFields(fields).Error("Failed to resume upload after guardrail action")
+			return false
+		}
+	}
+
+	if event.ID != 0 {
+		if err := j.store.EndThrottleEvent(ctx, event.ID, now); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"upload_id": event.UploadID,
+				"error":     err.Error(),
+			}).Warn("Failed to record end of throttle event")
+		}
+	}
+
+	duration := now.Sub(event.StartedAt).Round(time.Second).String()
+	recordNodeAction(ctx, j.store, j.logger, database.NodeAction{
+		NodeName:  event.NodeName,
+		UploadID:  &event.UploadID,
+		Action:    database.NodeActionGuardrailLifted,
+		Message:   fmt.Sprintf("Guardrail %s lifted: %s", event.Action, why),
+		Details:   database.JSONB{"action": event.Action, "duration": duration},
+		CreatedAt: now,
+	})
+
+	fields["duration"] = duration
+	j.logger.WithFields(fields).Infof("Guardrail action lifted: %s", why)
+	return true
+}
+
+// runCommand runs a guardrail command for an upload with the configured timeout
+func (j *GuardrailJob) runCommand(ctx context.Context, command, nodeName string, uploadID int64, action, reason string) error {
+	cmdCtx, cancel := context.WithTimeout(ctx, j.cfg.GetCommandTimeout())
+	defer cancel()
+
+	return j.uploadManager.RunHook(cmdCtx, nodeName, command, map[string]string{
+		"NODE_NAME":        nodeName,
+		"UPLOAD_ID":        strconv.FormatInt(uploadID, 10),
+		"GUARDRAIL_ACTION": action,
+		"GUARDRAIL_REASON": reason,
+	})
+}
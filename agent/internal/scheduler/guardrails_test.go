@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/hoststats"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeSampler returns queued usage samples, the first reporting no interval
+type fakeSampler struct {
+	samples []hoststats.Usage
+	started bool
+}
+
+func (s *fakeSampler) Sample() (hoststats.Usage, bool, error) {
+	if !s.started {
+		s.started = true
+		return hoststats.Usage{}, false, nil
+	}
+	if len(s.samples) == 0 {
+		return hoststats.Usage{}, false, errors.New("no sample")
+	}
+	usage := s.samples[0]
+	s.samples = s.samples[1:]
+	return usage, true, nil
+}
+
+// mockThrottleStore keeps throttle events in memory
+type mockThrottleStore struct {
+	running []database.Upload
+	events  []database.ThrottleEvent
+	actions []database.NodeAction
+}
+
+func (s *mockThrottleStore) RecordNodeAction(ctx context.Context, action database.NodeAction) error {
+	s.actions = append(s.actions, action)
+	return nil
+}
+
+func (s *mockThrottleStore) GetRunningUploads(ctx context.Context) ([]database.Upload, error) {
+	return s.running, nil
+}
+
+func (s *mockThrottleStore) CreateThrottleEvent(ctx context.Context, event database.ThrottleEvent) (int64, error) {
+	event.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+	return event.ID, nil
+}
+
+func (s *mockThrottleStore) EndThrottleEvent(ctx context.Context, id int64, endedAt time.Time) error {
+	s.events[id-1].EndedAt = &endedAt
+	return nil
+}
+
+func (s *mockThrottleStore) GetOpenThrottleEvents(ctx context.Context) ([]database.ThrottleEvent, error) {
+	var open []database.ThrottleEvent
+	for _, event := range s.events {
+		if event.EndedAt == nil {
+			open = append(open, event)
+		}
+	}
+	return open, nil
+}
+
+func TestGuardrailJob(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	high := hoststats.Usage{CPUPercent: 95, MemoryPercent: 40, DiskIOPercent: 85, DiskDevice: "nvme0n1"}
+	low := hoststats.Usage{CPUPercent: 20, MemoryPercent: 40, DiskIOPercent: 10, DiskDevice: "nvme0n1"}
+	sampler := &fakeSampler{samples: []hoststats.Usage{high, high, low, low, high}}
+	store := &mockThrottleStore{running: []database.Upload{{ID: 1, NodeName: "eth-1"}, {ID: 2, NodeName: "eth-2"}}}
+
+	var commands []string
+	uploadManager := &mockUploadManager{
+		runHookFunc: func(ctx context.Context, nodeName string, command string, env map[string]string) error {
+			if env["NODE_NAME"] != nodeName || env["GUARDRAIL_ACTION"] != "pause" {
+				t.Errorf("unexpected command environment: %v", env)
+			}
+			commands = append(commands, command+" "+env["UPLOAD_ID"])
+			return nil
+		},
+	}
+
+	cfg := &config.GuardrailsConfig{MaxCPUPercent: 90, MaxDiskIOPercent: 80, Action: "pause", Command: "pause", ResumeCommand: "resume"}
+	job := NewGuardrailJob(cfg, sampler, store, uploadManager, logger)
+
+	ctx := context.Background()
+	run := func() {
+		t.Helper()
+		if err := job.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	// The first sample has no interval; the second pauses both uploads once
+	run()
+	run()
+	run()
+	if len(commands) != 2 || commands[0] != "pause 1" || commands[1] != "pause 2" {
+		t.Fatalf("expected each upload paused once, got %v", commands)
+	}
+	if len(store.events) != 2 || store.events[0].Reason != "cpu 95.0% > 90%, disk_io 85.0% > 80% (nvme0n1)" {
+		t.Fatalf("expected 2 recorded events, got %+v", store.events)
+	}
+
+	// Usage must stay low for two samples before the uploads resume
+	run()
+	if len(commands) != 2 {
+		t.Fatalf("expected no resume after one low sample, got %v", commands)
+	}
+	run()
+	if len(commands) != 4 || store.events[0].EndedAt == nil || store.events[1].EndedAt == nil {
+		t.Fatalf("expected both uploads resumed and their events ended, got %v, %+v", commands, store.events)
+	}
+	if len(store.actions) != 4 || store.actions[0].Action != database.NodeActionGuardrailApplied || store.actions[3].Action != database.NodeActionGuardrailLifted {
+		t.Fatalf("expected each pause and resume in the nodes' action logs, got %+v", store.actions)
+	}
+
+	// A restarted daemon resumes uploads throttled before the restart, and an upload
+	// that ended while throttled is resumed too
+	run()
+	if len(store.events) != 4 {
+		t.Fatalf("expected both uploads paused again, got %+v", store.events)
+	}
+	store.running = store.running[:1]
+	restarted := NewGuardrailJob(cfg, &fakeSampler{samples: []hoststats.Usage{high}}, store, uploadManager, logger)
+	commands = nil
+	if err := restarted.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := restarted.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(commands) != 1 || commands[0] != "resume 2" || store.events[3].EndedAt == nil || store.events[2].EndedAt != nil {
+		t.Errorf("expected only the ended upload resumed, got %v, %+v", commands, store.events)
+	}
+}
+
+func TestGuardrailJob_FailedCommand(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	high := hoststats.Usage{MemoryPercent: 97}
+	store := &mockThrottleStore{running: []database.Upload{{ID: 1, NodeName: "eth-1"}}}
+	attempts := 0
+	uploadManager := &mockUploadManager{
+		runHookFunc: func(ctx context.Context, nodeName string, command string, env map[string]string) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+	}
+
+	cfg := &config.GuardrailsConfig{MaxMemoryPercent: 90, Action: "throttle", Command: "throttle"}
+	job := NewGuardrailJob(cfg, &fakeSampler{samples: []hoststats.Usage{high, high}}, store, uploadManager, logger)
+
+	// A failed throttle is not recorded and is tried again on the next sample
+	for i := 0; i < 3; i++ {
+		if err := job.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+	if attempts != 2 || len(store.events) != 1 || store.events[0].Action != "throttle" {
+		t.Errorf("expected the second attempt recorded, got %d attempts, %+v", attempts, store.events)
+	}
+}
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// Upload hook stages
+const (
+	HookPreUpload  = "pre_upload"  // Before the upload starts
+	HookPostUpload = "post_upload" // After the upload ends, or when it could not be started
+)
+
+// RunUploadHooks runs a node's hook commands for a stage, each with the configured
+// timeout. pre_upload commands stop at the first failure, since the upload must not start
+// from a half-prepared node; post_upload commands all run so cleanup is never skipped.
+// uploadID (0 when no upload was started) and status are passed to post_upload commands.
+func RunUploadHooks(ctx context.Context, uploadManager UploadManager, nodeName string, nodeConfig config.NodeConfig, stage string, uploadID int64, status string) error {
+	hooks := nodeConfig.Hooks
+	if hooks == nil {
+		return nil
+	}
+	commands := hooks.PreUpload
+	if stage == HookPostUpload {
+		commands = hooks.PostUpload
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	env := map[string]string{
+		"NODE_NAME":  nodeName,
+		"PROTOCOL":   nodeConfig.Protocol,
+		"HOOK_STAGE": stage,
+	}
+	if uploadID > 0 {
+		env["UPLOAD_ID"] = strconv.FormatInt(uploadID, 10)
+	}
+	if status != "" {
+		env["UPLOAD_STATUS"] = status
+	}
+
+	var failed []string
+	for _, command := range commands {
+		hookCtx, cancel := context.WithTimeout(ctx, hooks.GetTimeout())
+		err := uploadManager.RunHook(hookCtx, nodeName, command, env)
+		cancel()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%q: %v", command, err))
+			if stage == HookPreUpload {
+				break
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s hook failed: %s", stage, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// runPostUploadHooks runs the node's post_upload hooks, reporting failures as failure
+// notifications since a hook that did not undo its pre_upload counterpart needs attention
+func (j *NodeUploadJob) runPostUploadHooks(ctx context.Context, uploadID int64, status string) {
+	if err := RunUploadHooks(ctx, j.uploadManager, j.nodeName, j.nodeConfig, HookPostUpload, uploadID, status); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      j.nodeName,
+			"error":     err.Error(),
+		}).Error("Post-upload hook failed")
+		j.sendNotification(ctx, notification.EventFailure, "Post-upload hook failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// runPostUploadHooks runs the post_upload hooks of a finished upload's node
+func (j *UploadMonitorJob) runPostUploadHooks(ctx context.Context, nodeName string, uploadID int64, status string) {
+	nodeConfig, exists := j.nodeConfigs.get(nodeName)
+	if !exists {
+		return
+	}
+
+	if err := RunUploadHooks(ctx, j.uploadManager, nodeName, nodeConfig, HookPostUpload, uploadID, status); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+			"upload_id": uploadID,
+			"error":     err.Error(),
+		}).Error("Post-upload hook failed")
+		j.sendNotification(ctx, nodeName, notification.EventFailure, "Post-upload hook failed", map[string]interface{}{
+			"upload_id": uploadID,
+			"error":     err.Error(),
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// hookCall is one hook command run by the mock upload manager
+type hookCall struct {
+	command string
+	env     map[string]string
+}
+
+func TestNodeUploadJob_Hooks(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	hooks := &config.HooksConfig{
+		PreUpload:  []string{"pause-compaction", "flush-cache"},
+		PostUpload: []string{"resume-compaction"},
+	}
+
+	tests := []struct {
+		name        string
+		failHook    string // Hook command that fails
+		initiateErr error
+		wantStarted bool
+		wantHooks   []string
+	}{
+		{name: "upload started", wantStarted: true, wantHooks: []string{"pause-compaction", "flush-cache"}},
+		{name: "pre_upload hook fails", failHook: "pause-compaction", wantHooks: []string{"pause-compaction", "resume-compaction"}},
+		{name: "upload fails to start", initiateErr: fmt.Errorf("bv unavailable"), wantHooks: []string{"pause-compaction", "flush-cache", "resume-compaction"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []hookCall
+			started := false
+			uploadManager := &mockUploadManager{
+				initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+					if tt.initiateErr != nil {
+						return 0, tt.initiateErr
+					}
+					started = true
+					return 1, nil
+				},
+				runHookFunc: func(ctx context.Context, nodeName string, command string, env map[string]string) error {
+					calls = append(calls, hookCall{command: command, env: env})
+					if command == tt.failHook {
+						return fmt.Errorf("exit status 1")
+					}
+					return nil
+				},
+			}
+
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+			job := NewNodeUploadJob(
+				"test-node",
+				config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", Hooks: hooks},
+				protocolRegistry,
+				uploadManager,
+				&mockDatabase{},
+				nil,
+				nil,
+				logger,
+			)
+			err := job.Run(context.Background())
+
+			if started != tt.wantStarted || (err == nil) != tt.wantStarted {
+				t.Fatalf("expected upload started=%v, got %v (error %v)", tt.wantStarted, started, err)
+			}
+			var commands []string
+			for _, call := range calls {
+				commands = append(commands, call.command)
+			}
+			if strings.Join(commands, ",") != strings.Join(tt.wantHooks, ",") {
+				t.Errorf("expected hooks %v, got %v", tt.wantHooks, commands)
+			}
+			if calls[0].env["NODE_NAME"] != "test-node" || calls[0].env["HOOK_STAGE"] != HookPreUpload {
+				t.Errorf("unexpected pre_upload environment: %v", calls[0].env)
+			}
+			if last := calls[len(calls)-1]; !tt.wantStarted && last.env["UPLOAD_STATUS"] != "failed" {
+				t.Errorf("expected post_upload hook with failed status, got %v", last.env)
+			}
+		})
+	}
+}
+
+func TestUploadMonitorJob_PostUploadHooks(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	outcomes := map[string]upload.CompletionOutcome{
+		"success-node": upload.OutcomeSuccess,
+		"failure-node": upload.OutcomeFailure,
+		"running-node": upload.OutcomeRunning,
+	}
+
+	var mu sync.Mutex
+	ran := make(map[string]map[string]string)
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: outcomes[nodeName]}, nil
+		},
+		runHookFunc: func(ctx context.Context, nodeName string, command string, env map[string]string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran[nodeName] = env
+			return nil
+		},
+	}
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "success-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "failure-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 3, NodeName: "running-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+	}
+
+	hooks := &config.HooksConfig{PostUpload: []string{"resume-compaction"}}
+	nodes := map[string]config.NodeConfig{
+		"success-node": {Protocol: "ethereum", Hooks: hooks},
+		"failure-node": {Protocol: "ethereum", Hooks: hooks},
+		"running-node": {Protocol: "ethereum", Hooks: hooks},
+	}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), nil, nil, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if env := ran["success-node"]; env["UPLOAD_ID"] != "1" || env["UPLOAD_STATUS"] != "completed" || env["HOOK_STAGE"] != HookPostUpload {
+		t.Errorf("unexpected post_upload environment for success: %v", env)
+	}
+	if env := ran["failure-node"]; env["UPLOAD_ID"] != "2" || env["UPLOAD_STATUS"] != "failed" {
+		t.Errorf("unexpected post_upload environment for failure: %v", env)
+	}
+	if _, ok := ran["running-node"]; ok {
+		t.Error("expected no post_upload hook while the upload is running")
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// MarkInterrupted records that the daemon is stopping while uploads are running, so the
+// next daemon re-attaches monitoring to them as soon as it starts instead of at its first
+// monitor tick, and sends an interrupted notification for each. It returns how many
+// uploads were marked.
+func (j *UploadMonitorJob) MarkInterrupted(ctx context.Context) (int, error) {
+	now := j.now()
+	marked := 0
+	page := database.UploadPage{Limit: j.pageSize}
+	for {
+		runningUploads, err := j.db.ListRunningUploads(ctx, page)
+		if err != nil {
+			return marked, fmt.Errorf("failed to get running uploads: %w", err)
+		}
+
+		for _, u := range runningUploads {
+			if err := j.db.SetUploadInterrupted(ctx, u.ID, &now); err != nil {
+				j.logger.WithFields(logrus.Fields{
+					"component": "scheduler",
+					"node":      u.NodeName,
+					"upload_id": u.ID,
+					"error":     err.Error(),
+				}).Warn("Failed to mark upload interrupted")
+				continue
+			}
+			marked++
+
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      u.NodeName,
+				"upload_id": u.ID,
+			}).Info("Daemon stopping while upload is running, monitoring resumes on restart")
+
+			recordNodeAction(ctx, j.db, j.logger, database.NodeAction{
+				NodeName:  u.NodeName,
+				UploadID:  &u.ID,
+				Action:    database.NodeActionInterrupted,
+				Message:   "The daemon stopped while the upload was running",
+				CreatedAt: now,
+			})
+
+			details := map[string]interface{}{
+				"upload_id":  u.ID,
+				"started_at": u.StartedAt.UTC().Format(time.RFC3339),
+			}
+			if u.ProgressPercent != nil {
+				details["progress_percent"] = *u.ProgressPercent
+			}
+			j.sendNotification(ctx, u.NodeName, notification.EventInterrupted,
+				"The daemon stopped while the upload was running; monitoring resumes when it restarts", details)
+		}
+
+		if len(runningUploads) == 0 || len(runningUploads) < page.Limit {
+			break
+		}
+		page.AfterID = runningUploads[len(runningUploads)-1].ID
+	}
+
+	return marked, nil
+}
+
+// reattachInterrupted clears the marks a stopping daemon left on running uploads, which the
+// rest of the run then monitors
+func (j *UploadMonitorJob) reattachInterrupted(ctx context.Context) {
+	interrupted, err := j.db.ListInterruptedUploads(ctx)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"error":     err.Error(),
+		}).Warn("Failed to get interrupted uploads")
+		return
+	}
+
+	for _, u := range interrupted {
+		if err := j.db.SetUploadInterrupted(ctx, u.ID, nil); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      u.NodeName,
+				"upload_id": u.ID,
+				"error":     err.Error(),
+			}).Warn("Failed to clear upload interrupted mark")
+			continue
+		}
+
+		fields := logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+		}
+		details := database.JSONB{}
+		if u.InterruptedAt != nil {
+			fields["interrupted_at"] = u.InterruptedAt.UTC().Format(time.RFC3339)
+			details["interrupted_at"] = u.InterruptedAt.UTC().Format(time.RFC3339)
+		}
+		j.logger.WithFields(fields).Info("Re-attached monitoring to upload interrupted by a daemon stop")
+
+		recordNodeAction(ctx, j.db, j.logger, database.NodeAction{
+			NodeName: u.NodeName,
+			UploadID: &u.ID,
+			Action:   database.NodeActionReattached,
+			Message:  "Re-attached monitoring to the upload the daemon stopped during",
+			Details:  details,
+		})
+	}
+}
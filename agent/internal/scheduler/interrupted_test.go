@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUploadMonitorJob_MarkInterrupted(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	progress := 42.5
+	var mu sync.Mutex
+	marked := make(map[int64]*time.Time)
+	var actions []database.NodeAction
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "eth-node", Status: "running", StartedAt: time.Now().Add(-time.Hour), ProgressPercent: &progress},
+				{ID: 2, NodeName: "quiet-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		setUploadInterruptedFunc: func(ctx context.Context, uploadID int64, interruptedAt *time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			marked[uploadID] = interruptedAt
+			return nil
+		},
+		recordNodeActionFunc: func(ctx context.Context, action database.NodeAction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actions = append(actions, action)
+			return nil
+		},
+	}
+
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+	types := map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}}
+
+	nodes := map[string]config.NodeConfig{
+		"eth-node":   {Protocol: "ethereum", Notifications: &config.NotificationConfig{Interrupted: true, Types: types}},
+		"quiet-node": {Protocol: "ethereum", Notifications: &config.NotificationConfig{Types: types}},
+	}
+	job := NewUploadMonitorJob(&mockUploadManager{}, db, protocol.NewRegistry(), notifyRegistry, nil, nodes, 0, logger)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	count, err := job.MarkInterrupted(context.Background())
+	if err != nil {
+		t.Fatalf("MarkInterrupted failed: %v", err)
+	}
+
+	if count != 2 || len(marked) != 2 || marked[1] == nil || !marked[1].Equal(now) || marked[2] == nil {
+		t.Errorf("Expected both running uploads marked at %v, got %d: %v", now, count, marked)
+	}
+	if len(actions) != 2 || actions[0].Action != database.NodeActionInterrupted || actions[0].UploadID == nil || *actions[0].UploadID != 1 {
+		t.Errorf("Expected the interruptions in the nodes' action logs, got %+v", actions)
+	}
+	// Only the node with interrupted notifications enabled is notified
+	if len(sent) != 1 || sent[0].Event != notification.EventInterrupted || sent[0].NodeName != "eth-node" {
+		t.Fatalf("Expected one interrupted notification for eth-node, got %+v", sent)
+	}
+	if sent[0].Details["upload_id"] != int64(1) || sent[0].Details["progress_percent"] != progress {
+		t.Errorf("Expected the upload and its progress in the notification, got %v", sent[0].Details)
+	}
+}
+
+func TestUploadMonitorJob_ReattachesInterruptedUploads(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	interruptedAt := time.Now().Add(-5 * time.Minute).UTC()
+	var mu sync.Mutex
+	var monitored []int64
+	var events []string
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			monitored = append(monitored, uploadID)
+			events = append(events, "monitor")
+			return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil
+		},
+	}
+
+	cleared := make(map[int64]bool)
+	var actions []database.NodeAction
+	running := []database.Upload{
+		{ID: 1, NodeName: "eth-node", Status: "running", StartedAt: time.Now().Add(-time.Hour), InterruptedAt: &interruptedAt},
+	}
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return running, nil
+		},
+		listInterruptedUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return running, nil
+		},
+		setUploadInterruptedFunc: func(ctx context.Context, uploadID int64, at *time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			cleared[uploadID] = at == nil
+			events = append(events, "clear")
+			return nil
+		},
+		recordNodeActionFunc: func(ctx context.Context, action database.NodeAction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actions = append(actions, action)
+			return nil
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{"eth-node": {Protocol: "ethereum"}}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notification.NewRegistry(), nil, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if !cleared[1] {
+		t.Errorf("Expected the interrupted mark of upload 1 cleared, got %v", cleared)
+	}
+	if len(monitored) != 1 || monitored[0] != 1 || len(events) != 2 || events[0] != "clear" {
+		t.Errorf("Expected upload 1 re-attached and then monitored, got %v", events)
+	}
+	if len(actions) != 1 || actions[0].Action != database.NodeActionReattached || actions[0].Details["interrupted_at"] != interruptedAt.Format(time.RFC3339) {
+		t.Errorf("Expected the re-attach in the node's action log, got %+v", actions)
+	}
+}
@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Results recorded as a job's last_result in the job state
+const (
+	JobResultRunning   = "running"
+	JobResultSucceeded = "succeeded"
+	JobResultFailed    = "failed"
+	JobResultPanicked  = "panicked"
+)
+
+// JobStateStore persists what the scheduler last did with each named job, so the CLI,
+// the summary API and the metrics endpoint can show it
+type JobStateStore interface {
+	SaveJobSchedule(ctx context.Context, host, jobName, schedule string, nextRunAt *time.Time) error
+	RecordJobRun(ctx context.Context, state database.JobState) error
+	DeleteJobState(ctx context.Context, host, jobName string) error
+	GetJobStates(ctx context.Context, host string) ([]database.JobState, error)
+}
+
+// namedJob is a job whose runs are recorded in the job state under its name
+type namedJob struct {
+	name string
+	job  Job
+}
+
+// Named names a job so the scheduler records its last run, last result and next run.
+// Unnamed jobs run the same but are not recorded.
+func Named(name string, job Job) Job {
+	return &namedJob{name: name, job: job}
+}
+
+// Run runs the named job
+func (j *namedJob) Run(ctx context.Context) error {
+	return j.job.Run(ctx)
+}
+
+// NodeJobName is the name of a node's upload job in the job state
+func NodeJobName(nodeName string) string {
+	return "node_upload/" + nodeName
+}
+
+// ConsistencyGroupJobName is the name of a consistency group's job in the job state
+func ConsistencyGroupJobName(groupName string) string {
+	return "consistency_group/" + groupName
+}
+
+// jobName returns the name given to a job with Named, or "" for an unnamed job
+func jobName(job Job) string {
+	if named, ok := job.(*namedJob); ok {
+		return named.name
+	}
+	return ""
+}
+
+// scheduledJob is a named job's cron entry
+type scheduledJob struct {
+	entry    cron.EntryID
+	schedule string
+}
+
+// SetJobStateStore records the runs of named jobs in store as the jobs of the daemon on
+// host. Call it before adding jobs.
+func (s *CronScheduler) SetJobStateStore(store JobStateStore, host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store = store
+	s.host = host
+}
+
+// nextRun returns when a named job's cron entry fires next, or nil if it is not scheduled.
+// The caller holds s.mu.
+func (s *CronScheduler) nextRun(name string) *time.Time {
+	scheduled, ok := s.named[name]
+	if !ok {
+		return nil
+	}
+	entry := s.cron.Entry(scheduled.entry)
+	if !entry.Valid() {
+		return nil
+	}
+	next := entry.Schedule.Next(s.now().In(s.loc))
+	return &next
+}
+
+// saveJobSchedule records a named job's schedule and next run
+func (s *CronScheduler) saveJobSchedule(name string) {
+	s.mu.Lock()
+	store, host := s.store, s.host
+	scheduled, ok := s.named[name]
+	nextRunAt := s.nextRun(name)
+	s.mu.Unlock()
+	if store == nil || !ok {
+		return
+	}
+
+	if err := store.SaveJobSchedule(context.Background(), host, name, scheduled.schedule, nextRunAt); err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       name,
+			"error":     err.Error(),
+		}).Warn("Failed to save job schedule")
+	}
+}
+
+// startJobStates records the schedule and next run of every named job, and forgets the
+// jobs recorded for this daemon that it no longer schedules, such as nodes removed from
+// the configuration
+func (s *CronScheduler) startJobStates() {
+	s.mu.Lock()
+	store, host := s.store, s.host
+	names := make(map[string]bool, len(s.named))
+	for name := range s.named {
+		names[name] = true
+	}
+	s.mu.Unlock()
+	if store == nil {
+		return
+	}
+
+	for name := range names {
+		s.saveJobSchedule(name)
+	}
+
+	ctx := context.Background()
+	states, err := store.GetJobStates(ctx, host)
+	if err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"error":     err.Error(),
+		}).Warn("Failed to get job states")
+		return
+	}
+	for _, state := range states {
+		if names[state.JobName] {
+			continue
+		}
+		s.forgetJobState(state.JobName)
+	}
+}
+
+// forgetJobState deletes the job state of a job no longer scheduled
+func (s *CronScheduler) forgetJobState(name string) {
+	s.mu.Lock()
+	store, host := s.store, s.host
+	s.mu.Unlock()
+	if store == nil {
+		return
+	}
+
+	if err := store.DeleteJobState(context.Background(), host, name); err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       name,
+			"error":     err.Error(),
+		}).Warn("Failed to delete job state")
+	}
+}
+
+// recordJobRun records a named job's run as started, when duration is nil, or as finished
+// with result. Runs of jobs no longer scheduled are not recorded.
+func (s *CronScheduler) recordJobRun(job Job, startedAt time.Time, result, message string, duration *time.Duration) {
+	name := jobName(job)
+	if name == "" {
+		return
+	}
+
+	s.mu.Lock()
+	store := s.store
+	scheduled, ok := s.named[name]
+	state := database.JobState{
+		Host:       s.host,
+		JobName:    name,
+		Schedule:   scheduled.schedule,
+		LastRunAt:  &startedAt,
+		LastResult: &result,
+		NextRunAt:  s.nextRun(name),
+	}
+	s.mu.Unlock()
+	if store == nil || !ok {
+		return
+	}
+
+	if message != "" {
+		state.LastError = &message
+	}
+	if duration != nil {
+		ms := duration.Milliseconds()
+		state.LastDurationMs = &ms
+	}
+
+	if err := store.RecordJobRun(context.Background(), state); err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       name,
+			"error":     err.Error(),
+		}).Warn("Failed to record job run")
+	}
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// jobStateMemory is an in-memory job_states table
+type jobStateMemory struct {
+	mu     sync.Mutex
+	states map[string]database.JobState // host/job name -> state
+	runs   []database.JobState          // Every recorded run, in order
+}
+
+func newJobStateMemory() *jobStateMemory {
+	return &jobStateMemory{states: make(map[string]database.JobState)}
+}
+
+func (m *jobStateMemory) SaveJobSchedule(ctx context.Context, host, jobName, schedule string, nextRunAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.states[host+"/"+jobName]
+	state.Host, state.JobName, state.Schedule, state.NextRunAt = host, jobName, schedule, nextRunAt
+	m.states[host+"/"+jobName] = state
+	return nil
+}
+
+func (m *jobStateMemory) RecordJobRun(ctx context.Context, state database.JobState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[state.Host+"/"+state.JobName] = state
+	m.runs = append(m.runs, state)
+	return nil
+}
+
+func (m *jobStateMemory) DeleteJobState(ctx context.Context, host, jobName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, host+"/"+jobName)
+	return nil
+}
+
+func (m *jobStateMemory) GetJobStates(ctx context.Context, host string) ([]database.JobState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var states []database.JobState
+	for _, state := range m.states {
+		if state.Host == host {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+func TestCronScheduler_RecordsNamedJobRuns(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	memory := newJobStateMemory()
+	memory.states["snap-1/removed_job"] = database.JobState{Host: "snap-1", JobName: "removed_job"}
+	memory.states["snap-2/upload_monitor"] = database.JobState{Host: "snap-2", JobName: "upload_monitor"}
+
+	s := NewCronScheduler(logger)
+	s.now = func() time.Time { return now }
+	s.SetJobStateStore(memory, "snap-1")
+
+	runErr := errors.New("database is locked")
+	failing := Named("upload_monitor", &mockJob{runFunc: func(ctx context.Context) error { return runErr }})
+	if err := s.AddJob("0 0 0 1 1 *", failing); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	if err := s.AddJob("@every 1s", &mockJob{}); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	s.Start()
+	defer s.Stop(context.Background())
+
+	// Starting records the next run of named jobs and forgets this host's removed jobs
+	wantNext := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	state, ok := memory.states["snap-1/upload_monitor"]
+	if !ok || state.Schedule != "0 0 0 1 1 *" || state.NextRunAt == nil || !state.NextRunAt.Equal(wantNext) {
+		t.Fatalf("expected the schedule and next run recorded, got %+v", state)
+	}
+	if _, ok := memory.states["snap-1/removed_job"]; ok {
+		t.Error("expected the removed job to be forgotten")
+	}
+	if _, ok := memory.states["snap-2/upload_monitor"]; !ok {
+		t.Error("expected another host's job to be kept")
+	}
+	if len(memory.states) != 2 {
+		t.Errorf("expected unnamed jobs not to be recorded, got %+v", memory.states)
+	}
+
+	// A run is recorded as running when it starts, then with its outcome
+	s.wrap(failing)()
+	if len(memory.runs) != 2 || *memory.runs[0].LastResult != JobResultRunning || memory.runs[0].LastDurationMs != nil {
+		t.Fatalf("expected the run recorded as running first, got %+v", memory.runs)
+	}
+	state = memory.states["snap-1/upload_monitor"]
+	if *state.LastResult != JobResultFailed || *state.LastError != runErr.Error() || state.LastDurationMs == nil ||
+		!state.LastRunAt.Equal(now) || !state.NextRunAt.Equal(wantNext) {
+		t.Errorf("expected the failed run recorded, got %+v", state)
+	}
+
+	panicking := Named("node_upload/eth-node", &mockJob{runFunc: func(ctx context.Context) error { panic("boom") }})
+	id, err := s.ScheduleJob("0 0 0 1 1 *", 0, panicking)
+	if err != nil {
+		t.Fatalf("Failed to schedule job: %v", err)
+	}
+	s.wrap(panicking)()
+	state = memory.states["snap-1/node_upload/eth-node"]
+	if state.LastResult == nil || *state.LastResult != JobResultPanicked || *state.LastError != "boom" {
+		t.Errorf("expected the panic recorded, got %+v", state)
+	}
+
+	// Removing a job forgets it
+	s.RemoveJob(id)
+	if _, ok := memory.states["snap-1/node_upload/eth-node"]; ok {
+		t.Error("expected the removed job to be forgotten")
+	}
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Leader reports whether this daemon currently runs uploads
+type Leader interface {
+	IsLeader() bool
+}
+
+// LeaderLock is a held leader lock
+type LeaderLock interface {
+	// Check returns an error when the lock may have been lost
+	Check(ctx context.Context) error
+	// Release gives up the lock
+	Release() error
+}
+
+// LeaderLocker takes the named leader lock without waiting. It returns a nil lock when
+// another daemon holds it.
+type LeaderLocker interface {
+	TryAcquireLeaderLock(ctx context.Context, name string) (LeaderLock, error)
+}
+
+// LeaderElection elects one of several daemons sharing a database as the leader. The
+// leader holds the lock until it stops or its lock is lost; standbys retry on every
+// interval, so one takes over shortly after the leader dies.
+type LeaderElection struct {
+	locker   LeaderLocker
+	name     string
+	interval time.Duration
+	logger   *logrus.Logger
+
+	mu   sync.RWMutex
+	lock LeaderLock // Held lock, nil while standing by
+}
+
+// NewLeaderElection creates an election for the named lock, checked every interval
+func NewLeaderElection(locker LeaderLocker, name string, interval time.Duration, logger *logrus.Logger) *LeaderElection {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &LeaderElection{
+		locker:   locker,
+		name:     name,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// IsLeader reports whether this daemon holds the leader lock
+func (e *LeaderElection) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lock != nil
+}
+
+// Run checks leadership every interval until ctx is done. Call Poll first to settle
+// leadership before jobs start; the lock is kept when Run returns so the daemon can
+// finish its jobs and Resign.
+func (e *LeaderElection) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Poll(ctx)
+		}
+	}
+}
+
+// Poll verifies the held lock, or tries to take it while standing by
+func (e *LeaderElection) Poll(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lock != nil {
+		err := e.lock.Check(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		e.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"lock":      e.name,
+			"error":     err.Error(),
+		}).Warn("Lost leadership, standing by")
+		_ = e.lock.Release()
+		e.lock = nil
+		return
+	}
+
+	lock, err := e.locker.TryAcquireLeaderLock(ctx, e.name)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"lock":      e.name,
+				"error":     err.Error(),
+			}).Warn("Failed to acquire leader lock")
+		}
+		return
+	}
+	if lock == nil {
+		return
+	}
+
+	e.lock = lock
+	e.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"lock":      e.name,
+	}).Info("Acquired leadership, running uploads")
+}
+
+// Resign releases the leader lock, letting a standby take over
+func (e *LeaderElection) Resign() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lock == nil {
+		return nil
+	}
+
+	err := e.lock.Release()
+	e.lock = nil
+	e.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"lock":      e.name,
+	}).Info("Released leadership")
+	return err
+}
+
+// leaderOnlyJob runs a job only while this daemon is the leader
+type leaderOnlyJob struct {
+	job    Job
+	leader Leader
+}
+
+// LeaderOnly wraps job so it is skipped while this daemon is standing by
+func LeaderOnly(job Job, leader Leader) Job {
+	return &leaderOnlyJob{job: job, leader: leader}
+}
+
+// Run runs the wrapped job if this daemon is the leader
+func (j *leaderOnlyJob) Run(ctx context.Context) error {
+	if !j.leader.IsLeader() {
+		return nil
+	}
+	return j.job.Run(ctx)
+}
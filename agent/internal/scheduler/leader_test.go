@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// mockLeaderLocker is a lock shared by the daemons of a test
+type mockLeaderLocker struct {
+	mu     sync.Mutex
+	holder *mockLeaderLock
+}
+
+type mockLeaderLock struct {
+	locker   *mockLeaderLocker
+	checkErr error
+}
+
+func (m *mockLeaderLocker) TryAcquireLeaderLock(ctx context.Context, name string) (LeaderLock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder != nil {
+		return nil, nil
+	}
+	m.holder = &mockLeaderLock{locker: m}
+	return m.holder, nil
+}
+
+func (l *mockLeaderLock) Check(ctx context.Context) error {
+	return l.checkErr
+}
+
+func (l *mockLeaderLock) Release() error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	if l.locker.holder == l {
+		l.locker.holder = nil
+	}
+	return nil
+}
+
+// staticLeader reports a fixed leadership
+type staticLeader bool
+
+func (s staticLeader) IsLeader() bool { return bool(s) }
+
+func TestLeaderElection_Failover(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	ctx := context.Background()
+
+	locker := &mockLeaderLocker{}
+	a := NewLeaderElection(locker, "snapperd", 0, logger)
+	b := NewLeaderElection(locker, "snapperd", 0, logger)
+
+	a.Poll(ctx)
+	b.Poll(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected a to lead and b to stand by, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	// The leader keeps its lock while it is healthy
+	a.Poll(ctx)
+	b.Poll(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected leadership unchanged, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	// The leader's session dies: it steps down and the standby takes over
+	locker.holder.checkErr = fmt.Errorf("connection reset")
+	a.Poll(ctx)
+	if a.IsLeader() {
+		t.Fatal("expected a to step down after losing its lock")
+	}
+	b.Poll(ctx)
+	if !b.IsLeader() {
+		t.Fatal("expected b to take over")
+	}
+
+	// Resigning hands leadership back
+	if err := b.Resign(); err != nil {
+		t.Fatalf("Resign returned error: %v", err)
+	}
+	if b.IsLeader() {
+		t.Fatal("expected b to stand by after resigning")
+	}
+	a.Poll(ctx)
+	if !a.IsLeader() {
+		t.Fatal("expected a to lead after b resigned")
+	}
+}
+
+func TestLeaderOnly(t *testing.T) {
+	for _, leader := range []bool{true, false} {
+		job := &mockJob{}
+		if err := LeaderOnly(job, staticLeader(leader)).Run(context.Background()); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		if want := map[bool]int{true: 1, false: 0}[leader]; job.runCount != want {
+			t.Errorf("leader=%v: expected %d runs, got %d", leader, want, job.runCount)
+		}
+	}
+}
+
+func TestUploadRequestJob_Standby(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	store := &mockUploadRequestStore{
+		pending: map[string][]database.UploadRequest{
+			"node-a": {{ID: 1, NodeName: "node-a"}},
+		},
+		outcomes: make(map[int64]uploadRequestOutcome),
+	}
+
+	job := NewUploadRequestJob(store, map[string]*NodeUploadJob{"node-a": {}}, "host-b", 200, logger)
+	job.SetLeader(staticLeader(false))
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	// Standbys keep their heartbeat so CLI uploads are queued, but leave the queue to the leader
+	if store.heartbeats != 1 {
+		t.Errorf("expected 1 heartbeat, got %d", store.heartbeats)
+	}
+	if len(store.pending["node-a"]) != 1 || len(store.outcomes) != 0 {
+		t.Errorf("expected the request left queued, got pending %v, outcomes %v", store.pending, store.outcomes)
+	}
+}
@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/tracing"
+)
+
+// MetricPool bounds the protocol metric collections running at once across the daemon's
+// jobs, and limits each node's collection to a timeout, so a host with many nodes neither
+// floods its RPC endpoints when their schedules coincide nor waits on a hung node. A nil
+// pool collects directly, without either limit.
+type MetricPool struct {
+	slots   chan struct{}
+	timeout time.Duration
+
+	timeouts atomic.Int64 // Collections stopped by the timeout
+}
+
+// NewMetricPool creates a pool running up to concurrency collections at once, each
+// limited to timeout
+func NewMetricPool(concurrency int, timeout time.Duration) *MetricPool {
+	if concurrency <= 0 {
+		concurrency = config.DefaultMetricConcurrency
+	}
+	if timeout <= 0 {
+		timeout = config.DefaultMetricTimeout
+	}
+	return &MetricPool{
+		slots:   make(chan struct{}, concurrency),
+		timeout: timeout,
+	}
+}
+
+// Collect collects a node's protocol metrics once a slot is free. The timeout starts
+// when the collection does, so time spent waiting for a slot does not count against it.
+func (p *MetricPool) Collect(ctx context.Context, module protocol.ProtocolModule, nodeConfig config.NodeConfig) (metrics map[string]interface{}, err error) {
+	ctx, span := tracing.Start(ctx, "protocol.collect_metrics", tracing.String("protocol", module.Name()))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if p == nil {
+		return module.CollectMetrics(ctx, nodeConfig)
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting to collect metrics: %w", ctx.Err())
+	}
+	defer func() { <-p.slots }()
+
+	collectCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	metrics, err = module.CollectMetrics(collectCtx, nodeConfig)
+	if collectCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		// Modules record the queries the timeout cut short as nil metrics, or fail
+		p.timeouts.Add(1)
+		span.SetAttributes(tracing.Bool("timed_out", true))
+		if err != nil {
+			return nil, fmt.Errorf("metric collection timed out after %s: %w", p.timeout, err)
+		}
+	}
+	return metrics, err
+}
+
+// Timeouts returns how many collections the timeout has stopped since the pool was created
+func (p *MetricPool) Timeouts() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.timeouts.Load()
+}
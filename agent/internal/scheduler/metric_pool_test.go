@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+)
+
+func TestMetricPool_BoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	module := &mockProtocolModule{
+		name: "ethereum",
+		collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return map[string]interface{}{"latest_block": int64(1)}, nil
+		},
+	}
+	pool := NewMetricPool(3, time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.Collect(context.Background(), module, config.NodeConfig{}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() != 3 {
+		t.Errorf("Expected at most 3 collections at once, peaked at %d", peak.Load())
+	}
+}
+
+func TestMetricPool_Timeout(t *testing.T) {
+	hung := &mockProtocolModule{
+		name: "ethereum",
+		collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	// Modules that record failed queries as nil metrics return no error
+	partial := &mockProtocolModule{
+		name: "ethereum",
+		collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+			<-ctx.Done()
+			return map[string]interface{}{"latest_block": nil}, nil
+		},
+	}
+	pool := NewMetricPool(1, 20*time.Millisecond)
+
+	_, err := pool.Collect(context.Background(), hung, config.NodeConfig{})
+	if err == nil || !strings.Contains(err.Error(), "metric collection timed out after 20ms") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	metrics, err := pool.Collect(context.Background(), partial, config.NodeConfig{})
+	if err != nil || len(metrics) != 1 {
+		t.Errorf("Expected the partial metrics, got %v, %v", metrics, err)
+	}
+	if pool.Timeouts() != 2 {
+		t.Errorf("Expected 2 timeouts, got %d", pool.Timeouts())
+	}
+
+	// A cancelled caller is not a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Collect(ctx, hung, config.NodeConfig{}); err == nil {
+		t.Error("Expected an error for a cancelled context")
+	}
+	if pool.Timeouts() != 2 {
+		t.Errorf("Expected cancellation not to count as a timeout, got %d", pool.Timeouts())
+	}
+
+	// A nil pool collects directly
+	var direct *MetricPool
+	metrics, err = direct.Collect(context.Background(), &mockProtocolModule{name: "ethereum"}, config.NodeConfig{})
+	if err != nil {
+		t.Errorf("Unexpected error from a nil pool: %v, %v", metrics, err)
+	}
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// validateMetrics checks the metrics collected for a run against the node's validation
+// thresholds. latest_block must be positive and, when max_block_change is set, within
+// that many blocks of the latest_block recorded with the previous completed snapshot.
+// It returns nil when the node has no thresholds.
+func (j *NodeUploadJob) validateMetrics(ctx context.Context, metrics map[string]interface{}) error {
+	validation := j.nodeConfig.Validation
+	if validation == nil {
+		return nil
+	}
+
+	latestBlock, ok := toInt64(metrics["latest_block"])
+	if !ok {
+		return fmt.Errorf("latest_block unavailable")
+	}
+	if latestBlock <= 0 {
+		return fmt.Errorf("latest_block %d is not positive", latestBlock)
+	}
+
+	if validation.MaxBlockChange == 0 {
+		return nil
+	}
+
+	// The previous snapshot is the reference; without one there is nothing to compare
+	previous, err := j.db.GetLatestCompletedUploadForNode(ctx, j.nodeName)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      j.nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to load previous snapshot, skipping block change check")
+		return nil
+	}
+	if previous == nil {
+		return nil
+	}
+	previousBlock, ok := toInt64(previous.ProtocolData["latest_block"])
+	if !ok || previousBlock <= 0 {
+		return nil
+	}
+
+	change := latestBlock - previousBlock
+	if change < 0 {
+		change = -change
+	}
+	if change > validation.MaxBlockChange {
+		return fmt.Errorf("latest_block %d differs from previous snapshot's %d (upload %d) by %d blocks, more than max_block_change %d",
+			latestBlock, previousBlock, previous.ID, change, validation.MaxBlockChange)
+	}
+	return nil
+}
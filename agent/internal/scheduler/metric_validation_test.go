@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNodeUploadJob_MetricValidation(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	previous := &database.Upload{ID: 7, ProtocolData: database.JSONB{"latest_block": float64(20000000)}}
+
+	tests := []struct {
+		name        string
+		validation  *config.MetricValidationConfig
+		metrics     map[string]interface{}
+		collectErr  error
+		previous    *database.Upload
+		wantStarted bool
+	}{
+		{name: "no thresholds", metrics: map[string]interface{}{"latest_block": int64(0)}, wantStarted: true},
+		{name: "positive block", validation: &config.MetricValidationConfig{}, metrics: map[string]interface{}{"latest_block": int64(20001000)}, wantStarted: true},
+		{name: "zero block", validation: &config.MetricValidationConfig{}, metrics: map[string]interface{}{"latest_block": int64(0)}},
+		{name: "collection failed", validation: &config.MetricValidationConfig{}, collectErr: fmt.Errorf("connection refused")},
+		{name: "within max_block_change", validation: &config.MetricValidationConfig{MaxBlockChange: 50000}, metrics: map[string]interface{}{"latest_block": int64(20040000)}, previous: previous, wantStarted: true},
+		{name: "beyond max_block_change", validation: &config.MetricValidationConfig{MaxBlockChange: 50000}, metrics: map[string]interface{}{"latest_block": int64(20060000)}, previous: previous},
+		{name: "behind previous snapshot", validation: &config.MetricValidationConfig{MaxBlockChange: 50000}, metrics: map[string]interface{}{"latest_block": int64(1200)}, previous: previous},
+		{name: "no previous snapshot", validation: &config.MetricValidationConfig{MaxBlockChange: 50000}, metrics: map[string]interface{}{"latest_block": int64(1200)}, wantStarted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := false
+			uploadManager := &mockUploadManager{
+				initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+					started = true
+					return 1, nil
+				},
+			}
+			var result string
+			db := &mockDatabase{
+				getLatestCompletedUploadForNodeFunc: func(ctx context.Context, nodeName string) (*database.Upload, error) {
+					return tt.previous, nil
+				},
+				saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+					result = *state.LastResult
+					return nil
+				},
+			}
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{
+				name: "ethereum",
+				collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+					return tt.metrics, tt.collectErr
+				},
+			})
+
+			job := NewNodeUploadJob(
+				"test-node",
+				config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", Validation: tt.validation},
+				protocolRegistry,
+				uploadManager,
+				db,
+				notification.NewRegistry(),
+				nil,
+				logger,
+			)
+			err := job.Run(context.Background())
+
+			if started != tt.wantStarted {
+				t.Fatalf("expected upload started=%v, got %v", tt.wantStarted, started)
+			}
+			if tt.wantStarted {
+				if err != nil || result != scheduleResultInitiated {
+					t.Errorf("expected initiated run, got result %q, error %v", result, err)
+				}
+			} else if err == nil || result != scheduleResultInvalidMetrics {
+				t.Errorf("expected %q result with error, got result %q, error %v", scheduleResultInvalidMetrics, result, err)
+			}
+		})
+	}
+}
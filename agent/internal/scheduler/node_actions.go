@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// NodeActionRecorder is the database the daemon's automatic actions are logged in
+type NodeActionRecorder interface {
+	RecordNodeAction(ctx context.Context, action database.NodeAction) error
+}
+
+// recordNodeAction adds an automatic action to the node's action log, timestamped now
+// unless set. A failure is logged and does not affect the action itself.
+func recordNodeAction(ctx context.Context, recorder NodeActionRecorder, logger *logrus.Logger, action database.NodeAction) {
+	if recorder == nil {
+		return
+	}
+	if action.CreatedAt.IsZero() {
+		action.CreatedAt = time.Now()
+	}
+
+	if err := recorder.RecordNodeAction(ctx, action); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      action.NodeName,
+			"action":    action.Action,
+			"error":     err.Error(),
+		}).Warn("Failed to record node action")
+	}
+}
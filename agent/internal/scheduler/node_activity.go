@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// NodeActivityStore records which nodes are still run, so nodes removed from the
+// configuration keep their history but are marked inactive
+type NodeActivityStore interface {
+	MarkNodesActive(ctx context.Context, host string, nodeNames []string, now time.Time) error
+	MarkNodeInactive(ctx context.Context, nodeName string, now time.Time) error
+	DeactivateMissingNodes(ctx context.Context, host string, active []string, now time.Time) ([]string, error)
+}
+
+// NodeActivityTracker marks the nodes the daemon runs active and the nodes it stopped
+// running inactive: nodes removed from the configuration file at startup, and registered
+// nodes as they are deregistered
+type NodeActivityTracker struct {
+	store  NodeActivityStore
+	host   string
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewNodeActivityTracker creates a tracker recording the nodes run by the daemon on host
+func NewNodeActivityTracker(store NodeActivityStore, host string, logger *logrus.Logger) *NodeActivityTracker {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &NodeActivityTracker{
+		store:  store,
+		host:   host,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Sync marks the nodes the daemon runs at startup active, and the nodes this host ran
+// before, or that only have history, inactive
+func (t *NodeActivityTracker) Sync(ctx context.Context, nodeNames []string) error {
+	now := t.now()
+	if err := t.store.MarkNodesActive(ctx, t.host, nodeNames, now); err != nil {
+		return err
+	}
+
+	inactive, err := t.store.DeactivateMissingNodes(ctx, t.host, nodeNames, now)
+	if err != nil {
+		return err
+	}
+	for _, nodeName := range inactive {
+		t.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+		}).Info("Node no longer configured, marked inactive; its history is kept until 'snapperd purge-node'")
+	}
+
+	return nil
+}
+
+// SetNode marks a node registered, or updated, at runtime active
+func (t *NodeActivityTracker) SetNode(cfg *config.Config, nodeName string) {
+	if err := t.store.MarkNodesActive(context.Background(), t.host, []string{nodeName}, t.now()); err != nil {
+		t.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to mark node active")
+	}
+}
+
+// RemoveNode marks a deregistered node inactive
+func (t *NodeActivityTracker) RemoveNode(nodeName string) {
+	if err := t.store.MarkNodeInactive(context.Background(), nodeName, t.now()); err != nil {
+		t.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to mark node inactive")
+		return
+	}
+
+	t.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+	}).Info("Node deregistered, marked inactive")
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nodeActivityMemory records node activity by node name
+type nodeActivityMemory struct {
+	hosts    map[string]string
+	inactive map[string]bool
+}
+
+func (m *nodeActivityMemory) MarkNodesActive(ctx context.Context, host string, nodeNames []string, now time.Time) error {
+	for _, nodeName := range nodeNames {
+		m.hosts[nodeName] = host
+		delete(m.inactive, nodeName)
+	}
+	return nil
+}
+
+func (m *nodeActivityMemory) MarkNodeInactive(ctx context.Context, nodeName string, now time.Time) error {
+	if _, tracked := m.hosts[nodeName]; tracked {
+		m.inactive[nodeName] = true
+	}
+	return nil
+}
+
+func (m *nodeActivityMemory) DeactivateMissingNodes(ctx context.Context, host string, active []string, now time.Time) ([]string, error) {
+	isActive := make(map[string]bool)
+	for _, nodeName := range active {
+		isActive[nodeName] = true
+	}
+	var deactivated []string
+	for nodeName, nodeHost := range m.hosts {
+		if nodeHost == host && !isActive[nodeName] && !m.inactive[nodeName] {
+			m.inactive[nodeName] = true
+			deactivated = append(deactivated, nodeName)
+		}
+	}
+	return deactivated, nil
+}
+
+func TestNodeActivityTracker(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	memory := &nodeActivityMemory{
+		hosts:    map[string]string{"removed-node": "snap-1", "other-node": "snap-2"},
+		inactive: make(map[string]bool),
+	}
+	tracker := NewNodeActivityTracker(memory, "snap-1", logger)
+
+	// Startup marks the running nodes active and this host's removed nodes inactive
+	if err := tracker.Sync(context.Background(), []string{"eth-node", "arb-node"}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(memory.inactive) != 1 || !memory.inactive["removed-node"] {
+		t.Errorf("expected only removed-node inactive, got %v", memory.inactive)
+	}
+	if memory.hosts["eth-node"] != "snap-1" || memory.hosts["arb-node"] != "snap-1" {
+		t.Errorf("expected the running nodes recorded for snap-1, got %v", memory.hosts)
+	}
+
+	// Deregistered nodes become inactive and registered ones active again
+	tracker.RemoveNode("arb-node")
+	if !memory.inactive["arb-node"] {
+		t.Error("expected arb-node inactive after deregistration")
+	}
+	tracker.SetNode(nil, "arb-node")
+	if memory.inactive["arb-node"] {
+		t.Error("expected arb-node active after registration")
+	}
+}
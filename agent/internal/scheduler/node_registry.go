@@ -0,0 +1,618 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// Node sources reported by NodeRegistry.Nodes
+const (
+	NodeSourceConfig     = "config"     // Defined in the configuration file
+	NodeSourceRegistered = "registered" // Registered at runtime
+)
+
+var (
+	// ErrNodeConfigured is returned when registering or deregistering a node defined in
+	// the configuration file, which only a configuration change can modify
+	ErrNodeConfigured = errors.New("node is defined in the configuration file")
+
+	// ErrNodeNotRegistered is returned when deregistering a node that is not registered
+	ErrNodeNotRegistered = errors.New("node is not registered")
+
+	// ErrInvalidNode wraps the validation error of a rejected node configuration
+	ErrInvalidNode = errors.New("invalid node")
+)
+
+// NodeStore persists the nodes registered at runtime
+type NodeStore interface {
+	SaveRegisteredNode(ctx context.Context, node database.RegisteredNode) error
+	DeleteRegisteredNode(ctx context.Context, nodeName string) error
+	GetRegisteredNode(ctx context.Context, nodeName string) (*database.RegisteredNode, error)
+	ListAssignedNodes(ctx context.Context, host string) ([]database.RegisteredNode, error)
+}
+
+// JobScheduler adds and removes jobs while the scheduler runs
+type JobScheduler interface {
+	ScheduleJob(schedule string, splay time.Duration, job Job) (JobID, error)
+	RemoveJob(id JobID)
+	RunNow(job Job)
+}
+
+// NodeWatcher is a job that keeps per-node state and follows nodes being registered,
+// updated and deregistered
+type NodeWatcher interface {
+	// SetNode adds or replaces a node; cfg is the configuration including the node
+	SetNode(cfg *config.Config, nodeName string)
+	// RemoveNode forgets a node
+	RemoveNode(nodeName string)
+}
+
+// NodeJobFactory creates the upload job of a node in cfg
+type NodeJobFactory func(cfg *config.Config, nodeName string) *NodeUploadJob
+
+// NodeInfo describes a node known to the registry
+type NodeInfo struct {
+	Name         string     `json:"name"`
+	Source       string     `json:"source"`
+	Host         string     `json:"host,omitempty"` // Host a registered node is assigned to
+	Protocol     string     `json:"protocol"`
+	Type         string     `json:"type,omitempty"`
+	Tenant       string     `json:"tenant,omitempty"`
+	Schedule     string     `json:"schedule"`
+	RegisteredAt *time.Time `json:"registered_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	Overrides    bool       `json:"overrides,omitempty"` // Registered node replacing a configuration file node
+}
+
+// registeredNode is a node the registry has scheduled
+type registeredNode struct {
+	config       string // Stored YAML node config, compared to detect changes
+	host         string
+	registeredAt time.Time
+	updatedAt    time.Time
+	entry        JobID
+}
+
+// NodeRegistry adds and removes nodes while the daemon runs. Registered nodes are stored
+// in the database and scheduled like the nodes in the configuration file: the node gets
+// an upload job on its schedule, serves upload requests, and is followed by the monitor
+// and watchdog jobs. Nodes in the configuration file cannot be changed at runtime unless
+// SetOverridable lets registered nodes of the same name replace them.
+//
+// A registered node can be assigned to a host, in which case only the daemon on that host
+// schedules it; nodes assigned to no host are scheduled by every daemon. Sync loads the
+// stored nodes at startup and, run periodically, picks up nodes registered or reassigned
+// through another daemon or 'snapperd nodes'.
+type NodeRegistry struct {
+	host      string
+	store     NodeStore
+	scheduler JobScheduler
+	newJob    NodeJobFactory
+	requests  *UploadRequestJob
+	watchers  []NodeWatcher
+	leader    Leader
+	logger    *logrus.Logger
+	now       func() time.Time
+
+	mu          sync.Mutex
+	cfg         *config.Config // Configuration including the registered nodes
+	fileConfig  *config.Config // Configuration file, restored when an override is removed
+	fileNodes   map[string]bool
+	fileEntries map[string]JobID // Jobs of the configuration file nodes registered nodes may replace
+	registered  map[string]*registeredNode
+}
+
+// NewNodeRegistry creates a registry for the nodes of cfg, which are treated as the
+// configuration file's nodes, scheduling the registered nodes assigned to host. New node
+// jobs are created with newJob, scheduled on scheduler and added to requests.
+func NewNodeRegistry(cfg *config.Config, host string, store NodeStore, scheduler JobScheduler, newJob NodeJobFactory, requests *UploadRequestJob, logger *logrus.Logger) *NodeRegistry {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	fileNodes := make(map[string]bool, len(cfg.Nodes))
+	for nodeName := range cfg.Nodes {
+		fileNodes[nodeName] = true
+	}
+
+	return &NodeRegistry{
+		host:       host,
+		store:      store,
+		scheduler:  scheduler,
+		newJob:     newJob,
+		requests:   requests,
+		logger:     logger,
+		now:        time.Now,
+		cfg:        cfg,
+		fileConfig: cfg,
+		fileNodes:  fileNodes,
+		registered: make(map[string]*registeredNode),
+	}
+}
+
+// SetOverridable lets registered nodes replace the configuration file nodes in entries,
+// whose upload jobs are scheduled with the given IDs. A replaced node's job is removed
+// while the registered node is scheduled, and the file's definition is scheduled again
+// when the registered node is deregistered or assigned to another host. Members of a
+// consistency group have no job of their own and cannot be replaced.
+func (r *NodeRegistry) SetOverridable(entries map[string]JobID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fileEntries = make(map[string]JobID, len(entries))
+	for nodeName, entry := range entries {
+		r.fileEntries[nodeName] = entry
+	}
+}
+
+// Overridden reports whether a configuration file node is replaced by a registered node
+func (r *NodeRegistry) Overridden(nodeName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, exists := r.registered[nodeName]
+	return exists && r.fileNodes[nodeName]
+}
+
+// Watch adds jobs that follow node changes
+func (r *NodeRegistry) Watch(watchers ...NodeWatcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers = append(r.watchers, watchers...)
+}
+
+// SetLeader runs registered nodes' upload jobs only while leader reports this daemon is
+// the leader
+func (r *NodeRegistry) SetLeader(leader Leader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.leader = leader
+}
+
+// Register adds a node, or replaces the configuration or host of a registered node, and
+// reports whether the node was added. A node assigned to this daemon's host, or to no
+// host, is scheduled at once; daemons on other hosts pick it up when they next sync. The
+// node is validated like a nodes entry of the configuration file, including its protocol
+// module.
+func (r *NodeRegistry) Register(ctx context.Context, nodeName, host string, nodeConfig config.NodeConfig) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fileNodes[nodeName] && !r.overridable(nodeName) {
+		return false, ErrNodeConfigured
+	}
+
+	if host != "" {
+		if err := config.ValidateHostName(host); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidNode, err)
+		}
+	}
+	next, err := r.cfg.WithNode(nodeName, nodeConfig)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidNode, err)
+	}
+	encoded, err := config.EncodeNodeConfig(nodeConfig)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := r.store.GetRegisteredNode(ctx, nodeName)
+	if err != nil {
+		return false, err
+	}
+	created := existing == nil
+
+	if created || existing.Host != host || existing.Config != encoded {
+		now := r.now()
+		stored := database.RegisteredNode{
+			NodeName:     nodeName,
+			Host:         host,
+			Config:       encoded,
+			RegisteredAt: now,
+			UpdatedAt:    now,
+		}
+		if !created {
+			stored.RegisteredAt = existing.RegisteredAt
+		}
+		if err := r.store.SaveRegisteredNode(ctx, stored); err != nil {
+			return false, err
+		}
+		existing = &stored
+	}
+
+	current, scheduled := r.registered[nodeName]
+	switch {
+	case !r.assigned(host):
+		if scheduled {
+			r.remove(nodeName)
+		}
+	case scheduled && current.config == encoded:
+		current.host = host
+	default:
+		if err := r.apply(ctx, next, *existing); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// Deregister removes a registered node, and its upload job when this daemon runs it. A
+// node that replaced a configuration file node goes back to the file's definition. An
+// upload already running for the node is not stopped; the monitor still records its
+// completion.
+func (r *NodeRegistry) Deregister(ctx context.Context, nodeName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fileNodes[nodeName] && !r.overridable(nodeName) {
+		return ErrNodeConfigured
+	}
+	existing, err := r.store.GetRegisteredNode(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+	if existing == nil && r.fileNodes[nodeName] {
+		return ErrNodeConfigured
+	}
+	if existing == nil {
+		return ErrNodeNotRegistered
+	}
+
+	if err := r.store.DeleteRegisteredNode(ctx, nodeName); err != nil {
+		return err
+	}
+
+	if _, scheduled := r.registered[nodeName]; scheduled {
+		r.remove(nodeName)
+	}
+	return nil
+}
+
+// Nodes returns every node, from the configuration file and registered, by name
+func (r *NodeRegistry) Nodes() []NodeInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nodes := make([]NodeInfo, 0, len(r.cfg.Nodes))
+	for nodeName := range r.cfg.Nodes {
+		nodes = append(nodes, r.info(nodeName))
+	}
+
+	sort.Slice(nodes, func(i, k int) bool {
+		return nodes[i].Name < nodes[k].Name
+	})
+	return nodes
+}
+
+// Node returns a node run by this daemon and, when it is registered, its stored YAML
+// config. Configuration file nodes have no stored config, so none of the file's
+// settings, such as expanded secrets, are returned.
+func (r *NodeRegistry) Node(nodeName string) (NodeInfo, string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.cfg.Nodes[nodeName]; !exists {
+		return NodeInfo{}, "", false
+	}
+	var stored string
+	if registered, exists := r.registered[nodeName]; exists {
+		stored = registered.config
+	}
+	return r.info(nodeName), stored, true
+}
+
+// Tenant returns the tenant of a node registered for any host, or else defined in the
+// configuration file, and whether the node exists
+func (r *NodeRegistry) Tenant(ctx context.Context, nodeName string) (string, bool, error) {
+	stored, err := r.store.GetRegisteredNode(ctx, nodeName)
+	if err != nil {
+		return "", false, err
+	}
+	if stored != nil {
+		nodeConfig, err := config.ParseNodeConfig([]byte(stored.Config))
+		if err != nil {
+			return "", false, err
+		}
+		return nodeConfig.Tenant, true, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodeConfig, exists := r.fileConfig.Nodes[nodeName]
+	return nodeConfig.Tenant, exists, nil
+}
+
+// info describes a node of r.cfg. The caller holds r.mu.
+func (r *NodeRegistry) info(nodeName string) NodeInfo {
+	nodeConfig := r.cfg.Nodes[nodeName]
+	info := NodeInfo{
+		Name:     nodeName,
+		Source:   NodeSourceConfig,
+		Protocol: nodeConfig.Protocol,
+		Type:     nodeConfig.Type,
+		Tenant:   nodeConfig.Tenant,
+		Schedule: r.cfg.GetNodeSchedule(nodeName),
+	}
+	if registered, exists := r.registered[nodeName]; exists {
+		registeredAt, updatedAt := registered.registeredAt, registered.updatedAt
+		info.Source = NodeSourceRegistered
+		info.Host = registered.host
+		info.RegisteredAt = &registeredAt
+		info.UpdatedAt = &updatedAt
+		info.Overrides = r.fileNodes[nodeName]
+	}
+	return info
+}
+
+// Sync applies the registered nodes assigned to this daemon's host: new and changed
+// nodes are scheduled, and nodes removed or assigned to another host are unscheduled,
+// putting back the configuration file nodes they replaced.
+// Stored nodes that are invalid, such as ones whose protocol module is not loaded on this
+// host, are skipped with a warning.
+func (r *NodeRegistry) Sync(ctx context.Context) error {
+	// Hold the lock across the read so a concurrent Register is not undone
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.store.ListAssignedNodes(ctx, r.host)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(stored))
+	for _, node := range stored {
+		seen[node.NodeName] = true
+
+		fields := logrus.Fields{
+			"component": "scheduler",
+			"node":      node.NodeName,
+		}
+		if r.fileNodes[node.NodeName] && !r.overridable(node.NodeName) {
+			r.logger.WithFields(fields).Debug("Registered node is defined in the configuration file, using the file")
+			continue
+		}
+		if existing, exists := r.registered[node.NodeName]; exists && existing.config == node.Config {
+			existing.host = node.Host
+			continue
+		}
+
+		nodeConfig, err := config.ParseNodeConfig([]byte(node.Config))
+		if err == nil {
+			var next *config.Config
+			if next, err = r.cfg.WithNode(node.NodeName, nodeConfig); err == nil {
+				err = r.apply(ctx, next, node)
+			}
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			r.logger.WithFields(fields).Warn("Skipping invalid registered node")
+		}
+	}
+
+	for nodeName := range r.registered {
+		if !seen[nodeName] {
+			r.remove(nodeName)
+		}
+	}
+
+	return nil
+}
+
+// Run syncs the registered nodes, so changes made through another daemon or 'snapperd
+// nodes' are picked up
+func (r *NodeRegistry) Run(ctx context.Context) error {
+	if err := r.Sync(ctx); err != nil {
+		r.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       "node_sync",
+			"error":     err.Error(),
+		}).Warn("Failed to sync registered nodes")
+	}
+	return nil
+}
+
+// apply schedules a new or changed node of next, replacing any job it had. The caller
+// holds r.mu.
+func (r *NodeRegistry) apply(ctx context.Context, next *config.Config, stored database.RegisteredNode) error {
+	nodeName := stored.NodeName
+	schedule := next.GetNodeSchedule(nodeName)
+
+	job := r.newJob(next, nodeName)
+	scheduled := Named(NodeJobName(nodeName), r.gate(job))
+	entry, err := r.scheduler.ScheduleJob(schedule, job.SplayOffset(), scheduled)
+	if err != nil {
+		return err
+	}
+
+	existing, exists := r.registered[nodeName]
+	overrides := !exists && r.fileNodes[nodeName]
+	switch {
+	case exists:
+		r.scheduler.RemoveJob(existing.entry)
+	case overrides:
+		r.scheduler.RemoveJob(r.fileEntries[nodeName])
+	}
+	r.requests.SetNodeJob(nodeName, job)
+	for _, watcher := range r.watchers {
+		watcher.SetNode(next, nodeName)
+	}
+
+	r.cfg = next
+	r.registered[nodeName] = &registeredNode{
+		config:       stored.Config,
+		host:         stored.Host,
+		registeredAt: stored.RegisteredAt,
+		updatedAt:    stored.UpdatedAt,
+		entry:        entry,
+	}
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+		"protocol":  next.Nodes[nodeName].Protocol,
+		"schedule":  schedule,
+	}
+	if stored.Host != "" {
+		fields["host"] = stored.Host
+	}
+	switch {
+	case exists:
+		r.logger.WithFields(fields).Info("Registered node updated")
+	case overrides:
+		r.logger.WithFields(fields).Info("Registered node replaces the configuration file node")
+	default:
+		r.logger.WithFields(fields).Info("Node registered")
+	}
+
+	// Record the next run, and catch up a run missed while the daemon was stopped. A
+	// node that runs on start also runs when it is first scheduled, but not on updates.
+	catchUp, err := job.Resume(ctx)
+	if err != nil {
+		fields["error"] = err.Error()
+		r.logger.WithFields(fields).Warn("Failed to restore schedule state")
+	}
+	if catchUp || (!exists && job.RunOnStart()) {
+		r.scheduler.RunNow(scheduled)
+	}
+
+	return nil
+}
+
+// remove unschedules a registered node, scheduling the configuration file node it
+// replaced again. The caller holds r.mu.
+func (r *NodeRegistry) remove(nodeName string) {
+	r.scheduler.RemoveJob(r.registered[nodeName].entry)
+	delete(r.registered, nodeName)
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+	}
+	if r.fileNodes[nodeName] {
+		if err := r.restore(nodeName); err != nil {
+			fields["error"] = err.Error()
+			r.logger.WithFields(fields).Error("Failed to restore the configuration file node")
+		} else {
+			r.logger.WithFields(fields).Info("Registered node removed, configuration file node restored")
+			return
+		}
+	}
+
+	r.requests.RemoveNodeJob(nodeName)
+	for _, watcher := range r.watchers {
+		watcher.RemoveNode(nodeName)
+	}
+	r.cfg = r.cfg.WithoutNode(nodeName)
+
+	r.logger.WithFields(fields).Info("Node deregistered")
+}
+
+// restore schedules a configuration file node's own definition again, after the
+// registered node replacing it was removed. The caller holds r.mu.
+func (r *NodeRegistry) restore(nodeName string) error {
+	next, err := r.cfg.WithNode(nodeName, r.fileConfig.Nodes[nodeName])
+	if err != nil {
+		return err
+	}
+
+	job := r.newJob(next, nodeName)
+	entry, err := r.scheduler.ScheduleJob(next.GetNodeSchedule(nodeName), job.SplayOffset(), Named(NodeJobName(nodeName), r.gate(job)))
+	if err != nil {
+		return err
+	}
+	r.fileEntries[nodeName] = entry
+	r.requests.SetNodeJob(nodeName, job)
+	for _, watcher := range r.watchers {
+		watcher.SetNode(next, nodeName)
+	}
+	r.cfg = next
+
+	if _, err := job.Resume(context.Background()); err != nil {
+		r.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to restore schedule state")
+	}
+	return nil
+}
+
+// overridable reports whether a registered node may replace a configuration file node.
+// The caller holds r.mu.
+func (r *NodeRegistry) overridable(nodeName string) bool {
+	_, exists := r.fileEntries[nodeName]
+	return exists
+}
+
+// assigned reports whether a node assigned to host runs on this daemon
+func (r *NodeRegistry) assigned(host string) bool {
+	return host == "" || host == r.host
+}
+
+// gate wraps a node's upload job so it runs only on the leader, when leader election is on
+func (r *NodeRegistry) gate(job Job) Job {
+	if r.leader == nil {
+		return job
+	}
+	return LeaderOnly(job, r.leader)
+}
+
+// nodeConfigSet holds the node configs a job reads while nodes are registered and
+// deregistered. Changes replace the map instead of modifying it, so a map returned by
+// all can be read without locking.
+type nodeConfigSet struct {
+	mu    sync.RWMutex
+	nodes map[string]config.NodeConfig
+}
+
+// newNodeConfigSet creates a set holding nodes. The map is not modified.
+func newNodeConfigSet(nodes map[string]config.NodeConfig) *nodeConfigSet {
+	return &nodeConfigSet{nodes: nodes}
+}
+
+// all returns the current nodes. The map must not be modified.
+func (s *nodeConfigSet) all() map[string]config.NodeConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nodes
+}
+
+// get returns a node's config
+func (s *nodeConfigSet) get(nodeName string) (config.NodeConfig, bool) {
+	nodeConfig, exists := s.all()[nodeName]
+	return nodeConfig, exists
+}
+
+// set adds or replaces a node
+func (s *nodeConfigSet) set(nodeName string, nodeConfig config.NodeConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := make(map[string]config.NodeConfig, len(s.nodes)+1)
+	for name, existing := range s.nodes {
+		nodes[name] = existing
+	}
+	nodes[nodeName] = nodeConfig
+	s.nodes = nodes
+}
+
+// remove forgets a node
+func (s *nodeConfigSet) remove(nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := make(map[string]config.NodeConfig, len(s.nodes))
+	for name, existing := range s.nodes {
+		if name != nodeName {
+			nodes[name] = existing
+		}
+	}
+	s.nodes = nodes
+}
@@ -0,0 +1,403 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// mockNodeStore keeps registered nodes in memory; several registries can share one
+type mockNodeStore struct {
+	mu    sync.Mutex
+	nodes map[string]database.RegisteredNode
+}
+
+func (m *mockNodeStore) SaveRegisteredNode(ctx context.Context, node database.RegisteredNode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes[node.NodeName] = node
+	return nil
+}
+
+func (m *mockNodeStore) DeleteRegisteredNode(ctx context.Context, nodeName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.nodes, nodeName)
+	return nil
+}
+
+func (m *mockNodeStore) GetRegisteredNode(ctx context.Context, nodeName string) (*database.RegisteredNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, exists := m.nodes[nodeName]
+	if !exists {
+		return nil, nil
+	}
+	return &node, nil
+}
+
+func (m *mockNodeStore) ListAssignedNodes(ctx context.Context, host string) ([]database.RegisteredNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var nodes []database.RegisteredNode
+	for _, node := range m.nodes {
+		if node.Host == "" || node.Host == host {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, k int) bool { return nodes[i].NodeName < nodes[k].NodeName })
+	return nodes, nil
+}
+
+// mockJobScheduler records scheduled jobs by ID
+type mockJobScheduler struct {
+	nextID    JobID
+	schedules map[JobID]string
+	runs      int
+}
+
+func (m *mockJobScheduler) ScheduleJob(schedule string, splay time.Duration, job Job) (JobID, error) {
+	m.nextID++
+	m.schedules[m.nextID] = schedule
+	return m.nextID, nil
+}
+
+func (m *mockJobScheduler) RemoveJob(id JobID) {
+	delete(m.schedules, id)
+}
+
+func (m *mockJobScheduler) RunNow(job Job) {
+	m.runs++
+}
+
+// mockNodeWatcher records the nodes it follows
+type mockNodeWatcher struct {
+	nodes map[string]config.NodeConfig
+}
+
+func (m *mockNodeWatcher) SetNode(cfg *config.Config, nodeName string) {
+	m.nodes[nodeName] = cfg.Nodes[nodeName]
+}
+
+func (m *mockNodeWatcher) RemoveNode(nodeName string) {
+	delete(m.nodes, nodeName)
+}
+
+// newTestNodeRegistry creates a registry on host for a configuration file with node eth-file
+func newTestNodeRegistry(store NodeStore, host string) (*NodeRegistry, *mockJobScheduler, *mockNodeWatcher, *UploadRequestJob) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	cfg := &config.Config{
+		Schedule: "0 * * * * *",
+		Database: config.DatabaseConfig{Driver: "sqlite", Path: "/var/lib/snapd/snapd.db"},
+		Nodes: map[string]config.NodeConfig{
+			"eth-file": {Protocol: "ethereum", URL: "http://localhost:8545", Schedule: "0 0 */6 * * *"},
+		},
+	}
+	newJob := func(cfg *config.Config, nodeName string) *NodeUploadJob {
+		return NewNodeUploadJob(nodeName, cfg.Nodes[nodeName], protocol.NewRegistry(), &mockUploadManager{}, &mockDatabase{}, notification.NewRegistry(), cfg.GetNodeNotifications(nodeName), logger)
+	}
+
+	sched := &mockJobScheduler{schedules: make(map[JobID]string)}
+	watcher := &mockNodeWatcher{nodes: make(map[string]config.NodeConfig)}
+	requests := NewUploadRequestJob(&mockUploadRequestStore{}, map[string]*NodeUploadJob{}, "host-a", 1, logger)
+
+	registry := NewNodeRegistry(cfg, host, store, sched, newJob, requests, logger)
+	registry.Watch(watcher)
+	return registry, sched, watcher, requests
+}
+
+func TestNodeRegistry_RegisterAndDeregister(t *testing.T) {
+	ctx := context.Background()
+	store := &mockNodeStore{nodes: make(map[string]database.RegisteredNode)}
+	registry, sched, watcher, requests := newTestNodeRegistry(store, "host-a")
+
+	node := config.NodeConfig{Protocol: "arbitrum", URL: "http://10.0.0.5:8547", Schedule: "0 0 */12 * * *"}
+
+	if _, err := registry.Register(ctx, "eth-file", "", node); !errors.Is(err, ErrNodeConfigured) {
+		t.Errorf("expected ErrNodeConfigured for a configuration file node, got %v", err)
+	}
+	if _, err := registry.Register(ctx, "arb-1", "", config.NodeConfig{Protocol: "arbitrum", Schedule: "0 0 * * * *"}); !errors.Is(err, ErrInvalidNode) {
+		t.Errorf("expected ErrInvalidNode without url, got %v", err)
+	}
+
+	created, err := registry.Register(ctx, "arb-1", "", node)
+	if err != nil || !created {
+		t.Fatalf("expected arb-1 created, got %v, %v", created, err)
+	}
+	if len(sched.schedules) != 1 || requests.nodeJob("arb-1") == nil || watcher.nodes["arb-1"].URL != node.URL || store.nodes["arb-1"].Config == "" {
+		t.Fatalf("expected arb-1 scheduled, serving requests, watched and stored; schedules %v, watched %v", sched.schedules, watcher.nodes)
+	}
+
+	// Registering the same config again changes nothing
+	if created, err := registry.Register(ctx, "arb-1", "", node); err != nil || created {
+		t.Fatalf("expected unchanged re-registration, got %v, %v", created, err)
+	}
+	if len(sched.schedules) != 1 {
+		t.Errorf("expected one scheduled job, got %v", sched.schedules)
+	}
+
+	// A changed schedule replaces the job
+	node.Schedule = "0 30 * * * *"
+	if created, err := registry.Register(ctx, "arb-1", "", node); err != nil || created {
+		t.Fatalf("expected update, got %v, %v", created, err)
+	}
+	for _, schedule := range sched.schedules {
+		if schedule != node.Schedule || len(sched.schedules) != 1 {
+			t.Errorf("expected only the new schedule, got %v", sched.schedules)
+		}
+	}
+
+	nodes := registry.Nodes()
+	if len(nodes) != 2 || nodes[0].Name != "arb-1" || nodes[0].Source != NodeSourceRegistered || nodes[0].RegisteredAt == nil || nodes[1].Source != NodeSourceConfig {
+		t.Errorf("expected registered arb-1 and configured eth-file, got %+v", nodes)
+	}
+
+	if err := registry.Deregister(ctx, "eth-file"); !errors.Is(err, ErrNodeConfigured) {
+		t.Errorf("expected ErrNodeConfigured, got %v", err)
+	}
+	if err := registry.Deregister(ctx, "arb-2"); !errors.Is(err, ErrNodeNotRegistered) {
+		t.Errorf("expected ErrNodeNotRegistered, got %v", err)
+	}
+	if err := registry.Deregister(ctx, "arb-1"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	if len(sched.schedules) != 0 || requests.nodeJob("arb-1") != nil || len(watcher.nodes) != 0 || len(store.nodes) != 0 {
+		t.Errorf("expected arb-1 removed everywhere; schedules %v, watched %v, stored %v", sched.schedules, watcher.nodes, store.nodes)
+	}
+	if nodes := registry.Nodes(); len(nodes) != 1 {
+		t.Errorf("expected only eth-file left, got %+v", nodes)
+	}
+}
+
+func TestNodeRegistry_RunOnStart(t *testing.T) {
+	ctx := context.Background()
+	store := &mockNodeStore{nodes: make(map[string]database.RegisteredNode)}
+	registry, sched, _, _ := newTestNodeRegistry(store, "host-a")
+
+	// A node that runs on start runs when it is first scheduled
+	node := config.NodeConfig{Protocol: "arbitrum", URL: "http://10.0.0.5:8547", Schedule: "0 0 0 * * 0", RunOnStart: true}
+	if _, err := registry.Register(ctx, "arb-1", "", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if sched.runs != 1 {
+		t.Fatalf("expected one run on start, got %d", sched.runs)
+	}
+
+	// Updating it does not run it again
+	node.Schedule = "0 0 12 * * 0"
+	if _, err := registry.Register(ctx, "arb-1", "", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if sched.runs != 1 {
+		t.Errorf("expected no run on update, got %d runs", sched.runs)
+	}
+}
+
+func TestNodeRegistry_Sync(t *testing.T) {
+	ctx := context.Background()
+	store := &mockNodeStore{nodes: make(map[string]database.RegisteredNode)}
+	a, _, _, _ := newTestNodeRegistry(store, "host-a")
+	b, schedB, watcherB, _ := newTestNodeRegistry(store, "host-b")
+
+	node := config.NodeConfig{Protocol: "arbitrum", URL: "http://10.0.0.5:8547", Schedule: "0 0 */12 * * *"}
+	if _, err := a.Register(ctx, "arb-1", "", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Invalid and configuration file nodes in the store are skipped
+	store.nodes["broken"] = database.RegisteredNode{NodeName: "broken", Config: "protocol: arbitrum\n"}
+	store.nodes["eth-file"] = database.RegisteredNode{NodeName: "eth-file", Config: "protocol: ethereum\nurl: http://other:8545\nschedule: 0 0 * * * *\n"}
+
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(schedB.schedules) != 1 || watcherB.nodes["arb-1"].URL != node.URL {
+		t.Fatalf("expected arb-1 picked up, schedules %v, watched %v", schedB.schedules, watcherB.nodes)
+	}
+
+	// Syncing again without changes keeps the job
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(schedB.schedules) != 1 {
+		t.Errorf("expected one scheduled job, got %v", schedB.schedules)
+	}
+
+	if err := a.Deregister(ctx, "arb-1"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(schedB.schedules) != 0 || len(watcherB.nodes) != 0 {
+		t.Errorf("expected arb-1 removed, schedules %v, watched %v", schedB.schedules, watcherB.nodes)
+	}
+}
+
+func TestNodeRegistry_HostAssignment(t *testing.T) {
+	ctx := context.Background()
+	store := &mockNodeStore{nodes: make(map[string]database.RegisteredNode)}
+	a, schedA, watcherA, requestsA := newTestNodeRegistry(store, "host-a")
+	b, schedB, _, _ := newTestNodeRegistry(store, "host-b")
+
+	node := config.NodeConfig{Protocol: "arbitrum", URL: "http://10.0.0.5:8547", Schedule: "0 0 */12 * * *"}
+
+	if _, err := a.Register(ctx, "arb-1", "host b", node); !errors.Is(err, ErrInvalidNode) {
+		t.Errorf("expected ErrInvalidNode for an invalid host, got %v", err)
+	}
+
+	// A node assigned to another host is stored but not scheduled here
+	created, err := a.Register(ctx, "arb-1", "host-b", node)
+	if err != nil || !created {
+		t.Fatalf("expected arb-1 created, got %v, %v", created, err)
+	}
+	if len(schedA.schedules) != 0 || store.nodes["arb-1"].Host != "host-b" {
+		t.Fatalf("expected arb-1 stored for host-b only, schedules %v, stored %+v", schedA.schedules, store.nodes["arb-1"])
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(schedB.schedules) != 1 {
+		t.Fatalf("expected host-b to schedule arb-1, got %v", schedB.schedules)
+	}
+
+	// Reassigning moves the node without changing its config
+	if created, err := a.Register(ctx, "arb-1", "host-a", node); err != nil || created {
+		t.Fatalf("expected reassignment, got %v, %v", created, err)
+	}
+	if len(schedA.schedules) != 1 || requestsA.nodeJob("arb-1") == nil || watcherA.nodes["arb-1"].URL != node.URL {
+		t.Fatalf("expected host-a to schedule arb-1, schedules %v, watched %v", schedA.schedules, watcherA.nodes)
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(schedB.schedules) != 0 {
+		t.Errorf("expected host-b to drop arb-1, got %v", schedB.schedules)
+	}
+	if nodes := a.Nodes(); len(nodes) != 2 || nodes[0].Host != "host-a" {
+		t.Errorf("expected arb-1 listed on host-a, got %+v", nodes)
+	}
+
+	// A node assigned to another host can be deregistered from any daemon
+	if _, err := a.Register(ctx, "arb-2", "host-b", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := a.Deregister(ctx, "arb-2"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	if _, exists := store.nodes["arb-2"]; exists {
+		t.Errorf("expected arb-2 removed from the store")
+	}
+}
+
+func TestNodeRegistry_Override(t *testing.T) {
+	ctx := context.Background()
+	store := &mockNodeStore{nodes: make(map[string]database.RegisteredNode)}
+	registry, sched, watcher, requests := newTestNodeRegistry(store, "host-a")
+
+	// The configuration file node's own job, as the daemon schedules it
+	fileEntry, _ := sched.ScheduleJob("0 0 */6 * * *", 0, nil)
+	registry.SetOverridable(map[string]JobID{"eth-file": fileEntry})
+
+	node := config.NodeConfig{Protocol: "ethereum", URL: "http://10.0.0.9:8545", Schedule: "0 0 */2 * * *"}
+	if err := registry.Deregister(ctx, "eth-file"); !errors.Is(err, ErrNodeConfigured) {
+		t.Errorf("expected ErrNodeConfigured before an override, got %v", err)
+	}
+	if _, err := registry.Register(ctx, "eth-file", "", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, exists := sched.schedules[fileEntry]; exists || len(sched.schedules) != 1 {
+		t.Fatalf("expected the file node's job replaced, got %v", sched.schedules)
+	}
+	if watcher.nodes["eth-file"].URL != node.URL || requests.nodeJob("eth-file") == nil || !registry.Overridden("eth-file") {
+		t.Errorf("expected the registered definition in use, watched %v", watcher.nodes)
+	}
+	info, stored, exists := registry.Node("eth-file")
+	if !exists || info.Source != NodeSourceRegistered || !info.Overrides || stored == "" {
+		t.Errorf("expected eth-file described as an override, got %+v, %q", info, stored)
+	}
+
+	// Removing the registered node restores the file's definition
+	if err := registry.Deregister(ctx, "eth-file"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	for _, schedule := range sched.schedules {
+		if schedule != "0 0 */6 * * *" || len(sched.schedules) != 1 {
+			t.Errorf("expected only the file's schedule, got %v", sched.schedules)
+		}
+	}
+	if watcher.nodes["eth-file"].URL != "http://localhost:8545" || requests.nodeJob("eth-file") == nil || registry.Overridden("eth-file") {
+		t.Errorf("expected the file definition restored, watched %v", watcher.nodes)
+	}
+	if info, stored, _ := registry.Node("eth-file"); info.Source != NodeSourceConfig || stored != "" {
+		t.Errorf("expected eth-file from the configuration file, got %+v, %q", info, stored)
+	}
+
+	// Sync applies and drops overrides stored through other daemons
+	store.nodes["eth-file"] = database.RegisteredNode{NodeName: "eth-file", Config: "protocol: ethereum\nurl: http://other:8545\nschedule: 0 0 * * * *\n"}
+	if err := registry.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if watcher.nodes["eth-file"].URL != "http://other:8545" {
+		t.Errorf("expected the stored override applied, watched %v", watcher.nodes)
+	}
+	delete(store.nodes, "eth-file")
+	if err := registry.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if watcher.nodes["eth-file"].URL != "http://localhost:8545" || len(sched.schedules) != 1 {
+		t.Errorf("expected the file definition restored, watched %v, schedules %v", watcher.nodes, sched.schedules)
+	}
+}
+
+func TestNodeRegistry_Tenant(t *testing.T) {
+	ctx := context.Background()
+	store := &mockNodeStore{nodes: make(map[string]database.RegisteredNode)}
+	registry, _, _, _ := newTestNodeRegistry(store, "host-a")
+	registry.cfg.Tenants = map[string]config.TenantConfig{"team-a": {}}
+
+	node := config.NodeConfig{Protocol: "arbitrum", URL: "http://10.0.0.5:8547", Schedule: "0 0 */12 * * *", Tenant: "team-b"}
+	if _, err := registry.Register(ctx, "arb-1", "", node); !errors.Is(err, ErrInvalidNode) {
+		t.Errorf("expected ErrInvalidNode for an unknown tenant, got %v", err)
+	}
+
+	// Nodes assigned to other hosts are found through the store
+	node.Tenant = "team-a"
+	if _, err := registry.Register(ctx, "arb-1", "", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := registry.Register(ctx, "arb-2", "host-b", node); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if info, _, _ := registry.Node("arb-1"); info.Tenant != "team-a" {
+		t.Errorf("expected arb-1 of team-a, got %+v", info)
+	}
+
+	tests := []struct {
+		node       string
+		wantTenant string
+		wantExists bool
+	}{
+		{node: "arb-1", wantTenant: "team-a", wantExists: true},
+		{node: "arb-2", wantTenant: "team-a", wantExists: true},
+		{node: "eth-file", wantExists: true},
+		{node: "missing"},
+	}
+	for _, tt := range tests {
+		tenant, exists, err := registry.Tenant(ctx, tt.node)
+		if err != nil || tenant != tt.wantTenant || exists != tt.wantExists {
+			t.Errorf("Tenant(%s) = %q, %v, %v; want %q, %v", tt.node, tenant, exists, err, tt.wantTenant, tt.wantExists)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// RenderNotification renders a payload's title and message with the templates configured
+// for its event and a notification type. A template that fails to render is logged, and
+// the event's default template is used for it.
+func RenderNotification(cfg *config.NotificationConfig, notificationType string, payload notification.NotificationPayload, logger *logrus.Logger) notification.NotificationPayload {
+	tmpl := cfg.GetTemplate(notificationType, string(payload.Event))
+	rendered, err := notification.Render(payload, notification.Template{Title: tmpl.Title, Body: tmpl.Body})
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component":         "scheduler",
+			"node":              payload.NodeName,
+			"notification_type": notificationType,
+			"error":             err.Error(),
+		}).Warn("Failed to render notification template, using the default")
+	}
+	return rendered
+}
+
+// DeliverNotification sends a payload through one notification module and records the
+// attempt in the notification history, linked to the upload named by the payload's
+// upload_id detail. Notifications for a snoozed node are recorded as snoozed instead of
+// being sent. It returns the delivery error so callers can log it as before.
+func DeliverNotification(ctx context.Context, db Database, logger *logrus.Logger, module notification.NotificationModule, url string, payload notification.NotificationPayload) error {
+	if db == nil {
+		return notification.Deliver(ctx, module, url, payload).Err
+	}
+
+	record := database.NotificationAttempt{
+		UploadID:    payloadUploadID(payload),
+		NodeName:    payload.NodeName,
+		Event:       string(payload.Event),
+		Status:      database.NotificationSent,
+		AttemptedAt: time.Now(),
+	}
+
+	var attempt notification.Attempt
+	if snooze := activeSnooze(ctx, db, logger, payload.NodeName, record.AttemptedAt); snooze != nil {
+		attempt = notification.Attempt{Type: module.Name(), TargetHash: notification.TargetHash(url)}
+		record.Status = database.NotificationSnoozed
+		logger.WithFields(logrus.Fields{
+			"component":         "scheduler",
+			"node":              payload.NodeName,
+			"notification_type": attempt.Type,
+			"snoozed_by":        snooze.Actor,
+			"snoozed_until":     snooze.SnoozedUntil.UTC().Format(time.RFC3339),
+		}).Info("Notification not sent, node is snoozed")
+	} else {
+		attempt = notification.Deliver(ctx, module, url, payload)
+	}
+
+	record.NotificationType = attempt.Type
+	record.TargetHash = attempt.TargetHash
+	if attempt.StatusCode != 0 {
+		record.StatusCode = &attempt.StatusCode
+	}
+	if attempt.Err != nil {
+		message := attempt.Err.Error()
+		record.Status = database.NotificationFailed
+		record.ErrorMessage = &message
+	}
+
+	if err := db.RecordNotificationAttempt(ctx, record); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component":         "scheduler",
+			"node":              payload.NodeName,
+			"notification_type": attempt.Type,
+			"error":             err.Error(),
+		}).Warn("Failed to record notification attempt")
+	}
+
+	return attempt.Err
+}
+
+// activeSnooze returns the node's snooze in effect at now, or nil when the node is not
+// snoozed. Notifications are sent when the snoozes cannot be read.
+func activeSnooze(ctx context.Context, db Database, logger *logrus.Logger, nodeName string, now time.Time) *database.NotificationSnooze {
+	if nodeName == "" {
+		return nil
+	}
+
+	snooze, err := db.GetActiveNotificationSnooze(ctx, nodeName, now)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to check notification snooze")
+		return nil
+	}
+	return snooze
+}
+
+// payloadUploadID returns the upload a notification is about, from its upload_id detail
+func payloadUploadID(payload notification.NotificationPayload) *int64 {
+	switch id := payload.Details["upload_id"].(type) {
+	case int64:
+		return &id
+	case int:
+		value := int64(id)
+		return &value
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+func TestDeliverNotification(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var recorded []database.NotificationAttempt
+	db := &mockDatabase{
+		recordNotificationAttemptFunc: func(ctx context.Context, attempt database.NotificationAttempt) error {
+			recorded = append(recorded, attempt)
+			return nil
+		},
+	}
+
+	module := &mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			if payload.Event == notification.EventFailure {
+				return fmt.Errorf("webhook unreachable")
+			}
+			return nil
+		},
+	}
+	url := "https://example.com/webhook/secret"
+
+	ctx := context.Background()
+	if err := DeliverNotification(ctx, db, logger, module, url, notification.NotificationPayload{
+		Event:    notification.EventComplete,
+		NodeName: "test-node",
+		Details:  map[string]interface{}{"upload_id": int64(42)},
+	}); err != nil {
+		t.Fatalf("DeliverNotification() error = %v", err)
+	}
+	if err := DeliverNotification(ctx, db, logger, module, url, notification.NotificationPayload{
+		Event:    notification.EventFailure,
+		NodeName: "test-node",
+	}); err == nil {
+		t.Fatal("expected the delivery error to be returned")
+	}
+
+	if len(recorded) != 2 {
+		t.Fatalf("expected 2 recorded attempts, got %d", len(recorded))
+	}
+	sent := recorded[0]
+	if sent.UploadID == nil || *sent.UploadID != 42 || sent.Status != database.NotificationSent || sent.Event != "complete" ||
+		sent.NotificationType != "discord" || sent.TargetHash != notification.TargetHash(url) {
+		t.Errorf("unexpected sent attempt: %+v", sent)
+	}
+	failed := recorded[1]
+	if failed.UploadID != nil || failed.Status != database.NotificationFailed || failed.ErrorMessage == nil || *failed.ErrorMessage != "webhook unreachable" {
+		t.Errorf("unexpected failed attempt: %+v", failed)
+	}
+}
+
+func TestDeliverNotification_Snoozed(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var recorded []database.NotificationAttempt
+	db := &mockDatabase{
+		recordNotificationAttemptFunc: func(ctx context.Context, attempt database.NotificationAttempt) error {
+			recorded = append(recorded, attempt)
+			return nil
+		},
+		getActiveNotificationSnoozeFunc: func(ctx context.Context, nodeName string, now time.Time) (*database.NotificationSnooze, error) {
+			if nodeName == "snoozed-node" {
+				return &database.NotificationSnooze{NodeName: nodeName, SnoozedUntil: now.Add(time.Hour), Actor: "alice"}, nil
+			}
+			return nil, nil
+		},
+	}
+
+	var sent []string
+	module := &mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			sent = append(sent, payload.NodeName)
+			return nil
+		},
+	}
+
+	ctx := context.Background()
+	for _, nodeName := range []string{"snoozed-node", "other-node"} {
+		if err := DeliverNotification(ctx, db, logger, module, "https://example.com/webhook", notification.NotificationPayload{
+			Event:    notification.EventFailure,
+			NodeName: nodeName,
+		}); err != nil {
+			t.Fatalf("DeliverNotification() error = %v", err)
+		}
+	}
+
+	if len(sent) != 1 || sent[0] != "other-node" {
+		t.Errorf("expected only other-node to be notified, got %v", sent)
+	}
+	if len(recorded) != 2 || recorded[0].Status != database.NotificationSnoozed || recorded[1].Status != database.NotificationSent {
+		t.Errorf("expected a snoozed and a sent attempt, got %+v", recorded)
+	}
+	if recorded[0].NotificationType != "discord" || recorded[0].StatusCode != nil {
+		t.Errorf("unexpected snoozed attempt: %+v", recorded[0])
+	}
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"github.com/nodexeus/agent/internal/protocol"
+)
+
+// diskUsage returns the free and total bytes of the filesystem holding path
+func diskUsage(path string) (free, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
+
+// formatGB renders a byte count in gigabytes for preflight messages
+func formatGB(bytes uint64) string {
+	return fmt.Sprintf("%.1fGB", float64(bytes)/1e9)
+}
+
+// preflight checks the node's preflight gates before an upload is started and returns a
+// description of each failed gate. It returns nil when the node has no gates or all pass.
+func (j *NodeUploadJob) preflight(ctx context.Context, protocolModule protocol.ProtocolModule, metrics map[string]interface{}) []string {
+	gates := j.nodeConfig.Preflight
+	if gates == nil {
+		return nil
+	}
+
+	var failed []string
+
+	// The node's RPC answered the metric queries
+	if gates.RPC {
+		if collectErr, ok := metrics["error"]; ok {
+			failed = append(failed, fmt.Sprintf("rpc: %v", collectErr))
+		} else if metrics["latest_block"] == nil {
+			failed = append(failed, "rpc: latest_block not reported")
+		}
+	}
+
+	// The node is following the chain head
+	if gates.Synced {
+		if err := j.checkSynced(ctx, protocolModule); err != nil {
+			failed = append(failed, fmt.Sprintf("synced: %v", err))
+		}
+	}
+
+	// The host has room for the upload's working files
+	if gates.MinFreeDisk != "" {
+		if err := j.checkFreeDisk(); err != nil {
+			failed = append(failed, fmt.Sprintf("disk: %v", err))
+		}
+	}
+
+	// Operator-defined check
+	if len(gates.Command) > 0 {
+		cmdCtx, cancel := context.WithTimeout(ctx, gates.GetCommandTimeout())
+		err := j.uploadManager.RunPreflightCommand(cmdCtx, j.nodeName, gates.Command)
+		cancel()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("command: %v", err))
+		}
+	}
+
+	return failed
+}
+
+// checkSynced returns an error unless the protocol module reports the node is synced
+func (j *NodeUploadJob) checkSynced(ctx context.Context, protocolModule protocol.ProtocolModule) error {
+	syncModule, ok := protocolModule.(protocol.SyncModule)
+	if !ok {
+		return fmt.Errorf("protocol %s does not report sync status", protocolModule.Name())
+	}
+
+	syncing, err := syncModule.Syncing(ctx, j.nodeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to query sync status: %w", err)
+	}
+	if syncing {
+		return fmt.Errorf("node is syncing")
+	}
+	return nil
+}
+
+// checkFreeDisk returns an error when the free space on the preflight disk path is
+// below min_free_disk
+func (j *NodeUploadJob) checkFreeDisk() error {
+	gates := j.nodeConfig.Preflight
+	minBytes, minPercent, err := gates.GetMinFreeDisk()
+	if err != nil {
+		return err
+	}
+
+	path := gates.GetDiskPath()
+	free, total, err := j.diskUsage(path)
+	if err != nil {
+		return fmt.Errorf("failed to check free space on %s: %w", path, err)
+	}
+
+	if minPercent > 0 {
+		if total == 0 {
+			return fmt.Errorf("%s reports no capacity", path)
+		}
+		freePercent := float64(free) / float64(total) * 100
+		if freePercent < minPercent {
+			return fmt.Errorf("%.1f%% free on %s (%s), %.1f%% required", freePercent, path, formatGB(free), minPercent)
+		}
+		return nil
+	}
+
+	if free < minBytes {
+		return fmt.Errorf("%s free on %s, %s required", formatGB(free), path, formatGB(minBytes))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// mockSyncProtocolModule is a protocol module that reports sync status
+type mockSyncProtocolModule struct {
+	mockProtocolModule
+	syncing    bool
+	syncingErr error
+}
+
+func (m *mockSyncProtocolModule) Syncing(ctx context.Context, cfg config.NodeConfig) (bool, error) {
+	return m.syncing, m.syncingErr
+}
+
+func TestNodeUploadJob_Preflight(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	tests := []struct {
+		name        string
+		preflight   *config.PreflightConfig
+		protocol    string // "ethereum" reports sync status, "generic" does not
+		collectErr  error
+		syncing     bool
+		freeBytes   uint64
+		commandErr  error
+		wantStarted bool
+		wantGate    string // Gate named in the failure
+	}{
+		{name: "no gates", protocol: "ethereum", syncing: true, wantStarted: true},
+		{name: "all gates pass", preflight: &config.PreflightConfig{RPC: true, Synced: true, MinFreeDisk: "100GB", Command: []string{"check"}}, protocol: "ethereum", freeBytes: 500e9, wantStarted: true},
+		{name: "rpc down", preflight: &config.PreflightConfig{RPC: true}, protocol: "ethereum", collectErr: fmt.Errorf("connection refused"), wantGate: "rpc"},
+		{name: "node syncing", preflight: &config.PreflightConfig{Synced: true}, protocol: "ethereum", syncing: true, wantGate: "synced"},
+		{name: "sync status unsupported", preflight: &config.PreflightConfig{Synced: true}, protocol: "generic", wantGate: "synced"},
+		{name: "low disk bytes", preflight: &config.PreflightConfig{MinFreeDisk: "100GB"}, protocol: "ethereum", freeBytes: 50e9, wantGate: "disk"},
+		{name: "low disk percent", preflight: &config.PreflightConfig{MinFreeDisk: "10%"}, protocol: "ethereum", freeBytes: 50e9, wantGate: "disk"},
+		{name: "command fails", preflight: &config.PreflightConfig{Command: []string{"check", "{node}"}}, protocol: "ethereum", commandErr: fmt.Errorf("exit status 1"), wantGate: "command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := false
+			uploadManager := &mockUploadManager{
+				initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+					started = true
+					return 1, nil
+				},
+				runPreflightCommandFunc: func(ctx context.Context, nodeName string, command []string) error {
+					return tt.commandErr
+				},
+			}
+			var result string
+			db := &mockDatabase{
+				saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+					result = *state.LastResult
+					return nil
+				},
+			}
+
+			collect := func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+				return map[string]interface{}{"latest_block": int64(100)}, tt.collectErr
+			}
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockSyncProtocolModule{
+				mockProtocolModule: mockProtocolModule{name: "ethereum", collectMetricsFunc: collect},
+				syncing:            tt.syncing,
+			})
+			protocolRegistry.Register(&mockProtocolModule{name: "generic", collectMetricsFunc: collect})
+
+			var sent []notification.NotificationPayload
+			notifyRegistry := notification.NewRegistry()
+			notifyRegistry.Register(&mockNotificationModule{
+				name: "discord",
+				sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+					sent = append(sent, payload)
+					return nil
+				},
+			})
+			notifyConfig := &config.NotificationConfig{
+				Preflight: true,
+				Types:     map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+			}
+
+			job := NewNodeUploadJob(
+				"test-node",
+				config.NodeConfig{Protocol: tt.protocol, Schedule: "0 0 * * * *", Preflight: tt.preflight},
+				protocolRegistry,
+				uploadManager,
+				db,
+				notifyRegistry,
+				notifyConfig,
+				logger,
+			)
+			job.diskUsage = func(path string) (uint64, uint64, error) {
+				if path != config.DefaultPreflightDiskPath {
+					t.Errorf("expected disk check on %s, got %s", config.DefaultPreflightDiskPath, path)
+				}
+				return tt.freeBytes, 1000e9, nil
+			}
+			err := job.Run(context.Background())
+
+			if started != tt.wantStarted {
+				t.Fatalf("expected upload started=%v, got %v", tt.wantStarted, started)
+			}
+			if tt.wantStarted {
+				if err != nil || result != scheduleResultInitiated {
+					t.Errorf("expected initiated run, got result %q, error %v", result, err)
+				}
+				return
+			}
+
+			if err == nil || result != scheduleResultPreflightFailed {
+				t.Errorf("expected %q result with error, got result %q, error %v", scheduleResultPreflightFailed, result, err)
+			}
+			if len(sent) != 1 || sent[0].Event != notification.EventPreflight {
+				t.Fatalf("expected one preflight notification, got %v", sent)
+			}
+			gates, _ := sent[0].Details["failed_gates"].(string)
+			if !strings.HasPrefix(gates, tt.wantGate+": ") {
+				t.Errorf("expected failed gate %q, got %q", tt.wantGate, gates)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reconcile runs one monitor pass on startup, before the scheduler starts, so the running
+// uploads recorded in the database match their jobs before any upload is scheduled:
+// uploads that finished while the daemon was down are recorded as completed, failed or
+// cancelled, uploads a stopped daemon marked interrupted are re-attached, and uploads
+// started outside the daemon are registered. It returns the pass.
+func (j *UploadMonitorJob) Reconcile(ctx context.Context) (MonitorPass, error) {
+	pass, err := j.runPass(ctx)
+	if err != nil {
+		return pass, err
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component":  "scheduler",
+		"duration":   pass.Duration.String(),
+		"uploads":    pass.Uploads,
+		"completed":  pass.Completed,
+		"failed":     pass.Failed,
+		"cancelled":  pass.Cancelled,
+		"timed_out":  pass.TimedOut,
+		"discovered": pass.Discovered,
+	}).Info("Reconciled running uploads with their jobs")
+
+	return pass, nil
+}
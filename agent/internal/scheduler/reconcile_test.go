@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUploadMonitorJob_Reconcile(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	outcomes := map[string]upload.CompletionOutcome{
+		"finished-node":  upload.OutcomeSuccess,
+		"failed-node":    upload.OutcomeFailure,
+		"cancelled-node": upload.OutcomeCancelled,
+		"running-node":   upload.OutcomeRunning,
+	}
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: outcomes[nodeName]}, nil
+		},
+		checkUploadStatusFunc: func(ctx context.Context, nodeName string) (*upload.UploadStatus, error) {
+			if nodeName == "external-node" {
+				return &upload.UploadStatus{IsRunning: true}, nil
+			}
+			return &upload.UploadStatus{NotFound: true}, nil
+		},
+	}
+
+	started := time.Now().Add(-time.Hour)
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "finished-node", Status: "running", StartedAt: started},
+				{ID: 2, NodeName: "failed-node", Status: "running", StartedAt: started},
+				{ID: 3, NodeName: "cancelled-node", Status: "running", StartedAt: started},
+				{ID: 4, NodeName: "running-node", Status: "running", StartedAt: started},
+				{ID: 5, NodeName: "stuck-node", Status: "running", StartedAt: time.Now().Add(-13 * time.Hour)},
+			}, nil
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"finished-node":  {Protocol: "ethereum"},
+		"failed-node":    {Protocol: "ethereum"},
+		"cancelled-node": {Protocol: "ethereum"},
+		"running-node":   {Protocol: "ethereum"},
+		"stuck-node":     {Protocol: "ethereum", MaxDuration: "12h"},
+		"external-node":  {Protocol: "ethereum"},
+		"idle-node":      {Protocol: "ethereum"},
+	}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notification.NewRegistry(), nil, nodes, 0, logger)
+
+	pass, err := job.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if pass.Uploads != 5 || pass.Completed != 1 || pass.Failed != 1 || pass.Cancelled != 1 || pass.TimedOut != 1 {
+		t.Errorf("Expected each finished upload counted by outcome, got %+v", pass)
+	}
+	if pass.Probed != 2 || pass.Discovered != 1 {
+		t.Errorf("Expected the two idle nodes probed and the external upload registered, got %+v", pass)
+	}
+	// The reconciliation is the monitor's first pass
+	if last, ok := job.LastPass(); !ok || last.Completed != 1 {
+		t.Errorf("Expected the reconciliation recorded as the last pass, got %+v", last)
+	}
+}
+
+func TestUploadMonitorJob_ReconcileReportsDatabaseError(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	db := &mockDatabase{
+		listRunningUploadsFunc: func(ctx context.Context, page database.UploadPage) ([]database.Upload, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+	job := NewUploadMonitorJob(&mockUploadManager{}, db, protocol.NewRegistry(), notification.NewRegistry(), nil, nil, 0, logger)
+
+	if _, err := job.Reconcile(context.Background()); err == nil {
+		t.Error("Expected an error when the running uploads cannot be read")
+	}
+}
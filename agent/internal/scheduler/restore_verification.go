@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// restoreVerificationPollInterval is how often the restored client is queried while it starts
+const restoreVerificationPollInterval = 10 * time.Second
+
+// RestoreVerificationStore is the database the restore verification job reads completed
+// snapshots from and records its outcomes in
+type RestoreVerificationStore interface {
+	GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
+	SetUploadRestoreVerification(ctx context.Context, uploadID int64, outcome string, verifiedAt time.Time, message string) error
+}
+
+// RestoreVerificationJob proves that snapshots can be restored. For each node with
+// verification configured, it takes the latest completed snapshot not yet verified, runs
+// the restore commands that download it into a scratch location (or scratch bv node) and
+// start the client, and then queries the client until it reports the snapshot's block.
+// The outcome is recorded on the upload, so each snapshot is verified once.
+type RestoreVerificationJob struct {
+	store            RestoreVerificationStore
+	protocolRegistry *protocol.Registry
+	uploadManager    UploadManager
+	nodeConfigs      *nodeConfigSet
+	logger           *logrus.Logger
+	now              func() time.Time
+	pollInterval     time.Duration
+
+	// running is held for a whole run, which can take hours; an overlapping run is skipped
+	running sync.Mutex
+}
+
+// NewRestoreVerificationJob creates a new restore verification job
+func NewRestoreVerificationJob(
+	store RestoreVerificationStore,
+	protocolRegistry *protocol.Registry,
+	uploadManager UploadManager,
+	nodeConfigs map[string]config.NodeConfig,
+	logger *logrus.Logger,
+) *RestoreVerificationJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &RestoreVerificationJob{
+		store:            store,
+		protocolRegistry: protocolRegistry,
+		uploadManager:    uploadManager,
+		nodeConfigs:      newNodeConfigSet(nodeConfigs),
+		logger:           logger,
+		now:              time.Now,
+		pollInterval:     restoreVerificationPollInterval,
+	}
+}
+
+// SetNode starts verifying the snapshots of a node registered, or updated, at runtime
+func (j *RestoreVerificationJob) SetNode(cfg *config.Config, nodeName string) {
+	j.nodeConfigs.set(nodeName, cfg.Nodes[nodeName])
+}
+
+// RemoveNode stops verifying a deregistered node
+func (j *RestoreVerificationJob) RemoveNode(nodeName string) {
+	j.nodeConfigs.remove(nodeName)
+}
+
+// Run verifies the latest snapshot of every node with verification configured. Nodes
+// are verified one at a time, since each restore needs the scratch resources.
+func (j *RestoreVerificationJob) Run(ctx context.Context) error {
+	if !j.running.TryLock() {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       "restore_verification",
+		}).Debug("Previous restore verification still running, skipping")
+		return nil
+	}
+	defer j.running.Unlock()
+
+	nodes := j.nodeConfigs.all()
+	names := make([]string, 0, len(nodes))
+	for nodeName, nodeConfig := range nodes {
+		if nodeConfig.Verification != nil {
+			names = append(names, nodeName)
+		}
+	}
+	sort.Strings(names)
+
+	for _, nodeName := range names {
+		if err := j.verifyNode(ctx, nodeName, nodes[nodeName]); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Warn("Failed to verify snapshot restore")
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// verifyNode verifies the node's latest completed snapshot unless it has been verified
+func (j *RestoreVerificationJob) verifyNode(ctx context.Context, nodeName string, nodeConfig config.NodeConfig) error {
+	last, err := j.store.GetLatestCompletedUploadForNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get latest completed upload: %w", err)
+	}
+	if last == nil || last.RestoreVerification != nil {
+		return nil
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+		"upload_id": last.ID,
+	}).Info("Verifying snapshot restore")
+
+	outcome := database.RestoreVerified
+	message, err := j.verify(ctx, nodeName, nodeConfig, *last)
+	if err != nil {
+		// A verification interrupted by shutdown is retried by the next run
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		outcome = database.RestoreVerificationFailed
+		message = err.Error()
+	}
+
+	if err := j.store.SetUploadRestoreVerification(ctx, last.ID, outcome, j.now(), message); err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      nodeName,
+		"upload_id": last.ID,
+		"outcome":   outcome,
+	}
+	if outcome == database.RestoreVerified {
+		fields["message"] = message
+		j.logger.WithFields(fields).Info("Snapshot restore verified")
+	} else {
+		fields["error"] = message
+		j.logger.WithFields(fields).Error("Snapshot restore verification failed")
+	}
+	return nil
+}
+
+// verify restores a snapshot and waits for the restored client to report its block,
+// returning what the client reported
+func (j *RestoreVerificationJob) verify(ctx context.Context, nodeName string, nodeConfig config.NodeConfig, last database.Upload) (string, error) {
+	v := nodeConfig.Verification
+	module, err := j.protocolRegistry.Get(nodeConfig.Protocol)
+	if err != nil {
+		return "", fmt.Errorf("failed to get protocol module: %w", err)
+	}
+
+	scratchDir := v.GetScratchDir(nodeName)
+	if err := os.MkdirAll(scratchDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+
+	env := map[string]string{
+		"NODE_NAME":   nodeName,
+		"PROTOCOL":    nodeConfig.Protocol,
+		"UPLOAD_ID":   strconv.FormatInt(last.ID, 10),
+		"SCRATCH_DIR": scratchDir,
+	}
+	expected, hasExpected := toInt64(last.ProtocolData["latest_block"])
+	if hasExpected {
+		env["SNAPSHOT_BLOCK"] = strconv.FormatInt(expected, 10)
+	}
+
+	// Cleanup runs even when the restore failed halfway or the daemon is stopping
+	defer j.cleanup(context.WithoutCancel(ctx), nodeName, v, scratchDir, env)
+
+	restoreCtx, cancel := context.WithTimeout(ctx, v.GetTimeout())
+	defer cancel()
+	for _, command := range v.Restore {
+		if err := j.uploadManager.RunHook(restoreCtx, nodeName, command, env); err != nil {
+			return "", fmt.Errorf("restore command %q failed: %w", command, err)
+		}
+	}
+
+	// The restored client is queried like the node itself, at its own endpoint
+	clientConfig := nodeConfig
+	clientConfig.URL = v.RPCURL
+	minBlock := expected - v.BlockTolerance
+	startupCtx, cancelStartup := context.WithTimeout(ctx, v.GetStartupTimeout())
+	defer cancelStartup()
+
+	for {
+		var lastErr error
+		metrics, err := module.CollectMetrics(startupCtx, clientConfig)
+		switch block, ok := toInt64(metrics["latest_block"]); {
+		case err != nil:
+			lastErr = err
+		case !ok:
+			lastErr = errors.New("restored client did not report latest_block")
+		case !hasExpected && block > 0:
+			return fmt.Sprintf("restored client reported block %d; the snapshot has no recorded block", block), nil
+		case hasExpected && block >= minBlock:
+			return fmt.Sprintf("restored client reported block %d for snapshot block %d", block, expected), nil
+		default:
+			lastErr = fmt.Errorf("restored client reported block %d, expected at least %d", block, minBlock)
+		}
+
+		select {
+		case <-startupCtx.Done():
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", fmt.Errorf("restored client did not report the snapshot's block within %s: %w", v.GetStartupTimeout(), lastErr)
+		case <-time.After(j.pollInterval):
+		}
+	}
+}
+
+// cleanup runs the cleanup commands and removes the default scratch directory. A failed
+// cleanup does not change the verification outcome, but can break the next restore.
+func (j *RestoreVerificationJob) cleanup(ctx context.Context, nodeName string, v *config.VerificationConfig, scratchDir string, env map[string]string) {
+	for _, command := range v.Cleanup {
+		cleanupCtx, cancel := context.WithTimeout(ctx, config.DefaultHookTimeout)
+		err := j.uploadManager.RunHook(cleanupCtx, nodeName, command, env)
+		cancel()
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      nodeName,
+				"command":   command,
+				"error":     err.Error(),
+			}).Warn("Restore verification cleanup command failed")
+		}
+	}
+
+	// A configured scratch directory is left to the cleanup commands
+	if v.ScratchDir == "" {
+		if err := os.RemoveAll(scratchDir); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Warn("Failed to remove restore verification scratch directory")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// mockRestoreVerificationStore holds one completed upload per node and the outcomes recorded
+type mockRestoreVerificationStore struct {
+	uploads map[string]*database.Upload
+}
+
+func (m *mockRestoreVerificationStore) GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	return m.uploads[nodeName], nil
+}
+
+func (m *mockRestoreVerificationStore) SetUploadRestoreVerification(ctx context.Context, uploadID int64, outcome string, verifiedAt time.Time, message string) error {
+	for _, upload := range m.uploads {
+		if upload.ID == uploadID {
+			upload.RestoreVerification = &outcome
+			upload.RestoreVerifiedAt = &verifiedAt
+			upload.RestoreVerificationMessage = &message
+			return nil
+		}
+	}
+	return errors.New("upload not found")
+}
+
+func TestRestoreVerificationJob(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	tests := []struct {
+		name         string
+		failCommand  string
+		blocks       []int64 // latest_block reported by the restored client on each query
+		protocolData database.JSONB
+		wantOutcome  string
+		wantMessage  string
+		wantQueried  bool
+	}{
+		{
+			name:         "client reaches the snapshot block",
+			blocks:       []int64{0, 20990000, 21000000},
+			protocolData: database.JSONB{"latest_block": float64(21000000)},
+			wantOutcome:  database.RestoreVerified,
+			wantMessage:  "restored client reported block 21000000 for snapshot block 21000000",
+			wantQueried:  true,
+		},
+		{
+			name:         "snapshot without a recorded block",
+			blocks:       []int64{5},
+			protocolData: database.JSONB{},
+			wantOutcome:  database.RestoreVerified,
+			wantMessage:  "the snapshot has no recorded block",
+			wantQueried:  true,
+		},
+		{
+			name:         "restore command fails",
+			failCommand:  "start-client",
+			protocolData: database.JSONB{"latest_block": float64(21000000)},
+			wantOutcome:  database.RestoreVerificationFailed,
+			wantMessage:  `restore command "start-client" failed`,
+		},
+		{
+			name:         "client stays behind",
+			blocks:       []int64{20000000},
+			protocolData: database.JSONB{"latest_block": float64(21000000)},
+			wantOutcome:  database.RestoreVerificationFailed,
+			wantMessage:  "restored client reported block 20000000, expected at least 20999900",
+			wantQueried:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TMPDIR", t.TempDir())
+
+			store := &mockRestoreVerificationStore{uploads: map[string]*database.Upload{
+				"eth-node": {ID: 42, NodeName: "eth-node", Status: "completed", ProtocolData: tt.protocolData},
+			}}
+
+			var commands []string
+			var scratchDir string
+			uploadManager := &mockUploadManager{
+				runHookFunc: func(ctx context.Context, nodeName string, command string, env map[string]string) error {
+					commands = append(commands, command)
+					scratchDir = env["SCRATCH_DIR"]
+					if env["NODE_NAME"] != "eth-node" || env["UPLOAD_ID"] != "42" {
+						t.Errorf("unexpected command environment: %v", env)
+					}
+					if command == tt.failCommand {
+						return errors.New("exit status 1")
+					}
+					return nil
+				},
+			}
+
+			queries := 0
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{
+				name: "ethereum",
+				collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+					if cfg.URL != "http://localhost:18545" {
+						t.Errorf("expected the restored client to be queried, got %s", cfg.URL)
+					}
+					block := tt.blocks[len(tt.blocks)-1]
+					if queries < len(tt.blocks) {
+						block = tt.blocks[queries]
+					}
+					queries++
+					if block == 0 {
+						return nil, errors.New("connection refused")
+					}
+					return map[string]interface{}{"latest_block": block}, nil
+				},
+			})
+
+			nodes := map[string]config.NodeConfig{
+				"eth-node": {Protocol: "ethereum", Verification: &config.VerificationConfig{
+					Restore:        []string{"download-snapshot", "start-client"},
+					Cleanup:        []string{"stop-client"},
+					RPCURL:         "http://localhost:18545",
+					StartupTimeout: "100ms",
+					BlockTolerance: 100,
+				}},
+				"arb-node": {Protocol: "arbitrum"},
+			}
+
+			job := NewRestoreVerificationJob(store, protocolRegistry, uploadManager, nodes, logger)
+			job.pollInterval = time.Millisecond
+			if err := job.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			upload := store.uploads["eth-node"]
+			if upload.RestoreVerification == nil || *upload.RestoreVerification != tt.wantOutcome {
+				t.Fatalf("expected outcome %s, got %v", tt.wantOutcome, upload.RestoreVerification)
+			}
+			if !strings.Contains(*upload.RestoreVerificationMessage, tt.wantMessage) {
+				t.Errorf("expected message containing %q, got %q", tt.wantMessage, *upload.RestoreVerificationMessage)
+			}
+			if (queries > 0) != tt.wantQueried {
+				t.Errorf("expected queried = %v, got %d queries", tt.wantQueried, queries)
+			}
+			// Cleanup always runs, and the default scratch directory is removed
+			if commands[len(commands)-1] != "stop-client" {
+				t.Errorf("expected cleanup to run last, got %v", commands)
+			}
+			if scratchDir != filepath.Join(os.TempDir(), "snapperd-verify", "eth-node") {
+				t.Errorf("unexpected scratch directory %s", scratchDir)
+			}
+			if _, err := os.Stat(scratchDir); !os.IsNotExist(err) {
+				t.Errorf("expected the scratch directory to be removed, got %v", err)
+			}
+
+			// A verified snapshot is not restored again
+			commands = nil
+			if err := job.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(commands) != 0 {
+				t.Errorf("expected the snapshot to be verified once, ran %v", commands)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// retryResultSuperseded is the outcome of a retry no longer needed, because an upload of
+// the node completed after the failed one
+const retryResultSuperseded = "superseded"
+
+// scheduleRetry queues the next attempt of a failed upload to start after the node's
+// retry backoff, until the upload has been attempted max_attempts times. The attempt and
+// when it starts are added to the failure notification's details.
+func (j *UploadMonitorJob) scheduleRetry(ctx context.Context, u database.Upload, details map[string]interface{}) {
+	nodeConfig, exists := j.nodeConfigs.get(u.NodeName)
+	if !exists || nodeConfig.Retry == nil {
+		return
+	}
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      u.NodeName,
+		"upload_id": u.ID,
+	}
+	attempt := max(u.Attempt, 1)
+	details["attempt"] = attempt
+	if attempt >= nodeConfig.Retry.MaxAttempts {
+		j.logger.WithFields(fields).WithField("attempt", attempt).Warn("Upload failed on its last attempt, not retrying")
+		recordNodeAction(ctx, j.db, j.logger, database.NodeAction{
+			NodeName: u.NodeName,
+			UploadID: &u.ID,
+			Action:   database.NodeActionRetriesExhausted,
+			Message:  fmt.Sprintf("Upload failed on attempt %d of %d, not retrying", attempt, nodeConfig.Retry.MaxAttempts),
+		})
+		return
+	}
+
+	notBefore := j.now().Add(nodeConfig.Retry.Delay(attempt))
+	metadata := database.JSONB{"retry_of": u.ID, "attempt": attempt + 1}
+	requestID, err := j.db.CreateDelayedUploadRequest(ctx, u.NodeName, string(upload.TriggerRetry), nodeConfig.Priority, metadata, notBefore)
+	if err != nil {
+		j.logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to schedule retry of failed upload")
+		return
+	}
+
+	details["next_attempt_at"] = notBefore.UTC().Format(time.RFC3339)
+	fields["request_id"] = requestID
+	fields["attempt"] = attempt + 1
+	fields["not_before"] = notBefore.UTC().Format(time.RFC3339)
+	j.logger.WithFields(fields).Info("Scheduled retry of failed upload")
+	recordNodeAction(ctx, j.db, j.logger, database.NodeAction{
+		NodeName: u.NodeName,
+		UploadID: &u.ID,
+		Action:   database.NodeActionRetryScheduled,
+		Message:  fmt.Sprintf("Scheduled attempt %d of %d after the upload failed", attempt+1, nodeConfig.Retry.MaxAttempts),
+		Details:  database.JSONB{"request_id": requestID, "attempt": attempt + 1, "not_before": notBefore.UTC().Format(time.RFC3339)},
+	})
+}
+
+// retryTrigger returns the trigger of a queued retry, with the attempt and failed upload
+// recorded in its metadata
+func retryTrigger(metadata map[string]interface{}) upload.Trigger {
+	trigger := upload.Trigger{Type: upload.TriggerRetry, Metadata: metadata}
+	if attempt, ok := toInt64(metadata["attempt"]); ok {
+		trigger.Attempt = int(attempt)
+	}
+	if retryOf, ok := toInt64(metadata["retry_of"]); ok {
+		trigger.RetryOf = &retryOf
+	}
+	return trigger
+}
+
+// RunRetry executes the node upload workflow for a retry of a failed upload taken from
+// the upload queue. The retry is skipped while the node is disabled or paused, and when an
+// upload of the node completed after the failed one. The schedule state is left untouched.
+func (j *NodeUploadJob) RunRetry(ctx context.Context, trigger upload.Trigger) (string, int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.paused(ctx) {
+		return scheduleResultPaused, 0, nil
+	}
+
+	if trigger.RetryOf != nil {
+		latest, err := j.db.GetLatestCompletedUploadForNode(ctx, j.nodeName)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      j.nodeName,
+				"error":     err.Error(),
+			}).Warn("Failed to check for a later completed upload, retrying anyway")
+		} else if latest != nil && latest.ID > *trigger.RetryOf {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      j.nodeName,
+				"retry_of":  *trigger.RetryOf,
+				"upload_id": latest.ID,
+			}).Info("Upload completed since the failure, skipping retry")
+			return retryResultSuperseded, 0, nil
+		}
+	}
+
+	return j.run(ctx, j.now(), trigger)
+}
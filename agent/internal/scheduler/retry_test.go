@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUploadMonitorJob_SchedulesRetry(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	status := "Finished with exit code 1 and message 'Upload failed'"
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeFailure, Message: &status}, nil
+		},
+	}
+
+	type retryRequest struct {
+		nodeName  string
+		priority  int
+		metadata  database.JSONB
+		notBefore time.Time
+	}
+	var mu sync.Mutex
+	var requests []retryRequest
+	var actions []database.NodeAction
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "retry-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "last-attempt-node", Status: "running", StartedAt: time.Now().Add(-time.Hour), Attempt: 3},
+				{ID: 3, NodeName: "plain-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		createDelayedUploadRequestFunc: func(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB, notBefore time.Time) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if triggerType != string(upload.TriggerRetry) {
+				t.Errorf("Expected a retry request, got %s", triggerType)
+			}
+			requests = append(requests, retryRequest{nodeName: nodeName, priority: priority, metadata: triggerMetadata, notBefore: notBefore})
+			return 11, nil
+		},
+		recordNodeActionFunc: func(ctx context.Context, action database.NodeAction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actions = append(actions, action)
+			return nil
+		},
+	}
+
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+	notifyConfig := &config.NotificationConfig{
+		Failure: true,
+		Types:   map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+
+	retry := &config.RetryConfig{MaxAttempts: 3, Backoff: "5m"}
+	nodes := map[string]config.NodeConfig{
+		"retry-node":        {Protocol: "ethereum", Priority: 4, Retry: retry},
+		"last-attempt-node": {Protocol: "ethereum", Retry: retry},
+		"plain-node":        {Protocol: "ethereum"},
+	}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("Expected one retry queued, got %+v", requests)
+	}
+	request := requests[0]
+	if request.nodeName != "retry-node" || request.priority != 4 || !request.notBefore.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("Expected retry-node's retry at its priority after the backoff, got %+v", request)
+	}
+	if request.metadata["retry_of"] != int64(1) || request.metadata["attempt"] != 2 {
+		t.Errorf("Expected attempt 2 of upload 1, got %v", request.metadata)
+	}
+
+	byAction := make(map[string]database.NodeAction)
+	for _, action := range actions {
+		byAction[action.Action] = action
+	}
+	if scheduled, ok := byAction[database.NodeActionRetryScheduled]; !ok || scheduled.NodeName != "retry-node" {
+		t.Errorf("Expected the retry in retry-node's action log, got %+v", actions)
+	}
+	if exhausted, ok := byAction[database.NodeActionRetriesExhausted]; !ok || exhausted.NodeName != "last-attempt-node" {
+		t.Errorf("Expected the exhausted retries in last-attempt-node's action log, got %+v", actions)
+	}
+
+	// The failure notification tells when the next attempt starts
+	for _, payload := range sent {
+		_, hasRetry := payload.Details["next_attempt_at"]
+		if hasRetry != (payload.NodeName == "retry-node") {
+			t.Errorf("Unexpected next attempt in %s's failure notification: %v", payload.NodeName, payload.Details)
+		}
+	}
+	if len(sent) != 3 {
+		t.Errorf("Expected a failure notification per node, got %d", len(sent))
+	}
+}
+
+func TestUploadRequestJob_RunsRetries(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	triggers := make(map[string]upload.Trigger)
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			triggers[nodeName] = trigger
+			return 20, nil
+		},
+	}
+	db := &mockDatabase{
+		getLatestCompletedUploadForNodeFunc: func(ctx context.Context, nodeName string) (*database.Upload, error) {
+			// node-b completed a scheduled upload after its failure
+			if nodeName == "node-b" {
+				return &database.Upload{ID: 9, NodeName: nodeName, Status: "completed"}, nil
+			}
+			return &database.Upload{ID: 2, NodeName: nodeName, Status: "completed"}, nil
+		},
+	}
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+	jobs := make(map[string]*NodeUploadJob)
+	for _, name := range []string{"node-a", "node-b", "node-c"} {
+		jobs[name] = NewNodeUploadJob(name, config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *"},
+			protocolRegistry, uploadManager, db, notification.NewRegistry(), nil, logger)
+	}
+
+	later := time.Now().Add(time.Hour)
+	retry := string(upload.TriggerRetry)
+	store := &mockUploadRequestStore{
+		pending: map[string][]database.UploadRequest{
+			"node-a": {{ID: 1, NodeName: "node-a", TriggerType: retry, TriggerMetadata: database.JSONB{"retry_of": float64(5), "attempt": float64(2)}}},
+			"node-b": {{ID: 2, NodeName: "node-b", TriggerType: retry, TriggerMetadata: database.JSONB{"retry_of": float64(6), "attempt": float64(2)}}},
+			"node-c": {{ID: 3, NodeName: "node-c", TriggerType: retry, TriggerMetadata: database.JSONB{"retry_of": float64(7), "attempt": float64(2)}, NotBefore: &later}},
+		},
+		outcomes: make(map[int64]uploadRequestOutcome),
+	}
+
+	job := NewUploadRequestJob(store, jobs, "host-a", 100, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if outcome := store.outcomes[1]; outcome.status != database.UploadRequestInitiated {
+		t.Errorf("Expected node-a's retry initiated, got %+v", outcome)
+	}
+	trigger := triggers["node-a"]
+	if trigger.Type != upload.TriggerRetry || trigger.Attempt != 2 || trigger.RetryOf == nil || *trigger.RetryOf != 5 {
+		t.Errorf("Expected attempt 2 of upload 5, got %+v", trigger)
+	}
+
+	if outcome := store.outcomes[2]; outcome.status != database.UploadRequestSkipped || outcome.errorMessage == nil || *outcome.errorMessage != "an upload completed since the failure" {
+		t.Errorf("Expected node-b's retry skipped as superseded, got %+v", outcome)
+	}
+	if _, ok := triggers["node-b"]; ok {
+		t.Error("Expected no upload for node-b's superseded retry")
+	}
+
+	// A retry still in its backoff stays queued
+	if _, ok := store.outcomes[3]; ok || len(store.pending["node-c"]) != 1 {
+		t.Errorf("Expected node-c's retry to wait for its backoff, got %+v", store.outcomes[3])
+	}
+}
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// Outcomes recorded as last_result in the schedule state
+const (
+	scheduleResultInitiated = "initiated"
+	scheduleResultSkipped   = "skipped"
+	scheduleResultFailed    = "failed"
+	// Recorded while a run holds the upload until the snapshot block is finalized
+	scheduleResultWaitingFinality = "waiting_finality"
+	// Recorded while a run waits in the upload queue for the concurrency limit
+	scheduleResultQueued = "queued"
+	// Recorded when the collected metrics violate the node's validation thresholds
+	scheduleResultInvalidMetrics = "invalid_metrics"
+	// Recorded when the node fails one of its preflight health gates
+	scheduleResultPreflightFailed = "preflight_failed"
+	// Recorded when the node is disabled in the configuration or paused by an operator
+	scheduleResultPaused = "paused"
+)
+
+// nextScheduledRun returns the next run time after now for a 6-field cron schedule whose
+// runs are delayed by offset
+func nextScheduledRun(schedule string, offset time.Duration, now time.Time) (time.Time, error) {
+	parsed, err := ParseSchedule(schedule, offset)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parsed.Next(now), nil
+}
+
+// SplayOffset returns how long the node's scheduled runs are delayed by its splay
+func (j *NodeUploadJob) SplayOffset() time.Duration {
+	return SplayOffset(j.nodeName, j.nodeConfig.GetSplay())
+}
+
+// saveScheduleState persists the run's outcome and the node's next scheduled run
+func (j *NodeUploadJob) saveScheduleState(ctx context.Context, startedAt time.Time, result string) {
+	state := database.ScheduleState{
+		NodeName:   j.nodeName,
+		LastRunAt:  &startedAt,
+		LastResult: &result,
+	}
+	if nextRun, err := nextScheduledRun(j.nodeConfig.Schedule, j.SplayOffset(), j.now()); err == nil {
+		state.NextRunAt = &nextRun
+	}
+
+	if err := j.db.SaveScheduleState(ctx, state); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      j.nodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to save schedule state")
+	}
+}
+
+// RunOnStart reports whether the node runs an upload once when the daemon starts
+func (j *NodeUploadJob) RunOnStart() bool {
+	return j.nodeConfig.RunOnStart
+}
+
+// Resume reconciles the persisted schedule state with the node's schedule at startup.
+// It reports whether a scheduled run was missed while the daemon was stopped and the
+// node is configured to catch up; otherwise the next run is recorded and the missed
+// run is skipped.
+func (j *NodeUploadJob) Resume(ctx context.Context) (bool, error) {
+	now := j.now()
+
+	state, err := j.db.GetScheduleState(ctx, j.nodeName)
+	if err != nil {
+		return false, err
+	}
+
+	nextRun, err := nextScheduledRun(j.nodeConfig.Schedule, j.SplayOffset(), now)
+	if err != nil {
+		return false, err
+	}
+
+	// Without a recorded next run, the run after the last recorded one is the one due
+	var due *time.Time
+	if state != nil && state.NextRunAt != nil {
+		due = state.NextRunAt
+	} else if state != nil && state.LastRunAt != nil {
+		if next, err := nextScheduledRun(j.nodeConfig.Schedule, j.SplayOffset(), *state.LastRunAt); err == nil {
+			due = &next
+		}
+	}
+
+	missed := due != nil && due.Before(now)
+	if missed {
+		fields := logrus.Fields{
+			"component":   "scheduler",
+			"node":        j.nodeName,
+			"missed_run":  due.UTC().Format(time.RFC3339),
+			"next_run_at": nextRun.UTC().Format(time.RFC3339),
+		}
+		if j.nodeConfig.CatchUp {
+			j.logger.WithFields(fields).Info("Scheduled upload missed while stopped, catching up")
+			return true, nil
+		}
+		j.logger.WithFields(fields).Info("Scheduled upload missed while stopped, waiting for next run")
+	}
+
+	// Keep the recorded history and refresh the next run for the current schedule
+	updated := database.ScheduleState{NodeName: j.nodeName, NextRunAt: &nextRun}
+	if state != nil {
+		updated.LastRunAt = state.LastRunAt
+		updated.LastResult = state.LastResult
+	}
+	if err := j.db.SaveScheduleState(ctx, updated); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNodeUploadJob_RecordsScheduleState(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	disabled := false
+	tests := []struct {
+		name       string
+		shouldSkip bool
+		enabled    *bool
+		pause      *database.NodePause
+		want       string
+	}{
+		{name: "initiated", shouldSkip: false, want: scheduleResultInitiated},
+		{name: "skipped", shouldSkip: true, want: scheduleResultSkipped},
+		{name: "disabled", enabled: &disabled, want: scheduleResultPaused},
+		{name: "paused", pause: &database.NodePause{NodeName: "test-node", Actor: "alice"}, want: scheduleResultPaused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved *database.ScheduleState
+			db := &mockDatabase{
+				saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+					saved = &state
+					return nil
+				},
+				getNodePauseFunc: func(ctx context.Context, nodeName string) (*database.NodePause, error) {
+					return tt.pause, nil
+				},
+			}
+			uploadManager := &mockUploadManager{
+				shouldSkipFunc: func(ctx context.Context, nodeName string) (bool, error) {
+					if tt.want == scheduleResultPaused {
+						t.Error("expected a paused node not to check for a running upload")
+					}
+					return tt.shouldSkip, nil
+				},
+			}
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+			job := NewNodeUploadJob(
+				"test-node",
+				config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", Enabled: tt.enabled},
+				protocolRegistry,
+				uploadManager,
+				db,
+				notification.NewRegistry(),
+				nil,
+				logger,
+			)
+			now := time.Date(2024, 12, 9, 10, 15, 0, 0, time.UTC)
+			job.now = func() time.Time { return now }
+
+			if err := job.Run(context.Background()); err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+
+			if saved == nil {
+				t.Fatal("expected schedule state to be saved")
+			}
+			if saved.LastResult == nil || *saved.LastResult != tt.want {
+				t.Errorf("expected last_result %s, got %v", tt.want, saved.LastResult)
+			}
+			if saved.LastRunAt == nil || !saved.LastRunAt.Equal(now) {
+				t.Errorf("expected last_run_at %v, got %v", now, saved.LastRunAt)
+			}
+			wantNext := time.Date(2024, 12, 9, 11, 0, 0, 0, time.UTC)
+			if saved.NextRunAt == nil || !saved.NextRunAt.Equal(wantNext) {
+				t.Errorf("expected next_run_at %v, got %v", wantNext, saved.NextRunAt)
+			}
+		})
+	}
+}
+
+func TestNodeUploadJob_Resume(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2024, 12, 9, 10, 15, 0, 0, time.UTC)
+	past := now.Add(-15 * time.Minute)
+	future := now.Add(45 * time.Minute)
+	lastRun := now.Add(-75 * time.Minute)
+
+	tests := []struct {
+		name        string
+		state       *database.ScheduleState
+		catchUp     bool
+		wantCatchUp bool
+		wantSaved   bool
+	}{
+		{name: "first boot", state: nil, wantSaved: true},
+		{name: "next run still ahead", state: &database.ScheduleState{NextRunAt: &future, LastRunAt: &lastRun}, catchUp: true, wantSaved: true},
+		{name: "missed run without catch up", state: &database.ScheduleState{NextRunAt: &past, LastRunAt: &lastRun}, wantSaved: true},
+		{name: "missed run with catch up", state: &database.ScheduleState{NextRunAt: &past, LastRunAt: &lastRun}, catchUp: true, wantCatchUp: true},
+		{name: "missed run after the last run", state: &database.ScheduleState{LastRunAt: &lastRun}, catchUp: true, wantCatchUp: true},
+		{name: "no run due after the last run", state: &database.ScheduleState{LastRunAt: &past}, catchUp: true, wantSaved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved *database.ScheduleState
+			db := &mockDatabase{
+				getScheduleStateFunc: func(ctx context.Context, nodeName string) (*database.ScheduleState, error) {
+					return tt.state, nil
+				},
+				saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+					saved = &state
+					return nil
+				},
+			}
+
+			job := NewNodeUploadJob(
+				"test-node",
+				config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", CatchUp: tt.catchUp},
+				protocol.NewRegistry(),
+				&mockUploadManager{},
+				db,
+				notification.NewRegistry(),
+				nil,
+				logger,
+			)
+			job.now = func() time.Time { return now }
+
+			catchUp, err := job.Resume(context.Background())
+			if err != nil {
+				t.Fatalf("Resume returned error: %v", err)
+			}
+			if catchUp != tt.wantCatchUp {
+				t.Errorf("expected catch up %v, got %v", tt.wantCatchUp, catchUp)
+			}
+			if (saved != nil) != tt.wantSaved {
+				t.Fatalf("expected state saved %v, got %+v", tt.wantSaved, saved)
+			}
+			if saved != nil {
+				wantNext := time.Date(2024, 12, 9, 11, 0, 0, 0, time.UTC)
+				if saved.NextRunAt == nil || !saved.NextRunAt.Equal(wantNext) {
+					t.Errorf("expected next_run_at %v, got %v", wantNext, saved.NextRunAt)
+				}
+				if tt.state != nil && (saved.LastRunAt == nil || !saved.LastRunAt.Equal(*tt.state.LastRunAt)) {
+					t.Errorf("expected last_run_at to be preserved, got %v", saved.LastRunAt)
+				}
+			}
+		})
+	}
+}
+
+func TestNodeUploadJob_ResumeWithSplay(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var saved *database.ScheduleState
+	db := &mockDatabase{
+		saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+			saved = &state
+			return nil
+		},
+	}
+	job := NewNodeUploadJob(
+		"test-node",
+		config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", Splay: "30m"},
+		protocol.NewRegistry(),
+		&mockUploadManager{},
+		db,
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+	now := time.Date(2024, 12, 9, 10, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	offset := job.SplayOffset()
+	if offset != SplayOffset("test-node", 30*time.Minute) || offset <= 0 {
+		t.Fatalf("expected the node's splay offset, got %v", offset)
+	}
+	if _, err := job.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume returned error: %v", err)
+	}
+
+	// The top of the hour has passed, but its delayed run has not
+	wantNext := now.Add(offset)
+	if saved == nil || saved.NextRunAt == nil || !saved.NextRunAt.Equal(wantNext) {
+		t.Errorf("expected next_run_at %v, got %+v", wantNext, saved)
+	}
+}
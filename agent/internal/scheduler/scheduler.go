@@ -1,8 +1,11 @@
 package scheduler
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,8 +13,10 @@ import (
 
 	"github.com/nodexeus/agent/internal/config"
 	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/engine"
 	"github.com/nodexeus/agent/internal/notification"
 	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/tracing"
 	"github.com/nodexeus/agent/internal/upload"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
@@ -35,12 +40,22 @@ type Scheduler interface {
 	Stop(ctx context.Context) error
 }
 
+// JobID identifies a job added with ScheduleJob
+type JobID cron.EntryID
+
 // CronScheduler implements the Scheduler interface using robfig/cron
 type CronScheduler struct {
-	cron   *cron.Cron
-	logger *logrus.Logger
-	wg     sync.WaitGroup
-	mu     sync.Mutex
+	cron    *cron.Cron
+	logger  *logrus.Logger
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	now     func() time.Time
+	started bool
+	loc     *time.Location // Timezone of schedules that do not name their own
+
+	store JobStateStore           // Where runs of named jobs are recorded (nil disables)
+	host  string                  // This daemon's host in the job state
+	named map[string]scheduledJob // Cron entries of named jobs by name
 }
 
 // NewCronScheduler creates a new cron-based scheduler
@@ -52,28 +67,118 @@ func NewCronScheduler(logger *logrus.Logger) *CronScheduler {
 	return &CronScheduler{
 		cron:   cron.New(cron.WithSeconds()),
 		logger: logger,
+		now:    time.Now,
+		loc:    time.Local,
+		named:  make(map[string]scheduledJob),
 	}
 }
 
+// SetLocation evaluates schedules that do not name a timezone of their own, with a
+// CRON_TZ= prefix, in loc instead of the host clock's timezone. Call it before adding jobs.
+func (s *CronScheduler) SetLocation(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron = cron.New(cron.WithSeconds(), cron.WithLocation(loc))
+	s.loc = loc
+}
+
 // AddJob registers a job with a cron schedule
 func (s *CronScheduler) AddJob(schedule string, job Job) error {
+	_, err := s.ScheduleJob(schedule, 0, job)
+	return err
+}
+
+// ScheduleJob registers a job with a cron schedule whose runs are delayed by splay, see
+// SplayOffset, and returns its ID, so the job can be removed later. Jobs can be added
+// before or after Start.
+func (s *CronScheduler) ScheduleJob(schedule string, splay time.Duration, job Job) (JobID, error) {
+	parsed, err := ParseSchedule(schedule, splay)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add job with schedule %s: %w", schedule, err)
+	}
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	id := s.cron.Schedule(parsed, cron.FuncJob(s.wrap(job)))
+	name := jobName(job)
+	if name != "" {
+		s.named[name] = scheduledJob{entry: id, schedule: schedule}
+	}
+	started := s.started
+	s.mu.Unlock()
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"schedule":  schedule,
+	}
+	if splay > 0 {
+		fields["splay"] = splay.String()
+	}
+	if name != "" {
+		fields["job"] = name
+	}
+	s.logger.WithFields(fields).Info("Job added to scheduler")
+
+	// Jobs added before Start are recorded when it starts the cron entries
+	if name != "" && started {
+		s.saveJobSchedule(name)
+	}
+
+	return JobID(id), nil
+}
+
+// RemoveJob stops scheduling a job. A run already in progress is not interrupted.
+func (s *CronScheduler) RemoveJob(id JobID) {
+	s.mu.Lock()
+	s.cron.Remove(cron.EntryID(id))
+
+	// A job replaced under the same name keeps its state
+	var removed string
+	for name, scheduled := range s.named {
+		if scheduled.entry == cron.EntryID(id) {
+			removed = name
+			delete(s.named, name)
+		}
+	}
+	s.mu.Unlock()
+
+	if removed != "" {
+		s.forgetJobState(removed)
+	}
+}
 
-	// Wrap the job to handle panics and logging
-	wrappedJob := func() {
+// RunNow executes a job once in the background, outside its schedule. The run is
+// tracked so Stop waits for it like any scheduled run.
+func (s *CronScheduler) RunNow(job Job) {
+	go s.wrap(job)()
+}
+
+// wrap adapts a job for execution, tracking it for shutdown and recovering panics
+func (s *CronScheduler) wrap(job Job) func() {
+	return func() {
 		s.wg.Add(1)
 		defer s.wg.Done()
 
-		ctx := context.Background()
+		// Each run is the root of a trace, unnamed jobs included
+		name := jobName(job)
+		ctx, span := tracing.Start(context.Background(), "job "+cmp.Or(name, "unnamed"), tracing.String("job.name", name))
+		startedAt := s.now()
+		s.recordJobRun(job, startedAt, JobResultRunning, "", nil)
 
+		result, message := JobResultSucceeded, ""
 		defer func() {
 			if r := recover(); r != nil {
 				s.logger.WithFields(logrus.Fields{
 					"component": "scheduler",
 					"panic":     r,
 				}).Error("Job panicked")
+				result, message = JobResultPanicked, fmt.Sprint(r)
+				span.RecordError(fmt.Errorf("panic: %v", r))
 			}
+			duration := s.now().Sub(startedAt)
+			s.recordJobRun(job, startedAt, result, message, &duration)
+			span.SetAttributes(tracing.String("job.result", result))
+			span.End()
 		}()
 
 		if err := job.Run(ctx); err != nil {
@@ -81,28 +186,20 @@ func (s *CronScheduler) AddJob(schedule string, job Job) error {
 				"component": "scheduler",
 				"error":     err.Error(),
 			}).Error("Job execution failed")
+			result, message = JobResultFailed, err.Error()
+			span.RecordError(err)
 		}
 	}
-
-	_, err := s.cron.AddFunc(schedule, wrappedJob)
-	if err != nil {
-		return fmt.Errorf("failed to add job with schedule %s: %w", schedule, err)
-	}
-
-	s.logger.WithFields(logrus.Fields{
-		"component": "scheduler",
-		"schedule":  schedule,
-	}).Info("Job added to scheduler")
-
-	return nil
 }
 
 // Start begins executing scheduled jobs
 func (s *CronScheduler) Start() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	s.cron.Start()
+	s.started = true
+	s.mu.Unlock()
+
+	s.startJobStates()
 	s.logger.WithFields(logrus.Fields{
 		"component": "scheduler",
 	}).Info("Scheduler started")
@@ -145,22 +242,51 @@ func (s *CronScheduler) Stop(ctx context.Context) error {
 // UploadManager interface for upload operations
 type UploadManager interface {
 	ShouldSkipUpload(ctx context.Context, nodeName string) (bool, error)
-	InitiateUpload(ctx context.Context, nodeName string, triggerType string) (int64, error)
-	InitiateUploadWithProtocolData(ctx context.Context, nodeName string, triggerType string, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error)
-	CreateUploadRecord(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}) (int64, error)
-	CreateUploadRecordWithProgress(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}, progressData map[string]interface{}) (int64, error)
+	InitiateUpload(ctx context.Context, nodeName string, triggerType upload.TriggerType) (int64, error)
+	InitiateUploadWithProtocolData(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error)
+	CreateUploadRecord(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}) (int64, error)
+	CreateUploadRecordWithProgress(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}, progressData map[string]interface{}, startedAt *time.Time) (int64, error)
 	MonitorUploadProgress(ctx context.Context, uploadID int64, nodeName string) error
-	MonitorUploadProgressWithNotification(ctx context.Context, uploadID int64, nodeName string) (completed bool, err error)
+	MonitorUpload(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error)
 	CheckUploadStatus(ctx context.Context, nodeName string) (*upload.UploadStatus, error)
+	TimeoutUpload(ctx context.Context, uploadID int64, nodeName string, maxDuration time.Duration, cancel bool) error
+	FetchJobLogs(ctx context.Context, nodeName string, n int) ([]string, error)
+	TailJobLogs(ctx context.Context, nodeName string, n int) ([]upload.JobLogLine, error)
+	FetchContentListing(ctx context.Context, nodeName string, command []string) ([]upload.ContentObject, error)
+	InitiateIncrementalUpload(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}, command []string, base upload.IncrementalBase) (int64, error)
+	RunPreflightCommand(ctx context.Context, nodeName string, command []string) error
+	RunHook(ctx context.Context, nodeName string, command string, env map[string]string) error
 }
 
 // Database interface for database operations
 type Database interface {
 	CreateUpload(ctx context.Context, upload database.Upload) (int64, error)
 	UpdateUpload(ctx context.Context, upload database.Upload) error
-	GetRunningUploads(ctx context.Context) ([]database.Upload, error)
+	ListRunningUploads(ctx context.Context, page database.UploadPage) ([]database.Upload, error)
 	GetRunningUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
 	GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
+	SetUploadStalled(ctx context.Context, uploadID int64, stalledSince *time.Time) error
+	SetUploadInterrupted(ctx context.Context, uploadID int64, interruptedAt *time.Time) error
+	ListInterruptedUploads(ctx context.Context) ([]database.Upload, error)
+	SaveScheduleState(ctx context.Context, state database.ScheduleState) error
+	GetScheduleState(ctx context.Context, nodeName string) (*database.ScheduleState, error)
+	RecordUploadObjects(ctx context.Context, uploadID int64, objects []database.UploadObject) error
+	GetUploadObjects(ctx context.Context, uploadID int64) ([]database.UploadObject, error)
+	GetUpload(ctx context.Context, uploadID int64) (*database.Upload, error)
+	CreateUploadRequest(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB) (int64, error)
+	CreateDelayedUploadRequest(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB, notBefore time.Time) (int64, error)
+	RecordNotificationAttempt(ctx context.Context, attempt database.NotificationAttempt) error
+	GetActiveNotificationSnooze(ctx context.Context, nodeName string, now time.Time) (*database.NotificationSnooze, error)
+	GetNodePause(ctx context.Context, nodeName string) (*database.NodePause, error)
+	CountConsecutiveFailedUploads(ctx context.Context, nodeName string) (int, error)
+	OpenNodeCircuit(ctx context.Context, nodeName string, failures int, openedAt time.Time) (bool, error)
+	CloseNodeCircuit(ctx context.Context, nodeName string) (bool, error)
+	RecordUploadNotification(ctx context.Context, n database.UploadNotification) (bool, error)
+	MarkUploadNotificationSent(ctx context.Context, uploadID int64, key string, sentAt time.Time) error
+	GetPendingUploadNotifications(ctx context.Context, before time.Time) ([]database.UploadNotification, error)
+	RecordNodeAction(ctx context.Context, action database.NodeAction) error
+	RecordUploadLogs(ctx context.Context, uploadID int64, lines []database.UploadLogLine) error
+	UpsertCatalogEntry(ctx context.Context, entry database.CatalogEntry) (bool, error)
 }
 
 // NodeUploadJob handles the upload workflow for a single node
@@ -173,6 +299,22 @@ type NodeUploadJob struct {
 	notifyRegistry   *notification.Registry
 	notifyConfig     *config.NotificationConfig
 	logger           *logrus.Logger
+	now              func() time.Time
+
+	// mu serializes scheduled and requested runs of this node
+	mu sync.Mutex
+
+	// queued routes scheduled runs through the upload queue instead of running them
+	queued bool
+
+	// finalityPollInterval is how often the finalized head is checked while waiting for finality
+	finalityPollInterval time.Duration
+
+	// diskUsage reports free and total bytes of a filesystem for the preflight disk gate
+	diskUsage func(path string) (free, total uint64, err error)
+
+	// metricPool bounds the metric collections of all nodes' jobs (nil collects directly)
+	metricPool *MetricPool
 }
 
 // NewNodeUploadJob creates a new node upload job
@@ -199,11 +341,167 @@ func NewNodeUploadJob(
 		notifyRegistry:   notifyRegistry,
 		notifyConfig:     notifyConfig,
 		logger:           logger,
+		now:              time.Now,
+
+		finalityPollInterval: 15 * time.Second,
+		diskUsage:            diskUsage,
 	}
 }
 
-// Run executes the node upload workflow
+// SetQueued routes scheduled runs through the upload queue, where the upload request
+// job starts them as the concurrency limit allows
+func (j *NodeUploadJob) SetQueued(queued bool) {
+	j.queued = queued
+}
+
+// SetMetricPool collects the node's metrics through a pool shared with the other jobs
+func (j *NodeUploadJob) SetMetricPool(pool *MetricPool) {
+	j.metricPool = pool
+}
+
+// Run executes the node upload workflow and records the run in the schedule state. When
+// the node is queued, the run is added to the upload queue instead. Runs of a disabled or
+// paused node are skipped.
 func (j *NodeUploadJob) Run(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	startedAt := j.now()
+	if j.paused(ctx) {
+		j.saveScheduleState(ctx, startedAt, scheduleResultPaused)
+		return nil
+	}
+	if j.queued {
+		return j.enqueue(ctx, startedAt)
+	}
+
+	trigger := upload.Trigger{
+		Type:     upload.TriggerScheduled,
+		Metadata: map[string]interface{}{"schedule": j.nodeConfig.Schedule},
+	}
+	result, _, err := j.run(ctx, startedAt, trigger)
+	j.saveScheduleState(ctx, startedAt, result)
+	return err
+}
+
+// enqueue adds a scheduled run to the upload queue at the node's priority
+func (j *NodeUploadJob) enqueue(ctx context.Context, startedAt time.Time) error {
+	metadata := database.JSONB{"schedule": j.nodeConfig.Schedule}
+	requestID, err := j.db.CreateUploadRequest(ctx, j.nodeName, string(upload.TriggerScheduled), j.nodeConfig.Priority, metadata)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      j.nodeName,
+			"error":     err.Error(),
+		}).Error("Failed to queue scheduled upload")
+		j.sendNotification(ctx, notification.EventFailure, "Failed to queue scheduled upload", map[string]interface{}{
+			"error": err.Error(),
+		})
+		j.saveScheduleState(ctx, startedAt, scheduleResultFailed)
+		return fmt.Errorf("failed to queue scheduled upload: %w", err)
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component":  "scheduler",
+		"node":       j.nodeName,
+		"request_id": requestID,
+		"priority":   j.nodeConfig.Priority,
+	}).Info("Scheduled upload queued")
+	j.saveScheduleState(ctx, startedAt, scheduleResultQueued)
+	return nil
+}
+
+// RunQueued executes a scheduled run taken from the upload queue and records it in the
+// schedule state
+func (j *NodeUploadJob) RunQueued(ctx context.Context, trigger upload.Trigger) (string, int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// The node may have been paused while the run waited in the queue
+	startedAt := j.now()
+	if j.paused(ctx) {
+		j.saveScheduleState(ctx, startedAt, scheduleResultPaused)
+		return scheduleResultPaused, 0, nil
+	}
+	result, uploadID, err := j.run(ctx, startedAt, trigger)
+	j.saveScheduleState(ctx, startedAt, result)
+	return result, uploadID, err
+}
+
+// RunRequested executes the node upload workflow for an operator request queued through
+// the database. Runs are serialized with the node's scheduled runs, so a request that
+// arrives while a scheduled run is initiating an upload is skipped rather than racing
+// it. Requests run even while the node is disabled or paused. The schedule state is left
+// untouched. It returns the run's outcome and the ID of
+// the initiated upload, if any.
+func (j *NodeUploadJob) RunRequested(ctx context.Context, trigger upload.Trigger) (string, int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.run(ctx, j.now(), trigger)
+}
+
+// paused reports whether the node's scheduled runs are stopped, because enabled is false
+// in its configuration, an operator paused it with 'snapperd pause' or its circuit breaker
+// is open. The pause is read on every run, so pausing and resuming take effect without a
+// restart. A node whose pause cannot be read, or whose circuit is half-open, runs.
+func (j *NodeUploadJob) paused(ctx context.Context) bool {
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      j.nodeName,
+	}
+	if !j.nodeConfig.IsEnabled() {
+		j.logger.WithFields(fields).Info("Node is disabled in the configuration, skipping scheduled upload")
+		return true
+	}
+
+	pause, err := j.db.GetNodePause(ctx, j.nodeName)
+	if err != nil {
+		j.logger.WithFields(fields).WithField("error", err.Error()).Warn("Failed to check whether node is paused")
+		return false
+	}
+	if pause == nil {
+		return false
+	}
+
+	if pause.IsCircuit() {
+		fields["failures"] = *pause.CircuitFailures
+		fields["opened_at"] = pause.PausedAt.Format(time.RFC3339)
+		if j.circuitHalfOpen(pause) {
+			j.logger.WithFields(fields).Info("Node circuit is half-open, probing with one upload")
+			return false
+		}
+		j.logger.WithFields(fields).Info("Node circuit is open, skipping scheduled upload")
+		return true
+	}
+
+	fields["paused_by"] = pause.Actor
+	fields["paused_at"] = pause.PausedAt.Format(time.RFC3339)
+	if pause.Reason != nil {
+		fields["reason"] = *pause.Reason
+	}
+	j.logger.WithFields(fields).Info("Node is paused, skipping scheduled upload")
+	return true
+}
+
+// run executes the node upload workflow with the given trigger, returning the outcome
+// recorded as last_result and the ID of the initiated upload. The workflow is traced as
+// the upload.run span, which the upload record keeps so monitor checks join its trace.
+func (j *NodeUploadJob) run(ctx context.Context, startedAt time.Time, trigger upload.Trigger) (result string, uploadID int64, err error) {
+	ctx, span := tracing.Start(ctx, "upload.run",
+		tracing.String("node", j.nodeName),
+		tracing.String("protocol", j.nodeConfig.Protocol),
+		tracing.String("upload.trigger", string(trigger.Type)),
+	)
+	defer func() {
+		span.SetAttributes(tracing.String("upload.result", result))
+		if uploadID != 0 {
+			span.SetAttributes(tracing.Int64("upload.id", uploadID))
+		}
+		span.RecordError(err)
+		span.End()
+	}()
+
 	j.logger.WithFields(logrus.Fields{
 		"component": "scheduler",
 		"job":       "node_upload",
@@ -221,7 +519,7 @@ func (j *NodeUploadJob) Run(ctx context.Context) error {
 		j.sendNotification(ctx, notification.EventFailure, "Failed to check upload status", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return fmt.Errorf("failed to check upload status: %w", err)
+		return scheduleResultFailed, 0, fmt.Errorf("failed to check upload status: %w", err)
 	}
 
 	if shouldSkip {
@@ -230,7 +528,7 @@ func (j *NodeUploadJob) Run(ctx context.Context) error {
 			"node":      j.nodeName,
 		}).Info("Upload already running, skipping")
 		j.sendNotification(ctx, notification.EventSkip, "Upload already running", nil)
-		return nil
+		return scheduleResultSkipped, 0, nil
 	}
 
 	// Step 2: Collect metrics via protocol module
@@ -245,10 +543,10 @@ func (j *NodeUploadJob) Run(ctx context.Context) error {
 		j.sendNotification(ctx, notification.EventFailure, "Failed to get protocol module", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return fmt.Errorf("failed to get protocol module: %w", err)
+		return scheduleResultFailed, 0, fmt.Errorf("failed to get protocol module: %w", err)
 	}
 
-	metrics, err := protocolModule.CollectMetrics(ctx, j.nodeConfig)
+	metrics, err := j.metricPool.Collect(ctx, protocolModule, j.nodeConfig)
 	if err != nil {
 		j.logger.WithFields(logrus.Fields{
 			"component": "scheduler",
@@ -259,10 +557,95 @@ func (j *NodeUploadJob) Run(ctx context.Context) error {
 		metrics = map[string]interface{}{
 			"error": err.Error(),
 		}
+	} else if metricErrors, ok := metrics[protocol.MetricErrorsKey]; ok {
+		j.logger.WithFields(logrus.Fields{
+			"component":     "scheduler",
+			"node":          j.nodeName,
+			"metric_errors": metricErrors,
+		}).Warn("Some metric queries failed, storing them as null")
+	}
+
+	// Skip unhealthy nodes, whose snapshots would be useless
+	if failed := j.preflight(ctx, protocolModule, metrics); len(failed) > 0 {
+		reason := strings.Join(failed, "; ")
+		j.logger.WithFields(logrus.Fields{
+			"component":    "scheduler",
+			"node":         j.nodeName,
+			"failed_gates": reason,
+		}).Warn("Node failed preflight, skipping upload")
+		j.sendNotification(ctx, notification.EventPreflight, "Upload skipped: failed preflight", map[string]interface{}{
+			"failed_gates": reason,
+		})
+		return scheduleResultPreflightFailed, 0, fmt.Errorf("failed preflight: %s", reason)
+	}
+
+	// Refuse to label a snapshot with implausible chain state
+	if err := j.validateMetrics(ctx, metrics); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      j.nodeName,
+			"error":     err.Error(),
+		}).Error("Collected metrics failed validation")
+		j.sendNotification(ctx, notification.EventFailure, "Collected metrics failed validation, upload not started", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return scheduleResultInvalidMetrics, 0, fmt.Errorf("collected metrics failed validation: %w", err)
+	}
+
+	// Optionally hold the upload until the scheduled snapshot point is final
+	if j.nodeConfig.WaitForFinality {
+		// Let 'snapperd status' explain why the upload has not started yet
+		if trigger.Type == upload.TriggerScheduled || trigger.Type == upload.TriggerQueue {
+			j.saveScheduleState(ctx, startedAt, scheduleResultWaitingFinality)
+		}
+
+		finalizedBlock, err := j.waitForFinality(ctx, protocolModule, metrics)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      j.nodeName,
+				"error":     err.Error(),
+			}).Error("Failed waiting for finality")
+			j.sendNotification(ctx, notification.EventFailure, "Failed waiting for finality", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return scheduleResultFailed, 0, fmt.Errorf("failed waiting for finality: %w", err)
+		}
+		metrics["finalized_block"] = finalizedBlock
+
+		// Another upload may have started while waiting
+		shouldSkip, err := j.uploadManager.ShouldSkipUpload(ctx, j.nodeName)
+		if err != nil {
+			j.sendNotification(ctx, notification.EventFailure, "Failed to check upload status", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return scheduleResultFailed, 0, fmt.Errorf("failed to check upload status: %w", err)
+		}
+		if shouldSkip {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      j.nodeName,
+			}).Info("Upload started while waiting for finality, skipping")
+			j.sendNotification(ctx, notification.EventSkip, "Upload already running", nil)
+			return scheduleResultSkipped, 0, nil
+		}
 	}
 
+	// Attach static node metadata to the upload record
+	metrics = protocol.WithMetadata(metrics, j.nodeConfig)
+
 	// Step 3: Initiate upload with protocol data (metrics become part of upload record)
-	uploadID, err := j.uploadManager.InitiateUploadWithProtocolData(ctx, j.nodeName, "scheduled", j.nodeConfig.Protocol, j.nodeConfig.Type, metrics)
+	uploadID, err = j.initiateUpload(ctx, trigger, metrics)
+	if errors.Is(err, upload.ErrUploadRunning) {
+		// Another trigger, possibly in another process, started the node's upload first
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      j.nodeName,
+			"error":     err.Error(),
+		}).Info("Upload started concurrently, skipping")
+		j.sendNotification(ctx, notification.EventSkip, "Upload already running", nil)
+		return scheduleResultSkipped, 0, nil
+	}
 	if err != nil {
 		j.logger.WithFields(logrus.Fields{
 			"component": "scheduler",
@@ -270,9 +653,11 @@ func (j *NodeUploadJob) Run(ctx context.Context) error {
 			"error":     err.Error(),
 		}).Error("Failed to initiate upload")
 		j.sendNotification(ctx, notification.EventFailure, "Failed to initiate upload", map[string]interface{}{
-			"error": err.Error(),
+			"error":            err.Error(),
+			"failure_category": string(upload.ClassifyFailure(err.Error(), nil)),
 		})
-		return fmt.Errorf("failed to initiate upload: %w", err)
+		j.checkCircuit(ctx)
+		return scheduleResultFailed, 0, fmt.Errorf("failed to initiate upload: %w", err)
 	}
 
 	j.logger.WithFields(logrus.Fields{
@@ -285,7 +670,152 @@ func (j *NodeUploadJob) Run(ctx context.Context) error {
 	// Monitoring will be handled by the UploadMonitorJob
 	// Note: Completion notifications will be sent when the upload actually finishes
 
-	return nil
+	return scheduleResultInitiated, uploadID, nil
+}
+
+// initiateUpload starts the node's upload: incremental against its base snapshot when
+// the node is configured for incremental snapshots and a base is available, else full
+func (j *NodeUploadJob) initiateUpload(ctx context.Context, trigger upload.Trigger, metrics map[string]interface{}) (int64, error) {
+	// pre_upload hooks prepare the node; post_upload hooks undo that if no upload starts
+	if err := RunUploadHooks(ctx, j.uploadManager, j.nodeName, j.nodeConfig, HookPreUpload, 0, ""); err != nil {
+		j.runPostUploadHooks(ctx, 0, "failed")
+		return 0, err
+	}
+
+	var uploadID int64
+	var err error
+	if base := j.incrementalBase(ctx); base != nil {
+		uploadID, err = j.uploadManager.InitiateIncrementalUpload(ctx, j.nodeName, trigger, j.nodeConfig.Protocol, j.nodeConfig.Type, metrics, j.nodeConfig.Incremental.Command, *base)
+	} else {
+		uploadID, err = j.uploadManager.InitiateUploadWithProtocolData(ctx, j.nodeName, trigger, j.nodeConfig.Protocol, j.nodeConfig.Type, metrics)
+	}
+	if err != nil {
+		j.runPostUploadHooks(ctx, 0, "failed")
+		return 0, err
+	}
+	return uploadID, nil
+}
+
+// incrementalBase returns the snapshot the next incremental upload is taken against: the
+// latest completed upload, if its contents were recorded. It returns nil, meaning a full
+// upload, when the node is not incremental, no usable base exists or full_every
+// consecutive incremental uploads have been taken since the last full one.
+func (j *NodeUploadJob) incrementalBase(ctx context.Context) *upload.IncrementalBase {
+	incremental := j.nodeConfig.Incremental
+	if incremental == nil {
+		return nil
+	}
+
+	fields := logrus.Fields{
+		"component": "scheduler",
+		"node":      j.nodeName,
+	}
+
+	latest, err := j.db.GetLatestCompletedUploadForNode(ctx, j.nodeName)
+	if err != nil {
+		fields["error"] = err.Error()
+		j.logger.WithFields(fields).Warn("Failed to find base snapshot, starting a full upload")
+		return nil
+	}
+	if latest == nil {
+		j.logger.WithFields(fields).Info("No base snapshot, starting a full upload")
+		return nil
+	}
+	fields["base_upload_id"] = latest.ID
+
+	if incremental.FullEvery > 0 {
+		chain := 0
+		for current := latest; current != nil && current.BaseUploadID != nil && chain < incremental.FullEvery; chain++ {
+			current, err = j.db.GetUpload(ctx, *current.BaseUploadID)
+			if err != nil {
+				fields["error"] = err.Error()
+				j.logger.WithFields(fields).Warn("Failed to follow incremental chain, starting a full upload")
+				return nil
+			}
+		}
+		if chain >= incremental.FullEvery {
+			fields["full_every"] = incremental.FullEvery
+			j.logger.WithFields(fields).Info("Incremental chain reached full_every, starting a full upload")
+			return nil
+		}
+	}
+
+	objects, err := j.db.GetUploadObjects(ctx, latest.ID)
+	if err != nil {
+		fields["error"] = err.Error()
+		j.logger.WithFields(fields).Warn("Failed to load base snapshot contents, starting a full upload")
+		return nil
+	}
+	if len(objects) == 0 {
+		j.logger.WithFields(fields).Info("Base snapshot has no recorded contents, starting a full upload")
+		return nil
+	}
+
+	return &upload.IncrementalBase{UploadID: latest.ID, Objects: contentObjects(objects)}
+}
+
+// contentObjects converts a recorded content listing to upload content objects
+func contentObjects(objects []database.UploadObject) []upload.ContentObject {
+	contents := make([]upload.ContentObject, 0, len(objects))
+	for _, object := range objects {
+		content := upload.ContentObject{Key: object.ObjectKey, SizeBytes: object.SizeBytes}
+		if object.Checksum != nil {
+			content.Checksum = *object.Checksum
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+// waitForFinality polls the protocol module's finalized head until it reaches the
+// latest_block collected for this run (the intended snapshot point)
+func (j *NodeUploadJob) waitForFinality(ctx context.Context, protocolModule protocol.ProtocolModule, metrics map[string]interface{}) (int64, error) {
+	finalityModule, ok := protocolModule.(protocol.FinalityModule)
+	if !ok {
+		return 0, fmt.Errorf("protocol %s does not report finality", protocolModule.Name())
+	}
+
+	target, ok := toInt64(metrics["latest_block"])
+	if !ok {
+		return 0, fmt.Errorf("latest_block unavailable, cannot determine snapshot point")
+	}
+
+	timeout := j.nodeConfig.GetFinalityTimeout()
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	j.logger.WithFields(logrus.Fields{
+		"component":    "scheduler",
+		"node":         j.nodeName,
+		"target_block": target,
+		"timeout":      timeout.String(),
+	}).Info("Waiting for finality before initiating upload")
+
+	for {
+		finalized, err := finalityModule.FinalizedBlock(waitCtx, j.nodeConfig)
+		if err == nil && finalized >= target {
+			j.logger.WithFields(logrus.Fields{
+				"component":       "scheduler",
+				"node":            j.nodeName,
+				"target_block":    target,
+				"finalized_block": finalized,
+			}).Info("Snapshot point finalized")
+			return finalized, nil
+		}
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      j.nodeName,
+				"error":     err.Error(),
+			}).Warn("Failed to query finalized block")
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return 0, fmt.Errorf("finalized head did not reach block %d within %s", target, timeout)
+		case <-time.After(j.finalityPollInterval):
+		}
+	}
 }
 
 // sendNotification sends a notification if configured
@@ -303,6 +833,22 @@ func (j *NodeUploadJob) sendNotification(ctx context.Context, event notification
 		shouldNotify = j.notifyConfig.Skip
 	case notification.EventComplete:
 		shouldNotify = j.notifyConfig.Complete
+	case notification.EventBlobRetention:
+		shouldNotify = j.notifyConfig.BlobRetention
+	case notification.EventStalled:
+		shouldNotify = j.notifyConfig.Stalled
+	case notification.EventMonitorLag:
+		shouldNotify = j.notifyConfig.MonitorLag
+	case notification.EventStale:
+		shouldNotify = j.notifyConfig.Stale
+	case notification.EventPreflight:
+		shouldNotify = j.notifyConfig.Preflight
+	case notification.EventSLO:
+		shouldNotify = j.notifyConfig.SLO
+	case notification.EventInterrupted:
+		shouldNotify = j.notifyConfig.Interrupted
+	case notification.EventCircuitOpen:
+		shouldNotify = j.notifyConfig.CircuitOpen
 	}
 
 	if !shouldNotify {
@@ -316,6 +862,7 @@ func (j *NodeUploadJob) sendNotification(ctx context.Context, event notification
 		Timestamp: time.Now(),
 		Message:   message,
 		Details:   details,
+		Metadata:  j.nodeConfig.Metadata,
 	}
 
 	// Iterate through all configured notification types
@@ -341,7 +888,8 @@ func (j *NodeUploadJob) sendNotification(ctx context.Context, event notification
 			continue
 		}
 
-		if err := notifyModule.Send(ctx, url, payload); err != nil {
+		rendered := RenderNotification(j.notifyConfig, notificationType, payload, j.logger)
+		if err := DeliverNotification(ctx, j.db, j.logger, notifyModule, url, rendered); err != nil {
 			j.logger.WithFields(logrus.Fields{
 				"component":         "scheduler",
 				"node":              j.nodeName,
@@ -360,10 +908,67 @@ type UploadMonitorJob struct {
 	notifyRegistry   *notification.Registry
 	globalNotifyCfg  *config.NotificationConfig
 	logger           *logrus.Logger
-	nodeConfigs      map[string]config.NodeConfig
+	nodeConfigs      *nodeConfigSet
+	stallIntervals   int
+	pageSize         int           // Running uploads read and monitored at a time
+	lagThreshold     time.Duration // Completion detection lag that triggers a monitor_lag notification (0 disables)
+	contentListing   []string      // Command listing a completed snapshot's objects (empty disables recording)
+
+	progressMu sync.Mutex
+	progress   map[int64]*progressTracker // upload ID -> chunk progress across monitor runs
+
+	now       func() time.Time
+	startedAt time.Time // Notifications left pending before this were interrupted by a restart
+	probeMu   sync.Mutex
+	notFound  map[string]*probeBackoff // node name -> backoff after bv reported no upload job
+
+	// metricPool bounds the metric collections of discovered uploads (nil collects directly)
+	metricPool *MetricPool
+
+	passMu   sync.Mutex
+	lastPass *MonitorPass // nil until a pass finishes
+}
+
+// MonitorPass is one finished run of the upload monitor job
+type MonitorPass struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	Uploads   int // Running uploads monitored
+	Probed    int // Nodes probed for uploads started outside the daemon
+	// Monitored uploads found finished, by how they ended, and uploads started outside the
+	// daemon that were registered
+	Completed  int
+	Failed     int
+	Cancelled  int
+	TimedOut   int
+	Discovered int
+}
+
+// runningUploadsPageSize is how many running uploads the monitor job reads and monitors at a
+// time, bounding its memory and concurrent bv calls however many rows are stuck running
+const runningUploadsPageSize = 100
+
+// Discovery probes for nodes without an upload job back off from minProbeBackoff,
+// doubling per consecutive "job not found" response up to maxProbeBackoff
+const (
+	minProbeBackoff = time.Minute
+	maxProbeBackoff = 30 * time.Minute
+)
+
+// probeBackoff tracks consecutive "job not found" responses for a node
+type probeBackoff struct {
+	misses    int
+	nextProbe time.Time
 }
 
-// NewUploadMonitorJob creates a new upload monitor job
+// progressTracker records how long an upload's chunk count has been unchanged
+type progressTracker struct {
+	chunksCompleted int
+	unchanged       int
+}
+
+// NewUploadMonitorJob creates a new upload monitor job. An upload whose chunks_completed
+// has not advanced for stallIntervals monitor runs is marked stalled (0 disables detection).
 func NewUploadMonitorJob(
 	uploadManager UploadManager,
 	db Database,
@@ -371,6 +976,7 @@ func NewUploadMonitorJob(
 	notifyRegistry *notification.Registry,
 	globalNotifyCfg *config.NotificationConfig,
 	nodeConfigs map[string]config.NodeConfig,
+	stallIntervals int,
 	logger *logrus.Logger,
 ) *UploadMonitorJob {
 	if logger == nil {
@@ -384,45 +990,136 @@ func NewUploadMonitorJob(
 		notifyRegistry:   notifyRegistry,
 		globalNotifyCfg:  globalNotifyCfg,
 		logger:           logger,
-		nodeConfigs:      nodeConfigs,
+		nodeConfigs:      newNodeConfigSet(nodeConfigs),
+		stallIntervals:   stallIntervals,
+		pageSize:         runningUploadsPageSize,
+		progress:         make(map[int64]*progressTracker),
+		now:              time.Now,
+		startedAt:        time.Now(),
+		notFound:         make(map[string]*probeBackoff),
 	}
 }
 
+// SetMonitorLagThreshold sets the completion detection lag above which a monitor_lag
+// notification is sent (0 disables the alert)
+func (j *UploadMonitorJob) SetMonitorLagThreshold(threshold time.Duration) {
+	j.lagThreshold = threshold
+}
+
+// SetMetricPool collects the metrics of discovered uploads through a pool shared with the
+// other jobs
+func (j *UploadMonitorJob) SetMetricPool(pool *MetricPool) {
+	j.metricPool = pool
+}
+
+// LastPass returns the last finished run of the job, and false before the first
+func (j *UploadMonitorJob) LastPass() (MonitorPass, bool) {
+	j.passMu.Lock()
+	defer j.passMu.Unlock()
+	if j.lastPass == nil {
+		return MonitorPass{}, false
+	}
+	return *j.lastPass, true
+}
+
+// SetNode starts monitoring uploads of a node registered, or updated, at runtime
+func (j *UploadMonitorJob) SetNode(cfg *config.Config, nodeName string) {
+	j.nodeConfigs.set(nodeName, cfg.ResolvedNode(nodeName))
+}
+
+// RemoveNode stops discovering uploads of a deregistered node. Uploads already recorded
+// for it are still monitored to completion.
+func (j *UploadMonitorJob) RemoveNode(nodeName string) {
+	j.nodeConfigs.remove(nodeName)
+}
+
+// SetContentListing sets the command whose output is recorded as the content listing of
+// each successfully completed upload (empty disables recording)
+func (j *UploadMonitorJob) SetContentListing(command []string) {
+	j.contentListing = command
+}
+
 // Run executes the upload monitoring workflow
 func (j *UploadMonitorJob) Run(ctx context.Context) error {
+	_, err := j.runPass(ctx)
+	return err
+}
+
+// runPass monitors the running uploads and discovers the uploads started outside the
+// daemon, returning the finished pass
+func (j *UploadMonitorJob) runPass(ctx context.Context) (MonitorPass, error) {
 	j.logger.WithFields(logrus.Fields{
 		"component": "scheduler",
 		"job":       "upload_monitor",
 	}).Debug("Starting comprehensive upload monitor job")
+	pass := MonitorPass{StartedAt: j.now()}
 
-	// Step 1: Get all running uploads from database first
-	runningUploads, err := j.db.GetRunningUploads(ctx)
-	if err != nil {
+	// Send the notifications a previous daemon recorded but stopped before sending
+	j.resendPendingNotifications(ctx)
+
+	// Take over the uploads a previous daemon stopped during
+	j.reattachInterrupted(ctx)
+
+	// Step 1: Monitor the running uploads in the database a page at a time, so a backlog
+	// of stuck rows is never held in memory at once
+	trackedNodes := make(map[string]bool)
+	active := make(map[int64]bool)
+	page := database.UploadPage{Limit: j.pageSize}
+	for {
+		runningUploads, err := j.db.ListRunningUploads(ctx, page)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"error":     err.Error(),
+			}).Error("Failed to get running uploads")
+			return pass, fmt.Errorf("failed to get running uploads: %w", err)
+		}
+
+		for _, upload := range runningUploads {
+			trackedNodes[upload.NodeName] = true
+			active[upload.ID] = true
+		}
+		j.monitorUploads(ctx, runningUploads, &pass)
+
+		if len(runningUploads) == 0 || len(runningUploads) < page.Limit {
+			break
+		}
+		page.AfterID = runningUploads[len(runningUploads)-1].ID
+	}
+	j.forgetFinishedUploads(active)
+
+	if len(active) == 0 {
 		j.logger.WithFields(logrus.Fields{
 			"component": "scheduler",
-			"error":     err.Error(),
-		}).Error("Failed to get running uploads")
-		return fmt.Errorf("failed to get running uploads: %w", err)
+		}).Debug("No running uploads to monitor")
 	}
 
 	// Step 2: Check for external uploads (running uploads not in database)
-	trackedNodes := make(map[string]bool)
-	for _, upload := range runningUploads {
-		trackedNodes[upload.NodeName] = true
-	}
 
 	// Check all configured nodes for external uploads
 	var discoveryWg sync.WaitGroup
-	for nodeName := range j.nodeConfigs {
+	var discoveredMu sync.Mutex
+	for nodeName := range j.nodeConfigs.all() {
 		// Skip nodes that already have tracked uploads
 		if trackedNodes[nodeName] {
+			j.resetProbeBackoff(nodeName)
+			continue
+		}
+
+		// Nodes that have never uploaded are probed less often
+		if !j.shouldProbe(nodeName) {
 			continue
 		}
 
+		pass.Probed++
 		discoveryWg.Add(1)
 		go func(node string) {
 			defer discoveryWg.Done()
 
+			// A discovered upload's trace starts here
+			ctx, span := tracing.Start(ctx, "upload.discover", tracing.String("node", node))
+			defer span.End()
+
 			// Check if this node has a running upload
 			status, err := j.uploadManager.CheckUploadStatus(ctx, node)
 			if err != nil {
@@ -434,14 +1131,32 @@ func (j *UploadMonitorJob) Run(ctx context.Context) error {
 				return
 			}
 
+			if status.NotFound {
+				j.recordNotFound(node)
+				return
+			}
+			j.resetProbeBackoff(node)
+
 			// Only create record for truly external uploads (not already tracked)
 			if status.IsRunning {
-				nodeConfig := j.nodeConfigs[node]
+				nodeConfig, _ := j.nodeConfigs.get(node)
+
+				// An upload already past max_duration was marked stalled earlier; re-registering
+				// it would time it out and alert again on every monitor run
+				if maxDuration := nodeConfig.GetMaxDuration(); maxDuration > 0 && status.StartedAt != nil && time.Since(*status.StartedAt) > maxDuration {
+					j.logger.WithFields(logrus.Fields{
+						"component":    "scheduler",
+						"node":         node,
+						"started_at":   status.StartedAt.Format(time.RFC3339),
+						"max_duration": maxDuration.String(),
+					}).Debug("Not registering upload that exceeded max duration")
+					return
+				}
 
 				// Collect protocol metrics for discovered uploads (blockchain state only)
 				var protocolData map[string]interface{}
 				if protocolModule, err := j.protocolRegistry.Get(nodeConfig.Protocol); err == nil {
-					metrics, err := protocolModule.CollectMetrics(ctx, nodeConfig)
+					metrics, err := j.metricPool.Collect(ctx, protocolModule, nodeConfig)
 					if err != nil {
 						j.logger.WithFields(logrus.Fields{
 							"component": "scheduler",
@@ -466,10 +1181,18 @@ func (j *UploadMonitorJob) Run(ctx context.Context) error {
 					protocolData = make(map[string]interface{})
 				}
 
+				protocolData = protocol.WithMetadata(protocolData, nodeConfig)
+
 				// Extract progress data separately (for database columns)
 				progressData := status.Progress
 
-				uploadID, err := j.uploadManager.CreateUploadRecordWithProgress(ctx, node, nodeConfig.Protocol, nodeConfig.Type, "discovered", protocolData, progressData)
+				// The upload is recorded as started when its engine started it, so its duration
+				// is not shortened to the time since discovery, which the trigger keeps
+				trigger := upload.Trigger{
+					Type:     upload.TriggerExternal,
+					Metadata: map[string]interface{}{"discovered_at": j.now().UTC().Format(time.RFC3339)},
+				}
+				uploadID, err := j.uploadManager.CreateUploadRecordWithProgress(ctx, node, nodeConfig.Protocol, nodeConfig.Type, trigger, protocolData, progressData, status.StartedAt)
 				if err != nil {
 					j.logger.WithFields(logrus.Fields{
 						"component": "scheduler",
@@ -479,6 +1202,10 @@ func (j *UploadMonitorJob) Run(ctx context.Context) error {
 					return
 				}
 
+				discoveredMu.Lock()
+				pass.Discovered++
+				discoveredMu.Unlock()
+
 				j.logger.WithFields(logrus.Fields{
 					"component": "scheduler",
 					"node":      node,
@@ -490,11 +1217,34 @@ func (j *UploadMonitorJob) Run(ctx context.Context) error {
 
 	discoveryWg.Wait()
 
+	pass.Uploads = len(active)
+	pass.Duration = j.now().Sub(pass.StartedAt)
+	j.passMu.Lock()
+	j.lastPass = &pass
+	j.passMu.Unlock()
+
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"duration":  pass.Duration.String(),
+		"uploads":   pass.Uploads,
+		"probed":    pass.Probed,
+	}).Debug("Comprehensive upload monitor job completed")
+
+	return pass, nil
+}
+
+// monitorUploads checks one page of running uploads for progress and completion, counting
+// the uploads found finished in pass
+func (j *UploadMonitorJob) monitorUploads(ctx context.Context, runningUploads []database.Upload, pass *MonitorPass) {
 	if len(runningUploads) == 0 {
-		j.logger.WithFields(logrus.Fields{
-			"component": "scheduler",
-		}).Debug("No running uploads to monitor")
-		return nil
+		return
+	}
+
+	var countMu sync.Mutex
+	count := func(outcome *int) {
+		countMu.Lock()
+		defer countMu.Unlock()
+		*outcome++
 	}
 
 	j.logger.WithFields(logrus.Fields{
@@ -502,15 +1252,36 @@ func (j *UploadMonitorJob) Run(ctx context.Context) error {
 		"count":     len(runningUploads),
 	}).Info("Monitoring running uploads")
 
-	// Step 3: Monitor each upload independently (node isolation)
+	// Compare chunk progress with the previous runs before refreshing it
+	j.detectStalledUploads(ctx, runningUploads)
+
+	// Monitor each upload independently (node isolation)
 	var monitorWg sync.WaitGroup
-	for _, upload := range runningUploads {
+	for _, running := range runningUploads {
 		monitorWg.Add(1)
 		go func(u database.Upload) {
 			defer monitorWg.Done()
 
+			// Each check joins the trace of the run that started the upload
+			ctx, span := tracing.Start(tracing.ContextWithTraceParent(ctx, u.TraceParent), "upload.monitor",
+				tracing.String("node", u.NodeName),
+				tracing.Int64("upload.id", u.ID),
+			)
+			defer span.End()
+
+			// Uploads running longer than the node's max duration are marked stalled
+			nodeConfig, _ := j.nodeConfigs.get(u.NodeName)
+			if maxDuration := nodeConfig.GetMaxDuration(); maxDuration > 0 && time.Since(u.StartedAt) > maxDuration {
+				span.SetAttributes(tracing.Bool("upload.timed_out", true))
+				j.timeoutUpload(ctx, u, maxDuration, nodeConfig.CancelStalled)
+				count(&pass.TimedOut)
+				return
+			}
+
 			// Each upload is monitored independently to ensure node isolation
-			completed, err := j.uploadManager.MonitorUploadProgressWithNotification(ctx, u.ID, u.NodeName)
+			result, err := j.uploadManager.MonitorUpload(ctx, u.ID, u.NodeName)
+			span.SetAttributes(tracing.String("upload.outcome", string(result.Outcome)))
+			span.RecordError(err)
 			if err != nil {
 				j.logger.WithFields(logrus.Fields{
 					"component": "scheduler",
@@ -519,23 +1290,453 @@ func (j *UploadMonitorJob) Run(ctx context.Context) error {
 					"error":     err.Error(),
 				}).Error("Failed to monitor upload progress")
 				// Don't return error - continue monitoring other uploads (node isolation)
-			} else if completed {
-				// Send completion notification
-				j.sendNotification(ctx, u.NodeName, notification.EventComplete, "Upload completed successfully", map[string]interface{}{
-					"upload_id": u.ID,
-					"node":      u.NodeName,
-				})
+				return
+			}
+			switch result.Outcome {
+			case upload.OutcomeSuccess:
+				count(&pass.Completed)
+			case upload.OutcomeFailure:
+				count(&pass.Failed)
+			case upload.OutcomeCancelled:
+				count(&pass.Cancelled)
 			}
-		}(upload)
+			j.notifyCompletion(ctx, u, result)
+		}(running)
 	}
 
 	monitorWg.Wait()
+}
+
+// notifyCompletion sends the notification for an upload's monitor outcome. Cancelled
+// uploads were stopped deliberately and are only logged.
+func (j *UploadMonitorJob) notifyCompletion(ctx context.Context, u database.Upload, result upload.CompletionResult) {
+	details := map[string]interface{}{
+		"upload_id": u.ID,
+		"node":      u.NodeName,
+	}
+	if result.Message != nil {
+		details["status"] = *result.Message
+	}
+	// The snapshot's block, for templates such as "block {{.Details.latest_block}}"
+	if block := u.ProtocolData["latest_block"]; block != nil {
+		details["latest_block"] = block
+	}
+	if result.DetectionLag != nil {
+		details["detection_lag"] = result.DetectionLag.Round(time.Second).String()
+		j.checkDetectionLag(ctx, u, *result.DetectionLag)
+	}
+
+	switch result.Outcome {
+	case upload.OutcomeSuccess:
+		addThroughputDetails(details, u, j.now(), true)
+		addCompressionDetails(details, result.Compression)
+		if result.SizeBytes != nil {
+			details["size_bytes"] = *result.SizeBytes
+		}
+		pending := j.recordNotification(ctx, u, completionNotification, notification.EventComplete, "Upload completed successfully", details)
+		j.recordContents(ctx, details, u)
+		j.recordCatalog(ctx, u, result)
+		closeCircuit(ctx, j.db, j.logger, u.NodeName, u.ID)
+		if pending {
+			j.sendNotification(ctx, u.NodeName, notification.EventComplete, "Upload completed successfully", details)
+			j.markNotificationSent(ctx, u, completionNotification)
+		}
+	case upload.OutcomeFailure:
+		addThroughputDetails(details, u, j.now(), false)
+		// A node whose circuit opened is not retried until the circuit closes
+		if j.checkCircuit(ctx, u) {
+			details["circuit_open"] = true
+		} else {
+			j.scheduleRetry(ctx, u, details)
+		}
+		if j.recordNotification(ctx, u, completionNotification, notification.EventFailure, "Upload failed", details) {
+			j.addFailureDetails(ctx, details, u.NodeName, result.Message)
+			j.sendNotification(ctx, u.NodeName, notification.EventFailure, "Upload failed", details)
+			j.markNotificationSent(ctx, u, completionNotification)
+		}
+	case upload.OutcomeCancelled:
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+		}).Info("Upload was cancelled")
+	}
+
+	if result.Outcome != upload.OutcomeRunning {
+		j.recordJobLogs(ctx, u)
+		j.runPostUploadHooks(ctx, u.NodeName, u.ID, result.RecordStatus())
+	}
+}
+
+// recordJobLogs stores the tail of a finished upload's job log, so it can be read with
+// 'snapperd status --logs' after the engine has moved on. Lines read before the log
+// command failed are stored too; a failure to store is only logged.
+func (j *UploadMonitorJob) recordJobLogs(ctx context.Context, u database.Upload) {
+	lines, err := j.uploadManager.TailJobLogs(ctx, u.NodeName, database.MaxUploadLogLines)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+			"error":     err.Error(),
+		}).Warn("Failed to capture upload job logs")
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	now := j.now()
+	records := make([]database.UploadLogLine, 0, len(lines))
+	for _, line := range lines {
+		records = append(records, database.UploadLogLine{UploadID: u.ID, Stream: string(line.Stream), Line: line.Line, RecordedAt: now})
+	}
+	if err := j.db.RecordUploadLogs(ctx, u.ID, records); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+			"error":     err.Error(),
+		}).Warn("Failed to record upload job logs")
+	}
+}
+
+// addCompressionDetails adds how a completed upload was compressed, and the ratio of its
+// size before compression to its uploaded size, to the notification details
+func addCompressionDetails(details map[string]interface{}, compression *engine.CompressionReport) {
+	if compression == nil {
+		return
+	}
+	details["compression"] = compression.Algorithm
+	if compression.Level != 0 {
+		details["compression_level"] = compression.Level
+	}
+	details["raw_bytes"] = compression.RawBytes
+	details["compressed_bytes"] = compression.CompressedBytes
+	details["compression_ratio"] = math.Round(compression.Ratio()*100) / 100
+}
+
+// checkDetectionLag alerts when an upload's completion was detected long after bv reported
+// it finished, a sign that the monitor job is overloaded or stuck
+func (j *UploadMonitorJob) checkDetectionLag(ctx context.Context, u database.Upload, lag time.Duration) {
+	if j.lagThreshold <= 0 || lag <= j.lagThreshold {
+		return
+	}
 
 	j.logger.WithFields(logrus.Fields{
-		"component": "scheduler",
-	}).Debug("Comprehensive upload monitor job completed")
+		"component":     "scheduler",
+		"node":          u.NodeName,
+		"upload_id":     u.ID,
+		"detection_lag": lag.Round(time.Second).String(),
+		"threshold":     j.lagThreshold.String(),
+	}).Warn("Upload completion detected late")
+
+	j.sendUploadNotification(ctx, u, monitorLagNotification, notification.EventMonitorLag,
+		fmt.Sprintf("Upload completion was detected %s after it finished", lag.Round(time.Second)),
+		map[string]interface{}{
+			"upload_id":     u.ID,
+			"detection_lag": lag.Round(time.Second).String(),
+			"threshold":     j.lagThreshold.String(),
+		})
+}
 
-	return nil
+// recordContents stores the content listing of a successfully completed upload and adds
+// the object count and total size to the notification details. A failed listing is
+// logged and leaves the upload without a listing.
+func (j *UploadMonitorJob) recordContents(ctx context.Context, details map[string]interface{}, u database.Upload) {
+	if len(j.contentListing) == 0 {
+		return
+	}
+
+	contents, err := j.uploadManager.FetchContentListing(ctx, u.NodeName, j.contentListing)
+	if err == nil {
+		objects := make([]database.UploadObject, 0, len(contents))
+		for _, content := range contents {
+			object := database.UploadObject{UploadID: u.ID, ObjectKey: content.Key, SizeBytes: content.SizeBytes}
+			if content.Checksum != "" {
+				checksum := content.Checksum
+				object.Checksum = &checksum
+			}
+			objects = append(objects, object)
+		}
+		err = j.db.RecordUploadObjects(ctx, u.ID, objects)
+	}
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+			"error":     err.Error(),
+		}).Warn("Failed to record snapshot contents")
+		return
+	}
+
+	var totalBytes int64
+	for _, content := range contents {
+		if content.SizeBytes != nil {
+			totalBytes += *content.SizeBytes
+		}
+	}
+	details["objects"] = len(contents)
+	if totalBytes > 0 {
+		details["content_bytes"] = totalBytes
+	}
+
+	// Report how much an incremental upload actually sent
+	if u.BaseUploadID != nil {
+		details["base_upload_id"] = *u.BaseUploadID
+		baseObjects, err := j.db.GetUploadObjects(ctx, *u.BaseUploadID)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component":      "scheduler",
+				"node":           u.NodeName,
+				"upload_id":      u.ID,
+				"base_upload_id": *u.BaseUploadID,
+				"error":          err.Error(),
+			}).Warn("Failed to load base snapshot contents")
+			return
+		}
+		details["changed_objects"] = upload.DiffContents(contentObjects(baseObjects), contents)
+	}
+}
+
+// addFailureDetails adds the failure category and, when failure_log_lines is configured,
+// the tail of the bv upload job log to a failure notification's details
+func (j *UploadMonitorJob) addFailureDetails(ctx context.Context, details map[string]interface{}, nodeName string, message *string) {
+	var logLines []string
+	if notifyConfig := j.notificationConfig(nodeName); notifyConfig != nil && notifyConfig.Failure && notifyConfig.FailureLogLines > 0 {
+		lines, err := j.uploadManager.FetchJobLogs(ctx, nodeName, notifyConfig.FailureLogLines)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Warn("Failed to fetch upload job logs for failure notification")
+		} else if len(lines) > 0 {
+			logLines = lines
+			details["log_excerpt"] = strings.Join(lines, "\n")
+		}
+	}
+
+	status := ""
+	if message != nil {
+		status = *message
+	}
+	details["failure_category"] = string(upload.ClassifyFailure(status, logLines))
+}
+
+// addThroughputDetails adds an upload's duration and chunk throughput to notification details.
+// Completed uploads report their average over the whole upload; running uploads report
+// the recent throughput and estimated completion stored by the monitor.
+func addThroughputDetails(details map[string]interface{}, u database.Upload, now time.Time, completed bool) {
+	duration := now.Sub(u.StartedAt).Round(time.Second)
+	details["duration"] = duration.String()
+
+	if completed && u.ChunksTotal != nil && duration > 0 {
+		details["chunks_per_minute"] = fmt.Sprintf("%.1f", float64(*u.ChunksTotal)/duration.Minutes())
+		return
+	}
+
+	if u.ChunksPerMinute != nil {
+		details["chunks_per_minute"] = fmt.Sprintf("%.1f", *u.ChunksPerMinute)
+	}
+	if u.EstimatedCompletion != nil {
+		details["estimated_completion"] = u.EstimatedCompletion.UTC().Format(time.RFC3339)
+	}
+}
+
+// shouldProbe reports whether a node's upload status should be checked on this run
+func (j *UploadMonitorJob) shouldProbe(nodeName string) bool {
+	j.probeMu.Lock()
+	defer j.probeMu.Unlock()
+
+	backoff, exists := j.notFound[nodeName]
+	return !exists || !j.now().Before(backoff.nextProbe)
+}
+
+// recordNotFound extends a node's probe backoff after bv reported no upload job
+func (j *UploadMonitorJob) recordNotFound(nodeName string) {
+	j.probeMu.Lock()
+	defer j.probeMu.Unlock()
+
+	backoff, exists := j.notFound[nodeName]
+	if !exists {
+		backoff = &probeBackoff{}
+		j.notFound[nodeName] = backoff
+	}
+	backoff.misses++
+
+	delay := minProbeBackoff
+	for i := 1; i < backoff.misses && delay < maxProbeBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxProbeBackoff {
+		delay = maxProbeBackoff
+	}
+	backoff.nextProbe = j.now().Add(delay)
+
+	// Log once when the node is first classified; later misses are expected
+	if backoff.misses == 1 {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      nodeName,
+		}).Info("Node has never uploaded, backing off status probes")
+	}
+}
+
+// resetProbeBackoff resumes probing a node on every run
+func (j *UploadMonitorJob) resetProbeBackoff(nodeName string) {
+	j.probeMu.Lock()
+	defer j.probeMu.Unlock()
+
+	delete(j.notFound, nodeName)
+}
+
+// detectStalledUploads marks uploads whose chunks_completed has not advanced for
+// stallIntervals monitor runs and clears the mark once progress resumes
+func (j *UploadMonitorJob) detectStalledUploads(ctx context.Context, uploads []database.Upload) {
+	if j.stallIntervals <= 0 {
+		return
+	}
+
+	j.progressMu.Lock()
+	defer j.progressMu.Unlock()
+
+	for _, u := range uploads {
+		if u.ChunksCompleted == nil {
+			continue
+		}
+
+		tracker, exists := j.progress[u.ID]
+		if !exists {
+			j.progress[u.ID] = &progressTracker{chunksCompleted: *u.ChunksCompleted}
+			continue
+		}
+
+		if tracker.chunksCompleted != *u.ChunksCompleted {
+			tracker.chunksCompleted = *u.ChunksCompleted
+			tracker.unchanged = 0
+
+			if u.StalledSince != nil {
+				if err := j.db.SetUploadStalled(ctx, u.ID, nil); err != nil {
+					j.logger.WithFields(logrus.Fields{
+						"component": "scheduler",
+						"node":      u.NodeName,
+						"upload_id": u.ID,
+						"error":     err.Error(),
+					}).Error("Failed to clear stalled mark")
+					continue
+				}
+				j.logger.WithFields(logrus.Fields{
+					"component": "scheduler",
+					"node":      u.NodeName,
+					"upload_id": u.ID,
+				}).Info("Upload progress resumed")
+			}
+			continue
+		}
+
+		tracker.unchanged++
+		if tracker.unchanged < j.stallIntervals || u.StalledSince != nil {
+			continue
+		}
+
+		now := time.Now()
+		if err := j.db.SetUploadStalled(ctx, u.ID, &now); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"node":      u.NodeName,
+				"upload_id": u.ID,
+				"error":     err.Error(),
+			}).Error("Failed to mark upload as stalled")
+			continue
+		}
+
+		j.logger.WithFields(logrus.Fields{
+			"component":        "scheduler",
+			"node":             u.NodeName,
+			"upload_id":        u.ID,
+			"chunks_completed": *u.ChunksCompleted,
+			"intervals":        tracker.unchanged,
+		}).Warn("Upload progress stalled")
+
+		details := map[string]interface{}{
+			"upload_id":        u.ID,
+			"node":             u.NodeName,
+			"chunks_completed": *u.ChunksCompleted,
+			"intervals":        tracker.unchanged,
+		}
+		if u.ChunksTotal != nil {
+			details["chunks_total"] = *u.ChunksTotal
+		}
+		addThroughputDetails(details, u, now, false)
+		j.sendUploadNotification(ctx, u, stalledNotification(*u.ChunksCompleted), notification.EventStalled,
+			fmt.Sprintf("Upload progress has not advanced for %d monitor intervals", tracker.unchanged), details)
+	}
+}
+
+// forgetFinishedUploads drops the chunk progress of uploads no longer running, once every
+// page of running uploads has been monitored
+func (j *UploadMonitorJob) forgetFinishedUploads(active map[int64]bool) {
+	j.progressMu.Lock()
+	defer j.progressMu.Unlock()
+
+	for id := range j.progress {
+		if !active[id] {
+			delete(j.progress, id)
+		}
+	}
+}
+
+// timeoutUpload marks an upload that exceeded its max duration as stalled and sends a failure notification
+func (j *UploadMonitorJob) timeoutUpload(ctx context.Context, u database.Upload, maxDuration time.Duration, cancel bool) {
+	details := map[string]interface{}{
+		"upload_id":    u.ID,
+		"node":         u.NodeName,
+		"started_at":   u.StartedAt.UTC().Format(time.RFC3339),
+		"max_duration": maxDuration.String(),
+		"cancelled":    cancel,
+	}
+
+	if err := j.uploadManager.TimeoutUpload(ctx, u.ID, u.NodeName, maxDuration, cancel); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"node":      u.NodeName,
+			"upload_id": u.ID,
+			"error":     err.Error(),
+		}).Error("Failed to time out stalled upload")
+		details["cancelled"] = false
+		details["error"] = err.Error()
+	} else {
+		action := database.NodeActionTimedOut
+		message := fmt.Sprintf("Upload exceeded max duration of %s and was marked stalled", maxDuration)
+		if cancel {
+			action = database.NodeActionCancelled
+			message = fmt.Sprintf("Upload exceeded max duration of %s and its job was stopped", maxDuration)
+		}
+		recordNodeAction(ctx, j.db, j.logger, database.NodeAction{
+			NodeName: u.NodeName,
+			UploadID: &u.ID,
+			Action:   action,
+			Message:  message,
+			Details:  database.JSONB{"started_at": details["started_at"], "max_duration": details["max_duration"]},
+		})
+	}
+
+	j.sendUploadNotification(ctx, u, timeoutNotification, notification.EventFailure, fmt.Sprintf("Upload exceeded max duration of %s", maxDuration), details)
+	j.recordJobLogs(ctx, u)
+	j.runPostUploadHooks(ctx, u.NodeName, u.ID, "stalled")
+}
+
+// notificationConfig returns a node's notification settings, falling back to the global settings
+func (j *UploadMonitorJob) notificationConfig(nodeName string) *config.NotificationConfig {
+	nodeConfig, exists := j.nodeConfigs.get(nodeName)
+	if !exists {
+		return nil
+	}
+	if nodeConfig.Notifications != nil {
+		return nodeConfig.Notifications
+	}
+	return j.globalNotifyCfg
 }
 
 // sendNotification sends a notification for upload events
@@ -545,15 +1746,12 @@ func (j *UploadMonitorJob) sendNotification(ctx context.Context, nodeName string
 	}
 
 	// Get node-specific notification config
-	nodeConfig, exists := j.nodeConfigs[nodeName]
+	nodeConfig, exists := j.nodeConfigs.get(nodeName)
 	if !exists {
 		return
 	}
 
-	notifyConfig := nodeConfig.Notifications
-	if notifyConfig == nil {
-		notifyConfig = j.globalNotifyCfg
-	}
+	notifyConfig := j.notificationConfig(nodeName)
 	if notifyConfig == nil {
 		return
 	}
@@ -567,6 +1765,22 @@ func (j *UploadMonitorJob) sendNotification(ctx context.Context, nodeName string
 		shouldNotify = notifyConfig.Skip
 	case notification.EventComplete:
 		shouldNotify = notifyConfig.Complete
+	case notification.EventBlobRetention:
+		shouldNotify = notifyConfig.BlobRetention
+	case notification.EventStalled:
+		shouldNotify = notifyConfig.Stalled
+	case notification.EventMonitorLag:
+		shouldNotify = notifyConfig.MonitorLag
+	case notification.EventStale:
+		shouldNotify = notifyConfig.Stale
+	case notification.EventPreflight:
+		shouldNotify = notifyConfig.Preflight
+	case notification.EventSLO:
+		shouldNotify = notifyConfig.SLO
+	case notification.EventInterrupted:
+		shouldNotify = notifyConfig.Interrupted
+	case notification.EventCircuitOpen:
+		shouldNotify = notifyConfig.CircuitOpen
 	}
 
 	if !shouldNotify {
@@ -590,9 +1804,11 @@ func (j *UploadMonitorJob) sendNotification(ctx context.Context, nodeName string
 			Timestamp: time.Now(),
 			Message:   message,
 			Details:   details,
+			Metadata:  nodeConfig.Metadata,
 		}
 
-		if err := notificationModule.Send(ctx, typeConfig.URL, payload); err != nil {
+		rendered := RenderNotification(notifyConfig, notificationType, payload, j.logger)
+		if err := DeliverNotification(ctx, j.db, j.logger, notificationModule, typeConfig.URL, rendered); err != nil {
 			j.logger.WithFields(logrus.Fields{
 				"component": "scheduler",
 				"type":      notificationType,
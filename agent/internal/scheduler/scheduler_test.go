@@ -3,12 +3,14 @@ package scheduler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/nodexeus/agent/internal/config"
 	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/executor"
 	"github.com/nodexeus/agent/internal/notification"
 	"github.com/nodexeus/agent/internal/protocol"
 	"github.com/nodexeus/agent/internal/upload"
@@ -41,14 +43,21 @@ func (m *mockJob) getRunCount() int {
 }
 
 type mockUploadManager struct {
-	shouldSkipFunc                      func(ctx context.Context, nodeName string) (bool, error)
-	initiateUploadFunc                  func(ctx context.Context, nodeName string, triggerType string) (int64, error)
-	initiateUploadWithProtocolDataFunc  func(ctx context.Context, nodeName string, triggerType string, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error)
-	createUploadRecordFunc              func(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}) (int64, error)
-	createUploadRecordWithProgressFunc  func(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}, progressData map[string]interface{}) (int64, error)
-	monitorProgressFunc                 func(ctx context.Context, uploadID int64, nodeName string) error
-	monitorProgressWithNotificationFunc func(ctx context.Context, uploadID int64, nodeName string) (bool, error)
-	checkUploadStatusFunc               func(ctx context.Context, nodeName string) (*upload.UploadStatus, error)
+	shouldSkipFunc                     func(ctx context.Context, nodeName string) (bool, error)
+	initiateUploadFunc                 func(ctx context.Context, nodeName string, triggerType upload.TriggerType) (int64, error)
+	initiateUploadWithProtocolDataFunc func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error)
+	createUploadRecordFunc             func(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}) (int64, error)
+	createUploadRecordWithProgressFunc func(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}, progressData map[string]interface{}, startedAt *time.Time) (int64, error)
+	monitorProgressFunc                func(ctx context.Context, uploadID int64, nodeName string) error
+	monitorUploadFunc                  func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error)
+	checkUploadStatusFunc              func(ctx context.Context, nodeName string) (*upload.UploadStatus, error)
+	timeoutUploadFunc                  func(ctx context.Context, uploadID int64, nodeName string, maxDuration time.Duration, cancel bool) error
+	fetchJobLogsFunc                   func(ctx context.Context, nodeName string, n int) ([]string, error)
+	tailJobLogsFunc                    func(ctx context.Context, nodeName string, n int) ([]upload.JobLogLine, error)
+	fetchContentListingFunc            func(ctx context.Context, nodeName string, command []string) ([]upload.ContentObject, error)
+	initiateIncrementalUploadFunc      func(ctx context.Context, nodeName string, trigger upload.Trigger, command []string, base upload.IncrementalBase) (int64, error)
+	runPreflightCommandFunc            func(ctx context.Context, nodeName string, command []string) error
+	runHookFunc                        func(ctx context.Context, nodeName string, command string, env map[string]string) error
 }
 
 func (m *mockUploadManager) ShouldSkipUpload(ctx context.Context, nodeName string) (bool, error) {
@@ -58,31 +67,31 @@ func (m *mockUploadManager) ShouldSkipUpload(ctx context.Context, nodeName strin
 	return false, nil
 }
 
-func (m *mockUploadManager) InitiateUpload(ctx context.Context, nodeName string, triggerType string) (int64, error) {
+func (m *mockUploadManager) InitiateUpload(ctx context.Context, nodeName string, triggerType upload.TriggerType) (int64, error) {
 	if m.initiateUploadFunc != nil {
 		return m.initiateUploadFunc(ctx, nodeName, triggerType)
 	}
 	return 1, nil
 }
 
-func (m *mockUploadManager) InitiateUploadWithProtocolData(ctx context.Context, nodeName string, triggerType string, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+func (m *mockUploadManager) InitiateUploadWithProtocolData(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
 	if m.initiateUploadWithProtocolDataFunc != nil {
-		return m.initiateUploadWithProtocolDataFunc(ctx, nodeName, triggerType, protocol, nodeType, protocolData)
+		return m.initiateUploadWithProtocolDataFunc(ctx, nodeName, trigger, protocol, nodeType, protocolData)
 	}
 	// Fallback to regular InitiateUpload method
-	return m.InitiateUpload(ctx, nodeName, triggerType)
+	return m.InitiateUpload(ctx, nodeName, trigger.Type)
 }
 
-func (m *mockUploadManager) CreateUploadRecord(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}) (int64, error) {
+func (m *mockUploadManager) CreateUploadRecord(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}) (int64, error) {
 	if m.createUploadRecordFunc != nil {
-		return m.createUploadRecordFunc(ctx, nodeName, protocol, nodeType, triggerType, protocolData)
+		return m.createUploadRecordFunc(ctx, nodeName, protocol, nodeType, trigger, protocolData)
 	}
 	return 1, nil
 }
 
-func (m *mockUploadManager) CreateUploadRecordWithProgress(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}, progressData map[string]interface{}) (int64, error) {
+func (m *mockUploadManager) CreateUploadRecordWithProgress(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}, progressData map[string]interface{}, startedAt *time.Time) (int64, error) {
 	if m.createUploadRecordWithProgressFunc != nil {
-		return m.createUploadRecordWithProgressFunc(ctx, nodeName, protocol, nodeType, triggerType, protocolData, progressData)
+		return m.createUploadRecordWithProgressFunc(ctx, nodeName, protocol, nodeType, trigger, protocolData, progressData, startedAt)
 	}
 	return 1, nil
 }
@@ -94,11 +103,11 @@ func (m *mockUploadManager) MonitorUploadProgress(ctx context.Context, uploadID
 	return nil
 }
 
-func (m *mockUploadManager) MonitorUploadProgressWithNotification(ctx context.Context, uploadID int64, nodeName string) (bool, error) {
-	if m.monitorProgressWithNotificationFunc != nil {
-		return m.monitorProgressWithNotificationFunc(ctx, uploadID, nodeName)
+func (m *mockUploadManager) MonitorUpload(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+	if m.monitorUploadFunc != nil {
+		return m.monitorUploadFunc(ctx, uploadID, nodeName)
 	}
-	return false, nil
+	return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil
 }
 
 func (m *mockUploadManager) CheckUploadStatus(ctx context.Context, nodeName string) (*upload.UploadStatus, error) {
@@ -108,9 +117,83 @@ func (m *mockUploadManager) CheckUploadStatus(ctx context.Context, nodeName stri
 	return &upload.UploadStatus{IsRunning: false}, nil
 }
 
+func (m *mockUploadManager) TimeoutUpload(ctx context.Context, uploadID int64, nodeName string, maxDuration time.Duration, cancel bool) error {
+	if m.timeoutUploadFunc != nil {
+		return m.timeoutUploadFunc(ctx, uploadID, nodeName, maxDuration, cancel)
+	}
+	return nil
+}
+
+func (m *mockUploadManager) FetchJobLogs(ctx context.Context, nodeName string, n int) ([]string, error) {
+	if m.fetchJobLogsFunc != nil {
+		return m.fetchJobLogsFunc(ctx, nodeName, n)
+	}
+	return nil, nil
+}
+
+func (m *mockUploadManager) TailJobLogs(ctx context.Context, nodeName string, n int) ([]upload.JobLogLine, error) {
+	if m.tailJobLogsFunc != nil {
+		return m.tailJobLogsFunc(ctx, nodeName, n)
+	}
+	return nil, nil
+}
+
+func (m *mockUploadManager) FetchContentListing(ctx context.Context, nodeName string, command []string) ([]upload.ContentObject, error) {
+	if m.fetchContentListingFunc != nil {
+		return m.fetchContentListingFunc(ctx, nodeName, command)
+	}
+	return nil, nil
+}
+
+func (m *mockUploadManager) InitiateIncrementalUpload(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}, command []string, base upload.IncrementalBase) (int64, error) {
+	if m.initiateIncrementalUploadFunc != nil {
+		return m.initiateIncrementalUploadFunc(ctx, nodeName, trigger, command, base)
+	}
+	return 1, nil
+}
+
+func (m *mockUploadManager) RunPreflightCommand(ctx context.Context, nodeName string, command []string) error {
+	if m.runPreflightCommandFunc != nil {
+		return m.runPreflightCommandFunc(ctx, nodeName, command)
+	}
+	return nil
+}
+
+func (m *mockUploadManager) RunHook(ctx context.Context, nodeName string, command string, env map[string]string) error {
+	if m.runHookFunc != nil {
+		return m.runHookFunc(ctx, nodeName, command, env)
+	}
+	return nil
+}
+
 type mockDatabase struct {
-	createUploadFunc      func(ctx context.Context, upload database.Upload) (int64, error)
-	getRunningUploadsFunc func(ctx context.Context) ([]database.Upload, error)
+	createUploadFunc                    func(ctx context.Context, upload database.Upload) (int64, error)
+	getRunningUploadsFunc               func(ctx context.Context) ([]database.Upload, error)
+	listRunningUploadsFunc              func(ctx context.Context, page database.UploadPage) ([]database.Upload, error)
+	getRunningUploadForNodeFunc         func(ctx context.Context, nodeName string) (*database.Upload, error)
+	getLatestCompletedUploadForNodeFunc func(ctx context.Context, nodeName string) (*database.Upload, error)
+	setUploadStalledFunc                func(ctx context.Context, uploadID int64, stalledSince *time.Time) error
+	setUploadInterruptedFunc            func(ctx context.Context, uploadID int64, interruptedAt *time.Time) error
+	listInterruptedUploadsFunc          func(ctx context.Context) ([]database.Upload, error)
+	saveScheduleStateFunc               func(ctx context.Context, state database.ScheduleState) error
+	getScheduleStateFunc                func(ctx context.Context, nodeName string) (*database.ScheduleState, error)
+	recordUploadObjectsFunc             func(ctx context.Context, uploadID int64, objects []database.UploadObject) error
+	getUploadObjectsFunc                func(ctx context.Context, uploadID int64) ([]database.UploadObject, error)
+	getUploadFunc                       func(ctx context.Context, uploadID int64) (*database.Upload, error)
+	createUploadRequestFunc             func(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB) (int64, error)
+	createDelayedUploadRequestFunc      func(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB, notBefore time.Time) (int64, error)
+	recordNotificationAttemptFunc       func(ctx context.Context, attempt database.NotificationAttempt) error
+	getActiveNotificationSnoozeFunc     func(ctx context.Context, nodeName string, now time.Time) (*database.NotificationSnooze, error)
+	getNodePauseFunc                    func(ctx context.Context, nodeName string) (*database.NodePause, error)
+	countConsecutiveFailedUploadsFunc   func(ctx context.Context, nodeName string) (int, error)
+	openNodeCircuitFunc                 func(ctx context.Context, nodeName string, failures int, openedAt time.Time) (bool, error)
+	closeNodeCircuitFunc                func(ctx context.Context, nodeName string) (bool, error)
+	recordUploadNotificationFunc        func(ctx context.Context, n database.UploadNotification) (bool, error)
+	markUploadNotificationSentFunc      func(ctx context.Context, uploadID int64, key string, sentAt time.Time) error
+	getPendingUploadNotificationsFunc   func(ctx context.Context, before time.Time) ([]database.UploadNotification, error)
+	recordNodeActionFunc                func(ctx context.Context, action database.NodeAction) error
+	recordUploadLogsFunc                func(ctx context.Context, uploadID int64, lines []database.UploadLogLine) error
+	upsertCatalogEntryFunc              func(ctx context.Context, entry database.CatalogEntry) (bool, error)
 }
 
 func (m *mockDatabase) CreateUpload(ctx context.Context, upload database.Upload) (int64, error) {
@@ -131,14 +214,186 @@ func (m *mockDatabase) GetRunningUploads(ctx context.Context) ([]database.Upload
 	return []database.Upload{}, nil
 }
 
+// ListRunningUploads serves the running uploads of getRunningUploadsFunc as a single page
+// unless listRunningUploadsFunc pages them
+func (m *mockDatabase) ListRunningUploads(ctx context.Context, page database.UploadPage) ([]database.Upload, error) {
+	if m.listRunningUploadsFunc != nil {
+		return m.listRunningUploadsFunc(ctx, page)
+	}
+	if page.AfterID > 0 {
+		return nil, nil
+	}
+	return m.GetRunningUploads(ctx)
+}
+
 func (m *mockDatabase) GetRunningUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	if m.getRunningUploadForNodeFunc != nil {
+		return m.getRunningUploadForNodeFunc(ctx, nodeName)
+	}
 	return nil, nil
 }
 
 func (m *mockDatabase) GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	if m.getLatestCompletedUploadForNodeFunc != nil {
+		return m.getLatestCompletedUploadForNodeFunc(ctx, nodeName)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) SetUploadStalled(ctx context.Context, uploadID int64, stalledSince *time.Time) error {
+	if m.setUploadStalledFunc != nil {
+		return m.setUploadStalledFunc(ctx, uploadID, stalledSince)
+	}
+	return nil
+}
+
+func (m *mockDatabase) SetUploadInterrupted(ctx context.Context, uploadID int64, interruptedAt *time.Time) error {
+	if m.setUploadInterruptedFunc != nil {
+		return m.setUploadInterruptedFunc(ctx, uploadID, interruptedAt)
+	}
+	return nil
+}
+
+func (m *mockDatabase) ListInterruptedUploads(ctx context.Context) ([]database.Upload, error) {
+	if m.listInterruptedUploadsFunc != nil {
+		return m.listInterruptedUploadsFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) SaveScheduleState(ctx context.Context, state database.ScheduleState) error {
+	if m.saveScheduleStateFunc != nil {
+		return m.saveScheduleStateFunc(ctx, state)
+	}
+	return nil
+}
+
+func (m *mockDatabase) GetScheduleState(ctx context.Context, nodeName string) (*database.ScheduleState, error) {
+	if m.getScheduleStateFunc != nil {
+		return m.getScheduleStateFunc(ctx, nodeName)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) RecordUploadObjects(ctx context.Context, uploadID int64, objects []database.UploadObject) error {
+	if m.recordUploadObjectsFunc != nil {
+		return m.recordUploadObjectsFunc(ctx, uploadID, objects)
+	}
+	return nil
+}
+
+func (m *mockDatabase) GetUploadObjects(ctx context.Context, uploadID int64) ([]database.UploadObject, error) {
+	if m.getUploadObjectsFunc != nil {
+		return m.getUploadObjectsFunc(ctx, uploadID)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) GetUpload(ctx context.Context, uploadID int64) (*database.Upload, error) {
+	if m.getUploadFunc != nil {
+		return m.getUploadFunc(ctx, uploadID)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) CreateUploadRequest(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB) (int64, error) {
+	if m.createUploadRequestFunc != nil {
+		return m.createUploadRequestFunc(ctx, nodeName, triggerType, priority, triggerMetadata)
+	}
+	return 1, nil
+}
+
+func (m *mockDatabase) CreateDelayedUploadRequest(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB, notBefore time.Time) (int64, error) {
+	if m.createDelayedUploadRequestFunc != nil {
+		return m.createDelayedUploadRequestFunc(ctx, nodeName, triggerType, priority, triggerMetadata, notBefore)
+	}
+	return 1, nil
+}
+
+func (m *mockDatabase) RecordNotificationAttempt(ctx context.Context, attempt database.NotificationAttempt) error {
+	if m.recordNotificationAttemptFunc != nil {
+		return m.recordNotificationAttemptFunc(ctx, attempt)
+	}
+	return nil
+}
+
+func (m *mockDatabase) RecordUploadNotification(ctx context.Context, n database.UploadNotification) (bool, error) {
+	if m.recordUploadNotificationFunc != nil {
+		return m.recordUploadNotificationFunc(ctx, n)
+	}
+	return true, nil
+}
+
+func (m *mockDatabase) MarkUploadNotificationSent(ctx context.Context, uploadID int64, key string, sentAt time.Time) error {
+	if m.markUploadNotificationSentFunc != nil {
+		return m.markUploadNotificationSentFunc(ctx, uploadID, key, sentAt)
+	}
+	return nil
+}
+
+func (m *mockDatabase) GetPendingUploadNotifications(ctx context.Context, before time.Time) ([]database.UploadNotification, error) {
+	if m.getPendingUploadNotificationsFunc != nil {
+		return m.getPendingUploadNotificationsFunc(ctx, before)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) RecordNodeAction(ctx context.Context, action database.NodeAction) error {
+	if m.recordNodeActionFunc != nil {
+		return m.recordNodeActionFunc(ctx, action)
+	}
+	return nil
+}
+
+func (m *mockDatabase) RecordUploadLogs(ctx context.Context, uploadID int64, lines []database.UploadLogLine) error {
+	if m.recordUploadLogsFunc != nil {
+		return m.recordUploadLogsFunc(ctx, uploadID, lines)
+	}
+	return nil
+}
+
+func (m *mockDatabase) UpsertCatalogEntry(ctx context.Context, entry database.CatalogEntry) (bool, error) {
+	if m.upsertCatalogEntryFunc != nil {
+		return m.upsertCatalogEntryFunc(ctx, entry)
+	}
+	return true, nil
+}
+
+func (m *mockDatabase) GetActiveNotificationSnooze(ctx context.Context, nodeName string, now time.Time) (*database.NotificationSnooze, error) {
+	if m.getActiveNotificationSnoozeFunc != nil {
+		return m.getActiveNotificationSnoozeFunc(ctx, nodeName, now)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) GetNodePause(ctx context.Context, nodeName string) (*database.NodePause, error) {
+	if m.getNodePauseFunc != nil {
+		return m.getNodePauseFunc(ctx, nodeName)
+	}
 	return nil, nil
 }
 
+func (m *mockDatabase) CountConsecutiveFailedUploads(ctx context.Context, nodeName string) (int, error) {
+	if m.countConsecutiveFailedUploadsFunc != nil {
+		return m.countConsecutiveFailedUploadsFunc(ctx, nodeName)
+	}
+	return 0, nil
+}
+
+func (m *mockDatabase) OpenNodeCircuit(ctx context.Context, nodeName string, failures int, openedAt time.Time) (bool, error) {
+	if m.openNodeCircuitFunc != nil {
+		return m.openNodeCircuitFunc(ctx, nodeName, failures, openedAt)
+	}
+	return true, nil
+}
+
+func (m *mockDatabase) CloseNodeCircuit(ctx context.Context, nodeName string) (bool, error) {
+	if m.closeNodeCircuitFunc != nil {
+		return m.closeNodeCircuitFunc(ctx, nodeName)
+	}
+	return false, nil
+}
+
 type mockProtocolModule struct {
 	name               string
 	collectMetricsFunc func(ctx context.Context, config config.NodeConfig) (map[string]interface{}, error)
@@ -155,6 +410,16 @@ func (m *mockProtocolModule) CollectMetrics(ctx context.Context, cfg config.Node
 	return map[string]interface{}{"test": "data"}, nil
 }
 
+// mockFinalityModule is a protocol module that also reports the finalized head
+type mockFinalityModule struct {
+	mockProtocolModule
+	finalizedBlockFunc func(ctx context.Context, cfg config.NodeConfig) (int64, error)
+}
+
+func (m *mockFinalityModule) FinalizedBlock(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+	return m.finalizedBlockFunc(ctx, cfg)
+}
+
 type mockNotificationModule struct {
 	name     string
 	sendFunc func(ctx context.Context, url string, payload notification.NotificationPayload) error
@@ -194,6 +459,36 @@ func TestCronScheduler_AddJob(t *testing.T) {
 	}
 }
 
+func TestCronScheduler_SetLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	scheduler := NewCronScheduler(logger)
+	scheduler.SetLocation(tokyo)
+	scheduler.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+
+	if err := scheduler.AddJob("daily@03:00", Named("local", &mockJob{})); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	// A schedule naming its own timezone ignores the scheduler's
+	if err := scheduler.AddJob("CRON_TZ=UTC daily@03:00", Named("utc", &mockJob{})); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if next := scheduler.nextRun("local"); next == nil || !next.Equal(time.Date(2026, 10, 17, 3, 0, 0, 0, tokyo)) {
+		t.Errorf("Expected the next run at 03:00 in Tokyo, got %v", next)
+	}
+	if next := scheduler.nextRun("utc"); next == nil || !next.Equal(time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the next run at 03:00 UTC, got %v", next)
+	}
+}
+
 func TestCronScheduler_StartStop(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
@@ -313,6 +608,64 @@ func TestNodeUploadJob_SkipWhenUploadRunning(t *testing.T) {
 	}
 }
 
+func TestNodeUploadJob_StartedConcurrently(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	// The upload manager finds the node's upload created by another trigger
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			return 0, fmt.Errorf("failed to create upload record: %w for node %s (upload 7)", upload.ErrUploadRunning, nodeName)
+		},
+	}
+
+	var result string
+	db := &mockDatabase{
+		saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+			result = *state.LastResult
+			return nil
+		},
+	}
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+	var events []notification.NotificationEvent
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			events = append(events, payload.Event)
+			return nil
+		},
+	})
+	notifyConfig := &config.NotificationConfig{
+		Skip:    true,
+		Failure: true,
+		Types:   map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+
+	job := NewNodeUploadJob(
+		"test-node",
+		config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *"},
+		protocolRegistry,
+		uploadManager,
+		db,
+		notifyRegistry,
+		notifyConfig,
+		logger,
+	)
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("expected no error when another trigger started the upload, got %v", err)
+	}
+	if result != scheduleResultSkipped {
+		t.Errorf("expected %q result, got %q", scheduleResultSkipped, result)
+	}
+	if len(events) != 1 || events[0] != notification.EventSkip {
+		t.Errorf("expected one skip notification, got %v", events)
+	}
+}
+
 func TestNodeUploadJob_FullWorkflow(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
@@ -338,10 +691,13 @@ func TestNodeUploadJob_FullWorkflow(t *testing.T) {
 		shouldSkipFunc: func(ctx context.Context, nodeName string) (bool, error) {
 			return false, nil // Upload not running
 		},
-		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, triggerType string, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
 			uploadInitiated = true
-			if triggerType != "scheduled" {
-				t.Errorf("Expected trigger type 'scheduled', got '%s'", triggerType)
+			if trigger.Type != upload.TriggerScheduled {
+				t.Errorf("Expected trigger type 'scheduled', got '%s'", trigger.Type)
+			}
+			if _, ok := trigger.Metadata["schedule"]; !ok {
+				t.Errorf("Expected trigger metadata to record the schedule, got %v", trigger.Metadata)
 			}
 			if protocol != "ethereum" {
 				t.Errorf("Expected protocol 'ethereum', got '%s'", protocol)
@@ -353,7 +709,7 @@ func TestNodeUploadJob_FullWorkflow(t *testing.T) {
 				NodeType:     "archive", // Mock node type
 				StartedAt:    time.Now(),
 				Status:       "running",
-				TriggerType:  triggerType,
+				TriggerType:  string(trigger.Type),
 				ProtocolData: database.JSONB(protocolData),
 			}
 			return db.CreateUpload(ctx, upload)
@@ -500,83 +856,1035 @@ func TestNodeUploadJob_NotificationSending(t *testing.T) {
 	}
 }
 
-// Test UploadMonitorJob
-
-func TestUploadMonitorJob_NoRunningUploads(t *testing.T) {
+func TestNodeUploadJob_WaitsForFinality(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
 
-	uploadManager := &mockUploadManager{}
+	finalizedCalls := 0
+	var initiatedData map[string]interface{}
+	var savedResults []string
 
-	db := &mockDatabase{
-		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
-			return []database.Upload{}, nil
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockFinalityModule{
+		mockProtocolModule: mockProtocolModule{
+			name: "ethereum",
+			collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+				return map[string]interface{}{"latest_block": int64(1000)}, nil
+			},
+		},
+		finalizedBlockFunc: func(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+			finalizedCalls++
+			// Finality reaches the snapshot point on the third check
+			return int64(997 + finalizedCalls), nil
+		},
+	})
+
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			initiatedData = protocolData
+			return 1, nil
 		},
 	}
 
-	protocolRegistry := protocol.NewRegistry()
-	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, map[string]config.NodeConfig{}, logger)
+	job := NewNodeUploadJob(
+		"test-node",
+		config.NodeConfig{Protocol: "ethereum", WaitForFinality: true},
+		protocolRegistry,
+		uploadManager,
+		&mockDatabase{
+			saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+				savedResults = append(savedResults, *state.LastResult)
+				return nil
+			},
+		},
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+	job.finalityPollInterval = time.Millisecond
 
-	ctx := context.Background()
-	err := job.Run(ctx)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
 
-	if err != nil {
-		t.Errorf("Expected no error with no running uploads, got: %v", err)
+	// The wait is visible in the schedule state until the run finishes
+	if len(savedResults) != 2 || savedResults[0] != scheduleResultWaitingFinality || savedResults[1] != scheduleResultInitiated {
+		t.Errorf("Expected schedule results [waiting_finality initiated], got %v", savedResults)
+	}
+	if finalizedCalls != 3 {
+		t.Errorf("Expected 3 finality checks, got %d", finalizedCalls)
+	}
+	if initiatedData == nil || initiatedData["finalized_block"] != int64(1000) {
+		t.Errorf("Expected finalized_block 1000 in protocol data, got %v", initiatedData)
 	}
 }
 
-func TestUploadMonitorJob_MonitorsMultipleUploads(t *testing.T) {
+func TestNodeUploadJob_FinalityTimeout(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
 
-	monitoredUploads := make(map[int64]bool)
-	var mu sync.Mutex
-
-	uploadManager := &mockUploadManager{
-		monitorProgressWithNotificationFunc: func(ctx context.Context, uploadID int64, nodeName string) (bool, error) {
-			mu.Lock()
-			monitoredUploads[uploadID] = true
-			mu.Unlock()
-			return false, nil // Return false to indicate upload is still running
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockFinalityModule{
+		mockProtocolModule: mockProtocolModule{
+			name: "ethereum",
+			collectMetricsFunc: func(ctx context.Context, cfg config.NodeConfig) (map[string]interface{}, error) {
+				return map[string]interface{}{"latest_block": int64(1000)}, nil
+			},
 		},
-	}
-
-	db := &mockDatabase{
-		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
-			return []database.Upload{
-				{ID: 1, NodeName: "node1", Status: "running"},
-				{ID: 2, NodeName: "node2", Status: "running"},
-				{ID: 3, NodeName: "node3", Status: "running"},
-			}, nil
+		finalizedBlockFunc: func(ctx context.Context, cfg config.NodeConfig) (int64, error) {
+			return 900, nil
 		},
-	}
+	})
 
-	protocolRegistry := protocol.NewRegistry()
-	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, map[string]config.NodeConfig{}, logger)
-
-	ctx := context.Background()
-	err := job.Run(ctx)
-
-	if err != nil {
-		t.Fatalf("Job execution failed: %v", err)
+	uploadInitiated := false
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			uploadInitiated = true
+			return 1, nil
+		},
 	}
 
-	// Check that all uploads were monitored
-	mu.Lock()
-	defer mu.Unlock()
+	job := NewNodeUploadJob(
+		"test-node",
+		config.NodeConfig{Protocol: "ethereum", WaitForFinality: true, FinalityTimeout: "20ms"},
+		protocolRegistry,
+		uploadManager,
+		&mockDatabase{},
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+	job.finalityPollInterval = time.Millisecond
 
-	if len(monitoredUploads) != 3 {
-		t.Errorf("Expected 3 uploads to be monitored, got %d", len(monitoredUploads))
+	if err := job.Run(context.Background()); err == nil {
+		t.Error("Expected error when finality is not reached")
 	}
-
-	for _, id := range []int64{1, 2, 3} {
-		if !monitoredUploads[id] {
-			t.Errorf("Upload %d was not monitored", id)
-		}
+	if uploadInitiated {
+		t.Error("Expected upload not to be initiated before finality")
 	}
 }
 
-func TestUploadMonitorJob_NodeIsolation(t *testing.T) {
+// Test UploadMonitorJob
+
+func TestNodeUploadJob_Queued(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			t.Error("Expected a queued run not to start an upload")
+			return 0, nil
+		},
+	}
+
+	var queuedNode, queuedTrigger string
+	var queuedPriority int
+	var savedResult string
+	db := &mockDatabase{
+		createUploadRequestFunc: func(ctx context.Context, nodeName string, triggerType string, priority int, triggerMetadata database.JSONB) (int64, error) {
+			queuedNode, queuedTrigger, queuedPriority = nodeName, triggerType, priority
+			return 1, nil
+		},
+		saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+			savedResult = *state.LastResult
+			return nil
+		},
+	}
+
+	job := NewNodeUploadJob(
+		"test-node",
+		config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *", Priority: 3},
+		protocol.NewRegistry(),
+		uploadManager,
+		db,
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+	job.SetQueued(true)
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if queuedNode != "test-node" || queuedTrigger != "scheduled" || queuedPriority != 3 {
+		t.Errorf("expected scheduled request for test-node at priority 3, got %s %s %d", queuedNode, queuedTrigger, queuedPriority)
+	}
+	if savedResult != "queued" {
+		t.Errorf("expected schedule state 'queued', got %q", savedResult)
+	}
+}
+
+func TestNodeUploadJob_IncrementalUpload(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	baseID := int64(10)
+	olderID := int64(9)
+	listing := []database.UploadObject{{UploadID: 10, ObjectKey: "chunks/000001"}}
+
+	tests := []struct {
+		name            string
+		latest          *database.Upload
+		chain           map[int64]*database.Upload // earlier uploads by ID
+		objects         []database.UploadObject
+		fullEvery       int
+		wantIncremental bool
+	}{
+		{name: "no previous snapshot", latest: nil},
+		{name: "base with contents", latest: &database.Upload{ID: 10}, objects: listing, wantIncremental: true},
+		{name: "base without contents", latest: &database.Upload{ID: 10}},
+		{
+			name:            "chain below full_every",
+			latest:          &database.Upload{ID: 10, BaseUploadID: &olderID},
+			chain:           map[int64]*database.Upload{9: {ID: 9}},
+			objects:         listing,
+			fullEvery:       2,
+			wantIncremental: true,
+		},
+		{
+			name:      "chain reached full_every",
+			latest:    &database.Upload{ID: 10, BaseUploadID: &olderID},
+			chain:     map[int64]*database.Upload{9: {ID: 9, BaseUploadID: &baseID}},
+			objects:   listing,
+			fullEvery: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var full, incremental bool
+			var gotBase upload.IncrementalBase
+			uploadManager := &mockUploadManager{
+				initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+					full = true
+					return 1, nil
+				},
+				initiateIncrementalUploadFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, command []string, base upload.IncrementalBase) (int64, error) {
+					incremental = true
+					gotBase = base
+					return 2, nil
+				},
+			}
+			db := &mockDatabase{
+				getLatestCompletedUploadForNodeFunc: func(ctx context.Context, nodeName string) (*database.Upload, error) {
+					return tt.latest, nil
+				},
+				getUploadFunc: func(ctx context.Context, uploadID int64) (*database.Upload, error) {
+					return tt.chain[uploadID], nil
+				},
+				getUploadObjectsFunc: func(ctx context.Context, uploadID int64) ([]database.UploadObject, error) {
+					return tt.objects, nil
+				},
+			}
+			protocolRegistry := protocol.NewRegistry()
+			protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+			job := NewNodeUploadJob(
+				"test-node",
+				config.NodeConfig{
+					Protocol:    "ethereum",
+					Schedule:    "0 0 * * * *",
+					Incremental: &config.IncrementalConfig{Command: []string{"upload-incremental", "{base_manifest}"}, FullEvery: tt.fullEvery},
+				},
+				protocolRegistry,
+				uploadManager,
+				db,
+				notification.NewRegistry(),
+				nil,
+				logger,
+			)
+			if err := job.Run(context.Background()); err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+
+			if incremental != tt.wantIncremental || full == tt.wantIncremental {
+				t.Fatalf("expected incremental=%v, got incremental=%v full=%v", tt.wantIncremental, incremental, full)
+			}
+			if tt.wantIncremental && (gotBase.UploadID != 10 || len(gotBase.Objects) != 1 || gotBase.Objects[0].Key != "chunks/000001") {
+				t.Errorf("unexpected incremental base: %+v", gotBase)
+			}
+		})
+	}
+}
+
+func TestUploadMonitorJob_NoRunningUploads(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	uploadManager := &mockUploadManager{}
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{}, nil
+		},
+	}
+
+	protocolRegistry := protocol.NewRegistry()
+	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, map[string]config.NodeConfig{}, 0, logger)
+
+	ctx := context.Background()
+	err := job.Run(ctx)
+
+	if err != nil {
+		t.Errorf("Expected no error with no running uploads, got: %v", err)
+	}
+}
+
+func TestUploadMonitorJob_MonitorsRunningUploadsInPages(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	monitoredUploads := make(map[int64]bool)
+	var mu sync.Mutex
+
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			mu.Lock()
+			monitoredUploads[uploadID] = true
+			mu.Unlock()
+			return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil
+		},
+	}
+
+	chunks := 10
+	var running []database.Upload
+	for id := int64(1); id <= 5; id++ {
+		running = append(running, database.Upload{ID: id, NodeName: fmt.Sprintf("node%d", id), Status: "running", ChunksCompleted: &chunks})
+	}
+	var pages []database.UploadPage
+	db := &mockDatabase{
+		listRunningUploadsFunc: func(ctx context.Context, page database.UploadPage) ([]database.Upload, error) {
+			pages = append(pages, page)
+			var result []database.Upload
+			for _, u := range running {
+				if u.ID > page.AfterID && len(result) < page.Limit {
+					result = append(result, u)
+				}
+			}
+			return result, nil
+		},
+	}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notification.NewRegistry(), nil, map[string]config.NodeConfig{}, 3, logger)
+	job.pageSize = 2
+	if _, ok := job.LastPass(); ok {
+		t.Error("expected no pass before the first run")
+	}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if len(monitoredUploads) != 5 {
+		t.Errorf("expected every upload to be monitored, got %v", monitoredUploads)
+	}
+	if pass, ok := job.LastPass(); !ok || pass.Uploads != 5 || pass.Probed != 0 || pass.StartedAt.IsZero() {
+		t.Errorf("expected the pass over 5 uploads recorded, got %+v", pass)
+	}
+	if fmt.Sprint(pages) != "[{0 2 } {2 2 } {4 2 }]" {
+		t.Errorf("expected pages after each page's last ID, got %v", pages)
+	}
+
+	// Progress of uploads on earlier pages is kept, and dropped once they stop running
+	if len(job.progress) != 5 {
+		t.Errorf("expected progress tracked for every page, got %d uploads", len(job.progress))
+	}
+	running = running[:1]
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+	if len(job.progress) != 1 || job.progress[1] == nil {
+		t.Errorf("expected only upload 1 tracked, got %v", job.progress)
+	}
+}
+
+func TestUploadMonitorJob_MonitorsMultipleUploads(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	monitoredUploads := make(map[int64]bool)
+	var mu sync.Mutex
+
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			mu.Lock()
+			monitoredUploads[uploadID] = true
+			mu.Unlock()
+			return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil // Upload is still running
+		},
+	}
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "node1", Status: "running"},
+				{ID: 2, NodeName: "node2", Status: "running"},
+				{ID: 3, NodeName: "node3", Status: "running"},
+			}, nil
+		},
+	}
+
+	protocolRegistry := protocol.NewRegistry()
+	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, map[string]config.NodeConfig{}, 0, logger)
+
+	ctx := context.Background()
+	err := job.Run(ctx)
+
+	if err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	// Check that all uploads were monitored
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(monitoredUploads) != 3 {
+		t.Errorf("Expected 3 uploads to be monitored, got %d", len(monitoredUploads))
+	}
+
+	for _, id := range []int64{1, 2, 3} {
+		if !monitoredUploads[id] {
+			t.Errorf("Upload %d was not monitored", id)
+		}
+	}
+}
+
+func TestUploadMonitorJob_TimesOutStalledUpload(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	monitored := make(map[int64]bool)
+	var timedOutID int64
+	var timedOutCancel bool
+
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			mu.Lock()
+			monitored[uploadID] = true
+			mu.Unlock()
+			return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil
+		},
+		timeoutUploadFunc: func(ctx context.Context, uploadID int64, nodeName string, maxDuration time.Duration, cancel bool) error {
+			mu.Lock()
+			timedOutID = uploadID
+			timedOutCancel = cancel
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	var actions []database.NodeAction
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "stuck-node", Status: "running", StartedAt: time.Now().Add(-13 * time.Hour)},
+				{ID: 2, NodeName: "healthy-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		recordNodeActionFunc: func(ctx context.Context, action database.NodeAction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			actions = append(actions, action)
+			return nil
+		},
+	}
+
+	var sentEvent notification.NotificationEvent
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			sentEvent = payload.Event
+			mu.Unlock()
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Failure: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"stuck-node":   {Protocol: "ethereum", MaxDuration: "12h", CancelStalled: true},
+		"healthy-node": {Protocol: "ethereum", MaxDuration: "12h"},
+	}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if timedOutID != 1 || !timedOutCancel {
+		t.Errorf("Expected upload 1 to be timed out with cancel, got id=%d cancel=%v", timedOutID, timedOutCancel)
+	}
+	if monitored[1] {
+		t.Error("Expected stalled upload not to be monitored")
+	}
+	if !monitored[2] {
+		t.Error("Expected healthy upload to be monitored")
+	}
+	if sentEvent != notification.EventFailure {
+		t.Errorf("Expected EventFailure, got %v", sentEvent)
+	}
+	// The cancellation is logged for the node
+	if len(actions) != 1 || actions[0].NodeName != "stuck-node" || actions[0].Action != database.NodeActionCancelled || actions[0].UploadID == nil || *actions[0].UploadID != 1 {
+		t.Errorf("Expected the cancellation of upload 1 in the node's action log, got %+v", actions)
+	}
+}
+
+func TestUploadMonitorJob_NotifiesCompletionOutcome(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	outcomes := map[string]upload.CompletionOutcome{
+		"success-node":   upload.OutcomeSuccess,
+		"failure-node":   upload.OutcomeFailure,
+		"cancelled-node": upload.OutcomeCancelled,
+	}
+
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			result := upload.CompletionResult{Outcome: outcomes[nodeName]}
+			if result.Outcome == upload.OutcomeSuccess {
+				size := int64(1 << 40)
+				result.SizeBytes = &size
+			}
+			return result, nil
+		},
+	}
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "success-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "failure-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 3, NodeName: "cancelled-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+	}
+
+	var mu sync.Mutex
+	sent := make(map[string]notification.NotificationEvent)
+	var completeDetails map[string]interface{}
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			sent[payload.NodeName] = payload.Event
+			if payload.Event == notification.EventComplete {
+				completeDetails = payload.Details
+			}
+			mu.Unlock()
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Failure:  true,
+		Complete: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"success-node":   {Protocol: "ethereum"},
+		"failure-node":   {Protocol: "ethereum"},
+		"cancelled-node": {Protocol: "ethereum"},
+	}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if sent["success-node"] != notification.EventComplete {
+		t.Errorf("Expected EventComplete for success, got %v", sent["success-node"])
+	}
+	if completeDetails["size_bytes"] != int64(1<<40) {
+		t.Errorf("Expected the snapshot size in the completion details, got %v", completeDetails["size_bytes"])
+	}
+	if sent["failure-node"] != notification.EventFailure {
+		t.Errorf("Expected EventFailure for failure, got %v", sent["failure-node"])
+	}
+	if event, ok := sent["cancelled-node"]; ok {
+		t.Errorf("Expected no notification for a cancelled upload, got %v", event)
+	}
+}
+
+func TestUploadMonitorJob_RecordsJobLogs(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	outcomes := map[string]upload.CompletionOutcome{
+		"failure-node": upload.OutcomeFailure,
+		"running-node": upload.OutcomeRunning,
+	}
+	var tailed []string
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: outcomes[nodeName]}, nil
+		},
+		tailJobLogsFunc: func(ctx context.Context, nodeName string, n int) ([]upload.JobLogLine, error) {
+			tailed = append(tailed, nodeName)
+			if n != database.MaxUploadLogLines {
+				t.Errorf("Expected %d lines requested, got %d", database.MaxUploadLogLines, n)
+			}
+			// The lines read before the log command failed are still stored
+			return []upload.JobLogLine{
+				{Stream: executor.StreamStdout, Line: "uploading chunk 41"},
+				{Stream: executor.StreamStderr, Line: "error: no space left on device"},
+			}, errors.New("exit status 1")
+		},
+	}
+
+	var recordedID int64
+	var recorded []database.UploadLogLine
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "failure-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "running-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		recordUploadLogsFunc: func(ctx context.Context, uploadID int64, lines []database.UploadLogLine) error {
+			recordedID, recorded = uploadID, lines
+			return nil
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{"failure-node": {Protocol: "ethereum"}, "running-node": {Protocol: "ethereum"}}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notification.NewRegistry(), nil, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if len(tailed) != 1 || tailed[0] != "failure-node" {
+		t.Errorf("Expected only the finished upload's logs captured, got %v", tailed)
+	}
+	if recordedID != 1 || len(recorded) != 2 || recorded[1].Stream != "stderr" || recorded[1].Line != "error: no space left on device" || recorded[0].UploadID != 1 {
+		t.Errorf("Expected both lines stored for upload 1, got %d: %+v", recordedID, recorded)
+	}
+}
+
+func TestUploadMonitorJob_RecordsCatalogEntry(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	size := int64(1 << 30)
+	finishedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	results := map[string]upload.CompletionResult{
+		"eth-archive": {Outcome: upload.OutcomeSuccess, SizeBytes: &size, FinishedAt: &finishedAt},
+		"eth-rclone":  {Outcome: upload.OutcomeSuccess, Location: "s3:snapshots/eth-rclone"},
+		"eth-failed":  {Outcome: upload.OutcomeFailure},
+	}
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return results[nodeName], nil
+		},
+	}
+
+	var mu sync.Mutex
+	entries := make(map[string]database.CatalogEntry)
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "eth-archive", Protocol: "ethereum", NodeType: "archive", Tenant: "team-a", Status: "running", StartedAt: time.Now().Add(-time.Hour),
+					ProtocolData: database.JSONB{"latest_block": float64(20000000), "latest_slot": float64(9000000)}},
+				{ID: 2, NodeName: "eth-rclone", Protocol: "ethereum", NodeType: "full", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 3, NodeName: "eth-failed", Protocol: "ethereum", NodeType: "full", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		upsertCatalogEntryFunc: func(ctx context.Context, entry database.CatalogEntry) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			entries[entry.NodeName] = entry
+			return true, nil
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"eth-archive": {Protocol: "ethereum", Network: "mainnet", SnapshotLocation: "https://snapshots.example.com/eth-archive"},
+		"eth-rclone":  {Protocol: "ethereum", Network: "mainnet", SnapshotLocation: "unused"},
+		"eth-failed":  {Protocol: "ethereum", Network: "mainnet"},
+	}
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notification.NewRegistry(), nil, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected entries for the completed uploads only, got %+v", entries)
+	}
+	archive := entries["eth-archive"]
+	if archive.Protocol != "ethereum" || archive.Network != "mainnet" || archive.NodeType != "archive" || archive.Tenant != "team-a" || archive.UploadID != 1 {
+		t.Errorf("Expected the archive upload keyed by its protocol, network and type, got %+v", archive)
+	}
+	if archive.LatestBlock == nil || *archive.LatestBlock != 20000000 || archive.LatestSlot == nil || *archive.LatestSlot != 9000000 {
+		t.Errorf("Expected the snapshot's block and slot, got %v and %v", archive.LatestBlock, archive.LatestSlot)
+	}
+	if archive.SizeBytes == nil || *archive.SizeBytes != size || !archive.CompletedAt.Equal(finishedAt) {
+		t.Errorf("Expected the snapshot's size and finish time, got %v and %v", archive.SizeBytes, archive.CompletedAt)
+	}
+	if archive.Location == nil || *archive.Location != "https://snapshots.example.com/eth-archive" {
+		t.Errorf("Expected the node's snapshot location, got %v", archive.Location)
+	}
+	if rclone := entries["eth-rclone"]; rclone.Location == nil || *rclone.Location != "s3:snapshots/eth-rclone" || rclone.LatestBlock != nil {
+		t.Errorf("Expected the engine's location, got %+v", rclone)
+	}
+}
+
+func TestUploadMonitorJob_FailureNotificationIncludesLogExcerpt(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	status := "Finished with exit code 1 and message 'Upload failed'"
+	var requestedLines int
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeFailure, Message: &status}, nil
+		},
+		fetchJobLogsFunc: func(ctx context.Context, nodeName string, n int) ([]string, error) {
+			requestedLines = n
+			return []string{"uploading chunk 41", "error: no space left on device"}, nil
+		},
+	}
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "test-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+	}
+
+	var sentDetails map[string]interface{}
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			sentDetails = payload.Details
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Failure:         true,
+		FailureLogLines: 20,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{"test-node": {Protocol: "ethereum"}}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if requestedLines != 20 {
+		t.Errorf("Expected 20 log lines to be requested, got %d", requestedLines)
+	}
+	if sentDetails == nil {
+		t.Fatal("Expected a failure notification")
+	}
+	if sentDetails["failure_category"] != string(upload.FailureDiskFull) {
+		t.Errorf("Expected failure_category %q, got %v", upload.FailureDiskFull, sentDetails["failure_category"])
+	}
+	if sentDetails["log_excerpt"] != "uploading chunk 41\nerror: no space left on device" {
+		t.Errorf("Unexpected log_excerpt: %v", sentDetails["log_excerpt"])
+	}
+
+	// Without failure_log_lines the category is still reported but no logs are fetched
+	requestedLines = 0
+	notifyConfig.FailureLogLines = 0
+	sentDetails = nil
+	job = NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+	if requestedLines != 0 {
+		t.Errorf("Expected no log fetch, got a request for %d lines", requestedLines)
+	}
+	if _, ok := sentDetails["log_excerpt"]; ok {
+		t.Error("Expected no log_excerpt without failure_log_lines")
+	}
+	if sentDetails["failure_category"] != string(upload.FailureUnknown) {
+		t.Errorf("Expected failure_category %q, got %v", upload.FailureUnknown, sentDetails["failure_category"])
+	}
+}
+
+func TestUploadMonitorJob_RecordsSnapshotContents(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var listedCommand []string
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeSuccess}, nil
+		},
+		fetchContentListingFunc: func(ctx context.Context, nodeName string, command []string) ([]upload.ContentObject, error) {
+			listedCommand = command
+			size := int64(1024)
+			return []upload.ContentObject{
+				{Key: "chunks/000001", SizeBytes: &size, Checksum: "sha256:abc"},
+				{Key: "chunks/000002", SizeBytes: &size},
+			}, nil
+		},
+	}
+
+	var recordedID int64
+	var recorded []database.UploadObject
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 3, NodeName: "test-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+		recordUploadObjectsFunc: func(ctx context.Context, uploadID int64, objects []database.UploadObject) error {
+			recordedID = uploadID
+			recorded = objects
+			return nil
+		},
+	}
+
+	var sentDetails map[string]interface{}
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			sentDetails = payload.Details
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Complete: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{"test-node": {Protocol: "ethereum"}}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	job.SetContentListing([]string{"list-snapshot", "{node}"})
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	if len(listedCommand) != 2 || listedCommand[0] != "list-snapshot" {
+		t.Errorf("Expected the configured listing command, got %v", listedCommand)
+	}
+	if recordedID != 3 || len(recorded) != 2 {
+		t.Fatalf("Expected 2 objects recorded for upload 3, got %d for upload %d", len(recorded), recordedID)
+	}
+	if recorded[0].Checksum == nil || *recorded[0].Checksum != "sha256:abc" || recorded[1].Checksum != nil {
+		t.Errorf("Unexpected recorded checksums: %+v", recorded)
+	}
+	if sentDetails["objects"] != 2 || sentDetails["content_bytes"] != int64(2048) {
+		t.Errorf("Expected objects and content_bytes in the completion details, got %v", sentDetails)
+	}
+
+	if _, ok := sentDetails["changed_objects"]; ok {
+		t.Error("Expected no changed_objects for a full upload")
+	}
+
+	// An incremental upload reports how many objects changed since its base
+	baseID := int64(2)
+	size := int64(1024)
+	db.getRunningUploadsFunc = func(ctx context.Context) ([]database.Upload, error) {
+		return []database.Upload{
+			{ID: 3, NodeName: "test-node", Status: "running", StartedAt: time.Now().Add(-time.Hour), BaseUploadID: &baseID},
+		}, nil
+	}
+	db.getUploadObjectsFunc = func(ctx context.Context, uploadID int64) ([]database.UploadObject, error) {
+		checksum := "sha256:abc"
+		return []database.UploadObject{{UploadID: uploadID, ObjectKey: "chunks/000001", SizeBytes: &size, Checksum: &checksum}}, nil
+	}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+	if sentDetails["base_upload_id"] != int64(2) || sentDetails["changed_objects"] != 1 {
+		t.Errorf("Expected base_upload_id 2 and 1 changed object, got %v", sentDetails)
+	}
+
+	// Without a listing command nothing is listed or recorded
+	listedCommand = nil
+	recorded = nil
+	job = NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+	if listedCommand != nil || recorded != nil {
+		t.Error("Expected no content listing without a command")
+	}
+	if _, ok := sentDetails["objects"]; ok {
+		t.Error("Expected no objects detail without a command")
+	}
+}
+
+func TestUploadMonitorJob_AlertsOnDetectionLag(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	lags := map[string]time.Duration{
+		"slow-node": 20 * time.Minute,
+		"fast-node": 30 * time.Second,
+	}
+	uploadManager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			lag := lags[nodeName]
+			return upload.CompletionResult{Outcome: upload.OutcomeSuccess, DetectionLag: &lag}, nil
+		},
+	}
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			return []database.Upload{
+				{ID: 1, NodeName: "slow-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+				{ID: 2, NodeName: "fast-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)},
+			}, nil
+		},
+	}
+
+	var mu sync.Mutex
+	var lagAlerts []string
+	var completeLag interface{}
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			switch payload.Event {
+			case notification.EventMonitorLag:
+				lagAlerts = append(lagAlerts, payload.NodeName)
+			case notification.EventComplete:
+				if payload.NodeName == "slow-node" {
+					completeLag = payload.Details["detection_lag"]
+				}
+			}
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Complete:   true,
+		MonitorLag: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+
+	nodes := map[string]config.NodeConfig{
+		"slow-node": {Protocol: "ethereum"},
+		"fast-node": {Protocol: "ethereum"},
+	}
+
+	job := NewUploadMonitorJob(uploadManager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 0, logger)
+	job.SetMonitorLagThreshold(5 * time.Minute)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lagAlerts) != 1 || lagAlerts[0] != "slow-node" {
+		t.Errorf("Expected a monitor_lag alert for slow-node only, got %v", lagAlerts)
+	}
+	if completeLag != "20m0s" {
+		t.Errorf("Expected detection_lag 20m0s in the completion details, got %v", completeLag)
+	}
+}
+
+func TestUploadMonitorJob_DetectsStalledProgress(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	chunks := 10
+	var stalledSince *time.Time
+	var stalledCalls int
+	var sentEvents []notification.NotificationEvent
+
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			completed := chunks
+			return []database.Upload{
+				{ID: 1, NodeName: "node1", Status: "running", ChunksCompleted: &completed, StalledSince: stalledSince},
+			}, nil
+		},
+		setUploadStalledFunc: func(ctx context.Context, uploadID int64, since *time.Time) error {
+			stalledCalls++
+			stalledSince = since
+			return nil
+		},
+	}
+
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			sentEvents = append(sentEvents, payload.Event)
+			return nil
+		},
+	})
+
+	notifyConfig := &config.NotificationConfig{
+		Stalled: true,
+		Types: map[string]config.NotificationTypeConfig{
+			"discord": {URL: "https://example.com/webhook"},
+		},
+	}
+	nodes := map[string]config.NodeConfig{"node1": {Protocol: "ethereum"}}
+
+	job := NewUploadMonitorJob(&mockUploadManager{}, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 2, logger)
+	ctx := context.Background()
+
+	// First run records the baseline, the next two see no progress
+	for i := 0; i < 3; i++ {
+		if err := job.Run(ctx); err != nil {
+			t.Fatalf("Job execution failed: %v", err)
+		}
+	}
+	if stalledSince == nil {
+		t.Fatal("Expected upload to be marked stalled")
+	}
+	if len(sentEvents) != 1 || sentEvents[0] != notification.EventStalled {
+		t.Fatalf("Expected one EventStalled notification, got %v", sentEvents)
+	}
+
+	// Still stalled - no repeated notification
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+	if len(sentEvents) != 1 {
+		t.Errorf("Expected no repeated notification, got %v", sentEvents)
+	}
+
+	// Progress resumes - stalled mark is cleared
+	chunks = 11
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Job execution failed: %v", err)
+	}
+	if stalledSince != nil || stalledCalls != 2 {
+		t.Errorf("Expected stalled mark to be cleared, got %v after %d calls", stalledSince, stalledCalls)
+	}
+}
+
+func TestUploadMonitorJob_NodeIsolation(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
 
@@ -584,16 +1892,16 @@ func TestUploadMonitorJob_NodeIsolation(t *testing.T) {
 	var mu sync.Mutex
 
 	uploadManager := &mockUploadManager{
-		monitorProgressWithNotificationFunc: func(ctx context.Context, uploadID int64, nodeName string) (bool, error) {
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
 			mu.Lock()
 			monitoredUploads[uploadID] = true
 			mu.Unlock()
 
 			// Simulate failure for upload 2
 			if uploadID == 2 {
-				return false, errors.New("monitoring failed")
+				return upload.CompletionResult{}, errors.New("monitoring failed")
 			}
-			return false, nil // Return false to indicate upload is still running
+			return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil // Upload is still running
 		},
 	}
 
@@ -608,7 +1916,7 @@ func TestUploadMonitorJob_NodeIsolation(t *testing.T) {
 	}
 
 	protocolRegistry := protocol.NewRegistry()
-	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, map[string]config.NodeConfig{}, logger)
+	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, map[string]config.NodeConfig{}, 0, logger)
 
 	ctx := context.Background()
 	err := job.Run(ctx)
@@ -632,6 +1940,7 @@ func TestUploadMonitorJob_ExternalUploadDiscovery(t *testing.T) {
 
 	var createdUploads []database.Upload
 	var mu sync.Mutex
+	bvStartedAt := time.Now().Add(-3 * time.Hour).UTC().Truncate(time.Second)
 
 	// Mock upload manager that reports a running upload for "external-node"
 	uploadManager := &mockUploadManager{
@@ -639,6 +1948,7 @@ func TestUploadMonitorJob_ExternalUploadDiscovery(t *testing.T) {
 			if nodeName == "external-node" {
 				return &upload.UploadStatus{
 					IsRunning: true,
+					StartedAt: &bvStartedAt,
 					Progress: upload.JSONB{
 						"status":   "running",
 						"progress": "50.0%",
@@ -647,15 +1957,19 @@ func TestUploadMonitorJob_ExternalUploadDiscovery(t *testing.T) {
 			}
 			return &upload.UploadStatus{IsRunning: false}, nil
 		},
-		createUploadRecordWithProgressFunc: func(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}, progressData map[string]interface{}) (int64, error) {
+		createUploadRecordWithProgressFunc: func(ctx context.Context, nodeName, protocol, nodeType string, trigger upload.Trigger, protocolData map[string]interface{}, progressData map[string]interface{}, startedAt *time.Time) (int64, error) {
 			mu.Lock()
 			defer mu.Unlock()
 			upload := database.Upload{
-				ID:          int64(len(createdUploads) + 1),
-				NodeName:    nodeName,
-				Protocol:    protocol,
-				NodeType:    nodeType,
-				TriggerType: triggerType,
+				ID:              int64(len(createdUploads) + 1),
+				NodeName:        nodeName,
+				Protocol:        protocol,
+				NodeType:        nodeType,
+				TriggerType:     string(trigger.Type),
+				TriggerMetadata: database.JSONB(trigger.Metadata),
+			}
+			if startedAt != nil {
+				upload.StartedAt = *startedAt
 			}
 			createdUploads = append(createdUploads, upload)
 			return upload.ID, nil
@@ -682,7 +1996,7 @@ func TestUploadMonitorJob_ExternalUploadDiscovery(t *testing.T) {
 	}
 
 	protocolRegistry := protocol.NewRegistry()
-	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, nodeConfigs, logger)
+	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, nodeConfigs, 0, logger)
 
 	ctx := context.Background()
 
@@ -701,8 +2015,14 @@ func TestUploadMonitorJob_ExternalUploadDiscovery(t *testing.T) {
 		if upload.NodeName != "external-node" {
 			t.Errorf("Expected external upload for 'external-node', got '%s'", upload.NodeName)
 		}
-		if upload.TriggerType != "discovered" {
-			t.Errorf("Expected trigger_type 'discovered', got '%s'", upload.TriggerType)
+		if upload.TriggerType != "external" {
+			t.Errorf("Expected trigger_type 'external', got '%s'", upload.TriggerType)
+		}
+		if !upload.StartedAt.Equal(bvStartedAt) {
+			t.Errorf("Expected the upload to start when bv started it (%s), got %s", bvStartedAt, upload.StartedAt)
+		}
+		if _, ok := upload.TriggerMetadata["discovered_at"].(string); !ok {
+			t.Errorf("Expected the discovery time in the trigger metadata, got %v", upload.TriggerMetadata)
 		}
 	}
 	mu.Unlock()
@@ -773,7 +2093,7 @@ func TestUploadMonitorJob_DoesNotDuplicateTrackedUploads(t *testing.T) {
 	}
 
 	protocolRegistry := protocol.NewRegistry()
-	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, nodeConfigs, logger)
+	job := NewUploadMonitorJob(uploadManager, db, protocolRegistry, notification.NewRegistry(), nil, nodeConfigs, 0, logger)
 
 	ctx := context.Background()
 	err := job.Run(ctx)
@@ -787,3 +2107,109 @@ func TestUploadMonitorJob_DoesNotDuplicateTrackedUploads(t *testing.T) {
 	}
 	mu.Unlock()
 }
+
+func TestUploadMonitorJob_BacksOffNeverUploadedNodes(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	probes := 0
+	notFound := true
+
+	uploadManager := &mockUploadManager{
+		checkUploadStatusFunc: func(ctx context.Context, nodeName string) (*upload.UploadStatus, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			probes++
+			if notFound {
+				return &upload.UploadStatus{IsRunning: false, NotFound: true}, nil
+			}
+			return &upload.UploadStatus{IsRunning: false}, nil
+		},
+	}
+
+	nodeConfigs := map[string]config.NodeConfig{
+		"new-node": {Protocol: "ethereum", Type: "execution"},
+	}
+
+	job := NewUploadMonitorJob(uploadManager, &mockDatabase{}, protocol.NewRegistry(), notification.NewRegistry(), nil, nodeConfigs, 0, logger)
+	now := time.Date(2024, 12, 9, 10, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	runAt := func(offset time.Duration) int {
+		t.Helper()
+		now = time.Date(2024, 12, 9, 10, 0, 0, 0, time.UTC).Add(offset)
+		mu.Lock()
+		probes = 0
+		mu.Unlock()
+		if err := job.Run(ctx); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return probes
+	}
+
+	// First miss backs off for 1 minute, the second for 2 minutes
+	if got := runAt(0); got != 1 {
+		t.Fatalf("Expected initial probe, got %d", got)
+	}
+	if got := runAt(30 * time.Second); got != 0 {
+		t.Errorf("Expected probe to be skipped during backoff, got %d", got)
+	}
+	if got := runAt(time.Minute); got != 1 {
+		t.Errorf("Expected probe after 1 minute backoff, got %d", got)
+	}
+	if got := runAt(2 * time.Minute); got != 0 {
+		t.Errorf("Expected probe to be skipped during doubled backoff, got %d", got)
+	}
+	if got := runAt(3 * time.Minute); got != 1 {
+		t.Errorf("Expected probe after 2 minute backoff, got %d", got)
+	}
+
+	// Once a job exists, probing resumes on every run
+	mu.Lock()
+	notFound = false
+	mu.Unlock()
+	if got := runAt(7 * time.Minute); got != 1 {
+		t.Errorf("Expected probe after 4 minute backoff, got %d", got)
+	}
+	if got := runAt(8 * time.Minute); got != 1 {
+		t.Errorf("Expected backoff to reset once a job exists, got %d", got)
+	}
+}
+
+func TestAddThroughputDetails(t *testing.T) {
+	now := time.Date(2024, 12, 9, 12, 0, 0, 0, time.UTC)
+	total := 1200
+	rate := 7.5
+	eta := now.Add(30 * time.Minute)
+	u := database.Upload{
+		StartedAt:           now.Add(-2 * time.Hour),
+		ChunksTotal:         &total,
+		ChunksPerMinute:     &rate,
+		EstimatedCompletion: &eta,
+	}
+
+	completed := map[string]interface{}{}
+	addThroughputDetails(completed, u, now, true)
+	if completed["duration"] != "2h0m0s" {
+		t.Errorf("Expected duration 2h0m0s, got %v", completed["duration"])
+	}
+	if completed["chunks_per_minute"] != "10.0" {
+		t.Errorf("Expected average 10.0 chunks/min for completed upload, got %v", completed["chunks_per_minute"])
+	}
+	if _, ok := completed["estimated_completion"]; ok {
+		t.Error("Expected no estimated completion for completed upload")
+	}
+
+	running := map[string]interface{}{}
+	addThroughputDetails(running, u, now, false)
+	if running["chunks_per_minute"] != "7.5" {
+		t.Errorf("Expected recent 7.5 chunks/min for running upload, got %v", running["chunks_per_minute"])
+	}
+	if running["estimated_completion"] != "2024-12-09T12:30:00Z" {
+		t.Errorf("Expected estimated completion, got %v", running["estimated_completion"])
+	}
+}
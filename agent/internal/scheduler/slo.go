@@ -0,0 +1,310 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// SLOStore is the database the uploads measured against SLOs are read from
+type SLOStore interface {
+	ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error)
+	GetRunningUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
+}
+
+// SLOStatus is how a node's uploads measure against its SLO
+type SLOStatus struct {
+	analytics.SLOCompliance
+	Objective analytics.SLO
+	Window    time.Duration
+	// ProjectedMiss is set when the running upload is projected to miss the objective:
+	// it has run past max_duration, is stalled, or its estimated completion is later
+	ProjectedMiss bool
+	// AtRisk is set when compliance is below the target, or the running upload's
+	// projected miss would put it there
+	AtRisk bool
+}
+
+// SLOObjective returns the objective of an SLO configuration
+func SLOObjective(slo *config.SLOConfig) analytics.SLO {
+	return analytics.SLO{
+		Target:       slo.Target,
+		MaxDuration:  slo.GetMaxDuration(),
+		MinBandwidth: slo.GetMinBandwidth(),
+	}
+}
+
+// EvaluateSLO measures a node's finished uploads, started within the SLO's window, and
+// its running upload, if any, against the SLO. Failed and cancelled uploads miss it. A
+// finished upload lasts until bv reported the job finished, else until it was detected.
+func EvaluateSLO(slo *config.SLOConfig, finished []database.Upload, running *database.Upload, now time.Time) SLOStatus {
+	status := SLOStatus{Objective: SLOObjective(slo), Window: slo.GetWindow()}
+
+	since := now.Add(-status.Window)
+	uploads := make([]analytics.SLOUpload, 0, len(finished))
+	for _, u := range finished {
+		end := u.FinishedAt
+		if end == nil {
+			end = u.CompletedAt
+		}
+		if end == nil || u.StartedAt.Before(since) {
+			continue
+		}
+		uploads = append(uploads, analytics.SLOUpload{
+			Succeeded: u.Status == "completed",
+			Duration:  end.Sub(u.StartedAt),
+			SizeBytes: u.SizeBytes,
+		})
+	}
+	status.SLOCompliance = status.Objective.Evaluate(uploads)
+
+	status.ProjectedMiss = projectedSLOMiss(status.Objective, running, now)
+	status.AtRisk = status.Breached() || (status.ProjectedMiss && status.Budget < 1)
+	return status
+}
+
+// projectedSLOMiss reports whether a running upload is projected to miss the objective's
+// max_duration. Its bandwidth is not projected, since its size is only known at the end.
+func projectedSLOMiss(objective analytics.SLO, running *database.Upload, now time.Time) bool {
+	if running == nil || objective.MaxDuration <= 0 {
+		return false
+	}
+	deadline := running.StartedAt.Add(objective.MaxDuration)
+	switch {
+	case now.After(deadline), running.StalledSince != nil:
+		return true
+	case running.EstimatedCompletion != nil:
+		return running.EstimatedCompletion.After(deadline)
+	}
+	return false
+}
+
+// NodeSLOStatus reads a node's uploads within the SLO's window and evaluates them, see
+// EvaluateSLO
+func NodeSLOStatus(ctx context.Context, store SLOStore, nodeName string, slo *config.SLOConfig, now time.Time) (SLOStatus, error) {
+	finished, err := store.ListUploads(ctx, database.UploadFilter{NodeName: nodeName, Since: now.Add(-slo.GetWindow())})
+	if err != nil {
+		return SLOStatus{}, fmt.Errorf("failed to list uploads: %w", err)
+	}
+	running, err := store.GetRunningUploadForNode(ctx, nodeName)
+	if err != nil {
+		return SLOStatus{}, fmt.Errorf("failed to get running upload: %w", err)
+	}
+	return EvaluateSLO(slo, finished, running, now), nil
+}
+
+// SLOJob alerts when a node's upload SLO is at risk: its compliance over the rolling
+// window has fallen below the target, or its running upload is projected to miss the
+// objective with no error budget left to absorb the miss
+type SLOJob struct {
+	db              Database
+	store           SLOStore
+	notifyRegistry  *notification.Registry
+	globalNotifyCfg *config.NotificationConfig
+	nodeConfigs     *nodeConfigSet
+	logger          *logrus.Logger
+	now             func() time.Time
+
+	// slos maps node names to their SLO; nodes without one are not checked. Changes
+	// replace the map, so Run can range over it without holding slosMu.
+	slosMu sync.RWMutex
+	slos   map[string]*config.SLOConfig
+
+	mu      sync.Mutex
+	alerted map[string]bool // Nodes alerted on since their SLO became at risk
+}
+
+// NewSLOJob creates a new upload SLO job
+func NewSLOJob(
+	db Database,
+	store SLOStore,
+	notifyRegistry *notification.Registry,
+	globalNotifyCfg *config.NotificationConfig,
+	nodeConfigs map[string]config.NodeConfig,
+	slos map[string]*config.SLOConfig,
+	logger *logrus.Logger,
+) *SLOJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &SLOJob{
+		db:              db,
+		store:           store,
+		notifyRegistry:  notifyRegistry,
+		globalNotifyCfg: globalNotifyCfg,
+		nodeConfigs:     newNodeConfigSet(nodeConfigs),
+		slos:            slos,
+		logger:          logger,
+		now:             time.Now,
+		alerted:         make(map[string]bool),
+	}
+}
+
+// SetNode starts checking a node registered, or updated, at runtime, if it has an SLO
+func (j *SLOJob) SetNode(cfg *config.Config, nodeName string) {
+	j.nodeConfigs.set(nodeName, cfg.ResolvedNode(nodeName))
+	j.setSLO(nodeName, cfg.GetNodeSLO(nodeName))
+}
+
+// RemoveNode stops checking a deregistered node
+func (j *SLOJob) RemoveNode(nodeName string) {
+	j.nodeConfigs.remove(nodeName)
+	j.setSLO(nodeName, nil)
+}
+
+// setSLO replaces a node's SLO; nil stops checking the node
+func (j *SLOJob) setSLO(nodeName string, slo *config.SLOConfig) {
+	j.slosMu.Lock()
+	defer j.slosMu.Unlock()
+
+	slos := make(map[string]*config.SLOConfig, len(j.slos)+1)
+	for name, existing := range j.slos {
+		if name != nodeName {
+			slos[name] = existing
+		}
+	}
+	if slo != nil {
+		slos[nodeName] = slo
+	}
+	j.slos = slos
+}
+
+// Run checks the SLO of every node that has one
+func (j *SLOJob) Run(ctx context.Context) error {
+	j.logger.WithFields(logrus.Fields{
+		"component": "scheduler",
+		"job":       "slo",
+	}).Debug("Starting upload SLO job")
+
+	var wg sync.WaitGroup
+	j.slosMu.RLock()
+	slos := j.slos
+	j.slosMu.RUnlock()
+
+	for nodeName, slo := range slos {
+		wg.Add(1)
+		go func(node string, slo *config.SLOConfig) {
+			defer wg.Done()
+
+			// Each node is checked independently to ensure node isolation
+			if err := j.checkNode(ctx, node, slo); err != nil {
+				j.logger.WithFields(logrus.Fields{
+					"component": "scheduler",
+					"node":      node,
+					"error":     err.Error(),
+				}).Warn("Failed to check upload SLO")
+			}
+		}(nodeName, slo)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// checkNode alerts once when a node's SLO becomes at risk; it alerts again only after
+// the SLO has recovered
+func (j *SLOJob) checkNode(ctx context.Context, nodeName string, slo *config.SLOConfig) error {
+	status, err := NodeSLOStatus(ctx, j.store, nodeName, slo, j.now())
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	alreadyAlerted := j.alerted[nodeName]
+	if status.AtRisk {
+		j.alerted[nodeName] = true
+	} else {
+		delete(j.alerted, nodeName)
+	}
+	j.mu.Unlock()
+	if !status.AtRisk || alreadyAlerted {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"target":         fmt.Sprintf("%g%%", slo.Target),
+		"compliance":     fmt.Sprintf("%.1f%%", status.Percent),
+		"uploads":        status.Uploads,
+		"met":            status.Met,
+		"window":         status.Window.String(),
+		"error_budget":   status.Budget,
+		"projected_miss": status.ProjectedMiss,
+	}
+	if slo.MaxDuration != "" {
+		details["max_duration"] = slo.MaxDuration
+	}
+	if slo.MinBandwidth != "" {
+		details["min_bandwidth"] = slo.MinBandwidth
+	}
+	message := fmt.Sprintf("Upload SLO compliance is %.1f%%, below its %g%% target", status.Percent, slo.Target)
+	if !status.Breached() {
+		message = "The running upload is projected to miss the upload SLO, which would put compliance below its target"
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component":      "scheduler",
+		"node":           nodeName,
+		"compliance":     status.Percent,
+		"target":         slo.Target,
+		"projected_miss": status.ProjectedMiss,
+	}).Warn(message)
+
+	j.sendNotification(ctx, nodeName, message, details)
+
+	return nil
+}
+
+// sendNotification sends an slo notification using the node's effective config
+func (j *SLOJob) sendNotification(ctx context.Context, nodeName string, message string, details map[string]interface{}) {
+	if j.notifyRegistry == nil {
+		return
+	}
+
+	nodeConfig, _ := j.nodeConfigs.get(nodeName)
+	notifyConfig := nodeConfig.Notifications
+	if notifyConfig == nil {
+		notifyConfig = j.globalNotifyCfg
+	}
+	if notifyConfig == nil || !notifyConfig.SLO {
+		return
+	}
+
+	payload := notification.NotificationPayload{
+		Event:     notification.EventSLO,
+		NodeName:  nodeName,
+		Timestamp: time.Now(),
+		Message:   message,
+		Details:   details,
+		Metadata:  nodeConfig.Metadata,
+	}
+
+	for notificationType, typeConfig := range notifyConfig.Types {
+		notificationModule, err := j.notifyRegistry.Get(notificationType)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+			}).Warn("Notification module not found")
+			continue
+		}
+
+		rendered := RenderNotification(notifyConfig, notificationType, payload, j.logger)
+		if err := DeliverNotification(ctx, j.db, j.logger, notificationModule, typeConfig.URL, rendered); err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"type":      notificationType,
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Error("Failed to send notification")
+		}
+	}
+}
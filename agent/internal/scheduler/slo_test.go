@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// mockSLOStore serves fixed finished and running uploads by node
+type mockSLOStore struct {
+	mu       sync.Mutex
+	finished map[string][]database.Upload
+	running  map[string]*database.Upload
+}
+
+func (m *mockSLOStore) ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finished[filter.NodeName], nil
+}
+
+func (m *mockSLOStore) GetRunningUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running[nodeName], nil
+}
+
+// sloUploads returns finished uploads of the given durations, a day apart before now
+func sloUploads(now time.Time, status string, durations ...time.Duration) []database.Upload {
+	uploads := make([]database.Upload, 0, len(durations))
+	for i, d := range durations {
+		startedAt := now.Add(-time.Duration(i+1) * 24 * time.Hour)
+		finishedAt := startedAt.Add(d)
+		uploads = append(uploads, database.Upload{NodeName: "eth", Status: status, StartedAt: startedAt, CompletedAt: &finishedAt, FinishedAt: &finishedAt})
+	}
+	return uploads
+}
+
+func TestEvaluateSLO(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	slo := &config.SLOConfig{Target: 75, MaxDuration: "8h", Window: "168h"}
+
+	// Three of four uploads met the objective, exactly the 75% target
+	finished := sloUploads(now, "completed", 6*time.Hour, 7*time.Hour, 9*time.Hour, 5*time.Hour)
+	// Started before the window, not counted
+	finished = append(finished, sloUploads(now.Add(-10*24*time.Hour), "failed", time.Hour)...)
+
+	status := EvaluateSLO(slo, finished, nil, now)
+	if status.Uploads != 4 || status.Met != 3 || status.Percent != 75 {
+		t.Fatalf("Expected 3 of 4 uploads met, got %d of %d (%.1f%%)", status.Met, status.Uploads, status.Percent)
+	}
+	if status.Budget != 0 || status.AtRisk {
+		t.Errorf("Expected no budget left and not at risk, got budget %d, at risk %v", status.Budget, status.AtRisk)
+	}
+
+	projected := now.Add(6 * time.Hour)
+	tests := []struct {
+		name    string
+		running *database.Upload
+		want    bool
+	}{
+		{name: "on track", running: &database.Upload{StartedAt: now.Add(-time.Hour), EstimatedCompletion: &now}, want: false},
+		{name: "projected late", running: &database.Upload{StartedAt: now.Add(-3 * time.Hour), EstimatedCompletion: &projected}, want: true},
+		{name: "past max_duration", running: &database.Upload{StartedAt: now.Add(-9 * time.Hour)}, want: true},
+		{name: "stalled", running: &database.Upload{StartedAt: now.Add(-time.Hour), StalledSince: &now}, want: true},
+		{name: "no estimate yet", running: &database.Upload{StartedAt: now.Add(-time.Hour)}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := EvaluateSLO(slo, finished, tt.running, now)
+			if status.ProjectedMiss != tt.want || status.AtRisk != tt.want {
+				t.Errorf("Expected projected miss and at risk %v, got %v and %v", tt.want, status.ProjectedMiss, status.AtRisk)
+			}
+		})
+	}
+
+	// With budget to spare, a projected miss is not a risk
+	slo.Target = 50
+	late := &database.Upload{StartedAt: now.Add(-9 * time.Hour)}
+	if status := EvaluateSLO(slo, finished, late, now); !status.ProjectedMiss || status.AtRisk {
+		t.Errorf("Expected a projected miss within budget, got projected %v, at risk %v", status.ProjectedMiss, status.AtRisk)
+	}
+}
+
+func TestSLOJob(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	store := &mockSLOStore{
+		finished: map[string][]database.Upload{
+			"eth": append(sloUploads(now, "completed", 6*time.Hour, 7*time.Hour), sloUploads(now, "failed", time.Hour)...),
+			"arb": sloUploads(now, "completed", 2*time.Hour, 3*time.Hour),
+		},
+		running: map[string]*database.Upload{},
+	}
+
+	var mu sync.Mutex
+	var sent []notification.NotificationPayload
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, payload)
+			return nil
+		},
+	})
+	notifyConfig := &config.NotificationConfig{
+		SLO:   true,
+		Types: map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+
+	slo := &config.SLOConfig{Target: 90, MaxDuration: "8h"}
+	nodes := map[string]config.NodeConfig{
+		"eth": {Protocol: "ethereum", SLO: slo},
+		"arb": {Protocol: "arbitrum", SLO: slo},
+	}
+	job := NewSLOJob(nil, store, notifyRegistry, notifyConfig, nodes, map[string]*config.SLOConfig{"eth": slo, "arb": slo}, logger)
+	job.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := job.Run(ctx); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	// eth's failed upload breached its SLO; it is alerted on once
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(sent))
+	}
+	if sent[0].Event != notification.EventSLO || sent[0].NodeName != "eth" {
+		t.Errorf("Expected slo notification for eth, got %v for %s", sent[0].Event, sent[0].NodeName)
+	}
+	if sent[0].Details["compliance"] != "66.7%" || sent[0].Details["target"] != "90%" {
+		t.Errorf("Unexpected details: %v", sent[0].Details)
+	}
+
+	// A running arb upload projected to miss, with no budget left, puts arb at risk
+	startedAt := now.Add(-9 * time.Hour)
+	store.mu.Lock()
+	store.running["arb"] = &database.Upload{NodeName: "arb", StartedAt: startedAt}
+	store.mu.Unlock()
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sent) != 2 || sent[1].NodeName != "arb" || sent[1].Details["projected_miss"] != true {
+		t.Fatalf("Expected a projected miss notification for arb, got %v", sent)
+	}
+
+	// Once eth recovers, a new breach is alerted on again
+	store.mu.Lock()
+	store.finished["eth"] = sloUploads(now, "completed", time.Hour)
+	store.mu.Unlock()
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	store.mu.Lock()
+	store.finished["eth"] = sloUploads(now, "failed", time.Hour)
+	store.mu.Unlock()
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sent) != 3 || sent[2].NodeName != "eth" {
+		t.Errorf("Expected eth to be alerted on again after recovering, got %d notifications", len(sent))
+	}
+
+	// A deregistered node is no longer checked
+	job.RemoveNode("eth")
+	if _, checked := job.slos["eth"]; checked {
+		t.Error("Expected eth to stop being checked")
+	}
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+// splaySchedule delays every run of a cron schedule by a fixed offset
+type splaySchedule struct {
+	schedule cron.Schedule
+	offset   time.Duration
+}
+
+// Next returns the first delayed run after t
+func (s splaySchedule) Next(t time.Time) time.Time {
+	next := s.schedule.Next(t.Add(-s.offset))
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(s.offset)
+}
+
+// SplayOffset returns how long a node's scheduled runs are delayed with the given splay:
+// a whole number of seconds below splay derived from the node name, so each node keeps the
+// same offset across restarts and daemons while nodes sharing a schedule start staggered
+func SplayOffset(nodeName string, splay time.Duration) time.Duration {
+	seconds := uint64(splay / time.Second)
+	if seconds == 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(nodeName))
+	return time.Duration(h.Sum64()%seconds) * time.Second
+}
+
+// ParseSchedule parses a schedule, in any form config.NormalizeSchedule accepts, whose
+// runs are delayed by offset
+func ParseSchedule(schedule string, offset time.Duration) (cron.Schedule, error) {
+	parsed, err := config.ParseSchedule(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schedule: %w", err)
+	}
+	if offset <= 0 {
+		return parsed, nil
+	}
+	return splaySchedule{schedule: parsed, offset: offset}, nil
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplayOffset(t *testing.T) {
+	splay := 15 * time.Minute
+
+	offsets := make(map[time.Duration]bool)
+	for _, nodeName := range []string{"eth-1", "eth-2", "eth-3", "arb-1", "base-1"} {
+		offset := SplayOffset(nodeName, splay)
+		if offset < 0 || offset >= splay || offset%time.Second != 0 {
+			t.Errorf("SplayOffset(%s) = %v, want whole seconds below %v", nodeName, offset, splay)
+		}
+		if again := SplayOffset(nodeName, splay); again != offset {
+			t.Errorf("SplayOffset(%s) changed from %v to %v", nodeName, offset, again)
+		}
+		offsets[offset] = true
+	}
+	if len(offsets) < 2 {
+		t.Errorf("expected nodes to get different offsets, got %v", offsets)
+	}
+
+	if offset := SplayOffset("eth-1", 0); offset != 0 {
+		t.Errorf("expected no offset without a splay, got %v", offset)
+	}
+	if offset := SplayOffset("eth-1", 500*time.Millisecond); offset != 0 {
+		t.Errorf("expected no offset for a splay under a second, got %v", offset)
+	}
+}
+
+func TestParseSchedule_DelaysRunsByOffset(t *testing.T) {
+	parsed, err := ParseSchedule("0 0 */6 * * *", 10*time.Minute)
+	if err != nil {
+		t.Fatalf("ParseSchedule() error = %v", err)
+	}
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		// Before the delayed run of the current slot
+		{now: time.Date(2024, 12, 9, 6, 5, 0, 0, time.UTC), want: time.Date(2024, 12, 9, 6, 10, 0, 0, time.UTC)},
+		// Right at a delayed run, the next slot is returned
+		{now: time.Date(2024, 12, 9, 6, 10, 0, 0, time.UTC), want: time.Date(2024, 12, 9, 12, 10, 0, 0, time.UTC)},
+		// Across midnight
+		{now: time.Date(2024, 12, 9, 23, 0, 0, 0, time.UTC), want: time.Date(2024, 12, 10, 0, 10, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := parsed.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("Next(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+
+	if _, err := ParseSchedule("invalid", time.Minute); err == nil {
+		t.Error("expected an invalid schedule to fail")
+	}
+
+	// Friendly schedules are normalized to cron before they are delayed
+	friendly, err := ParseSchedule("every 6h", 10*time.Minute)
+	if err != nil {
+		t.Fatalf("ParseSchedule() error = %v", err)
+	}
+	if got := friendly.Next(tests[0].now); !got.Equal(tests[0].want) {
+		t.Errorf("Next(%v) = %v, want %v", tests[0].now, got, tests[0].want)
+	}
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// Keys of the notifications the monitor remembers per upload in the upload_notifications
+// table. Each is sent once per upload, even across daemon restarts.
+const (
+	completionNotification = "completion"  // complete or failure, once the upload finished
+	timeoutNotification    = "timeout"     // failure after exceeding max_duration
+	monitorLagNotification = "monitor_lag" // completion detected late
+)
+
+// stalledNotification is the key of a stall at a chunk count. An upload that resumes and
+// stalls again further on is notified again.
+func stalledNotification(chunksCompleted int) string {
+	return fmt.Sprintf("stalled:%d", chunksCompleted)
+}
+
+// sendUploadNotification sends a notification about an upload unless it was already sent
+func (j *UploadMonitorJob) sendUploadNotification(ctx context.Context, u database.Upload, key string, event notification.NotificationEvent, message string, details map[string]interface{}) {
+	if !j.recordNotification(ctx, u, key, event, message, details) {
+		return
+	}
+	j.sendNotification(ctx, u.NodeName, event, message, details)
+	j.markNotificationSent(ctx, u, key)
+}
+
+// recordNotification records a notification about an upload as pending before it is sent,
+// reporting whether it should be sent: false when the upload already has it. A
+// notification that cannot be recorded is sent anyway, since a repeat is better than a
+// lost notification.
+func (j *UploadMonitorJob) recordNotification(ctx context.Context, u database.Upload, key string, event notification.NotificationEvent, message string, details map[string]interface{}) bool {
+	recorded, err := j.db.RecordUploadNotification(ctx, database.UploadNotification{
+		UploadID:  u.ID,
+		Key:       key,
+		NodeName:  u.NodeName,
+		Event:     string(event),
+		Message:   message,
+		Details:   database.JSONB(details),
+		CreatedAt: j.now(),
+	})
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component":    "scheduler",
+			"node":         u.NodeName,
+			"upload_id":    u.ID,
+			"notification": key,
+			"error":        err.Error(),
+		}).Warn("Failed to record upload notification, sending it anyway")
+		return true
+	}
+	if !recorded {
+		j.logger.WithFields(logrus.Fields{
+			"component":    "scheduler",
+			"node":         u.NodeName,
+			"upload_id":    u.ID,
+			"notification": key,
+		}).Debug("Upload notification already sent")
+	}
+	return recorded
+}
+
+// markNotificationSent records that an upload's pending notification was sent
+func (j *UploadMonitorJob) markNotificationSent(ctx context.Context, u database.Upload, key string) {
+	if err := j.db.MarkUploadNotificationSent(ctx, u.ID, key, j.now()); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component":    "scheduler",
+			"node":         u.NodeName,
+			"upload_id":    u.ID,
+			"notification": key,
+			"error":        err.Error(),
+		}).Warn("Failed to mark upload notification sent")
+	}
+}
+
+// resendPendingNotifications sends the notifications recorded by a previous daemon that
+// stopped before sending them, with the details recorded at the time
+func (j *UploadMonitorJob) resendPendingNotifications(ctx context.Context) {
+	pending, err := j.db.GetPendingUploadNotifications(ctx, j.startedAt)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"error":     err.Error(),
+		}).Warn("Failed to get pending upload notifications")
+		return
+	}
+
+	for _, n := range pending {
+		details := map[string]interface{}(n.Details)
+		if details == nil {
+			details = make(map[string]interface{})
+		}
+		// JSON numbers come back as floats; the notification history links by upload ID
+		details["upload_id"] = n.UploadID
+
+		j.logger.WithFields(logrus.Fields{
+			"component":    "scheduler",
+			"node":         n.NodeName,
+			"upload_id":    n.UploadID,
+			"notification": n.Key,
+		}).Info("Sending upload notification interrupted by a restart")
+
+		u := database.Upload{ID: n.UploadID, NodeName: n.NodeName}
+		j.sendNotification(ctx, n.NodeName, notification.NotificationEvent(n.Event), n.Message, details)
+		j.markNotificationSent(ctx, u, n.Key)
+		recordNodeAction(ctx, j.db, j.logger, database.NodeAction{
+			NodeName: n.NodeName,
+			UploadID: &u.ID,
+			Action:   database.NodeActionNotificationResent,
+			Message:  fmt.Sprintf("Sent the %s notification interrupted by a restart", n.Key),
+			Details:  database.JSONB{"event": n.Event, "recorded_at": n.CreatedAt.UTC().Format(time.RFC3339)},
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// notificationMemory is an in-memory upload_notifications table
+type notificationMemory struct {
+	mu   sync.Mutex
+	rows map[string]*database.UploadNotification
+}
+
+func newNotificationMemory() *notificationMemory {
+	return &notificationMemory{rows: make(map[string]*database.UploadNotification)}
+}
+
+func (m *notificationMemory) wire(db *mockDatabase) {
+	db.recordUploadNotificationFunc = func(ctx context.Context, n database.UploadNotification) (bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		id := fmt.Sprintf("%d/%s", n.UploadID, n.Key)
+		if _, exists := m.rows[id]; exists {
+			return false, nil
+		}
+		m.rows[id] = &n
+		return true, nil
+	}
+	db.markUploadNotificationSentFunc = func(ctx context.Context, uploadID int64, key string, sentAt time.Time) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if n, exists := m.rows[fmt.Sprintf("%d/%s", uploadID, key)]; exists {
+			n.SentAt = &sentAt
+		}
+		return nil
+	}
+	db.getPendingUploadNotificationsFunc = func(ctx context.Context, before time.Time) ([]database.UploadNotification, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		var pending []database.UploadNotification
+		for _, n := range m.rows {
+			if n.SentAt == nil && n.CreatedAt.Before(before) {
+				pending = append(pending, *n)
+			}
+		}
+		return pending, nil
+	}
+}
+
+// newNotifyingMonitorJob creates a monitor job for test-node sending every event to sent
+func newNotifyingMonitorJob(manager UploadManager, db *mockDatabase, sent *[]notification.NotificationPayload) *UploadMonitorJob {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	notifyRegistry := notification.NewRegistry()
+	notifyRegistry.Register(&mockNotificationModule{
+		name: "discord",
+		sendFunc: func(ctx context.Context, url string, payload notification.NotificationPayload) error {
+			mu.Lock()
+			*sent = append(*sent, payload)
+			mu.Unlock()
+			return nil
+		},
+	})
+	notifyConfig := &config.NotificationConfig{
+		Failure:  true,
+		Complete: true,
+		Stalled:  true,
+		Types:    map[string]config.NotificationTypeConfig{"discord": {URL: "https://example.com/webhook"}},
+	}
+	nodes := map[string]config.NodeConfig{"test-node": {Protocol: "ethereum"}}
+
+	return NewUploadMonitorJob(manager, db, protocol.NewRegistry(), notifyRegistry, notifyConfig, nodes, 2, logger)
+}
+
+func TestUploadMonitorJob_CompletionNotifiedOnce(t *testing.T) {
+	memory := newNotificationMemory()
+	db := &mockDatabase{}
+	memory.wire(db)
+
+	var sent []notification.NotificationPayload
+	manager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeSuccess}, nil
+		},
+	}
+	u := database.Upload{ID: 7, NodeName: "test-node", Status: "running", StartedAt: time.Now().Add(-time.Hour)}
+
+	// The same completion seen by the daemon before and after a restart is notified once
+	for i := 0; i < 2; i++ {
+		job := newNotifyingMonitorJob(manager, db, &sent)
+		job.notifyCompletion(context.Background(), u, upload.CompletionResult{Outcome: upload.OutcomeSuccess})
+	}
+
+	if len(sent) != 1 || sent[0].Event != notification.EventComplete {
+		t.Fatalf("expected one complete notification, got %+v", sent)
+	}
+	if n := memory.rows["7/completion"]; n == nil || n.SentAt == nil {
+		t.Errorf("expected the completion to be marked sent, got %+v", n)
+	}
+}
+
+func TestUploadMonitorJob_ResendsPendingNotifications(t *testing.T) {
+	memory := newNotificationMemory()
+	db := &mockDatabase{}
+	memory.wire(db)
+
+	// A previous daemon recorded the failure and stopped before sending it
+	memory.rows["9/completion"] = &database.UploadNotification{
+		UploadID:  9,
+		Key:       completionNotification,
+		NodeName:  "test-node",
+		Event:     string(notification.EventFailure),
+		Message:   "Upload failed",
+		Details:   database.JSONB{"upload_id": float64(9), "status": "Finished with exit code 1"},
+		CreatedAt: time.Now().Add(-time.Minute),
+	}
+
+	var sent []notification.NotificationPayload
+	job := newNotifyingMonitorJob(&mockUploadManager{}, db, &sent)
+	for i := 0; i < 2; i++ {
+		if err := job.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	if len(sent) != 1 || sent[0].Event != notification.EventFailure || sent[0].Message != "Upload failed" {
+		t.Fatalf("expected the pending failure to be sent once, got %+v", sent)
+	}
+	if sent[0].Details["upload_id"] != int64(9) || sent[0].Details["status"] != "Finished with exit code 1" {
+		t.Errorf("expected the recorded details, got %+v", sent[0].Details)
+	}
+	if memory.rows["9/completion"].SentAt == nil {
+		t.Error("expected the notification to be marked sent")
+	}
+}
+
+func TestUploadMonitorJob_StallNotifiedOncePerChunkCount(t *testing.T) {
+	memory := newNotificationMemory()
+	chunks := 3100
+	db := &mockDatabase{
+		getRunningUploadsFunc: func(ctx context.Context) ([]database.Upload, error) {
+			completed := chunks
+			return []database.Upload{{ID: 5, NodeName: "test-node", Status: "running", StartedAt: time.Now().Add(-time.Hour), ChunksCompleted: &completed}}, nil
+		},
+	}
+	memory.wire(db)
+
+	var sent []notification.NotificationPayload
+	manager := &mockUploadManager{
+		monitorUploadFunc: func(ctx context.Context, uploadID int64, nodeName string) (upload.CompletionResult, error) {
+			return upload.CompletionResult{Outcome: upload.OutcomeRunning}, nil
+		},
+	}
+
+	// Stalled, then restarted while still stalled: the stall is notified once
+	for _, runs := range []int{3, 3} {
+		job := newNotifyingMonitorJob(manager, db, &sent)
+		for i := 0; i < runs; i++ {
+			job.Run(context.Background())
+		}
+	}
+	if len(sent) != 1 || sent[0].Event != notification.EventStalled {
+		t.Fatalf("expected one stalled notification, got %+v", sent)
+	}
+
+	// Resuming and stalling further on is a new stall
+	chunks = 3200
+	job := newNotifyingMonitorJob(manager, db, &sent)
+	for i := 0; i < 3; i++ {
+		job.Run(context.Background())
+	}
+	if len(sent) != 2 {
+		t.Errorf("expected a second stalled notification, got %+v", sent)
+	}
+}
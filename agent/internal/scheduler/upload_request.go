@@ -0,0 +1,382 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+// UploadRequestStore is the database access needed to serve the upload queue
+type UploadRequestStore interface {
+	RecordDaemonHeartbeat(ctx context.Context, host string, pid int, at time.Time) error
+	ListUploadQueue(ctx context.Context) ([]database.UploadRequest, error)
+	GetRunningUploads(ctx context.Context) ([]database.Upload, error)
+	ClaimUploadRequests(ctx context.Context, nodeName string) ([]database.UploadRequest, error)
+	CompleteUploadRequest(ctx context.Context, requestID int64, status string, uploadID *int64, errorMessage *string) error
+	IncrementCoalescedTriggers(ctx context.Context, uploadID int64) (int, error)
+	NodeActionRecorder
+}
+
+// UploadRequestJob records the daemon's heartbeat and drains the upload queue. The queue
+// holds the uploads operators have requested with 'snapperd upload' and, when a
+// concurrency limit is set, scheduled runs. The CLI queues a request instead of running
+// bv itself while the heartbeat is fresh, so manual and scheduled uploads for a node go
+// through the same serialized workflow. Bursts of requests for a node are coalesced into
+// the upload the first one started, see SetTriggerDebounce.
+type UploadRequestJob struct {
+	store  UploadRequestStore
+	jobs   map[string]*NodeUploadJob
+	host   string
+	pid    int
+	logger *logrus.Logger
+	now    func() time.Time
+
+	// maxConcurrent limits the uploads running at once (0 = unlimited)
+	maxConcurrent int
+
+	// leader, when set, gates draining the queue on leadership
+	leader Leader
+
+	// debounce is how long after a request starts an upload later requests for the node
+	// are coalesced into it (0 = never)
+	debounce time.Duration
+
+	// mu guards jobs, which change as nodes are registered, inFlight, which holds the
+	// nodes whose requests are being processed, and recent, which holds the last upload
+	// a request started for each node
+	mu       sync.Mutex
+	inFlight map[string]bool
+	recent   map[string]requestedUpload
+}
+
+// requestedUpload is an upload started for an upload request
+type requestedUpload struct {
+	uploadID  int64
+	startedAt time.Time
+}
+
+// NewUploadRequestJob creates a job serving upload requests for the given node jobs
+func NewUploadRequestJob(store UploadRequestStore, jobs map[string]*NodeUploadJob, host string, pid int, logger *logrus.Logger) *UploadRequestJob {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &UploadRequestJob{
+		store:  store,
+		jobs:   jobs,
+		host:   host,
+		pid:    pid,
+		logger: logger,
+		now:    time.Now,
+
+		inFlight: make(map[string]bool),
+		recent:   make(map[string]requestedUpload),
+	}
+}
+
+// SetNodeJob serves upload requests for a node added, or replaced, while the daemon runs
+func (j *UploadRequestJob) SetNodeJob(nodeName string, job *NodeUploadJob) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jobs := make(map[string]*NodeUploadJob, len(j.jobs)+1)
+	for name, existing := range j.jobs {
+		jobs[name] = existing
+	}
+	jobs[nodeName] = job
+	j.jobs = jobs
+}
+
+// RemoveNodeJob stops serving upload requests for a node. Its pending requests stay
+// queued until the node is added again.
+func (j *UploadRequestJob) RemoveNodeJob(nodeName string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jobs := make(map[string]*NodeUploadJob, len(j.jobs))
+	for name, existing := range j.jobs {
+		if name != nodeName {
+			jobs[name] = existing
+		}
+	}
+	j.jobs = jobs
+	delete(j.recent, nodeName)
+}
+
+// nodeJob returns the upload job serving a node's requests
+func (j *UploadRequestJob) nodeJob(nodeName string) *NodeUploadJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jobs[nodeName]
+}
+
+// SetMaxConcurrentUploads limits how many uploads may run at once. Queued requests wait
+// until a running upload finishes; 0 removes the limit.
+func (j *UploadRequestJob) SetMaxConcurrentUploads(n int) {
+	j.maxConcurrent = n
+}
+
+// SetTriggerDebounce coalesces the upload requests for a node that arrive within d of a
+// request starting an upload into that upload, instead of starting another or skipping
+// them. Coalesced requests reply with the upload's ID, and the upload counts them. Queued
+// scheduled runs are never coalesced; 0 disables coalescing.
+func (j *UploadRequestJob) SetTriggerDebounce(d time.Duration) {
+	j.debounce = d
+}
+
+// SetLeader drains the queue only while leader reports this daemon is the leader. The
+// heartbeat is still recorded on standbys, so CLI uploads on their hosts are queued for
+// the leader instead of run locally.
+func (j *UploadRequestJob) SetLeader(leader Leader) {
+	j.leader = leader
+}
+
+// Run records the heartbeat and processes pending upload requests in queue order. Nodes
+// are processed concurrently; requests for the same node are processed one at a time.
+func (j *UploadRequestJob) Run(ctx context.Context) error {
+	if err := j.store.RecordDaemonHeartbeat(ctx, j.host, j.pid, j.now()); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       "upload_requests",
+			"error":     err.Error(),
+		}).Warn("Failed to record daemon heartbeat")
+	}
+
+	if j.leader != nil && !j.leader.IsLeader() {
+		return nil
+	}
+
+	nodeNames, err := j.dequeue(ctx)
+	if err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component": "scheduler",
+			"job":       "upload_requests",
+			"error":     err.Error(),
+		}).Error("Failed to read upload queue")
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, nodeName := range nodeNames {
+		requests, err := j.store.ClaimUploadRequests(ctx, nodeName)
+		if err != nil {
+			j.logger.WithFields(logrus.Fields{
+				"component": "scheduler",
+				"job":       "upload_requests",
+				"node":      nodeName,
+				"error":     err.Error(),
+			}).Error("Failed to claim upload requests")
+			j.release(nodeName)
+			continue
+		}
+
+		wg.Add(1)
+		go func(nodeName string, requests []database.UploadRequest) {
+			defer wg.Done()
+			defer j.release(nodeName)
+			job := j.nodeJob(nodeName)
+			for _, request := range requests {
+				j.process(ctx, job, request)
+			}
+		}(nodeName, requests)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// dequeue selects, in queue order, the nodes whose pending requests are processed in
+// this run and marks them in flight. Nodes already in flight stay queued. A node with a
+// running upload is always selected, since its requests are skipped without starting
+// another upload; other nodes are selected while the concurrency limit allows.
+func (j *UploadRequestJob) dequeue(ctx context.Context) ([]string, error) {
+	queue, err := j.store.ListUploadQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]bool)
+	if j.maxConcurrent > 0 {
+		uploads, err := j.store.GetRunningUploads(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range uploads {
+			running[u.NodeName] = true
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	busy := len(running)
+	for nodeName := range j.inFlight {
+		if !running[nodeName] {
+			busy++
+		}
+	}
+
+	var nodeNames []string
+	for _, request := range queue {
+		nodeName := request.NodeName
+		if request.Status != database.UploadRequestPending || j.inFlight[nodeName] {
+			continue
+		}
+		// A delayed request, such as a retry, waits for its backoff
+		if request.NotBefore != nil && request.NotBefore.After(j.now()) {
+			continue
+		}
+		if _, ok := j.jobs[nodeName]; !ok {
+			continue
+		}
+		if j.maxConcurrent > 0 && !running[nodeName] {
+			if busy >= j.maxConcurrent {
+				continue
+			}
+			busy++
+		}
+		j.inFlight[nodeName] = true
+		nodeNames = append(nodeNames, nodeName)
+	}
+
+	return nodeNames, nil
+}
+
+// release marks a node's requests as no longer in flight
+func (j *UploadRequestJob) release(nodeName string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.inFlight, nodeName)
+}
+
+// process runs the node's upload workflow for a claimed request and records the outcome.
+// Queued scheduled runs are started with the queue trigger and recorded in the node's
+// schedule state.
+func (j *UploadRequestJob) process(ctx context.Context, job *NodeUploadJob, request database.UploadRequest) {
+	scheduled := request.TriggerType == string(upload.TriggerScheduled)
+	retry := request.TriggerType == string(upload.TriggerRetry)
+	if !scheduled && !retry {
+		if uploadID, ok := j.recentUpload(request.NodeName); ok {
+			j.coalesce(ctx, request, uploadID)
+			return
+		}
+	}
+
+	metadata := map[string]interface{}{"request_id": request.ID}
+	for key, value := range request.TriggerMetadata {
+		metadata[key] = value
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"component":    "scheduler",
+		"job":          "upload_requests",
+		"node":         request.NodeName,
+		"request_id":   request.ID,
+		"trigger_type": request.TriggerType,
+		"priority":     request.Priority,
+	}).Info("Processing upload request")
+
+	var result string
+	var uploadID int64
+	var err error
+	switch {
+	case scheduled:
+		metadata["queued_at"] = request.RequestedAt.Format(time.RFC3339)
+		result, uploadID, err = job.RunQueued(ctx, upload.Trigger{Type: upload.TriggerQueue, Metadata: metadata})
+	case retry:
+		result, uploadID, err = job.RunRetry(ctx, retryTrigger(metadata))
+	default:
+		result, uploadID, err = job.RunRequested(ctx, upload.Trigger{Type: upload.TriggerManual, Metadata: metadata})
+	}
+
+	status := database.UploadRequestFailed
+	var recordedUploadID *int64
+	var errorMessage *string
+	switch {
+	case err != nil:
+		message := err.Error()
+		errorMessage = &message
+	case result == scheduleResultInitiated:
+		status = database.UploadRequestInitiated
+		recordedUploadID = &uploadID
+		if !scheduled {
+			j.mu.Lock()
+			j.recent[request.NodeName] = requestedUpload{uploadID: uploadID, startedAt: j.now()}
+			j.mu.Unlock()
+		}
+	case result == scheduleResultSkipped:
+		status = database.UploadRequestSkipped
+		message := "upload already running"
+		errorMessage = &message
+	case result == scheduleResultPaused:
+		status = database.UploadRequestSkipped
+		message := "node is paused"
+		errorMessage = &message
+	case result == retryResultSuperseded:
+		status = database.UploadRequestSkipped
+		message := "an upload completed since the failure"
+		errorMessage = &message
+	}
+
+	if err := j.store.CompleteUploadRequest(ctx, request.ID, status, recordedUploadID, errorMessage); err != nil {
+		j.logger.WithFields(logrus.Fields{
+			"component":  "scheduler",
+			"job":        "upload_requests",
+			"node":       request.NodeName,
+			"request_id": request.ID,
+			"error":      err.Error(),
+		}).Error("Failed to record upload request outcome")
+	}
+}
+
+// recentUpload returns the upload a request started for the node within the debounce
+// window, if any
+func (j *UploadRequestJob) recentUpload(nodeName string) (int64, bool) {
+	if j.debounce <= 0 {
+		return 0, false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	recent, ok := j.recent[nodeName]
+	if !ok || j.now().Sub(recent.startedAt) >= j.debounce {
+		return 0, false
+	}
+	return recent.uploadID, true
+}
+
+// coalesce records a request as coalesced into an upload started moments earlier and
+// counts it on the upload
+func (j *UploadRequestJob) coalesce(ctx context.Context, request database.UploadRequest, uploadID int64) {
+	fields := logrus.Fields{
+		"component":  "scheduler",
+		"job":        "upload_requests",
+		"node":       request.NodeName,
+		"request_id": request.ID,
+		"upload_id":  uploadID,
+	}
+
+	count, err := j.store.IncrementCoalescedTriggers(ctx, uploadID)
+	if err != nil {
+		j.logger.WithFields(fields).WithField("error", err.Error()).Warn("Failed to count coalesced upload request")
+	} else {
+		fields["coalesced_triggers"] = count
+	}
+	j.logger.WithFields(fields).Info("Coalesced upload request into recently started upload")
+	recordNodeAction(ctx, j.store, j.logger, database.NodeAction{
+		NodeName: request.NodeName,
+		UploadID: &uploadID,
+		Action:   database.NodeActionRequestCoalesced,
+		Message:  fmt.Sprintf("Upload request %d joined upload %d, started moments earlier", request.ID, uploadID),
+		Details:  database.JSONB{"request_id": request.ID, "trigger_type": request.TriggerType},
+	})
+
+	if err := j.store.CompleteUploadRequest(ctx, request.ID, database.UploadRequestCoalesced, &uploadID, nil); err != nil {
+		j.logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to record upload request outcome")
+	}
+}
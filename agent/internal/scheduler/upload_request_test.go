@@ -0,0 +1,437 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/notification"
+	"github.com/nodexeus/agent/internal/protocol"
+	"github.com/nodexeus/agent/internal/upload"
+	"github.com/sirupsen/logrus"
+)
+
+type uploadRequestOutcome struct {
+	status       string
+	uploadID     *int64
+	errorMessage *string
+}
+
+type mockUploadRequestStore struct {
+	mu         sync.Mutex
+	heartbeats int
+	pending    map[string][]database.UploadRequest
+	running    []database.Upload
+	outcomes   map[int64]uploadRequestOutcome
+	coalesced  map[int64]int
+	actions    []database.NodeAction
+}
+
+func (m *mockUploadRequestStore) RecordNodeAction(ctx context.Context, action database.NodeAction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actions = append(m.actions, action)
+	return nil
+}
+
+func (m *mockUploadRequestStore) ListUploadQueue(ctx context.Context) ([]database.UploadRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var queue []database.UploadRequest
+	for _, requests := range m.pending {
+		for _, request := range requests {
+			request.Status = database.UploadRequestPending
+			queue = append(queue, request)
+		}
+	}
+	sort.Slice(queue, func(i, k int) bool {
+		if queue[i].Priority != queue[k].Priority {
+			return queue[i].Priority > queue[k].Priority
+		}
+		return queue[i].ID < queue[k].ID
+	})
+	return queue, nil
+}
+
+func (m *mockUploadRequestStore) GetRunningUploads(ctx context.Context) ([]database.Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running, nil
+}
+
+func (m *mockUploadRequestStore) RecordDaemonHeartbeat(ctx context.Context, host string, pid int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeats++
+	return nil
+}
+
+func (m *mockUploadRequestStore) ClaimUploadRequests(ctx context.Context, nodeName string) ([]database.UploadRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	requests := m.pending[nodeName]
+	delete(m.pending, nodeName)
+	return requests, nil
+}
+
+func (m *mockUploadRequestStore) CompleteUploadRequest(ctx context.Context, requestID int64, status string, uploadID *int64, errorMessage *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcomes[requestID] = uploadRequestOutcome{status: status, uploadID: uploadID, errorMessage: errorMessage}
+	return nil
+}
+
+func (m *mockUploadRequestStore) IncrementCoalescedTriggers(ctx context.Context, uploadID int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.coalesced == nil {
+		m.coalesced = make(map[int64]int)
+	}
+	m.coalesced[uploadID]++
+	return m.coalesced[uploadID], nil
+}
+
+func TestUploadRequestJob_ProcessesRequests(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	running := false
+	var triggers []upload.Trigger
+	uploadManager := &mockUploadManager{
+		shouldSkipFunc: func(ctx context.Context, nodeName string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return running, nil
+		},
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			running = true
+			triggers = append(triggers, trigger)
+			return 7, nil
+		},
+	}
+
+	saveCount := 0
+	db := &mockDatabase{
+		saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+			saveCount++
+			return nil
+		},
+	}
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+	nodeJob := NewNodeUploadJob(
+		"node-a",
+		config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *"},
+		protocolRegistry,
+		uploadManager,
+		db,
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+
+	// Two requests for the same node: the first starts an upload, the second finds it running
+	store := &mockUploadRequestStore{
+		pending: map[string][]database.UploadRequest{
+			"node-a": {
+				{ID: 1, NodeName: "node-a", TriggerMetadata: database.JSONB{"command": "upload", "user": "alice"}},
+				{ID: 2, NodeName: "node-a"},
+			},
+		},
+		outcomes: make(map[int64]uploadRequestOutcome),
+	}
+
+	job := NewUploadRequestJob(store, map[string]*NodeUploadJob{"node-a": nodeJob}, "host-a", 100, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if store.heartbeats != 1 {
+		t.Errorf("expected 1 heartbeat, got %d", store.heartbeats)
+	}
+
+	first := store.outcomes[1]
+	if first.status != database.UploadRequestInitiated || first.uploadID == nil || *first.uploadID != 7 {
+		t.Errorf("expected first request initiated with upload 7, got %+v", first)
+	}
+	second := store.outcomes[2]
+	if second.status != database.UploadRequestSkipped {
+		t.Errorf("expected second request skipped, got %+v", second)
+	}
+
+	if len(triggers) != 1 {
+		t.Fatalf("expected 1 upload initiated, got %d", len(triggers))
+	}
+	if triggers[0].Type != upload.TriggerManual || triggers[0].Metadata["user"] != "alice" || triggers[0].Metadata["request_id"] != int64(1) {
+		t.Errorf("expected manual trigger carrying the request metadata, got %+v", triggers[0])
+	}
+
+	// Requested runs leave the node's schedule state alone
+	if saveCount != 0 {
+		t.Errorf("expected no schedule state saved, got %d", saveCount)
+	}
+}
+
+func TestUploadRequestJob_CoalescesBursts(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	running := false
+	initiated := 0
+	uploadManager := &mockUploadManager{
+		shouldSkipFunc: func(ctx context.Context, nodeName string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return running, nil
+		},
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			running = true
+			initiated++
+			return 7, nil
+		},
+	}
+
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+	nodeJob := NewNodeUploadJob(
+		"node-a",
+		config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *"},
+		protocolRegistry,
+		uploadManager,
+		&mockDatabase{},
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+
+	// A burst of three manual requests and a queued scheduled run
+	store := &mockUploadRequestStore{
+		pending: map[string][]database.UploadRequest{
+			"node-a": {
+				{ID: 1, NodeName: "node-a", TriggerType: "manual"},
+				{ID: 2, NodeName: "node-a", TriggerType: "manual"},
+				{ID: 3, NodeName: "node-a", TriggerType: "manual"},
+				{ID: 4, NodeName: "node-a", TriggerType: string(upload.TriggerScheduled)},
+			},
+		},
+		outcomes: make(map[int64]uploadRequestOutcome),
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	job := NewUploadRequestJob(store, map[string]*NodeUploadJob{"node-a": nodeJob}, "host-a", 100, logger)
+	job.SetTriggerDebounce(time.Minute)
+	job.now = func() time.Time { return now }
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if initiated != 1 {
+		t.Fatalf("expected 1 upload initiated, got %d", initiated)
+	}
+	if first := store.outcomes[1]; first.status != database.UploadRequestInitiated {
+		t.Errorf("expected first request initiated, got %+v", first)
+	}
+	for _, id := range []int64{2, 3} {
+		outcome := store.outcomes[id]
+		if outcome.status != database.UploadRequestCoalesced || outcome.uploadID == nil || *outcome.uploadID != 7 {
+			t.Errorf("expected request %d coalesced into upload 7, got %+v", id, outcome)
+		}
+	}
+	if store.coalesced[7] != 2 {
+		t.Errorf("expected upload 7 to count 2 coalesced triggers, got %d", store.coalesced[7])
+	}
+	if len(store.actions) != 2 || store.actions[0].Action != database.NodeActionRequestCoalesced || store.actions[0].NodeName != "node-a" {
+		t.Errorf("expected each coalesced request in the node's action log, got %+v", store.actions)
+	}
+	// Scheduled runs are not coalesced
+	if scheduled := store.outcomes[4]; scheduled.status != database.UploadRequestSkipped {
+		t.Errorf("expected queued scheduled run skipped, got %+v", scheduled)
+	}
+
+	// Past the window, a request finds the upload running and is skipped
+	now = now.Add(time.Minute)
+	store.pending = map[string][]database.UploadRequest{
+		"node-a": {{ID: 5, NodeName: "node-a", TriggerType: "manual"}},
+	}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if late := store.outcomes[5]; late.status != database.UploadRequestSkipped {
+		t.Errorf("expected request after the window skipped, got %+v", late)
+	}
+	if store.coalesced[7] != 2 {
+		t.Errorf("expected no more coalesced triggers, got %d", store.coalesced[7])
+	}
+}
+
+func TestUploadRequestJob_PausedNode(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var triggers []upload.Trigger
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			triggers = append(triggers, trigger)
+			return 7, nil
+		},
+	}
+	var lastResult string
+	db := &mockDatabase{
+		saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+			lastResult = *state.LastResult
+			return nil
+		},
+		getNodePauseFunc: func(ctx context.Context, nodeName string) (*database.NodePause, error) {
+			return &database.NodePause{NodeName: nodeName, Actor: "alice"}, nil
+		},
+	}
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+	nodeJob := NewNodeUploadJob(
+		"node-a",
+		config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *"},
+		protocolRegistry,
+		uploadManager,
+		db,
+		notification.NewRegistry(),
+		nil,
+		logger,
+	)
+
+	// A scheduled run queued before the node was paused, and an operator request
+	store := &mockUploadRequestStore{
+		pending: map[string][]database.UploadRequest{
+			"node-a": {
+				{ID: 1, NodeName: "node-a", TriggerType: string(upload.TriggerScheduled)},
+				{ID: 2, NodeName: "node-a", TriggerType: string(upload.TriggerManual)},
+			},
+		},
+		outcomes: make(map[int64]uploadRequestOutcome),
+	}
+
+	job := NewUploadRequestJob(store, map[string]*NodeUploadJob{"node-a": nodeJob}, "host-a", 100, logger)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	queued := store.outcomes[1]
+	if queued.status != database.UploadRequestSkipped || queued.errorMessage == nil || *queued.errorMessage != "node is paused" {
+		t.Errorf("expected queued scheduled run skipped as paused, got %+v", queued)
+	}
+	if lastResult != scheduleResultPaused {
+		t.Errorf("expected last_result %s, got %q", scheduleResultPaused, lastResult)
+	}
+
+	// Operators can still request uploads of a paused node
+	requested := store.outcomes[2]
+	if requested.status != database.UploadRequestInitiated {
+		t.Errorf("expected operator request initiated, got %+v", requested)
+	}
+	if len(triggers) != 1 || triggers[0].Type != upload.TriggerManual {
+		t.Errorf("expected only the operator request's upload initiated, got %+v", triggers)
+	}
+}
+
+func TestUploadRequestJob_ConcurrencyLimit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	var mu sync.Mutex
+	var initiated []string
+	var triggers []upload.Trigger
+	uploadManager := &mockUploadManager{
+		initiateUploadWithProtocolDataFunc: func(ctx context.Context, nodeName string, trigger upload.Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			initiated = append(initiated, nodeName)
+			triggers = append(triggers, trigger)
+			return int64(len(initiated)), nil
+		},
+	}
+
+	var savedResults []string
+	db := &mockDatabase{
+		saveScheduleStateFunc: func(ctx context.Context, state database.ScheduleState) error {
+			mu.Lock()
+			defer mu.Unlock()
+			savedResults = append(savedResults, state.NodeName+":"+*state.LastResult)
+			return nil
+		},
+	}
+	protocolRegistry := protocol.NewRegistry()
+	protocolRegistry.Register(&mockProtocolModule{name: "ethereum"})
+
+	jobs := make(map[string]*NodeUploadJob)
+	for _, nodeName := range []string{"node-a", "node-b"} {
+		jobs[nodeName] = NewNodeUploadJob(
+			nodeName,
+			config.NodeConfig{Protocol: "ethereum", Schedule: "0 0 * * * *"},
+			protocolRegistry,
+			uploadManager,
+			db,
+			notification.NewRegistry(),
+			nil,
+			logger,
+		)
+	}
+
+	// node-b has the higher priority, so it takes the only slot
+	store := &mockUploadRequestStore{
+		pending: map[string][]database.UploadRequest{
+			"node-a": {{ID: 1, NodeName: "node-a", TriggerType: "scheduled", RequestedAt: time.Now()}},
+			"node-b": {{ID: 2, NodeName: "node-b", TriggerType: "scheduled", Priority: 5, RequestedAt: time.Now()}},
+		},
+		outcomes: make(map[int64]uploadRequestOutcome),
+	}
+	job := NewUploadRequestJob(store, jobs, "host-a", 100, logger)
+	job.SetMaxConcurrentUploads(1)
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(initiated) != 1 || initiated[0] != "node-b" {
+		t.Fatalf("expected only node-b initiated, got %v", initiated)
+	}
+	if triggers[0].Type != upload.TriggerQueue || triggers[0].Metadata["request_id"] != int64(2) {
+		t.Errorf("expected queue trigger for request 2, got %+v", triggers[0])
+	}
+	if len(savedResults) != 1 || savedResults[0] != "node-b:initiated" {
+		t.Errorf("expected node-b schedule state initiated, got %v", savedResults)
+	}
+	if _, queued := store.pending["node-a"]; !queued {
+		t.Fatal("expected node-a to stay queued")
+	}
+
+	// The limit holds while node-b's upload runs
+	store.running = []database.Upload{{ID: 1, NodeName: "node-b", Status: "running"}}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(initiated) != 1 {
+		t.Fatalf("expected node-a to wait for a free slot, got %v", initiated)
+	}
+
+	// Once it finishes, node-a is dequeued
+	store.running = nil
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(initiated) != 2 || initiated[1] != "node-a" {
+		t.Fatalf("expected node-a initiated after the slot freed, got %v", initiated)
+	}
+	if store.outcomes[1].status != database.UploadRequestInitiated {
+		t.Errorf("expected request 1 initiated, got %+v", store.outcomes[1])
+	}
+}
@@ -0,0 +1,213 @@
+package snooze
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// maxActorLength bounds the recorded actor to the column size
+const maxActorLength = 255
+
+// Store records snoozes
+type Store interface {
+	CreateNotificationSnooze(ctx context.Context, snooze database.NotificationSnooze) (int64, error)
+}
+
+// AuditRecorder records snoozes in the audit log
+type AuditRecorder interface {
+	RecordAudit(ctx context.Context, entry database.AuditEntry) error
+}
+
+// Handler serves snooze links. Opening a link shows a confirmation form asking who is
+// snoozing the node; only submitting it records the snooze. Chat clients fetch links to
+// build previews, so following a link must not change anything by itself.
+type Handler struct {
+	links  *Links
+	store  Store
+	audit  AuditRecorder
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewHandler creates a handler for snooze links
+func NewHandler(links *Links, store Store, logger *logrus.Logger) *Handler {
+	return &Handler{
+		links:  links,
+		store:  store,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// SetAudit records the snoozes taken through links in audit
+func (h *Handler) SetAudit(audit AuditRecorder) {
+	h.audit = audit
+}
+
+// pageData is rendered by the snooze page
+type pageData struct {
+	NodeName string
+	Duration string
+	Values   map[string]string // Link parameters passed on by the confirmation form
+	Until    string            // Set once the snooze is recorded
+	Actor    string
+	Error    string
+}
+
+var page = template.Must(template.New("snooze").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Snooze {{.NodeName}}</title></head>
+<body>
+{{if .Error}}<p>{{.Error}}</p>
+{{else if .Until}}<p>Notifications for <strong>{{.NodeName}}</strong> are snoozed until {{.Until}} by {{.Actor}}.</p>
+{{else}}<form method="post">
+<p>Snooze notifications for <strong>{{.NodeName}}</strong> for {{.Duration}}?</p>
+{{range $name, $value := .Values}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Your name <input name="actor" required maxlength="255"></label>
+<button type="submit">Snooze</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// ServeHTTP shows the confirmation form for GET requests and records the snooze for POST
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		h.render(w, http.StatusMethodNotAllowed, pageData{Error: "Method not allowed."})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, http.StatusBadRequest, pageData{Error: "Invalid request."})
+		return
+	}
+
+	request, err := h.links.Verify(r.Form)
+	if err != nil {
+		h.render(w, http.StatusForbidden, pageData{Error: "This snooze link is not valid: " + err.Error() + "."})
+		return
+	}
+	data := pageData{NodeName: request.NodeName, Duration: FormatDuration(request.Duration)}
+
+	if r.Method == http.MethodGet {
+		data.Values = map[string]string{
+			"node":    r.Form.Get("node"),
+			"for":     r.Form.Get("for"),
+			"expires": r.Form.Get("expires"),
+			"sig":     r.Form.Get("sig"),
+		}
+		h.render(w, http.StatusOK, data)
+		return
+	}
+
+	actor := strings.TrimSpace(r.PostForm.Get("actor"))
+	if actor == "" || len(actor) > maxActorLength {
+		h.render(w, http.StatusBadRequest, pageData{Error: "A name of up to 255 characters is required."})
+		return
+	}
+
+	until, err := h.snooze(r.Context(), request, actor)
+	if err != nil {
+		h.render(w, http.StatusInternalServerError, pageData{Error: "Failed to snooze notifications."})
+		return
+	}
+
+	data.Until = until.UTC().Format(time.RFC3339)
+	data.Actor = actor
+	h.render(w, http.StatusOK, data)
+}
+
+// snooze records the snooze and returns when it ends
+func (h *Handler) snooze(ctx context.Context, request Request, actor string) (time.Time, error) {
+	now := h.now()
+	until := now.Add(request.Duration)
+	if _, err := h.store.CreateNotificationSnooze(ctx, database.NotificationSnooze{
+		NodeName:     request.NodeName,
+		SnoozedUntil: until,
+		Actor:        actor,
+		CreatedAt:    now,
+	}); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "snooze",
+			"node":      request.NodeName,
+			"error":     err.Error(),
+		}).Error("Failed to record notification snooze")
+		return time.Time{}, err
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"component": "snooze",
+		"node":      request.NodeName,
+		"actor":     actor,
+		"until":     until.UTC().Format(time.RFC3339),
+	}).Info("Notifications snoozed")
+	h.recordAudit(ctx, request, actor, until)
+	return until, nil
+}
+
+// recordAudit records a snooze in the audit log. The snooze has already taken effect, so
+// a failure to record it is only logged.
+func (h *Handler) recordAudit(ctx context.Context, request Request, actor string, until time.Time) {
+	if h.audit == nil {
+		return
+	}
+
+	nodeName := request.NodeName
+	if err := h.audit.RecordAudit(ctx, database.AuditEntry{
+		Actor:     actor,
+		Source:    database.AuditSourceSnooze,
+		Action:    database.AuditNodeSnoozed,
+		NodeName:  &nodeName,
+		Message:   "notifications snoozed for " + FormatDuration(request.Duration),
+		Details:   database.JSONB{"until": until.UTC().Format(time.RFC3339)},
+		CreatedAt: h.now(),
+	}); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "snooze",
+			"node":      request.NodeName,
+			"error":     err.Error(),
+		}).Warn("Failed to record audit entry")
+	}
+}
+
+// render writes the snooze page
+func (h *Handler) render(w http.ResponseWriter, status int, data pageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := page.Execute(w, data); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "snooze",
+			"error":     err.Error(),
+		}).Warn("Failed to render snooze page")
+	}
+}
+
+// Serve serves the snooze endpoint on the listener until ctx is cancelled
+func Serve(ctx context.Context, listener net.Listener, handler *Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
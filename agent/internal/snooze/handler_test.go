@@ -0,0 +1,134 @@
+package snooze
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// mockStore records created snoozes and audit entries
+type mockStore struct {
+	snoozes []database.NotificationSnooze
+	audit   []database.AuditEntry
+	err     error
+}
+
+func (m *mockStore) CreateNotificationSnooze(ctx context.Context, snooze database.NotificationSnooze) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.snoozes = append(m.snoozes, snooze)
+	return int64(len(m.snoozes)), nil
+}
+
+func (m *mockStore) RecordAudit(ctx context.Context, entry database.AuditEntry) error {
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+func newTestHandler(now time.Time, store Store) (*Handler, *Links) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	links := newTestLinks(now)
+	handler := NewHandler(links, store, logger)
+	handler.now = func() time.Time { return now }
+	return handler, links
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{}
+	handler, links := newTestHandler(now, store)
+	handler.SetAudit(store)
+	link := links.URL("eth-mainnet", 4*time.Hour)
+	query := link[strings.Index(link, "?")+1:]
+
+	// Opening the link only shows the confirmation form
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post">`) || !strings.Contains(rec.Body.String(), "eth-mainnet") {
+		t.Fatalf("expected confirmation form, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(store.snoozes) != 0 {
+		t.Fatalf("expected no snooze before confirmation, got %+v", store.snoozes)
+	}
+
+	// Submitting the form records the snooze with the actor
+	form := query + "&actor=" + "alice"
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "snoozed until 2025-12-10T16:00:00Z by alice") {
+		t.Fatalf("expected snooze confirmation, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(store.snoozes) != 1 {
+		t.Fatalf("expected 1 snooze, got %+v", store.snoozes)
+	}
+	snooze := store.snoozes[0]
+	if snooze.NodeName != "eth-mainnet" || snooze.Actor != "alice" || !snooze.SnoozedUntil.Equal(now.Add(4*time.Hour)) || !snooze.CreatedAt.Equal(now) {
+		t.Errorf("unexpected snooze %+v", snooze)
+	}
+
+	// The snooze is recorded in the audit log with who took it
+	if len(store.audit) != 1 {
+		t.Fatalf("expected 1 audit entry, got %+v", store.audit)
+	}
+	entry := store.audit[0]
+	if entry.Actor != "alice" || entry.Source != database.AuditSourceSnooze || entry.Action != database.AuditNodeSnoozed ||
+		*entry.NodeName != "eth-mainnet" || entry.Details["until"] != "2025-12-10T16:00:00Z" {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
+
+func TestHandler_Rejected(t *testing.T) {
+	now := time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{}
+	handler, links := newTestHandler(now, store)
+	link := links.URL("eth-mainnet", time.Hour)
+	query := link[strings.Index(link, "?")+1:]
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{name: "tampered link", method: http.MethodPost, body: strings.Replace(query, "for=1h", "for=240h", 1) + "&actor=alice", wantStatus: http.StatusForbidden},
+		{name: "missing actor", method: http.MethodPost, body: query + "&actor=+", wantStatus: http.StatusBadRequest},
+		{name: "long actor", method: http.MethodPost, body: query + "&actor=" + strings.Repeat("a", 256), wantStatus: http.StatusBadRequest},
+		{name: "unsupported method", method: http.MethodDelete, body: query, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, Path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if len(store.snoozes) != 0 {
+		t.Errorf("expected no snoozes, got %+v", store.snoozes)
+	}
+
+	// A failure to record the snooze is reported
+	store.err = fmt.Errorf("database unavailable")
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(query+"&actor=alice"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
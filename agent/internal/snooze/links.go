@@ -0,0 +1,122 @@
+package snooze
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/notification"
+)
+
+// Path is the path of the snooze endpoint under the configured base URL
+const Path = "/snooze"
+
+// Links builds and verifies signed snooze links. A link names a node and a snooze
+// duration and expires after the configured link TTL; the signature proves the daemon
+// sent it, so the endpoint can be reached from chat without further authentication.
+type Links struct {
+	baseURL   string
+	secret    []byte
+	durations []time.Duration
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// Request is a verified snooze link
+type Request struct {
+	NodeName string
+	Duration time.Duration
+	Expires  time.Time // When the link stops being accepted
+}
+
+// NewLinks creates snooze links from the snooze configuration
+func NewLinks(cfg *config.SnoozeConfig) *Links {
+	return &Links{
+		baseURL:   strings.TrimRight(cfg.BaseURL, "/"),
+		secret:    []byte(cfg.Secret),
+		durations: cfg.GetDurations(),
+		ttl:       cfg.GetLinkTTL(),
+		now:       time.Now,
+	}
+}
+
+// Actions returns a snooze link for each configured duration for the notification's
+// node. It is a notification.ActionProvider.
+func (l *Links) Actions(payload notification.NotificationPayload) []notification.Action {
+	if payload.NodeName == "" {
+		return nil
+	}
+
+	actions := make([]notification.Action, 0, len(l.durations))
+	for _, duration := range l.durations {
+		actions = append(actions, notification.Action{
+			Label: "Snooze " + FormatDuration(duration),
+			URL:   l.URL(payload.NodeName, duration),
+		})
+	}
+	return actions
+}
+
+// URL returns a signed link that snoozes the node's notifications for the duration
+func (l *Links) URL(nodeName string, duration time.Duration) string {
+	values := url.Values{}
+	values.Set("node", nodeName)
+	values.Set("for", FormatDuration(duration))
+	values.Set("expires", strconv.FormatInt(l.now().Add(l.ttl).Unix(), 10))
+	values.Set("sig", l.sign(values.Get("node"), values.Get("for"), values.Get("expires")))
+	return l.baseURL + Path + "?" + values.Encode()
+}
+
+// Verify checks the parameters of a snooze link and returns the request it carries
+func (l *Links) Verify(values url.Values) (Request, error) {
+	nodeName, duration, expires := values.Get("node"), values.Get("for"), values.Get("expires")
+	if nodeName == "" || duration == "" || expires == "" {
+		return Request{}, fmt.Errorf("incomplete snooze link")
+	}
+
+	expected := l.sign(nodeName, duration, expires)
+	if !hmac.Equal([]byte(values.Get("sig")), []byte(expected)) {
+		return Request{}, fmt.Errorf("invalid snooze link signature")
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid snooze link expiry")
+	}
+	request := Request{NodeName: nodeName, Expires: time.Unix(expiresUnix, 0)}
+	if !l.now().Before(request.Expires) {
+		return Request{}, fmt.Errorf("snooze link expired at %s", request.Expires.UTC().Format(time.RFC3339))
+	}
+
+	request.Duration, err = time.ParseDuration(duration)
+	if err != nil || request.Duration <= 0 {
+		return Request{}, fmt.Errorf("invalid snooze duration")
+	}
+
+	return request, nil
+}
+
+// sign returns the signature of a link's parameters
+func (l *Links) sign(nodeName, duration, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(nodeName + "\n" + duration + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FormatDuration renders a duration without zero trailing units, e.g. 4h rather than 4h0m0s
+func FormatDuration(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
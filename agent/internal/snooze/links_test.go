@@ -0,0 +1,121 @@
+package snooze
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/notification"
+)
+
+func newTestLinks(now time.Time) *Links {
+	links := NewLinks(&config.SnoozeConfig{
+		Listen:  ":8095",
+		BaseURL: "https://snapper.example.com/",
+		Secret:  "0123456789abcdef",
+	})
+	links.now = func() time.Time { return now }
+	return links
+}
+
+// linkValues returns the query parameters of a snooze link
+func linkValues(t *testing.T, link string) url.Values {
+	t.Helper()
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("invalid link %q: %v", link, err)
+	}
+	if parsed.Path != Path {
+		t.Fatalf("expected link to %s, got %s", Path, parsed.Path)
+	}
+	return parsed.Query()
+}
+
+func TestLinks_Actions(t *testing.T) {
+	links := newTestLinks(time.Now())
+
+	actions := links.Actions(notification.NotificationPayload{NodeName: "eth-mainnet"})
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 default durations, got %v", actions)
+	}
+	labels := []string{actions[0].Label, actions[1].Label, actions[2].Label}
+	if strings.Join(labels, ",") != "Snooze 1h,Snooze 4h,Snooze 24h" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if !strings.HasPrefix(actions[0].URL, "https://snapper.example.com/snooze?") {
+		t.Errorf("unexpected link %s", actions[0].URL)
+	}
+
+	if actions := links.Actions(notification.NotificationPayload{}); actions != nil {
+		t.Errorf("expected no actions without a node, got %v", actions)
+	}
+}
+
+func TestLinks_Verify(t *testing.T) {
+	now := time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC)
+	links := newTestLinks(now)
+	link := links.URL("eth-mainnet", 4*time.Hour)
+
+	request, err := links.Verify(linkValues(t, link))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if request.NodeName != "eth-mainnet" || request.Duration != 4*time.Hour || !request.Expires.Equal(now.Add(config.DefaultSnoozeLinkTTL)) {
+		t.Errorf("unexpected request %+v", request)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(values url.Values)
+	}{
+		{name: "other node", modify: func(values url.Values) { values.Set("node", "arb-mainnet") }},
+		{name: "longer duration", modify: func(values url.Values) { values.Set("for", "240h") }},
+		{name: "extended expiry", modify: func(values url.Values) { values.Set("expires", "99999999999") }},
+		{name: "missing signature", modify: func(values url.Values) { values.Del("sig") }},
+		{name: "missing node", modify: func(values url.Values) { values.Del("node") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := linkValues(t, link)
+			tt.modify(values)
+			if _, err := links.Verify(values); err == nil {
+				t.Error("expected tampered link to be rejected")
+			}
+		})
+	}
+
+	// Links stop working after the link TTL
+	links.now = func() time.Time { return now.Add(config.DefaultSnoozeLinkTTL) }
+	if _, err := links.Verify(linkValues(t, link)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected expired link error, got %v", err)
+	}
+
+	// Links signed with another secret are rejected
+	other := NewLinks(&config.SnoozeConfig{BaseURL: "https://snapper.example.com", Secret: "fedcba9876543210"})
+	other.now = func() time.Time { return now }
+	if _, err := other.Verify(linkValues(t, link)); err == nil {
+		t.Error("expected link signed with another secret to be rejected")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Hour:                    "1h",
+		24 * time.Hour:               "24h",
+		30 * time.Minute:             "30m",
+		90 * time.Minute:             "1h30m",
+		45 * time.Second:             "45s",
+		time.Hour + 30*time.Second:   "1h0m30s",
+		2*time.Hour + 15*time.Minute: "2h15m",
+	}
+	for duration, want := range tests {
+		if got := FormatDuration(duration); got != want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", duration, got, want)
+		}
+		if parsed, err := time.ParseDuration(FormatDuration(duration)); err != nil || parsed != duration {
+			t.Errorf("FormatDuration(%v) does not parse back: %v, %v", duration, parsed, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+)
+
+// Catalog is the freshest completed snapshot of each protocol, network and node type
+type Catalog struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Snapshots   []CatalogSnapshot `json:"snapshots"`
+}
+
+// CatalogSnapshot is a catalog entry with its age
+type CatalogSnapshot struct {
+	database.CatalogEntry
+	AgeSeconds float64 `json:"age_seconds"` // Time since the snapshot completed
+}
+
+// Catalog reads the catalog entries matching filter from the store
+func (b *Builder) Catalog(ctx context.Context, filter database.CatalogFilter) (*Catalog, error) {
+	now := b.now()
+	entries, err := b.store.ListCatalog(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalog: %w", err)
+	}
+
+	catalog := &Catalog{GeneratedAt: now.UTC(), Snapshots: make([]CatalogSnapshot, 0, len(entries))}
+	for _, entry := range entries {
+		catalog.Snapshots = append(catalog.Snapshots, CatalogSnapshot{CatalogEntry: entry, AgeSeconds: now.Sub(entry.CompletedAt).Seconds()})
+	}
+	return catalog, nil
+}
@@ -0,0 +1,251 @@
+package summary
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Path is the URL path of the summary endpoint
+	Path = "/api/v1/summary"
+
+	// StatsPath prefixes the URL path of a node's stats, /api/v1/stats/{node}
+	StatsPath = "/api/v1/stats/"
+
+	// DefaultStatsLast is how many finished uploads a node's stats cover unless the
+	// request sets last
+	DefaultStatsLast = 20
+
+	// EventsPath is the URL path of the upload event stream
+	EventsPath = "/api/v1/events"
+
+	// CatalogPath is the URL path of the snapshot catalog
+	CatalogPath = "/api/v1/catalog"
+)
+
+// Handler serves the summary as JSON. When a token is configured, requests must carry
+// it as a bearer token. A tenant's token instead scopes the summary, stats, events and
+// catalog to the tenant's nodes.
+type Handler struct {
+	builder *Builder
+	events  *events.Hub // nil without an event stream
+	token   string
+	tenants map[string]string // Token -> tenant
+	logger  *logrus.Logger
+}
+
+// NewHandler creates a handler serving the builder's summaries; an empty token leaves
+// the endpoint open
+func NewHandler(builder *Builder, token string, logger *logrus.Logger) *Handler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &Handler{
+		builder: builder,
+		token:   token,
+		logger:  logger,
+	}
+}
+
+// SetEvents streams the hub's upload events at EventsPath
+func (h *Handler) SetEvents(hub *events.Hub) {
+	h.events = hub
+}
+
+// SetTenants accepts the tenants' tokens, mapped to their tenant, scoping each request
+// made with one to its tenant
+func (h *Handler) SetTenants(tokens map[string]string) {
+	h.tenants = tokens
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP writes the current summary, a node's stats under StatsPath, the upload event
+// stream at EventsPath, or the snapshot catalog at CatalogPath
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, ok := h.authorize(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="snapperd"`)
+		h.write(w, r, http.StatusUnauthorized, errorResponse{Error: "invalid or missing token"})
+		return
+	}
+	if nodeName, ok := strings.CutPrefix(r.URL.Path, StatsPath); ok {
+		h.serveStats(w, r, nodeName, tenant)
+		return
+	}
+	if r.URL.Path == EventsPath {
+		h.serveEvents(w, r, tenant)
+		return
+	}
+	if r.URL.Path == CatalogPath {
+		h.serveCatalog(w, r, tenant)
+		return
+	}
+
+	summary, err := h.builder.BuildTenant(r.Context(), tenant)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "summary",
+			"error":     err.Error(),
+		}).Error("Failed to build summary")
+		h.write(w, r, http.StatusInternalServerError, errorResponse{Error: "failed to build summary"})
+		return
+	}
+	h.write(w, r, http.StatusOK, summary)
+}
+
+// serveStats writes a node's stats over the last finished uploads the request sets. A
+// tenant's request only finds the tenant's configured nodes.
+func (h *Handler) serveStats(w http.ResponseWriter, r *http.Request, nodeName, tenant string) {
+	if nodeName == "" || strings.Contains(nodeName, "/") {
+		h.write(w, r, http.StatusNotFound, errorResponse{Error: "expected " + StatsPath + "{node}"})
+		return
+	}
+	if nodeTenant, configured := h.builder.NodeTenant(nodeName); tenant != "" && (!configured || nodeTenant != tenant) {
+		h.write(w, r, http.StatusNotFound, errorResponse{Error: ErrUnknownNode.Error() + ": " + nodeName})
+		return
+	}
+	last := DefaultStatsLast
+	if value := r.URL.Query().Get("last"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			h.write(w, r, http.StatusBadRequest, errorResponse{Error: "last must be a non-negative number of uploads"})
+			return
+		}
+		last = n
+	}
+
+	stats, err := h.builder.NodeStats(r.Context(), nodeName, last)
+	if errors.Is(err, ErrUnknownNode) {
+		h.write(w, r, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "summary",
+			"node":      nodeName,
+			"error":     err.Error(),
+		}).Error("Failed to build node stats")
+		h.write(w, r, http.StatusInternalServerError, errorResponse{Error: "failed to build node stats"})
+		return
+	}
+	h.write(w, r, http.StatusOK, stats)
+}
+
+// serveEvents streams upload events as server-sent events, of one node when the request
+// sets node and of the tenant's nodes for a tenant's request
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request, tenant string) {
+	if h.events == nil {
+		h.write(w, r, http.StatusNotFound, errorResponse{Error: "event stream not enabled"})
+		return
+	}
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	events.ServeStream(w, r, h.events, events.Filter{Node: r.URL.Query().Get("node"), Tenant: tenant})
+}
+
+// serveCatalog writes the catalog entries matching the request's protocol, network and
+// node_type, of the tenant's nodes for a tenant's request
+func (h *Handler) serveCatalog(w http.ResponseWriter, r *http.Request, tenant string) {
+	query := r.URL.Query()
+	catalog, err := h.builder.Catalog(r.Context(), database.CatalogFilter{
+		Protocol: query.Get("protocol"),
+		Network:  query.Get("network"),
+		NodeType: query.Get("node_type"),
+		Tenant:   tenant,
+	})
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "summary",
+			"error":     err.Error(),
+		}).Error("Failed to build catalog")
+		h.write(w, r, http.StatusInternalServerError, errorResponse{Error: "failed to build catalog"})
+		return
+	}
+	h.write(w, r, http.StatusOK, catalog)
+}
+
+// authorize reports whether the request carries the configured bearer token, if any, or
+// a tenant's token, returning the tenant the request is scoped to
+func (h *Handler) authorize(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok {
+		for tenantToken, tenant := range h.tenants {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(tenantToken)) == 1 {
+				return tenant, true
+			}
+		}
+	}
+	if h.token == "" {
+		return "", true
+	}
+	return "", ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// write writes a JSON response
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"component": "summary",
+			"error":     err.Error(),
+		}).Warn("Failed to write summary")
+	}
+}
+
+// Serve serves the summary, stats, event and catalog endpoints on the listener until ctx
+// is cancelled
+func Serve(ctx context.Context, listener net.Listener, handler *Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+	mux.Handle(StatsPath, handler)
+	mux.Handle(EventsPath, handler)
+	mux.Handle(CatalogPath, handler)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Requests end with ctx, so open event streams do not hold up the shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,452 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/scheduler"
+)
+
+const (
+	// FailureWindow is how far back failed uploads are reported
+	FailureWindow = 24 * time.Hour
+
+	// maxFailures bounds the failed uploads reported
+	maxFailures = 20
+
+	// heartbeatTimeout is how old the daemon's heartbeat may be before the daemon is
+	// reported as stopped
+	heartbeatTimeout = time.Minute
+
+	// overdueGrace is how long past its next run a node's schedule may be before it is
+	// reported as overdue
+	overdueGrace = time.Minute
+)
+
+// Store is the database the summary is read from
+type Store interface {
+	GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error)
+	GetRunningUploads(ctx context.Context) ([]database.Upload, error)
+	ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error)
+	GetScheduleStates(ctx context.Context) ([]database.ScheduleState, error)
+	GetDaemonHeartbeat(ctx context.Context, host string) (*database.DaemonHeartbeat, error)
+	ListUploadQueue(ctx context.Context) ([]database.UploadRequest, error)
+	GetJobStates(ctx context.Context, host string) ([]database.JobState, error)
+	GetNodeUploadStats(ctx context.Context, nodeName string, limit int) (*database.NodeUploadStats, error)
+	GetUploadStats(ctx context.Context, filter database.UploadFilter) (*database.UploadStats, error)
+	ListCatalog(ctx context.Context, filter database.CatalogFilter) ([]database.CatalogEntry, error)
+}
+
+// Summary is the compact state document served to external pollers
+type Summary struct {
+	GeneratedAt    time.Time       `json:"generated_at"`
+	Tenant         string          `json:"tenant,omitempty"` // Tenant the summary is scoped to, if any
+	Scheduler      Scheduler       `json:"scheduler"`
+	Nodes          []Node          `json:"nodes"`
+	Jobs           []Job           `json:"jobs"` // The scheduled jobs of the daemon on Scheduler.Host
+	RunningUploads []RunningUpload `json:"running_uploads"`
+	RecentFailures []Failure       `json:"recent_failures"` // Failed uploads started within FailureWindow
+}
+
+// Scheduler reports whether the daemon is running and keeping up with its schedules
+type Scheduler struct {
+	Healthy        bool       `json:"healthy"` // The daemon is running and no node is overdue
+	Host           string     `json:"host"`
+	DaemonRunning  bool       `json:"daemon_running"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"`
+	OverdueNodes   int        `json:"overdue_nodes"`
+	OverdueJobs    int        `json:"overdue_jobs"`
+	QueuedRequests int        `json:"queued_requests"` // Pending and processing upload requests
+}
+
+// Node is a node's snapshot freshness and schedule
+type Node struct {
+	Name            string     `json:"name"`
+	Protocol        string     `json:"protocol"`
+	LastUploadID    *int64     `json:"last_upload_id,omitempty"` // Latest completed upload
+	LastUploadAt    *time.Time `json:"last_upload_at,omitempty"`
+	AgeSeconds      *int64     `json:"age_seconds,omitempty"`
+	MaxAgeSeconds   *int64     `json:"max_age_seconds,omitempty"` // The node's max_snapshot_age, if set
+	Stale           bool       `json:"stale"`
+	RunningUploadID *int64     `json:"running_upload_id,omitempty"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastResult      *string    `json:"last_result,omitempty"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+	Overdue         bool       `json:"overdue"`
+	SLO             *NodeSLO   `json:"slo,omitempty"` // Compliance with the node's upload SLO, if it has one
+}
+
+// NodeSLO is a node's compliance with its upload SLO over the rolling window
+type NodeSLO struct {
+	TargetPercent     float64 `json:"target_percent"`
+	CompliancePercent float64 `json:"compliance_percent"`
+	Uploads           int     `json:"uploads"` // Uploads within the window measured against the SLO
+	Met               int     `json:"met"`
+	ErrorBudget       int     `json:"error_budget"` // Further uploads that may miss before compliance falls below target
+	WindowSeconds     int64   `json:"window_seconds"`
+	ProjectedMiss     bool    `json:"projected_miss"` // The running upload is projected to miss the SLO
+	AtRisk            bool    `json:"at_risk"`
+}
+
+// Job is a scheduled job's last run and next run
+type Job struct {
+	Name                string     `json:"name"`
+	Schedule            string     `json:"schedule"`
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	LastResult          *string    `json:"last_result,omitempty"` // running, succeeded, failed or panicked
+	LastError           *string    `json:"last_error,omitempty"`
+	LastDurationSeconds *float64   `json:"last_duration_seconds,omitempty"`
+	NextRunAt           *time.Time `json:"next_run_at,omitempty"`
+	Overdue             bool       `json:"overdue"`
+}
+
+// RunningUpload is an upload in progress
+type RunningUpload struct {
+	ID                  int64      `json:"id"`
+	Node                string     `json:"node"`
+	StartedAt           time.Time  `json:"started_at"`
+	ProgressPercent     *float64   `json:"progress_percent,omitempty"`
+	ChunksCompleted     *int       `json:"chunks_completed,omitempty"`
+	ChunksTotal         *int       `json:"chunks_total,omitempty"`
+	EstimatedCompletion *time.Time `json:"estimated_completion,omitempty"`
+	Stalled             bool       `json:"stalled"`
+}
+
+// Failure is a recently failed upload
+type Failure struct {
+	ID          int64      `json:"id"`
+	Node        string     `json:"node"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// NodeStats is a node's upload statistics over its last finished uploads, and how its
+// latest completed upload compares with its recent average
+type NodeStats struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Node        string    `json:"node"`
+	Last        int       `json:"last"` // Finished uploads requested (0 = all of them)
+	database.NodeUploadStats
+	FailureRate *float64 `json:"failure_rate,omitempty"` // Failed share of the uploads counted
+	Trend       *Trend   `json:"trend,omitempty"`        // nil when the node has no completed upload
+}
+
+// Trend compares a node's latest completed upload with the average of its completed
+// uploads started within the baseline window before it
+type Trend struct {
+	UploadID                   int64    `json:"upload_id"`
+	DurationSeconds            float64  `json:"duration_seconds"`
+	BaselineUploads            int      `json:"baseline_uploads"`
+	BaselineAvgDurationSeconds *float64 `json:"baseline_avg_duration_seconds,omitempty"`
+	BaselineWindowSeconds      int64    `json:"baseline_window_seconds"`
+	Factor                     *float64 `json:"factor,omitempty"` // Duration over the baseline average
+	Regressed                  bool     `json:"regressed"`        // At least analytics.DefaultRegressionFactor times the baseline average
+}
+
+// ErrUnknownNode is returned for stats of a node that is neither configured nor has uploads
+var ErrUnknownNode = errors.New("unknown node")
+
+// node is what the builder knows about a node from its configuration
+type node struct {
+	protocol string
+	tenant   string
+	maxAge   time.Duration
+	slo      *config.SLOConfig
+}
+
+// Builder builds summaries of the nodes of a configuration. The daemon keeps it in step
+// with nodes registered at runtime through SetNode and RemoveNode.
+type Builder struct {
+	store Store
+	host  string
+	now   func() time.Time
+
+	mu    sync.RWMutex
+	nodes map[string]node
+}
+
+// NewBuilder creates a builder for the nodes of cfg, reporting the heartbeat of the
+// daemon on host
+func NewBuilder(store Store, cfg *config.Config, host string) *Builder {
+	b := &Builder{
+		store: store,
+		host:  host,
+		now:   time.Now,
+		nodes: make(map[string]node, len(cfg.Nodes)),
+	}
+	for nodeName := range cfg.Nodes {
+		b.nodes[nodeName] = newNode(cfg, nodeName)
+	}
+	return b
+}
+
+// newNode reads a node's protocol, tenant, max_snapshot_age and SLO from cfg
+func newNode(cfg *config.Config, nodeName string) node {
+	return node{protocol: cfg.Nodes[nodeName].Protocol, tenant: cfg.GetNodeTenant(nodeName), maxAge: cfg.GetMaxSnapshotAge(nodeName), slo: cfg.GetNodeSLO(nodeName)}
+}
+
+// SetNode adds a node registered, or updated, at runtime
+func (b *Builder) SetNode(cfg *config.Config, nodeName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nodes[nodeName] = newNode(cfg, nodeName)
+}
+
+// RemoveNode drops a deregistered node
+func (b *Builder) RemoveNode(nodeName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.nodes, nodeName)
+}
+
+// NodeTenant returns the tenant of a configured node, and whether the node is configured
+func (b *Builder) NodeTenant(nodeName string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n, configured := b.nodes[nodeName]
+	return n.tenant, configured
+}
+
+// Build reads the current summary from the store
+func (b *Builder) Build(ctx context.Context) (*Summary, error) {
+	return b.BuildTenant(ctx, "")
+}
+
+// BuildTenant reads the current summary of a tenant's nodes and uploads from the store;
+// an empty tenant covers every node. The scheduler section and jobs describe the whole
+// daemon either way.
+func (b *Builder) BuildTenant(ctx context.Context, tenant string) (*Summary, error) {
+	now := b.now()
+	b.mu.RLock()
+	nodes := make(map[string]node, len(b.nodes))
+	for nodeName, n := range b.nodes {
+		if tenant == "" || n.tenant == tenant {
+			nodes[nodeName] = n
+		}
+	}
+	b.mu.RUnlock()
+
+	summary := &Summary{
+		GeneratedAt:    now.UTC(),
+		Tenant:         tenant,
+		Scheduler:      Scheduler{Host: b.host},
+		Nodes:          make([]Node, 0, len(nodes)),
+		Jobs:           []Job{},
+		RunningUploads: []RunningUpload{},
+		RecentFailures: []Failure{},
+	}
+
+	running, err := b.store.GetRunningUploads(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running uploads: %w", err)
+	}
+	runningByNode := make(map[string]*database.Upload, len(running))
+	for i, u := range running {
+		if tenant != "" && u.Tenant != tenant {
+			continue
+		}
+		runningByNode[u.NodeName] = &running[i]
+		summary.RunningUploads = append(summary.RunningUploads, RunningUpload{
+			ID:                  u.ID,
+			Node:                u.NodeName,
+			StartedAt:           u.StartedAt,
+			ProgressPercent:     u.ProgressPercent,
+			ChunksCompleted:     u.ChunksCompleted,
+			ChunksTotal:         u.ChunksTotal,
+			EstimatedCompletion: u.EstimatedCompletion,
+			Stalled:             u.StalledSince != nil,
+		})
+	}
+
+	states, err := b.store.GetScheduleStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule states: %w", err)
+	}
+	stateByNode := make(map[string]database.ScheduleState, len(states))
+	for _, state := range states {
+		stateByNode[state.NodeName] = state
+	}
+
+	for nodeName, n := range nodes {
+		entry := Node{Name: nodeName, Protocol: n.protocol}
+		if n.maxAge > 0 {
+			maxAge := int64(n.maxAge.Seconds())
+			entry.MaxAgeSeconds = &maxAge
+		}
+
+		last, err := b.store.GetLatestCompletedUploadForNode(ctx, nodeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest completed upload for %s: %w", nodeName, err)
+		}
+		if age, known := scheduler.SnapshotAge(last, now); known {
+			seconds := int64(age.Seconds())
+			entry.LastUploadID = &last.ID
+			entry.LastUploadAt = last.CompletedAt
+			entry.AgeSeconds = &seconds
+			entry.Stale = n.maxAge > 0 && age > n.maxAge
+		}
+
+		if u, ok := runningByNode[nodeName]; ok {
+			entry.RunningUploadID = &u.ID
+		}
+
+		if n.slo != nil {
+			finished, err := b.store.ListUploads(ctx, database.UploadFilter{NodeName: nodeName, Since: now.Add(-n.slo.GetWindow())})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list uploads for %s: %w", nodeName, err)
+			}
+			status := scheduler.EvaluateSLO(n.slo, finished, runningByNode[nodeName], now)
+			entry.SLO = &NodeSLO{
+				TargetPercent:     n.slo.Target,
+				CompliancePercent: status.Percent,
+				Uploads:           status.Uploads,
+				Met:               status.Met,
+				ErrorBudget:       status.Budget,
+				WindowSeconds:     int64(status.Window.Seconds()),
+				ProjectedMiss:     status.ProjectedMiss,
+				AtRisk:            status.AtRisk,
+			}
+		}
+
+		if state, recorded := stateByNode[nodeName]; recorded {
+			entry.LastRunAt = state.LastRunAt
+			entry.LastResult = state.LastResult
+			entry.NextRunAt = state.NextRunAt
+			entry.Overdue = state.NextRunAt != nil && now.Sub(*state.NextRunAt) > overdueGrace
+		}
+		if entry.Overdue {
+			summary.Scheduler.OverdueNodes++
+		}
+
+		summary.Nodes = append(summary.Nodes, entry)
+	}
+	sort.Slice(summary.Nodes, func(i, k int) bool { return summary.Nodes[i].Name < summary.Nodes[k].Name })
+
+	failed, err := b.store.ListUploads(ctx, database.UploadFilter{Status: "failed", Tenant: tenant, Since: now.Add(-FailureWindow), Limit: maxFailures})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed uploads: %w", err)
+	}
+	for _, u := range failed {
+		failure := Failure{ID: u.ID, Node: u.NodeName, StartedAt: u.StartedAt, CompletedAt: u.CompletedAt}
+		switch {
+		case u.ErrorMessage != nil:
+			failure.Error = *u.ErrorMessage
+		case u.CompletionMessage != nil:
+			failure.Error = *u.CompletionMessage
+		}
+		summary.RecentFailures = append(summary.RecentFailures, failure)
+	}
+
+	heartbeat, err := b.store.GetDaemonHeartbeat(ctx, b.host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daemon heartbeat: %w", err)
+	}
+	if heartbeat != nil {
+		heartbeatAt := heartbeat.HeartbeatAt
+		summary.Scheduler.HeartbeatAt = &heartbeatAt
+		summary.Scheduler.DaemonRunning = now.Sub(heartbeatAt) < heartbeatTimeout
+	}
+
+	queue, err := b.store.ListUploadQueue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upload queue: %w", err)
+	}
+	for _, request := range queue {
+		if _, included := nodes[request.NodeName]; tenant == "" || included {
+			summary.Scheduler.QueuedRequests++
+		}
+	}
+
+	jobs, err := b.store.GetJobStates(ctx, b.host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job states: %w", err)
+	}
+	for _, state := range jobs {
+		job := Job{
+			Name:       state.JobName,
+			Schedule:   state.Schedule,
+			LastRunAt:  state.LastRunAt,
+			LastResult: state.LastResult,
+			LastError:  state.LastError,
+			NextRunAt:  state.NextRunAt,
+			Overdue:    state.NextRunAt != nil && now.Sub(*state.NextRunAt) > overdueGrace,
+		}
+		if state.LastDurationMs != nil {
+			seconds := float64(*state.LastDurationMs) / 1000
+			job.LastDurationSeconds = &seconds
+		}
+		if job.Overdue {
+			summary.Scheduler.OverdueJobs++
+		}
+		summary.Jobs = append(summary.Jobs, job)
+	}
+
+	summary.Scheduler.Healthy = summary.Scheduler.DaemonRunning && summary.Scheduler.OverdueNodes == 0 && summary.Scheduler.OverdueJobs == 0
+
+	return summary, nil
+}
+
+// NodeStats reads a node's statistics over its last finished uploads (0 = all of them)
+// from the store
+func (b *Builder) NodeStats(ctx context.Context, nodeName string, last int) (*NodeStats, error) {
+	now := b.now()
+	b.mu.RLock()
+	_, configured := b.nodes[nodeName]
+	b.mu.RUnlock()
+
+	uploads, err := b.store.GetNodeUploadStats(ctx, nodeName, last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload stats for %s: %w", nodeName, err)
+	}
+	stats := &NodeStats{GeneratedAt: now.UTC(), Node: nodeName, Last: last, NodeUploadStats: *uploads}
+	if uploads.Uploads > 0 {
+		rate := float64(uploads.Failed) / float64(uploads.Uploads)
+		stats.FailureRate = &rate
+	}
+
+	latest, err := b.store.GetLatestCompletedUploadForNode(ctx, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest completed upload for %s: %w", nodeName, err)
+	}
+	if latest == nil || latest.CompletedAt == nil {
+		if !configured && uploads.Uploads == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownNode, nodeName)
+		}
+		return stats, nil
+	}
+
+	baseline, err := b.store.GetUploadStats(ctx, database.UploadFilter{
+		NodeName: nodeName,
+		Status:   "completed",
+		Since:    latest.StartedAt.Add(-analytics.DefaultBaselineWindow),
+		Until:    latest.StartedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get baseline upload stats for %s: %w", nodeName, err)
+	}
+	duration := latest.CompletedAt.Sub(latest.StartedAt)
+	stats.Trend = &Trend{
+		UploadID:                   latest.ID,
+		DurationSeconds:            duration.Seconds(),
+		BaselineUploads:            baseline.ByStatus["completed"],
+		BaselineAvgDurationSeconds: baseline.AvgDurationSeconds,
+		BaselineWindowSeconds:      int64(analytics.DefaultBaselineWindow.Seconds()),
+	}
+	if baseline.AvgDurationSeconds != nil && *baseline.AvgDurationSeconds > 0 {
+		factor := duration.Seconds() / *baseline.AvgDurationSeconds
+		stats.Trend.Factor = &factor
+		_, stats.Trend.Regressed = analytics.DetectRegression(duration, analytics.Baseline{
+			Uploads:     stats.Trend.BaselineUploads,
+			AvgDuration: time.Duration(*baseline.AvgDurationSeconds * float64(time.Second)),
+		}, analytics.DefaultRegressionFactor)
+	}
+	return stats, nil
+}
@@ -0,0 +1,542 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodexeus/agent/internal/config"
+	"github.com/nodexeus/agent/internal/database"
+	"github.com/nodexeus/agent/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+const testToken = "0123456789abcdef"
+
+// mockStore serves fixed uploads, schedule states and heartbeat
+type mockStore struct {
+	completed map[string]*database.Upload
+	running   []database.Upload
+	failed    []database.Upload
+	states    []database.ScheduleState
+	heartbeat *database.DaemonHeartbeat
+	queue     []database.UploadRequest
+	jobs      []database.JobState
+	filter    database.UploadFilter
+
+	nodeStats   map[string]*database.NodeUploadStats
+	baseline    *database.UploadStats
+	statsFilter database.UploadFilter
+
+	catalog       []database.CatalogEntry
+	catalogFilter database.CatalogFilter
+}
+
+func (m *mockStore) GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*database.Upload, error) {
+	return m.completed[nodeName], nil
+}
+
+func (m *mockStore) GetRunningUploads(ctx context.Context) ([]database.Upload, error) {
+	return m.running, nil
+}
+
+func (m *mockStore) ListUploads(ctx context.Context, filter database.UploadFilter) ([]database.Upload, error) {
+	m.filter = filter
+	return m.failed, nil
+}
+
+func (m *mockStore) GetScheduleStates(ctx context.Context) ([]database.ScheduleState, error) {
+	return m.states, nil
+}
+
+func (m *mockStore) GetDaemonHeartbeat(ctx context.Context, host string) (*database.DaemonHeartbeat, error) {
+	if m.heartbeat == nil || m.heartbeat.Host != host {
+		return nil, nil
+	}
+	return m.heartbeat, nil
+}
+
+func (m *mockStore) ListUploadQueue(ctx context.Context) ([]database.UploadRequest, error) {
+	return m.queue, nil
+}
+
+func (m *mockStore) GetJobStates(ctx context.Context, host string) ([]database.JobState, error) {
+	var jobs []database.JobState
+	for _, job := range m.jobs {
+		if job.Host == host {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *mockStore) GetNodeUploadStats(ctx context.Context, nodeName string, limit int) (*database.NodeUploadStats, error) {
+	if stats, ok := m.nodeStats[nodeName]; ok {
+		return stats, nil
+	}
+	return &database.NodeUploadStats{}, nil
+}
+
+func (m *mockStore) GetUploadStats(ctx context.Context, filter database.UploadFilter) (*database.UploadStats, error) {
+	m.statsFilter = filter
+	if m.baseline == nil {
+		return &database.UploadStats{ByStatus: map[string]int{}}, nil
+	}
+	return m.baseline, nil
+}
+
+func (m *mockStore) ListCatalog(ctx context.Context, filter database.CatalogFilter) ([]database.CatalogEntry, error) {
+	m.catalogFilter = filter
+	var entries []database.CatalogEntry
+	for _, entry := range m.catalog {
+		if (filter.Protocol == "" || entry.Protocol == filter.Protocol) && (filter.Network == "" || entry.Network == filter.Network) &&
+			(filter.NodeType == "" || entry.NodeType == filter.NodeType) && (filter.Tenant == "" || entry.Tenant == filter.Tenant) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func newTestBuilder(now time.Time) (*Builder, *mockStore) {
+	completedAt := now.Add(-30 * time.Hour)
+	overdueAt := now.Add(-10 * time.Minute)
+	nextRunAt := now.Add(time.Hour)
+	failedMsg := "bv upload exited with status 1"
+	percent := 42.5
+	succeeded := "succeeded"
+	durationMs := int64(1500)
+	failedAt := now.Add(-2 * time.Hour)
+
+	store := &mockStore{
+		completed: map[string]*database.Upload{
+			"eth-node": {ID: 7, NodeName: "eth-node", Status: "completed", StartedAt: completedAt.Add(-5 * time.Hour), CompletedAt: &completedAt},
+		},
+		running: []database.Upload{
+			{ID: 9, NodeName: "arb-node", Status: "running", StartedAt: now.Add(-time.Hour), ProgressPercent: &percent},
+		},
+		failed: []database.Upload{
+			{ID: 8, NodeName: "arb-node", Status: "failed", StartedAt: now.Add(-3 * time.Hour), CompletedAt: &failedAt, CompletionMessage: &failedMsg},
+		},
+		states: []database.ScheduleState{
+			{NodeName: "eth-node", NextRunAt: &overdueAt},
+			{NodeName: "arb-node", NextRunAt: &nextRunAt},
+		},
+		heartbeat: &database.DaemonHeartbeat{Host: "snap-1", HeartbeatAt: now.Add(-5 * time.Second)},
+		queue:     []database.UploadRequest{{ID: 1, NodeName: "eth-node", Status: database.UploadRequestPending}},
+		jobs: []database.JobState{
+			{Host: "snap-1", JobName: "upload_monitor", Schedule: "0 */5 * * * *", LastRunAt: &completedAt, LastResult: &succeeded, LastDurationMs: &durationMs, NextRunAt: &nextRunAt},
+			{Host: "snap-2", JobName: "upload_monitor", Schedule: "0 */5 * * * *", NextRunAt: &overdueAt},
+		},
+	}
+	cfg := &config.Config{
+		MaxSnapshotAge: "24h",
+		Nodes: map[string]config.NodeConfig{
+			"eth-node": {Protocol: "ethereum"},
+			"arb-node": {Protocol: "arbitrum", MaxSnapshotAge: "48h", SLO: &config.SLOConfig{Target: 90, MaxDuration: "8h"}},
+		},
+	}
+
+	builder := NewBuilder(store, cfg, "snap-1")
+	builder.now = func() time.Time { return now }
+	return builder, store
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	builder, store := newTestBuilder(now)
+
+	summary, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if len(summary.Nodes) != 2 || summary.Nodes[0].Name != "arb-node" || summary.Nodes[1].Name != "eth-node" {
+		t.Fatalf("expected nodes sorted by name, got %+v", summary.Nodes)
+	}
+	arb, eth := summary.Nodes[0], summary.Nodes[1]
+	if arb.LastUploadID != nil || arb.Stale || arb.Overdue || arb.RunningUploadID == nil || *arb.RunningUploadID != 9 {
+		t.Errorf("unexpected arb-node entry: %+v", arb)
+	}
+	if *arb.MaxAgeSeconds != 48*3600 {
+		t.Errorf("expected the node's max_snapshot_age, got %d", *arb.MaxAgeSeconds)
+	}
+	if eth.LastUploadID == nil || *eth.LastUploadID != 7 || *eth.AgeSeconds != 30*3600 || !eth.Stale || !eth.Overdue {
+		t.Errorf("unexpected eth-node entry: %+v", eth)
+	}
+
+	// arb-node's failed upload breaches its SLO; eth-node has none
+	if arb.SLO == nil || arb.SLO.Uploads != 1 || arb.SLO.Met != 0 || arb.SLO.CompliancePercent != 0 || !arb.SLO.AtRisk || arb.SLO.ProjectedMiss {
+		t.Errorf("unexpected arb-node SLO: %+v", arb.SLO)
+	}
+	if eth.SLO != nil {
+		t.Errorf("expected no SLO for eth-node, got %+v", eth.SLO)
+	}
+
+	if len(summary.RunningUploads) != 1 || *summary.RunningUploads[0].ProgressPercent != 42.5 {
+		t.Errorf("unexpected running uploads: %+v", summary.RunningUploads)
+	}
+	if len(summary.RecentFailures) != 1 || summary.RecentFailures[0].Error != "bv upload exited with status 1" {
+		t.Errorf("unexpected recent failures: %+v", summary.RecentFailures)
+	}
+	if store.filter.Status != "failed" || !store.filter.Since.Equal(now.Add(-FailureWindow)) {
+		t.Errorf("unexpected failure filter: %+v", store.filter)
+	}
+
+	s := summary.Scheduler
+	if !s.DaemonRunning || s.OverdueNodes != 1 || s.OverdueJobs != 0 || s.QueuedRequests != 1 || s.Healthy {
+		t.Errorf("expected a running daemon with an overdue node, got %+v", s)
+	}
+
+	// Only this host's jobs are reported
+	if len(summary.Jobs) != 1 || summary.Jobs[0].Name != "upload_monitor" || summary.Jobs[0].Overdue ||
+		summary.Jobs[0].LastDurationSeconds == nil || *summary.Jobs[0].LastDurationSeconds != 1.5 {
+		t.Errorf("unexpected jobs: %+v", summary.Jobs)
+	}
+
+	// A deregistered node is dropped, and a stopped daemon is reported
+	builder.RemoveNode("eth-node")
+	store.heartbeat.HeartbeatAt = now.Add(-time.Hour)
+	summary, err = builder.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(summary.Nodes) != 1 || summary.Scheduler.OverdueNodes != 0 || summary.Scheduler.DaemonRunning || summary.Scheduler.Healthy {
+		t.Errorf("expected one node and a stopped daemon, got %+v", summary)
+	}
+
+	// A job whose cron entry stopped firing makes the scheduler unhealthy
+	store.heartbeat.HeartbeatAt = now
+	store.jobs[0].NextRunAt = store.jobs[1].NextRunAt
+	summary, err = builder.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !summary.Jobs[0].Overdue || summary.Scheduler.OverdueJobs != 1 || summary.Scheduler.Healthy {
+		t.Errorf("expected an overdue job, got %+v", summary)
+	}
+}
+
+func TestBuildTenant(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	builder, store := newTestBuilder(now)
+	builder.SetNode(&config.Config{Nodes: map[string]config.NodeConfig{"arb-node": {Protocol: "arbitrum", Tenant: "team-arb"}}}, "arb-node")
+	store.running[0].Tenant = "team-arb"
+
+	summary, err := builder.BuildTenant(context.Background(), "team-arb")
+	if err != nil {
+		t.Fatalf("BuildTenant() error = %v", err)
+	}
+	if summary.Tenant != "team-arb" || len(summary.Nodes) != 1 || summary.Nodes[0].Name != "arb-node" {
+		t.Errorf("expected only the tenant's node, got %+v", summary.Nodes)
+	}
+	if len(summary.RunningUploads) != 1 || store.filter.Tenant != "team-arb" {
+		t.Errorf("expected the tenant's uploads, got %+v with failure filter %+v", summary.RunningUploads, store.filter)
+	}
+	// The queued request is eth-node's
+	if summary.Scheduler.QueuedRequests != 0 || summary.Scheduler.OverdueNodes != 0 {
+		t.Errorf("expected no queued requests or overdue nodes, got %+v", summary.Scheduler)
+	}
+
+	summary, err = builder.BuildTenant(context.Background(), "team-eth")
+	if err != nil {
+		t.Fatalf("BuildTenant() error = %v", err)
+	}
+	if len(summary.Nodes) != 0 || len(summary.RunningUploads) != 0 {
+		t.Errorf("expected nothing for a tenant without nodes, got %+v", summary)
+	}
+}
+
+func TestNodeStats(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	builder, store := newTestBuilder(now)
+	avg := 7200.0
+	store.nodeStats = map[string]*database.NodeUploadStats{
+		"eth-node": {Uploads: 4, Completed: 3, Failed: 1, AvgDurationSeconds: &avg},
+	}
+	store.baseline = &database.UploadStats{ByStatus: map[string]int{"completed": 6}, AvgDurationSeconds: &avg}
+
+	// The latest upload took 5h against a 2h baseline
+	stats, err := builder.NodeStats(context.Background(), "eth-node", 20)
+	if err != nil {
+		t.Fatalf("NodeStats() error = %v", err)
+	}
+	if stats.Uploads != 4 || stats.FailureRate == nil || *stats.FailureRate != 0.25 || stats.Last != 20 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	trend := stats.Trend
+	if trend == nil || trend.UploadID != 7 || trend.DurationSeconds != 5*3600 || trend.BaselineUploads != 6 ||
+		trend.Factor == nil || *trend.Factor != 2.5 || !trend.Regressed {
+		t.Fatalf("expected a regression, got %+v", trend)
+	}
+	latestStart := now.Add(-35 * time.Hour)
+	if f := store.statsFilter; f.NodeName != "eth-node" || f.Status != "completed" || !f.Until.Equal(latestStart) ||
+		!f.Since.Equal(latestStart.Add(-30*24*time.Hour)) {
+		t.Errorf("unexpected baseline filter: %+v", f)
+	}
+
+	// Too few baseline uploads to judge
+	store.baseline.ByStatus["completed"] = 2
+	stats, err = builder.NodeStats(context.Background(), "eth-node", 20)
+	if err != nil {
+		t.Fatalf("NodeStats() error = %v", err)
+	}
+	if stats.Trend.Regressed {
+		t.Errorf("expected no regression against 2 baseline uploads, got %+v", stats.Trend)
+	}
+
+	// A configured node without uploads has empty stats; an unknown one is an error
+	stats, err = builder.NodeStats(context.Background(), "arb-node", 20)
+	if err != nil || stats.Uploads != 0 || stats.FailureRate != nil || stats.Trend != nil {
+		t.Errorf("expected empty stats for arb-node, got %+v, %v", stats, err)
+	}
+	if _, err := builder.NodeStats(context.Background(), "missing-node", 20); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("expected ErrUnknownNode, got %v", err)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	builder, _ := newTestBuilder(time.Now())
+	handler := NewHandler(builder, testToken, logger)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+		wantLast   int
+	}{
+		{name: "default last", path: StatsPath + "eth-node", header: "Bearer " + testToken, wantStatus: http.StatusOK, wantLast: DefaultStatsLast},
+		{name: "last", path: StatsPath + "eth-node?last=5", header: "Bearer " + testToken, wantStatus: http.StatusOK, wantLast: 5},
+		{name: "missing token", path: StatsPath + "eth-node", wantStatus: http.StatusUnauthorized},
+		{name: "invalid last", path: StatsPath + "eth-node?last=-1", header: "Bearer " + testToken, wantStatus: http.StatusBadRequest},
+		{name: "unknown node", path: StatsPath + "missing-node", header: "Bearer " + testToken, wantStatus: http.StatusNotFound},
+		{name: "no node", path: StatsPath, header: "Bearer " + testToken, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var stats NodeStats
+			if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+				t.Fatalf("failed to decode stats: %v: %s", err, rec.Body.String())
+			}
+			if stats.Node != "eth-node" || stats.Last != tt.wantLast || stats.Trend == nil {
+				t.Errorf("unexpected stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	builder, _ := newTestBuilder(time.Now())
+
+	tests := []struct {
+		name        string
+		method      string
+		token       string
+		header      string
+		wantStatus  int
+		wantSummary bool
+	}{
+		{name: "open endpoint", method: http.MethodGet, wantStatus: http.StatusOK, wantSummary: true},
+		{name: "valid token", method: http.MethodGet, token: testToken, header: "Bearer " + testToken, wantStatus: http.StatusOK, wantSummary: true},
+		{name: "missing token", method: http.MethodGet, token: testToken, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, token: testToken, header: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "head", method: http.MethodHead, wantStatus: http.StatusOK},
+		{name: "post", method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(builder, tt.token, logger)
+			req := httptest.NewRequest(tt.method, Path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if !tt.wantSummary {
+				return
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("expected the summary not to be cached")
+			}
+			var summary Summary
+			if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+				t.Fatalf("failed to decode summary: %v: %s", err, rec.Body.String())
+			}
+			if len(summary.Nodes) != 2 || summary.Scheduler.Host != "snap-1" {
+				t.Errorf("unexpected summary: %+v", summary)
+			}
+		})
+	}
+}
+
+func TestTenantHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	const tenantToken = "team-arb-token-0001"
+	builder, _ := newTestBuilder(time.Now())
+	builder.SetNode(&config.Config{Nodes: map[string]config.NodeConfig{"arb-node": {Protocol: "arbitrum", Tenant: "team-arb"}}}, "arb-node")
+	handler := NewHandler(builder, testToken, logger)
+	handler.SetTenants(map[string]string{tenantToken: "team-arb"})
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(Path, tenantToken)
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected a summary, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if summary.Tenant != "team-arb" || len(summary.Nodes) != 1 || summary.Nodes[0].Name != "arb-node" {
+		t.Errorf("expected the tenant's summary, got %+v", summary)
+	}
+
+	// Other tenants' nodes are not found, and the daemon's token still sees every node
+	if rec := get(StatsPath+"arb-node", tenantToken); rec.Code != http.StatusOK {
+		t.Errorf("expected the tenant's node stats, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := get(StatsPath+"eth-node", tenantToken); rec.Code != http.StatusNotFound {
+		t.Errorf("expected another tenant's node not to be found, got %d", rec.Code)
+	}
+	if rec := get(StatsPath+"eth-node", testToken); rec.Code != http.StatusOK {
+		t.Errorf("expected the node stats with the daemon's token, got %d", rec.Code)
+	}
+	if rec := get(Path, "wrong-token-0000000"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown token to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestCatalogHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	const tenantToken = "team-arb-token-0001"
+	now := time.Now()
+	builder, store := newTestBuilder(now)
+	location := "s3://snapshots/eth-node/20261001.tar.gz"
+	store.catalog = []database.CatalogEntry{
+		{Protocol: "arbitrum", Network: "one", NodeType: "archive", NodeName: "arb-node", Tenant: "team-arb", UploadID: 3, CompletedAt: now.Add(-2 * time.Hour)},
+		{Protocol: "ethereum", Network: "mainnet", NodeType: "archive", NodeName: "eth-node", UploadID: 7, Location: &location, CompletedAt: now.Add(-time.Hour)},
+		{Protocol: "ethereum", Network: "mainnet", NodeType: "full", NodeName: "eth-full", UploadID: 8, CompletedAt: now},
+	}
+	handler := NewHandler(builder, testToken, logger)
+	handler.SetTenants(map[string]string{tenantToken: "team-arb"})
+
+	get := func(path, token string) Catalog {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var catalog Catalog
+		if err := json.Unmarshal(rec.Body.Bytes(), &catalog); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("expected a catalog, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return catalog
+	}
+
+	if catalog := get(CatalogPath, testToken); len(catalog.Snapshots) != 3 {
+		t.Errorf("expected every entry, got %+v", catalog.Snapshots)
+	}
+
+	// The freshest ethereum archive snapshot
+	catalog := get(CatalogPath+"?protocol=ethereum&network=mainnet&node_type=archive", testToken)
+	if len(catalog.Snapshots) != 1 || catalog.Snapshots[0].UploadID != 7 || catalog.Snapshots[0].Location == nil || *catalog.Snapshots[0].Location != location {
+		t.Fatalf("expected the ethereum archive snapshot, got %+v", catalog.Snapshots)
+	}
+	if age := catalog.Snapshots[0].AgeSeconds; age < 3599 || age > 3601 {
+		t.Errorf("expected the snapshot an hour old, got %v seconds", age)
+	}
+
+	// A tenant only sees the snapshots of its nodes
+	if catalog := get(CatalogPath, tenantToken); len(catalog.Snapshots) != 1 || catalog.Snapshots[0].NodeName != "arb-node" || store.catalogFilter.Tenant != "team-arb" {
+		t.Errorf("expected the tenant's entry only, got %+v", catalog.Snapshots)
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	builder, _ := newTestBuilder(time.Now())
+	handler := NewHandler(builder, testToken, logger)
+
+	get := func(ctx context.Context, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, EventsPath, nil).WithContext(ctx)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(context.Background(), "Bearer "+testToken); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without an event stream, got %d", rec.Code)
+	}
+
+	hub := events.NewHub()
+	handler.SetEvents(hub)
+	if rec := get(context.Background(), ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+
+	// The stream ends with the request; a resuming client receives the retained events
+	hub.Publish(events.Event{Type: events.TypeStarted, UploadID: 7, Node: "eth-node"})
+	hub.Publish(events.Event{Type: events.TypeCompleted, UploadID: 7, Node: "eth-node"})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, EventsPath, nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Last-Event-ID", "1")
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.HasPrefix(body, "id: 2\nevent: completed\ndata: {") {
+		t.Errorf("expected the completed event, got %q", body)
+	}
+}
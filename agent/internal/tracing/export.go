@@ -0,0 +1,284 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxPendingSpans bounds the finished spans held for export; later spans are dropped
+	// while the collector is unreachable
+	maxPendingSpans = 4096
+
+	// maxBatchSpans bounds the spans sent in one export request
+	maxBatchSpans = 512
+
+	// exportTimeout limits one export request
+	exportTimeout = 10 * time.Second
+
+	// scopeName is the instrumentation scope of the exported spans
+	scopeName = "github.com/nodexeus/agent"
+)
+
+// Tracer records finished spans and exports them in batches to an OTLP/HTTP collector,
+// JSON encoded
+type Tracer struct {
+	url      string
+	headers  map[string]string
+	resource []Attr
+	interval time.Duration
+	client   *http.Client
+	logger   *logrus.Logger
+
+	mu      sync.Mutex
+	pending []*Span
+	dropped int
+
+	exportMu sync.Mutex // Serializes exports, so spans are sent in the order they ended
+}
+
+// NewTracer creates a tracer exporting to a collector's OTLP/HTTP traces URL every
+// interval, sending headers with each export. resource describes the process, such as
+// service.name and host.name.
+func NewTracer(url string, headers map[string]string, interval time.Duration, resource []Attr, logger *logrus.Logger) *Tracer {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &Tracer{
+		url:      url,
+		headers:  headers,
+		resource: resource,
+		interval: interval,
+		client:   &http.Client{Timeout: exportTimeout},
+		logger:   logger,
+	}
+}
+
+// enqueue holds a finished span for the next export
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, s)
+}
+
+// Run exports finished spans every export interval until ctx is cancelled. Call Flush
+// afterwards to export the spans that end during shutdown.
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				t.logger.WithFields(logrus.Fields{
+					"component": "tracing",
+					"url":       t.url,
+					"error":     err.Error(),
+				}).Warn("Failed to export spans")
+			}
+		}
+	}
+}
+
+// Flush exports the finished spans. Spans in a batch the collector rejects are dropped.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.exportMu.Lock()
+	defer t.exportMu.Unlock()
+
+	t.mu.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		t.logger.WithFields(logrus.Fields{
+			"component": "tracing",
+			"dropped":   dropped,
+		}).Warn("Dropped spans while the export queue was full")
+	}
+
+	for len(spans) > 0 {
+		batch := spans
+		if len(batch) > maxBatchSpans {
+			batch = batch[:maxBatchSpans]
+		}
+		spans = spans[len(batch):]
+		if err := t.export(ctx, batch); err != nil {
+			return fmt.Errorf("failed to export %d spans: %w", len(batch)+len(spans), err)
+		}
+	}
+	return nil
+}
+
+// export sends one batch of spans to the collector
+func (t *Tracer) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// OTLP JSON documents, as in the ExportTraceServiceRequest protobuf mapped to JSON:
+// IDs are hex strings, and 64-bit integers and nanosecond timestamps are decimal strings
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 is STATUS_CODE_ERROR
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// encode converts spans to an OTLP export request
+func (t *Tracer) encode(spans []*Span) otlpRequest {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: scopeName}, Spans: make([]otlpSpan, 0, len(spans))}
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(s.end),
+			Attributes:        encodeAttributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.failed {
+			span.Status = &otlpStatus{Code: 2, Message: s.err}
+			span.Events = []otlpEvent{{
+				TimeUnixNano: unixNano(s.errAt),
+				Name:         "exception",
+				Attributes:   encodeAttributes([]Attr{String("exception.message", s.err)}),
+			}}
+		}
+		s.mu.Unlock()
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes(t.resource)},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+// encodeAttributes converts attributes to OTLP key-values; other value types are
+// formatted as strings
+func encodeAttributes(attrs []Attr) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
+
+// unixNano formats a time as OTLP nanoseconds since the Unix epoch
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
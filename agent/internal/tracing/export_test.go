@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlush(t *testing.T) {
+	var requests []otlpRequest
+	var path, auth string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		var request otlpRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("invalid export body: %v: %s", err, body)
+		}
+		requests = append(requests, request)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	tracer := newTestTracer(t, server.URL+"/v1/traces")
+	tracer.headers = map[string]string{"Authorization": "Bearer token"}
+
+	ctx, root := Start(context.Background(), "upload.run", String("node", "eth-node"))
+	_, child := StartClient(ctx, "exec bv", Int64("upload.id", 42), Bool("retried", false), Float64("ratio", 0.5))
+	child.RecordError(errors.New("exit status 1"))
+	child.End()
+	root.End()
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if path != "/v1/traces" || auth != "Bearer token" || len(requests) != 1 {
+		t.Fatalf("expected one export to /v1/traces with the configured headers, got %d to %s", len(requests), path)
+	}
+
+	resource := requests[0].ResourceSpans[0]
+	if attr := resource.Resource.Attributes[0]; attr.Key != "service.name" || *attr.Value.StringValue != "snapperd" {
+		t.Errorf("expected service.name snapperd, got %+v", attr)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	exported, parent := spans[0], spans[1]
+	if exported.Name != "exec bv" || exported.Kind != KindClient || exported.TraceID != parent.TraceID || exported.ParentSpanID != parent.SpanID || parent.ParentSpanID != "" {
+		t.Errorf("unexpected span hierarchy: %+v, %+v", exported, parent)
+	}
+	if exported.Status == nil || exported.Status.Code != 2 || exported.Status.Message != "exit status 1" || len(exported.Events) != 1 {
+		t.Errorf("expected a failed span with an exception event, got %+v", exported)
+	}
+	if *exported.Attributes[0].Value.IntValue != "42" || exported.StartTimeUnixNano == "" || exported.EndTimeUnixNano < exported.StartTimeUnixNano {
+		t.Errorf("unexpected attributes or times: %+v", exported)
+	}
+
+	// Nothing left to export
+	if err := tracer.Flush(context.Background()); err != nil || len(requests) != 1 {
+		t.Errorf("expected no further export, got %d, %v", len(requests), err)
+	}
+
+	// A rejected batch is reported and dropped
+	status = http.StatusBadRequest
+	_, span := Start(context.Background(), "upload.run")
+	span.End()
+	if err := tracer.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("expected the collector's error, got %v", err)
+	}
+	if len(tracer.pending) != 0 {
+		t.Errorf("expected the rejected spans to be dropped, got %d", len(tracer.pending))
+	}
+}
+
+func TestEnqueueBounded(t *testing.T) {
+	tracer := newTestTracer(t, "http://localhost:4318/v1/traces")
+	for i := 0; i < maxPendingSpans+10; i++ {
+		_, span := Start(context.Background(), "upload.run")
+		span.End()
+	}
+	if len(tracer.pending) != maxPendingSpans || tracer.dropped != 10 {
+		t.Errorf("expected %d spans held and 10 dropped, got %d and %d", maxPendingSpans, len(tracer.pending), tracer.dropped)
+	}
+}
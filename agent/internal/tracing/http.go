@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Inject sets the W3C traceparent header of the span in ctx on an outgoing request, so
+// a traced server continues the trace
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := parentContext(ctx); ok {
+		header.Set("traceparent", sc.TraceParent())
+	}
+}
+
+// Transport records a client span for each request of a traced operation and propagates
+// the trace to the server
+type Transport struct {
+	Base http.RoundTripper // nil uses http.DefaultTransport
+}
+
+// RoundTrip sends the request within a span named for its method
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Only the host is recorded: RPC URL paths and queries can carry API keys
+	ctx, span := StartClient(req.Context(), "HTTP "+req.Method,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Host),
+	)
+	if span == nil {
+		return base.RoundTrip(req)
+	}
+	defer span.End()
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.RecordError(fmt.Errorf("server returned status %d", resp.StatusCode))
+	}
+	return resp, nil
+}
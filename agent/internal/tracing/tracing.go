@@ -0,0 +1,264 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind is the OTLP kind of a span
+type SpanKind int
+
+// Span kinds, numbered as in OTLP
+const (
+	KindInternal SpanKind = 1
+	KindClient   SpanKind = 3
+)
+
+// Attr is a span attribute. Values are strings, bools, int64s or float64s.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int64 returns an integer attribute
+func Int64(key string, value int64) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Float64 returns a floating point attribute
+func Float64(key string, value float64) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// SpanContext identifies a span within its trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// IsValid reports whether the trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent returns the W3C traceparent header value of the span
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]))
+}
+
+// ParseTraceParent parses a W3C traceparent header value
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, fmt.Errorf("invalid traceparent '%s'", value)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid traceparent trace ID: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid traceparent span ID: %w", err)
+	}
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent '%s': zero trace or span ID", value)
+	}
+	return sc, nil
+}
+
+// Span is an operation within a trace. Its methods may be called on a nil span, which is
+// what Start returns while tracing is disabled, so instrumented code needs no checks.
+type Span struct {
+	tracer   *Tracer
+	context  SpanContext
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []Attr
+	err    string // Status message of a failed span
+	errAt  time.Time
+	failed bool
+	ended  bool
+}
+
+// Context returns the span's trace and span IDs, or a zero SpanContext for a nil span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span as failed with err; a nil err is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.err = err.Error()
+	s.errAt = time.Now()
+}
+
+// End finishes the span and queues it for export. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// global is the tracer spans are started with; nil while tracing is disabled
+var global atomic.Pointer[Tracer]
+
+// SetTracer sets the tracer spans are started with; nil disables tracing
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the span started in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithTraceParent returns ctx with a span recorded elsewhere, given as a W3C
+// traceparent, as the parent of the spans started from it. A span of a stored upload
+// continues the upload's trace this way. A nil or invalid traceparent leaves ctx as is.
+func ContextWithTraceParent(ctx context.Context, traceParent *string) context.Context {
+	if traceParent == nil || *traceParent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceParent(*traceParent)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(context.WithValue(ctx, spanKey{}, (*Span)(nil)), remoteKey{}, sc)
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, or nil without one
+func TraceParent(ctx context.Context) *string {
+	sc, ok := parentContext(ctx)
+	if !ok {
+		return nil
+	}
+	traceParent := sc.TraceParent()
+	return &traceParent
+}
+
+// parentContext returns the span context new spans in ctx are children of
+func parentContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context, true
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		return sc, true
+	}
+	return SpanContext{}, false
+}
+
+// Start starts an internal span, a child of the span in ctx or else the root of a new
+// trace, and returns ctx with the new span. While tracing is disabled it returns ctx and
+// a nil span.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, KindInternal, name, attrs)
+}
+
+// StartClient starts a client span for a call made by the operation traced in ctx: a
+// command, HTTP request or database query. Calls made outside a traced operation are not
+// recorded, so they are not exported as traces of their own; it then returns ctx and a
+// nil span.
+func StartClient(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if _, ok := parentContext(ctx); !ok {
+		return ctx, nil
+	}
+	return start(ctx, KindClient, name, attrs)
+}
+
+func start(ctx context.Context, kind SpanKind, name string, attrs []Attr) (context.Context, *Span) {
+	tracer := global.Load()
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: tracer,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	if parent, ok := parentContext(ctx); ok {
+		span.context.TraceID = parent.TraceID
+		span.parentID = parent.SpanID
+	} else {
+		randomID(span.context.TraceID[:])
+	}
+	randomID(span.context.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// randomID fills id with random bytes, never all zero
+func randomID(id []byte) {
+	for {
+		if _, err := rand.Read(id); err != nil {
+			panic(fmt.Sprintf("failed to generate trace ID: %v", err))
+		}
+		for _, b := range id {
+			if b != 0 {
+				return
+			}
+		}
+	}
+}
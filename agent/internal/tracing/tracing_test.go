@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestTracer installs a tracer for the test and returns it
+func newTestTracer(t *testing.T, url string) *Tracer {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	tracer := NewTracer(url, nil, time.Second, []Attr{String("service.name", "snapperd"), String("host.name", "snap-1")}, logger)
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return tracer
+}
+
+func TestTraceParent(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceParent(traceParent)
+	if err != nil {
+		t.Fatalf("ParseTraceParent() error = %v", err)
+	}
+	if sc.TraceParent() != traceParent {
+		t.Errorf("expected %s to round-trip, got %s", traceParent, sc.TraceParent())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceParent(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "upload.run")
+	if span != nil || SpanFromContext(ctx) != nil || TraceParent(ctx) != nil {
+		t.Fatal("expected no span while tracing is disabled")
+	}
+	// A nil span is safe to use
+	span.SetAttributes(String("node", "eth-node"))
+	span.RecordError(errors.New("failed"))
+	span.End()
+}
+
+func TestStartNesting(t *testing.T) {
+	tracer := newTestTracer(t, "http://localhost:4318/v1/traces")
+
+	// Client spans are only recorded within a traced operation
+	if _, span := StartClient(context.Background(), "db.query"); span != nil {
+		t.Error("expected no client span outside a trace")
+	}
+
+	ctx, root := Start(context.Background(), "upload.run")
+	childCtx, child := StartClient(ctx, "db.query")
+	if child == nil || child.Context().TraceID != root.Context().TraceID || child.parentID != root.Context().SpanID {
+		t.Fatalf("expected a child of the root span, got %+v", child)
+	}
+	if SpanFromContext(childCtx) != child {
+		t.Error("expected the child span in its context")
+	}
+	child.End()
+	child.End()
+	root.End()
+	if len(tracer.pending) != 2 {
+		t.Errorf("expected each span queued once, got %d", len(tracer.pending))
+	}
+
+	// A stored traceparent continues the trace, in place of the span in ctx
+	stored := TraceParent(ctx)
+	_, other := Start(context.Background(), "upload_monitor")
+	remoteCtx := ContextWithTraceParent(context.WithValue(ctx, spanKey{}, other), stored)
+	_, tick := Start(remoteCtx, "upload.monitor")
+	if tick.Context().TraceID != root.Context().TraceID || tick.parentID != root.Context().SpanID {
+		t.Errorf("expected the monitor span to continue the stored trace, got %+v", tick.Context())
+	}
+	if ContextWithTraceParent(ctx, nil) != ctx {
+		t.Error("expected a nil traceparent to leave the context as is")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	tracer := newTestTracer(t, "http://localhost:4318/v1/traces")
+	client := &http.Client{Transport: &Transport{}}
+
+	ctx, root := Start(context.Background(), "upload.run")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(tracer.pending) != 1 {
+		t.Fatalf("expected the request's span, got %d spans", len(tracer.pending))
+	}
+	span := tracer.pending[0]
+	if span.name != "HTTP POST" || span.kind != KindClient || !span.failed {
+		t.Errorf("expected a failed client span, got %+v", span)
+	}
+	if received != span.Context().TraceParent() || span.parentID != root.Context().SpanID {
+		t.Errorf("expected the request's span propagated, got traceparent %q", received)
+	}
+}
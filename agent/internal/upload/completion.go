@@ -0,0 +1,92 @@
+package upload
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/engine"
+)
+
+// CompletionOutcome is the state of an upload after a monitor check
+type CompletionOutcome string
+
+// Monitor check outcomes
+const (
+	OutcomeRunning   CompletionOutcome = "running"   // The upload job is still running
+	OutcomeSuccess   CompletionOutcome = "success"   // The upload job finished successfully
+	OutcomeFailure   CompletionOutcome = "failure"   // The upload job finished with an error
+	OutcomeCancelled CompletionOutcome = "cancelled" // The upload job was stopped before finishing
+)
+
+// exitCodePattern extracts the exit code from bv status lines such as "Finished with exit code 1"
+var exitCodePattern = regexp.MustCompile(`(?i)exit code (-?\d+)`)
+
+// CompletionResult is the result of a monitor check, shared by the scheduler and the CLI
+type CompletionResult struct {
+	Outcome CompletionOutcome
+	Message *string // bv's final status line, when reported
+	// FinishedAt is when bv reports the job finished and DetectionLag how long after that
+	// the monitor detected it; both are nil when the status line has no timestamp
+	FinishedAt   *time.Time
+	DetectionLag *time.Duration
+	// Compression is how a successful upload was compressed, for engines that report it
+	Compression *engine.CompressionReport
+	// SizeBytes is the snapshot's size in bytes after a successful upload, for engines that
+	// report it
+	SizeBytes *int64
+	// Location is where a successful upload stored the snapshot, for engines that report it
+	Location string
+}
+
+// Done reports whether the upload has finished
+func (r CompletionResult) Done() bool {
+	return r.Outcome != OutcomeRunning
+}
+
+// RecordStatus returns the uploads.status value recorded for a finished upload
+func (r CompletionResult) RecordStatus() string {
+	switch r.Outcome {
+	case OutcomeFailure:
+		return "failed"
+	case OutcomeCancelled:
+		return "cancelled"
+	default:
+		return "completed"
+	}
+}
+
+// parseStatusTime returns the timestamp of bv's status line, stored by parseUploadStatus
+func parseStatusTime(progress JSONB) (time.Time, bool) {
+	value, ok := progress["started_at"].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// classifyCompletion determines how an upload that is no longer running ended. An exit
+// code decides on its own; otherwise the status wording is used. Output without a
+// recognizable failure or cancellation (including a job that has disappeared) is
+// treated as success.
+func classifyCompletion(statusLine string) CompletionOutcome {
+	lower := strings.ToLower(statusLine)
+
+	if match := exitCodePattern.FindStringSubmatch(lower); match != nil {
+		if match[1] == "0" {
+			return OutcomeSuccess
+		}
+		return OutcomeFailure
+	}
+	switch {
+	case strings.Contains(lower, "cancel"), strings.Contains(lower, "stopped"), strings.Contains(lower, "killed"):
+		return OutcomeCancelled
+	case strings.Contains(lower, "failed"), strings.Contains(lower, "error"):
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
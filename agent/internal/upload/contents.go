@@ -0,0 +1,80 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ContentObject is one object (chunk or file) stored for a completed snapshot
+type ContentObject struct {
+	Key       string // Object key in the storage backend
+	SizeBytes *int64 // Object size, when listed
+	Checksum  string // Object checksum, when listed
+}
+
+// FetchContentListing runs the configured listing command for a node's completed upload
+// and parses the objects it reports. "{node}" in the command is replaced with the node
+// name. Each output line is "<key> [size_bytes] [checksum]"; blank lines and lines
+// starting with "#" are ignored.
+func (m *Manager) FetchContentListing(ctx context.Context, nodeName string, command []string) ([]ContentObject, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no content listing command configured")
+	}
+
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		args[i] = strings.ReplaceAll(arg, "{node}", nodeName)
+	}
+
+	stdout, stderr, err := m.executor.Execute(ctx, command[0], args...)
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"error":     err.Error(),
+			"stderr":    stderr,
+		}).Warn("Failed to list snapshot contents")
+		return nil, fmt.Errorf("failed to list snapshot contents: %w", err)
+	}
+
+	return parseContentListing(stdout)
+}
+
+// parseContentListing parses the output of a content listing command
+func parseContentListing(output string) ([]ContentObject, error) {
+	var objects []ContentObject
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected '<key> [size_bytes] [checksum]', got %q", i+1, strings.TrimSpace(line))
+		}
+
+		object := ContentObject{Key: fields[0]}
+		if len(fields) > 1 {
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("line %d: invalid size '%s' for %s", i+1, fields[1], object.Key)
+			}
+			object.SizeBytes = &size
+		}
+		if len(fields) > 2 {
+			object.Checksum = fields[2]
+		}
+
+		if seen[object.Key] {
+			return nil, fmt.Errorf("line %d: duplicate object key %s", i+1, object.Key)
+		}
+		seen[object.Key] = true
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,193 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nodexeus/agent/internal/bvclient"
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/sirupsen/logrus"
+)
+
+// bvEngine runs uploads as blockvisor upload jobs through the bv CLI. It reads the
+// manager's status rules and output format, so it is created by the manager.
+type bvEngine struct {
+	m *Manager
+}
+
+// Name returns the engine name
+func (e *bvEngine) Name() string {
+	return engine.BV
+}
+
+// StartUpload starts the node's upload job. A failed command returns a
+// *bvclient.CommandError with bv's output.
+func (e *bvEngine) StartUpload(ctx context.Context, nodeName string) error {
+	ctx, cancel := withTimeout(ctx, e.m.bvStartTimeout)
+	defer cancel()
+
+	// Execute: bv node run upload <node> [flags from the node's engine arguments]
+	args := append([]string{"node", "run", "upload", nodeName}, e.m.bvFlagsFor(nodeName)...)
+	return e.run(ctx, args...)
+}
+
+// Cancel stops the node's upload job
+func (e *bvEngine) Cancel(ctx context.Context, nodeName string) error {
+	ctx, cancel := withTimeout(ctx, e.m.bvStartTimeout)
+	defer cancel()
+
+	// Execute: bv node job <node> stop upload
+	return e.run(ctx, "node", "job", nodeName, "stop", "upload")
+}
+
+// Logs returns the node's upload job log
+func (e *bvEngine) Logs(ctx context.Context, nodeName string) (string, error) {
+	ctx, cancel := withTimeout(ctx, e.m.bvStatusTimeout)
+	defer cancel()
+
+	// Execute: bv node job <node> logs upload
+	stdout, stderr, err := e.m.executor.Execute(ctx, "bv", "node", "job", nodeName, "logs", "upload")
+	if err != nil {
+		return "", &bvclient.CommandError{Stdout: stdout, Stderr: stderr, Err: err}
+	}
+	return stdout, nil
+}
+
+// StreamLogs hands each line of the node's upload job log to onLine as bv writes it,
+// when the executor can stream, else once the log has been read
+func (e *bvEngine) StreamLogs(ctx context.Context, nodeName string, onLine executor.LineHandler) error {
+	ctx, cancel := withTimeout(ctx, e.m.bvStatusTimeout)
+	defer cancel()
+
+	streamer, ok := e.m.executor.(executor.StreamingExecutor)
+	if !ok {
+		stdout, err := e.Logs(ctx, nodeName)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+			onLine(executor.StreamStdout, strings.TrimRight(line, "\r"))
+		}
+		return nil
+	}
+
+	// Execute: bv node job <node> logs upload
+	if err := streamer.ExecuteStream(ctx, onLine, "bv", "node", "job", nodeName, "logs", "upload"); err != nil {
+		return &bvclient.CommandError{Err: err}
+	}
+	return nil
+}
+
+// Status reads the node's upload job info. A failed command whose output matches the
+// not-running rules reports an upload that is not running, with the command's output
+// in the status fields; other failures are returned, since they say nothing about the
+// upload.
+func (e *bvEngine) Status(ctx context.Context, nodeName string) (*engine.Status, error) {
+	m := e.m
+	ctx, cancel := withTimeout(ctx, m.bvStatusTimeout)
+	defer cancel()
+
+	// Execute: bv node job <node> info upload
+	info, err := m.bv.JobInfo(ctx, nodeName, "upload")
+	if err != nil {
+		var cmdErr *bvclient.CommandError
+		if !errors.As(err, &cmdErr) {
+			return nil, fmt.Errorf("failed to check upload status: %w", err)
+		}
+		stdout, stderr := cmdErr.Stdout, cmdErr.Stderr
+		// Check if this is a "job not found" type error vs other system errors
+		errorOutput := cmdErr.Output()
+
+		// Only treat errors matching the not-running rules as "not running"
+		if matchesAny(m.rules.NotRunning, errorOutput, err.Error()) {
+			notFound := matchesAny(m.rules.NotFound, errorOutput, err.Error())
+
+			m.logger.WithFields(logrus.Fields{
+				"component": "upload",
+				"node":      nodeName,
+				"not_found": notFound,
+			}).Debug("No upload job for node, treating as not running")
+
+			return &engine.Status{
+				NotFound: notFound,
+				Fields: map[string]string{
+					"error":  err.Error(),
+					"stderr": stderr,
+					"stdout": stdout,
+				},
+				Raw: errorOutput,
+			}, nil
+		}
+
+		// For other errors, return the error
+		// Don't assume the upload status based on command execution issues
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"error":     err.Error(),
+			"stderr":    executor.TruncateOutput(stderr, maxFieldBytes),
+		}).Error("Failed to check upload status")
+		return nil, fmt.Errorf("failed to check upload status: %w", err)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"component": "upload",
+		"node":      nodeName,
+		"bv_output": info.Format,
+	}).Debug("Read bv upload job info")
+
+	return e.status(info), nil
+}
+
+// status converts bv's upload job info to an engine status, keeping the fields shown
+// with an upload's progress
+func (e *bvEngine) status(info *bvclient.JobInfo) *engine.Status {
+	// Check for empty output or no job indicators
+	if info.Raw == "" || matchesAny(e.m.rules.IdleOutput, info.Raw) {
+		return &engine.Status{
+			NotFound: matchesAny(e.m.rules.NotFound, info.Raw),
+			Raw:      info.Raw,
+		}
+	}
+
+	status := &engine.Status{
+		Running:         info.Running,
+		Status:          info.Status,
+		State:           info.State,
+		StatusTime:      info.StatusTime,
+		Progress:        info.Progress,
+		ProgressPercent: info.ProgressPercent,
+		ChunksCompleted: info.ChunksCompleted,
+		ChunksTotal:     info.ChunksTotal,
+		SizeBytes:       info.SizeBytes,
+		Fields:          make(map[string]string),
+		Raw:             info.Raw,
+	}
+	for _, key := range []string{"restart_count", "upgrade_blocking", "logs"} {
+		if value, ok := info.Fields[key]; ok {
+			status.Fields[key] = value
+		}
+	}
+	return status
+}
+
+// withTimeout returns ctx bounded by timeout, or ctx itself when timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// run runs a bv command, returning a *bvclient.CommandError with its output on failure
+func (e *bvEngine) run(ctx context.Context, args ...string) error {
+	stdout, stderr, err := e.m.executor.Execute(ctx, "bv", args...)
+	if err != nil {
+		return &bvclient.CommandError{Stdout: stdout, Stderr: stderr, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package upload
+
+import (
+	"time"
+
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/events"
+)
+
+// SetEvents publishes the lifecycle events of the uploads the manager records to hub
+func (m *Manager) SetEvents(hub *events.Hub) {
+	m.events = hub
+}
+
+// publishStarted publishes the start of a newly recorded upload
+func (m *Manager) publishStarted(uploadID int64, u Upload) {
+	m.events.Publish(events.Event{
+		Type:            events.TypeStarted,
+		UploadID:        uploadID,
+		Node:            u.NodeName,
+		Tenant:          u.Tenant,
+		Protocol:        u.Protocol,
+		Trigger:         string(u.TriggerType),
+		StartedAt:       &u.StartedAt,
+		ProgressPercent: u.ProgressPercent,
+		ChunksCompleted: u.ChunksCompleted,
+		ChunksTotal:     u.ChunksTotal,
+	})
+}
+
+// publishProgress publishes a running upload's refreshed progress and, when estimated,
+// its throughput
+func (m *Manager) publishProgress(uploadID int64, nodeName string, progressPercent *float64, chunksCompleted, chunksTotal *int, estimate *analytics.Estimate) {
+	e := events.Event{
+		Type:            events.TypeProgress,
+		UploadID:        uploadID,
+		Node:            nodeName,
+		Tenant:          m.tenantFor(nodeName),
+		ProgressPercent: progressPercent,
+		ChunksCompleted: chunksCompleted,
+		ChunksTotal:     chunksTotal,
+	}
+	if estimate != nil {
+		e.ChunksPerMinute = &estimate.ChunksPerMinute
+		e.EstimatedCompletion = estimate.EstimatedCompletion
+	}
+	m.events.Publish(e)
+}
+
+// publishFinished publishes the end of an upload recorded with status: completed,
+// cancelled, or failed for any other status, such as stalled
+func (m *Manager) publishFinished(uploadID int64, nodeName, status string, message *string, finishedAt time.Time) {
+	eventType := events.TypeFailed
+	switch status {
+	case "completed":
+		eventType = events.TypeCompleted
+	case "cancelled":
+		eventType = events.TypeCancelled
+	}
+	m.events.Publish(events.Event{
+		Type:     eventType,
+		Time:     finishedAt,
+		UploadID: uploadID,
+		Node:     nodeName,
+		Tenant:   m.tenantFor(nodeName),
+		Status:   status,
+		Message:  message,
+	})
+}
@@ -0,0 +1,162 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/sirupsen/logrus"
+)
+
+// FailureCategory is a coarse classification of why an upload failed, used to triage alerts
+type FailureCategory string
+
+// Failure categories, checked in this order
+const (
+	FailureDiskFull    FailureCategory = "disk_full"     // The node ran out of disk space
+	FailureAuth        FailureCategory = "auth"          // Credentials were rejected by the storage backend
+	FailureNetwork     FailureCategory = "network"       // The storage backend or API could not be reached
+	FailureOutOfMemory FailureCategory = "out_of_memory" // The upload process was killed for memory
+	FailureUnknown     FailureCategory = "unknown"       // No known pattern matched
+)
+
+// failurePatterns maps each category to the case-insensitive substrings that identify it
+var failurePatterns = []struct {
+	category FailureCategory
+	patterns []string
+}{
+	{FailureDiskFull, []string{"no space left", "disk full", "disk quota exceeded"}},
+	{FailureAuth, []string{"access denied", "accessdenied", "unauthorized", "forbidden", "invalid credentials", "signaturedoesnotmatch", "expired token"}},
+	{FailureNetwork, []string{"connection refused", "connection reset", "timed out", "timeout", "no such host", "network is unreachable", "broken pipe"}},
+	{FailureOutOfMemory, []string{"out of memory", "oom-kill", "oomkilled", "cannot allocate memory"}},
+}
+
+// ClassifyFailure categorizes a failed upload from its status message and job log lines
+func ClassifyFailure(message string, logLines []string) FailureCategory {
+	text := strings.ToLower(message + "\n" + strings.Join(logLines, "\n"))
+	for _, entry := range failurePatterns {
+		for _, pattern := range entry.patterns {
+			if strings.Contains(text, pattern) {
+				return entry.category
+			}
+		}
+	}
+	return FailureUnknown
+}
+
+// maxLogLineBytes bounds each log line returned by FetchJobLogs and TailJobLogs
+const maxLogLineBytes = 1 << 10
+
+// JobLogLine is a line of an upload's log with the output stream it was written to
+type JobLogLine struct {
+	Stream executor.Stream
+	Line   string
+}
+
+// FetchJobLogs returns up to the last n non-empty lines of the log of a node's upload,
+// or none when its engine keeps no log. Overlong lines are truncated.
+func (m *Manager) FetchJobLogs(ctx context.Context, nodeName string, n int) ([]string, error) {
+	tail := newLogTail(n)
+	if err := m.streamJobLogs(ctx, nodeName, n, func(stream executor.Stream, line string) {
+		if stream == executor.StreamStdout {
+			tail.add(JobLogLine{Stream: stream, Line: line})
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range tail.lines() {
+		lines = append(lines, line.Line)
+	}
+	return lines, nil
+}
+
+// TailJobLogs returns up to the last n non-empty lines of the log of a node's upload
+// from both output streams, or none when its engine keeps no log. The log is streamed
+// from the engine when it can, so only n lines are ever held. Overlong lines are
+// truncated. When reading the log fails, the lines read before the failure are returned
+// with the error, as the command's own complaints are often the most telling.
+func (m *Manager) TailJobLogs(ctx context.Context, nodeName string, n int) ([]JobLogLine, error) {
+	tail := newLogTail(n)
+	err := m.streamJobLogs(ctx, nodeName, n, func(stream executor.Stream, line string) {
+		tail.add(JobLogLine{Stream: stream, Line: line})
+	})
+	return tail.lines(), err
+}
+
+// streamJobLogs hands each non-empty line of the log of a node's upload to onLine,
+// truncated to maxLogLineBytes. It does nothing when n is not positive or the node's
+// engine keeps no log.
+func (m *Manager) streamJobLogs(ctx context.Context, nodeName string, n int, onLine executor.LineHandler) error {
+	if n <= 0 {
+		return nil
+	}
+
+	handle := func(stream executor.Stream, line string) {
+		if strings.TrimSpace(line) != "" {
+			onLine(stream, executor.TruncateOutput(strings.TrimRight(line, "\r"), maxLogLineBytes))
+		}
+	}
+
+	var err error
+	switch e := m.engineFor(nodeName).(type) {
+	case engine.LogStreamer:
+		err = e.StreamLogs(ctx, nodeName, handle)
+	case engine.LogReader:
+		var stdout string
+		stdout, err = e.Logs(ctx, nodeName)
+		if err == nil {
+			for _, line := range strings.Split(stdout, "\n") {
+				handle(executor.StreamStdout, line)
+			}
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		_, stderr := commandOutput(err)
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"error":     err.Error(),
+			"stderr":    stderr,
+		}).Warn("Failed to fetch upload job logs")
+		return fmt.Errorf("failed to fetch upload job logs: %w", err)
+	}
+	return nil
+}
+
+// logTail keeps the last lines added to it
+type logTail struct {
+	max  int
+	buf  []JobLogLine
+	next int // Where the next line goes once buf is full
+}
+
+func newLogTail(max int) *logTail {
+	return &logTail{max: max}
+}
+
+// add keeps line, dropping the oldest line when the tail is full
+func (t *logTail) add(line JobLogLine) {
+	if t.max <= 0 {
+		return
+	}
+	if len(t.buf) < t.max {
+		t.buf = append(t.buf, line)
+		return
+	}
+	t.buf[t.next] = line
+	t.next = (t.next + 1) % t.max
+}
+
+// lines returns the kept lines, oldest first
+func (t *logTail) lines() []JobLogLine {
+	if len(t.buf) == 0 {
+		return nil
+	}
+	return append(append([]JobLogLine{}, t.buf[t.next:]...), t.buf[:t.next]...)
+}
@@ -0,0 +1,50 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunHook runs an upload hook command with sh -c. The variables in env are set for the
+// command through env(1), so hooks run through the same executor as bv. The hook
+// succeeds when the command exits 0; otherwise the returned error includes its output.
+func (m *Manager) RunHook(ctx context.Context, nodeName string, command string, env map[string]string) error {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(env)+3)
+	for _, key := range keys {
+		args = append(args, key+"="+env[key])
+	}
+	args = append(args, "sh", "-c", command)
+
+	stdout, stderr, err := m.executorFor(nodeName).Execute(ctx, "env", args...)
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"hook":      command,
+			"error":     err.Error(),
+			"stderr":    stderr,
+			"stdout":    stdout,
+		}).Warn("Upload hook failed")
+
+		output := strings.TrimSpace(stderr)
+		if output == "" {
+			output = strings.TrimSpace(stdout)
+		}
+		if output != "" {
+			return fmt.Errorf("%w: %s", err, output)
+		}
+		return err
+	}
+
+	return nil
+}
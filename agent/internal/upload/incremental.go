@@ -0,0 +1,141 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IncrementalBase is the snapshot an incremental upload is taken against
+type IncrementalBase struct {
+	UploadID int64           // The base upload
+	Objects  []ContentObject // Its recorded content listing
+}
+
+// InitiateIncrementalUpload starts an upload that only sends the objects changed since
+// the base snapshot. The base's content listing is written to a manifest file and the
+// node's incremental command is run in place of 'bv node run upload'. "{node}",
+// "{base_upload_id}" and "{base_manifest}" in the command arguments are replaced. The
+// upload record is linked to its base.
+func (m *Manager) InitiateIncrementalUpload(ctx context.Context, nodeName string, trigger Trigger, protocol string, nodeType string, protocolData map[string]interface{}, command []string, base IncrementalBase) (int64, error) {
+	if len(command) == 0 {
+		return 0, fmt.Errorf("no incremental upload command configured")
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"component":      "upload",
+		"node":           nodeName,
+		"protocol":       protocol,
+		"trigger_type":   trigger.Type,
+		"base_upload_id": base.UploadID,
+		"action":         "initiate_incremental",
+	}).Info("Initiating incremental upload")
+
+	// Write the manifest before creating the record, so a failure leaves no record behind
+	manifestPath, err := writeBaseManifest(nodeName, base.Objects)
+	if err != nil {
+		return 0, err
+	}
+
+	baseUploadID := base.UploadID
+	uploadID, err := m.startUploadRecord(ctx, nodeName, protocol, nodeType, trigger, protocolData, &baseUploadID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create upload record: %w", err)
+	}
+
+	replacer := strings.NewReplacer(
+		"{node}", nodeName,
+		"{base_upload_id}", strconv.FormatInt(base.UploadID, 10),
+		"{base_manifest}", manifestPath,
+	)
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		args[i] = replacer.Replace(arg)
+	}
+
+	stdout, stderr, err := m.executorFor(nodeName).Execute(ctx, command[0], args...)
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"error":     err.Error(),
+			"stderr":    stderr,
+			"stdout":    stdout,
+			"upload_id": uploadID,
+		}).Error("Failed to initiate incremental upload")
+		// Mark the upload as failed since we already created the record
+		completionMsg := fmt.Sprintf("Failed to start incremental upload: %s", err.Error())
+		now := time.Now()
+		_ = m.db.UpdateUploadCompletion(ctx, uploadID, now, "failed", &completionMsg, nil)
+		m.publishFinished(uploadID, nodeName, "failed", &completionMsg, now)
+		return 0, fmt.Errorf("failed to initiate incremental upload: %w", err)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"component":      "upload",
+		"node":           nodeName,
+		"upload_id":      uploadID,
+		"base_upload_id": base.UploadID,
+		"base_objects":   len(base.Objects),
+	}).Info("Incremental upload initiated successfully")
+
+	return uploadID, nil
+}
+
+// writeBaseManifest writes a base snapshot's content listing, in the format read by
+// FetchContentListing, to the node's manifest file in the temporary directory. The
+// file is replaced by the node's next incremental upload.
+func writeBaseManifest(nodeName string, objects []ContentObject) (string, error) {
+	var b strings.Builder
+	for _, object := range objects {
+		b.WriteString(object.Key)
+		if object.SizeBytes != nil {
+			b.WriteString(" ")
+			b.WriteString(strconv.FormatInt(*object.SizeBytes, 10))
+			if object.Checksum != "" {
+				b.WriteString(" ")
+				b.WriteString(object.Checksum)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("snapperd-%s-base.manifest", filepath.Base(nodeName)))
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write base manifest: %w", err)
+	}
+	return path, nil
+}
+
+// DiffContents counts the objects of a snapshot that are new or changed relative to
+// its base. An object is unchanged when its key exists in the base with the same
+// size and checksum.
+func DiffContents(base, current []ContentObject) int {
+	baseByKey := make(map[string]ContentObject, len(base))
+	for _, object := range base {
+		baseByKey[object.Key] = object
+	}
+
+	changed := 0
+	for _, object := range current {
+		previous, ok := baseByKey[object.Key]
+		if !ok || !sameSize(previous.SizeBytes, object.SizeBytes) || previous.Checksum != object.Checksum {
+			changed++
+		}
+	}
+	return changed
+}
+
+// sameSize reports whether two optional object sizes are equal
+func sameSize(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
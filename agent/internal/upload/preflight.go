@@ -0,0 +1,45 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunPreflightCommand runs a node's custom preflight check. "{node}" in the command
+// arguments is replaced with the node name. The check passes when the command exits 0;
+// otherwise the returned error includes its output.
+func (m *Manager) RunPreflightCommand(ctx context.Context, nodeName string, command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("no preflight command configured")
+	}
+
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		args[i] = strings.ReplaceAll(arg, "{node}", nodeName)
+	}
+
+	stdout, stderr, err := m.executorFor(nodeName).Execute(ctx, command[0], args...)
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"error":     err.Error(),
+			"stderr":    stderr,
+			"stdout":    stdout,
+		}).Warn("Preflight command failed")
+
+		output := strings.TrimSpace(stderr)
+		if output == "" {
+			output = strings.TrimSpace(stdout)
+		}
+		if output != "" {
+			return fmt.Errorf("%w: %s", err, output)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package upload
+
+import (
+	"strings"
+)
+
+// StatusRules classifies bv output that does not describe a running or finished job.
+// Patterns are matched case-insensitively as substrings.
+type StatusRules struct {
+	NotRunning []string // Error output meaning no upload is running (not a command failure)
+	NotFound   []string // Output meaning the node has no upload job at all (never uploaded)
+	IdleOutput []string // Successful output meaning no upload job is active
+}
+
+// DefaultStatusRules returns the bv wordings recognized without configuration
+func DefaultStatusRules() StatusRules {
+	return StatusRules{
+		NotRunning: []string{
+			"job 'upload' not found",
+			"unknown status",
+			"job_status failed",
+		},
+		NotFound: []string{
+			"job 'upload' not found",
+			"no job",
+			"no upload",
+			"not found",
+		},
+		IdleOutput: []string{
+			"no job",
+			"no upload",
+			"not found",
+			"job 'upload' not found",
+			"unknown status",
+			"job_status failed",
+		},
+	}
+}
+
+// NewStatusRules builds rules from configured patterns. Not-running patterns apply to
+// error and idle output; not-found patterns also imply not running. The patterns
+// extend the defaults unless replaceDefaults is set.
+func NewStatusRules(notRunning, notFound []string, replaceDefaults bool) StatusRules {
+	rules := DefaultStatusRules()
+	if replaceDefaults {
+		rules = StatusRules{}
+	}
+
+	rules.NotRunning = append(rules.NotRunning, notRunning...)
+	rules.NotRunning = append(rules.NotRunning, notFound...)
+	rules.NotFound = append(rules.NotFound, notFound...)
+	rules.IdleOutput = append(rules.IdleOutput, notRunning...)
+	rules.IdleOutput = append(rules.IdleOutput, notFound...)
+
+	return rules
+}
+
+// matchesAny reports whether any of the texts contains any of the patterns
+func matchesAny(patterns []string, texts ...string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == "" {
+			continue
+		}
+		for _, text := range texts {
+			if strings.Contains(strings.ToLower(text), pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TriggerType identifies what started an upload
+type TriggerType string
+
+// Supported trigger types
+const (
+	TriggerScheduled TriggerType = "scheduled" // Started by a node's cron schedule
+	TriggerManual    TriggerType = "manual"    // Started by an operator from the CLI
+	TriggerExternal  TriggerType = "external"  // Started outside the daemon and discovered by the monitor
+	TriggerAPI       TriggerType = "api"       // Started through an API request
+	TriggerQueue     TriggerType = "queue"     // Started when a queued upload was dequeued
+	TriggerRetry     TriggerType = "retry"     // Started to retry a failed upload
+)
+
+// TriggerTypes returns all supported trigger types
+func TriggerTypes() []TriggerType {
+	return []TriggerType{TriggerScheduled, TriggerManual, TriggerExternal, TriggerAPI, TriggerQueue, TriggerRetry}
+}
+
+// ParseTriggerType validates a trigger type name
+func ParseTriggerType(value string) (TriggerType, error) {
+	for _, t := range TriggerTypes() {
+		if string(t) == value {
+			return t, nil
+		}
+	}
+
+	names := make([]string, 0, len(TriggerTypes()))
+	for _, t := range TriggerTypes() {
+		names = append(names, string(t))
+	}
+	return "", fmt.Errorf("unknown trigger type '%s' (expected one of %s)", value, strings.Join(names, ", "))
+}
+
+// Trigger describes what started an upload. Metadata records who or why, e.g.
+// {"user": "alice", "reason": "pre-upgrade snapshot"}, and is stored as JSON.
+type Trigger struct {
+	Type     TriggerType
+	Metadata map[string]interface{}
+	// Attempt is which attempt at the snapshot the upload is (0 for the first) and RetryOf
+	// the failed upload a retry starts again
+	Attempt int
+	RetryOf *int64
+}
+
+// Validate checks that the trigger type is supported and the metadata is JSON-encodable
+func (t Trigger) Validate() error {
+	if _, err := ParseTriggerType(string(t.Type)); err != nil {
+		return err
+	}
+	if t.Metadata != nil {
+		if _, err := json.Marshal(t.Metadata); err != nil {
+			return fmt.Errorf("invalid trigger metadata: %w", err)
+		}
+	}
+	return nil
+}
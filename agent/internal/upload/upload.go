@@ -2,11 +2,19 @@ package upload
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/bvclient"
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/events"
+	"github.com/nodexeus/agent/internal/executor"
+	"github.com/nodexeus/agent/internal/tracing"
 	"github.com/sirupsen/logrus"
 )
 
@@ -27,7 +35,8 @@ type Upload struct {
 	StartedAt         time.Time
 	CompletedAt       *time.Time
 	Status            string
-	TriggerType       string
+	TriggerType       TriggerType
+	TriggerMetadata   JSONB // Who or what triggered the upload and why
 	ErrorMessage      *string
 	ProtocolData      JSONB      // Blockchain state when upload started
 	ProgressPercent   *float64   // Current progress percentage
@@ -35,29 +44,91 @@ type Upload struct {
 	ChunksTotal       *int       // Total chunks in upload
 	LastProgressCheck *time.Time // When progress was last updated
 	CompletionMessage *string    // Success/completion message
+	BaseUploadID      *int64     // Snapshot an incremental upload was taken against (nil for full uploads)
+	Agent             *string    // Host of the snapperd that recorded the upload
+	TraceParent       *string    // W3C traceparent of the span that recorded the upload, continued by monitor checks
+	Tenant            string     // Tenant of the node (empty for nodes of no tenant)
+	Attempt           int        // Which attempt at the snapshot the upload is (0 for the first)
+	RetryOf           *int64     // Failed upload a retry starts again
 }
 
 // Database interface for upload persistence
 type Database interface {
-	CreateUpload(ctx context.Context, upload Upload) (int64, error)
+	// CreateUploadIfNotRunning atomically creates the upload unless the node has a running
+	// one, returning that upload's ID and false instead
+	CreateUploadIfNotRunning(ctx context.Context, upload Upload) (int64, bool, error)
 	UpdateUpload(ctx context.Context, upload Upload) error
 	UpdateUploadProgress(ctx context.Context, uploadID int64, status string, progressPercent *float64, chunksCompleted *int, chunksTotal *int, lastProgressCheck *time.Time) error
 	UpdateUploadCompletion(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error
 	GetRunningUploadForNode(ctx context.Context, nodeName string) (*Upload, error)
 	GetLatestCompletedUploadForNode(ctx context.Context, nodeName string) (*Upload, error)
+	RecordProgressSample(ctx context.Context, uploadID int64, sample analytics.Sample) error
+	GetProgressSamples(ctx context.Context, uploadID int64, since time.Time) ([]analytics.Sample, error)
+	UpdateUploadThroughput(ctx context.Context, uploadID int64, chunksPerMinute *float64, estimatedCompletion *time.Time) error
+	SetUploadDetectionLag(ctx context.Context, uploadID int64, finishedAt time.Time, lag time.Duration) error
+	SetUploadCompression(ctx context.Context, uploadID int64, algorithm string, level int, rawBytes, compressedBytes int64) error
+	// RecordStatusOutput stores the raw output when the upload's state differs from the
+	// last one recorded
+	RecordStatusOutput(ctx context.Context, uploadID int64, state, rawOutput string, recordedAt time.Time) (bool, error)
+	SetUploadSize(ctx context.Context, uploadID int64, sizeBytes int64) error
+	// RecordUploadResumed adds the resume of an interrupted upload to the node's action log
+	RecordUploadResumed(ctx context.Context, uploadID int64, nodeName string, resumedAt time.Time) error
 }
 
-// UploadStatus represents the parsed status from the info command
+// ErrNoRunningUpload is returned when an operation requires a running upload and none exists
+var ErrNoRunningUpload = errors.New("no running upload")
+
+// ErrUploadRunning is returned when an upload is started for a node that already has a
+// running upload, for example one started by a concurrent trigger
+var ErrUploadRunning = errors.New("upload already running")
+
+// UploadStatus represents the parsed status from the node's upload engine
 type UploadStatus struct {
 	IsRunning bool
+	NotFound  bool // The engine has no upload for the node (it has never uploaded)
 	Progress  JSONB
+	// StartedAt is when a running upload started, from the timestamp of the engine's
+	// status line; nil when the upload has stopped or the engine reports no timestamp
+	StartedAt *time.Time
+	// RawOutput is the engine output the status was read from, capped at 64KiB. It is
+	// kept out of Progress and stored once per status change.
+	RawOutput string
+	// SizeBytes is the bytes uploaded, for engines that report it
+	SizeBytes *int64
+	// Compression is how a completed upload was compressed, for engines that report it
+	Compression *engine.CompressionReport
+	// Location is where the upload stores the snapshot, for engines that report it
+	Location string
 }
 
-// Manager handles upload operations
+// Manager handles upload operations. Uploads are run by each node's engine: bv by
+// default, or another engine set with SetNodeEngine.
 type Manager struct {
 	executor CommandExecutor
+	bv       *bvclient.Client
 	db       Database
 	logger   *logrus.Logger
+	rules    StatusRules
+
+	defaultEngine engine.Engine
+	enginesMu     sync.RWMutex
+	engines       map[string]engine.Engine   // Node name -> engine, for nodes not using bv
+	executors     map[string]CommandExecutor // Node name -> executor of its commands, for nodes running them in a container
+	bvFlags       map[string][]string        // Node name -> flags added to bv node run upload
+	tenants       map[string]string          // Node name -> tenant the node's uploads are recorded under
+
+	// resumeInterrupted resumes uploads that in-process engines lost to a restart
+	resumeInterrupted bool
+
+	// agent is recorded as the host of the uploads the manager creates
+	agent string
+
+	// Timeouts of bv commands reading an upload job and starting or stopping one (0 = none)
+	bvStatusTimeout time.Duration
+	bvStartTimeout  time.Duration
+
+	// events receives the lifecycle events of the uploads the manager records (nil discards them)
+	events *events.Hub
 }
 
 // NewManager creates a new upload manager
@@ -65,231 +136,273 @@ func NewManager(executor CommandExecutor, db Database, logger *logrus.Logger) *M
 	if logger == nil {
 		logger = logrus.New()
 	}
-	return &Manager{
-		executor: executor,
-		db:       db,
-		logger:   logger,
+	m := &Manager{
+		executor:  executor,
+		bv:        bvclient.New(executor),
+		db:        db,
+		logger:    logger,
+		rules:     DefaultStatusRules(),
+		engines:   make(map[string]engine.Engine),
+		executors: make(map[string]CommandExecutor),
+		bvFlags:   make(map[string][]string),
+		tenants:   make(map[string]string),
+	}
+	m.defaultEngine = &bvEngine{m: m}
+	return m
+}
+
+// SetNodeEngine sets the engine that runs a node's uploads; nil restores the bv engine
+func (m *Manager) SetNodeEngine(nodeName string, e engine.Engine) {
+	m.enginesMu.Lock()
+	defer m.enginesMu.Unlock()
+
+	if e == nil {
+		delete(m.engines, nodeName)
+		return
+	}
+	m.engines[nodeName] = e
+}
+
+// SetNodeExecutor sets the executor that runs a node's hooks, preflight and incremental
+// upload commands, such as one running them in the node's container; nil restores the
+// manager's executor. bv commands always run on the manager's executor.
+func (m *Manager) SetNodeExecutor(nodeName string, exec CommandExecutor) {
+	m.enginesMu.Lock()
+	defer m.enginesMu.Unlock()
+
+	if exec == nil {
+		delete(m.executors, nodeName)
+		return
+	}
+	m.executors[nodeName] = exec
+}
+
+// SetNodeBVFlags sets flags added to the bv command starting a node's upload job, from
+// the node's engine arguments; nil removes them
+func (m *Manager) SetNodeBVFlags(nodeName string, flags []string) {
+	m.enginesMu.Lock()
+	defer m.enginesMu.Unlock()
+
+	if len(flags) == 0 {
+		delete(m.bvFlags, nodeName)
+		return
+	}
+	m.bvFlags[nodeName] = flags
+}
+
+// SetNodeTenant sets the tenant a node's uploads are recorded and published under; an
+// empty tenant removes it
+func (m *Manager) SetNodeTenant(nodeName, tenant string) {
+	m.enginesMu.Lock()
+	defer m.enginesMu.Unlock()
+
+	if tenant == "" {
+		delete(m.tenants, nodeName)
+		return
+	}
+	m.tenants[nodeName] = tenant
+}
+
+// tenantFor returns the tenant of a node's uploads
+func (m *Manager) tenantFor(nodeName string) string {
+	m.enginesMu.RLock()
+	defer m.enginesMu.RUnlock()
+	return m.tenants[nodeName]
+}
+
+// bvFlagsFor returns the flags added to the bv command starting a node's upload job
+func (m *Manager) bvFlagsFor(nodeName string) []string {
+	m.enginesMu.RLock()
+	defer m.enginesMu.RUnlock()
+	return m.bvFlags[nodeName]
+}
+
+// executorFor returns the executor that runs a node's own commands
+func (m *Manager) executorFor(nodeName string) CommandExecutor {
+	m.enginesMu.RLock()
+	defer m.enginesMu.RUnlock()
+
+	if exec, ok := m.executors[nodeName]; ok {
+		return exec
+	}
+	return m.executor
+}
+
+// engineFor returns the engine that runs a node's uploads
+func (m *Manager) engineFor(nodeName string) engine.Engine {
+	m.enginesMu.RLock()
+	defer m.enginesMu.RUnlock()
+
+	if e, ok := m.engines[nodeName]; ok {
+		return e
 	}
+	return m.defaultEngine
+}
+
+// SetResumeInterrupted makes MonitorUpload resume a running upload whose engine lost it
+// to a restart of the daemon, for engines that checkpoint their chunks. Only the daemon
+// enables it: an upload resumed by a CLI command would stop when the command exits.
+func (m *Manager) SetResumeInterrupted(resume bool) {
+	m.resumeInterrupted = resume
+}
+
+// SetAgent sets the host recorded on the uploads the manager creates
+func (m *Manager) SetAgent(agent string) {
+	m.agent = agent
+}
+
+// SetBVOutputFormat sets how bv status output is read: auto, json or text
+func (m *Manager) SetBVOutputFormat(format string) {
+	m.bv.SetFormat(format)
+}
+
+// SetBVTimeouts bounds how long bv commands may run: status for those reading an upload
+// job (its info and logs), start for those starting or stopping one. Since bv commands
+// run one at a time, a hung command would otherwise hold up every node. Zero leaves a
+// class of commands unbounded.
+func (m *Manager) SetBVTimeouts(status, start time.Duration) {
+	m.bvStatusTimeout = status
+	m.bvStartTimeout = start
+}
+
+// SetStatusRules replaces the rules used to classify bv status output
+func (m *Manager) SetStatusRules(rules StatusRules) {
+	m.rules = rules
 }
 
 // CheckUploadStatus checks if an upload is currently running for a node
 func (m *Manager) CheckUploadStatus(ctx context.Context, nodeName string) (*UploadStatus, error) {
+	e := m.engineFor(nodeName)
+	ctx, span := tracing.Start(ctx, "upload.check_status", tracing.String("node", nodeName), tracing.String("engine", e.Name()))
+	defer span.End()
 	m.logger.WithFields(logrus.Fields{
 		"component": "upload",
 		"node":      nodeName,
+		"engine":    e.Name(),
 		"action":    "check_status",
 	}).Debug("Checking upload status")
 
-	// Execute: bv node job <node> info upload
-	stdout, stderr, err := m.executor.Execute(ctx, "bv", "node", "job", nodeName, "info", "upload")
-	if err != nil {
-		// Check if this is a "job not found" type error vs other system errors
-		errorOutput := stderr
-		if errorOutput == "" {
-			errorOutput = stdout
-		}
-
-		lowerError := strings.ToLower(errorOutput)
-		lowerErrMsg := strings.ToLower(err.Error())
-
-		// Only treat specific "job not found" errors as "not running"
-		if strings.Contains(lowerError, "job 'upload' not found") ||
-			strings.Contains(lowerError, "unknown status") ||
-			strings.Contains(lowerError, "job_status failed") ||
-			strings.Contains(lowerErrMsg, "job 'upload' not found") ||
-			strings.Contains(lowerErrMsg, "unknown status") {
-
-			m.logger.WithFields(logrus.Fields{
-				"component": "upload",
-				"node":      nodeName,
-				"error":     err.Error(),
-				"stderr":    stderr,
-			}).Debug("Upload job not found, treating as not running")
-
-			status := &UploadStatus{
-				IsRunning: false,
-				Progress: JSONB{
-					"error":      err.Error(),
-					"stderr":     stderr,
-					"stdout":     stdout,
-					"raw_output": errorOutput,
-				},
-			}
-			return status, nil
-		}
-
-		// For other errors, return the error
-		// Don't assume the upload status based on command execution issues
-		m.logger.WithFields(logrus.Fields{
-			"component": "upload",
-			"node":      nodeName,
-			"error":     err.Error(),
-			"stderr":    stderr,
-		}).Error("Failed to check upload status")
-		return nil, fmt.Errorf("failed to check upload status: %w", err)
-	}
-
-	// Parse the status from stdout
-	status, err := m.parseUploadStatus(stdout)
+	engineStatus, err := e.Status(ctx, nodeName)
 	if err != nil {
-		m.logger.WithFields(logrus.Fields{
-			"component": "upload",
-			"node":      nodeName,
-			"error":     err.Error(),
-			"stdout":    stdout,
-		}).Error("Failed to parse upload status")
-		return nil, fmt.Errorf("failed to parse upload status: %w", err)
+		span.RecordError(err)
+		return nil, err
 	}
+	status := uploadStatus(engineStatus)
+	span.SetAttributes(tracing.Bool("upload.running", status.IsRunning), tracing.Bool("upload.not_found", status.NotFound))
 
 	m.logger.WithFields(logrus.Fields{
 		"component":  "upload",
 		"node":       nodeName,
+		"engine":     e.Name(),
 		"is_running": status.IsRunning,
 	}).Info("Upload status checked")
 
 	return status, nil
 }
 
-// parseUploadStatus parses the output from the upload info command
-// Expected format from `bv node job <node> info upload`:
-// status:           2025-12-07 13:41:43 UTC| Finished with exit code 0 and message `...`
-// progress:         100.00% (3248/3248 multi-client upload completed)
-// restart_count:    0
-// upgrade_blocking: true
-// logs:             <empty>
+// parseUploadStatus parses the text output from the bv upload info command
 func (m *Manager) parseUploadStatus(output string) (*UploadStatus, error) {
-	output = strings.TrimSpace(output)
+	info, err := bvclient.ParseJobInfo(output, bvclient.FormatText)
+	if err != nil {
+		return nil, err
+	}
+	return uploadStatus((&bvEngine{m: m}).status(info)), nil
+}
 
+// Bounds on the engine output kept with an upload's progress, so the megabytes of logs
+// some bv failure modes print stay out of the progress JSON, the upload's status line
+// and notifications. Longer output keeps its start and end around a truncation marker.
+const (
+	maxRawOutputBytes = 64 << 10 // The raw output stored at each status change
+	maxFieldBytes     = 4 << 10  // Every other field, including the status line
+)
+
+// uploadStatus converts an engine's status to an UploadStatus. The progress holds the
+// reported fields as strings, in the same keys for every engine, bv version and output
+// format.
+func uploadStatus(engineStatus *engine.Status) *UploadStatus {
 	status := &UploadStatus{
-		Progress: make(JSONB),
-	}
-
-	// Check for empty output or no job indicators
-	lowerOutput := strings.ToLower(output)
-	if output == "" ||
-		strings.Contains(lowerOutput, "no job") ||
-		strings.Contains(lowerOutput, "no upload") ||
-		strings.Contains(lowerOutput, "not found") ||
-		strings.Contains(lowerOutput, "job 'upload' not found") ||
-		strings.Contains(lowerOutput, "unknown status") ||
-		strings.Contains(lowerOutput, "job_status failed") {
-		status.IsRunning = false
-		status.Progress["raw_output"] = output
-		return status, nil
-	}
-
-	// Parse the key-value format
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+		IsRunning:   engineStatus.Running,
+		NotFound:    engineStatus.NotFound,
+		Progress:    make(JSONB),
+		RawOutput:   executor.TruncateOutput(engineStatus.Raw, maxRawOutputBytes),
+		SizeBytes:   engineStatus.SizeBytes,
+		Compression: engineStatus.Compression,
+		Location:    engineStatus.Location,
+	}
 
-		// Split on first colon
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+	for key, value := range engineStatus.Fields {
+		status.Progress[key] = executor.TruncateOutput(value, maxFieldBytes)
+	}
+	if engineStatus.Status != "" {
+		status.Progress["status"] = executor.TruncateOutput(engineStatus.Status, maxFieldBytes)
+	}
+	if engineStatus.StatusTime != nil {
+		status.Progress["started_at"] = engineStatus.StatusTime.Format(time.RFC3339)
+		status.Progress["actual_status"] = engineStatus.State
+		if engineStatus.Running {
+			startedAt := *engineStatus.StatusTime
+			status.StartedAt = &startedAt
 		}
+	}
+	if engineStatus.Progress != "" {
+		status.Progress["progress"] = engineStatus.Progress
+	}
+	if engineStatus.ProgressPercent != nil {
+		status.Progress["progress_percent"] = strconv.FormatFloat(clampPercent(*engineStatus.ProgressPercent), 'f', 2, 64)
+	}
+	// Each count is kept when the other is missing, e.g. while the total is unknown
+	if engineStatus.ChunksCompleted != nil {
+		status.Progress["chunks_completed"] = strconv.Itoa(*engineStatus.ChunksCompleted)
+	}
+	if engineStatus.ChunksTotal != nil {
+		status.Progress["chunks_total"] = strconv.Itoa(*engineStatus.ChunksTotal)
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		switch strings.ToLower(key) {
-		case "status":
-			status.Progress["status"] = value
-
-			// Extract started_at timestamp from status line
-			// Format: "2025-12-10 15:18:44 UTC| Running"
-			if strings.Contains(value, "UTC|") {
-				parts := strings.SplitN(value, "UTC|", 2)
-				if len(parts) == 2 {
-					timestampStr := strings.TrimSpace(parts[0]) + " UTC"
-					statusStr := strings.TrimSpace(parts[1])
-
-					// Parse the timestamp
-					if parsedTime, err := time.Parse("2006-01-02 15:04:05 MST", timestampStr); err == nil {
-						status.Progress["started_at"] = parsedTime.Format(time.RFC3339)
-					}
-
-					// Store the actual status
-					status.Progress["actual_status"] = statusStr
-
-					// Check if status indicates running
-					lowerStatusStr := strings.ToLower(statusStr)
-					if strings.Contains(lowerStatusStr, "running") {
-						status.IsRunning = true
-					} else if strings.Contains(lowerStatusStr, "finished") ||
-						strings.Contains(lowerStatusStr, "completed") ||
-						strings.Contains(lowerStatusStr, "failed") ||
-						strings.Contains(lowerStatusStr, "exit code") ||
-						strings.Contains(lowerStatusStr, "unknown") ||
-						strings.Contains(lowerStatusStr, "error") {
-						status.IsRunning = false
-					}
-				}
-			} else {
-				// Fallback for different format
-				lowerValue := strings.ToLower(value)
-				if strings.Contains(lowerValue, "running") {
-					status.IsRunning = true
-				} else if strings.Contains(lowerValue, "finished") ||
-					strings.Contains(lowerValue, "completed") ||
-					strings.Contains(lowerValue, "failed") ||
-					strings.Contains(lowerValue, "exit code") ||
-					strings.Contains(lowerValue, "unknown") ||
-					strings.Contains(lowerValue, "error") {
-					status.IsRunning = false
-				}
-			}
-
-		case "progress":
-			status.Progress["progress"] = value
-			// Extract percentage if present
-			if strings.Contains(value, "%") {
-				// Parse format like "100.00% (3248/3248 ...)"
-				percentIdx := strings.Index(value, "%")
-				if percentIdx > 0 {
-					percentStr := strings.TrimSpace(value[:percentIdx])
-					status.Progress["progress_percent"] = percentStr
-				}
-
-				// Extract chunk counts if present (e.g., "3100/3248")
-				if strings.Contains(value, "(") && strings.Contains(value, "/") {
-					startIdx := strings.Index(value, "(")
-					endIdx := strings.Index(value, ")")
-					if startIdx > 0 && endIdx > startIdx {
-						chunkInfo := value[startIdx+1 : endIdx]
-						slashIdx := strings.Index(chunkInfo, "/")
-						if slashIdx > 0 {
-							completed := strings.TrimSpace(chunkInfo[:slashIdx])
-							remaining := chunkInfo[slashIdx+1:]
-							spaceIdx := strings.Index(remaining, " ")
-							if spaceIdx > 0 {
-								remaining = remaining[:spaceIdx]
-							}
-							status.Progress["chunks_completed"] = completed
-							status.Progress["chunks_total"] = strings.TrimSpace(remaining)
-						}
-					}
-				}
-			}
-
-		case "restart_count":
-			status.Progress["restart_count"] = value
-
-		case "upgrade_blocking":
-			status.Progress["upgrade_blocking"] = value
+	return status
+}
 
-		case "logs":
-			status.Progress["logs"] = value
-		}
+// clampPercent bounds a reported percentage to 0-100. Engines estimating the total can
+// report more than 100%, which the progress_percent column cannot hold past 999.99.
+func clampPercent(percent float64) float64 {
+	switch {
+	case percent < 0:
+		return 0
+	case percent > 100:
+		return 100
 	}
+	return percent
+}
 
-	// Store raw output for debugging
-	status.Progress["raw_output"] = output
+// recordStatusOutput stores the upload's raw output when its state has changed since the
+// last check, so the output is kept once per transition rather than with every check
+func (m *Manager) recordStatusOutput(ctx context.Context, uploadID int64, nodeName string, status *UploadStatus) {
+	state, ok := status.Progress["actual_status"].(string)
+	if !ok {
+		state, _ = status.Progress["status"].(string)
+	}
+	if state == "" {
+		return
+	}
 
-	return status, nil
+	recorded, err := m.db.RecordStatusOutput(ctx, uploadID, state, status.RawOutput, time.Now())
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"upload_id": uploadID,
+			"error":     err.Error(),
+		}).Warn("Failed to record upload status output")
+		return
+	}
+	if recorded {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"upload_id": uploadID,
+			"state":     state,
+		}).Debug("Recorded upload status output")
+	}
 }
 
 // extractProgressData extracts structured progress data from parsed status
@@ -341,29 +454,30 @@ func parseInt(s string) (int, error) {
 }
 
 // InitiateUploadWithProtocolData starts a new upload for a node with protocol data
-func (m *Manager) InitiateUploadWithProtocolData(ctx context.Context, nodeName string, triggerType string, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
+func (m *Manager) InitiateUploadWithProtocolData(ctx context.Context, nodeName string, trigger Trigger, protocol string, nodeType string, protocolData map[string]interface{}) (int64, error) {
 	m.logger.WithFields(logrus.Fields{
 		"component":    "upload",
 		"node":         nodeName,
 		"protocol":     protocol,
-		"trigger_type": triggerType,
+		"trigger_type": trigger.Type,
 		"action":       "initiate_with_protocol_data",
 	}).Info("Initiating upload with protocol data")
 
 	// Create upload record in database FIRST to prevent race condition with UploadMonitorJob
 	// This ensures the upload is tracked before the actual upload command starts,
 	// preventing the monitor from "discovering" it as an external upload.
-	uploadID, err := m.CreateUploadRecord(ctx, nodeName, protocol, nodeType, triggerType, protocolData)
+	uploadID, err := m.startUploadRecord(ctx, nodeName, protocol, nodeType, trigger, protocolData, nil)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create upload record: %w", err)
 	}
 
-	// Execute: bv node run upload <node>
-	stdout, stderr, err := m.executor.Execute(ctx, "bv", "node", "run", "upload", nodeName)
-	if err != nil {
+	e := m.engineFor(nodeName)
+	if err := m.startEngineUpload(ctx, e, nodeName, uploadID); err != nil {
+		stdout, stderr := commandOutput(err)
 		m.logger.WithFields(logrus.Fields{
 			"component": "upload",
 			"node":      nodeName,
+			"engine":    e.Name(),
 			"error":     err.Error(),
 			"stderr":    stderr,
 			"stdout":    stdout,
@@ -373,6 +487,7 @@ func (m *Manager) InitiateUploadWithProtocolData(ctx context.Context, nodeName s
 		completionMsg := fmt.Sprintf("Failed to start upload: %s", err.Error())
 		now := time.Now()
 		_ = m.db.UpdateUploadCompletion(ctx, uploadID, now, "failed", &completionMsg, nil)
+		m.publishFinished(uploadID, nodeName, "failed", &completionMsg, now)
 		return 0, fmt.Errorf("failed to initiate upload: %w", err)
 	}
 
@@ -380,14 +495,29 @@ func (m *Manager) InitiateUploadWithProtocolData(ctx context.Context, nodeName s
 		"component":     "upload",
 		"node":          nodeName,
 		"upload_id":     uploadID,
+		"engine":        e.Name(),
 		"protocol_data": protocolData,
 	}).Info("Upload initiated successfully with protocol data")
 
 	return uploadID, nil
 }
 
+// startEngineUpload starts a recorded upload with the node's engine, traced as upload.start
+func (m *Manager) startEngineUpload(ctx context.Context, e engine.Engine, nodeName string, uploadID int64) error {
+	ctx, span := tracing.Start(ctx, "upload.start",
+		tracing.String("node", nodeName),
+		tracing.String("engine", e.Name()),
+		tracing.Int64("upload.id", uploadID),
+	)
+	defer span.End()
+
+	err := e.StartUpload(ctx, nodeName)
+	span.RecordError(err)
+	return err
+}
+
 // InitiateUpload starts a new upload for a node (legacy method)
-func (m *Manager) InitiateUpload(ctx context.Context, nodeName string, triggerType string) (int64, error) {
+func (m *Manager) InitiateUpload(ctx context.Context, nodeName string, triggerType TriggerType) (int64, error) {
 	m.logger.WithFields(logrus.Fields{
 		"component":    "upload",
 		"node":         nodeName,
@@ -401,17 +531,18 @@ func (m *Manager) InitiateUpload(ctx context.Context, nodeName string, triggerTy
 		"legacy": true,
 	}
 
-	uploadID, err := m.CreateUploadRecord(ctx, nodeName, "unknown", "unknown", triggerType, protocolData)
+	uploadID, err := m.startUploadRecord(ctx, nodeName, "unknown", "unknown", Trigger{Type: triggerType}, protocolData, nil)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create upload record: %w", err)
 	}
 
-	// Execute: bv node run upload <node>
-	stdout, stderr, err := m.executor.Execute(ctx, "bv", "node", "run", "upload", nodeName)
-	if err != nil {
+	e := m.engineFor(nodeName)
+	if err := m.startEngineUpload(ctx, e, nodeName, uploadID); err != nil {
+		_, stderr := commandOutput(err)
 		m.logger.WithFields(logrus.Fields{
 			"component": "upload",
 			"node":      nodeName,
+			"engine":    e.Name(),
 			"error":     err.Error(),
 			"stderr":    stderr,
 			"upload_id": uploadID,
@@ -420,6 +551,7 @@ func (m *Manager) InitiateUpload(ctx context.Context, nodeName string, triggerTy
 		completionMsg := fmt.Sprintf("Failed to start upload: %s", err.Error())
 		now := time.Now()
 		_ = m.db.UpdateUploadCompletion(ctx, uploadID, now, "failed", &completionMsg, nil)
+		m.publishFinished(uploadID, nodeName, "failed", &completionMsg, now)
 		return 0, fmt.Errorf("failed to initiate upload: %w", err)
 	}
 
@@ -427,8 +559,7 @@ func (m *Manager) InitiateUpload(ctx context.Context, nodeName string, triggerTy
 		"component": "upload",
 		"node":      nodeName,
 		"upload_id": uploadID,
-		"stdout":    stdout,
-		"stderr":    stderr,
+		"engine":    e.Name(),
 	}).Info("Upload initiated successfully")
 
 	return uploadID, nil
@@ -436,6 +567,14 @@ func (m *Manager) InitiateUpload(ctx context.Context, nodeName string, triggerTy
 
 // MonitorUploadProgress checks and updates the progress of an upload
 func (m *Manager) MonitorUploadProgress(ctx context.Context, uploadID int64, nodeName string) error {
+	_, err := m.MonitorUpload(ctx, uploadID, nodeName)
+	return err
+}
+
+// MonitorUpload checks an upload's status, records its progress while it runs and its
+// outcome once it has finished. This is the single completion detection path used by
+// the scheduler's monitor job and the CLI.
+func (m *Manager) MonitorUpload(ctx context.Context, uploadID int64, nodeName string) (CompletionResult, error) {
 	m.logger.WithFields(logrus.Fields{
 		"component": "upload",
 		"node":      nodeName,
@@ -446,45 +585,28 @@ func (m *Manager) MonitorUploadProgress(ctx context.Context, uploadID int64, nod
 	// Check current status
 	status, err := m.CheckUploadStatus(ctx, nodeName)
 	if err != nil {
-		return fmt.Errorf("failed to check upload status: %w", err)
+		return CompletionResult{}, fmt.Errorf("failed to check upload status: %w", err)
 	}
 
-	// Extract structured progress data
-	progressPercent, chunksCompleted, chunksTotal := m.extractProgressData(status.Progress)
-
-	// Update progress in the main upload record
-	now := time.Now()
-
-	// If upload is no longer running, mark as completed
-	if !status.IsRunning {
-		completedAt := time.Now()
-
-		// Extract completion message
-		var completionMessage *string
-		if statusMsg, ok := status.Progress["status"].(string); ok {
-			completionMessage = &statusMsg
+	// An in-process engine has no upload after a restart; one it checkpointed is resumed
+	// under the same record instead of being recorded as failed
+	if status.NotFound && m.resumeInterrupted {
+		resumed, err := m.resumeUpload(ctx, uploadID, nodeName)
+		if err != nil {
+			status.Progress["status"] = fmt.Sprintf("Failed: could not resume the interrupted upload: %v", err)
+		} else if resumed {
+			return CompletionResult{Outcome: OutcomeRunning}, nil
 		}
+	}
 
-		// Update completion data
-		if err := m.db.UpdateUploadCompletion(ctx, uploadID, completedAt, "completed", completionMessage, nil); err != nil {
-			m.logger.WithFields(logrus.Fields{
-				"component": "upload",
-				"node":      nodeName,
-				"upload_id": uploadID,
-				"error":     err.Error(),
-			}).Error("Failed to update upload completion")
-			return fmt.Errorf("failed to update upload completion: %w", err)
-		}
+	m.recordStatusOutput(ctx, uploadID, nodeName, status)
 
-		m.logger.WithFields(logrus.Fields{
-			"component":          "upload",
-			"node":               nodeName,
-			"upload_id":          uploadID,
-			"total_chunks":       chunksTotal,
-			"completion_message": completionMessage,
-		}).Info("Upload completed")
-	} else {
+	// Extract structured progress data
+	progressPercent, chunksCompleted, chunksTotal := m.extractProgressData(status.Progress)
+
+	if status.IsRunning {
 		// Upload is still running - update progress only
+		now := time.Now()
 		if err := m.db.UpdateUploadProgress(ctx, uploadID, "running", progressPercent, chunksCompleted, chunksTotal, &now); err != nil {
 			m.logger.WithFields(logrus.Fields{
 				"component": "upload",
@@ -492,9 +614,12 @@ func (m *Manager) MonitorUploadProgress(ctx context.Context, uploadID int64, nod
 				"upload_id": uploadID,
 				"error":     err.Error(),
 			}).Error("Failed to update upload progress")
-			return fmt.Errorf("failed to update upload progress: %w", err)
+			return CompletionResult{}, fmt.Errorf("failed to update upload progress: %w", err)
 		}
 
+		estimate := m.updateThroughput(ctx, uploadID, nodeName, chunksCompleted, chunksTotal, now)
+		m.publishProgress(uploadID, nodeName, progressPercent, chunksCompleted, chunksTotal, estimate)
+
 		m.logger.WithFields(logrus.Fields{
 			"component":        "upload",
 			"node":             nodeName,
@@ -503,84 +628,283 @@ func (m *Manager) MonitorUploadProgress(ctx context.Context, uploadID int64, nod
 			"chunks_completed": chunksCompleted,
 			"chunks_total":     chunksTotal,
 		}).Debug("Upload progress updated")
-	}
 
-	return nil
-}
-
-// MonitorUploadProgressWithNotification checks and updates the progress of an upload, returning completion status
-func (m *Manager) MonitorUploadProgressWithNotification(ctx context.Context, uploadID int64, nodeName string) (bool, error) {
-	m.logger.WithFields(logrus.Fields{
-		"component": "upload",
-		"node":      nodeName,
-		"upload_id": uploadID,
-		"action":    "monitor_progress_with_notification",
-	}).Debug("Monitoring upload progress with notification support")
+		return CompletionResult{Outcome: OutcomeRunning}, nil
+	}
 
-	// Check current status
-	status, err := m.CheckUploadStatus(ctx, nodeName)
-	if err != nil {
-		return false, fmt.Errorf("failed to check upload status: %w", err)
+	// Upload is no longer running - classify and record how it ended
+	result := CompletionResult{Outcome: OutcomeSuccess}
+	if statusMsg, ok := status.Progress["status"].(string); ok {
+		result.Message = &statusMsg
+		result.Outcome = classifyCompletion(statusMsg)
 	}
 
-	// Extract structured progress data
-	progressPercent, chunksCompleted, chunksTotal := m.extractProgressData(status.Progress)
+	var completionMessage, errorMessage *string
+	if result.Outcome == OutcomeSuccess {
+		completionMessage = result.Message
+	} else {
+		errorMessage = result.Message
+	}
 
-	// Update progress in the main upload record
 	now := time.Now()
-	completed := false
-
-	// If upload is no longer running, mark as completed
-	if !status.IsRunning {
-		completedAt := time.Now()
-		completed = true
+	if err := m.db.UpdateUploadCompletion(ctx, uploadID, now, result.RecordStatus(), completionMessage, errorMessage); err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"upload_id": uploadID,
+			"error":     err.Error(),
+		}).Error("Failed to update upload completion")
+		return CompletionResult{}, fmt.Errorf("failed to update upload completion: %w", err)
+	}
+	m.publishFinished(uploadID, nodeName, result.RecordStatus(), result.Message, now)
 
-		// Extract completion message
-		var completionMessage *string
-		if statusMsg, ok := status.Progress["status"].(string); ok {
-			completionMessage = &statusMsg
+	// bv timestamps the final status line, which measures how long detection took
+	if finishedAt, ok := parseStatusTime(status.Progress); ok {
+		lag := now.Sub(finishedAt)
+		if lag < 0 {
+			lag = 0
 		}
-
-		// Update completion data
-		if err := m.db.UpdateUploadCompletion(ctx, uploadID, completedAt, "completed", completionMessage, nil); err != nil {
+		result.FinishedAt = &finishedAt
+		result.DetectionLag = &lag
+		if err := m.db.SetUploadDetectionLag(ctx, uploadID, finishedAt, lag); err != nil {
 			m.logger.WithFields(logrus.Fields{
 				"component": "upload",
 				"node":      nodeName,
 				"upload_id": uploadID,
 				"error":     err.Error(),
-			}).Error("Failed to update upload completion")
-			return false, fmt.Errorf("failed to update upload completion: %w", err)
+			}).Warn("Failed to record upload detection lag")
 		}
+	}
 
-		m.logger.WithFields(logrus.Fields{
-			"component":          "upload",
-			"node":               nodeName,
-			"upload_id":          uploadID,
-			"completion_message": completionMessage,
-		}).Info("Upload completed")
-	} else {
-		// Upload is still running - update progress only
-		if err := m.db.UpdateUploadProgress(ctx, uploadID, "running", progressPercent, chunksCompleted, chunksTotal, &now); err != nil {
+	// Engines that compress report the sizes before and after compression
+	if c := status.Compression; c != nil && result.Outcome == OutcomeSuccess {
+		result.Compression = c
+		if err := m.db.SetUploadCompression(ctx, uploadID, c.Algorithm, c.Level, c.RawBytes, c.CompressedBytes); err != nil {
 			m.logger.WithFields(logrus.Fields{
 				"component": "upload",
 				"node":      nodeName,
 				"upload_id": uploadID,
 				"error":     err.Error(),
-			}).Error("Failed to update upload progress")
-			return false, fmt.Errorf("failed to update upload progress: %w", err)
+			}).Warn("Failed to record upload compression")
+		}
+	}
+
+	// The snapshot's size is the bytes uploaded, or the compressed size when the engine
+	// only reports compression
+	if result.Outcome == OutcomeSuccess {
+		size := status.SizeBytes
+		if size == nil && result.Compression != nil {
+			size = &result.Compression.CompressedBytes
+		}
+		if size != nil {
+			result.SizeBytes = size
+			if err := m.db.SetUploadSize(ctx, uploadID, *size); err != nil {
+				m.logger.WithFields(logrus.Fields{
+					"component": "upload",
+					"node":      nodeName,
+					"upload_id": uploadID,
+					"error":     err.Error(),
+				}).Warn("Failed to record upload size")
+			}
+		}
+	}
+
+	if result.Outcome == OutcomeSuccess {
+		result.Location = status.Location
+	}
+
+	fields := logrus.Fields{
+		"component":          "upload",
+		"node":               nodeName,
+		"upload_id":          uploadID,
+		"outcome":            result.Outcome,
+		"total_chunks":       chunksTotal,
+		"completion_message": result.Message,
+	}
+	if result.DetectionLag != nil {
+		fields["detection_lag"] = result.DetectionLag.Round(time.Second).String()
+	}
+	if result.Compression != nil {
+		fields["compression_ratio"] = result.Compression.Ratio()
+	}
+	if result.SizeBytes != nil {
+		fields["size_bytes"] = *result.SizeBytes
+	}
+	m.logger.WithFields(fields).Info("Upload completed")
+
+	return result, nil
+}
+
+// resumeUpload resumes a node's interrupted upload if its engine checkpointed it
+func (m *Manager) resumeUpload(ctx context.Context, uploadID int64, nodeName string) (bool, error) {
+	resumer, ok := m.engineFor(nodeName).(engine.Resumer)
+	if !ok {
+		return false, nil
+	}
+	resumed, err := resumer.ResumeUpload(ctx, nodeName)
+	if err != nil || !resumed {
+		return false, err
+	}
+
+	// The record keeps its last progress until the next monitor run reads the resumed one
+	logger := m.logger.WithFields(logrus.Fields{
+		"component": "upload",
+		"node":      nodeName,
+		"upload_id": uploadID,
+	})
+	logger.Info("Resumed interrupted upload from its checkpoint")
+	if err := m.db.RecordUploadResumed(ctx, uploadID, nodeName, time.Now()); err != nil {
+		logger.WithField("error", err.Error()).Warn("Failed to record node action")
+	}
+	return true, nil
+}
+
+// updateThroughput records a progress sample and refreshes the upload's throughput and
+// estimated completion from its recent samples, returning the estimate recorded. Failures
+// are logged and do not interrupt monitoring.
+func (m *Manager) updateThroughput(ctx context.Context, uploadID int64, nodeName string, chunksCompleted *int, chunksTotal *int, now time.Time) *analytics.Estimate {
+	if chunksCompleted == nil {
+		return nil
+	}
+
+	logger := m.logger.WithFields(logrus.Fields{
+		"component": "upload",
+		"node":      nodeName,
+		"upload_id": uploadID,
+	})
+
+	sample := analytics.Sample{RecordedAt: now, ChunksCompleted: *chunksCompleted, ChunksTotal: chunksTotal}
+	if err := m.db.RecordProgressSample(ctx, uploadID, sample); err != nil {
+		logger.WithField("error", err.Error()).Warn("Failed to record progress sample")
+		return nil
+	}
+
+	samples, err := m.db.GetProgressSamples(ctx, uploadID, now.Add(-analytics.DefaultWindow))
+	if err != nil {
+		logger.WithField("error", err.Error()).Warn("Failed to get progress samples")
+		return nil
+	}
+
+	estimate, ok := analytics.EstimateThroughput(samples, analytics.DefaultWindow)
+	if !ok {
+		return nil
+	}
+
+	if err := m.db.UpdateUploadThroughput(ctx, uploadID, &estimate.ChunksPerMinute, estimate.EstimatedCompletion); err != nil {
+		logger.WithField("error", err.Error()).Warn("Failed to update upload throughput")
+		return nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"chunks_per_minute":    estimate.ChunksPerMinute,
+		"estimated_completion": estimate.EstimatedCompletion,
+	}).Debug("Upload throughput updated")
+	return &estimate
+}
+
+// TimeoutUpload marks an upload that exceeded its maximum duration as stalled.
+// When cancel is set, the bv upload job is stopped first; a failure to stop the job
+// is returned after the record has been marked stalled.
+func (m *Manager) TimeoutUpload(ctx context.Context, uploadID int64, nodeName string, maxDuration time.Duration, cancel bool) error {
+	errorMessage := fmt.Sprintf("Upload exceeded max duration of %s", maxDuration)
+
+	var cancelErr error
+	if cancel {
+		if err := m.stopUploadJob(ctx, nodeName); err != nil {
+			cancelErr = err
+			errorMessage += "; stopping the upload job failed"
+		} else {
+			errorMessage += "; upload job stopped"
 		}
+	}
 
+	now := time.Now()
+	if err := m.db.UpdateUploadCompletion(ctx, uploadID, now, "stalled", nil, &errorMessage); err != nil {
+		return fmt.Errorf("failed to mark upload as stalled: %w", err)
+	}
+	m.publishFinished(uploadID, nodeName, "stalled", &errorMessage, now)
+
+	m.logger.WithFields(logrus.Fields{
+		"component":    "upload",
+		"node":         nodeName,
+		"upload_id":    uploadID,
+		"max_duration": maxDuration.String(),
+		"cancelled":    cancel && cancelErr == nil,
+	}).Warn("Upload marked as stalled")
+
+	return cancelErr
+}
+
+// CancelUpload stops a node's running upload and marks its record as cancelled.
+// Returns ErrNoRunningUpload when neither bv nor the database has a running upload.
+func (m *Manager) CancelUpload(ctx context.Context, nodeName string, reason string) (int64, error) {
+	runningUpload, err := m.db.GetRunningUploadForNode(ctx, nodeName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for running upload: %w", err)
+	}
+
+	status, err := m.CheckUploadStatus(ctx, nodeName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check upload status: %w", err)
+	}
+
+	if runningUpload == nil && !status.IsRunning {
+		return 0, ErrNoRunningUpload
+	}
+
+	if status.IsRunning {
+		if err := m.stopUploadJob(ctx, nodeName); err != nil {
+			return 0, err
+		}
+	}
+
+	var uploadID int64
+	if runningUpload != nil {
+		uploadID = runningUpload.ID
+		errorMessage := fmt.Sprintf("Upload cancelled: %s", reason)
+		now := time.Now()
+		if err := m.db.UpdateUploadCompletion(ctx, uploadID, now, "cancelled", nil, &errorMessage); err != nil {
+			return uploadID, fmt.Errorf("failed to mark upload as cancelled: %w", err)
+		}
+		m.publishFinished(uploadID, nodeName, "cancelled", &errorMessage, now)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"component": "upload",
+		"node":      nodeName,
+		"upload_id": uploadID,
+		"reason":    reason,
+	}).Info("Upload cancelled")
+
+	return uploadID, nil
+}
+
+// stopUploadJob stops the node's running upload through its engine
+func (m *Manager) stopUploadJob(ctx context.Context, nodeName string) error {
+	e := m.engineFor(nodeName)
+	if err := e.Cancel(ctx, nodeName); err != nil {
+		stdout, stderr := commandOutput(err)
 		m.logger.WithFields(logrus.Fields{
-			"component":        "upload",
-			"node":             nodeName,
-			"upload_id":        uploadID,
-			"progress_percent": progressPercent,
-			"chunks_completed": chunksCompleted,
-			"chunks_total":     chunksTotal,
-		}).Debug("Upload progress updated")
+			"component": "upload",
+			"node":      nodeName,
+			"engine":    e.Name(),
+			"stdout":    stdout,
+			"stderr":    stderr,
+			"error":     err.Error(),
+		}).Error("Failed to stop upload job")
+		return fmt.Errorf("failed to stop upload job: %w", err)
 	}
 
-	return completed, nil
+	return nil
+}
+
+// commandOutput returns the output of a failed engine command, when the error carries it
+func commandOutput(err error) (stdout, stderr string) {
+	var cmdErr *bvclient.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Stdout, cmdErr.Stderr
+	}
+	return "", ""
 }
 
 // ShouldSkipUpload checks if an upload should be skipped (already running)
@@ -618,41 +942,46 @@ func (m *Manager) ShouldSkipUpload(ctx context.Context, nodeName string) (bool,
 }
 
 // CreateUploadRecord creates a new upload record, checking for existing running uploads first
-func (m *Manager) CreateUploadRecord(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}) (int64, error) {
-	return m.CreateUploadRecordWithProgress(ctx, nodeName, protocol, nodeType, triggerType, protocolData, nil)
+func (m *Manager) CreateUploadRecord(ctx context.Context, nodeName, protocol, nodeType string, trigger Trigger, protocolData map[string]interface{}) (int64, error) {
+	return m.CreateUploadRecordWithProgress(ctx, nodeName, protocol, nodeType, trigger, protocolData, nil, nil)
 }
 
-// CreateUploadRecordWithProgress creates a new upload record with separate protocol data and progress data
-func (m *Manager) CreateUploadRecordWithProgress(ctx context.Context, nodeName, protocol, nodeType, triggerType string, protocolData map[string]interface{}, progressData map[string]interface{}) (int64, error) {
-	// Check if there's already a running upload for this node
-	existingUpload, err := m.db.GetRunningUploadForNode(ctx, nodeName)
+// CreateUploadRecordWithProgress creates a new upload record with separate protocol data and
+// progress data. An upload found already running, such as one started outside the daemon,
+// is recorded as started at startedAt, its engine's start time; nil records it as
+// starting now.
+func (m *Manager) CreateUploadRecordWithProgress(ctx context.Context, nodeName, protocol, nodeType string, trigger Trigger, protocolData map[string]interface{}, progressData map[string]interface{}, startedAt *time.Time) (int64, error) {
+	uploadID, _, err := m.createUploadRecord(ctx, nodeName, protocol, nodeType, trigger, protocolData, progressData, startedAt, nil)
+	return uploadID, err
+}
+
+// startUploadRecord creates the record of an upload about to be started. It returns
+// ErrUploadRunning when the node already has a running upload, so the caller does not
+// start a second one.
+func (m *Manager) startUploadRecord(ctx context.Context, nodeName, protocol, nodeType string, trigger Trigger, protocolData map[string]interface{}, baseUploadID *int64) (int64, error) {
+	uploadID, created, err := m.createUploadRecord(ctx, nodeName, protocol, nodeType, trigger, protocolData, nil, nil, baseUploadID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check for existing upload: %w", err)
+		return 0, err
+	}
+	if !created {
+		return 0, fmt.Errorf("%w for node %s (upload %d)", ErrUploadRunning, nodeName, uploadID)
 	}
+	return uploadID, nil
+}
 
-	if existingUpload != nil {
-		m.logger.WithFields(logrus.Fields{
-			"component": "upload",
-			"node":      nodeName,
-			"upload_id": existingUpload.ID,
-		}).Info("Upload already exists for node, using existing record")
-		return existingUpload.ID, nil
+// createUploadRecord creates a new upload record, linked to its base snapshot when
+// incremental, started at engineStartedAt when given and otherwise now. The record is
+// only created when the node has no running upload, checked atomically under the node's
+// database lock; otherwise the running upload's ID is returned with created false.
+func (m *Manager) createUploadRecord(ctx context.Context, nodeName, protocol, nodeType string, trigger Trigger, protocolData map[string]interface{}, progressData map[string]interface{}, engineStartedAt *time.Time, baseUploadID *int64) (int64, bool, error) {
+	if err := trigger.Validate(); err != nil {
+		return 0, false, err
 	}
 
-	// Extract started_at from progress data if available, otherwise use current time
-	var startedAt time.Time
-	if progressData != nil {
-		if startedAtStr, ok := progressData["started_at"].(string); ok {
-			if parsedTime, err := time.Parse(time.RFC3339, startedAtStr); err == nil {
-				startedAt = parsedTime
-			} else {
-				startedAt = time.Now()
-			}
-		} else {
-			startedAt = time.Now()
-		}
-	} else {
-		startedAt = time.Now()
+	// A start time ahead of the host clock is skew, not a start, and is not kept
+	startedAt := time.Now()
+	if engineStartedAt != nil && !engineStartedAt.IsZero() && engineStartedAt.Before(startedAt) {
+		startedAt = *engineStartedAt
 	}
 
 	// Extract progress data from progress data (not protocol data)
@@ -694,22 +1023,40 @@ func (m *Manager) CreateUploadRecordWithProgress(ctx context.Context, nodeName,
 		NodeType:          nodeType,
 		StartedAt:         startedAt,
 		Status:            "running",
-		TriggerType:       triggerType,
+		TriggerType:       trigger.Type,
+		TriggerMetadata:   JSONB(trigger.Metadata),
 		ProtocolData:      JSONB(protocolData),
 		ProgressPercent:   progressPercent,
 		ChunksCompleted:   chunksCompleted,
 		ChunksTotal:       chunksTotal,
 		LastProgressCheck: lastProgressCheck,
+		BaseUploadID:      baseUploadID,
+		TraceParent:       tracing.TraceParent(ctx),
+		Tenant:            m.tenantFor(nodeName),
+		Attempt:           trigger.Attempt,
+		RetryOf:           trigger.RetryOf,
+	}
+	if m.agent != "" {
+		upload.Agent = &m.agent
 	}
 
-	uploadID, err := m.db.CreateUpload(ctx, upload)
+	uploadID, created, err := m.db.CreateUploadIfNotRunning(ctx, upload)
 	if err != nil {
 		m.logger.WithFields(logrus.Fields{
 			"component": "upload",
 			"node":      nodeName,
 			"error":     err.Error(),
 		}).Error("Failed to create upload record")
-		return 0, fmt.Errorf("failed to create upload record: %w", err)
+		return 0, false, fmt.Errorf("failed to create upload record: %w", err)
+	}
+
+	if !created {
+		m.logger.WithFields(logrus.Fields{
+			"component": "upload",
+			"node":      nodeName,
+			"upload_id": uploadID,
+		}).Info("Upload already exists for node, using existing record")
+		return uploadID, false, nil
 	}
 
 	m.logger.WithFields(logrus.Fields{
@@ -721,6 +1068,7 @@ func (m *Manager) CreateUploadRecordWithProgress(ctx context.Context, nodeName,
 		"chunks_completed": chunksCompleted,
 		"chunks_total":     chunksTotal,
 	}).Info("Created new upload record")
+	m.publishStarted(uploadID, upload)
 
-	return uploadID, nil
+	return uploadID, true, nil
 }
@@ -3,9 +3,17 @@ package upload
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/nodexeus/agent/internal/analytics"
+	"github.com/nodexeus/agent/internal/engine"
+	"github.com/nodexeus/agent/internal/events"
+	"github.com/nodexeus/agent/internal/executor"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,13 +36,30 @@ type mockDatabase struct {
 	updateUploadProgressFunc    func(ctx context.Context, uploadID int64, status string, progressPercent *float64, chunksCompleted *int, chunksTotal *int, lastProgressCheck *time.Time) error
 	updateUploadCompletionFunc  func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error
 	getRunningUploadForNodeFunc func(ctx context.Context, nodeName string) (*Upload, error)
+	recordProgressSampleFunc    func(ctx context.Context, uploadID int64, sample analytics.Sample) error
+	getProgressSamplesFunc      func(ctx context.Context, uploadID int64, since time.Time) ([]analytics.Sample, error)
+	updateUploadThroughputFunc  func(ctx context.Context, uploadID int64, chunksPerMinute *float64, estimatedCompletion *time.Time) error
+	setUploadDetectionLagFunc   func(ctx context.Context, uploadID int64, finishedAt time.Time, lag time.Duration) error
+	setUploadCompressionFunc    func(ctx context.Context, uploadID int64, algorithm string, level int, rawBytes, compressedBytes int64) error
+	recordStatusOutputFunc      func(ctx context.Context, uploadID int64, state, rawOutput string, recordedAt time.Time) (bool, error)
+	setUploadSizeFunc           func(ctx context.Context, uploadID int64, sizeBytes int64) error
+	recordUploadResumedFunc     func(ctx context.Context, uploadID int64, nodeName string, resumedAt time.Time) error
 }
 
-func (m *mockDatabase) CreateUpload(ctx context.Context, upload Upload) (int64, error) {
+// CreateUploadIfNotRunning checks getRunningUploadForNodeFunc, then creates with createUploadFunc
+func (m *mockDatabase) CreateUploadIfNotRunning(ctx context.Context, upload Upload) (int64, bool, error) {
+	running, err := m.GetRunningUploadForNode(ctx, upload.NodeName)
+	if err != nil {
+		return 0, false, err
+	}
+	if running != nil {
+		return running.ID, false, nil
+	}
 	if m.createUploadFunc != nil {
-		return m.createUploadFunc(ctx, upload)
+		id, err := m.createUploadFunc(ctx, upload)
+		return id, err == nil, err
 	}
-	return 1, nil
+	return 1, true, nil
 }
 
 func (m *mockDatabase) UpdateUpload(ctx context.Context, upload Upload) error {
@@ -55,6 +80,62 @@ func (m *mockDatabase) GetLatestCompletedUploadForNode(ctx context.Context, node
 	return nil, nil
 }
 
+func (m *mockDatabase) RecordProgressSample(ctx context.Context, uploadID int64, sample analytics.Sample) error {
+	if m.recordProgressSampleFunc != nil {
+		return m.recordProgressSampleFunc(ctx, uploadID, sample)
+	}
+	return nil
+}
+
+func (m *mockDatabase) GetProgressSamples(ctx context.Context, uploadID int64, since time.Time) ([]analytics.Sample, error) {
+	if m.getProgressSamplesFunc != nil {
+		return m.getProgressSamplesFunc(ctx, uploadID, since)
+	}
+	return nil, nil
+}
+
+func (m *mockDatabase) UpdateUploadThroughput(ctx context.Context, uploadID int64, chunksPerMinute *float64, estimatedCompletion *time.Time) error {
+	if m.updateUploadThroughputFunc != nil {
+		return m.updateUploadThroughputFunc(ctx, uploadID, chunksPerMinute, estimatedCompletion)
+	}
+	return nil
+}
+
+func (m *mockDatabase) SetUploadDetectionLag(ctx context.Context, uploadID int64, finishedAt time.Time, lag time.Duration) error {
+	if m.setUploadDetectionLagFunc != nil {
+		return m.setUploadDetectionLagFunc(ctx, uploadID, finishedAt, lag)
+	}
+	return nil
+}
+
+func (m *mockDatabase) RecordStatusOutput(ctx context.Context, uploadID int64, state, rawOutput string, recordedAt time.Time) (bool, error) {
+	if m.recordStatusOutputFunc != nil {
+		return m.recordStatusOutputFunc(ctx, uploadID, state, rawOutput, recordedAt)
+	}
+	return true, nil
+}
+
+func (m *mockDatabase) SetUploadSize(ctx context.Context, uploadID int64, sizeBytes int64) error {
+	if m.setUploadSizeFunc != nil {
+		return m.setUploadSizeFunc(ctx, uploadID, sizeBytes)
+	}
+	return nil
+}
+
+func (m *mockDatabase) RecordUploadResumed(ctx context.Context, uploadID int64, nodeName string, resumedAt time.Time) error {
+	if m.recordUploadResumedFunc != nil {
+		return m.recordUploadResumedFunc(ctx, uploadID, nodeName, resumedAt)
+	}
+	return nil
+}
+
+func (m *mockDatabase) SetUploadCompression(ctx context.Context, uploadID int64, algorithm string, level int, rawBytes, compressedBytes int64) error {
+	if m.setUploadCompressionFunc != nil {
+		return m.setUploadCompressionFunc(ctx, uploadID, algorithm, level, rawBytes, compressedBytes)
+	}
+	return nil
+}
+
 func (m *mockDatabase) UpdateUploadProgress(ctx context.Context, uploadID int64, status string, progressPercent *float64, chunksCompleted *int, chunksTotal *int, lastProgressCheck *time.Time) error {
 	if m.updateUploadProgressFunc != nil {
 		return m.updateUploadProgressFunc(ctx, uploadID, status, progressPercent, chunksCompleted, chunksTotal, lastProgressCheck)
@@ -233,6 +314,37 @@ func TestCheckUploadStatus_CommandConstruction(t *testing.T) {
 	}
 }
 
+func TestCheckUploadStatus_JSONOutput(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			switch strings.Join(args, " ") {
+			case "--version":
+				return "bv 1.6.0\n", "", nil
+			case "node job eth-1 info upload --output json":
+				return `{"status": {"state": "Finished", "exit_code": 0, "timestamp": "2025-12-07T13:41:43Z"}, "progress": {"completed": 3248, "total": 3248}, "restart_count": 0}`, "", nil
+			}
+			return "", "", fmt.Errorf("unexpected command: %v", args)
+		},
+	}
+
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	status, err := manager.CheckUploadStatus(context.Background(), "eth-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The progress has the keys of the text format
+	if status.IsRunning || status.Progress["status"] != "2025-12-07 13:41:43 UTC| Finished with exit code 0" {
+		t.Errorf("expected the finished status line, got running %v, %v", status.IsRunning, status.Progress["status"])
+	}
+	if status.Progress["started_at"] != "2025-12-07T13:41:43Z" || status.Progress["progress_percent"] != "100.00" || status.Progress["chunks_total"] != "3248" || status.Progress["restart_count"] != "0" {
+		t.Errorf("unexpected progress: %v", status.Progress)
+	}
+	if outcome := classifyCompletion(status.Progress["status"].(string)); outcome != OutcomeSuccess {
+		t.Errorf("expected success, got %s", outcome)
+	}
+}
+
 func TestInitiateUpload_CommandConstruction(t *testing.T) {
 	var capturedCommand string
 	var capturedArgs []string
@@ -274,6 +386,15 @@ func TestInitiateUpload_CommandConstruction(t *testing.T) {
 			t.Errorf("Expected arg[%d]=%q, got %q", i, arg, capturedArgs[i])
 		}
 	}
+
+	// Flags from the node's engine arguments follow the node name
+	manager.SetNodeBVFlags("arbitrum-one", []string{"--max-chunks=100"})
+	if _, err := manager.InitiateUpload(context.Background(), "arbitrum-one", "scheduled"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := strings.Join(capturedArgs, " "); got != "node run upload arbitrum-one --max-chunks=100" {
+		t.Errorf("Expected the engine arguments as flags, got %q", got)
+	}
 }
 
 func TestInitiateUpload_DatabasePersistence(t *testing.T) {
@@ -320,6 +441,177 @@ func TestInitiateUpload_DatabasePersistence(t *testing.T) {
 	}
 }
 
+func TestMonitorUpload_Outcomes(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		wantOutcome CompletionOutcome
+		wantStatus  string // recorded uploads.status ("" = still running)
+	}{
+		{
+			name: "running",
+			output: `status:           2025-12-07 13:41:43 UTC| Running
+progress:         50.00% (1624/3248 multi-client upload)`,
+			wantOutcome: OutcomeRunning,
+		},
+		{
+			name:        "success",
+			output:      `status:           2025-12-07 13:41:43 UTC| Finished with exit code 0 and message 'no errors'`,
+			wantOutcome: OutcomeSuccess,
+			wantStatus:  "completed",
+		},
+		{
+			name:        "failure",
+			output:      `status:           2025-12-07 15:00:00 UTC| Finished with exit code 1 and message 'Upload failed'`,
+			wantOutcome: OutcomeFailure,
+			wantStatus:  "failed",
+		},
+		{
+			name:        "cancelled",
+			output:      `status:           2025-12-07 15:00:00 UTC| Stopped`,
+			wantOutcome: OutcomeCancelled,
+			wantStatus:  "cancelled",
+		},
+		{
+			name:        "job gone",
+			output:      "",
+			wantOutcome: OutcomeSuccess,
+			wantStatus:  "completed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recordedStatus string
+			var errorMessage *string
+			executor := &mockExecutor{
+				executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+					return tt.output, "", nil
+				},
+			}
+			db := &mockDatabase{
+				updateUploadCompletionFunc: func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errMsg *string) error {
+					recordedStatus = status
+					errorMessage = errMsg
+					return nil
+				},
+			}
+
+			manager := NewManager(executor, db, logrus.New())
+			result, err := manager.MonitorUpload(context.Background(), 1, "test-node")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Outcome != tt.wantOutcome {
+				t.Errorf("Expected outcome %q, got %q", tt.wantOutcome, result.Outcome)
+			}
+			if result.Done() != (tt.wantOutcome != OutcomeRunning) {
+				t.Errorf("Unexpected Done() = %v for outcome %q", result.Done(), result.Outcome)
+			}
+			if recordedStatus != tt.wantStatus {
+				t.Errorf("Expected recorded status %q, got %q", tt.wantStatus, recordedStatus)
+			}
+			if (tt.wantOutcome == OutcomeFailure || tt.wantOutcome == OutcomeCancelled) && errorMessage == nil {
+				t.Error("Expected the final status to be recorded as the error message")
+			}
+		})
+	}
+}
+
+func TestCreateUploadRecord_TriggerValidation(t *testing.T) {
+	var capturedUpload Upload
+	db := &mockDatabase{
+		createUploadFunc: func(ctx context.Context, upload Upload) (int64, error) {
+			capturedUpload = upload
+			return 7, nil
+		},
+	}
+	manager := NewManager(&mockExecutor{}, db, logrus.New())
+
+	trigger := Trigger{Type: TriggerAPI, Metadata: map[string]interface{}{"user": "alice", "reason": "pre-upgrade"}}
+	if _, err := manager.CreateUploadRecord(context.Background(), "test-node", "ethereum", "archive", trigger, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if capturedUpload.TriggerType != TriggerAPI {
+		t.Errorf("Expected trigger type 'api', got %q", capturedUpload.TriggerType)
+	}
+	if capturedUpload.TriggerMetadata["user"] != "alice" {
+		t.Errorf("Expected trigger metadata to be stored, got %v", capturedUpload.TriggerMetadata)
+	}
+
+	// A retry records its attempt and the upload it retries
+	failedID := int64(6)
+	retry := Trigger{Type: TriggerRetry, Attempt: 2, RetryOf: &failedID}
+	if _, err := manager.CreateUploadRecord(context.Background(), "test-node", "ethereum", "archive", retry, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if capturedUpload.Attempt != 2 || capturedUpload.RetryOf == nil || *capturedUpload.RetryOf != failedID {
+		t.Errorf("Expected attempt 2 of upload 6 to be stored, got %d of %v", capturedUpload.Attempt, capturedUpload.RetryOf)
+	}
+
+	invalid := []Trigger{
+		{Type: "discovered"},
+		{Type: ""},
+		{Type: TriggerManual, Metadata: map[string]interface{}{"bad": func() {}}},
+	}
+	for _, trigger := range invalid {
+		capturedUpload = Upload{}
+		if _, err := manager.CreateUploadRecord(context.Background(), "test-node", "ethereum", "archive", trigger, nil); err == nil {
+			t.Errorf("Expected error for trigger %+v", trigger)
+		}
+		if capturedUpload.NodeName != "" {
+			t.Errorf("Expected no upload record for invalid trigger %+v", trigger)
+		}
+	}
+}
+
+func TestCreateUploadRecordWithProgress_EngineStartTime(t *testing.T) {
+	var capturedUpload Upload
+	db := &mockDatabase{
+		createUploadFunc: func(ctx context.Context, upload Upload) (int64, error) {
+			capturedUpload = upload
+			return 7, nil
+		},
+	}
+	manager := NewManager(&mockExecutor{}, db, logrus.New())
+
+	// The running upload's status line carries its start
+	engineStatus := &engine.Status{Running: true}
+	bvStartedAt := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	engineStatus.SetState("Running", bvStartedAt)
+	status := uploadStatus(engineStatus)
+	if status.StartedAt == nil || !status.StartedAt.Equal(bvStartedAt) {
+		t.Fatalf("Expected the running upload to start at %s, got %v", bvStartedAt, status.StartedAt)
+	}
+
+	trigger := Trigger{Type: TriggerExternal}
+	if _, err := manager.CreateUploadRecordWithProgress(context.Background(), "test-node", "ethereum", "archive", trigger, nil, status.Progress, status.StartedAt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !capturedUpload.StartedAt.Equal(bvStartedAt) {
+		t.Errorf("Expected the record to start at %s, got %s", bvStartedAt, capturedUpload.StartedAt)
+	}
+
+	// A start ahead of the host clock, or none, records the upload as starting now
+	future := time.Now().Add(time.Hour)
+	for _, startedAt := range []*time.Time{&future, nil} {
+		before := time.Now()
+		if _, err := manager.CreateUploadRecordWithProgress(context.Background(), "test-node", "ethereum", "archive", trigger, nil, nil, startedAt); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if capturedUpload.StartedAt.Before(before) || capturedUpload.StartedAt.After(time.Now()) {
+			t.Errorf("Expected the record to start now for %v, got %s", startedAt, capturedUpload.StartedAt)
+		}
+	}
+
+	// A finished upload's status time is its end, not a start
+	engineStatus = &engine.Status{}
+	engineStatus.SetState("Finished with exit code 0", bvStartedAt)
+	if status := uploadStatus(engineStatus); status.StartedAt != nil {
+		t.Errorf("Expected no start time for a finished upload, got %v", status.StartedAt)
+	}
+}
+
 func TestShouldSkipUpload_DatabaseHasRunning(t *testing.T) {
 	executor := &mockExecutor{}
 
@@ -450,6 +742,60 @@ progress:         75.00% (2436/3248 uploading)`, "", nil
 	}
 }
 
+func TestMonitorUploadProgress_UpdatesThroughput(t *testing.T) {
+	var recorded []analytics.Sample
+	var capturedRate *float64
+	var capturedETA *time.Time
+
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			return `status:           Running
+progress:         75.00% (2436/3248 uploading)`, "", nil
+		},
+	}
+
+	db := &mockDatabase{
+		recordProgressSampleFunc: func(ctx context.Context, uploadID int64, sample analytics.Sample) error {
+			recorded = append(recorded, sample)
+			return nil
+		},
+		getProgressSamplesFunc: func(ctx context.Context, uploadID int64, since time.Time) ([]analytics.Sample, error) {
+			// An earlier sample 10 minutes before the one just recorded
+			last := recorded[len(recorded)-1]
+			earlier := analytics.Sample{RecordedAt: last.RecordedAt.Add(-10 * time.Minute), ChunksCompleted: last.ChunksCompleted - 100, ChunksTotal: last.ChunksTotal}
+			return []analytics.Sample{earlier, last}, nil
+		},
+		updateUploadThroughputFunc: func(ctx context.Context, uploadID int64, chunksPerMinute *float64, estimatedCompletion *time.Time) error {
+			capturedRate = chunksPerMinute
+			capturedETA = estimatedCompletion
+			return nil
+		},
+	}
+
+	manager := NewManager(executor, db, logrus.New())
+	result, err := manager.MonitorUpload(context.Background(), 999, "test-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Done() {
+		t.Fatal("Expected upload to still be running")
+	}
+
+	if len(recorded) != 1 || recorded[0].ChunksCompleted != 2436 {
+		t.Fatalf("Expected one progress sample with 2436 chunks, got %+v", recorded)
+	}
+
+	if capturedRate == nil || *capturedRate != 10 {
+		t.Errorf("Expected 10 chunks/min, got %v", capturedRate)
+	}
+
+	// 812 chunks remaining at 10 chunks/min
+	wantETA := recorded[0].RecordedAt.Add(81*time.Minute + 12*time.Second)
+	if capturedETA == nil || !capturedETA.Equal(wantETA) {
+		t.Errorf("Expected ETA %v, got %v", wantETA, capturedETA)
+	}
+}
+
 func TestMonitorUploadProgress_UpdatesOnCompletion(t *testing.T) {
 	var capturedUploadID int64
 	var capturedStatus string
@@ -491,6 +837,51 @@ progress:         100.00% (3248/3248 completed)`, "", nil
 	}
 }
 
+func TestManager_PublishesEvents(t *testing.T) {
+	output := `status:           Running
+progress:         75.00% (2436/3248 uploading)`
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			return output, "", nil
+		},
+	}
+	db := &mockDatabase{
+		createUploadFunc: func(ctx context.Context, upload Upload) (int64, error) {
+			return 42, nil
+		},
+	}
+
+	hub := events.NewHub()
+	sub := hub.Subscribe(0)
+	defer sub.Close()
+	manager := NewManager(executor, db, logrus.New())
+	manager.SetEvents(hub)
+
+	ctx := context.Background()
+	if _, err := manager.CreateUploadRecord(ctx, "test-node", "ethereum", "archive", Trigger{Type: TriggerScheduled}, nil); err != nil {
+		t.Fatalf("CreateUploadRecord failed: %v", err)
+	}
+	if _, err := manager.MonitorUpload(ctx, 42, "test-node"); err != nil {
+		t.Fatalf("MonitorUpload failed: %v", err)
+	}
+	output = `status:           2025-12-07 13:41:43 UTC| Finished with exit code 0
+progress:         100.00% (3248/3248 completed)`
+	if _, err := manager.MonitorUpload(ctx, 42, "test-node"); err != nil {
+		t.Fatalf("MonitorUpload failed: %v", err)
+	}
+
+	started, progress, completed := <-sub.Events(), <-sub.Events(), <-sub.Events()
+	if started.Type != events.TypeStarted || started.UploadID != 42 || started.Protocol != "ethereum" || started.Trigger != "scheduled" {
+		t.Errorf("unexpected started event: %+v", started)
+	}
+	if progress.Type != events.TypeProgress || progress.ChunksCompleted == nil || *progress.ChunksCompleted != 2436 || progress.ProgressPercent == nil {
+		t.Errorf("unexpected progress event: %+v", progress)
+	}
+	if completed.Type != events.TypeCompleted || completed.Status != "completed" || completed.Node != "test-node" {
+		t.Errorf("unexpected completed event: %+v", completed)
+	}
+}
+
 func TestInitiateUpload_ErrorHandling(t *testing.T) {
 	executor := &mockExecutor{
 		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
@@ -508,6 +899,44 @@ func TestInitiateUpload_ErrorHandling(t *testing.T) {
 	}
 }
 
+func TestInitiateUploadWithProtocolData_AlreadyRunning(t *testing.T) {
+	executed := false
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			executed = true
+			return "", "", nil
+		},
+	}
+
+	// A concurrent trigger created the node's upload first
+	created := false
+	db := &mockDatabase{
+		getRunningUploadForNodeFunc: func(ctx context.Context, nodeName string) (*Upload, error) {
+			return &Upload{ID: 42, NodeName: nodeName, Status: "running"}, nil
+		},
+		createUploadFunc: func(ctx context.Context, upload Upload) (int64, error) {
+			created = true
+			return 43, nil
+		},
+	}
+
+	manager := NewManager(executor, db, logrus.New())
+	uploadID, err := manager.InitiateUploadWithProtocolData(context.Background(), "test-node", Trigger{Type: TriggerScheduled}, "ethereum", "archive", nil)
+
+	if !errors.Is(err, ErrUploadRunning) || uploadID != 0 {
+		t.Fatalf("expected ErrUploadRunning, got upload %d, error %v", uploadID, err)
+	}
+	if created || executed {
+		t.Errorf("expected no record created and bv not run, got created=%v executed=%v", created, executed)
+	}
+
+	// Discovered external uploads still reuse the running record
+	uploadID, err = manager.CreateUploadRecord(context.Background(), "test-node", "ethereum", "archive", Trigger{Type: TriggerExternal}, nil)
+	if err != nil || uploadID != 42 {
+		t.Errorf("expected running upload 42 reused, got %d, error %v", uploadID, err)
+	}
+}
+
 func TestCheckUploadStatus_ErrorHandling(t *testing.T) {
 	manager := NewManager(&mockExecutor{
 		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
@@ -569,57 +998,178 @@ progress:         50.00% (1624/3248 uploading)`, "", nil
 	}
 }
 
-func TestParseUploadStatus_ProgressExtraction(t *testing.T) {
-	manager := NewManager(&mockExecutor{}, &mockDatabase{}, logrus.New())
+func TestTimeoutUpload_CancelsAndMarksStalled(t *testing.T) {
+	var executedArgs []string
+	var capturedStatus string
+	var capturedError *string
 
-	tests := []struct {
-		name              string
-		output            string
-		expectedRunning   bool
-		expectedPercent   string
-		expectedCompleted string
-		expectedTotal     string
-	}{
-		{
-			name: "Completed upload with full details",
-			output: `status:           2025-12-07 13:41:43 UTC| Finished with exit code 0
-progress:         100.00% (3248/3248 multi-client upload completed)
-restart_count:    0`,
-			expectedRunning:   false,
-			expectedPercent:   "100.00",
-			expectedCompleted: "3248",
-			expectedTotal:     "3248",
-		},
-		{
-			name: "Running upload with progress",
-			output: `status:           Running
-progress:         75.50% (3100/4112 uploading)
-restart_count:    1`,
-			expectedRunning:   true,
-			expectedPercent:   "75.50",
-			expectedCompleted: "3100",
-			expectedTotal:     "4112",
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			executedArgs = args
+			return "Stopped job 'upload'", "", nil
 		},
-		{
-			name: "Partial progress",
-			output: `status:           Running
-progress:         25.00% (1024/4096 in progress)`,
-			expectedRunning:   true,
-			expectedPercent:   "25.00",
-			expectedCompleted: "1024",
-			expectedTotal:     "4096",
+	}
+
+	db := &mockDatabase{
+		updateUploadCompletionFunc: func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error {
+			capturedStatus = status
+			capturedError = errorMessage
+			return nil
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			status, err := manager.parseUploadStatus(tt.output)
-			if err != nil {
-				t.Fatalf("Unexpected error: %v", err)
-			}
-			if status.IsRunning != tt.expectedRunning {
-				t.Errorf("Expected IsRunning=%v, got %v", tt.expectedRunning, status.IsRunning)
-			}
+	manager := NewManager(executor, db, logrus.New())
+	if err := manager.TimeoutUpload(context.Background(), 42, "test-node", 12*time.Hour, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Join(executedArgs, " ") != "node job test-node stop upload" {
+		t.Errorf("Expected stop command, got: %v", executedArgs)
+	}
+	if capturedStatus != "stalled" {
+		t.Errorf("Expected status 'stalled', got '%s'", capturedStatus)
+	}
+	if capturedError == nil || !strings.Contains(*capturedError, "12h0m0s") {
+		t.Errorf("Expected error message with max duration, got %v", capturedError)
+	}
+}
+
+func TestTimeoutUpload_StillMarksStalledWhenCancelFails(t *testing.T) {
+	var capturedStatus string
+
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			return "", "Error: job 'upload' not found", errors.New("exit status 1")
+		},
+	}
+
+	db := &mockDatabase{
+		updateUploadCompletionFunc: func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error {
+			capturedStatus = status
+			return nil
+		},
+	}
+
+	manager := NewManager(executor, db, logrus.New())
+	if err := manager.TimeoutUpload(context.Background(), 42, "test-node", time.Hour, true); err == nil {
+		t.Error("Expected error when stopping the upload job fails")
+	}
+
+	if capturedStatus != "stalled" {
+		t.Errorf("Expected status 'stalled', got '%s'", capturedStatus)
+	}
+}
+
+func TestCancelUpload_StopsJobAndMarksCancelled(t *testing.T) {
+	var stopped bool
+	var capturedStatus string
+
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			if len(args) == 5 && args[3] == "stop" {
+				stopped = true
+				return "", "", nil
+			}
+			return `status:           2025-12-10 15:18:44 UTC| Running
+progress:         50.00% (1624/3248 uploading)`, "", nil
+		},
+	}
+
+	db := &mockDatabase{
+		getRunningUploadForNodeFunc: func(ctx context.Context, nodeName string) (*Upload, error) {
+			return &Upload{ID: 9, NodeName: nodeName, Status: "running"}, nil
+		},
+		updateUploadCompletionFunc: func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error {
+			capturedStatus = status
+			return nil
+		},
+	}
+
+	manager := NewManager(executor, db, logrus.New())
+	uploadID, err := manager.CancelUpload(context.Background(), "test-node", "storage outage")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if uploadID != 9 {
+		t.Errorf("Expected upload ID 9, got %d", uploadID)
+	}
+	if !stopped {
+		t.Error("Expected bv upload job to be stopped")
+	}
+	if capturedStatus != "cancelled" {
+		t.Errorf("Expected status 'cancelled', got '%s'", capturedStatus)
+	}
+}
+
+func TestCancelUpload_NoRunningUpload(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			if len(args) == 5 && args[3] == "stop" {
+				t.Error("Expected no stop command when nothing is running")
+			}
+			return "", "Error: job 'upload' not found", errors.New("exit status 1")
+		},
+	}
+
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	_, err := manager.CancelUpload(context.Background(), "test-node", "storage outage")
+	if !errors.Is(err, ErrNoRunningUpload) {
+		t.Errorf("Expected ErrNoRunningUpload, got: %v", err)
+	}
+}
+
+func TestParseUploadStatus_ProgressExtraction(t *testing.T) {
+	manager := NewManager(&mockExecutor{}, &mockDatabase{}, logrus.New())
+
+	tests := []struct {
+		name              string
+		output            string
+		expectedRunning   bool
+		expectedPercent   string
+		expectedCompleted string
+		expectedTotal     string
+	}{
+		{
+			name: "Completed upload with full details",
+			output: `status:           2025-12-07 13:41:43 UTC| Finished with exit code 0
+progress:         100.00% (3248/3248 multi-client upload completed)
+restart_count:    0`,
+			expectedRunning:   false,
+			expectedPercent:   "100.00",
+			expectedCompleted: "3248",
+			expectedTotal:     "3248",
+		},
+		{
+			name: "Running upload with progress",
+			output: `status:           Running
+progress:         75.50% (3100/4112 uploading)
+restart_count:    1`,
+			expectedRunning:   true,
+			expectedPercent:   "75.50",
+			expectedCompleted: "3100",
+			expectedTotal:     "4112",
+		},
+		{
+			name: "Partial progress",
+			output: `status:           Running
+progress:         25.00% (1024/4096 in progress)`,
+			expectedRunning:   true,
+			expectedPercent:   "25.00",
+			expectedCompleted: "1024",
+			expectedTotal:     "4096",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := manager.parseUploadStatus(tt.output)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if status.IsRunning != tt.expectedRunning {
+				t.Errorf("Expected IsRunning=%v, got %v", tt.expectedRunning, status.IsRunning)
+			}
 			if tt.expectedPercent != "" {
 				if percent, ok := status.Progress["progress_percent"].(string); !ok || percent != tt.expectedPercent {
 					t.Errorf("Expected progress_percent=%q, got %v", tt.expectedPercent, status.Progress["progress_percent"])
@@ -661,6 +1211,10 @@ func TestCheckUploadStatus_JobNotFound(t *testing.T) {
 		t.Error("Expected IsRunning to be false when job not found")
 	}
 
+	if !status.NotFound {
+		t.Error("Expected NotFound to be true when job not found")
+	}
+
 	if status.Progress["error"] == nil {
 		t.Error("Expected error information to be stored in progress")
 	}
@@ -669,15 +1223,17 @@ func TestCheckUploadStatus_JobNotFound(t *testing.T) {
 func TestCheckUploadStatus_CommandError(t *testing.T) {
 	// Test various command error scenarios
 	testCases := []struct {
-		name   string
-		stderr string
-		stdout string
-		err    error
+		name         string
+		stderr       string
+		stdout       string
+		err          error
+		wantNotFound bool
 	}{
 		{
-			name:   "Job not found",
-			stderr: "job 'upload' not found",
-			err:    errors.New("exit status 1"),
+			name:         "Job not found",
+			stderr:       "job 'upload' not found",
+			err:          errors.New("exit status 1"),
+			wantNotFound: true,
 		},
 		{
 			name:   "Unknown status",
@@ -711,6 +1267,873 @@ func TestCheckUploadStatus_CommandError(t *testing.T) {
 			if status.IsRunning {
 				t.Errorf("Expected IsRunning to be false for %s", tc.name)
 			}
+
+			if status.NotFound != tc.wantNotFound {
+				t.Errorf("Expected NotFound %v for %s, got %v", tc.wantNotFound, tc.name, status.NotFound)
+			}
 		})
 	}
 }
+
+func TestCheckUploadStatus_ConfiguredStatusRules(t *testing.T) {
+	testCases := []struct {
+		name          string
+		rules         StatusRules
+		stdout        string
+		stderr        string
+		err           error
+		wantErr       bool
+		wantNotFound  bool
+		wantIsRunning bool
+	}{
+		{
+			name:    "new wording is an error by default",
+			rules:   DefaultStatusRules(),
+			stderr:  "Error: no such job: upload",
+			err:     errors.New("exit status 1"),
+			wantErr: true,
+		},
+		{
+			name:         "new wording configured as not found",
+			rules:        NewStatusRules(nil, []string{"No such job"}, false),
+			stderr:       "Error: no such job: upload",
+			err:          errors.New("exit status 1"),
+			wantNotFound: true,
+		},
+		{
+			name:   "new wording configured as not running",
+			rules:  NewStatusRules([]string{"job is paused"}, nil, false),
+			stderr: "Error: job is paused",
+			err:    errors.New("exit status 1"),
+		},
+		{
+			name:   "configured pattern in successful output",
+			rules:  NewStatusRules([]string{"nothing scheduled"}, nil, false),
+			stdout: "nothing scheduled",
+		},
+		{
+			name:    "replaced defaults drop built-in wordings",
+			rules:   NewStatusRules([]string{"job is paused"}, nil, true),
+			stderr:  "job 'upload' not found",
+			err:     errors.New("exit status 1"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			executor := &mockExecutor{
+				executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+					return tc.stdout, tc.stderr, tc.err
+				},
+			}
+
+			manager := NewManager(executor, &mockDatabase{}, logrus.New())
+			manager.SetStatusRules(tc.rules)
+
+			status, err := manager.CheckUploadStatus(context.Background(), "test-node")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if status.IsRunning != tc.wantIsRunning {
+				t.Errorf("Expected IsRunning %v, got %v", tc.wantIsRunning, status.IsRunning)
+			}
+			if status.NotFound != tc.wantNotFound {
+				t.Errorf("Expected NotFound %v, got %v", tc.wantNotFound, status.NotFound)
+			}
+		})
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		logs    []string
+		want    FailureCategory
+	}{
+		{"disk full in logs", "Finished with exit code 1", []string{"writing chunk 12", "write /data/chunk: no space left on device"}, FailureDiskFull},
+		{"auth in message", "Finished with exit code 1 and message 'AccessDenied: bucket policy'", nil, FailureAuth},
+		{"network", "Finished with exit code 1", []string{"dial tcp 10.0.0.1:443: connection refused"}, FailureNetwork},
+		{"out of memory", "Finished with exit code 137", []string{"process OOMKilled"}, FailureOutOfMemory},
+		{"unknown", "Finished with exit code 1 and message 'Upload failed'", nil, FailureUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyFailure(tt.message, tt.logs); got != tt.want {
+				t.Errorf("ClassifyFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchJobLogs(t *testing.T) {
+	var gotArgs []string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			gotArgs = args
+			return "line 1\nline 2\n\nline 3\nline 4\n", "", nil
+		},
+	}
+
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	lines, err := manager.FetchJobLogs(context.Background(), "test-node", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(gotArgs, " ") != "node job test-node logs upload" {
+		t.Errorf("Unexpected bv arguments: %v", gotArgs)
+	}
+	if len(lines) != 2 || lines[0] != "line 3" || lines[1] != "line 4" {
+		t.Errorf("Expected the last two lines, got %v", lines)
+	}
+
+	executor.executeFunc = func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+		return "", "job not found", errors.New("exit status 1")
+	}
+	if _, err := manager.FetchJobLogs(context.Background(), "test-node", 2); err == nil {
+		t.Error("Expected an error when bv fails")
+	}
+}
+
+// mockStreamingExecutor streams its output line by line like the default executor
+type mockStreamingExecutor struct {
+	mockExecutor
+	streamFunc func(ctx context.Context, onLine executor.LineHandler, command string, args ...string) error
+}
+
+func (m *mockStreamingExecutor) ExecuteStream(ctx context.Context, onLine executor.LineHandler, command string, args ...string) error {
+	return m.streamFunc(ctx, onLine, command, args...)
+}
+
+func TestTailJobLogs(t *testing.T) {
+	var gotArgs []string
+	exec := &mockStreamingExecutor{
+		streamFunc: func(ctx context.Context, onLine executor.LineHandler, command string, args ...string) error {
+			gotArgs = args
+			for i := 1; i <= 5; i++ {
+				onLine(executor.StreamStdout, fmt.Sprintf("line %d", i))
+			}
+			onLine(executor.StreamStdout, "  ")
+			onLine(executor.StreamStderr, "warning: slow disk")
+			return nil
+		},
+	}
+
+	manager := NewManager(exec, &mockDatabase{}, logrus.New())
+	lines, err := manager.TailJobLogs(context.Background(), "test-node", 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(gotArgs, " ") != "node job test-node logs upload" {
+		t.Errorf("Unexpected bv arguments: %v", gotArgs)
+	}
+	want := []JobLogLine{
+		{Stream: executor.StreamStdout, Line: "line 4"},
+		{Stream: executor.StreamStdout, Line: "line 5"},
+		{Stream: executor.StreamStderr, Line: "warning: slow disk"},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Expected %v, got %v", want, lines)
+	}
+
+	// FetchJobLogs keeps the stdout lines only
+	if lines, err := manager.FetchJobLogs(context.Background(), "test-node", 2); err != nil || strings.Join(lines, "|") != "line 4|line 5" {
+		t.Errorf("Expected the last two stdout lines, got %v (%v)", lines, err)
+	}
+
+	// The lines read before a failure are returned with the error
+	exec.streamFunc = func(ctx context.Context, onLine executor.LineHandler, command string, args ...string) error {
+		onLine(executor.StreamStderr, "job not found")
+		return errors.New("exit status 1")
+	}
+	lines, err = manager.TailJobLogs(context.Background(), "test-node", 3)
+	if err == nil || len(lines) != 1 || lines[0].Line != "job not found" {
+		t.Errorf("Expected the stderr line with an error, got %v (%v)", lines, err)
+	}
+
+	if lines, err := manager.TailJobLogs(context.Background(), "test-node", 0); err != nil || lines != nil {
+		t.Errorf("Expected no lines for n = 0, got %v (%v)", lines, err)
+	}
+}
+
+func TestBVTimeouts(t *testing.T) {
+	// The time left before each bv command's deadline, by its action
+	remaining := make(map[string]time.Duration)
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			action := strings.Join(args, " ")
+			if deadline, ok := ctx.Deadline(); ok {
+				remaining[action] = time.Until(deadline)
+			} else {
+				remaining[action] = 0
+			}
+			return "", "", nil
+		},
+	}
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	manager.SetBVOutputFormat("text")
+	bv := manager.defaultEngine
+	ctx := context.Background()
+
+	// Without timeouts, bv commands are unbounded
+	if _, err := bv.Status(ctx, "eth-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if remaining["node job eth-node info upload"] != 0 {
+		t.Errorf("Expected no deadline without timeouts, got %s", remaining["node job eth-node info upload"])
+	}
+
+	manager.SetBVTimeouts(30*time.Second, 10*time.Minute)
+	if _, err := bv.Status(ctx, "eth-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := bv.StartUpload(ctx, "eth-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := bv.Cancel(ctx, "eth-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := bv.(engine.LogReader).Logs(ctx, "eth-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for action, want := range map[string]time.Duration{
+		"node job eth-node info upload": 30 * time.Second,
+		"node job eth-node logs upload": 30 * time.Second,
+		"node run upload eth-node":      10 * time.Minute,
+		"node job eth-node stop upload": 10 * time.Minute,
+	} {
+		if got := remaining[action]; got <= want-time.Second || got > want {
+			t.Errorf("Expected bv %s bounded by %s, got %s", action, want, got)
+		}
+	}
+
+	// A caller's shorter deadline still applies
+	short, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := bv.Status(short, "eth-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := remaining["node job eth-node info upload"]; got > time.Second {
+		t.Errorf("Expected the caller's deadline kept, got %s", got)
+	}
+}
+
+func TestFetchContentListing(t *testing.T) {
+	var gotCommand string
+	var gotArgs []string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			gotCommand = command
+			gotArgs = args
+			return "# snapshot manifest\nchunks/000001 1048576 sha256:abc\n\nchunks/000002 2048\nmanifest.json\n", "", nil
+		},
+	}
+
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	objects, err := manager.FetchContentListing(context.Background(), "test-node", []string{"bv", "node", "job", "{node}", "manifest", "upload"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotCommand != "bv" || strings.Join(gotArgs, " ") != "node job test-node manifest upload" {
+		t.Errorf("Unexpected listing command: %s %v", gotCommand, gotArgs)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("Expected 3 objects, got %d: %+v", len(objects), objects)
+	}
+	if objects[0].Key != "chunks/000001" || objects[0].SizeBytes == nil || *objects[0].SizeBytes != 1048576 || objects[0].Checksum != "sha256:abc" {
+		t.Errorf("Unexpected first object: %+v", objects[0])
+	}
+	if objects[1].SizeBytes == nil || *objects[1].SizeBytes != 2048 || objects[1].Checksum != "" {
+		t.Errorf("Unexpected second object: %+v", objects[1])
+	}
+	if objects[2].Key != "manifest.json" || objects[2].SizeBytes != nil {
+		t.Errorf("Unexpected third object: %+v", objects[2])
+	}
+
+	for _, output := range []string{"chunk-1 notanumber", "chunk-1 10 sum extra", "chunk-1\nchunk-1"} {
+		executor.executeFunc = func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			return output, "", nil
+		}
+		if _, err := manager.FetchContentListing(context.Background(), "test-node", []string{"list"}); err == nil {
+			t.Errorf("Expected an error for listing %q", output)
+		}
+	}
+
+	executor.executeFunc = func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+		return "", "not found", errors.New("exit status 1")
+	}
+	if _, err := manager.FetchContentListing(context.Background(), "test-node", []string{"list"}); err == nil {
+		t.Error("Expected an error when the listing command fails")
+	}
+}
+
+func TestInitiateIncrementalUpload(t *testing.T) {
+	var gotCommand string
+	var gotArgs []string
+	var manifest string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			gotCommand = command
+			gotArgs = args
+			data, readErr := os.ReadFile(args[len(args)-1])
+			if readErr != nil {
+				t.Errorf("Failed to read base manifest: %v", readErr)
+			}
+			manifest = string(data)
+			return "", "", nil
+		},
+	}
+
+	var created Upload
+	db := &mockDatabase{
+		createUploadFunc: func(ctx context.Context, upload Upload) (int64, error) {
+			created = upload
+			return 8, nil
+		},
+	}
+
+	size := int64(1024)
+	base := IncrementalBase{
+		UploadID: 5,
+		Objects: []ContentObject{
+			{Key: "chunks/000001", SizeBytes: &size, Checksum: "sha256:abc"},
+			{Key: "manifest.json"},
+		},
+	}
+
+	manager := NewManager(executor, db, logrus.New())
+	uploadID, err := manager.InitiateIncrementalUpload(context.Background(), "test-node", Trigger{Type: TriggerScheduled}, "ethereum", "archive", map[string]interface{}{}, []string{"upload-incremental", "--node", "{node}", "--base", "{base_upload_id}", "{base_manifest}"}, base)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if uploadID != 8 {
+		t.Errorf("Expected upload ID 8, got %d", uploadID)
+	}
+	if created.BaseUploadID == nil || *created.BaseUploadID != 5 {
+		t.Errorf("Expected the record to be linked to base upload 5, got %v", created.BaseUploadID)
+	}
+	if gotCommand != "upload-incremental" || strings.Join(gotArgs[:4], " ") != "--node test-node --base 5" {
+		t.Errorf("Unexpected incremental command: %s %v", gotCommand, gotArgs)
+	}
+	if manifest != "chunks/000001 1024 sha256:abc\nmanifest.json\n" {
+		t.Errorf("Unexpected base manifest: %q", manifest)
+	}
+
+	// A failing command marks the record failed
+	var failedStatus string
+	db.updateUploadCompletionFunc = func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errorMessage *string) error {
+		failedStatus = status
+		return nil
+	}
+	executor.executeFunc = func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+		return "", "backend does not support incremental uploads", errors.New("exit status 2")
+	}
+	if _, err := manager.InitiateIncrementalUpload(context.Background(), "test-node", Trigger{Type: TriggerScheduled}, "ethereum", "archive", nil, []string{"upload-incremental"}, base); err == nil {
+		t.Error("Expected an error when the incremental command fails")
+	}
+	if failedStatus != "failed" {
+		t.Errorf("Expected the record to be marked failed, got %q", failedStatus)
+	}
+}
+
+func TestDiffContents(t *testing.T) {
+	small, large := int64(10), int64(20)
+	base := []ContentObject{
+		{Key: "a", SizeBytes: &small, Checksum: "1"},
+		{Key: "b", SizeBytes: &small, Checksum: "2"},
+		{Key: "c"},
+	}
+	current := []ContentObject{
+		{Key: "a", SizeBytes: &small, Checksum: "1"}, // unchanged
+		{Key: "b", SizeBytes: &large, Checksum: "3"}, // changed
+		{Key: "c"},                    // unchanged
+		{Key: "d", SizeBytes: &small}, // new
+	}
+
+	if changed := DiffContents(base, current); changed != 2 {
+		t.Errorf("Expected 2 changed objects, got %d", changed)
+	}
+	if changed := DiffContents(nil, current); changed != 4 {
+		t.Errorf("Expected every object to be new without a base, got %d", changed)
+	}
+}
+
+func TestMonitorUpload_RecordsDetectionLag(t *testing.T) {
+	finishedAt := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+	output := fmt.Sprintf("status:           %s UTC| Finished with exit code 0 and message 'done'", finishedAt.Format("2006-01-02 15:04:05"))
+
+	var recordedFinish time.Time
+	var recordedLag time.Duration
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			return output, "", nil
+		},
+	}
+	db := &mockDatabase{
+		setUploadDetectionLagFunc: func(ctx context.Context, uploadID int64, finishedAt time.Time, lag time.Duration) error {
+			recordedFinish = finishedAt
+			recordedLag = lag
+			return nil
+		},
+	}
+
+	manager := NewManager(executor, db, logrus.New())
+	result, err := manager.MonitorUpload(context.Background(), 1, "test-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.FinishedAt == nil || !result.FinishedAt.Equal(finishedAt) {
+		t.Errorf("Expected FinishedAt %v, got %v", finishedAt, result.FinishedAt)
+	}
+	if result.DetectionLag == nil || *result.DetectionLag < 10*time.Minute || *result.DetectionLag > 11*time.Minute {
+		t.Errorf("Expected a detection lag of about 10m, got %v", result.DetectionLag)
+	}
+	if !recordedFinish.Equal(finishedAt) || recordedLag != *result.DetectionLag {
+		t.Errorf("Expected the lag to be stored, got %v / %v", recordedFinish, recordedLag)
+	}
+
+	// Without a timestamp in the status line there is nothing to measure
+	output = "status:           Finished with exit code 0"
+	result, err = manager.MonitorUpload(context.Background(), 1, "test-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.DetectionLag != nil {
+		t.Errorf("Expected no detection lag, got %v", *result.DetectionLag)
+	}
+}
+
+func TestRunPreflightCommand(t *testing.T) {
+	var gotArgs []string
+	failing := false
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			gotArgs = args
+			if failing {
+				return "", "disk smart check failed\n", fmt.Errorf("exit status 2")
+			}
+			return "ok\n", "", nil
+		},
+	}
+
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	if err := manager.RunPreflightCommand(context.Background(), "test-node", []string{"/usr/local/bin/node-healthy", "--node", "{node}"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(gotArgs, " ") != "--node test-node" {
+		t.Errorf("Unexpected preflight arguments: %v", gotArgs)
+	}
+
+	failing = true
+	err := manager.RunPreflightCommand(context.Background(), "test-node", []string{"/usr/local/bin/node-healthy"})
+	if err == nil || err.Error() != "exit status 2: disk smart check failed" {
+		t.Errorf("Expected error with command output, got %v", err)
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	var gotCommand string
+	var gotArgs []string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			gotCommand, gotArgs = command, args
+			if strings.Contains(args[len(args)-1], "fail") {
+				return "", "compaction still running\n", fmt.Errorf("exit status 1")
+			}
+			return "", "", nil
+		},
+	}
+
+	manager := NewManager(executor, &mockDatabase{}, logrus.New())
+	env := map[string]string{"NODE_NAME": "test-node", "UPLOAD_ID": "42"}
+	if err := manager.RunHook(context.Background(), "test-node", "systemctl start compaction@$NODE_NAME", env); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := "NODE_NAME=test-node UPLOAD_ID=42 sh -c systemctl start compaction@$NODE_NAME"
+	if gotCommand != "env" || strings.Join(gotArgs, " ") != want {
+		t.Errorf("Unexpected hook invocation: %s %v", gotCommand, gotArgs)
+	}
+
+	err := manager.RunHook(context.Background(), "test-node", "fail", env)
+	if err == nil || err.Error() != "exit status 1: compaction still running" {
+		t.Errorf("Expected error with command output, got %v", err)
+	}
+}
+
+func TestSetNodeExecutor(t *testing.T) {
+	var hostCommands, nodeCommands []string
+	record := func(commands *[]string) *mockExecutor {
+		return &mockExecutor{
+			executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+				*commands = append(*commands, command)
+				return "", "", nil
+			},
+		}
+	}
+
+	manager := NewManager(record(&hostCommands), &mockDatabase{}, logrus.New())
+	manager.SetNodeExecutor("test-node", record(&nodeCommands))
+	ctx := context.Background()
+
+	// The node's hooks and preflight command run on its executor, other nodes' on the host
+	if err := manager.RunHook(ctx, "test-node", "true", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := manager.RunPreflightCommand(ctx, "test-node", []string{"check-peers"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := manager.RunHook(ctx, "other-node", "true", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(nodeCommands, " ") != "env check-peers" || strings.Join(hostCommands, " ") != "env" {
+		t.Errorf("Node executor ran %v and host executor %v", nodeCommands, hostCommands)
+	}
+
+	// nil restores the manager's executor
+	manager.SetNodeExecutor("test-node", nil)
+	if err := manager.RunHook(ctx, "test-node", "true", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(hostCommands) != 2 {
+		t.Errorf("Expected the hook to run on the host after the reset, ran %v", hostCommands)
+	}
+}
+
+// fakeEngine reports a fixed status and records the engine calls
+type fakeEngine struct {
+	status *engine.Status
+	calls  []string
+}
+
+func (e *fakeEngine) Name() string { return "fake" }
+
+func (e *fakeEngine) StartUpload(ctx context.Context, nodeName string) error {
+	e.calls = append(e.calls, "start "+nodeName)
+	return nil
+}
+
+func (e *fakeEngine) Status(ctx context.Context, nodeName string) (*engine.Status, error) {
+	e.calls = append(e.calls, "status "+nodeName)
+	return e.status, nil
+}
+
+func (e *fakeEngine) Cancel(ctx context.Context, nodeName string) error {
+	e.calls = append(e.calls, "cancel "+nodeName)
+	return nil
+}
+
+func (e *fakeEngine) Logs(ctx context.Context, nodeName string) (string, error) {
+	return "copying\nFailed to copy: no space left on device\n", nil
+}
+
+func TestManager_NodeEngine(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, command string, args ...string) (stdout, stderr string, err error) {
+			t.Errorf("Unexpected bv command for a node with another engine: %s %v", command, args)
+			return "", "", nil
+		},
+	}
+	var completedStatus string
+	var errorMessage *string
+	db := &mockDatabase{
+		updateUploadCompletionFunc: func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errMsg *string) error {
+			completedStatus, errorMessage = status, errMsg
+			return nil
+		},
+	}
+	manager := NewManager(executor, db, logrus.New())
+
+	percent, completed, total := 40.0, 4, 10
+	fake := &fakeEngine{status: &engine.Status{Running: true, ProgressPercent: &percent, ChunksCompleted: &completed, ChunksTotal: &total, Fields: map[string]string{"errors": "0"}, Raw: "stats"}}
+	fake.status.SetState("Running", time.Date(2025, 12, 10, 15, 18, 44, 0, time.UTC))
+	manager.SetNodeEngine("rclone-node", fake)
+	ctx := context.Background()
+
+	if _, err := manager.InitiateUploadWithProtocolData(ctx, "rclone-node", Trigger{Type: TriggerScheduled}, "ethereum", "archive", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	status, err := manager.CheckUploadStatus(ctx, "rclone-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !status.IsRunning || status.Progress["progress_percent"] != "40.00" || status.Progress["chunks_completed"] != "4" ||
+		status.Progress["started_at"] != "2025-12-10T15:18:44Z" || status.Progress["errors"] != "0" || status.RawOutput != "stats" {
+		t.Errorf("Unexpected status from the engine: %+v", status.Progress)
+	}
+
+	// The engine's final status line decides the outcome, and its log is used for failures
+	fake.status = &engine.Status{}
+	fake.status.SetState("Finished with exit code 3", time.Now())
+	result, err := manager.MonitorUpload(ctx, 1, "rclone-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Outcome != OutcomeFailure || completedStatus != "failed" || errorMessage == nil {
+		t.Errorf("Expected a failed upload, got %v, %q", result.Outcome, completedStatus)
+	}
+	if lines, err := manager.FetchJobLogs(ctx, "rclone-node", 1); err != nil || len(lines) != 1 || ClassifyFailure("", lines) != FailureDiskFull {
+		t.Errorf("Expected the engine's last log line, got %v, %v", lines, err)
+	}
+
+	fake.status = &engine.Status{Running: true}
+	if _, err := manager.CancelUpload(ctx, "rclone-node", "test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(fake.calls, ",") != "start rclone-node,status rclone-node,status rclone-node,status rclone-node,cancel rclone-node" {
+		t.Errorf("Unexpected engine calls: %v", fake.calls)
+	}
+
+	// Resetting the engine goes back to bv
+	manager.SetNodeEngine("rclone-node", nil)
+	if manager.engineFor("rclone-node").Name() != engine.BV {
+		t.Errorf("Expected the bv engine after reset, got %s", manager.engineFor("rclone-node").Name())
+	}
+}
+
+// resumingEngine has lost its upload to a restart and resumes it from a checkpoint
+type resumingEngine struct {
+	fakeEngine
+	resumeErr error
+}
+
+func (e *resumingEngine) ResumeUpload(ctx context.Context, nodeName string) (bool, error) {
+	e.calls = append(e.calls, "resume "+nodeName)
+	if e.resumeErr != nil {
+		return false, e.resumeErr
+	}
+	return true, nil
+}
+
+func TestMonitorUpload_ResumesInterrupted(t *testing.T) {
+	tests := []struct {
+		name        string
+		resume      bool
+		resumeErr   error
+		wantOutcome CompletionOutcome
+		wantCalls   string
+		wantError   string
+	}{
+		{name: "resumed", resume: true, wantOutcome: OutcomeRunning, wantCalls: "status s3-node,resume s3-node"},
+		{name: "resume fails", resume: true, resumeErr: errors.New("no S3 credentials"), wantOutcome: OutcomeFailure, wantCalls: "status s3-node,resume s3-node", wantError: "Failed: could not resume the interrupted upload: no S3 credentials"},
+		{name: "not resumed outside the daemon", wantOutcome: OutcomeFailure, wantCalls: "status s3-node", wantError: "Failed: no S3 upload has run for the node since snapperd started"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errorMessage *string
+			var recorded []string
+			db := &mockDatabase{
+				updateUploadCompletionFunc: func(ctx context.Context, uploadID int64, completedAt time.Time, status string, completionMessage *string, errMsg *string) error {
+					errorMessage = errMsg
+					return nil
+				},
+				recordUploadResumedFunc: func(ctx context.Context, uploadID int64, nodeName string, resumedAt time.Time) error {
+					recorded = append(recorded, fmt.Sprintf("%d %s", uploadID, nodeName))
+					return nil
+				},
+			}
+			manager := NewManager(&mockExecutor{}, db, logrus.New())
+			manager.SetResumeInterrupted(tt.resume)
+			fake := &resumingEngine{resumeErr: tt.resumeErr}
+			fake.status = &engine.Status{NotFound: true, Status: "Failed: no S3 upload has run for the node since snapperd started"}
+			manager.SetNodeEngine("s3-node", fake)
+
+			result, err := manager.MonitorUpload(context.Background(), 1, "s3-node")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Outcome != tt.wantOutcome {
+				t.Errorf("Expected outcome %v, got %v", tt.wantOutcome, result.Outcome)
+			}
+			if strings.Join(fake.calls, ",") != tt.wantCalls {
+				t.Errorf("Unexpected engine calls: %v", fake.calls)
+			}
+			if tt.wantError == "" && errorMessage != nil {
+				t.Errorf("Expected the upload to stay running, got error %q", *errorMessage)
+			}
+			if tt.wantError != "" && (errorMessage == nil || *errorMessage != tt.wantError) {
+				t.Errorf("Expected error %q, got %v", tt.wantError, errorMessage)
+			}
+			wantRecorded := ""
+			if tt.wantOutcome == OutcomeRunning {
+				wantRecorded = "1 s3-node"
+			}
+			if strings.Join(recorded, ",") != wantRecorded {
+				t.Errorf("Expected resume actions %q, got %v", wantRecorded, recorded)
+			}
+		})
+	}
+}
+
+func TestMonitorUpload_RecordsCompression(t *testing.T) {
+	var recorded string
+	db := &mockDatabase{
+		setUploadCompressionFunc: func(ctx context.Context, uploadID int64, algorithm string, level int, rawBytes, compressedBytes int64) error {
+			recorded = fmt.Sprintf("%d %s %d %d %d", uploadID, algorithm, level, rawBytes, compressedBytes)
+			return nil
+		},
+	}
+	manager := NewManager(&mockExecutor{}, db, logrus.New())
+	report := &engine.CompressionReport{Algorithm: engine.CompressionZstd, Level: 3, RawBytes: 4000, CompressedBytes: 1000}
+	fake := &fakeEngine{status: &engine.Status{Compression: report}}
+	fake.status.SetState("Finished with exit code 0", time.Now())
+	manager.SetNodeEngine("s3-node", fake)
+
+	result, err := manager.MonitorUpload(context.Background(), 5, "s3-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Outcome != OutcomeSuccess || result.Compression != report {
+		t.Errorf("Expected a successful upload with its compression, got %v, %+v", result.Outcome, result.Compression)
+	}
+	if recorded != "5 zstd 3 4000 1000" {
+		t.Errorf("Expected the compression to be stored, got %q", recorded)
+	}
+	if report.Ratio() != 4 {
+		t.Errorf("Expected a ratio of 4, got %v", report.Ratio())
+	}
+}
+
+func TestMonitorUpload_RecordsSize(t *testing.T) {
+	sizes := make(map[int64]int64)
+	db := &mockDatabase{
+		setUploadSizeFunc: func(ctx context.Context, uploadID int64, sizeBytes int64) error {
+			sizes[uploadID] = sizeBytes
+			return nil
+		},
+	}
+	manager := NewManager(&mockExecutor{}, db, logrus.New())
+	size := int64(5000)
+	fake := &fakeEngine{status: &engine.Status{SizeBytes: &size, Location: "s3:snapshots/rclone-node"}}
+	fake.status.SetState("Finished with exit code 0", time.Now())
+	manager.SetNodeEngine("rclone-node", fake)
+
+	result, err := manager.MonitorUpload(context.Background(), 5, "rclone-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SizeBytes == nil || *result.SizeBytes != 5000 || sizes[5] != 5000 {
+		t.Errorf("Expected the reported size to be stored, got %v, %v", result.SizeBytes, sizes)
+	}
+	if result.Location != "s3:snapshots/rclone-node" {
+		t.Errorf("Expected the reported location, got %q", result.Location)
+	}
+
+	// Without a reported size, the compressed size is the bytes uploaded
+	fake.status = &engine.Status{Compression: &engine.CompressionReport{Algorithm: engine.CompressionZstd, RawBytes: 4000, CompressedBytes: 1000}}
+	fake.status.SetState("Finished with exit code 0", time.Now())
+	if _, err := manager.MonitorUpload(context.Background(), 6, "rclone-node"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sizes[6] != 1000 {
+		t.Errorf("Expected the compressed size to be stored, got %v", sizes)
+	}
+
+	// A failed upload has no snapshot size
+	fake.status = &engine.Status{SizeBytes: &size, Location: "s3:snapshots/rclone-node"}
+	fake.status.SetState("Finished with exit code 1", time.Now())
+	result, err = manager.MonitorUpload(context.Background(), 7, "rclone-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := sizes[7]; ok || result.Location != "" {
+		t.Errorf("Expected no size or location for a failed upload, got %v, %q", sizes, result.Location)
+	}
+}
+
+func TestCheckUploadStatus_TruncatesLargeOutput(t *testing.T) {
+	manager := NewManager(&mockExecutor{}, &mockDatabase{}, logrus.New())
+	logs := strings.Repeat("panicked at worker.rs: stack frame\n", 100000)
+	fake := &fakeEngine{status: &engine.Status{Fields: map[string]string{"logs": logs, "restart_count": "0"}, Raw: "status: Failed\n" + logs}}
+	fake.status.SetState("Finished with exit code 101 and message `"+logs+"`", time.Now())
+	manager.SetNodeEngine("bv-node", fake)
+
+	status, err := manager.CheckUploadStatus(context.Background(), "bv-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	raw := status.RawOutput
+	if len(raw) > maxRawOutputBytes+64 || !strings.HasPrefix(raw, "status: Failed\n") || !executor.IsTruncated(raw) {
+		t.Errorf("Expected the raw output truncated to its start and end, got %d bytes", len(raw))
+	}
+	if _, ok := status.Progress["raw_output"]; ok {
+		t.Errorf("Expected the raw output kept out of the progress")
+	}
+	for _, key := range []string{"logs", "status"} {
+		if value := status.Progress[key].(string); len(value) > maxFieldBytes+64 || !executor.IsTruncated(value) {
+			t.Errorf("Expected %s to be truncated, got %d bytes", key, len(value))
+		}
+	}
+	if status.Progress["restart_count"] != "0" {
+		t.Errorf("Expected short fields unchanged, got %v", status.Progress["restart_count"])
+	}
+}
+
+func TestCheckUploadStatus_PartialProgress(t *testing.T) {
+	manager := NewManager(&mockExecutor{}, &mockDatabase{}, logrus.New())
+	percent := 1012.5
+	completed := 4200
+	fake := &fakeEngine{status: &engine.Status{Running: true, ProgressPercent: &percent, ChunksCompleted: &completed}}
+	fake.status.SetState("Running", time.Now())
+	manager.SetNodeEngine("bv-node", fake)
+
+	status, err := manager.CheckUploadStatus(context.Background(), "bv-node")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status.Progress["progress_percent"] != "100.00" {
+		t.Errorf("Expected the percentage capped at 100, got %v", status.Progress["progress_percent"])
+	}
+	if status.Progress["chunks_completed"] != "4200" {
+		t.Errorf("Expected the completed chunks without a total, got %v", status.Progress["chunks_completed"])
+	}
+	if _, ok := status.Progress["chunks_total"]; ok {
+		t.Errorf("Expected no chunk total, got %v", status.Progress["chunks_total"])
+	}
+
+	gotPercent, gotCompleted, gotTotal := manager.extractProgressData(status.Progress)
+	if gotPercent == nil || *gotPercent != 100 || gotCompleted == nil || *gotCompleted != 4200 || gotTotal != nil {
+		t.Errorf("Expected 100%% and 4200 chunks stored, got %v, %v, %v", gotPercent, gotCompleted, gotTotal)
+	}
+}
+
+func TestMonitorUpload_RecordsStatusOutputOnTransitions(t *testing.T) {
+	var recorded []string
+	lastState := ""
+	db := &mockDatabase{
+		recordStatusOutputFunc: func(ctx context.Context, uploadID int64, state, rawOutput string, recordedAt time.Time) (bool, error) {
+			if state == lastState {
+				return false, nil
+			}
+			lastState = state
+			recorded = append(recorded, state+": "+rawOutput)
+			return true, nil
+		},
+	}
+	manager := NewManager(&mockExecutor{}, db, logrus.New())
+	fake := &fakeEngine{}
+	manager.SetNodeEngine("s3-node", fake)
+	startedAt := time.Date(2025, 12, 10, 15, 18, 44, 0, time.UTC)
+
+	for i, state := range []string{"Running", "Running", "Running", "Finished with exit code 0"} {
+		fake.status = &engine.Status{Running: state == "Running", Raw: fmt.Sprintf("check %d", i)}
+		fake.status.SetState(state, startedAt)
+		if _, err := manager.MonitorUpload(context.Background(), 1, "s3-node"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if strings.Join(recorded, ",") != "Running: check 0,Finished with exit code 0: check 3" {
+		t.Errorf("Expected the output stored once per state, got %v", recorded)
+	}
+}